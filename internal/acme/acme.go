@@ -49,6 +49,7 @@ import (
 	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
 	"github.com/drakkan/sftpgo/v2/internal/ftpd"
 	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/s3gw"
 	"github.com/drakkan/sftpgo/v2/internal/telemetry"
 	"github.com/drakkan/sftpgo/v2/internal/util"
 	"github.com/drakkan/sftpgo/v2/internal/version"
@@ -740,6 +741,8 @@ func (c *Configuration) renewCertificates() error {
 		}
 		err = webdavd.ReloadCertificateMgr()
 		acmeLog(logger.LevelInfo, "webdav certificates manager reloaded , error: %v", err)
+		err = s3gw.ReloadCertificateMgr()
+		acmeLog(logger.LevelInfo, "s3gw certificates manager reloaded , error: %v", err)
 		err = telemetry.ReloadCertificateMgr()
 		acmeLog(logger.LevelInfo, "telemetry certificates manager reloaded , error: %v", err)
 	}
@@ -0,0 +1,143 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/config"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/httpd"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/service"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+var (
+	restoreBackupAction string
+	restoreBackupName   string
+	restoreBackupMode   int
+	restoreBackupCmd    = &cobra.Command{
+		Use:   "restorebackup",
+		Short: "Restore a backup uploaded by a backup event action",
+		Long: `This command reads the data provider connection details from the specified
+configuration file, downloads the specified backup from the virtual folder
+configured in the given backup event action, decrypts it if needed and
+restores it.
+
+If no backup name is specified, the available backups are listed.
+This command is not supported for the memory provider.
+For embedded providers like bolt and SQLite you should stop the running SFTPGo
+instance to avoid database corruption.
+
+Please take a look at the usage below to customize the options.`,
+		Run: func(_ *cobra.Command, _ []string) {
+			logger.DisableLogger()
+			logger.EnableConsoleLogger(zerolog.DebugLevel)
+			configDir = util.CleanDirInput(configDir)
+			err := config.LoadConfig(configDir, configFile)
+			if err != nil {
+				logger.WarnToConsole("Unable to load configuration: %v", err)
+				os.Exit(1)
+			}
+			kmsConfig := config.GetKMSConfig()
+			err = kmsConfig.Initialize()
+			if err != nil {
+				logger.ErrorToConsole("unable to initialize KMS: %v", err)
+				os.Exit(1)
+			}
+			providerConf := config.GetProviderConf()
+			if providerConf.Driver == dataprovider.MemoryDataProviderName {
+				logger.ErrorToConsole("memory provider is not supported")
+				os.Exit(1)
+			}
+			// ignore actions and do not apply the declarative configuration
+			providerConf.Actions.Hook = ""
+			providerConf.Actions.ExecuteFor = nil
+			providerConf.Actions.ExecuteOn = nil
+			providerConf.Reconciler.SourceDir = ""
+			logger.InfoToConsole("Initializing provider: %q config file: %q", providerConf.Driver, viper.ConfigFileUsed())
+			err = dataprovider.Initialize(providerConf, configDir, false)
+			if err != nil {
+				logger.ErrorToConsole("Unable to initialize data provider: %v", err)
+				os.Exit(1)
+			}
+			if restoreBackupName == "" {
+				backups, err := common.GetRemoteBackups(restoreBackupAction)
+				if err != nil {
+					logger.ErrorToConsole("Unable to list backups for action %q: %v", restoreBackupAction, err)
+					os.Exit(1)
+				}
+				for _, backup := range backups {
+					logger.InfoToConsole("%s, size: %s, encrypted: %t", backup.Name, util.ByteCountIEC(backup.Size),
+						backup.Encrypted)
+				}
+				return
+			}
+			content, err := common.GetRemoteBackupContent(restoreBackupAction, restoreBackupName, httpd.MaxRestoreSize)
+			if err != nil {
+				logger.ErrorToConsole("Unable to download backup %q: %v", restoreBackupName, err)
+				os.Exit(1)
+			}
+			f, err := os.CreateTemp("", "sftpgo_restore_*.json")
+			if err != nil {
+				logger.ErrorToConsole("Unable to create temporary file: %v", err)
+				os.Exit(1)
+			}
+			_, err = f.Write(content)
+			if errClose := f.Close(); err == nil {
+				err = errClose
+			}
+			if err != nil {
+				os.Remove(f.Name())
+				logger.ErrorToConsole("Unable to write temporary file: %v", err)
+				os.Exit(1)
+			}
+			service := service.Service{
+				LoadDataFrom:  f.Name(),
+				LoadDataMode:  restoreBackupMode,
+				LoadDataClean: true,
+			}
+			if err = service.LoadInitialData(); err != nil {
+				os.Remove(f.Name())
+				logger.ErrorToConsole("Unable to restore backup %q: %v", restoreBackupName, err)
+				os.Exit(1)
+			}
+			logger.InfoToConsole("Backup %q restored", restoreBackupName)
+		},
+	}
+)
+
+func init() {
+	addConfigFlags(restoreBackupCmd)
+	restoreBackupCmd.Flags().StringVar(&restoreBackupAction, "action", "", `Name of the backup event action that
+uploaded the backups`)
+	restoreBackupCmd.MarkFlagRequired("action") //nolint:errcheck
+	restoreBackupCmd.Flags().StringVar(&restoreBackupName, "backup", "", `Name of the backup to restore.
+Leave empty to list the available backups`)
+	restoreBackupCmd.Flags().IntVar(&restoreBackupMode, "mode", 0, `Restore mode:
+  0 - new objects are added, existing objects
+      are updated
+  1 - new objects are added, existing objects
+      are not modified
+`)
+
+	rootCmd.AddCommand(restoreBackupCmd)
+}
@@ -78,12 +78,39 @@ func InitializeActionHandler(handler ActionHandler) {
 	actionHandler = handler
 }
 
+func checkFileLocks(conn *BaseConnection, operation, virtualPath string) error {
+	mask := getLockMaskForOperation(operation)
+	if mask == 0 {
+		return nil
+	}
+	// WebDAV locks are already confirmed by the WebDAV lock system
+	var excludedProtocol string
+	if conn.protocol == ProtocolWebDAV {
+		excludedProtocol = ProtocolWebDAV
+	}
+	if err := FileLocks.CheckAccess(conn.User.Username, virtualPath, conn.ID, excludedProtocol, mask); err != nil {
+		conn.Log(logger.LevelInfo, "denying %s for %q: %v", operation, virtualPath, err)
+		return conn.GetPermissionDeniedError()
+	}
+	return nil
+}
+
 // ExecutePreAction executes a pre-* action and returns the result.
 // The returned status has the following meaning:
 // - 0 not executed
 // - 1 executed using an external hook
 // - 2 executed using the event manager
 func ExecutePreAction(conn *BaseConnection, operation, filePath, virtualPath string, fileSize int64, openFlags int) (int, error) {
+	if operation == OperationPreUpload || operation == OperationPreDownload {
+		if connectionsDrainer.isDraining(&conn.User) {
+			conn.Log(logger.LevelInfo, "denying %s for %q, connections for user %q are draining",
+				operation, virtualPath, conn.User.Username)
+			return 0, conn.GetPermissionDeniedError()
+		}
+	}
+	if err := checkFileLocks(conn, operation, virtualPath); err != nil {
+		return 0, err
+	}
 	var event *notifier.FsEvent
 	hasNotifiersPlugin := plugin.Handler.HasNotifiers()
 	hasHook := util.Contains(Config.Actions.ExecuteOn, operation)
@@ -114,6 +141,7 @@ func ExecutePreAction(conn *BaseConnection, operation, filePath, virtualPath str
 			Role:              event.Role,
 			Timestamp:         event.Timestamp,
 			Email:             conn.User.Email,
+			Attributes:        conn.User.Attributes,
 			Object:            nil,
 		}
 		executedSync, err := eventManager.handleFsEvent(params)
@@ -131,6 +159,7 @@ func ExecutePreAction(conn *BaseConnection, operation, filePath, virtualPath str
 func ExecuteActionNotification(conn *BaseConnection, operation, filePath, virtualPath, target, virtualTarget, sshCmd string,
 	fileSize int64, err error, elapsed int64, metadata map[string]string,
 ) error {
+	publishFsEvent(conn, operation, virtualPath, virtualTarget, fileSize, err, elapsed)
 	hasNotifiersPlugin := plugin.Handler.HasNotifiers()
 	hasHook := util.Contains(Config.Actions.ExecuteOn, operation)
 	hasRules := eventManager.hasFsRules()
@@ -161,6 +190,7 @@ func ExecuteActionNotification(conn *BaseConnection, operation, filePath, virtua
 			Role:              notification.Role,
 			Timestamp:         notification.Timestamp,
 			Email:             conn.User.Email,
+			Attributes:        conn.User.Attributes,
 			Object:            nil,
 			Metadata:          metadata,
 		}
@@ -274,12 +304,17 @@ func (h *defaultActionHandler) handleHTTP(event *notifier.FsEvent) error {
 		return err
 	}
 
-	startTime := time.Now()
-	respCode := 0
-
 	var b bytes.Buffer
 	_ = json.NewEncoder(&b).Encode(event)
 
+	// synchronous notifications must be delivered before returning
+	if dataprovider.IsWebhooksEnabled() && !util.Contains(Config.Actions.ExecuteSync, event.Action) {
+		return dataprovider.EnqueueWebhookDelivery(Config.Actions.Hook, event.Action, b.Bytes())
+	}
+
+	startTime := time.Now()
+	respCode := 0
+
 	resp, err := httpclient.RetryablePost(Config.Actions.Hook, "application/json", &b)
 	if err == nil {
 		respCode = resp.StatusCode
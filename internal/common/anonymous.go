@@ -0,0 +1,184 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+const logSenderAnonymous = "anonymous"
+
+var anonymousRateLimiter *rateLimiter
+
+// AnonymousAccessConfig defines the configuration for the anonymous access.
+// Anonymous logins are mapped to a template user, the template user permissions
+// are restricted to list and download
+type AnonymousAccessConfig struct {
+	// Username of an existing user used as template for the anonymous sessions.
+	// Empty means disabled
+	TemplateUser string `json:"template_user" mapstructure:"template_user"`
+	// FTP usernames, case insensitive, mapped to the template user. Any password
+	// is accepted. Empty means no anonymous FTP access
+	FTPUsernames []string `json:"ftp_usernames" mapstructure:"ftp_usernames"`
+	// Public SFTP credentials mapped to the template user. An empty username or
+	// password means no anonymous SFTP access
+	SFTPUsername string `json:"sftp_username" mapstructure:"sftp_username"`
+	SFTPPassword string `json:"sftp_password" mapstructure:"sftp_password"`
+	// Names of the virtual folders of the template user accessible to the anonymous
+	// sessions. If set, the other virtual folders are not available and the template
+	// user root directory can only be listed
+	Folders []string `json:"folders" mapstructure:"folders"`
+	// Rate limiter for the anonymous logins, applied in addition to the ones
+	// configured for the protocol. Supported protocols: "SSH", "FTP"
+	RateLimiter RateLimiterConfig `json:"rate_limiter" mapstructure:"rate_limiter"`
+}
+
+// IsEnabled returns true if the anonymous access is enabled
+func (c *AnonymousAccessConfig) IsEnabled() bool {
+	return c.TemplateUser != ""
+}
+
+func (c *AnonymousAccessConfig) initialize() error {
+	anonymousRateLimiter = nil
+	c.TemplateUser = strings.TrimSpace(c.TemplateUser)
+	if !c.IsEnabled() {
+		return nil
+	}
+	var ftpUsernames []string
+	for _, username := range c.FTPUsernames {
+		username = strings.TrimSpace(username)
+		if username != "" {
+			ftpUsernames = append(ftpUsernames, strings.ToLower(username))
+		}
+	}
+	c.FTPUsernames = util.RemoveDuplicates(ftpUsernames, false)
+	c.SFTPUsername = strings.TrimSpace(c.SFTPUsername)
+	c.Folders = util.RemoveDuplicates(c.Folders, true)
+	if c.RateLimiter.isEnabled() {
+		if err := c.RateLimiter.validate(); err != nil {
+			return fmt.Errorf("invalid rate limiter: %w", err)
+		}
+		for _, protocol := range c.RateLimiter.Protocols {
+			if protocol != ProtocolSSH && protocol != ProtocolFTP {
+				return fmt.Errorf("invalid rate limiter protocol %q", protocol)
+			}
+		}
+		anonymousRateLimiter = c.RateLimiter.getLimiter()
+	}
+	logger.Info(logSenderAnonymous, "", "anonymous access enabled, template user %q, FTP usernames: %v, "+
+		"SFTP enabled: %t, folders: %v", c.TemplateUser, c.FTPUsernames, c.isSFTPEnabled(), c.Folders)
+	return nil
+}
+
+func (c *AnonymousAccessConfig) isSFTPEnabled() bool {
+	return c.SFTPUsername != "" && c.SFTPPassword != ""
+}
+
+func (c *AnonymousAccessConfig) isAnonymousLogin(username, password, protocol string) bool {
+	if !c.IsEnabled() {
+		return false
+	}
+	switch protocol {
+	case ProtocolFTP:
+		return util.Contains(c.FTPUsernames, strings.ToLower(username))
+	case ProtocolSSH:
+		return c.isSFTPEnabled() && username == c.SFTPUsername &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(c.SFTPPassword)) == 1
+	default:
+		return false
+	}
+}
+
+func (c *AnonymousAccessConfig) restrictUser(user *dataprovider.User) {
+	if len(c.Folders) > 0 {
+		var folderPaths []string
+		folders := make([]vfs.VirtualFolder, 0, len(c.Folders))
+		for _, folder := range user.VirtualFolders {
+			if util.Contains(c.Folders, folder.Name) {
+				folders = append(folders, folder)
+				folderPaths = append(folderPaths, folder.VirtualPath)
+			}
+		}
+		user.VirtualFolders = folders
+		// the root directory only allows to list the configured folders, each
+		// folder keeps the permissions the template user has for it
+		permissions := make(map[string][]string)
+		permissions["/"] = []string{}
+		if user.HasPerm(dataprovider.PermListItems, "/") {
+			permissions["/"] = []string{dataprovider.PermListItems}
+		}
+		for _, folderPath := range folderPaths {
+			permissions[folderPath] = user.GetPermissionsForPath(folderPath)
+		}
+		for p, perms := range user.Permissions {
+			if _, ok := permissions[p]; ok {
+				continue
+			}
+			for _, folderPath := range folderPaths {
+				if strings.HasPrefix(p, path.Clean(folderPath)+"/") {
+					permissions[p] = perms
+				}
+			}
+		}
+		user.Permissions = permissions
+	}
+	user.SetReadOnlyPermissions()
+	user.Filters.TwoFactorAuthProtocols = nil
+	user.Filters.TOTPConfig.Enabled = false
+}
+
+// CheckAnonymousLogin checks if the specified credentials must be mapped to the
+// anonymous template user. It returns false if the login is not an anonymous
+// login, the credentials must be checked as usual in this case
+func CheckAnonymousLogin(username, password, ip, protocol string) (dataprovider.User, bool, error) {
+	c := &Config.AnonymousAccess
+	if !c.isAnonymousLogin(username, password, protocol) {
+		return dataprovider.User{}, false, nil
+	}
+	// an existing user is never shadowed by the anonymous access
+	if _, err := dataprovider.UserExists(username, ""); err == nil {
+		logger.Warn(logSenderAnonymous, "", "%s username %q is configured for anonymous access but a user with "+
+			"the same name exists, anonymous login not allowed", protocol, username)
+		return dataprovider.User{}, false, nil
+	}
+	if anonymousRateLimiter != nil && util.Contains(c.RateLimiter.Protocols, protocol) {
+		if _, err := anonymousRateLimiter.Wait(ip, protocol); err != nil {
+			logger.Info(logSenderAnonymous, "", "anonymous %s login from ip %q rejected: %v", protocol, ip, err)
+			return dataprovider.User{}, true, err
+		}
+	}
+	user, err := dataprovider.GetUserWithGroupSettings(c.TemplateUser, "")
+	if err != nil {
+		logger.Warn(logSenderAnonymous, "", "unable to get anonymous template user %q: %v", c.TemplateUser, err)
+		return user, true, errors.New("anonymous access not available")
+	}
+	if err := user.CheckLoginConditions(); err != nil {
+		logger.Info(logSenderAnonymous, "", "anonymous %s login from ip %q rejected: %v", protocol, ip, err)
+		return user, true, err
+	}
+	c.restrictUser(&user)
+	logger.Info(logSenderAnonymous, "", "anonymous %s login from ip %q, provided username %q, template user %q",
+		protocol, ip, username, user.Username)
+	return user, true, nil
+}
@@ -0,0 +1,165 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+const (
+	// the pending restores for archived files are checked at this interval
+	archiveRestoreCheckInterval = 10 * time.Minute
+	// restores not completed within this interval are no longer tracked
+	archiveRestoreMaxWait = 7 * 24 * time.Hour
+)
+
+var archiveRestores = newArchiveRestoresManager()
+
+// archiveRestore defines a pending restore for an archived file, for example
+// a S3 Glacier object, started when a client tried to read it
+type archiveRestore struct {
+	username    string
+	virtualPath string
+	fsPath      string
+	protocol    string
+	remoteAddr  string
+	createdAt   time.Time
+}
+
+func (r *archiveRestore) getKey() string {
+	return r.username + "_" + r.virtualPath
+}
+
+// isRestored returns true if the archived file is available, the file size is also returned
+func (r *archiveRestore) isRestored(user *dataprovider.User) (bool, int64, error) {
+	fs, err := user.GetFilesystemForPath(r.virtualPath, xid.New().String())
+	if err != nil {
+		return false, 0, err
+	}
+	restorer, ok := fs.(vfs.FsArchiveRestorer)
+	if !ok {
+		return false, 0, errors.New("archive restore is not supported by this filesystem")
+	}
+	restored, err := restorer.IsArchiveRestored(r.fsPath)
+	if err != nil || !restored {
+		return false, 0, err
+	}
+	var size int64
+	if info, err := fs.Stat(r.fsPath); err == nil {
+		size = info.Size()
+	}
+	return true, size, nil
+}
+
+type archiveRestoresManager struct {
+	sync.Mutex
+	restores map[string]archiveRestore
+}
+
+func newArchiveRestoresManager() *archiveRestoresManager {
+	return &archiveRestoresManager{
+		restores: make(map[string]archiveRestore),
+	}
+}
+
+func (m *archiveRestoresManager) add(restore archiveRestore) {
+	m.Lock()
+	defer m.Unlock()
+
+	key := restore.getKey()
+	if _, ok := m.restores[key]; ok {
+		return
+	}
+	m.restores[key] = restore
+	logger.Debug(logSender, "", "tracking archive restore for user %q, path %q", restore.username, restore.virtualPath)
+}
+
+func (m *archiveRestoresManager) remove(key string) {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.restores, key)
+}
+
+func (m *archiveRestoresManager) getRestores() []archiveRestore {
+	m.Lock()
+	defer m.Unlock()
+
+	restores := make([]archiveRestore, 0, len(m.restores))
+	for _, r := range m.restores {
+		restores = append(restores, r)
+	}
+	return restores
+}
+
+// check notifies the completed restores using the archive-restore filesystem event
+func (m *archiveRestoresManager) check() {
+	for _, r := range m.getRestores() {
+		if time.Since(r.createdAt) > archiveRestoreMaxWait {
+			logger.Info(logSender, "", "archive restore for user %q, path %q not completed after %s, stop tracking",
+				r.username, r.virtualPath, archiveRestoreMaxWait)
+			m.remove(r.getKey())
+			continue
+		}
+		user, err := dataprovider.GetUserWithGroupSettings(r.username, "")
+		if err != nil {
+			logger.Warn(logSender, "", "unable to get user %q to check archive restore: %v", r.username, err)
+			if errors.Is(err, util.ErrNotFound) {
+				m.remove(r.getKey())
+			}
+			continue
+		}
+		restored, size, err := r.isRestored(&user)
+		if err != nil {
+			logger.Warn(logSender, "", "unable to check archive restore for user %q, path %q: %v",
+				r.username, r.virtualPath, err)
+			continue
+		}
+		if !restored {
+			continue
+		}
+		m.remove(r.getKey())
+		logger.Info(logSender, "", "archive restore completed for user %q, path %q, size: %d",
+			r.username, r.virtualPath, size)
+		conn := NewBaseConnection(xid.New().String(), r.protocol, "", r.remoteAddr, user)
+		ExecuteActionNotification(conn, operationArchiveRestore, r.fsPath, r.virtualPath, "", "", "", //nolint:errcheck
+			size, nil, time.Since(r.createdAt).Milliseconds(), nil)
+	}
+}
+
+// TrackArchiveRestore tracks the restore started reading an archived file,
+// an event is triggered when the restore completes and the file is available
+func (c *BaseConnection) TrackArchiveRestore(fsPath, virtualPath string, err error) {
+	if !errors.Is(err, vfs.ErrRestoreInProgress) {
+		return
+	}
+	archiveRestores.add(archiveRestore{
+		username:    c.User.Username,
+		virtualPath: virtualPath,
+		fsPath:      fsPath,
+		protocol:    c.protocol,
+		remoteAddr:  c.remoteAddr,
+		createdAt:   time.Now(),
+	})
+}
@@ -0,0 +1,233 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/metric"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// Supported kinds for the monitored certificates
+const (
+	CertificateKindTLS     = "TLS certificate"
+	CertificateKindSSHHost = "SSH host certificate"
+	CertificateKindUser    = "user certificate"
+)
+
+const (
+	certExpirationEvent      = "Certificate expiration"
+	certExpiryCheckInterval  = "@every 12h"
+	certExpiryUsersPageLimit = 100
+)
+
+var (
+	certExpiryMonitor = newCertificatesMonitor()
+)
+
+// CertExpiryConfig defines the thresholds, as number of days before the expiration,
+// to start notifying about expiring certificates. 0 means disabled
+type CertExpiryConfig struct {
+	// Threshold for the TLS certificates used by the FTP, WebDAV, HTTP and telemetry services
+	TLSCertificates int `json:"tls_certificates" mapstructure:"tls_certificates"`
+	// Threshold for the SSH host certificates
+	SSHHostCertificates int `json:"ssh_host_certificates" mapstructure:"ssh_host_certificates"`
+	// Threshold for the TLS certificates configured for the users
+	UserCertificates int `json:"user_certificates" mapstructure:"user_certificates"`
+}
+
+func (c *CertExpiryConfig) isEnabled() bool {
+	return c.TLSCertificates > 0 || c.SSHHostCertificates > 0 || c.UserCertificates > 0
+}
+
+func (c *CertExpiryConfig) getThreshold(kind string) time.Duration {
+	var days int
+	switch kind {
+	case CertificateKindTLS:
+		days = c.TLSCertificates
+	case CertificateKindSSHHost:
+		days = c.SSHHostCertificates
+	case CertificateKindUser:
+		days = c.UserCertificates
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+type monitoredCertificate struct {
+	kind      string
+	name      string
+	subject   string
+	expiresAt time.Time
+}
+
+func (c *monitoredCertificate) getKey() string {
+	return c.kind + "_" + c.name
+}
+
+func (c *monitoredCertificate) check(threshold time.Duration) {
+	if threshold <= 0 {
+		return
+	}
+	remaining := time.Until(c.expiresAt)
+	if remaining > threshold {
+		return
+	}
+	var err error
+	if remaining <= 0 {
+		err = fmt.Errorf("%s %q expired on %s", c.kind, c.subject, c.expiresAt.UTC().Format(time.RFC3339))
+	} else {
+		err = fmt.Errorf("%s %q will expire on %s", c.kind, c.subject, c.expiresAt.UTC().Format(time.RFC3339))
+	}
+	logger.Warn(logSender, "", "certificate %q: %v", c.name, err)
+	params := EventParams{
+		Name:       c.name,
+		Event:      certExpirationEvent,
+		Status:     2,
+		ObjectName: c.subject,
+		ObjectType: c.kind,
+		Timestamp:  time.Now().UnixNano(),
+	}
+	params.AddError(err)
+	HandleCertificateEvent(params)
+}
+
+type certificatesMonitor struct {
+	sync.RWMutex
+	certs map[string]monitoredCertificate
+}
+
+func newCertificatesMonitor() *certificatesMonitor {
+	return &certificatesMonitor{
+		certs: make(map[string]monitoredCertificate),
+	}
+}
+
+func (m *certificatesMonitor) add(cert monitoredCertificate) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.certs[cert.getKey()] = cert
+	metric.UpdateCertificateExpiration(cert.kind, cert.name, cert.expiresAt)
+}
+
+func (m *certificatesMonitor) remove(kind, name string) {
+	m.Lock()
+	defer m.Unlock()
+
+	cert := monitoredCertificate{
+		kind: kind,
+		name: name,
+	}
+	if _, ok := m.certs[cert.getKey()]; ok {
+		delete(m.certs, cert.getKey())
+		metric.RemoveCertificateExpiration(kind, name)
+	}
+}
+
+func (m *certificatesMonitor) getCertificates() []monitoredCertificate {
+	m.RLock()
+	defer m.RUnlock()
+
+	certs := make([]monitoredCertificate, 0, len(m.certs))
+	for _, cert := range m.certs {
+		certs = append(certs, cert)
+	}
+	return certs
+}
+
+func (m *certificatesMonitor) checkUsersCertificates(threshold time.Duration) {
+	if threshold <= 0 {
+		return
+	}
+	offset := 0
+	for {
+		users, err := dataprovider.GetUsers(certExpiryUsersPageLimit, offset, dataprovider.OrderASC, "")
+		if err != nil {
+			logger.Error(logSender, "", "unable to get users to check certificates expiration: %v", err)
+			return
+		}
+		for idx := range users {
+			for _, certificate := range getUserCertificates(&users[idx]) {
+				certificate.check(threshold)
+			}
+		}
+		if len(users) < certExpiryUsersPageLimit {
+			return
+		}
+		offset += len(users)
+	}
+}
+
+func (m *certificatesMonitor) check() {
+	for _, cert := range m.getCertificates() {
+		cert.check(Config.CertExpiry.getThreshold(cert.kind))
+	}
+	m.checkUsersCertificates(Config.CertExpiry.getThreshold(CertificateKindUser))
+}
+
+func getUserCertificates(user *dataprovider.User) []monitoredCertificate {
+	var result []monitoredCertificate
+	for _, cert := range user.Filters.TLSCerts {
+		derBlock, _ := pem.Decode([]byte(cert))
+		if derBlock == nil {
+			continue
+		}
+		crt, err := x509.ParseCertificate(derBlock.Bytes)
+		if err != nil {
+			continue
+		}
+		result = append(result, monitoredCertificate{
+			kind:      CertificateKindUser,
+			name:      user.Username,
+			subject:   crt.Subject.String(),
+			expiresAt: crt.NotAfter,
+		})
+	}
+	return result
+}
+
+// AddMonitoredCertificate adds, or updates, a certificate to check for expiration.
+// name must be unique for the specified kind
+func AddMonitoredCertificate(kind, name, subject string, expiresAt time.Time) {
+	certExpiryMonitor.add(monitoredCertificate{
+		kind:      kind,
+		name:      name,
+		subject:   subject,
+		expiresAt: expiresAt,
+	})
+}
+
+// RemoveMonitoredCertificate removes a certificate from the expiration checks
+func RemoveMonitoredCertificate(kind, name string) {
+	certExpiryMonitor.remove(kind, name)
+}
+
+func startCertificatesExpiryCheck() {
+	if !Config.CertExpiry.isEnabled() {
+		logger.Debug(logSender, "", "certificates expiry check disabled")
+		return
+	}
+	_, err := eventScheduler.AddFunc(certExpiryCheckInterval, certExpiryMonitor.check)
+	util.PanicOnError(err)
+	logger.Info(logSender, "", "scheduled certificates expiry check, schedule %q, thresholds %+v",
+		certExpiryCheckInterval, Config.CertExpiry)
+}
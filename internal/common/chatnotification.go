@@ -0,0 +1,146 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/httpclient"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+const (
+	chatNotificationTimeout = 30 * time.Second
+)
+
+type slackMessage struct {
+	Text    string `json:"text"`
+	Channel string `json:"channel,omitempty"`
+}
+
+type teamsMessage struct {
+	Text string `json:"text"`
+}
+
+type discordMessage struct {
+	Content string `json:"content"`
+}
+
+type matrixMessage struct {
+	MsgType string `json:"msgtype"`
+	Body    string `json:"body"`
+}
+
+// getChatNotificationRequest returns the HTTP request to send the specified
+// message using the configured chat platform
+func getChatNotificationRequest(ctx context.Context, c *dataprovider.EventActionChatConfig, message string) (*http.Request, error) {
+	var payload any
+	method := http.MethodPost
+	endpoint := c.Endpoint
+
+	switch c.Platform {
+	case dataprovider.ChatPlatformSlack:
+		payload = slackMessage{
+			Text:    message,
+			Channel: c.Channel,
+		}
+	case dataprovider.ChatPlatformTeams:
+		payload = teamsMessage{
+			Text: message,
+		}
+	case dataprovider.ChatPlatformDiscord:
+		payload = discordMessage{
+			Content: message,
+		}
+	case dataprovider.ChatPlatformMatrix:
+		payload = matrixMessage{
+			MsgType: "m.text",
+			Body:    message,
+		}
+		method = http.MethodPut
+		endpoint = fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+			strings.TrimSuffix(c.Endpoint, "/"), url.PathEscape(c.Channel), xid.New().String())
+	default:
+		return nil, fmt.Errorf("unsupported chat platform: %d", c.Platform)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Platform == dataprovider.ChatPlatformMatrix {
+		req.Header.Set("Authorization", "Bearer "+c.Token.GetPayload())
+	}
+	return req, nil
+}
+
+func executeChatRuleAction(c dataprovider.EventActionChatConfig, params *EventParams) error {
+	if err := c.TryDecryptToken(); err != nil {
+		return err
+	}
+	addObjectData := false
+	if params.Object != nil {
+		if strings.Contains(c.Message, objDataPlaceholder) || strings.Contains(c.Message, objDataPlaceholderString) {
+			addObjectData = true
+		}
+	}
+	replacements := params.getStringReplacements(addObjectData, false)
+	replacer := strings.NewReplacer(replacements...)
+	message := replaceWithReplacer(c.Message, replacer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), chatNotificationTimeout)
+	defer cancel()
+
+	req, err := getChatNotificationRequest(ctx, &c, message)
+	if err != nil {
+		return err
+	}
+	client := httpclient.GetHTTPClient()
+	defer client.CloseIdleConnections()
+
+	startTime := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		eventManagerLog(logger.LevelDebug, "unable to send chat notification, platform: %d, elapsed: %s, err: %v",
+			c.Platform, time.Since(startTime), err)
+		return fmt.Errorf("error sending chat notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	eventManagerLog(logger.LevelDebug, "chat notification sent, platform: %d, elapsed: %s, status code: %d",
+		c.Platform, time.Since(startTime), resp.StatusCode)
+	if resp.StatusCode < http.StatusOK || resp.StatusCode > http.StatusNoContent {
+		if rb, err := io.ReadAll(io.LimitReader(resp.Body, 2048)); err == nil {
+			eventManagerLog(logger.LevelDebug, "error chat notification response, platform: %d: %s", c.Platform, string(rb))
+		}
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
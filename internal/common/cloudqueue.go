@@ -0,0 +1,80 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/httpclient"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+const (
+	cloudQueueTimeout = 30 * time.Second
+)
+
+func sendCloudQueueRequest(req *http.Request) error {
+	client := httpclient.GetHTTPClient()
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending cloud queue request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode > http.StatusNoContent {
+		if rb, err := io.ReadAll(io.LimitReader(resp.Body, 2048)); err == nil {
+			eventManagerLog(logger.LevelDebug, "error cloud queue response: %s", string(rb))
+		}
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func executeCloudQueueRuleAction(c dataprovider.EventActionCloudQueueConfig, params *EventParams) error {
+	addObjectData := false
+	if params.Object != nil {
+		if strings.Contains(c.Message, objDataPlaceholder) || strings.Contains(c.Message, objDataPlaceholderString) {
+			addObjectData = true
+		}
+	}
+	replacements := params.getStringReplacements(addObjectData, false)
+	replacer := strings.NewReplacer(replacements...)
+	message := replaceWithReplacer(c.Message, replacer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cloudQueueTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	var err error
+	switch c.Service {
+	case dataprovider.CloudQueueSQS, dataprovider.CloudQueueSNS:
+		err = publishAWSMessage(ctx, &c, message)
+	case dataprovider.CloudQueuePubSub:
+		err = publishPubSubMessage(ctx, &c, message)
+	default:
+		err = fmt.Errorf("unsupported cloud queue service: %d", c.Service)
+	}
+	eventManagerLog(logger.LevelDebug, "cloud queue message published to %q, service: %d, elapsed: %s, error: %v",
+		c.Target, c.Service, time.Since(startTime), err)
+	return err
+}
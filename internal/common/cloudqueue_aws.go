@@ -0,0 +1,81 @@
+//go:build !nos3
+// +build !nos3
+
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+)
+
+// getAWSQueueRequestValues returns the query API parameters and the signing
+// service name for the specified configuration
+func getAWSQueueRequestValues(c *dataprovider.EventActionCloudQueueConfig, message string) (url.Values, string) {
+	values := url.Values{}
+	if c.Service == dataprovider.CloudQueueSNS {
+		values.Set("Action", "Publish")
+		values.Set("Version", "2010-03-31")
+		values.Set("TopicArn", c.Target)
+		values.Set("Message", message)
+		return values, "sns"
+	}
+	values.Set("Action", "SendMessage")
+	values.Set("Version", "2012-11-05")
+	values.Set("QueueUrl", c.Target)
+	values.Set("MessageBody", message)
+	return values, "sqs"
+}
+
+func publishAWSMessage(ctx context.Context, c *dataprovider.EventActionCloudQueueConfig, message string) error {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(c.Region))
+	if err != nil {
+		return fmt.Errorf("unable to load AWS config: %w", err)
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get AWS credentials: %w", err)
+	}
+	values, service := getAWSQueueRequestValues(c, message)
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.%s.amazonaws.com/", service, c.Region)
+	}
+	body := values.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	payloadHash := sha256.Sum256([]byte(body))
+	signer := v4.NewSigner()
+	err = signer.SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), service, c.Region, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to sign AWS request: %w", err)
+	}
+	return sendCloudQueueRequest(req)
+}
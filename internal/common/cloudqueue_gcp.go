@@ -0,0 +1,78 @@
+//go:build !nogcs
+// +build !nogcs
+
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"golang.org/x/oauth2/google"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+)
+
+const (
+	pubSubDefaultEndpoint = "https://pubsub.googleapis.com"
+	pubSubScope           = "https://www.googleapis.com/auth/pubsub"
+)
+
+type pubSubMessage struct {
+	Data string `json:"data"`
+}
+
+type pubSubPublishRequest struct {
+	Messages []pubSubMessage `json:"messages"`
+}
+
+func publishPubSubMessage(ctx context.Context, c *dataprovider.EventActionCloudQueueConfig, message string) error {
+	tokenSource, err := google.DefaultTokenSource(ctx, pubSubScope)
+	if err != nil {
+		return fmt.Errorf("unable to get Google Cloud credentials: %w", err)
+	}
+	token, err := tokenSource.Token()
+	if err != nil {
+		return fmt.Errorf("unable to get Google Cloud access token: %w", err)
+	}
+	body, err := json.Marshal(pubSubPublishRequest{
+		Messages: []pubSubMessage{
+			{
+				Data: base64.StdEncoding.EncodeToString([]byte(message)),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = pubSubDefaultEndpoint
+	}
+	endpoint = fmt.Sprintf("%s/v1/%s:publish", strings.TrimSuffix(endpoint, "/"), c.Target)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token.SetAuthHeader(req)
+	return sendCloudQueueRequest(req)
+}
@@ -47,25 +47,26 @@ import (
 
 // constants
 const (
-	logSender              = "common"
-	uploadLogSender        = "Upload"
-	downloadLogSender      = "Download"
-	renameLogSender        = "Rename"
-	rmdirLogSender         = "Rmdir"
-	mkdirLogSender         = "Mkdir"
-	symlinkLogSender       = "Symlink"
-	removeLogSender        = "Remove"
-	chownLogSender         = "Chown"
-	chmodLogSender         = "Chmod"
-	chtimesLogSender       = "Chtimes"
-	copyLogSender          = "Copy"
-	truncateLogSender      = "Truncate"
-	operationDownload      = "download"
-	operationUpload        = "upload"
-	operationFirstDownload = "first-download"
-	operationFirstUpload   = "first-upload"
-	operationDelete        = "delete"
-	operationCopy          = "copy"
+	logSender               = "common"
+	uploadLogSender         = "Upload"
+	downloadLogSender       = "Download"
+	renameLogSender         = "Rename"
+	rmdirLogSender          = "Rmdir"
+	mkdirLogSender          = "Mkdir"
+	symlinkLogSender        = "Symlink"
+	removeLogSender         = "Remove"
+	chownLogSender          = "Chown"
+	chmodLogSender          = "Chmod"
+	chtimesLogSender        = "Chtimes"
+	copyLogSender           = "Copy"
+	truncateLogSender       = "Truncate"
+	operationDownload       = "download"
+	operationUpload         = "upload"
+	operationFirstDownload  = "first-download"
+	operationFirstUpload    = "first-upload"
+	operationArchiveRestore = "archive-restore"
+	operationDelete         = "delete"
+	operationCopy           = "copy"
 	// Pre-download action name
 	OperationPreDownload = "pre-download"
 	// Pre-upload action name
@@ -79,6 +80,8 @@ const (
 	chtimesFormat                = "2006-01-02T15:04:05" // YYYY-MM-DDTHH:MM:SS
 	idleTimeoutCheckInterval     = 3 * time.Minute
 	periodicTimeoutCheckInterval = 1 * time.Minute
+	dedupCleanupInterval         = 1 * time.Hour
+	accessScheduleCheckInterval  = 1 * time.Minute
 )
 
 // Stat flags
@@ -103,6 +106,7 @@ const (
 	ProtocolFTP           = "FTP"
 	ProtocolWebDAV        = "DAV"
 	ProtocolHTTP          = "HTTP"
+	ProtocolS3            = "S3"
 	ProtocolHTTPShare     = "HTTPShare"
 	ProtocolDataRetention = "DataRetention"
 	ProtocolOIDC          = "OIDC"
@@ -158,7 +162,7 @@ var (
 	ActiveMetadataChecks MetadataChecks
 	transfersChecker     TransfersChecker
 	supportedProtocols   = []string{ProtocolSFTP, ProtocolSCP, ProtocolSSH, ProtocolFTP, ProtocolWebDAV,
-		ProtocolHTTP, ProtocolHTTPShare, ProtocolOIDC}
+		ProtocolHTTP, ProtocolS3, ProtocolHTTPShare, ProtocolOIDC}
 	disconnHookProtocols = []string{ProtocolSFTP, ProtocolSCP, ProtocolSSH, ProtocolFTP}
 	// the map key is the protocol, for each protocol we can have multiple rate limiters
 	rateLimiters     map[string][]*rateLimiter
@@ -235,6 +239,26 @@ func Initialize(c Configuration, isShared int) error {
 	vfs.SetReadMetadataMode(c.Metadata.Read)
 	vfs.SetResumeMaxSize(c.ResumeMaxSize)
 	vfs.SetUploadMode(c.UploadMode)
+	vfs.SetS3MaxUploadMemory(c.S3MaxUploadMemory)
+	if err := vfs.SetReadCache(c.ReadCache.Path, c.ReadCache.MaxSize, c.ReadCache.MaxFileSize); err != nil {
+		return fmt.Errorf("read cache initialization error: %w", err)
+	}
+	if err := vfs.SetDedupStore(c.Dedup.Path, c.Dedup.MinFileSize); err != nil {
+		return fmt.Errorf("deduplication store initialization error: %w", err)
+	}
+	if err := vfs.SetSFTPFsPool(c.SFTPFsPool.MaxSessionsPerConnection, c.SFTPFsPool.MaxConnections,
+		c.SFTPFsPool.IdleTimeout, c.SFTPFsPool.HealthCheckInterval); err != nil {
+		return fmt.Errorf("SFTP pool initialization error: %w", err)
+	}
+	if err := Config.Snapshots.validate(); err != nil {
+		return fmt.Errorf("snapshots initialization error: %w", err)
+	}
+	if err := Config.initializeTrash(); err != nil {
+		return fmt.Errorf("trash initialization error: %w", err)
+	}
+	if err := Config.AnonymousAccess.initialize(); err != nil {
+		return fmt.Errorf("anonymous access initialization error: %w", err)
+	}
 	dataprovider.SetAllowSelfConnections(c.AllowSelfConnections)
 	transfersChecker = getTransfersChecker(isShared)
 	return nil
@@ -400,6 +424,36 @@ func startPeriodicChecks(duration time.Duration, isShared int) {
 	_, err := eventScheduler.AddFunc(spec, Connections.checkTransfers)
 	util.PanicOnError(err)
 	logger.Info(logSender, "", "scheduled overquota transfers check, schedule %q", spec)
+	drainSpec := fmt.Sprintf("@every %s", drainCheckInterval)
+	_, err = eventScheduler.AddFunc(drainSpec, Connections.checkDrains)
+	util.PanicOnError(err)
+	logger.Info(logSender, "", "scheduled draining connections check, schedule %q", drainSpec)
+	if Config.UploadStateRetention > 0 {
+		uploadStateSpec := fmt.Sprintf("@every %s", uploadStateCheckInterval)
+		_, err = eventScheduler.AddFunc(uploadStateSpec, uploadStates.check)
+		util.PanicOnError(err)
+		logger.Info(logSender, "", "scheduled upload states check, schedule %q", uploadStateSpec)
+	}
+	if Config.TrashRetention > 0 {
+		trashSpec := fmt.Sprintf("@every %s", trashPurgeInterval)
+		_, err = eventScheduler.AddFunc(trashSpec, purgeTrash)
+		util.PanicOnError(err)
+		logger.Info(logSender, "", "scheduled trash purge, schedule %q", trashSpec)
+	}
+	if Config.Dedup.Path != "" {
+		dedupSpec := fmt.Sprintf("@every %s", dedupCleanupInterval)
+		_, err = eventScheduler.AddFunc(dedupSpec, vfs.CleanupDedupStore)
+		util.PanicOnError(err)
+		logger.Info(logSender, "", "scheduled deduplication store cleanup, schedule %q", dedupSpec)
+	}
+	accessScheduleSpec := fmt.Sprintf("@every %s", accessScheduleCheckInterval)
+	_, err = eventScheduler.AddFunc(accessScheduleSpec, Connections.checkAccessSchedules)
+	util.PanicOnError(err)
+	logger.Info(logSender, "", "scheduled access schedules check, schedule %q", accessScheduleSpec)
+	archiveRestoreSpec := fmt.Sprintf("@every %s", archiveRestoreCheckInterval)
+	_, err = eventScheduler.AddFunc(archiveRestoreSpec, archiveRestores.check)
+	util.PanicOnError(err)
+	logger.Info(logSender, "", "scheduled archive restores check, schedule %q", archiveRestoreSpec)
 	if isShared == 1 {
 		logger.Info(logSender, "", "add reload configs task")
 		_, err := eventScheduler.AddFunc("@every 10m", smtp.ReloadProviderConf)
@@ -412,6 +466,7 @@ func startPeriodicChecks(duration time.Duration, isShared int) {
 		util.PanicOnError(err)
 		logger.Info(logSender, "", "scheduled idle connections check, schedule %q", spec)
 	}
+	startCertificatesExpiryCheck()
 }
 
 // ActiveTransfer defines the interface for the current active transfers
@@ -436,6 +491,7 @@ type ActiveConnection interface {
 	GetID() string
 	GetUsername() string
 	GetRole() string
+	GetUser() *dataprovider.User
 	GetMaxSessions() int
 	GetLocalAddress() string
 	GetRemoteAddress() string
@@ -482,6 +538,43 @@ type MetadataConfig struct {
 	Read int `json:"read" mapstructure:"read"`
 }
 
+// ReadCacheConfig defines the configuration for the local read-through cache
+// used for cloud storage backends
+type ReadCacheConfig struct {
+	// Absolute path to the directory for the cached files. Empty means disabled
+	Path string `json:"path" mapstructure:"path"`
+	// Maximum size, as MB, for the cached files. The least recently used
+	// files are evicted when this size is exceeded. 0 means disabled
+	MaxSize int64 `json:"max_size" mapstructure:"max_size"`
+	// Files bigger than this size, as MB, are not cached. 0 means no limit
+	MaxFileSize int64 `json:"max_file_size" mapstructure:"max_file_size"`
+}
+
+// SFTPFsPoolConfig defines the pool of connections to the remote SFTP servers
+// used for the SFTP storage backend
+type SFTPFsPoolConfig struct {
+	// User sessions multiplexed on each SSH connection. 0 means the default: 5
+	MaxSessionsPerConnection int `json:"max_sessions_per_connection" mapstructure:"max_sessions_per_connection"`
+	// Maximum number of connections for each remote account. If the limit is reached
+	// the new sessions are added to the least loaded connections. 0 means no limit
+	MaxConnections int `json:"max_connections" mapstructure:"max_connections"`
+	// Connections without sessions are closed after this timeout, as seconds.
+	// 0 means the default: 30
+	IdleTimeout int `json:"idle_timeout" mapstructure:"idle_timeout"`
+	// Interval, as seconds, for checking the pooled connections. 0 means the default: 30
+	HealthCheckInterval int `json:"health_check_interval" mapstructure:"health_check_interval"`
+}
+
+// DedupConfig defines the configuration for the content-addressed deduplication
+// store for the local filesystem
+type DedupConfig struct {
+	// Absolute path to the directory for the stored contents. It must be on the
+	// same filesystem as the users' home directories. Empty means disabled
+	Path string `json:"path" mapstructure:"path"`
+	// Files smaller than this size, as KB, are not deduplicated
+	MinFileSize int64 `json:"min_file_size" mapstructure:"min_file_size"`
+}
+
 // Configuration defines configuration parameters common to all supported protocols
 type Configuration struct {
 	// Maximum idle timeout as minutes. If a client is idle for a time that exceeds this setting it will be disconnected.
@@ -578,7 +671,37 @@ type Configuration struct {
 	// Umask for new uploads. Leave blank to use the system default.
 	Umask string `json:"umask" mapstructure:"umask"`
 	// Metadata configuration
-	Metadata              MetadataConfig `json:"metadata" mapstructure:"metadata"`
+	Metadata MetadataConfig `json:"metadata" mapstructure:"metadata"`
+	// Retention, as hours, for the state of the interrupted atomic uploads. If greater than 0 the
+	// state of the atomic uploads in progress is stored in the data provider, only SQL based providers
+	// are supported, and the uploads interrupted by a service restart are recovered by moving the
+	// partial files to the target paths, so the clients can resume them. The states that cannot be
+	// recovered within the configured retention are removed together with their temporary files.
+	// 0 means disabled
+	UploadStateRetention int `json:"upload_state_retention" mapstructure:"upload_state_retention"`
+	// Retention, as hours, for the deleted files. If greater than 0 the files deleted inside the
+	// users root filesystem are moved to a per-user trash and permanently removed after the
+	// retention expires. 0 means disabled
+	TrashRetention int `json:"trash_retention" mapstructure:"trash_retention"`
+	// Name of the directory, inside the users root filesystem, used to store the trash.
+	// An existing directory with this name, not created to store the trash, is never
+	// used and deleting files fails until a different name is configured
+	TrashDirName string `json:"trash_dir_name" mapstructure:"trash_dir_name"`
+	// Maximum memory, as MB, for the buffers of the concurrent S3 multipart uploads.
+	// New uploads wait until enough memory is available. 0 means no limit
+	S3MaxUploadMemory int64 `json:"s3_max_upload_memory" mapstructure:"s3_max_upload_memory"`
+	// Local read-through cache for S3, GCS, Azure Blob and SFTP storage backends
+	ReadCache ReadCacheConfig `json:"read_cache" mapstructure:"read_cache"`
+	// Content-addressed deduplication store for the local filesystem
+	Dedup DedupConfig `json:"dedup" mapstructure:"dedup"`
+	// Pool of connections for the SFTP storage backend
+	SFTPFsPool SFTPFsPoolConfig `json:"sftpfs_pool" mapstructure:"sftpfs_pool"`
+	// Point-in-time snapshots for the local filesystem
+	Snapshots SnapshotsConfig `json:"snapshots" mapstructure:"snapshots"`
+	// Certificates expiry check configuration
+	CertExpiry CertExpiryConfig `json:"cert_expiry" mapstructure:"cert_expiry"`
+	// Anonymous access configuration
+	AnonymousAccess       AnonymousAccessConfig `json:"anonymous_access" mapstructure:"anonymous_access"`
 	idleTimeoutAsDuration time.Duration
 	idleLoginTimeout      time.Duration
 	defender              Defender
@@ -911,6 +1034,9 @@ func (conns *ActiveConnections) Add(c ActiveConnection) error {
 	defer conns.Unlock()
 
 	if username := c.GetUsername(); username != "" {
+		if connectionsDrainer.isDraining(c.GetUser()) {
+			return errConnectionDraining
+		}
 		if maxSessions := c.GetMaxSessions(); maxSessions > 0 {
 			if val := conns.perUserConns[username]; val >= maxSessions {
 				return fmt.Errorf("too many open sessions: %d/%d", val, maxSessions)
@@ -923,6 +1049,7 @@ func (conns *ActiveConnections) Add(c ActiveConnection) error {
 	metric.UpdateActiveConnectionsSize(len(conns.connections))
 	logger.Debug(c.GetProtocol(), c.GetID(), "connection added, local address %q, remote address %q, num open connections: %d",
 		c.GetLocalAddress(), c.GetRemoteAddress(), len(conns.connections))
+	publishConnectionEvent(StreamEventConnectionOpen, c)
 	return nil
 }
 
@@ -938,6 +1065,10 @@ func (conns *ActiveConnections) Swap(c ActiveConnection) error {
 		conn := conns.connections[idx]
 		conns.removeUserConnection(conn.GetUsername())
 		if username := c.GetUsername(); username != "" {
+			if connectionsDrainer.isDraining(c.GetUser()) {
+				conns.addUserConnection(conn.GetUsername())
+				return errConnectionDraining
+			}
 			if maxSessions := c.GetMaxSessions(); maxSessions > 0 {
 				if val, ok := conns.perUserConns[username]; ok && val >= maxSessions {
 					conns.addUserConnection(conn.GetUsername())
@@ -989,6 +1120,7 @@ func (conns *ActiveConnections) Remove(connectionID string) {
 		}
 		Config.checkPostDisconnectHook(conn.GetRemoteAddress(), conn.GetProtocol(), conn.GetUsername(),
 			conn.GetID(), conn.GetConnectionTime())
+		publishConnectionEvent(StreamEventConnectionClose, conn)
 		return
 	}
 
@@ -1091,6 +1223,31 @@ func (conns *ActiveConnections) checkIdles() {
 	conns.RUnlock()
 }
 
+// checkAccessSchedules closes the connections for users outside their allowed
+// access time windows, if session termination is enabled
+func (conns *ActiveConnections) checkAccessSchedules() {
+	now := time.Now()
+
+	conns.RLock()
+
+	for _, c := range conns.connections {
+		if c.GetUsername() == "" {
+			continue
+		}
+		schedule := &c.GetUser().Filters.AccessSchedule
+		if !schedule.TerminateSessions || schedule.IsAccessAllowed(now) {
+			continue
+		}
+		defer func(conn ActiveConnection) {
+			err := conn.Disconnect()
+			logger.Info(conn.GetProtocol(), conn.GetID(), "close connection outside the allowed access time, username: %q, close err: %v",
+				conn.GetUsername(), err)
+		}(c)
+	}
+
+	conns.RUnlock()
+}
+
 func (conns *ActiveConnections) checkTransfers() {
 	if conns.transfersCheckStatus.Load() {
 		logger.Warn(logSender, "", "the previous transfer check is still running, skipping execution")
@@ -245,6 +245,33 @@ func TestInitializationProxyErrors(t *testing.T) {
 	assert.Len(t, Config.proxySkipped, 0)
 }
 
+func TestInitializationSFTPFsPoolErrors(t *testing.T) {
+	configCopy := Config
+
+	c := Configuration{
+		SFTPFsPool: SFTPFsPoolConfig{
+			MaxSessionsPerConnection: -1,
+		},
+	}
+	err := Initialize(c, 0)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "SFTP pool initialization error")
+	}
+	c.SFTPFsPool.MaxSessionsPerConnection = 10
+	c.SFTPFsPool.HealthCheckInterval = 2
+	err = Initialize(c, 0)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid SFTP pool health check interval")
+	}
+	c.SFTPFsPool.HealthCheckInterval = 0
+	err = Initialize(c, 0)
+	assert.NoError(t, err)
+
+	Config = configCopy
+	err = vfs.SetSFTPFsPool(0, 0, 0, 0)
+	assert.NoError(t, err)
+}
+
 func TestInitializationClosedProvider(t *testing.T) {
 	configCopy := Config
 
@@ -532,19 +559,21 @@ func TestRateLimitersIntegration(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, Config.rateLimitersList)
 
-	assert.Len(t, rateLimiters, 4)
+	assert.Len(t, rateLimiters, 5)
 	assert.Len(t, rateLimiters[ProtocolSSH], 1)
 	assert.Len(t, rateLimiters[ProtocolFTP], 2)
 	assert.Len(t, rateLimiters[ProtocolWebDAV], 2)
 	assert.Len(t, rateLimiters[ProtocolHTTP], 1)
+	assert.Len(t, rateLimiters[ProtocolS3], 1)
 
 	enabled, protocols = Config.GetRateLimitersStatus()
 	assert.True(t, enabled)
-	assert.Len(t, protocols, 4)
+	assert.Len(t, protocols, 5)
 	assert.Contains(t, protocols, ProtocolFTP)
 	assert.Contains(t, protocols, ProtocolSSH)
 	assert.Contains(t, protocols, ProtocolHTTP)
 	assert.Contains(t, protocols, ProtocolWebDAV)
+	assert.Contains(t, protocols, ProtocolS3)
 
 	source1 := "127.1.1.1"
 	source2 := "127.1.1.2"
@@ -840,6 +869,418 @@ func TestSwapConnection(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestConnectionsDrain(t *testing.T) {
+	groupName := "drain_group"
+	folderName := "drain_folder"
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: userTestUsername,
+			Role:     "role1",
+		},
+		Groups: []sdk.GroupMapping{
+			{
+				Name: groupName,
+				Type: sdk.GroupTypePrimary,
+			},
+		},
+		VirtualFolders: []vfs.VirtualFolder{
+			{
+				BaseVirtualFolder: vfs.BaseVirtualFolder{
+					Name: folderName,
+				},
+				VirtualPath: "/vdir",
+			},
+		},
+	}
+	_, err := AddDrainTarget("invalid", groupName, "")
+	assert.Error(t, err)
+	_, err = AddDrainTarget(DrainTypeGroup, " ", "")
+	assert.Error(t, err)
+
+	fs := vfs.NewOsFs("", os.TempDir(), "", nil)
+	c1 := NewBaseConnection("id1", ProtocolSFTP, "", "", user)
+	fakeConn1 := &fakeConnection{
+		BaseConnection: c1,
+	}
+	c2 := NewBaseConnection("id2", ProtocolSFTP, "", "", user)
+	fakeConn2 := &fakeConnection{
+		BaseConnection: c2,
+	}
+	err = Connections.Add(fakeConn1)
+	assert.NoError(t, err)
+	err = Connections.Add(fakeConn2)
+	assert.NoError(t, err)
+	tr := NewBaseTransfer(nil, c1, nil, "/p1", "/p1", "/r1", TransferUpload, 0, 0, 0, 0, true, fs, dataprovider.TransferQuota{})
+	// a drain target for a different role has no effect
+	target, err := AddDrainTarget(DrainTypeUser, userTestUsername, "role2")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, target.Connections)
+	assert.Len(t, Connections.GetStats(""), 2)
+	_, err = AddDrainTarget(DrainTypeUser, userTestUsername, "")
+	assert.Error(t, err)
+	assert.Len(t, GetDrainTargets(""), 1)
+	assert.Len(t, GetDrainTargets("role1"), 0)
+	err = RemoveDrainTarget(DrainTypeUser, userTestUsername, "role1")
+	assert.Error(t, err)
+	err = RemoveDrainTarget(DrainTypeUser, userTestUsername, "")
+	assert.NoError(t, err)
+	// the connection without transfers is closed, the other one is still active
+	target, err = AddDrainTarget(DrainTypeGroup, groupName, "role1")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, target.Connections)
+	assert.Eventually(t, func() bool { return len(Connections.GetStats("")) == 1 }, 1*time.Second, 50*time.Millisecond)
+	_, err = ExecutePreAction(c1, OperationPreDownload, "/p2", "/p2", 0, 0)
+	assert.ErrorIs(t, err, c1.GetPermissionDeniedError())
+	c3 := NewBaseConnection("id3", ProtocolSFTP, "", "", user)
+	err = Connections.Add(&fakeConnection{
+		BaseConnection: c3,
+	})
+	assert.ErrorIs(t, err, errConnectionDraining)
+	_, err = GetDrainTarget(DrainTypeGroup, groupName, "role2")
+	assert.Error(t, err)
+	err = RemoveDrainTarget(DrainTypeGroup, groupName, "role1")
+	assert.NoError(t, err)
+	_, err = ExecutePreAction(c1, OperationPreDownload, "/p2", "/p2", 0, 0)
+	assert.NoError(t, err)
+
+	_, err = AddDrainTarget(DrainTypeFolder, folderName, "")
+	assert.NoError(t, err)
+	Connections.checkDrains()
+	assert.Len(t, Connections.GetStats(""), 1)
+	err = tr.Close()
+	assert.NoError(t, err)
+	Connections.checkDrains()
+	assert.Eventually(t, func() bool { return len(Connections.GetStats("")) == 0 }, 1*time.Second, 50*time.Millisecond)
+	err = RemoveDrainTarget(DrainTypeFolder, folderName, "")
+	assert.NoError(t, err)
+	assert.Len(t, GetDrainTargets(""), 0)
+	err = RemoveDrainTarget(DrainTypeFolder, folderName, "")
+	assert.Error(t, err)
+}
+
+func TestAccessSchedules(t *testing.T) {
+	schedule := dataprovider.AccessSchedule{
+		Windows: []dataprovider.AccessTimeWindow{
+			{
+				DaysOfWeek: []int{1, 2, 3, 4, 5},
+				From:       "09:00",
+				To:         "18:00",
+			},
+		},
+		Timezone: "Europe/Rome",
+	}
+	// Monday
+	assert.True(t, schedule.IsAccessAllowed(time.Date(2024, 1, 15, 8, 30, 0, 0, time.UTC)))
+	assert.False(t, schedule.IsAccessAllowed(time.Date(2024, 1, 15, 17, 30, 0, 0, time.UTC)))
+	// Saturday
+	assert.False(t, schedule.IsAccessAllowed(time.Date(2024, 1, 13, 10, 0, 0, 0, time.UTC)))
+	// the window ends the next day
+	schedule = dataprovider.AccessSchedule{
+		Windows: []dataprovider.AccessTimeWindow{
+			{
+				DaysOfWeek: []int{5},
+				From:       "22:00",
+				To:         "06:00",
+			},
+		},
+	}
+	assert.True(t, schedule.IsAccessAllowed(time.Date(2024, 1, 19, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, schedule.IsAccessAllowed(time.Date(2024, 1, 20, 3, 0, 0, 0, time.UTC)))
+	assert.False(t, schedule.IsAccessAllowed(time.Date(2024, 1, 20, 7, 0, 0, 0, time.UTC)))
+	assert.False(t, schedule.IsAccessAllowed(time.Date(2024, 1, 21, 3, 0, 0, 0, time.UTC)))
+	schedule.Timezone = "invalid timezone"
+	assert.False(t, schedule.IsAccessAllowed(time.Date(2024, 1, 19, 23, 0, 0, 0, time.UTC)))
+	schedule = dataprovider.AccessSchedule{}
+	assert.True(t, schedule.IsAccessAllowed(time.Now()))
+
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: userTestUsername,
+		},
+	}
+	user.Filters.AccessSchedule = dataprovider.AccessSchedule{
+		Windows: []dataprovider.AccessTimeWindow{
+			{
+				DaysOfWeek: []int{(int(time.Now().UTC().Weekday()) + 3) % 7},
+				From:       "00:00",
+				To:         "24:00",
+			},
+		},
+	}
+	assert.Error(t, user.CheckLoginConditions())
+	c1 := NewBaseConnection("id1", ProtocolSFTP, "", "", user)
+	err := Connections.Add(&fakeConnection{
+		BaseConnection: c1,
+	})
+	assert.NoError(t, err)
+	user.Filters.AccessSchedule.TerminateSessions = true
+	c2 := NewBaseConnection("id2", ProtocolSFTP, "", "", user)
+	err = Connections.Add(&fakeConnection{
+		BaseConnection: c2,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, Connections.GetStats(""), 2)
+	Connections.checkAccessSchedules()
+	assert.Eventually(t, func() bool { return len(Connections.GetStats("")) == 1 }, 1*time.Second, 50*time.Millisecond)
+	Connections.Remove(c1.GetID())
+	assert.Len(t, Connections.GetStats(""), 0)
+}
+
+func TestAnonymousAccessConfig(t *testing.T) {
+	c := AnonymousAccessConfig{
+		FTPUsernames: []string{"anonymous"},
+	}
+	err := c.initialize()
+	assert.NoError(t, err)
+	assert.False(t, c.isAnonymousLogin("anonymous", "", ProtocolFTP))
+
+	c = AnonymousAccessConfig{
+		TemplateUser: " template ",
+		FTPUsernames: []string{" Anonymous", "ftp", "FTP", ""},
+		SFTPUsername: "public",
+		SFTPPassword: "public password",
+		RateLimiter: RateLimiterConfig{
+			Average:          1,
+			Period:           1000,
+			Burst:            1,
+			Type:             int(rateLimiterTypeSource),
+			Protocols:        []string{ProtocolWebDAV},
+			EntriesSoftLimit: 10,
+			EntriesHardLimit: 20,
+		},
+	}
+	err = c.initialize()
+	assert.ErrorContains(t, err, "invalid rate limiter protocol")
+	c.RateLimiter.Protocols = []string{ProtocolFTP}
+	err = c.initialize()
+	assert.NoError(t, err)
+	assert.NotNil(t, anonymousRateLimiter)
+	assert.Equal(t, "template", c.TemplateUser)
+	assert.Equal(t, []string{"anonymous", "ftp"}, c.FTPUsernames)
+	assert.True(t, c.isAnonymousLogin("ANONYMOUS", "user@example.com", ProtocolFTP))
+	assert.False(t, c.isAnonymousLogin("public", "public password", ProtocolFTP))
+	assert.True(t, c.isAnonymousLogin("public", "public password", ProtocolSSH))
+	assert.False(t, c.isAnonymousLogin("public", "wrong password", ProtocolSSH))
+	assert.False(t, c.isAnonymousLogin("anonymous", "", ProtocolSSH))
+	assert.False(t, c.isAnonymousLogin("anonymous", "", ProtocolWebDAV))
+	c.SFTPPassword = ""
+	assert.False(t, c.isAnonymousLogin("public", "", ProtocolSSH))
+
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: userTestUsername,
+			Permissions: map[string][]string{
+				"/":          {dataprovider.PermAny},
+				"/vdir1/sub": {dataprovider.PermListItems, dataprovider.PermUpload},
+				"/vdir2":     {dataprovider.PermDownload, dataprovider.PermDelete},
+				"/other":     {dataprovider.PermDelete},
+			},
+		},
+		VirtualFolders: []vfs.VirtualFolder{
+			{
+				BaseVirtualFolder: vfs.BaseVirtualFolder{
+					Name: "folder1",
+				},
+				VirtualPath: "/vdir1",
+			},
+			{
+				BaseVirtualFolder: vfs.BaseVirtualFolder{
+					Name: "folder2",
+				},
+				VirtualPath: "/vdir2",
+			},
+		},
+	}
+	user.Filters.TOTPConfig.Enabled = true
+	c.restrictUser(&user)
+	readOnly := []string{dataprovider.PermListItems, dataprovider.PermDownload}
+	assert.Len(t, user.VirtualFolders, 2)
+	assert.Len(t, user.Permissions, 4)
+	assert.Equal(t, readOnly, user.Permissions["/"])
+	assert.Equal(t, []string{dataprovider.PermListItems}, user.Permissions["/vdir1/sub"])
+	assert.Equal(t, []string{dataprovider.PermDownload}, user.Permissions["/vdir2"])
+	assert.Empty(t, user.Permissions["/other"])
+	assert.False(t, user.Filters.TOTPConfig.Enabled)
+
+	c.Folders = []string{"folder1"}
+	user.Permissions["/other"] = []string{dataprovider.PermAny}
+	c.restrictUser(&user)
+	if assert.Len(t, user.VirtualFolders, 1) {
+		assert.Equal(t, "folder1", user.VirtualFolders[0].Name)
+	}
+	assert.Len(t, user.Permissions, 3)
+	assert.Equal(t, []string{dataprovider.PermListItems}, user.Permissions["/"])
+	assert.Equal(t, readOnly, user.Permissions["/vdir1"])
+	assert.Equal(t, []string{dataprovider.PermListItems}, user.Permissions["/vdir1/sub"])
+	// a template user with a list only subdirectory inside the folder
+	user.Permissions = map[string][]string{
+		"/":          {dataprovider.PermListItems},
+		"/vdir1/sub": {dataprovider.PermAny},
+	}
+	c.restrictUser(&user)
+	assert.Len(t, user.Permissions, 3)
+	assert.Equal(t, []string{dataprovider.PermListItems}, user.Permissions["/"])
+	assert.Equal(t, []string{dataprovider.PermListItems}, user.Permissions["/vdir1"])
+	assert.Equal(t, readOnly, user.Permissions["/vdir1/sub"])
+
+	anonymousRateLimiter = nil
+}
+
+func TestAnonymousLoginExistingUser(t *testing.T) {
+	users := make([]dataprovider.User, 0, 2)
+	for _, username := range []string{"anonymous_template", "ftp"} {
+		u := dataprovider.User{
+			BaseUser: sdk.BaseUser{
+				Username: username,
+				Password: "pwd",
+				HomeDir:  filepath.Join(os.TempDir(), username),
+				Status:   1,
+				Permissions: map[string][]string{
+					"/": {dataprovider.PermAny},
+				},
+			},
+		}
+		err := dataprovider.AddUser(&u, "", "", "")
+		require.NoError(t, err)
+		users = append(users, u)
+	}
+	configCopy := Config
+	Config.AnonymousAccess = AnonymousAccessConfig{
+		TemplateUser: users[0].Username,
+		FTPUsernames: []string{"anonymous", "ftp"},
+	}
+	err := Config.AnonymousAccess.initialize()
+	require.NoError(t, err)
+	user, ok, err := CheckAnonymousLogin("anonymous", "user@example.com", "127.0.0.1", ProtocolFTP)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, users[0].Username, user.Username)
+	// the existing user is not shadowed by the anonymous access
+	_, ok, err = CheckAnonymousLogin("ftp", "pwd", "127.0.0.1", ProtocolFTP)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	Config = configCopy
+	for _, u := range users {
+		err = dataprovider.DeleteUser(u.Username, "", "", "")
+		assert.NoError(t, err)
+	}
+}
+
+func TestFileLocks(t *testing.T) {
+	username := "lock_user"
+	p := "/dir/file.txt"
+	err := FileLocks.Add(username, p, FileLock{
+		Owner:    "owner1",
+		Protocol: ProtocolSFTP,
+		Offset:   0,
+		Length:   100,
+		Mask:     LockBlockWrite,
+	})
+	assert.NoError(t, err)
+	// shared locks do not conflict
+	err = FileLocks.Add(username, p, FileLock{
+		Owner:    "owner2",
+		Protocol: ProtocolSFTP,
+		Offset:   50,
+		Length:   100,
+		Mask:     LockBlockWrite,
+	})
+	assert.NoError(t, err)
+	err = FileLocks.Add(username, p, FileLock{
+		Owner:     "owner3",
+		Protocol:  ProtocolSFTP,
+		Offset:    99,
+		Length:    0,
+		Mask:      LockBlockRead | LockBlockWrite,
+		Exclusive: true,
+	})
+	assert.ErrorIs(t, err, ErrLockConflict)
+	// no overlap
+	err = FileLocks.Add(username, p, FileLock{
+		Owner:     "owner3",
+		Protocol:  ProtocolSFTP,
+		Offset:    150,
+		Length:    0,
+		Mask:      LockBlockRead | LockBlockWrite,
+		Exclusive: true,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, FileLocks.GetLocks(username), 3)
+	// a recursive lock on the parent directory conflicts with the exclusive lock
+	err = FileLocks.Add(username, "/dir", FileLock{
+		Owner:     "token",
+		Protocol:  ProtocolWebDAV,
+		Mask:      LockBlockWrite | LockBlockDelete,
+		Exclusive: true,
+		Recursive: true,
+	})
+	assert.ErrorIs(t, err, ErrLockConflict)
+
+	err = FileLocks.CheckAccess(username, p, "owner1", "", LockBlockRead)
+	assert.ErrorIs(t, err, ErrLockConflict)
+	err = FileLocks.CheckAccess(username, p, "owner1", ProtocolSFTP, LockBlockRead)
+	assert.NoError(t, err)
+	err = FileLocks.CheckAccess(username, p, "owner1", "", LockBlockDelete)
+	assert.NoError(t, err)
+	err = FileLocks.CheckAccess(username, "/dir/file1.txt", "owner4", "", LockBlockWrite)
+	assert.NoError(t, err)
+
+	err = FileLocks.Remove(username, p, "owner3", 150, 1)
+	assert.ErrorIs(t, err, ErrNoMatchingLock)
+	err = FileLocks.Remove(username, p, "owner3", 150, 0)
+	assert.NoError(t, err)
+	FileLocks.RemoveOwnerLocks("owner2")
+	assert.Len(t, FileLocks.GetLocks(username), 1)
+	FileLocks.RemoveOwnerLocks("owner1")
+	assert.Len(t, FileLocks.GetLocks(username), 0)
+
+	err = FileLocks.Add(username, "/dir", FileLock{
+		Owner:     "token",
+		Protocol:  ProtocolWebDAV,
+		Mask:      LockBlockWrite | LockBlockDelete,
+		Exclusive: true,
+		Recursive: true,
+		ExpiresAt: time.Now().Add(1 * time.Hour),
+	})
+	assert.NoError(t, err)
+	c := NewBaseConnection("id", ProtocolSFTP, "", "", dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: username,
+		},
+	})
+	_, err = ExecutePreAction(c, OperationPreUpload, p, p, 0, 0)
+	assert.ErrorIs(t, err, c.GetPermissionDeniedError())
+	_, err = ExecutePreAction(c, OperationPreDownload, p, p, 0, 0)
+	assert.NoError(t, err)
+	FileLocks.Refresh("token", time.Now().Add(-1*time.Second))
+	_, err = ExecutePreAction(c, OperationPreUpload, p, p, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, FileLocks.GetLocks(username), 0)
+	// only the locks for the specified protocol rooted at the path are removed
+	for _, lockPath := range []string{"/dir", p, "/dir1"} {
+		err = FileLocks.Add(username, lockPath, FileLock{
+			Owner:    "token",
+			Protocol: ProtocolWebDAV,
+			Mask:     LockBlockWrite,
+		})
+		assert.NoError(t, err)
+	}
+	err = FileLocks.Add(username, p, FileLock{
+		Owner:    "owner1",
+		Protocol: ProtocolSFTP,
+		Mask:     LockBlockWrite,
+	})
+	assert.NoError(t, err)
+	FileLocks.RemovePathLocks(username, "/dir", ProtocolWebDAV, false)
+	assert.Len(t, FileLocks.GetLocks(username), 3)
+	FileLocks.RemovePathLocks(username, "/dir", ProtocolWebDAV, true)
+	assert.Len(t, FileLocks.GetLocks(username), 2)
+	FileLocks.RemovePathLocks(username, "/dir1", ProtocolWebDAV, true)
+	FileLocks.RemoveOwnerLocks("owner1")
+	assert.Len(t, FileLocks.GetLocks(username), 0)
+}
+
 func TestAtomicUpload(t *testing.T) {
 	configCopy := Config
 
@@ -1273,6 +1714,26 @@ func TestUserPerms(t *testing.T) {
 	assert.True(t, u.HasPermsRenameAll("/"))
 }
 
+func TestReadOnlyUserPerms(t *testing.T) {
+	u := dataprovider.User{}
+	u.Permissions = make(map[string][]string)
+	u.Permissions["/"] = []string{dataprovider.PermAny}
+	u.Permissions["/sub"] = []string{dataprovider.PermListItems, dataprovider.PermUpload}
+	u.Permissions["/sub1"] = []string{dataprovider.PermUpload, dataprovider.PermDelete}
+	perms := u.Permissions
+	u.SetReadOnlyPermissions()
+	assert.Equal(t, []string{dataprovider.PermListItems, dataprovider.PermDownload}, u.Permissions["/"])
+	assert.Equal(t, []string{dataprovider.PermListItems}, u.Permissions["/sub"])
+	assert.Len(t, u.Permissions["/sub1"], 0)
+	assert.True(t, u.HasPerm(dataprovider.PermDownload, "/file"))
+	assert.False(t, u.HasPerm(dataprovider.PermUpload, "/file"))
+	assert.False(t, u.HasPerm(dataprovider.PermUpload, "/sub/file"))
+	assert.False(t, u.HasPerm(dataprovider.PermDownload, "/sub/file"))
+	assert.False(t, u.HasAnyPerm([]string{dataprovider.PermUpload, dataprovider.PermDelete}, "/sub1"))
+	// the original permissions must not be modified
+	assert.Equal(t, []string{dataprovider.PermAny}, perms["/"])
+}
+
 func TestGetTLSVersion(t *testing.T) {
 	tlsVer := util.GetTLSVersion(0)
 	assert.Equal(t, uint16(tls.VersionTLS12), tlsVer)
@@ -1835,3 +2296,107 @@ func BenchmarkAddRemoveSSHConnections(b *testing.B) {
 	conn1.Close()
 	conn2.Close()
 }
+
+func TestArchiveRestores(t *testing.T) {
+	u := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: "archive_restore_user",
+			Password: "pwd",
+			HomeDir:  filepath.Join(os.TempDir(), "archive_restore_user"),
+			Status:   1,
+			Permissions: map[string][]string{
+				"/": {dataprovider.PermAny},
+			},
+		},
+	}
+	err := dataprovider.AddUser(&u, "", "", "")
+	require.NoError(t, err)
+	conn := NewBaseConnection("", ProtocolSFTP, "", "", u)
+	conn.TrackArchiveRestore(filepath.Join(u.HomeDir, "file"), "/file", os.ErrNotExist)
+	assert.Len(t, archiveRestores.getRestores(), 0)
+	conn.TrackArchiveRestore(filepath.Join(u.HomeDir, "file"), "/file", vfs.ErrRestoreInProgress)
+	assert.Len(t, archiveRestores.getRestores(), 1)
+	conn.TrackArchiveRestore(filepath.Join(u.HomeDir, "file"), "/file",
+		fmt.Errorf("wrapped: %w", vfs.ErrRestoreInProgress))
+	assert.Len(t, archiveRestores.getRestores(), 1)
+	// the local filesystem does not support archive restore, the restore is still tracked
+	archiveRestores.check()
+	assert.Len(t, archiveRestores.getRestores(), 1)
+	// restores not completed within the max wait are removed
+	restore := archiveRestores.getRestores()[0]
+	restore.createdAt = time.Now().Add(-archiveRestoreMaxWait - time.Minute)
+	archiveRestores.Lock()
+	archiveRestores.restores[restore.getKey()] = restore
+	archiveRestores.Unlock()
+	archiveRestores.check()
+	assert.Len(t, archiveRestores.getRestores(), 0)
+
+	err = dataprovider.DeleteUser(u.Username, "", "", "")
+	assert.NoError(t, err)
+	// restores for missing users are removed
+	conn.TrackArchiveRestore(filepath.Join(u.HomeDir, "file"), "/file", vfs.ErrRestoreInProgress)
+	assert.Len(t, archiveRestores.getRestores(), 1)
+	archiveRestores.check()
+	assert.Len(t, archiveRestores.getRestores(), 0)
+}
+
+func TestEventStream(t *testing.T) {
+	_, err := SubscribeEvents([]string{"unknown"}, nil, "")
+	assert.ErrorIs(t, err, util.ErrValidation)
+
+	subAll, err := SubscribeEvents(nil, nil, "")
+	require.NoError(t, err)
+	subConns, err := SubscribeEvents([]string{StreamEventConnectionOpen, StreamEventConnectionClose}, nil, "")
+	require.NoError(t, err)
+	subRole, err := SubscribeEvents(nil, []string{userTestUsername}, "role1")
+	require.NoError(t, err)
+	assert.True(t, eventStream.hasSubscribers())
+
+	c := &fakeConnection{
+		BaseConnection: NewBaseConnection("streamid", ProtocolSFTP, "", "", dataprovider.User{
+			BaseUser: sdk.BaseUser{
+				Username: userTestUsername,
+			},
+		}),
+	}
+	err = Connections.Add(c)
+	assert.NoError(t, err)
+	Connections.Remove(c.GetID())
+	err = ExecuteActionNotification(c.BaseConnection, operationUpload, "/path", "/vpath", "", "", "", 123,
+		ErrQuotaExceeded, 10, nil)
+	assert.NoError(t, err)
+	dataprovider.ExecutePostLoginHook(&dataprovider.User{BaseUser: sdk.BaseUser{Username: userTestUsername}},
+		dataprovider.LoginMethodPassword, "127.0.0.1", ProtocolSSH, nil)
+
+	var events []*StreamEvent
+	for i := 0; i < 4; i++ {
+		events = append(events, <-subAll.Events())
+	}
+	assert.Equal(t, StreamEventConnectionOpen, events[0].Type)
+	assert.Equal(t, c.GetID(), events[0].ConnectionID)
+	assert.Equal(t, StreamEventConnectionClose, events[1].Type)
+	assert.Equal(t, StreamEventFs, events[2].Type)
+	assert.Equal(t, operationUpload, events[2].Action)
+	assert.Equal(t, "/vpath", events[2].VirtualPath)
+	assert.Equal(t, int64(123), events[2].FileSize)
+	assert.Equal(t, 3, events[2].Status)
+	assert.NotEmpty(t, events[2].Error)
+	assert.Equal(t, StreamEventLogin, events[3].Type)
+	assert.Equal(t, dataprovider.LoginMethodPassword, events[3].Action)
+	assert.Equal(t, 1, events[3].Status)
+	assert.Greater(t, events[3].Timestamp, int64(0))
+	assert.Len(t, subConns.Events(), 2)
+	// the events are for a user without a role
+	assert.Len(t, subRole.Events(), 0)
+	// slow subscribers lose the events when the buffer is full
+	for i := 0; i < eventStreamBufferSize+10; i++ {
+		eventStream.publish(&StreamEvent{Type: StreamEventLogin})
+	}
+	assert.Len(t, subAll.Events(), eventStreamBufferSize)
+	assert.Equal(t, int64(10), subAll.GetDropped())
+
+	UnsubscribeEvents(subAll)
+	UnsubscribeEvents(subConns)
+	UnsubscribeEvents(subRole)
+	assert.False(t, eventStream.hasSubscribers())
+}
@@ -15,8 +15,10 @@
 package common
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path"
@@ -56,8 +58,13 @@ type BaseConnection struct {
 	localAddr  string
 	sync.RWMutex
 	activeTransfers []ActiveTransfer
+	copyProgressFn  CopyProgressFunc
 }
 
+// CopyProgressFunc is called after each file copied by Copy with the virtual
+// source path and the file size. Returning an error stops the copy
+type CopyProgressFunc func(virtualPath string, size int64) error
+
 // NewBaseConnection returns a new BaseConnection
 func NewBaseConnection(id, protocol, localAddr, remoteAddr string, user dataprovider.User) *BaseConnection {
 	connID := id
@@ -79,6 +86,11 @@ func NewBaseConnection(id, protocol, localAddr, remoteAddr string, user dataprov
 	return c
 }
 
+// SetCopyProgressFunc sets the function to call after each copied file
+func (c *BaseConnection) SetCopyProgressFunc(fn CopyProgressFunc) {
+	c.copyProgressFn = fn
+}
+
 // Log outputs a log entry to the configured logger
 func (c *BaseConnection) Log(level logger.LogLevel, format string, v ...any) {
 	logger.Log(level, c.protocol, c.ID, format, v...)
@@ -104,6 +116,37 @@ func (c *BaseConnection) GetRole() string {
 	return c.User.Role
 }
 
+// GetUser returns the user associated with this connection
+func (c *BaseConnection) GetUser() *dataprovider.User {
+	return &c.User
+}
+
+// getTransferBandwidth returns the bandwidth limit, as KB/s, for a transfer of the
+// specified type inside the given virtual path. Virtual folder limits override the user ones
+func (c *BaseConnection) getTransferBandwidth(virtualPath string, transferType int) int64 {
+	bandwidth := c.User.UploadBandwidth
+	if transferType == TransferDownload {
+		bandwidth = c.User.DownloadBandwidth
+	}
+	if len(c.User.VirtualFolders) == 0 || virtualPath == "" {
+		return bandwidth
+	}
+	folder, err := c.User.GetVirtualFolderForPath(path.Dir(virtualPath))
+	if err != nil {
+		return bandwidth
+	}
+	if transferType == TransferDownload {
+		if folder.DownloadBandwidth > 0 {
+			return folder.DownloadBandwidth
+		}
+		return bandwidth
+	}
+	if folder.UploadBandwidth > 0 {
+		return folder.UploadBandwidth
+	}
+	return bandwidth
+}
+
 // GetMaxSessions returns the maximum number of concurrent sessions allowed
 func (c *BaseConnection) GetMaxSessions() int {
 	return c.User.MaxSessions
@@ -310,6 +353,9 @@ func (c *BaseConnection) ListDir(virtualPath string) ([]os.FileInfo, error) {
 		c.Log(logger.LevelDebug, "error listing directory: %+v", err)
 		return nil, c.GetFsError(fs, err)
 	}
+	if virtualPath == "/" && IsTrashEnabled() {
+		files = c.filterTrashDir(files)
+	}
 	return c.User.FilterListDir(files, virtualPath), nil
 }
 
@@ -416,7 +462,13 @@ func (c *BaseConnection) RemoveFile(fs vfs.Fs, fsPath, virtualPath string, info
 	}
 	updateQuota := true
 	startTime := time.Now()
-	if err := fs.Remove(fsPath, false); err != nil {
+	moveToTrash := c.canMoveToTrash(virtualPath, info)
+	if moveToTrash {
+		err = c.moveToTrash(fs, fsPath, virtualPath, info)
+	} else {
+		err = fs.Remove(fsPath, false)
+	}
+	if err != nil {
 		if status > 0 && fs.IsNotExist(err) {
 			// file removed in the pre-action, if the file was deleted from the EventManager the quota is already updated
 			c.Log(logger.LevelDebug, "file deleted from the hook, status: %d", status)
@@ -425,6 +477,9 @@ func (c *BaseConnection) RemoveFile(fs vfs.Fs, fsPath, virtualPath string, info
 			c.Log(logger.LevelError, "failed to remove file/symlink %q: %+v", fsPath, err)
 			return c.GetFsError(fs, err)
 		}
+	} else if moveToTrash {
+		// trashed files are included in the quota until they are purged
+		updateQuota = false
 	}
 	elapsed := time.Since(startTime).Nanoseconds() / 1000000
 
@@ -433,7 +488,7 @@ func (c *BaseConnection) RemoveFile(fs vfs.Fs, fsPath, virtualPath string, info
 	if updateQuota && info.Mode()&os.ModeSymlink == 0 {
 		vfolder, err := c.User.GetVirtualFolderForPath(path.Dir(virtualPath))
 		if err == nil {
-			dataprovider.UpdateVirtualFolderQuota(&vfolder.BaseVirtualFolder, -1, -size, false) //nolint:errcheck
+			dataprovider.UpdateVirtualFolderQuota(&vfolder, -1, -size, false) //nolint:errcheck
 			if vfolder.IsIncludedInUserQuota() {
 				dataprovider.UpdateUserQuota(&c.User, -1, -size, false) //nolint:errcheck
 			}
@@ -669,7 +724,13 @@ func (c *BaseConnection) doRecursiveCopy(virtualSourcePath, virtualTargetPath st
 		return nil
 	}
 
-	return c.copyFile(virtualSourcePath, virtualTargetPath, srcInfo.Size())
+	if err := c.copyFile(virtualSourcePath, virtualTargetPath, srcInfo.Size()); err != nil {
+		return err
+	}
+	if c.copyProgressFn != nil {
+		return c.copyProgressFn(virtualSourcePath, srcInfo.Size())
+	}
+	return nil
 }
 
 // Copy virtualSourcePath to virtualTargetPath
@@ -720,6 +781,130 @@ func (c *BaseConnection) Copy(virtualSourcePath, virtualTargetPath string) error
 	return c.doRecursiveCopy(virtualSourcePath, destPath, srcInfo, createTargetDir)
 }
 
+// GetFileVersions returns the stored versions for the file at the specified virtual path
+func (c *BaseConnection) GetFileVersions(virtualPath string) ([]vfs.FileVersion, error) {
+	if !c.User.HasPerm(dataprovider.PermListItems, path.Dir(virtualPath)) {
+		return nil, c.GetPermissionDeniedError()
+	}
+	if ok, policy := c.User.IsFileAllowed(virtualPath); !ok {
+		c.Log(logger.LevelDebug, "listing versions for file %q is not allowed", virtualPath)
+		return nil, c.GetErrorForDeniedFile(policy)
+	}
+	fs, fsPath, err := c.GetFsAndResolvedPath(virtualPath)
+	if err != nil {
+		return nil, err
+	}
+	versioner, ok := fs.(vfs.FsVersioner)
+	if !ok {
+		return nil, fmt.Errorf("file versions are not supported: %w", c.GetOpUnsupportedError())
+	}
+	versions, err := versioner.GetVersions(fsPath)
+	if err != nil {
+		c.Log(logger.LevelError, "unable to get versions for file %q: %+v", fsPath, err)
+		return nil, c.GetFsError(fs, err)
+	}
+	return versions, nil
+}
+
+// RestoreFileVersion restores the specified version as the current version
+// of the file at the specified virtual path
+func (c *BaseConnection) RestoreFileVersion(virtualPath, versionID string) error {
+	if versionID == "" {
+		return fmt.Errorf("the version to restore is required: %w", c.GetOpUnsupportedError())
+	}
+	if ok, policy := c.User.IsFileAllowed(virtualPath); !ok {
+		c.Log(logger.LevelDebug, "restoring a version for file %q is not allowed", virtualPath)
+		return c.GetErrorForDeniedFile(policy)
+	}
+	fs, fsPath, err := c.GetFsAndResolvedPath(virtualPath)
+	if err != nil {
+		return err
+	}
+	versioner, ok := fs.(vfs.FsVersioner)
+	if !ok {
+		return fmt.Errorf("file versions are not supported: %w", c.GetOpUnsupportedError())
+	}
+	numFiles := 0
+	var initialSize int64
+	info, err := fs.Stat(fsPath)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("cannot restore a version for directory %q: %w", virtualPath, c.GetOpUnsupportedError())
+		}
+		if !c.User.HasPerm(dataprovider.PermOverwrite, path.Dir(virtualPath)) {
+			return c.GetPermissionDeniedError()
+		}
+		initialSize = info.Size()
+	} else {
+		if !fs.IsNotExist(err) {
+			return c.GetFsError(fs, err)
+		}
+		if !c.User.HasPerm(dataprovider.PermUpload, path.Dir(virtualPath)) {
+			return c.GetPermissionDeniedError()
+		}
+		numFiles = 1
+	}
+	quotaResult, _ := c.HasSpace(numFiles > 0, false, virtualPath)
+	if !quotaResult.HasSpace {
+		return c.GetQuotaExceededError()
+	}
+	if err := versioner.RestoreVersion(fsPath, versionID); err != nil {
+		c.Log(logger.LevelError, "unable to restore version %q for file %q: %+v", versionID, fsPath, err)
+		return c.GetFsError(fs, err)
+	}
+	info, err = fs.Stat(fsPath)
+	if err != nil {
+		c.Log(logger.LevelError, "unable to stat file %q after restoring version %q: %+v", fsPath, versionID, err)
+		return c.GetFsError(fs, err)
+	}
+	sizeDiff := info.Size() - initialSize
+	vfolder, err := c.User.GetVirtualFolderForPath(path.Dir(virtualPath))
+	if err == nil {
+		dataprovider.UpdateVirtualFolderQuota(&vfolder, numFiles, sizeDiff, false) //nolint:errcheck
+		if vfolder.IsIncludedInUserQuota() {
+			dataprovider.UpdateUserQuota(&c.User, numFiles, sizeDiff, false) //nolint:errcheck
+		}
+	} else {
+		dataprovider.UpdateUserQuota(&c.User, numFiles, sizeDiff, false) //nolint:errcheck
+	}
+	c.Log(logger.LevelInfo, "version %q restored for file %q, size: %d", versionID, virtualPath, info.Size())
+	return nil
+}
+
+// SetFileAccessTier changes the storage access tier, for example Hot, Cool or Archive,
+// for the file at the specified virtual path
+func (c *BaseConnection) SetFileAccessTier(virtualPath, tier string) error {
+	if !c.User.HasPerm(dataprovider.PermOverwrite, path.Dir(virtualPath)) {
+		return c.GetPermissionDeniedError()
+	}
+	if ok, policy := c.User.IsFileAllowed(virtualPath); !ok {
+		c.Log(logger.LevelDebug, "setting the access tier for file %q is not allowed", virtualPath)
+		return c.GetErrorForDeniedFile(policy)
+	}
+	fs, fsPath, err := c.GetFsAndResolvedPath(virtualPath)
+	if err != nil {
+		return err
+	}
+	tierSetter, ok := fs.(vfs.FsAccessTierSetter)
+	if !ok {
+		return fmt.Errorf("access tiers are not supported: %w", c.GetOpUnsupportedError())
+	}
+	info, err := fs.Stat(fsPath)
+	if err != nil {
+		return c.GetFsError(fs, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("cannot set the access tier for %q, it is not a file: %w", virtualPath,
+			c.GetOpUnsupportedError())
+	}
+	if err := tierSetter.SetAccessTier(fsPath, tier); err != nil {
+		c.Log(logger.LevelError, "unable to set access tier %q for file %q: %+v", tier, fsPath, err)
+		return c.GetFsError(fs, err)
+	}
+	c.Log(logger.LevelInfo, "access tier %q set for file %q", tier, virtualPath)
+	return nil
+}
+
 // Rename renames (moves) virtualSourcePath to virtualTargetPath
 func (c *BaseConnection) Rename(virtualSourcePath, virtualTargetPath string) error {
 	return c.renameInternal(virtualSourcePath, virtualTargetPath, false)
@@ -1053,7 +1238,7 @@ func (c *BaseConnection) truncateFile(fs vfs.Fs, fsPath, virtualPath string, siz
 		sizeDiff := initialSize - size
 		vfolder, err := c.User.GetVirtualFolderForPath(path.Dir(virtualPath))
 		if err == nil {
-			dataprovider.UpdateVirtualFolderQuota(&vfolder.BaseVirtualFolder, 0, -sizeDiff, false) //nolint:errcheck
+			dataprovider.UpdateVirtualFolderQuota(&vfolder, 0, -sizeDiff, false) //nolint:errcheck
 			if vfolder.IsIncludedInUserQuota() {
 				dataprovider.UpdateUserQuota(&c.User, 0, -sizeDiff, false) //nolint:errcheck
 			}
@@ -1450,7 +1635,7 @@ func (c *BaseConnection) updateQuotaMoveBetweenVFolders(sourceFolder, dstFolder
 	if sourceFolder.Name == dstFolder.Name {
 		// both files are inside the same virtual folder
 		if initialSize != -1 {
-			dataprovider.UpdateVirtualFolderQuota(&dstFolder.BaseVirtualFolder, -numFiles, -initialSize, false) //nolint:errcheck
+			dataprovider.UpdateVirtualFolderQuota(dstFolder, -numFiles, -initialSize, false) //nolint:errcheck
 			if dstFolder.IsIncludedInUserQuota() {
 				dataprovider.UpdateUserQuota(&c.User, -numFiles, -initialSize, false) //nolint:errcheck
 			}
@@ -1458,18 +1643,18 @@ func (c *BaseConnection) updateQuotaMoveBetweenVFolders(sourceFolder, dstFolder
 		return
 	}
 	// files are inside different virtual folders
-	dataprovider.UpdateVirtualFolderQuota(&sourceFolder.BaseVirtualFolder, -numFiles, -filesSize, false) //nolint:errcheck
+	dataprovider.UpdateVirtualFolderQuota(sourceFolder, -numFiles, -filesSize, false) //nolint:errcheck
 	if sourceFolder.IsIncludedInUserQuota() {
 		dataprovider.UpdateUserQuota(&c.User, -numFiles, -filesSize, false) //nolint:errcheck
 	}
 	if initialSize == -1 {
-		dataprovider.UpdateVirtualFolderQuota(&dstFolder.BaseVirtualFolder, numFiles, filesSize, false) //nolint:errcheck
+		dataprovider.UpdateVirtualFolderQuota(dstFolder, numFiles, filesSize, false) //nolint:errcheck
 		if dstFolder.IsIncludedInUserQuota() {
 			dataprovider.UpdateUserQuota(&c.User, numFiles, filesSize, false) //nolint:errcheck
 		}
 	} else {
 		// we cannot have a directory here, initialSize != -1 only for files
-		dataprovider.UpdateVirtualFolderQuota(&dstFolder.BaseVirtualFolder, 0, filesSize-initialSize, false) //nolint:errcheck
+		dataprovider.UpdateVirtualFolderQuota(dstFolder, 0, filesSize-initialSize, false) //nolint:errcheck
 		if dstFolder.IsIncludedInUserQuota() {
 			dataprovider.UpdateUserQuota(&c.User, 0, filesSize-initialSize, false) //nolint:errcheck
 		}
@@ -1478,7 +1663,7 @@ func (c *BaseConnection) updateQuotaMoveBetweenVFolders(sourceFolder, dstFolder
 
 func (c *BaseConnection) updateQuotaMoveFromVFolder(sourceFolder *vfs.VirtualFolder, initialSize, filesSize int64, numFiles int) {
 	// move between a virtual folder and the user home dir
-	dataprovider.UpdateVirtualFolderQuota(&sourceFolder.BaseVirtualFolder, -numFiles, -filesSize, false) //nolint:errcheck
+	dataprovider.UpdateVirtualFolderQuota(sourceFolder, -numFiles, -filesSize, false) //nolint:errcheck
 	if sourceFolder.IsIncludedInUserQuota() {
 		dataprovider.UpdateUserQuota(&c.User, -numFiles, -filesSize, false) //nolint:errcheck
 	}
@@ -1494,13 +1679,13 @@ func (c *BaseConnection) updateQuotaMoveToVFolder(dstFolder *vfs.VirtualFolder,
 	// move between the user home dir and a virtual folder
 	dataprovider.UpdateUserQuota(&c.User, -numFiles, -filesSize, false) //nolint:errcheck
 	if initialSize == -1 {
-		dataprovider.UpdateVirtualFolderQuota(&dstFolder.BaseVirtualFolder, numFiles, filesSize, false) //nolint:errcheck
+		dataprovider.UpdateVirtualFolderQuota(dstFolder, numFiles, filesSize, false) //nolint:errcheck
 		if dstFolder.IsIncludedInUserQuota() {
 			dataprovider.UpdateUserQuota(&c.User, numFiles, filesSize, false) //nolint:errcheck
 		}
 	} else {
 		// we cannot have a directory here, initialSize != -1 only for files
-		dataprovider.UpdateVirtualFolderQuota(&dstFolder.BaseVirtualFolder, 0, filesSize-initialSize, false) //nolint:errcheck
+		dataprovider.UpdateVirtualFolderQuota(dstFolder, 0, filesSize-initialSize, false) //nolint:errcheck
 		if dstFolder.IsIncludedInUserQuota() {
 			dataprovider.UpdateUserQuota(&c.User, 0, filesSize-initialSize, false) //nolint:errcheck
 		}
@@ -1569,7 +1754,7 @@ func (c *BaseConnection) IsNotExistError(err error) bool {
 	switch c.protocol {
 	case ProtocolSFTP:
 		return errors.Is(err, sftp.ErrSSHFxNoSuchFile)
-	case ProtocolWebDAV, ProtocolFTP, ProtocolHTTP, ProtocolOIDC, ProtocolHTTPShare, ProtocolDataRetention:
+	case ProtocolWebDAV, ProtocolFTP, ProtocolHTTP, ProtocolS3, ProtocolOIDC, ProtocolHTTPShare, ProtocolDataRetention:
 		return errors.Is(err, os.ErrNotExist)
 	default:
 		return errors.Is(err, ErrNotExist)
@@ -1596,7 +1781,7 @@ func (c *BaseConnection) GetNotExistError() error {
 	switch c.protocol {
 	case ProtocolSFTP:
 		return sftp.ErrSSHFxNoSuchFile
-	case ProtocolWebDAV, ProtocolFTP, ProtocolHTTP, ProtocolOIDC, ProtocolHTTPShare, ProtocolDataRetention:
+	case ProtocolWebDAV, ProtocolFTP, ProtocolHTTP, ProtocolS3, ProtocolOIDC, ProtocolHTTPShare, ProtocolDataRetention:
 		return os.ErrNotExist
 	default:
 		return ErrNotExist
@@ -1696,9 +1881,21 @@ func (c *BaseConnection) GetFsError(fs vfs.Fs, err error) error {
 	if fs.IsNotExist(err) {
 		return c.GetNotExistError()
 	} else if fs.IsPermission(err) {
+		if errors.Is(err, vfs.ErrObjectLocked) {
+			c.Log(logger.LevelInfo, "operation denied: %v", err)
+			if c.protocol == ProtocolSFTP {
+				return fmt.Errorf("%w: %v", sftp.ErrSSHFxPermissionDenied, err.Error())
+			}
+		}
 		return c.GetPermissionDeniedError()
 	} else if fs.IsNotSupported(err) {
 		return c.GetOpUnsupportedError()
+	} else if errors.Is(err, vfs.ErrRestoreInProgress) {
+		c.Log(logger.LevelInfo, "unable to read archived file: %v", err)
+		if c.protocol == ProtocolSFTP {
+			return fmt.Errorf("%w: %v", sftp.ErrSSHFxFailure, err.Error())
+		}
+		return vfs.ErrRestoreInProgress
 	} else if err != nil {
 		return c.GetGenericError(err)
 	}
@@ -1730,6 +1927,10 @@ func (c *BaseConnection) GetFsAndResolvedPath(virtualPath string) (vfs.Fs, strin
 	if isShuttingDown.Load() {
 		return nil, "", c.GetFsError(fs, ErrShuttingDown)
 	}
+	if IsTrashEnabled() && isTrashPath(virtualPath) && c.hasTrashDir() {
+		c.Log(logger.LevelDebug, "access to the trash path %q is not allowed", virtualPath)
+		return nil, "", c.GetPermissionDeniedError()
+	}
 
 	fsPath, err := fs.ResolvePath(virtualPath)
 	if err != nil {
@@ -1739,11 +1940,62 @@ func (c *BaseConnection) GetFsAndResolvedPath(virtualPath string) (vfs.Fs, strin
 	return fs, fsPath, nil
 }
 
+// GetFileHash returns the hex encoded hash, computed using the specified hasher, for the
+// file at the given virtual path. If length is greater than 0, only the specified byte
+// range is hashed. The list permission is required, as for the other metadata of the file
+func (c *BaseConnection) GetFileHash(virtualPath string, hasher hash.Hash, offset, length int64) (string, error) {
+	if !c.User.HasPerm(dataprovider.PermListItems, path.Dir(virtualPath)) {
+		return "", c.GetPermissionDeniedError()
+	}
+	if ok, policy := c.User.IsFileAllowed(virtualPath); !ok {
+		c.Log(logger.LevelInfo, "hash not allowed for file %q", virtualPath)
+		return "", c.GetErrorForDeniedFile(policy)
+	}
+	fs, fsPath, err := c.GetFsAndResolvedPath(virtualPath)
+	if err != nil {
+		return "", err
+	}
+	info, err := fs.Stat(fsPath)
+	if err != nil {
+		return "", c.GetFsError(fs, err)
+	}
+	if !info.Mode().IsRegular() {
+		c.Log(logger.LevelDebug, "unable to compute the hash for %q, it is not a regular file", virtualPath)
+		return "", c.GetOpUnsupportedError()
+	}
+	if offset < 0 || offset > info.Size() {
+		return "", c.GetGenericError(fmt.Errorf("invalid offset %d for file %q", offset, virtualPath))
+	}
+	f, r, cancelFn, err := vfs.OpenWithInfo(fs, fsPath, offset, info)
+	if err != nil {
+		return "", c.GetFsError(fs, err)
+	}
+	if cancelFn != nil {
+		defer cancelFn()
+	}
+	var reader io.ReadCloser
+	if f != nil {
+		reader = f
+	} else {
+		reader = r
+	}
+	defer reader.Close()
+
+	var src io.Reader = reader
+	if length > 0 {
+		src = io.LimitReader(reader, length)
+	}
+	if _, err := io.Copy(hasher, src); err != nil {
+		return "", c.GetFsError(fs, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
 func getPermissionDeniedError(protocol string) error {
 	switch protocol {
 	case ProtocolSFTP:
 		return sftp.ErrSSHFxPermissionDenied
-	case ProtocolWebDAV, ProtocolFTP, ProtocolHTTP, ProtocolOIDC, ProtocolHTTPShare, ProtocolDataRetention:
+	case ProtocolWebDAV, ProtocolFTP, ProtocolHTTP, ProtocolS3, ProtocolOIDC, ProtocolHTTPShare, ProtocolDataRetention:
 		return os.ErrPermission
 	default:
 		return ErrPermissionDenied
@@ -380,7 +380,7 @@ func TestUpdateQuotaAfterRename(t *testing.T) {
 func TestErrorsMapping(t *testing.T) {
 	fs := vfs.NewOsFs("", os.TempDir(), "", nil)
 	conn := NewBaseConnection("", ProtocolSFTP, "", "", dataprovider.User{BaseUser: sdk.BaseUser{HomeDir: os.TempDir()}})
-	osErrorsProtocols := []string{ProtocolWebDAV, ProtocolFTP, ProtocolHTTP, ProtocolHTTPShare,
+	osErrorsProtocols := []string{ProtocolWebDAV, ProtocolFTP, ProtocolHTTP, ProtocolS3, ProtocolHTTPShare,
 		ProtocolDataRetention, ProtocolOIDC, protocolEventAction}
 	for _, protocol := range supportedProtocols {
 		conn.SetProtocol(protocol)
@@ -1034,3 +1034,69 @@ func TestFilePatterns(t *testing.T) {
 	filtered = user.FilterListDir(dirContents, "/dir3/ic35/abc")
 	require.Len(t, filtered, 1)
 }
+
+func TestSearchIndex(t *testing.T) {
+	homeDir := filepath.Join(os.TempDir(), "searchIndexHome")
+	err := os.MkdirAll(filepath.Join(homeDir, "sub"), os.ModePerm)
+	assert.NoError(t, err)
+	defer os.RemoveAll(homeDir)
+	for _, name := range []string{"file1.txt", "sub/file2.txt"} {
+		err = os.WriteFile(filepath.Join(homeDir, name), []byte("content"), os.ModePerm)
+		assert.NoError(t, err)
+	}
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: "search_index_user",
+			HomeDir:  homeDir,
+		},
+	}
+	user.Permissions = make(map[string][]string)
+	user.Permissions["/"] = []string{dataprovider.PermAny}
+	connection := NewBaseConnection(xid.New().String(), ProtocolHTTP, "", "", user)
+	mgr := newSearchIndexManager(time.Hour, 100)
+	entries, truncated, err := mgr.getEntries(connection, "/")
+	assert.NoError(t, err)
+	assert.False(t, truncated)
+	assert.Len(t, entries, 3)
+	entries, _, err = mgr.getEntries(connection, "/sub")
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "/sub/file2.txt", entries[0].Path)
+	}
+	_, _, err = mgr.getEntries(connection, "/missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	// the index is reused until it expires
+	err = os.WriteFile(filepath.Join(homeDir, "file3.txt"), []byte("content"), os.ModePerm)
+	assert.NoError(t, err)
+	entries, _, err = mgr.getEntries(connection, "/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	// the refresh fails, the user does not exist in the data provider, and the index is removed
+	mgr.mu.Lock()
+	mgr.indexes[user.Username].createdAt = time.Now().Add(-2 * time.Hour)
+	mgr.mu.Unlock()
+	entries, _, err = mgr.getEntries(connection, "/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Eventually(t, func() bool {
+		mgr.mu.Lock()
+		defer mgr.mu.Unlock()
+
+		_, ok := mgr.indexes[user.Username]
+		return !ok
+	}, 2*time.Second, 50*time.Millisecond)
+	entries, _, err = mgr.getEntries(connection, "/")
+	assert.NoError(t, err)
+	assert.Len(t, entries, 4)
+
+	mgr.cleanup()
+	assert.Len(t, mgr.indexes, 1)
+	mgr.indexes[user.Username].lastUsed = time.Now().Add(-3 * time.Hour)
+	mgr.cleanup()
+	assert.Len(t, mgr.indexes, 0)
+
+	entries, truncated, err = walkUserFiles(connection, "/", 2)
+	assert.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Len(t, entries, 2)
+}
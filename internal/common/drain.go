@@ -0,0 +1,273 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// Supported drain target types
+const (
+	DrainTypeUser   = "user"
+	DrainTypeGroup  = "group"
+	DrainTypeFolder = "folder"
+)
+
+const drainCheckInterval = 10 * time.Second
+
+var (
+	errConnectionDraining = errors.New("connections for this user are draining")
+	drainTypes            = []string{DrainTypeUser, DrainTypeGroup, DrainTypeFolder}
+	connectionsDrainer    = newDrainer()
+)
+
+// DrainTarget defines the user, group or virtual folder for which the connections
+// must be drained. New sessions and transfers are denied for the matching users,
+// existing connections are closed as soon as they have no active transfers
+type DrainTarget struct {
+	// Target type: user, group or folder
+	Type string `json:"type"`
+	// User, group or folder name
+	Name string `json:"name"`
+	// Role of the admin that added the drain target, if any.
+	// Only the connections for users with this role are drained
+	Role string `json:"role,omitempty"`
+	// Creation time as unix timestamp in milliseconds
+	CreatedAt int64 `json:"created_at"`
+	// Number of connections still active for this target
+	Connections int `json:"connections"`
+}
+
+func (t *DrainTarget) getKey() string {
+	return t.Type + "_" + t.Name
+}
+
+func (t *DrainTarget) validate() error {
+	t.Name = strings.TrimSpace(t.Name)
+	if !util.Contains(drainTypes, t.Type) {
+		return util.NewValidationError(fmt.Sprintf("invalid drain type %q", t.Type))
+	}
+	if t.Name == "" {
+		return util.NewValidationError("the drain target name is mandatory")
+	}
+	return nil
+}
+
+func (t *DrainTarget) matches(user *dataprovider.User) bool {
+	if t.Role != "" && t.Role != user.Role {
+		return false
+	}
+	switch t.Type {
+	case DrainTypeUser:
+		return user.Username == t.Name
+	case DrainTypeGroup:
+		for _, group := range user.Groups {
+			if group.Name == t.Name {
+				return true
+			}
+		}
+	case DrainTypeFolder:
+		for _, folder := range user.VirtualFolders {
+			if folder.Name == t.Name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type drainer struct {
+	sync.RWMutex
+	targets map[string]DrainTarget
+}
+
+func newDrainer() *drainer {
+	return &drainer{
+		targets: make(map[string]DrainTarget),
+	}
+}
+
+func (d *drainer) add(target DrainTarget) error {
+	d.Lock()
+	defer d.Unlock()
+
+	if existing, ok := d.targets[target.getKey()]; ok && existing.Role != target.Role {
+		return util.NewValidationError(fmt.Sprintf("%s %q is already draining", target.Type, target.Name))
+	}
+	target.CreatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	d.targets[target.getKey()] = target
+	return nil
+}
+
+func (d *drainer) remove(drainType, name, role string) error {
+	d.Lock()
+	defer d.Unlock()
+
+	target := DrainTarget{
+		Type: drainType,
+		Name: name,
+	}
+	existing, ok := d.targets[target.getKey()]
+	if !ok || (role != "" && existing.Role != role) {
+		return util.NewRecordNotFoundError(fmt.Sprintf("no drain found for %s %q", drainType, name))
+	}
+	delete(d.targets, target.getKey())
+	return nil
+}
+
+func (d *drainer) get(drainType, name, role string) (DrainTarget, error) {
+	d.RLock()
+	defer d.RUnlock()
+
+	target := DrainTarget{
+		Type: drainType,
+		Name: name,
+	}
+	existing, ok := d.targets[target.getKey()]
+	if !ok || (role != "" && existing.Role != role) {
+		return target, util.NewRecordNotFoundError(fmt.Sprintf("no drain found for %s %q", drainType, name))
+	}
+	return existing, nil
+}
+
+func (d *drainer) list(role string) []DrainTarget {
+	d.RLock()
+	defer d.RUnlock()
+
+	targets := make([]DrainTarget, 0, len(d.targets))
+	for _, target := range d.targets {
+		if role == "" || target.Role == role {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+func (d *drainer) isDraining(user *dataprovider.User) bool {
+	d.RLock()
+	defer d.RUnlock()
+
+	for _, target := range d.targets {
+		if target.matches(user) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *drainer) hasTargets() bool {
+	d.RLock()
+	defer d.RUnlock()
+
+	return len(d.targets) > 0
+}
+
+// AddDrainTarget starts draining the connections for the specified target.
+// New sessions and transfers are denied for the matching users and the existing
+// connections are closed once their transfers complete
+func AddDrainTarget(drainType, name, role string) (DrainTarget, error) {
+	target := DrainTarget{
+		Type: drainType,
+		Name: name,
+		Role: role,
+	}
+	if err := target.validate(); err != nil {
+		return target, err
+	}
+	if err := connectionsDrainer.add(target); err != nil {
+		return target, err
+	}
+	logger.Info(logSender, "", "start draining connections for %s %q, role %q", target.Type, target.Name, role)
+	Connections.checkDrains()
+	return GetDrainTarget(target.Type, target.Name, role)
+}
+
+// RemoveDrainTarget stops draining the connections for the specified target
+func RemoveDrainTarget(drainType, name, role string) error {
+	if err := connectionsDrainer.remove(drainType, name, role); err != nil {
+		return err
+	}
+	logger.Info(logSender, "", "stop draining connections for %s %q", drainType, name)
+	return nil
+}
+
+// GetDrainTarget returns the drain target with the specified type and name
+func GetDrainTarget(drainType, name, role string) (DrainTarget, error) {
+	target, err := connectionsDrainer.get(drainType, name, role)
+	if err != nil {
+		return target, err
+	}
+	target.Connections = Connections.getDrainingConnections(&target)
+	return target, nil
+}
+
+// GetDrainTargets returns the drain targets visible for the specified role
+func GetDrainTargets(role string) []DrainTarget {
+	targets := connectionsDrainer.list(role)
+	for idx := range targets {
+		targets[idx].Connections = Connections.getDrainingConnections(&targets[idx])
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].CreatedAt < targets[j].CreatedAt
+	})
+	return targets
+}
+
+func (conns *ActiveConnections) getDrainingConnections(target *DrainTarget) int {
+	conns.RLock()
+	defer conns.RUnlock()
+
+	result := 0
+	for _, c := range conns.connections {
+		if target.matches(c.GetUser()) {
+			result++
+		}
+	}
+	return result
+}
+
+// checkDrains disconnects the draining connections without active transfers
+func (conns *ActiveConnections) checkDrains() {
+	if !connectionsDrainer.hasTargets() {
+		return
+	}
+
+	conns.RLock()
+
+	for _, c := range conns.connections {
+		if c.GetUsername() == "" || !connectionsDrainer.isDraining(c.GetUser()) {
+			continue
+		}
+		if len(c.GetTransfers()) > 0 {
+			continue
+		}
+		defer func(conn ActiveConnection) {
+			err := conn.Disconnect()
+			logger.Info(conn.GetProtocol(), conn.GetID(), "close drained connection, username: %q, close err: %v",
+				conn.GetUsername(), err)
+		}(c)
+	}
+
+	conns.RUnlock()
+}
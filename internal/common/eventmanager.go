@@ -74,6 +74,7 @@ var (
 func init() {
 	eventManager = eventRulesContainer{
 		schedulesMapping: make(map[string][]cron.EntryID),
+		quotaLevels:      newQuotaThresholdLevels(),
 		// arbitrary maximum number of concurrent asynchronous tasks,
 		// each task could execute multiple actions
 		concurrencyGuard: make(chan struct{}, 200),
@@ -90,6 +91,7 @@ func init() {
 				Role:       role,
 				Timestamp:  time.Now().UnixNano(),
 				Object:     object,
+				Attributes: dataprovider.GetObjectAttributes(object),
 			}
 			if u, ok := object.(*dataprovider.User); ok {
 				p.Email = u.Email
@@ -98,6 +100,7 @@ func init() {
 			}
 			eventManager.handleProviderEvent(p)
 		})
+	dataprovider.SetQuotaUpdateCallback(eventManager.handleQuotaUpdate)
 }
 
 // HandleCertificateEvent checks and executes action rules for certificate events
@@ -120,8 +123,10 @@ type eventRulesContainer struct {
 	IPBlockedEvents   []dataprovider.EventRule
 	CertificateEvents []dataprovider.EventRule
 	IPDLoginEvents    []dataprovider.EventRule
+	QuotaEvents       []dataprovider.EventRule
 	schedulesMapping  map[string][]cron.EntryID
 	concurrencyGuard  chan struct{}
+	quotaLevels       *quotaThresholdLevels
 }
 
 func (r *eventRulesContainer) addAsyncTask() {
@@ -198,6 +203,16 @@ func (r *eventRulesContainer) removeRuleInternal(name string) {
 			return
 		}
 	}
+	for idx := range r.QuotaEvents {
+		if r.QuotaEvents[idx].Name == name {
+			lastIdx := len(r.QuotaEvents) - 1
+			r.QuotaEvents[idx] = r.QuotaEvents[lastIdx]
+			r.QuotaEvents = r.QuotaEvents[:lastIdx]
+			r.quotaLevels.removeRule(name)
+			eventManagerLog(logger.LevelDebug, "removed rule %q from quota threshold events", name)
+			return
+		}
+	}
 	for idx := range r.Schedules {
 		if r.Schedules[idx].Name == name {
 			if schedules, ok := r.schedulesMapping[name]; ok {
@@ -246,7 +261,10 @@ func (r *eventRulesContainer) addUpdateRuleInternal(rule dataprovider.EventRule)
 	case dataprovider.EventTriggerIDPLogin:
 		r.IPDLoginEvents = append(r.IPDLoginEvents, rule)
 		eventManagerLog(logger.LevelDebug, "added rule %q to IDP login events", rule.Name)
-	case dataprovider.EventTriggerSchedule:
+	case dataprovider.EventTriggerQuotaThreshold:
+		r.QuotaEvents = append(r.QuotaEvents, rule)
+		eventManagerLog(logger.LevelDebug, "added rule %q to quota threshold events", rule.Name)
+	case dataprovider.EventTriggerSchedule, dataprovider.EventTriggerUserInactivity:
 		for _, schedule := range rule.Conditions.Schedules {
 			cronSpec := schedule.GetCronSpec()
 			job := &eventCronJob{
@@ -286,8 +304,9 @@ func (r *eventRulesContainer) loadRules() {
 			r.addUpdateRuleInternal(rule)
 		}
 	}
-	eventManagerLog(logger.LevelDebug, "event rules updated, fs events: %d, provider events: %d, schedules: %d, ip blocked events: %d, certificate events: %d, IDP login events: %d",
-		len(r.FsEvents), len(r.ProviderEvents), len(r.Schedules), len(r.IPBlockedEvents), len(r.CertificateEvents), len(r.IPDLoginEvents))
+	eventManagerLog(logger.LevelDebug, "event rules updated, fs events: %d, provider events: %d, schedules: %d, ip blocked events: %d, certificate events: %d, IDP login events: %d, quota threshold events: %d",
+		len(r.FsEvents), len(r.ProviderEvents), len(r.Schedules), len(r.IPBlockedEvents), len(r.CertificateEvents), len(r.IPDLoginEvents),
+		len(r.QuotaEvents))
 
 	r.setLastLoadTime(modTime)
 }
@@ -319,7 +338,7 @@ func (*eventRulesContainer) checkProviderEventMatch(conditions *dataprovider.Eve
 	if len(conditions.Options.ProviderObjects) > 0 && !util.Contains(conditions.Options.ProviderObjects, params.ObjectType) {
 		return false
 	}
-	return true
+	return checkEventAttributeConditions(params.Attributes, conditions.Options.Attributes)
 }
 
 func (*eventRulesContainer) checkFsEventMatch(conditions *dataprovider.EventConditions, params *EventParams) bool {
@@ -338,6 +357,9 @@ func (*eventRulesContainer) checkFsEventMatch(conditions *dataprovider.EventCond
 	if !checkEventConditionPatterns(params.VirtualPath, conditions.Options.FsPaths) {
 		return false
 	}
+	if !checkEventAttributeConditions(params.Attributes, conditions.Options.Attributes) {
+		return false
+	}
 	if len(conditions.Options.Protocols) > 0 && !util.Contains(conditions.Options.Protocols, params.Protocol) {
 		return false
 	}
@@ -558,8 +580,12 @@ type EventParams struct {
 	Timestamp             int64
 	UID                   string
 	IDPCustomFields       *map[string]string
+	Attributes            map[string]string
 	Object                plugin.Renderer
 	Metadata              map[string]string
+	QuotaThreshold        int
+	QuotaUsage            int
+	InactivityDays        int
 	sender                string
 	updateStatusFromError bool
 	errors                []string
@@ -588,6 +614,13 @@ func (p *EventParams) getACopy() *EventParams {
 		}
 		params.IDPCustomFields = &fields
 	}
+	if len(p.Attributes) > 0 {
+		attributes := make(map[string]string)
+		for k, v := range p.Attributes {
+			attributes[k] = v
+		}
+		params.Attributes = attributes
+	}
 	if len(params.Metadata) > 0 {
 		metadata := make(map[string]string)
 		for k, v := range p.Metadata {
@@ -793,6 +826,9 @@ func (p *EventParams) getStringReplacements(addObjectData, jsonEscaped bool) []s
 		"{{StatusString}}", p.getStatusString(),
 		"{{UID}}", p.getStringReplacement(p.UID, jsonEscaped),
 		"{{Ext}}", p.getStringReplacement(p.Extension, jsonEscaped),
+		"{{QuotaThreshold}}", strconv.Itoa(p.QuotaThreshold),
+		"{{QuotaUsage}}", strconv.Itoa(p.QuotaUsage),
+		"{{InactivityDays}}", strconv.Itoa(p.InactivityDays),
 	}
 	if p.VirtualPath != "" {
 		replacements = append(replacements, "{{VirtualDirPath}}", p.getStringReplacement(path.Dir(p.VirtualPath), jsonEscaped))
@@ -821,6 +857,9 @@ func (p *EventParams) getStringReplacements(addObjectData, jsonEscaped bool) []s
 			replacements = append(replacements, fmt.Sprintf("{{IDPField%s}}", k), p.getStringReplacement(v, jsonEscaped))
 		}
 	}
+	for k, v := range p.Attributes {
+		replacements = append(replacements, fmt.Sprintf("{{Attribute%s}}", k), p.getStringReplacement(v, jsonEscaped))
+	}
 	replacements = append(replacements, "{{Metadata}}", "{}")
 	replacements = append(replacements, "{{MetadataString}}", "")
 	if len(p.Metadata) > 0 {
@@ -908,7 +947,7 @@ func updateUserQuotaAfterFileWrite(conn *BaseConnection, virtualPath string, num
 		dataprovider.UpdateUserQuota(&conn.User, numFiles, fileSize, false) //nolint:errcheck
 		return
 	}
-	dataprovider.UpdateVirtualFolderQuota(&vfolder.BaseVirtualFolder, numFiles, fileSize, false) //nolint:errcheck
+	dataprovider.UpdateVirtualFolderQuota(&vfolder, numFiles, fileSize, false) //nolint:errcheck
 	if vfolder.IsIncludedInUserQuota() {
 		dataprovider.UpdateUserQuota(&conn.User, numFiles, fileSize, false) //nolint:errcheck
 	}
@@ -1175,7 +1214,7 @@ func checkUserConditionOptions(user *dataprovider.User, conditions *dataprovider
 	if !checkEventGroupConditionPatterns(user.Groups, conditions.GroupNames) {
 		return false
 	}
-	return true
+	return checkEventAttributeConditions(user.Attributes, conditions.Attributes)
 }
 
 // checkConditionPatterns returns false if patterns are defined and no match is found
@@ -1199,6 +1238,20 @@ func checkEventConditionPatterns(name string, patterns []dataprovider.ConditionP
 	return matches
 }
 
+// checkEventAttributeConditions returns false if any of the defined attribute conditions does not match
+func checkEventAttributeConditions(attributes map[string]string, conditions []dataprovider.ConditionAttribute) bool {
+	for _, c := range conditions {
+		p := dataprovider.ConditionPattern{
+			Pattern:      c.Pattern,
+			InverseMatch: c.InverseMatch,
+		}
+		if !checkEventConditionPattern(p, attributes[c.Name]) {
+			return false
+		}
+	}
+	return true
+}
+
 func checkEventGroupConditionPatterns(groups []sdk.GroupMapping, patterns []dataprovider.ConditionPattern) bool {
 	if len(patterns) == 0 {
 		return true
@@ -1559,7 +1612,15 @@ func executeEmailRuleAction(c dataprovider.EventActionEmailConfig, params *Event
 		}
 		files = append(files, res...)
 	}
-	err := smtp.SendEmail(recipients, bcc, subject, body, smtp.EmailContentType(c.ContentType), files...)
+	embeds := make([]*mail.File, 0, len(c.InlineImages))
+	for _, name := range c.InlineImages {
+		f, err := smtp.GetInlineImage(name)
+		if err != nil {
+			return err
+		}
+		embeds = append(embeds, f)
+	}
+	err := smtp.SendEmailWithEmbeds(recipients, bcc, subject, body, smtp.EmailContentType(c.ContentType), embeds, files...)
 	eventManagerLog(logger.LevelDebug, "executed email notification action, elapsed: %s, error: %v",
 		time.Since(startTime), err)
 	if err != nil {
@@ -2095,7 +2156,7 @@ func executeQuotaResetForUser(user *dataprovider.User) error {
 	}
 	defer QuotaScans.RemoveUserQuotaScan(user.Username)
 
-	numFiles, size, err := user.ScanQuota()
+	numFiles, size, err := ScanUserQuota(user)
 	if err != nil {
 		eventManagerLog(logger.LevelError, "error scanning quota for user %q: %v", user.Username, err)
 		return fmt.Errorf("error scanning quota for user %q: %w", user.Username, err)
@@ -2173,7 +2234,7 @@ func executeFoldersQuotaResetRuleAction(conditions dataprovider.ConditionOptions
 			failures = append(failures, folder.Name)
 			continue
 		}
-		err = dataprovider.UpdateVirtualFolderQuota(&folder, numFiles, size, true)
+		err = dataprovider.UpdateVirtualFolderQuota(&f, numFiles, size, true)
 		if err != nil {
 			eventManagerLog(logger.LevelError, "error updating quota for folder %q: %v", folder.Name, err)
 			params.AddError(fmt.Errorf("error updating quota for folder %q: %w", folder.Name, err))
@@ -2322,6 +2383,39 @@ func executeUserExpirationCheckRuleAction(conditions dataprovider.ConditionOptio
 	return nil
 }
 
+func executeSnapshotRuleAction(conditions dataprovider.ConditionOptions, params *EventParams) error {
+	users, err := params.getUsers()
+	if err != nil {
+		return fmt.Errorf("unable to get users: %w", err)
+	}
+	var failures []string
+	executed := 0
+	for _, user := range users {
+		// if sender is set, the conditions have already been evaluated
+		if params.sender == "" {
+			if !checkUserConditionOptions(&user, &conditions) {
+				eventManagerLog(logger.LevelDebug, "skipping snapshot for user %q, condition options don't match",
+					user.Username)
+				continue
+			}
+		}
+		executed++
+		if _, err = CreateSnapshot(user.Username); err != nil {
+			eventManagerLog(logger.LevelError, "unable to create snapshot for user %q: %v", user.Username, err)
+			params.AddError(fmt.Errorf("unable to create snapshot for user %q: %w", user.Username, err))
+			failures = append(failures, user.Username)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("snapshot failed for users: %s", strings.Join(failures, ", "))
+	}
+	if executed == 0 {
+		eventManagerLog(logger.LevelError, "no snapshot executed")
+		return errors.New("no snapshot executed")
+	}
+	return nil
+}
+
 func executeMetadataCheckForUser(user *dataprovider.User) error {
 	if err := user.LoadAndApplyGroupSettings(); err != nil {
 		eventManagerLog(logger.LevelError, "skipping scheduled quota reset for user %s, cannot apply group settings: %v",
@@ -2523,11 +2617,7 @@ func executeRuleAction(action dataprovider.BaseEventAction, params *EventParams,
 	case dataprovider.ActionTypeEmail:
 		err = executeEmailRuleAction(action.Options.EmailConfig, params)
 	case dataprovider.ActionTypeBackup:
-		var backupPath string
-		backupPath, err = dataprovider.ExecuteBackup()
-		if err == nil {
-			params.setBackupParams(backupPath)
-		}
+		err = executeBackupRuleAction(action.Options.BackupConfig, params)
 	case dataprovider.ActionTypeUserQuotaReset:
 		err = executeUsersQuotaResetRuleAction(conditions, params)
 	case dataprovider.ActionTypeFolderQuotaReset:
@@ -2544,6 +2634,22 @@ func executeRuleAction(action dataprovider.BaseEventAction, params *EventParams,
 		err = executePwdExpirationCheckRuleAction(action.Options.PwdExpirationConfig, conditions, params)
 	case dataprovider.ActionTypeUserExpirationCheck:
 		err = executeUserExpirationCheckRuleAction(conditions, params)
+	case dataprovider.ActionTypeSnapshot:
+		err = executeSnapshotRuleAction(conditions, params)
+	case dataprovider.ActionTypeTiering:
+		err = executeTieringRuleAction(action.Options.TieringConfig, params)
+	case dataprovider.ActionTypeUserArchive:
+		err = executeUserArchiveRuleAction(action.Options.ArchiveConfig, conditions, params)
+	case dataprovider.ActionTypeChatNotification:
+		err = executeChatRuleAction(action.Options.ChatConfig, params)
+	case dataprovider.ActionTypeMessagePublish:
+		err = executeMessagePublishRuleAction(action.Options.MessageConfig, params)
+	case dataprovider.ActionTypeCloudQueue:
+		err = executeCloudQueueRuleAction(action.Options.CloudQueueConfig, params)
+	case dataprovider.ActionTypeUserDisable:
+		err = executeUserDisableRuleAction(conditions, params)
+	case dataprovider.ActionTypeUsageReport:
+		err = executeUsageReportRuleAction(action.Options.UsageReportConfig, conditions, params)
 	default:
 		err = fmt.Errorf("unsupported action type: %d", action.Type)
 	}
@@ -2701,6 +2807,14 @@ func (j *eventCronJob) getTask(rule *dataprovider.EventRule) (dataprovider.Task,
 	return dataprovider.Task{}, nil
 }
 
+func (j *eventCronJob) executeRule(rule dataprovider.EventRule) {
+	if rule.Trigger == dataprovider.EventTriggerUserInactivity {
+		executeUserInactivityRule(rule)
+		return
+	}
+	executeAsyncRulesActions([]dataprovider.EventRule{rule}, EventParams{Status: 1, updateStatusFromError: true})
+}
+
 func (j *eventCronJob) Run() {
 	eventManagerLog(logger.LevelDebug, "executing scheduled rule %q", j.ruleName)
 	rule, err := dataprovider.EventRuleExists(j.ruleName)
@@ -2751,9 +2865,9 @@ func (j *eventCronJob) Run() {
 			}
 		}(task.Name)
 
-		executeAsyncRulesActions([]dataprovider.EventRule{rule}, EventParams{Status: 1, updateStatusFromError: true})
+		j.executeRule(rule)
 	} else {
-		executeAsyncRulesActions([]dataprovider.EventRule{rule}, EventParams{Status: 1, updateStatusFromError: true})
+		j.executeRule(rule)
 	}
 	eventManagerLog(logger.LevelDebug, "execution for scheduled rule %q finished", j.ruleName)
 }
@@ -15,13 +15,19 @@
 package common
 
 import (
+	"archive/tar"
+	"bufio"
 	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path"
@@ -31,14 +37,23 @@ import (
 	"testing"
 	"time"
 
+	"github.com/eclipse/paho.mqtt.golang/packets"
 	"github.com/klauspost/compress/zip"
+	"github.com/klauspost/compress/zstd"
 	"github.com/rs/xid"
 	"github.com/sftpgo/sdk"
 	sdkkms "github.com/sftpgo/sdk/kms"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
 
 	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/kafkaclient"
 	"github.com/drakkan/sftpgo/v2/internal/kms"
 	"github.com/drakkan/sftpgo/v2/internal/util"
 	"github.com/drakkan/sftpgo/v2/internal/vfs"
@@ -269,6 +284,48 @@ func TestEventRuleMatch(t *testing.T) {
 		},
 	})
 	assert.False(t, res)
+	attributeConditions := &dataprovider.ConditionOptions{
+		Attributes: []dataprovider.ConditionAttribute{
+			{
+				Name:    "department",
+				Pattern: "sales*",
+			},
+			{
+				Name:         "tier",
+				Pattern:      "gold",
+				InverseMatch: true,
+			},
+		},
+	}
+	res = checkUserConditionOptions(&user, attributeConditions)
+	assert.False(t, res)
+	user.Attributes = map[string]string{
+		"department": "sales-eu",
+	}
+	res = checkUserConditionOptions(&user, attributeConditions)
+	assert.True(t, res)
+	user.Attributes["tier"] = "gold"
+	res = checkUserConditionOptions(&user, attributeConditions)
+	assert.False(t, res)
+	params = EventParams{
+		Event:      "add",
+		Name:       "folder1",
+		ObjectType: "folder",
+		Attributes: map[string]string{
+			"department": "sales",
+		},
+	}
+	res = eventManager.checkProviderEventMatch(&dataprovider.EventConditions{
+		ProviderEvents: []string{"add"},
+		Options:        *attributeConditions,
+	}, &params)
+	assert.True(t, res)
+	params.Attributes = nil
+	res = eventManager.checkProviderEventMatch(&dataprovider.EventConditions{
+		ProviderEvents: []string{"add"},
+		Options:        *attributeConditions,
+	}, &params)
+	assert.False(t, res)
 	res = eventManager.checkIPDLoginEventMatch(&dataprovider.EventConditions{
 		IDPLoginEvent: 0,
 	}, &EventParams{
@@ -1204,6 +1261,39 @@ func TestEventRuleActions(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, getErrorString(err), "no transfer quota reset executed")
 
+	action.Type = dataprovider.ActionTypeSnapshot
+	snapshotConditions := dataprovider.ConditionOptions{
+		Names: []dataprovider.ConditionPattern{
+			{
+				Pattern: username1,
+			},
+		},
+	}
+	err = executeRuleAction(action, &EventParams{}, snapshotConditions)
+	assert.Error(t, err)
+	Config.Snapshots.Path = filepath.Join(os.TempDir(), "snapshots")
+	err = os.MkdirAll(user1.GetHomeDir(), os.ModePerm)
+	assert.NoError(t, err)
+	err = executeRuleAction(action, &EventParams{}, snapshotConditions)
+	assert.NoError(t, err)
+	snapshots, err := GetSnapshots(username1)
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+	err = executeRuleAction(action, &EventParams{}, dataprovider.ConditionOptions{
+		Names: []dataprovider.ConditionPattern{
+			{
+				Pattern: "no match",
+			},
+		},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, getErrorString(err), "no snapshot executed")
+	err = os.RemoveAll(Config.Snapshots.Path)
+	assert.NoError(t, err)
+	Config.Snapshots.Path = ""
+	err = os.RemoveAll(user1.GetHomeDir())
+	assert.NoError(t, err)
+
 	action.Type = dataprovider.ActionTypeFilesystem
 	action.Options = dataprovider.BaseEventActionOptions{
 		FsConfig: dataprovider.EventActionFilesystemConfig{
@@ -1376,6 +1466,275 @@ func TestEventRuleActions(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestTieringAction(t *testing.T) {
+	sourceFolder := vfs.BaseVirtualFolder{
+		Name:       "tiering_source",
+		MappedPath: filepath.Join(os.TempDir(), "tiering_source"),
+	}
+	targetFolder := vfs.BaseVirtualFolder{
+		Name:       "tiering_target",
+		MappedPath: filepath.Join(os.TempDir(), "tiering_target"),
+	}
+	action := dataprovider.BaseEventAction{
+		Type: dataprovider.ActionTypeTiering,
+		Options: dataprovider.BaseEventActionOptions{
+			TieringConfig: dataprovider.EventActionTieringConfig{
+				Policies: []dataprovider.FolderTiering{
+					{
+						SourceFolder: sourceFolder.Name,
+						TargetFolder: targetFolder.Name,
+						MinAge:       10,
+						LeaveStub:    true,
+					},
+				},
+			},
+		},
+	}
+	err := executeRuleAction(action, &EventParams{}, dataprovider.ConditionOptions{})
+	assert.Error(t, err) // folders do not exist
+	err = dataprovider.AddFolder(&sourceFolder, "", "", "")
+	assert.NoError(t, err)
+	err = dataprovider.AddFolder(&targetFolder, "", "", "")
+	assert.NoError(t, err)
+
+	oldFile := filepath.Join(sourceFolder.MappedPath, "sub", "old.txt")
+	newFile := filepath.Join(sourceFolder.MappedPath, "new.txt")
+	err = os.MkdirAll(filepath.Dir(oldFile), os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(oldFile, []byte("old content"), 0666)
+	assert.NoError(t, err)
+	err = os.WriteFile(newFile, []byte("new"), 0666)
+	assert.NoError(t, err)
+	oldTime := time.Now().Add(-30 * 24 * time.Hour)
+	err = os.Chtimes(oldFile, oldTime, oldTime)
+	assert.NoError(t, err)
+	err = dataprovider.UpdateVirtualFolderQuota(&vfs.VirtualFolder{BaseVirtualFolder: sourceFolder}, 2, 14, true)
+	assert.NoError(t, err)
+	// simulate another tiering in progress
+	assert.True(t, activeTierings.add(sourceFolder.Name))
+	err = executeRuleAction(action, &EventParams{}, dataprovider.ConditionOptions{})
+	assert.Error(t, err)
+	activeTierings.remove(sourceFolder.Name)
+
+	err = executeRuleAction(action, &EventParams{}, dataprovider.ConditionOptions{})
+	assert.NoError(t, err)
+	assert.NoFileExists(t, oldFile)
+	assert.FileExists(t, newFile)
+	assert.FileExists(t, filepath.Join(targetFolder.MappedPath, "sub", "old.txt"))
+	assert.NoFileExists(t, filepath.Join(targetFolder.MappedPath, "new.txt"))
+	stub, err := os.ReadFile(oldFile + TieringStubSuffix)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(stub), targetFolder.Name)
+		assert.Contains(t, string(stub), "/sub/old.txt")
+	}
+	folderGet, err := dataprovider.GetFolderByName(targetFolder.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, folderGet.UsedQuotaFiles)
+	assert.Equal(t, int64(11), folderGet.UsedQuotaSize)
+	folderGet, err = dataprovider.GetFolderByName(sourceFolder.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, folderGet.UsedQuotaFiles)
+	assert.Equal(t, int64(3+len(stub)), folderGet.UsedQuotaSize)
+	// stubs are never moved
+	action.Options.TieringConfig.Policies[0].MinAge = 1
+	err = os.Chtimes(newFile, oldTime, oldTime)
+	assert.NoError(t, err)
+	err = os.Chtimes(oldFile+TieringStubSuffix, oldTime, oldTime)
+	assert.NoError(t, err)
+	err = executeRuleAction(action, &EventParams{}, dataprovider.ConditionOptions{})
+	assert.NoError(t, err)
+	assert.NoFileExists(t, newFile)
+	assert.FileExists(t, newFile+TieringStubSuffix)
+	assert.FileExists(t, oldFile+TieringStubSuffix)
+	assert.FileExists(t, filepath.Join(targetFolder.MappedPath, "new.txt"))
+
+	err = os.RemoveAll(sourceFolder.MappedPath)
+	assert.NoError(t, err)
+	err = os.RemoveAll(targetFolder.MappedPath)
+	assert.NoError(t, err)
+	err = dataprovider.DeleteFolder(sourceFolder.Name, "", "", "")
+	assert.NoError(t, err)
+	err = dataprovider.DeleteFolder(targetFolder.Name, "", "", "")
+	assert.NoError(t, err)
+}
+
+func TestUserArchiveAction(t *testing.T) {
+	username := "test_user_archive"
+	archiveFolder := vfs.BaseVirtualFolder{
+		Name:       "user_archives",
+		MappedPath: filepath.Join(os.TempDir(), "user_archives"),
+	}
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: username,
+			Status:   1,
+			Permissions: map[string][]string{
+				"/": {dataprovider.PermAny},
+			},
+			HomeDir:        filepath.Join(os.TempDir(), username),
+			ExpirationDate: util.GetTimeAsMsSinceEpoch(time.Now().Add(-24 * time.Hour)),
+		},
+	}
+	err := dataprovider.AddUser(&user, "", "", "")
+	assert.NoError(t, err)
+	action := dataprovider.BaseEventAction{
+		Type: dataprovider.ActionTypeUserArchive,
+		Options: dataprovider.BaseEventActionOptions{
+			ArchiveConfig: dataprovider.EventActionUserArchiveConfig{
+				Folder: archiveFolder.Name,
+				Path:   "/expired",
+			},
+		},
+	}
+	conditions := dataprovider.ConditionOptions{
+		Names: []dataprovider.ConditionPattern{
+			{
+				Pattern: username,
+			},
+		},
+	}
+	err = executeRuleAction(action, &EventParams{}, conditions)
+	assert.Error(t, err) // the folder does not exist
+	err = dataprovider.AddFolder(&archiveFolder, "", "", "")
+	assert.NoError(t, err)
+	err = os.MkdirAll(filepath.Join(user.GetHomeDir(), "sub"), os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(user.GetHomeDir(), "sub", "file.txt"), []byte("archived content"), 0666)
+	assert.NoError(t, err)
+	err = dataprovider.UpdateUserQuota(&user, 1, 16, true)
+	assert.NoError(t, err)
+	// simulate another archive in progress
+	assert.True(t, activeUserArchives.add(username))
+	err = executeRuleAction(action, &EventParams{}, conditions)
+	assert.Error(t, err)
+	activeUserArchives.remove(username)
+
+	err = executeRuleAction(action, &EventParams{}, conditions)
+	assert.NoError(t, err)
+	userGet, err := dataprovider.UserExists(username, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, userGet.Status)
+	assert.Equal(t, 0, userGet.UsedQuotaFiles)
+	assert.Equal(t, int64(0), userGet.UsedQuotaSize)
+	assert.NoFileExists(t, filepath.Join(user.GetHomeDir(), "sub", "file.txt"))
+	if assert.NotNil(t, userGet.Filters.Archive) {
+		assert.Equal(t, archiveFolder.Name, userGet.Filters.Archive.Folder)
+		assert.True(t, strings.HasPrefix(userGet.Filters.Archive.Path, "/expired/"+username+"/"))
+		assert.Equal(t, 1, userGet.Filters.Archive.Files)
+		f, err := os.Open(filepath.Join(archiveFolder.MappedPath, filepath.FromSlash(userGet.Filters.Archive.Path)))
+		if assert.NoError(t, err) {
+			zr, err := zstd.NewReader(f)
+			assert.NoError(t, err)
+			tr := tar.NewReader(zr)
+			var names []string
+			for {
+				hdr, err := tr.Next()
+				if err != nil {
+					assert.ErrorIs(t, err, io.EOF)
+					break
+				}
+				names = append(names, hdr.Name)
+			}
+			assert.Equal(t, []string{"sub/", "sub/file.txt"}, names)
+			zr.Close()
+			f.Close()
+		}
+	}
+	// already archived, nothing to do
+	_, err = ArchiveExpiredUser(username, action.Options.ArchiveConfig)
+	assert.Error(t, err)
+	err = executeRuleAction(action, &EventParams{}, conditions)
+	assert.NoError(t, err)
+
+	err = dataprovider.DeleteUser(username, "", "", "")
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+	err = os.RemoveAll(archiveFolder.MappedPath)
+	assert.NoError(t, err)
+	err = dataprovider.DeleteFolder(archiveFolder.Name, "", "", "")
+	assert.NoError(t, err)
+}
+
+func TestRemoteBackupAction(t *testing.T) {
+	backupFolder := vfs.BaseVirtualFolder{
+		Name:       "remote_backups",
+		MappedPath: filepath.Join(os.TempDir(), "remote_backups"),
+	}
+	action := dataprovider.BaseEventAction{
+		Name: "remote backup",
+		Type: dataprovider.ActionTypeBackup,
+		Options: dataprovider.BaseEventActionOptions{
+			BackupConfig: dataprovider.EventActionBackupConfig{
+				Folder:     backupFolder.Name,
+				Path:       "/provider",
+				Passphrase: kms.NewPlainSecret("backup passphrase"),
+				Retention:  2,
+			},
+		},
+	}
+	err := executeRuleAction(action, &EventParams{}, dataprovider.ConditionOptions{})
+	assert.Error(t, err) // the folder does not exist
+	err = dataprovider.AddFolder(&backupFolder, "", "", "")
+	assert.NoError(t, err)
+	err = dataprovider.AddEventAction(&action, "", "", "")
+	assert.NoError(t, err)
+	// simulate older backups, only the most recent ones must be preserved
+	backupDir := filepath.Join(backupFolder.MappedPath, "provider")
+	err = os.MkdirAll(backupDir, os.ModePerm)
+	assert.NoError(t, err)
+	for _, name := range []string{"backup_20200101T000000Z.json", "backup_20210101T000000Z.json", "other.json"} {
+		err = os.WriteFile(filepath.Join(backupDir, name), []byte(`{"version":16}`), 0666)
+		assert.NoError(t, err)
+	}
+	action, err = dataprovider.EventActionExists(action.Name)
+	assert.NoError(t, err)
+	params := &EventParams{}
+	err = executeRuleAction(action, params, dataprovider.ConditionOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, params.FsPath)
+	assert.NoFileExists(t, filepath.Join(backupDir, "backup_20200101T000000Z.json"))
+	assert.FileExists(t, filepath.Join(backupDir, "other.json"))
+	backups, err := GetRemoteBackups(action.Name)
+	assert.NoError(t, err)
+	if assert.Len(t, backups, 2) {
+		assert.True(t, backups[0].Encrypted)
+		assert.True(t, strings.HasSuffix(backups[0].Name, ".json.enc"))
+		assert.Equal(t, "backup_20210101T000000Z.json", backups[1].Name)
+		assert.False(t, backups[1].Encrypted)
+		// the uploaded backup must be encrypted
+		content, err := os.ReadFile(filepath.Join(backupDir, backups[0].Name))
+		assert.NoError(t, err)
+		_, err = dataprovider.ParseDumpData(content)
+		assert.Error(t, err)
+
+		content, err = GetRemoteBackupContent(action.Name, backups[0].Name, 10*1048576)
+		assert.NoError(t, err)
+		dump, err := dataprovider.ParseDumpData(content)
+		assert.NoError(t, err)
+		assert.Equal(t, dataprovider.DumpVersion, dump.Version)
+		_, err = GetRemoteBackupContent(action.Name, backups[0].Name, 10)
+		assert.Error(t, err)
+		content, err = GetRemoteBackupContent(action.Name, backups[1].Name, 10*1048576)
+		assert.NoError(t, err)
+		assert.Equal(t, `{"version":16}`, string(content))
+	}
+	_, err = GetRemoteBackupContent(action.Name, "../other.json", 10*1048576)
+	assert.Error(t, err)
+	_, err = GetRemoteBackupContent(action.Name, "backup_20200101T000000Z.json", 10*1048576)
+	var errNotFound *util.RecordNotFoundError
+	assert.ErrorAs(t, err, &errNotFound)
+	_, err = GetRemoteBackups("missing action")
+	assert.Error(t, err)
+
+	err = dataprovider.DeleteEventAction(action.Name, "", "", "")
+	assert.NoError(t, err)
+	err = os.RemoveAll(backupFolder.MappedPath)
+	assert.NoError(t, err)
+	err = dataprovider.DeleteFolder(backupFolder.Name, "", "", "")
+	assert.NoError(t, err)
+}
+
 func TestIDPAccountCheckRule(t *testing.T) {
 	_, _, err := executeIDPAccountCheckRule(dataprovider.EventRule{}, EventParams{})
 	if assert.Error(t, err) {
@@ -1645,6 +2004,862 @@ func TestGetFileContent(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestChatNotificationAction(t *testing.T) {
+	var reqMethod, reqPath, reqAuth string
+	var reqBody map[string]string
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqMethod = r.Method
+		reqPath = r.URL.Path
+		reqAuth = r.Header.Get("Authorization")
+		reqBody = nil
+		json.NewDecoder(r.Body).Decode(&reqBody) //nolint:errcheck
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+
+	params := &EventParams{
+		Name:  "chat_user",
+		Event: operationUpload,
+	}
+	c := dataprovider.EventActionChatConfig{
+		Platform: dataprovider.ChatPlatformSlack,
+		Endpoint: server.URL + "/hook",
+		Channel:  "#alerts",
+		Message:  "{{Name}} {{Event}}",
+	}
+	err := executeChatRuleAction(c, params)
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPost, reqMethod)
+	assert.Equal(t, "/hook", reqPath)
+	assert.Equal(t, "chat_user upload", reqBody["text"])
+	assert.Equal(t, "#alerts", reqBody["channel"])
+	assert.Empty(t, reqAuth)
+
+	c.Platform = dataprovider.ChatPlatformTeams
+	err = executeChatRuleAction(c, params)
+	assert.NoError(t, err)
+	assert.Equal(t, "chat_user upload", reqBody["text"])
+	assert.Empty(t, reqBody["channel"])
+
+	c.Platform = dataprovider.ChatPlatformDiscord
+	err = executeChatRuleAction(c, params)
+	assert.NoError(t, err)
+	assert.Equal(t, "chat_user upload", reqBody["content"])
+
+	c.Platform = dataprovider.ChatPlatformMatrix
+	c.Endpoint = server.URL + "/"
+	c.Channel = "!room:example.com"
+	c.Token = kms.NewPlainSecret("matrix_token")
+	err = executeChatRuleAction(c, params)
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPut, reqMethod)
+	assert.True(t, strings.HasPrefix(reqPath, "/_matrix/client/v3/rooms/!room:example.com/send/m.room.message/"), reqPath)
+	assert.Equal(t, "Bearer matrix_token", reqAuth)
+	assert.Equal(t, "m.text", reqBody["msgtype"])
+	assert.Equal(t, "chat_user upload", reqBody["body"])
+
+	statusCode = http.StatusForbidden
+	err = executeChatRuleAction(c, params)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unexpected status code")
+	}
+	c.Platform = 100
+	err = executeChatRuleAction(c, params)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unsupported chat platform")
+	}
+	c.Platform = dataprovider.ChatPlatformDiscord
+	c.Endpoint = "http://127.0.0.1:1/hook"
+	err = executeChatRuleAction(c, params)
+	assert.Error(t, err)
+}
+
+func TestMessagePublishAction(t *testing.T) {
+	params := &EventParams{
+		Name:  "msg_user",
+		Event: operationUpload,
+	}
+	// NATS
+	natsListener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer natsListener.Close()
+	natsCommands := make(chan []string, 1)
+	go func() {
+		conn, err := natsListener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("INFO {\"server_id\":\"test\",\"max_payload\":1048576}\r\n")) //nolint:errcheck
+		reader := bufio.NewReader(conn)
+		var lines []string
+		numPings := 0
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "PING" {
+				conn.Write([]byte("PONG\r\n")) //nolint:errcheck
+				numPings++
+				// the client pings after connecting and to flush the published message
+				if numPings == 2 {
+					natsCommands <- lines
+				}
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}()
+	c := dataprovider.EventActionMessageConfig{
+		Protocol: dataprovider.MessagingProtocolNATS,
+		Endpoint: "nats://" + natsListener.Addr().String(),
+		Topic:    "sftpgo.{{Event}}",
+		Username: "nats_user",
+		Password: kms.NewPlainSecret("nats_pwd"),
+		Message:  "{{Name}} {{Event}}",
+	}
+	err = executeMessagePublishRuleAction(c, params)
+	assert.NoError(t, err)
+	select {
+	case lines := <-natsCommands:
+		if assert.Len(t, lines, 3) {
+			assert.True(t, strings.HasPrefix(lines[0], "CONNECT "))
+			var opts struct {
+				User string `json:"user"`
+				Pass string `json:"pass"`
+			}
+			err = json.Unmarshal([]byte(strings.TrimPrefix(lines[0], "CONNECT ")), &opts)
+			assert.NoError(t, err)
+			assert.Equal(t, "nats_user", opts.User)
+			assert.Equal(t, "nats_pwd", opts.Pass)
+			assert.Equal(t, "PUB sftpgo.upload 15", lines[1])
+			assert.Equal(t, "msg_user upload", lines[2])
+		}
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "NATS message not received")
+	}
+	c.Topic = "invalid subject"
+	err = executeMessagePublishRuleAction(c, params)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid NATS subject")
+	}
+	// MQTT
+	mqttListener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer mqttListener.Close()
+	mqttPublish := make(chan *packets.PublishPacket, 1)
+	mqttConnect := make(chan *packets.ConnectPacket, 1)
+	go func() {
+		conn, err := mqttListener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		packet, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		connect, ok := packet.(*packets.ConnectPacket)
+		if !ok {
+			return
+		}
+		mqttConnect <- connect
+		packets.NewControlPacket(packets.Connack).Write(conn) //nolint:errcheck
+		packet, err = packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		publish, ok := packet.(*packets.PublishPacket)
+		if !ok {
+			return
+		}
+		pubAck := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+		pubAck.MessageID = publish.MessageID
+		pubAck.Write(conn) //nolint:errcheck
+		mqttPublish <- publish
+		packets.ReadPacket(conn) //nolint:errcheck
+	}()
+	c = dataprovider.EventActionMessageConfig{
+		Protocol: dataprovider.MessagingProtocolMQTT,
+		Endpoint: "mqtt://" + mqttListener.Addr().String(),
+		Topic:    "sftpgo/{{Event}}",
+		Username: "mqtt_user",
+		Password: kms.NewPlainSecret("mqtt_pwd"),
+		Message:  "{{Name}} {{Event}}",
+	}
+	err = executeMessagePublishRuleAction(c, params)
+	assert.NoError(t, err)
+	select {
+	case connect := <-mqttConnect:
+		assert.Equal(t, "mqtt_user", connect.Username)
+		assert.Equal(t, "mqtt_pwd", string(connect.Password))
+		assert.True(t, connect.CleanSession)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "MQTT connect not received")
+	}
+	select {
+	case publish := <-mqttPublish:
+		assert.Equal(t, "sftpgo/upload", publish.TopicName)
+		assert.Equal(t, "msg_user upload", string(publish.Payload))
+		assert.Equal(t, byte(1), publish.Qos)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "MQTT message not received")
+	}
+	c.Topic = "sftpgo/#"
+	err = executeMessagePublishRuleAction(c, params)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid MQTT topic")
+	}
+	c.Topic = "sftpgo"
+	c.Endpoint = "mqtt://127.0.0.1:1"
+	err = executeMessagePublishRuleAction(c, params)
+	assert.Error(t, err)
+	c.Protocol = 100
+	err = executeMessagePublishRuleAction(c, params)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unsupported messaging protocol")
+	}
+	c.Topic = ""
+	err = executeMessagePublishRuleAction(c, params)
+	assert.Error(t, err)
+	_, _, _, err = getMessageBrokerAddress("nats://:4222", "4222", "4222")
+	assert.Error(t, err)
+	address, host, useTLS, err := getMessageBrokerAddress("mqtts://broker.example.com", "1883", "8883")
+	assert.NoError(t, err)
+	assert.Equal(t, "broker.example.com:8883", address)
+	assert.Equal(t, "broker.example.com", host)
+	assert.True(t, useTLS)
+}
+
+func consumeKafkaRecords(t *testing.T, cluster *kfake.Cluster, topic string, count int, opts ...kgo.Opt) []*kgo.Record {
+	opts = append(opts, kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.ConsumeTopics(topic))
+	client, err := kgo.NewClient(opts...)
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var records []*kgo.Record
+	for len(records) < count {
+		fetches := client.PollFetches(ctx)
+		if ctx.Err() != nil {
+			assert.Fail(t, "Kafka records not received", "received %d/%d", len(records), count)
+			break
+		}
+		records = append(records, fetches.Records()...)
+	}
+	return records
+}
+
+func TestKafkaPublishAction(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.SeedTopics(3, "sftpgo.upload"))
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	params := &EventParams{
+		Name:  "kafka_user",
+		Event: operationUpload,
+	}
+	c := dataprovider.EventActionMessageConfig{
+		Protocol: dataprovider.MessagingProtocolKafka,
+		Endpoint: "kafka://" + strings.Join(cluster.ListenAddrs(), ","),
+		Topic:    "sftpgo.{{Event}}",
+		Key:      "{{Name}}",
+		Message:  "{{Name}} {{Event}}",
+	}
+	err = executeMessagePublishRuleAction(c, params)
+	assert.NoError(t, err)
+	// the first produce request fails, the record is sent again
+	cluster.ControlKey(int16(kmsg.Produce), func(kmsg.Request) (kmsg.Response, error, bool) {
+		return nil, errors.New("produce error"), true
+	})
+	c.Acks = dataprovider.KafkaAcksAll
+	err = executeMessagePublishRuleAction(c, params)
+	assert.NoError(t, err)
+	c.Acks = dataprovider.KafkaAcksNone
+	err = executeMessagePublishRuleAction(c, params)
+	assert.NoError(t, err)
+	records := consumeKafkaRecords(t, cluster, "sftpgo.upload", 3)
+	if assert.Len(t, records, 3) {
+		for _, record := range records {
+			assert.Equal(t, "kafka_user", string(record.Key))
+			assert.Equal(t, "kafka_user upload", string(record.Value))
+			// records with the same key are sent to the same partition
+			assert.Equal(t, records[0].Partition, record.Partition)
+		}
+	}
+
+	c.Topic = "invalid topic"
+	err = executeMessagePublishRuleAction(c, params)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid Kafka topic")
+	}
+	c.Topic = "sftpgo"
+	c.Endpoint = "kafka://,"
+	err = executeMessagePublishRuleAction(c, params)
+	assert.Error(t, err)
+
+	brokers, useTLS, err := kafkaclient.GetBrokers("kafkas://broker1, broker2:9094,[::1]/")
+	assert.NoError(t, err)
+	assert.True(t, useTLS)
+	assert.Equal(t, []string{"broker1:9093", "broker2:9094", "[::1]:9093"}, brokers)
+	_, _, err = kafkaclient.GetBrokers("kafka://,")
+	assert.Error(t, err)
+	_, _, err = kafkaclient.GetBrokers("kafka://:9092")
+	assert.Error(t, err)
+}
+
+func TestKafkaPublishActionSASL(t *testing.T) {
+	for _, mechanism := range []int{dataprovider.KafkaSASLPlain, dataprovider.KafkaSASLScramSHA256,
+		dataprovider.KafkaSASLScramSHA512} {
+		var saslMechanism sasl.Mechanism
+		var saslMethod string
+		switch mechanism {
+		case dataprovider.KafkaSASLPlain:
+			saslMethod = "PLAIN"
+			saslMechanism = plain.Auth{User: "kafka_username", Pass: "kafka_password"}.AsMechanism()
+		case dataprovider.KafkaSASLScramSHA256:
+			saslMethod = "SCRAM-SHA-256"
+			saslMechanism = scram.Auth{User: "kafka_username", Pass: "kafka_password"}.AsSha256Mechanism()
+		default:
+			saslMethod = "SCRAM-SHA-512"
+			saslMechanism = scram.Auth{User: "kafka_username", Pass: "kafka_password"}.AsSha512Mechanism()
+		}
+		cluster, err := kfake.NewCluster(kfake.SeedTopics(1, "sftpgo"), kfake.EnableSASL(),
+			kfake.Superuser(saslMethod, "kafka_username", "kafka_password"))
+		require.NoError(t, err)
+
+		c := dataprovider.EventActionMessageConfig{
+			Protocol:      dataprovider.MessagingProtocolKafka,
+			Endpoint:      "kafka://" + strings.Join(cluster.ListenAddrs(), ","),
+			Topic:         "sftpgo",
+			Message:       "{{Name}} {{Event}}",
+			Username:      "kafka_username",
+			Password:      kms.NewPlainSecret("kafka_password"),
+			SASLMechanism: mechanism,
+		}
+		params := &EventParams{
+			Name:  "kafka_user",
+			Event: operationDownload,
+		}
+		err = executeMessagePublishRuleAction(c, params)
+		assert.NoError(t, err, saslMethod)
+		records := consumeKafkaRecords(t, cluster, "sftpgo", 1, kgo.SASL(saslMechanism))
+		if assert.Len(t, records, 1) {
+			assert.Nil(t, records[0].Key)
+			assert.Equal(t, "kafka_user download", string(records[0].Value))
+		}
+		c.Password = kms.NewPlainSecret("wrong")
+		err = executeMessagePublishRuleAction(c, params)
+		assert.Error(t, err, saslMethod)
+
+		cluster.Close()
+	}
+}
+
+func TestCloudQueueAction(t *testing.T) {
+	var reqAuth string
+	var reqForm url.Values
+	statusCode := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqAuth = r.Header.Get("Authorization")
+		r.ParseForm() //nolint:errcheck
+		reqForm = r.PostForm
+		w.WriteHeader(statusCode)
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "access_key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret_key")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", filepath.Join(os.TempDir(), "missing_credentials.json"))
+
+	params := &EventParams{
+		Name:  "queue_user",
+		Event: operationUpload,
+	}
+	c := dataprovider.EventActionCloudQueueConfig{
+		Service:  dataprovider.CloudQueueSQS,
+		Target:   "https://sqs.us-east-1.amazonaws.com/123456789012/queue",
+		Region:   "us-east-1",
+		Endpoint: server.URL,
+		Message:  "{{Name}} {{Event}}",
+	}
+	err := executeCloudQueueRuleAction(c, params)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(reqAuth, "AWS4-HMAC-SHA256 Credential=access_key/"), reqAuth)
+	assert.Contains(t, reqAuth, "/us-east-1/sqs/aws4_request")
+	assert.Equal(t, "SendMessage", reqForm.Get("Action"))
+	assert.Equal(t, c.Target, reqForm.Get("QueueUrl"))
+	assert.Equal(t, "queue_user upload", reqForm.Get("MessageBody"))
+
+	c.Service = dataprovider.CloudQueueSNS
+	c.Target = "arn:aws:sns:us-east-1:123456789012:topic"
+	err = executeCloudQueueRuleAction(c, params)
+	assert.NoError(t, err)
+	assert.Contains(t, reqAuth, "/us-east-1/sns/aws4_request")
+	assert.Equal(t, "Publish", reqForm.Get("Action"))
+	assert.Equal(t, c.Target, reqForm.Get("TopicArn"))
+	assert.Equal(t, "queue_user upload", reqForm.Get("Message"))
+
+	statusCode = http.StatusForbidden
+	err = executeCloudQueueRuleAction(c, params)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unexpected status code")
+	}
+
+	c.Service = dataprovider.CloudQueuePubSub
+	c.Target = "projects/p/topics/t"
+	err = executeCloudQueueRuleAction(c, params)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to get Google Cloud credentials")
+	}
+	c.Service = 100
+	err = executeCloudQueueRuleAction(c, params)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unsupported cloud queue service")
+	}
+}
+
+func TestQuotaThresholdLevels(t *testing.T) {
+	assert.Equal(t, 0, getQuotaUsagePercentage(10, 0, 100, 0))
+	assert.Equal(t, 50, getQuotaUsagePercentage(1, 0, 50, 100))
+	assert.Equal(t, 80, getQuotaUsagePercentage(8, 10, 50, 100))
+	assert.Equal(t, 120, getQuotaUsagePercentage(3, 10, 120, 100))
+
+	levels := newQuotaThresholdLevels()
+	thresholds := []int{80, 95}
+	assert.Equal(t, 0, levels.update("r", "user_u", thresholds, 5, 79))
+	assert.Equal(t, 80, levels.update("r", "user_u", thresholds, 5, 81))
+	assert.Equal(t, 0, levels.update("r", "user_u", thresholds, 5, 90))
+	// within the hysteresis, the threshold is not re-armed
+	assert.Equal(t, 0, levels.update("r", "user_u", thresholds, 5, 76))
+	assert.Equal(t, 0, levels.update("r", "user_u", thresholds, 5, 82))
+	assert.Equal(t, 95, levels.update("r", "user_u", thresholds, 5, 100))
+	assert.Equal(t, 0, levels.update("r", "user_u", thresholds, 5, 91))
+	// 95 is re-armed, 80 is still notified
+	assert.Equal(t, 0, levels.update("r", "user_u", thresholds, 5, 89))
+	assert.Equal(t, 80, levels.levels["r"]["user_u"])
+	assert.Equal(t, 95, levels.update("r", "user_u", thresholds, 5, 96))
+	assert.Equal(t, 0, levels.update("r", "user_u", thresholds, 5, 10))
+	assert.Len(t, levels.levels["r"], 0)
+	// other rules and objects are tracked independently
+	assert.Equal(t, 95, levels.update("r", "folder_u", thresholds, 5, 100))
+	assert.Equal(t, 80, levels.update("r1", "user_u", thresholds, 5, 85))
+	levels.removeRule("r")
+	assert.Len(t, levels.levels, 1)
+	assert.Equal(t, 80, levels.update("r", "folder_u", thresholds, 5, 85))
+}
+
+func TestQuotaThresholdRule(t *testing.T) {
+	thresholdsCh := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		thresholdsCh <- r.URL.Query().Get("t") + "_" + r.URL.Query().Get("u")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	username := "test_user_quota_threshold"
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: username,
+			Permissions: map[string][]string{
+				"/": {dataprovider.PermAny},
+			},
+			HomeDir:   filepath.Join(os.TempDir(), username),
+			QuotaSize: 100,
+		},
+	}
+	err := dataprovider.AddUser(&user, "", "", "")
+	assert.NoError(t, err)
+	action := &dataprovider.BaseEventAction{
+		Name: "quota_threshold_action",
+		Type: dataprovider.ActionTypeHTTP,
+		Options: dataprovider.BaseEventActionOptions{
+			HTTPConfig: dataprovider.EventActionHTTPConfig{
+				Endpoint: server.URL,
+				Timeout:  20,
+				Method:   http.MethodGet,
+				QueryParameters: []dataprovider.KeyValue{
+					{
+						Key:   "t",
+						Value: "{{QuotaThreshold}}",
+					},
+					{
+						Key:   "u",
+						Value: "{{Name}}",
+					},
+				},
+			},
+		},
+	}
+	err = dataprovider.AddEventAction(action, "", "", "")
+	assert.NoError(t, err)
+	rule := &dataprovider.EventRule{
+		Name:    "quota_threshold_rule",
+		Status:  1,
+		Trigger: dataprovider.EventTriggerQuotaThreshold,
+		Conditions: dataprovider.EventConditions{
+			QuotaThresholds: []int{95, 50, 50},
+			QuotaHysteresis: 10,
+			Options: dataprovider.ConditionOptions{
+				ProviderObjects: []string{"user"},
+			},
+		},
+		Actions: []dataprovider.EventAction{
+			{
+				BaseEventAction: dataprovider.BaseEventAction{
+					Name: action.Name,
+				},
+				Order: 1,
+			},
+		},
+	}
+	err = dataprovider.AddEventRule(rule, "", "", "")
+	assert.NoError(t, err)
+	ruleGet, err := dataprovider.EventRuleExists(rule.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{50, 95}, ruleGet.Conditions.QuotaThresholds)
+
+	eventManager.RLock()
+	assert.Len(t, eventManager.QuotaEvents, 1)
+	eventManager.RUnlock()
+
+	getLevel := func() int {
+		eventManager.quotaLevels.Lock()
+		defer eventManager.quotaLevels.Unlock()
+
+		return eventManager.quotaLevels.levels[rule.Name]["user_"+username]
+	}
+	checkNotification := func(expected string) {
+		select {
+		case val := <-thresholdsCh:
+			assert.Equal(t, expected, val)
+		case <-time.After(5 * time.Second):
+			assert.Fail(t, "quota threshold event not received")
+		}
+	}
+
+	err = dataprovider.UpdateUserQuota(&user, 1, 60, false)
+	assert.NoError(t, err)
+	checkNotification("50_" + username)
+	// the usage is within the hysteresis, nothing changes
+	err = dataprovider.UpdateUserQuota(&user, 0, -15, false)
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 50, getLevel())
+	// below the hysteresis, the threshold is re-armed
+	err = dataprovider.UpdateUserQuota(&user, 0, -20, false)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return getLevel() == 0
+	}, 2*time.Second, 50*time.Millisecond)
+	err = dataprovider.UpdateUserQuota(&user, 0, 30, false)
+	assert.NoError(t, err)
+	checkNotification("50_" + username)
+	err = dataprovider.UpdateUserQuota(&user, 0, 40, false)
+	assert.NoError(t, err)
+	checkNotification("95_" + username)
+	assert.Len(t, thresholdsCh, 0)
+
+	err = dataprovider.DeleteEventRule(rule.Name, "", "", "")
+	assert.NoError(t, err)
+	eventManager.quotaLevels.Lock()
+	assert.Len(t, eventManager.quotaLevels.levels[rule.Name], 0)
+	eventManager.quotaLevels.Unlock()
+	err = dataprovider.DeleteEventAction(action.Name, "", "", "")
+	assert.NoError(t, err)
+	err = dataprovider.DeleteUser(username, "", "", "")
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestUserInactivityRule(t *testing.T) {
+	startEventScheduler()
+	defer stopEventScheduler()
+
+	now := time.Now()
+	u := dataprovider.User{}
+	assert.Equal(t, 0, getUserInactivityDays(&u, now))
+	u.CreatedAt = util.GetTimeAsMsSinceEpoch(now.Add(-73 * time.Hour))
+	assert.Equal(t, 3, getUserInactivityDays(&u, now))
+	u.LastLogin = util.GetTimeAsMsSinceEpoch(now.Add(-25 * time.Hour))
+	assert.Equal(t, 1, getUserInactivityDays(&u, now))
+
+	inactiveCh := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inactiveCh <- r.URL.Query().Get("u") + "_" + r.URL.Query().Get("d")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	username := "test_user_inactivity"
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: username,
+			Status:   1,
+			Permissions: map[string][]string{
+				"/": {dataprovider.PermAny},
+			},
+			HomeDir: filepath.Join(os.TempDir(), username),
+		},
+	}
+	err := dataprovider.AddUser(&user, "", "", "")
+	assert.NoError(t, err)
+	action1 := &dataprovider.BaseEventAction{
+		Name: "inactivity_http_action",
+		Type: dataprovider.ActionTypeHTTP,
+		Options: dataprovider.BaseEventActionOptions{
+			HTTPConfig: dataprovider.EventActionHTTPConfig{
+				Endpoint: server.URL,
+				Timeout:  20,
+				Method:   http.MethodGet,
+				QueryParameters: []dataprovider.KeyValue{
+					{
+						Key:   "u",
+						Value: "{{Name}}",
+					},
+					{
+						Key:   "d",
+						Value: "{{InactivityDays}}",
+					},
+				},
+			},
+		},
+	}
+	err = dataprovider.AddEventAction(action1, "", "", "")
+	assert.NoError(t, err)
+	action2 := &dataprovider.BaseEventAction{
+		Name: "inactivity_disable_action",
+		Type: dataprovider.ActionTypeUserDisable,
+	}
+	err = dataprovider.AddEventAction(action2, "", "", "")
+	assert.NoError(t, err)
+	rule := &dataprovider.EventRule{
+		Name:    "user_inactivity_rule",
+		Status:  1,
+		Trigger: dataprovider.EventTriggerUserInactivity,
+		Conditions: dataprovider.EventConditions{
+			Schedules: []dataprovider.Schedule{
+				{
+					Hours:      "3",
+					DayOfWeek:  "*",
+					DayOfMonth: "*",
+					Month:      "*",
+				},
+			},
+			Options: dataprovider.ConditionOptions{
+				Names: []dataprovider.ConditionPattern{
+					{
+						Pattern: username,
+					},
+				},
+			},
+		},
+		Actions: []dataprovider.EventAction{
+			{
+				BaseEventAction: dataprovider.BaseEventAction{
+					Name: action1.Name,
+				},
+				Order: 1,
+			},
+			{
+				BaseEventAction: dataprovider.BaseEventAction{
+					Name: action2.Name,
+				},
+				Order: 2,
+			},
+		},
+	}
+	err = dataprovider.AddEventRule(rule, "", "", "")
+	assert.Error(t, err)
+	rule.Conditions.InactivityDays = 1
+	err = dataprovider.AddEventRule(rule, "", "", "")
+	assert.NoError(t, err)
+	ruleGet, err := dataprovider.EventRuleExists(rule.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, ruleGet.Conditions.InactivityDays)
+	eventManager.RLock()
+	assert.Len(t, eventManager.schedulesMapping[rule.Name], 1)
+	eventManager.RUnlock()
+	// the user was just created
+	executeUserInactivityRule(ruleGet)
+	assert.Len(t, inactiveCh, 0)
+	user, err = dataprovider.UserExists(username, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, user.Status)
+
+	ruleGet.Conditions.InactivityDays = 0
+	executeUserInactivityRule(ruleGet)
+	select {
+	case val := <-inactiveCh:
+		assert.Equal(t, username+"_0", val)
+	case <-time.After(5 * time.Second):
+		assert.Fail(t, "user inactivity event not received")
+	}
+	user, err = dataprovider.UserExists(username, "")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, user.Status)
+	// disabled users are not matched
+	executeUserInactivityRule(ruleGet)
+	assert.Len(t, inactiveCh, 0)
+
+	err = executeUserDisableRuleAction(dataprovider.ConditionOptions{
+		Names: []dataprovider.ConditionPattern{
+			{
+				Pattern: "missing_user",
+			},
+		},
+	}, &EventParams{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no user disabled")
+	}
+	err = executeUserDisableRuleAction(dataprovider.ConditionOptions{}, &EventParams{
+		sender: "missing_user",
+	})
+	assert.Error(t, err)
+
+	err = dataprovider.DeleteEventRule(rule.Name, "", "", "")
+	assert.NoError(t, err)
+	eventManager.RLock()
+	assert.Len(t, eventManager.schedulesMapping[rule.Name], 0)
+	eventManager.RUnlock()
+	err = dataprovider.DeleteEventAction(action1.Name, "", "", "")
+	assert.NoError(t, err)
+	err = dataprovider.DeleteEventAction(action2.Name, "", "", "")
+	assert.NoError(t, err)
+	err = dataprovider.DeleteUser(username, "", "", "")
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestUsageReportAction(t *testing.T) {
+	users := []dataprovider.User{
+		{
+			BaseUser: sdk.BaseUser{
+				Username:                 "user2",
+				Role:                     "role1",
+				Status:                   1,
+				UsedQuotaSize:            100,
+				UsedQuotaFiles:           2,
+				UsedUploadDataTransfer:   10,
+				UsedDownloadDataTransfer: 20,
+			},
+		},
+		{
+			BaseUser: sdk.BaseUser{
+				Username:       "user1",
+				Role:           "role1",
+				QuotaSize:      1000,
+				UsedQuotaSize:  50,
+				UsedQuotaFiles: 1,
+				LastLogin:      util.GetTimeAsMsSinceEpoch(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)),
+			},
+		},
+		{
+			BaseUser: sdk.BaseUser{
+				Username:               "user3",
+				UsedUploadDataTransfer: 5,
+			},
+		},
+	}
+	rows := getUsageReportRows(users, dataprovider.UsageReportGroupByUser)
+	if assert.Len(t, rows, 3) {
+		assert.Equal(t, "user1", rows[0].name)
+		assert.Equal(t, "user3", rows[2].name)
+	}
+	data, err := getCSVUsageReport(rows, dataprovider.UsageReportGroupByUser)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "user1,role1,0,1000,50,0,1,0,0,2024-05-01T10:00:00Z")
+	assert.Contains(t, string(data), "user2,role1,1,0,100,0,2,10,20,")
+	rows = getUsageReportRows(users, dataprovider.UsageReportGroupByRole)
+	if assert.Len(t, rows, 2) {
+		assert.Equal(t, "", rows[0].name)
+		assert.Equal(t, 1, rows[0].users)
+		assert.Equal(t, "role1", rows[1].name)
+		assert.Equal(t, 2, rows[1].users)
+		assert.Equal(t, int64(150), rows[1].usedQuotaSize)
+	}
+	data, err = getCSVUsageReport(rows, dataprovider.UsageReportGroupByRole)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "role,users,used size (bytes)")
+	assert.Contains(t, string(data), "role1,2,150,3,10,20")
+
+	username := "test_user_usage_report"
+	reportFolder := vfs.BaseVirtualFolder{
+		Name:       "usage_reports",
+		MappedPath: filepath.Join(os.TempDir(), "usage_reports"),
+	}
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: username,
+			Status:   1,
+			Permissions: map[string][]string{
+				"/": {dataprovider.PermAny},
+			},
+			HomeDir: filepath.Join(os.TempDir(), username),
+		},
+	}
+	err = dataprovider.AddUser(&user, "", "", "")
+	assert.NoError(t, err)
+	action := dataprovider.BaseEventAction{
+		Type: dataprovider.ActionTypeUsageReport,
+		Options: dataprovider.BaseEventActionOptions{
+			UsageReportConfig: dataprovider.EventActionUsageReportConfig{
+				GroupBy: dataprovider.UsageReportGroupByUser,
+				Folder:  reportFolder.Name,
+				Path:    "/monthly",
+			},
+		},
+	}
+	conditions := dataprovider.ConditionOptions{
+		Names: []dataprovider.ConditionPattern{
+			{
+				Pattern: username,
+			},
+		},
+	}
+	err = executeRuleAction(action, &EventParams{}, conditions)
+	assert.Error(t, err) // the folder does not exist
+	err = dataprovider.AddFolder(&reportFolder, "", "", "")
+	assert.NoError(t, err)
+	err = executeRuleAction(action, &EventParams{}, conditions)
+	assert.NoError(t, err)
+	entries, err := os.ReadDir(filepath.Join(reportFolder.MappedPath, "monthly"))
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.True(t, strings.HasPrefix(entries[0].Name(), usageReportPrefix))
+		content, err := os.ReadFile(filepath.Join(reportFolder.MappedPath, "monthly", entries[0].Name()))
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), username)
+	}
+	// smtp is not configured
+	action.Options.UsageReportConfig.Recipients = []string{"example@example.net"}
+	err = executeRuleAction(action, &EventParams{}, conditions)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "email")
+	}
+	conditions.Names[0].Pattern = "missing_user"
+	err = executeRuleAction(action, &EventParams{}, conditions)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no user matches")
+	}
+
+	err = dataprovider.DeleteUser(username, "", "", "")
+	assert.NoError(t, err)
+	err = dataprovider.DeleteFolder(reportFolder.Name, "", "", "")
+	assert.NoError(t, err)
+	err = os.RemoveAll(reportFolder.MappedPath)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestFilesystemActionErrors(t *testing.T) {
 	err := executeFsRuleAction(dataprovider.EventActionFilesystemConfig{}, dataprovider.ConditionOptions{}, &EventParams{})
 	if assert.Error(t, err) {
@@ -1680,6 +2895,16 @@ func TestFilesystemActionErrors(t *testing.T) {
 		sender: username,
 	})
 	assert.Error(t, err)
+	err = executeEmailRuleAction(dataprovider.EventActionEmailConfig{
+		Recipients:   []string{"test@example.net"},
+		Subject:      "subject",
+		Body:         `<img src="cid:missing.png">`,
+		ContentType:  1,
+		InlineImages: []string{"missing.png"},
+	}, &EventParams{})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "inline image")
+	}
 	conn := NewBaseConnection("", protocolEventAction, "", "", user)
 	err = executeDeleteFileFsAction(conn, "", nil)
 	assert.Error(t, err)
@@ -2295,3 +3520,18 @@ func TestMetadataReplacement(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, `{"key":"value"} {\"key\":\"value\"}`, string(data))
 }
+
+func TestAttributesReplacement(t *testing.T) {
+	params := &EventParams{
+		Name: "user1",
+		Attributes: map[string]string{
+			"department": "sales",
+		},
+	}
+	replacements := params.getStringReplacements(false, false)
+	replacer := strings.NewReplacer(replacements...)
+	assert.Equal(t, "/sales/user1", replacer.Replace("/{{Attributedepartment}}/{{Name}}"))
+	paramsCopy := params.getACopy()
+	paramsCopy.Attributes["department"] = "support"
+	assert.Equal(t, "sales", params.Attributes["department"])
+}
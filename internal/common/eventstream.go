@@ -0,0 +1,251 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// Supported event stream types
+const (
+	StreamEventFs              = "fs"
+	StreamEventLogin           = "login"
+	StreamEventConnectionOpen  = "connection_open"
+	StreamEventConnectionClose = "connection_close"
+)
+
+const (
+	maxEventStreamSubscribers = 100
+	// events are dropped for slow subscribers if their buffer is full
+	eventStreamBufferSize = 256
+)
+
+var (
+	eventStream = newEventStreamBroker()
+	// StreamEventTypes defines the supported event stream types
+	StreamEventTypes = []string{StreamEventFs, StreamEventLogin, StreamEventConnectionOpen, StreamEventConnectionClose}
+	// ErrTooManySubscribers is returned if the maximum number of event stream subscribers is reached
+	ErrTooManySubscribers = errors.New("too many event stream subscribers")
+)
+
+func init() {
+	dataprovider.SetLoginEventCallback(func(user *dataprovider.User, loginMethod, ip, protocol string, err error) {
+		if !eventStream.hasSubscribers() {
+			return
+		}
+		ev := StreamEvent{
+			Type:     StreamEventLogin,
+			Username: user.Username,
+			Role:     user.Role,
+			IP:       ip,
+			Protocol: protocol,
+			Action:   loginMethod,
+			Status:   1,
+		}
+		if err != nil {
+			ev.Status = 2
+			ev.Error = err.Error()
+		}
+		eventStream.publish(&ev)
+	})
+}
+
+// StreamEvent defines an event pushed to the event stream subscribers
+type StreamEvent struct {
+	Type string `json:"type"`
+	// Unix timestamp in milliseconds
+	Timestamp    int64  `json:"timestamp"`
+	Username     string `json:"username,omitempty"`
+	Role         string `json:"role,omitempty"`
+	IP           string `json:"ip,omitempty"`
+	Protocol     string `json:"protocol,omitempty"`
+	ConnectionID string `json:"connection_id,omitempty"`
+	// Filesystem action or login method
+	Action            string `json:"action,omitempty"`
+	VirtualPath       string `json:"virtual_path,omitempty"`
+	VirtualTargetPath string `json:"virtual_target_path,omitempty"`
+	FileSize          int64  `json:"file_size,omitempty"`
+	Elapsed           int64  `json:"elapsed,omitempty"`
+	// 1 means success, 2 error, 3 quota exceeded error. Not set for connection events
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// EventStreamSubscription defines a subscription to the event stream
+type EventStreamSubscription struct {
+	ID        string
+	types     []string
+	usernames []string
+	role      string
+	events    chan *StreamEvent
+	dropped   atomic.Int64
+}
+
+// Events returns the channel to receive the subscribed events
+func (s *EventStreamSubscription) Events() <-chan *StreamEvent {
+	return s.events
+}
+
+// GetDropped returns the number of events dropped because the subscriber was too slow
+func (s *EventStreamSubscription) GetDropped() int64 {
+	return s.dropped.Load()
+}
+
+func (s *EventStreamSubscription) matches(ev *StreamEvent) bool {
+	if s.role != "" && ev.Role != s.role {
+		return false
+	}
+	if len(s.types) > 0 && !slices.Contains(s.types, ev.Type) {
+		return false
+	}
+	if len(s.usernames) > 0 && !slices.Contains(s.usernames, ev.Username) {
+		return false
+	}
+	return true
+}
+
+func (s *EventStreamSubscription) send(ev *StreamEvent) {
+	select {
+	case s.events <- ev:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+type eventStreamBroker struct {
+	mu            sync.RWMutex
+	subscriptions map[string]*EventStreamSubscription
+	// number of subscriptions, it allows to check for subscribers without locking
+	count atomic.Int32
+}
+
+func newEventStreamBroker() *eventStreamBroker {
+	return &eventStreamBroker{
+		subscriptions: make(map[string]*EventStreamSubscription),
+	}
+}
+
+func (b *eventStreamBroker) hasSubscribers() bool {
+	return b.count.Load() > 0
+}
+
+func (b *eventStreamBroker) subscribe(types, usernames []string, role string) (*EventStreamSubscription, error) {
+	for _, t := range types {
+		if !slices.Contains(StreamEventTypes, t) {
+			return nil, util.NewValidationError("invalid event type: " + t)
+		}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.subscriptions) >= maxEventStreamSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+	s := &EventStreamSubscription{
+		ID:        xid.New().String(),
+		types:     types,
+		usernames: usernames,
+		role:      role,
+		events:    make(chan *StreamEvent, eventStreamBufferSize),
+	}
+	b.subscriptions[s.ID] = s
+	b.count.Store(int32(len(b.subscriptions)))
+	return s, nil
+}
+
+func (b *eventStreamBroker) unsubscribe(s *EventStreamSubscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscriptions, s.ID)
+	b.count.Store(int32(len(b.subscriptions)))
+}
+
+func (b *eventStreamBroker) publish(ev *StreamEvent) {
+	if ev.Timestamp == 0 {
+		ev.Timestamp = util.GetTimeAsMsSinceEpoch(time.Now())
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, s := range b.subscriptions {
+		if s.matches(ev) {
+			s.send(ev)
+		}
+	}
+}
+
+// SubscribeEvents subscribes to the event stream. Empty types or usernames
+// means any type or user, if role is not empty only the events for the users
+// with the specified role are received. The subscription must be removed using
+// UnsubscribeEvents when no longer needed
+func SubscribeEvents(types, usernames []string, role string) (*EventStreamSubscription, error) {
+	return eventStream.subscribe(types, usernames, role)
+}
+
+// UnsubscribeEvents removes the given event stream subscription
+func UnsubscribeEvents(s *EventStreamSubscription) {
+	eventStream.unsubscribe(s)
+}
+
+func publishConnectionEvent(eventType string, c ActiveConnection) {
+	if !eventStream.hasSubscribers() {
+		return
+	}
+	eventStream.publish(&StreamEvent{
+		Type:         eventType,
+		Username:     c.GetUsername(),
+		Role:         c.GetRole(),
+		IP:           util.GetIPFromRemoteAddress(c.GetRemoteAddress()),
+		Protocol:     c.GetProtocol(),
+		ConnectionID: c.GetID(),
+	})
+}
+
+func publishFsEvent(conn *BaseConnection, operation, virtualPath, virtualTarget string, fileSize int64, err error,
+	elapsed int64,
+) {
+	if !eventStream.hasSubscribers() {
+		return
+	}
+	ev := StreamEvent{
+		Type:              StreamEventFs,
+		Username:          conn.User.Username,
+		Role:              conn.User.Role,
+		IP:                conn.GetRemoteIP(),
+		Protocol:          conn.protocol,
+		ConnectionID:      conn.ID,
+		Action:            operation,
+		VirtualPath:       virtualPath,
+		VirtualTargetPath: virtualTarget,
+		FileSize:          fileSize,
+		Elapsed:           elapsed,
+		Status:            conn.getNotificationStatus(err),
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	eventStream.publish(&ev)
+}
@@ -0,0 +1,269 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Lock mask bits, the values are the same defined for the SFTP v6 block request
+const (
+	// other owners cannot read the locked range
+	LockBlockRead uint32 = 0x00000040
+	// other owners cannot write the locked range
+	LockBlockWrite uint32 = 0x00000080
+	// other owners cannot delete or rename the locked file
+	LockBlockDelete uint32 = 0x00000100
+	// the lock is only used to coordinate the clients, access is not denied
+	LockBlockAdvisory uint32 = 0x00000200
+)
+
+// lock errors
+var (
+	ErrLockConflict   = errors.New("byte range lock conflict")
+	ErrNoMatchingLock = errors.New("no matching byte range lock")
+)
+
+// FileLocks holds the locks for the user paths, shared among the supported protocols
+var FileLocks FileLockManager
+
+// FileLock defines a lock on a byte range, or on the whole file, for a user path.
+// Locks are shared among the supported protocols
+type FileLock struct {
+	// Lock owner, for example the connection ID for SFTP and the lock token for WebDAV
+	Owner string
+	// Protocol used to acquire the lock
+	Protocol string
+	// Locked range, 0 length means up to the end of the file
+	Offset uint64
+	Length uint64
+	// Lock mask, a combination of the LockBlock* values
+	Mask uint32
+	// Exclusive locks conflict with any other overlapping lock
+	Exclusive bool
+	// Recursive locks apply to all the paths inside the locked one
+	Recursive bool
+	// Zero means no expiration
+	ExpiresAt time.Time
+	path      string
+}
+
+func (l *FileLock) isExpired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && l.ExpiresAt.Before(now)
+}
+
+func (l *FileLock) matchesPath(name string) bool {
+	if l.path == name {
+		return true
+	}
+	return l.Recursive && strings.HasPrefix(name, strings.TrimSuffix(l.path, "/")+"/")
+}
+
+func (l *FileLock) getEnd() uint64 {
+	if l.Length == 0 || l.Offset+l.Length < l.Offset {
+		return 0
+	}
+	return l.Offset + l.Length
+}
+
+func (l *FileLock) overlaps(other *FileLock) bool {
+	end := l.getEnd()
+	otherEnd := other.getEnd()
+	if end != 0 && end <= other.Offset {
+		return false
+	}
+	if otherEnd != 0 && otherEnd <= l.Offset {
+		return false
+	}
+	return true
+}
+
+func (l *FileLock) conflicts(other *FileLock) bool {
+	if l.Owner == other.Owner {
+		return false
+	}
+	if !l.Exclusive && !other.Exclusive {
+		return false
+	}
+	return l.overlaps(other)
+}
+
+// FileLockManager handles the locks for the user paths
+type FileLockManager struct {
+	sync.Mutex
+	// the map key is the username
+	locks map[string][]FileLock
+}
+
+// removeExpired removes the expired locks for the specified username,
+// it must be called within a locked block
+func (m *FileLockManager) removeExpired(username string, now time.Time) []FileLock {
+	locks := m.locks[username]
+	validLocks := locks[:0]
+	for _, l := range locks {
+		if !l.isExpired(now) {
+			validLocks = append(validLocks, l)
+		}
+	}
+	if len(validLocks) == 0 {
+		delete(m.locks, username)
+		return nil
+	}
+	m.locks[username] = validLocks
+	return validLocks
+}
+
+// Add adds the specified lock for the given user path.
+// ErrLockConflict is returned if the lock conflicts with an existing one
+func (m *FileLockManager) Add(username, virtualPath string, lock FileLock) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.locks == nil {
+		m.locks = make(map[string][]FileLock)
+	}
+	lock.path = virtualPath
+	for _, l := range m.removeExpired(username, time.Now()) {
+		if (l.matchesPath(virtualPath) || lock.matchesPath(l.path)) && l.conflicts(&lock) {
+			return ErrLockConflict
+		}
+	}
+	m.locks[username] = append(m.locks[username], lock)
+	return nil
+}
+
+// Remove removes the lock with the specified owner and range for the given user path
+func (m *FileLockManager) Remove(username, virtualPath, owner string, offset, length uint64) error {
+	m.Lock()
+	defer m.Unlock()
+
+	locks := m.locks[username]
+	for idx, l := range locks {
+		if l.path == virtualPath && l.Owner == owner && l.Offset == offset && l.Length == length {
+			locks = append(locks[:idx], locks[idx+1:]...)
+			if len(locks) == 0 {
+				delete(m.locks, username)
+			} else {
+				m.locks[username] = locks
+			}
+			return nil
+		}
+	}
+	return ErrNoMatchingLock
+}
+
+// RemoveOwnerLocks removes all the locks for the specified owner
+func (m *FileLockManager) RemoveOwnerLocks(owner string) {
+	m.Lock()
+	defer m.Unlock()
+
+	for username, locks := range m.locks {
+		validLocks := locks[:0]
+		for _, l := range locks {
+			if l.Owner != owner {
+				validLocks = append(validLocks, l)
+			}
+		}
+		if len(validLocks) == 0 {
+			delete(m.locks, username)
+		} else {
+			m.locks[username] = validLocks
+		}
+	}
+}
+
+// RemovePathLocks removes the locks acquired using the specified protocol for the
+// given user path and, if recursive is true, for the paths inside it
+func (m *FileLockManager) RemovePathLocks(username, virtualPath, protocol string, recursive bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	locks := m.locks[username]
+	validLocks := locks[:0]
+	dirPrefix := strings.TrimSuffix(virtualPath, "/") + "/"
+	for _, l := range locks {
+		if l.Protocol == protocol && (l.path == virtualPath || (recursive && strings.HasPrefix(l.path, dirPrefix))) {
+			continue
+		}
+		validLocks = append(validLocks, l)
+	}
+	if len(validLocks) == 0 {
+		delete(m.locks, username)
+	} else {
+		m.locks[username] = validLocks
+	}
+}
+
+// Refresh updates the expiration for all the locks of the specified owner
+func (m *FileLockManager) Refresh(owner string, expiresAt time.Time) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, locks := range m.locks {
+		for idx := range locks {
+			if locks[idx].Owner == owner {
+				locks[idx].ExpiresAt = expiresAt
+			}
+		}
+	}
+}
+
+// GetLocks returns the locks for the specified user
+func (m *FileLockManager) GetLocks(username string) []FileLock {
+	m.Lock()
+	defer m.Unlock()
+
+	locks := m.removeExpired(username, time.Now())
+	result := make([]FileLock, len(locks))
+	copy(result, locks)
+	return result
+}
+
+// CheckAccess returns ErrLockConflict if an operation, defined by the lock mask,
+// is not allowed for the specified user path because of the locks owned by others.
+// Locks acquired using the excluded protocol and advisory locks are ignored
+func (m *FileLockManager) CheckAccess(username, virtualPath, owner, excludedProtocol string, mask uint32) error {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, l := range m.removeExpired(username, time.Now()) {
+		if l.Owner == owner || l.Mask&LockBlockAdvisory != 0 {
+			continue
+		}
+		if excludedProtocol != "" && l.Protocol == excludedProtocol {
+			continue
+		}
+		if l.Mask&mask != 0 && l.matchesPath(virtualPath) {
+			return ErrLockConflict
+		}
+	}
+	return nil
+}
+
+func getLockMaskForOperation(operation string) uint32 {
+	switch operation {
+	case OperationPreUpload:
+		return LockBlockWrite
+	case OperationPreDownload:
+		return LockBlockRead
+	case operationPreDelete:
+		return LockBlockDelete
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// ActiveKeyRotations holds the active key rotations
+var ActiveKeyRotations KeyRotations
+
+// KeyRotation defines an active key rotation for a local encrypted filesystem
+type KeyRotation struct {
+	// Username to which the key rotation refers
+	Username string `json:"username"`
+	// rotation start time as unix timestamp in milliseconds
+	StartTime int64 `json:"start_time"`
+	// number of files re-encrypted so far
+	Files int `json:"files"`
+	// size of the files re-encrypted so far as bytes
+	Size int64  `json:"size"`
+	Role string `json:"-"`
+}
+
+// KeyRotations holds the active key rotations
+type KeyRotations struct {
+	sync.RWMutex
+	rotations []KeyRotation
+}
+
+// Get returns the active key rotations
+func (r *KeyRotations) Get(role string) []KeyRotation {
+	r.RLock()
+	defer r.RUnlock()
+
+	rotations := make([]KeyRotation, 0, len(r.rotations))
+	for _, rotation := range r.rotations {
+		if role == "" || role == rotation.Role {
+			rotations = append(rotations, KeyRotation{
+				Username:  rotation.Username,
+				StartTime: rotation.StartTime,
+				Files:     rotation.Files,
+				Size:      rotation.Size,
+			})
+		}
+	}
+
+	return rotations
+}
+
+// Add adds a user to the ones with active key rotations.
+// Return false if a key rotation is already active for the specified user
+func (r *KeyRotations) Add(username, role string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	for idx := range r.rotations {
+		if r.rotations[idx].Username == username {
+			return false
+		}
+	}
+
+	r.rotations = append(r.rotations, KeyRotation{
+		Username:  username,
+		StartTime: util.GetTimeAsMsSinceEpoch(time.Now()),
+		Role:      role,
+	})
+
+	return true
+}
+
+// Remove removes a user from the ones with active key rotations
+func (r *KeyRotations) Remove(username string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	for idx := range r.rotations {
+		if r.rotations[idx].Username == username {
+			lastIdx := len(r.rotations) - 1
+			r.rotations[idx] = r.rotations[lastIdx]
+			r.rotations = r.rotations[:lastIdx]
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *KeyRotations) updateProgress(username string, size int64) {
+	r.Lock()
+	defer r.Unlock()
+
+	for idx := range r.rotations {
+		if r.rotations[idx].Username == username {
+			r.rotations[idx].Files++
+			r.rotations[idx].Size += size
+			return
+		}
+	}
+}
+
+// RotateEncryptionKey re-encrypts, using the current passphrase, the files of the
+// specified user still encrypted with an old passphrase and then removes the old
+// passphrases. The rotation must be added to the active ones before calling this
+// function, it will be removed when the rotation ends
+func RotateEncryptionKey(user dataprovider.User, executor, ipAddress string) error {
+	defer ActiveKeyRotations.Remove(user.Username)
+
+	passphrase := user.FsConfig.CryptConfig.Passphrase.GetPayload()
+	err := user.ReencryptFiles(func(size int64) {
+		ActiveKeyRotations.updateProgress(user.Username, size)
+	})
+	if err != nil {
+		logger.Warn(logSender, "", "unable to rotate the encryption key for user %q: %v", user.Username, err)
+		return err
+	}
+	// the passphrase could be changed while re-encrypting
+	current, err := dataprovider.UserExists(user.Username, "")
+	if err != nil {
+		return err
+	}
+	if current.FsConfig.Provider != sdk.CryptedFilesystemProvider ||
+		current.FsConfig.CryptConfig.Passphrase.GetPayload() != passphrase {
+		logger.Warn(logSender, "", "the passphrase for user %q changed during the key rotation, old passphrases preserved",
+			user.Username)
+		return errors.New("the passphrase changed during the key rotation")
+	}
+	current.FsConfig.CryptConfig.OldPassphrases = nil
+	if err := dataprovider.UpdateUser(&current, executor, ipAddress, current.Role); err != nil {
+		logger.Warn(logSender, "", "unable to remove the old passphrases for user %q: %v", user.Username, err)
+		return err
+	}
+	logger.Info(logSender, "", "key rotation completed for user %q", user.Username)
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/nats-io/nats.go"
+	"github.com/rs/xid"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/kafkaclient"
+	"github.com/drakkan/sftpgo/v2/internal/kms"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+const (
+	messagePublishTimeout = 30 * time.Second
+	messageReconnectWait  = time.Second
+	messageMaxReconnects  = 3
+	mqttQoSAtLeastOnce    = 1
+)
+
+// getMessageBrokerAddress returns the address to dial, the host name
+// and whether TLS is required for the specified broker URL
+func getMessageBrokerAddress(endpoint, defaultPort, defaultTLSPort string) (string, string, bool, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", "", false, fmt.Errorf("invalid broker URL %q: %w", endpoint, err)
+	}
+	if u.Hostname() == "" {
+		return "", "", false, fmt.Errorf("invalid broker URL %q: host is required", endpoint)
+	}
+	useTLS := u.Scheme == "tls" || u.Scheme == "mqtts"
+	port := u.Port()
+	if port == "" {
+		port = defaultPort
+		if useTLS {
+			port = defaultTLSPort
+		}
+	}
+	return net.JoinHostPort(u.Hostname(), port), u.Hostname(), useTLS, nil
+}
+
+func getMessageBrokerTLSConfig(host string) *tls.Config {
+	return &tls.Config{
+		ServerName: host,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+func publishNATSMessage(c *dataprovider.EventActionMessageConfig, subject string, payload []byte) error {
+	if strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("invalid NATS subject %q", subject)
+	}
+	_, host, useTLS, err := getMessageBrokerAddress(c.Endpoint, "4222", "4222")
+	if err != nil {
+		return err
+	}
+	opts := []nats.Option{
+		nats.Name("SFTPGo"),
+		nats.Timeout(messagePublishTimeout),
+		nats.MaxReconnects(messageMaxReconnects),
+		nats.ReconnectWait(messageReconnectWait),
+		nats.NoCallbacksAfterClientClose(),
+	}
+	if useTLS {
+		opts = append(opts, nats.Secure(getMessageBrokerTLSConfig(host)))
+	}
+	if c.Username != "" {
+		opts = append(opts, nats.UserInfo(c.Username, c.Password.GetPayload()))
+	}
+	conn, err := nats.Connect(c.Endpoint, opts...)
+	if err != nil {
+		return fmt.Errorf("unable to connect to NATS server %q: %w", c.Endpoint, err)
+	}
+	defer conn.Close()
+
+	if err := conn.Publish(subject, payload); err != nil {
+		return fmt.Errorf("unable to publish NATS message: %w", err)
+	}
+	// the flush round trip confirms that the server processed the message
+	if err := conn.FlushTimeout(messagePublishTimeout); err != nil {
+		return fmt.Errorf("unable to publish NATS message: %w", err)
+	}
+	return nil
+}
+
+func publishMQTTMessage(c *dataprovider.EventActionMessageConfig, topic string, payload []byte) error {
+	if strings.ContainsAny(topic, "+#") || len(topic) > 65535 {
+		return fmt.Errorf("invalid MQTT topic %q", topic)
+	}
+	address, host, useTLS, err := getMessageBrokerAddress(c.Endpoint, "1883", "8883")
+	if err != nil {
+		return err
+	}
+	broker := "mqtt://" + address
+	if useTLS {
+		broker = "mqtts://" + address
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(broker).
+		SetClientID(fmt.Sprintf("sftpgo_%s", xid.New().String())).
+		SetCleanSession(true).
+		SetConnectTimeout(messagePublishTimeout).
+		SetWriteTimeout(messagePublishTimeout).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(messageReconnectWait).
+		SetTLSConfig(getMessageBrokerTLSConfig(host))
+	if c.Username != "" {
+		opts.SetUsername(c.Username)
+		opts.SetPassword(c.Password.GetPayload())
+	}
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	if !token.WaitTimeout(messagePublishTimeout) {
+		return fmt.Errorf("unable to connect to MQTT broker %q: timeout", address)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("unable to connect to MQTT broker %q: %w", address, err)
+	}
+	defer client.Disconnect(250)
+
+	// QoS 1, the broker acknowledges the message
+	token = client.Publish(topic, mqttQoSAtLeastOnce, false, payload)
+	if !token.WaitTimeout(messagePublishTimeout) {
+		return errors.New("unable to publish MQTT message: timeout")
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("unable to publish MQTT message: %w", err)
+	}
+	return nil
+}
+
+func executeMessagePublishRuleAction(c dataprovider.EventActionMessageConfig, params *EventParams) error {
+	if c.Password == nil {
+		c.Password = kms.NewEmptySecret()
+	}
+	if err := c.TryDecryptPassword(); err != nil {
+		return err
+	}
+	addObjectData := false
+	if params.Object != nil {
+		if strings.Contains(c.Message, objDataPlaceholder) || strings.Contains(c.Message, objDataPlaceholderString) {
+			addObjectData = true
+		}
+	}
+	replacements := params.getStringReplacements(addObjectData, false)
+	replacer := strings.NewReplacer(replacements...)
+	topic := replaceWithReplacer(c.Topic, replacer)
+	if topic == "" {
+		return errors.New("the topic cannot be empty")
+	}
+	payload := []byte(replaceWithReplacer(c.Message, replacer))
+	var key []byte
+	if c.Key != "" {
+		key = []byte(replaceWithReplacer(c.Key, replacer))
+	}
+
+	startTime := time.Now()
+	var err error
+	switch c.Protocol {
+	case dataprovider.MessagingProtocolNATS:
+		err = publishNATSMessage(&c, topic, payload)
+	case dataprovider.MessagingProtocolMQTT:
+		err = publishMQTTMessage(&c, topic, payload)
+	case dataprovider.MessagingProtocolKafka:
+		err = kafkaclient.Publish(&kafkaclient.Config{
+			Endpoint:      c.Endpoint,
+			Username:      c.Username,
+			Password:      c.Password.GetPayload(),
+			SASLMechanism: c.SASLMechanism,
+			Acks:          c.Acks,
+			Timeout:       messagePublishTimeout,
+		}, topic, key, payload)
+	default:
+		err = fmt.Errorf("unsupported messaging protocol: %d", c.Protocol)
+	}
+	eventManagerLog(logger.LevelDebug, "message publish to topic %q, protocol: %d, elapsed: %s, error: %v",
+		topic, c.Protocol, time.Since(startTime), err)
+	return err
+}
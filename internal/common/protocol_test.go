@@ -3516,7 +3516,7 @@ func TestDelayedQuotaUpdater(t *testing.T) {
 	err = dataprovider.AddFolder(&folder, "", "", "")
 	assert.NoError(t, err)
 
-	err = dataprovider.UpdateVirtualFolderQuota(&folder, 10, 6000, false)
+	err = dataprovider.UpdateVirtualFolderQuota(&vfs.VirtualFolder{BaseVirtualFolder: folder}, 10, 6000, false)
 	assert.NoError(t, err)
 	files, size, err = dataprovider.GetUsedVirtualFolderQuota(folder.Name)
 	assert.NoError(t, err)
@@ -3528,7 +3528,7 @@ func TestDelayedQuotaUpdater(t *testing.T) {
 	assert.Equal(t, 0, folderGet.UsedQuotaFiles)
 	assert.Equal(t, int64(0), folderGet.UsedQuotaSize)
 
-	err = dataprovider.UpdateVirtualFolderQuota(&folder, 10, 6000, true)
+	err = dataprovider.UpdateVirtualFolderQuota(&vfs.VirtualFolder{BaseVirtualFolder: folder}, 10, 6000, true)
 	assert.NoError(t, err)
 	files, size, err = dataprovider.GetUsedVirtualFolderQuota(folder.Name)
 	assert.NoError(t, err)
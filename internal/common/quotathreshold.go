@@ -0,0 +1,183 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"sync"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	quotaThresholdEventName = "Quota threshold"
+	quotaObjectUser         = "user"
+	quotaObjectFolder       = "folder"
+)
+
+// quotaThresholdLevels tracks, for each rule, the highest quota threshold
+// notified for users and folders. The state is kept in memory only
+type quotaThresholdLevels struct {
+	sync.Mutex
+	levels map[string]map[string]int
+}
+
+func newQuotaThresholdLevels() *quotaThresholdLevels {
+	return &quotaThresholdLevels{
+		levels: make(map[string]map[string]int),
+	}
+}
+
+func (l *quotaThresholdLevels) removeRule(ruleName string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.levels, ruleName)
+}
+
+// update returns the threshold to notify for the specified usage percentage,
+// 0 means nothing to notify. A notified threshold can fire again only after
+// the usage drops below the threshold minus the hysteresis
+func (l *quotaThresholdLevels) update(ruleName, objectKey string, thresholds []int, hysteresis, usage int) int {
+	l.Lock()
+	defer l.Unlock()
+
+	level := l.levels[ruleName][objectKey]
+	crossed := 0
+	for _, t := range thresholds {
+		if usage >= t {
+			crossed = t
+		}
+	}
+	if crossed > level {
+		if _, ok := l.levels[ruleName]; !ok {
+			l.levels[ruleName] = make(map[string]int)
+		}
+		l.levels[ruleName][objectKey] = crossed
+		return crossed
+	}
+	if level > 0 && usage < level-hysteresis {
+		newLevel := 0
+		for _, t := range thresholds {
+			if t < level && usage >= t-hysteresis {
+				newLevel = t
+			}
+		}
+		if newLevel > 0 {
+			l.levels[ruleName][objectKey] = newLevel
+		} else {
+			delete(l.levels[ruleName], objectKey)
+		}
+	}
+	return 0
+}
+
+// getQuotaUsagePercentage returns the highest usage percentage between
+// the size and the number of files
+func getQuotaUsagePercentage(usedFiles, quotaFiles int, usedSize, quotaSize int64) int {
+	usage := 0
+	if quotaSize > 0 {
+		usage = int(usedSize * 100 / quotaSize)
+	}
+	if quotaFiles > 0 {
+		if p := usedFiles * 100 / quotaFiles; p > usage {
+			usage = p
+		}
+	}
+	return usage
+}
+
+func (*eventRulesContainer) checkQuotaEventMatch(conditions *dataprovider.EventConditions, objectType, objectName string) bool {
+	if len(conditions.Options.ProviderObjects) > 0 && !util.Contains(conditions.Options.ProviderObjects, objectType) {
+		return false
+	}
+	return checkEventConditionPatterns(objectName, conditions.Options.Names)
+}
+
+// handleQuotaUpdate checks the quota threshold rules after a quota update
+// for a user or folder with quota restrictions
+func (r *eventRulesContainer) handleQuotaUpdate(objectType, objectName string, quotaFiles int, quotaSize int64) {
+	r.RLock()
+
+	var rules []dataprovider.EventRule
+	for _, rule := range r.QuotaEvents {
+		if r.checkQuotaEventMatch(&rule.Conditions, objectType, objectName) {
+			rules = append(rules, rule)
+		}
+	}
+
+	r.RUnlock()
+
+	if len(rules) > 0 {
+		go r.checkQuotaThresholds(rules, objectType, objectName, quotaFiles, quotaSize)
+	}
+}
+
+func (r *eventRulesContainer) checkQuotaThresholds(rules []dataprovider.EventRule, objectType, objectName string,
+	quotaFiles int, quotaSize int64,
+) {
+	var usedFiles int
+	var usedSize int64
+	var err error
+	if objectType == quotaObjectFolder {
+		usedFiles, usedSize, err = dataprovider.GetUsedVirtualFolderQuota(objectName)
+	} else {
+		usedFiles, usedSize, _, _, err = dataprovider.GetUsedQuota(objectName)
+	}
+	if err != nil {
+		eventManagerLog(logger.LevelError, "unable to get used quota for %s %q: %v", objectType, objectName, err)
+		return
+	}
+	usage := getQuotaUsagePercentage(usedFiles, quotaFiles, usedSize, quotaSize)
+	objectKey := objectType + "_" + objectName
+
+	for _, rule := range rules {
+		threshold := r.quotaLevels.update(rule.Name, objectKey, rule.Conditions.QuotaThresholds,
+			rule.Conditions.QuotaHysteresis, usage)
+		if threshold == 0 {
+			continue
+		}
+		if err := rule.CheckActionsConsistency(objectType); err != nil {
+			eventManagerLog(logger.LevelWarn, "rule %q skipped: %v, event %q object type %q",
+				rule.Name, err, quotaThresholdEventName, objectType)
+			continue
+		}
+		eventManagerLog(logger.LevelDebug, "quota threshold %d%% crossed for %s %q, usage: %d%%, rule %q",
+			threshold, objectType, objectName, usage, rule.Name)
+		params := EventParams{
+			Name:           objectName,
+			ObjectName:     objectName,
+			ObjectType:     objectType,
+			Event:          quotaThresholdEventName,
+			Status:         1,
+			QuotaThreshold: threshold,
+			QuotaUsage:     usage,
+			Timestamp:      time.Now().UnixNano(),
+			sender:         objectName,
+		}
+		if objectType == quotaObjectUser {
+			user, err := dataprovider.UserExists(objectName, "")
+			if err == nil {
+				params.Email = user.Email
+				params.Role = user.Role
+				params.Attributes = user.Attributes
+				params.Object = &user
+			}
+		}
+		executeAsyncRulesActions([]dataprovider.EventRule{rule}, params)
+	}
+}
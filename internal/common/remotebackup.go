@@ -0,0 +1,351 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/sio"
+	"github.com/rs/xid"
+	"golang.org/x/crypto/hkdf"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	remoteBackupDir        = "/backups"
+	remoteBackupPrefix     = "backup_"
+	remoteBackupExt        = ".json"
+	remoteBackupEncExt     = ".json.enc"
+	remoteBackupTimeFormat = "20060102T150405Z"
+	remoteBackupNonceSize  = 32
+)
+
+// RemoteBackup defines a backup uploaded inside a virtual folder
+type RemoteBackup struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// Last modification as unix timestamp in milliseconds
+	ModTime   int64 `json:"last_modified"`
+	Encrypted bool  `json:"encrypted"`
+}
+
+func isRemoteBackupName(name string) bool {
+	if !strings.HasPrefix(name, remoteBackupPrefix) {
+		return false
+	}
+	return strings.HasSuffix(name, remoteBackupExt) || strings.HasSuffix(name, remoteBackupEncExt)
+}
+
+func getRemoteBackupSIOConfig(passphrase string, nonce []byte) (sio.Config, error) {
+	var key [32]byte
+	kdf := hkdf.New(sha256.New, []byte(passphrase), nonce, nil)
+	if _, err := io.ReadFull(kdf, key[:]); err != nil {
+		return sio.Config{}, err
+	}
+	return sio.Config{
+		MinVersion: sio.Version20,
+		MaxVersion: sio.Version20,
+		Key:        key[:],
+	}, nil
+}
+
+// remoteBackupStorage allows to access the backups uploaded inside a virtual folder
+type remoteBackupStorage struct {
+	config dataprovider.EventActionBackupConfig
+	conn   *BaseConnection
+}
+
+func newRemoteBackupStorage(config dataprovider.EventActionBackupConfig) (*remoteBackupStorage, error) {
+	if err := config.TryDecryptPassphrase(); err != nil {
+		return nil, err
+	}
+	folder, err := dataprovider.GetFolderByName(config.Folder)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get backup folder %q: %w", config.Folder, err)
+	}
+	connectionID := fmt.Sprintf("%s_%s", protocolEventAction, xid.New().String())
+	user := getFolderTargetUser(folder, remoteBackupDir)
+	if err := user.CheckFsRoot(connectionID); err != nil {
+		user.CloseFs() //nolint:errcheck
+		return nil, fmt.Errorf("unable to check root fs for backup folder %q: %w", folder.Name, err)
+	}
+	return &remoteBackupStorage{
+		config: config,
+		conn:   NewBaseConnection(connectionID, protocolEventAction, "", "", user),
+	}, nil
+}
+
+func (s *remoteBackupStorage) close() {
+	s.conn.User.CloseFs() //nolint:errcheck
+}
+
+func (s *remoteBackupStorage) getVirtualPath(name string) string {
+	return path.Join(remoteBackupDir, s.config.Path, name)
+}
+
+func (s *remoteBackupStorage) isEncrypted() bool {
+	return s.config.Passphrase != nil && s.config.Passphrase.GetPayload() != ""
+}
+
+func (s *remoteBackupStorage) list() ([]RemoteBackup, error) {
+	files, err := s.conn.ListDir(path.Join(remoteBackupDir, s.config.Path))
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var backups []RemoteBackup
+	for _, info := range files {
+		if !info.Mode().IsRegular() || !isRemoteBackupName(info.Name()) {
+			continue
+		}
+		backups = append(backups, RemoteBackup{
+			Name:      info.Name(),
+			Size:      info.Size(),
+			ModTime:   util.GetTimeAsMsSinceEpoch(info.ModTime()),
+			Encrypted: strings.HasSuffix(info.Name(), remoteBackupEncExt),
+		})
+	}
+	// the names contain the backup time, the most recent backups are returned first
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Name > backups[j].Name
+	})
+	return backups, nil
+}
+
+func (s *remoteBackupStorage) upload(localPath string) (string, error) {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	name := remoteBackupPrefix + time.Now().UTC().Format(remoteBackupTimeFormat)
+	if s.isEncrypted() {
+		name += remoteBackupEncExt
+	} else {
+		name += remoteBackupExt
+	}
+	virtualPath := s.getVirtualPath(name)
+	if err := s.conn.CheckParentDirs(path.Dir(virtualPath)); err != nil {
+		return name, fmt.Errorf("unable to create parent directories for %q: %w", virtualPath, err)
+	}
+	writer, numFiles, truncatedSize, cancelFn, err := getFileWriter(s.conn, virtualPath, -1)
+	if err != nil {
+		return name, fmt.Errorf("unable to create %q: %w", virtualPath, err)
+	}
+	defer cancelFn()
+
+	cw := &countingWriter{w: writer}
+	if s.isEncrypted() {
+		err = s.encrypt(cw, src)
+	} else {
+		_, err = io.Copy(cw, src)
+	}
+	if errClose := writer.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		fs, fsPath, errFs := s.conn.GetFsAndResolvedPath(virtualPath)
+		if errFs == nil {
+			errRemove := fs.Remove(fsPath, false)
+			eventManagerLog(logger.LevelDebug, "removing partial backup %q, result: %v", virtualPath, errRemove)
+		}
+		return name, fmt.Errorf("unable to upload backup %q: %w", virtualPath, err)
+	}
+	updateUserQuotaAfterFileWrite(s.conn, virtualPath, numFiles, cw.n-truncatedSize)
+	return name, nil
+}
+
+func (s *remoteBackupStorage) encrypt(w io.Writer, r io.Reader) error {
+	nonce := make([]byte, remoteBackupNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	config, err := getRemoteBackupSIOConfig(s.config.Passphrase.GetPayload(), nonce)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(nonce); err != nil {
+		return err
+	}
+	_, err = sio.Encrypt(w, r, config)
+	return err
+}
+
+func (s *remoteBackupStorage) download(name string, maxSize int64) ([]byte, error) {
+	if !isRemoteBackupName(name) || path.Base(name) != name {
+		return nil, util.NewValidationError(fmt.Sprintf("invalid backup name %q", name))
+	}
+	encrypted := strings.HasSuffix(name, remoteBackupEncExt)
+	if encrypted && !s.isEncrypted() {
+		return nil, util.NewValidationError(fmt.Sprintf("backup %q is encrypted but no passphrase is configured", name))
+	}
+	reader, cancelFn, err := getFileReader(s.conn, s.getVirtualPath(name))
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil, util.NewRecordNotFoundError(fmt.Sprintf("backup %q not found", name))
+		}
+		return nil, err
+	}
+	defer cancelFn()
+	defer reader.Close()
+
+	var src io.Reader = reader
+	var buf bytes.Buffer
+	dst := &limitedWriter{w: &buf, limit: maxSize}
+	if encrypted {
+		nonce := make([]byte, remoteBackupNonceSize)
+		if _, err := io.ReadFull(reader, nonce); err != nil {
+			return nil, fmt.Errorf("unable to read backup %q: %w", name, err)
+		}
+		config, err := getRemoteBackupSIOConfig(s.config.Passphrase.GetPayload(), nonce)
+		if err != nil {
+			return nil, err
+		}
+		_, err = sio.Decrypt(dst, src, config)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decrypt backup %q: %w", name, err)
+		}
+		return buf.Bytes(), nil
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		return nil, fmt.Errorf("unable to read backup %q: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *remoteBackupStorage) applyRetention() error {
+	if s.config.Retention <= 0 {
+		return nil
+	}
+	backups, err := s.list()
+	if err != nil {
+		return err
+	}
+	if len(backups) <= s.config.Retention {
+		return nil
+	}
+	for _, backup := range backups[s.config.Retention:] {
+		virtualPath := s.getVirtualPath(backup.Name)
+		fs, fsPath, err := s.conn.GetFsAndResolvedPath(virtualPath)
+		if err != nil {
+			return err
+		}
+		info, err := fs.Lstat(fsPath)
+		if err != nil {
+			return s.conn.GetFsError(fs, err)
+		}
+		if err := s.conn.RemoveFile(fs, fsPath, virtualPath, info); err != nil {
+			return fmt.Errorf("unable to remove backup %q: %w", virtualPath, err)
+		}
+		eventManagerLog(logger.LevelDebug, "backup %q removed, retention: %d", virtualPath, s.config.Retention)
+	}
+	return nil
+}
+
+// limitedWriter returns an error if more than limit bytes are written
+type limitedWriter struct {
+	w     io.Writer
+	limit int64
+	n     int64
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.n+int64(len(p)) > w.limit {
+		return 0, fmt.Errorf("backup too large, max allowed size: %s", util.ByteCountIEC(w.limit))
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func executeBackupRuleAction(config dataprovider.EventActionBackupConfig, params *EventParams) error {
+	backupPath, err := dataprovider.ExecuteBackup()
+	if err != nil {
+		return err
+	}
+	params.setBackupParams(backupPath)
+	if config.Folder == "" {
+		return nil
+	}
+	storage, err := newRemoteBackupStorage(config)
+	if err != nil {
+		return err
+	}
+	defer storage.close()
+
+	startTime := time.Now()
+	name, err := storage.upload(backupPath)
+	eventManagerLog(logger.LevelInfo, "backup %q uploaded to folder %q, elapsed: %s, error: %v",
+		name, config.Folder, time.Since(startTime), err)
+	if err != nil {
+		return err
+	}
+	if err := storage.applyRetention(); err != nil {
+		return fmt.Errorf("unable to apply backup retention: %w", err)
+	}
+	return nil
+}
+
+func getRemoteBackupStorage(actionName string) (*remoteBackupStorage, error) {
+	action, err := dataprovider.EventActionExists(actionName)
+	if err != nil {
+		return nil, err
+	}
+	if action.Type != dataprovider.ActionTypeBackup || action.Options.BackupConfig.Folder == "" {
+		return nil, util.NewValidationError(fmt.Sprintf("action %q does not upload backups to a virtual folder", actionName))
+	}
+	return newRemoteBackupStorage(action.Options.BackupConfig)
+}
+
+// GetRemoteBackups returns the backups uploaded by the specified backup action,
+// the most recent backups are returned first
+func GetRemoteBackups(actionName string) ([]RemoteBackup, error) {
+	storage, err := getRemoteBackupStorage(actionName)
+	if err != nil {
+		return nil, err
+	}
+	defer storage.close()
+
+	return storage.list()
+}
+
+// GetRemoteBackupContent downloads, and decrypts if needed, the specified backup
+// uploaded by the given backup action. An error is returned if the backup is
+// larger than maxSize
+func GetRemoteBackupContent(actionName, name string, maxSize int64) ([]byte, error) {
+	storage, err := getRemoteBackupStorage(actionName)
+	if err != nil {
+		return nil, err
+	}
+	defer storage.close()
+
+	return storage.download(name, maxSize)
+}
@@ -0,0 +1,441 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// Search types
+const (
+	SearchTypeFile = "file"
+	SearchTypeDir  = "dir"
+)
+
+// Search limits
+const (
+	// SearchDefaultLimit is the default number of results for each search
+	SearchDefaultLimit = 100
+	// SearchMaxLimit is the maximum number of results for each search
+	SearchMaxLimit          = 1000
+	searchDefaultMaxEntries = 100000
+	searchBinaryCheckSize   = 8192
+)
+
+var (
+	searchConfig   SearchConfig
+	searchIndexMgr *searchIndexManager
+	// ErrContentSearchUnavailable defines the error returned if a content filter is
+	// requested but it cannot be used
+	ErrContentSearchUnavailable = errors.New("content search is disabled")
+)
+
+// SearchConfig defines the configuration for the search of files and directories
+// available to WebClient, REST API and WebDAV users
+type SearchConfig struct {
+	// Maximum number of files and directories to visit for each search.
+	// 0 means the default limit
+	MaxEntries int `json:"max_entries" mapstructure:"max_entries"`
+	// Maximum size, in KB, for the text files whose contents can be searched.
+	// 0 means content search disabled
+	ContentMaxFileSize int64 `json:"content_max_file_size" mapstructure:"content_max_file_size"`
+	// Validity, in minutes, of the search index. If greater than 0, the file and directory
+	// listings of each user are indexed and reused for the following searches. Expired
+	// indexes are rebuilt in background while the previous one is used
+	IndexTTL int `json:"index_ttl" mapstructure:"index_ttl"`
+}
+
+// Initialize validates the search configuration and sets it as the active one
+func (c *SearchConfig) Initialize() error {
+	if c.MaxEntries < 0 || c.ContentMaxFileSize < 0 || c.IndexTTL < 0 {
+		return fmt.Errorf("search: invalid configuration, negative values are not allowed")
+	}
+	if c.MaxEntries == 0 {
+		c.MaxEntries = searchDefaultMaxEntries
+	}
+	searchConfig = *c
+	searchIndexMgr = nil
+	if c.IndexTTL > 0 {
+		searchIndexMgr = newSearchIndexManager(time.Duration(c.IndexTTL)*time.Minute, c.MaxEntries)
+	}
+	return nil
+}
+
+func (c *SearchConfig) getMaxEntries() int {
+	if c.MaxEntries <= 0 {
+		return searchDefaultMaxEntries
+	}
+	return c.MaxEntries
+}
+
+// IsContentSearchEnabled returns true if searching the contents of the text files is enabled
+func IsContentSearchEnabled() bool {
+	return searchConfig.ContentMaxFileSize > 0
+}
+
+// CleanupSearchIndexes removes the unused search indexes
+func CleanupSearchIndexes() {
+	if searchIndexMgr != nil {
+		searchIndexMgr.cleanup()
+	}
+}
+
+// SearchEntry defines a file or directory visited while searching
+type SearchEntry struct {
+	Path    string
+	Name    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+}
+
+// SearchFilters defines the filters for a search. Name and Content must be lower case
+type SearchFilters struct {
+	BaseDir string
+	// Shell pattern if it contains any wildcard, otherwise any name containing it matches
+	Name           string
+	MinSize        int64
+	MaxSize        int64
+	ModifiedAfter  time.Time
+	ModifiedBefore time.Time
+	Type           string
+	Content        string
+	Limit          int
+}
+
+// Validate returns an error if the filters are not valid
+func (f *SearchFilters) Validate() error {
+	if f.Name != "" {
+		if _, err := path.Match(f.Name, ""); err != nil {
+			return util.NewValidationError(fmt.Sprintf("invalid name pattern %q: %v", f.Name, err))
+		}
+	}
+	if f.Type != "" && f.Type != SearchTypeFile && f.Type != SearchTypeDir {
+		return util.NewValidationError(fmt.Sprintf("invalid type %q", f.Type))
+	}
+	if f.Content != "" {
+		if !IsContentSearchEnabled() {
+			return util.NewValidationError(ErrContentSearchUnavailable.Error())
+		}
+		f.Type = SearchTypeFile
+	}
+	if f.Name == "" && f.Content == "" && f.MinSize == 0 && f.MaxSize == 0 && f.ModifiedAfter.IsZero() &&
+		f.ModifiedBefore.IsZero() {
+		return util.NewValidationError("at least a search filter is required")
+	}
+	return nil
+}
+
+// matchName returns true if the specified name matches the name filter.
+// The filter is a shell pattern if it contains any wildcard, otherwise it
+// matches any name containing it. The match is case insensitive
+func (f *SearchFilters) matchName(name string) bool {
+	if f.Name == "" {
+		return true
+	}
+	name = strings.ToLower(name)
+	if strings.ContainsAny(f.Name, "*?[") {
+		matched, err := path.Match(f.Name, name)
+		return err == nil && matched
+	}
+	return strings.Contains(name, f.Name)
+}
+
+func (f *SearchFilters) matchMetadata(entry *SearchEntry) bool {
+	switch f.Type {
+	case SearchTypeFile:
+		if entry.IsDir {
+			return false
+		}
+	case SearchTypeDir:
+		if !entry.IsDir {
+			return false
+		}
+	}
+	if entry.Path == f.BaseDir || !f.matchName(entry.Name) {
+		return false
+	}
+	if f.MinSize > 0 || f.MaxSize > 0 {
+		if entry.IsDir {
+			return false
+		}
+		if entry.Size < f.MinSize || (f.MaxSize > 0 && entry.Size > f.MaxSize) {
+			return false
+		}
+	}
+	if !f.ModifiedAfter.IsZero() && !entry.ModTime.After(f.ModifiedAfter) {
+		return false
+	}
+	if !f.ModifiedBefore.IsZero() && !entry.ModTime.Before(f.ModifiedBefore) {
+		return false
+	}
+	return true
+}
+
+// matchContent returns true if the specified text file contains the content filter.
+// Binary files and files larger than the configured limit are never matched
+func (f *SearchFilters) matchContent(conn *BaseConnection, entry *SearchEntry,
+	fileReader func(string) (io.ReadCloser, error),
+) bool {
+	if f.Content == "" {
+		return true
+	}
+	maxSize := searchConfig.ContentMaxFileSize * 1024
+	if entry.Size > maxSize {
+		return false
+	}
+	reader, err := fileReader(entry.Path)
+	if err != nil {
+		conn.Log(logger.LevelDebug, "unable to search the contents of file %q: %v", entry.Path, err)
+		return false
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize))
+	if err != nil {
+		conn.Log(logger.LevelDebug, "unable to read file %q: %v", entry.Path, err)
+		return false
+	}
+	if bytes.IndexByte(data[:min(len(data), searchBinaryCheckSize)], 0) != -1 {
+		return false
+	}
+	return bytes.Contains(bytes.ToLower(data), []byte(f.Content))
+}
+
+// isSearchEntryAllowed checks the current user permissions for an entry, the
+// index could be built before a permission change
+func isSearchEntryAllowed(conn *BaseConnection, entry *SearchEntry) bool {
+	if !conn.User.HasPerm(dataprovider.PermListItems, path.Dir(entry.Path)) {
+		return false
+	}
+	ok, _ := conn.User.IsFileAllowed(entry.Path)
+	return ok
+}
+
+// walkUserFiles returns the files and directories inside the specified directory,
+// virtual folders included. Directories that cannot be listed are skipped.
+// The returned boolean is true if the maximum number of entries was reached
+func walkUserFiles(conn *BaseConnection, baseDir string, maxEntries int) ([]SearchEntry, bool, error) {
+	contents, err := conn.ListDir(baseDir)
+	if err != nil {
+		return nil, false, err
+	}
+	var entries []SearchEntry
+	dirs := []string{baseDir}
+	for len(dirs) > 0 {
+		dir := dirs[0]
+		dirs = dirs[1:]
+		if dir != baseDir {
+			contents, err = conn.ListDir(dir)
+			if err != nil {
+				conn.Log(logger.LevelDebug, "search, unable to list directory %q: %v", dir, err)
+				continue
+			}
+		}
+		for _, info := range contents {
+			if len(entries) >= maxEntries {
+				return entries, true, nil
+			}
+			entry := SearchEntry{
+				Path:    path.Join(dir, info.Name()),
+				Name:    info.Name(),
+				IsDir:   info.IsDir(),
+				ModTime: info.ModTime(),
+			}
+			if entry.IsDir {
+				dirs = append(dirs, entry.Path)
+			} else {
+				entry.Size = info.Size()
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, false, nil
+}
+
+// SearchFiles returns the files and directories matching the specified filters.
+// The fileReader function is used to read the files if a content filter is set,
+// it is the responsibility of the caller to check the download permissions and quota.
+// The returned boolean is true if the limit for the results or for the visited
+// entries was reached
+func SearchFiles(conn *BaseConnection, filters *SearchFilters, fileReader func(string) (io.ReadCloser, error),
+) ([]SearchEntry, bool, error) {
+	if filters.Content != "" && fileReader == nil {
+		return nil, false, util.NewValidationError(ErrContentSearchUnavailable.Error())
+	}
+	var entries []SearchEntry
+	var truncated bool
+	var err error
+	if searchIndexMgr != nil {
+		entries, truncated, err = searchIndexMgr.getEntries(conn, filters.BaseDir)
+	} else {
+		entries, truncated, err = walkUserFiles(conn, filters.BaseDir, searchConfig.getMaxEntries())
+	}
+	if err != nil {
+		return nil, truncated, err
+	}
+	results := []SearchEntry{}
+	for idx := range entries {
+		entry := &entries[idx]
+		if !filters.matchMetadata(entry) || !isSearchEntryAllowed(conn, entry) {
+			continue
+		}
+		if !filters.matchContent(conn, entry, fileReader) {
+			continue
+		}
+		if len(results) >= filters.Limit {
+			truncated = true
+			break
+		}
+		results = append(results, *entry)
+	}
+	return results, truncated, nil
+}
+
+type searchIndex struct {
+	entries    []SearchEntry
+	truncated  bool
+	createdAt  time.Time
+	lastUsed   time.Time
+	refreshing bool
+}
+
+type searchIndexManager struct {
+	ttl        time.Duration
+	maxEntries int
+	mu         sync.Mutex
+	indexes    map[string]*searchIndex
+}
+
+func newSearchIndexManager(ttl time.Duration, maxEntries int) *searchIndexManager {
+	return &searchIndexManager{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		indexes:    make(map[string]*searchIndex),
+	}
+}
+
+// getEntries returns the indexed entries inside the specified directory. The index
+// is built on first use, an expired index is rebuilt in background
+func (m *searchIndexManager) getEntries(conn *BaseConnection, baseDir string) ([]SearchEntry, bool, error) {
+	// listing the base directory checks that it exists and can be listed
+	if _, err := conn.ListDir(baseDir); err != nil {
+		return nil, false, err
+	}
+	username := conn.User.Username
+	m.mu.Lock()
+	idx, ok := m.indexes[username]
+	if ok {
+		idx.lastUsed = time.Now()
+		if time.Since(idx.createdAt) > m.ttl && !idx.refreshing {
+			idx.refreshing = true
+			go m.refresh(username)
+		}
+		entries, truncated := idx.entries, idx.truncated
+		m.mu.Unlock()
+		return filterSearchEntries(entries, baseDir), truncated, nil
+	}
+	m.mu.Unlock()
+
+	entries, truncated, err := walkUserFiles(conn, "/", m.maxEntries)
+	if err != nil {
+		return nil, false, err
+	}
+	m.set(username, entries, truncated)
+	return filterSearchEntries(entries, baseDir), truncated, nil
+}
+
+func (m *searchIndexManager) set(username string, entries []SearchEntry, truncated bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.indexes[username] = &searchIndex{
+		entries:   entries,
+		truncated: truncated,
+		createdAt: now,
+		lastUsed:  now,
+	}
+}
+
+func (m *searchIndexManager) remove(username string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.indexes, username)
+}
+
+func (m *searchIndexManager) refresh(username string) {
+	user, err := dataprovider.GetUserWithGroupSettings(username, "")
+	if err != nil {
+		logger.Debug(logSender, "", "unable to refresh the search index for user %q: %v", username, err)
+		m.remove(username)
+		return
+	}
+	conn := NewBaseConnection(xid.New().String(), ProtocolHTTP, "", "", user)
+	defer conn.CloseFS() //nolint:errcheck
+
+	conn.User.CheckFsRoot(conn.ID) //nolint:errcheck
+
+	startTime := time.Now()
+	entries, truncated, err := walkUserFiles(conn, "/", m.maxEntries)
+	if err != nil {
+		logger.Debug(logSender, "", "unable to refresh the search index for user %q: %v", username, err)
+		m.remove(username)
+		return
+	}
+	m.set(username, entries, truncated)
+	logger.Debug(logSender, "", "search index refreshed for user %q, entries: %d, truncated: %t, elapsed: %s",
+		username, len(entries), truncated, time.Since(startTime))
+}
+
+// cleanup removes the indexes not used for more than twice the configured validity
+func (m *searchIndexManager) cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for username, idx := range m.indexes {
+		if time.Since(idx.lastUsed) > 2*m.ttl && !idx.refreshing {
+			delete(m.indexes, username)
+		}
+	}
+}
+
+func filterSearchEntries(entries []SearchEntry, baseDir string) []SearchEntry {
+	if baseDir == "/" {
+		return entries
+	}
+	prefix := baseDir + "/"
+	var result []SearchEntry
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Path, prefix) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
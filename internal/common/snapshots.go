@@ -0,0 +1,463 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	snapshotIDFormat      = "20060102T150405.000Z"
+	snapshotDataDir       = "data"
+	snapshotInfoFile      = "snapshot.json"
+	snapshotTempSuffix    = ".tmp"
+	snapshotRestorePrefix = ".sftpgo-restore-"
+)
+
+var (
+	// ErrSnapshotInProgress is returned if another snapshot operation is in progress for the same user
+	ErrSnapshotInProgress = errors.New("another snapshot operation is in progress")
+	snapshotIDRegex       = regexp.MustCompile(`^\d{8}T\d{6}\.\d{3}Z$`)
+	activeSnapshots       = snapshotsLocker{
+		users: make(map[string]bool),
+	}
+)
+
+// SnapshotsConfig defines the configuration for the local filesystem snapshots
+type SnapshotsConfig struct {
+	// Absolute path to the directory where the snapshots are stored. It must be on
+	// the same filesystem as the users home directories since the snapshots are
+	// created using hard links. Empty means disabled
+	Path string `json:"path" mapstructure:"path"`
+	// Number of snapshots to keep for each user, the oldest ones are removed
+	// after creating a new snapshot. 0 means no limit
+	Retention int `json:"retention" mapstructure:"retention"`
+}
+
+func (c *SnapshotsConfig) validate() error {
+	if c.Path == "" {
+		return nil
+	}
+	if !filepath.IsAbs(c.Path) {
+		return fmt.Errorf("invalid snapshots path %q, it must be an absolute path", c.Path)
+	}
+	if c.Retention < 0 {
+		return fmt.Errorf("invalid snapshots retention %d", c.Retention)
+	}
+	c.Path = filepath.Clean(c.Path)
+	return nil
+}
+
+// Snapshot defines a point-in-time snapshot of a user home directory
+type Snapshot struct {
+	// Unique identifier, it is based on the creation time
+	ID string `json:"id"`
+	// creation time as unix timestamp in milliseconds
+	CreatedAt int64 `json:"created_at"`
+	// number of files included in the snapshot
+	Files int `json:"files"`
+	// size of the included files as bytes
+	Size int64 `json:"size"`
+}
+
+type snapshotsLocker struct {
+	sync.Mutex
+	users map[string]bool
+}
+
+func (l *snapshotsLocker) add(username string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.users[username] {
+		return false
+	}
+	l.users[username] = true
+	return true
+}
+
+func (l *snapshotsLocker) remove(username string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.users, username)
+}
+
+func getUserSnapshotsDir(username string) string {
+	return filepath.Join(Config.Snapshots.Path, username)
+}
+
+func getSnapshotDir(username, id string) (string, error) {
+	if !snapshotIDRegex.MatchString(id) {
+		return "", util.NewRecordNotFoundError(fmt.Sprintf("snapshot %q does not exist", id))
+	}
+	snapshotDir := filepath.Join(getUserSnapshotsDir(username), id)
+	info, err := os.Stat(snapshotDir)
+	if err != nil || !info.IsDir() {
+		return "", util.NewRecordNotFoundError(fmt.Sprintf("snapshot %q does not exist", id))
+	}
+	return snapshotDir, nil
+}
+
+func checkSnapshotsEnabled() error {
+	if Config.Snapshots.Path == "" {
+		return util.NewMethodDisabledError("snapshots are disabled")
+	}
+	return nil
+}
+
+func getSnapshotUser(username string) (dataprovider.User, error) {
+	if err := checkSnapshotsEnabled(); err != nil {
+		return dataprovider.User{}, err
+	}
+	user, err := dataprovider.GetUserWithGroupSettings(username, "")
+	if err != nil {
+		return user, err
+	}
+	if user.FsConfig.Provider != sdk.LocalFilesystemProvider && user.FsConfig.Provider != sdk.CryptedFilesystemProvider {
+		return user, util.NewValidationError(fmt.Sprintf("snapshots are not supported for the filesystem of user %q",
+			user.Username))
+	}
+	return user, nil
+}
+
+// CreateSnapshot creates a point-in-time snapshot of the home directory for the
+// specified user. The files are hard linked, so they don't use additional disk space.
+// The oldest snapshots exceeding the configured retention are removed
+func CreateSnapshot(username string) (Snapshot, error) {
+	user, err := getSnapshotUser(username)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if !activeSnapshots.add(user.Username) {
+		return Snapshot{}, ErrSnapshotInProgress
+	}
+	defer activeSnapshots.remove(user.Username)
+
+	now := time.Now().UTC()
+	snapshot := Snapshot{
+		ID:        now.Format(snapshotIDFormat),
+		CreatedAt: util.GetTimeAsMsSinceEpoch(now),
+	}
+	snapshotDir := filepath.Join(getUserSnapshotsDir(user.Username), snapshot.ID)
+	if _, err := os.Stat(snapshotDir); err == nil {
+		return Snapshot{}, ErrSnapshotInProgress
+	}
+	// the snapshot is created inside a temporary directory and renamed when
+	// completed, this way incomplete snapshots are never listed
+	tempDir := snapshotDir + snapshotTempSuffix
+	if err := os.RemoveAll(tempDir); err != nil {
+		return Snapshot{}, err
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, snapshotDataDir), 0700); err != nil {
+		return Snapshot{}, err
+	}
+	snapshot.Files, snapshot.Size, err = linkSnapshotFiles(user.GetHomeDir(), filepath.Join(tempDir, snapshotDataDir))
+	if err != nil {
+		logger.Warn(logSender, "", "unable to create snapshot for user %q: %v", user.Username, err)
+		os.RemoveAll(tempDir)
+		return Snapshot{}, err
+	}
+	if err := writeSnapshotInfo(tempDir, snapshot); err != nil {
+		os.RemoveAll(tempDir)
+		return Snapshot{}, err
+	}
+	if err := os.Rename(tempDir, snapshotDir); err != nil {
+		os.RemoveAll(tempDir)
+		return Snapshot{}, err
+	}
+	logger.Info(logSender, "", "snapshot %q created for user %q, files: %d, size: %d", snapshot.ID, user.Username,
+		snapshot.Files, snapshot.Size)
+	pruneSnapshots(user.Username)
+	return snapshot, nil
+}
+
+// GetSnapshots returns the snapshots for the specified user, the most recent first
+func GetSnapshots(username string) ([]Snapshot, error) {
+	if err := checkSnapshotsEnabled(); err != nil {
+		return nil, err
+	}
+	snapshots := make([]Snapshot, 0)
+	entries, err := os.ReadDir(getUserSnapshotsDir(username))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return snapshots, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !snapshotIDRegex.MatchString(entry.Name()) {
+			continue
+		}
+		snapshots = append(snapshots, readSnapshotInfo(filepath.Join(getUserSnapshotsDir(username), entry.Name()),
+			entry.Name()))
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID > snapshots[j].ID
+	})
+	return snapshots, nil
+}
+
+// DeleteSnapshot removes the snapshot with the specified ID for the given user
+func DeleteSnapshot(username, id string) error {
+	if err := checkSnapshotsEnabled(); err != nil {
+		return err
+	}
+	snapshotDir, err := getSnapshotDir(username, id)
+	if err != nil {
+		return err
+	}
+	if !activeSnapshots.add(username) {
+		return ErrSnapshotInProgress
+	}
+	defer activeSnapshots.remove(username)
+
+	if err := os.RemoveAll(snapshotDir); err != nil {
+		logger.Warn(logSender, "", "unable to remove snapshot %q for user %q: %v", id, username, err)
+		return err
+	}
+	logger.Info(logSender, "", "snapshot %q removed for user %q", id, username)
+	return nil
+}
+
+// RestoreSnapshot restores the specified virtual path, "/" means the whole home
+// directory, from a snapshot. The existing files are overwritten, files created
+// after the snapshot are preserved. The number and the size of the restored
+// files are returned
+func RestoreSnapshot(username, id, virtualPath string) (int, int64, error) {
+	user, err := getSnapshotUser(username)
+	if err != nil {
+		return 0, 0, err
+	}
+	snapshotDir, err := getSnapshotDir(user.Username, id)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !activeSnapshots.add(user.Username) {
+		return 0, 0, ErrSnapshotInProgress
+	}
+	defer activeSnapshots.remove(user.Username)
+
+	relPath := strings.TrimPrefix(util.CleanPath(virtualPath), "/")
+	source := filepath.Join(snapshotDir, snapshotDataDir, filepath.FromSlash(relPath))
+	if _, err := os.Lstat(source); err != nil {
+		return 0, 0, util.NewRecordNotFoundError(fmt.Sprintf("path %q does not exist in snapshot %q",
+			util.CleanPath(virtualPath), id))
+	}
+	numFiles, size, err := restoreSnapshotFiles(source, filepath.Join(user.GetHomeDir(), filepath.FromSlash(relPath)))
+	if err != nil {
+		logger.Warn(logSender, "", "unable to restore path %q from snapshot %q for user %q: %v",
+			virtualPath, id, user.Username, err)
+		return numFiles, size, err
+	}
+	logger.Info(logSender, "", "path %q restored from snapshot %q for user %q, files: %d, size: %d",
+		util.CleanPath(virtualPath), id, user.Username, numFiles, size)
+	if dataprovider.GetQuotaTracking() > 0 {
+		if err := executeQuotaResetForUser(&user); err != nil {
+			logger.Warn(logSender, "", "unable to update quota for user %q after restoring a snapshot: %v",
+				user.Username, err)
+		}
+	}
+	return numFiles, size, nil
+}
+
+func pruneSnapshots(username string) {
+	if Config.Snapshots.Retention <= 0 {
+		return
+	}
+	snapshots, err := GetSnapshots(username)
+	if err != nil {
+		logger.Warn(logSender, "", "unable to get snapshots for user %q: %v", username, err)
+		return
+	}
+	for idx := Config.Snapshots.Retention; idx < len(snapshots); idx++ {
+		snapshotDir := filepath.Join(getUserSnapshotsDir(username), snapshots[idx].ID)
+		if err := os.RemoveAll(snapshotDir); err != nil {
+			logger.Warn(logSender, "", "unable to prune snapshot %q for user %q: %v", snapshots[idx].ID, username, err)
+			continue
+		}
+		logger.Debug(logSender, "", "snapshot %q pruned for user %q", snapshots[idx].ID, username)
+	}
+}
+
+func writeSnapshotInfo(snapshotDir string, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(snapshotDir, snapshotInfoFile), data, 0600)
+}
+
+func readSnapshotInfo(snapshotDir, id string) Snapshot {
+	var snapshot Snapshot
+	data, err := os.ReadFile(filepath.Join(snapshotDir, snapshotInfoFile))
+	if err == nil {
+		err = json.Unmarshal(data, &snapshot)
+	}
+	if err != nil || snapshot.ID != id {
+		snapshot = Snapshot{ID: id}
+		if createdAt, err := time.Parse(snapshotIDFormat, id); err == nil {
+			snapshot.CreatedAt = util.GetTimeAsMsSinceEpoch(createdAt)
+		}
+	}
+	return snapshot
+}
+
+// linkSnapshotFiles recreates the directory tree of source inside target and
+// hard links the regular files
+func linkSnapshotFiles(source, target string) (int, int64, error) {
+	var numFiles int
+	var size int64
+
+	err := filepath.WalkDir(source, func(walkedPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && walkedPath == Config.Snapshots.Path {
+			return filepath.SkipDir
+		}
+		relPath, err := filepath.Rel(source, walkedPath)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(target, relPath)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(dst, info.Mode().Perm()|0700)
+		case info.Mode().IsRegular():
+			if err := os.Link(walkedPath, dst); err != nil {
+				if errors.Is(err, syscall.EXDEV) {
+					return fmt.Errorf("the snapshots path must be on the same filesystem as the home dir: %w", err)
+				}
+				return err
+			}
+			numFiles++
+			size += info.Size()
+		case info.Mode()&os.ModeSymlink != 0:
+			linkTarget, err := os.Readlink(walkedPath)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(linkTarget, dst)
+		}
+		return nil
+	})
+	return numFiles, size, err
+}
+
+// restoreSnapshotFiles copies source to target. The files are copied instead of
+// linked so that modifying a restored file does not change the snapshot
+func restoreSnapshotFiles(source, target string) (int, int64, error) {
+	var numFiles int
+	var size int64
+
+	err := filepath.WalkDir(source, func(walkedPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(source, walkedPath)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(target, relPath)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(dst, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			if err := restoreSnapshotFile(walkedPath, dst, info); err != nil {
+				return err
+			}
+			numFiles++
+			size += info.Size()
+		case info.Mode()&os.ModeSymlink != 0:
+			linkTarget, err := os.Readlink(walkedPath)
+			if err != nil {
+				return err
+			}
+			if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			return os.Symlink(linkTarget, dst)
+		}
+		return nil
+	})
+	return numFiles, size, err
+}
+
+func restoreSnapshotFile(source, target string, info fs.FileInfo) error {
+	if targetInfo, err := os.Stat(target); err == nil && os.SameFile(info, targetInfo) {
+		// the file was not modified after the snapshot
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// the target could be a hard link to the source so we cannot truncate it,
+	// we write a temporary file and then we replace the target
+	dst, err := os.CreateTemp(filepath.Dir(target), snapshotRestorePrefix)
+	if err != nil {
+		return err
+	}
+	tempPath := dst.Name()
+	_, err = io.Copy(dst, src)
+	if err == nil {
+		err = dst.Chmod(info.Mode().Perm())
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chtimes(tempPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(tempPath, target)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+	}
+	return err
+}
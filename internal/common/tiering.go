@@ -0,0 +1,290 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+const (
+	tieringSourceDir = "/source"
+	tieringTargetDir = "/target"
+	// TieringStubSuffix is the suffix for the stub files left in the source
+	// folder for the files moved to the target folder
+	TieringStubSuffix = ".tiered"
+)
+
+var activeTierings = tieringLocker{
+	folders: make(map[string]bool),
+}
+
+// TieringStub defines the content of the stub files left in the source folder
+type TieringStub struct {
+	// Name of the virtual folder where the file was moved to
+	Folder string `json:"folder"`
+	// File path relative to the target folder root
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Move time as unix timestamp in milliseconds
+	MovedAt int64 `json:"moved_at"`
+}
+
+type tieringLocker struct {
+	sync.Mutex
+	folders map[string]bool
+}
+
+func (l *tieringLocker) add(folderName string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.folders[folderName] {
+		return false
+	}
+	l.folders[folderName] = true
+	return true
+}
+
+func (l *tieringLocker) remove(folderName string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.folders, folderName)
+}
+
+// tiering moves the files from the source to the target folder using
+// a system user with both folders mounted as virtual folders
+type tiering struct {
+	conn      *BaseConnection
+	policy    dataprovider.FolderTiering
+	threshold time.Time
+	files     int
+	size      int64
+}
+
+func (t *tiering) isCold(info os.FileInfo) bool {
+	lastUse := info.ModTime()
+	if t.policy.AgeMode == dataprovider.TieringAgeAccessed {
+		lastUse = vfs.GetAccessTime(info)
+	}
+	return lastUse.Before(t.threshold)
+}
+
+func (t *tiering) walk(virtualPath string) error {
+	contents, err := t.conn.ListDir(virtualPath)
+	if err != nil {
+		if t.conn.IsNotExistError(err) {
+			return nil
+		}
+		return fmt.Errorf("unable to list directory %q: %w", virtualPath, err)
+	}
+	for _, info := range contents {
+		itemPath := path.Join(virtualPath, info.Name())
+		if info.IsDir() {
+			if err := t.walk(itemPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() || strings.HasSuffix(info.Name(), TieringStubSuffix) {
+			continue
+		}
+		if !t.isCold(info) {
+			continue
+		}
+		if err := t.moveFile(itemPath, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tiering) moveFile(sourcePath string, info os.FileInfo) error {
+	relPath := strings.TrimPrefix(sourcePath, tieringSourceDir)
+	targetPath := path.Join(tieringTargetDir, relPath)
+	if err := t.conn.CheckParentDirs(path.Dir(targetPath)); err != nil {
+		return fmt.Errorf("unable to create parent directories for %q: %w", targetPath, err)
+	}
+	if err := t.copyFile(sourcePath, targetPath, info.Size()); err != nil {
+		return err
+	}
+	fs, fsPath, err := t.conn.GetFsAndResolvedPath(sourcePath)
+	if err != nil {
+		return err
+	}
+	if err := fs.Remove(fsPath, false); err != nil {
+		return fmt.Errorf("unable to remove %q after copying it to the target folder: %w", sourcePath, t.conn.GetFsError(fs, err))
+	}
+	updateUserQuotaAfterFileWrite(t.conn, sourcePath, -1, -info.Size())
+	if t.policy.LeaveStub {
+		if err := t.writeStub(sourcePath, relPath, info.Size()); err != nil {
+			eventManagerLog(logger.LevelWarn, "unable to write tiering stub for %q: %v", sourcePath, err)
+		}
+	}
+	t.files++
+	t.size += info.Size()
+	eventManagerLog(logger.LevelDebug, "file %q moved from folder %q to folder %q, size: %d",
+		relPath, t.policy.SourceFolder, t.policy.TargetFolder, info.Size())
+	return nil
+}
+
+func (t *tiering) copyFile(sourcePath, targetPath string, size int64) error {
+	reader, cancelReader, err := getFileReader(t.conn, sourcePath)
+	if err != nil {
+		return fmt.Errorf("unable to open %q: %w", sourcePath, err)
+	}
+	defer cancelReader()
+	defer reader.Close()
+
+	writer, numFiles, truncatedSize, cancelWriter, err := getFileWriter(t.conn, targetPath, size)
+	if err != nil {
+		return fmt.Errorf("unable to create %q: %w", targetPath, err)
+	}
+	defer cancelWriter()
+
+	n, err := io.Copy(writer, reader)
+	if errClose := writer.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil && n != size {
+		err = fmt.Errorf("size mismatch, expected: %d, copied: %d", size, n)
+	}
+	if err != nil {
+		fs, fsPath, errFs := t.conn.GetFsAndResolvedPath(targetPath)
+		if errFs == nil {
+			errRemove := fs.Remove(fsPath, false)
+			eventManagerLog(logger.LevelDebug, "removing partial file %q after copy error, result: %v", targetPath, errRemove)
+		}
+		return fmt.Errorf("unable to copy %q to %q: %w", sourcePath, targetPath, err)
+	}
+	updateUserQuotaAfterFileWrite(t.conn, targetPath, numFiles, n-truncatedSize)
+	return nil
+}
+
+func (t *tiering) writeStub(sourcePath, relPath string, size int64) error {
+	data, err := json.Marshal(TieringStub{
+		Folder:  t.policy.TargetFolder,
+		Path:    relPath,
+		Size:    size,
+		MovedAt: util.GetTimeAsMsSinceEpoch(time.Now()),
+	})
+	if err != nil {
+		return err
+	}
+	stubPath := sourcePath + TieringStubSuffix
+	writer, numFiles, truncatedSize, cancelFn, err := getFileWriter(t.conn, stubPath, int64(len(data)))
+	if err != nil {
+		return err
+	}
+	defer cancelFn()
+
+	n, err := io.Copy(writer, bytes.NewReader(data))
+	if errClose := writer.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	updateUserQuotaAfterFileWrite(t.conn, stubPath, numFiles, n-truncatedSize)
+	return nil
+}
+
+func getTieringUser(source, target vfs.BaseVirtualFolder) dataprovider.User {
+	return dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Status:   1,
+			Username: dataprovider.ActionExecutorSystem,
+			HomeDir:  dataprovider.GetBackupsPath(),
+			Permissions: map[string][]string{
+				"/": {dataprovider.PermAny},
+			},
+		},
+		VirtualFolders: []vfs.VirtualFolder{
+			{
+				BaseVirtualFolder: source,
+				VirtualPath:       tieringSourceDir,
+			},
+			{
+				BaseVirtualFolder: target,
+				VirtualPath:       tieringTargetDir,
+			},
+		},
+	}
+}
+
+func executeTieringPolicy(policy dataprovider.FolderTiering) error {
+	if !activeTierings.add(policy.SourceFolder) {
+		return fmt.Errorf("another tiering is in progress for folder %q", policy.SourceFolder)
+	}
+	defer activeTierings.remove(policy.SourceFolder)
+
+	source, err := dataprovider.GetFolderByName(policy.SourceFolder)
+	if err != nil {
+		return fmt.Errorf("unable to get source folder %q: %w", policy.SourceFolder, err)
+	}
+	target, err := dataprovider.GetFolderByName(policy.TargetFolder)
+	if err != nil {
+		return fmt.Errorf("unable to get target folder %q: %w", policy.TargetFolder, err)
+	}
+	user := getTieringUser(source, target)
+	connectionID := fmt.Sprintf("%s_%s", protocolEventAction, xid.New().String())
+	err = user.CheckFsRoot(connectionID)
+	defer user.CloseFs() //nolint:errcheck
+	if err != nil {
+		return fmt.Errorf("unable to check root fs for tiering from folder %q: %w", policy.SourceFolder, err)
+	}
+	t := &tiering{
+		conn:      NewBaseConnection(connectionID, protocolEventAction, "", "", user),
+		policy:    policy,
+		threshold: time.Now().Add(-time.Duration(policy.MinAge) * 24 * time.Hour),
+	}
+	startTime := time.Now()
+	err = t.walk(tieringSourceDir)
+	eventManagerLog(logger.LevelInfo, "tiering from folder %q to folder %q completed, moved files: %d, size: %d, "+
+		"elapsed: %s, error: %v", policy.SourceFolder, policy.TargetFolder, t.files, t.size, time.Since(startTime), err)
+	return err
+}
+
+func executeTieringRuleAction(config dataprovider.EventActionTieringConfig, params *EventParams) error {
+	var failures []string
+	for _, policy := range config.Policies {
+		if err := executeTieringPolicy(policy); err != nil {
+			eventManagerLog(logger.LevelError, "tiering from folder %q to folder %q failed: %v",
+				policy.SourceFolder, policy.TargetFolder, err)
+			params.AddError(err)
+			failures = append(failures, policy.SourceFolder)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("tiering failed for folders: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/drakkan/sftpgo/v2/internal/httpclient"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+const (
+	ocspRequestContentType  = "application/ocsp-request"
+	ocspResponseContentType = "application/ocsp-response"
+	maxOCSPResponseSize     = 1048576
+)
+
+var (
+	errRevocationStatusUnknown = errors.New("unable to determine the revocation status of your certificate")
+)
+
+// RevocationCheckConfig defines how the revocation status of TLS client
+// certificates is checked in addition to the configured CRLs
+type RevocationCheckConfig struct {
+	// Set to true to query the OCSP responders defined in the client certificates
+	OCSPEnabled bool `json:"ocsp_enabled" mapstructure:"ocsp_enabled"`
+	// If true the connection is rejected if the revocation status of the client
+	// certificate cannot be determined, for example because the OCSP responder is
+	// unreachable or the matching CRL is expired. By default these errors are
+	// logged and the connection is allowed (soft-fail)
+	HardFail bool `json:"hard_fail" mapstructure:"hard_fail"`
+	// OCSP responses are cached for this number of minutes. The cache expires earlier
+	// if the response defines an earlier next update. 0 means no cache
+	CacheTTL int `json:"cache_ttl" mapstructure:"cache_ttl"`
+}
+
+// Validate returns an error if the configuration is not valid
+func (c *RevocationCheckConfig) Validate() error {
+	if c.CacheTTL < 0 {
+		return fmt.Errorf("invalid OCSP cache TTL: %d", c.CacheTTL)
+	}
+	return nil
+}
+
+type ocspCacheEntry struct {
+	status    int
+	expiresAt time.Time
+}
+
+type ocspCache struct {
+	sync.RWMutex
+	entries map[string]ocspCacheEntry
+}
+
+func (c *ocspCache) get(key string) (int, bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return 0, false
+	}
+	return entry.status, true
+}
+
+func (c *ocspCache) add(key string, status int, expiresAt time.Time) {
+	c.Lock()
+	defer c.Unlock()
+
+	now := time.Now()
+	for k, v := range c.entries {
+		if now.After(v.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = ocspCacheEntry{
+		status:    status,
+		expiresAt: expiresAt,
+	}
+}
+
+// SetRevocationCheck sets the revocation check configuration.
+// This should not be changed at runtime
+func (m *CertManager) SetRevocationCheck(config RevocationCheckConfig) {
+	m.revocationCheck = config
+	m.ocspCache = &ocspCache{
+		entries: make(map[string]ocspCacheEntry),
+	}
+}
+
+// CheckRevocation returns ErrCrtRevoked if the leaf certificate of the given verified
+// chain has been revoked. The configured CRLs are checked first, then the OCSP
+// responders, if enabled. If hard-fail is enabled an error is returned if the
+// revocation status cannot be determined
+func (m *CertManager) CheckRevocation(chain []*x509.Certificate) error {
+	var crt, issuer, caCrt *x509.Certificate
+	if len(chain) > 0 {
+		crt = chain[0]
+		caCrt = chain[len(chain)-1]
+	}
+	if len(chain) > 1 {
+		issuer = chain[1]
+	}
+	if m.IsRevoked(crt, caCrt) {
+		return ErrCrtRevoked
+	}
+	if crt == nil || issuer == nil {
+		return m.onRevocationStatusUnknown(errors.New("incomplete certificate chain"))
+	}
+	if m.revocationCheck.OCSPEnabled && len(crt.OCSPServer) > 0 {
+		status, err := m.getOCSPStatus(crt, issuer)
+		if err != nil {
+			return m.onRevocationStatusUnknown(err)
+		}
+		switch status {
+		case ocsp.Good:
+			return nil
+		case ocsp.Revoked:
+			return ErrCrtRevoked
+		default:
+			return m.onRevocationStatusUnknown(fmt.Errorf("unknown OCSP status for certificate %q", crt.Subject))
+		}
+	}
+	if m.revocationCheck.HardFail && !m.hasValidCRL(caCrt) {
+		return m.onRevocationStatusUnknown(fmt.Errorf("no valid CRL or OCSP responder for certificate %q", crt.Subject))
+	}
+	return nil
+}
+
+func (m *CertManager) onRevocationStatusUnknown(err error) error {
+	if m.revocationCheck.HardFail {
+		logger.Warn(m.logSender, "", "unable to check the certificate revocation status, hard-fail enabled: %v", err)
+		return errRevocationStatusUnknown
+	}
+	logger.Debug(m.logSender, "", "unable to check the certificate revocation status, soft-fail: %v", err)
+	return nil
+}
+
+// hasValidCRL returns true if a not expired CRL signed by the specified CA is available
+func (m *CertManager) hasValidCRL(caCrt *x509.Certificate) bool {
+	if caCrt == nil {
+		return false
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+
+	now := time.Now()
+	for _, crl := range m.crls {
+		if crl.CheckSignatureFrom(caCrt) == nil {
+			if crl.NextUpdate.IsZero() || crl.NextUpdate.After(now) {
+				return true
+			}
+			logger.Warn(m.logSender, "", "the CRL for CA %q is expired, next update: %v", caCrt.Subject, crl.NextUpdate)
+		}
+	}
+	return false
+}
+
+func (m *CertManager) getOCSPStatus(crt, issuer *x509.Certificate) (int, error) {
+	cacheKey := fmt.Sprintf("%x_%s", issuer.SubjectKeyId, crt.SerialNumber.String())
+	if m.ocspCache != nil {
+		if status, ok := m.ocspCache.get(cacheKey); ok {
+			return status, nil
+		}
+	}
+	req, err := ocsp.CreateRequest(crt, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return ocsp.Unknown, fmt.Errorf("unable to create OCSP request: %w", err)
+	}
+	var lastErr error
+	for _, server := range crt.OCSPServer {
+		resp, err := m.sendOCSPRequest(server, req, crt, issuer)
+		if err != nil {
+			logger.Debug(m.logSender, "", "OCSP request to %q failed: %v", server, err)
+			lastErr = err
+			continue
+		}
+		if m.ocspCache != nil && m.revocationCheck.CacheTTL > 0 {
+			expiresAt := time.Now().Add(time.Duration(m.revocationCheck.CacheTTL) * time.Minute)
+			if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(expiresAt) {
+				expiresAt = resp.NextUpdate
+			}
+			m.ocspCache.add(cacheKey, resp.Status, expiresAt)
+		}
+		return resp.Status, nil
+	}
+	return ocsp.Unknown, lastErr
+}
+
+func (m *CertManager) sendOCSPRequest(server string, req []byte, crt, issuer *x509.Certificate) (*ocsp.Response, error) {
+	httpResp, err := httpclient.Post(server, ocspRequestContentType, bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", httpResp.StatusCode)
+	}
+	if contentType := httpResp.Header.Get("Content-Type"); contentType != "" && contentType != ocspResponseContentType {
+		return nil, fmt.Errorf("unexpected content type: %q", contentType)
+	}
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	resp, err := ocsp.ParseResponseForCert(body, crt, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse OCSP response: %w", err)
+	}
+	if !resp.NextUpdate.IsZero() && resp.NextUpdate.Before(time.Now()) {
+		return nil, errors.New("the OCSP response is expired")
+	}
+	return resp, nil
+}
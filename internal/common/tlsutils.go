@@ -61,6 +61,8 @@ type CertManager struct {
 	certsInfo         map[string]fs.FileInfo
 	rootCAs           *x509.CertPool
 	crls              []*x509.RevocationList
+	revocationCheck   RevocationCheckConfig
+	ocspCache         *ocspCache
 }
 
 // Reload tries to reload certificate and CRLs
@@ -96,6 +98,7 @@ func (m *CertManager) loadCertificates() error {
 		}
 		logger.Debug(m.logSender, "", "TLS certificate %q successfully loaded, id %v", keyPair.Cert, keyPair.ID)
 		certs[keyPair.ID] = &newCert
+		m.monitorExpiration(keyPair.Cert, &newCert)
 		if !util.Contains(m.monitorList, keyPair.Cert) {
 			m.monitorList = append(m.monitorList, keyPair.Cert)
 		}
@@ -108,6 +111,21 @@ func (m *CertManager) loadCertificates() error {
 	return nil
 }
 
+func (m *CertManager) monitorExpiration(certPath string, cert *tls.Certificate) {
+	leaf := cert.Leaf
+	if leaf == nil && len(cert.Certificate) > 0 {
+		parsed, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			logger.Warn(m.logSender, "", "unable to parse TLS certificate %q, expiration not monitored: %v", certPath, err)
+			return
+		}
+		leaf = parsed
+	}
+	if leaf != nil {
+		AddMonitoredCertificate(CertificateKindTLS, certPath, leaf.Subject.String(), leaf.NotAfter)
+	}
+}
+
 // HasCertificate returns true if there is a certificate for the specified certID
 func (m *CertManager) HasCertificate(certID string) bool {
 	m.RLock()
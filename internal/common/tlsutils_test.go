@@ -15,15 +15,26 @@
 package common
 
 import (
+	"crypto"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
 )
 
 const (
@@ -435,6 +446,108 @@ func TestLoadCertificate(t *testing.T) {
 	stopEventScheduler()
 }
 
+func TestCertificateRevocationCheck(t *testing.T) {
+	caPair, err := tls.X509KeyPair([]byte(caCRT), []byte(caKey))
+	require.NoError(t, err)
+	x509CAcrt, err := x509.ParseCertificate(caPair.Certificate[0])
+	require.NoError(t, err)
+	crt, err := tls.X509KeyPair([]byte(client1Crt), []byte(client1Key))
+	require.NoError(t, err)
+	x509crt, err := x509.ParseCertificate(crt.Certificate[0])
+	require.NoError(t, err)
+
+	certManager := &CertManager{
+		logSender: logSenderTest,
+	}
+	assert.NoError(t, certManager.CheckRevocation([]*x509.Certificate{x509crt, x509CAcrt}))
+	assert.NoError(t, certManager.CheckRevocation([]*x509.Certificate{x509crt}))
+	certManager.SetRevocationCheck(RevocationCheckConfig{HardFail: true})
+	assert.ErrorIs(t, certManager.CheckRevocation([]*x509.Certificate{x509crt, x509CAcrt}), errRevocationStatusUnknown)
+	assert.ErrorIs(t, certManager.CheckRevocation([]*x509.Certificate{x509crt}), errRevocationStatusUnknown)
+	assert.ErrorIs(t, certManager.CheckRevocation(nil), errRevocationStatusUnknown)
+
+	var ocspStatus atomic.Int32
+	var ocspRequests atomic.Int32
+	ocspStatus.Store(int32(ocsp.Good))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ocspRequests.Add(1)
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp, err := ocsp.CreateResponse(x509CAcrt, x509CAcrt, ocsp.Response{
+			Status:       int(ocspStatus.Load()),
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-1 * time.Minute),
+			NextUpdate:   time.Now().Add(1 * time.Hour),
+			RevokedAt:    time.Now().Add(-1 * time.Minute),
+		}, caPair.PrivateKey.(crypto.Signer))
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ocspResponseContentType)
+		w.Write(resp) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "ocsp client"},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		OCSPServer:   []string{server.URL},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, x509CAcrt, x509crt.PublicKey, caPair.PrivateKey)
+	require.NoError(t, err)
+	ocspCrt, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	chain := []*x509.Certificate{ocspCrt, x509CAcrt}
+
+	certManager.SetRevocationCheck(RevocationCheckConfig{
+		OCSPEnabled: true,
+		HardFail:    true,
+		CacheTTL:    10,
+	})
+	assert.NoError(t, certManager.CheckRevocation(chain))
+	assert.NoError(t, certManager.CheckRevocation(chain))
+	assert.Equal(t, int32(1), ocspRequests.Load())
+	// the cached response is used
+	ocspStatus.Store(int32(ocsp.Revoked))
+	assert.NoError(t, certManager.CheckRevocation(chain))
+	// without cache the revoked status is returned
+	certManager.SetRevocationCheck(RevocationCheckConfig{
+		OCSPEnabled: true,
+	})
+	assert.ErrorIs(t, certManager.CheckRevocation(chain), ErrCrtRevoked)
+	assert.ErrorIs(t, certManager.CheckRevocation(chain), ErrCrtRevoked)
+	assert.Equal(t, int32(3), ocspRequests.Load())
+	ocspStatus.Store(int32(ocsp.Unknown))
+	assert.NoError(t, certManager.CheckRevocation(chain))
+	certManager.SetRevocationCheck(RevocationCheckConfig{
+		OCSPEnabled: true,
+		HardFail:    true,
+	})
+	assert.ErrorIs(t, certManager.CheckRevocation(chain), errRevocationStatusUnknown)
+	server.Close()
+	assert.ErrorIs(t, certManager.CheckRevocation(chain), errRevocationStatusUnknown)
+	certManager.SetRevocationCheck(RevocationCheckConfig{
+		OCSPEnabled: true,
+	})
+	assert.NoError(t, certManager.CheckRevocation(chain))
+
+	config := RevocationCheckConfig{CacheTTL: -1}
+	assert.Error(t, config.Validate())
+}
+
 func TestLoadInvalidCert(t *testing.T) {
 	startEventScheduler()
 	certManager, err := NewCertManager(nil, configDir, logSenderTest)
@@ -524,3 +637,58 @@ func TestCertificateMonitor(t *testing.T) {
 	err = os.Remove(keyPath)
 	assert.NoError(t, err)
 }
+
+func TestCertificatesExpiry(t *testing.T) {
+	certPath := filepath.Join(os.TempDir(), "test.crt")
+	keyPath := filepath.Join(os.TempDir(), "test.key")
+	err := os.WriteFile(certPath, []byte(serverCert), os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(keyPath, []byte(serverKey), os.ModePerm)
+	assert.NoError(t, err)
+
+	keyPairs := []TLSKeyPair{
+		{
+			Cert: certPath,
+			Key:  keyPath,
+			ID:   DefaultTLSKeyPaidID,
+		},
+	}
+	_, err = NewCertManager(keyPairs, configDir, logSenderTest)
+	assert.NoError(t, err)
+	found := false
+	for _, cert := range certExpiryMonitor.getCertificates() {
+		if cert.kind == CertificateKindTLS && cert.name == certPath {
+			found = true
+			assert.Equal(t, "CN=localhost", cert.subject)
+			assert.True(t, cert.expiresAt.After(time.Now()))
+		}
+	}
+	assert.True(t, found)
+	RemoveMonitoredCertificate(CertificateKindTLS, certPath)
+	for _, cert := range certExpiryMonitor.getCertificates() {
+		assert.False(t, cert.kind == CertificateKindTLS && cert.name == certPath)
+	}
+	// removing a missing certificate should not fail
+	RemoveMonitoredCertificate(CertificateKindTLS, certPath)
+
+	conf := CertExpiryConfig{}
+	assert.False(t, conf.isEnabled())
+	conf.UserCertificates = 10
+	assert.True(t, conf.isEnabled())
+	assert.Equal(t, 10*24*time.Hour, conf.getThreshold(CertificateKindUser))
+	assert.Equal(t, time.Duration(0), conf.getThreshold(CertificateKindTLS))
+	assert.Equal(t, time.Duration(0), conf.getThreshold("unknown"))
+
+	user := dataprovider.User{}
+	user.Username = "test_user"
+	user.Filters.TLSCerts = []string{"invalid cert", serverCert}
+	certs := getUserCertificates(&user)
+	require.Len(t, certs, 1)
+	assert.Equal(t, CertificateKindUser, certs[0].kind)
+	assert.Equal(t, user.Username, certs[0].name)
+
+	err = os.Remove(certPath)
+	assert.NoError(t, err)
+	err = os.Remove(keyPath)
+	assert.NoError(t, err)
+}
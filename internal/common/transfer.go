@@ -56,6 +56,8 @@ type BaseTransfer struct { //nolint:maligned
 	aTime           time.Time
 	mTime           time.Time
 	transferQuota   dataprovider.TransferQuota
+	bandwidth       int64
+	uploadStateKey  string
 	metadata        map[string]string
 	sync.Mutex
 	errAbort    error
@@ -83,11 +85,13 @@ func NewBaseTransfer(file vfs.File, conn *BaseConnection, cancelFn func(), fsPat
 		MaxWriteSize:    maxWriteSize,
 		truncatedSize:   truncatedSize,
 		transferQuota:   transferQuota,
+		bandwidth:       conn.getTransferBandwidth(requestPath, transferType),
 		Fs:              fs,
 	}
 	t.AbortTransfer.Store(false)
 	t.BytesSent.Store(0)
 	t.BytesReceived.Store(0)
+	t.addUploadState()
 
 	conn.AddTransfer(t)
 	return t
@@ -281,6 +285,7 @@ func (t *BaseTransfer) Truncate(fsPath string, size int64) (int64, error) {
 					t.MaxWriteSize += sizeDiff
 					metric.TransferCompleted(t.BytesSent.Load(), t.BytesReceived.Load(),
 						t.transferType, t.ErrTransfer, vfs.IsSFTPFs(t.Fs))
+					t.updateTransferStats()
 					if t.transferQuota.HasSizeLimits() {
 						go func(ulSize, dlSize int64, user dataprovider.User) {
 							dataprovider.UpdateUserTransferQuota(&user, ulSize, dlSize, false) //nolint:errcheck
@@ -363,6 +368,11 @@ func (t *BaseTransfer) checkUploadOutsideHomeDir(err error) int {
 	return 1
 }
 
+func (t *BaseTransfer) updateTransferStats() {
+	transfersStats.add(t.Connection.protocol, t.Connection.User.Username, t.Connection.User.Role,
+		t.BytesReceived.Load(), t.BytesSent.Load())
+}
+
 // Close it is called when the transfer is completed.
 // It logs the transfer info, updates the user quota (for uploads)
 // and executes any defined action.
@@ -375,6 +385,7 @@ func (t *BaseTransfer) Close() error {
 	numFiles := t.getUploadedFiles()
 	metric.TransferCompleted(t.BytesSent.Load(), t.BytesReceived.Load(),
 		t.transferType, t.ErrTransfer, vfs.IsSFTPFs(t.Fs))
+	t.updateTransferStats()
 	if t.transferQuota.HasSizeLimits() {
 		dataprovider.UpdateUserTransferQuota(&t.Connection.User, t.BytesReceived.Load(), //nolint:errcheck
 			t.BytesSent.Load(), false)
@@ -405,6 +416,7 @@ func (t *BaseTransfer) Close() error {
 			}
 		}
 	}
+	t.removeUploadState()
 	elapsed := time.Since(t.start).Nanoseconds() / 1000000
 	var uploadFileSize int64
 	if t.transferType == TransferDownload {
@@ -429,6 +441,7 @@ func (t *BaseTransfer) Close() error {
 		numFiles, uploadFileSize = t.executeUploadHook(numFiles, uploadFileSize, elapsed)
 		t.updateQuota(numFiles, uploadFileSize)
 		t.updateTimes()
+		t.deduplicate()
 		logger.TransferLog(uploadLogSender, t.fsPath, elapsed, t.BytesReceived.Load(), t.Connection.User.Username,
 			t.Connection.ID, t.Connection.protocol, t.Connection.localAddr, t.Connection.remoteAddr, t.ftpMode)
 	}
@@ -506,6 +519,18 @@ func (t *BaseTransfer) updateTimes() {
 	}
 }
 
+func (t *BaseTransfer) deduplicate() {
+	if t.ErrTransfer != nil {
+		return
+	}
+	if deduplicator, ok := t.Fs.(vfs.FsDeduplicator); ok {
+		err := deduplicator.Deduplicate(t.fsPath)
+		if err != nil {
+			t.Connection.Log(logger.LevelWarn, "unable to deduplicate file %q: %v", t.fsPath, err)
+		}
+	}
+}
+
 func (t *BaseTransfer) updateQuota(numFiles int, fileSize int64) bool {
 	// Uploads on some filesystem (S3 and similar) are atomic, if there is an error nothing is uploaded
 	if t.File == nil && t.ErrTransfer != nil && vfs.HasImplicitAtomicUploads(t.Fs) {
@@ -515,7 +540,7 @@ func (t *BaseTransfer) updateQuota(numFiles int, fileSize int64) bool {
 	if t.transferType == TransferUpload && (numFiles != 0 || sizeDiff != 0) {
 		vfolder, err := t.Connection.User.GetVirtualFolderForPath(path.Dir(t.requestPath))
 		if err == nil {
-			dataprovider.UpdateVirtualFolderQuota(&vfolder.BaseVirtualFolder, numFiles, //nolint:errcheck
+			dataprovider.UpdateVirtualFolderQuota(&vfolder, numFiles, //nolint:errcheck
 				sizeDiff, false)
 			if vfolder.IsIncludedInUserQuota() {
 				dataprovider.UpdateUserQuota(&t.Connection.User, numFiles, sizeDiff, false) //nolint:errcheck
@@ -530,15 +555,13 @@ func (t *BaseTransfer) updateQuota(numFiles int, fileSize int64) bool {
 
 // HandleThrottle manage bandwidth throttling
 func (t *BaseTransfer) HandleThrottle() {
-	var wantedBandwidth int64
 	var trasferredBytes int64
 	if t.transferType == TransferDownload {
-		wantedBandwidth = t.Connection.User.DownloadBandwidth
 		trasferredBytes = t.BytesSent.Load()
 	} else {
-		wantedBandwidth = t.Connection.User.UploadBandwidth
 		trasferredBytes = t.BytesReceived.Load()
 	}
+	wantedBandwidth := t.bandwidth
 	if wantedBandwidth > 0 {
 		// real and wanted elapsed as milliseconds, bytes as kilobytes
 		realElapsed := time.Since(t.start).Nanoseconds() / 1000000
@@ -28,6 +28,7 @@ import (
 
 	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
 	"github.com/drakkan/sftpgo/v2/internal/kms"
+	"github.com/drakkan/sftpgo/v2/internal/util"
 	"github.com/drakkan/sftpgo/v2/internal/vfs"
 )
 
@@ -105,6 +106,144 @@ func TestTransferThrottling(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestTransferFolderBandwidth(t *testing.T) {
+	u := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username:          "test",
+			UploadBandwidth:   50,
+			DownloadBandwidth: 40,
+		},
+		VirtualFolders: []vfs.VirtualFolder{
+			{
+				BaseVirtualFolder: vfs.BaseVirtualFolder{
+					Name:       "archive",
+					MappedPath: filepath.Join(os.TempDir(), "archive"),
+				},
+				VirtualPath:       "/archive",
+				UploadBandwidth:   10,
+				DownloadBandwidth: 0,
+			},
+		},
+	}
+	fs := vfs.NewOsFs("", os.TempDir(), "", nil)
+	conn := NewBaseConnection("id", ProtocolSFTP, "", "", u)
+	assert.Equal(t, int64(50), conn.getTransferBandwidth("/file", TransferUpload))
+	assert.Equal(t, int64(40), conn.getTransferBandwidth("/file", TransferDownload))
+	assert.Equal(t, int64(10), conn.getTransferBandwidth("/archive/file", TransferUpload))
+	assert.Equal(t, int64(40), conn.getTransferBandwidth("/archive/sub/file", TransferDownload))
+
+	transfer := NewBaseTransfer(nil, conn, nil, "", "", "/archive/file", TransferUpload, 0, 0, 0, 0, true, fs,
+		dataprovider.TransferQuota{})
+	assert.Equal(t, int64(10), transfer.bandwidth)
+	err := transfer.Close()
+	assert.NoError(t, err)
+	transfer = NewBaseTransfer(nil, conn, nil, "", "", "/file", TransferUpload, 0, 0, 0, 0, true, fs,
+		dataprovider.TransferQuota{})
+	assert.Equal(t, int64(50), transfer.bandwidth)
+	err = transfer.Close()
+	assert.NoError(t, err)
+}
+
+func TestUploadStates(t *testing.T) {
+	switch dataprovider.GetProviderStatus().Driver {
+	case dataprovider.BoltDataProviderName, dataprovider.MemoryDataProviderName:
+		t.Skip("upload states are not supported with the configured data provider")
+	}
+	oldRetention := Config.UploadStateRetention
+	Config.UploadStateRetention = 1
+
+	u := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: "upload_state_user",
+			Password: "pwd",
+			HomeDir:  filepath.Join(os.TempDir(), "upload_state_user"),
+			Status:   1,
+			Permissions: map[string][]string{
+				"/": {dataprovider.PermAny},
+			},
+		},
+	}
+	err := dataprovider.AddUser(&u, "", "", "")
+	require.NoError(t, err)
+	err = os.MkdirAll(u.HomeDir, os.ModePerm)
+	require.NoError(t, err)
+	fs := vfs.NewOsFs("", u.HomeDir, "", nil)
+	conn := NewBaseConnection("", ProtocolSFTP, "", "", u)
+	fsPath := filepath.Join(u.HomeDir, "file")
+	tempPath := filepath.Join(u.HomeDir, ".file.tmp")
+	err = os.WriteFile(tempPath, []byte("data"), 0666)
+	require.NoError(t, err)
+	// the upload state is removed when the transfer is closed
+	transfer := NewBaseTransfer(nil, conn, nil, fsPath, tempPath, "/file", TransferUpload, 0, 0, 0, 0, true, fs,
+		dataprovider.TransferQuota{})
+	assert.NotEmpty(t, transfer.uploadStateKey)
+	assert.True(t, uploadStates.isLocal(transfer.uploadStateKey))
+	sessions, err := dataprovider.GetSharedSessions(dataprovider.SessionTypeUploadState)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	err = transfer.Close()
+	assert.NoError(t, err)
+	assert.False(t, uploadStates.isLocal(transfer.uploadStateKey))
+	sessions, err = dataprovider.GetSharedSessions(dataprovider.SessionTypeUploadState)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 0)
+	assert.FileExists(t, fsPath)
+	assert.NoFileExists(t, tempPath)
+	// an interrupted upload is recovered
+	err = os.Remove(fsPath)
+	assert.NoError(t, err)
+	err = os.WriteFile(tempPath, []byte("partial data"), 0666)
+	require.NoError(t, err)
+	state := uploadState{
+		Username:    u.Username,
+		VirtualPath: "/file",
+		FsPath:      fsPath,
+		TempPath:    tempPath,
+		IsNewFile:   true,
+		CreatedAt:   util.GetTimeAsMsSinceEpoch(time.Now().Add(-10 * time.Minute)),
+	}
+	err = dataprovider.AddSharedSession(dataprovider.Session{
+		Key:       state.getKey(),
+		Data:      state,
+		Type:      dataprovider.SessionTypeUploadState,
+		Timestamp: util.GetTimeAsMsSinceEpoch(time.Now().Add(-2 * uploadStateStaleInterval)),
+	})
+	assert.NoError(t, err)
+	uploadStates.check()
+	assert.NoFileExists(t, tempPath)
+	assert.FileExists(t, fsPath)
+	sessions, err = dataprovider.GetSharedSessions(dataprovider.SessionTypeUploadState)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 0)
+	// an abandoned upload is removed
+	err = os.WriteFile(tempPath, []byte("partial data"), 0666)
+	require.NoError(t, err)
+	state.CreatedAt = util.GetTimeAsMsSinceEpoch(time.Now().Add(-2 * time.Hour))
+	err = dataprovider.AddSharedSession(dataprovider.Session{
+		Key:       state.getKey(),
+		Data:      state,
+		Type:      dataprovider.SessionTypeUploadState,
+		Timestamp: util.GetTimeAsMsSinceEpoch(time.Now().Add(-2 * uploadStateStaleInterval)),
+	})
+	assert.NoError(t, err)
+	uploadStates.check()
+	assert.NoFileExists(t, tempPath)
+	sessions, err = dataprovider.GetSharedSessions(dataprovider.SessionTypeUploadState)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 0)
+	// invalid upload state data
+	_, err = decodeUploadState("invalid")
+	assert.Error(t, err)
+	_, err = decodeUploadState([]byte("{}"))
+	assert.Error(t, err)
+
+	err = dataprovider.DeleteUser(u.Username, "", "", "")
+	assert.NoError(t, err)
+	err = os.RemoveAll(u.HomeDir)
+	assert.NoError(t, err)
+	Config.UploadStateRetention = oldRetention
+}
+
 func TestRealPath(t *testing.T) {
 	testFile := filepath.Join(os.TempDir(), "afile.txt")
 	fs := vfs.NewOsFs("123", os.TempDir(), "", nil)
@@ -458,3 +597,35 @@ func TestUploadOutsideHomeRenameError(t *testing.T) {
 
 	Config.TempPath = oldTempPath
 }
+
+func TestTransferStats(t *testing.T) {
+	stats := newTransferStats()
+	stats.add(ProtocolSFTP, "user1", "", 100, 0)
+	stats.add(ProtocolSFTP, "user2", "role1", 0, 300)
+	stats.add(ProtocolFTP, "user2", "role1", 50, 0)
+	stats.add(ProtocolWebDAV, "user3", "role2", 0, 0)
+
+	protocols, users := stats.get("")
+	assert.Len(t, protocols, 2)
+	assert.Len(t, users, 2)
+	assert.Equal(t, int64(100), protocols[ProtocolSFTP].Uploaded)
+	assert.Equal(t, int64(300), protocols[ProtocolSFTP].Downloaded)
+	assert.Equal(t, int64(350), users["user2"].GetTotal())
+
+	protocols, users = stats.get("role1")
+	assert.Len(t, protocols, 2)
+	assert.Len(t, users, 1)
+	assert.Equal(t, int64(0), protocols[ProtocolSFTP].Uploaded)
+	assert.Equal(t, int64(300), protocols[ProtocolSFTP].Downloaded)
+
+	protocols, users = stats.get("role2")
+	assert.Len(t, protocols, 0)
+	assert.Len(t, users, 0)
+
+	_, users = stats.get("")
+	sorted := getSortedTransferStats(users)
+	if assert.Len(t, sorted, 2) {
+		assert.Equal(t, "user2", sorted[0].Name)
+		assert.Equal(t, "user1", sorted[1].Name)
+	}
+}
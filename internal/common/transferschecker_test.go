@@ -635,7 +635,9 @@ func TestGetUsersForQuotaCheck(t *testing.T) {
 		assert.NoError(t, err)
 		err = dataprovider.AddUser(&user, "", "", "")
 		assert.NoError(t, err)
-		err = dataprovider.UpdateVirtualFolderQuota(&vfs.BaseVirtualFolder{Name: fmt.Sprintf("f%v", i)}, 1, 50, false)
+		err = dataprovider.UpdateVirtualFolderQuota(&vfs.VirtualFolder{
+			BaseVirtualFolder: vfs.BaseVirtualFolder{Name: fmt.Sprintf("f%v", i)},
+		}, 1, 50, false)
 		assert.NoError(t, err)
 	}
 
@@ -0,0 +1,134 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+var transfersStats = newTransferStats()
+
+// TransferStat defines the bytes transferred for a protocol or a user
+type TransferStat struct {
+	Name       string `json:"name"`
+	Uploaded   int64  `json:"uploaded"`
+	Downloaded int64  `json:"downloaded"`
+	role       string
+}
+
+// GetTotal returns the total bytes transferred
+func (s *TransferStat) GetTotal() int64 {
+	return s.Uploaded + s.Downloaded
+}
+
+// transferStats keeps in memory the bytes transferred since the service start
+type transferStats struct {
+	mu sync.RWMutex
+	// protocols stats grouped by user role
+	protocols map[string]map[string]*TransferStat
+	users     map[string]*TransferStat
+}
+
+func newTransferStats() *transferStats {
+	return &transferStats{
+		protocols: make(map[string]map[string]*TransferStat),
+		users:     make(map[string]*TransferStat),
+	}
+}
+
+func (s *transferStats) add(protocol, username, role string, uploaded, downloaded int64) {
+	if uploaded == 0 && downloaded == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	protocols, ok := s.protocols[role]
+	if !ok {
+		protocols = make(map[string]*TransferStat)
+		s.protocols[role] = protocols
+	}
+	addTransferStat(protocols, protocol, role, uploaded, downloaded)
+	addTransferStat(s.users, username, role, uploaded, downloaded)
+}
+
+func (s *transferStats) get(role string) (map[string]*TransferStat, map[string]*TransferStat) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	protocols := make(map[string]*TransferStat)
+	users := make(map[string]*TransferStat)
+	for protocolsRole, stats := range s.protocols {
+		if role == "" || protocolsRole == role {
+			for name, stat := range stats {
+				addTransferStat(protocols, name, role, stat.Uploaded, stat.Downloaded)
+			}
+		}
+	}
+	for name, stat := range s.users {
+		if role == "" || stat.role == role {
+			addTransferStat(users, name, stat.role, stat.Uploaded, stat.Downloaded)
+		}
+	}
+	return protocols, users
+}
+
+func addTransferStat(stats map[string]*TransferStat, name, role string, uploaded, downloaded int64) {
+	stat, ok := stats[name]
+	if !ok {
+		stat = &TransferStat{
+			Name: name,
+			role: role,
+		}
+		stats[name] = stat
+	}
+	stat.Uploaded += uploaded
+	stat.Downloaded += downloaded
+}
+
+// GetTransferStats returns the bytes transferred for each protocol and user
+// since the service start, the active transfers are included. Admins with a
+// role only see the users with the same role
+func GetTransferStats(role string) ([]TransferStat, []TransferStat) {
+	protocols, users := transfersStats.get(role)
+	for _, conn := range Connections.GetStats(role) {
+		for _, t := range conn.Transfers {
+			addTransferStat(protocols, conn.Protocol, "", t.ULSize, t.DLSize)
+			addTransferStat(users, conn.Username, role, t.ULSize, t.DLSize)
+		}
+	}
+	return getSortedTransferStats(protocols), getSortedTransferStats(users)
+}
+
+// getSortedTransferStats returns the stats sorted by total bytes transferred
+func getSortedTransferStats(stats map[string]*TransferStat) []TransferStat {
+	result := make([]TransferStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, *stat)
+	}
+	slices.SortFunc(result, func(a, b TransferStat) int {
+		if a.GetTotal() == b.GetTotal() {
+			return strings.Compare(a.Name, b.Name)
+		}
+		if a.GetTotal() > b.GetTotal() {
+			return -1
+		}
+		return 1
+	})
+	return result
+}
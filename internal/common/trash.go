@@ -0,0 +1,513 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+const (
+	// the trash is stored inside this directory in the user's root filesystem,
+	// if no different name is configured
+	defaultTrashDirName = ".trash"
+	// this file is created together with the trash directory. An existing directory
+	// with the trash name but without this file belongs to the user and is never used
+	// as trash
+	trashMarkerName     = ".sftpgo-trash"
+	trashMetadataSuffix = ".json"
+	// the expired trash items are purged at this interval
+	trashPurgeInterval   = 1 * time.Hour
+	trashUsersPageLimit  = 100
+	maxTrashMetadataSize = 64 * 1024
+)
+
+// TrashItem defines a deleted file moved to the trash
+type TrashItem struct {
+	// Unique identifier
+	ID string `json:"id"`
+	// Original virtual path
+	Path string `json:"path"`
+	// File size as bytes
+	Size int64 `json:"size"`
+	// deletion time as unix timestamp in milliseconds
+	DeletedAt int64 `json:"deleted_at"`
+	// the item is permanently removed after this time, as unix timestamp in milliseconds
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+func (i *TrashItem) isExpired() bool {
+	return i.ExpiresAt < util.GetTimeAsMsSinceEpoch(time.Now())
+}
+
+// IsTrashEnabled returns true if the deleted files are moved to the trash
+func IsTrashEnabled() bool {
+	return Config.TrashRetention > 0
+}
+
+func (c *Configuration) initializeTrash() error {
+	if c.TrashRetention <= 0 {
+		return nil
+	}
+	if c.TrashDirName == "" {
+		c.TrashDirName = defaultTrashDirName
+	}
+	if c.TrashDirName == "." || c.TrashDirName == ".." || strings.ContainsAny(c.TrashDirName, `/\`) {
+		return fmt.Errorf("invalid trash dir name %q", c.TrashDirName)
+	}
+	return nil
+}
+
+func getTrashVirtualPath() string {
+	return "/" + Config.TrashDirName
+}
+
+func isTrashPath(virtualPath string) bool {
+	trashPath := getTrashVirtualPath()
+	return virtualPath == trashPath || strings.HasPrefix(virtualPath, trashPath+"/")
+}
+
+// isTrashDir returns true if the specified directory was created to store the trash
+func isTrashDir(fs vfs.Fs, trashPath string) bool {
+	info, err := fs.Stat(fs.Join(trashPath, trashMarkerName))
+	return err == nil && info.Mode().IsRegular()
+}
+
+func getTrashRetention() time.Duration {
+	return time.Duration(Config.TrashRetention) * time.Hour
+}
+
+// hasTrashDir returns true if the trash directory exists inside the user's root filesystem
+func (c *BaseConnection) hasTrashDir() bool {
+	fs, trashPath, err := c.getTrashFsAndPath()
+	if err != nil {
+		return false
+	}
+	return isTrashDir(fs, trashPath)
+}
+
+func (c *BaseConnection) filterTrashDir(files []os.FileInfo) []os.FileInfo {
+	for idx, fi := range files {
+		if fi.Name() == Config.TrashDirName && fi.IsDir() {
+			if !c.hasTrashDir() {
+				return files
+			}
+			return append(files[:idx], files[idx+1:]...)
+		}
+	}
+	return files
+}
+
+// canMoveToTrash returns true if the specified file must be moved to the trash
+// instead of being removed. Only regular files inside the user's root
+// filesystem can be moved to the trash, files inside virtual folders are
+// permanently removed
+func (c *BaseConnection) canMoveToTrash(virtualPath string, info os.FileInfo) bool {
+	if !IsTrashEnabled() || !info.Mode().IsRegular() || c.protocol == ProtocolDataRetention {
+		return false
+	}
+	if isTrashPath(virtualPath) {
+		return false
+	}
+	_, err := c.User.GetVirtualFolderForPath(path.Dir(virtualPath))
+	return err != nil
+}
+
+func (c *BaseConnection) getTrashFsAndPath() (vfs.Fs, string, error) {
+	fs, err := c.User.GetFilesystemForPath("/", c.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	trashPath, err := fs.ResolvePath(getTrashVirtualPath())
+	if err != nil {
+		return nil, "", err
+	}
+	return fs, trashPath, nil
+}
+
+// checkTrashDir creates the trash directory if missing. It returns an error if
+// a directory with the same name, not created to store the trash, already exists
+func (c *BaseConnection) checkTrashDir(fs vfs.Fs, trashPath string) error {
+	if _, err := fs.Stat(trashPath); err != nil {
+		if !fs.IsNotExist(err) {
+			return err
+		}
+		if err := fs.Mkdir(trashPath); err != nil {
+			return err
+		}
+		vfs.SetPathPermissions(fs, trashPath, c.User.GetUID(), c.User.GetGID())
+		return writeTrashFile(fs, fs.Join(trashPath, trashMarkerName), nil)
+	}
+	if !isTrashDir(fs, trashPath) {
+		c.Log(logger.LevelError, "unable to use %q as trash, the directory already exists, configure a different trash dir name",
+			getTrashVirtualPath())
+		return fmt.Errorf("the trash directory %q conflicts with an existing directory: %w",
+			getTrashVirtualPath(), c.GetOpUnsupportedError())
+	}
+	return nil
+}
+
+func (c *BaseConnection) moveToTrash(fs vfs.Fs, fsPath, virtualPath string, info os.FileInfo) error {
+	_, trashPath, err := c.getTrashFsAndPath()
+	if err != nil {
+		return err
+	}
+	if err := c.checkTrashDir(fs, trashPath); err != nil {
+		return err
+	}
+	now := time.Now()
+	item := TrashItem{
+		ID:        xid.New().String(),
+		Path:      virtualPath,
+		Size:      info.Size(),
+		DeletedAt: util.GetTimeAsMsSinceEpoch(now),
+		ExpiresAt: util.GetTimeAsMsSinceEpoch(now.Add(getTrashRetention())),
+	}
+	target := fs.Join(trashPath, item.ID)
+	if _, _, err := fs.Rename(fsPath, target); err != nil {
+		return err
+	}
+	if err := writeTrashMetadata(fs, trashPath, &item); err != nil {
+		c.Log(logger.LevelError, "unable to write trash metadata for %q: %v", virtualPath, err)
+		if _, _, errRename := fs.Rename(target, fsPath); errRename != nil {
+			c.Log(logger.LevelError, "unable to restore %q after a trash error: %v", virtualPath, errRename)
+		}
+		return err
+	}
+	// the trashed file is still included in the quota, the metadata file is not
+	c.Log(logger.LevelDebug, "file %q moved to the trash, id: %q", virtualPath, item.ID)
+	return nil
+}
+
+// GetTrashItems returns the files inside the trash, the most recently deleted first
+func (c *BaseConnection) GetTrashItems() ([]TrashItem, error) {
+	if !IsTrashEnabled() {
+		return nil, fmt.Errorf("the trash is disabled: %w", c.GetOpUnsupportedError())
+	}
+	fs, trashPath, err := c.getTrashFsAndPath()
+	if err != nil {
+		return nil, err
+	}
+	if !isTrashDir(fs, trashPath) {
+		return []TrashItem{}, nil
+	}
+	entries, err := fs.ReadDir(trashPath)
+	if err != nil {
+		if fs.IsNotExist(err) {
+			return []TrashItem{}, nil
+		}
+		return nil, c.GetFsError(fs, err)
+	}
+	items := make([]TrashItem, 0, len(entries)/2)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), trashMetadataSuffix) {
+			continue
+		}
+		item, err := readTrashMetadata(fs, trashPath, strings.TrimSuffix(entry.Name(), trashMetadataSuffix))
+		if err != nil {
+			c.Log(logger.LevelWarn, "unable to read trash metadata %q: %v", entry.Name(), err)
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt > items[j].DeletedAt
+	})
+	return items, nil
+}
+
+func (c *BaseConnection) getTrashItem(id string) (vfs.Fs, string, TrashItem, error) {
+	if !IsTrashEnabled() {
+		return nil, "", TrashItem{}, fmt.Errorf("the trash is disabled: %w", c.GetOpUnsupportedError())
+	}
+	if _, err := xid.FromString(id); err != nil {
+		return nil, "", TrashItem{}, fmt.Errorf("invalid trash item %q: %w", id, c.GetNotExistError())
+	}
+	fs, trashPath, err := c.getTrashFsAndPath()
+	if err != nil {
+		return nil, "", TrashItem{}, err
+	}
+	if !isTrashDir(fs, trashPath) {
+		return nil, "", TrashItem{}, fmt.Errorf("trash item %q not found: %w", id, c.GetNotExistError())
+	}
+	item, err := readTrashMetadata(fs, trashPath, id)
+	if err != nil {
+		return nil, "", TrashItem{}, c.GetFsError(fs, err)
+	}
+	return fs, trashPath, item, nil
+}
+
+// RestoreTrashItem moves the trash item with the specified ID back to its original path
+func (c *BaseConnection) RestoreTrashItem(id string) (TrashItem, error) {
+	fs, trashPath, item, err := c.getTrashItem(id)
+	if err != nil {
+		return item, err
+	}
+	if !c.User.HasPerm(dataprovider.PermUpload, path.Dir(item.Path)) {
+		return item, c.GetPermissionDeniedError()
+	}
+	if ok, policy := c.User.IsFileAllowed(item.Path); !ok {
+		return item, c.GetErrorForDeniedFile(policy)
+	}
+	if _, err := c.User.GetVirtualFolderForPath(path.Dir(item.Path)); err == nil {
+		return item, fmt.Errorf("cannot restore %q inside a virtual folder: %w", item.Path, c.GetOpUnsupportedError())
+	}
+	targetFs, fsTargetPath, err := c.GetFsAndResolvedPath(item.Path)
+	if err != nil {
+		return item, err
+	}
+	if _, err := targetFs.Lstat(fsTargetPath); err == nil {
+		return item, fmt.Errorf("cannot restore %q, the path already exists: %w", item.Path, c.GetOpUnsupportedError())
+	}
+	if err := c.CheckParentDirs(path.Dir(item.Path)); err != nil {
+		return item, err
+	}
+	if _, _, err := fs.Rename(fs.Join(trashPath, item.ID), fsTargetPath); err != nil {
+		c.Log(logger.LevelError, "unable to restore trash item %q to %q: %v", item.ID, item.Path, err)
+		return item, c.GetFsError(fs, err)
+	}
+	if err := removeTrashMetadata(fs, trashPath, item.ID); err != nil {
+		c.Log(logger.LevelWarn, "unable to remove metadata for restored trash item %q: %v", item.ID, err)
+	}
+	c.Log(logger.LevelInfo, "trash item %q restored to %q", item.ID, item.Path)
+	return item, nil
+}
+
+// DeleteTrashItem permanently removes the trash item with the specified ID
+func (c *BaseConnection) DeleteTrashItem(id string) error {
+	fs, trashPath, item, err := c.getTrashItem(id)
+	if err != nil {
+		return err
+	}
+	if !c.User.HasAnyPerm([]string{dataprovider.PermDeleteFiles, dataprovider.PermDelete}, path.Dir(item.Path)) {
+		return c.GetPermissionDeniedError()
+	}
+	return c.purgeTrashItem(fs, trashPath, item)
+}
+
+// EmptyTrash permanently removes all the items inside the trash
+func (c *BaseConnection) EmptyTrash() error {
+	items, err := c.GetTrashItems()
+	if err != nil {
+		return err
+	}
+	fs, trashPath, err := c.getTrashFsAndPath()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if !c.User.HasAnyPerm([]string{dataprovider.PermDeleteFiles, dataprovider.PermDelete}, path.Dir(item.Path)) {
+			continue
+		}
+		if err := c.purgeTrashItem(fs, trashPath, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *BaseConnection) purgeTrashItem(fs vfs.Fs, trashPath string, item TrashItem) error {
+	removed := true
+	if err := fs.Remove(fs.Join(trashPath, item.ID), false); err != nil {
+		if !fs.IsNotExist(err) {
+			c.Log(logger.LevelError, "unable to purge trash item %q: %v", item.ID, err)
+			return c.GetFsError(fs, err)
+		}
+		removed = false
+	}
+	if err := removeTrashMetadata(fs, trashPath, item.ID); err != nil {
+		c.Log(logger.LevelError, "unable to remove metadata for trash item %q: %v", item.ID, err)
+		return c.GetFsError(fs, err)
+	}
+	if removed {
+		dataprovider.UpdateUserQuota(&c.User, -1, -item.Size, false) //nolint:errcheck
+	}
+	c.Log(logger.LevelDebug, "trash item %q, original path %q, purged", item.ID, item.Path)
+	return nil
+}
+
+// purgeExpiredTrashItems permanently removes the expired items
+func (c *BaseConnection) purgeExpiredTrashItems() error {
+	items, err := c.GetTrashItems()
+	if err != nil {
+		return err
+	}
+	fs, trashPath, err := c.getTrashFsAndPath()
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if !item.isExpired() {
+			continue
+		}
+		if err := c.purgeTrashItem(fs, trashPath, item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getTrashMetadataUsage returns the number and the size of the files used to
+// store the trash metadata, they are not included in the user's quota
+func (c *BaseConnection) getTrashMetadataUsage() (int, int64, error) {
+	fs, trashPath, err := c.getTrashFsAndPath()
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isTrashDir(fs, trashPath) {
+		return 0, 0, nil
+	}
+	entries, err := fs.ReadDir(trashPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	numFiles := 0
+	var size int64
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		if entry.Name() == trashMarkerName || strings.HasSuffix(entry.Name(), trashMetadataSuffix) {
+			numFiles++
+			size += entry.Size()
+		}
+	}
+	return numFiles, size, nil
+}
+
+// ScanUserQuota returns the number of files and their size for the specified user,
+// the files used to store the trash metadata are excluded
+func ScanUserQuota(user *dataprovider.User) (int, int64, error) {
+	numFiles, size, err := user.ScanQuota()
+	if err != nil || !IsTrashEnabled() {
+		return numFiles, size, err
+	}
+	conn := NewBaseConnection(xid.New().String(), ProtocolDataRetention, "", "", *user)
+	defer conn.CloseFS() //nolint:errcheck
+
+	metadataFiles, metadataSize, err := conn.getTrashMetadataUsage()
+	if err != nil {
+		return numFiles, size, err
+	}
+	return numFiles - metadataFiles, size - metadataSize, nil
+}
+
+func writeTrashMetadata(fs vfs.Fs, trashPath string, item *TrashItem) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return writeTrashFile(fs, fs.Join(trashPath, item.ID+trashMetadataSuffix), data)
+}
+
+func writeTrashFile(fs vfs.Fs, name string, data []byte) error {
+	f, w, cancelFn, err := fs.Create(name, 0, 0)
+	if err != nil {
+		return err
+	}
+	if cancelFn != nil {
+		defer cancelFn()
+	}
+	var writer io.WriteCloser = w
+	if f != nil {
+		writer = f
+	}
+	_, err = writer.Write(data)
+	if errClose := writer.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+func readTrashMetadata(fs vfs.Fs, trashPath, id string) (TrashItem, error) {
+	var item TrashItem
+	f, r, cancelFn, err := fs.Open(fs.Join(trashPath, id+trashMetadataSuffix), 0)
+	if err != nil {
+		return item, err
+	}
+	if cancelFn != nil {
+		defer cancelFn()
+	}
+	var reader io.ReadCloser = r
+	if f != nil {
+		reader = f
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxTrashMetadataSize))
+	if err != nil {
+		return item, err
+	}
+	if err := json.Unmarshal(data, &item); err != nil {
+		return item, err
+	}
+	if item.ID != id {
+		return item, fmt.Errorf("trash metadata mismatch, expected id %q, got %q", id, item.ID)
+	}
+	return item, nil
+}
+
+func removeTrashMetadata(fs vfs.Fs, trashPath, id string) error {
+	return fs.Remove(fs.Join(trashPath, id+trashMetadataSuffix), false)
+}
+
+// purgeTrash permanently removes the expired trash items for all the users
+func purgeTrash() {
+	if !IsTrashEnabled() {
+		return
+	}
+	offset := 0
+	for {
+		users, err := dataprovider.GetUsers(trashUsersPageLimit, offset, dataprovider.OrderASC, "")
+		if err != nil {
+			logger.Error(logSender, "", "unable to get users to purge the trash: %v", err)
+			return
+		}
+		for idx := range users {
+			purgeUserTrash(users[idx])
+		}
+		if len(users) < trashUsersPageLimit {
+			return
+		}
+		offset += len(users)
+	}
+}
+
+func purgeUserTrash(user dataprovider.User) {
+	if err := user.LoadAndApplyGroupSettings(); err != nil {
+		logger.Warn(logSender, "", "unable to purge the trash for user %q, cannot apply group settings: %v",
+			user.Username, err)
+		return
+	}
+	conn := NewBaseConnection(xid.New().String(), ProtocolDataRetention, "", "", user)
+	defer conn.CloseFS() //nolint:errcheck
+
+	if err := conn.purgeExpiredTrashItems(); err != nil {
+		logger.Warn(logSender, "", "unable to purge the trash for user %q: %v", user.Username, err)
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	// the upload states for the active transfers are refreshed at this interval
+	uploadStateCheckInterval = 5 * time.Minute
+	// upload states not refreshed within this interval belong to interrupted uploads
+	uploadStateStaleInterval = 3 * uploadStateCheckInterval
+)
+
+var uploadStates = newUploadStatesManager()
+
+// uploadState defines the bookkeeping for an in progress atomic upload, it is persisted
+// in the data provider so that uploads interrupted by a service restart can be resumed
+type uploadState struct {
+	Username    string `json:"username"`
+	VirtualPath string `json:"virtual_path"`
+	FsPath      string `json:"fs_path"`
+	TempPath    string `json:"temp_path"`
+	InitialSize int64  `json:"initial_size"`
+	IsNewFile   bool   `json:"is_new_file"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+func (s *uploadState) getKey() string {
+	h := sha256.Sum256([]byte(s.Username + "_" + s.VirtualPath))
+	return "upload_" + hex.EncodeToString(h[:])
+}
+
+// recover moves the temporary file to the target path, so the client can resume
+// the upload, and updates the quota as for a completed upload
+func (s *uploadState) recover() error {
+	user, err := dataprovider.GetUserWithGroupSettings(s.Username, "")
+	if err != nil {
+		return err
+	}
+	fs, err := user.GetFilesystemForPath(s.VirtualPath, xid.New().String())
+	if err != nil {
+		return err
+	}
+	info, err := fs.Stat(s.TempPath)
+	if err != nil {
+		if fs.IsNotExist(err) {
+			logger.Debug(logSender, "", "temporary file %q for user %q not found, nothing to recover",
+				s.TempPath, s.Username)
+			return nil
+		}
+		return err
+	}
+	if _, _, err := fs.Rename(s.TempPath, s.FsPath); err != nil {
+		return err
+	}
+	numFiles := 0
+	if s.IsNewFile {
+		numFiles = 1
+	}
+	sizeDiff := info.Size() - s.InitialSize
+	vfolder, err := user.GetVirtualFolderForPath(path.Dir(s.VirtualPath))
+	if err == nil {
+		dataprovider.UpdateVirtualFolderQuota(&vfolder, numFiles, sizeDiff, false) //nolint:errcheck
+		if vfolder.IsIncludedInUserQuota() {
+			dataprovider.UpdateUserQuota(&user, numFiles, sizeDiff, false) //nolint:errcheck
+		}
+	} else {
+		dataprovider.UpdateUserQuota(&user, numFiles, sizeDiff, false) //nolint:errcheck
+	}
+	logger.Info(logSender, "", "interrupted upload recovered for user %q, path %q, rename %q -> %q, size: %d",
+		s.Username, s.VirtualPath, s.TempPath, s.FsPath, info.Size())
+	return nil
+}
+
+// cleanup removes the temporary file for an abandoned upload
+func (s *uploadState) cleanup() error {
+	user, err := dataprovider.GetUserWithGroupSettings(s.Username, "")
+	if err != nil {
+		return err
+	}
+	fs, err := user.GetFilesystemForPath(s.VirtualPath, xid.New().String())
+	if err != nil {
+		return err
+	}
+	err = fs.Remove(s.TempPath, false)
+	if err != nil && !fs.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+type uploadStatesManager struct {
+	sync.RWMutex
+	// upload states for the transfers handled by this instance
+	states map[string]uploadState
+}
+
+func newUploadStatesManager() *uploadStatesManager {
+	return &uploadStatesManager{
+		states: make(map[string]uploadState),
+	}
+}
+
+func (m *uploadStatesManager) add(state uploadState) string {
+	key := state.getKey()
+	m.Lock()
+	m.states[key] = state
+	m.Unlock()
+
+	m.persist(key, state) //nolint:errcheck
+	return key
+}
+
+func (m *uploadStatesManager) remove(key string) {
+	m.Lock()
+	_, ok := m.states[key]
+	delete(m.states, key)
+	m.Unlock()
+
+	if ok {
+		dataprovider.DeleteSharedSession(key) //nolint:errcheck
+	}
+}
+
+func (m *uploadStatesManager) isLocal(key string) bool {
+	m.RLock()
+	defer m.RUnlock()
+
+	_, ok := m.states[key]
+	return ok
+}
+
+func (m *uploadStatesManager) persist(key string, state uploadState) error {
+	return dataprovider.AddSharedSession(dataprovider.Session{
+		Key:       key,
+		Data:      state,
+		Type:      dataprovider.SessionTypeUploadState,
+		Timestamp: util.GetTimeAsMsSinceEpoch(time.Now()),
+	})
+}
+
+// refresh updates the timestamp for the upload states handled by this instance
+func (m *uploadStatesManager) refresh() {
+	m.RLock()
+	states := make(map[string]uploadState, len(m.states))
+	for k, v := range m.states {
+		states[k] = v
+	}
+	m.RUnlock()
+
+	for key, state := range states {
+		m.persist(key, state) //nolint:errcheck
+	}
+}
+
+// check refreshes the local upload states and recovers the interrupted uploads.
+// The states that cannot be recovered within the configured retention are
+// removed together with their temporary files
+func (m *uploadStatesManager) check() {
+	m.refresh()
+
+	sessions, err := dataprovider.GetSharedSessions(dataprovider.SessionTypeUploadState)
+	if err != nil {
+		logger.Warn(logSender, "", "unable to get the upload states: %v", err)
+		return
+	}
+	now := time.Now()
+	staleLimit := util.GetTimeAsMsSinceEpoch(now.Add(-uploadStateStaleInterval))
+	retentionLimit := util.GetTimeAsMsSinceEpoch(now.Add(-time.Duration(Config.UploadStateRetention) * time.Hour))
+
+	for _, session := range sessions {
+		if session.Timestamp > staleLimit || m.isLocal(session.Key) {
+			continue
+		}
+		state, err := decodeUploadState(session.Data)
+		if err != nil {
+			logger.Warn(logSender, "", "unable to decode upload state %q: %v", session.Key, err)
+			dataprovider.DeleteSharedSession(session.Key) //nolint:errcheck
+			continue
+		}
+		if state.CreatedAt < retentionLimit {
+			err = state.cleanup()
+			logger.Info(logSender, "", "removing abandoned upload for user %q, path %q, temporary file %q, err: %v",
+				state.Username, state.VirtualPath, state.TempPath, err)
+			dataprovider.DeleteSharedSession(session.Key) //nolint:errcheck
+			continue
+		}
+		if err := state.recover(); err != nil {
+			logger.Warn(logSender, "", "unable to recover the upload for user %q, path %q: %v",
+				state.Username, state.VirtualPath, err)
+			continue
+		}
+		dataprovider.DeleteSharedSession(session.Key) //nolint:errcheck
+	}
+}
+
+func decodeUploadState(data any) (uploadState, error) {
+	var state uploadState
+	val, ok := data.([]byte)
+	if !ok {
+		return state, fmt.Errorf("invalid upload state data type %T", data)
+	}
+	err := json.Unmarshal(val, &state)
+	if err == nil && (state.Username == "" || state.TempPath == "" || state.FsPath == "") {
+		err = errors.New("incomplete upload state")
+	}
+	return state, err
+}
+
+// addUploadState persists the state for atomic uploads to filesystems supporting resume
+func (t *BaseTransfer) addUploadState() {
+	if Config.UploadStateRetention <= 0 || !t.isAtomicUpload() || !t.Fs.IsUploadResumeSupported() {
+		return
+	}
+	t.uploadStateKey = uploadStates.add(uploadState{
+		Username:    t.Connection.User.Username,
+		VirtualPath: t.requestPath,
+		FsPath:      t.fsPath,
+		TempPath:    t.effectiveFsPath,
+		InitialSize: t.InitialSize,
+		IsNewFile:   t.isNewFile,
+		CreatedAt:   util.GetTimeAsMsSinceEpoch(t.start),
+	})
+}
+
+func (t *BaseTransfer) removeUploadState() {
+	if t.uploadStateKey != "" {
+		uploadStates.remove(t.uploadStateKey)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/wneessen/go-mail"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/smtp"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	usageReportDir        = "/reports"
+	usageReportPrefix     = "usage_report_"
+	usageReportTimeFormat = "20060102T150405Z"
+)
+
+// usageReportRow defines the usage statistics for a user or, if the report
+// is grouped by role, the aggregated statistics for the users of a role
+type usageReportRow struct {
+	name            string
+	role            string
+	status          int
+	users           int
+	quotaSize       int64
+	usedQuotaSize   int64
+	quotaFiles      int
+	usedQuotaFiles  int
+	uploadedBytes   int64
+	downloadedBytes int64
+	lastLogin       int64
+}
+
+func getUsageReportRows(users []dataprovider.User, groupBy int) []usageReportRow {
+	var rows []usageReportRow
+	if groupBy == dataprovider.UsageReportGroupByRole {
+		roles := make(map[string]*usageReportRow)
+		for _, user := range users {
+			row, ok := roles[user.Role]
+			if !ok {
+				row = &usageReportRow{
+					name: user.Role,
+				}
+				roles[user.Role] = row
+			}
+			row.users++
+			row.usedQuotaSize += user.UsedQuotaSize
+			row.usedQuotaFiles += user.UsedQuotaFiles
+			row.uploadedBytes += user.UsedUploadDataTransfer
+			row.downloadedBytes += user.UsedDownloadDataTransfer
+		}
+		for _, row := range roles {
+			rows = append(rows, *row)
+		}
+	} else {
+		for _, user := range users {
+			rows = append(rows, usageReportRow{
+				name:            user.Username,
+				role:            user.Role,
+				status:          user.Status,
+				users:           1,
+				quotaSize:       user.QuotaSize,
+				usedQuotaSize:   user.UsedQuotaSize,
+				quotaFiles:      user.QuotaFiles,
+				usedQuotaFiles:  user.UsedQuotaFiles,
+				uploadedBytes:   user.UsedUploadDataTransfer,
+				downloadedBytes: user.UsedDownloadDataTransfer,
+				lastLogin:       user.LastLogin,
+			})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].name < rows[j].name
+	})
+	return rows
+}
+
+func getCSVUsageReport(rows []usageReportRow, groupBy int) ([]byte, error) {
+	var b bytes.Buffer
+	csvWriter := csv.NewWriter(&b)
+	var err error
+	if groupBy == dataprovider.UsageReportGroupByRole {
+		err = csvWriter.Write([]string{"role", "users", "used size (bytes)", "used files", "uploaded (bytes)",
+			"downloaded (bytes)"})
+	} else {
+		err = csvWriter.Write([]string{"username", "role", "status", "quota size (bytes)", "used size (bytes)",
+			"quota files", "used files", "uploaded (bytes)", "downloaded (bytes)", "last login"})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if groupBy == dataprovider.UsageReportGroupByRole {
+			err = csvWriter.Write([]string{row.name, strconv.Itoa(row.users), strconv.FormatInt(row.usedQuotaSize, 10),
+				strconv.Itoa(row.usedQuotaFiles), strconv.FormatInt(row.uploadedBytes, 10),
+				strconv.FormatInt(row.downloadedBytes, 10)})
+		} else {
+			var lastLogin string
+			if row.lastLogin > 0 {
+				lastLogin = util.GetTimeFromMsecSinceEpoch(row.lastLogin).UTC().Format(time.RFC3339)
+			}
+			err = csvWriter.Write([]string{row.name, row.role, strconv.Itoa(row.status),
+				strconv.FormatInt(row.quotaSize, 10), strconv.FormatInt(row.usedQuotaSize, 10),
+				strconv.Itoa(row.quotaFiles), strconv.Itoa(row.usedQuotaFiles), strconv.FormatInt(row.uploadedBytes, 10),
+				strconv.FormatInt(row.downloadedBytes, 10), lastLogin})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	csvWriter.Flush()
+	err = csvWriter.Error()
+	return b.Bytes(), err
+}
+
+func getUsageReportUsers(groupBy int, conditions dataprovider.ConditionOptions, params *EventParams,
+) ([]dataprovider.User, error) {
+	users, err := params.getUsers()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get users: %w", err)
+	}
+	var result []dataprovider.User
+	for _, user := range users {
+		// if sender is set, the conditions have already been evaluated
+		if params.sender == "" {
+			if !checkUserConditionOptions(&user, &conditions) {
+				eventManagerLog(logger.LevelDebug, "skipping usage report for user %q, condition options don't match",
+					user.Username)
+				continue
+			}
+		}
+		if groupBy == dataprovider.UsageReportGroupByUser {
+			// quota limits could be inherited from groups
+			if err := user.LoadAndApplyGroupSettings(); err != nil {
+				eventManagerLog(logger.LevelError, "unable to apply group settings for user %q: %v", user.Username, err)
+			}
+		}
+		result = append(result, user)
+	}
+	return result, nil
+}
+
+func uploadUsageReport(config dataprovider.EventActionUsageReportConfig, name string, data []byte) error {
+	folder, err := dataprovider.GetFolderByName(config.Folder)
+	if err != nil {
+		return fmt.Errorf("unable to get report folder %q: %w", config.Folder, err)
+	}
+	connectionID := fmt.Sprintf("%s_%s", protocolEventAction, xid.New().String())
+	user := getFolderTargetUser(folder, usageReportDir)
+	err = user.CheckFsRoot(connectionID)
+	defer user.CloseFs() //nolint:errcheck
+	if err != nil {
+		return fmt.Errorf("unable to check root fs for report folder %q: %w", folder.Name, err)
+	}
+	conn := NewBaseConnection(connectionID, protocolEventAction, "", "", user)
+	virtualPath := path.Join(usageReportDir, config.Path, name)
+	if err := conn.CheckParentDirs(path.Dir(virtualPath)); err != nil {
+		return fmt.Errorf("unable to create parent directories for %q: %w", virtualPath, err)
+	}
+	writer, numFiles, truncatedSize, cancelFn, err := getFileWriter(conn, virtualPath, -1)
+	if err != nil {
+		return fmt.Errorf("unable to create %q: %w", virtualPath, err)
+	}
+	defer cancelFn()
+
+	n, err := writer.Write(data)
+	if errClose := writer.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return fmt.Errorf("unable to upload usage report %q: %w", virtualPath, err)
+	}
+	updateUserQuotaAfterFileWrite(conn, virtualPath, numFiles, int64(n)-truncatedSize)
+	return nil
+}
+
+func sendUsageReport(recipients []string, name string, data []byte, now time.Time) error {
+	file := &mail.File{
+		Name:   name,
+		Header: make(map[string][]string),
+		Writer: func(w io.Writer) (int64, error) {
+			n, err := w.Write(data)
+			return int64(n), err
+		},
+	}
+	subject := fmt.Sprintf("Usage report %s", now.UTC().Format(time.RFC3339))
+	body := "Usage report attached."
+	return smtp.SendEmail(recipients, nil, subject, body, smtp.EmailContentTypeTextPlain, file)
+}
+
+func executeUsageReportRuleAction(config dataprovider.EventActionUsageReportConfig,
+	conditions dataprovider.ConditionOptions, params *EventParams,
+) error {
+	users, err := getUsageReportUsers(config.GroupBy, conditions, params)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		eventManagerLog(logger.LevelError, "no user matches the usage report conditions")
+		return errors.New("no user matches the usage report conditions")
+	}
+	data, err := getCSVUsageReport(getUsageReportRows(users, config.GroupBy), config.GroupBy)
+	if err != nil {
+		return fmt.Errorf("unable to generate usage report: %w", err)
+	}
+	now := time.Now()
+	name := usageReportPrefix + now.UTC().Format(usageReportTimeFormat) + ".csv"
+	var failures []string
+	if len(config.Recipients) > 0 {
+		startTime := time.Now()
+		err := sendUsageReport(config.Recipients, name, data, now)
+		eventManagerLog(logger.LevelDebug, "usage report for %d users sent via email, elapsed: %s, error: %v",
+			len(users), time.Since(startTime), err)
+		if err != nil {
+			params.AddError(fmt.Errorf("unable to send usage report via email: %w", err))
+			failures = append(failures, "email")
+		}
+	}
+	if config.Folder != "" {
+		startTime := time.Now()
+		err := uploadUsageReport(config, name, data)
+		eventManagerLog(logger.LevelDebug, "usage report %q uploaded to folder %q, elapsed: %s, error: %v",
+			name, config.Folder, time.Since(startTime), err)
+		if err != nil {
+			params.AddError(fmt.Errorf("unable to upload usage report: %w", err))
+			failures = append(failures, "upload")
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("usage report failed: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,413 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/rs/xid"
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+const (
+	userArchiveDir        = "/archive"
+	userArchiveTimeFormat = "20060102T150405Z"
+)
+
+var activeUserArchives = userArchivesLocker{
+	users: make(map[string]bool),
+}
+
+type userArchivesLocker struct {
+	sync.Mutex
+	users map[string]bool
+}
+
+func (l *userArchivesLocker) add(username string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.users[username] {
+		return false
+	}
+	l.users[username] = true
+	return true
+}
+
+func (l *userArchivesLocker) remove(username string) {
+	l.Lock()
+	defer l.Unlock()
+
+	delete(l.users, username)
+}
+
+// countingWriter counts the bytes written to the wrapped writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// userArchiver writes the home directory of a user, as seen using the
+// source connection, to a tar archive
+type userArchiver struct {
+	conn  *BaseConnection
+	tw    *tar.Writer
+	files int
+}
+
+func (a *userArchiver) walk(virtualPath string) error {
+	contents, err := a.conn.ListDir(virtualPath)
+	if err != nil {
+		return fmt.Errorf("unable to list directory %q: %w", virtualPath, err)
+	}
+	for _, info := range contents {
+		itemPath := path.Join(virtualPath, info.Name())
+		entryName := strings.TrimPrefix(itemPath, "/")
+		if info.IsDir() {
+			err = a.tw.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     entryName + "/",
+				Mode:     int64(info.Mode().Perm()),
+				ModTime:  info.ModTime(),
+			})
+			if err != nil {
+				return fmt.Errorf("unable to add archive entry %q: %w", itemPath, err)
+			}
+			if err := a.walk(itemPath); err != nil {
+				return err
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			eventManagerLog(logger.LevelInfo, "skipping archive entry for non regular file %q", itemPath)
+			continue
+		}
+		if err := a.addFile(itemPath, entryName, info.Size(), info.Mode().Perm(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *userArchiver) addFile(virtualPath, entryName string, size int64, mode os.FileMode, modTime time.Time) error {
+	reader, cancelFn, err := getFileReader(a.conn, virtualPath)
+	if err != nil {
+		return fmt.Errorf("unable to open %q: %w", virtualPath, err)
+	}
+	defer cancelFn()
+	defer reader.Close()
+
+	err = a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     entryName,
+		Size:     size,
+		Mode:     int64(mode),
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to add archive entry %q: %w", virtualPath, err)
+	}
+	// the user is disabled and disconnected, a size change means a concurrent
+	// modification outside SFTPGo, the archive is not valid in this case
+	if _, err := io.CopyN(a.tw, reader, size); err != nil {
+		return fmt.Errorf("unable to archive %q: %w", virtualPath, err)
+	}
+	a.files++
+	return nil
+}
+
+// getFolderTargetUser returns a system user with the specified folder mounted
+// on virtualPath, it allows to read and write files inside the folder using
+// the configured storage backend
+func getFolderTargetUser(folder vfs.BaseVirtualFolder, virtualPath string) dataprovider.User {
+	return dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Status:   1,
+			Username: dataprovider.ActionExecutorSystem,
+			HomeDir:  dataprovider.GetBackupsPath(),
+			Permissions: map[string][]string{
+				"/": {dataprovider.PermAny},
+			},
+		},
+		VirtualFolders: []vfs.VirtualFolder{
+			{
+				BaseVirtualFolder: folder,
+				VirtualPath:       virtualPath,
+			},
+		},
+	}
+}
+
+// isUserArchiveRequired returns true if the user is expired and its home
+// directory was not archived after the expiration
+func isUserArchiveRequired(user *dataprovider.User) bool {
+	if user.ExpirationDate == 0 {
+		return false
+	}
+	if util.GetTimeFromMsecSinceEpoch(user.ExpirationDate).After(time.Now()) {
+		return false
+	}
+	return user.Filters.Archive == nil || user.Filters.Archive.ArchivedAt < user.ExpirationDate
+}
+
+// isInactiveUserArchiveRequired returns true if the home directory of an
+// inactive user was not archived after its last login
+func isInactiveUserArchiveRequired(user *dataprovider.User) bool {
+	return user.Filters.Archive == nil || user.Filters.Archive.ArchivedAt < user.LastLogin
+}
+
+// disableUser disables the specified user and closes its active connections
+func disableUser(username string) error {
+	user, err := dataprovider.UserExists(username, "")
+	if err != nil {
+		return err
+	}
+	if user.Status != 0 {
+		user.Status = 0
+		if err := dataprovider.UpdateUser(&user, dataprovider.ActionExecutorSystem, "", ""); err != nil {
+			return fmt.Errorf("unable to disable user: %w", err)
+		}
+	}
+	for _, stat := range Connections.GetStats("") {
+		if stat.Username == username {
+			Connections.Close(stat.ConnectionID, "")
+		}
+	}
+	return nil
+}
+
+// ArchiveExpiredUser disables the specified expired user, archives its home
+// directory as a tar.zst file inside the configured virtual folder, records the
+// archive location in the user's details and then removes the archived files
+// and resets the used quota
+func ArchiveExpiredUser(username string, config dataprovider.EventActionUserArchiveConfig) (dataprovider.UserArchive, error) {
+	return archiveUser(username, config, false)
+}
+
+// archiveUser archives the specified user. Inactive users are archived even
+// if they are not expired
+func archiveUser(username string, config dataprovider.EventActionUserArchiveConfig, inactive bool,
+) (dataprovider.UserArchive, error) {
+	var archive dataprovider.UserArchive
+
+	if !activeUserArchives.add(username) {
+		return archive, fmt.Errorf("an archive is already in progress for user %q", username)
+	}
+	defer activeUserArchives.remove(username)
+
+	user, err := dataprovider.UserExists(username, "")
+	if err != nil {
+		return archive, err
+	}
+	if inactive {
+		if !isInactiveUserArchiveRequired(&user) {
+			return archive, util.NewValidationError(fmt.Sprintf("user %q is already archived", username))
+		}
+	} else if !isUserArchiveRequired(&user) {
+		return archive, util.NewValidationError(fmt.Sprintf("user %q is not expired or it is already archived", username))
+	}
+	folder, err := dataprovider.GetFolderByName(config.Folder)
+	if err != nil {
+		return archive, fmt.Errorf("unable to get archive folder %q: %w", config.Folder, err)
+	}
+	if err := disableUser(username); err != nil {
+		return archive, err
+	}
+	startTime := time.Now()
+	archive, err = writeUserArchive(user, folder, config.Path)
+	eventManagerLog(logger.LevelInfo, "archive for user %q completed, files: %d, archive size: %d, elapsed: %s, error: %v",
+		username, archive.Files, archive.Size, time.Since(startTime), err)
+	if err != nil {
+		return archive, err
+	}
+	// reload the user, it was disabled in the meantime
+	user, err = dataprovider.UserExists(username, "")
+	if err != nil {
+		return archive, err
+	}
+	user.Filters.Archive = &archive
+	if err := dataprovider.UpdateUser(&user, dataprovider.ActionExecutorSystem, "", ""); err != nil {
+		return archive, fmt.Errorf("unable to save the archive details: %w", err)
+	}
+	if err := removeArchivedUserFiles(user); err != nil {
+		return archive, err
+	}
+	return archive, nil
+}
+
+func writeUserArchive(user dataprovider.User, folder vfs.BaseVirtualFolder, archiveDir string) (dataprovider.UserArchive, error) {
+	var archive dataprovider.UserArchive
+
+	sourceUser, err := getUserForEventAction(user)
+	if err != nil {
+		return archive, err
+	}
+	// virtual folders are not archived, they could be shared with other users
+	sourceUser.VirtualFolders = nil
+	connectionID := fmt.Sprintf("%s_%s", protocolEventAction, xid.New().String())
+	err = sourceUser.CheckFsRoot(connectionID)
+	defer sourceUser.CloseFs() //nolint:errcheck
+	if err != nil {
+		return archive, fmt.Errorf("unable to check root fs for user %q: %w", user.Username, err)
+	}
+	targetUser := getFolderTargetUser(folder, userArchiveDir)
+	err = targetUser.CheckFsRoot(connectionID)
+	defer targetUser.CloseFs() //nolint:errcheck
+	if err != nil {
+		return archive, fmt.Errorf("unable to check root fs for archive folder %q: %w", folder.Name, err)
+	}
+	targetConn := NewBaseConnection(connectionID, protocolEventAction, "", "", targetUser)
+	now := time.Now()
+	archive.Folder = folder.Name
+	archive.Path = path.Join(util.CleanPath(archiveDir), user.Username,
+		fmt.Sprintf("%s_%s.tar.zst", user.Username, now.UTC().Format(userArchiveTimeFormat)))
+	archive.ArchivedAt = util.GetTimeAsMsSinceEpoch(now)
+	archivePath := path.Join(userArchiveDir, archive.Path)
+	if err := targetConn.CheckParentDirs(path.Dir(archivePath)); err != nil {
+		return archive, fmt.Errorf("unable to create parent directories for %q: %w", archivePath, err)
+	}
+	writer, numFiles, truncatedSize, cancelFn, err := getFileWriter(targetConn, archivePath, -1)
+	if err != nil {
+		return archive, fmt.Errorf("unable to create %q: %w", archivePath, err)
+	}
+	defer cancelFn()
+
+	cw := &countingWriter{w: writer}
+	archiver := &userArchiver{
+		conn: NewBaseConnection(connectionID, protocolEventAction, "", "", sourceUser),
+	}
+	err = archiveUserHomeDir(archiver, cw)
+	if errClose := writer.Close(); err == nil {
+		err = errClose
+	}
+	archive.Files = archiver.files
+	archive.Size = cw.n
+	if err != nil {
+		fs, fsPath, errFs := targetConn.GetFsAndResolvedPath(archivePath)
+		if errFs == nil {
+			errRemove := fs.Remove(fsPath, false)
+			eventManagerLog(logger.LevelDebug, "removing partial archive %q, result: %v", archivePath, errRemove)
+		}
+		return archive, fmt.Errorf("unable to archive the home directory for user %q: %w", user.Username, err)
+	}
+	updateUserQuotaAfterFileWrite(targetConn, archivePath, numFiles, cw.n-truncatedSize)
+	return archive, nil
+}
+
+func archiveUserHomeDir(archiver *userArchiver, w io.Writer) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	archiver.tw = tar.NewWriter(zw)
+	err = archiver.walk("/")
+	if errClose := archiver.tw.Close(); err == nil {
+		err = errClose
+	}
+	if errClose := zw.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+func removeArchivedUserFiles(user dataprovider.User) error {
+	sourceUser, err := getUserForEventAction(user)
+	if err != nil {
+		return err
+	}
+	sourceUser.VirtualFolders = nil
+	connectionID := fmt.Sprintf("%s_%s", protocolEventAction, xid.New().String())
+	err = sourceUser.CheckFsRoot(connectionID)
+	defer sourceUser.CloseFs() //nolint:errcheck
+	if err != nil {
+		return fmt.Errorf("unable to check root fs for user %q: %w", user.Username, err)
+	}
+	conn := NewBaseConnection(connectionID, protocolEventAction, "", "", sourceUser)
+	contents, err := conn.ListDir("/")
+	if err != nil {
+		return fmt.Errorf("unable to list the home directory for user %q: %w", user.Username, err)
+	}
+	var errs []error
+	for _, info := range contents {
+		if err := conn.RemoveAll(path.Join("/", info.Name())); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := dataprovider.UpdateUserQuota(&user, 0, 0, true); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("unable to free the quota for user %q: %w", user.Username, errors.Join(errs...))
+	}
+	return nil
+}
+
+func executeUserArchiveRuleAction(config dataprovider.EventActionUserArchiveConfig,
+	conditions dataprovider.ConditionOptions, params *EventParams,
+) error {
+	users, err := params.getUsers()
+	if err != nil {
+		return fmt.Errorf("unable to get users: %w", err)
+	}
+	// users matched by an inactivity rule are archived even if not expired
+	inactive := params.Event == userInactivityEventName
+	var failures []string
+	for _, user := range users {
+		// if sender is set, the conditions have already been evaluated
+		if params.sender == "" {
+			if !checkUserConditionOptions(&user, &conditions) {
+				eventManagerLog(logger.LevelDebug, "skipping archive for user %q, condition options don't match",
+					user.Username)
+				continue
+			}
+		}
+		if inactive {
+			if !isInactiveUserArchiveRequired(&user) {
+				continue
+			}
+		} else if !isUserArchiveRequired(&user) {
+			continue
+		}
+		if _, err := archiveUser(user.Username, config, inactive); err != nil {
+			eventManagerLog(logger.LevelError, "unable to archive user %q: %v", user.Username, err)
+			params.AddError(fmt.Errorf("unable to archive user %q: %w", user.Username, err))
+			failures = append(failures, user.Username)
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("archive failed for users: %s", strings.Join(failures, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	userInactivityEventName = "User inactivity"
+)
+
+// getUserInactivityDays returns the number of days since the last login.
+// The creation date is used for users who never logged in
+func getUserInactivityDays(user *dataprovider.User, now time.Time) int {
+	lastActivity := user.LastLogin
+	if lastActivity == 0 {
+		lastActivity = user.CreatedAt
+	}
+	if lastActivity == 0 {
+		return 0
+	}
+	return int(now.Sub(util.GetTimeFromMsecSinceEpoch(lastActivity)).Hours() / 24)
+}
+
+// executeUserInactivityRule executes the rule actions for each enabled user
+// with no login for at least the configured number of days
+func executeUserInactivityRule(rule dataprovider.EventRule) {
+	dump, err := dataprovider.DumpData([]string{dataprovider.DumpScopeUsers})
+	if err != nil {
+		eventManagerLog(logger.LevelError, "unable to get users for inactivity rule %q: %v", rule.Name, err)
+		return
+	}
+	now := time.Now()
+	matched := 0
+	for _, user := range dump.Users {
+		if user.Status != 1 {
+			continue
+		}
+		if !checkUserConditionOptions(&user, &rule.Conditions.Options) {
+			continue
+		}
+		days := getUserInactivityDays(&user, now)
+		if days < rule.Conditions.InactivityDays {
+			continue
+		}
+		matched++
+		eventManagerLog(logger.LevelDebug, "user %q inactive for %d days, rule %q", user.Username, days, rule.Name)
+		params := EventParams{
+			Name:           user.Username,
+			ObjectName:     user.Username,
+			ObjectType:     quotaObjectUser,
+			Event:          userInactivityEventName,
+			Status:         1,
+			Email:          user.Email,
+			Role:           user.Role,
+			Attributes:     user.Attributes,
+			InactivityDays: days,
+			Object:         &user,
+			Timestamp:      now.UnixNano(),
+			sender:         user.Username,
+		}
+		executeAsyncRulesActions([]dataprovider.EventRule{rule}, params)
+	}
+	eventManagerLog(logger.LevelDebug, "inactivity rule %q executed, inactive users: %d", rule.Name, matched)
+}
+
+func executeUserDisableRuleAction(conditions dataprovider.ConditionOptions, params *EventParams) error {
+	users, err := params.getUsers()
+	if err != nil {
+		return fmt.Errorf("unable to get users: %w", err)
+	}
+	var failures []string
+	executed := 0
+	for _, user := range users {
+		// if sender is set, the conditions have already been evaluated
+		if params.sender == "" {
+			if !checkUserConditionOptions(&user, &conditions) {
+				eventManagerLog(logger.LevelDebug, "skipping disable for user %q, condition options don't match",
+					user.Username)
+				continue
+			}
+		}
+		executed++
+		if err := disableUser(user.Username); err != nil {
+			eventManagerLog(logger.LevelError, "unable to disable user %q: %v", user.Username, err)
+			params.AddError(fmt.Errorf("unable to disable user %q: %w", user.Username, err))
+			failures = append(failures, user.Username)
+			continue
+		}
+		eventManagerLog(logger.LevelDebug, "user %q disabled", user.Username)
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("disable failed for users: %s", strings.Join(failures, ", "))
+	}
+	if executed == 0 {
+		eventManagerLog(logger.LevelError, "no user disabled")
+		return errors.New("no user disabled")
+	}
+	return nil
+}
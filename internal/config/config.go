@@ -37,6 +37,7 @@ import (
 	"github.com/drakkan/sftpgo/v2/internal/logger"
 	"github.com/drakkan/sftpgo/v2/internal/mfa"
 	"github.com/drakkan/sftpgo/v2/internal/plugin"
+	"github.com/drakkan/sftpgo/v2/internal/s3gw"
 	"github.com/drakkan/sftpgo/v2/internal/sftpd"
 	"github.com/drakkan/sftpgo/v2/internal/smtp"
 	"github.com/drakkan/sftpgo/v2/internal/telemetry"
@@ -65,6 +66,7 @@ var (
 		Address:          "",
 		Port:             2022,
 		ApplyProxyConfig: true,
+		ReadOnly:         false,
 	}
 	defaultFTPDBinding = ftpd.Binding{
 		Address:                    "",
@@ -82,6 +84,7 @@ var (
 		PassiveConnectionsSecurity: 0,
 		ActiveConnectionsSecurity:  0,
 		Debug:                      false,
+		ReadOnly:                   false,
 	}
 	defaultWebDAVDBinding = webdavd.Binding{
 		Address:              "",
@@ -94,10 +97,24 @@ var (
 		TLSCipherSuites:      nil,
 		Protocols:            nil,
 		Prefix:               "",
+		UserPrefixes:         nil,
 		ProxyAllowed:         nil,
 		ClientIPProxyHeader:  "",
 		ClientIPHeaderDepth:  0,
 		DisableWWWAuthHeader: false,
+		ReadOnly:             false,
+	}
+	defaultS3GWBinding = s3gw.Binding{
+		Address:             "",
+		Port:                0,
+		EnableHTTPS:         false,
+		CertificateFile:     "",
+		CertificateKeyFile:  "",
+		MinTLSVersion:       12,
+		TLSCipherSuites:     nil,
+		ProxyAllowed:        nil,
+		ClientIPProxyHeader: "",
+		ClientIPHeaderDepth: 0,
 	}
 	defaultHTTPDBinding = httpd.Binding{
 		Address:             "",
@@ -119,18 +136,21 @@ var (
 		HideLoginURL:        0,
 		RenderOpenAPI:       true,
 		OIDC: httpd.OIDC{
-			ClientID:                   "",
-			ClientSecret:               "",
-			ClientSecretFile:           "",
-			ConfigURL:                  "",
-			RedirectBaseURL:            "",
-			UsernameField:              "",
-			RoleField:                  "",
-			ImplicitRoles:              false,
-			Scopes:                     []string{"openid", "profile", "email"},
-			CustomFields:               []string{},
-			InsecureSkipSignatureCheck: false,
-			Debug:                      false,
+			ClientID:                       "",
+			ClientSecret:                   "",
+			ClientSecretFile:               "",
+			ConfigURL:                      "",
+			RedirectBaseURL:                "",
+			UsernameField:                  "",
+			RoleField:                      "",
+			ImplicitRoles:                  false,
+			Scopes:                         []string{"openid", "profile", "email"},
+			CustomFields:                   []string{},
+			InsecureSkipSignatureCheck:     false,
+			Debug:                          false,
+			DeviceAuth:                     false,
+			DeviceAuthTokenLifetime:        0,
+			DeviceAuthRefreshTokenLifetime: 0,
 		},
 		Security: httpd.SecurityConf{
 			Enabled:                 false,
@@ -148,7 +168,9 @@ var (
 			PermissionsPolicy:       "",
 			CrossOriginOpenerPolicy: "",
 		},
-		Branding: httpd.Branding{},
+		Branding:    httpd.Branding{},
+		ReadOnly:    false,
+		PublicIndex: httpd.PublicIndex{},
 	}
 	defaultRateLimiter = common.RateLimiterConfig{
 		Average:                0,
@@ -173,6 +195,7 @@ type globalConfig struct {
 	SFTPD           sftpd.Configuration   `json:"sftpd" mapstructure:"sftpd"`
 	FTPD            ftpd.Configuration    `json:"ftpd" mapstructure:"ftpd"`
 	WebDAVD         webdavd.Configuration `json:"webdavd" mapstructure:"webdavd"`
+	S3GW            s3gw.Configuration    `json:"s3gw" mapstructure:"s3gw"`
 	ProviderConf    dataprovider.Config   `json:"data_provider" mapstructure:"data_provider"`
 	HTTPDConfig     httpd.Conf            `json:"httpd" mapstructure:"httpd"`
 	HTTPConfig      httpclient.Config     `json:"http" mapstructure:"http"`
@@ -235,6 +258,51 @@ func Init() {
 			Metadata: common.MetadataConfig{
 				Read: 0,
 			},
+			CertExpiry: common.CertExpiryConfig{
+				TLSCertificates:     0,
+				SSHHostCertificates: 0,
+				UserCertificates:    0,
+			},
+			UploadStateRetention: 0,
+			TrashRetention:       0,
+			TrashDirName:         ".trash",
+			S3MaxUploadMemory:    0,
+			ReadCache: common.ReadCacheConfig{
+				Path:        "",
+				MaxSize:     0,
+				MaxFileSize: 0,
+			},
+			Dedup: common.DedupConfig{
+				Path:        "",
+				MinFileSize: 0,
+			},
+			SFTPFsPool: common.SFTPFsPoolConfig{
+				MaxSessionsPerConnection: 5,
+				MaxConnections:           0,
+				IdleTimeout:              30,
+				HealthCheckInterval:      30,
+			},
+			Snapshots: common.SnapshotsConfig{
+				Path:      "",
+				Retention: 7,
+			},
+			AnonymousAccess: common.AnonymousAccessConfig{
+				TemplateUser: "",
+				FTPUsernames: []string{},
+				SFTPUsername: "",
+				SFTPPassword: "",
+				Folders:      []string{},
+				RateLimiter: common.RateLimiterConfig{
+					Average:                0,
+					Period:                 1000,
+					Burst:                  1,
+					Type:                   2,
+					Protocols:              []string{common.ProtocolSSH, common.ProtocolFTP},
+					GenerateDefenderEvents: false,
+					EntriesSoftLimit:       100,
+					EntriesHardLimit:       150,
+				},
+			},
 		},
 		ACME: acme.Configuration{
 			Email:      "",
@@ -272,6 +340,13 @@ func Init() {
 			KeyboardInteractiveHook:           "",
 			PasswordAuthentication:            true,
 			FolderPrefix:                      "",
+			ByteRangeLocking:                  false,
+			HostCA: sftpd.HostCAConfig{
+				Key:         "",
+				Validity:    30,
+				RenewBefore: 7,
+				Principals:  []string{},
+			},
 		},
 		FTPD: ftpd.Configuration{
 			Bindings:                 []ftpd.Binding{defaultFTPDBinding},
@@ -290,6 +365,11 @@ func Init() {
 			CertificateKeyFile: "",
 			CACertificates:     []string{},
 			CARevocationLists:  []string{},
+			RevocationCheck: common.RevocationCheckConfig{
+				OCSPEnabled: false,
+				HardFail:    false,
+				CacheTTL:    60,
+			},
 		},
 		WebDAVD: webdavd.Configuration{
 			Bindings:           []webdavd.Binding{defaultWebDAVDBinding},
@@ -297,6 +377,11 @@ func Init() {
 			CertificateKeyFile: "",
 			CACertificates:     []string{},
 			CARevocationLists:  []string{},
+			RevocationCheck: common.RevocationCheckConfig{
+				OCSPEnabled: false,
+				HardFail:    false,
+				CacheTTL:    60,
+			},
 			Cors: webdavd.CorsConfig{
 				Enabled:              false,
 				AllowedOrigins:       []string{},
@@ -320,6 +405,24 @@ func Init() {
 					CustomMappings: nil,
 				},
 			},
+			BearerAuth: webdavd.BearerAuthConfig{
+				IssuerURL:     "",
+				Audience:      "",
+				UsernameField: "",
+				CookieName:    "",
+			},
+			DeadProperties: false,
+			InfiniteDepth: webdavd.InfiniteDepthConfig{
+				Enabled:    false,
+				MaxResults: 10000,
+			},
+		},
+		S3GW: s3gw.Configuration{
+			Bindings:                  []s3gw.Binding{defaultS3GWBinding},
+			CertificateFile:           "",
+			CertificateKeyFile:        "",
+			Region:                    "",
+			MultipartUploadExpiration: 24,
 		},
 		ProviderConf: dataprovider.Config{
 			Driver:             "sqlite",
@@ -340,6 +443,7 @@ func Init() {
 			ClientKey:          "",
 			TrackQuota:         2,
 			PoolSize:           0,
+			RedisPersistence:   0,
 			UsersBaseDir:       "",
 			Actions: dataprovider.ObjectsActions{
 				ExecuteOn:  []string{},
@@ -384,6 +488,59 @@ func Init() {
 				Proto: "http",
 			},
 			BackupsPath: "backups",
+			AuditTrail: dataprovider.AuditTrailConfig{
+				Enabled:       false,
+				RetentionDays: 0,
+			},
+			Reconciler: dataprovider.ReconcilerConfig{
+				SourceDir: "",
+				GitURL:    "",
+				GitBranch: "",
+				Interval:  5,
+				Mode:      0,
+			},
+			LDAPSync: dataprovider.LDAPSyncConfig{
+				URL:               "",
+				StartTLS:          false,
+				SkipTLSVerify:     false,
+				BindDN:            "",
+				BindPassword:      "",
+				BaseDN:            "",
+				UserFilter:        "(objectClass=person)",
+				UsernameAttribute: "uid",
+				EmailAttribute:    "mail",
+				GroupAttribute:    "memberOf",
+				Permissions:       []string{"*"},
+				Mappings:          nil,
+				Interval:          60,
+			},
+			LDAPAuth: dataprovider.LDAPAuthConfig{
+				URL:            "",
+				StartTLS:       false,
+				SkipTLSVerify:  false,
+				BindDN:         "",
+				BindPassword:   "",
+				BaseDN:         "",
+				UserFilter:     "(&(objectClass=person)(uid=%username%))",
+				EmailAttribute: "mail",
+				GroupAttribute: "memberOf",
+				Permissions:    []string{"*"},
+				Mappings:       nil,
+				Protocols:      nil,
+			},
+			ShareAnalytics: dataprovider.ShareAnalyticsConfig{
+				Enabled:       false,
+				RetentionDays: 0,
+				AnonymizeIP:   false,
+			},
+			Webhooks: dataprovider.WebhooksConfig{
+				Enabled:          false,
+				SigningSecret:    "",
+				MaxAttempts:      10,
+				RetryInterval:    30,
+				MaxRetryInterval: 3600,
+				RetentionDays:    7,
+			},
 		},
 		HTTPDConfig: httpd.Conf{
 			Bindings:              []httpd.Binding{defaultHTTPDBinding},
@@ -416,6 +573,36 @@ func Init() {
 				InstallationCodeHint: defaultInstallCodeHint,
 			},
 			HideSupportLink: false,
+			ExternalJWT: httpd.ExternalJWT{
+				Issuer:       "",
+				JWKSURL:      "",
+				Audience:     "",
+				RoleField:    "",
+				RoleMappings: []httpd.ExternalJWTRoleMapping{},
+			},
+			Thumbnails: httpd.ThumbnailsConfig{
+				Enabled:        false,
+				CachePath:      "thumbnails",
+				Size:           256,
+				MaxFileSize:    20,
+				CacheRetention: 720,
+				FFmpegPath:     "",
+			},
+			Search: common.SearchConfig{
+				MaxEntries:         100000,
+				ContentMaxFileSize: 1024,
+				IndexTTL:           0,
+			},
+			ChunkedUploads: httpd.ChunkedUploadsConfig{
+				Enabled:           false,
+				StoragePath:       "chunked_uploads",
+				ChunkSize:         8,
+				MaxParallelChunks: 3,
+				Retention:         24,
+			},
+			GraphQL: httpd.GraphQLConfig{
+				Enabled: false,
+			},
 		},
 		HTTPConfig: httpclient.Config{
 			Timeout:        20,
@@ -441,6 +628,19 @@ func Init() {
 		},
 		MFAConfig: mfa.Config{
 			TOTP: []mfa.TOTPConfig{defaultTOTP},
+			WebAuthn: mfa.WebAuthnConfig{
+				Enabled:       false,
+				RPID:          "",
+				RPDisplayName: "SFTPGo",
+				Origins:       []string{},
+			},
+			Push: mfa.PushConfig{
+				APIHost:        "",
+				IntegrationKey: "",
+				SecretKey:      "",
+				Timeout:        60,
+				FallbackToTOTP: false,
+			},
 		},
 		TelemetryConfig: telemetry.Conf{
 			BindPort:           0,
@@ -516,6 +716,16 @@ func SetWebDAVDConfig(config webdavd.Configuration) {
 	globalConf.WebDAVD = config
 }
 
+// GetS3GWConfig returns the configuration for the S3 compatible gateway
+func GetS3GWConfig() s3gw.Configuration {
+	return globalConf.S3GW
+}
+
+// SetS3GWConfig sets the configuration for the S3 compatible gateway
+func SetS3GWConfig(config s3gw.Configuration) {
+	globalConf.S3GW = config
+}
+
 // GetHTTPDConfig returns the configuration for the HTTP server
 func GetHTTPDConfig() httpd.Conf {
 	return globalConf.HTTPDConfig
@@ -592,7 +802,7 @@ func GetACMEConfig() acme.Configuration {
 }
 
 // HasServicesToStart returns true if the config defines at least a service to start.
-// Supported services are SFTP, FTP and WebDAV
+// Supported services are SFTP, FTP, WebDAV and the S3 gateway
 func HasServicesToStart() bool {
 	if globalConf.SFTPD.ShouldBind() {
 		return true
@@ -603,6 +813,9 @@ func HasServicesToStart() bool {
 	if globalConf.WebDAVD.ShouldBind() {
 		return true
 	}
+	if globalConf.S3GW.ShouldBind() {
+		return true
+	}
 	if globalConf.HTTPDConfig.ShouldBind() {
 		return true
 	}
@@ -627,6 +840,8 @@ func getRedactedGlobalConf() globalConfig {
 	conf.HTTPDConfig.SigningPassphrase = getRedactedPassword(conf.HTTPDConfig.SigningPassphrase)
 	conf.HTTPDConfig.Setup.InstallationCode = getRedactedPassword(conf.HTTPDConfig.Setup.InstallationCode)
 	conf.ProviderConf.Password = getRedactedPassword(conf.ProviderConf.Password)
+	conf.ProviderConf.LDAPSync.BindPassword = getRedactedPassword(conf.ProviderConf.LDAPSync.BindPassword)
+	conf.ProviderConf.LDAPAuth.BindPassword = getRedactedPassword(conf.ProviderConf.LDAPAuth.BindPassword)
 	conf.ProviderConf.Actions.Hook = util.GetRedactedURL(conf.ProviderConf.Actions.Hook)
 	conf.ProviderConf.ExternalAuthHook = util.GetRedactedURL(conf.ProviderConf.ExternalAuthHook)
 	conf.ProviderConf.PreLoginHook = util.GetRedactedURL(conf.ProviderConf.PreLoginHook)
@@ -748,6 +963,8 @@ func LoadConfig(configDir, configFile string) error {
 	// viper only supports slice of strings from env vars, so we use our custom method
 	loadBindingsFromEnv()
 	loadWebDAVCacheMappingsFromEnv()
+	loadLDAPSyncMappingsFromEnv()
+	loadLDAPAuthMappingsFromEnv()
 	resetInvalidConfigs()
 	logger.Debug(logSender, "", "config file used: '%q', config loaded: %+v", viper.ConfigFileUsed(), getRedactedGlobalConf())
 	return nil
@@ -801,6 +1018,34 @@ func resetInvalidConfigs() {
 			logger.WarnToConsole("Non-fatal configuration error: %v", warn)
 		}
 	}
+	if globalConf.ProviderConf.AuditTrail.Enabled && !globalConf.ProviderConf.IsAuditTrailSupported() {
+		warn := fmt.Sprintf("audit trail is not supported with data provider %q and will be disabled",
+			globalConf.ProviderConf.Driver)
+		globalConf.ProviderConf.AuditTrail.Enabled = false
+		logger.Warn(logSender, "", "Non-fatal configuration error: %v", warn)
+		logger.WarnToConsole("Non-fatal configuration error: %v", warn)
+	}
+	if globalConf.ProviderConf.ShareAnalytics.Enabled && !globalConf.ProviderConf.IsShareAnalyticsSupported() {
+		warn := fmt.Sprintf("share analytics are not supported with data provider %q and will be disabled",
+			globalConf.ProviderConf.Driver)
+		globalConf.ProviderConf.ShareAnalytics.Enabled = false
+		logger.Warn(logSender, "", "Non-fatal configuration error: %v", warn)
+		logger.WarnToConsole("Non-fatal configuration error: %v", warn)
+	}
+	if globalConf.ProviderConf.Webhooks.Enabled && !globalConf.ProviderConf.IsWebhooksSupported() {
+		warn := fmt.Sprintf("webhooks are not supported with data provider %q and will be disabled",
+			globalConf.ProviderConf.Driver)
+		globalConf.ProviderConf.Webhooks.Enabled = false
+		logger.Warn(logSender, "", "Non-fatal configuration error: %v", warn)
+		logger.WarnToConsole("Non-fatal configuration error: %v", warn)
+	}
+	if globalConf.WebDAVD.DeadProperties && !globalConf.ProviderConf.IsDAVPropertiesSupported() {
+		warn := fmt.Sprintf("WebDAV dead properties are not supported with data provider %q and will be disabled",
+			globalConf.ProviderConf.Driver)
+		globalConf.WebDAVD.DeadProperties = false
+		logger.Warn(logSender, "", "Non-fatal configuration error: %v", warn)
+		logger.WarnToConsole("Non-fatal configuration error: %v", warn)
+	}
 }
 
 func loadBindingsFromEnv() {
@@ -811,6 +1056,7 @@ func loadBindingsFromEnv() {
 		getSFTPDBindindFromEnv(idx)
 		getFTPDBindingFromEnv(idx)
 		getWebDAVDBindingFromEnv(idx)
+		getS3GWBindingFromEnv(idx)
 		getHTTPDBindingFromEnv(idx)
 		getHTTPClientCertificatesFromEnv(idx)
 		getHTTPClientHeadersFromEnv(idx)
@@ -1096,6 +1342,12 @@ func getSFTPDBindindFromEnv(idx int) {
 		isSet = true
 	}
 
+	readOnly, ok := lookupBoolFromEnv(fmt.Sprintf("SFTPGO_SFTPD__BINDINGS__%v__READ_ONLY", idx))
+	if ok {
+		binding.ReadOnly = readOnly
+		isSet = true
+	}
+
 	if isSet {
 		if len(globalConf.SFTPD.Bindings) > idx {
 			globalConf.SFTPD.Bindings[idx] = binding
@@ -1256,6 +1508,12 @@ func getFTPDBindingFromEnv(idx int) {
 		isSet = true
 	}
 
+	readOnly, ok := lookupBoolFromEnv(fmt.Sprintf("SFTPGO_FTPD__BINDINGS__%v__READ_ONLY", idx))
+	if ok {
+		binding.ReadOnly = readOnly
+		isSet = true
+	}
+
 	if getFTPDBindingSecurityFromEnv(idx, &binding) {
 		isSet = true
 	}
@@ -1366,6 +1624,102 @@ func loadWebDAVCacheMappingsFromEnv() []webdavd.CustomMimeMapping {
 	return globalConf.WebDAVD.Cache.MimeTypes.CustomMappings
 }
 
+func loadLDAPSyncMappingsFromEnv() {
+	globalConf.ProviderConf.LDAPSync.Mappings = loadLDAPMappingsFromEnv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS",
+		globalConf.ProviderConf.LDAPSync.Mappings)
+}
+
+func loadLDAPAuthMappingsFromEnv() {
+	globalConf.ProviderConf.LDAPAuth.Mappings = loadLDAPMappingsFromEnv("SFTPGO_DATA_PROVIDER__LDAP_AUTH__MAPPINGS",
+		globalConf.ProviderConf.LDAPAuth.Mappings)
+}
+
+func loadLDAPMappingsFromEnv(prefix string, mappings []dataprovider.LDAPSyncMapping) []dataprovider.LDAPSyncMapping {
+	for idx := 0; idx < 30; idx++ {
+		mapping := dataprovider.LDAPSyncMapping{}
+		if len(mappings) > idx {
+			mapping = mappings[idx]
+		}
+		isSet := false
+
+		group, ok := os.LookupEnv(fmt.Sprintf("%s__%d__GROUP", prefix, idx))
+		if ok {
+			mapping.Group = group
+			isSet = true
+		}
+		homeDir, ok := os.LookupEnv(fmt.Sprintf("%s__%d__HOME_DIR", prefix, idx))
+		if ok {
+			mapping.HomeDir = homeDir
+			isSet = true
+		}
+		quotaSize, ok := lookupIntFromEnv(fmt.Sprintf("%s__%d__QUOTA_SIZE", prefix, idx), 64)
+		if ok {
+			mapping.QuotaSize = quotaSize
+			isSet = true
+		}
+		quotaFiles, ok := lookupIntFromEnv(fmt.Sprintf("%s__%d__QUOTA_FILES", prefix, idx), 0)
+		if ok {
+			mapping.QuotaFiles = int(quotaFiles)
+			isSet = true
+		}
+		primaryGroup, ok := os.LookupEnv(fmt.Sprintf("%s__%d__PRIMARY_GROUP", prefix, idx))
+		if ok {
+			mapping.PrimaryGroup = primaryGroup
+			isSet = true
+		}
+		secondaryGroups, ok := lookupStringListFromEnv(fmt.Sprintf("%s__%d__SECONDARY_GROUPS", prefix, idx))
+		if ok {
+			mapping.SecondaryGroups = secondaryGroups
+			isSet = true
+		}
+
+		if isSet {
+			if len(mappings) > idx {
+				mappings[idx] = mapping
+			} else {
+				mappings = append(mappings, mapping)
+			}
+		}
+	}
+	return mappings
+}
+
+func getWebDAVDUserPrefixesFromEnv(idx int) []webdavd.UserPrefix {
+	var userPrefixes []webdavd.UserPrefix
+	if len(globalConf.WebDAVD.Bindings) > idx {
+		userPrefixes = globalConf.WebDAVD.Bindings[idx].UserPrefixes
+	}
+
+	for subIdx := 0; subIdx < 10; subIdx++ {
+		var userPrefix webdavd.UserPrefix
+		var replace bool
+		if len(globalConf.WebDAVD.Bindings) > idx && len(globalConf.WebDAVD.Bindings[idx].UserPrefixes) > subIdx {
+			userPrefix = globalConf.WebDAVD.Bindings[idx].UserPrefixes[subIdx]
+			replace = true
+		}
+
+		prefix, ok := os.LookupEnv(fmt.Sprintf("SFTPGO_WEBDAVD__BINDINGS__%v__USER_PREFIXES__%v__PREFIX", idx, subIdx))
+		if ok {
+			userPrefix.Prefix = prefix
+		}
+
+		username, ok := os.LookupEnv(fmt.Sprintf("SFTPGO_WEBDAVD__BINDINGS__%v__USER_PREFIXES__%v__USERNAME", idx, subIdx))
+		if ok {
+			userPrefix.Username = username
+		}
+
+		if userPrefix.Prefix != "" {
+			if replace {
+				userPrefixes[subIdx] = userPrefix
+			} else {
+				userPrefixes = append(userPrefixes, userPrefix)
+			}
+		}
+	}
+
+	return userPrefixes
+}
+
 func getWebDAVDBindingFromEnv(idx int) {
 	binding := defaultWebDAVDBinding
 	if len(globalConf.WebDAVD.Bindings) > idx {
@@ -1396,6 +1750,12 @@ func getWebDAVDBindingFromEnv(idx int) {
 		isSet = true
 	}
 
+	userPrefixes := getWebDAVDUserPrefixesFromEnv(idx)
+	if len(userPrefixes) > 0 {
+		binding.UserPrefixes = userPrefixes
+		isSet = true
+	}
+
 	if getWebDAVDBindingProxyConfigsFromEnv(idx, &binding) {
 		isSet = true
 	}
@@ -1406,6 +1766,12 @@ func getWebDAVDBindingFromEnv(idx int) {
 		isSet = true
 	}
 
+	readOnly, ok := lookupBoolFromEnv(fmt.Sprintf("SFTPGO_WEBDAVD__BINDINGS__%v__READ_ONLY", idx))
+	if ok {
+		binding.ReadOnly = readOnly
+		isSet = true
+	}
+
 	if isSet {
 		if len(globalConf.WebDAVD.Bindings) > idx {
 			globalConf.WebDAVD.Bindings[idx] = binding
@@ -1415,6 +1781,103 @@ func getWebDAVDBindingFromEnv(idx int) {
 	}
 }
 
+func getS3GWBindingHTTPSConfigsFromEnv(idx int, binding *s3gw.Binding) bool {
+	isSet := false
+
+	enableHTTPS, ok := lookupBoolFromEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__ENABLE_HTTPS", idx))
+	if ok {
+		binding.EnableHTTPS = enableHTTPS
+		isSet = true
+	}
+
+	certificateFile, ok := os.LookupEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__CERTIFICATE_FILE", idx))
+	if ok {
+		binding.CertificateFile = certificateFile
+		isSet = true
+	}
+
+	certificateKeyFile, ok := os.LookupEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__CERTIFICATE_KEY_FILE", idx))
+	if ok {
+		binding.CertificateKeyFile = certificateKeyFile
+		isSet = true
+	}
+
+	tlsVer, ok := lookupIntFromEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__MIN_TLS_VERSION", idx), 0)
+	if ok {
+		binding.MinTLSVersion = int(tlsVer)
+		isSet = true
+	}
+
+	tlsCiphers, ok := lookupStringListFromEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__TLS_CIPHER_SUITES", idx))
+	if ok {
+		binding.TLSCipherSuites = tlsCiphers
+		isSet = true
+	}
+
+	return isSet
+}
+
+func getS3GWBindingProxyConfigsFromEnv(idx int, binding *s3gw.Binding) bool {
+	isSet := false
+
+	proxyAllowed, ok := lookupStringListFromEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__PROXY_ALLOWED", idx))
+	if ok {
+		binding.ProxyAllowed = proxyAllowed
+		isSet = true
+	}
+
+	clientIPProxyHeader, ok := os.LookupEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__CLIENT_IP_PROXY_HEADER", idx))
+	if ok {
+		binding.ClientIPProxyHeader = clientIPProxyHeader
+		isSet = true
+	}
+
+	clientIPHeaderDepth, ok := lookupIntFromEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__CLIENT_IP_HEADER_DEPTH", idx), 0)
+	if ok {
+		binding.ClientIPHeaderDepth = int(clientIPHeaderDepth)
+		isSet = true
+	}
+
+	return isSet
+}
+
+func getS3GWBindingFromEnv(idx int) {
+	binding := defaultS3GWBinding
+	if len(globalConf.S3GW.Bindings) > idx {
+		binding = globalConf.S3GW.Bindings[idx]
+	}
+
+	isSet := false
+
+	port, ok := lookupIntFromEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__PORT", idx), 0)
+	if ok {
+		binding.Port = int(port)
+		isSet = true
+	}
+
+	address, ok := os.LookupEnv(fmt.Sprintf("SFTPGO_S3GW__BINDINGS__%v__ADDRESS", idx))
+	if ok {
+		binding.Address = address
+		isSet = true
+	}
+
+	if getS3GWBindingHTTPSConfigsFromEnv(idx, &binding) {
+		isSet = true
+	}
+
+	if getS3GWBindingProxyConfigsFromEnv(idx, &binding) {
+		isSet = true
+	}
+
+	if isSet {
+		if len(globalConf.S3GW.Bindings) > idx {
+			globalConf.S3GW.Bindings[idx] = binding
+		} else {
+			globalConf.S3GW.Bindings = append(globalConf.S3GW.Bindings, binding)
+		}
+	}
+}
+
 func getHTTPDSecurityProxyHeadersFromEnv(idx int) []httpd.HTTPSProxyHeader {
 	var httpsProxyHeaders []httpd.HTTPSProxyHeader
 	if len(globalConf.HTTPDConfig.Bindings) > idx {
@@ -1623,6 +2086,24 @@ func getHTTPDOIDCFromEnv(idx int) (httpd.OIDC, bool) {
 		isSet = true
 	}
 
+	deviceAuth, ok := lookupBoolFromEnv(fmt.Sprintf("SFTPGO_HTTPD__BINDINGS__%v__OIDC__DEVICE_AUTH", idx))
+	if ok {
+		result.DeviceAuth = deviceAuth
+		isSet = true
+	}
+
+	tokenLifetime, ok := lookupIntFromEnv(fmt.Sprintf("SFTPGO_HTTPD__BINDINGS__%v__OIDC__DEVICE_AUTH_TOKEN_LIFETIME", idx), 0)
+	if ok {
+		result.DeviceAuthTokenLifetime = int(tokenLifetime)
+		isSet = true
+	}
+
+	refreshTokenLifetime, ok := lookupIntFromEnv(fmt.Sprintf("SFTPGO_HTTPD__BINDINGS__%v__OIDC__DEVICE_AUTH_REFRESH_TOKEN_LIFETIME", idx), 0)
+	if ok {
+		result.DeviceAuthRefreshTokenLifetime = int(refreshTokenLifetime)
+		isSet = true
+	}
+
 	return result, isSet
 }
 
@@ -1739,9 +2220,37 @@ func getHTTPDNestedObjectsFromEnv(idx int, binding *httpd.Binding) bool {
 		isSet = true
 	}
 
+	publicIndex, ok := getHTTPDPublicIndexFromEnv(idx)
+	if ok {
+		binding.PublicIndex = publicIndex
+		isSet = true
+	}
+
 	return isSet
 }
 
+func getHTTPDPublicIndexFromEnv(idx int) (httpd.PublicIndex, bool) {
+	result := defaultHTTPDBinding.PublicIndex
+	if len(globalConf.HTTPDConfig.Bindings) > idx {
+		result = globalConf.HTTPDConfig.Bindings[idx].PublicIndex
+	}
+	isSet := false
+
+	folders, ok := lookupStringListFromEnv(fmt.Sprintf("SFTPGO_HTTPD__BINDINGS__%v__PUBLIC_INDEX__FOLDERS", idx))
+	if ok {
+		result.Folders = folders
+		isSet = true
+	}
+
+	renderIndexHTML, ok := lookupBoolFromEnv(fmt.Sprintf("SFTPGO_HTTPD__BINDINGS__%v__PUBLIC_INDEX__RENDER_INDEX_HTML", idx))
+	if ok {
+		result.RenderIndexHTML = renderIndexHTML
+		isSet = true
+	}
+
+	return result, isSet
+}
+
 func getHTTPDBindingProxyConfigsFromEnv(idx int, binding *httpd.Binding) bool {
 	isSet := false
 
@@ -1864,6 +2373,12 @@ func getHTTPDBindingFromEnv(idx int) { //nolint:gocyclo
 		isSet = true
 	}
 
+	readOnly, ok := lookupBoolFromEnv(fmt.Sprintf("SFTPGO_HTTPD__BINDINGS__%v__READ_ONLY", idx))
+	if ok {
+		binding.ReadOnly = readOnly
+		isSet = true
+	}
+
 	if getHTTPDNestedObjectsFromEnv(idx, &binding) {
 		isSet = true
 	}
@@ -2005,6 +2520,40 @@ func setViperDefaults() {
 	viper.SetDefault("common.defender.entries_hard_limit", globalConf.Common.DefenderConfig.EntriesHardLimit)
 	viper.SetDefault("common.umask", globalConf.Common.Umask)
 	viper.SetDefault("common.metadata.read", globalConf.Common.Metadata.Read)
+	viper.SetDefault("common.cert_expiry.tls_certificates", globalConf.Common.CertExpiry.TLSCertificates)
+	viper.SetDefault("common.cert_expiry.ssh_host_certificates", globalConf.Common.CertExpiry.SSHHostCertificates)
+	viper.SetDefault("common.cert_expiry.user_certificates", globalConf.Common.CertExpiry.UserCertificates)
+	viper.SetDefault("common.upload_state_retention", globalConf.Common.UploadStateRetention)
+	viper.SetDefault("common.trash_retention", globalConf.Common.TrashRetention)
+	viper.SetDefault("common.trash_dir_name", globalConf.Common.TrashDirName)
+	viper.SetDefault("common.s3_max_upload_memory", globalConf.Common.S3MaxUploadMemory)
+	viper.SetDefault("common.read_cache.path", globalConf.Common.ReadCache.Path)
+	viper.SetDefault("common.read_cache.max_size", globalConf.Common.ReadCache.MaxSize)
+	viper.SetDefault("common.read_cache.max_file_size", globalConf.Common.ReadCache.MaxFileSize)
+	viper.SetDefault("common.dedup.path", globalConf.Common.Dedup.Path)
+	viper.SetDefault("common.dedup.min_file_size", globalConf.Common.Dedup.MinFileSize)
+	viper.SetDefault("common.sftpfs_pool.max_sessions_per_connection", globalConf.Common.SFTPFsPool.MaxSessionsPerConnection)
+	viper.SetDefault("common.sftpfs_pool.max_connections", globalConf.Common.SFTPFsPool.MaxConnections)
+	viper.SetDefault("common.sftpfs_pool.idle_timeout", globalConf.Common.SFTPFsPool.IdleTimeout)
+	viper.SetDefault("common.sftpfs_pool.health_check_interval", globalConf.Common.SFTPFsPool.HealthCheckInterval)
+	viper.SetDefault("common.snapshots.path", globalConf.Common.Snapshots.Path)
+	viper.SetDefault("common.snapshots.retention", globalConf.Common.Snapshots.Retention)
+	viper.SetDefault("common.anonymous_access.template_user", globalConf.Common.AnonymousAccess.TemplateUser)
+	viper.SetDefault("common.anonymous_access.ftp_usernames", globalConf.Common.AnonymousAccess.FTPUsernames)
+	viper.SetDefault("common.anonymous_access.sftp_username", globalConf.Common.AnonymousAccess.SFTPUsername)
+	viper.SetDefault("common.anonymous_access.sftp_password", globalConf.Common.AnonymousAccess.SFTPPassword)
+	viper.SetDefault("common.anonymous_access.folders", globalConf.Common.AnonymousAccess.Folders)
+	viper.SetDefault("common.anonymous_access.rate_limiter.average", globalConf.Common.AnonymousAccess.RateLimiter.Average)
+	viper.SetDefault("common.anonymous_access.rate_limiter.period", globalConf.Common.AnonymousAccess.RateLimiter.Period)
+	viper.SetDefault("common.anonymous_access.rate_limiter.burst", globalConf.Common.AnonymousAccess.RateLimiter.Burst)
+	viper.SetDefault("common.anonymous_access.rate_limiter.type", globalConf.Common.AnonymousAccess.RateLimiter.Type)
+	viper.SetDefault("common.anonymous_access.rate_limiter.protocols", globalConf.Common.AnonymousAccess.RateLimiter.Protocols)
+	viper.SetDefault("common.anonymous_access.rate_limiter.generate_defender_events",
+		globalConf.Common.AnonymousAccess.RateLimiter.GenerateDefenderEvents)
+	viper.SetDefault("common.anonymous_access.rate_limiter.entries_soft_limit",
+		globalConf.Common.AnonymousAccess.RateLimiter.EntriesSoftLimit)
+	viper.SetDefault("common.anonymous_access.rate_limiter.entries_hard_limit",
+		globalConf.Common.AnonymousAccess.RateLimiter.EntriesHardLimit)
 	viper.SetDefault("acme.email", globalConf.ACME.Email)
 	viper.SetDefault("acme.key_type", globalConf.ACME.KeyType)
 	viper.SetDefault("acme.certs_path", globalConf.ACME.CertsPath)
@@ -2019,6 +2568,10 @@ func setViperDefaults() {
 	viper.SetDefault("sftpd.banner", globalConf.SFTPD.Banner)
 	viper.SetDefault("sftpd.host_keys", globalConf.SFTPD.HostKeys)
 	viper.SetDefault("sftpd.host_certificates", globalConf.SFTPD.HostCertificates)
+	viper.SetDefault("sftpd.host_ca.key", globalConf.SFTPD.HostCA.Key)
+	viper.SetDefault("sftpd.host_ca.validity", globalConf.SFTPD.HostCA.Validity)
+	viper.SetDefault("sftpd.host_ca.renew_before", globalConf.SFTPD.HostCA.RenewBefore)
+	viper.SetDefault("sftpd.host_ca.principals", globalConf.SFTPD.HostCA.Principals)
 	viper.SetDefault("sftpd.host_key_algorithms", globalConf.SFTPD.HostKeyAlgorithms)
 	viper.SetDefault("sftpd.moduli", globalConf.SFTPD.Moduli)
 	viper.SetDefault("sftpd.kex_algorithms", globalConf.SFTPD.KexAlgorithms)
@@ -2033,6 +2586,7 @@ func setViperDefaults() {
 	viper.SetDefault("sftpd.keyboard_interactive_auth_hook", globalConf.SFTPD.KeyboardInteractiveHook)
 	viper.SetDefault("sftpd.password_authentication", globalConf.SFTPD.PasswordAuthentication)
 	viper.SetDefault("sftpd.folder_prefix", globalConf.SFTPD.FolderPrefix)
+	viper.SetDefault("sftpd.byte_range_locking", globalConf.SFTPD.ByteRangeLocking)
 	viper.SetDefault("ftpd.banner", globalConf.FTPD.Banner)
 	viper.SetDefault("ftpd.banner_file", globalConf.FTPD.BannerFile)
 	viper.SetDefault("ftpd.active_transfers_port_non_20", globalConf.FTPD.ActiveTransfersPortNon20)
@@ -2046,10 +2600,16 @@ func setViperDefaults() {
 	viper.SetDefault("ftpd.certificate_key_file", globalConf.FTPD.CertificateKeyFile)
 	viper.SetDefault("ftpd.ca_certificates", globalConf.FTPD.CACertificates)
 	viper.SetDefault("ftpd.ca_revocation_lists", globalConf.FTPD.CARevocationLists)
+	viper.SetDefault("ftpd.revocation_check.ocsp_enabled", globalConf.FTPD.RevocationCheck.OCSPEnabled)
+	viper.SetDefault("ftpd.revocation_check.hard_fail", globalConf.FTPD.RevocationCheck.HardFail)
+	viper.SetDefault("ftpd.revocation_check.cache_ttl", globalConf.FTPD.RevocationCheck.CacheTTL)
 	viper.SetDefault("webdavd.certificate_file", globalConf.WebDAVD.CertificateFile)
 	viper.SetDefault("webdavd.certificate_key_file", globalConf.WebDAVD.CertificateKeyFile)
 	viper.SetDefault("webdavd.ca_certificates", globalConf.WebDAVD.CACertificates)
 	viper.SetDefault("webdavd.ca_revocation_lists", globalConf.WebDAVD.CARevocationLists)
+	viper.SetDefault("webdavd.revocation_check.ocsp_enabled", globalConf.WebDAVD.RevocationCheck.OCSPEnabled)
+	viper.SetDefault("webdavd.revocation_check.hard_fail", globalConf.WebDAVD.RevocationCheck.HardFail)
+	viper.SetDefault("webdavd.revocation_check.cache_ttl", globalConf.WebDAVD.RevocationCheck.CacheTTL)
 	viper.SetDefault("webdavd.cors.enabled", globalConf.WebDAVD.Cors.Enabled)
 	viper.SetDefault("webdavd.cors.allowed_origins", globalConf.WebDAVD.Cors.AllowedOrigins)
 	viper.SetDefault("webdavd.cors.allowed_methods", globalConf.WebDAVD.Cors.AllowedMethods)
@@ -2065,6 +2625,17 @@ func setViperDefaults() {
 	viper.SetDefault("webdavd.cache.mime_types.enabled", globalConf.WebDAVD.Cache.MimeTypes.Enabled)
 	viper.SetDefault("webdavd.cache.mime_types.max_size", globalConf.WebDAVD.Cache.MimeTypes.MaxSize)
 	viper.SetDefault("webdavd.cache.mime_types.custom_mappings", globalConf.WebDAVD.Cache.MimeTypes.CustomMappings)
+	viper.SetDefault("webdavd.bearer_auth.issuer_url", globalConf.WebDAVD.BearerAuth.IssuerURL)
+	viper.SetDefault("webdavd.bearer_auth.audience", globalConf.WebDAVD.BearerAuth.Audience)
+	viper.SetDefault("webdavd.bearer_auth.username_field", globalConf.WebDAVD.BearerAuth.UsernameField)
+	viper.SetDefault("webdavd.bearer_auth.cookie_name", globalConf.WebDAVD.BearerAuth.CookieName)
+	viper.SetDefault("webdavd.dead_properties", globalConf.WebDAVD.DeadProperties)
+	viper.SetDefault("webdavd.infinite_depth.enabled", globalConf.WebDAVD.InfiniteDepth.Enabled)
+	viper.SetDefault("webdavd.infinite_depth.max_results", globalConf.WebDAVD.InfiniteDepth.MaxResults)
+	viper.SetDefault("s3gw.certificate_file", globalConf.S3GW.CertificateFile)
+	viper.SetDefault("s3gw.certificate_key_file", globalConf.S3GW.CertificateKeyFile)
+	viper.SetDefault("s3gw.region", globalConf.S3GW.Region)
+	viper.SetDefault("s3gw.multipart_upload_expiration", globalConf.S3GW.MultipartUploadExpiration)
 	viper.SetDefault("data_provider.driver", globalConf.ProviderConf.Driver)
 	viper.SetDefault("data_provider.name", globalConf.ProviderConf.Name)
 	viper.SetDefault("data_provider.host", globalConf.ProviderConf.Host)
@@ -2083,6 +2654,7 @@ func setViperDefaults() {
 	viper.SetDefault("data_provider.sql_tables_prefix", globalConf.ProviderConf.SQLTablesPrefix)
 	viper.SetDefault("data_provider.track_quota", globalConf.ProviderConf.TrackQuota)
 	viper.SetDefault("data_provider.pool_size", globalConf.ProviderConf.PoolSize)
+	viper.SetDefault("data_provider.redis_persistence", globalConf.ProviderConf.RedisPersistence)
 	viper.SetDefault("data_provider.users_base_dir", globalConf.ProviderConf.UsersBaseDir)
 	viper.SetDefault("data_provider.actions.execute_on", globalConf.ProviderConf.Actions.ExecuteOn)
 	viper.SetDefault("data_provider.actions.execute_for", globalConf.ProviderConf.Actions.ExecuteFor)
@@ -2111,6 +2683,45 @@ func setViperDefaults() {
 	viper.SetDefault("data_provider.node.port", globalConf.ProviderConf.Node.Port)
 	viper.SetDefault("data_provider.node.proto", globalConf.ProviderConf.Node.Proto)
 	viper.SetDefault("data_provider.backups_path", globalConf.ProviderConf.BackupsPath)
+	viper.SetDefault("data_provider.audit_trail.enabled", globalConf.ProviderConf.AuditTrail.Enabled)
+	viper.SetDefault("data_provider.audit_trail.retention_days", globalConf.ProviderConf.AuditTrail.RetentionDays)
+	viper.SetDefault("data_provider.reconciler.source_dir", globalConf.ProviderConf.Reconciler.SourceDir)
+	viper.SetDefault("data_provider.reconciler.git_url", globalConf.ProviderConf.Reconciler.GitURL)
+	viper.SetDefault("data_provider.reconciler.git_branch", globalConf.ProviderConf.Reconciler.GitBranch)
+	viper.SetDefault("data_provider.reconciler.interval", globalConf.ProviderConf.Reconciler.Interval)
+	viper.SetDefault("data_provider.reconciler.mode", globalConf.ProviderConf.Reconciler.Mode)
+	viper.SetDefault("data_provider.ldap_sync.url", globalConf.ProviderConf.LDAPSync.URL)
+	viper.SetDefault("data_provider.ldap_sync.start_tls", globalConf.ProviderConf.LDAPSync.StartTLS)
+	viper.SetDefault("data_provider.ldap_sync.skip_tls_verify", globalConf.ProviderConf.LDAPSync.SkipTLSVerify)
+	viper.SetDefault("data_provider.ldap_sync.bind_dn", globalConf.ProviderConf.LDAPSync.BindDN)
+	viper.SetDefault("data_provider.ldap_sync.bind_password", globalConf.ProviderConf.LDAPSync.BindPassword)
+	viper.SetDefault("data_provider.ldap_sync.base_dn", globalConf.ProviderConf.LDAPSync.BaseDN)
+	viper.SetDefault("data_provider.ldap_sync.user_filter", globalConf.ProviderConf.LDAPSync.UserFilter)
+	viper.SetDefault("data_provider.ldap_sync.username_attribute", globalConf.ProviderConf.LDAPSync.UsernameAttribute)
+	viper.SetDefault("data_provider.ldap_sync.email_attribute", globalConf.ProviderConf.LDAPSync.EmailAttribute)
+	viper.SetDefault("data_provider.ldap_sync.group_attribute", globalConf.ProviderConf.LDAPSync.GroupAttribute)
+	viper.SetDefault("data_provider.ldap_sync.permissions", globalConf.ProviderConf.LDAPSync.Permissions)
+	viper.SetDefault("data_provider.ldap_sync.interval", globalConf.ProviderConf.LDAPSync.Interval)
+	viper.SetDefault("data_provider.ldap_auth.url", globalConf.ProviderConf.LDAPAuth.URL)
+	viper.SetDefault("data_provider.ldap_auth.start_tls", globalConf.ProviderConf.LDAPAuth.StartTLS)
+	viper.SetDefault("data_provider.ldap_auth.skip_tls_verify", globalConf.ProviderConf.LDAPAuth.SkipTLSVerify)
+	viper.SetDefault("data_provider.ldap_auth.bind_dn", globalConf.ProviderConf.LDAPAuth.BindDN)
+	viper.SetDefault("data_provider.ldap_auth.bind_password", globalConf.ProviderConf.LDAPAuth.BindPassword)
+	viper.SetDefault("data_provider.ldap_auth.base_dn", globalConf.ProviderConf.LDAPAuth.BaseDN)
+	viper.SetDefault("data_provider.ldap_auth.user_filter", globalConf.ProviderConf.LDAPAuth.UserFilter)
+	viper.SetDefault("data_provider.ldap_auth.email_attribute", globalConf.ProviderConf.LDAPAuth.EmailAttribute)
+	viper.SetDefault("data_provider.ldap_auth.group_attribute", globalConf.ProviderConf.LDAPAuth.GroupAttribute)
+	viper.SetDefault("data_provider.ldap_auth.permissions", globalConf.ProviderConf.LDAPAuth.Permissions)
+	viper.SetDefault("data_provider.ldap_auth.protocols", globalConf.ProviderConf.LDAPAuth.Protocols)
+	viper.SetDefault("data_provider.share_analytics.enabled", globalConf.ProviderConf.ShareAnalytics.Enabled)
+	viper.SetDefault("data_provider.share_analytics.retention_days", globalConf.ProviderConf.ShareAnalytics.RetentionDays)
+	viper.SetDefault("data_provider.share_analytics.anonymize_ip", globalConf.ProviderConf.ShareAnalytics.AnonymizeIP)
+	viper.SetDefault("data_provider.webhooks.enabled", globalConf.ProviderConf.Webhooks.Enabled)
+	viper.SetDefault("data_provider.webhooks.signing_secret", globalConf.ProviderConf.Webhooks.SigningSecret)
+	viper.SetDefault("data_provider.webhooks.max_attempts", globalConf.ProviderConf.Webhooks.MaxAttempts)
+	viper.SetDefault("data_provider.webhooks.retry_interval", globalConf.ProviderConf.Webhooks.RetryInterval)
+	viper.SetDefault("data_provider.webhooks.max_retry_interval", globalConf.ProviderConf.Webhooks.MaxRetryInterval)
+	viper.SetDefault("data_provider.webhooks.retention_days", globalConf.ProviderConf.Webhooks.RetentionDays)
 	viper.SetDefault("httpd.templates_path", globalConf.HTTPDConfig.TemplatesPath)
 	viper.SetDefault("httpd.static_files_path", globalConf.HTTPDConfig.StaticFilesPath)
 	viper.SetDefault("httpd.openapi_path", globalConf.HTTPDConfig.OpenAPIPath)
@@ -2136,6 +2747,25 @@ func setViperDefaults() {
 	viper.SetDefault("httpd.setup.installation_code", globalConf.HTTPDConfig.Setup.InstallationCode)
 	viper.SetDefault("httpd.setup.installation_code_hint", globalConf.HTTPDConfig.Setup.InstallationCodeHint)
 	viper.SetDefault("httpd.hide_support_link", globalConf.HTTPDConfig.HideSupportLink)
+	viper.SetDefault("httpd.external_jwt.issuer", globalConf.HTTPDConfig.ExternalJWT.Issuer)
+	viper.SetDefault("httpd.external_jwt.jwks_url", globalConf.HTTPDConfig.ExternalJWT.JWKSURL)
+	viper.SetDefault("httpd.external_jwt.audience", globalConf.HTTPDConfig.ExternalJWT.Audience)
+	viper.SetDefault("httpd.external_jwt.role_field", globalConf.HTTPDConfig.ExternalJWT.RoleField)
+	viper.SetDefault("httpd.thumbnails.enabled", globalConf.HTTPDConfig.Thumbnails.Enabled)
+	viper.SetDefault("httpd.thumbnails.cache_path", globalConf.HTTPDConfig.Thumbnails.CachePath)
+	viper.SetDefault("httpd.thumbnails.size", globalConf.HTTPDConfig.Thumbnails.Size)
+	viper.SetDefault("httpd.thumbnails.max_file_size", globalConf.HTTPDConfig.Thumbnails.MaxFileSize)
+	viper.SetDefault("httpd.thumbnails.cache_retention", globalConf.HTTPDConfig.Thumbnails.CacheRetention)
+	viper.SetDefault("httpd.thumbnails.ffmpeg_path", globalConf.HTTPDConfig.Thumbnails.FFmpegPath)
+	viper.SetDefault("httpd.search.max_entries", globalConf.HTTPDConfig.Search.MaxEntries)
+	viper.SetDefault("httpd.search.content_max_file_size", globalConf.HTTPDConfig.Search.ContentMaxFileSize)
+	viper.SetDefault("httpd.search.index_ttl", globalConf.HTTPDConfig.Search.IndexTTL)
+	viper.SetDefault("httpd.chunked_uploads.enabled", globalConf.HTTPDConfig.ChunkedUploads.Enabled)
+	viper.SetDefault("httpd.chunked_uploads.storage_path", globalConf.HTTPDConfig.ChunkedUploads.StoragePath)
+	viper.SetDefault("httpd.chunked_uploads.chunk_size", globalConf.HTTPDConfig.ChunkedUploads.ChunkSize)
+	viper.SetDefault("httpd.chunked_uploads.max_parallel_chunks", globalConf.HTTPDConfig.ChunkedUploads.MaxParallelChunks)
+	viper.SetDefault("httpd.chunked_uploads.retention", globalConf.HTTPDConfig.ChunkedUploads.Retention)
+	viper.SetDefault("httpd.graphql.enabled", globalConf.HTTPDConfig.GraphQL.Enabled)
 	viper.SetDefault("http.timeout", globalConf.HTTPConfig.Timeout)
 	viper.SetDefault("http.retry_wait_min", globalConf.HTTPConfig.RetryWaitMin)
 	viper.SetDefault("http.retry_wait_max", globalConf.HTTPConfig.RetryWaitMax)
@@ -2156,6 +2786,15 @@ func setViperDefaults() {
 	viper.SetDefault("telemetry.min_tls_version", globalConf.TelemetryConfig.MinTLSVersion)
 	viper.SetDefault("telemetry.tls_cipher_suites", globalConf.TelemetryConfig.TLSCipherSuites)
 	viper.SetDefault("telemetry.tls_protocols", globalConf.TelemetryConfig.Protocols)
+	viper.SetDefault("mfa.webauthn.enabled", globalConf.MFAConfig.WebAuthn.Enabled)
+	viper.SetDefault("mfa.webauthn.rp_id", globalConf.MFAConfig.WebAuthn.RPID)
+	viper.SetDefault("mfa.webauthn.rp_display_name", globalConf.MFAConfig.WebAuthn.RPDisplayName)
+	viper.SetDefault("mfa.webauthn.origins", globalConf.MFAConfig.WebAuthn.Origins)
+	viper.SetDefault("mfa.push.api_host", globalConf.MFAConfig.Push.APIHost)
+	viper.SetDefault("mfa.push.integration_key", globalConf.MFAConfig.Push.IntegrationKey)
+	viper.SetDefault("mfa.push.secret_key", globalConf.MFAConfig.Push.SecretKey)
+	viper.SetDefault("mfa.push.timeout", globalConf.MFAConfig.Push.Timeout)
+	viper.SetDefault("mfa.push.fallback_to_totp", globalConf.MFAConfig.Push.FallbackToTOTP)
 	viper.SetDefault("smtp.host", globalConf.SMTPConfig.Host)
 	viper.SetDefault("smtp.port", globalConf.SMTPConfig.Port)
 	viper.SetDefault("smtp.from", globalConf.SMTPConfig.From)
@@ -309,6 +309,112 @@ func TestDefenderProviderDriver(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAuditTrailUnsupportedProvider(t *testing.T) {
+	reset()
+
+	confName := tempConfigName + ".json"
+	configFilePath := filepath.Join(configDir, confName)
+	providerConf := config.GetProviderConf()
+	providerConf.Driver = dataprovider.MemoryDataProviderName
+	providerConf.AuditTrail.Enabled = true
+	c := make(map[string]any)
+	c["data_provider"] = providerConf
+	jsonConf, err := json.Marshal(c)
+	assert.NoError(t, err)
+	err = os.WriteFile(configFilePath, jsonConf, os.ModePerm)
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, confName)
+	assert.NoError(t, err)
+	assert.False(t, config.GetProviderConf().AuditTrail.Enabled)
+	err = os.Remove(configFilePath)
+	assert.NoError(t, err)
+}
+
+func TestShareAnalyticsUnsupportedProvider(t *testing.T) {
+	reset()
+
+	confName := tempConfigName + ".json"
+	configFilePath := filepath.Join(configDir, confName)
+	providerConf := config.GetProviderConf()
+	providerConf.Driver = dataprovider.BoltDataProviderName
+	providerConf.ShareAnalytics.Enabled = true
+	providerConf.ShareAnalytics.AnonymizeIP = true
+	c := make(map[string]any)
+	c["data_provider"] = providerConf
+	jsonConf, err := json.Marshal(c)
+	assert.NoError(t, err)
+	err = os.WriteFile(configFilePath, jsonConf, os.ModePerm)
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, confName)
+	assert.NoError(t, err)
+	assert.False(t, config.GetProviderConf().ShareAnalytics.Enabled)
+	assert.True(t, config.GetProviderConf().ShareAnalytics.AnonymizeIP)
+	err = os.Remove(configFilePath)
+	assert.NoError(t, err)
+}
+
+func TestWebhooksUnsupportedProvider(t *testing.T) {
+	reset()
+
+	confName := tempConfigName + ".json"
+	configFilePath := filepath.Join(configDir, confName)
+	providerConf := config.GetProviderConf()
+	assert.False(t, providerConf.Webhooks.Enabled)
+	assert.Equal(t, 10, providerConf.Webhooks.MaxAttempts)
+	assert.Equal(t, 30, providerConf.Webhooks.RetryInterval)
+	assert.Equal(t, 3600, providerConf.Webhooks.MaxRetryInterval)
+	assert.Equal(t, 7, providerConf.Webhooks.RetentionDays)
+	providerConf.Driver = dataprovider.MemoryDataProviderName
+	providerConf.Webhooks.Enabled = true
+	providerConf.Webhooks.SigningSecret = "secret"
+	c := make(map[string]any)
+	c["data_provider"] = providerConf
+	jsonConf, err := json.Marshal(c)
+	assert.NoError(t, err)
+	err = os.WriteFile(configFilePath, jsonConf, os.ModePerm)
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, confName)
+	assert.NoError(t, err)
+	assert.False(t, config.GetProviderConf().Webhooks.Enabled)
+	assert.Equal(t, "secret", config.GetProviderConf().Webhooks.SigningSecret)
+	err = os.Remove(configFilePath)
+	assert.NoError(t, err)
+}
+
+func TestDAVDeadPropertiesUnsupportedProvider(t *testing.T) {
+	reset()
+
+	confName := tempConfigName + ".json"
+	configFilePath := filepath.Join(configDir, confName)
+	assert.False(t, config.GetWebDAVDConfig().DeadProperties)
+	providerConf := config.GetProviderConf()
+	providerConf.Driver = dataprovider.BoltDataProviderName
+	webDavConf := config.GetWebDAVDConfig()
+	webDavConf.DeadProperties = true
+	c := make(map[string]any)
+	c["data_provider"] = providerConf
+	c["webdavd"] = webDavConf
+	jsonConf, err := json.Marshal(c)
+	assert.NoError(t, err)
+	err = os.WriteFile(configFilePath, jsonConf, os.ModePerm)
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, confName)
+	assert.NoError(t, err)
+	assert.False(t, config.GetWebDAVDConfig().DeadProperties)
+
+	providerConf.Driver = dataprovider.SQLiteDataProviderName
+	c["data_provider"] = providerConf
+	jsonConf, err = json.Marshal(c)
+	assert.NoError(t, err)
+	err = os.WriteFile(configFilePath, jsonConf, os.ModePerm)
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, confName)
+	assert.NoError(t, err)
+	assert.True(t, config.GetWebDAVDConfig().DeadProperties)
+	err = os.Remove(configFilePath)
+	assert.NoError(t, err)
+}
+
 func TestSetGetConfig(t *testing.T) {
 	reset()
 
@@ -390,6 +496,13 @@ func TestServiceToStart(t *testing.T) {
 	webdavdConf.Bindings[0].Port = 0
 	config.SetWebDAVDConfig(webdavdConf)
 	assert.False(t, config.HasServicesToStart())
+	s3gwConf := config.GetS3GWConfig()
+	s3gwConf.Bindings[0].Port = 9002
+	config.SetS3GWConfig(s3gwConf)
+	assert.True(t, config.HasServicesToStart())
+	s3gwConf.Bindings[0].Port = 0
+	config.SetS3GWConfig(s3gwConf)
+	assert.False(t, config.HasServicesToStart())
 	sftpdConf.Bindings[0].Port = 2022
 	config.SetSFTPDConfig(sftpdConf)
 	assert.True(t, config.HasServicesToStart())
@@ -854,12 +967,14 @@ func TestSFTPDBindingsFromEnv(t *testing.T) {
 	os.Setenv("SFTPGO_SFTPD__BINDINGS__0__APPLY_PROXY_CONFIG", "false")
 	os.Setenv("SFTPGO_SFTPD__BINDINGS__3__ADDRESS", "127.0.1.1")
 	os.Setenv("SFTPGO_SFTPD__BINDINGS__3__PORT", "2203")
+	os.Setenv("SFTPGO_SFTPD__BINDINGS__3__READ_ONLY", "true")
 	t.Cleanup(func() {
 		os.Unsetenv("SFTPGO_SFTPD__BINDINGS__0__ADDRESS")
 		os.Unsetenv("SFTPGO_SFTPD__BINDINGS__0__PORT")
 		os.Unsetenv("SFTPGO_SFTPD__BINDINGS__0__APPLY_PROXY_CONFIG")
 		os.Unsetenv("SFTPGO_SFTPD__BINDINGS__3__ADDRESS")
 		os.Unsetenv("SFTPGO_SFTPD__BINDINGS__3__PORT")
+		os.Unsetenv("SFTPGO_SFTPD__BINDINGS__3__READ_ONLY")
 	})
 
 	err := config.LoadConfig(configDir, "")
@@ -869,9 +984,11 @@ func TestSFTPDBindingsFromEnv(t *testing.T) {
 	require.Equal(t, 2200, bindings[0].Port)
 	require.Equal(t, "127.0.0.1", bindings[0].Address)
 	require.False(t, bindings[0].ApplyProxyConfig)
+	require.False(t, bindings[0].ReadOnly)
 	require.Equal(t, 2203, bindings[1].Port)
 	require.Equal(t, "127.0.1.1", bindings[1].Address)
 	require.True(t, bindings[1].ApplyProxyConfig) // default value
+	require.True(t, bindings[1].ReadOnly)
 }
 
 func TestCommandsFromEnv(t *testing.T) {
@@ -963,6 +1080,7 @@ func TestFTPDBindingsFromEnv(t *testing.T) {
 	os.Setenv("SFTPGO_FTPD__BINDINGS__9__PASSIVE_IP_OVERRIDES__3__NETWORKS", "192.168.1.0/24, 192.168.3.0/25")
 	os.Setenv("SFTPGO_FTPD__BINDINGS__9__CLIENT_AUTH_TYPE", "2")
 	os.Setenv("SFTPGO_FTPD__BINDINGS__9__DEBUG", "1")
+	os.Setenv("SFTPGO_FTPD__BINDINGS__9__READ_ONLY", "1")
 	os.Setenv("SFTPGO_FTPD__BINDINGS__9__ACTIVE_CONNECTIONS_SECURITY", "1")
 	os.Setenv("SFTPGO_FTPD__BINDINGS__9__CERTIFICATE_FILE", "cert.crt")
 	os.Setenv("SFTPGO_FTPD__BINDINGS__9__CERTIFICATE_KEY_FILE", "cert.key")
@@ -987,6 +1105,7 @@ func TestFTPDBindingsFromEnv(t *testing.T) {
 		os.Unsetenv("SFTPGO_FTPD__BINDINGS__9__PASSIVE_IP_OVERRIDES__3__NETWORKS")
 		os.Unsetenv("SFTPGO_FTPD__BINDINGS__9__CLIENT_AUTH_TYPE")
 		os.Unsetenv("SFTPGO_FTPD__BINDINGS__9__DEBUG")
+		os.Unsetenv("SFTPGO_FTPD__BINDINGS__9__READ_ONLY")
 		os.Unsetenv("SFTPGO_FTPD__BINDINGS__9__ACTIVE_CONNECTIONS_SECURITY")
 		os.Unsetenv("SFTPGO_FTPD__BINDINGS__9__CERTIFICATE_FILE")
 		os.Unsetenv("SFTPGO_FTPD__BINDINGS__9__CERTIFICATE_KEY_FILE")
@@ -1010,6 +1129,7 @@ func TestFTPDBindingsFromEnv(t *testing.T) {
 	require.Equal(t, "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256", bindings[0].TLSCipherSuites[0])
 	require.Equal(t, "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", bindings[0].TLSCipherSuites[1])
 	require.False(t, bindings[0].Debug)
+	require.False(t, bindings[0].ReadOnly)
 	require.Equal(t, 1, bindings[0].PassiveConnectionsSecurity)
 	require.Equal(t, 0, bindings[0].ActiveConnectionsSecurity)
 	require.Equal(t, 2203, bindings[1].Port)
@@ -1030,6 +1150,7 @@ func TestFTPDBindingsFromEnv(t *testing.T) {
 	require.Equal(t, 0, bindings[1].PassiveConnectionsSecurity)
 	require.Equal(t, 1, bindings[1].ActiveConnectionsSecurity)
 	require.True(t, bindings[1].Debug)
+	require.True(t, bindings[1].ReadOnly)
 	require.Equal(t, "cert.crt", bindings[1].CertificateFile)
 	require.Equal(t, "cert.key", bindings[1].CertificateKeyFile)
 }
@@ -1100,6 +1221,70 @@ func TestWebDAVMimeCache(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestLDAPSyncMappingsFromEnv(t *testing.T) {
+	reset()
+
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__GROUP", "cn=sftp,ou=groups,dc=example,dc=com")
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__HOME_DIR", "/srv/sftpgo/%username%")
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__QUOTA_SIZE", "1048576")
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__QUOTA_FILES", "100")
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__SECONDARY_GROUPS", "group1, group2")
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__1__PRIMARY_GROUP", "default")
+	t.Cleanup(func() {
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__GROUP")
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__HOME_DIR")
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__QUOTA_SIZE")
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__QUOTA_FILES")
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__0__SECONDARY_GROUPS")
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_SYNC__MAPPINGS__1__PRIMARY_GROUP")
+	})
+
+	configDir := ".."
+	err := config.LoadConfig(configDir, "")
+	assert.NoError(t, err)
+	mappings := config.GetProviderConf().LDAPSync.Mappings
+	if assert.Len(t, mappings, 2) {
+		assert.Equal(t, "cn=sftp,ou=groups,dc=example,dc=com", mappings[0].Group)
+		assert.Equal(t, "/srv/sftpgo/%username%", mappings[0].HomeDir)
+		assert.Equal(t, int64(1048576), mappings[0].QuotaSize)
+		assert.Equal(t, 100, mappings[0].QuotaFiles)
+		assert.Equal(t, []string{"group1", "group2"}, mappings[0].SecondaryGroups)
+		assert.Empty(t, mappings[0].PrimaryGroup)
+		assert.Empty(t, mappings[1].Group)
+		assert.Equal(t, "default", mappings[1].PrimaryGroup)
+	}
+	assert.Equal(t, "uid", config.GetProviderConf().LDAPSync.UsernameAttribute)
+	assert.Equal(t, 60, config.GetProviderConf().LDAPSync.Interval)
+}
+
+func TestLDAPAuthFromEnv(t *testing.T) {
+	reset()
+
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_AUTH__URL", "ldap://127.0.0.1:389")
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_AUTH__PROTOCOLS", "SSH,FTP")
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_AUTH__MAPPINGS__0__GROUP", "cn=sftp,ou=groups,dc=example,dc=com")
+	os.Setenv("SFTPGO_DATA_PROVIDER__LDAP_AUTH__MAPPINGS__0__PRIMARY_GROUP", "ldap")
+	t.Cleanup(func() {
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_AUTH__URL")
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_AUTH__PROTOCOLS")
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_AUTH__MAPPINGS__0__GROUP")
+		os.Unsetenv("SFTPGO_DATA_PROVIDER__LDAP_AUTH__MAPPINGS__0__PRIMARY_GROUP")
+	})
+
+	configDir := ".."
+	err := config.LoadConfig(configDir, "")
+	assert.NoError(t, err)
+	ldapAuth := config.GetProviderConf().LDAPAuth
+	assert.Equal(t, "ldap://127.0.0.1:389", ldapAuth.URL)
+	assert.Equal(t, []string{"SSH", "FTP"}, ldapAuth.Protocols)
+	assert.Equal(t, "(&(objectClass=person)(uid=%username%))", ldapAuth.UserFilter)
+	if assert.Len(t, ldapAuth.Mappings, 1) {
+		assert.Equal(t, "cn=sftp,ou=groups,dc=example,dc=com", ldapAuth.Mappings[0].Group)
+		assert.Equal(t, "ldap", ldapAuth.Mappings[0].PrimaryGroup)
+	}
+	assert.Len(t, config.GetProviderConf().LDAPSync.Mappings, 0)
+}
+
 func TestWebDAVBindingsFromEnv(t *testing.T) {
 	reset()
 
@@ -1120,6 +1305,8 @@ func TestWebDAVBindingsFromEnv(t *testing.T) {
 	os.Setenv("SFTPGO_WEBDAVD__BINDINGS__2__CERTIFICATE_FILE", "webdav.crt")
 	os.Setenv("SFTPGO_WEBDAVD__BINDINGS__2__CERTIFICATE_KEY_FILE", "webdav.key")
 	os.Setenv("SFTPGO_WEBDAVD__BINDINGS__2__DISABLE_WWW_AUTH_HEADER", "1")
+	os.Setenv("SFTPGO_WEBDAVD__BINDINGS__2__USER_PREFIXES__0__PREFIX", "/legacy/files")
+	os.Setenv("SFTPGO_WEBDAVD__BINDINGS__2__USER_PREFIXES__0__USERNAME", "user1")
 
 	t.Cleanup(func() {
 		os.Unsetenv("SFTPGO_WEBDAVD__BINDINGS__1__ADDRESS")
@@ -1139,6 +1326,8 @@ func TestWebDAVBindingsFromEnv(t *testing.T) {
 		os.Unsetenv("SFTPGO_WEBDAVD__BINDINGS__2__CERTIFICATE_FILE")
 		os.Unsetenv("SFTPGO_WEBDAVD__BINDINGS__2__CERTIFICATE_KEY_FILE")
 		os.Unsetenv("SFTPGO_WEBDAVD__BINDINGS__2__DISABLE_WWW_AUTH_HEADER")
+		os.Unsetenv("SFTPGO_WEBDAVD__BINDINGS__2__USER_PREFIXES__0__PREFIX")
+		os.Unsetenv("SFTPGO_WEBDAVD__BINDINGS__2__USER_PREFIXES__0__USERNAME")
 	})
 
 	err := config.LoadConfig(configDir, "")
@@ -1167,6 +1356,7 @@ func TestWebDAVBindingsFromEnv(t *testing.T) {
 	require.Equal(t, "X-Forwarded-For", bindings[1].ClientIPProxyHeader)
 	require.Equal(t, 2, bindings[1].ClientIPHeaderDepth)
 	require.Empty(t, bindings[1].Prefix)
+	require.Len(t, bindings[1].UserPrefixes, 0)
 	require.False(t, bindings[1].DisableWWWAuthHeader)
 	require.Equal(t, 9000, bindings[2].Port)
 	require.Equal(t, "127.0.1.1", bindings[2].Address)
@@ -1179,6 +1369,66 @@ func TestWebDAVBindingsFromEnv(t *testing.T) {
 	require.Equal(t, "webdav.key", bindings[2].CertificateKeyFile)
 	require.Equal(t, 0, bindings[2].ClientIPHeaderDepth)
 	require.True(t, bindings[2].DisableWWWAuthHeader)
+	require.Len(t, bindings[2].UserPrefixes, 1)
+	require.Equal(t, "/legacy/files", bindings[2].UserPrefixes[0].Prefix)
+	require.Equal(t, "user1", bindings[2].UserPrefixes[0].Username)
+}
+
+func TestS3GWBindingsFromEnv(t *testing.T) {
+	reset()
+
+	os.Setenv("SFTPGO_S3GW__BINDINGS__0__PORT", "9002")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__ADDRESS", "127.0.0.1")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__PORT", "9003")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__ENABLE_HTTPS", "1")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__MIN_TLS_VERSION", "13")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__TLS_CIPHER_SUITES", "TLS_RSA_WITH_AES_128_CBC_SHA ")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__CERTIFICATE_FILE", "s3gw.crt")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__CERTIFICATE_KEY_FILE", "s3gw.key")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__PROXY_ALLOWED", "192.168.10.1")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__CLIENT_IP_PROXY_HEADER", "X-Forwarded-For")
+	os.Setenv("SFTPGO_S3GW__BINDINGS__1__CLIENT_IP_HEADER_DEPTH", "1")
+	os.Setenv("SFTPGO_S3GW__REGION", "us-east-1")
+	os.Setenv("SFTPGO_S3GW__MULTIPART_UPLOAD_EXPIRATION", "48")
+
+	t.Cleanup(func() {
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__0__PORT")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__ADDRESS")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__PORT")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__ENABLE_HTTPS")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__MIN_TLS_VERSION")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__TLS_CIPHER_SUITES")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__CERTIFICATE_FILE")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__CERTIFICATE_KEY_FILE")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__PROXY_ALLOWED")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__CLIENT_IP_PROXY_HEADER")
+		os.Unsetenv("SFTPGO_S3GW__BINDINGS__1__CLIENT_IP_HEADER_DEPTH")
+		os.Unsetenv("SFTPGO_S3GW__REGION")
+		os.Unsetenv("SFTPGO_S3GW__MULTIPART_UPLOAD_EXPIRATION")
+	})
+
+	err := config.LoadConfig(configDir, "")
+	assert.NoError(t, err)
+	s3gwConf := config.GetS3GWConfig()
+	assert.Equal(t, "us-east-1", s3gwConf.Region)
+	assert.Equal(t, 48, s3gwConf.MultipartUploadExpiration)
+	bindings := s3gwConf.Bindings
+	require.Len(t, bindings, 2)
+	require.Equal(t, 9002, bindings[0].Port)
+	require.Empty(t, bindings[0].Address)
+	require.False(t, bindings[0].EnableHTTPS)
+	require.Equal(t, 12, bindings[0].MinTLSVersion)
+	require.Len(t, bindings[0].TLSCipherSuites, 0)
+	require.Equal(t, 9003, bindings[1].Port)
+	require.Equal(t, "127.0.0.1", bindings[1].Address)
+	require.True(t, bindings[1].EnableHTTPS)
+	require.Equal(t, 13, bindings[1].MinTLSVersion)
+	require.Equal(t, []string{"TLS_RSA_WITH_AES_128_CBC_SHA"}, bindings[1].TLSCipherSuites)
+	require.Equal(t, "s3gw.crt", bindings[1].CertificateFile)
+	require.Equal(t, "s3gw.key", bindings[1].CertificateKeyFile)
+	require.Equal(t, []string{"192.168.10.1"}, bindings[1].ProxyAllowed)
+	require.Equal(t, "X-Forwarded-For", bindings[1].ClientIPProxyHeader)
+	require.Equal(t, 1, bindings[1].ClientIPHeaderDepth)
 }
 
 func TestHTTPDBindingsFromEnv(t *testing.T) {
@@ -1222,6 +1472,9 @@ func TestHTTPDBindingsFromEnv(t *testing.T) {
 	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__CUSTOM_FIELDS", "field1,field2")
 	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__INSECURE_SKIP_SIGNATURE_CHECK", "1")
 	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__DEBUG", "1")
+	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__DEVICE_AUTH", "1")
+	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__DEVICE_AUTH_TOKEN_LIFETIME", "60")
+	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__DEVICE_AUTH_REFRESH_TOKEN_LIFETIME", "24")
 	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__SECURITY__ENABLED", "true")
 	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__SECURITY__ALLOWED_HOSTS", "*.example.com,*.example.net")
 	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__SECURITY__ALLOWED_HOSTS_ARE_REGEX", "1")
@@ -1248,6 +1501,8 @@ func TestHTTPDBindingsFromEnv(t *testing.T) {
 	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__BRANDING__WEB_CLIENT__EXTRA_CSS", "1.css,2.css")
 	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__CERTIFICATE_FILE", "httpd.crt")
 	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__CERTIFICATE_KEY_FILE", "httpd.key")
+	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__PUBLIC_INDEX__FOLDERS", "releases,docs")
+	os.Setenv("SFTPGO_HTTPD__BINDINGS__2__PUBLIC_INDEX__RENDER_INDEX_HTML", "1")
 
 	t.Cleanup(func() {
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__0__ADDRESS")
@@ -1287,6 +1542,9 @@ func TestHTTPDBindingsFromEnv(t *testing.T) {
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__CUSTOM_FIELDS")
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__INSECURE_SKIP_SIGNATURE_CHECK")
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__DEBUG")
+		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__DEVICE_AUTH")
+		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__DEVICE_AUTH_TOKEN_LIFETIME")
+		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__OIDC__DEVICE_AUTH_REFRESH_TOKEN_LIFETIME")
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__SECURITY__ENABLED")
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__SECURITY__ALLOWED_HOSTS")
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__SECURITY__ALLOWED_HOSTS_ARE_REGEX")
@@ -1313,6 +1571,8 @@ func TestHTTPDBindingsFromEnv(t *testing.T) {
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__BRANDING__WEB_CLIENT__EXTRA_CSS")
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__CERTIFICATE_FILE")
 		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__CERTIFICATE_KEY_FILE")
+		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__PUBLIC_INDEX__FOLDERS")
+		os.Unsetenv("SFTPGO_HTTPD__BINDINGS__2__PUBLIC_INDEX__RENDER_INDEX_HTML")
 	})
 
 	err := config.LoadConfig(configDir, "")
@@ -1393,6 +1653,9 @@ func TestHTTPDBindingsFromEnv(t *testing.T) {
 	require.Equal(t, "field2", bindings[2].OIDC.CustomFields[1])
 	require.True(t, bindings[2].OIDC.InsecureSkipSignatureCheck)
 	require.True(t, bindings[2].OIDC.Debug)
+	require.True(t, bindings[2].OIDC.DeviceAuth)
+	require.Equal(t, 60, bindings[2].OIDC.DeviceAuthTokenLifetime)
+	require.Equal(t, 24, bindings[2].OIDC.DeviceAuthRefreshTokenLifetime)
 	require.True(t, bindings[2].Security.Enabled)
 	require.Len(t, bindings[2].Security.AllowedHosts, 2)
 	require.Equal(t, "*.example.com", bindings[2].Security.AllowedHosts[0])
@@ -1423,6 +1686,9 @@ func TestHTTPDBindingsFromEnv(t *testing.T) {
 	require.Equal(t, "2.css", bindings[2].Branding.WebClient.ExtraCSS[1])
 	require.Equal(t, "httpd.crt", bindings[2].CertificateFile)
 	require.Equal(t, "httpd.key", bindings[2].CertificateKeyFile)
+	require.Equal(t, []string{"releases", "docs"}, bindings[2].PublicIndex.Folders)
+	require.True(t, bindings[2].PublicIndex.RenderIndexHTML)
+	require.Len(t, bindings[0].PublicIndex.Folders, 0)
 }
 
 func TestHTTPClientCertificatesFromEnv(t *testing.T) {
@@ -0,0 +1,170 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const minutesInDay = 24 * 60
+
+// AccessTimeWindow defines a time window during which the access is allowed
+type AccessTimeWindow struct {
+	// Days of the week the window applies to, 0 is Sunday. Empty means every day
+	DaysOfWeek []int `json:"days_of_week,omitempty"`
+	// Window start time in "HH:MM" format
+	From string `json:"from"`
+	// Window end time in "HH:MM" format. If it is not greater than the start time
+	// the window ends the next day
+	To string `json:"to"`
+}
+
+func (w *AccessTimeWindow) isDayAllowed(day time.Weekday) bool {
+	return len(w.DaysOfWeek) == 0 || util.Contains(w.DaysOfWeek, int(day))
+}
+
+func (w *AccessTimeWindow) isAllowed(t time.Time) bool {
+	from, err := parseAccessTime(w.From)
+	if err != nil {
+		return false
+	}
+	to, err := parseAccessTime(w.To)
+	if err != nil {
+		return false
+	}
+	current := t.Hour()*60 + t.Minute()
+	if from < to {
+		return w.isDayAllowed(t.Weekday()) && current >= from && current < to
+	}
+	// the window ends the next day
+	if current >= from && w.isDayAllowed(t.Weekday()) {
+		return true
+	}
+	return current < to && w.isDayAllowed(t.AddDate(0, 0, -1).Weekday())
+}
+
+// AccessSchedule defines the time windows during which a user is allowed to login
+type AccessSchedule struct {
+	// Allowed time windows. Empty means no restrictions
+	Windows []AccessTimeWindow `json:"windows,omitempty"`
+	// IANA time zone name, for example "Europe/Rome". Empty means UTC
+	Timezone string `json:"timezone,omitempty"`
+	// If enabled, the active sessions are terminated when the time window closes
+	TerminateSessions bool `json:"terminate_sessions,omitempty"`
+}
+
+// IsEmpty returns true if no time window is defined
+func (s *AccessSchedule) IsEmpty() bool {
+	return len(s.Windows) == 0
+}
+
+// IsAccessAllowed returns true if the specified time is within an allowed time window
+func (s *AccessSchedule) IsAccessAllowed(t time.Time) bool {
+	if s.IsEmpty() {
+		return true
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		providerLog(logger.LevelError, "unable to load access schedule time zone %q: %v", s.Timezone, err)
+		return false
+	}
+	t = t.In(loc)
+	for idx := range s.Windows {
+		if s.Windows[idx].isAllowed(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AccessSchedule) getACopy() AccessSchedule {
+	windows := make([]AccessTimeWindow, 0, len(s.Windows))
+	for _, w := range s.Windows {
+		days := make([]int, len(w.DaysOfWeek))
+		copy(days, w.DaysOfWeek)
+		windows = append(windows, AccessTimeWindow{
+			DaysOfWeek: days,
+			From:       w.From,
+			To:         w.To,
+		})
+	}
+	return AccessSchedule{
+		Windows:           windows,
+		Timezone:          s.Timezone,
+		TerminateSessions: s.TerminateSessions,
+	}
+}
+
+func (s *AccessSchedule) validate() error {
+	if s.IsEmpty() {
+		s.Timezone = ""
+		s.TerminateSessions = false
+		return nil
+	}
+	s.Timezone = strings.TrimSpace(s.Timezone)
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return util.NewValidationError(fmt.Sprintf("invalid access schedule time zone %q: %v", s.Timezone, err))
+	}
+	for idx := range s.Windows {
+		w := &s.Windows[idx]
+		w.From = strings.TrimSpace(w.From)
+		w.To = strings.TrimSpace(w.To)
+		if from, err := parseAccessTime(w.From); err != nil || from == minutesInDay {
+			return util.NewValidationError(fmt.Sprintf("access window #%d: invalid start time %q", idx+1, w.From))
+		}
+		if _, err := parseAccessTime(w.To); err != nil {
+			return util.NewValidationError(fmt.Sprintf("access window #%d: invalid end time %q", idx+1, w.To))
+		}
+		var days []int
+		for _, day := range w.DaysOfWeek {
+			if day < int(time.Sunday) || day > int(time.Saturday) {
+				return util.NewValidationError(fmt.Sprintf("access window #%d: invalid day of week %d", idx+1, day))
+			}
+			if !util.Contains(days, day) {
+				days = append(days, day)
+			}
+		}
+		w.DaysOfWeek = days
+	}
+	return nil
+}
+
+// parseAccessTime parses a time in "HH:MM" format and returns the minutes since midnight.
+// "24:00" is accepted to define the end of the day
+func parseAccessTime(val string) (int, error) {
+	hours, minutes, ok := strings.Cut(val, ":")
+	if !ok || len(hours) != 2 || len(minutes) != 2 {
+		return 0, fmt.Errorf("invalid time %q", val)
+	}
+	h, err := strconv.Atoi(hours)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hours in time %q: %w", val, err)
+	}
+	m, err := strconv.Atoi(minutes)
+	if err != nil {
+		return 0, fmt.Errorf("invalid minutes in time %q: %w", val, err)
+	}
+	result := h*60 + m
+	if h < 0 || m < 0 || m > 59 || result > minutesInDay {
+		return 0, fmt.Errorf("invalid time %q", val)
+	}
+	return result, nil
+}
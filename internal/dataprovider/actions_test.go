@@ -0,0 +1,89 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+
+	"github.com/drakkan/sftpgo/v2/internal/kafkaclient"
+)
+
+func TestKafkaProviderHook(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.SeedTopics(1, "sftpgo-provider"), kfake.EnableSASL(),
+		kfake.Superuser("SCRAM-SHA-512", "kafka_user", "kafka_password"))
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	oldHook := config.Actions.Hook
+	t.Cleanup(func() {
+		config.Actions.Hook = oldHook
+	})
+
+	config.Actions.Hook = "kafka://kafka_user:kafka_password@" + strings.Join(cluster.ListenAddrs(), ",") +
+		"/sftpgo-provider?acks=all&sasl_mechanism=SCRAM-SHA-512"
+	c, topic, err := getKafkaProviderHookConfig(config.Actions.Hook)
+	require.NoError(t, err)
+	assert.Equal(t, "sftpgo-provider", topic)
+	assert.Equal(t, "kafka_user", c.Username)
+	assert.Equal(t, "kafka_password", c.Password)
+	assert.Equal(t, kafkaclient.AcksAll, c.Acks)
+	assert.Equal(t, kafkaclient.SASLScramSHA512, c.SASLMechanism)
+
+	err = publishProviderEventToKafka(operationAdd, "admin", "127.0.0.1", actionObjectUser, "user1", "role1",
+		[]byte(`{"username":"user1"}`))
+	require.NoError(t, err)
+
+	client, err := kgo.NewClient(kgo.SeedBrokers(cluster.ListenAddrs()...), kgo.ConsumeTopics(topic),
+		kgo.SASL(scram.Auth{User: "kafka_user", Pass: "kafka_password"}.AsSha512Mechanism()))
+	require.NoError(t, err)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	records := client.PollFetches(ctx).Records()
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "user/user1", string(records[0].Key))
+		var record providerEventRecord
+		err = json.Unmarshal(records[0].Value, &record)
+		require.NoError(t, err)
+		assert.Equal(t, operationAdd, record.Action)
+		assert.Equal(t, "admin", record.Username)
+		assert.Equal(t, "127.0.0.1", record.IP)
+		assert.Equal(t, actionObjectUser, record.ObjectType)
+		assert.Equal(t, "user1", record.ObjectName)
+		assert.Equal(t, "role1", record.Role)
+		assert.JSONEq(t, `{"username":"user1"}`, string(record.Object))
+	}
+
+	_, _, err = getKafkaProviderHookConfig("kafka://127.0.0.1:9092/topic?acks=invalid")
+	assert.Error(t, err)
+	_, _, err = getKafkaProviderHookConfig("kafka://127.0.0.1:9092/topic?sasl_mechanism=invalid")
+	assert.Error(t, err)
+	config.Actions.Hook = "kafka://127.0.0.1:9092/invalid topic"
+	err = publishProviderEventToKafka(operationDelete, "admin", "127.0.0.1", actionObjectUser, "user1", "", nil)
+	assert.Error(t, err)
+	assert.True(t, isKafkaProviderHook("kafkas://127.0.0.1:9093/topic"))
+	assert.False(t, isKafkaProviderHook("http://127.0.0.1:8080/hook"))
+}
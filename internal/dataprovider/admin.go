@@ -15,6 +15,9 @@
 package dataprovider
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -59,6 +62,13 @@ const (
 	PermAdminManageEventRules = "manage_event_rules"
 	PermAdminManageRoles      = "manage_roles"
 	PermAdminManageIPLists    = "manage_ip_lists"
+	PermAdminViewFolders      = "view_folders"
+	PermAdminViewGroups       = "view_groups"
+	PermAdminViewAdmins       = "view_admins"
+	PermAdminViewRoles        = "view_roles"
+	PermAdminViewEventRules   = "view_event_rules"
+	PermAdminViewIPLists      = "view_ip_lists"
+	PermAdminImpersonateUsers = "impersonate_users"
 )
 
 const (
@@ -76,9 +86,12 @@ var (
 		PermAdminCloseConnections, PermAdminViewServerStatus, PermAdminManageAdmins, PermAdminManageRoles,
 		PermAdminManageEventRules, PermAdminManageAPIKeys, PermAdminQuotaScans, PermAdminManageSystem,
 		PermAdminManageDefender, PermAdminViewDefender, PermAdminManageIPLists, PermAdminRetentionChecks,
-		PermAdminMetadataChecks, PermAdminViewEvents}
+		PermAdminMetadataChecks, PermAdminViewEvents, PermAdminViewFolders, PermAdminViewGroups,
+		PermAdminViewAdmins, PermAdminViewRoles, PermAdminViewEventRules, PermAdminViewIPLists,
+		PermAdminImpersonateUsers}
 	forbiddenPermsForRoleAdmins = []string{PermAdminAny, PermAdminManageAdmins, PermAdminManageSystem,
-		PermAdminManageEventRules, PermAdminManageIPLists, PermAdminManageRoles}
+		PermAdminManageEventRules, PermAdminManageIPLists, PermAdminManageRoles, PermAdminViewAdmins,
+		PermAdminViewRoles, PermAdminViewEventRules, PermAdminViewIPLists}
 )
 
 // AdminTOTPConfig defines the time-based one time password configuration
@@ -205,8 +218,26 @@ type AdminFilters struct {
 	// Recovery codes to use if the user loses access to their second factor auth device.
 	// Each code can only be used once, you should use these codes to login and disable or
 	// reset 2FA for your account
-	RecoveryCodes []RecoveryCode   `json:"recovery_codes,omitempty"`
-	Preferences   AdminPreferences `json:"preferences"`
+	RecoveryCodes []RecoveryCode `json:"recovery_codes,omitempty"`
+	// WebAuthn security keys and passkeys registered by the admin
+	WebAuthnCredentials WebAuthnCredentials `json:"webauthn_credentials,omitempty"`
+	// If set, the admin must login to the WebAdmin using a WebAuthn credential,
+	// as passwordless primary factor or as second factor. Password only and
+	// TOTP logins are not allowed. It requires at least one registered credential
+	RequireWebAuthn bool             `json:"require_webauthn,omitempty"`
+	Preferences     AdminPreferences `json:"preferences"`
+	// Names of the admin roles assigned to this admin. The permissions granted
+	// by the roles are added to the ones directly assigned
+	AdminRoles []string `json:"admin_roles,omitempty"`
+	// User selectors in the form "attribute=value". If set, the admin can only
+	// view and manage the users matching the selectors. Selectors for the same
+	// attribute are evaluated in OR, selectors for different attributes in AND
+	UserSelectors []string `json:"user_selectors,omitempty"`
+	// TLS certificate attribute to map to the admin username for mutual TLS
+	// authentication on the HTTP listeners. Empty or "None" means disabled
+	TLSUsername sdk.TLSUsername `json:"tls_username,omitempty"`
+	// If set, both the TLS certificate and the password are required to login
+	TLSCertAndPassword bool `json:"tls_cert_and_password,omitempty"`
 }
 
 // AdminGroupMappingOptions defines the options for admin/group mapping
@@ -328,7 +359,7 @@ func (a *Admin) validateRecoveryCodes() error {
 
 func (a *Admin) validatePermissions() error {
 	a.Permissions = util.RemoveDuplicates(a.Permissions, false)
-	if len(a.Permissions) == 0 {
+	if len(a.Permissions) == 0 && len(a.Filters.AdminRoles) == 0 {
 		return util.NewI18nError(
 			util.NewValidationError("please grant some permissions to this admin"),
 			util.I18nErrorPermissionsRequired,
@@ -399,6 +430,24 @@ func (a *Admin) validate() error {
 	if err := a.validateRecoveryCodes(); err != nil {
 		return util.NewI18nError(err, util.I18nErrorRecoveryCodesInvalid)
 	}
+	if err := a.Filters.WebAuthnCredentials.validate(); err != nil {
+		return util.NewI18nError(err, util.I18nErrorWebAuthnInvalid)
+	}
+	if a.Filters.RequireWebAuthn && len(a.Filters.WebAuthnCredentials) == 0 {
+		return util.NewI18nError(
+			util.NewValidationError("webauthn cannot be required for an admin without registered security keys"),
+			util.I18nErrorWebAuthnRequireNoKeys,
+		)
+	}
+	if a.Filters.TLSUsername != "" && !util.Contains(validTLSUsernames, string(a.Filters.TLSUsername)) {
+		return util.NewValidationError(fmt.Sprintf("invalid TLS username: %q", a.Filters.TLSUsername))
+	}
+	if a.Filters.TLSCertAndPassword && !a.IsTLSVerificationEnabled() {
+		return util.NewI18nError(
+			util.NewValidationError("a TLS username is required to enforce certificate and password authentication"),
+			util.I18nErrorTLSCertAndPwdNoUsername,
+		)
+	}
 	if config.NamingRules&1 == 0 && !usernameRegex.MatchString(a.Username) {
 		return util.NewI18nError(
 			util.NewValidationError(fmt.Sprintf("username %q is not valid, the following characters are allowed: a-zA-Z0-9-_.~", a.Username)),
@@ -417,6 +466,9 @@ func (a *Admin) validate() error {
 			util.I18nErrorInvalidEmail,
 		)
 	}
+	if err := a.validateUserSelectors(); err != nil {
+		return err
+	}
 	a.Filters.AllowList = util.RemoveDuplicates(a.Filters.AllowList, false)
 	for _, IPMask := range a.Filters.AllowList {
 		_, _, err := net.ParseCIDR(IPMask)
@@ -431,6 +483,100 @@ func (a *Admin) validate() error {
 	return a.validateGroups()
 }
 
+func (a *Admin) validateUserSelectors() error {
+	selectors := make([]string, 0, len(a.Filters.UserSelectors))
+	for _, selector := range a.Filters.UserSelectors {
+		name, value, ok := strings.Cut(selector, "=")
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+		if !ok || value == "" || !attributeNameRegex.MatchString(name) {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("invalid user selector %q, the expected format is attribute=value", selector)),
+				util.I18nErrorInvalidUserSelector,
+			)
+		}
+		selectors = append(selectors, name+"="+value)
+	}
+	a.Filters.UserSelectors = util.RemoveDuplicates(selectors, false)
+	if len(a.Filters.UserSelectors) > maxAttributes {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("too many user selectors: %d, max allowed: %d",
+				len(a.Filters.UserSelectors), maxAttributes)),
+			util.I18nErrorInvalidUserSelector,
+		)
+	}
+	return nil
+}
+
+// GetUserSelectorsAsString returns the user selectors as a comma separated string
+func (a *Admin) GetUserSelectorsAsString() string {
+	return strings.Join(a.Filters.UserSelectors, ", ")
+}
+
+// MatchUserSelectors returns true if the specified attributes match the given
+// selectors. Selectors for the same attribute are evaluated in OR, selectors for
+// different attributes in AND. Empty selectors match everything
+func MatchUserSelectors(selectors []string, attributes map[string]string) bool {
+	if len(selectors) == 0 {
+		return true
+	}
+	allowed := make(map[string][]string)
+	for _, selector := range selectors {
+		name, value, _ := strings.Cut(selector, "=")
+		allowed[name] = append(allowed[name], value)
+	}
+	for name, values := range allowed {
+		if !util.Contains(values, attributes[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsUserSelectorsScopeIncluded returns true if the scope defined by the selectors
+// is included in the scope defined by the parent selectors
+func IsUserSelectorsScopeIncluded(parent, selectors []string) bool {
+	if len(parent) == 0 {
+		return true
+	}
+	allowed := make(map[string][]string)
+	for _, selector := range selectors {
+		name, value, _ := strings.Cut(selector, "=")
+		allowed[name] = append(allowed[name], value)
+	}
+	for _, selector := range parent {
+		name, _, _ := strings.Cut(selector, "=")
+		values, ok := allowed[name]
+		if !ok {
+			return false
+		}
+		for _, value := range values {
+			if !util.Contains(parent, name+"="+value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// GetUserSelectorDefaults returns the attributes that can be automatically set
+// for new users to match the given selectors, they are the attributes with a
+// single allowed value
+func GetUserSelectorDefaults(selectors []string) map[string]string {
+	allowed := make(map[string][]string)
+	for _, selector := range selectors {
+		name, value, _ := strings.Cut(selector, "=")
+		allowed[name] = append(allowed[name], value)
+	}
+	result := make(map[string]string)
+	for name, values := range allowed {
+		if len(values) == 1 {
+			result[name] = values[0]
+		}
+	}
+	return result
+}
+
 // GetGroupsAsString returns the user's groups as a string
 func (a *Admin) GetGroupsAsString() string {
 	if len(a.Groups) == 0 {
@@ -569,10 +715,41 @@ func (a *Admin) SetNilSecretsIfEmpty() {
 
 // HasPermission returns true if the admin has the specified permission
 func (a *Admin) HasPermission(perm string) bool {
-	if util.Contains(a.Permissions, PermAdminAny) {
-		return true
+	return HasAdminPermission(a.Permissions, perm)
+}
+
+// GetEffectivePermissions returns the permissions directly assigned to the admin
+// combined with the ones granted by the assigned admin roles.
+// Role admins cannot get the permissions forbidden for them from admin roles
+func (a *Admin) GetEffectivePermissions() []string {
+	if len(a.Filters.AdminRoles) == 0 {
+		return a.Permissions
+	}
+	configs, err := provider.getConfigs()
+	if err != nil {
+		providerLog(logger.LevelError, "unable to get configs to resolve admin roles for %q: %v", a.Username, err)
+		return a.Permissions
 	}
-	return util.Contains(a.Permissions, perm)
+	permissions := make([]string, len(a.Permissions))
+	copy(permissions, a.Permissions)
+	for _, name := range a.Filters.AdminRoles {
+		role := configs.getAdminRole(name)
+		if role == nil {
+			providerLog(logger.LevelWarn, "admin role %q assigned to %q not found", name, a.Username)
+			continue
+		}
+		for _, perm := range role.GetAdminPermissions() {
+			if a.Role != "" && util.Contains(forbiddenPermsForRoleAdmins, perm) {
+				continue
+			}
+			permissions = append(permissions, perm)
+		}
+	}
+	permissions = util.RemoveDuplicates(permissions, false)
+	if util.Contains(permissions, PermAdminAny) {
+		return []string{PermAdminAny}
+	}
+	return permissions
 }
 
 // GetPermissionsAsString returns permission as string
@@ -600,13 +777,47 @@ func (a *Admin) GetValidPerms() []string {
 
 // CanManageMFA returns true if the admin can add a multi-factor authentication configuration
 func (a *Admin) CanManageMFA() bool {
-	return len(mfa.GetAvailableTOTPConfigs()) > 0
+	return len(mfa.GetAvailableTOTPConfigs()) > 0 || mfa.IsWebAuthnEnabled()
+}
+
+// HasWebAuthn returns true if the admin can login using a registered WebAuthn credential
+func (a *Admin) HasWebAuthn() bool {
+	return mfa.IsWebAuthnEnabled() && len(a.Filters.WebAuthnCredentials) > 0
+}
+
+// MustUseWebAuthn returns true if the admin can only login using a WebAuthn credential
+func (a *Admin) MustUseWebAuthn() bool {
+	return a.Filters.RequireWebAuthn && a.HasWebAuthn()
+}
+
+// IsTLSVerificationEnabled returns true if the admin can authenticate using a TLS certificate
+func (a *Admin) IsTLSVerificationEnabled() bool {
+	return a.Filters.TLSUsername == sdk.TLSUsernameCN
+}
+
+func (a *Admin) checkTLSCertificate(tlsCert *x509.Certificate) error {
+	if !a.IsTLSVerificationEnabled() {
+		return fmt.Errorf("TLS certificate authentication is not enabled for admin %q", a.Username)
+	}
+	if tlsCert == nil {
+		return fmt.Errorf("a TLS certificate is required for admin %q", a.Username)
+	}
+	if tlsCert.Subject.CommonName != a.Username {
+		return fmt.Errorf("CN %q does not match username %q", tlsCert.Subject.CommonName, a.Username)
+	}
+	return nil
 }
 
 // GetSignature returns a signature for this admin.
-// It will change after an update
+// It will change after an update or if the permissions granted by the
+// assigned admin roles change
 func (a *Admin) GetSignature() string {
-	return strconv.FormatInt(a.UpdatedAt, 10)
+	signature := strconv.FormatInt(a.UpdatedAt, 10)
+	if len(a.Filters.AdminRoles) == 0 {
+		return signature
+	}
+	h := sha256.Sum256([]byte(strings.Join(a.GetEffectivePermissions(), ",")))
+	return signature + "_" + hex.EncodeToString(h[:8])
 }
 
 func (a *Admin) getACopy() Admin {
@@ -620,6 +831,10 @@ func (a *Admin) getACopy() Admin {
 	filters.TOTPConfig.ConfigName = a.Filters.TOTPConfig.ConfigName
 	filters.TOTPConfig.Secret = a.Filters.TOTPConfig.Secret.Clone()
 	copy(filters.AllowList, a.Filters.AllowList)
+	filters.AdminRoles = make([]string, len(a.Filters.AdminRoles))
+	copy(filters.AdminRoles, a.Filters.AdminRoles)
+	filters.UserSelectors = make([]string, len(a.Filters.UserSelectors))
+	copy(filters.UserSelectors, a.Filters.UserSelectors)
 	filters.RecoveryCodes = make([]RecoveryCode, 0)
 	for _, code := range a.Filters.RecoveryCodes {
 		if code.Secret == nil {
@@ -630,6 +845,10 @@ func (a *Admin) getACopy() Admin {
 			Used:   code.Used,
 		})
 	}
+	filters.WebAuthnCredentials = a.Filters.WebAuthnCredentials.getACopy()
+	filters.RequireWebAuthn = a.Filters.RequireWebAuthn
+	filters.TLSUsername = a.Filters.TLSUsername
+	filters.TLSCertAndPassword = a.Filters.TLSCertAndPassword
 	filters.Preferences = AdminPreferences{
 		HideUserPageSections:   a.Filters.Preferences.HideUserPageSections,
 		DefaultUsersExpiration: a.Filters.Preferences.DefaultUsersExpiration,
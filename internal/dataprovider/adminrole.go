@@ -0,0 +1,376 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// Object types supported in the admin permission matrix
+const (
+	AdminPermObjectUsers        = "users"
+	AdminPermObjectFolders      = "folders"
+	AdminPermObjectGroups       = "groups"
+	AdminPermObjectAdmins       = "admins"
+	AdminPermObjectRoles        = "roles"
+	AdminPermObjectEventRules   = "event_rules"
+	AdminPermObjectEventLogs    = "event_logs"
+	AdminPermObjectIPLists      = "ip_lists"
+	AdminPermObjectConnections  = "connections"
+	AdminPermObjectDefender     = "defender"
+	AdminPermObjectAPIKeys      = "api_keys"
+	AdminPermObjectServerStatus = "server_status"
+	AdminPermObjectQuotaScans   = "quota_scans"
+	AdminPermObjectRetention    = "retention_checks"
+	AdminPermObjectMetadata     = "metadata_checks"
+	AdminPermObjectSystem       = "system"
+)
+
+// Verbs supported in the admin permission matrix
+const (
+	AdminPermVerbView   = "view"
+	AdminPermVerbAdd    = "add"
+	AdminPermVerbEdit   = "edit"
+	AdminPermVerbDelete = "delete"
+	AdminPermVerbManage = "manage"
+	AdminPermVerbClose  = "close"
+	AdminPermVerbRun    = "run"
+)
+
+const maxAdminRoleNameLength = 64
+
+var (
+	// adminPermissionMatrix maps the object types to the supported verbs and the
+	// related admin permissions
+	adminPermissionMatrix = map[string]map[string]string{
+		AdminPermObjectUsers: {
+			AdminPermVerbView:   PermAdminViewUsers,
+			AdminPermVerbAdd:    PermAdminAddUsers,
+			AdminPermVerbEdit:   PermAdminChangeUsers,
+			AdminPermVerbDelete: PermAdminDeleteUsers,
+		},
+		AdminPermObjectFolders: {
+			AdminPermVerbView:   PermAdminViewFolders,
+			AdminPermVerbManage: PermAdminManageFolders,
+		},
+		AdminPermObjectGroups: {
+			AdminPermVerbView:   PermAdminViewGroups,
+			AdminPermVerbManage: PermAdminManageGroups,
+		},
+		AdminPermObjectAdmins: {
+			AdminPermVerbView:   PermAdminViewAdmins,
+			AdminPermVerbManage: PermAdminManageAdmins,
+		},
+		AdminPermObjectRoles: {
+			AdminPermVerbView:   PermAdminViewRoles,
+			AdminPermVerbManage: PermAdminManageRoles,
+		},
+		AdminPermObjectEventRules: {
+			AdminPermVerbView:   PermAdminViewEventRules,
+			AdminPermVerbManage: PermAdminManageEventRules,
+		},
+		AdminPermObjectEventLogs: {
+			AdminPermVerbView: PermAdminViewEvents,
+		},
+		AdminPermObjectIPLists: {
+			AdminPermVerbView:   PermAdminViewIPLists,
+			AdminPermVerbManage: PermAdminManageIPLists,
+		},
+		AdminPermObjectConnections: {
+			AdminPermVerbView:  PermAdminViewConnections,
+			AdminPermVerbClose: PermAdminCloseConnections,
+		},
+		AdminPermObjectDefender: {
+			AdminPermVerbView:   PermAdminViewDefender,
+			AdminPermVerbManage: PermAdminManageDefender,
+		},
+		AdminPermObjectAPIKeys: {
+			AdminPermVerbManage: PermAdminManageAPIKeys,
+		},
+		AdminPermObjectServerStatus: {
+			AdminPermVerbView: PermAdminViewServerStatus,
+		},
+		AdminPermObjectQuotaScans: {
+			AdminPermVerbRun: PermAdminQuotaScans,
+		},
+		AdminPermObjectRetention: {
+			AdminPermVerbRun: PermAdminRetentionChecks,
+		},
+		AdminPermObjectMetadata: {
+			AdminPermVerbRun: PermAdminMetadataChecks,
+		},
+		AdminPermObjectSystem: {
+			AdminPermVerbManage: PermAdminManageSystem,
+		},
+	}
+	// impliedAdminPerms maps the view permissions to the manage permissions implying them
+	impliedAdminPerms = map[string]string{
+		PermAdminViewFolders:    PermAdminManageFolders,
+		PermAdminViewGroups:     PermAdminManageGroups,
+		PermAdminViewAdmins:     PermAdminManageAdmins,
+		PermAdminViewRoles:      PermAdminManageRoles,
+		PermAdminViewEventRules: PermAdminManageEventRules,
+		PermAdminViewIPLists:    PermAdminManageIPLists,
+	}
+)
+
+// AdminPermissionEntry defines a supported object type/verb pair
+type AdminPermissionEntry struct {
+	Object string
+	Verb   string
+}
+
+// Value returns the entry as "object:verb"
+func (e *AdminPermissionEntry) Value() string {
+	return e.Object + ":" + e.Verb
+}
+
+// GetAdminPermissionEntries returns the object type/verb pairs supported in
+// the admin permission matrix
+func GetAdminPermissionEntries() []AdminPermissionEntry {
+	objects := make([]string, 0, len(adminPermissionMatrix))
+	for object := range adminPermissionMatrix {
+		objects = append(objects, object)
+	}
+	sort.Strings(objects)
+	var result []AdminPermissionEntry
+	for _, object := range objects {
+		verbs := make([]string, 0, len(adminPermissionMatrix[object]))
+		for verb := range adminPermissionMatrix[object] {
+			verbs = append(verbs, verb)
+		}
+		sort.Strings(verbs)
+		for _, verb := range verbs {
+			result = append(result, AdminPermissionEntry{Object: object, Verb: verb})
+		}
+	}
+	return result
+}
+
+// HasAdminPermission returns true if the specified permissions grant perm.
+// The manage permissions imply the related view permissions
+func HasAdminPermission(permissions []string, perm string) bool {
+	if util.Contains(permissions, PermAdminAny) || util.Contains(permissions, perm) {
+		return true
+	}
+	if implied, ok := impliedAdminPerms[perm]; ok {
+		return util.Contains(permissions, implied)
+	}
+	return false
+}
+
+// AdminRole defines a set of admin permissions expressed as a matrix of
+// object types and allowed verbs. Roles can be assigned to admins to grant
+// them the related permissions
+type AdminRole struct {
+	// Unique role name
+	Name string `json:"name"`
+	// optional description
+	Description string `json:"description,omitempty"`
+	// Map an object type to the allowed verbs, for example
+	// {"users": ["view", "edit"], "folders": ["view"]}
+	Permissions map[string][]string `json:"permissions"`
+}
+
+func (r *AdminRole) validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return util.NewI18nError(util.NewValidationError("admin role name is mandatory"), util.I18nErrorNameRequired)
+	}
+	if len(r.Name) > maxAdminRoleNameLength || !usernameRegex.MatchString(r.Name) {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("admin role name %q is not valid", r.Name)),
+			util.I18nErrorInvalidName,
+		)
+	}
+	if len(r.Permissions) == 0 {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("please grant some permissions to the admin role %q", r.Name)),
+			util.I18nErrorPermissionsRequired,
+		)
+	}
+	for object, verbs := range r.Permissions {
+		allowed, ok := adminPermissionMatrix[object]
+		if !ok {
+			return util.NewValidationError(fmt.Sprintf("admin role %q: invalid object type %q", r.Name, object))
+		}
+		verbs = util.RemoveDuplicates(verbs, true)
+		if len(verbs) == 0 {
+			return util.NewValidationError(fmt.Sprintf("admin role %q: no verb defined for object type %q",
+				r.Name, object))
+		}
+		for _, verb := range verbs {
+			if _, ok := allowed[verb]; !ok {
+				return util.NewValidationError(fmt.Sprintf("admin role %q: invalid verb %q for object type %q",
+					r.Name, verb, object))
+			}
+		}
+		r.Permissions[object] = verbs
+	}
+	return nil
+}
+
+// GetAdminPermissions returns the admin permissions granted by this role
+func (r *AdminRole) GetAdminPermissions() []string {
+	var result []string
+	for object, verbs := range r.Permissions {
+		for _, verb := range verbs {
+			if perm, ok := adminPermissionMatrix[object][verb]; ok {
+				result = append(result, perm)
+			}
+		}
+	}
+	return result
+}
+
+// HasPermission returns true if the role allows the specified object type/verb pair
+func (r *AdminRole) HasPermission(object, verb string) bool {
+	return util.Contains(r.Permissions[object], verb)
+}
+
+func (r *AdminRole) getACopy() AdminRole {
+	permissions := make(map[string][]string)
+	for k, v := range r.Permissions {
+		verbs := make([]string, len(v))
+		copy(verbs, v)
+		permissions[k] = verbs
+	}
+	return AdminRole{
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: permissions,
+	}
+}
+
+func validateAdminRoles(roles []AdminRole) error {
+	names := make(map[string]bool)
+	for idx := range roles {
+		role := &roles[idx]
+		if err := role.validate(); err != nil {
+			return err
+		}
+		if names[role.Name] {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("duplicated admin role %q", role.Name)),
+				util.I18nErrorDuplicatedName,
+			)
+		}
+		names[role.Name] = true
+	}
+	return nil
+}
+
+// checkAdminRoles checks that the admin roles assigned to the specified admin exist
+func checkAdminRoles(admin *Admin) error {
+	admin.Filters.AdminRoles = util.RemoveDuplicates(admin.Filters.AdminRoles, true)
+	if len(admin.Filters.AdminRoles) == 0 {
+		return nil
+	}
+	configs, err := provider.getConfigs()
+	if err != nil {
+		return err
+	}
+	for _, name := range admin.Filters.AdminRoles {
+		if configs.getAdminRole(name) == nil {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("admin role %q does not exist", name)),
+				util.I18nErrorAdminRoleNotFound,
+			)
+		}
+	}
+	return nil
+}
+
+// GetAdminRoles returns the defined admin roles
+func GetAdminRoles() ([]AdminRole, error) {
+	configs, err := provider.getConfigs()
+	if err != nil {
+		return nil, err
+	}
+	roles := make([]AdminRole, 0, len(configs.AdminRoles))
+	roles = append(roles, configs.AdminRoles...)
+	return roles, nil
+}
+
+// AdminRoleExists returns the admin role with the given name if it exists
+func AdminRoleExists(name string) (AdminRole, error) {
+	configs, err := provider.getConfigs()
+	if err != nil {
+		return AdminRole{}, err
+	}
+	role := configs.getAdminRole(name)
+	if role == nil {
+		return AdminRole{}, util.NewRecordNotFoundError(fmt.Sprintf("admin role %q does not exist", name))
+	}
+	return *role, nil
+}
+
+// AddAdminRole adds a new admin role
+func AddAdminRole(role *AdminRole, executor, ipAddress, executorRole string) error {
+	configs, err := provider.getConfigs()
+	if err != nil {
+		return err
+	}
+	if configs.getAdminRole(strings.TrimSpace(role.Name)) != nil {
+		return util.NewI18nError(
+			fmt.Errorf("%w: admin role %q already exists", ErrDuplicatedKey, role.Name),
+			util.I18nErrorDuplicatedName,
+		)
+	}
+	configs.AdminRoles = append(configs.AdminRoles, *role)
+	if err := UpdateConfigs(&configs, executor, ipAddress, executorRole); err != nil {
+		return err
+	}
+	role.Name = strings.TrimSpace(role.Name)
+	return nil
+}
+
+// UpdateAdminRole updates an existing admin role
+func UpdateAdminRole(role *AdminRole, executor, ipAddress, executorRole string) error {
+	configs, err := provider.getConfigs()
+	if err != nil {
+		return err
+	}
+	existing := configs.getAdminRole(role.Name)
+	if existing == nil {
+		return util.NewRecordNotFoundError(fmt.Sprintf("admin role %q does not exist", role.Name))
+	}
+	*existing = *role
+	return UpdateConfigs(&configs, executor, ipAddress, executorRole)
+}
+
+// DeleteAdminRole deletes an existing admin role.
+// The admins with the deleted role assigned lose the related permissions
+func DeleteAdminRole(name, executor, ipAddress, executorRole string) error {
+	configs, err := provider.getConfigs()
+	if err != nil {
+		return err
+	}
+	roles := make([]AdminRole, 0, len(configs.AdminRoles))
+	for _, role := range configs.AdminRoles {
+		if role.Name != name {
+			roles = append(roles, role)
+		}
+	}
+	if len(roles) == len(configs.AdminRoles) {
+		return util.NewRecordNotFoundError(fmt.Sprintf("admin role %q does not exist", name))
+	}
+	configs.AdminRoles = roles
+	return UpdateConfigs(&configs, executor, ipAddress, executorRole)
+}
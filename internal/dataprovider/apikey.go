@@ -17,6 +17,8 @@ package dataprovider
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"strings"
 	"time"
 
@@ -38,6 +40,100 @@ const (
 	APIKeyScopeUser
 )
 
+var apiKeyEndpointMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions}
+
+// APIKeyEndpoint defines a REST API endpoint an API key can access
+type APIKeyEndpoint struct {
+	// Path relative to the REST API base path, for example "/users". A trailing "*"
+	// matches any path with the specified prefix, for example "/users/*"
+	Path string `json:"path"`
+	// Allowed HTTP methods, for example "GET". Empty means any method
+	Methods []string `json:"methods,omitempty"`
+}
+
+func (e *APIKeyEndpoint) matches(method, urlPath string) bool {
+	if len(e.Methods) > 0 && !util.Contains(e.Methods, method) {
+		return false
+	}
+	if prefix, ok := strings.CutSuffix(e.Path, "*"); ok {
+		return strings.HasPrefix(urlPath, prefix)
+	}
+	return strings.TrimSuffix(urlPath, "/") == strings.TrimSuffix(e.Path, "/")
+}
+
+// APIKeyFilters defines additional restrictions for an API key
+type APIKeyFilters struct {
+	// Endpoints the API key can access. Empty means any endpoint allowed for the
+	// associated admin or user
+	Endpoints []APIKeyEndpoint `json:"endpoints,omitempty"`
+	// IP addresses or CIDR networks allowed to use the API key. Empty means any
+	AllowedIP []string `json:"allowed_ip,omitempty"`
+	// Hash of the key replaced by the last rotation, it is accepted until the
+	// defined expiration, as unix timestamp in milliseconds
+	PreviousKey          string `json:"previous_key,omitempty"`
+	PreviousKeyExpiresAt int64  `json:"previous_key_expires_at,omitempty"`
+}
+
+func (f *APIKeyFilters) getACopy() APIKeyFilters {
+	endpoints := make([]APIKeyEndpoint, 0, len(f.Endpoints))
+	for _, e := range f.Endpoints {
+		methods := make([]string, len(e.Methods))
+		copy(methods, e.Methods)
+		endpoints = append(endpoints, APIKeyEndpoint{
+			Path:    e.Path,
+			Methods: methods,
+		})
+	}
+	allowedIP := make([]string, len(f.AllowedIP))
+	copy(allowedIP, f.AllowedIP)
+	return APIKeyFilters{
+		Endpoints:            endpoints,
+		AllowedIP:            allowedIP,
+		PreviousKey:          f.PreviousKey,
+		PreviousKeyExpiresAt: f.PreviousKeyExpiresAt,
+	}
+}
+
+func (f *APIKeyFilters) validate() error {
+	var endpoints []APIKeyEndpoint
+	for idx, e := range f.Endpoints {
+		e.Path = strings.TrimSpace(e.Path)
+		if e.Path == "" {
+			continue
+		}
+		if !strings.HasPrefix(e.Path, "/") {
+			return util.NewValidationError(fmt.Sprintf("endpoint #%d: invalid path %q, it must start with \"/\"",
+				idx+1, e.Path))
+		}
+		var methods []string
+		for _, method := range e.Methods {
+			method = strings.ToUpper(strings.TrimSpace(method))
+			if method == "" {
+				continue
+			}
+			if !util.Contains(apiKeyEndpointMethods, method) {
+				return util.NewValidationError(fmt.Sprintf("endpoint #%d: invalid method %q", idx+1, method))
+			}
+			methods = append(methods, method)
+		}
+		e.Methods = util.RemoveDuplicates(methods, false)
+		endpoints = append(endpoints, e)
+	}
+	f.Endpoints = endpoints
+	f.AllowedIP = util.RemoveDuplicates(f.AllowedIP, true)
+	for _, IPMask := range f.AllowedIP {
+		if _, _, err := net.ParseCIDR(IPMask); err != nil {
+			return util.NewValidationError(fmt.Sprintf("could not parse allowed IP entry %q: %v", IPMask, err))
+		}
+	}
+	if f.PreviousKey == "" || f.PreviousKeyExpiresAt < util.GetTimeAsMsSinceEpoch(time.Now()) {
+		f.PreviousKey = ""
+		f.PreviousKeyExpiresAt = 0
+	}
+	return nil
+}
+
 // APIKey defines a SFTPGo API key.
 // API keys can be used as authentication alternative to short lived tokens
 // for REST API
@@ -66,6 +162,8 @@ type APIKey struct {
 	// Admin username associated with this API key.
 	// If empty and the scope is APIKeyScopeAdmin the key is valid for any admin
 	Admin string `json:"admin,omitempty"`
+	// Additional restrictions
+	Filters APIKeyFilters `json:"filters"`
 	// these fields are for internal use
 	userID   int64
 	adminID  int64
@@ -86,6 +184,7 @@ func (k *APIKey) getACopy() APIKey {
 		Description: k.Description,
 		User:        k.User,
 		Admin:       k.Admin,
+		Filters:     k.Filters.getACopy(),
 		userID:      k.userID,
 		adminID:     k.adminID,
 	}
@@ -109,6 +208,7 @@ func (k *APIKey) RenderAsJSON(reload bool) ([]byte, error) {
 // HideConfidentialData hides API key confidential data
 func (k *APIKey) HideConfidentialData() {
 	k.Key = ""
+	k.Filters.PreviousKey = ""
 }
 
 func (k *APIKey) hashKey() error {
@@ -176,7 +276,56 @@ func (k *APIKey) validate() error {
 			return util.NewValidationError(fmt.Sprintf("unable to check API key admin %v: %v", k.Admin, err))
 		}
 	}
-	return nil
+	return k.Filters.validate()
+}
+
+// rotate generates a new key. If gracePeriod is greater than 0, the current
+// key is still accepted for the specified duration
+func (k *APIKey) rotate(gracePeriod time.Duration) error {
+	k.Filters.PreviousKey = ""
+	k.Filters.PreviousKeyExpiresAt = 0
+	if gracePeriod > 0 {
+		k.Filters.PreviousKey = k.Key
+		k.Filters.PreviousKeyExpiresAt = util.GetTimeAsMsSinceEpoch(time.Now().Add(gracePeriod))
+	}
+	k.Key = util.GenerateUniqueID()
+	k.plainKey = k.Key
+	return k.hashKey()
+}
+
+// IsAllowedFromIP returns true if the API key can be used from the specified IP address
+func (k *APIKey) IsAllowedFromIP(ip string) bool {
+	if len(k.Filters.AllowedIP) == 0 {
+		return true
+	}
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+	for _, ipMask := range k.Filters.AllowedIP {
+		_, network, err := net.ParseCIDR(ipMask)
+		if err != nil {
+			continue
+		}
+		if network.Contains(parsedIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEndpointAllowed returns true if the API key can access the specified endpoint.
+// The path must be relative to the REST API base path
+func (k *APIKey) IsEndpointAllowed(method, urlPath string) bool {
+	if len(k.Filters.Endpoints) == 0 {
+		return true
+	}
+	for idx := range k.Filters.Endpoints {
+		if k.Filters.Endpoints[idx].matches(method, urlPath) {
+			return true
+		}
+	}
+	return false
 }
 
 // Authenticate tries to authenticate the provided plain key
@@ -185,6 +334,18 @@ func (k *APIKey) Authenticate(plainKey string) error {
 		return fmt.Errorf("API key %q is expired, expiration timestamp: %v current timestamp: %v", k.KeyID,
 			k.ExpiresAt, util.GetTimeAsMsSinceEpoch(time.Now()))
 	}
+	err := k.authenticateWithCache(plainKey)
+	if err != nil && k.Filters.PreviousKey != "" &&
+		k.Filters.PreviousKeyExpiresAt >= util.GetTimeAsMsSinceEpoch(time.Now()) {
+		if compareAPIKeyAndHash(plainKey, k.Filters.PreviousKey) {
+			providerLog(logger.LevelDebug, "API key %q authenticated using the previous key", k.KeyID)
+			return nil
+		}
+	}
+	return err
+}
+
+func (k *APIKey) authenticateWithCache(plainKey string) error {
 	if config.PasswordCaching {
 		found, match := cachedAPIKeys.Check(k.KeyID, plainKey, k.Key)
 		if found {
@@ -194,13 +355,8 @@ func (k *APIKey) Authenticate(plainKey string) error {
 			return nil
 		}
 	}
-	if strings.HasPrefix(k.Key, bcryptPwdPrefix) {
-		if err := bcrypt.CompareHashAndPassword([]byte(k.Key), []byte(plainKey)); err != nil {
-			return ErrInvalidCredentials
-		}
-	} else if strings.HasPrefix(k.Key, argonPwdPrefix) {
-		match, err := argon2id.ComparePasswordAndHash(plainKey, k.Key)
-		if err != nil || !match {
+	if strings.HasPrefix(k.Key, bcryptPwdPrefix) || strings.HasPrefix(k.Key, argonPwdPrefix) {
+		if !compareAPIKeyAndHash(plainKey, k.Key) {
 			return ErrInvalidCredentials
 		}
 	}
@@ -208,3 +364,14 @@ func (k *APIKey) Authenticate(plainKey string) error {
 	cachedAPIKeys.Add(k.KeyID, plainKey, k.Key)
 	return nil
 }
+
+func compareAPIKeyAndHash(plainKey, hash string) bool {
+	if strings.HasPrefix(hash, bcryptPwdPrefix) {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plainKey)) == nil
+	}
+	if strings.HasPrefix(hash, argonPwdPrefix) {
+		match, err := argon2id.ComparePasswordAndHash(plainKey, hash)
+		return err == nil && match
+	}
+	return false
+}
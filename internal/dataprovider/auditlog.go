@@ -0,0 +1,273 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/plugin"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// fields that change without an explicit create/update/delete request, they
+// are excluded from the audit log diffs
+var auditLogVolatileFields = []string{"updated_at", "last_login", "last_use_at", "used_tokens", "used_quota_size",
+	"used_quota_files", "last_quota_update", "used_upload_data_transfer", "used_download_data_transfer",
+	"first_download", "first_upload"}
+
+// AuditTrailConfig defines the configuration for the audit trail of the changes
+// made to the data provider objects
+type AuditTrailConfig struct {
+	// Set to true to record create, update and delete operations performed on
+	// the data provider objects. Only SQL based data providers are supported
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Audit log entries older than the specified number of days are automatically removed.
+	// 0 means no automatic removal
+	RetentionDays int `json:"retention_days" mapstructure:"retention_days"`
+}
+
+func (c *AuditTrailConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.RetentionDays < 0 {
+		return fmt.Errorf("invalid audit trail retention days: %d", c.RetentionDays)
+	}
+	if !config.IsAuditTrailSupported() {
+		return fmt.Errorf("audit trail is not supported with data provider %q", config.Driver)
+	}
+	return nil
+}
+
+// AuditLogChange defines a changed field within an audit log entry.
+// Path is a JSON pointer to the changed field, nested objects are compared
+// field by field while lists are compared as a whole
+type AuditLogChange struct {
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// AuditLogEntry defines a create, update or delete operation performed on a
+// data provider object
+type AuditLogEntry struct {
+	ID int64 `json:"id"`
+	// Unix timestamp in milliseconds
+	Timestamp  int64  `json:"timestamp"`
+	Executor   string `json:"executor"`
+	IP         string `json:"ip,omitempty"`
+	Role       string `json:"role,omitempty"`
+	Operation  string `json:"operation"`
+	ObjectType string `json:"object_type"`
+	ObjectName string `json:"object_name"`
+	// Object as JSON after the operation, confidential data are hidden.
+	// Empty for delete operations
+	ObjectData json.RawMessage  `json:"object_data,omitempty"`
+	Diff       []AuditLogChange `json:"diff,omitempty"`
+}
+
+// GetCSVHeader returns the CSV header for audit log entries
+func (e *AuditLogEntry) GetCSVHeader() []string {
+	return []string{"ID", "Time", "Executor", "IP", "Role", "Operation", "Object type", "Object name", "Diff"}
+}
+
+// GetCSVData returns the audit log entry as CSV row
+func (e *AuditLogEntry) GetCSVData() []string {
+	var diff string
+	if len(e.Diff) > 0 {
+		data, err := json.Marshal(e.Diff)
+		if err == nil {
+			diff = string(data)
+		}
+	}
+	return []string{fmt.Sprintf("%d", e.ID), util.GetTimeFromMsecSinceEpoch(e.Timestamp).UTC().Format(time.RFC3339Nano),
+		e.Executor, e.IP, e.Role, e.Operation, e.ObjectType, e.ObjectName, diff}
+}
+
+// AuditLogSearch defines the filters to search audit log entries
+type AuditLogSearch struct {
+	// Unix timestamps in milliseconds, 0 means no limit
+	StartTimestamp int64
+	EndTimestamp   int64
+	Executor       string
+	IP             string
+	Role           string
+	ObjectName     string
+	ObjectTypes    []string
+	Operations     []string
+	// Return the entries after (ASC order) or before (DESC order) the specified ID
+	FromID         int64
+	Limit          int
+	Order          string
+	OmitObjectData bool
+}
+
+// GetAuditLogObjectTypes returns the object types that can be recorded in the audit trail
+func GetAuditLogObjectTypes() []string {
+	return []string{actionObjectUser, actionObjectFolder, actionObjectGroup, actionObjectAdmin, actionObjectAPIKey,
+		actionObjectShare, actionObjectEventAction, actionObjectEventRule, actionObjectRole, actionObjectIPListEntry,
+		actionObjectConfigs}
+}
+
+// GetAuditLogOperations returns the operations that can be recorded in the audit trail
+func GetAuditLogOperations() []string {
+	return []string{operationAdd, operationUpdate, operationDelete, operationImpersonate}
+}
+
+// RecordUserImpersonation records in the audit trail that the specified admin
+// started a WebClient session as the specified user
+func RecordUserImpersonation(executor, ip, username, role string, readOnly bool) {
+	if !config.AuditTrail.Enabled {
+		return
+	}
+	data, err := json.Marshal(map[string]any{
+		"read_only": readOnly,
+	})
+	if err != nil {
+		providerLog(logger.LevelError, "unable to marshal impersonation data for user %q: %v", username, err)
+	}
+	entry := AuditLogEntry{
+		Timestamp:  util.GetTimeAsMsSinceEpoch(time.Now()),
+		Executor:   executor,
+		IP:         ip,
+		Role:       role,
+		Operation:  operationImpersonate,
+		ObjectType: actionObjectUser,
+		ObjectName: username,
+		ObjectData: data,
+	}
+	if err := provider.addAuditLogEntry(&entry); err != nil {
+		providerLog(logger.LevelError, "unable to add audit log entry for user %q, operation %q: %v",
+			username, operationImpersonate, err)
+	}
+}
+
+func recordAuditLogEntry(operation, executor, ip, objectType, objectName, role string, object plugin.Renderer) {
+	if !config.AuditTrail.Enabled {
+		return
+	}
+	entry := AuditLogEntry{
+		Timestamp:  util.GetTimeAsMsSinceEpoch(time.Now()),
+		Executor:   executor,
+		IP:         ip,
+		Role:       role,
+		Operation:  operation,
+		ObjectType: objectType,
+		ObjectName: objectName,
+	}
+	if operation != operationDelete {
+		data, err := object.RenderAsJSON(true)
+		if err != nil {
+			providerLog(logger.LevelError, "unable to render %s %q for the audit trail: %v", objectType, objectName, err)
+		} else {
+			entry.ObjectData = data
+		}
+	}
+	var previous []byte
+	if operation != operationAdd {
+		last, err := provider.getLastAuditLogEntry(objectType, objectName)
+		if err == nil {
+			previous = last.ObjectData
+		} else if !errors.Is(err, util.ErrNotFound) {
+			providerLog(logger.LevelError, "unable to get the last audit log entry for %s %q: %v", objectType, objectName, err)
+		}
+	}
+	diff, err := getAuditLogDiff(previous, entry.ObjectData)
+	if err != nil {
+		providerLog(logger.LevelError, "unable to compute the audit log diff for %s %q: %v", objectType, objectName, err)
+	}
+	entry.Diff = diff
+	if err := provider.addAuditLogEntry(&entry); err != nil {
+		providerLog(logger.LevelError, "unable to add audit log entry for %s %q, operation %q: %v",
+			objectType, objectName, operation, err)
+	}
+}
+
+func getAuditLogDiff(previous, current []byte) ([]AuditLogChange, error) {
+	oldObj := make(map[string]any)
+	newObj := make(map[string]any)
+	if len(previous) > 0 {
+		if err := json.Unmarshal(previous, &oldObj); err != nil {
+			return nil, err
+		}
+	}
+	if len(current) > 0 {
+		if err := json.Unmarshal(current, &newObj); err != nil {
+			return nil, err
+		}
+	}
+	removeAuditLogVolatileFields(oldObj)
+	removeAuditLogVolatileFields(newObj)
+
+	var changes []AuditLogChange
+	diffAuditLogObjects("", oldObj, newObj, &changes)
+	return changes, nil
+}
+
+func diffAuditLogObjects(prefix string, oldObj, newObj map[string]any, changes *[]AuditLogChange) {
+	keys := make([]string, 0, len(oldObj)+len(newObj))
+	for k := range oldObj {
+		keys = append(keys, k)
+	}
+	for k := range newObj {
+		if _, ok := oldObj[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		path := prefix + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+		oldVal := oldObj[k]
+		newVal := newObj[k]
+		oldMap, isOldMap := oldVal.(map[string]any)
+		newMap, isNewMap := newVal.(map[string]any)
+		if isOldMap && isNewMap {
+			diffAuditLogObjects(path, oldMap, newMap, changes)
+			continue
+		}
+		if reflect.DeepEqual(oldVal, newVal) {
+			continue
+		}
+		*changes = append(*changes, AuditLogChange{
+			Path: path,
+			Old:  oldVal,
+			New:  newVal,
+		})
+	}
+}
+
+func removeAuditLogVolatileFields(val any) {
+	switch v := val.(type) {
+	case map[string]any:
+		for _, field := range auditLogVolatileFields {
+			delete(v, field)
+		}
+		for _, item := range v {
+			removeAuditLogVolatileFields(item)
+		}
+	case []any:
+		for _, item := range v {
+			removeAuditLogVolatileFields(item)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+// Supported batch actions
+const (
+	BatchActionAdd    = "add"
+	BatchActionUpdate = "update"
+	BatchActionDelete = "delete"
+)
+
+// ErrBatchAborted is returned for the operations not applied because another
+// operation within the same transactional batch failed
+var ErrBatchAborted = errors.New("not applied, another operation in the batch failed")
+
+// UserBatchItem defines an operation on a user within a batch.
+// For deletions only the username is required
+type UserBatchItem struct {
+	Action string `json:"action"`
+	User   User   `json:"user"`
+}
+
+// FolderBatchItem defines an operation on a virtual folder within a batch.
+// For deletions only the folder name is required
+type FolderBatchItem struct {
+	Action string                `json:"action"`
+	Folder vfs.BaseVirtualFolder `json:"folder"`
+}
+
+// batchProvider is implemented by the providers able to execute a batch
+// of operations within a single transaction. The items are already validated
+type batchProvider interface {
+	executeUsersBatch(items []UserBatchItem) error
+	executeFoldersBatch(items []FolderBatchItem) error
+}
+
+// batchItemError wraps the error returned for the batch item at the specified index
+type batchItemError struct {
+	index int
+	err   error
+}
+
+func (e *batchItemError) Error() string {
+	return fmt.Sprintf("batch item %d: %v", e.index, e.err)
+}
+
+func (e *batchItemError) Unwrap() error {
+	return e.err
+}
+
+func getBatchErrors(size int, err error) []error {
+	errs := make([]error, size)
+	if err == nil {
+		return errs
+	}
+	var itemErr *batchItemError
+	isItemErr := errors.As(err, &itemErr)
+	for idx := range errs {
+		switch {
+		case !isItemErr:
+			errs[idx] = err
+		case idx == itemErr.index:
+			errs[idx] = itemErr.err
+		default:
+			errs[idx] = ErrBatchAborted
+		}
+	}
+	return errs
+}
+
+func getInvalidBatchActionError(action string) error {
+	return util.NewValidationError(fmt.Sprintf("invalid batch action %q", action))
+}
+
+// IsBatchTransactional returns true if the configured data provider
+// applies the batch operations atomically
+func IsBatchTransactional() bool {
+	_, ok := provider.(batchProvider)
+	return ok
+}
+
+// ExecuteUsersBatch executes the given user operations and returns the error,
+// nil on success, for each of them. If the data provider supports transactions,
+// the operations are applied atomically: if one fails none is applied.
+// Otherwise each operation is executed independently
+func ExecuteUsersBatch(items []UserBatchItem, executor, ipAddress, role string) []error {
+	p, ok := provider.(batchProvider)
+	if !ok {
+		errs := make([]error, len(items))
+		for idx := range items {
+			item := &items[idx]
+			switch item.Action {
+			case BatchActionAdd:
+				errs[idx] = AddUser(&item.User, executor, ipAddress, role)
+			case BatchActionUpdate:
+				errs[idx] = UpdateUser(&item.User, executor, ipAddress, role)
+			case BatchActionDelete:
+				errs[idx] = DeleteUser(item.User.Username, executor, ipAddress, role)
+			default:
+				errs[idx] = getInvalidBatchActionError(item.Action)
+			}
+		}
+		return errs
+	}
+	// the validation could require provider queries, so it must be done
+	// before starting the transaction
+	for idx := range items {
+		item := &items[idx]
+		var err error
+		switch item.Action {
+		case BatchActionAdd:
+			item.User.Username = config.convertName(item.User.Username)
+			err = ValidateUser(&item.User)
+		case BatchActionUpdate:
+			if item.User.groupSettingsApplied {
+				err = errors.New("cannot save a user with group settings applied")
+			} else {
+				err = ValidateUser(&item.User)
+			}
+		case BatchActionDelete:
+			item.User, err = provider.userExists(config.convertName(item.User.Username), role)
+		default:
+			err = getInvalidBatchActionError(item.Action)
+		}
+		if err != nil {
+			return getBatchErrors(len(items), &batchItemError{index: idx, err: err})
+		}
+	}
+	if err := p.executeUsersBatch(items); err != nil {
+		return getBatchErrors(len(items), err)
+	}
+	for idx := range items {
+		item := &items[idx]
+		switch item.Action {
+		case BatchActionAdd:
+			executeAction(operationAdd, executor, ipAddress, actionObjectUser, item.User.Username, role, &item.User)
+		case BatchActionUpdate:
+			webDAVUsersCache.swap(&item.User, "")
+			executeAction(operationUpdate, executor, ipAddress, actionObjectUser, item.User.Username, role, &item.User)
+		case BatchActionDelete:
+			onUserDeleted(&item.User, executor, ipAddress, role)
+		}
+	}
+	return getBatchErrors(len(items), nil)
+}
+
+// ExecuteFoldersBatch executes the given folder operations and returns the error,
+// nil on success, for each of them. If the data provider supports transactions,
+// the operations are applied atomically: if one fails none is applied.
+// Otherwise each operation is executed independently
+func ExecuteFoldersBatch(items []FolderBatchItem, executor, ipAddress, role string) []error {
+	p, ok := provider.(batchProvider)
+	if !ok {
+		errs := make([]error, len(items))
+		for idx := range items {
+			item := &items[idx]
+			switch item.Action {
+			case BatchActionAdd:
+				errs[idx] = AddFolder(&item.Folder, executor, ipAddress, role)
+			case BatchActionUpdate:
+				errs[idx] = UpdateFolder(&item.Folder, item.Folder.Users, item.Folder.Groups, executor, ipAddress, role)
+			case BatchActionDelete:
+				errs[idx] = DeleteFolder(item.Folder.Name, executor, ipAddress, role)
+			default:
+				errs[idx] = getInvalidBatchActionError(item.Action)
+			}
+		}
+		return errs
+	}
+	for idx := range items {
+		item := &items[idx]
+		var err error
+		switch item.Action {
+		case BatchActionAdd:
+			item.Folder.Name = config.convertName(item.Folder.Name)
+			err = ValidateFolder(&item.Folder)
+		case BatchActionUpdate:
+			err = ValidateFolder(&item.Folder)
+		case BatchActionDelete:
+			item.Folder, err = provider.getFolderByName(config.convertName(item.Folder.Name))
+		default:
+			err = getInvalidBatchActionError(item.Action)
+		}
+		if err != nil {
+			return getBatchErrors(len(items), &batchItemError{index: idx, err: err})
+		}
+	}
+	if err := p.executeFoldersBatch(items); err != nil {
+		return getBatchErrors(len(items), err)
+	}
+	for idx := range items {
+		item := &items[idx]
+		switch item.Action {
+		case BatchActionAdd:
+			executeAction(operationAdd, executor, ipAddress, actionObjectFolder, item.Folder.Name, role,
+				&wrappedFolder{Folder: item.Folder})
+		case BatchActionUpdate:
+			onFolderUpdated(&item.Folder, item.Folder.Users, item.Folder.Groups, executor, ipAddress, role)
+		case BatchActionDelete:
+			onFolderDeleted(&item.Folder, executor, ipAddress, role)
+		}
+	}
+	return getBatchErrors(len(items), nil)
+}
@@ -0,0 +1,60 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !nobolt
+// +build !nobolt
+
+package dataprovider
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestBoltProvider(t *testing.T) {
+	dbHandle, err := bolt.Open(filepath.Join(t.TempDir(), "sftpgo.db"), 0600, &bolt.Options{
+		Timeout: 5 * time.Second,
+	})
+	require.NoError(t, err)
+	err = dbHandle.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range boltBuckets {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	p := newBoltProvider(dbHandle)
+	t.Cleanup(func() {
+		p.close() //nolint:errcheck
+	})
+	setKVTestProvider(t, p)
+
+	testKVProvider(t, p.kvProvider)
+	// ids are allocated using the native bolt sequences
+	err = dbHandle.View(func(tx *bolt.Tx) error {
+		assert.Greater(t, tx.Bucket([]byte(kvUsersBucket)).Sequence(), uint64(0))
+		assert.Nil(t, tx.Bucket([]byte(kvSequencesBucket)))
+		return nil
+	})
+	assert.NoError(t, err)
+	err = p.initializeDatabase()
+	assert.ErrorIs(t, err, ErrNoInitRequired)
+}
@@ -0,0 +1,142 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	maxBrandingLogoSize    = 256 * 1024
+	maxBrandingTextLength  = 2000
+	maxBrandingFooterLinks = 10
+)
+
+var (
+	brandingColorRegex    = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+	brandingLogoMimeTypes = []string{"image/png", "image/jpeg", "image/gif", "image/webp", "image/svg+xml"}
+)
+
+// BrandingLink defines a link to show in the WebClient footer
+type BrandingLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// GroupBranding defines the WebClient customizations for the users whose
+// primary group is the group these settings belong to
+type GroupBranding struct {
+	// Name to show as HTML title
+	Name string `json:"name,omitempty"`
+	// Name to show next to the logo image
+	ShortName string `json:"short_name,omitempty"`
+	// Logo as absolute http/https URL or as base64 encoded data URI,
+	// for example "data:image/png;base64,..."
+	Logo string `json:"logo,omitempty"`
+	// Primary color in hex format, for example "#1b84ff"
+	PrimaryColor string `json:"primary_color,omitempty"`
+	// Text to show on the login page
+	LoginText string `json:"login_text,omitempty"`
+	// Links to show in the page footer
+	FooterLinks []BrandingLink `json:"footer_links,omitempty"`
+}
+
+// IsEmpty returns true if no customization is defined
+func (b *GroupBranding) IsEmpty() bool {
+	return b.Name == "" && b.ShortName == "" && b.Logo == "" && b.PrimaryColor == "" && b.LoginText == "" &&
+		len(b.FooterLinks) == 0
+}
+
+func (b *GroupBranding) getACopy() GroupBranding {
+	links := make([]BrandingLink, len(b.FooterLinks))
+	copy(links, b.FooterLinks)
+	return GroupBranding{
+		Name:         b.Name,
+		ShortName:    b.ShortName,
+		Logo:         b.Logo,
+		PrimaryColor: b.PrimaryColor,
+		LoginText:    b.LoginText,
+		FooterLinks:  links,
+	}
+}
+
+func (b *GroupBranding) validate() error {
+	b.Name = strings.TrimSpace(b.Name)
+	b.ShortName = strings.TrimSpace(b.ShortName)
+	b.Logo = strings.TrimSpace(b.Logo)
+	b.PrimaryColor = strings.TrimSpace(b.PrimaryColor)
+	b.LoginText = strings.TrimSpace(b.LoginText)
+	if len(b.Name) > 255 || len(b.ShortName) > 255 {
+		return util.NewValidationError("branding name and short name cannot be longer than 255 characters")
+	}
+	if len(b.LoginText) > maxBrandingTextLength {
+		return util.NewValidationError(fmt.Sprintf("branding login text cannot be longer than %d characters",
+			maxBrandingTextLength))
+	}
+	if b.PrimaryColor != "" && !brandingColorRegex.MatchString(b.PrimaryColor) {
+		return util.NewValidationError(fmt.Sprintf("invalid branding primary color %q, a hex color is required, for example \"#1b84ff\"",
+			b.PrimaryColor))
+	}
+	if err := validateBrandingLogo(b.Logo); err != nil {
+		return err
+	}
+	if len(b.FooterLinks) > maxBrandingFooterLinks {
+		return util.NewValidationError(fmt.Sprintf("too many branding footer links, max allowed: %d", maxBrandingFooterLinks))
+	}
+	for idx := range b.FooterLinks {
+		link := &b.FooterLinks[idx]
+		link.Name = strings.TrimSpace(link.Name)
+		link.URL = strings.TrimSpace(link.URL)
+		if link.Name == "" {
+			return util.NewValidationError("branding footer link name is required")
+		}
+		if !isValidBrandingURL(link.URL) {
+			return util.NewValidationError(fmt.Sprintf("invalid branding footer link URL %q, an absolute http/https URL is required",
+				link.URL))
+		}
+	}
+	return nil
+}
+
+func validateBrandingLogo(logo string) error {
+	if logo == "" || isValidBrandingURL(logo) {
+		return nil
+	}
+	if len(logo) > maxBrandingLogoSize {
+		return util.NewValidationError(fmt.Sprintf("branding logo cannot be larger than %d bytes", maxBrandingLogoSize))
+	}
+	mimeType, data, ok := strings.Cut(strings.TrimPrefix(logo, "data:"), ";base64,")
+	if !ok || !strings.HasPrefix(logo, "data:") || !util.Contains(brandingLogoMimeTypes, mimeType) {
+		return util.NewValidationError("invalid branding logo, an absolute http/https URL or a base64 encoded image data URI is required")
+	}
+	if _, err := base64.StdEncoding.DecodeString(data); err != nil {
+		return util.NewValidationError(fmt.Sprintf("invalid branding logo, unable to decode the image data: %v", err))
+	}
+	return nil
+}
+
+func isValidBrandingURL(val string) bool {
+	u, err := url.Parse(val)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
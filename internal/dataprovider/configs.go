@@ -390,10 +390,12 @@ func (c *ACMEConfigs) getACopy() *ACMEConfigs {
 // Configs allows to set configuration keys disabled by default without
 // modifying the config file or setting env vars
 type Configs struct {
-	SFTPD     *SFTPDConfigs `json:"sftpd,omitempty"`
-	SMTP      *SMTPConfigs  `json:"smtp,omitempty"`
-	ACME      *ACMEConfigs  `json:"acme,omitempty"`
-	UpdatedAt int64         `json:"updated_at,omitempty"`
+	SFTPD *SFTPDConfigs `json:"sftpd,omitempty"`
+	SMTP  *SMTPConfigs  `json:"smtp,omitempty"`
+	ACME  *ACMEConfigs  `json:"acme,omitempty"`
+	// Admin roles define granular permissions that can be assigned to admins
+	AdminRoles []AdminRole `json:"admin_roles,omitempty"`
+	UpdatedAt  int64       `json:"updated_at,omitempty"`
 }
 
 func (c *Configs) validate() error {
@@ -412,6 +414,15 @@ func (c *Configs) validate() error {
 			return err
 		}
 	}
+	return validateAdminRoles(c.AdminRoles)
+}
+
+func (c *Configs) getAdminRole(name string) *AdminRole {
+	for idx := range c.AdminRoles {
+		if c.AdminRoles[idx].Name == name {
+			return &c.AdminRoles[idx]
+		}
+	}
 	return nil
 }
 
@@ -498,6 +509,9 @@ func (c *Configs) getACopy() Configs {
 	if c.ACME != nil {
 		result.ACME = c.ACME.getACopy()
 	}
+	for idx := range c.AdminRoles {
+		result.AdminRoles = append(result.AdminRoles, c.AdminRoles[idx].getACopy())
+	}
 	result.UpdatedAt = c.UpdatedAt
 	return result
 }
@@ -86,6 +86,10 @@ const (
 	MemoryDataProviderName = "memory"
 	// CockroachDataProviderName defines the for CockroachDB provider
 	CockroachDataProviderName = "cockroachdb"
+	// EtcdDataProviderName defines the name for etcd key/value store provider
+	EtcdDataProviderName = "etcd"
+	// RedisDataProviderName defines the name for Redis key/value store provider
+	RedisDataProviderName = "redis"
 	// DumpVersion defines the version for the dump.
 	// For restore/load we support the current version and the previous one
 	DumpVersion = 16
@@ -108,9 +112,13 @@ const (
 	operationAdd              = "add"
 	operationUpdate           = "update"
 	operationDelete           = "delete"
+	operationDrift            = "drift"
+	operationImpersonate      = "impersonate"
 	sqlPrefixValidChars       = "abcdefghijklmnopqrstuvwxyz_0123456789"
 	maxHookResponseSize       = 1048576 // 1MB
 	iso8601UTCFormat          = "2006-01-02T15:04:05Z"
+	maxAttributes             = 50
+	maxAttributeValueLength   = 1024
 )
 
 // Supported algorithms for hashing passwords.
@@ -131,6 +139,7 @@ const (
 	protocolFTP    = "FTP"
 	protocolWebDAV = "DAV"
 	protocolHTTP   = "HTTP"
+	protocolS3     = "S3"
 )
 
 // Dump scopes
@@ -157,7 +166,8 @@ const (
 var (
 	// SupportedProviders defines the supported data providers
 	SupportedProviders = []string{SQLiteDataProviderName, PGSQLDataProviderName, MySQLDataProviderName,
-		BoltDataProviderName, MemoryDataProviderName, CockroachDataProviderName}
+		BoltDataProviderName, MemoryDataProviderName, CockroachDataProviderName, EtcdDataProviderName,
+		RedisDataProviderName}
 	// ValidPerms defines all the valid permissions for a user
 	ValidPerms = []string{PermAny, PermListItems, PermDownload, PermUpload, PermOverwrite, PermCreateDirs, PermRename,
 		PermRenameFiles, PermRenameDirs, PermDelete, PermDeleteFiles, PermDeleteDirs, PermCreateSymlinks, PermChmod,
@@ -173,9 +183,11 @@ var (
 	// ErrNotImplemented defines the error for features not supported for a particular data provider
 	ErrNotImplemented = errors.New("feature not supported with the configured data provider")
 	// ValidProtocols defines all the valid protcols
-	ValidProtocols = []string{protocolSSH, protocolFTP, protocolWebDAV, protocolHTTP}
+	ValidProtocols = []string{protocolSSH, protocolFTP, protocolWebDAV, protocolHTTP, protocolS3}
 	// MFAProtocols defines the supported protocols for multi-factor authentication
 	MFAProtocols = []string{protocolHTTP, protocolSSH, protocolFTP}
+	// PushMFAProtocols defines the supported protocols for push based multi-factor authentication
+	PushMFAProtocols = []string{protocolHTTP, protocolSSH}
 	// ErrNoInitRequired defines the error returned by InitProvider if no inizialization/update is required
 	ErrNoInitRequired = errors.New("the data provider is up to date")
 	// ErrInvalidCredentials defines the error to return if the supplied credentials are invalid
@@ -200,7 +212,7 @@ var (
 	unixPwdPrefixes         = []string{md5cryptPwdPrefix, md5cryptApr1PwdPrefix, sha256cryptPwdPrefix, sha512cryptPwdPrefix,
 		yescryptPwdPrefix}
 	digestPwdPrefixes            = []string{md5DigestPwdPrefix, sha256DigestPwdPrefix, sha512DigestPwdPrefix}
-	sharedProviders              = []string{PGSQLDataProviderName, MySQLDataProviderName, CockroachDataProviderName}
+	sharedProviders              = []string{PGSQLDataProviderName, MySQLDataProviderName, CockroachDataProviderName, EtcdDataProviderName, RedisDataProviderName}
 	logSender                    = "dataprovider"
 	sqlTableUsers                string
 	sqlTableFolders              string
@@ -224,15 +236,22 @@ var (
 	sqlTableRoles                string
 	sqlTableIPLists              string
 	sqlTableConfigs              string
+	sqlTableAuditLogs            string
+	sqlTableShareDownloads       string
+	sqlTableWebhookDeliveries    string
+	sqlTableDAVProperties        string
 	sqlTableSchemaVersion        string
 	argon2Params                 *argon2id.Params
 	lastLoginMinDelay            = 10 * time.Minute
 	usernameRegex                = regexp.MustCompile("^[a-zA-Z0-9-_.~]+$")
+	attributeNameRegex           = regexp.MustCompile("^[a-zA-Z0-9_.-]{1,64}$")
 	tempPath                     string
 	allowSelfConnections         int
 	fnReloadRules                FnReloadRules
 	fnRemoveRule                 FnRemoveRule
 	fnHandleRuleForProviderEvent FnHandleRuleForProviderEvent
+	fnHandleLoginEvent           FnHandleLoginEvent
+	fnHandleQuotaUpdate          FnHandleQuotaUpdate
 )
 
 func initSQLTables() {
@@ -258,6 +277,10 @@ func initSQLTables() {
 	sqlTableRoles = "roles"
 	sqlTableIPLists = "ip_lists"
 	sqlTableConfigs = "configurations"
+	sqlTableAuditLogs = "audit_logs"
+	sqlTableShareDownloads = "share_downloads"
+	sqlTableWebhookDeliveries = "webhook_deliveries"
+	sqlTableDAVProperties = "dav_properties"
 	sqlTableSchemaVersion = "schema_version"
 }
 
@@ -270,6 +293,23 @@ type FnRemoveRule func(name string)
 // FnHandleRuleForProviderEvent define the callback to handle event rules for provider events
 type FnHandleRuleForProviderEvent func(operation, executor, ip, objectType, objectName, role string, object plugin.Renderer)
 
+// FnHandleLoginEvent defines the callback to handle login events
+type FnHandleLoginEvent func(user *User, loginMethod, ip, protocol string, err error)
+
+// FnHandleQuotaUpdate defines the callback to handle quota updates for users and folders
+// with quota restrictions
+type FnHandleQuotaUpdate func(objectType, objectName string, quotaFiles int, quotaSize int64)
+
+// SetQuotaUpdateCallback sets the callback invoked after each quota update
+func SetQuotaUpdateCallback(handle FnHandleQuotaUpdate) {
+	fnHandleQuotaUpdate = handle
+}
+
+// SetLoginEventCallback sets the callback invoked after each login attempt
+func SetLoginEventCallback(handle FnHandleLoginEvent) {
+	fnHandleLoginEvent = handle
+}
+
 // SetEventRulesCallbacks sets the event rules callbacks
 func SetEventRulesCallbacks(reload FnReloadRules, remove FnRemoveRule, handle FnHandleRuleForProviderEvent) {
 	fnReloadRules = reload
@@ -362,6 +402,18 @@ func (w *wrappedFolder) RenderAsJSON(reload bool) ([]byte, error) {
 	return json.Marshal(w.Folder)
 }
 
+// GetObjectAttributes returns the custom attributes for the specified provider
+// object, only users and folders have custom attributes
+func GetObjectAttributes(object plugin.Renderer) map[string]string {
+	switch o := object.(type) {
+	case *User:
+		return o.Attributes
+	case *wrappedFolder:
+		return o.Folder.Attributes
+	}
+	return nil
+}
+
 // ObjectsActions defines the action to execute on user create, update, delete for the specified objects
 type ObjectsActions struct {
 	// Valid values are add, update, delete. Empty slice to disable
@@ -384,9 +436,9 @@ type Config struct {
 	// Driver name, must be one of the SupportedProviders
 	Driver string `json:"driver" mapstructure:"driver"`
 	// Database name. For driver sqlite this can be the database name relative to the config dir
-	// or the absolute path to the SQLite database.
+	// or the absolute path to the SQLite database. For drivers etcd and redis this is the prefix for the keys.
 	Name string `json:"name" mapstructure:"name"`
-	// Database host. For postgresql and cockroachdb driver you can specify multiple hosts separated by commas
+	// Database host. For postgresql, cockroachdb, etcd and redis driver you can specify multiple hosts separated by commas
 	Host string `json:"host" mapstructure:"host"`
 	// Database port
 	Port int `json:"port" mapstructure:"port"`
@@ -431,6 +483,9 @@ type Config struct {
 	// Sets the maximum number of open connections for mysql and postgresql driver.
 	// Default 0 (unlimited)
 	PoolSize int `json:"pool_size" mapstructure:"pool_size"`
+	// Persistence required for the redis driver, validated at startup:
+	// 0 no check, 1 RDB snapshots or AOF must be enabled, 2 AOF must be enabled
+	RedisPersistence int `json:"redis_persistence" mapstructure:"redis_persistence"`
 	// Users default base directory.
 	// If no home dir is defined while adding a new user, and this value is
 	// a valid absolute path, then the user home dir will be automatically
@@ -528,10 +583,12 @@ type Config struct {
 	// You have to ensure that all existing users respect the defined rules.
 	NamingRules int `json:"naming_rules" mapstructure:"naming_rules"`
 	// If the data provider is shared across multiple SFTPGo instances, set this parameter to 1.
-	// MySQL, PostgreSQL and CockroachDB can be shared, this setting is ignored for other data
+	// MySQL, PostgreSQL, CockroachDB, etcd and Redis can be shared, this setting is ignored for other data
 	// providers. For shared data providers, SFTPGo periodically reloads the latest updated users,
 	// based on the "updated_at" field, and updates its internal caches if users are updated from
 	// a different instance. This check, if enabled, is executed every 10 minutes.
+	// For etcd, changes made by other instances are also received using a watch and applied
+	// instantly, for Redis using pub/sub.
 	// For shared data providers, active transfers are persisted in the database and thus
 	// quota checks between ongoing transfers will work cross multiple instances
 	IsShared int `json:"is_shared" mapstructure:"is_shared"`
@@ -540,6 +597,24 @@ type Config struct {
 	Node NodeConfig `json:"node" mapstructure:"node"`
 	// Path to the backup directory. This can be an absolute path or a path relative to the config dir
 	BackupsPath string `json:"backups_path" mapstructure:"backups_path"`
+	// AuditTrail defines the configuration for recording the changes made to
+	// users, admins and the other data provider objects
+	AuditTrail AuditTrailConfig `json:"audit_trail" mapstructure:"audit_trail"`
+	// Reconciler defines the configuration for applying users, groups, folders
+	// and event rules declared in YAML/JSON files
+	Reconciler ReconcilerConfig `json:"reconciler" mapstructure:"reconciler"`
+	// LDAPSync defines the configuration for importing users and their group
+	// memberships from LDAP/Active Directory
+	LDAPSync LDAPSyncConfig `json:"ldap_sync" mapstructure:"ldap_sync"`
+	// LDAPAuth defines the configuration for authenticating users against
+	// LDAP/Active Directory
+	LDAPAuth LDAPAuthConfig `json:"ldap_auth" mapstructure:"ldap_auth"`
+	// ShareAnalytics defines the configuration for recording the downloads
+	// from the shares
+	ShareAnalytics ShareAnalyticsConfig `json:"share_analytics" mapstructure:"share_analytics"`
+	// Webhooks defines the configuration for the durable delivery of the
+	// HTTP notifications
+	Webhooks WebhooksConfig `json:"webhooks" mapstructure:"webhooks"`
 }
 
 // GetShared returns the provider share mode.
@@ -575,6 +650,37 @@ func (c *Config) IsDefenderSupported() bool {
 	}
 }
 
+// IsAuditTrailSupported returns true if the configured provider supports the audit trail
+func (c *Config) IsAuditTrailSupported() bool {
+	switch c.Driver {
+	case SQLiteDataProviderName, MySQLDataProviderName, PGSQLDataProviderName, CockroachDataProviderName:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsShareAnalyticsSupported returns true if the configured provider supports share analytics
+func (c *Config) IsShareAnalyticsSupported() bool {
+	switch c.Driver {
+	case SQLiteDataProviderName, MySQLDataProviderName, PGSQLDataProviderName, CockroachDataProviderName:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsWebhooksSupported returns true if the configured provider supports the
+// durable delivery of the HTTP notifications
+func (c *Config) IsWebhooksSupported() bool {
+	switch c.Driver {
+	case SQLiteDataProviderName, MySQLDataProviderName, PGSQLDataProviderName, CockroachDataProviderName:
+		return true
+	default:
+		return false
+	}
+}
+
 func (c *Config) requireCustomTLSForMySQL() bool {
 	if config.DisableSNI {
 		return config.SSLMode != 0
@@ -816,6 +922,7 @@ type Provider interface {
 	getAPIKeys(limit int, offset int, order string) ([]APIKey, error)
 	dumpAPIKeys() ([]APIKey, error)
 	updateAPIKeyLastUse(keyID string) error
+	rotateAPIKey(apiKey *APIKey) error
 	shareExists(shareID, username string) (Share, error)
 	addShare(share *Share) error
 	updateShare(share *Share) error
@@ -839,6 +946,7 @@ type Provider interface {
 	addSharedSession(session Session) error
 	deleteSharedSession(key string) error
 	getSharedSession(key string) (Session, error)
+	getSharedSessions(sessionType SessionType) ([]Session, error)
 	cleanupSharedSessions(sessionType SessionType, before int64) error
 	getEventActions(limit, offset int, order string, minimal bool) ([]BaseEventAction, error)
 	dumpEventActions() ([]BaseEventAction, error)
@@ -881,6 +989,25 @@ type Provider interface {
 	getListEntriesForIP(ip string, listType IPListType) ([]IPListEntry, error)
 	getConfigs() (Configs, error)
 	setConfigs(configs *Configs) error
+	addAuditLogEntry(entry *AuditLogEntry) error
+	getLastAuditLogEntry(objectType, objectName string) (AuditLogEntry, error)
+	searchAuditLogs(filters *AuditLogSearch) ([]AuditLogEntry, error)
+	cleanupAuditLogs(before int64) error
+	addShareDownload(shareID string, download *ShareDownload) error
+	searchShareDownloads(shareID string, filters *ShareDownloadSearch) ([]ShareDownload, error)
+	getShareDownloadStats(shareID string) (ShareDownloadStats, error)
+	cleanupShareDownloads(before int64) error
+	addWebhookDelivery(delivery *WebhookDelivery) error
+	getWebhookDelivery(id string) (WebhookDelivery, error)
+	getDueWebhookDeliveries(now int64, limit int) ([]WebhookDelivery, error)
+	lockWebhookDelivery(id string, nextAttemptAt, lockUntil int64) (bool, error)
+	updateWebhookDelivery(delivery *WebhookDelivery) error
+	searchWebhookDeliveries(filters *WebhookDeliverySearch) ([]WebhookDelivery, error)
+	cleanupWebhookDeliveries(before int64) error
+	getDAVProperties(username, virtualPath string) (DAVProperties, error)
+	setDAVProperties(props *DAVProperties) error
+	renameDAVProperties(username, source, target string) error
+	deleteDAVProperties(username, virtualPath string, recursive bool) error
 	checkAvailability() error
 	close() error
 	reloadConfig() error
@@ -946,6 +1073,24 @@ func Initialize(cnf Config, basePath string, checkAdmins bool) error {
 	if err := validateHooks(); err != nil {
 		return err
 	}
+	if err := config.AuditTrail.validate(); err != nil {
+		return err
+	}
+	if err := config.ShareAnalytics.validate(); err != nil {
+		return err
+	}
+	if err := config.Webhooks.validate(); err != nil {
+		return err
+	}
+	if err := config.Reconciler.validate(basePath); err != nil {
+		return err
+	}
+	if err := config.LDAPSync.validate(); err != nil {
+		return err
+	}
+	if err := config.LDAPAuth.validate(); err != nil {
+		return err
+	}
 	if err := createProvider(basePath); err != nil {
 		return err
 	}
@@ -1085,17 +1230,22 @@ func validateSQLTablesPrefix() error {
 		sqlTableRoles = config.SQLTablesPrefix + sqlTableRoles
 		sqlTableIPLists = config.SQLTablesPrefix + sqlTableIPLists
 		sqlTableConfigs = config.SQLTablesPrefix + sqlTableConfigs
+		sqlTableAuditLogs = config.SQLTablesPrefix + sqlTableAuditLogs
+		sqlTableShareDownloads = config.SQLTablesPrefix + sqlTableShareDownloads
+		sqlTableWebhookDeliveries = config.SQLTablesPrefix + sqlTableWebhookDeliveries
+		sqlTableDAVProperties = config.SQLTablesPrefix + sqlTableDAVProperties
 		sqlTableSchemaVersion = config.SQLTablesPrefix + sqlTableSchemaVersion
 		providerLog(logger.LevelDebug, "sql table for users %q, folders %q users folders mapping %q admins %q "+
 			"api keys %q shares %q defender hosts %q defender events %q transfers %q  groups %q "+
 			"users groups mapping %q admins groups mapping %q groups folders mapping %q shared sessions %q "+
 			"schema version %q events actions %q events rules %q rules actions mapping %q tasks %q nodes %q roles %q"+
-			"ip lists %q configs %q",
+			"ip lists %q configs %q audit logs %q share downloads %q webhook deliveries %q dav properties %q",
 			sqlTableUsers, sqlTableFolders, sqlTableUsersFoldersMapping, sqlTableAdmins, sqlTableAPIKeys,
 			sqlTableShares, sqlTableDefenderHosts, sqlTableDefenderEvents, sqlTableActiveTransfers, sqlTableGroups,
 			sqlTableUsersGroupsMapping, sqlTableAdminsGroupsMapping, sqlTableGroupsFoldersMapping, sqlTableSharedSessions,
 			sqlTableSchemaVersion, sqlTableEventsActions, sqlTableEventsRules, sqlTableRulesActionsMapping,
-			sqlTableTasks, sqlTableNodes, sqlTableRoles, sqlTableIPLists, sqlTableConfigs)
+			sqlTableTasks, sqlTableNodes, sqlTableRoles, sqlTableIPLists, sqlTableConfigs, sqlTableAuditLogs,
+			sqlTableShareDownloads, sqlTableWebhookDeliveries, sqlTableDAVProperties)
 	}
 	return nil
 }
@@ -1167,9 +1317,48 @@ func CheckAdminAndPass(username, password, ip string) (Admin, error) {
 	return provider.validateAdminAndPass(username, password, ip)
 }
 
+// CheckAdminCredentials validates the given admin credentials connecting from ip.
+// tlsCert is the verified TLS client certificate, if any. If a password is provided
+// it is always checked and the certificate, if present, must match the admin if TLS
+// verification is enabled. Without a password the admin is authenticated using the
+// certificate, if allowed
+func CheckAdminCredentials(username, password, ip string, tlsCert *x509.Certificate) (Admin, error) {
+	if password != "" {
+		admin, err := CheckAdminAndPass(username, password, ip)
+		if err != nil {
+			return admin, err
+		}
+		if (tlsCert != nil && admin.IsTLSVerificationEnabled()) || admin.Filters.TLSCertAndPassword {
+			if err := admin.checkTLSCertificate(tlsCert); err != nil {
+				return admin, err
+			}
+		}
+		return admin, nil
+	}
+	if tlsCert == nil {
+		return Admin{}, ErrInvalidCredentials
+	}
+	admin, err := provider.adminExists(config.convertName(username))
+	if err != nil {
+		return admin, err
+	}
+	if err := admin.CanLogin(ip); err != nil {
+		return admin, err
+	}
+	if err := admin.checkTLSCertificate(tlsCert); err != nil {
+		return admin, err
+	}
+	if admin.Filters.TLSCertAndPassword {
+		return admin, fmt.Errorf("a password is required for admin %q", admin.Username)
+	}
+	return admin, nil
+}
+
 // CheckCachedUserCredentials checks the credentials for a cached user
 func CheckCachedUserCredentials(user *CachedUser, password, ip, loginMethod, protocol string, tlsCert *x509.Certificate) (*CachedUser, *User, error) {
-	if !user.User.skipExternalAuth() && isExternalAuthConfigured(loginMethod) {
+	// LDAP users are authenticated again if the password does not match the cached one
+	if (!user.User.skipExternalAuth() && isExternalAuthConfigured(loginMethod)) ||
+		(loginMethod == LoginMethodPassword && password != user.Password && config.LDAPAuth.isUserManaged(&user.User, protocol)) {
 		u, _, err := CheckCompositeCredentials(user.User.Username, password, ip, loginMethod, protocol, tlsCert)
 		if err != nil {
 			return nil, nil, err
@@ -1241,6 +1430,9 @@ func CheckCompositeCredentials(username, password, ip, loginMethod, protocol str
 			user, err = doPluginAuth(username, password, nil, ip, protocol, nil, plugin.AuthScopePassword)
 		} else if config.ExternalAuthHook != "" && (config.ExternalAuthScope == 0 || config.ExternalAuthScope&1 != 0) {
 			user, err = doExternalAuth(username, password, nil, "", ip, protocol, nil)
+		} else if config.LDAPAuth.isUserManaged(&user, protocol) {
+			user, err = doLDAPAuth(username, password, ip, protocol)
+			return user, loginMethod, err
 		} else if config.PreLoginHook != "" {
 			user, err = executePreLoginHook(username, LoginMethodPassword, ip, protocol, nil)
 		}
@@ -1332,6 +1524,9 @@ func CheckUserAndPass(username, password, ip, protocol string) (User, error) {
 		}
 		return checkUserAndPass(&user, password, ip, protocol)
 	}
+	if config.LDAPAuth.isEnabledForProtocol(protocol) {
+		return doLDAPAuth(username, password, ip, protocol)
+	}
 	if config.PreLoginHook != "" {
 		user, err := executePreLoginHook(username, LoginMethodPassword, ip, protocol, nil)
 		if err != nil {
@@ -1473,6 +1668,11 @@ func CleanupDefender(from int64) error {
 	return provider.cleanupDefender(from)
 }
 
+// SearchAuditLogs returns the audit log entries matching the specified filters
+func SearchAuditLogs(filters *AuditLogSearch) ([]AuditLogEntry, error) {
+	return provider.searchAuditLogs(filters)
+}
+
 // UpdateShareLastUse updates the LastUseAt and UsedTokens for the given share
 func UpdateShareLastUse(share *Share, numTokens int) error {
 	return provider.updateShareLastUse(share.ShareID, numTokens)
@@ -1524,15 +1724,20 @@ func UpdateUserQuota(user *User, filesAdd int, sizeAdd int64, reset bool) error
 		if reset {
 			delayedQuotaUpdater.resetUserQuota(user.Username)
 		}
-		return provider.updateQuota(user.Username, filesAdd, sizeAdd, reset)
+		if err := provider.updateQuota(user.Username, filesAdd, sizeAdd, reset); err != nil {
+			return err
+		}
+	} else {
+		delayedQuotaUpdater.updateUserQuota(user.Username, filesAdd, sizeAdd)
 	}
-	delayedQuotaUpdater.updateUserQuota(user.Username, filesAdd, sizeAdd)
+	executeQuotaUpdateCallback(actionObjectUser, user.Username, user.QuotaFiles, user.QuotaSize)
 	return nil
 }
 
 // UpdateVirtualFolderQuota updates the quota for the given virtual folder adding filesAdd and sizeAdd.
 // If reset is true filesAdd and sizeAdd indicates the total files and the total size instead of the difference.
-func UpdateVirtualFolderQuota(vfolder *vfs.BaseVirtualFolder, filesAdd int, sizeAdd int64, reset bool) error {
+// The quota limits defined for the folder mapping are used to check the configured quota thresholds
+func UpdateVirtualFolderQuota(vfolder *vfs.VirtualFolder, filesAdd int, sizeAdd int64, reset bool) error {
 	if config.TrackQuota == 0 {
 		return util.NewMethodDisabledError(trackQuotaDisabledError)
 	}
@@ -1543,12 +1748,25 @@ func UpdateVirtualFolderQuota(vfolder *vfs.BaseVirtualFolder, filesAdd int, size
 		if reset {
 			delayedQuotaUpdater.resetFolderQuota(vfolder.Name)
 		}
-		return provider.updateFolderQuota(vfolder.Name, filesAdd, sizeAdd, reset)
+		if err := provider.updateFolderQuota(vfolder.Name, filesAdd, sizeAdd, reset); err != nil {
+			return err
+		}
+	} else {
+		delayedQuotaUpdater.updateFolderQuota(vfolder.Name, filesAdd, sizeAdd)
 	}
-	delayedQuotaUpdater.updateFolderQuota(vfolder.Name, filesAdd, sizeAdd)
+	executeQuotaUpdateCallback(actionObjectFolder, vfolder.Name, vfolder.QuotaFiles, vfolder.QuotaSize)
 	return nil
 }
 
+func executeQuotaUpdateCallback(objectType, objectName string, quotaFiles int, quotaSize int64) {
+	if fnHandleQuotaUpdate == nil {
+		return
+	}
+	if quotaFiles > 0 || quotaSize > 0 {
+		fnHandleQuotaUpdate(objectType, objectName, quotaFiles, quotaSize)
+	}
+}
+
 // UpdateUserTransferQuota updates the transfer quota for the given SFTPGo user.
 // If reset is true uploadSize and downloadSize indicates the actual sizes instead of the difference.
 func UpdateUserTransferQuota(user *User, uploadSize, downloadSize int64, reset bool) error {
@@ -1780,6 +1998,9 @@ func RoleExists(name string) (Role, error) {
 // AddGroup adds a new group
 func AddGroup(group *Group, executor, ipAddress, role string) error {
 	group.Name = config.convertName(group.Name)
+	if err := group.checkIncludedGroups(); err != nil {
+		return err
+	}
 	err := provider.addGroup(group)
 	if err == nil {
 		executeAction(operationAdd, executor, ipAddress, actionObjectGroup, group.Name, role, group)
@@ -1789,6 +2010,9 @@ func AddGroup(group *Group, executor, ipAddress, role string) error {
 
 // UpdateGroup updates an existing Group
 func UpdateGroup(group *Group, users []string, executor, ipAddress, role string) error {
+	if err := group.checkIncludedGroups(); err != nil {
+		return err
+	}
 	err := provider.updateGroup(group)
 	if err == nil {
 		for _, user := range users {
@@ -1855,6 +2079,24 @@ func UpdateAPIKey(apiKey *APIKey, executor, ipAddress, role string) error {
 	return err
 }
 
+// RotateAPIKey generates a new key for the API key with the specified ID.
+// The current key is still accepted for the specified grace period
+func RotateAPIKey(keyID string, gracePeriod time.Duration, executor, ipAddress, role string) (APIKey, error) {
+	apiKey, err := provider.apiKeyExists(keyID)
+	if err != nil {
+		return apiKey, err
+	}
+	if err := apiKey.rotate(gracePeriod); err != nil {
+		return apiKey, err
+	}
+	if err := provider.rotateAPIKey(&apiKey); err != nil {
+		return apiKey, err
+	}
+	cachedAPIKeys.Remove(keyID)
+	executeAction(operationUpdate, executor, ipAddress, actionObjectAPIKey, apiKey.KeyID, role, &apiKey)
+	return apiKey, nil
+}
+
 // DeleteAPIKey deletes an existing API key
 func DeleteAPIKey(keyID string, executor, ipAddress, role string) error {
 	apiKey, err := provider.apiKeyExists(keyID)
@@ -2046,7 +2288,12 @@ func AddAdmin(admin *Admin, executor, ipAddress, role string) error {
 	admin.Filters.TOTPConfig = AdminTOTPConfig{
 		Enabled: false,
 	}
+	admin.Filters.WebAuthnCredentials = nil
+	admin.Filters.RequireWebAuthn = false
 	admin.Username = config.convertName(admin.Username)
+	if err := checkAdminRoles(admin); err != nil {
+		return err
+	}
 	err := provider.addAdmin(admin)
 	if err == nil {
 		isAdminCreated.Store(true)
@@ -2057,6 +2304,9 @@ func AddAdmin(admin *Admin, executor, ipAddress, role string) error {
 
 // UpdateAdmin updates an existing SFTPGo admin
 func UpdateAdmin(admin *Admin, executor, ipAddress, role string) error {
+	if err := checkAdminRoles(admin); err != nil {
+		return err
+	}
 	err := provider.updateAdmin(admin)
 	if err == nil {
 		executeAction(operationUpdate, executor, ipAddress, actionObjectAdmin, admin.Username, role, admin)
@@ -2174,14 +2424,19 @@ func DeleteUser(username, executor, ipAddress, role string) error {
 	}
 	err = provider.deleteUser(user, config.IsShared == 1)
 	if err == nil {
-		RemoveCachedWebDAVUser(user.Username)
-		delayedQuotaUpdater.resetUserQuota(user.Username)
-		cachedUserPasswords.Remove(username)
-		executeAction(operationDelete, executor, ipAddress, actionObjectUser, user.Username, role, &user)
+		onUserDeleted(&user, executor, ipAddress, role)
 	}
 	return err
 }
 
+func onUserDeleted(user *User, executor, ipAddress, role string) {
+	RemoveCachedWebDAVUser(user.Username)
+	delayedQuotaUpdater.resetUserQuota(user.Username)
+	cachedUserPasswords.Remove(user.Username)
+	deleteUserDAVProperties(user.Username)
+	executeAction(operationDelete, executor, ipAddress, actionObjectUser, user.Username, role, user)
+}
+
 // AddActiveTransfer stores the specified transfer
 func AddActiveTransfer(transfer ActiveTransfer) {
 	if err := provider.addActiveTransfer(transfer); err != nil {
@@ -2246,6 +2501,11 @@ func GetSharedSession(key string) (Session, error) {
 	return provider.getSharedSession(key)
 }
 
+// GetSharedSessions retrieves the sessions with the specified type
+func GetSharedSessions(sessionType SessionType) ([]Session, error) {
+	return provider.getSharedSessions(sessionType)
+}
+
 // CleanupSharedSessions removes the shared session with the specified type and
 // before the specified time
 func CleanupSharedSessions(sessionType SessionType, before time.Time) error {
@@ -2314,26 +2574,30 @@ func AddFolder(folder *vfs.BaseVirtualFolder, executor, ipAddress, role string)
 func UpdateFolder(folder *vfs.BaseVirtualFolder, users []string, groups []string, executor, ipAddress, role string) error {
 	err := provider.updateFolder(folder)
 	if err == nil {
-		executeAction(operationUpdate, executor, ipAddress, actionObjectFolder, folder.Name, role, &wrappedFolder{Folder: *folder})
-		usersInGroups, errGrp := provider.getUsersInGroups(groups)
-		if errGrp == nil {
-			users = append(users, usersInGroups...)
-			users = util.RemoveDuplicates(users, false)
+		onFolderUpdated(folder, users, groups, executor, ipAddress, role)
+	}
+	return err
+}
+
+func onFolderUpdated(folder *vfs.BaseVirtualFolder, users []string, groups []string, executor, ipAddress, role string) {
+	executeAction(operationUpdate, executor, ipAddress, actionObjectFolder, folder.Name, role, &wrappedFolder{Folder: *folder})
+	usersInGroups, errGrp := provider.getUsersInGroups(groups)
+	if errGrp == nil {
+		users = append(users, usersInGroups...)
+		users = util.RemoveDuplicates(users, false)
+	} else {
+		providerLog(logger.LevelWarn, "unable to get users in groups %+v: %v", groups, errGrp)
+	}
+	for _, user := range users {
+		provider.setUpdatedAt(user)
+		u, err := provider.userExists(user, "")
+		if err == nil {
+			webDAVUsersCache.swap(&u, "")
+			executeAction(operationUpdate, executor, ipAddress, actionObjectUser, u.Username, u.Role, &u)
 		} else {
-			providerLog(logger.LevelWarn, "unable to get users in groups %+v: %v", groups, errGrp)
-		}
-		for _, user := range users {
-			provider.setUpdatedAt(user)
-			u, err := provider.userExists(user, "")
-			if err == nil {
-				webDAVUsersCache.swap(&u, "")
-				executeAction(operationUpdate, executor, ipAddress, actionObjectUser, u.Username, u.Role, &u)
-			} else {
-				RemoveCachedWebDAVUser(user)
-			}
+			RemoveCachedWebDAVUser(user)
 		}
 	}
-	return err
 }
 
 // DeleteFolder deletes an existing folder.
@@ -2345,28 +2609,32 @@ func DeleteFolder(folderName, executor, ipAddress, role string) error {
 	}
 	err = provider.deleteFolder(folder)
 	if err == nil {
-		executeAction(operationDelete, executor, ipAddress, actionObjectFolder, folder.Name, role, &wrappedFolder{Folder: folder})
-		users := folder.Users
-		usersInGroups, errGrp := provider.getUsersInGroups(folder.Groups)
-		if errGrp == nil {
-			users = append(users, usersInGroups...)
-			users = util.RemoveDuplicates(users, false)
-		} else {
-			providerLog(logger.LevelWarn, "unable to get users in groups %+v: %v", folder.Groups, errGrp)
-		}
-		for _, user := range users {
-			provider.setUpdatedAt(user)
-			u, err := provider.userExists(user, "")
-			if err == nil {
-				executeAction(operationUpdate, executor, ipAddress, actionObjectUser, u.Username, u.Role, &u)
-			}
-			RemoveCachedWebDAVUser(user)
-		}
-		delayedQuotaUpdater.resetFolderQuota(folderName)
+		onFolderDeleted(&folder, executor, ipAddress, role)
 	}
 	return err
 }
 
+func onFolderDeleted(folder *vfs.BaseVirtualFolder, executor, ipAddress, role string) {
+	executeAction(operationDelete, executor, ipAddress, actionObjectFolder, folder.Name, role, &wrappedFolder{Folder: *folder})
+	users := folder.Users
+	usersInGroups, errGrp := provider.getUsersInGroups(folder.Groups)
+	if errGrp == nil {
+		users = append(users, usersInGroups...)
+		users = util.RemoveDuplicates(users, false)
+	} else {
+		providerLog(logger.LevelWarn, "unable to get users in groups %+v: %v", folder.Groups, errGrp)
+	}
+	for _, user := range users {
+		provider.setUpdatedAt(user)
+		u, err := provider.userExists(user, "")
+		if err == nil {
+			executeAction(operationUpdate, executor, ipAddress, actionObjectUser, u.Username, u.Role, &u)
+		}
+		RemoveCachedWebDAVUser(user)
+	}
+	delayedQuotaUpdater.resetFolderQuota(folder.Name)
+}
+
 // GetFolderByName returns the folder with the specified name if any
 func GetFolderByName(name string) (vfs.BaseVirtualFolder, error) {
 	name = config.convertName(name)
@@ -2594,6 +2862,10 @@ func createProvider(basePath string) error {
 		return initializeMySQLProvider()
 	case BoltDataProviderName:
 		return initializeBoltProvider(basePath)
+	case EtcdDataProviderName:
+		return initializeEtcdProvider()
+	case RedisDataProviderName:
+		return initializeRedisProvider()
 	case MemoryDataProviderName:
 		initializeMemoryProvider(basePath)
 		return nil
@@ -2657,7 +2929,8 @@ func buildUserHomeDir(user *User) {
 			return
 		}
 		switch user.FsConfig.Provider {
-		case sdk.SFTPFilesystemProvider, sdk.S3FilesystemProvider, sdk.AzureBlobFilesystemProvider, sdk.GCSFilesystemProvider, sdk.HTTPFilesystemProvider:
+		case sdk.SFTPFilesystemProvider, sdk.S3FilesystemProvider, sdk.AzureBlobFilesystemProvider, sdk.GCSFilesystemProvider, sdk.HTTPFilesystemProvider,
+			vfs.UnionFilesystemProvider:
 			if tempPath != "" {
 				user.HomeDir = filepath.Join(tempPath, user.Username)
 			} else {
@@ -2741,6 +3014,12 @@ func validateAssociatedVirtualFolders(vfolders []vfs.VirtualFolder) ([]vfs.Virtu
 		if err := validateFolderQuotaLimits(v); err != nil {
 			return nil, err
 		}
+		if v.UploadBandwidth < 0 {
+			v.UploadBandwidth = 0
+		}
+		if v.DownloadBandwidth < 0 {
+			v.DownloadBandwidth = 0
+		}
 		if v.Name == "" {
 			return nil, util.NewI18nError(util.NewValidationError("folder name is mandatory"), util.I18nErrorFolderNameRequired)
 		}
@@ -2763,9 +3042,11 @@ func validateAssociatedVirtualFolders(vfolders []vfs.VirtualFolder) ([]vfs.Virtu
 			BaseVirtualFolder: vfs.BaseVirtualFolder{
 				Name: v.Name,
 			},
-			VirtualPath: cleanedVPath,
-			QuotaSize:   v.QuotaSize,
-			QuotaFiles:  v.QuotaFiles,
+			VirtualPath:       cleanedVPath,
+			QuotaSize:         v.QuotaSize,
+			QuotaFiles:        v.QuotaFiles,
+			UploadBandwidth:   v.UploadBandwidth,
+			DownloadBandwidth: v.DownloadBandwidth,
 		})
 		folderNames[v.Name] = true
 	}
@@ -2805,6 +3086,25 @@ func validateUserTOTPConfig(c *UserTOTPConfig, username string) error {
 	return nil
 }
 
+func validateUserPushMFAConfig(c *UserPushMFAConfig) error {
+	c.Username = strings.TrimSpace(c.Username)
+	if !c.Enabled {
+		c.Username = ""
+		c.Protocols = nil
+		return nil
+	}
+	c.Protocols = util.RemoveDuplicates(c.Protocols, false)
+	if len(c.Protocols) == 0 {
+		return util.NewValidationError("push: specify at least one protocol")
+	}
+	for _, protocol := range c.Protocols {
+		if !util.Contains(PushMFAProtocols, protocol) {
+			return util.NewValidationError(fmt.Sprintf("push: invalid protocol %q", protocol))
+		}
+	}
+	return nil
+}
+
 func validateUserRecoveryCodes(user *User) error {
 	for i := 0; i < len(user.Filters.RecoveryCodes); i++ {
 		code := &user.Filters.RecoveryCodes[i]
@@ -2994,6 +3294,34 @@ func validateBandwidthLimit(bl sdk.BandwidthLimit) error {
 	return nil
 }
 
+func validateSourceAuthPolicies(policies []SourceAuthPolicy) error {
+	for idx := range policies {
+		policy := &policies[idx]
+		if len(policy.Sources) == 0 {
+			return util.NewValidationError(fmt.Sprintf("source auth policy #%d: no source specified", idx+1))
+		}
+		for _, source := range policy.Sources {
+			if _, _, err := net.ParseCIDR(source); err != nil {
+				return util.NewValidationError(fmt.Sprintf("source auth policy #%d: could not parse source %q: %v",
+					idx+1, source, err))
+			}
+		}
+		policy.DeniedLoginMethods = util.RemoveDuplicates(policy.DeniedLoginMethods, false)
+		for _, method := range policy.DeniedLoginMethods {
+			if !util.Contains(ValidLoginMethods, method) {
+				return util.NewValidationError(fmt.Sprintf("source auth policy #%d: invalid login method %q", idx+1, method))
+			}
+		}
+		policy.TwoFactorAuthProtocols = util.RemoveDuplicates(policy.TwoFactorAuthProtocols, false)
+		for _, protocol := range policy.TwoFactorAuthProtocols {
+			if !util.Contains(MFAProtocols, protocol) {
+				return util.NewValidationError(fmt.Sprintf("source auth policy #%d: invalid two-factor protocol %q", idx+1, protocol))
+			}
+		}
+	}
+	return nil
+}
+
 func validateBandwidthLimitsFilter(filters *sdk.BaseUserFilters) error {
 	for idx, bandwidthLimit := range filters.BandwidthLimits {
 		if err := validateBandwidthLimit(bandwidthLimit); err != nil {
@@ -3099,6 +3427,23 @@ func validateBaseFilters(filters *sdk.BaseUserFilters) error {
 	return validateFiltersPatternExtensions(filters)
 }
 
+func cleanSSHAlgorithms(algorithms []string) []string {
+	var result []string
+	for _, algo := range algorithms {
+		algo = strings.TrimSpace(algo)
+		if algo != "" {
+			result = append(result, algo)
+		}
+	}
+	return util.RemoveDuplicates(result, false)
+}
+
+func updateSSHAlgorithmsValues(algorithms *SSHAlgorithms) {
+	algorithms.KexAlgorithms = cleanSSHAlgorithms(algorithms.KexAlgorithms)
+	algorithms.Ciphers = cleanSSHAlgorithms(algorithms.Ciphers)
+	algorithms.MACs = cleanSSHAlgorithms(algorithms.MACs)
+}
+
 func validateCombinedUserFilters(user *User) error {
 	if user.Filters.TOTPConfig.Enabled && util.Contains(user.Filters.WebClient, sdk.WebClientMFADisabled) {
 		return util.NewI18nError(
@@ -3115,6 +3460,30 @@ func validateCombinedUserFilters(user *User) error {
 	return nil
 }
 
+func validateAttributes(attributes map[string]string) error {
+	if len(attributes) > maxAttributes {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("too many attributes: %d, max allowed: %d", len(attributes), maxAttributes)),
+			util.I18nErrorAttributesInvalid,
+		)
+	}
+	for k, v := range attributes {
+		if !attributeNameRegex.MatchString(k) {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("attribute name %q is not valid, the following characters are allowed: a-zA-Z0-9_.-", k)),
+				util.I18nErrorAttributesInvalid,
+			)
+		}
+		if len(v) > maxAttributeValueLength {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("the value for the attribute %q is too long, max allowed length: %d", k, maxAttributeValueLength)),
+				util.I18nErrorAttributesInvalid,
+			)
+		}
+	}
+	return nil
+}
+
 func validateBaseParams(user *User) error {
 	if user.Username == "" {
 		return util.NewI18nError(util.NewValidationError("username is mandatory"), util.I18nErrorUsernameRequired)
@@ -3164,6 +3533,9 @@ func validateBaseParams(user *User) error {
 	if user.Filters.IsAnonymous {
 		user.setAnonymousSettings()
 	}
+	if err := validateAttributes(user.Attributes); err != nil {
+		return err
+	}
 	err := user.FsConfig.Validate(user.GetEncryptionAdditionalData())
 	if err != nil {
 		return err
@@ -3230,6 +3602,9 @@ func ValidateFolder(folder *vfs.BaseVirtualFolder) error {
 	if folder.HasRedactedSecret() {
 		return errors.New("cannot save a folder with a redacted secret")
 	}
+	if err := validateAttributes(folder.Attributes); err != nil {
+		return err
+	}
 	return folder.FsConfig.Validate(folder.GetEncryptionAdditionalData())
 }
 
@@ -3255,6 +3630,15 @@ func ValidateUser(user *User) error {
 	if err := validateUserRecoveryCodes(user); err != nil {
 		return util.NewI18nError(err, util.I18nErrorRecoveryCodesInvalid)
 	}
+	if err := user.Filters.WebAuthnCredentials.validate(); err != nil {
+		return util.NewI18nError(err, util.I18nErrorWebAuthnInvalid)
+	}
+	if err := validateUserS3Credentials(user); err != nil {
+		return err
+	}
+	if err := validateUserPushMFAConfig(&user.Filters.PushMFA); err != nil {
+		return util.NewI18nError(err, util.I18nErrorPushMFAInvalid)
+	}
 	vfolders, err := validateAssociatedVirtualFolders(user.VirtualFolders)
 	if err != nil {
 		return err
@@ -3272,6 +3656,19 @@ func ValidateUser(user *User) error {
 	if err := validateBaseFilters(&user.Filters.BaseUserFilters); err != nil {
 		return err
 	}
+	updateSSHAlgorithmsValues(&user.Filters.SSHAlgorithms)
+	if err := user.Filters.SSHCertificates.validate(); err != nil {
+		return util.NewI18nError(err, util.I18nErrorSSHCertificatesInvalid)
+	}
+	if err := user.Filters.AccessSchedule.validate(); err != nil {
+		return util.NewI18nError(err, util.I18nErrorAccessScheduleInvalid)
+	}
+	if err := user.Filters.FTP.validate(); err != nil {
+		return util.NewI18nError(err, util.I18nErrorFTPSettingsInvalid)
+	}
+	if err := validateSourceAuthPolicies(user.Filters.SourceAuthPolicies); err != nil {
+		return util.NewI18nError(err, util.I18nErrorSourceAuthPolicyInvalid)
+	}
 	if !user.HasExternalAuth() {
 		user.Filters.ExternalAuthCacheTime = 0
 	}
@@ -3294,14 +3691,14 @@ func isPasswordOK(user *User, password string) (bool, error) {
 			return match, ErrInvalidCredentials
 		}
 		match = true
-		updatePwd = config.PasswordHashing.Algo != HashingAlgoBcrypt
+		updatePwd = !isPasswordHashUpToDate(user.Password)
 	} else if strings.HasPrefix(user.Password, argonPwdPrefix) {
 		match, err = argon2id.ComparePasswordAndHash(password, user.Password)
 		if err != nil {
 			providerLog(logger.LevelError, "error comparing password with argon hash: %v", err)
 			return match, err
 		}
-		updatePwd = config.PasswordHashing.Algo != HashingAlgoArgon2ID
+		updatePwd = !isPasswordHashUpToDate(user.Password)
 	} else if util.IsStringPrefixInSlice(user.Password, unixPwdPrefixes) {
 		match, err = compareUnixPasswordAndHash(user, password)
 		if err != nil {
@@ -3333,6 +3730,7 @@ func convertUserPassword(username, plainPwd string) {
 		providerLog(logger.LevelWarn, "unable to convert password for user %s: %v", username, err)
 	} else {
 		providerLog(logger.LevelDebug, "password converted for user %s", username)
+		pwdMigrationMgr.onRehashed()
 	}
 }
 
@@ -3346,7 +3744,7 @@ func checkUserAndTLSCertificate(user *User, protocol string, tlsCert *x509.Certi
 		return *user, err
 	}
 	switch protocol {
-	case protocolFTP, protocolWebDAV:
+	case protocolFTP, protocolWebDAV, protocolHTTP:
 		for _, cert := range user.Filters.TLSCerts {
 			derBlock, _ := pem.Decode([]byte(cert))
 			if derBlock != nil && bytes.Equal(derBlock.Bytes, tlsCert.Raw) {
@@ -3640,10 +4038,48 @@ func doBuiltinKeyboardInteractiveAuth(user *User, client ssh.KeyboardInteractive
 	if err != nil {
 		return 0, err
 	}
-	return checkKeyboardInteractiveSecondFactor(user, client, protocol)
+	return checkKeyboardInteractiveSecondFactor(user, client, ip, protocol)
+}
+
+// CheckPushSecondFactor sends a push notification to the specified user and waits for the approval.
+// A nil error means that the user approved the authentication request
+func CheckPushSecondFactor(user *User, ip, protocol string) error {
+	err := mfa.SendPushRequest(mfa.PushRequest{
+		Username: user.Filters.PushMFA.GetProviderUsername(user.Username),
+		IP:       ip,
+		Protocol: protocol,
+	})
+	if err != nil {
+		providerLog(logger.LevelWarn, "push authentication failed for user %q, protocol %v, err: %v",
+			user.Username, protocol, err)
+		return err
+	}
+	providerLog(logger.LevelDebug, "push authentication approved for user %q, protocol %v", user.Username, protocol)
+	return nil
 }
 
-func checkKeyboardInteractiveSecondFactor(user *User, client ssh.KeyboardInteractiveChallenge, protocol string) (int, error) {
+// CanFallbackToTOTP returns true if the user can provide a TOTP authentication code
+// for the specified protocol after a failed push authentication
+func CanFallbackToTOTP(user *User, protocol string, pushErr error) bool {
+	return errors.Is(pushErr, mfa.ErrPushUnavailable) && mfa.IsPushFallbackToTOTPEnabled() &&
+		user.Filters.TOTPConfig.Enabled && util.Contains(user.Filters.TOTPConfig.Protocols, protocol)
+}
+
+func checkKeyboardInteractiveSecondFactor(user *User, client ssh.KeyboardInteractiveChallenge, ip, protocol string) (int, error) {
+	if user.Filters.PushMFA.IsEnabledForProtocol(protocolSSH) {
+		// instructions only, some clients may not display them
+		if _, err := client("", "A push notification was sent to your device, approve it to continue", nil, nil); err != nil {
+			return 0, err
+		}
+		err := CheckPushSecondFactor(user, ip, protocol)
+		if err == nil {
+			return 1, nil
+		}
+		if !CanFallbackToTOTP(user, protocolSSH, err) {
+			return 0, util.NewValidationError("push authentication failed")
+		}
+		providerLog(logger.LevelDebug, "push authentication unavailable for user %q, fallback to TOTP", user.Username)
+	}
 	if !user.Filters.TOTPConfig.Enabled || !util.Contains(user.Filters.TOTPConfig.Protocols, protocolSSH) {
 		return 1, nil
 	}
@@ -3891,7 +4327,7 @@ func doKeyboardInteractiveAuth(user *User, authHook string, client ssh.KeyboardI
 		if plugin.Handler.HasAuthScope(plugin.AuthScopeKeyboardInteractive) {
 			authResult, err = executeKeyboardInteractivePlugin(user, client, ip, protocol)
 			if authResult == 1 && err == nil {
-				authResult, err = checkKeyboardInteractiveSecondFactor(user, client, protocol)
+				authResult, err = checkKeyboardInteractiveSecondFactor(user, client, ip, protocol)
 			}
 		} else if authHook != "" {
 			if strings.HasPrefix(authHook, "http") {
@@ -4073,10 +4509,25 @@ func executePreLoginHook(username, loginMethod, ip, protocol string, oidcTokenFi
 	userCreatedAt := u.CreatedAt
 	totpConfig := u.Filters.TOTPConfig
 	recoveryCodes := u.Filters.RecoveryCodes
+	archive := u.Filters.Archive
+	ldapSync := u.Filters.LDAPSync
+	s3Credentials := u.Filters.S3Credentials
 	err = json.Unmarshal(out, &u)
 	if err != nil {
 		return u, fmt.Errorf("invalid pre-login hook response %q, error: %v", string(out), err)
 	}
+	var templateAttributes struct {
+		Attributes map[string]string `json:"template_attributes"`
+	}
+	if err := json.Unmarshal(out, &templateAttributes); err != nil {
+		return u, fmt.Errorf("invalid pre-login hook response %q, error: %v", string(out), err)
+	}
+	u.ApplyTemplate(UserTemplateFields{
+		Username:   u.Username,
+		Password:   u.Password,
+		PublicKeys: u.PublicKeys,
+		Attributes: templateAttributes.Attributes,
+	})
 	u.ID = userID
 	u.UsedQuotaSize = userUsedQuotaSize
 	u.UsedQuotaFiles = userUsedQuotaFiles
@@ -4092,9 +4543,12 @@ func executePreLoginHook(username, loginMethod, ip, protocol string, oidcTokenFi
 		err = provider.addUser(&u)
 	} else {
 		u.UpdatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
-		// preserve TOTP config and recovery codes
+		// preserve TOTP config, recovery codes, archive, LDAP sync details and S3 credentials
 		u.Filters.TOTPConfig = totpConfig
 		u.Filters.RecoveryCodes = recoveryCodes
+		u.Filters.Archive = archive
+		u.Filters.LDAPSync = ldapSync
+		u.Filters.S3Credentials = s3Credentials
 		err = provider.updateUser(&u)
 		if err == nil {
 			webDAVUsersCache.swap(&u, "")
@@ -4112,6 +4566,9 @@ func executePreLoginHook(username, loginMethod, ip, protocol string, oidcTokenFi
 
 // ExecutePostLoginHook executes the post login hook if defined
 func ExecutePostLoginHook(user *User, loginMethod, ip, protocol string, err error) {
+	if fnHandleLoginEvent != nil {
+		fnHandleLoginEvent(user, loginMethod, ip, protocol, err)
+	}
 	if config.PostLoginHook == "" {
 		return
 	}
@@ -4352,9 +4809,12 @@ func doExternalAuth(username, password string, pubKey []byte, keyboardInteractiv
 		user.FirstUpload = u.FirstUpload
 		user.CreatedAt = u.CreatedAt
 		user.UpdatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
-		// preserve TOTP config and recovery codes
+		// preserve TOTP config, recovery codes, archive, LDAP sync details and S3 credentials
 		user.Filters.TOTPConfig = u.Filters.TOTPConfig
 		user.Filters.RecoveryCodes = u.Filters.RecoveryCodes
+		user.Filters.Archive = u.Filters.Archive
+		user.Filters.LDAPSync = u.Filters.LDAPSync
+		user.Filters.S3Credentials = u.Filters.S3Credentials
 		err = provider.updateUser(&user)
 		if err == nil {
 			if protocol != protocolWebDAV {
@@ -4424,9 +4884,12 @@ func doPluginAuth(username, password string, pubKey []byte, ip, protocol string,
 		user.LastPasswordChange = u.LastPasswordChange
 		user.FirstDownload = u.FirstDownload
 		user.FirstUpload = u.FirstUpload
-		// preserve TOTP config and recovery codes
+		// preserve TOTP config, recovery codes, archive, LDAP sync details and S3 credentials
 		user.Filters.TOTPConfig = u.Filters.TOTPConfig
 		user.Filters.RecoveryCodes = u.Filters.RecoveryCodes
+		user.Filters.Archive = u.Filters.Archive
+		user.Filters.LDAPSync = u.Filters.LDAPSync
+		user.Filters.S3Credentials = u.Filters.S3Credentials
 		err = provider.updateUser(&user)
 		if err == nil {
 			if protocol != protocolWebDAV {
@@ -0,0 +1,174 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	// max number of dead properties for each file or directory
+	maxDAVPropertiesPerPath = 100
+	// max size for the JSON serialized properties of a file or directory
+	maxDAVPropertiesSize = 64 * 1024
+)
+
+// DAVProperty defines a WebDAV dead property, a property set by the clients
+// using PROPPATCH requests and stored as is
+type DAVProperty struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Lang      string `json:"lang,omitempty"`
+	// Raw XML value of the property
+	InnerXML string `json:"inner_xml,omitempty"`
+}
+
+// DAVProperties defines the dead properties stored for a file or directory
+type DAVProperties struct {
+	Username   string
+	Path       string
+	Properties []DAVProperty
+	// Last update as unix timestamp in milliseconds
+	UpdatedAt int64
+}
+
+func (p *DAVProperties) validate() error {
+	if p.Username == "" {
+		return util.NewValidationError("username is mandatory")
+	}
+	if p.Path == "" || !path.IsAbs(p.Path) {
+		return util.NewValidationError(fmt.Sprintf("invalid path %q", p.Path))
+	}
+	if len(p.Properties) > maxDAVPropertiesPerPath {
+		return util.NewValidationError(fmt.Sprintf("too many properties: %d, max allowed: %d",
+			len(p.Properties), maxDAVPropertiesPerPath))
+	}
+	for _, prop := range p.Properties {
+		if prop.Name == "" {
+			return util.NewValidationError("property name is mandatory")
+		}
+	}
+	return nil
+}
+
+func (p *DAVProperties) marshalProperties() (string, error) {
+	data, err := json.Marshal(p.Properties)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxDAVPropertiesSize {
+		return "", util.NewValidationError(fmt.Sprintf("properties too large: %d bytes, max allowed: %d",
+			len(data), maxDAVPropertiesSize))
+	}
+	return string(data), nil
+}
+
+func (p *DAVProperties) unmarshalProperties(data string) error {
+	if data == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(data), &p.Properties)
+}
+
+// IsDAVPropertiesSupported returns true if the configured provider supports
+// storing WebDAV dead properties
+func (c *Config) IsDAVPropertiesSupported() bool {
+	switch c.Driver {
+	case SQLiteDataProviderName, MySQLDataProviderName, PGSQLDataProviderName, CockroachDataProviderName:
+		return true
+	default:
+		return false
+	}
+}
+
+// getDAVPathHash returns the hash used to lookup the properties for the specified path,
+// paths can be too long to be indexed
+func getDAVPathHash(virtualPath string) string {
+	h := sha256.Sum256([]byte(virtualPath))
+	return hex.EncodeToString(h[:])
+}
+
+// GetDAVProperties returns the dead properties for the specified user and path.
+// An empty slice is returned if no property is stored
+func GetDAVProperties(username, virtualPath string) ([]DAVProperty, error) {
+	if !config.IsDAVPropertiesSupported() {
+		return nil, ErrNotImplemented
+	}
+	props, err := provider.getDAVProperties(username, util.CleanPath(virtualPath))
+	if err != nil {
+		if errors.Is(err, util.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return props.Properties, nil
+}
+
+// SetDAVProperties replaces the dead properties for the specified user and path.
+// Setting an empty slice removes the stored properties
+func SetDAVProperties(username, virtualPath string, properties []DAVProperty) error {
+	if !config.IsDAVPropertiesSupported() {
+		return ErrNotImplemented
+	}
+	props := DAVProperties{
+		Username:   username,
+		Path:       util.CleanPath(virtualPath),
+		Properties: properties,
+		UpdatedAt:  util.GetTimeAsMsSinceEpoch(time.Now()),
+	}
+	if err := props.validate(); err != nil {
+		return err
+	}
+	if len(props.Properties) == 0 {
+		return provider.deleteDAVProperties(username, props.Path, false)
+	}
+	return provider.setDAVProperties(&props)
+}
+
+// RenameDAVProperties moves the dead properties for the specified path, and
+// for its children if it is a directory, to the target path
+func RenameDAVProperties(username, source, target string) error {
+	if !config.IsDAVPropertiesSupported() {
+		return ErrNotImplemented
+	}
+	return provider.renameDAVProperties(username, util.CleanPath(source), util.CleanPath(target))
+}
+
+// DeleteDAVProperties removes the dead properties for the specified path and,
+// if it is a directory, for its children
+func DeleteDAVProperties(username, virtualPath string) error {
+	if !config.IsDAVPropertiesSupported() {
+		return ErrNotImplemented
+	}
+	return provider.deleteDAVProperties(username, util.CleanPath(virtualPath), true)
+}
+
+func deleteUserDAVProperties(username string) {
+	if !config.IsDAVPropertiesSupported() {
+		return
+	}
+	if err := provider.deleteDAVProperties(username, "/", true); err != nil {
+		providerLog(logger.LevelError, "unable to delete dead properties for user %q: %v", username, err)
+	}
+}
@@ -0,0 +1,694 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !noetcd
+// +build !noetcd
+
+package dataprovider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/version"
+)
+
+const (
+	etcdDefaultPort        = 2379
+	etcdDialTimeout        = 10 * time.Second
+	etcdTxnGuardKey        = "txn_guard"
+	etcdWatchRetryInterval = 5 * time.Second
+)
+
+// EtcdProvider defines the auth provider for etcd key/value store.
+// Objects are stored as JSON values using the "<name>/<bucket>/<key>" layout.
+// Write transactions are serialized using a guard key, so they have the same
+// semantics as the bolt ones. If the provider is shared, changes made by other
+// instances are received using an etcd watch and the caches are updated instantly
+type EtcdProvider struct {
+	*kvProvider
+	client *clientv3.Client
+	root   string
+	cancel context.CancelFunc
+}
+
+func init() {
+	version.AddFeature("+etcd")
+}
+
+func initializeEtcdProvider() error {
+	cfg, err := getEtcdClientConfig()
+	if err != nil {
+		providerLog(logger.LevelError, "invalid etcd configuration: %v", err)
+		return err
+	}
+	client, err := clientv3.New(cfg)
+	if err != nil {
+		providerLog(logger.LevelError, "error creating etcd client: %v", err)
+		return err
+	}
+	root := getKVKeyPrefix()
+	providerLog(logger.LevelDebug, "etcd client created, endpoints: %+v, key prefix: %q", cfg.Endpoints, root)
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &EtcdProvider{
+		client: client,
+		root:   root,
+		cancel: cancel,
+	}
+	p.kvProvider = &kvProvider{store: p}
+	if config.IsShared == 1 {
+		go p.watch(ctx)
+	}
+	provider = p
+	return nil
+}
+
+func getEtcdClientConfig() (clientv3.Config, error) {
+	cfg := clientv3.Config{
+		Endpoints:   getKVStoreAddrs(etcdDefaultPort),
+		DialTimeout: etcdDialTimeout,
+		Username:    config.Username,
+		Password:    config.Password,
+	}
+	if config.ConnectionString != "" {
+		cfg.Endpoints = strings.Split(config.ConnectionString, ",")
+	}
+	if len(cfg.Endpoints) == 0 {
+		return cfg, errors.New("at least an etcd endpoint is required")
+	}
+	tlsConfig, err := getKVStoreTLSConfig()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.TLS = tlsConfig
+	return cfg, nil
+}
+
+func (p *EtcdProvider) begin(readOnly bool) (kvSnapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	s := &etcdSnapshot{
+		ctx:    ctx,
+		cancel: cancel,
+		p:      p,
+	}
+	if !readOnly {
+		// reading the guard key sets the snapshot revision for the transaction
+		if _, err := s.read(p.key(etcdTxnGuardKey)); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// etcdSnapshot implements kvSnapshot. All the reads are done at the revision
+// of the first one
+type etcdSnapshot struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	p      *EtcdProvider
+	rev    int64
+}
+
+func (s *etcdSnapshot) read(key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	if s.rev > 0 {
+		opts = append(opts, clientv3.WithRev(s.rev))
+	}
+	resp, err := s.p.client.Get(s.ctx, key, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if s.rev == 0 {
+		s.rev = resp.Header.Revision
+	}
+	return resp, nil
+}
+
+func (s *etcdSnapshot) get(bucket, key string) ([]byte, error) {
+	resp, err := s.read(s.p.key(bucket, key))
+	if err != nil || len(resp.Kvs) == 0 {
+		return nil, err
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+func (s *etcdSnapshot) getAll(bucket string) (map[string][]byte, error) {
+	prefix := s.p.key(bucket) + "/"
+	resp, err := s.read(prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string][]byte, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		values[strings.TrimPrefix(string(kv.Key), prefix)] = kv.Value
+	}
+	return values, nil
+}
+
+func (s *etcdSnapshot) count(bucket string) (int, error) {
+	resp, err := s.read(s.p.key(bucket)+"/", clientv3.WithPrefix(), clientv3.WithCountOnly())
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.Count), nil
+}
+
+func (s *etcdSnapshot) commit(changes []kvChange) (bool, error) {
+	guardKey := s.p.key(etcdTxnGuardKey)
+	ops := make([]clientv3.Op, 0, len(changes)+1)
+	for _, c := range changes {
+		if c.deleted {
+			ops = append(ops, clientv3.OpDelete(s.p.key(c.bucket, c.key)))
+		} else {
+			ops = append(ops, clientv3.OpPut(s.p.key(c.bucket, c.key), string(c.value)))
+		}
+	}
+	ops = append(ops, clientv3.OpPut(guardKey, strconv.FormatInt(s.rev, 10)))
+	resp, err := s.p.client.Txn(s.ctx).
+		If(clientv3.Compare(clientv3.ModRevision(guardKey), "<", s.rev+1)).
+		Then(ops...).
+		Commit()
+	if err != nil {
+		return false, err
+	}
+	return resp.Succeeded, nil
+}
+
+func (s *etcdSnapshot) close() {
+	s.cancel()
+}
+
+func (p *EtcdProvider) addSharedSession(session Session) error {
+	if err := session.validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(session.Data)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(kvSession{
+		Key:       session.Key,
+		Data:      data,
+		Type:      session.Type,
+		Timestamp: session.Timestamp,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	_, err = p.client.Put(ctx, p.key(kvSessionsBucket, session.Key), string(buf))
+	return err
+}
+
+func (p *EtcdProvider) deleteSharedSession(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	resp, err := p.client.Delete(ctx, p.key(kvSessionsBucket, key))
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return util.NewRecordNotFoundError(fmt.Sprintf("session %q does not exist", key))
+	}
+	return nil
+}
+
+func (p *EtcdProvider) getSharedSession(key string) (Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	resp, err := p.client.Get(ctx, p.key(kvSessionsBucket, key))
+	if err != nil {
+		return Session{}, err
+	}
+	if len(resp.Kvs) == 0 {
+		return Session{}, util.NewRecordNotFoundError(fmt.Sprintf("session %q does not exist", key))
+	}
+	var session kvSession
+	if err := json.Unmarshal(resp.Kvs[0].Value, &session); err != nil {
+		return Session{}, err
+	}
+	return Session{
+		Key:       session.Key,
+		Data:      session.Data,
+		Type:      session.Type,
+		Timestamp: session.Timestamp,
+	}, nil
+}
+
+func (p *EtcdProvider) getSharedSessions(sessionType SessionType) ([]Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	resp, err := p.client.Get(ctx, p.key(kvSessionsBucket)+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	for _, kv := range resp.Kvs {
+		var session kvSession
+		if err := json.Unmarshal(kv.Value, &session); err != nil {
+			return sessions, err
+		}
+		if session.Type != sessionType {
+			continue
+		}
+		sessions = append(sessions, Session{
+			Key:       session.Key,
+			Data:      session.Data,
+			Type:      session.Type,
+			Timestamp: session.Timestamp,
+		})
+	}
+	return sessions, nil
+}
+
+func (p *EtcdProvider) cleanupSharedSessions(sessionType SessionType, before int64) error {
+	return p.deleteExpired(kvSessionsBucket, func(value []byte) (bool, error) {
+		var session kvSession
+		if err := json.Unmarshal(value, &session); err != nil {
+			return false, err
+		}
+		return session.Type == sessionType && session.Timestamp < before, nil
+	})
+}
+
+func (p *EtcdProvider) addActiveTransfer(transfer ActiveTransfer) error {
+	now := util.GetTimeAsMsSinceEpoch(time.Now())
+	transfer.CreatedAt = now
+	transfer.UpdatedAt = now
+	buf, err := json.Marshal(transfer)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	_, err = p.client.Put(ctx, p.getTransferKey(transfer.ID, transfer.ConnID), string(buf))
+	return err
+}
+
+func (p *EtcdProvider) updateActiveTransferSizes(ulSize, dlSize, transferID int64, connectionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	key := p.getTransferKey(transferID, connectionID)
+	resp, err := p.client.Get(ctx, key)
+	if err != nil || len(resp.Kvs) == 0 {
+		return err
+	}
+	var transfer ActiveTransfer
+	if err := json.Unmarshal(resp.Kvs[0].Value, &transfer); err != nil {
+		return err
+	}
+	transfer.CurrentULSize = ulSize
+	transfer.CurrentDLSize = dlSize
+	transfer.UpdatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	buf, err := json.Marshal(transfer)
+	if err != nil {
+		return err
+	}
+	// the transfer could be removed in the meantime, we don't want to add it again
+	_, err = p.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", resp.Kvs[0].ModRevision)).
+		Then(clientv3.OpPut(key, string(buf))).
+		Commit()
+	return err
+}
+
+func (p *EtcdProvider) removeActiveTransfer(transferID int64, connectionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	_, err := p.client.Delete(ctx, p.getTransferKey(transferID, connectionID))
+	return err
+}
+
+func (p *EtcdProvider) cleanupActiveTransfers(before time.Time) error {
+	updatedBefore := util.GetTimeAsMsSinceEpoch(before)
+	return p.deleteExpired(kvTransfersBucket, func(value []byte) (bool, error) {
+		var transfer ActiveTransfer
+		if err := json.Unmarshal(value, &transfer); err != nil {
+			return false, err
+		}
+		return transfer.UpdatedAt < updatedBefore, nil
+	})
+}
+
+func (p *EtcdProvider) getActiveTransfers(from time.Time) ([]ActiveTransfer, error) {
+	transfers := make([]ActiveTransfer, 0, 30)
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	resp, err := p.client.Get(ctx, p.key(kvTransfersBucket)+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	updatedAfter := util.GetTimeAsMsSinceEpoch(from)
+	for _, kv := range resp.Kvs {
+		var transfer ActiveTransfer
+		if err := json.Unmarshal(kv.Value, &transfer); err != nil {
+			return transfers, err
+		}
+		if transfer.UpdatedAt > updatedAfter {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers, nil
+}
+
+func (p *EtcdProvider) getTransferKey(transferID int64, connectionID string) string {
+	return p.key(kvTransfersBucket, connectionID, strconv.FormatInt(transferID, 10))
+}
+
+func (p *EtcdProvider) getTaskByName(name string) (Task, error) {
+	task, _, err := p.getTask(name)
+	return task, err
+}
+
+func (p *EtcdProvider) getTask(name string) (Task, int64, error) {
+	task := Task{
+		Name: name,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	resp, err := p.client.Get(ctx, p.key(kvTasksBucket, name))
+	if err != nil {
+		return task, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return task, 0, util.NewRecordNotFoundError(fmt.Sprintf("task %q does not exist", name))
+	}
+	err = json.Unmarshal(resp.Kvs[0].Value, &task)
+	return task, resp.Kvs[0].ModRevision, err
+}
+
+func (p *EtcdProvider) addTask(name string) error {
+	buf, err := json.Marshal(Task{
+		Name:     name,
+		UpdateAt: util.GetTimeAsMsSinceEpoch(time.Now()),
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	key := p.key(kvTasksBucket, name)
+	resp, err := p.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(buf))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return fmt.Errorf("%w: task %q already exists", ErrDuplicatedKey, name)
+	}
+	return nil
+}
+
+func (p *EtcdProvider) updateTask(name string, version int64) error {
+	return p.updateTaskInternal(name, func(task *Task) bool {
+		if task.Version != version {
+			return false
+		}
+		task.Version++
+		return true
+	})
+}
+
+func (p *EtcdProvider) updateTaskTimestamp(name string) error {
+	return p.updateTaskInternal(name, func(_ *Task) bool {
+		return true
+	})
+}
+
+// updateTaskInternal updates the task with the specified name if the update
+// function returns true, the task must not be modified concurrently
+func (p *EtcdProvider) updateTaskInternal(name string, update func(task *Task) bool) error {
+	task, modRevision, err := p.getTask(name)
+	if err != nil {
+		return err
+	}
+	if !update(&task) {
+		return util.NewRecordNotFoundError(fmt.Sprintf("task %q was updated concurrently", name))
+	}
+	task.UpdateAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	buf, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	key := p.key(kvTasksBucket, name)
+	resp, err := p.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", modRevision)).
+		Then(clientv3.OpPut(key, string(buf))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return util.NewRecordNotFoundError(fmt.Sprintf("task %q was updated concurrently", name))
+	}
+	return nil
+}
+
+func (p *EtcdProvider) addNode() error {
+	if err := currentNode.validate(); err != nil {
+		return fmt.Errorf("unable to register cluster node: %w", err)
+	}
+	now := util.GetTimeAsMsSinceEpoch(time.Now())
+	buf, err := json.Marshal(Node{
+		Name:      currentNode.Name,
+		Data:      currentNode.Data,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	if _, err = p.client.Put(ctx, p.key(kvNodesBucket, currentNode.Name), string(buf)); err != nil {
+		return fmt.Errorf("unable to register cluster node: %w", err)
+	}
+	providerLog(logger.LevelInfo, "registered as cluster node %q, port: %d, proto: %s",
+		currentNode.Name, currentNode.Data.Port, currentNode.Data.Proto)
+
+	return nil
+}
+
+func (p *EtcdProvider) getNodeByName(name string) (Node, error) {
+	var node Node
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	resp, err := p.client.Get(ctx, p.key(kvNodesBucket, name))
+	if err != nil {
+		return node, err
+	}
+	if len(resp.Kvs) == 0 {
+		return node, util.NewRecordNotFoundError(fmt.Sprintf("node %q does not exist", name))
+	}
+	if err := json.Unmarshal(resp.Kvs[0].Value, &node); err != nil {
+		return node, err
+	}
+	if node.UpdatedAt <= util.GetTimeAsMsSinceEpoch(time.Now().Add(activeNodeTimeDiff)) {
+		return node, util.NewRecordNotFoundError(fmt.Sprintf("node %q is not active", name))
+	}
+	return node, nil
+}
+
+func (p *EtcdProvider) getNodes() ([]Node, error) {
+	var nodes []Node
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	resp, err := p.client.Get(ctx, p.key(kvNodesBucket)+"/", clientv3.WithPrefix())
+	if err != nil {
+		return nodes, err
+	}
+	activeAfter := util.GetTimeAsMsSinceEpoch(time.Now().Add(activeNodeTimeDiff))
+	for _, kv := range resp.Kvs {
+		var node Node
+		if err := json.Unmarshal(kv.Value, &node); err != nil {
+			return nodes, err
+		}
+		if node.Name == currentNode.Name || node.UpdatedAt <= activeAfter {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (p *EtcdProvider) updateNodeTimestamp() error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	key := p.key(kvNodesBucket, currentNode.Name)
+	resp, err := p.client.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if len(resp.Kvs) == 0 {
+		return util.NewRecordNotFoundError(fmt.Sprintf("node %q does not exist", currentNode.Name))
+	}
+	var node Node
+	if err := json.Unmarshal(resp.Kvs[0].Value, &node); err != nil {
+		return err
+	}
+	node.UpdatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	buf, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.Put(ctx, key, string(buf))
+	return err
+}
+
+func (p *EtcdProvider) cleanupNodes() error {
+	updatedBefore := util.GetTimeAsMsSinceEpoch(time.Now().Add(10 * activeNodeTimeDiff))
+	return p.deleteExpired(kvNodesBucket, func(value []byte) (bool, error) {
+		var node Node
+		if err := json.Unmarshal(value, &node); err != nil {
+			return false, err
+		}
+		return node.UpdatedAt < updatedBefore, nil
+	})
+}
+
+// deleteExpired removes the keys inside the specified bucket for which the
+// isExpired function returns true. Keys modified after the check are preserved
+func (p *EtcdProvider) deleteExpired(bucket string, isExpired func(value []byte) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	resp, err := p.client.Get(ctx, p.key(bucket)+"/", clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	for _, kv := range resp.Kvs {
+		expired, err := isExpired(kv.Value)
+		if err != nil {
+			providerLog(logger.LevelError, "unable to decode key %q: %v", string(kv.Key), err)
+			continue
+		}
+		if !expired {
+			continue
+		}
+		_, err = p.client.Txn(ctx).
+			If(clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision)).
+			Then(clientv3.OpDelete(string(kv.Key))).
+			Commit()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *EtcdProvider) close() error {
+	p.cancel()
+	return p.client.Close()
+}
+
+func (p *EtcdProvider) reloadConfig() error {
+	return nil
+}
+
+func (p *EtcdProvider) resetDatabase() error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	_, err := p.client.Delete(ctx, p.root+"/", clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("unable to remove keys with prefix %q: %w", p.root, err)
+	}
+	return nil
+}
+
+func (p *EtcdProvider) key(elem ...string) string {
+	return p.root + "/" + strings.Join(elem, "/")
+}
+
+func (p *EtcdProvider) watch(ctx context.Context) {
+	prefix := p.root + "/"
+	var rev int64
+
+	for {
+		opts := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithPrevKV()}
+		if rev > 0 {
+			opts = append(opts, clientv3.WithRev(rev+1))
+		}
+		providerLog(logger.LevelDebug, "starting etcd watch for prefix %q, last revision: %d", prefix, rev)
+		for resp := range p.client.Watch(clientv3.WithRequireLeader(ctx), prefix, opts...) {
+			if err := resp.Err(); err != nil {
+				providerLog(logger.LevelError, "etcd watch error: %v", err)
+				if resp.CompactRevision > 0 {
+					// some events are lost, the periodic cache check will catch up
+					rev = 0
+				}
+				break
+			}
+			for _, ev := range resp.Events {
+				p.handleWatchEvent(ev)
+				rev = ev.Kv.ModRevision
+			}
+		}
+		select {
+		case <-ctx.Done():
+			providerLog(logger.LevelDebug, "etcd watch stopped")
+			return
+		case <-time.After(etcdWatchRetryInterval):
+		}
+	}
+}
+
+func (p *EtcdProvider) handleWatchEvent(ev *clientv3.Event) {
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(string(ev.Kv.Key), p.root+"/"), "/")
+	if !ok {
+		return
+	}
+	var oldValue []byte
+	if ev.PrevKv != nil {
+		oldValue = ev.PrevKv.Value
+	}
+	if ev.Type == clientv3.EventTypeDelete {
+		if isKVChangeRelevant(bucket, oldValue, nil) {
+			p.applyKVChange(bucket, key, oldValue, true)
+		}
+		return
+	}
+	if isKVChangeRelevant(bucket, oldValue, ev.Kv.Value) {
+		p.applyKVChange(bucket, key, ev.Kv.Value, false)
+	}
+}
@@ -0,0 +1,346 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !noetcd
+// +build !noetcd
+
+package dataprovider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	pb "go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memEtcdKV is an in-memory implementation of the etcd KV API. It keeps the
+// history of each key, so reads at a past revision and compares on the
+// create/mod revisions work as in etcd
+type memEtcdKV struct {
+	mu      sync.Mutex
+	rev     int64
+	history map[string][]*mvccpb.KeyValue
+}
+
+func newMemEtcdKV() *memEtcdKV {
+	return &memEtcdKV{
+		rev:     1,
+		history: make(map[string][]*mvccpb.KeyValue),
+	}
+}
+
+// lookup returns the key value at the specified revision, a nil value means
+// that the key does not exist. Deleted keys are stored with version 0
+func (m *memEtcdKV) lookup(key string, rev int64) *mvccpb.KeyValue {
+	h := m.history[key]
+	for i := len(h) - 1; i >= 0; i-- {
+		if h[i].ModRevision <= rev {
+			if h[i].Version == 0 {
+				return nil
+			}
+			return h[i]
+		}
+	}
+	return nil
+}
+
+func (m *memEtcdKV) keys(op clientv3.Op) []string {
+	key := string(op.KeyBytes())
+	end := string(op.RangeBytes())
+	if end == "" {
+		return []string{key}
+	}
+	var result []string
+	for k := range m.history {
+		if k >= key && (end == "\x00" || k < end) {
+			result = append(result, k)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (m *memEtcdKV) header() *pb.ResponseHeader {
+	return &pb.ResponseHeader{Revision: m.rev}
+}
+
+func (m *memEtcdKV) get(op clientv3.Op) (*clientv3.GetResponse, error) {
+	rev := m.rev
+	if op.Rev() > 0 {
+		if op.Rev() > m.rev {
+			return nil, errors.New("required revision is a future revision")
+		}
+		rev = op.Rev()
+	}
+	resp := &clientv3.GetResponse{Header: m.header()}
+	for _, k := range m.keys(op) {
+		if kv := m.lookup(k, rev); kv != nil {
+			resp.Count++
+			if !op.IsCountOnly() {
+				resp.Kvs = append(resp.Kvs, kv)
+			}
+		}
+	}
+	return resp, nil
+}
+
+// put and del must be called after incrementing the revision
+func (m *memEtcdKV) put(op clientv3.Op) *clientv3.PutResponse {
+	key := string(op.KeyBytes())
+	kv := &mvccpb.KeyValue{
+		Key:            []byte(key),
+		Value:          bytes.Clone(op.ValueBytes()),
+		CreateRevision: m.rev,
+		ModRevision:    m.rev,
+		Version:        1,
+	}
+	if prev := m.lookup(key, m.rev); prev != nil {
+		kv.CreateRevision = prev.CreateRevision
+		kv.Version = prev.Version + 1
+	}
+	m.history[key] = append(m.history[key], kv)
+	return &clientv3.PutResponse{Header: m.header()}
+}
+
+func (m *memEtcdKV) del(op clientv3.Op) *clientv3.DeleteResponse {
+	resp := &clientv3.DeleteResponse{Header: m.header()}
+	for _, k := range m.keys(op) {
+		if m.lookup(k, m.rev) != nil {
+			m.history[k] = append(m.history[k], &mvccpb.KeyValue{Key: []byte(k), ModRevision: m.rev})
+			resp.Deleted++
+		}
+	}
+	return resp
+}
+
+func (m *memEtcdKV) compare(cmp clientv3.Cmp) bool {
+	kv := m.lookup(string(cmp.Key), m.rev)
+	if kv == nil {
+		kv = &mvccpb.KeyValue{}
+	}
+	var result int
+	switch target := cmp.TargetUnion.(type) {
+	case *pb.Compare_ModRevision:
+		result = compareInt64(kv.ModRevision, target.ModRevision)
+	case *pb.Compare_CreateRevision:
+		result = compareInt64(kv.CreateRevision, target.CreateRevision)
+	case *pb.Compare_Version:
+		result = compareInt64(kv.Version, target.Version)
+	case *pb.Compare_Value:
+		result = bytes.Compare(kv.Value, target.Value)
+	}
+	switch cmp.Result {
+	case pb.Compare_EQUAL:
+		return result == 0
+	case pb.Compare_GREATER:
+		return result > 0
+	case pb.Compare_LESS:
+		return result < 0
+	default:
+		return result != 0
+	}
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (m *memEtcdKV) do(op clientv3.Op) (clientv3.OpResponse, error) {
+	switch {
+	case op.IsGet():
+		resp, err := m.get(op)
+		if err != nil {
+			return clientv3.OpResponse{}, err
+		}
+		return resp.OpResponse(), nil
+	case op.IsPut():
+		m.rev++
+		return m.put(op).OpResponse(), nil
+	case op.IsDelete():
+		m.rev++
+		return m.del(op).OpResponse(), nil
+	default:
+		return clientv3.OpResponse{}, errors.New("unsupported operation")
+	}
+}
+
+func (m *memEtcdKV) Put(_ context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	resp, err := m.Do(context.Background(), clientv3.OpPut(key, val, opts...))
+	return resp.Put(), err
+}
+
+func (m *memEtcdKV) Get(_ context.Context, key string, opts ...clientv3.OpOption) (*clientv3.GetResponse, error) {
+	resp, err := m.Do(context.Background(), clientv3.OpGet(key, opts...))
+	return resp.Get(), err
+}
+
+func (m *memEtcdKV) Delete(_ context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	resp, err := m.Do(context.Background(), clientv3.OpDelete(key, opts...))
+	return resp.Del(), err
+}
+
+func (m *memEtcdKV) Compact(_ context.Context, _ int64, _ ...clientv3.CompactOption) (*clientv3.CompactResponse, error) {
+	return nil, errors.New("compaction is not supported")
+}
+
+func (m *memEtcdKV) Do(_ context.Context, op clientv3.Op) (clientv3.OpResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.do(op)
+}
+
+func (m *memEtcdKV) Txn(_ context.Context) clientv3.Txn {
+	return &memEtcdTxn{kv: m}
+}
+
+type memEtcdTxn struct {
+	kv      *memEtcdKV
+	cmps    []clientv3.Cmp
+	thenOps []clientv3.Op
+	elseOps []clientv3.Op
+}
+
+func (t *memEtcdTxn) If(cs ...clientv3.Cmp) clientv3.Txn {
+	t.cmps = append(t.cmps, cs...)
+	return t
+}
+
+func (t *memEtcdTxn) Then(ops ...clientv3.Op) clientv3.Txn {
+	t.thenOps = append(t.thenOps, ops...)
+	return t
+}
+
+func (t *memEtcdTxn) Else(ops ...clientv3.Op) clientv3.Txn {
+	t.elseOps = append(t.elseOps, ops...)
+	return t
+}
+
+func (t *memEtcdTxn) Commit() (*clientv3.TxnResponse, error) {
+	m := t.kv
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	succeeded := true
+	for _, cmp := range t.cmps {
+		if !m.compare(cmp) {
+			succeeded = false
+			break
+		}
+	}
+	ops := t.thenOps
+	if !succeeded {
+		ops = t.elseOps
+	}
+	// all the writes inside a transaction share the same revision
+	for _, op := range ops {
+		if op.IsPut() || op.IsDelete() {
+			m.rev++
+			break
+		}
+	}
+	for _, op := range ops {
+		switch {
+		case op.IsGet():
+			if _, err := m.get(op); err != nil {
+				return nil, err
+			}
+		case op.IsPut():
+			m.put(op)
+		case op.IsDelete():
+			m.del(op)
+		default:
+			return nil, errors.New("unsupported transaction operation")
+		}
+	}
+	return &clientv3.TxnResponse{Header: m.header(), Succeeded: succeeded}, nil
+}
+
+func getTestEtcdProvider(t *testing.T) *EtcdProvider {
+	client := clientv3.NewCtxClient(context.Background())
+	client.KV = newMemEtcdKV()
+	t.Cleanup(func() {
+		client.Close() //nolint:errcheck
+	})
+	p := &EtcdProvider{
+		client: client,
+		root:   kvDefaultKeyPrefix,
+		cancel: func() {},
+	}
+	p.kvProvider = &kvProvider{store: p}
+	return p
+}
+
+func TestEtcdProvider(t *testing.T) {
+	p := getTestEtcdProvider(t)
+	setKVTestProvider(t, p)
+
+	testKVProvider(t, p.kvProvider)
+	testKVConflict(t, p)
+}
+
+func TestEtcdTasksAndSessions(t *testing.T) {
+	p := getTestEtcdProvider(t)
+	setKVTestProvider(t, p)
+
+	err := p.addTask("task")
+	require.NoError(t, err)
+	err = p.addTask("task")
+	assert.Error(t, err)
+	task, err := p.getTaskByName("task")
+	require.NoError(t, err)
+	err = p.updateTask(task.Name, task.Version)
+	assert.NoError(t, err)
+	// the version is now changed, a stale update must fail
+	err = p.updateTask(task.Name, task.Version)
+	assert.Error(t, err)
+
+	session := Session{
+		Key:       "session_key",
+		Data:      "data",
+		Type:      SessionTypeOIDCAuth,
+		Timestamp: time.Now().Add(-time.Hour).UnixMilli(),
+	}
+	err = p.addSharedSession(session)
+	require.NoError(t, err)
+	s, err := p.getSharedSession(session.Key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`"data"`), s.Data)
+	sessions, err := p.getSharedSessions(SessionTypeOIDCAuth)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	err = p.cleanupSharedSessions(SessionTypeOIDCAuth, time.Now().UnixMilli())
+	require.NoError(t, err)
+	_, err = p.getSharedSession(session.Key)
+	assert.Error(t, err)
+	err = p.deleteSharedSession(session.Key)
+	assert.Error(t, err)
+}
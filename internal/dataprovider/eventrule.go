@@ -23,11 +23,15 @@ import (
 	"net/http"
 	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/robfig/cron/v3"
 
+	"github.com/drakkan/sftpgo/v2/internal/kafkaclient"
 	"github.com/drakkan/sftpgo/v2/internal/kms"
 	"github.com/drakkan/sftpgo/v2/internal/logger"
 	"github.com/drakkan/sftpgo/v2/internal/util"
@@ -48,13 +52,23 @@ const (
 	ActionTypePasswordExpirationCheck
 	ActionTypeUserExpirationCheck
 	ActionTypeIDPAccountCheck
+	ActionTypeSnapshot
+	ActionTypeTiering
+	ActionTypeUserArchive
+	ActionTypeChatNotification
+	ActionTypeMessagePublish
+	ActionTypeCloudQueue
+	ActionTypeUserDisable
+	ActionTypeUsageReport
 )
 
 var (
 	supportedEventActions = []int{ActionTypeHTTP, ActionTypeCommand, ActionTypeEmail, ActionTypeFilesystem,
 		ActionTypeBackup, ActionTypeUserQuotaReset, ActionTypeFolderQuotaReset, ActionTypeTransferQuotaReset,
 		ActionTypeDataRetentionCheck, ActionTypeMetadataCheck, ActionTypePasswordExpirationCheck,
-		ActionTypeUserExpirationCheck, ActionTypeIDPAccountCheck}
+		ActionTypeUserExpirationCheck, ActionTypeIDPAccountCheck, ActionTypeSnapshot, ActionTypeTiering,
+		ActionTypeUserArchive, ActionTypeChatNotification, ActionTypeMessagePublish, ActionTypeCloudQueue,
+		ActionTypeUserDisable, ActionTypeUsageReport}
 )
 
 func isActionTypeValid(action int) bool {
@@ -87,6 +101,22 @@ func getActionTypeAsString(action int) string {
 		return util.I18nActionTypeUserExpirationCheck
 	case ActionTypeIDPAccountCheck:
 		return util.I18nActionTypeIDPCheck
+	case ActionTypeSnapshot:
+		return util.I18nActionTypeSnapshot
+	case ActionTypeTiering:
+		return util.I18nActionTypeTiering
+	case ActionTypeUserArchive:
+		return util.I18nActionTypeUserArchive
+	case ActionTypeChatNotification:
+		return util.I18nActionTypeChat
+	case ActionTypeMessagePublish:
+		return util.I18nActionTypeMessagePublish
+	case ActionTypeCloudQueue:
+		return util.I18nActionTypeCloudQueue
+	case ActionTypeUserDisable:
+		return util.I18nActionTypeUserDisable
+	case ActionTypeUsageReport:
+		return util.I18nActionTypeUsageReport
 	default:
 		return util.I18nActionTypeCommand
 	}
@@ -103,11 +133,18 @@ const (
 	EventTriggerCertificate
 	EventTriggerOnDemand
 	EventTriggerIDPLogin
+	// Usage thresholds crossed for users or folders with quota restrictions
+	EventTriggerQuotaThreshold
+	// Scheduled check for users with no login for the configured number of days
+	EventTriggerUserInactivity
 )
 
 var (
 	supportedEventTriggers = []int{EventTriggerFsEvent, EventTriggerProviderEvent, EventTriggerSchedule,
-		EventTriggerIPBlocked, EventTriggerCertificate, EventTriggerIDPLogin, EventTriggerOnDemand}
+		EventTriggerIPBlocked, EventTriggerCertificate, EventTriggerIDPLogin, EventTriggerOnDemand,
+		EventTriggerQuotaThreshold, EventTriggerUserInactivity}
+	// SupportedQuotaThresholdObjects defines the supported object types for quota threshold events
+	SupportedQuotaThresholdObjects = []string{actionObjectUser, actionObjectFolder}
 )
 
 func isEventTriggerValid(trigger int) bool {
@@ -128,6 +165,10 @@ func getTriggerTypeAsString(trigger int) string {
 		return util.I18nTriggerOnDemandEvent
 	case EventTriggerIDPLogin:
 		return util.I18nTriggerIDPLoginEvent
+	case EventTriggerQuotaThreshold:
+		return util.I18nTriggerQuotaThresholdEvent
+	case EventTriggerUserInactivity:
+		return util.I18nTriggerUserInactivityEvent
 	default:
 		return util.I18nTriggerScheduleEvent
 	}
@@ -189,9 +230,9 @@ func getFsActionTypeAsString(value int) string {
 var (
 	// SupportedFsEvents defines the supported filesystem events
 	SupportedFsEvents = []string{"upload", "pre-upload", "first-upload", "download", "pre-download",
-		"first-download", "delete", "pre-delete", "rename", "mkdir", "rmdir", "copy", "ssh_cmd"}
+		"first-download", "delete", "pre-delete", "rename", "mkdir", "rmdir", "copy", "ssh_cmd", "archive-restore"}
 	// SupportedProviderEvents defines the supported provider events
-	SupportedProviderEvents = []string{operationAdd, operationUpdate, operationDelete}
+	SupportedProviderEvents = []string{operationAdd, operationUpdate, operationDelete, operationDrift}
 	// SupportedRuleConditionProtocols defines the supported protcols for rule conditions
 	SupportedRuleConditionProtocols = []string{"SFTP", "SCP", "SSH", "FTP", "DAV", "HTTP", "HTTPShare",
 		"OIDC"}
@@ -487,12 +528,13 @@ func (c EventActionCommandConfig) GetArgumentsAsString() string {
 
 // EventActionEmailConfig defines the configuration options for SMTP event actions
 type EventActionEmailConfig struct {
-	Recipients  []string `json:"recipients,omitempty"`
-	Bcc         []string `json:"bcc,omitempty"`
-	Subject     string   `json:"subject,omitempty"`
-	Body        string   `json:"body,omitempty"`
-	Attachments []string `json:"attachments,omitempty"`
-	ContentType int      `json:"content_type,omitempty"`
+	Recipients   []string `json:"recipients,omitempty"`
+	Bcc          []string `json:"bcc,omitempty"`
+	Subject      string   `json:"subject,omitempty"`
+	Body         string   `json:"body,omitempty"`
+	Attachments  []string `json:"attachments,omitempty"`
+	ContentType  int      `json:"content_type,omitempty"`
+	InlineImages []string `json:"inline_images,omitempty"`
 }
 
 // GetRecipientsAsString returns the list of recipients as comma separated string
@@ -510,6 +552,11 @@ func (c EventActionEmailConfig) GetAttachmentsAsString() string {
 	return strings.Join(c.Attachments, ",")
 }
 
+// GetInlineImagesAsString returns the list of inline images as comma separated string
+func (c EventActionEmailConfig) GetInlineImagesAsString() string {
+	return strings.Join(c.InlineImages, ",")
+}
+
 func (c *EventActionEmailConfig) hasFilesAttachments() bool {
 	for _, a := range c.Attachments {
 		if a != RetentionReportPlaceHolder {
@@ -565,6 +612,17 @@ func (c *EventActionEmailConfig) validate() error {
 		}
 	}
 	c.Attachments = util.RemoveDuplicates(c.Attachments, false)
+	if len(c.InlineImages) > 0 && c.ContentType != 1 {
+		return util.NewValidationError("inline images require HTML content type")
+	}
+	for idx, val := range c.InlineImages {
+		val = strings.TrimSpace(val)
+		if val == "" || val == "." || val == ".." || strings.ContainsAny(val, `/\`) {
+			return util.NewValidationError(fmt.Sprintf("invalid inline image %q", val))
+		}
+		c.InlineImages[idx] = val
+	}
+	c.InlineImages = util.RemoveDuplicates(c.InlineImages, false)
 	return nil
 }
 
@@ -629,6 +687,468 @@ func (c *EventActionDataRetentionConfig) validate() error {
 	return nil
 }
 
+// Supported tiering age modes
+const (
+	// TieringAgeModified selects files based on their modification time
+	TieringAgeModified = iota
+	// TieringAgeAccessed selects files based on their access time
+	TieringAgeAccessed
+)
+
+// FolderTiering defines a tiering policy between two virtual folders
+type FolderTiering struct {
+	// SourceFolder is the name of the virtual folder to move the files from
+	SourceFolder string `json:"source_folder"`
+	// TargetFolder is the name of the virtual folder to move the files to
+	TargetFolder string `json:"target_folder"`
+	// MinAge defines the age, in days, after which a file is moved
+	MinAge int `json:"min_age"`
+	// AgeMode defines how the age of a file is computed, see the above enum.
+	// The access time could be not available, or not updated, for some
+	// filesystems, in this case the modification time is used
+	AgeMode int `json:"age_mode,omitempty"`
+	// LeaveStub defines if a stub file, with the ".tiered" suffix, is left
+	// in the source folder for each moved file. The stub contains the
+	// target folder name and the file path
+	LeaveStub bool `json:"leave_stub,omitempty"`
+}
+
+func (f *FolderTiering) validate() error {
+	f.SourceFolder = strings.TrimSpace(f.SourceFolder)
+	f.TargetFolder = strings.TrimSpace(f.TargetFolder)
+	if f.SourceFolder == "" || f.TargetFolder == "" {
+		return util.NewI18nError(
+			util.NewValidationError("source and target folders are mandatory"),
+			util.I18nErrorTieringFoldersRequired,
+		)
+	}
+	if f.SourceFolder == f.TargetFolder {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("source and target folders must be different, got %q", f.SourceFolder)),
+			util.I18nErrorSourceDestMatch,
+		)
+	}
+	if f.MinAge <= 0 {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("invalid tiering min age %d, it must be greater than zero", f.MinAge)),
+			util.I18nErrorTieringInvalidAge,
+		)
+	}
+	if f.AgeMode != TieringAgeModified && f.AgeMode != TieringAgeAccessed {
+		return util.NewValidationError(fmt.Sprintf("invalid tiering age mode: %d", f.AgeMode))
+	}
+	return nil
+}
+
+// EventActionTieringConfig defines the configuration for a tiering action
+type EventActionTieringConfig struct {
+	Policies []FolderTiering `json:"policies,omitempty"`
+}
+
+func (c *EventActionTieringConfig) validate() error {
+	if len(c.Policies) == 0 {
+		return util.NewI18nError(
+			util.NewValidationError("at least a tiering policy is required"),
+			util.I18nErrorTieringPolicyRequired,
+		)
+	}
+	sourceFolders := make(map[string]bool)
+	for idx := range c.Policies {
+		p := &c.Policies[idx]
+		if err := p.validate(); err != nil {
+			return err
+		}
+		if _, ok := sourceFolders[p.SourceFolder]; ok {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("duplicated source folder %q", p.SourceFolder)),
+				util.I18nErrorTieringFolderDuplicated,
+			)
+		}
+		sourceFolders[p.SourceFolder] = true
+	}
+	return nil
+}
+
+// EventActionUserArchiveConfig defines the configuration for the expired users archive action
+type EventActionUserArchiveConfig struct {
+	// Name of the virtual folder where the archives are stored, for example
+	// a folder backed by an S3 bucket
+	Folder string `json:"folder,omitempty"`
+	// Directory, inside the folder, where the archives are stored
+	Path string `json:"path,omitempty"`
+}
+
+func (c *EventActionUserArchiveConfig) validate() error {
+	c.Folder = strings.TrimSpace(c.Folder)
+	if c.Folder == "" {
+		return util.NewI18nError(
+			util.NewValidationError("the archive folder is mandatory"),
+			util.I18nErrorArchiveFolderRequired,
+		)
+	}
+	c.Path = util.CleanPath(c.Path)
+	return nil
+}
+
+// Supported chat platforms
+const (
+	ChatPlatformSlack = iota + 1
+	ChatPlatformTeams
+	ChatPlatformDiscord
+	ChatPlatformMatrix
+)
+
+var (
+	supportedChatPlatforms = []int{ChatPlatformSlack, ChatPlatformTeams, ChatPlatformDiscord, ChatPlatformMatrix}
+)
+
+// EventActionChatConfig defines the configuration for the chat notification action
+type EventActionChatConfig struct {
+	// Chat platform, see the above enum
+	Platform int `json:"platform,omitempty"`
+	// Incoming webhook URL for Slack, Microsoft Teams and Discord.
+	// Homeserver URL for Matrix
+	Endpoint string `json:"endpoint,omitempty"`
+	// Optional channel override for Slack, room ID for Matrix
+	Channel string `json:"channel,omitempty"`
+	// Access token, required for Matrix
+	Token *kms.Secret `json:"token,omitempty"`
+	// Message to send, placeholders are supported
+	Message string `json:"message,omitempty"`
+}
+
+func (c *EventActionChatConfig) validate(name string) error {
+	if !util.Contains(supportedChatPlatforms, c.Platform) {
+		return util.NewValidationError(fmt.Sprintf("unsupported chat platform: %d", c.Platform))
+	}
+	c.Endpoint = strings.TrimSpace(c.Endpoint)
+	if c.Endpoint == "" {
+		return util.NewI18nError(util.NewValidationError("chat endpoint is required"), util.I18nErrorURLRequired)
+	}
+	if !util.IsStringPrefixInSlice(c.Endpoint, []string{"http://", "https://"}) {
+		return util.NewI18nError(
+			util.NewValidationError("invalid chat endpoint schema: http and https are supported"),
+			util.I18nErrorURLInvalid,
+		)
+	}
+	c.Channel = strings.TrimSpace(c.Channel)
+	if c.Message == "" {
+		return util.NewI18nError(util.NewValidationError("chat message is required"), util.I18nErrorChatMessageRequired)
+	}
+	if c.Platform != ChatPlatformMatrix {
+		if c.Platform != ChatPlatformSlack {
+			c.Channel = ""
+		}
+		c.Token = kms.NewEmptySecret()
+		return nil
+	}
+	if c.Channel == "" {
+		return util.NewI18nError(util.NewValidationError("Matrix room ID is required"), util.I18nErrorChatRoomRequired)
+	}
+	if c.Token.IsEmpty() {
+		return util.NewI18nError(util.NewValidationError("Matrix access token is required"), util.I18nErrorChatTokenRequired)
+	}
+	if c.Token.IsRedacted() {
+		return util.NewValidationError("cannot save chat configuration with a redacted secret")
+	}
+	if c.Token.IsPlain() {
+		c.Token.SetAdditionalData(name)
+		if err := c.Token.Encrypt(); err != nil {
+			return util.NewValidationError(fmt.Sprintf("could not encrypt chat access token: %v", err))
+		}
+	}
+	return nil
+}
+
+// TryDecryptToken decrypts the access token if encrypted
+func (c *EventActionChatConfig) TryDecryptToken() error {
+	if c.Token != nil && !c.Token.IsEmpty() {
+		if err := c.Token.TryDecrypt(); err != nil {
+			return fmt.Errorf("unable to decrypt chat access token: %w", err)
+		}
+	}
+	return nil
+}
+
+// Supported messaging protocols
+const (
+	MessagingProtocolNATS = iota + 1
+	MessagingProtocolMQTT
+	MessagingProtocolKafka
+)
+
+// Supported Kafka acknowledgement modes
+const (
+	KafkaAcksLeader = kafkaclient.AcksLeader
+	KafkaAcksAll    = kafkaclient.AcksAll
+	KafkaAcksNone   = kafkaclient.AcksNone
+)
+
+// Supported Kafka SASL mechanisms
+const (
+	KafkaSASLPlain       = kafkaclient.SASLPlain
+	KafkaSASLScramSHA256 = kafkaclient.SASLScramSHA256
+	KafkaSASLScramSHA512 = kafkaclient.SASLScramSHA512
+)
+
+var (
+	supportedMessagingSchemes = map[int][]string{
+		MessagingProtocolNATS:  {"nats://", "tls://"},
+		MessagingProtocolMQTT:  {"mqtt://", "mqtts://"},
+		MessagingProtocolKafka: {"kafka://", "kafkas://"},
+	}
+)
+
+// EventActionMessageConfig defines the configuration for the message publish action
+type EventActionMessageConfig struct {
+	// Messaging protocol, see the above enum
+	Protocol int `json:"protocol,omitempty"`
+	// Broker URL, for example nats://host:4222, tls://host:4222,
+	// mqtt://host:1883, mqtts://host:8883 or kafka://host1:9092,host2:9092
+	Endpoint string `json:"endpoint,omitempty"`
+	// NATS subject, MQTT topic or Kafka topic, placeholders are supported
+	Topic string `json:"topic,omitempty"`
+	// Optional credentials
+	Username string      `json:"username,omitempty"`
+	Password *kms.Secret `json:"password,omitempty"`
+	// Message to publish, placeholders are supported
+	Message string `json:"message,omitempty"`
+	// Kafka record key, placeholders are supported. Records with the
+	// same key are sent to the same partition, if empty a random
+	// partition is used
+	Key string `json:"key,omitempty"`
+	// Kafka acknowledgements required to consider a record delivered
+	Acks int `json:"acks,omitempty"`
+	// Kafka SASL mechanism, used if a username is set
+	SASLMechanism int `json:"sasl_mechanism,omitempty"`
+}
+
+func (c *EventActionMessageConfig) validate(name string) error {
+	schemes, ok := supportedMessagingSchemes[c.Protocol]
+	if !ok {
+		return util.NewValidationError(fmt.Sprintf("unsupported messaging protocol: %d", c.Protocol))
+	}
+	c.Endpoint = strings.TrimSpace(c.Endpoint)
+	if c.Endpoint == "" {
+		return util.NewI18nError(util.NewValidationError("broker URL is required"), util.I18nErrorURLRequired)
+	}
+	if !util.IsStringPrefixInSlice(c.Endpoint, schemes) {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("invalid broker URL schema, supported: %s", strings.Join(schemes, ", "))),
+			util.I18nErrorURLInvalid,
+		)
+	}
+	c.Topic = strings.TrimSpace(c.Topic)
+	if c.Topic == "" {
+		return util.NewI18nError(util.NewValidationError("topic is required"), util.I18nErrorMessageTopicRequired)
+	}
+	if c.Message == "" {
+		return util.NewI18nError(util.NewValidationError("message is required"), util.I18nErrorChatMessageRequired)
+	}
+	c.Username = strings.TrimSpace(c.Username)
+	if c.Protocol == MessagingProtocolKafka {
+		if c.Acks < KafkaAcksLeader || c.Acks > KafkaAcksNone {
+			return util.NewValidationError(fmt.Sprintf("invalid Kafka acks: %d", c.Acks))
+		}
+		if c.SASLMechanism < KafkaSASLPlain || c.SASLMechanism > KafkaSASLScramSHA512 {
+			return util.NewValidationError(fmt.Sprintf("invalid Kafka SASL mechanism: %d", c.SASLMechanism))
+		}
+	} else {
+		c.Key = ""
+		c.Acks = 0
+		c.SASLMechanism = 0
+	}
+	if c.Password.IsRedacted() {
+		return util.NewValidationError("cannot save messaging configuration with a redacted secret")
+	}
+	if c.Password.IsPlain() {
+		c.Password.SetAdditionalData(name)
+		if err := c.Password.Encrypt(); err != nil {
+			return util.NewValidationError(fmt.Sprintf("could not encrypt messaging password: %v", err))
+		}
+	}
+	return nil
+}
+
+// TryDecryptPassword decrypts the password if encrypted
+func (c *EventActionMessageConfig) TryDecryptPassword() error {
+	if c.Password != nil && !c.Password.IsEmpty() {
+		if err := c.Password.TryDecrypt(); err != nil {
+			return fmt.Errorf("unable to decrypt messaging password: %w", err)
+		}
+	}
+	return nil
+}
+
+// Supported cloud queue services
+const (
+	CloudQueueSQS = iota + 1
+	CloudQueueSNS
+	CloudQueuePubSub
+)
+
+var (
+	pubSubTopicRegex = regexp.MustCompile(`^projects/[^/]+/topics/[^/]+$`)
+)
+
+// EventActionCloudQueueConfig defines the configuration for the cloud queue action.
+// Credentials are not stored, the default credentials chain is used, for example
+// IAM roles on AWS and the application default credentials on Google Cloud
+type EventActionCloudQueueConfig struct {
+	// Cloud service, see the above enum
+	Service int `json:"service,omitempty"`
+	// SQS queue URL, SNS topic ARN or Pub/Sub topic as "projects/<project>/topics/<topic>"
+	Target string `json:"target,omitempty"`
+	// AWS region, required for SQS and SNS
+	Region string `json:"region,omitempty"`
+	// Optional custom endpoint, for example a VPC endpoint
+	Endpoint string `json:"endpoint,omitempty"`
+	// Message to publish, placeholders are supported
+	Message string `json:"message,omitempty"`
+}
+
+func (c *EventActionCloudQueueConfig) validate() error {
+	c.Target = strings.TrimSpace(c.Target)
+	c.Region = strings.TrimSpace(c.Region)
+	c.Endpoint = strings.TrimSpace(c.Endpoint)
+	if c.Target == "" {
+		return util.NewI18nError(util.NewValidationError("cloud queue target is required"), util.I18nErrorCloudQueueTarget)
+	}
+	switch c.Service {
+	case CloudQueueSQS:
+		if !util.IsStringPrefixInSlice(c.Target, []string{"http://", "https://"}) {
+			return util.NewI18nError(util.NewValidationError("invalid SQS queue URL"), util.I18nErrorCloudQueueTarget)
+		}
+	case CloudQueueSNS:
+		if !strings.HasPrefix(c.Target, "arn:") {
+			return util.NewI18nError(util.NewValidationError("invalid SNS topic ARN"), util.I18nErrorCloudQueueTarget)
+		}
+	case CloudQueuePubSub:
+		if !pubSubTopicRegex.MatchString(c.Target) {
+			return util.NewI18nError(util.NewValidationError("invalid Pub/Sub topic"), util.I18nErrorCloudQueueTarget)
+		}
+		c.Region = ""
+	default:
+		return util.NewValidationError(fmt.Sprintf("unsupported cloud queue service: %d", c.Service))
+	}
+	if c.Service != CloudQueuePubSub && c.Region == "" {
+		return util.NewI18nError(util.NewValidationError("AWS region is required"), util.I18nErrorCloudQueueRegion)
+	}
+	if c.Endpoint != "" && !util.IsStringPrefixInSlice(c.Endpoint, []string{"http://", "https://"}) {
+		return util.NewI18nError(
+			util.NewValidationError("invalid cloud queue endpoint schema: http and https are supported"),
+			util.I18nErrorURLInvalid,
+		)
+	}
+	if c.Message == "" {
+		return util.NewI18nError(util.NewValidationError("message is required"), util.I18nErrorChatMessageRequired)
+	}
+	return nil
+}
+
+// Supported usage report groupings
+const (
+	UsageReportGroupByUser = iota + 1
+	UsageReportGroupByRole
+)
+
+var (
+	supportedUsageReportGroupings = []int{UsageReportGroupByUser, UsageReportGroupByRole}
+)
+
+// EventActionUsageReportConfig defines the configuration for the usage report action.
+// The report is generated as CSV and it can be sent via email, uploaded inside a
+// virtual folder or both
+type EventActionUsageReportConfig struct {
+	// 1 per user, 2 per role
+	GroupBy int `json:"group_by,omitempty"`
+	// Email recipients, the report is sent as attachment
+	Recipients []string `json:"recipients,omitempty"`
+	// Name of the virtual folder where the reports are uploaded
+	Folder string `json:"folder,omitempty"`
+	// Directory, inside the folder, where the reports are uploaded
+	Path string `json:"path,omitempty"`
+}
+
+// GetRecipientsAsString returns the list of recipients as comma separated string
+func (c EventActionUsageReportConfig) GetRecipientsAsString() string {
+	return strings.Join(c.Recipients, ",")
+}
+
+func (c *EventActionUsageReportConfig) validate() error {
+	if !util.Contains(supportedUsageReportGroupings, c.GroupBy) {
+		return util.NewValidationError(fmt.Sprintf("invalid usage report grouping: %d", c.GroupBy))
+	}
+	c.Recipients = util.RemoveDuplicates(c.Recipients, false)
+	for _, r := range c.Recipients {
+		if r == "" {
+			return util.NewValidationError("invalid email recipients")
+		}
+	}
+	c.Folder = strings.TrimSpace(c.Folder)
+	if c.Folder == "" {
+		c.Path = ""
+	} else {
+		c.Path = util.CleanPath(c.Path)
+	}
+	if len(c.Recipients) == 0 && c.Folder == "" {
+		return util.NewI18nError(
+			util.NewValidationError("at least an email recipient or a folder is required"),
+			util.I18nErrorUsageReportDestination,
+		)
+	}
+	return nil
+}
+
+// EventActionBackupConfig defines the configuration for the backup action.
+// The backup is always saved to the configured backups path, if a folder is set
+// it is also uploaded inside the specified virtual folder
+type EventActionBackupConfig struct {
+	// Name of the virtual folder where the backups are uploaded, for example
+	// a folder backed by an S3 bucket. Empty means local backups only
+	Folder string `json:"folder,omitempty"`
+	// Directory, inside the folder, where the backups are uploaded
+	Path string `json:"path,omitempty"`
+	// Passphrase used to encrypt the uploaded backups. Empty means no encryption
+	Passphrase *kms.Secret `json:"passphrase,omitempty"`
+	// Number of uploaded backups to keep, older ones are removed. 0 means no limit
+	Retention int `json:"retention,omitempty"`
+}
+
+func (c *EventActionBackupConfig) validate(name string) error {
+	c.Folder = strings.TrimSpace(c.Folder)
+	if c.Folder == "" {
+		c.Path = ""
+		c.Passphrase = kms.NewEmptySecret()
+		c.Retention = 0
+		return nil
+	}
+	c.Path = util.CleanPath(c.Path)
+	if c.Retention < 0 {
+		return util.NewValidationError(fmt.Sprintf("invalid backup retention: %d", c.Retention))
+	}
+	if c.Passphrase.IsRedacted() {
+		return util.NewValidationError("cannot save backup configuration with a redacted secret")
+	}
+	if c.Passphrase.IsPlain() {
+		c.Passphrase.SetAdditionalData(name)
+		if err := c.Passphrase.Encrypt(); err != nil {
+			return util.NewValidationError(fmt.Sprintf("could not encrypt backup passphrase: %v", err))
+		}
+	}
+	return nil
+}
+
+// TryDecryptPassphrase decrypts the passphrase if encrypted
+func (c *EventActionBackupConfig) TryDecryptPassphrase() error {
+	if c.Passphrase != nil && !c.Passphrase.IsEmpty() {
+		if err := c.Passphrase.TryDecrypt(); err != nil {
+			return fmt.Errorf("unable to decrypt backup passphrase: %w", err)
+		}
+	}
+	return nil
+}
+
 // EventActionFsCompress defines the configuration for the compress filesystem action
 type EventActionFsCompress struct {
 	// Archive path
@@ -947,6 +1467,13 @@ type BaseEventActionOptions struct {
 	FsConfig            EventActionFilesystemConfig    `json:"fs_config"`
 	PwdExpirationConfig EventActionPasswordExpiration  `json:"pwd_expiration_config"`
 	IDPConfig           EventActionIDPAccountCheck     `json:"idp_config"`
+	TieringConfig       EventActionTieringConfig       `json:"tiering_config"`
+	ArchiveConfig       EventActionUserArchiveConfig   `json:"archive_config"`
+	BackupConfig        EventActionBackupConfig        `json:"backup_config"`
+	ChatConfig          EventActionChatConfig          `json:"chat_config"`
+	MessageConfig       EventActionMessageConfig       `json:"message_config"`
+	CloudQueueConfig    EventActionCloudQueueConfig    `json:"cloud_queue_config"`
+	UsageReportConfig   EventActionUsageReportConfig   `json:"usage_report_config"`
 }
 
 func (o *BaseEventActionOptions) getACopy() BaseEventActionOptions {
@@ -957,6 +1484,8 @@ func (o *BaseEventActionOptions) getACopy() BaseEventActionOptions {
 	copy(emailBcc, o.EmailConfig.Bcc)
 	emailAttachments := make([]string, len(o.EmailConfig.Attachments))
 	copy(emailAttachments, o.EmailConfig.Attachments)
+	emailInlineImages := make([]string, len(o.EmailConfig.InlineImages))
+	copy(emailInlineImages, o.EmailConfig.InlineImages)
 	cmdArgs := make([]string, len(o.CmdConfig.Args))
 	copy(cmdArgs, o.CmdConfig.Args)
 	folders := make([]FolderRetention, 0, len(o.RetentionConfig.Folders))
@@ -968,6 +1497,10 @@ func (o *BaseEventActionOptions) getACopy() BaseEventActionOptions {
 			IgnoreUserPermissions: folder.IgnoreUserPermissions,
 		})
 	}
+	reportRecipients := make([]string, len(o.UsageReportConfig.Recipients))
+	copy(reportRecipients, o.UsageReportConfig.Recipients)
+	policies := make([]FolderTiering, len(o.TieringConfig.Policies))
+	copy(policies, o.TieringConfig.Policies)
 	httpParts := make([]HTTPPart, 0, len(o.HTTPConfig.Parts))
 	for _, part := range o.HTTPConfig.Parts {
 		httpParts = append(httpParts, HTTPPart{
@@ -998,12 +1531,13 @@ func (o *BaseEventActionOptions) getACopy() BaseEventActionOptions {
 			EnvVars: cloneKeyValues(o.CmdConfig.EnvVars),
 		},
 		EmailConfig: EventActionEmailConfig{
-			Recipients:  emailRecipients,
-			Bcc:         emailBcc,
-			Subject:     o.EmailConfig.Subject,
-			ContentType: o.EmailConfig.ContentType,
-			Body:        o.EmailConfig.Body,
-			Attachments: emailAttachments,
+			Recipients:   emailRecipients,
+			Bcc:          emailBcc,
+			Subject:      o.EmailConfig.Subject,
+			ContentType:  o.EmailConfig.ContentType,
+			Body:         o.EmailConfig.Body,
+			Attachments:  emailAttachments,
+			InlineImages: emailInlineImages,
 		},
 		RetentionConfig: EventActionDataRetentionConfig{
 			Folders: folders,
@@ -1016,6 +1550,50 @@ func (o *BaseEventActionOptions) getACopy() BaseEventActionOptions {
 			TemplateUser:  o.IDPConfig.TemplateUser,
 			TemplateAdmin: o.IDPConfig.TemplateAdmin,
 		},
+		TieringConfig: EventActionTieringConfig{
+			Policies: policies,
+		},
+		ArchiveConfig: EventActionUserArchiveConfig{
+			Folder: o.ArchiveConfig.Folder,
+			Path:   o.ArchiveConfig.Path,
+		},
+		BackupConfig: EventActionBackupConfig{
+			Folder:     o.BackupConfig.Folder,
+			Path:       o.BackupConfig.Path,
+			Passphrase: o.BackupConfig.Passphrase.Clone(),
+			Retention:  o.BackupConfig.Retention,
+		},
+		ChatConfig: EventActionChatConfig{
+			Platform: o.ChatConfig.Platform,
+			Endpoint: o.ChatConfig.Endpoint,
+			Channel:  o.ChatConfig.Channel,
+			Token:    o.ChatConfig.Token.Clone(),
+			Message:  o.ChatConfig.Message,
+		},
+		MessageConfig: EventActionMessageConfig{
+			Protocol:      o.MessageConfig.Protocol,
+			Endpoint:      o.MessageConfig.Endpoint,
+			Topic:         o.MessageConfig.Topic,
+			Username:      o.MessageConfig.Username,
+			Password:      o.MessageConfig.Password.Clone(),
+			Message:       o.MessageConfig.Message,
+			Key:           o.MessageConfig.Key,
+			Acks:          o.MessageConfig.Acks,
+			SASLMechanism: o.MessageConfig.SASLMechanism,
+		},
+		CloudQueueConfig: EventActionCloudQueueConfig{
+			Service:  o.CloudQueueConfig.Service,
+			Target:   o.CloudQueueConfig.Target,
+			Region:   o.CloudQueueConfig.Region,
+			Endpoint: o.CloudQueueConfig.Endpoint,
+			Message:  o.CloudQueueConfig.Message,
+		},
+		UsageReportConfig: EventActionUsageReportConfig{
+			GroupBy:    o.UsageReportConfig.GroupBy,
+			Recipients: reportRecipients,
+			Folder:     o.UsageReportConfig.Folder,
+			Path:       o.UsageReportConfig.Path,
+		},
 		FsConfig: o.FsConfig.getACopy(),
 	}
 }
@@ -1025,18 +1603,45 @@ func (o *BaseEventActionOptions) SetEmptySecretsIfNil() {
 	if o.HTTPConfig.Password == nil {
 		o.HTTPConfig.Password = kms.NewEmptySecret()
 	}
+	if o.BackupConfig.Passphrase == nil {
+		o.BackupConfig.Passphrase = kms.NewEmptySecret()
+	}
+	if o.ChatConfig.Token == nil {
+		o.ChatConfig.Token = kms.NewEmptySecret()
+	}
+	if o.MessageConfig.Password == nil {
+		o.MessageConfig.Password = kms.NewEmptySecret()
+	}
 }
 
 func (o *BaseEventActionOptions) setNilSecretsIfEmpty() {
 	if o.HTTPConfig.Password != nil && o.HTTPConfig.Password.IsEmpty() {
 		o.HTTPConfig.Password = nil
 	}
+	if o.BackupConfig.Passphrase != nil && o.BackupConfig.Passphrase.IsEmpty() {
+		o.BackupConfig.Passphrase = nil
+	}
+	if o.ChatConfig.Token != nil && o.ChatConfig.Token.IsEmpty() {
+		o.ChatConfig.Token = nil
+	}
+	if o.MessageConfig.Password != nil && o.MessageConfig.Password.IsEmpty() {
+		o.MessageConfig.Password = nil
+	}
 }
 
 func (o *BaseEventActionOptions) hideConfidentialData() {
 	if o.HTTPConfig.Password != nil {
 		o.HTTPConfig.Password.Hide()
 	}
+	if o.BackupConfig.Passphrase != nil {
+		o.BackupConfig.Passphrase.Hide()
+	}
+	if o.ChatConfig.Token != nil {
+		o.ChatConfig.Token.Hide()
+	}
+	if o.MessageConfig.Password != nil {
+		o.MessageConfig.Password.Hide()
+	}
 }
 
 func (o *BaseEventActionOptions) validate(action int, name string) error {
@@ -1049,6 +1654,13 @@ func (o *BaseEventActionOptions) validate(action int, name string) error {
 		o.FsConfig = EventActionFilesystemConfig{}
 		o.PwdExpirationConfig = EventActionPasswordExpiration{}
 		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
 		return o.HTTPConfig.validate(name)
 	case ActionTypeCommand:
 		o.HTTPConfig = EventActionHTTPConfig{}
@@ -1057,6 +1669,13 @@ func (o *BaseEventActionOptions) validate(action int, name string) error {
 		o.FsConfig = EventActionFilesystemConfig{}
 		o.PwdExpirationConfig = EventActionPasswordExpiration{}
 		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
 		return o.CmdConfig.validate()
 	case ActionTypeEmail:
 		o.HTTPConfig = EventActionHTTPConfig{}
@@ -1065,6 +1684,13 @@ func (o *BaseEventActionOptions) validate(action int, name string) error {
 		o.FsConfig = EventActionFilesystemConfig{}
 		o.PwdExpirationConfig = EventActionPasswordExpiration{}
 		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
 		return o.EmailConfig.validate()
 	case ActionTypeDataRetentionCheck:
 		o.HTTPConfig = EventActionHTTPConfig{}
@@ -1073,6 +1699,13 @@ func (o *BaseEventActionOptions) validate(action int, name string) error {
 		o.FsConfig = EventActionFilesystemConfig{}
 		o.PwdExpirationConfig = EventActionPasswordExpiration{}
 		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
 		return o.RetentionConfig.validate()
 	case ActionTypeFilesystem:
 		o.HTTPConfig = EventActionHTTPConfig{}
@@ -1081,6 +1714,13 @@ func (o *BaseEventActionOptions) validate(action int, name string) error {
 		o.RetentionConfig = EventActionDataRetentionConfig{}
 		o.PwdExpirationConfig = EventActionPasswordExpiration{}
 		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
 		return o.FsConfig.validate()
 	case ActionTypePasswordExpirationCheck:
 		o.HTTPConfig = EventActionHTTPConfig{}
@@ -1089,6 +1729,13 @@ func (o *BaseEventActionOptions) validate(action int, name string) error {
 		o.RetentionConfig = EventActionDataRetentionConfig{}
 		o.FsConfig = EventActionFilesystemConfig{}
 		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
 		return o.PwdExpirationConfig.validate()
 	case ActionTypeIDPAccountCheck:
 		o.HTTPConfig = EventActionHTTPConfig{}
@@ -1097,7 +1744,119 @@ func (o *BaseEventActionOptions) validate(action int, name string) error {
 		o.RetentionConfig = EventActionDataRetentionConfig{}
 		o.FsConfig = EventActionFilesystemConfig{}
 		o.PwdExpirationConfig = EventActionPasswordExpiration{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
 		return o.IDPConfig.validate()
+	case ActionTypeTiering:
+		o.HTTPConfig = EventActionHTTPConfig{}
+		o.CmdConfig = EventActionCommandConfig{}
+		o.EmailConfig = EventActionEmailConfig{}
+		o.RetentionConfig = EventActionDataRetentionConfig{}
+		o.FsConfig = EventActionFilesystemConfig{}
+		o.PwdExpirationConfig = EventActionPasswordExpiration{}
+		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
+		return o.TieringConfig.validate()
+	case ActionTypeUserArchive:
+		o.HTTPConfig = EventActionHTTPConfig{}
+		o.CmdConfig = EventActionCommandConfig{}
+		o.EmailConfig = EventActionEmailConfig{}
+		o.RetentionConfig = EventActionDataRetentionConfig{}
+		o.FsConfig = EventActionFilesystemConfig{}
+		o.PwdExpirationConfig = EventActionPasswordExpiration{}
+		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
+		return o.ArchiveConfig.validate()
+	case ActionTypeBackup:
+		o.HTTPConfig = EventActionHTTPConfig{}
+		o.CmdConfig = EventActionCommandConfig{}
+		o.EmailConfig = EventActionEmailConfig{}
+		o.RetentionConfig = EventActionDataRetentionConfig{}
+		o.FsConfig = EventActionFilesystemConfig{}
+		o.PwdExpirationConfig = EventActionPasswordExpiration{}
+		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
+		return o.BackupConfig.validate(name)
+	case ActionTypeChatNotification:
+		o.HTTPConfig = EventActionHTTPConfig{}
+		o.CmdConfig = EventActionCommandConfig{}
+		o.EmailConfig = EventActionEmailConfig{}
+		o.RetentionConfig = EventActionDataRetentionConfig{}
+		o.FsConfig = EventActionFilesystemConfig{}
+		o.PwdExpirationConfig = EventActionPasswordExpiration{}
+		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
+		return o.ChatConfig.validate(name)
+	case ActionTypeMessagePublish:
+		o.HTTPConfig = EventActionHTTPConfig{}
+		o.CmdConfig = EventActionCommandConfig{}
+		o.EmailConfig = EventActionEmailConfig{}
+		o.RetentionConfig = EventActionDataRetentionConfig{}
+		o.FsConfig = EventActionFilesystemConfig{}
+		o.PwdExpirationConfig = EventActionPasswordExpiration{}
+		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
+		return o.MessageConfig.validate(name)
+	case ActionTypeCloudQueue:
+		o.HTTPConfig = EventActionHTTPConfig{}
+		o.CmdConfig = EventActionCommandConfig{}
+		o.EmailConfig = EventActionEmailConfig{}
+		o.RetentionConfig = EventActionDataRetentionConfig{}
+		o.FsConfig = EventActionFilesystemConfig{}
+		o.PwdExpirationConfig = EventActionPasswordExpiration{}
+		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
+		return o.CloudQueueConfig.validate()
+	case ActionTypeUsageReport:
+		o.HTTPConfig = EventActionHTTPConfig{}
+		o.CmdConfig = EventActionCommandConfig{}
+		o.EmailConfig = EventActionEmailConfig{}
+		o.RetentionConfig = EventActionDataRetentionConfig{}
+		o.FsConfig = EventActionFilesystemConfig{}
+		o.PwdExpirationConfig = EventActionPasswordExpiration{}
+		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		return o.UsageReportConfig.validate()
 	default:
 		o.HTTPConfig = EventActionHTTPConfig{}
 		o.CmdConfig = EventActionCommandConfig{}
@@ -1106,6 +1865,13 @@ func (o *BaseEventActionOptions) validate(action int, name string) error {
 		o.FsConfig = EventActionFilesystemConfig{}
 		o.PwdExpirationConfig = EventActionPasswordExpiration{}
 		o.IDPConfig = EventActionIDPAccountCheck{}
+		o.TieringConfig = EventActionTieringConfig{}
+		o.ArchiveConfig = EventActionUserArchiveConfig{}
+		o.BackupConfig = EventActionBackupConfig{}
+		o.ChatConfig = EventActionChatConfig{}
+		o.MessageConfig = EventActionMessageConfig{}
+		o.CloudQueueConfig = EventActionCloudQueueConfig{}
+		o.UsageReportConfig = EventActionUsageReportConfig{}
 	}
 	return nil
 }
@@ -1256,6 +2022,22 @@ func (p *ConditionPattern) validate() error {
 	return nil
 }
 
+// ConditionAttribute defines a pattern to match against the value of a custom attribute.
+// A missing attribute is evaluated as an empty value
+type ConditionAttribute struct {
+	Name         string `json:"name"`
+	Pattern      string `json:"pattern"`
+	InverseMatch bool   `json:"inverse_match,omitempty"`
+}
+
+func (a *ConditionAttribute) validate() error {
+	if !attributeNameRegex.MatchString(a.Name) {
+		return util.NewValidationError(fmt.Sprintf("invalid condition attribute name %q", a.Name))
+	}
+	p := ConditionPattern{Pattern: a.Pattern}
+	return p.validate()
+}
+
 // ConditionOptions defines options for event conditions
 type ConditionOptions struct {
 	// Usernames or folder names
@@ -1265,11 +2047,13 @@ type ConditionOptions struct {
 	// Role names
 	RoleNames []ConditionPattern `json:"role_names,omitempty"`
 	// Virtual paths
-	FsPaths         []ConditionPattern `json:"fs_paths,omitempty"`
-	Protocols       []string           `json:"protocols,omitempty"`
-	ProviderObjects []string           `json:"provider_objects,omitempty"`
-	MinFileSize     int64              `json:"min_size,omitempty"`
-	MaxFileSize     int64              `json:"max_size,omitempty"`
+	FsPaths []ConditionPattern `json:"fs_paths,omitempty"`
+	// User or folder attributes, all the defined conditions must match
+	Attributes      []ConditionAttribute `json:"attributes,omitempty"`
+	Protocols       []string             `json:"protocols,omitempty"`
+	ProviderObjects []string             `json:"provider_objects,omitempty"`
+	MinFileSize     int64                `json:"min_size,omitempty"`
+	MaxFileSize     int64                `json:"max_size,omitempty"`
 	// allow to execute scheduled tasks concurrently from multiple instances
 	ConcurrentExecution bool `json:"concurrent_execution,omitempty"`
 }
@@ -1279,12 +2063,15 @@ func (f *ConditionOptions) getACopy() ConditionOptions {
 	copy(protocols, f.Protocols)
 	providerObjects := make([]string, len(f.ProviderObjects))
 	copy(providerObjects, f.ProviderObjects)
+	attributes := make([]ConditionAttribute, len(f.Attributes))
+	copy(attributes, f.Attributes)
 
 	return ConditionOptions{
 		Names:               cloneConditionPatterns(f.Names),
 		GroupNames:          cloneConditionPatterns(f.GroupNames),
 		RoleNames:           cloneConditionPatterns(f.RoleNames),
 		FsPaths:             cloneConditionPatterns(f.FsPaths),
+		Attributes:          attributes,
 		Protocols:           protocols,
 		ProviderObjects:     providerObjects,
 		MinFileSize:         f.MinFileSize,
@@ -1306,6 +2093,11 @@ func (f *ConditionOptions) validate() error {
 	if err := validateConditionPatterns(f.FsPaths); err != nil {
 		return err
 	}
+	for idx := range f.Attributes {
+		if err := f.Attributes[idx].validate(); err != nil {
+			return err
+		}
+	}
 
 	for _, p := range f.Protocols {
 		if !util.Contains(SupportedRuleConditionProtocols, p) {
@@ -1358,8 +2150,24 @@ type EventConditions struct {
 	ProviderEvents []string   `json:"provider_events,omitempty"`
 	Schedules      []Schedule `json:"schedules,omitempty"`
 	// 0 any, 1 user, 2 admin
-	IDPLoginEvent int              `json:"idp_login_event,omitempty"`
-	Options       ConditionOptions `json:"options"`
+	IDPLoginEvent int `json:"idp_login_event,omitempty"`
+	// Usage percentages, of the quota size or files, for quota threshold events
+	QuotaThresholds []int `json:"quota_thresholds,omitempty"`
+	// Percentage points the usage must drop below a crossed threshold before
+	// the event can fire again for that threshold
+	QuotaHysteresis int `json:"quota_hysteresis,omitempty"`
+	// Users with no login for at least this number of days match user inactivity events
+	InactivityDays int              `json:"inactivity_days,omitempty"`
+	Options        ConditionOptions `json:"options"`
+}
+
+// GetQuotaThresholdsAsString returns the quota thresholds as comma separated string
+func (c EventConditions) GetQuotaThresholdsAsString() string {
+	thresholds := make([]string, 0, len(c.QuotaThresholds))
+	for _, t := range c.QuotaThresholds {
+		thresholds = append(thresholds, strconv.Itoa(t))
+	}
+	return strings.Join(thresholds, ",")
 }
 
 func (c *EventConditions) getACopy() EventConditions {
@@ -1377,15 +2185,53 @@ func (c *EventConditions) getACopy() EventConditions {
 		})
 	}
 
+	quotaThresholds := make([]int, len(c.QuotaThresholds))
+	copy(quotaThresholds, c.QuotaThresholds)
+
 	return EventConditions{
-		FsEvents:       fsEvents,
-		ProviderEvents: providerEvents,
-		Schedules:      schedules,
-		IDPLoginEvent:  c.IDPLoginEvent,
-		Options:        c.Options.getACopy(),
+		FsEvents:        fsEvents,
+		ProviderEvents:  providerEvents,
+		Schedules:       schedules,
+		IDPLoginEvent:   c.IDPLoginEvent,
+		QuotaThresholds: quotaThresholds,
+		QuotaHysteresis: c.QuotaHysteresis,
+		InactivityDays:  c.InactivityDays,
+		Options:         c.Options.getACopy(),
 	}
 }
 
+func (c *EventConditions) validateQuotaThresholds() error {
+	if len(c.QuotaThresholds) == 0 {
+		return util.NewI18nError(
+			util.NewValidationError("at least one quota threshold is required"),
+			util.I18nErrorRuleQuotaThresholdRequired,
+		)
+	}
+	for _, t := range c.QuotaThresholds {
+		if t < 1 || t > 100 {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("invalid quota threshold %d, allowed range: 1-100", t)),
+				util.I18nErrorRuleQuotaThresholdInvalid,
+			)
+		}
+	}
+	slices.Sort(c.QuotaThresholds)
+	c.QuotaThresholds = slices.Compact(c.QuotaThresholds)
+	if c.QuotaHysteresis < 0 || c.QuotaHysteresis >= c.QuotaThresholds[0] {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("invalid quota hysteresis %d, it must be lower than the lowest threshold",
+				c.QuotaHysteresis)),
+			util.I18nErrorRuleQuotaHysteresisInvalid,
+		)
+	}
+	for _, obj := range c.Options.ProviderObjects {
+		if !util.Contains(SupportedQuotaThresholdObjects, obj) {
+			return util.NewValidationError(fmt.Sprintf("unsupported quota threshold object: %q", obj))
+		}
+	}
+	return nil
+}
+
 func (c *EventConditions) validateSchedules() error {
 	if len(c.Schedules) == 0 {
 		return util.NewI18nError(
@@ -1402,6 +2248,13 @@ func (c *EventConditions) validateSchedules() error {
 }
 
 func (c *EventConditions) validate(trigger int) error {
+	if trigger != EventTriggerQuotaThreshold {
+		c.QuotaThresholds = nil
+		c.QuotaHysteresis = 0
+	}
+	if trigger != EventTriggerUserInactivity {
+		c.InactivityDays = 0
+	}
 	switch trigger {
 	case EventTriggerFsEvent:
 		c.ProviderEvents = nil
@@ -1458,6 +2311,7 @@ func (c *EventConditions) validate(trigger int) error {
 		c.Options.GroupNames = nil
 		c.Options.RoleNames = nil
 		c.Options.FsPaths = nil
+		c.Options.Attributes = nil
 		c.Options.Protocols = nil
 		c.Options.MinFileSize = 0
 		c.Options.MaxFileSize = 0
@@ -1480,6 +2334,7 @@ func (c *EventConditions) validate(trigger int) error {
 		c.Options.GroupNames = nil
 		c.Options.RoleNames = nil
 		c.Options.FsPaths = nil
+		c.Options.Attributes = nil
 		c.Options.Protocols = nil
 		c.Options.MinFileSize = 0
 		c.Options.MaxFileSize = 0
@@ -1487,12 +2342,47 @@ func (c *EventConditions) validate(trigger int) error {
 		if !util.Contains(supportedIDPLoginEvents, c.IDPLoginEvent) {
 			return util.NewValidationError(fmt.Sprintf("invalid Identity Provider login event %d", c.IDPLoginEvent))
 		}
+	case EventTriggerQuotaThreshold:
+		c.FsEvents = nil
+		c.ProviderEvents = nil
+		c.Options.GroupNames = nil
+		c.Options.RoleNames = nil
+		c.Options.FsPaths = nil
+		c.Options.Attributes = nil
+		c.Options.Protocols = nil
+		c.Options.MinFileSize = 0
+		c.Options.MaxFileSize = 0
+		c.Schedules = nil
+		c.IDPLoginEvent = 0
+		c.Options.ConcurrentExecution = false
+		if err := c.validateQuotaThresholds(); err != nil {
+			return err
+		}
+	case EventTriggerUserInactivity:
+		c.FsEvents = nil
+		c.ProviderEvents = nil
+		c.Options.FsPaths = nil
+		c.Options.Protocols = nil
+		c.Options.MinFileSize = 0
+		c.Options.MaxFileSize = 0
+		c.Options.ProviderObjects = nil
+		c.IDPLoginEvent = 0
+		if c.InactivityDays < 1 {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("invalid inactivity days %d, it must be greater than 0", c.InactivityDays)),
+				util.I18nErrorRuleInactivityDaysInvalid,
+			)
+		}
+		if err := c.validateSchedules(); err != nil {
+			return err
+		}
 	default:
 		c.FsEvents = nil
 		c.ProviderEvents = nil
 		c.Options.GroupNames = nil
 		c.Options.RoleNames = nil
 		c.Options.FsPaths = nil
+		c.Options.Attributes = nil
 		c.Options.Protocols = nil
 		c.Options.MinFileSize = 0
 		c.Options.MaxFileSize = 0
@@ -1653,7 +2543,8 @@ func (r *EventRule) validateMandatorySyncActions() error {
 func (r *EventRule) checkIPBlockedAndCertificateActions() error {
 	unavailableActions := []int{ActionTypeUserQuotaReset, ActionTypeFolderQuotaReset, ActionTypeTransferQuotaReset,
 		ActionTypeDataRetentionCheck, ActionTypeMetadataCheck, ActionTypeFilesystem, ActionTypePasswordExpirationCheck,
-		ActionTypeUserExpirationCheck}
+		ActionTypeUserExpirationCheck, ActionTypeSnapshot, ActionTypeTiering, ActionTypeUserArchive,
+		ActionTypeUserDisable, ActionTypeUsageReport}
 	for _, action := range r.Actions {
 		if util.Contains(unavailableActions, action.Type) {
 			return fmt.Errorf("action %q, type %q is not supported for event trigger %q",
@@ -1664,12 +2555,13 @@ func (r *EventRule) checkIPBlockedAndCertificateActions() error {
 }
 
 func (r *EventRule) checkProviderEventActions(providerObjectType string) error {
-	// user quota reset, transfer quota reset, data retention check and filesystem actions
-	// can be executed only if we modify a user. They will be executed for the
-	// affected user. Folder quota reset can be executed only for folders.
+	// user quota reset, transfer quota reset, data retention check, snapshot, user archive,
+	// user disable and filesystem actions can be executed only if we modify a user. They will
+	// be executed for the affected user. Folder quota reset can be executed only for folders.
 	userSpecificActions := []int{ActionTypeUserQuotaReset, ActionTypeTransferQuotaReset,
 		ActionTypeDataRetentionCheck, ActionTypeMetadataCheck, ActionTypeFilesystem,
-		ActionTypePasswordExpirationCheck, ActionTypeUserExpirationCheck}
+		ActionTypePasswordExpirationCheck, ActionTypeUserExpirationCheck, ActionTypeSnapshot,
+		ActionTypeUserArchive, ActionTypeUserDisable, ActionTypeUsageReport}
 	for _, action := range r.Actions {
 		if util.Contains(userSpecificActions, action.Type) && providerObjectType != actionObjectUser {
 			return fmt.Errorf("action %q, type %q is only supported for provider user events",
@@ -1685,9 +2577,9 @@ func (r *EventRule) checkProviderEventActions(providerObjectType string) error {
 
 func (r *EventRule) hasUserAssociated(providerObjectType string) bool {
 	switch r.Trigger {
-	case EventTriggerProviderEvent:
+	case EventTriggerProviderEvent, EventTriggerQuotaThreshold:
 		return providerObjectType == actionObjectUser
-	case EventTriggerFsEvent:
+	case EventTriggerFsEvent, EventTriggerUserInactivity:
 		return true
 	default:
 		if len(r.Actions) > 0 {
@@ -1735,10 +2627,14 @@ func (r *EventRule) checkActions(providerObjectType string) error {
 // CheckActionsConsistency returns an error if the actions cannot be executed
 func (r *EventRule) CheckActionsConsistency(providerObjectType string) error {
 	switch r.Trigger {
-	case EventTriggerProviderEvent:
+	case EventTriggerProviderEvent, EventTriggerQuotaThreshold:
 		if err := r.checkProviderEventActions(providerObjectType); err != nil {
 			return err
 		}
+	case EventTriggerUserInactivity:
+		if err := r.checkProviderEventActions(actionObjectUser); err != nil {
+			return err
+		}
 	case EventTriggerFsEvent:
 		// folder quota reset cannot be executed
 		for _, action := range r.Actions {
@@ -28,11 +28,28 @@ import (
 	"github.com/drakkan/sftpgo/v2/internal/vfs"
 )
 
+const (
+	maxIncludedGroups    = 20
+	maxGroupNestingLevel = 10
+)
+
 // GroupUserSettings defines the settings to apply to users
 type GroupUserSettings struct {
 	sdk.BaseGroupUserSettings
 	// Filesystem configuration details
 	FsConfig vfs.Filesystem `json:"filesystem"`
+	// SSH algorithms allowed for the users of this group
+	SSHAlgorithms SSHAlgorithms `json:"ssh_algorithms,omitempty"`
+	// SSH user certificates settings for the users of this group
+	SSHCertificates SSHCertificateConfig `json:"ssh_certificates,omitempty"`
+	// Time windows during which the users of this group are allowed to login
+	AccessSchedule AccessSchedule `json:"access_schedule,omitempty"`
+	// Authentication requirements based on the connection source for the users of this group
+	SourceAuthPolicies []SourceAuthPolicy `json:"source_auth_policies,omitempty"`
+	// FTP specific settings for the users of this group
+	FTP FTPSettings `json:"ftp,omitempty"`
+	// WebClient customizations for the users of this group
+	Branding GroupBranding `json:"branding,omitempty"`
 }
 
 // Group defines an SFTPGo group.
@@ -43,6 +60,10 @@ type Group struct {
 	UserSettings GroupUserSettings `json:"user_settings,omitempty"`
 	// Mapping between virtual paths and virtual folders
 	VirtualFolders []vfs.VirtualFolder `json:"virtual_folders,omitempty"`
+	// Names of the groups whose settings are included in this group.
+	// The settings defined in this group take precedence over the included ones
+	// and the included groups are merged in the defined order
+	IncludedGroups []string `json:"included_groups,omitempty"`
 }
 
 // GetPermissions returns the permissions as list
@@ -57,6 +78,11 @@ func (g *Group) GetPermissions() []sdk.DirectoryPermissions {
 	return result
 }
 
+// IncludesGroup returns true if the specified group is directly included
+func (g *Group) IncludesGroup(name string) bool {
+	return util.Contains(g.IncludedGroups, name)
+}
+
 // GetAllowedIPAsString returns the allowed IP as comma separated string
 func (g *Group) GetAllowedIPAsString() string {
 	return strings.Join(g.UserSettings.Filters.AllowedIP, ",")
@@ -151,9 +177,175 @@ func (g *Group) validate() error {
 		return err
 	}
 	g.VirtualFolders = vfolders
+	if err := g.validateIncludedGroups(); err != nil {
+		return err
+	}
 	return g.validateUserSettings()
 }
 
+func (g *Group) validateIncludedGroups() error {
+	g.IncludedGroups = util.RemoveDuplicates(g.IncludedGroups, false)
+	if len(g.IncludedGroups) > maxIncludedGroups {
+		return util.NewValidationError(fmt.Sprintf("too many included groups, max allowed: %d", maxIncludedGroups))
+	}
+	for _, name := range g.IncludedGroups {
+		if name == g.Name {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("group %q cannot include itself", g.Name)),
+				util.I18nErrorGroupIncludesCycle,
+			)
+		}
+	}
+	return nil
+}
+
+// checkIncludedGroups checks that the included groups exist and that they
+// don't include, directly or indirectly, the specified group
+func (g *Group) checkIncludedGroups() error {
+	if len(g.IncludedGroups) == 0 {
+		return nil
+	}
+	groupsMapping := make(map[string]Group)
+	groups, err := provider.getGroupsWithNames(g.IncludedGroups)
+	if err != nil {
+		return err
+	}
+	for idx := range groups {
+		groupsMapping[groups[idx].Name] = groups[idx]
+	}
+	for _, name := range g.IncludedGroups {
+		if _, ok := groupsMapping[name]; !ok {
+			return util.NewI18nError(
+				util.NewValidationError(fmt.Sprintf("included group %q does not exist", name)),
+				util.I18nErrorGroupIncludesNotFound,
+			)
+		}
+	}
+	if err := loadIncludedGroups(groupsMapping, provider.getGroupsWithNames); err != nil {
+		return err
+	}
+	groupsMapping[g.Name] = *g
+	visited := make(map[string]bool)
+	var check func(name string, depth int) error
+	check = func(name string, depth int) error {
+		if depth > maxGroupNestingLevel {
+			return util.NewValidationError(fmt.Sprintf("too many nesting levels for group %q, max allowed: %d",
+				g.Name, maxGroupNestingLevel))
+		}
+		group, ok := groupsMapping[name]
+		if !ok {
+			return nil
+		}
+		for _, included := range group.IncludedGroups {
+			if included == g.Name {
+				return util.NewI18nError(
+					util.NewValidationError(fmt.Sprintf("including groups %v in %q creates a cycle",
+						g.IncludedGroups, g.Name)),
+					util.I18nErrorGroupIncludesCycle,
+				)
+			}
+			if visited[included] {
+				continue
+			}
+			visited[included] = true
+			if err := check(included, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return check(g.Name, 1)
+}
+
+// loadIncludedGroups adds to the given mapping the groups included, directly
+// or indirectly, by the groups already in the mapping
+func loadIncludedGroups(groupsMapping map[string]Group, getter func([]string) ([]Group, error)) error {
+	for level := 0; level < maxGroupNestingLevel; level++ {
+		var names []string
+		for _, group := range groupsMapping {
+			for _, name := range group.IncludedGroups {
+				if _, ok := groupsMapping[name]; !ok {
+					names = append(names, name)
+				}
+			}
+		}
+		names = util.RemoveDuplicates(names, false)
+		if len(names) == 0 {
+			return nil
+		}
+		groups, err := getter(names)
+		if err != nil {
+			return err
+		}
+		if len(groups) == 0 {
+			return nil
+		}
+		for idx := range groups {
+			groupsMapping[groups[idx].Name] = groups[idx]
+		}
+	}
+	return nil
+}
+
+// getGroupHierarchy returns the specified group followed by the groups it
+// includes, directly or indirectly, in depth-first order. Each group is
+// returned only once and cycles are ignored
+func getGroupHierarchy(name string, groupsMapping map[string]Group) []Group {
+	var result []Group
+	visited := make(map[string]bool)
+
+	var visit func(name string, depth int)
+	visit = func(name string, depth int) {
+		if visited[name] || depth > maxGroupNestingLevel {
+			return
+		}
+		visited[name] = true
+		group, ok := groupsMapping[name]
+		if !ok {
+			providerLog(logger.LevelError, "unable to resolve group hierarchy, group %q not found", name)
+			return
+		}
+		result = append(result, group)
+		for _, included := range group.IncludedGroups {
+			visit(included, depth+1)
+		}
+	}
+
+	visit(name, 1)
+	return result
+}
+
+// OrderGroupsByInclusion returns the given groups ordered so that the included
+// groups come before the groups that include them
+func OrderGroupsByInclusion(groups []Group) []Group {
+	indexes := make(map[string]int)
+	for idx := range groups {
+		indexes[groups[idx].Name] = idx
+	}
+	result := make([]Group, 0, len(groups))
+	visited := make(map[string]bool)
+
+	var visit func(idx int)
+	visit = func(idx int) {
+		name := groups[idx].Name
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		for _, included := range groups[idx].IncludedGroups {
+			if includedIdx, ok := indexes[included]; ok {
+				visit(includedIdx)
+			}
+		}
+		result = append(result, groups[idx])
+	}
+
+	for idx := range groups {
+		visit(idx)
+	}
+	return result
+}
+
 func (g *Group) validateUserSettings() error {
 	if g.UserSettings.HomeDir != "" {
 		g.UserSettings.HomeDir = filepath.Clean(g.UserSettings.HomeDir)
@@ -180,6 +372,22 @@ func (g *Group) validateUserSettings() error {
 		g.UserSettings.Permissions = permissions
 	}
 	g.UserSettings.Filters.TLSCerts = nil
+	updateSSHAlgorithmsValues(&g.UserSettings.SSHAlgorithms)
+	if err := g.UserSettings.SSHCertificates.validate(); err != nil {
+		return util.NewI18nError(err, util.I18nErrorSSHCertificatesInvalid)
+	}
+	if err := g.UserSettings.AccessSchedule.validate(); err != nil {
+		return util.NewI18nError(err, util.I18nErrorAccessScheduleInvalid)
+	}
+	if err := g.UserSettings.FTP.validate(); err != nil {
+		return util.NewI18nError(err, util.I18nErrorFTPSettingsInvalid)
+	}
+	if err := validateSourceAuthPolicies(g.UserSettings.SourceAuthPolicies); err != nil {
+		return util.NewI18nError(err, util.I18nErrorSourceAuthPolicyInvalid)
+	}
+	if err := g.UserSettings.Branding.validate(); err != nil {
+		return util.NewI18nError(err, util.I18nErrorBrandingInvalid)
+	}
 	if err := validateBaseFilters(&g.UserSettings.Filters); err != nil {
 		return err
 	}
@@ -200,6 +408,12 @@ func (g *Group) getACopy() Group {
 		vfolder := g.VirtualFolders[idx].GetACopy()
 		virtualFolders = append(virtualFolders, vfolder)
 	}
+	includedGroups := make([]string, len(g.IncludedGroups))
+	copy(includedGroups, g.IncludedGroups)
+	sourceAuthPolicies := make([]SourceAuthPolicy, 0, len(g.UserSettings.SourceAuthPolicies))
+	for idx := range g.UserSettings.SourceAuthPolicies {
+		sourceAuthPolicies = append(sourceAuthPolicies, g.UserSettings.SourceAuthPolicies[idx].getACopy())
+	}
 	permissions := make(map[string][]string)
 	for k, v := range g.UserSettings.Permissions {
 		perms := make([]string, len(v))
@@ -232,8 +446,15 @@ func (g *Group) getACopy() Group {
 				ExpiresIn:            g.UserSettings.ExpiresIn,
 				Filters:              copyBaseUserFilters(g.UserSettings.Filters),
 			},
-			FsConfig: g.UserSettings.FsConfig.GetACopy(),
+			FsConfig:           g.UserSettings.FsConfig.GetACopy(),
+			SSHAlgorithms:      g.UserSettings.SSHAlgorithms.getACopy(),
+			SSHCertificates:    g.UserSettings.SSHCertificates.getACopy(),
+			AccessSchedule:     g.UserSettings.AccessSchedule.getACopy(),
+			SourceAuthPolicies: sourceAuthPolicies,
+			FTP:                g.UserSettings.FTP,
+			Branding:           g.UserSettings.Branding.getACopy(),
 		},
 		VirtualFolders: virtualFolders,
+		IncludedGroups: includedGroups,
 	}
 }
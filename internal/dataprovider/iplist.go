@@ -135,6 +135,8 @@ func (e *IPListEntry) HasProtocol(proto string) bool {
 		return e.Protocols&4 != 0
 	case protocolHTTP:
 		return e.Protocols&8 != 0
+	case protocolS3:
+		return e.Protocols&16 != 0
 	default:
 		return false
 	}
@@ -0,0 +1,252 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !nobolt || !noetcd || !noredis
+// +build !nobolt !noetcd !noredis
+
+package dataprovider
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/sftpgo/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+// setKVTestProvider sets p as the global provider, with fast password
+// hashing, and restores the previous configuration when the test ends
+func setKVTestProvider(t *testing.T, p Provider) {
+	oldConfig := config
+	oldProvider := provider
+	t.Cleanup(func() {
+		config = oldConfig
+		provider = oldProvider
+	})
+	config.IsShared = 0
+	config.NamingRules = 0
+	config.PasswordHashing.Algo = HashingAlgoBcrypt
+	config.PasswordHashing.BcryptOptions.Cost = bcrypt.MinCost
+	provider = p
+}
+
+func getKVTestUser(homeDir string) User {
+	return User{
+		BaseUser: sdk.BaseUser{
+			Username: "kv_user",
+			Password: "kv_password",
+			HomeDir:  homeDir,
+			Status:   1,
+			Role:     "kv_role",
+			Permissions: map[string][]string{
+				"/": {PermAny},
+			},
+		},
+		VirtualFolders: []vfs.VirtualFolder{
+			{
+				BaseVirtualFolder: vfs.BaseVirtualFolder{
+					Name: "kv_folder",
+				},
+				VirtualPath: "/vdir",
+			},
+		},
+		Groups: []sdk.GroupMapping{
+			{
+				Name: "kv_group",
+				Type: sdk.GroupTypePrimary,
+			},
+		},
+	}
+}
+
+// testKVProvider runs the CRUD and dump/restore checks shared by the
+// key/value providers
+func testKVProvider(t *testing.T, p *kvProvider) {
+	var errNotFound *util.RecordNotFoundError
+	homeDir := filepath.Join(t.TempDir(), "home")
+	err := p.initializeDatabase()
+	require.NoError(t, err)
+	err = p.initializeDatabase()
+	assert.ErrorIs(t, err, ErrNoInitRequired)
+	err = p.migrateDatabase()
+	assert.ErrorIs(t, err, ErrNoInitRequired)
+
+	role := Role{Name: "kv_role"}
+	err = p.addRole(&role)
+	require.NoError(t, err)
+	folder := vfs.BaseVirtualFolder{
+		Name:       "kv_folder",
+		MappedPath: filepath.Join(homeDir, "folder"),
+	}
+	err = p.addFolder(&folder)
+	require.NoError(t, err)
+	group := Group{
+		BaseGroup: sdk.BaseGroup{
+			Name:        "kv_group",
+			Description: "kv group",
+		},
+	}
+	err = p.addGroup(&group)
+	require.NoError(t, err)
+	user := getKVTestUser(homeDir)
+	err = p.addUser(&user)
+	require.NoError(t, err)
+	err = p.addUser(&user)
+	assert.ErrorIs(t, err, ErrDuplicatedKey)
+
+	user, err = p.userExists(user.Username, "")
+	require.NoError(t, err)
+	assert.Greater(t, user.ID, int64(0))
+	assert.True(t, user.IsPasswordHashed())
+	assert.Len(t, user.VirtualFolders, 1)
+	assert.Len(t, user.Groups, 1)
+	_, err = p.userExists(user.Username, "other_role")
+	assert.ErrorAs(t, err, &errNotFound)
+	_, err = p.validateUserAndPass(user.Username, "kv_password", "127.0.0.1", protocolSSH)
+	assert.NoError(t, err)
+	_, err = p.validateUserAndPass(user.Username, "wrong_password", "127.0.0.1", protocolSSH)
+	assert.Error(t, err)
+	role, err = p.roleExists(role.Name)
+	require.NoError(t, err)
+	assert.Equal(t, []string{user.Username}, role.Users)
+	group, err = p.groupExists(group.Name)
+	require.NoError(t, err)
+	assert.Equal(t, []string{user.Username}, group.Users)
+	folder, err = p.getFolderByName(folder.Name)
+	require.NoError(t, err)
+	assert.Equal(t, []string{user.Username}, folder.Users)
+
+	user.Description = "updated"
+	err = p.updateUser(&user)
+	require.NoError(t, err)
+	err = p.updateQuota(user.Username, 2, 100, false)
+	require.NoError(t, err)
+	err = p.updateQuota(user.Username, 1, 50, false)
+	require.NoError(t, err)
+	files, size, _, _, err := p.getUsedQuota(user.Username)
+	require.NoError(t, err)
+	assert.Equal(t, 3, files)
+	assert.Equal(t, int64(150), size)
+	users, err := p.getUsers(10, 0, OrderASC, "")
+	require.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, "updated", users[0].Description)
+	}
+
+	dumpedUsers, err := p.dumpUsers()
+	require.NoError(t, err)
+	dumpedFolders, err := p.dumpFolders()
+	require.NoError(t, err)
+	dumpedGroups, err := p.dumpGroups()
+	require.NoError(t, err)
+	dumpedRoles, err := p.dumpRoles()
+	require.NoError(t, err)
+	require.Len(t, dumpedUsers, 1)
+	require.Len(t, dumpedFolders, 1)
+	require.Len(t, dumpedGroups, 1)
+	require.Len(t, dumpedRoles, 1)
+
+	err = provider.resetDatabase()
+	require.NoError(t, err)
+	_, err = p.userExists(user.Username, "")
+	assert.ErrorAs(t, err, &errNotFound)
+	users, err = p.dumpUsers()
+	require.NoError(t, err)
+	assert.Len(t, users, 0)
+	// restore the dumped objects in dependency order
+	for idx := range dumpedRoles {
+		err = p.addRole(&dumpedRoles[idx])
+		require.NoError(t, err)
+	}
+	for idx := range dumpedFolders {
+		err = p.addFolder(&dumpedFolders[idx])
+		require.NoError(t, err)
+	}
+	for idx := range dumpedGroups {
+		err = p.addGroup(&dumpedGroups[idx])
+		require.NoError(t, err)
+	}
+	for idx := range dumpedUsers {
+		err = p.addUser(&dumpedUsers[idx])
+		require.NoError(t, err)
+	}
+	restored, err := p.userExists(user.Username, "")
+	require.NoError(t, err)
+	assert.Equal(t, user.Password, restored.Password)
+	assert.Equal(t, "updated", restored.Description)
+	assert.Equal(t, user.Role, restored.Role)
+	assert.Len(t, restored.VirtualFolders, 1)
+	assert.Len(t, restored.Groups, 1)
+	group, err = p.groupExists(group.Name)
+	require.NoError(t, err)
+	assert.Equal(t, "kv group", group.Description)
+	assert.Equal(t, []string{user.Username}, group.Users)
+	folder, err = p.getFolderByName(folder.Name)
+	require.NoError(t, err)
+	assert.Equal(t, []string{user.Username}, folder.Users)
+
+	err = p.deleteUser(restored, false)
+	require.NoError(t, err)
+	_, err = p.userExists(user.Username, "")
+	assert.ErrorAs(t, err, &errNotFound)
+	role, err = p.roleExists(role.Name)
+	require.NoError(t, err)
+	assert.Len(t, role.Users, 0)
+	err = p.deleteGroup(group)
+	require.NoError(t, err)
+	err = p.deleteFolder(folder)
+	require.NoError(t, err)
+	err = p.deleteRole(role)
+	require.NoError(t, err)
+	err = p.deleteRole(role)
+	assert.Error(t, err)
+}
+
+// testKVConflict checks that a transaction is not committed if a key it read
+// was modified by a concurrent transaction
+func testKVConflict(t *testing.T, store kvStore) {
+	s1, err := store.begin(false)
+	require.NoError(t, err)
+	defer s1.close()
+	_, err = s1.get(kvConfigsBucket, kvConfigsKey)
+	require.NoError(t, err)
+
+	s2, err := store.begin(false)
+	require.NoError(t, err)
+	defer s2.close()
+	_, err = s2.get(kvConfigsBucket, kvConfigsKey)
+	require.NoError(t, err)
+	committed, err := s2.commit([]kvChange{{bucket: kvConfigsBucket, key: kvConfigsKey, value: []byte("{}")}})
+	require.NoError(t, err)
+	assert.True(t, committed)
+
+	committed, err = s1.commit([]kvChange{{bucket: kvConfigsBucket, key: kvConfigsKey, value: []byte("{}")}})
+	require.NoError(t, err)
+	assert.False(t, committed)
+
+	s3, err := store.begin(true)
+	require.NoError(t, err)
+	defer s3.close()
+	values, err := s3.getAll(kvConfigsBucket)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{kvConfigsKey: []byte("{}")}, values)
+	n, err := s3.count(kvConfigsBucket)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
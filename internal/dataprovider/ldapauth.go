@@ -0,0 +1,245 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// LDAPAuthConfig defines the configuration to authenticate users by binding
+// to an LDAP server or Active Directory with their credentials
+type LDAPAuthConfig struct {
+	// LDAP server URL, for example ldaps://ldap.example.com:636. Empty means disabled
+	URL string `json:"url" mapstructure:"url"`
+	// Upgrade the connection using StartTLS, not allowed for ldaps URLs
+	StartTLS bool `json:"start_tls" mapstructure:"start_tls"`
+	// Skip the TLS certificate verification, only useful for testing
+	SkipTLSVerify bool `json:"skip_tls_verify" mapstructure:"skip_tls_verify"`
+	// Distinguished name and password used to search the users.
+	// Empty means anonymous search
+	BindDN       string `json:"bind_dn" mapstructure:"bind_dn"`
+	BindPassword string `json:"bind_password" mapstructure:"bind_password"`
+	// Base DN to search the users
+	BaseDN string `json:"base_dn" mapstructure:"base_dn"`
+	// LDAP filter to search the user, the "%username%" placeholder is replaced
+	// with the escaped login username, for example "(&(objectClass=person)(uid=%username%))"
+	UserFilter string `json:"user_filter" mapstructure:"user_filter"`
+	// Attribute to use as email, optional
+	EmailAttribute string `json:"email_attribute" mapstructure:"email_attribute"`
+	// Attribute containing the distinguished names of the groups the user is a member of
+	GroupAttribute string `json:"group_attribute" mapstructure:"group_attribute"`
+	// Permissions granted on the root directory to the created users
+	Permissions []string `json:"permissions" mapstructure:"permissions"`
+	// Mappings are evaluated in order, the first matching mapping is applied.
+	// The users that do not match any mapping are not allowed to login
+	Mappings []LDAPSyncMapping `json:"mappings" mapstructure:"mappings"`
+	// Protocols for which the LDAP authentication is enabled, empty means all.
+	// Supported values: SSH, FTP, DAV, HTTP
+	Protocols []string `json:"protocols" mapstructure:"protocols"`
+}
+
+func (c *LDAPAuthConfig) isEnabled() bool {
+	return c.URL != ""
+}
+
+func (c *LDAPAuthConfig) isEnabledForProtocol(protocol string) bool {
+	if !c.isEnabled() {
+		return false
+	}
+	return len(c.Protocols) == 0 || util.Contains(c.Protocols, protocol)
+}
+
+// isUserManaged returns true if the given user is authenticated using LDAP
+// for the specified protocol
+func (c *LDAPAuthConfig) isUserManaged(user *User, protocol string) bool {
+	return user.Filters.LDAPSync != nil && c.isEnabledForProtocol(protocol)
+}
+
+func (c *LDAPAuthConfig) validate() error {
+	if !c.isEnabled() {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid LDAP auth URL %q: %w", c.URL, err)
+	}
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		if c.StartTLS {
+			return errors.New("StartTLS is not allowed for ldaps URLs")
+		}
+	default:
+		return fmt.Errorf("invalid LDAP auth URL %q, unsupported scheme %q", c.URL, u.Scheme)
+	}
+	if c.BaseDN == "" {
+		return errors.New("LDAP auth base DN is mandatory")
+	}
+	if !strings.Contains(c.UserFilter, "%username%") {
+		return fmt.Errorf("invalid LDAP auth user filter %q, the %%username%% placeholder is required", c.UserFilter)
+	}
+	if len(c.Mappings) == 0 {
+		return errors.New("at least an LDAP auth mapping is required")
+	}
+	if len(c.Permissions) == 0 {
+		c.Permissions = []string{PermAny}
+	}
+	for _, protocol := range c.Protocols {
+		if !util.Contains(ValidProtocols, protocol) {
+			return fmt.Errorf("invalid LDAP auth protocol %q", protocol)
+		}
+	}
+	for idx := range c.Mappings {
+		m := &c.Mappings[idx]
+		if m.Group != "" && c.GroupAttribute == "" {
+			return fmt.Errorf("LDAP auth mapping for group %q: the group attribute is mandatory", m.Group)
+		}
+		if m.HomeDir != "" && !filepath.IsAbs(strings.ReplaceAll(m.HomeDir, "%username%", "user")) {
+			return fmt.Errorf("invalid LDAP auth mapping home dir %q, it must be an absolute path", m.HomeDir)
+		}
+		if m.QuotaSize < 0 || m.QuotaFiles < 0 {
+			return fmt.Errorf("invalid LDAP auth mapping quota for group %q", m.Group)
+		}
+		if m.HomeDir == "" && m.PrimaryGroup == "" && config.UsersBaseDir == "" {
+			return fmt.Errorf("LDAP auth mapping for group %q: a home dir or a primary group is required", m.Group)
+		}
+	}
+	return nil
+}
+
+// authenticate searches the user with the configured credentials and then
+// binds as the found user with the given password
+func (c *LDAPAuthConfig) authenticate(username, password string) (ldapSyncUser, error) {
+	var result ldapSyncUser
+
+	conn, err := ldapConnect(c.URL, c.StartTLS, c.SkipTLSVerify, c.BindDN, c.BindPassword, ldapAuthTimeout)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Close()
+
+	var attributes []string
+	if c.GroupAttribute != "" {
+		attributes = append(attributes, c.GroupAttribute)
+	}
+	if c.EmailAttribute != "" {
+		attributes = append(attributes, c.EmailAttribute)
+	}
+	if len(attributes) == 0 {
+		// no attributes
+		attributes = append(attributes, "1.1")
+	}
+	filter := strings.ReplaceAll(c.UserFilter, "%username%", ldap.EscapeFilter(username))
+	// a size limit of 2 allows to detect ambiguous filters
+	req := ldap.NewSearchRequest(c.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2,
+		int(ldapAuthTimeout/time.Second), false, filter, attributes, nil)
+	res, err := conn.Search(req)
+	if err != nil {
+		return result, fmt.Errorf("unable to search the LDAP user: %w", err)
+	}
+	if len(res.Entries) != 1 {
+		return result, fmt.Errorf("the LDAP search returned %d entries, exactly one is required", len(res.Entries))
+	}
+	entry := res.Entries[0]
+	var memberOf []string
+	if c.GroupAttribute != "" {
+		memberOf = entry.GetAttributeValues(c.GroupAttribute)
+	}
+	mapping := getLDAPMapping(c.Mappings, memberOf)
+	if mapping == nil {
+		return result, fmt.Errorf("no mapping matches the groups of the LDAP user %q", entry.DN)
+	}
+	if err := conn.Bind(entry.DN, password); err != nil {
+		return result, fmt.Errorf("unable to bind as %q: %w", entry.DN, err)
+	}
+	result = ldapSyncUser{
+		dn:       entry.DN,
+		username: username,
+		mapping:  mapping,
+	}
+	if c.EmailAttribute != "" {
+		result.email = entry.GetAttributeValue(c.EmailAttribute)
+	}
+	return result, nil
+}
+
+// doLDAPAuth authenticates the given user using LDAP. The existing users not
+// created by the LDAP authentication or imported by the LDAP sync are
+// authenticated using their local credentials. The LDAP users are created on
+// their first login and their settings are updated on each login
+func doLDAPAuth(username, password, ip, protocol string) (User, error) {
+	u, err := provider.userExists(username, "")
+	if err != nil {
+		if !errors.Is(err, util.ErrNotFound) {
+			return u, err
+		}
+		u = User{}
+	} else if u.Filters.LDAPSync == nil {
+		return provider.validateUserAndPass(username, password, ip, protocol)
+	}
+	if u.ID > 0 {
+		// check the login conditions and the TOTP passcode, if any, before contacting the LDAP server
+		user := u.getACopy()
+		if err := user.LoadAndApplyGroupSettings(); err != nil {
+			return user, err
+		}
+		if err := user.CheckLoginConditions(); err != nil {
+			return user, err
+		}
+		password, err = checkUserPasscode(&user, password, protocol)
+		if err != nil {
+			return user, ErrInvalidCredentials
+		}
+	}
+	if password == "" {
+		return u, errors.New("credentials cannot be null or empty")
+	}
+	startTime := time.Now()
+	ldapUser, err := config.LDAPAuth.authenticate(username, password)
+	if err != nil {
+		providerLog(logger.LevelDebug, "LDAP auth failed for user %q, ip %v, protocol %v, elapsed: %s: %v",
+			username, ip, protocol, time.Since(startTime), err)
+		return u, ErrInvalidCredentials
+	}
+	providerLog(logger.LevelDebug, "LDAP auth completed for user %q, elapsed: %s", username, time.Since(startTime))
+	if u.ID == 0 {
+		user := getNewLDAPUser(ldapUser, config.LDAPAuth.Permissions)
+		if err := provider.addUser(&user); err != nil {
+			return user, fmt.Errorf("unable to add LDAP user %q: %w", username, err)
+		}
+		u, err = provider.userExists(username, "")
+		if err != nil {
+			return u, err
+		}
+	} else if applyLDAPUser(ldapUser, &u) {
+		if err := provider.updateUser(&u); err != nil {
+			return u, fmt.Errorf("unable to update LDAP user %q: %w", username, err)
+		}
+	}
+	if err := u.LoadAndApplyGroupSettings(); err != nil {
+		return u, err
+	}
+	return u, u.CheckLoginConditions()
+}
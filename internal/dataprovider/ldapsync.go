@@ -0,0 +1,413 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+const (
+	ldapSyncPageSize = 500
+	ldapSyncTimeout  = 30 * time.Second
+	ldapAuthTimeout  = 10 * time.Second
+)
+
+var ldapSyncRunning atomic.Bool
+
+// LDAPSyncMapping defines the settings to apply to the users that are members of an LDAP group
+type LDAPSyncMapping struct {
+	// Distinguished name of the LDAP group. Empty means any user
+	Group string `json:"group" mapstructure:"group"`
+	// Home directory for the users, the "%username%" placeholder is replaced with the username.
+	// Empty means the home directory is defined by the groups or by the users base dir
+	HomeDir string `json:"home_dir" mapstructure:"home_dir"`
+	// Maximum size allowed as bytes, 0 means unlimited
+	QuotaSize int64 `json:"quota_size" mapstructure:"quota_size"`
+	// Maximum number of files allowed, 0 means unlimited
+	QuotaFiles int `json:"quota_files" mapstructure:"quota_files"`
+	// Name of the SFTPGo group to set as primary group, optional
+	PrimaryGroup string `json:"primary_group" mapstructure:"primary_group"`
+	// Names of the SFTPGo groups to set as secondary groups, optional
+	SecondaryGroups []string `json:"secondary_groups" mapstructure:"secondary_groups"`
+}
+
+func (m *LDAPSyncMapping) matches(memberOf []string) bool {
+	if m.Group == "" {
+		return true
+	}
+	for _, group := range memberOf {
+		if strings.EqualFold(group, m.Group) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *LDAPSyncMapping) getGroups() []sdk.GroupMapping {
+	var groups []sdk.GroupMapping
+	if m.PrimaryGroup != "" {
+		groups = append(groups, sdk.GroupMapping{
+			Name: m.PrimaryGroup,
+			Type: sdk.GroupTypePrimary,
+		})
+	}
+	for _, name := range m.SecondaryGroups {
+		groups = append(groups, sdk.GroupMapping{
+			Name: name,
+			Type: sdk.GroupTypeSecondary,
+		})
+	}
+	return groups
+}
+
+// LDAPSyncConfig defines the configuration to periodically import users and their
+// group memberships from an LDAP server or Active Directory
+type LDAPSyncConfig struct {
+	// LDAP server URL, for example ldaps://ldap.example.com:636. Empty means disabled
+	URL string `json:"url" mapstructure:"url"`
+	// Upgrade the connection using StartTLS, not allowed for ldaps URLs
+	StartTLS bool `json:"start_tls" mapstructure:"start_tls"`
+	// Skip the TLS certificate verification, only useful for testing
+	SkipTLSVerify bool `json:"skip_tls_verify" mapstructure:"skip_tls_verify"`
+	// Distinguished name and password used to bind to the LDAP server
+	BindDN       string `json:"bind_dn" mapstructure:"bind_dn"`
+	BindPassword string `json:"bind_password" mapstructure:"bind_password"`
+	// Base DN to search the users
+	BaseDN string `json:"base_dn" mapstructure:"base_dn"`
+	// LDAP filter to search the users
+	UserFilter string `json:"user_filter" mapstructure:"user_filter"`
+	// Attribute to use as SFTPGo username, for example "uid" or "sAMAccountName"
+	UsernameAttribute string `json:"username_attribute" mapstructure:"username_attribute"`
+	// Attribute to use as email, optional
+	EmailAttribute string `json:"email_attribute" mapstructure:"email_attribute"`
+	// Attribute containing the distinguished names of the groups the user is a member of
+	GroupAttribute string `json:"group_attribute" mapstructure:"group_attribute"`
+	// Permissions granted on the root directory to the created users
+	Permissions []string `json:"permissions" mapstructure:"permissions"`
+	// Mappings are evaluated in order, the first matching mapping is applied.
+	// The users that do not match any mapping are not imported
+	Mappings []LDAPSyncMapping `json:"mappings" mapstructure:"mappings"`
+	// Interval between synchronizations, in minutes
+	Interval int `json:"interval" mapstructure:"interval"`
+}
+
+func (c *LDAPSyncConfig) isEnabled() bool {
+	return c.URL != ""
+}
+
+func (c *LDAPSyncConfig) validate() error {
+	if !c.isEnabled() {
+		return nil
+	}
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return fmt.Errorf("invalid LDAP sync URL %q: %w", c.URL, err)
+	}
+	switch u.Scheme {
+	case "ldap":
+	case "ldaps":
+		if c.StartTLS {
+			return errors.New("StartTLS is not allowed for ldaps URLs")
+		}
+	default:
+		return fmt.Errorf("invalid LDAP sync URL %q, unsupported scheme %q", c.URL, u.Scheme)
+	}
+	if c.BaseDN == "" {
+		return errors.New("LDAP sync base DN is mandatory")
+	}
+	if c.UserFilter == "" || c.UsernameAttribute == "" || c.GroupAttribute == "" {
+		return errors.New("LDAP sync user filter, username attribute and group attribute are mandatory")
+	}
+	if c.Interval < 1 {
+		return fmt.Errorf("invalid LDAP sync interval: %d", c.Interval)
+	}
+	if len(c.Mappings) == 0 {
+		return errors.New("at least an LDAP sync mapping is required")
+	}
+	if len(c.Permissions) == 0 {
+		c.Permissions = []string{PermAny}
+	}
+	for idx := range c.Mappings {
+		m := &c.Mappings[idx]
+		if m.HomeDir != "" && !filepath.IsAbs(strings.ReplaceAll(m.HomeDir, "%username%", "user")) {
+			return fmt.Errorf("invalid LDAP sync mapping home dir %q, it must be an absolute path", m.HomeDir)
+		}
+		if m.QuotaSize < 0 || m.QuotaFiles < 0 {
+			return fmt.Errorf("invalid LDAP sync mapping quota for group %q", m.Group)
+		}
+		if m.HomeDir == "" && m.PrimaryGroup == "" && config.UsersBaseDir == "" {
+			return fmt.Errorf("LDAP sync mapping for group %q: a home dir or a primary group is required", m.Group)
+		}
+	}
+	return nil
+}
+
+// getLDAPMapping returns the first mapping matching the given groups, if any
+func getLDAPMapping(mappings []LDAPSyncMapping, memberOf []string) *LDAPSyncMapping {
+	for idx := range mappings {
+		if mappings[idx].matches(memberOf) {
+			return &mappings[idx]
+		}
+	}
+	return nil
+}
+
+func (c *LDAPSyncConfig) connect() (*ldap.Conn, error) {
+	return ldapConnect(c.URL, c.StartTLS, c.SkipTLSVerify, c.BindDN, c.BindPassword, ldapSyncTimeout)
+}
+
+// ldapConnect connects to the LDAP server and binds using the specified credentials.
+// An empty bind DN means unauthenticated bind
+func ldapConnect(rawURL string, startTLS, skipTLSVerify bool, bindDN, bindPassword string,
+	timeout time.Duration,
+) (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: skipTLSVerify, //nolint:gosec
+		MinVersion:         tls.VersionTLS12,
+	}
+	conn, err := ldap.DialURL(rawURL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to the LDAP server: %w", err)
+	}
+	conn.SetTimeout(timeout)
+	if startTLS {
+		u, _ := url.Parse(rawURL)
+		tlsConfig.ServerName = u.Hostname()
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to start TLS: %w", err)
+		}
+	}
+	if bindDN != "" {
+		err = conn.Bind(bindDN, bindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to bind to the LDAP server: %w", err)
+	}
+	return conn, nil
+}
+
+// ldapSyncUser defines a user read from the LDAP server
+type ldapSyncUser struct {
+	dn       string
+	username string
+	email    string
+	mapping  *LDAPSyncMapping
+}
+
+func (c *LDAPSyncConfig) searchUsers() (map[string]ldapSyncUser, error) {
+	conn, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	attributes := []string{c.UsernameAttribute, c.GroupAttribute}
+	if c.EmailAttribute != "" {
+		attributes = append(attributes, c.EmailAttribute)
+	}
+	req := ldap.NewSearchRequest(c.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		c.UserFilter, attributes, nil)
+	res, err := conn.SearchWithPaging(req, ldapSyncPageSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to search LDAP users: %w", err)
+	}
+	users := make(map[string]ldapSyncUser)
+	for _, entry := range res.Entries {
+		username := entry.GetAttributeValue(c.UsernameAttribute)
+		if username == "" {
+			providerLog(logger.LevelDebug, "LDAP sync, skipping entry %q without username", entry.DN)
+			continue
+		}
+		username = config.convertName(username)
+		mapping := getLDAPMapping(c.Mappings, entry.GetAttributeValues(c.GroupAttribute))
+		if mapping == nil {
+			continue
+		}
+		user := ldapSyncUser{
+			dn:       entry.DN,
+			username: username,
+			mapping:  mapping,
+		}
+		if c.EmailAttribute != "" {
+			user.email = entry.GetAttributeValue(c.EmailAttribute)
+		}
+		users[username] = user
+	}
+	return users, nil
+}
+
+// getNewLDAPUser returns a new user for the given LDAP user with the specified
+// permissions on the root directory
+func getNewLDAPUser(u ldapSyncUser, permissions []string) User {
+	user := User{
+		BaseUser: sdk.BaseUser{
+			Username:    u.username,
+			Email:       u.email,
+			Status:      1,
+			HomeDir:     strings.ReplaceAll(u.mapping.HomeDir, "%username%", u.username),
+			QuotaSize:   u.mapping.QuotaSize,
+			QuotaFiles:  u.mapping.QuotaFiles,
+			Description: "Imported from LDAP",
+			Permissions: map[string][]string{
+				"/": permissions,
+			},
+		},
+		Groups: u.mapping.getGroups(),
+	}
+	user.Filters.LDAPSync = &UserLDAPSync{
+		DN: u.dn,
+	}
+	return user
+}
+
+// applyLDAPUser applies the LDAP settings to an existing user and returns true if the user was modified
+func applyLDAPUser(u ldapSyncUser, user *User) bool {
+	homeDir := strings.ReplaceAll(u.mapping.HomeDir, "%username%", u.username)
+	groups := u.mapping.getGroups()
+	modified := user.Status != 1 || user.Email != u.email || user.QuotaSize != u.mapping.QuotaSize ||
+		user.QuotaFiles != u.mapping.QuotaFiles || user.Filters.LDAPSync.DN != u.dn ||
+		(homeDir != "" && user.HomeDir != homeDir) || !isSameGroupMappings(user.Groups, groups)
+	if !modified {
+		return false
+	}
+	user.Status = 1
+	user.Email = u.email
+	user.QuotaSize = u.mapping.QuotaSize
+	user.QuotaFiles = u.mapping.QuotaFiles
+	user.Groups = groups
+	if homeDir != "" {
+		user.HomeDir = homeDir
+	}
+	user.Filters.LDAPSync.DN = u.dn
+	return true
+}
+
+func isSameGroupMappings(current, groups []sdk.GroupMapping) bool {
+	if len(current) != len(groups) {
+		return false
+	}
+	key := func(g sdk.GroupMapping) string {
+		return fmt.Sprintf("%d\x00%s", g.Type, g.Name)
+	}
+	currentKeys := make([]string, 0, len(current))
+	for _, g := range current {
+		currentKeys = append(currentKeys, key(g))
+	}
+	keys := make([]string, 0, len(groups))
+	for _, g := range groups {
+		keys = append(keys, key(g))
+	}
+	sort.Strings(currentKeys)
+	sort.Strings(keys)
+	for idx := range keys {
+		if keys[idx] != currentKeys[idx] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *LDAPSyncConfig) sync() error {
+	if !ldapSyncRunning.CompareAndSwap(false, true) {
+		return errors.New("an LDAP synchronization is already in progress")
+	}
+	defer ldapSyncRunning.Store(false)
+
+	startTime := time.Now()
+	ldapUsers, err := c.searchUsers()
+	if err != nil {
+		return err
+	}
+	users, err := provider.dumpUsers()
+	if err != nil {
+		return fmt.Errorf("unable to get users: %w", err)
+	}
+	var added, updated, disabled int
+	var errs []string
+	existing := make(map[string]bool)
+	for idx := range users {
+		user := &users[idx]
+		existing[user.Username] = true
+		u, ok := ldapUsers[user.Username]
+		if user.Filters.LDAPSync == nil {
+			if ok {
+				providerLog(logger.LevelWarn, "LDAP sync, user %q already exists and is not managed by LDAP sync, skipped",
+					user.Username)
+			}
+			continue
+		}
+		if !ok {
+			if user.Status == 0 {
+				continue
+			}
+			user.Status = 0
+			if err := UpdateUser(user, ActionExecutorLDAPSync, "", ""); err != nil {
+				errs = append(errs, fmt.Sprintf("unable to disable user %q: %v", user.Username, err))
+				continue
+			}
+			providerLog(logger.LevelInfo, "LDAP sync, user %q removed upstream, disabled", user.Username)
+			disabled++
+			continue
+		}
+		if !applyLDAPUser(u, user) {
+			continue
+		}
+		if err := UpdateUser(user, ActionExecutorLDAPSync, "", ""); err != nil {
+			errs = append(errs, fmt.Sprintf("unable to update user %q: %v", user.Username, err))
+			continue
+		}
+		updated++
+	}
+	for username, u := range ldapUsers {
+		if existing[username] {
+			continue
+		}
+		user := getNewLDAPUser(u, c.Permissions)
+		if err := AddUser(&user, ActionExecutorLDAPSync, "", ""); err != nil {
+			errs = append(errs, fmt.Sprintf("unable to add user %q: %v", username, err))
+			continue
+		}
+		added++
+	}
+	providerLog(logger.LevelDebug, "LDAP sync completed, LDAP users: %d, added: %d, updated: %d, disabled: %d, "+
+		"errors: %d, elapsed: %s", len(ldapUsers), added, updated, disabled, len(errs), time.Since(startTime))
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func runLDAPSync() {
+	if err := config.LDAPSync.sync(); err != nil {
+		providerLog(logger.LevelError, "LDAP sync error: %v", err)
+	}
+}
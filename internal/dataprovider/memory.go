@@ -209,6 +209,23 @@ func (p *MemoryProvider) updateAPIKeyLastUse(keyID string) error {
 	return nil
 }
 
+func (p *MemoryProvider) rotateAPIKey(apiKey *APIKey) error {
+	p.dbHandle.Lock()
+	defer p.dbHandle.Unlock()
+	if p.dbHandle.isClosed {
+		return errMemoryProviderClosed
+	}
+	k, err := p.apiKeyExistsInternal(apiKey.KeyID)
+	if err != nil {
+		return err
+	}
+	k.Key = apiKey.Key
+	k.Filters = apiKey.Filters.getACopy()
+	k.UpdatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	p.dbHandle.apiKeys[k.KeyID] = k
+	return nil
+}
+
 func (p *MemoryProvider) setUpdatedAt(username string) {
 	p.dbHandle.Lock()
 	defer p.dbHandle.Unlock()
@@ -565,6 +582,7 @@ func (p *MemoryProvider) getRecentlyUpdatedUsers(after int64) ([]User, error) {
 				}
 				groupMapping[group.Name] = group
 			}
+			loadIncludedGroups(groupMapping, p.getGroupsWithNamesInternal) //nolint:errcheck
 			user.applyGroupSettings(groupMapping)
 		}
 
@@ -598,6 +616,7 @@ func (p *MemoryProvider) getUsersForQuotaCheck(toFetch map[string]bool) ([]User,
 					}
 					groupMapping[group.Name] = group
 				}
+				loadIncludedGroups(groupMapping, p.getGroupsWithNamesInternal) //nolint:errcheck
 				user.applyGroupSettings(groupMapping)
 			}
 			user.SetEmptySecretsIfNil()
@@ -686,6 +705,16 @@ func (p *MemoryProvider) userExistsInternal(username string) (User, error) {
 	return User{}, util.NewRecordNotFoundError(fmt.Sprintf("username %q does not exist", username))
 }
 
+func (p *MemoryProvider) getGroupsWithNamesInternal(names []string) ([]Group, error) {
+	groups := make([]Group, 0, len(names))
+	for _, name := range names {
+		if val, ok := p.dbHandle.groups[name]; ok {
+			groups = append(groups, val.getACopy())
+		}
+	}
+	return groups, nil
+}
+
 func (p *MemoryProvider) groupExistsInternal(name string) (Group, error) {
 	if val, ok := p.dbHandle.groups[name]; ok {
 		return val.getACopy(), nil
@@ -2100,6 +2129,10 @@ func (p *MemoryProvider) getSharedSession(_ string) (Session, error) {
 	return Session{}, ErrNotImplemented
 }
 
+func (p *MemoryProvider) getSharedSessions(_ SessionType) ([]Session, error) {
+	return nil, ErrNotImplemented
+}
+
 func (p *MemoryProvider) cleanupSharedSessions(_ SessionType, _ int64) error {
 	return ErrNotImplemented
 }
@@ -2864,6 +2897,82 @@ func (p *MemoryProvider) setConfigs(configs *Configs) error {
 	return nil
 }
 
+func (p *MemoryProvider) addAuditLogEntry(_ *AuditLogEntry) error {
+	return ErrNotImplemented
+}
+
+func (p *MemoryProvider) getLastAuditLogEntry(_, _ string) (AuditLogEntry, error) {
+	return AuditLogEntry{}, ErrNotImplemented
+}
+
+func (p *MemoryProvider) searchAuditLogs(_ *AuditLogSearch) ([]AuditLogEntry, error) {
+	return nil, ErrNotImplemented
+}
+
+func (p *MemoryProvider) cleanupAuditLogs(_ int64) error {
+	return ErrNotImplemented
+}
+
+func (p *MemoryProvider) addShareDownload(_ string, _ *ShareDownload) error {
+	return ErrNotImplemented
+}
+
+func (p *MemoryProvider) searchShareDownloads(_ string, _ *ShareDownloadSearch) ([]ShareDownload, error) {
+	return nil, ErrNotImplemented
+}
+
+func (p *MemoryProvider) getShareDownloadStats(_ string) (ShareDownloadStats, error) {
+	return ShareDownloadStats{}, ErrNotImplemented
+}
+
+func (p *MemoryProvider) cleanupShareDownloads(_ int64) error {
+	return ErrNotImplemented
+}
+
+func (p *MemoryProvider) addWebhookDelivery(_ *WebhookDelivery) error {
+	return ErrNotImplemented
+}
+
+func (p *MemoryProvider) getWebhookDelivery(_ string) (WebhookDelivery, error) {
+	return WebhookDelivery{}, ErrNotImplemented
+}
+
+func (p *MemoryProvider) getDueWebhookDeliveries(_ int64, _ int) ([]WebhookDelivery, error) {
+	return nil, ErrNotImplemented
+}
+
+func (p *MemoryProvider) lockWebhookDelivery(_ string, _, _ int64) (bool, error) {
+	return false, ErrNotImplemented
+}
+
+func (p *MemoryProvider) updateWebhookDelivery(_ *WebhookDelivery) error {
+	return ErrNotImplemented
+}
+
+func (p *MemoryProvider) searchWebhookDeliveries(_ *WebhookDeliverySearch) ([]WebhookDelivery, error) {
+	return nil, ErrNotImplemented
+}
+
+func (p *MemoryProvider) cleanupWebhookDeliveries(_ int64) error {
+	return ErrNotImplemented
+}
+
+func (p *MemoryProvider) getDAVProperties(_, _ string) (DAVProperties, error) {
+	return DAVProperties{}, ErrNotImplemented
+}
+
+func (p *MemoryProvider) setDAVProperties(_ *DAVProperties) error {
+	return ErrNotImplemented
+}
+
+func (p *MemoryProvider) renameDAVProperties(_, _, _ string) error {
+	return ErrNotImplemented
+}
+
+func (p *MemoryProvider) deleteDAVProperties(_, _ string, _ bool) error {
+	return ErrNotImplemented
+}
+
 func (p *MemoryProvider) setFirstDownloadTimestamp(username string) error {
 	p.dbHandle.Lock()
 	defer p.dbHandle.Unlock()
@@ -3256,8 +3365,9 @@ func (p *MemoryProvider) restoreRoles(dump *BackupData) error {
 }
 
 func (p *MemoryProvider) restoreGroups(dump *BackupData) error {
-	for idx := range dump.Groups {
-		group := dump.Groups[idx]
+	groups := OrderGroupsByInclusion(dump.Groups)
+	for idx := range groups {
+		group := groups[idx]
 		group.Name = config.convertName(group.Name)
 		g, err := p.groupExists(group.Name)
 		if err == nil {
@@ -46,6 +46,9 @@ const (
 		"DROP TABLE IF EXISTS `{{groups_folders_mapping}}` CASCADE;" +
 		"DROP TABLE IF EXISTS `{{admins}}` CASCADE;" +
 		"DROP TABLE IF EXISTS `{{folders}}` CASCADE;" +
+		"DROP TABLE IF EXISTS `{{share_downloads}}` CASCADE;" +
+		"DROP TABLE IF EXISTS `{{webhook_deliveries}}` CASCADE;" +
+		"DROP TABLE IF EXISTS `{{dav_properties}}` CASCADE;" +
 		"DROP TABLE IF EXISTS `{{shares}}` CASCADE;" +
 		"DROP TABLE IF EXISTS `{{users}}` CASCADE;" +
 		"DROP TABLE IF EXISTS `{{groups}}` CASCADE;" +
@@ -61,6 +64,7 @@ const (
 		"DROP TABLE IF EXISTS `{{roles}}` CASCADE;" +
 		"DROP TABLE IF EXISTS `{{ip_lists}}` CASCADE;" +
 		"DROP TABLE IF EXISTS `{{configs}}` CASCADE;" +
+		"DROP TABLE IF EXISTS `{{audit_logs}}` CASCADE;" +
 		"DROP TABLE IF EXISTS `{{schema_version}}` CASCADE;"
 	mysqlInitialSQL = "CREATE TABLE `{{schema_version}}` (`id` integer AUTO_INCREMENT NOT NULL PRIMARY KEY, `version` integer NOT NULL);" +
 		"CREATE TABLE `{{admins}}` (`id` integer AUTO_INCREMENT NOT NULL PRIMARY KEY, `username` varchar(255) NOT NULL UNIQUE, " +
@@ -194,6 +198,55 @@ const (
 		"CREATE INDEX `{{prefix}}ip_lists_deleted_at_idx` ON `{{ip_lists}}` (`deleted_at`);" +
 		"CREATE INDEX `{{prefix}}ip_lists_first_last_idx` ON `{{ip_lists}}` (`first`, `last`);" +
 		"INSERT INTO {{schema_version}} (version) VALUES (28);"
+	mysqlV29SQL = "ALTER TABLE `{{users_folders_mapping}}` ADD COLUMN `upload_bandwidth` integer DEFAULT 0 NOT NULL;" +
+		"ALTER TABLE `{{users_folders_mapping}}` ADD COLUMN `download_bandwidth` integer DEFAULT 0 NOT NULL;" +
+		"ALTER TABLE `{{groups_folders_mapping}}` ADD COLUMN `upload_bandwidth` integer DEFAULT 0 NOT NULL;" +
+		"ALTER TABLE `{{groups_folders_mapping}}` ADD COLUMN `download_bandwidth` integer DEFAULT 0 NOT NULL;"
+	mysqlV29DownSQL = "ALTER TABLE `{{groups_folders_mapping}}` DROP COLUMN `download_bandwidth`;" +
+		"ALTER TABLE `{{groups_folders_mapping}}` DROP COLUMN `upload_bandwidth`;" +
+		"ALTER TABLE `{{users_folders_mapping}}` DROP COLUMN `download_bandwidth`;" +
+		"ALTER TABLE `{{users_folders_mapping}}` DROP COLUMN `upload_bandwidth`;"
+	mysqlV30SQL = "CREATE TABLE `{{audit_logs}}` (`id` bigint AUTO_INCREMENT NOT NULL PRIMARY KEY, " +
+		"`date_time` bigint NOT NULL, `executor` varchar(255) NOT NULL, `ip` varchar(50) NOT NULL, " +
+		"`role` varchar(255) NULL, `operation` varchar(20) NOT NULL, `object_type` varchar(50) NOT NULL, " +
+		"`object_name` varchar(512) NOT NULL, `object_data` longtext NULL, `diff` longtext NULL);" +
+		"CREATE INDEX `{{prefix}}audit_logs_date_time_idx` ON `{{audit_logs}}` (`date_time`);" +
+		"CREATE INDEX `{{prefix}}audit_logs_object_idx` ON `{{audit_logs}}` (`object_type`, `object_name`);" +
+		"CREATE INDEX `{{prefix}}audit_logs_executor_idx` ON `{{audit_logs}}` (`executor`);"
+	mysqlV30DownSQL = "DROP TABLE `{{audit_logs}}` CASCADE;"
+	mysqlV31SQL     = "ALTER TABLE `{{users}}` ADD COLUMN `attributes` longtext NULL;" +
+		"ALTER TABLE `{{folders}}` ADD COLUMN `attributes` longtext NULL;"
+	mysqlV31DownSQL = "ALTER TABLE `{{folders}}` DROP COLUMN `attributes`;" +
+		"ALTER TABLE `{{users}}` DROP COLUMN `attributes`;"
+	mysqlV32SQL     = "ALTER TABLE `{{groups}}` ADD COLUMN `included_groups` longtext NULL;"
+	mysqlV32DownSQL = "ALTER TABLE `{{groups}}` DROP COLUMN `included_groups`;"
+	mysqlV33SQL     = "ALTER TABLE `{{api_keys}}` ADD COLUMN `filters` longtext NULL;"
+	mysqlV33DownSQL = "ALTER TABLE `{{api_keys}}` DROP COLUMN `filters`;"
+	mysqlV34SQL     = "ALTER TABLE `{{shares}}` ADD COLUMN `email_verification` longtext NULL;"
+	mysqlV34DownSQL = "ALTER TABLE `{{shares}}` DROP COLUMN `email_verification`;"
+	mysqlV35SQL     = "ALTER TABLE `{{shares}}` ADD COLUMN `file_request` longtext NULL;"
+	mysqlV35DownSQL = "ALTER TABLE `{{shares}}` DROP COLUMN `file_request`;"
+	mysqlV36SQL     = "CREATE TABLE `{{share_downloads}}` (`id` bigint AUTO_INCREMENT NOT NULL PRIMARY KEY, " +
+		"`date_time` bigint NOT NULL, `ip` varchar(50) NOT NULL, `file_path` varchar(512) NOT NULL, " +
+		"`bytes_sent` bigint NOT NULL, `share_id` integer NOT NULL);" +
+		"ALTER TABLE `{{share_downloads}}` ADD CONSTRAINT `{{prefix}}share_downloads_share_id_fk_shares_id` " +
+		"FOREIGN KEY (`share_id`) REFERENCES `{{shares}}` (`id`) ON DELETE CASCADE;" +
+		"CREATE INDEX `{{prefix}}share_downloads_date_time_idx` ON `{{share_downloads}}` (`date_time`);"
+	mysqlV36DownSQL = "DROP TABLE `{{share_downloads}}` CASCADE;"
+	mysqlV37SQL     = "CREATE TABLE `{{webhook_deliveries}}` (`id` bigint AUTO_INCREMENT NOT NULL PRIMARY KEY, " +
+		"`delivery_id` varchar(60) NOT NULL UNIQUE, `url` longtext NOT NULL, `event` varchar(100) NOT NULL, " +
+		"`payload` longtext NOT NULL, `status` integer NOT NULL, `attempts` integer NOT NULL, " +
+		"`next_attempt_at` bigint NOT NULL, `created_at` bigint NOT NULL, `updated_at` bigint NOT NULL, " +
+		"`attempts_log` longtext NULL);" +
+		"CREATE INDEX `{{prefix}}webhook_deliveries_status_next_attempt_at_idx` ON `{{webhook_deliveries}}` (`status`, `next_attempt_at`);" +
+		"CREATE INDEX `{{prefix}}webhook_deliveries_updated_at_idx` ON `{{webhook_deliveries}}` (`updated_at`);"
+	mysqlV37DownSQL = "DROP TABLE `{{webhook_deliveries}}` CASCADE;"
+	mysqlV38SQL     = "CREATE TABLE `{{dav_properties}}` (`id` bigint AUTO_INCREMENT NOT NULL PRIMARY KEY, " +
+		"`username` varchar(255) NOT NULL, `path` longtext NOT NULL, `path_hash` varchar(64) NOT NULL, " +
+		"`properties` longtext NOT NULL, `updated_at` bigint NOT NULL);" +
+		"ALTER TABLE `{{dav_properties}}` ADD CONSTRAINT `{{prefix}}unique_dav_properties_path` " +
+		"UNIQUE (`username`, `path_hash`);"
+	mysqlV38DownSQL = "DROP TABLE `{{dav_properties}}` CASCADE;"
 )
 
 // MySQLProvider defines the auth provider for MySQL/MariaDB database
@@ -399,6 +452,22 @@ func (p *MySQLProvider) deleteFolder(folder vfs.BaseVirtualFolder) error {
 	return sqlCommonDeleteFolder(folder, p.dbHandle)
 }
 
+func (p *MySQLProvider) executeUsersBatch(items []UserBatchItem) error {
+	return sqlCommonExecuteUsersBatch(items, p.dbHandle, p.normalizeError)
+}
+
+func (p *MySQLProvider) executeFoldersBatch(items []FolderBatchItem) error {
+	return sqlCommonExecuteFoldersBatch(items, p.dbHandle, p.normalizeError)
+}
+
+func (p *MySQLProvider) listUsers(opts *ListOptions) ([]User, error) {
+	return sqlCommonListUsers(opts, p.dbHandle)
+}
+
+func (p *MySQLProvider) listFolders(opts *ListOptions) ([]vfs.BaseVirtualFolder, error) {
+	return sqlCommonListFolders(opts, p.dbHandle)
+}
+
 func (p *MySQLProvider) updateFolderQuota(name string, filesAdd int, sizeAdd int64, reset bool) error {
 	return sqlCommonUpdateFolderQuota(name, filesAdd, sizeAdd, reset, p.dbHandle)
 }
@@ -495,6 +564,10 @@ func (p *MySQLProvider) updateAPIKeyLastUse(keyID string) error {
 	return sqlCommonUpdateAPIKeyLastUse(keyID, p.dbHandle)
 }
 
+func (p *MySQLProvider) rotateAPIKey(apiKey *APIKey) error {
+	return sqlCommonRotateAPIKey(apiKey, p.dbHandle)
+}
+
 func (p *MySQLProvider) shareExists(shareID, username string) (Share, error) {
 	return sqlCommonGetShareByID(shareID, username, p.dbHandle)
 }
@@ -587,6 +660,10 @@ func (p *MySQLProvider) getSharedSession(key string) (Session, error) {
 	return sqlCommonGetSession(key, p.dbHandle)
 }
 
+func (p *MySQLProvider) getSharedSessions(sessionType SessionType) ([]Session, error) {
+	return sqlCommonGetSessions(sessionType, p.dbHandle)
+}
+
 func (p *MySQLProvider) cleanupSharedSessions(sessionType SessionType, before int64) error {
 	return sqlCommonCleanupSessions(sessionType, before, p.dbHandle)
 }
@@ -747,6 +824,82 @@ func (p *MySQLProvider) setConfigs(configs *Configs) error {
 	return sqlCommonSetConfigs(configs, p.dbHandle)
 }
 
+func (p *MySQLProvider) addAuditLogEntry(entry *AuditLogEntry) error {
+	return sqlCommonAddAuditLogEntry(entry, p.dbHandle)
+}
+
+func (p *MySQLProvider) getLastAuditLogEntry(objectType, objectName string) (AuditLogEntry, error) {
+	return sqlCommonGetLastAuditLogEntry(objectType, objectName, p.dbHandle)
+}
+
+func (p *MySQLProvider) searchAuditLogs(filters *AuditLogSearch) ([]AuditLogEntry, error) {
+	return sqlCommonSearchAuditLogs(filters, p.dbHandle)
+}
+
+func (p *MySQLProvider) cleanupAuditLogs(before int64) error {
+	return sqlCommonCleanupAuditLogs(before, p.dbHandle)
+}
+
+func (p *MySQLProvider) addShareDownload(shareID string, download *ShareDownload) error {
+	return sqlCommonAddShareDownload(shareID, download, p.dbHandle)
+}
+
+func (p *MySQLProvider) searchShareDownloads(shareID string, filters *ShareDownloadSearch) ([]ShareDownload, error) {
+	return sqlCommonSearchShareDownloads(shareID, filters, p.dbHandle)
+}
+
+func (p *MySQLProvider) getShareDownloadStats(shareID string) (ShareDownloadStats, error) {
+	return sqlCommonGetShareDownloadStats(shareID, p.dbHandle)
+}
+
+func (p *MySQLProvider) cleanupShareDownloads(before int64) error {
+	return sqlCommonCleanupShareDownloads(before, p.dbHandle)
+}
+
+func (p *MySQLProvider) addWebhookDelivery(delivery *WebhookDelivery) error {
+	return sqlCommonAddWebhookDelivery(delivery, p.dbHandle)
+}
+
+func (p *MySQLProvider) getWebhookDelivery(id string) (WebhookDelivery, error) {
+	return sqlCommonGetWebhookDelivery(id, p.dbHandle)
+}
+
+func (p *MySQLProvider) getDueWebhookDeliveries(now int64, limit int) ([]WebhookDelivery, error) {
+	return sqlCommonGetDueWebhookDeliveries(now, limit, p.dbHandle)
+}
+
+func (p *MySQLProvider) lockWebhookDelivery(id string, nextAttemptAt, lockUntil int64) (bool, error) {
+	return sqlCommonLockWebhookDelivery(id, nextAttemptAt, lockUntil, p.dbHandle)
+}
+
+func (p *MySQLProvider) updateWebhookDelivery(delivery *WebhookDelivery) error {
+	return sqlCommonUpdateWebhookDelivery(delivery, p.dbHandle)
+}
+
+func (p *MySQLProvider) searchWebhookDeliveries(filters *WebhookDeliverySearch) ([]WebhookDelivery, error) {
+	return sqlCommonSearchWebhookDeliveries(filters, p.dbHandle)
+}
+
+func (p *MySQLProvider) cleanupWebhookDeliveries(before int64) error {
+	return sqlCommonCleanupWebhookDeliveries(before, p.dbHandle)
+}
+
+func (p *MySQLProvider) getDAVProperties(username, virtualPath string) (DAVProperties, error) {
+	return sqlCommonGetDAVProperties(username, virtualPath, p.dbHandle)
+}
+
+func (p *MySQLProvider) setDAVProperties(props *DAVProperties) error {
+	return sqlCommonSetDAVProperties(props, p.dbHandle)
+}
+
+func (p *MySQLProvider) renameDAVProperties(username, source, target string) error {
+	return sqlCommonRenameDAVProperties(username, source, target, p.dbHandle)
+}
+
+func (p *MySQLProvider) deleteDAVProperties(username, virtualPath string, recursive bool) error {
+	return sqlCommonDeleteDAVProperties(username, virtualPath, recursive, p.dbHandle)
+}
+
 func (p *MySQLProvider) setFirstDownloadTimestamp(username string) error {
 	return sqlCommonSetFirstDownloadTimestamp(username, p.dbHandle)
 }
@@ -794,6 +947,26 @@ func (p *MySQLProvider) migrateDatabase() error {
 		providerLog(logger.LevelError, "%v", err)
 		logger.ErrorToConsole("%v", err)
 		return err
+	case version == 28:
+		return updateMySQLDatabaseFromV28(p.dbHandle)
+	case version == 29:
+		return updateMySQLDatabaseFromV29(p.dbHandle)
+	case version == 30:
+		return updateMySQLDatabaseFromV30(p.dbHandle)
+	case version == 31:
+		return updateMySQLDatabaseFromV31(p.dbHandle)
+	case version == 32:
+		return updateMySQLDatabaseFromV32(p.dbHandle)
+	case version == 33:
+		return updateMySQLDatabaseFromV33(p.dbHandle)
+	case version == 34:
+		return updateMySQLDatabaseFromV34(p.dbHandle)
+	case version == 35:
+		return updateMySQLDatabaseFromV35(p.dbHandle)
+	case version == 36:
+		return updateMySQLDatabaseFromV36(p.dbHandle)
+	case version == 37:
+		return updateMySQLDatabaseFromV37(p.dbHandle)
 	default:
 		if version > sqlDatabaseVersion {
 			providerLog(logger.LevelError, "database schema version %d is newer than the supported one: %d", version,
@@ -816,6 +989,26 @@ func (p *MySQLProvider) revertDatabase(targetVersion int) error {
 	}
 
 	switch dbVersion.Version {
+	case 29:
+		return downgradeMySQLDatabaseFromV29(p.dbHandle)
+	case 30:
+		return downgradeMySQLDatabaseFromV30(p.dbHandle)
+	case 31:
+		return downgradeMySQLDatabaseFromV31(p.dbHandle)
+	case 32:
+		return downgradeMySQLDatabaseFromV32(p.dbHandle)
+	case 33:
+		return downgradeMySQLDatabaseFromV33(p.dbHandle)
+	case 34:
+		return downgradeMySQLDatabaseFromV34(p.dbHandle)
+	case 35:
+		return downgradeMySQLDatabaseFromV35(p.dbHandle)
+	case 36:
+		return downgradeMySQLDatabaseFromV36(p.dbHandle)
+	case 37:
+		return downgradeMySQLDatabaseFromV37(p.dbHandle)
+	case 38:
+		return downgradeMySQLDatabaseFromV38(p.dbHandle)
 	default:
 		return fmt.Errorf("database schema version not handled: %d", dbVersion.Version)
 	}
@@ -853,3 +1046,277 @@ func (p *MySQLProvider) normalizeError(err error, fieldType int) error {
 	}
 	return err
 }
+
+func updateMySQLDatabaseFromV28(dbHandle *sql.DB) error {
+	if err := updateMySQLDatabaseFrom28To29(dbHandle); err != nil {
+		return err
+	}
+	return updateMySQLDatabaseFromV29(dbHandle)
+}
+
+func updateMySQLDatabaseFromV29(dbHandle *sql.DB) error {
+	if err := updateMySQLDatabaseFrom29To30(dbHandle); err != nil {
+		return err
+	}
+	return updateMySQLDatabaseFromV30(dbHandle)
+}
+
+func updateMySQLDatabaseFromV30(dbHandle *sql.DB) error {
+	if err := updateMySQLDatabaseFrom30To31(dbHandle); err != nil {
+		return err
+	}
+	return updateMySQLDatabaseFromV31(dbHandle)
+}
+
+func updateMySQLDatabaseFromV31(dbHandle *sql.DB) error {
+	if err := updateMySQLDatabaseFrom31To32(dbHandle); err != nil {
+		return err
+	}
+	return updateMySQLDatabaseFromV32(dbHandle)
+}
+
+func updateMySQLDatabaseFromV32(dbHandle *sql.DB) error {
+	if err := updateMySQLDatabaseFrom32To33(dbHandle); err != nil {
+		return err
+	}
+	return updateMySQLDatabaseFromV33(dbHandle)
+}
+
+func updateMySQLDatabaseFromV33(dbHandle *sql.DB) error {
+	if err := updateMySQLDatabaseFrom33To34(dbHandle); err != nil {
+		return err
+	}
+	return updateMySQLDatabaseFromV34(dbHandle)
+}
+
+func updateMySQLDatabaseFromV34(dbHandle *sql.DB) error {
+	if err := updateMySQLDatabaseFrom34To35(dbHandle); err != nil {
+		return err
+	}
+	return updateMySQLDatabaseFromV35(dbHandle)
+}
+
+func updateMySQLDatabaseFromV35(dbHandle *sql.DB) error {
+	if err := updateMySQLDatabaseFrom35To36(dbHandle); err != nil {
+		return err
+	}
+	return updateMySQLDatabaseFromV36(dbHandle)
+}
+
+func updateMySQLDatabaseFromV36(dbHandle *sql.DB) error {
+	if err := updateMySQLDatabaseFrom36To37(dbHandle); err != nil {
+		return err
+	}
+	return updateMySQLDatabaseFromV37(dbHandle)
+}
+
+func updateMySQLDatabaseFromV37(dbHandle *sql.DB) error {
+	return updateMySQLDatabaseFrom37To38(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV29(dbHandle *sql.DB) error {
+	return downgradeMySQLDatabaseFrom29To28(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV30(dbHandle *sql.DB) error {
+	if err := downgradeMySQLDatabaseFrom30To29(dbHandle); err != nil {
+		return err
+	}
+	return downgradeMySQLDatabaseFromV29(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV31(dbHandle *sql.DB) error {
+	if err := downgradeMySQLDatabaseFrom31To30(dbHandle); err != nil {
+		return err
+	}
+	return downgradeMySQLDatabaseFromV30(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV32(dbHandle *sql.DB) error {
+	if err := downgradeMySQLDatabaseFrom32To31(dbHandle); err != nil {
+		return err
+	}
+	return downgradeMySQLDatabaseFromV31(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV33(dbHandle *sql.DB) error {
+	if err := downgradeMySQLDatabaseFrom33To32(dbHandle); err != nil {
+		return err
+	}
+	return downgradeMySQLDatabaseFromV32(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV34(dbHandle *sql.DB) error {
+	if err := downgradeMySQLDatabaseFrom34To33(dbHandle); err != nil {
+		return err
+	}
+	return downgradeMySQLDatabaseFromV33(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV35(dbHandle *sql.DB) error {
+	if err := downgradeMySQLDatabaseFrom35To34(dbHandle); err != nil {
+		return err
+	}
+	return downgradeMySQLDatabaseFromV34(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV36(dbHandle *sql.DB) error {
+	if err := downgradeMySQLDatabaseFrom36To35(dbHandle); err != nil {
+		return err
+	}
+	return downgradeMySQLDatabaseFromV35(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV37(dbHandle *sql.DB) error {
+	if err := downgradeMySQLDatabaseFrom37To36(dbHandle); err != nil {
+		return err
+	}
+	return downgradeMySQLDatabaseFromV36(dbHandle)
+}
+
+func downgradeMySQLDatabaseFromV38(dbHandle *sql.DB) error {
+	if err := downgradeMySQLDatabaseFrom38To37(dbHandle); err != nil {
+		return err
+	}
+	return downgradeMySQLDatabaseFromV37(dbHandle)
+}
+
+func updateMySQLDatabaseFrom28To29(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 28 -> 29")
+	providerLog(logger.LevelInfo, "updating database schema version: 28 -> 29")
+	sql := sqlReplaceAll(mysqlV29SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 29, true)
+}
+
+func downgradeMySQLDatabaseFrom29To28(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 29 -> 28")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 29 -> 28")
+	sql := sqlReplaceAll(mysqlV29DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 28, false)
+}
+
+func updateMySQLDatabaseFrom29To30(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 29 -> 30")
+	providerLog(logger.LevelInfo, "updating database schema version: 29 -> 30")
+	sql := sqlReplaceAll(mysqlV30SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 30, true)
+}
+
+func downgradeMySQLDatabaseFrom30To29(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 30 -> 29")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 30 -> 29")
+	sql := sqlReplaceAll(mysqlV30DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 29, false)
+}
+
+func updateMySQLDatabaseFrom30To31(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 30 -> 31")
+	providerLog(logger.LevelInfo, "updating database schema version: 30 -> 31")
+	sql := sqlReplaceAll(mysqlV31SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 31, true)
+}
+
+func downgradeMySQLDatabaseFrom31To30(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 31 -> 30")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 31 -> 30")
+	sql := sqlReplaceAll(mysqlV31DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 30, false)
+}
+
+func updateMySQLDatabaseFrom31To32(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 31 -> 32")
+	providerLog(logger.LevelInfo, "updating database schema version: 31 -> 32")
+	sql := sqlReplaceAll(mysqlV32SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 32, true)
+}
+
+func downgradeMySQLDatabaseFrom32To31(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 32 -> 31")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 32 -> 31")
+	sql := sqlReplaceAll(mysqlV32DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 31, false)
+}
+
+func updateMySQLDatabaseFrom32To33(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 32 -> 33")
+	providerLog(logger.LevelInfo, "updating database schema version: 32 -> 33")
+	sql := sqlReplaceAll(mysqlV33SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 33, true)
+}
+
+func downgradeMySQLDatabaseFrom33To32(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 33 -> 32")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 33 -> 32")
+	sql := sqlReplaceAll(mysqlV33DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 32, false)
+}
+
+func updateMySQLDatabaseFrom33To34(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 33 -> 34")
+	providerLog(logger.LevelInfo, "updating database schema version: 33 -> 34")
+	sql := sqlReplaceAll(mysqlV34SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 34, true)
+}
+
+func downgradeMySQLDatabaseFrom34To33(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 34 -> 33")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 34 -> 33")
+	sql := sqlReplaceAll(mysqlV34DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 33, false)
+}
+
+func updateMySQLDatabaseFrom34To35(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 34 -> 35")
+	providerLog(logger.LevelInfo, "updating database schema version: 34 -> 35")
+	sql := sqlReplaceAll(mysqlV35SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 35, true)
+}
+
+func downgradeMySQLDatabaseFrom35To34(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 35 -> 34")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 35 -> 34")
+	sql := sqlReplaceAll(mysqlV35DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 34, false)
+}
+
+func updateMySQLDatabaseFrom35To36(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 35 -> 36")
+	providerLog(logger.LevelInfo, "updating database schema version: 35 -> 36")
+	sql := sqlReplaceAll(mysqlV36SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 36, true)
+}
+
+func downgradeMySQLDatabaseFrom36To35(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 36 -> 35")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 36 -> 35")
+	sql := sqlReplaceAll(mysqlV36DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 35, false)
+}
+
+func updateMySQLDatabaseFrom36To37(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 36 -> 37")
+	providerLog(logger.LevelInfo, "updating database schema version: 36 -> 37")
+	sql := sqlReplaceAll(mysqlV37SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 37, true)
+}
+
+func downgradeMySQLDatabaseFrom37To36(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 37 -> 36")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 37 -> 36")
+	sql := sqlReplaceAll(mysqlV37DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 36, false)
+}
+
+func updateMySQLDatabaseFrom37To38(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 37 -> 38")
+	providerLog(logger.LevelInfo, "updating database schema version: 37 -> 38")
+	sql := sqlReplaceAll(mysqlV38SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 38, true)
+}
+
+func downgradeMySQLDatabaseFrom38To37(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 38 -> 37")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 38 -> 37")
+	sql := sqlReplaceAll(mysqlV38DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, strings.Split(sql, ";"), 37, false)
+}
@@ -0,0 +1,268 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+// Supported sort fields for keyset paginated listings.
+// The values match the database column names
+const (
+	SortFieldUsername      = "username"
+	SortFieldName          = "name"
+	SortFieldCreatedAt     = "created_at"
+	SortFieldUpdatedAt     = "updated_at"
+	SortFieldLastLogin     = "last_login"
+	SortFieldUsedQuotaSize = "used_quota_size"
+)
+
+var (
+	userSortFields   = []string{SortFieldUsername, SortFieldCreatedAt, SortFieldUpdatedAt, SortFieldLastLogin}
+	folderSortFields = []string{SortFieldName, SortFieldUsedQuotaSize}
+)
+
+// ListCursor identifies the last item returned by a keyset paginated listing,
+// the next page starts after this item
+type ListCursor struct {
+	// Value of the sort field, unused if sorting by username/name
+	Value int64 `json:"v,omitempty"`
+	// Username/name of the last item, it is unique and so it is used as tie-breaker
+	Key string `json:"k"`
+}
+
+// ListOptions defines the options for a keyset paginated listing
+type ListOptions struct {
+	Limit  int
+	Order  string
+	SortBy string
+	// Nil to get the first page
+	After *ListCursor
+	// Restrict the users to the specified role
+	Role string
+}
+
+func (o *ListOptions) validate(sortFields []string) error {
+	if o.Limit <= 0 {
+		return util.NewValidationError(fmt.Sprintf("invalid limit: %d", o.Limit))
+	}
+	if o.Order == "" {
+		o.Order = OrderASC
+	}
+	if o.Order != OrderASC && o.Order != OrderDESC {
+		return util.NewValidationError(fmt.Sprintf("invalid order %q", o.Order))
+	}
+	if o.SortBy == "" {
+		o.SortBy = sortFields[0]
+	}
+	if !util.Contains(sortFields, o.SortBy) {
+		return util.NewValidationError(fmt.Sprintf("invalid sort field %q, supported: %s", o.SortBy,
+			strings.Join(sortFields, ", ")))
+	}
+	return nil
+}
+
+// compare compares two items taking into account the sort field and the order.
+// It returns a negative number if the first item comes first
+func (o *ListOptions) compare(value1 int64, key1 string, value2 int64, key2 string, keyField string) int {
+	res := 0
+	if o.SortBy != keyField {
+		switch {
+		case value1 < value2:
+			res = -1
+		case value1 > value2:
+			res = 1
+		}
+	}
+	if res == 0 {
+		res = strings.Compare(key1, key2)
+	}
+	if o.Order == OrderDESC {
+		return -res
+	}
+	return res
+}
+
+// isAfterCursor returns true if the item comes after the cursor
+func (o *ListOptions) isAfterCursor(value int64, key string, keyField string) bool {
+	if o.After == nil {
+		return true
+	}
+	return o.compare(value, key, o.After.Value, o.After.Key, keyField) > 0
+}
+
+// keysetProvider is implemented by the providers able to execute keyset
+// paginated listings natively. The options are already validated
+type keysetProvider interface {
+	listUsers(opts *ListOptions) ([]User, error)
+	listFolders(opts *ListOptions) ([]vfs.BaseVirtualFolder, error)
+}
+
+// GetUserSortFields returns the supported sort fields for the users
+func GetUserSortFields() []string {
+	return userSortFields
+}
+
+// GetFolderSortFields returns the supported sort fields for the virtual folders
+func GetFolderSortFields() []string {
+	return folderSortFields
+}
+
+func getUserSortValue(user *User, sortBy string) int64 {
+	switch sortBy {
+	case SortFieldCreatedAt:
+		return user.CreatedAt
+	case SortFieldUpdatedAt:
+		return user.UpdatedAt
+	case SortFieldLastLogin:
+		return user.LastLogin
+	default:
+		return 0
+	}
+}
+
+func getFolderSortValue(folder *vfs.BaseVirtualFolder, sortBy string) int64 {
+	if sortBy == SortFieldUsedQuotaSize {
+		return folder.UsedQuotaSize
+	}
+	return 0
+}
+
+// ListUsers returns up to opts.Limit users, ready for rendering, and the cursor
+// for the next page, nil if there are no more users.
+// Data providers not supporting keyset pagination natively will load all the
+// users in memory for each page after the first one
+func ListUsers(opts ListOptions) ([]User, *ListCursor, error) {
+	if err := opts.validate(userSortFields); err != nil {
+		return nil, nil, err
+	}
+	limit := opts.Limit
+	// fetch an additional user to know if there is a next page
+	opts.Limit++
+	var users []User
+	var err error
+	if p, ok := provider.(keysetProvider); ok {
+		users, err = p.listUsers(&opts)
+	} else {
+		users, err = listUsersInMemory(&opts)
+	}
+	if err != nil {
+		return users, nil, err
+	}
+	if len(users) <= limit {
+		return users, nil, nil
+	}
+	users = users[:limit]
+	last := &users[limit-1]
+	return users, &ListCursor{Value: getUserSortValue(last, opts.SortBy), Key: last.Username}, nil
+}
+
+// ListFolders returns up to opts.Limit virtual folders, ready for rendering,
+// and the cursor for the next page, nil if there are no more folders
+func ListFolders(opts ListOptions) ([]vfs.BaseVirtualFolder, *ListCursor, error) {
+	if err := opts.validate(folderSortFields); err != nil {
+		return nil, nil, err
+	}
+	limit := opts.Limit
+	opts.Limit++
+	var folders []vfs.BaseVirtualFolder
+	var err error
+	if p, ok := provider.(keysetProvider); ok {
+		folders, err = p.listFolders(&opts)
+	} else {
+		folders, err = listFoldersInMemory(&opts)
+	}
+	if err != nil {
+		return folders, nil, err
+	}
+	if len(folders) <= limit {
+		return folders, nil, nil
+	}
+	folders = folders[:limit]
+	last := &folders[limit-1]
+	return folders, &ListCursor{Value: getFolderSortValue(last, opts.SortBy), Key: last.Name}, nil
+}
+
+func listUsersInMemory(opts *ListOptions) ([]User, error) {
+	if opts.After == nil && opts.SortBy == SortFieldUsername {
+		return provider.getUsers(opts.Limit, 0, opts.Order, opts.Role)
+	}
+	users, err := provider.dumpUsers()
+	if err != nil {
+		return nil, err
+	}
+	filtered := users[:0]
+	for idx := range users {
+		u := &users[idx]
+		if !u.hasRole(opts.Role) {
+			continue
+		}
+		if !opts.isAfterCursor(getUserSortValue(u, opts.SortBy), u.Username, SortFieldUsername) {
+			continue
+		}
+		filtered = append(filtered, *u)
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		return opts.compare(getUserSortValue(&filtered[i], opts.SortBy), filtered[i].Username,
+			getUserSortValue(&filtered[j], opts.SortBy), filtered[j].Username, SortFieldUsername) < 0
+	})
+	if len(filtered) > opts.Limit {
+		filtered = filtered[:opts.Limit]
+	}
+	result := make([]User, 0, len(filtered))
+	for idx := range filtered {
+		user := filtered[idx].getACopy()
+		user.PrepareForRendering()
+		result = append(result, user)
+	}
+	return result, nil
+}
+
+func listFoldersInMemory(opts *ListOptions) ([]vfs.BaseVirtualFolder, error) {
+	if opts.After == nil && opts.SortBy == SortFieldName {
+		return provider.getFolders(opts.Limit, 0, opts.Order, false)
+	}
+	folders, err := provider.dumpFolders()
+	if err != nil {
+		return nil, err
+	}
+	filtered := folders[:0]
+	for idx := range folders {
+		f := &folders[idx]
+		if !opts.isAfterCursor(getFolderSortValue(f, opts.SortBy), f.Name, SortFieldName) {
+			continue
+		}
+		filtered = append(filtered, *f)
+	}
+	sort.Slice(filtered, func(i, j int) bool {
+		return opts.compare(getFolderSortValue(&filtered[i], opts.SortBy), filtered[i].Name,
+			getFolderSortValue(&filtered[j], opts.SortBy), filtered[j].Name, SortFieldName) < 0
+	})
+	if len(filtered) > opts.Limit {
+		filtered = filtered[:opts.Limit]
+	}
+	result := make([]vfs.BaseVirtualFolder, 0, len(filtered))
+	for idx := range filtered {
+		folder := filtered[idx].GetACopy()
+		folder.PrepareForRendering()
+		result = append(result, folder)
+	}
+	return result, nil
+}
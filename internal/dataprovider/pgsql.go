@@ -47,6 +47,9 @@ DROP TABLE IF EXISTS "{{admins_groups_mapping}}" CASCADE;
 DROP TABLE IF EXISTS "{{groups_folders_mapping}}" CASCADE;
 DROP TABLE IF EXISTS "{{admins}}" CASCADE;
 DROP TABLE IF EXISTS "{{folders}}" CASCADE;
+DROP TABLE IF EXISTS "{{share_downloads}}" CASCADE;
+DROP TABLE IF EXISTS "{{webhook_deliveries}}" CASCADE;
+DROP TABLE IF EXISTS "{{dav_properties}}" CASCADE;
 DROP TABLE IF EXISTS "{{shares}}" CASCADE;
 DROP TABLE IF EXISTS "{{users}}" CASCADE;
 DROP TABLE IF EXISTS "{{groups}}" CASCADE;
@@ -62,6 +65,7 @@ DROP TABLE IF EXISTS "{{nodes}}" CASCADE;
 DROP TABLE IF EXISTS "{{roles}}" CASCADE;
 DROP TABLE IF EXISTS "{{ip_lists}}" CASCADE;
 DROP TABLE IF EXISTS "{{configs}}" CASCADE;
+DROP TABLE IF EXISTS "{{audit_logs}}" CASCADE;
 DROP TABLE IF EXISTS "{{schema_version}}" CASCADE;
 `
 	pgsqlInitial = `CREATE TABLE "{{schema_version}}" ("id" integer NOT NULL PRIMARY KEY GENERATED ALWAYS AS IDENTITY, "version" integer NOT NULL);
@@ -209,6 +213,74 @@ INSERT INTO {{schema_version}} (version) VALUES (28);
 `
 	// not supported in CockroachDB
 	ipListsLikeIndex = `CREATE INDEX "{{prefix}}ip_lists_ipornet_like_idx" ON "{{ip_lists}}" ("ipornet" varchar_pattern_ops);`
+	pgsqlV29SQL      = `ALTER TABLE "{{users_folders_mapping}}" ADD COLUMN "upload_bandwidth" integer DEFAULT 0 NOT NULL;
+ALTER TABLE "{{users_folders_mapping}}" ADD COLUMN "download_bandwidth" integer DEFAULT 0 NOT NULL;
+ALTER TABLE "{{groups_folders_mapping}}" ADD COLUMN "upload_bandwidth" integer DEFAULT 0 NOT NULL;
+ALTER TABLE "{{groups_folders_mapping}}" ADD COLUMN "download_bandwidth" integer DEFAULT 0 NOT NULL;
+`
+	pgsqlV29DownSQL = `ALTER TABLE "{{groups_folders_mapping}}" DROP COLUMN "download_bandwidth" CASCADE;
+ALTER TABLE "{{groups_folders_mapping}}" DROP COLUMN "upload_bandwidth" CASCADE;
+ALTER TABLE "{{users_folders_mapping}}" DROP COLUMN "download_bandwidth" CASCADE;
+ALTER TABLE "{{users_folders_mapping}}" DROP COLUMN "upload_bandwidth" CASCADE;
+`
+	pgsqlV30SQL = `CREATE TABLE "{{audit_logs}}" ("id" bigint NOT NULL PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+"date_time" bigint NOT NULL, "executor" varchar(255) NOT NULL, "ip" varchar(50) NOT NULL, "role" varchar(255) NULL,
+"operation" varchar(20) NOT NULL, "object_type" varchar(50) NOT NULL, "object_name" varchar(512) NOT NULL,
+"object_data" text NULL, "diff" text NULL);
+CREATE INDEX "{{prefix}}audit_logs_date_time_idx" ON "{{audit_logs}}" ("date_time");
+CREATE INDEX "{{prefix}}audit_logs_object_idx" ON "{{audit_logs}}" ("object_type", "object_name");
+CREATE INDEX "{{prefix}}audit_logs_executor_idx" ON "{{audit_logs}}" ("executor");
+`
+	pgsqlV30DownSQL = `DROP TABLE "{{audit_logs}}" CASCADE;
+`
+	pgsqlV31SQL = `ALTER TABLE "{{users}}" ADD COLUMN "attributes" text NULL;
+ALTER TABLE "{{folders}}" ADD COLUMN "attributes" text NULL;
+`
+	pgsqlV31DownSQL = `ALTER TABLE "{{folders}}" DROP COLUMN "attributes" CASCADE;
+ALTER TABLE "{{users}}" DROP COLUMN "attributes" CASCADE;
+`
+	pgsqlV32SQL = `ALTER TABLE "{{groups}}" ADD COLUMN "included_groups" text NULL;
+`
+	pgsqlV32DownSQL = `ALTER TABLE "{{groups}}" DROP COLUMN "included_groups" CASCADE;
+`
+	pgsqlV33SQL = `ALTER TABLE "{{api_keys}}" ADD COLUMN "filters" text NULL;
+`
+	pgsqlV33DownSQL = `ALTER TABLE "{{api_keys}}" DROP COLUMN "filters" CASCADE;
+`
+	pgsqlV34SQL = `ALTER TABLE "{{shares}}" ADD COLUMN "email_verification" text NULL;
+`
+	pgsqlV34DownSQL = `ALTER TABLE "{{shares}}" DROP COLUMN "email_verification" CASCADE;
+`
+	pgsqlV35SQL = `ALTER TABLE "{{shares}}" ADD COLUMN "file_request" text NULL;
+`
+	pgsqlV35DownSQL = `ALTER TABLE "{{shares}}" DROP COLUMN "file_request" CASCADE;
+`
+	pgsqlV36SQL = `CREATE TABLE "{{share_downloads}}" ("id" bigint NOT NULL PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+"date_time" bigint NOT NULL, "ip" varchar(50) NOT NULL, "file_path" varchar(512) NOT NULL,
+"bytes_sent" bigint NOT NULL, "share_id" integer NOT NULL);
+ALTER TABLE "{{share_downloads}}" ADD CONSTRAINT "{{prefix}}share_downloads_share_id_fk_shares_id" FOREIGN KEY ("share_id")
+REFERENCES "{{shares}}" ("id") MATCH SIMPLE ON UPDATE NO ACTION ON DELETE CASCADE;
+CREATE INDEX "{{prefix}}share_downloads_share_id_idx" ON "{{share_downloads}}" ("share_id");
+CREATE INDEX "{{prefix}}share_downloads_date_time_idx" ON "{{share_downloads}}" ("date_time");
+`
+	pgsqlV36DownSQL = `DROP TABLE "{{share_downloads}}" CASCADE;
+`
+	pgsqlV37SQL = `CREATE TABLE "{{webhook_deliveries}}" ("id" bigint NOT NULL PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+"delivery_id" varchar(60) NOT NULL UNIQUE, "url" text NOT NULL, "event" varchar(100) NOT NULL, "payload" text NOT NULL,
+"status" integer NOT NULL, "attempts" integer NOT NULL, "next_attempt_at" bigint NOT NULL, "created_at" bigint NOT NULL,
+"updated_at" bigint NOT NULL, "attempts_log" text NULL);
+CREATE INDEX "{{prefix}}webhook_deliveries_status_next_attempt_at_idx" ON "{{webhook_deliveries}}" ("status", "next_attempt_at");
+CREATE INDEX "{{prefix}}webhook_deliveries_updated_at_idx" ON "{{webhook_deliveries}}" ("updated_at");
+`
+	pgsqlV37DownSQL = `DROP TABLE "{{webhook_deliveries}}" CASCADE;
+`
+	pgsqlV38SQL = `CREATE TABLE "{{dav_properties}}" ("id" bigint NOT NULL PRIMARY KEY GENERATED ALWAYS AS IDENTITY,
+"username" varchar(255) NOT NULL, "path" text NOT NULL, "path_hash" varchar(64) NOT NULL, "properties" text NOT NULL,
+"updated_at" bigint NOT NULL);
+ALTER TABLE "{{dav_properties}}" ADD CONSTRAINT "{{prefix}}unique_dav_properties_path" UNIQUE ("username", "path_hash");
+`
+	pgsqlV38DownSQL = `DROP TABLE "{{dav_properties}}" CASCADE;
+`
 )
 
 // PGSQLProvider defines the auth provider for PostgreSQL database
@@ -412,6 +484,22 @@ func (p *PGSQLProvider) deleteFolder(folder vfs.BaseVirtualFolder) error {
 	return sqlCommonDeleteFolder(folder, p.dbHandle)
 }
 
+func (p *PGSQLProvider) executeUsersBatch(items []UserBatchItem) error {
+	return sqlCommonExecuteUsersBatch(items, p.dbHandle, p.normalizeError)
+}
+
+func (p *PGSQLProvider) executeFoldersBatch(items []FolderBatchItem) error {
+	return sqlCommonExecuteFoldersBatch(items, p.dbHandle, p.normalizeError)
+}
+
+func (p *PGSQLProvider) listUsers(opts *ListOptions) ([]User, error) {
+	return sqlCommonListUsers(opts, p.dbHandle)
+}
+
+func (p *PGSQLProvider) listFolders(opts *ListOptions) ([]vfs.BaseVirtualFolder, error) {
+	return sqlCommonListFolders(opts, p.dbHandle)
+}
+
 func (p *PGSQLProvider) updateFolderQuota(name string, filesAdd int, sizeAdd int64, reset bool) error {
 	return sqlCommonUpdateFolderQuota(name, filesAdd, sizeAdd, reset, p.dbHandle)
 }
@@ -508,6 +596,10 @@ func (p *PGSQLProvider) updateAPIKeyLastUse(keyID string) error {
 	return sqlCommonUpdateAPIKeyLastUse(keyID, p.dbHandle)
 }
 
+func (p *PGSQLProvider) rotateAPIKey(apiKey *APIKey) error {
+	return sqlCommonRotateAPIKey(apiKey, p.dbHandle)
+}
+
 func (p *PGSQLProvider) shareExists(shareID, username string) (Share, error) {
 	return sqlCommonGetShareByID(shareID, username, p.dbHandle)
 }
@@ -600,6 +692,10 @@ func (p *PGSQLProvider) getSharedSession(key string) (Session, error) {
 	return sqlCommonGetSession(key, p.dbHandle)
 }
 
+func (p *PGSQLProvider) getSharedSessions(sessionType SessionType) ([]Session, error) {
+	return sqlCommonGetSessions(sessionType, p.dbHandle)
+}
+
 func (p *PGSQLProvider) cleanupSharedSessions(sessionType SessionType, before int64) error {
 	return sqlCommonCleanupSessions(sessionType, before, p.dbHandle)
 }
@@ -760,6 +856,82 @@ func (p *PGSQLProvider) setConfigs(configs *Configs) error {
 	return sqlCommonSetConfigs(configs, p.dbHandle)
 }
 
+func (p *PGSQLProvider) addAuditLogEntry(entry *AuditLogEntry) error {
+	return sqlCommonAddAuditLogEntry(entry, p.dbHandle)
+}
+
+func (p *PGSQLProvider) getLastAuditLogEntry(objectType, objectName string) (AuditLogEntry, error) {
+	return sqlCommonGetLastAuditLogEntry(objectType, objectName, p.dbHandle)
+}
+
+func (p *PGSQLProvider) searchAuditLogs(filters *AuditLogSearch) ([]AuditLogEntry, error) {
+	return sqlCommonSearchAuditLogs(filters, p.dbHandle)
+}
+
+func (p *PGSQLProvider) cleanupAuditLogs(before int64) error {
+	return sqlCommonCleanupAuditLogs(before, p.dbHandle)
+}
+
+func (p *PGSQLProvider) addShareDownload(shareID string, download *ShareDownload) error {
+	return sqlCommonAddShareDownload(shareID, download, p.dbHandle)
+}
+
+func (p *PGSQLProvider) searchShareDownloads(shareID string, filters *ShareDownloadSearch) ([]ShareDownload, error) {
+	return sqlCommonSearchShareDownloads(shareID, filters, p.dbHandle)
+}
+
+func (p *PGSQLProvider) getShareDownloadStats(shareID string) (ShareDownloadStats, error) {
+	return sqlCommonGetShareDownloadStats(shareID, p.dbHandle)
+}
+
+func (p *PGSQLProvider) cleanupShareDownloads(before int64) error {
+	return sqlCommonCleanupShareDownloads(before, p.dbHandle)
+}
+
+func (p *PGSQLProvider) addWebhookDelivery(delivery *WebhookDelivery) error {
+	return sqlCommonAddWebhookDelivery(delivery, p.dbHandle)
+}
+
+func (p *PGSQLProvider) getWebhookDelivery(id string) (WebhookDelivery, error) {
+	return sqlCommonGetWebhookDelivery(id, p.dbHandle)
+}
+
+func (p *PGSQLProvider) getDueWebhookDeliveries(now int64, limit int) ([]WebhookDelivery, error) {
+	return sqlCommonGetDueWebhookDeliveries(now, limit, p.dbHandle)
+}
+
+func (p *PGSQLProvider) lockWebhookDelivery(id string, nextAttemptAt, lockUntil int64) (bool, error) {
+	return sqlCommonLockWebhookDelivery(id, nextAttemptAt, lockUntil, p.dbHandle)
+}
+
+func (p *PGSQLProvider) updateWebhookDelivery(delivery *WebhookDelivery) error {
+	return sqlCommonUpdateWebhookDelivery(delivery, p.dbHandle)
+}
+
+func (p *PGSQLProvider) searchWebhookDeliveries(filters *WebhookDeliverySearch) ([]WebhookDelivery, error) {
+	return sqlCommonSearchWebhookDeliveries(filters, p.dbHandle)
+}
+
+func (p *PGSQLProvider) cleanupWebhookDeliveries(before int64) error {
+	return sqlCommonCleanupWebhookDeliveries(before, p.dbHandle)
+}
+
+func (p *PGSQLProvider) getDAVProperties(username, virtualPath string) (DAVProperties, error) {
+	return sqlCommonGetDAVProperties(username, virtualPath, p.dbHandle)
+}
+
+func (p *PGSQLProvider) setDAVProperties(props *DAVProperties) error {
+	return sqlCommonSetDAVProperties(props, p.dbHandle)
+}
+
+func (p *PGSQLProvider) renameDAVProperties(username, source, target string) error {
+	return sqlCommonRenameDAVProperties(username, source, target, p.dbHandle)
+}
+
+func (p *PGSQLProvider) deleteDAVProperties(username, virtualPath string, recursive bool) error {
+	return sqlCommonDeleteDAVProperties(username, virtualPath, recursive, p.dbHandle)
+}
+
 func (p *PGSQLProvider) setFirstDownloadTimestamp(username string) error {
 	return sqlCommonSetFirstDownloadTimestamp(username, p.dbHandle)
 }
@@ -813,6 +985,26 @@ func (p *PGSQLProvider) migrateDatabase() error { //nolint:dupl
 		providerLog(logger.LevelError, "%v", err)
 		logger.ErrorToConsole("%v", err)
 		return err
+	case version == 28:
+		return updatePGSQLDatabaseFromV28(p.dbHandle)
+	case version == 29:
+		return updatePGSQLDatabaseFromV29(p.dbHandle)
+	case version == 30:
+		return updatePGSQLDatabaseFromV30(p.dbHandle)
+	case version == 31:
+		return updatePGSQLDatabaseFromV31(p.dbHandle)
+	case version == 32:
+		return updatePGSQLDatabaseFromV32(p.dbHandle)
+	case version == 33:
+		return updatePGSQLDatabaseFromV33(p.dbHandle)
+	case version == 34:
+		return updatePGSQLDatabaseFromV34(p.dbHandle)
+	case version == 35:
+		return updatePGSQLDatabaseFromV35(p.dbHandle)
+	case version == 36:
+		return updatePGSQLDatabaseFromV36(p.dbHandle)
+	case version == 37:
+		return updatePGSQLDatabaseFromV37(p.dbHandle)
 	default:
 		if version > sqlDatabaseVersion {
 			providerLog(logger.LevelError, "database schema version %d is newer than the supported one: %d", version,
@@ -835,6 +1027,26 @@ func (p *PGSQLProvider) revertDatabase(targetVersion int) error {
 	}
 
 	switch dbVersion.Version {
+	case 29:
+		return downgradePGSQLDatabaseFromV29(p.dbHandle)
+	case 30:
+		return downgradePGSQLDatabaseFromV30(p.dbHandle)
+	case 31:
+		return downgradePGSQLDatabaseFromV31(p.dbHandle)
+	case 32:
+		return downgradePGSQLDatabaseFromV32(p.dbHandle)
+	case 33:
+		return downgradePGSQLDatabaseFromV33(p.dbHandle)
+	case 34:
+		return downgradePGSQLDatabaseFromV34(p.dbHandle)
+	case 35:
+		return downgradePGSQLDatabaseFromV35(p.dbHandle)
+	case 36:
+		return downgradePGSQLDatabaseFromV36(p.dbHandle)
+	case 37:
+		return downgradePGSQLDatabaseFromV37(p.dbHandle)
+	case 38:
+		return downgradePGSQLDatabaseFromV38(p.dbHandle)
 	default:
 		return fmt.Errorf("database schema version not handled: %d", dbVersion.Version)
 	}
@@ -872,3 +1084,277 @@ func (p *PGSQLProvider) normalizeError(err error, fieldType int) error {
 	}
 	return err
 }
+
+func updatePGSQLDatabaseFromV28(dbHandle *sql.DB) error {
+	if err := updatePGSQLDatabaseFrom28To29(dbHandle); err != nil {
+		return err
+	}
+	return updatePGSQLDatabaseFromV29(dbHandle)
+}
+
+func updatePGSQLDatabaseFromV29(dbHandle *sql.DB) error {
+	if err := updatePGSQLDatabaseFrom29To30(dbHandle); err != nil {
+		return err
+	}
+	return updatePGSQLDatabaseFromV30(dbHandle)
+}
+
+func updatePGSQLDatabaseFromV30(dbHandle *sql.DB) error {
+	if err := updatePGSQLDatabaseFrom30To31(dbHandle); err != nil {
+		return err
+	}
+	return updatePGSQLDatabaseFromV31(dbHandle)
+}
+
+func updatePGSQLDatabaseFromV31(dbHandle *sql.DB) error {
+	if err := updatePGSQLDatabaseFrom31To32(dbHandle); err != nil {
+		return err
+	}
+	return updatePGSQLDatabaseFromV32(dbHandle)
+}
+
+func updatePGSQLDatabaseFromV32(dbHandle *sql.DB) error {
+	if err := updatePGSQLDatabaseFrom32To33(dbHandle); err != nil {
+		return err
+	}
+	return updatePGSQLDatabaseFromV33(dbHandle)
+}
+
+func updatePGSQLDatabaseFromV33(dbHandle *sql.DB) error {
+	if err := updatePGSQLDatabaseFrom33To34(dbHandle); err != nil {
+		return err
+	}
+	return updatePGSQLDatabaseFromV34(dbHandle)
+}
+
+func updatePGSQLDatabaseFromV34(dbHandle *sql.DB) error {
+	if err := updatePGSQLDatabaseFrom34To35(dbHandle); err != nil {
+		return err
+	}
+	return updatePGSQLDatabaseFromV35(dbHandle)
+}
+
+func updatePGSQLDatabaseFromV35(dbHandle *sql.DB) error {
+	if err := updatePGSQLDatabaseFrom35To36(dbHandle); err != nil {
+		return err
+	}
+	return updatePGSQLDatabaseFromV36(dbHandle)
+}
+
+func updatePGSQLDatabaseFromV36(dbHandle *sql.DB) error {
+	if err := updatePGSQLDatabaseFrom36To37(dbHandle); err != nil {
+		return err
+	}
+	return updatePGSQLDatabaseFromV37(dbHandle)
+}
+
+func updatePGSQLDatabaseFromV37(dbHandle *sql.DB) error {
+	return updatePGSQLDatabaseFrom37To38(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV29(dbHandle *sql.DB) error {
+	return downgradePGSQLDatabaseFrom29To28(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV30(dbHandle *sql.DB) error {
+	if err := downgradePGSQLDatabaseFrom30To29(dbHandle); err != nil {
+		return err
+	}
+	return downgradePGSQLDatabaseFromV29(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV31(dbHandle *sql.DB) error {
+	if err := downgradePGSQLDatabaseFrom31To30(dbHandle); err != nil {
+		return err
+	}
+	return downgradePGSQLDatabaseFromV30(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV32(dbHandle *sql.DB) error {
+	if err := downgradePGSQLDatabaseFrom32To31(dbHandle); err != nil {
+		return err
+	}
+	return downgradePGSQLDatabaseFromV31(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV33(dbHandle *sql.DB) error {
+	if err := downgradePGSQLDatabaseFrom33To32(dbHandle); err != nil {
+		return err
+	}
+	return downgradePGSQLDatabaseFromV32(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV34(dbHandle *sql.DB) error {
+	if err := downgradePGSQLDatabaseFrom34To33(dbHandle); err != nil {
+		return err
+	}
+	return downgradePGSQLDatabaseFromV33(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV35(dbHandle *sql.DB) error {
+	if err := downgradePGSQLDatabaseFrom35To34(dbHandle); err != nil {
+		return err
+	}
+	return downgradePGSQLDatabaseFromV34(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV36(dbHandle *sql.DB) error {
+	if err := downgradePGSQLDatabaseFrom36To35(dbHandle); err != nil {
+		return err
+	}
+	return downgradePGSQLDatabaseFromV35(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV37(dbHandle *sql.DB) error {
+	if err := downgradePGSQLDatabaseFrom37To36(dbHandle); err != nil {
+		return err
+	}
+	return downgradePGSQLDatabaseFromV36(dbHandle)
+}
+
+func downgradePGSQLDatabaseFromV38(dbHandle *sql.DB) error {
+	if err := downgradePGSQLDatabaseFrom38To37(dbHandle); err != nil {
+		return err
+	}
+	return downgradePGSQLDatabaseFromV37(dbHandle)
+}
+
+func updatePGSQLDatabaseFrom28To29(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 28 -> 29")
+	providerLog(logger.LevelInfo, "updating database schema version: 28 -> 29")
+	sql := sqlReplaceAll(pgsqlV29SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 29, true)
+}
+
+func downgradePGSQLDatabaseFrom29To28(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 29 -> 28")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 29 -> 28")
+	sql := sqlReplaceAll(pgsqlV29DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 28, false)
+}
+
+func updatePGSQLDatabaseFrom29To30(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 29 -> 30")
+	providerLog(logger.LevelInfo, "updating database schema version: 29 -> 30")
+	sql := sqlReplaceAll(pgsqlV30SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 30, true)
+}
+
+func downgradePGSQLDatabaseFrom30To29(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 30 -> 29")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 30 -> 29")
+	sql := sqlReplaceAll(pgsqlV30DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 29, false)
+}
+
+func updatePGSQLDatabaseFrom30To31(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 30 -> 31")
+	providerLog(logger.LevelInfo, "updating database schema version: 30 -> 31")
+	sql := sqlReplaceAll(pgsqlV31SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 31, true)
+}
+
+func downgradePGSQLDatabaseFrom31To30(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 31 -> 30")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 31 -> 30")
+	sql := sqlReplaceAll(pgsqlV31DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 30, false)
+}
+
+func updatePGSQLDatabaseFrom31To32(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 31 -> 32")
+	providerLog(logger.LevelInfo, "updating database schema version: 31 -> 32")
+	sql := sqlReplaceAll(pgsqlV32SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 32, true)
+}
+
+func downgradePGSQLDatabaseFrom32To31(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 32 -> 31")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 32 -> 31")
+	sql := sqlReplaceAll(pgsqlV32DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 31, false)
+}
+
+func updatePGSQLDatabaseFrom32To33(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 32 -> 33")
+	providerLog(logger.LevelInfo, "updating database schema version: 32 -> 33")
+	sql := sqlReplaceAll(pgsqlV33SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 33, true)
+}
+
+func downgradePGSQLDatabaseFrom33To32(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 33 -> 32")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 33 -> 32")
+	sql := sqlReplaceAll(pgsqlV33DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 32, false)
+}
+
+func updatePGSQLDatabaseFrom33To34(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 33 -> 34")
+	providerLog(logger.LevelInfo, "updating database schema version: 33 -> 34")
+	sql := sqlReplaceAll(pgsqlV34SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 34, true)
+}
+
+func downgradePGSQLDatabaseFrom34To33(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 34 -> 33")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 34 -> 33")
+	sql := sqlReplaceAll(pgsqlV34DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 33, false)
+}
+
+func updatePGSQLDatabaseFrom34To35(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 34 -> 35")
+	providerLog(logger.LevelInfo, "updating database schema version: 34 -> 35")
+	sql := sqlReplaceAll(pgsqlV35SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 35, true)
+}
+
+func downgradePGSQLDatabaseFrom35To34(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 35 -> 34")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 35 -> 34")
+	sql := sqlReplaceAll(pgsqlV35DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 34, false)
+}
+
+func updatePGSQLDatabaseFrom35To36(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 35 -> 36")
+	providerLog(logger.LevelInfo, "updating database schema version: 35 -> 36")
+	sql := sqlReplaceAll(pgsqlV36SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 36, true)
+}
+
+func downgradePGSQLDatabaseFrom36To35(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 36 -> 35")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 36 -> 35")
+	sql := sqlReplaceAll(pgsqlV36DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 35, false)
+}
+
+func updatePGSQLDatabaseFrom36To37(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 36 -> 37")
+	providerLog(logger.LevelInfo, "updating database schema version: 36 -> 37")
+	sql := sqlReplaceAll(pgsqlV37SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 37, true)
+}
+
+func downgradePGSQLDatabaseFrom37To36(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 37 -> 36")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 37 -> 36")
+	sql := sqlReplaceAll(pgsqlV37DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 36, false)
+}
+
+func updatePGSQLDatabaseFrom37To38(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 37 -> 38")
+	providerLog(logger.LevelInfo, "updating database schema version: 37 -> 38")
+	sql := sqlReplaceAll(pgsqlV38SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 38, true)
+}
+
+func downgradePGSQLDatabaseFrom38To37(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 38 -> 37")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 38 -> 37")
+	sql := sqlReplaceAll(pgsqlV38DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 37, false)
+}
@@ -0,0 +1,269 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alexedwards/argon2id"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// Password hashing schemes reported by the password hashes migration
+const (
+	PasswordSchemeArgon2ID    = "argon2id"
+	PasswordSchemeBcrypt      = "bcrypt"
+	PasswordSchemeMD5Crypt    = "md5crypt"
+	PasswordSchemeSHA256Crypt = "sha256crypt"
+	PasswordSchemeSHA512Crypt = "sha512crypt"
+	PasswordSchemeYescrypt    = "yescrypt"
+	PasswordSchemePBKDF2      = "pbkdf2"
+	PasswordSchemeDigest      = "digest"
+	PasswordSchemeUnknown     = "unknown"
+)
+
+var pwdMigrationMgr passwordMigrationManager
+
+// PasswordMigrationStatus defines the status of the last password hashes migration check
+type PasswordMigrationStatus struct {
+	IsRunning bool `json:"is_running"`
+	// Unix timestamps in milliseconds
+	StartTime int64 `json:"start_time,omitempty"`
+	EndTime   int64 `json:"end_time,omitempty"`
+	// Number of checked users
+	Users int `json:"users"`
+	// Number of users without a password
+	NoPassword int `json:"no_password"`
+	// Number of users whose password hash uses the configured algorithm and options
+	UpToDate int `json:"up_to_date"`
+	// Number of users whose password hash must be upgraded, grouped by hashing scheme
+	Legacy map[string]int `json:"legacy,omitempty"`
+	// Number of never used accounts with a legacy password hash that were expired
+	Expired int `json:"expired"`
+	// Number of password hashes upgraded on login since the service started
+	Rehashed int64    `json:"rehashed"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+type passwordMigrationManager struct {
+	running  atomic.Bool
+	rehashed atomic.Int64
+	mu       sync.RWMutex
+	status   PasswordMigrationStatus
+}
+
+func (m *passwordMigrationManager) getStatus() PasswordMigrationStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := m.status
+	status.IsRunning = m.running.Load()
+	status.Rehashed = m.rehashed.Load()
+	status.Legacy = make(map[string]int, len(m.status.Legacy))
+	for k, v := range m.status.Legacy {
+		status.Legacy[k] = v
+	}
+	status.Errors = append([]string(nil), m.status.Errors...)
+	return status
+}
+
+func (m *passwordMigrationManager) onRehashed() {
+	m.rehashed.Add(1)
+}
+
+func (m *passwordMigrationManager) onUserDone(username, scheme string, upToDate, expired bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		providerLog(logger.LevelError, "password hashes migration, unable to check user %q: %v", username, err)
+		m.status.Errors = append(m.status.Errors, fmt.Sprintf("user %q: %v", username, err))
+		return
+	}
+	m.status.Users++
+	switch {
+	case scheme == "":
+		m.status.NoPassword++
+	case upToDate:
+		m.status.UpToDate++
+	default:
+		m.status.Legacy[scheme]++
+	}
+	if expired {
+		providerLog(logger.LevelInfo, "password hashes migration, never used account %q with %s password hash expired",
+			username, scheme)
+		m.status.Expired++
+	}
+}
+
+func (m *passwordMigrationManager) start(expireAfterDays int) bool {
+	if !m.running.CompareAndSwap(false, true) {
+		return false
+	}
+	m.mu.Lock()
+	m.status = PasswordMigrationStatus{
+		StartTime: util.GetTimeAsMsSinceEpoch(time.Now()),
+		Legacy:    make(map[string]int),
+	}
+	m.mu.Unlock()
+
+	go m.run(expireAfterDays)
+	return true
+}
+
+func (m *passwordMigrationManager) run(expireAfterDays int) {
+	defer m.running.Store(false)
+
+	startTime := time.Now()
+	providerLog(logger.LevelInfo, "password hashes migration check started, expire after days: %d", expireAfterDays)
+	m.checkUsers(expireAfterDays)
+
+	m.mu.Lock()
+	m.status.EndTime = util.GetTimeAsMsSinceEpoch(time.Now())
+	providerLog(logger.LevelInfo, "password hashes migration check completed, users: %d, up to date: %d, "+
+		"legacy: %v, expired: %d, errors: %d, elapsed: %s", m.status.Users, m.status.UpToDate, m.status.Legacy,
+		m.status.Expired, len(m.status.Errors), time.Since(startTime))
+	m.mu.Unlock()
+}
+
+// the users are loaded again before updating them to minimize the chance
+// of overwriting concurrent changes
+func (m *passwordMigrationManager) checkUsers(expireAfterDays int) {
+	users, err := provider.dumpUsers()
+	if err != nil {
+		m.onUserDone("*", "", false, false, err)
+		return
+	}
+	for idx := range users {
+		scheme := getPasswordHashScheme(users[idx].Password)
+		upToDate := isPasswordHashUpToDate(users[idx].Password)
+		if scheme == "" || upToDate || !isNeverUsedAccount(&users[idx], expireAfterDays) {
+			m.onUserDone(users[idx].Username, scheme, upToDate, false, nil)
+			continue
+		}
+		user, err := provider.userExists(users[idx].Username, "")
+		if err != nil {
+			m.onUserDone(users[idx].Username, scheme, upToDate, false, err)
+			continue
+		}
+		expired := false
+		if isNeverUsedAccount(&user, expireAfterDays) {
+			user.ExpirationDate = util.GetTimeAsMsSinceEpoch(time.Now())
+			err = provider.updateUser(&user)
+			if err == nil {
+				expired = true
+				RemoveCachedWebDAVUser(user.Username)
+			}
+		}
+		m.onUserDone(user.Username, scheme, upToDate, expired, err)
+	}
+}
+
+// isNeverUsedAccount returns true if the specified user never logged in, was
+// created more than the specified number of days ago and is not expired yet
+func isNeverUsedAccount(user *User, expireAfterDays int) bool {
+	if expireAfterDays <= 0 || user.LastLogin > 0 {
+		return false
+	}
+	now := time.Now()
+	if user.ExpirationDate > 0 && user.ExpirationDate <= util.GetTimeAsMsSinceEpoch(now) {
+		return false
+	}
+	limit := util.GetTimeAsMsSinceEpoch(now.Add(-time.Duration(expireAfterDays) * 24 * time.Hour))
+	return user.CreatedAt < limit
+}
+
+// getPasswordHashScheme returns the scheme for the specified password hash,
+// an empty string is returned if the password is empty
+func getPasswordHashScheme(hash string) string {
+	switch {
+	case hash == "":
+		return ""
+	case strings.HasPrefix(hash, argonPwdPrefix):
+		return PasswordSchemeArgon2ID
+	case strings.HasPrefix(hash, bcryptPwdPrefix):
+		return PasswordSchemeBcrypt
+	case strings.HasPrefix(hash, md5cryptPwdPrefix), strings.HasPrefix(hash, md5cryptApr1PwdPrefix):
+		return PasswordSchemeMD5Crypt
+	case strings.HasPrefix(hash, sha256cryptPwdPrefix):
+		return PasswordSchemeSHA256Crypt
+	case strings.HasPrefix(hash, sha512cryptPwdPrefix):
+		return PasswordSchemeSHA512Crypt
+	case strings.HasPrefix(hash, yescryptPwdPrefix):
+		return PasswordSchemeYescrypt
+	case util.IsStringPrefixInSlice(hash, pbkdfPwdPrefixes):
+		return PasswordSchemePBKDF2
+	case util.IsStringPrefixInSlice(hash, digestPwdPrefixes):
+		return PasswordSchemeDigest
+	default:
+		return PasswordSchemeUnknown
+	}
+}
+
+// isPasswordHashUpToDate returns true if the specified password hash was generated
+// using the configured algorithm and options. The argon2id parallelism is ignored,
+// its default value depends on the number of CPUs
+func isPasswordHashUpToDate(hash string) bool {
+	switch config.PasswordHashing.Algo {
+	case HashingAlgoBcrypt:
+		if !strings.HasPrefix(hash, bcryptPwdPrefix) {
+			return false
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false
+		}
+		configuredCost := config.PasswordHashing.BcryptOptions.Cost
+		if configuredCost < bcrypt.MinCost {
+			configuredCost = bcrypt.DefaultCost
+		}
+		return cost >= configuredCost
+	case HashingAlgoArgon2ID:
+		if !strings.HasPrefix(hash, argonPwdPrefix) {
+			return false
+		}
+		params, _, _, err := argon2id.DecodeHash(hash)
+		if err != nil {
+			return false
+		}
+		return params.Memory >= argon2Params.Memory && params.Iterations >= argon2Params.Iterations
+	default:
+		return true
+	}
+}
+
+// StartPasswordMigrationCheck starts, in background, a check of the stored user
+// password hashes. The password hashes not generated using the configured algorithm
+// and options cannot be converted without the plain text password, they are upgraded
+// when the users login. If expireAfterDays is greater than 0, accounts with a legacy
+// password hash that never logged in and were created more than the specified
+// number of days ago are expired.
+// It returns false if another check is already in progress
+func StartPasswordMigrationCheck(expireAfterDays int) bool {
+	return pwdMigrationMgr.start(expireAfterDays)
+}
+
+// GetPasswordMigrationStatus returns the status of the current or last password
+// hashes migration check
+func GetPasswordMigrationStatus() PasswordMigrationStatus {
+	return pwdMigrationMgr.getStatus()
+}
@@ -0,0 +1,693 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/plugin"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+// Supported reconciler modes
+const (
+	// Declared objects are created or updated to match the declarations
+	ReconcilerModeApply = iota
+	// Differences are only reported
+	ReconcilerModeReport
+)
+
+// Drift reasons
+const (
+	ReconcilerDriftMissing  = "missing"
+	ReconcilerDriftModified = "modified"
+)
+
+const (
+	reconcilerMaxFileSize = 10 * 1048576
+	reconcilerGitTimeout  = 5 * time.Minute
+)
+
+var (
+	reconcilerMgr        reconcilerManager
+	errReconcilerRunning = util.NewValidationError("a reconciliation is already in progress")
+)
+
+// ReconcilerConfig defines the configuration for the declarative configuration reconciler.
+// Users, groups, virtual folders, event actions and event rules can be declared in YAML or
+// JSON files and they are periodically applied to the data provider
+type ReconcilerConfig struct {
+	// Directory containing the declarations. This can be an absolute path or a path relative to
+	// the config dir. Empty means disabled
+	SourceDir string `json:"source_dir" mapstructure:"source_dir"`
+	// Git repository to clone, or update, in SourceDir before each reconciliation.
+	// The git executable must be available in the PATH
+	GitURL string `json:"git_url" mapstructure:"git_url"`
+	// Git branch to use, empty means the remote default branch
+	GitBranch string `json:"git_branch" mapstructure:"git_branch"`
+	// Interval between reconciliations, in minutes
+	Interval int `json:"interval" mapstructure:"interval"`
+	// 0 means apply the declarations, 1 means report the differences only
+	Mode int `json:"mode" mapstructure:"mode"`
+}
+
+func (c *ReconcilerConfig) isEnabled() bool {
+	return c.SourceDir != ""
+}
+
+func (c *ReconcilerConfig) validate(configDir string) error {
+	if !c.isEnabled() {
+		return nil
+	}
+	c.SourceDir = getConfigPath(c.SourceDir, configDir)
+	if !filepath.IsAbs(c.SourceDir) {
+		return fmt.Errorf("invalid reconciler source dir %q, it must be an absolute path or a path relative to the config dir",
+			c.SourceDir)
+	}
+	if c.Interval < 1 {
+		return fmt.Errorf("invalid reconciler interval: %d", c.Interval)
+	}
+	if c.Mode != ReconcilerModeApply && c.Mode != ReconcilerModeReport {
+		return fmt.Errorf("invalid reconciler mode: %d", c.Mode)
+	}
+	if c.GitURL == "" && c.GitBranch != "" {
+		return errors.New("a git branch requires a git repository")
+	}
+	return nil
+}
+
+// ReconcilerDrift defines an object that differs from its declaration
+type ReconcilerDrift struct {
+	ObjectType string `json:"object_type"`
+	ObjectName string `json:"object_name"`
+	Reason     string `json:"reason"`
+	// JSON pointers to the fields that differ from the declaration
+	Fields []string `json:"fields,omitempty"`
+	// true if the declaration was successfully applied
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (d *ReconcilerDrift) getKey() string {
+	return d.ObjectType + "\x00" + d.ObjectName + "\x00" + d.Reason + "\x00" + strings.Join(d.Fields, ",")
+}
+
+// ReconcilerStatus defines the status of the declarative configuration reconciler
+type ReconcilerStatus struct {
+	Enabled bool `json:"enabled"`
+	Mode    int  `json:"mode"`
+	// Git commit of the applied declarations, if a git repository is configured
+	Revision string `json:"revision,omitempty"`
+	// Unix timestamp in milliseconds
+	LastRun   int64             `json:"last_run,omitempty"`
+	LastError string            `json:"last_error,omitempty"`
+	Objects   int               `json:"objects"`
+	Drifts    []ReconcilerDrift `json:"drifts"`
+}
+
+type reconcilerDeclarations struct {
+	Folders      []json.RawMessage `json:"folders"`
+	Groups       []json.RawMessage `json:"groups"`
+	Users        []json.RawMessage `json:"users"`
+	EventActions []json.RawMessage `json:"event_actions"`
+	EventRules   []json.RawMessage `json:"event_rules"`
+}
+
+func (d *reconcilerDeclarations) append(other reconcilerDeclarations) {
+	d.Folders = append(d.Folders, other.Folders...)
+	d.Groups = append(d.Groups, other.Groups...)
+	d.Users = append(d.Users, other.Users...)
+	d.EventActions = append(d.EventActions, other.EventActions...)
+	d.EventRules = append(d.EventRules, other.EventRules...)
+}
+
+// reconcilerKind defines how to handle a declared object type
+type reconcilerKind struct {
+	objectType string
+	nameField  string
+	// get returns the existing object, the returned copy is rendered and compared with the declaration
+	get func(name string) (plugin.Renderer, error)
+	add func(data []byte) (plugin.Renderer, error)
+	// update merges the declaration into the existing object
+	update func(name string, data []byte) error
+	// newDeclared returns the declared object, used as event payload for missing objects
+	newDeclared func(data []byte) (plugin.Renderer, error)
+}
+
+func getReconcilerKinds() []reconcilerKind {
+	return []reconcilerKind{
+		{
+			objectType: actionObjectFolder,
+			nameField:  "name",
+			get: func(name string) (plugin.Renderer, error) {
+				folder, err := GetFolderByName(name)
+				if err != nil {
+					return nil, err
+				}
+				return &wrappedFolder{Folder: folder}, nil
+			},
+			add: func(data []byte) (plugin.Renderer, error) {
+				var folder vfs.BaseVirtualFolder
+				if err := json.Unmarshal(data, &folder); err != nil {
+					return nil, err
+				}
+				folder.Users = nil
+				folder.Groups = nil
+				err := AddFolder(&folder, ActionExecutorReconciler, "", "")
+				return &wrappedFolder{Folder: folder}, err
+			},
+			update: func(name string, data []byte) error {
+				folder, err := GetFolderByName(name)
+				if err != nil {
+					return err
+				}
+				users := folder.Users
+				groups := folder.Groups
+				if err := json.Unmarshal(data, &folder); err != nil {
+					return err
+				}
+				return UpdateFolder(&folder, users, groups, ActionExecutorReconciler, "", "")
+			},
+			newDeclared: func(data []byte) (plugin.Renderer, error) {
+				var folder vfs.BaseVirtualFolder
+				err := json.Unmarshal(data, &folder)
+				return &wrappedFolder{Folder: folder}, err
+			},
+		},
+		{
+			objectType: actionObjectGroup,
+			nameField:  "name",
+			get: func(name string) (plugin.Renderer, error) {
+				group, err := GroupExists(name)
+				return &group, err
+			},
+			add: func(data []byte) (plugin.Renderer, error) {
+				var group Group
+				if err := json.Unmarshal(data, &group); err != nil {
+					return nil, err
+				}
+				err := AddGroup(&group, ActionExecutorReconciler, "", "")
+				return &group, err
+			},
+			update: func(name string, data []byte) error {
+				group, err := GroupExists(name)
+				if err != nil {
+					return err
+				}
+				users := group.Users
+				if err := json.Unmarshal(data, &group); err != nil {
+					return err
+				}
+				return UpdateGroup(&group, users, ActionExecutorReconciler, "", "")
+			},
+			newDeclared: func(data []byte) (plugin.Renderer, error) {
+				var group Group
+				err := json.Unmarshal(data, &group)
+				return &group, err
+			},
+		},
+		{
+			objectType: actionObjectUser,
+			nameField:  "username",
+			get: func(name string) (plugin.Renderer, error) {
+				user, err := UserExists(name, "")
+				return &user, err
+			},
+			add: func(data []byte) (plugin.Renderer, error) {
+				var user User
+				if err := json.Unmarshal(data, &user); err != nil {
+					return nil, err
+				}
+				err := AddUser(&user, ActionExecutorReconciler, "", "")
+				return &user, err
+			},
+			update: func(name string, data []byte) error {
+				user, err := UserExists(name, "")
+				if err != nil {
+					return err
+				}
+				if err := json.Unmarshal(data, &user); err != nil {
+					return err
+				}
+				return UpdateUser(&user, ActionExecutorReconciler, "", "")
+			},
+			newDeclared: func(data []byte) (plugin.Renderer, error) {
+				var user User
+				err := json.Unmarshal(data, &user)
+				return &user, err
+			},
+		},
+		{
+			objectType: actionObjectEventAction,
+			nameField:  "name",
+			get: func(name string) (plugin.Renderer, error) {
+				action, err := EventActionExists(name)
+				return &action, err
+			},
+			add: func(data []byte) (plugin.Renderer, error) {
+				var action BaseEventAction
+				if err := json.Unmarshal(data, &action); err != nil {
+					return nil, err
+				}
+				err := AddEventAction(&action, ActionExecutorReconciler, "", "")
+				return &action, err
+			},
+			update: func(name string, data []byte) error {
+				action, err := EventActionExists(name)
+				if err != nil {
+					return err
+				}
+				if err := json.Unmarshal(data, &action); err != nil {
+					return err
+				}
+				return UpdateEventAction(&action, ActionExecutorReconciler, "", "")
+			},
+			newDeclared: func(data []byte) (plugin.Renderer, error) {
+				var action BaseEventAction
+				err := json.Unmarshal(data, &action)
+				return &action, err
+			},
+		},
+		{
+			objectType: actionObjectEventRule,
+			nameField:  "name",
+			get: func(name string) (plugin.Renderer, error) {
+				rule, err := EventRuleExists(name)
+				return &rule, err
+			},
+			add: func(data []byte) (plugin.Renderer, error) {
+				var rule EventRule
+				if err := json.Unmarshal(data, &rule); err != nil {
+					return nil, err
+				}
+				err := AddEventRule(&rule, ActionExecutorReconciler, "", "")
+				return &rule, err
+			},
+			update: func(name string, data []byte) error {
+				rule, err := EventRuleExists(name)
+				if err != nil {
+					return err
+				}
+				if err := json.Unmarshal(data, &rule); err != nil {
+					return err
+				}
+				return UpdateEventRule(&rule, ActionExecutorReconciler, "", "")
+			},
+			newDeclared: func(data []byte) (plugin.Renderer, error) {
+				var rule EventRule
+				err := json.Unmarshal(data, &rule)
+				return &rule, err
+			},
+		},
+	}
+}
+
+type reconcilerManager struct {
+	running atomic.Bool
+	mu      sync.RWMutex
+	status  ReconcilerStatus
+}
+
+func (m *reconcilerManager) getStatus() ReconcilerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := m.status
+	status.Enabled = config.Reconciler.isEnabled()
+	status.Mode = config.Reconciler.Mode
+	status.Drifts = make([]ReconcilerDrift, 0, len(m.status.Drifts))
+	for _, d := range m.status.Drifts {
+		d.Fields = append([]string(nil), d.Fields...)
+		status.Drifts = append(status.Drifts, d)
+	}
+	return status
+}
+
+func (m *reconcilerManager) run() error {
+	if !m.running.CompareAndSwap(false, true) {
+		return errReconcilerRunning
+	}
+	defer m.running.Store(false)
+
+	startTime := time.Now()
+	revision, declarations, err := loadReconcilerDeclarations(&config.Reconciler)
+	if err != nil {
+		providerLog(logger.LevelError, "unable to load reconciler declarations: %v", err)
+		m.mu.Lock()
+		m.status.LastRun = util.GetTimeAsMsSinceEpoch(startTime)
+		m.status.LastError = err.Error()
+		m.mu.Unlock()
+		return err
+	}
+
+	m.mu.RLock()
+	previousDrifts := make(map[string]bool)
+	for _, d := range m.status.Drifts {
+		previousDrifts[d.getKey()] = true
+	}
+	m.mu.RUnlock()
+
+	var drifts []ReconcilerDrift
+	var objects int
+	var errs []string
+	kinds := getReconcilerKinds()
+	declared := [][]json.RawMessage{declarations.Folders, declarations.Groups, declarations.Users,
+		declarations.EventActions, declarations.EventRules}
+	for idx, kind := range kinds {
+		for _, data := range declared[idx] {
+			objects++
+			drift, object, err := reconcileObject(&kind, data, config.Reconciler.Mode == ReconcilerModeApply)
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+			if drift == nil {
+				continue
+			}
+			if !previousDrifts[drift.getKey()] && fnHandleRuleForProviderEvent != nil && object != nil {
+				fnHandleRuleForProviderEvent(operationDrift, ActionExecutorReconciler, "", drift.ObjectType,
+					drift.ObjectName, "", object)
+			}
+			drifts = append(drifts, *drift)
+		}
+	}
+
+	m.mu.Lock()
+	m.status.Revision = revision
+	m.status.LastRun = util.GetTimeAsMsSinceEpoch(startTime)
+	m.status.LastError = strings.Join(errs, "; ")
+	m.status.Objects = objects
+	m.status.Drifts = drifts
+	m.mu.Unlock()
+
+	providerLog(logger.LevelDebug, "reconciliation completed, revision %q, objects: %d, drifts: %d, errors: %d, elapsed: %s",
+		revision, objects, len(drifts), len(errs), time.Since(startTime))
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func reconcileObject(kind *reconcilerKind, data json.RawMessage, apply bool) (*ReconcilerDrift, plugin.Renderer, error) {
+	var spec map[string]any
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, nil, fmt.Errorf("invalid %s declaration: %w", kind.objectType, err)
+	}
+	name, _ := spec[kind.nameField].(string)
+	if name == "" {
+		return nil, nil, fmt.Errorf("invalid %s declaration: %q is mandatory", kind.objectType, kind.nameField)
+	}
+	object, err := kind.get(name)
+	if err != nil {
+		if !errors.Is(err, util.ErrNotFound) {
+			return nil, nil, fmt.Errorf("unable to get %s %q: %w", kind.objectType, name, err)
+		}
+		drift := &ReconcilerDrift{
+			ObjectType: kind.objectType,
+			ObjectName: name,
+			Reason:     ReconcilerDriftMissing,
+		}
+		declared, err := kind.newDeclared(data)
+		if err != nil {
+			drift.Error = err.Error()
+			return drift, nil, fmt.Errorf("invalid %s declaration %q: %w", kind.objectType, name, err)
+		}
+		if !apply {
+			return drift, declared, nil
+		}
+		added, err := kind.add(data)
+		if err != nil {
+			drift.Error = err.Error()
+			return drift, declared, fmt.Errorf("unable to add %s %q: %w", kind.objectType, name, err)
+		}
+		drift.Applied = true
+		providerLog(logger.LevelInfo, "reconciler, %s %q added", kind.objectType, name)
+		return drift, added, nil
+	}
+	rendered, err := object.RenderAsJSON(false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to render %s %q: %w", kind.objectType, name, err)
+	}
+	var current map[string]any
+	if err := json.Unmarshal(rendered, &current); err != nil {
+		return nil, nil, fmt.Errorf("unable to render %s %q: %w", kind.objectType, name, err)
+	}
+	// the password is hashed and so it cannot be compared, it is only set when the object is added
+	delete(spec, "password")
+	var fields []string
+	getReconcilerDriftFields("", spec, current, &fields)
+	if len(fields) == 0 {
+		return nil, nil, nil
+	}
+	drift := &ReconcilerDrift{
+		ObjectType: kind.objectType,
+		ObjectName: name,
+		Reason:     ReconcilerDriftModified,
+		Fields:     fields,
+	}
+	if !apply {
+		return drift, object, nil
+	}
+	updateData, err := json.Marshal(spec)
+	if err != nil {
+		drift.Error = err.Error()
+		return drift, object, err
+	}
+	if err := kind.update(name, updateData); err != nil {
+		drift.Error = err.Error()
+		return drift, object, fmt.Errorf("unable to update %s %q: %w", kind.objectType, name, err)
+	}
+	drift.Applied = true
+	providerLog(logger.LevelInfo, "reconciler, %s %q updated, changed fields: %v", kind.objectType, name, fields)
+	return drift, object, nil
+}
+
+// getReconcilerDriftFields adds to fields the JSON pointers for the declared values
+// that differ from the current ones. Only the declared fields are compared
+func getReconcilerDriftFields(path string, declared, current any, fields *[]string) {
+	switch d := declared.(type) {
+	case map[string]any:
+		if isReconcilerSecret(d) {
+			// secrets are hidden in the current object
+			return
+		}
+		c, ok := current.(map[string]any)
+		if !ok {
+			if current != nil || len(d) > 0 {
+				*fields = append(*fields, path)
+			}
+			return
+		}
+		keys := make([]string, 0, len(d))
+		for k := range d {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			getReconcilerDriftFields(path+"/"+strings.NewReplacer("~", "~0", "/", "~1").Replace(k), d[k], c[k], fields)
+		}
+	case []any:
+		c, ok := current.([]any)
+		if !ok {
+			if current != nil || len(d) > 0 {
+				*fields = append(*fields, path)
+			}
+			return
+		}
+		if len(c) != len(d) {
+			*fields = append(*fields, path)
+			return
+		}
+		for idx := range d {
+			getReconcilerDriftFields(path+"/"+strconv.Itoa(idx), d[idx], c[idx], fields)
+		}
+	default:
+		if current == nil {
+			// empty values are omitted from the current object
+			if declared == nil || reflect.ValueOf(declared).IsZero() {
+				return
+			}
+			*fields = append(*fields, path)
+			return
+		}
+		if !reflect.DeepEqual(declared, current) {
+			*fields = append(*fields, path)
+		}
+	}
+}
+
+func isReconcilerSecret(val map[string]any) bool {
+	_, hasStatus := val["status"]
+	_, hasPayload := val["payload"]
+	return hasStatus && hasPayload
+}
+
+func loadReconcilerDeclarations(c *ReconcilerConfig) (string, reconcilerDeclarations, error) {
+	var declarations reconcilerDeclarations
+
+	revision, err := syncReconcilerGitRepo(c)
+	if err != nil {
+		return revision, declarations, err
+	}
+	var files []string
+	err = filepath.WalkDir(c.SourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != c.SourceDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return revision, declarations, fmt.Errorf("unable to walk %q: %w", c.SourceDir, err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		d, err := parseReconcilerFile(file)
+		if err != nil {
+			return revision, declarations, err
+		}
+		declarations.append(d)
+	}
+	return revision, declarations, nil
+}
+
+func parseReconcilerFile(file string) (reconcilerDeclarations, error) {
+	var declarations reconcilerDeclarations
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return declarations, err
+	}
+	if info.Size() > reconcilerMaxFileSize {
+		return declarations, fmt.Errorf("unable to parse %q: file too large: %s", file, util.ByteCountIEC(info.Size()))
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return declarations, err
+	}
+	if ext := strings.ToLower(filepath.Ext(file)); ext == ".yaml" || ext == ".yml" {
+		var data any
+		if err := yaml.Unmarshal(content, &data); err != nil {
+			return declarations, fmt.Errorf("unable to parse %q: %w", file, err)
+		}
+		if data == nil {
+			return declarations, nil
+		}
+		content, err = json.Marshal(data)
+		if err != nil {
+			return declarations, fmt.Errorf("unable to parse %q: %w", file, err)
+		}
+	}
+	if err := json.Unmarshal(content, &declarations); err != nil {
+		return declarations, fmt.Errorf("unable to parse %q: %w", file, err)
+	}
+	return declarations, nil
+}
+
+func syncReconcilerGitRepo(c *ReconcilerConfig) (string, error) {
+	if c.GitURL == "" {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reconcilerGitTimeout)
+	defer cancel()
+
+	if _, err := os.Stat(filepath.Join(c.SourceDir, ".git")); err != nil {
+		args := []string{"clone", "--depth", "1"}
+		if c.GitBranch != "" {
+			args = append(args, "--branch", c.GitBranch)
+		}
+		args = append(args, "--", c.GitURL, c.SourceDir)
+		if _, err := runReconcilerGitCommand(ctx, args...); err != nil {
+			return "", err
+		}
+	} else {
+		ref := "HEAD"
+		if c.GitBranch != "" {
+			ref = c.GitBranch
+		}
+		if _, err := runReconcilerGitCommand(ctx, "-C", c.SourceDir, "fetch", "--depth", "1", "origin", ref); err != nil {
+			return "", err
+		}
+		if _, err := runReconcilerGitCommand(ctx, "-C", c.SourceDir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	}
+	return runReconcilerGitCommand(ctx, "-C", c.SourceDir, "rev-parse", "HEAD")
+}
+
+func runReconcilerGitCommand(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s failed: %w, stderr: %s", args[len(args)-1], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func runReconciler() {
+	if err := reconcilerMgr.run(); err != nil {
+		providerLog(logger.LevelError, "reconciliation error: %v", err)
+	}
+}
+
+// GetReconcilerStatus returns the status of the declarative configuration reconciler
+func GetReconcilerStatus() ReconcilerStatus {
+	return reconcilerMgr.getStatus()
+}
+
+// RunReconciler applies the declarations, or reports the differences, immediately
+// and returns the updated status. Reconciliation errors are reported within the status
+func RunReconciler() (ReconcilerStatus, error) {
+	if !config.Reconciler.isEnabled() {
+		return ReconcilerStatus{}, util.NewMethodDisabledError("the reconciler is disabled")
+	}
+	if err := reconcilerMgr.run(); err != nil {
+		if errors.Is(err, errReconcilerRunning) {
+			return ReconcilerStatus{}, err
+		}
+		providerLog(logger.LevelError, "reconciliation error: %v", err)
+	}
+	return reconcilerMgr.getStatus(), nil
+}
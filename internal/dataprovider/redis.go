@@ -0,0 +1,901 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !noredis
+// +build !noredis
+
+package dataprovider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/version"
+)
+
+const (
+	redisDefaultPort    = 6379
+	redisDialTimeout    = 10 * time.Second
+	redisVersionsKey    = "versions"
+	redisChangesChannel = "changes"
+)
+
+// Supported values for the redis_persistence configuration
+const (
+	redisPersistenceNone = iota
+	redisPersistenceAny
+	redisPersistenceAOF
+)
+
+// redisCommitScript atomically validates the values read inside a transaction
+// and applies the buffered writes. The arguments are:
+// - the number of point reads followed by, for each read, the hash key index,
+// the field, "1" if the field existed and the read value
+// - the number of scanned buckets followed by, for each bucket, its name and
+// the version read
+// - the number of writes followed by, for each write, the hash key index, the
+// field, "1" for deletes, the value and the bucket name
+// - the number of change notifications followed by the channel and the message
+// KEYS[1] is the hash with the bucket versions, a version is incremented for
+// each write to the bucket
+var redisCommitScript = redis.NewScript(`
+local pos = 1
+local n = tonumber(ARGV[pos])
+pos = pos + 1
+for i = 1, n do
+	local current = redis.call('HGET', KEYS[tonumber(ARGV[pos])], ARGV[pos + 1])
+	if ARGV[pos + 2] == '1' then
+		if current ~= ARGV[pos + 3] then
+			return 0
+		end
+	elseif current then
+		return 0
+	end
+	pos = pos + 4
+end
+n = tonumber(ARGV[pos])
+pos = pos + 1
+for i = 1, n do
+	local current = redis.call('HGET', KEYS[1], ARGV[pos]) or '0'
+	if current ~= ARGV[pos + 1] then
+		return 0
+	end
+	pos = pos + 2
+end
+n = tonumber(ARGV[pos])
+pos = pos + 1
+for i = 1, n do
+	local key = KEYS[tonumber(ARGV[pos])]
+	if ARGV[pos + 2] == '1' then
+		redis.call('HDEL', key, ARGV[pos + 1])
+	else
+		redis.call('HSET', key, ARGV[pos + 1], ARGV[pos + 3])
+	end
+	redis.call('HINCRBY', KEYS[1], ARGV[pos + 4], 1)
+	pos = pos + 5
+end
+n = tonumber(ARGV[pos])
+pos = pos + 1
+for i = 1, n do
+	redis.call('PUBLISH', ARGV[pos], ARGV[pos + 1])
+	pos = pos + 2
+end
+return 1
+`)
+
+// redisCASScript sets a hash field only if its value is unchanged,
+// ARGV[1] is the expected value, an empty string means the field must not
+// exist, ARGV[2] is the field and ARGV[3] the new value
+var redisCASScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[2])
+if ARGV[1] == '' then
+	if current then
+		return 0
+	end
+elseif current ~= ARGV[1] then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[2], ARGV[3])
+return 1
+`)
+
+// RedisProvider defines the auth provider for Redis and Redis Cluster.
+// Each bucket is stored as a hash, all the keys share the same hash tag so
+// they are stored in the same cluster slot and can be updated atomically.
+// Write transactions are validated and applied using Lua scripts, quota
+// updates included. If the provider is shared, changes made by other instances
+// are received using pub/sub and the caches are updated instantly
+type RedisProvider struct {
+	*kvProvider
+	client redis.UniversalClient
+	prefix string
+	cancel context.CancelFunc
+}
+
+// redisChange is the notification published for changes relevant to the caches
+type redisChange struct {
+	Bucket  string `json:"bucket"`
+	Key     string `json:"key"`
+	Value   []byte `json:"value,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+func init() {
+	version.AddFeature("+redis")
+}
+
+func initializeRedisProvider() error {
+	client, err := getRedisClient()
+	if err != nil {
+		providerLog(logger.LevelError, "error creating redis client: %v", err)
+		return err
+	}
+	prefix := getKVKeyPrefix()
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		providerLog(logger.LevelError, "unable to connect to redis: %v", err)
+		return err
+	}
+	if err := checkRedisPersistence(ctx, client); err != nil {
+		client.Close()
+		providerLog(logger.LevelError, "%v", err)
+		return err
+	}
+	providerLog(logger.LevelDebug, "redis client created, key prefix: %q", prefix)
+	watchCtx, watchCancel := context.WithCancel(context.Background())
+	p := &RedisProvider{
+		client: client,
+		prefix: prefix,
+		cancel: watchCancel,
+	}
+	p.kvProvider = &kvProvider{store: p}
+	if config.IsShared == 1 {
+		go p.subscribe(watchCtx)
+	}
+	provider = p
+	return nil
+}
+
+func getRedisClient() (redis.UniversalClient, error) {
+	tlsConfig, err := getKVStoreTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config.ConnectionString != "" {
+		opts, err := redis.ParseURL(config.ConnectionString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis connection string: %w", err)
+		}
+		if tlsConfig != nil {
+			opts.TLSConfig = tlsConfig
+		}
+		return redis.NewClient(opts), nil
+	}
+	addrs := getKVStoreAddrs(redisDefaultPort)
+	if len(addrs) == 0 {
+		return nil, errors.New("at least a redis host is required")
+	}
+	// a cluster client is returned if more than one address is specified
+	return redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:       addrs,
+		Username:    config.Username,
+		Password:    config.Password,
+		DialTimeout: redisDialTimeout,
+		PoolSize:    config.PoolSize,
+		TLSConfig:   tlsConfig,
+	}), nil
+}
+
+// checkRedisPersistence verifies that the Redis servers persist data on disk
+// as required by the redis_persistence setting. Without persistence all the
+// users, folders and so on are lost if Redis restarts
+func checkRedisPersistence(ctx context.Context, client redis.UniversalClient) error {
+	if config.RedisPersistence == redisPersistenceNone {
+		return nil
+	}
+	if cluster, ok := client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, c *redis.Client) error {
+			return checkRedisServerPersistence(ctx, c)
+		})
+	}
+	return checkRedisServerPersistence(ctx, client)
+}
+
+func checkRedisServerPersistence(ctx context.Context, client redis.Cmdable) error {
+	info, err := client.Info(ctx, "persistence").Result()
+	if err != nil {
+		return fmt.Errorf("unable to check redis persistence: %w", err)
+	}
+	if getRedisInfoValue(info, "aof_enabled") == "1" {
+		return nil
+	}
+	if config.RedisPersistence == redisPersistenceAOF {
+		return errors.New("redis AOF persistence is required but disabled, set \"appendonly yes\" in the redis configuration")
+	}
+	save, err := client.ConfigGet(ctx, "save").Result()
+	if err != nil {
+		return fmt.Errorf("unable to check redis RDB persistence, the CONFIG command is probably disabled: %w", err)
+	}
+	if strings.TrimSpace(save["save"]) == "" {
+		return errors.New("redis persistence is required but both RDB snapshots and AOF are disabled")
+	}
+	return nil
+}
+
+func getRedisInfoValue(info, name string) string {
+	for _, line := range strings.Split(info, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && k == name {
+			return v
+		}
+	}
+	return ""
+}
+
+// key returns the key for the specified bucket, the hash tag is the prefix
+func (p *RedisProvider) key(bucket string) string {
+	return fmt.Sprintf("{%s}:%s", p.prefix, bucket)
+}
+
+func (p *RedisProvider) begin(_ bool) (kvSnapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	return &redisSnapshot{
+		ctx:    ctx,
+		cancel: cancel,
+		p:      p,
+		reads:  make(map[kvKey][]byte),
+		scans:  make(map[string]string),
+	}, nil
+}
+
+// redisSnapshot implements kvSnapshot. The values read are saved and
+// validated on commit, so a transaction fails if a concurrent transaction
+// modified them
+type redisSnapshot struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	p      *RedisProvider
+	reads  map[kvKey][]byte
+	scans  map[string]string
+	values map[kvKey][]byte
+}
+
+func (s *redisSnapshot) get(bucket, key string) ([]byte, error) {
+	k := kvKey{bucket: bucket, key: key}
+	if v, ok := s.reads[k]; ok {
+		return v, nil
+	}
+	if v, ok := s.values[k]; ok {
+		return v, nil
+	}
+	v, err := s.p.client.HGet(s.ctx, s.p.key(bucket), key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			s.reads[k] = nil
+			return nil, nil
+		}
+		return nil, err
+	}
+	s.reads[k] = v
+	return v, nil
+}
+
+func (s *redisSnapshot) getAll(bucket string) (map[string][]byte, error) {
+	var versionCmd *redis.StringCmd
+	var valuesCmd *redis.MapStringStringCmd
+	_, err := s.p.client.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		versionCmd = pipe.HGet(s.ctx, s.p.key(redisVersionsKey), bucket)
+		valuesCmd = pipe.HGetAll(s.ctx, s.p.key(bucket))
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	version, err := versionCmd.Result()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		version = "0"
+	}
+	if _, ok := s.scans[bucket]; !ok {
+		s.scans[bucket] = version
+	}
+	if s.values == nil {
+		s.values = make(map[kvKey][]byte)
+	}
+	result := make(map[string][]byte, len(valuesCmd.Val()))
+	for k, v := range valuesCmd.Val() {
+		result[k] = []byte(v)
+		s.values[kvKey{bucket: bucket, key: k}] = result[k]
+	}
+	return result, nil
+}
+
+func (s *redisSnapshot) count(bucket string) (int, error) {
+	n, err := s.p.client.HLen(s.ctx, s.p.key(bucket)).Result()
+	return int(n), err
+}
+
+func (s *redisSnapshot) commit(changes []kvChange) (bool, error) {
+	keys := []string{s.p.key(redisVersionsKey)}
+	keyIndexes := make(map[string]int)
+	getKeyIndex := func(bucket string) int {
+		if idx, ok := keyIndexes[bucket]; ok {
+			return idx
+		}
+		keys = append(keys, s.p.key(bucket))
+		keyIndexes[bucket] = len(keys)
+		return len(keys)
+	}
+	args := make([]any, 0, 4*len(s.reads)+2*len(s.scans)+5*len(changes)+4)
+	args = append(args, len(s.reads))
+	for k, v := range s.reads {
+		exists := "0"
+		if v != nil {
+			exists = "1"
+		}
+		args = append(args, getKeyIndex(k.bucket), k.key, exists, v)
+	}
+	args = append(args, len(s.scans))
+	for bucket, version := range s.scans {
+		args = append(args, bucket, version)
+	}
+	args = append(args, len(changes))
+	for _, c := range changes {
+		deleted := "0"
+		if c.deleted {
+			deleted = "1"
+		}
+		args = append(args, getKeyIndex(c.bucket), c.key, deleted, c.value, c.bucket)
+	}
+	messages := s.getChangeMessages(changes)
+	args = append(args, len(messages))
+	for _, msg := range messages {
+		args = append(args, s.p.key(redisChangesChannel), msg)
+	}
+	res, err := redisCommitScript.Run(s.ctx, s.p.client, keys, args...).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+func (s *redisSnapshot) getChangeMessages(changes []kvChange) [][]byte {
+	if config.IsShared != 1 {
+		return nil
+	}
+	var messages [][]byte
+	for _, c := range changes {
+		k := kvKey{bucket: c.bucket, key: c.key}
+		oldValue, ok := s.reads[k]
+		if !ok {
+			oldValue = s.values[k]
+		}
+		newValue := c.value
+		if c.deleted {
+			newValue = nil
+		}
+		if !isKVChangeRelevant(c.bucket, oldValue, newValue) {
+			continue
+		}
+		change := redisChange{
+			Bucket:  c.bucket,
+			Key:     c.key,
+			Value:   newValue,
+			Deleted: c.deleted,
+		}
+		if c.deleted {
+			change.Value = oldValue
+		}
+		msg, err := json.Marshal(change)
+		if err != nil {
+			providerLog(logger.LevelError, "unable to marshal change notification for key %q: %v", c.key, err)
+			continue
+		}
+		messages = append(messages, msg)
+	}
+	return messages
+}
+
+func (s *redisSnapshot) close() {
+	s.cancel()
+}
+
+func (p *RedisProvider) subscribe(ctx context.Context) {
+	channel := p.key(redisChangesChannel)
+	pubsub := p.client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	providerLog(logger.LevelDebug, "subscribed to redis channel %q", channel)
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			providerLog(logger.LevelDebug, "redis subscription stopped")
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				providerLog(logger.LevelWarn, "redis subscription closed")
+				return
+			}
+			var change redisChange
+			if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+				providerLog(logger.LevelError, "unable to decode redis change notification: %v", err)
+				continue
+			}
+			p.applyKVChange(change.Bucket, change.Key, change.Value, change.Deleted)
+		}
+	}
+}
+
+// casHashField sets the hash field to value if its current value matches
+// the expected one, nil means the field must not exist
+func (p *RedisProvider) casHashField(ctx context.Context, bucket, field string, expected, value []byte) (bool, error) {
+	res, err := redisCASScript.Run(ctx, p.client, []string{p.key(bucket)}, expected, field, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// deleteHashFields removes the hash fields for which the isExpired function
+// returns true. Fields modified after the check are preserved
+func (p *RedisProvider) deleteHashFields(bucket string, isExpired func(value []byte) (bool, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	values, err := p.client.HGetAll(ctx, p.key(bucket)).Result()
+	if err != nil {
+		return err
+	}
+	for field, value := range values {
+		expired, err := isExpired([]byte(value))
+		if err != nil {
+			providerLog(logger.LevelError, "unable to decode field %q in bucket %q: %v", field, bucket, err)
+			continue
+		}
+		if !expired {
+			continue
+		}
+		if _, err := redisDeleteIfEqualScript.Run(ctx, p.client, []string{p.key(bucket)}, field, value).Result(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redisDeleteIfEqualScript removes the hash field ARGV[1] if its value is ARGV[2]
+var redisDeleteIfEqualScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+func (p *RedisProvider) addSharedSession(session Session) error {
+	if err := session.validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(session.Data)
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(kvSession{
+		Key:       session.Key,
+		Data:      data,
+		Type:      session.Type,
+		Timestamp: session.Timestamp,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	return p.client.HSet(ctx, p.key(kvSessionsBucket), session.Key, buf).Err()
+}
+
+func (p *RedisProvider) deleteSharedSession(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	n, err := p.client.HDel(ctx, p.key(kvSessionsBucket), key).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return util.NewRecordNotFoundError(fmt.Sprintf("session %q does not exist", key))
+	}
+	return nil
+}
+
+func (p *RedisProvider) getSharedSession(key string) (Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	buf, err := p.client.HGet(ctx, p.key(kvSessionsBucket), key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return Session{}, util.NewRecordNotFoundError(fmt.Sprintf("session %q does not exist", key))
+		}
+		return Session{}, err
+	}
+	var session kvSession
+	if err := json.Unmarshal(buf, &session); err != nil {
+		return Session{}, err
+	}
+	return Session{
+		Key:       session.Key,
+		Data:      session.Data,
+		Type:      session.Type,
+		Timestamp: session.Timestamp,
+	}, nil
+}
+
+func (p *RedisProvider) getSharedSessions(sessionType SessionType) ([]Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	values, err := p.client.HVals(ctx, p.key(kvSessionsBucket)).Result()
+	if err != nil {
+		return nil, err
+	}
+	var sessions []Session
+	for _, value := range values {
+		var session kvSession
+		if err := json.Unmarshal([]byte(value), &session); err != nil {
+			return sessions, err
+		}
+		if session.Type != sessionType {
+			continue
+		}
+		sessions = append(sessions, Session{
+			Key:       session.Key,
+			Data:      session.Data,
+			Type:      session.Type,
+			Timestamp: session.Timestamp,
+		})
+	}
+	return sessions, nil
+}
+
+func (p *RedisProvider) cleanupSharedSessions(sessionType SessionType, before int64) error {
+	return p.deleteHashFields(kvSessionsBucket, func(value []byte) (bool, error) {
+		var session kvSession
+		if err := json.Unmarshal(value, &session); err != nil {
+			return false, err
+		}
+		return session.Type == sessionType && session.Timestamp < before, nil
+	})
+}
+
+func (p *RedisProvider) addActiveTransfer(transfer ActiveTransfer) error {
+	now := util.GetTimeAsMsSinceEpoch(time.Now())
+	transfer.CreatedAt = now
+	transfer.UpdatedAt = now
+	buf, err := json.Marshal(transfer)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	return p.client.HSet(ctx, p.key(kvTransfersBucket), p.getTransferField(transfer.ID, transfer.ConnID), buf).Err()
+}
+
+func (p *RedisProvider) updateActiveTransferSizes(ulSize, dlSize, transferID int64, connectionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	field := p.getTransferField(transferID, connectionID)
+	current, err := p.client.HGet(ctx, p.key(kvTransfersBucket), field).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		return err
+	}
+	var transfer ActiveTransfer
+	if err := json.Unmarshal(current, &transfer); err != nil {
+		return err
+	}
+	transfer.CurrentULSize = ulSize
+	transfer.CurrentDLSize = dlSize
+	transfer.UpdatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	buf, err := json.Marshal(transfer)
+	if err != nil {
+		return err
+	}
+	// the transfer could be removed in the meantime, we don't want to add it again
+	_, err = p.casHashField(ctx, kvTransfersBucket, field, current, buf)
+	return err
+}
+
+func (p *RedisProvider) removeActiveTransfer(transferID int64, connectionID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	return p.client.HDel(ctx, p.key(kvTransfersBucket), p.getTransferField(transferID, connectionID)).Err()
+}
+
+func (p *RedisProvider) cleanupActiveTransfers(before time.Time) error {
+	updatedBefore := util.GetTimeAsMsSinceEpoch(before)
+	return p.deleteHashFields(kvTransfersBucket, func(value []byte) (bool, error) {
+		var transfer ActiveTransfer
+		if err := json.Unmarshal(value, &transfer); err != nil {
+			return false, err
+		}
+		return transfer.UpdatedAt < updatedBefore, nil
+	})
+}
+
+func (p *RedisProvider) getActiveTransfers(from time.Time) ([]ActiveTransfer, error) {
+	transfers := make([]ActiveTransfer, 0, 30)
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	values, err := p.client.HVals(ctx, p.key(kvTransfersBucket)).Result()
+	if err != nil {
+		return nil, err
+	}
+	updatedAfter := util.GetTimeAsMsSinceEpoch(from)
+	for _, value := range values {
+		var transfer ActiveTransfer
+		if err := json.Unmarshal([]byte(value), &transfer); err != nil {
+			return transfers, err
+		}
+		if transfer.UpdatedAt > updatedAfter {
+			transfers = append(transfers, transfer)
+		}
+	}
+	return transfers, nil
+}
+
+func (p *RedisProvider) getTransferField(transferID int64, connectionID string) string {
+	return connectionID + ":" + strconv.FormatInt(transferID, 10)
+}
+
+func (p *RedisProvider) getTaskByName(name string) (Task, error) {
+	task, _, err := p.getTask(name)
+	return task, err
+}
+
+func (p *RedisProvider) getTask(name string) (Task, []byte, error) {
+	task := Task{
+		Name: name,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	buf, err := p.client.HGet(ctx, p.key(kvTasksBucket), name).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return task, nil, util.NewRecordNotFoundError(fmt.Sprintf("task %q does not exist", name))
+		}
+		return task, nil, err
+	}
+	err = json.Unmarshal(buf, &task)
+	return task, buf, err
+}
+
+func (p *RedisProvider) addTask(name string) error {
+	buf, err := json.Marshal(Task{
+		Name:     name,
+		UpdateAt: util.GetTimeAsMsSinceEpoch(time.Now()),
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	added, err := p.client.HSetNX(ctx, p.key(kvTasksBucket), name, buf).Result()
+	if err != nil {
+		return err
+	}
+	if !added {
+		return fmt.Errorf("%w: task %q already exists", ErrDuplicatedKey, name)
+	}
+	return nil
+}
+
+func (p *RedisProvider) updateTask(name string, version int64) error {
+	return p.updateTaskInternal(name, func(task *Task) bool {
+		if task.Version != version {
+			return false
+		}
+		task.Version++
+		return true
+	})
+}
+
+func (p *RedisProvider) updateTaskTimestamp(name string) error {
+	return p.updateTaskInternal(name, func(_ *Task) bool {
+		return true
+	})
+}
+
+// updateTaskInternal updates the task with the specified name if the update
+// function returns true, the task must not be modified concurrently
+func (p *RedisProvider) updateTaskInternal(name string, update func(task *Task) bool) error {
+	task, current, err := p.getTask(name)
+	if err != nil {
+		return err
+	}
+	if !update(&task) {
+		return util.NewRecordNotFoundError(fmt.Sprintf("task %q was updated concurrently", name))
+	}
+	task.UpdateAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	buf, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	updated, err := p.casHashField(ctx, kvTasksBucket, name, current, buf)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return util.NewRecordNotFoundError(fmt.Sprintf("task %q was updated concurrently", name))
+	}
+	return nil
+}
+
+func (p *RedisProvider) addNode() error {
+	if err := currentNode.validate(); err != nil {
+		return fmt.Errorf("unable to register cluster node: %w", err)
+	}
+	now := util.GetTimeAsMsSinceEpoch(time.Now())
+	buf, err := json.Marshal(Node{
+		Name:      currentNode.Name,
+		Data:      currentNode.Data,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	if err = p.client.HSet(ctx, p.key(kvNodesBucket), currentNode.Name, buf).Err(); err != nil {
+		return fmt.Errorf("unable to register cluster node: %w", err)
+	}
+	providerLog(logger.LevelInfo, "registered as cluster node %q, port: %d, proto: %s",
+		currentNode.Name, currentNode.Data.Port, currentNode.Data.Proto)
+
+	return nil
+}
+
+func (p *RedisProvider) getNodeByName(name string) (Node, error) {
+	var node Node
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	buf, err := p.client.HGet(ctx, p.key(kvNodesBucket), name).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return node, util.NewRecordNotFoundError(fmt.Sprintf("node %q does not exist", name))
+		}
+		return node, err
+	}
+	if err := json.Unmarshal(buf, &node); err != nil {
+		return node, err
+	}
+	if node.UpdatedAt <= util.GetTimeAsMsSinceEpoch(time.Now().Add(activeNodeTimeDiff)) {
+		return node, util.NewRecordNotFoundError(fmt.Sprintf("node %q is not active", name))
+	}
+	return node, nil
+}
+
+func (p *RedisProvider) getNodes() ([]Node, error) {
+	var nodes []Node
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	values, err := p.client.HVals(ctx, p.key(kvNodesBucket)).Result()
+	if err != nil {
+		return nodes, err
+	}
+	activeAfter := util.GetTimeAsMsSinceEpoch(time.Now().Add(activeNodeTimeDiff))
+	for _, value := range values {
+		var node Node
+		if err := json.Unmarshal([]byte(value), &node); err != nil {
+			return nodes, err
+		}
+		if node.Name == currentNode.Name || node.UpdatedAt <= activeAfter {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (p *RedisProvider) updateNodeTimestamp() error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	key := p.key(kvNodesBucket)
+	buf, err := p.client.HGet(ctx, key, currentNode.Name).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return util.NewRecordNotFoundError(fmt.Sprintf("node %q does not exist", currentNode.Name))
+		}
+		return err
+	}
+	var node Node
+	if err := json.Unmarshal(buf, &node); err != nil {
+		return err
+	}
+	node.UpdatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	buf, err = json.Marshal(node)
+	if err != nil {
+		return err
+	}
+	return p.client.HSet(ctx, key, currentNode.Name, buf).Err()
+}
+
+func (p *RedisProvider) cleanupNodes() error {
+	updatedBefore := util.GetTimeAsMsSinceEpoch(time.Now().Add(10 * activeNodeTimeDiff))
+	return p.deleteHashFields(kvNodesBucket, func(value []byte) (bool, error) {
+		var node Node
+		if err := json.Unmarshal(value, &node); err != nil {
+			return false, err
+		}
+		return node.UpdatedAt < updatedBefore, nil
+	})
+}
+
+func (p *RedisProvider) close() error {
+	p.cancel()
+	return p.client.Close()
+}
+
+func (p *RedisProvider) reloadConfig() error {
+	return nil
+}
+
+func (p *RedisProvider) resetDatabase() error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	buckets := []string{kvUsersBucket, kvGroupsBucket, kvFoldersBucket, kvAdminsBucket, kvAPIKeysBucket,
+		kvSharesBucket, kvActionsBucket, kvRulesBucket, kvRolesBucket, kvIPListsBucket, kvConfigsBucket,
+		kvDBVersionBucket, kvSequencesBucket, kvSessionsBucket, kvTransfersBucket, kvNodesBucket,
+		kvTasksBucket, redisVersionsKey}
+	keys := make([]string, 0, len(buckets))
+	for _, bucket := range buckets {
+		keys = append(keys, p.key(bucket))
+	}
+	// all the keys share the same hash tag, so they can be removed at once
+	// in cluster mode too
+	if err := p.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("unable to remove keys with prefix %q: %w", p.prefix, err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !noredis
+// +build !noredis
+
+package dataprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestRedisProvider(t *testing.T) (*RedisProvider, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{
+		Addr: server.Addr(),
+	})
+	t.Cleanup(func() {
+		client.Close()
+	})
+	p := &RedisProvider{
+		client: client,
+		prefix: kvDefaultKeyPrefix,
+		cancel: func() {},
+	}
+	p.kvProvider = &kvProvider{store: p}
+	return p, server
+}
+
+func TestRedisProvider(t *testing.T) {
+	p, server := getTestRedisProvider(t)
+	setKVTestProvider(t, p)
+
+	testKVProvider(t, p.kvProvider)
+	testKVConflict(t, p)
+	// all the keys must share the same hash tag
+	for _, key := range server.Keys() {
+		assert.Contains(t, key, "{"+kvDefaultKeyPrefix+"}:")
+	}
+}
+
+func TestRedisPersistence(t *testing.T) {
+	p, _ := getTestRedisProvider(t)
+	setKVTestProvider(t, p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), kvRequestTimeout)
+	defer cancel()
+
+	config.RedisPersistence = redisPersistenceNone
+	err := checkRedisPersistence(ctx, p.client)
+	assert.NoError(t, err)
+	// miniredis has no persistence
+	config.RedisPersistence = redisPersistenceAny
+	err = checkRedisPersistence(ctx, p.client)
+	assert.Error(t, err)
+	assert.Equal(t, "yes", getRedisInfoValue("# Persistence\r\naof_enabled:yes\r\n", "aof_enabled"))
+	assert.Empty(t, getRedisInfoValue("aof_enabled:yes", "rdb_changes_since_last_save"))
+}
+
+func TestRedisTasksAndSessions(t *testing.T) {
+	p, _ := getTestRedisProvider(t)
+	setKVTestProvider(t, p)
+
+	err := p.addTask("task")
+	require.NoError(t, err)
+	err = p.addTask("task")
+	assert.Error(t, err)
+	task, err := p.getTaskByName("task")
+	require.NoError(t, err)
+	err = p.updateTask(task.Name, task.Version)
+	assert.NoError(t, err)
+	// the version is now changed, a stale update must fail
+	err = p.updateTask(task.Name, task.Version)
+	assert.Error(t, err)
+
+	session := Session{
+		Key:       "session_key",
+		Data:      "data",
+		Type:      SessionTypeOIDCAuth,
+		Timestamp: time.Now().Add(-time.Hour).UnixMilli(),
+	}
+	err = p.addSharedSession(session)
+	require.NoError(t, err)
+	s, err := p.getSharedSession(session.Key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`"data"`), s.Data)
+	sessions, err := p.getSharedSessions(SessionTypeOIDCAuth)
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	err = p.cleanupSharedSessions(SessionTypeOIDCAuth, time.Now().UnixMilli())
+	require.NoError(t, err)
+	_, err = p.getSharedSession(session.Key)
+	assert.Error(t, err)
+	err = p.deleteSharedSession(session.Key)
+	assert.Error(t, err)
+}
@@ -0,0 +1,283 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/kms"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+var rekeyMgr rekeyManager
+
+// RekeyStatus defines the status of the last secrets re-key
+type RekeyStatus struct {
+	IsRunning bool `json:"is_running"`
+	// Unix timestamps in milliseconds
+	StartTime int64 `json:"start_time,omitempty"`
+	EndTime   int64 `json:"end_time,omitempty"`
+	// Number of updated objects
+	Updated int      `json:"updated"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+type rekeyManager struct {
+	running atomic.Bool
+	mu      sync.RWMutex
+	status  RekeyStatus
+}
+
+func (m *rekeyManager) getStatus() RekeyStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	status := m.status
+	status.IsRunning = m.running.Load()
+	status.Errors = append([]string(nil), m.status.Errors...)
+	return status
+}
+
+func (m *rekeyManager) onObjectDone(objectType, name string, updated bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err != nil {
+		providerLog(logger.LevelError, "unable to re-key secrets for %s %q: %v", objectType, name, err)
+		m.status.Errors = append(m.status.Errors, fmt.Sprintf("%s %q: %v", objectType, name, err))
+		return
+	}
+	if updated {
+		providerLog(logger.LevelInfo, "secrets re-keyed for %s %q", objectType, name)
+		m.status.Updated++
+	}
+}
+
+func (m *rekeyManager) start(force bool) bool {
+	if !m.running.CompareAndSwap(false, true) {
+		return false
+	}
+	m.mu.Lock()
+	m.status = RekeyStatus{
+		StartTime: util.GetTimeAsMsSinceEpoch(time.Now()),
+	}
+	m.mu.Unlock()
+
+	go m.run(force)
+	return true
+}
+
+func (m *rekeyManager) run(force bool) {
+	defer m.running.Store(false)
+
+	startTime := time.Now()
+	providerLog(logger.LevelInfo, "secrets re-key started, force: %t", force)
+	m.rekeyUsers(force)
+	m.rekeyFolders(force)
+	m.rekeyGroups(force)
+	m.rekeyAdmins(force)
+	m.rekeyEventActions(force)
+	m.rekeyConfigs(force)
+
+	m.mu.Lock()
+	m.status.EndTime = util.GetTimeAsMsSinceEpoch(time.Now())
+	providerLog(logger.LevelInfo, "secrets re-key completed, updated objects: %d, errors: %d, elapsed: %s",
+		m.status.Updated, len(m.status.Errors), time.Since(startTime))
+	m.mu.Unlock()
+}
+
+// the objects are loaded again before updating them to minimize the chance
+// of overwriting concurrent changes
+func (m *rekeyManager) rekeyUsers(force bool) {
+	users, err := provider.dumpUsers()
+	if err != nil {
+		m.onObjectDone(actionObjectUser, "*", false, err)
+		return
+	}
+	for idx := range users {
+		user, err := provider.userExists(users[idx].Username, "")
+		if err != nil {
+			m.onObjectDone(actionObjectUser, users[idx].Username, false, err)
+			continue
+		}
+		secrets := user.FsConfig.GetSecrets()
+		secrets = append(secrets, user.Filters.TOTPConfig.Secret)
+		for _, code := range user.Filters.RecoveryCodes {
+			secrets = append(secrets, code.Secret)
+		}
+		if user.Filters.S3Credentials != nil {
+			secrets = append(secrets, user.Filters.S3Credentials.Secret)
+		}
+		updated, err := rekeySecrets(secrets, force)
+		if err == nil && updated {
+			err = provider.updateUser(&user)
+			if err == nil {
+				webDAVUsersCache.swap(&user, "")
+			}
+		}
+		m.onObjectDone(actionObjectUser, user.Username, updated, err)
+	}
+}
+
+func (m *rekeyManager) rekeyFolders(force bool) {
+	folders, err := provider.dumpFolders()
+	if err != nil {
+		m.onObjectDone(actionObjectFolder, "*", false, err)
+		return
+	}
+	for idx := range folders {
+		folder, err := provider.getFolderByName(folders[idx].Name)
+		if err != nil {
+			m.onObjectDone(actionObjectFolder, folders[idx].Name, false, err)
+			continue
+		}
+		updated, err := rekeySecrets(folder.FsConfig.GetSecrets(), force)
+		if err == nil && updated {
+			err = provider.updateFolder(&folder)
+			if err == nil {
+				for _, username := range folder.Users {
+					RemoveCachedWebDAVUser(username)
+				}
+			}
+		}
+		m.onObjectDone(actionObjectFolder, folder.Name, updated, err)
+	}
+}
+
+func (m *rekeyManager) rekeyGroups(force bool) {
+	groups, err := provider.dumpGroups()
+	if err != nil {
+		m.onObjectDone(actionObjectGroup, "*", false, err)
+		return
+	}
+	for idx := range groups {
+		group, err := provider.groupExists(groups[idx].Name)
+		if err != nil {
+			m.onObjectDone(actionObjectGroup, groups[idx].Name, false, err)
+			continue
+		}
+		updated, err := rekeySecrets(group.UserSettings.FsConfig.GetSecrets(), force)
+		if err == nil && updated {
+			err = provider.updateGroup(&group)
+			if err == nil {
+				for _, username := range group.Users {
+					RemoveCachedWebDAVUser(username)
+				}
+			}
+		}
+		m.onObjectDone(actionObjectGroup, group.Name, updated, err)
+	}
+}
+
+func (m *rekeyManager) rekeyAdmins(force bool) {
+	admins, err := provider.dumpAdmins()
+	if err != nil {
+		m.onObjectDone(actionObjectAdmin, "*", false, err)
+		return
+	}
+	for idx := range admins {
+		admin, err := provider.adminExists(admins[idx].Username)
+		if err != nil {
+			m.onObjectDone(actionObjectAdmin, admins[idx].Username, false, err)
+			continue
+		}
+		secrets := []*kms.Secret{admin.Filters.TOTPConfig.Secret}
+		for _, code := range admin.Filters.RecoveryCodes {
+			secrets = append(secrets, code.Secret)
+		}
+		updated, err := rekeySecrets(secrets, force)
+		if err == nil && updated {
+			err = provider.updateAdmin(&admin)
+		}
+		m.onObjectDone(actionObjectAdmin, admin.Username, updated, err)
+	}
+}
+
+func (m *rekeyManager) rekeyEventActions(force bool) {
+	actions, err := provider.dumpEventActions()
+	if err != nil {
+		m.onObjectDone(actionObjectEventAction, "*", false, err)
+		return
+	}
+	for idx := range actions {
+		action, err := provider.eventActionExists(actions[idx].Name)
+		if err != nil {
+			m.onObjectDone(actionObjectEventAction, actions[idx].Name, false, err)
+			continue
+		}
+		secrets := []*kms.Secret{action.Options.HTTPConfig.Password, action.Options.BackupConfig.Passphrase,
+			action.Options.ChatConfig.Token, action.Options.MessageConfig.Password}
+		updated, err := rekeySecrets(secrets, force)
+		if err == nil && updated {
+			err = provider.updateEventAction(&action)
+		}
+		m.onObjectDone(actionObjectEventAction, action.Name, updated, err)
+	}
+}
+
+func (m *rekeyManager) rekeyConfigs(force bool) {
+	configs, err := provider.getConfigs()
+	if err != nil {
+		m.onObjectDone(actionObjectConfigs, "configs", false, err)
+		return
+	}
+	var secrets []*kms.Secret
+	if configs.SMTP != nil {
+		secrets = append(secrets, configs.SMTP.Password, configs.SMTP.OAuth2.ClientSecret,
+			configs.SMTP.OAuth2.RefreshToken)
+	}
+	updated, err := rekeySecrets(secrets, force)
+	if err == nil && updated {
+		err = provider.setConfigs(&configs)
+	}
+	m.onObjectDone(actionObjectConfigs, "configs", updated, err)
+}
+
+// rekeySecrets encrypts the given secrets using the configured KMS and
+// returns true if at least one of them was changed. Nil secrets are ignored
+func rekeySecrets(secrets []*kms.Secret, force bool) (bool, error) {
+	var updated bool
+	for _, secret := range secrets {
+		if secret == nil {
+			continue
+		}
+		changed, err := secret.Rekey(force)
+		if err != nil {
+			return false, err
+		}
+		if changed {
+			updated = true
+		}
+	}
+	return updated, nil
+}
+
+// StartRekey starts, in background, the re-key of all the encrypted secrets
+// stored in the data provider using the configured KMS. If force is true the
+// secrets already encrypted with the configured KMS are encrypted again too,
+// for example after a master key rotation.
+// It returns false if another re-key is already in progress
+func StartRekey(force bool) bool {
+	return rekeyMgr.start(force)
+}
+
+// GetRekeyStatus returns the status of the current or last secrets re-key
+func GetRekeyStatus() RekeyStatus {
+	return rekeyMgr.getStatus()
+}
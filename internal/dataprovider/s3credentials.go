@@ -0,0 +1,102 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/kms"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// UserS3Credentials defines the credentials used to authenticate against the S3
+// compatible gateway. The access key ID is the username
+type UserS3Credentials struct {
+	// Secret access key used to sign the requests
+	Secret *kms.Secret `json:"secret"`
+	// Creation time as unix timestamp in milliseconds
+	CreatedAt int64 `json:"created_at"`
+}
+
+func (c *UserS3Credentials) getACopy() *UserS3Credentials {
+	if c == nil {
+		return nil
+	}
+	return &UserS3Credentials{
+		Secret:    c.Secret.Clone(),
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+func (c *UserS3Credentials) validate(username string) error {
+	if c.Secret == nil || c.Secret.IsEmpty() {
+		return util.NewValidationError("s3 credentials: secret is mandatory")
+	}
+	if !c.Secret.IsValid() {
+		return util.NewValidationError("s3 credentials: invalid secret")
+	}
+	if c.Secret.IsPlain() {
+		c.Secret.SetAdditionalData(username)
+		if err := c.Secret.Encrypt(); err != nil {
+			return util.NewValidationError(fmt.Sprintf("s3 credentials: unable to encrypt secret: %v", err))
+		}
+	}
+	if c.CreatedAt == 0 {
+		c.CreatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	}
+	return nil
+}
+
+func validateUserS3Credentials(user *User) error {
+	if user.Filters.S3Credentials == nil {
+		return nil
+	}
+	return user.Filters.S3Credentials.validate(user.Username)
+}
+
+// GetUserForS3Auth returns the user with the specified access key ID, the group
+// settings applied, and the decrypted secret access key to use to verify the
+// request signature
+func GetUserForS3Auth(accessKey, ip, protocol string) (User, string, error) {
+	var user User
+	var err error
+	username := config.convertName(accessKey)
+	if config.PreLoginHook != "" {
+		user, err = executePreLoginHook(username, LoginMethodS3AccessKey, ip, protocol, nil)
+	} else {
+		user, err = UserExists(username, "")
+	}
+	if err != nil {
+		return user, "", err
+	}
+	if err := user.LoadAndApplyGroupSettings(); err != nil {
+		return user, "", err
+	}
+	if err := user.CheckLoginConditions(); err != nil {
+		return user, "", err
+	}
+	if user.Filters.S3Credentials == nil || user.Filters.S3Credentials.Secret.IsEmpty() {
+		return user, "", errors.New("no s3 credentials defined")
+	}
+	secret := user.Filters.S3Credentials.Secret.Clone()
+	if err := secret.TryDecrypt(); err != nil {
+		providerLog(logger.LevelError, "unable to decrypt s3 secret for user %q: %v", user.Username, err)
+		return user, "", ErrInvalidCredentials
+	}
+	return user, secret.GetPayload(), nil
+}
@@ -68,6 +68,44 @@ func startScheduler() error {
 	if err != nil {
 		return fmt.Errorf("unable to schedule nodes cleanup: %w", err)
 	}
+	if config.AuditTrail.Enabled && config.AuditTrail.RetentionDays > 0 {
+		_, err = scheduler.AddFunc("@every 1h", cleanupAuditLogs)
+		if err != nil {
+			return fmt.Errorf("unable to schedule audit logs cleanup: %w", err)
+		}
+	}
+	if config.ShareAnalytics.Enabled && config.ShareAnalytics.RetentionDays > 0 {
+		_, err = scheduler.AddFunc("@every 1h", cleanupShareDownloads)
+		if err != nil {
+			return fmt.Errorf("unable to schedule share downloads cleanup: %w", err)
+		}
+	}
+	if config.Webhooks.Enabled {
+		_, err = scheduler.AddFunc("@every 10s", processWebhookDeliveries)
+		if err != nil {
+			return fmt.Errorf("unable to schedule webhook deliveries: %w", err)
+		}
+		if config.Webhooks.RetentionDays > 0 {
+			_, err = scheduler.AddFunc("@every 1h", cleanupWebhookDeliveries)
+			if err != nil {
+				return fmt.Errorf("unable to schedule webhook deliveries cleanup: %w", err)
+			}
+		}
+	}
+	if config.Reconciler.isEnabled() {
+		_, err = scheduler.AddFunc(fmt.Sprintf("@every %dm", config.Reconciler.Interval), runReconciler)
+		if err != nil {
+			return fmt.Errorf("unable to schedule the reconciler: %w", err)
+		}
+		go runReconciler()
+	}
+	if config.LDAPSync.isEnabled() {
+		_, err = scheduler.AddFunc(fmt.Sprintf("@every %dm", config.LDAPSync.Interval), runLDAPSync)
+		if err != nil {
+			return fmt.Errorf("unable to schedule LDAP sync: %w", err)
+		}
+		go runLDAPSync()
+	}
 	scheduler.Start()
 	return nil
 }
@@ -97,6 +135,26 @@ func checkDataprovider() {
 	metric.UpdateDataProviderAvailability(err)
 }
 
+func cleanupAuditLogs() {
+	before := time.Now().Add(-time.Duration(config.AuditTrail.RetentionDays) * 24 * time.Hour)
+	err := provider.cleanupAuditLogs(util.GetTimeAsMsSinceEpoch(before))
+	if err != nil {
+		providerLog(logger.LevelError, "unable to cleanup audit logs: %v", err)
+	} else {
+		providerLog(logger.LevelDebug, "cleanup audit logs older than %s ok", before)
+	}
+}
+
+func cleanupShareDownloads() {
+	before := time.Now().Add(-time.Duration(config.ShareAnalytics.RetentionDays) * 24 * time.Hour)
+	err := provider.cleanupShareDownloads(util.GetTimeAsMsSinceEpoch(before))
+	if err != nil {
+		providerLog(logger.LevelError, "unable to cleanup share downloads: %v", err)
+	} else {
+		providerLog(logger.LevelDebug, "cleanup share downloads older than %s ok", before)
+	}
+}
+
 func checkCacheUpdates() {
 	checkUserCache()
 	checkIPListEntryCache()
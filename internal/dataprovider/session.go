@@ -29,6 +29,9 @@ const (
 	SessionTypeResetCode
 	SessionTypeOAuth2Auth
 	SessionTypeInvalidToken
+	SessionTypeUploadState
+	SessionTypeShareVerification
+	SessionTypeAuthSession
 )
 
 // Session defines a shared session persisted in the data provider
@@ -43,7 +46,7 @@ func (s *Session) validate() error {
 	if s.Key == "" {
 		return errors.New("unable to save a session with an empty key")
 	}
-	if s.Type < SessionTypeOIDCAuth || s.Type > SessionTypeInvalidToken {
+	if s.Type < SessionTypeOIDCAuth || s.Type > SessionTypeAuthSession {
 		return fmt.Errorf("invalid session type: %v", s.Type)
 	}
 	return nil
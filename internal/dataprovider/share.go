@@ -37,12 +37,163 @@ const (
 	ShareScopeRead ShareScope = iota + 1
 	ShareScopeWrite
 	ShareScopeReadWrite
+	// files can be browsed and previewed in the WebClient but not downloaded
+	ShareScopeView
 )
 
 const (
 	redactedPassword = "[**redacted**]"
+	// default number of attempts allowed to enter an emailed share access code
+	defaultShareVerificationAttempts = 3
+	maxShareVerificationAttempts     = 10
+	maxShareUploaderNameLength       = 100
 )
 
+// ShareEmailVerification defines the email verification required before granting
+// access to a share. The recipients enter their email address and get a one-time
+// code that must be entered to access the share
+type ShareEmailVerification struct {
+	// Email addresses allowed to access the share. An entire domain can be allowed
+	// using the "@example.com" notation. Empty means email verification disabled
+	AllowedEmails []string `json:"allowed_emails,omitempty"`
+	// Maximum number of attempts to enter the emailed code. 0 means the default (3)
+	MaxAttempts int `json:"max_attempts,omitempty"`
+}
+
+// IsEnabled returns true if email verification is required to access the share
+func (v *ShareEmailVerification) IsEnabled() bool {
+	return len(v.AllowedEmails) > 0
+}
+
+// IsEmailAllowed returns true if the specified email address is allowed to access the share
+func (v *ShareEmailVerification) IsEmailAllowed(email string) bool {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !util.IsEmailValid(email) {
+		return false
+	}
+	for _, allowed := range v.AllowedEmails {
+		if strings.HasPrefix(allowed, "@") {
+			if strings.HasSuffix(email, allowed) {
+				return true
+			}
+			continue
+		}
+		if email == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMaxAttempts returns the maximum number of attempts allowed to enter the emailed code
+func (v *ShareEmailVerification) GetMaxAttempts() int {
+	if v.MaxAttempts <= 0 {
+		return defaultShareVerificationAttempts
+	}
+	return v.MaxAttempts
+}
+
+// GetAllowedEmailsAsString returns the allowed email addresses as comma separated string
+func (v *ShareEmailVerification) GetAllowedEmailsAsString() string {
+	return strings.Join(v.AllowedEmails, ",")
+}
+
+func (v *ShareEmailVerification) getACopy() ShareEmailVerification {
+	emails := make([]string, len(v.AllowedEmails))
+	copy(emails, v.AllowedEmails)
+
+	return ShareEmailVerification{
+		AllowedEmails: emails,
+		MaxAttempts:   v.MaxAttempts,
+	}
+}
+
+func (v *ShareEmailVerification) validate() error {
+	var emails []string
+	for _, email := range v.AllowedEmails {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if email == "" {
+			continue
+		}
+		if strings.HasPrefix(email, "@") {
+			if !util.IsEmailValid("user" + email) {
+				return util.NewValidationError(fmt.Sprintf("invalid allowed email domain %q", email))
+			}
+		} else if !util.IsEmailValid(email) {
+			return util.NewValidationError(fmt.Sprintf("invalid allowed email %q", email))
+		}
+		emails = append(emails, email)
+	}
+	v.AllowedEmails = util.RemoveDuplicates(emails, false)
+	if !v.IsEnabled() {
+		v.MaxAttempts = 0
+		return nil
+	}
+	if v.MaxAttempts < 0 || v.MaxAttempts > maxShareVerificationAttempts {
+		return util.NewValidationError(fmt.Sprintf("invalid email verification max attempts %d, allowed range: 0-%d",
+			v.MaxAttempts, maxShareVerificationAttempts))
+	}
+	return nil
+}
+
+// ShareFileRequest defines how an upload only share collects files from external users
+type ShareFileRequest struct {
+	// Require the uploaders to enter their name and email address
+	RequireUploaderInfo bool `json:"require_uploader_info,omitempty"`
+	// Store the files uploaded by each uploader in a dedicated subdirectory,
+	// the uploader info is required
+	UploaderDirs bool `json:"uploader_dirs,omitempty"`
+	// Notify the share owner, via email, each time an uploader completes a drop
+	NotifyOwner bool `json:"notify_owner,omitempty"`
+}
+
+// IsEnabled returns true if at least a file request option is set
+func (f *ShareFileRequest) IsEnabled() bool {
+	return f.RequireUploaderInfo || f.UploaderDirs || f.NotifyOwner
+}
+
+// CheckUploader validates the specified uploader name and email and returns them
+// normalized. Empty values are allowed if the uploader info is not required
+func (f *ShareFileRequest) CheckUploader(name, email string) (string, string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	email = strings.ToLower(strings.TrimSpace(email))
+	if name == "" && email == "" && !f.RequireUploaderInfo {
+		return "", "", nil
+	}
+	if name == "" || len(name) > maxShareUploaderNameLength || strings.ContainsAny(name, "/\\") {
+		return "", "", util.NewI18nError(util.NewValidationError(fmt.Sprintf("invalid uploader name %q", name)),
+			util.I18nErrorShareUploaderName)
+	}
+	if !util.IsEmailValid(email) {
+		return "", "", util.NewI18nError(util.NewValidationError(fmt.Sprintf("invalid uploader email %q", email)),
+			util.I18nErrorShareUploaderEmail)
+	}
+	return name, email, nil
+}
+
+// GetUploaderDir returns the name of the subdirectory for the specified uploader,
+// an empty string means that the files must be stored in the shared directory
+func (f *ShareFileRequest) GetUploaderDir(name, email string) string {
+	if !f.UploaderDirs || email == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", name, strings.ReplaceAll(email, "/", "_"))
+}
+
+func (f *ShareFileRequest) validate(scope ShareScope) error {
+	if !f.IsEnabled() {
+		return nil
+	}
+	if scope != ShareScopeWrite {
+		return util.NewI18nError(util.NewValidationError("file requests require the write share scope"),
+			util.I18nErrorShareFileRequestScope)
+	}
+	if f.UploaderDirs {
+		f.RequireUploaderInfo = true
+	}
+	return nil
+}
+
 // Share defines files and or directories shared with external users
 type Share struct {
 	// Database unique identifier
@@ -52,7 +203,8 @@ type Share struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description,omitempty"`
 	Scope       ShareScope `json:"scope"`
-	// Paths to files or directories, for ShareScopeWrite it must be exactly one directory
+	// Paths to files or directories, for ShareScopeWrite, ShareScopeReadWrite and
+	// ShareScopeView it must be exactly one directory
 	Paths []string `json:"paths"`
 	// Username who shared this object
 	Username  string `json:"username"`
@@ -70,6 +222,10 @@ type Share struct {
 	UsedTokens int `json:"used_tokens,omitempty"`
 	// Limit the share availability to these IPs/CIDR networks
 	AllowFrom []string `json:"allow_from,omitempty"`
+	// Email verification required before granting access
+	EmailVerification ShareEmailVerification `json:"email_verification,omitempty"`
+	// File request options for upload only shares
+	FileRequest ShareFileRequest `json:"file_request,omitempty"`
 	// set for restores, we don't have to validate the expiration date
 	// otherwise we fail to restore existing shares and we have to insert
 	// all the previous values with no modifications
@@ -94,21 +250,23 @@ func (s *Share) getACopy() Share {
 	copy(allowFrom, s.AllowFrom)
 
 	return Share{
-		ID:          s.ID,
-		ShareID:     s.ShareID,
-		Name:        s.Name,
-		Description: s.Description,
-		Scope:       s.Scope,
-		Paths:       s.Paths,
-		Username:    s.Username,
-		CreatedAt:   s.CreatedAt,
-		UpdatedAt:   s.UpdatedAt,
-		LastUseAt:   s.LastUseAt,
-		ExpiresAt:   s.ExpiresAt,
-		Password:    s.Password,
-		MaxTokens:   s.MaxTokens,
-		UsedTokens:  s.UsedTokens,
-		AllowFrom:   allowFrom,
+		ID:                s.ID,
+		ShareID:           s.ShareID,
+		Name:              s.Name,
+		Description:       s.Description,
+		Scope:             s.Scope,
+		Paths:             s.Paths,
+		Username:          s.Username,
+		CreatedAt:         s.CreatedAt,
+		UpdatedAt:         s.UpdatedAt,
+		LastUseAt:         s.LastUseAt,
+		ExpiresAt:         s.ExpiresAt,
+		Password:          s.Password,
+		MaxTokens:         s.MaxTokens,
+		UsedTokens:        s.UsedTokens,
+		AllowFrom:         allowFrom,
+		EmailVerification: s.EmailVerification.getACopy(),
+		FileRequest:       s.FileRequest,
 	}
 }
 
@@ -182,7 +340,7 @@ func (s *Share) validatePaths() error {
 		s.Paths[idx] = util.CleanPath(s.Paths[idx])
 	}
 	s.Paths = util.RemoveDuplicates(s.Paths, false)
-	if s.Scope >= ShareScopeWrite && len(s.Paths) != 1 {
+	if s.Scope != ShareScopeRead && len(s.Paths) != 1 {
 		return util.NewI18nError(util.NewValidationError("the write share scope requires exactly one path"), util.I18nErrorShareWriteScope)
 	}
 	// check nested paths
@@ -208,7 +366,7 @@ func (s *Share) validate() error {
 	if s.Name == "" {
 		return util.NewI18nError(util.NewValidationError("name is mandatory"), util.I18nErrorNameRequired)
 	}
-	if s.Scope < ShareScopeRead || s.Scope > ShareScopeReadWrite {
+	if s.Scope < ShareScopeRead || s.Scope > ShareScopeView {
 		return util.NewI18nError(util.NewValidationError(fmt.Sprintf("invalid scope: %v", s.Scope)), util.I18nErrorShareScope)
 	}
 	if err := s.validatePaths(); err != nil {
@@ -233,6 +391,12 @@ func (s *Share) validate() error {
 	if err := s.hashPassword(); err != nil {
 		return err
 	}
+	if err := s.EmailVerification.validate(); err != nil {
+		return err
+	}
+	if err := s.FileRequest.validate(s.Scope); err != nil {
+		return err
+	}
 	s.AllowFrom = util.RemoveDuplicates(s.AllowFrom, false)
 	for _, IPMask := range s.AllowFrom {
 		_, _, err := net.ParseCIDR(IPMask)
@@ -0,0 +1,156 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	// max length for the recorded downloaded file paths
+	maxShareDownloadFileLength = 512
+)
+
+// ShareAnalyticsConfig defines the configuration for recording the downloads
+// from the shares
+type ShareAnalyticsConfig struct {
+	// Set to true to record the downloads from the shares. The share owners can
+	// view the recorded downloads. Only SQL based data providers are supported
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Downloads older than the specified number of days are automatically removed.
+	// 0 means no automatic removal
+	RetentionDays int `json:"retention_days" mapstructure:"retention_days"`
+	// Set to true to record anonymized IP addresses. The last octet is removed from
+	// IPv4 addresses and the last 80 bits from IPv6 addresses
+	AnonymizeIP bool `json:"anonymize_ip" mapstructure:"anonymize_ip"`
+}
+
+func (c *ShareAnalyticsConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.RetentionDays < 0 {
+		return fmt.Errorf("invalid share analytics retention days: %d", c.RetentionDays)
+	}
+	if !config.IsShareAnalyticsSupported() {
+		return fmt.Errorf("share analytics are not supported with data provider %q", config.Driver)
+	}
+	return nil
+}
+
+// ShareDownload defines a download from a share
+type ShareDownload struct {
+	ID int64 `json:"id"`
+	// Unix timestamp in milliseconds
+	Timestamp int64  `json:"timestamp"`
+	IP        string `json:"ip"`
+	// Virtual path of the downloaded file. For archives, the virtual paths
+	// of the archived files and directories separated by a comma
+	File string `json:"file"`
+	// Bytes sent to the recipient
+	Size int64 `json:"size"`
+}
+
+// GetCSVHeader returns the CSV header for share downloads
+func (d *ShareDownload) GetCSVHeader() []string {
+	return []string{"ID", "Time", "IP", "File", "Size"}
+}
+
+// GetCSVData returns the share download as CSV row
+func (d *ShareDownload) GetCSVData() []string {
+	return []string{fmt.Sprintf("%d", d.ID), util.GetTimeFromMsecSinceEpoch(d.Timestamp).UTC().Format(time.RFC3339Nano),
+		d.IP, d.File, fmt.Sprintf("%d", d.Size)}
+}
+
+// ShareDownloadStats defines the aggregated downloads for a share
+type ShareDownloadStats struct {
+	Downloads int64 `json:"downloads"`
+	Size      int64 `json:"size"`
+	UniqueIPs int64 `json:"unique_ips"`
+	// Unix timestamps in milliseconds, 0 means no download
+	FirstDownload int64 `json:"first_download"`
+	LastDownload  int64 `json:"last_download"`
+}
+
+// ShareDownloadSearch defines the filters to search the downloads from a share
+type ShareDownloadSearch struct {
+	// Unix timestamps in milliseconds, 0 means no limit
+	StartTimestamp int64
+	EndTimestamp   int64
+	IP             string
+	// Return the downloads after (ASC order) or before (DESC order) the specified ID
+	FromID int64
+	Limit  int
+	Order  string
+}
+
+// IsShareAnalyticsEnabled returns true if the downloads from the shares are recorded
+func IsShareAnalyticsEnabled() bool {
+	return config.ShareAnalytics.Enabled
+}
+
+// RecordShareDownload records a download from the specified share
+func RecordShareDownload(share *Share, ip, file string, size int64) {
+	if !config.ShareAnalytics.Enabled {
+		return
+	}
+	if config.ShareAnalytics.AnonymizeIP {
+		ip = anonymizeIP(ip)
+	}
+	if len(file) > maxShareDownloadFileLength {
+		file = file[:maxShareDownloadFileLength-3] + "..."
+	}
+	download := ShareDownload{
+		Timestamp: util.GetTimeAsMsSinceEpoch(time.Now()),
+		IP:        ip,
+		File:      file,
+		Size:      size,
+	}
+	if err := provider.addShareDownload(share.ShareID, &download); err != nil {
+		providerLog(logger.LevelError, "unable to record download for share %q: %v", share.ShareID, err)
+	}
+}
+
+// SearchShareDownloads returns the downloads from the specified share matching the specified filters
+func SearchShareDownloads(shareID string, filters *ShareDownloadSearch) ([]ShareDownload, error) {
+	if !config.ShareAnalytics.Enabled {
+		return nil, util.NewMethodDisabledError("share analytics are disabled")
+	}
+	return provider.searchShareDownloads(shareID, filters)
+}
+
+// GetShareDownloadStats returns the aggregated downloads for the specified share
+func GetShareDownloadStats(shareID string) (ShareDownloadStats, error) {
+	if !config.ShareAnalytics.Enabled {
+		return ShareDownloadStats{}, util.NewMethodDisabledError("share analytics are disabled")
+	}
+	return provider.getShareDownloadStats(shareID)
+}
+
+func anonymizeIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if ipv4 := parsed.To4(); ipv4 != nil {
+		return ipv4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -22,6 +22,7 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
+	"path"
 	"runtime/debug"
 	"strings"
 	"time"
@@ -35,7 +36,7 @@ import (
 )
 
 const (
-	sqlDatabaseVersion     = 28
+	sqlDatabaseVersion     = 38
 	defaultSQLQueryTimeout = 10 * time.Second
 	longSQLQueryTimeout    = 60 * time.Second
 )
@@ -82,6 +83,10 @@ func sqlReplaceAll(sql string) string {
 	sql = strings.ReplaceAll(sql, "{{roles}}", sqlTableRoles)
 	sql = strings.ReplaceAll(sql, "{{ip_lists}}", sqlTableIPLists)
 	sql = strings.ReplaceAll(sql, "{{configs}}", sqlTableConfigs)
+	sql = strings.ReplaceAll(sql, "{{audit_logs}}", sqlTableAuditLogs)
+	sql = strings.ReplaceAll(sql, "{{share_downloads}}", sqlTableShareDownloads)
+	sql = strings.ReplaceAll(sql, "{{webhook_deliveries}}", sqlTableWebhookDeliveries)
+	sql = strings.ReplaceAll(sql, "{{dav_properties}}", sqlTableDAVProperties)
 	sql = strings.ReplaceAll(sql, "{{prefix}}", config.SQLTablesPrefix)
 	return sql
 }
@@ -125,6 +130,14 @@ func sqlCommonAddShare(share *Share, dbHandle *sql.DB) error {
 			allowFrom = res
 		}
 	}
+	emailVerification, err := json.Marshal(share.EmailVerification)
+	if err != nil {
+		return err
+	}
+	fileRequest, err := json.Marshal(share.FileRequest)
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
@@ -146,7 +159,7 @@ func sqlCommonAddShare(share *Share, dbHandle *sql.DB) error {
 	}
 	_, err = dbHandle.ExecContext(ctx, q, share.ShareID, share.Name, share.Description, share.Scope,
 		paths, createdAt, updatedAt, lastUseAt, share.ExpiresAt, share.Password,
-		share.MaxTokens, usedTokens, allowFrom, user.ID)
+		share.MaxTokens, usedTokens, allowFrom, user.ID, string(emailVerification), string(fileRequest))
 	return err
 }
 
@@ -168,6 +181,14 @@ func sqlCommonUpdateShare(share *Share, dbHandle *sql.DB) error {
 			allowFrom = res
 		}
 	}
+	emailVerification, err := json.Marshal(share.EmailVerification)
+	if err != nil {
+		return err
+	}
+	fileRequest, err := json.Marshal(share.FileRequest)
+	if err != nil {
+		return err
+	}
 
 	user, err := provider.userExists(share.Username, "")
 	if err != nil {
@@ -194,11 +215,11 @@ func sqlCommonUpdateShare(share *Share, dbHandle *sql.DB) error {
 		}
 		res, err = dbHandle.ExecContext(ctx, q, share.Name, share.Description, share.Scope, paths,
 			share.CreatedAt, share.UpdatedAt, share.LastUseAt, share.ExpiresAt, share.Password, share.MaxTokens,
-			share.UsedTokens, allowFrom, user.ID, share.ShareID)
+			share.UsedTokens, allowFrom, user.ID, string(emailVerification), string(fileRequest), share.ShareID)
 	} else {
 		res, err = dbHandle.ExecContext(ctx, q, share.Name, share.Description, share.Scope, paths,
 			util.GetTimeAsMsSinceEpoch(time.Now()), share.ExpiresAt, share.Password, share.MaxTokens,
-			allowFrom, user.ID, share.ShareID)
+			allowFrom, user.ID, string(emailVerification), string(fileRequest), share.ShareID)
 	}
 	if err != nil {
 		return err
@@ -289,6 +310,10 @@ func sqlCommonAddAPIKey(apiKey *APIKey, dbHandle *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	filters, err := json.Marshal(apiKey.Filters)
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
@@ -296,7 +321,7 @@ func sqlCommonAddAPIKey(apiKey *APIKey, dbHandle *sql.DB) error {
 	q := getAddAPIKeyQuery()
 	_, err = dbHandle.ExecContext(ctx, q, apiKey.KeyID, apiKey.Name, apiKey.Key, apiKey.Scope,
 		util.GetTimeAsMsSinceEpoch(time.Now()), util.GetTimeAsMsSinceEpoch(time.Now()), apiKey.LastUseAt,
-		apiKey.ExpiresAt, apiKey.Description, userID, adminID)
+		apiKey.ExpiresAt, apiKey.Description, userID, adminID, string(filters))
 	return err
 }
 
@@ -310,13 +335,34 @@ func sqlCommonUpdateAPIKey(apiKey *APIKey, dbHandle *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	filters, err := json.Marshal(apiKey.Filters)
+	if err != nil {
+		return err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
 
 	q := getUpdateAPIKeyQuery()
 	res, err := dbHandle.ExecContext(ctx, q, apiKey.Name, apiKey.Scope, apiKey.ExpiresAt, userID, adminID,
-		apiKey.Description, util.GetTimeAsMsSinceEpoch(time.Now()), apiKey.KeyID)
+		apiKey.Description, util.GetTimeAsMsSinceEpoch(time.Now()), string(filters), apiKey.KeyID)
+	if err != nil {
+		return err
+	}
+	return sqlCommonRequireRowAffected(res)
+}
+
+func sqlCommonRotateAPIKey(apiKey *APIKey, dbHandle *sql.DB) error {
+	filters, err := json.Marshal(apiKey.Filters)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getRotateAPIKeyQuery()
+	res, err := dbHandle.ExecContext(ctx, q, apiKey.Key, string(filters), util.GetTimeAsMsSinceEpoch(time.Now()),
+		apiKey.KeyID)
 	if err != nil {
 		return err
 	}
@@ -1099,13 +1145,17 @@ func sqlCommonAddGroup(group *Group, dbHandle *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	includedGroups, err := marshalIncludedGroups(group.IncludedGroups)
+	if err != nil {
+		return err
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
 
 	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
 		q := getAddGroupQuery()
 		_, err := tx.ExecContext(ctx, q, group.Name, group.Description, util.GetTimeAsMsSinceEpoch(time.Now()),
-			util.GetTimeAsMsSinceEpoch(time.Now()), settings)
+			util.GetTimeAsMsSinceEpoch(time.Now()), settings, includedGroups)
 		if err != nil {
 			return err
 		}
@@ -1122,12 +1172,17 @@ func sqlCommonUpdateGroup(group *Group, dbHandle *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	includedGroups, err := marshalIncludedGroups(group.IncludedGroups)
+	if err != nil {
+		return err
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
 
 	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
 		q := getUpdateGroupQuery()
-		_, err := tx.ExecContext(ctx, q, group.Description, settings, util.GetTimeAsMsSinceEpoch(time.Now()), group.Name)
+		_, err := tx.ExecContext(ctx, q, group.Description, settings, util.GetTimeAsMsSinceEpoch(time.Now()),
+			includedGroups, group.Name)
 		if err != nil {
 			return err
 		}
@@ -1361,7 +1416,16 @@ func sqlCommonAddUser(user *User, dbHandle *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
 
+	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
+		return sqlCommonAddUserWithTx(ctx, user, tx)
+	})
+}
+
+// sqlCommonAddUserWithTx adds an already validated user using the given transaction
+func sqlCommonAddUserWithTx(ctx context.Context, user *User, tx *sql.Tx) error {
 	permissions, err := user.GetPermissionsAsJSON()
 	if err != nil {
 		return err
@@ -1378,30 +1442,30 @@ func sqlCommonAddUser(user *User, dbHandle *sql.DB) error {
 	if err != nil {
 		return err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
-	defer cancel()
-
-	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
-		if config.IsShared == 1 {
-			_, err := tx.ExecContext(ctx, getRemoveSoftDeletedUserQuery(), user.Username)
-			if err != nil {
-				return err
-			}
-		}
-		q := getAddUserQuery(user.Role)
-		_, err := tx.ExecContext(ctx, q, user.Username, user.Password, publicKeys, user.HomeDir, user.UID, user.GID,
-			user.MaxSessions, user.QuotaSize, user.QuotaFiles, permissions, user.UploadBandwidth,
-			user.DownloadBandwidth, user.Status, user.ExpirationDate, filters, fsConfig, user.AdditionalInfo,
-			user.Description, user.Email, util.GetTimeAsMsSinceEpoch(time.Now()), util.GetTimeAsMsSinceEpoch(time.Now()),
-			user.UploadDataTransfer, user.DownloadDataTransfer, user.TotalDataTransfer, user.Role, user.LastPasswordChange)
+	attributes, err := marshalAttributes(user.Attributes)
+	if err != nil {
+		return err
+	}
+	if config.IsShared == 1 {
+		_, err := tx.ExecContext(ctx, getRemoveSoftDeletedUserQuery(), user.Username)
 		if err != nil {
 			return err
 		}
-		if err := generateUserVirtualFoldersMapping(ctx, user, tx); err != nil {
-			return err
-		}
-		return generateUserGroupMapping(ctx, user, tx)
-	})
+	}
+	q := getAddUserQuery(user.Role)
+	_, err = tx.ExecContext(ctx, q, user.Username, user.Password, publicKeys, user.HomeDir, user.UID, user.GID,
+		user.MaxSessions, user.QuotaSize, user.QuotaFiles, permissions, user.UploadBandwidth,
+		user.DownloadBandwidth, user.Status, user.ExpirationDate, filters, fsConfig, user.AdditionalInfo,
+		user.Description, user.Email, util.GetTimeAsMsSinceEpoch(time.Now()), util.GetTimeAsMsSinceEpoch(time.Now()),
+		user.UploadDataTransfer, user.DownloadDataTransfer, user.TotalDataTransfer, user.Role, user.LastPasswordChange,
+		attributes)
+	if err != nil {
+		return err
+	}
+	if err := generateUserVirtualFoldersMapping(ctx, user, tx); err != nil {
+		return err
+	}
+	return generateUserGroupMapping(ctx, user, tx)
 }
 
 func sqlCommonUpdateUserPassword(username, password string, dbHandle *sql.DB) error {
@@ -1421,7 +1485,16 @@ func sqlCommonUpdateUser(user *User, dbHandle *sql.DB) error {
 	if err != nil {
 		return err
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
 
+	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
+		return sqlCommonUpdateUserWithTx(ctx, user, tx)
+	})
+}
+
+// sqlCommonUpdateUserWithTx updates an already validated user using the given transaction
+func sqlCommonUpdateUserWithTx(ctx context.Context, user *User, tx *sql.Tx) error {
 	permissions, err := user.GetPermissionsAsJSON()
 	if err != nil {
 		return err
@@ -1438,50 +1511,56 @@ func sqlCommonUpdateUser(user *User, dbHandle *sql.DB) error {
 	if err != nil {
 		return err
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
-	defer cancel()
-
-	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
-		q := getUpdateUserQuery(user.Role)
-		res, err := tx.ExecContext(ctx, q, user.Password, publicKeys, user.HomeDir, user.UID, user.GID, user.MaxSessions,
-			user.QuotaSize, user.QuotaFiles, permissions, user.UploadBandwidth, user.DownloadBandwidth, user.Status,
-			user.ExpirationDate, filters, fsConfig, user.AdditionalInfo, user.Description, user.Email,
-			util.GetTimeAsMsSinceEpoch(time.Now()), user.UploadDataTransfer, user.DownloadDataTransfer, user.TotalDataTransfer,
-			user.Role, user.LastPasswordChange, user.Username)
-		if err != nil {
-			return err
-		}
-		if err := sqlCommonRequireRowAffected(res); err != nil {
-			return err
-		}
-		if err := generateUserVirtualFoldersMapping(ctx, user, tx); err != nil {
-			return err
-		}
-		return generateUserGroupMapping(ctx, user, tx)
-	})
+	attributes, err := marshalAttributes(user.Attributes)
+	if err != nil {
+		return err
+	}
+	q := getUpdateUserQuery(user.Role)
+	res, err := tx.ExecContext(ctx, q, user.Password, publicKeys, user.HomeDir, user.UID, user.GID, user.MaxSessions,
+		user.QuotaSize, user.QuotaFiles, permissions, user.UploadBandwidth, user.DownloadBandwidth, user.Status,
+		user.ExpirationDate, filters, fsConfig, user.AdditionalInfo, user.Description, user.Email,
+		util.GetTimeAsMsSinceEpoch(time.Now()), user.UploadDataTransfer, user.DownloadDataTransfer, user.TotalDataTransfer,
+		user.Role, user.LastPasswordChange, attributes, user.Username)
+	if err != nil {
+		return err
+	}
+	if err := sqlCommonRequireRowAffected(res); err != nil {
+		return err
+	}
+	if err := generateUserVirtualFoldersMapping(ctx, user, tx); err != nil {
+		return err
+	}
+	return generateUserGroupMapping(ctx, user, tx)
 }
 
 func sqlCommonDeleteUser(user User, softDelete bool, dbHandle *sql.DB) error {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
 
-	q := getDeleteUserQuery(softDelete)
 	if softDelete {
 		return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
-			if err := sqlCommonClearUserFolderMapping(ctx, &user, tx); err != nil {
-				return err
-			}
-			if err := sqlCommonClearUserGroupMapping(ctx, &user, tx); err != nil {
-				return err
-			}
-			ts := util.GetTimeAsMsSinceEpoch(time.Now())
-			res, err := tx.ExecContext(ctx, q, ts, ts, user.Username)
-			if err != nil {
-				return err
-			}
-			return sqlCommonRequireRowAffected(res)
+			return sqlCommonDeleteUserWithTx(ctx, user, true, tx)
 		})
 	}
+	return sqlCommonDeleteUserWithTx(ctx, user, false, dbHandle)
+}
+
+func sqlCommonDeleteUserWithTx(ctx context.Context, user User, softDelete bool, dbHandle sqlQuerier) error {
+	q := getDeleteUserQuery(softDelete)
+	if softDelete {
+		if err := sqlCommonClearUserFolderMapping(ctx, &user, dbHandle); err != nil {
+			return err
+		}
+		if err := sqlCommonClearUserGroupMapping(ctx, &user, dbHandle); err != nil {
+			return err
+		}
+		ts := util.GetTimeAsMsSinceEpoch(time.Now())
+		res, err := dbHandle.ExecContext(ctx, q, ts, ts, user.Username)
+		if err != nil {
+			return err
+		}
+		return sqlCommonRequireRowAffected(res)
+	}
 	res, err := dbHandle.ExecContext(ctx, q, user.Username)
 	if err != nil {
 		return err
@@ -1572,6 +1651,12 @@ func sqlCommonGetRecentlyUpdatedUsers(after int64, dbHandle sqlQuerier) ([]User,
 	for idx := range groups {
 		groupsMapping[groups[idx].Name] = groups[idx]
 	}
+	err = loadIncludedGroups(groupsMapping, func(names []string) ([]Group, error) {
+		return sqlCommonGetGroupsWithNames(names, dbHandle)
+	})
+	if err != nil {
+		return users, err
+	}
 	for idx := range users {
 		ref := &users[idx]
 		ref.applyGroupSettings(groupsMapping)
@@ -1650,6 +1735,12 @@ func sqlCommonGetUsersForQuotaCheck(toFetch map[string]bool, dbHandle sqlQuerier
 	for idx := range groups {
 		groupsMapping[groups[idx].Name] = groups[idx]
 	}
+	err = loadIncludedGroups(groupsMapping, func(names []string) ([]Group, error) {
+		return sqlCommonGetGroupsWithNames(names, dbHandle)
+	})
+	if err != nil {
+		return users, err
+	}
 	for idx := range users {
 		ref := &users[idx]
 		ref.applyGroupSettings(groupsMapping)
@@ -1764,10 +1855,6 @@ func sqlCommonGetActiveTransfers(from time.Time, dbHandle sqlQuerier) ([]ActiveT
 }
 
 func sqlCommonGetUsers(limit int, offset int, order, role string, dbHandle sqlQuerier) ([]User, error) {
-	users := make([]User, 0, limit)
-	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
-	defer cancel()
-
 	q := getUsersQuery(order, role)
 	var args []any
 	if role == "" {
@@ -1775,6 +1862,19 @@ func sqlCommonGetUsers(limit int, offset int, order, role string, dbHandle sqlQu
 	} else {
 		args = append(args, role, limit, offset)
 	}
+	return sqlCommonQueryUsersForRendering(q, args, limit, dbHandle)
+}
+
+func sqlCommonListUsers(opts *ListOptions, dbHandle sqlQuerier) ([]User, error) {
+	q, args := getListUsersQuery(opts)
+	return sqlCommonQueryUsersForRendering(q, args, opts.Limit, dbHandle)
+}
+
+func sqlCommonQueryUsersForRendering(q string, args []any, limit int, dbHandle sqlQuerier) ([]User, error) {
+	users := make([]User, 0, limit)
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
 	rows, err := dbHandle.QueryContext(ctx, q, args...)
 	if err != nil {
 		return users, err
@@ -2009,13 +2109,13 @@ func sqlCommonCleanupDefenderEvents(from int64, dbHandle *sql.DB) error {
 
 func getShareFromDbRow(row sqlScanner) (Share, error) {
 	var share Share
-	var description, password sql.NullString
+	var description, password, emailVerification, fileRequest sql.NullString
 	var allowFrom, paths []byte
 
 	err := row.Scan(&share.ShareID, &share.Name, &description, &share.Scope,
 		&paths, &share.Username, &share.CreatedAt, &share.UpdatedAt,
 		&share.LastUseAt, &share.ExpiresAt, &password, &share.MaxTokens,
-		&share.UsedTokens, &allowFrom)
+		&share.UsedTokens, &allowFrom, &emailVerification, &fileRequest)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return share, util.NewRecordNotFoundError(err.Error())
@@ -2039,16 +2139,28 @@ func getShareFromDbRow(row sqlScanner) (Share, error) {
 	if err == nil {
 		share.AllowFrom = list
 	}
+	if emailVerification.Valid && emailVerification.String != "" {
+		var verification ShareEmailVerification
+		if err := json.Unmarshal([]byte(emailVerification.String), &verification); err == nil {
+			share.EmailVerification = verification
+		}
+	}
+	if fileRequest.Valid && fileRequest.String != "" {
+		var request ShareFileRequest
+		if err := json.Unmarshal([]byte(fileRequest.String), &request); err == nil {
+			share.FileRequest = request
+		}
+	}
 	return share, nil
 }
 
 func getAPIKeyFromDbRow(row sqlScanner) (APIKey, error) {
 	var apiKey APIKey
 	var userID, adminID sql.NullInt64
-	var description sql.NullString
+	var description, filters sql.NullString
 
 	err := row.Scan(&apiKey.KeyID, &apiKey.Name, &apiKey.Key, &apiKey.Scope, &apiKey.CreatedAt, &apiKey.UpdatedAt,
-		&apiKey.LastUseAt, &apiKey.ExpiresAt, &description, &userID, &adminID)
+		&apiKey.LastUseAt, &apiKey.ExpiresAt, &description, &userID, &adminID, &filters)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -2066,6 +2178,12 @@ func getAPIKeyFromDbRow(row sqlScanner) (APIKey, error) {
 	if description.Valid {
 		apiKey.Description = description.String
 	}
+	if filters.Valid && filters.String != "" {
+		var keyFilters APIKeyFilters
+		if err := json.Unmarshal([]byte(filters.String), &keyFilters); err == nil {
+			apiKey.Filters = keyFilters
+		}
+	}
 
 	return apiKey, nil
 }
@@ -2201,10 +2319,11 @@ func getRoleFromDbRow(row sqlScanner) (Role, error) {
 
 func getGroupFromDbRow(row sqlScanner) (Group, error) {
 	var group Group
-	var description sql.NullString
+	var description, includedGroups sql.NullString
 	var userSettings []byte
 
-	err := row.Scan(&group.ID, &group.Name, &description, &group.CreatedAt, &group.UpdatedAt, &userSettings)
+	err := row.Scan(&group.ID, &group.Name, &description, &group.CreatedAt, &group.UpdatedAt, &userSettings,
+		&includedGroups)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return group, util.NewRecordNotFoundError(err.Error())
@@ -2220,22 +2339,73 @@ func getGroupFromDbRow(row sqlScanner) (Group, error) {
 	if err == nil {
 		group.UserSettings = settings
 	}
+	if includedGroups.Valid && includedGroups.String != "" {
+		var names []string
+		if err := json.Unmarshal([]byte(includedGroups.String), &names); err == nil {
+			group.IncludedGroups = names
+		}
+	}
 
 	return group, nil
 }
 
+func marshalIncludedGroups(names []string) (sql.NullString, error) {
+	if len(names) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(names)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func marshalAttributes(attributes map[string]string) (sql.NullString, error) {
+	if len(attributes) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(attributes)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func marshalWebhookDeliveryLog(attempts []WebhookDeliveryAttempt) (sql.NullString, error) {
+	if len(attempts) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(attempts)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func unmarshalAttributes(data sql.NullString) map[string]string {
+	if !data.Valid || data.String == "" {
+		return nil
+	}
+	var attributes map[string]string
+	if err := json.Unmarshal([]byte(data.String), &attributes); err != nil {
+		providerLog(logger.LevelError, "unable to deserialize attributes: %v", err)
+		return nil
+	}
+	return attributes
+}
+
 func getUserFromDbRow(row sqlScanner) (User, error) {
 	var user User
 	var password sql.NullString
 	var permissions, publicKey, filters, fsConfig []byte
-	var additionalInfo, description, email, role sql.NullString
+	var additionalInfo, description, email, role, attributes sql.NullString
 
 	err := row.Scan(&user.ID, &user.Username, &password, &publicKey, &user.HomeDir, &user.UID, &user.GID, &user.MaxSessions,
 		&user.QuotaSize, &user.QuotaFiles, &permissions, &user.UsedQuotaSize, &user.UsedQuotaFiles, &user.LastQuotaUpdate,
 		&user.UploadBandwidth, &user.DownloadBandwidth, &user.ExpirationDate, &user.LastLogin, &user.Status, &filters, &fsConfig,
 		&additionalInfo, &description, &email, &user.CreatedAt, &user.UpdatedAt, &user.UploadDataTransfer, &user.DownloadDataTransfer,
 		&user.TotalDataTransfer, &user.UsedUploadDataTransfer, &user.UsedDownloadDataTransfer, &user.DeletedAt, &user.FirstDownload,
-		&user.FirstUpload, &role, &user.LastPasswordChange)
+		&user.FirstUpload, &role, &user.LastPasswordChange, &attributes)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return user, util.NewRecordNotFoundError(err.Error())
@@ -2282,6 +2452,7 @@ func getUserFromDbRow(row sqlScanner) (User, error) {
 	if role.Valid {
 		user.Role = role.String
 	}
+	user.Attributes = unmarshalAttributes(attributes)
 	user.SetEmptySecretsIfNil()
 	return user, nil
 }
@@ -2290,10 +2461,10 @@ func sqlCommonGetFolder(ctx context.Context, name string, dbHandle sqlQuerier) (
 	var folder vfs.BaseVirtualFolder
 	q := getFolderByNameQuery()
 	row := dbHandle.QueryRowContext(ctx, q, name)
-	var mappedPath, description sql.NullString
+	var mappedPath, description, attributes sql.NullString
 	var fsConfig []byte
 	err := row.Scan(&folder.ID, &mappedPath, &folder.UsedQuotaSize, &folder.UsedQuotaFiles, &folder.LastQuotaUpdate,
-		&folder.Name, &description, &fsConfig)
+		&folder.Name, &description, &fsConfig, &attributes)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return folder, util.NewRecordNotFoundError(err.Error())
@@ -2306,6 +2477,7 @@ func sqlCommonGetFolder(ctx context.Context, name string, dbHandle sqlQuerier) (
 	if description.Valid {
 		folder.Description = description.String
 	}
+	folder.Attributes = unmarshalAttributes(attributes)
 	var fs vfs.Filesystem
 	err = json.Unmarshal(fsConfig, &fs)
 	if err == nil {
@@ -2345,12 +2517,16 @@ func sqlCommonAddFolder(folder *vfs.BaseVirtualFolder, dbHandle sqlQuerier) erro
 	if err != nil {
 		return err
 	}
+	attributes, err := marshalAttributes(folder.Attributes)
+	if err != nil {
+		return err
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
 
 	q := getAddFolderQuery()
 	_, err = dbHandle.ExecContext(ctx, q, folder.MappedPath, folder.UsedQuotaSize, folder.UsedQuotaFiles,
-		folder.LastQuotaUpdate, folder.Name, folder.Description, fsConfig)
+		folder.LastQuotaUpdate, folder.Name, folder.Description, fsConfig, attributes)
 	return err
 }
 
@@ -2363,11 +2539,15 @@ func sqlCommonUpdateFolder(folder *vfs.BaseVirtualFolder, dbHandle sqlQuerier) e
 	if err != nil {
 		return err
 	}
+	attributes, err := marshalAttributes(folder.Attributes)
+	if err != nil {
+		return err
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
 
 	q := getUpdateFolderQuery()
-	res, err := dbHandle.ExecContext(ctx, q, folder.MappedPath, folder.Description, fsConfig, folder.Name)
+	res, err := dbHandle.ExecContext(ctx, q, folder.MappedPath, folder.Description, fsConfig, attributes, folder.Name)
 	if err != nil {
 		return err
 	}
@@ -2386,6 +2566,56 @@ func sqlCommonDeleteFolder(folder vfs.BaseVirtualFolder, dbHandle sqlQuerier) er
 	return sqlCommonRequireRowAffected(res)
 }
 
+func sqlCommonExecuteUsersBatch(items []UserBatchItem, dbHandle *sql.DB, normalizeError func(error, int) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
+		for idx := range items {
+			var err error
+			switch items[idx].Action {
+			case BatchActionAdd:
+				err = normalizeError(sqlCommonAddUserWithTx(ctx, &items[idx].User, tx), fieldUsername)
+			case BatchActionUpdate:
+				err = normalizeError(sqlCommonUpdateUserWithTx(ctx, &items[idx].User, tx), -1)
+			case BatchActionDelete:
+				err = sqlCommonDeleteUserWithTx(ctx, items[idx].User, config.IsShared == 1, tx)
+			default:
+				err = getInvalidBatchActionError(items[idx].Action)
+			}
+			if err != nil {
+				return &batchItemError{index: idx, err: err}
+			}
+		}
+		return nil
+	})
+}
+
+func sqlCommonExecuteFoldersBatch(items []FolderBatchItem, dbHandle *sql.DB, normalizeError func(error, int) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
+		for idx := range items {
+			var err error
+			switch items[idx].Action {
+			case BatchActionAdd:
+				err = normalizeError(sqlCommonAddFolder(&items[idx].Folder, tx), fieldName)
+			case BatchActionUpdate:
+				err = sqlCommonUpdateFolder(&items[idx].Folder, tx)
+			case BatchActionDelete:
+				err = sqlCommonDeleteFolder(items[idx].Folder, tx)
+			default:
+				err = getInvalidBatchActionError(items[idx].Action)
+			}
+			if err != nil {
+				return &batchItemError{index: idx, err: err}
+			}
+		}
+		return nil
+	})
+}
+
 func sqlCommonDumpFolders(dbHandle sqlQuerier) ([]vfs.BaseVirtualFolder, error) {
 	folders := make([]vfs.BaseVirtualFolder, 0, 50)
 	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
@@ -2399,10 +2629,10 @@ func sqlCommonDumpFolders(dbHandle sqlQuerier) ([]vfs.BaseVirtualFolder, error)
 	defer rows.Close()
 	for rows.Next() {
 		var folder vfs.BaseVirtualFolder
-		var mappedPath, description sql.NullString
+		var mappedPath, description, attributes sql.NullString
 		var fsConfig []byte
 		err = rows.Scan(&folder.ID, &mappedPath, &folder.UsedQuotaSize, &folder.UsedQuotaFiles,
-			&folder.LastQuotaUpdate, &folder.Name, &description, &fsConfig)
+			&folder.LastQuotaUpdate, &folder.Name, &description, &fsConfig, &attributes)
 		if err != nil {
 			return folders, err
 		}
@@ -2412,6 +2642,7 @@ func sqlCommonDumpFolders(dbHandle sqlQuerier) ([]vfs.BaseVirtualFolder, error)
 		if description.Valid {
 			folder.Description = description.String
 		}
+		folder.Attributes = unmarshalAttributes(attributes)
 		var fs vfs.Filesystem
 		err = json.Unmarshal(fsConfig, &fs)
 		if err == nil {
@@ -2423,12 +2654,21 @@ func sqlCommonDumpFolders(dbHandle sqlQuerier) ([]vfs.BaseVirtualFolder, error)
 }
 
 func sqlCommonGetFolders(limit, offset int, order string, minimal bool, dbHandle sqlQuerier) ([]vfs.BaseVirtualFolder, error) {
+	q := getFoldersQuery(order, minimal)
+	return sqlCommonQueryFolders(q, []any{limit, offset}, limit, minimal, dbHandle)
+}
+
+func sqlCommonListFolders(opts *ListOptions, dbHandle sqlQuerier) ([]vfs.BaseVirtualFolder, error) {
+	q, args := getListFoldersQuery(opts)
+	return sqlCommonQueryFolders(q, args, opts.Limit, false, dbHandle)
+}
+
+func sqlCommonQueryFolders(q string, args []any, limit int, minimal bool, dbHandle sqlQuerier) ([]vfs.BaseVirtualFolder, error) {
 	folders := make([]vfs.BaseVirtualFolder, 0, limit)
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
 
-	q := getFoldersQuery(order, minimal)
-	rows, err := dbHandle.QueryContext(ctx, q, limit, offset)
+	rows, err := dbHandle.QueryContext(ctx, q, args...)
 	if err != nil {
 		return folders, err
 	}
@@ -2441,10 +2681,10 @@ func sqlCommonGetFolders(limit, offset int, order string, minimal bool, dbHandle
 				return folders, err
 			}
 		} else {
-			var mappedPath, description sql.NullString
+			var mappedPath, description, attributes sql.NullString
 			var fsConfig []byte
 			err = rows.Scan(&folder.ID, &mappedPath, &folder.UsedQuotaSize, &folder.UsedQuotaFiles,
-				&folder.LastQuotaUpdate, &folder.Name, &description, &fsConfig)
+				&folder.LastQuotaUpdate, &folder.Name, &description, &fsConfig, &attributes)
 			if err != nil {
 				return folders, err
 			}
@@ -2454,6 +2694,7 @@ func sqlCommonGetFolders(limit, offset int, order string, minimal bool, dbHandle
 			if description.Valid {
 				folder.Description = description.String
 			}
+			folder.Attributes = unmarshalAttributes(attributes)
 			var fs vfs.Filesystem
 			err = json.Unmarshal(fsConfig, &fs)
 			if err == nil {
@@ -2498,7 +2739,8 @@ func sqlCommonClearUserGroupMapping(ctx context.Context, user *User, dbHandle sq
 
 func sqlCommonAddUserFolderMapping(ctx context.Context, user *User, folder *vfs.VirtualFolder, dbHandle sqlQuerier) error {
 	q := getAddUserFolderMappingQuery()
-	_, err := dbHandle.ExecContext(ctx, q, folder.VirtualPath, folder.QuotaSize, folder.QuotaFiles, folder.Name, user.Username)
+	_, err := dbHandle.ExecContext(ctx, q, folder.VirtualPath, folder.QuotaSize, folder.QuotaFiles, folder.UploadBandwidth,
+		folder.DownloadBandwidth, folder.Name, user.Username)
 	return err
 }
 
@@ -2510,7 +2752,8 @@ func sqlCommonClearAdminGroupMapping(ctx context.Context, admin *Admin, dbHandle
 
 func sqlCommonAddGroupFolderMapping(ctx context.Context, group *Group, folder *vfs.VirtualFolder, dbHandle sqlQuerier) error {
 	q := getAddGroupFolderMappingQuery()
-	_, err := dbHandle.ExecContext(ctx, q, folder.VirtualPath, folder.QuotaSize, folder.QuotaFiles, folder.Name, group.Name)
+	_, err := dbHandle.ExecContext(ctx, q, folder.VirtualPath, folder.QuotaSize, folder.QuotaFiles, folder.UploadBandwidth,
+		folder.DownloadBandwidth, folder.Name, group.Name)
 	return err
 }
 
@@ -2715,11 +2958,11 @@ func getUsersWithVirtualFolders(ctx context.Context, users []User, dbHandle sqlQ
 	for rows.Next() {
 		var folder vfs.VirtualFolder
 		var userID int64
-		var mappedPath, description sql.NullString
+		var mappedPath, description, attributes sql.NullString
 		var fsConfig []byte
 		err = rows.Scan(&folder.ID, &folder.Name, &mappedPath, &folder.UsedQuotaSize, &folder.UsedQuotaFiles,
-			&folder.LastQuotaUpdate, &folder.VirtualPath, &folder.QuotaSize, &folder.QuotaFiles, &userID, &fsConfig,
-			&description)
+			&folder.LastQuotaUpdate, &folder.VirtualPath, &folder.QuotaSize, &folder.QuotaFiles, &folder.UploadBandwidth,
+			&folder.DownloadBandwidth, &userID, &fsConfig, &description, &attributes)
 		if err != nil {
 			return users, err
 		}
@@ -2729,6 +2972,7 @@ func getUsersWithVirtualFolders(ctx context.Context, users []User, dbHandle sqlQ
 		if description.Valid {
 			folder.Description = description.String
 		}
+		folder.Attributes = unmarshalAttributes(attributes)
 		var fs vfs.Filesystem
 		err = json.Unmarshal(fsConfig, &fs)
 		if err == nil {
@@ -2866,11 +3110,11 @@ func getGroupsWithVirtualFolders(ctx context.Context, groups []Group, dbHandle s
 	for rows.Next() {
 		var groupID int64
 		var folder vfs.VirtualFolder
-		var mappedPath, description sql.NullString
+		var mappedPath, description, attributes sql.NullString
 		var fsConfig []byte
 		err = rows.Scan(&folder.ID, &folder.Name, &mappedPath, &folder.UsedQuotaSize, &folder.UsedQuotaFiles,
-			&folder.LastQuotaUpdate, &folder.VirtualPath, &folder.QuotaSize, &folder.QuotaFiles, &groupID, &fsConfig,
-			&description)
+			&folder.LastQuotaUpdate, &folder.VirtualPath, &folder.QuotaSize, &folder.QuotaFiles, &folder.UploadBandwidth,
+			&folder.DownloadBandwidth, &groupID, &fsConfig, &description, &attributes)
 		if err != nil {
 			return groups, err
 		}
@@ -2880,6 +3124,7 @@ func getGroupsWithVirtualFolders(ctx context.Context, groups []Group, dbHandle s
 		if description.Valid {
 			folder.Description = description.String
 		}
+		folder.Attributes = unmarshalAttributes(attributes)
 		var fs vfs.Filesystem
 		err = json.Unmarshal(fsConfig, &fs)
 		if err == nil {
@@ -3253,6 +3498,30 @@ func sqlCommonGetSession(key string, dbHandle sqlQuerier) (Session, error) {
 	return session, nil
 }
 
+func sqlCommonGetSessions(sessionType SessionType, dbHandle sqlQuerier) ([]Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getSessionsQuery()
+	rows, err := dbHandle.QueryContext(ctx, q, sessionType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []Session
+	for rows.Next() {
+		var session Session
+		var data []byte
+		if err := rows.Scan(&session.Key, &data, &session.Type, &session.Timestamp); err != nil {
+			return sessions, err
+		}
+		session.Data = data
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
 func sqlCommonDeleteSession(key string, dbHandle *sql.DB) error {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
 	defer cancel()
@@ -3898,6 +4167,378 @@ func sqlCommonSetConfigs(configs *Configs, dbHandle *sql.DB) error {
 	return sqlCommonRequireRowAffected(res)
 }
 
+func sqlCommonAddAuditLogEntry(entry *AuditLogEntry, dbHandle *sql.DB) error {
+	var diff []byte
+	if len(entry.Diff) > 0 {
+		data, err := json.Marshal(entry.Diff)
+		if err != nil {
+			return err
+		}
+		diff = data
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getAddAuditLogEntryQuery()
+	_, err := dbHandle.ExecContext(ctx, q, entry.Timestamp, entry.Executor, entry.IP, entry.Role, entry.Operation,
+		entry.ObjectType, entry.ObjectName, string(entry.ObjectData), string(diff))
+	return err
+}
+
+func sqlCommonGetLastAuditLogEntry(objectType, objectName string, dbHandle sqlQuerier) (AuditLogEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getLastAuditLogEntryQuery()
+	row := dbHandle.QueryRowContext(ctx, q, objectType, objectName)
+	return getAuditLogEntryFromDbRow(row)
+}
+
+func sqlCommonSearchAuditLogs(filters *AuditLogSearch, dbHandle sqlQuerier) ([]AuditLogEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	q, args := getSearchAuditLogsQuery(filters)
+	entries := make([]AuditLogEntry, 0, filters.Limit)
+	rows, err := dbHandle.QueryContext(ctx, q, args...)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := getAuditLogEntryFromDbRow(rows)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+func sqlCommonCleanupAuditLogs(before int64, dbHandle *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	q := getCleanupAuditLogsQuery()
+	_, err := dbHandle.ExecContext(ctx, q, before)
+	return err
+}
+
+func sqlCommonAddShareDownload(shareID string, download *ShareDownload, dbHandle *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getAddShareDownloadQuery()
+	res, err := dbHandle.ExecContext(ctx, q, download.Timestamp, download.IP, download.File, download.Size, shareID)
+	if err != nil {
+		return err
+	}
+	return sqlCommonRequireRowAffected(res)
+}
+
+func sqlCommonSearchShareDownloads(shareID string, filters *ShareDownloadSearch, dbHandle sqlQuerier) ([]ShareDownload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	q, args := getSearchShareDownloadsQuery(shareID, filters)
+	downloads := make([]ShareDownload, 0, filters.Limit)
+	rows, err := dbHandle.QueryContext(ctx, q, args...)
+	if err != nil {
+		return downloads, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var download ShareDownload
+		var ip, file sql.NullString
+		if err := rows.Scan(&download.ID, &download.Timestamp, &ip, &file, &download.Size); err != nil {
+			return downloads, err
+		}
+		download.IP = ip.String
+		download.File = file.String
+		downloads = append(downloads, download)
+	}
+	return downloads, rows.Err()
+}
+
+func sqlCommonGetShareDownloadStats(shareID string, dbHandle sqlQuerier) (ShareDownloadStats, error) {
+	var stats ShareDownloadStats
+	var size, firstDownload, lastDownload sql.NullInt64
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	q := getShareDownloadStatsQuery()
+	err := dbHandle.QueryRowContext(ctx, q, shareID).Scan(&stats.Downloads, &size, &stats.UniqueIPs,
+		&firstDownload, &lastDownload)
+	if err != nil {
+		return stats, err
+	}
+	stats.Size = size.Int64
+	stats.FirstDownload = firstDownload.Int64
+	stats.LastDownload = lastDownload.Int64
+	return stats, nil
+}
+
+func sqlCommonCleanupShareDownloads(before int64, dbHandle *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	q := getCleanupShareDownloadsQuery()
+	_, err := dbHandle.ExecContext(ctx, q, before)
+	return err
+}
+
+func sqlCommonAddWebhookDelivery(delivery *WebhookDelivery, dbHandle *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getAddWebhookDeliveryQuery()
+	_, err := dbHandle.ExecContext(ctx, q, delivery.ID, delivery.URL, delivery.Event, delivery.Payload,
+		delivery.Status, delivery.Attempts, delivery.NextAttemptAt, delivery.CreatedAt, delivery.UpdatedAt)
+	return err
+}
+
+func sqlCommonGetWebhookDelivery(id string, dbHandle sqlQuerier) (WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getWebhookDeliveryQuery()
+	row := dbHandle.QueryRowContext(ctx, q, id)
+	return getWebhookDeliveryFromDbRow(row)
+}
+
+func sqlCommonGetDueWebhookDeliveries(now int64, limit int, dbHandle sqlQuerier) ([]WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getDueWebhookDeliveriesQuery()
+	return sqlCommonQueryWebhookDeliveries(ctx, q, limit, dbHandle, WebhookDeliveryStatusPending, now, limit)
+}
+
+func sqlCommonLockWebhookDelivery(id string, nextAttemptAt, lockUntil int64, dbHandle *sql.DB) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getLockWebhookDeliveryQuery()
+	res, err := dbHandle.ExecContext(ctx, q, lockUntil, id, WebhookDeliveryStatusPending, nextAttemptAt)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func sqlCommonUpdateWebhookDelivery(delivery *WebhookDelivery, dbHandle *sql.DB) error {
+	attemptsLog, err := marshalWebhookDeliveryLog(delivery.Log)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	q := getUpdateWebhookDeliveryQuery()
+	res, err := dbHandle.ExecContext(ctx, q, delivery.Status, delivery.Attempts, delivery.NextAttemptAt,
+		delivery.UpdatedAt, attemptsLog, delivery.ID)
+	if err != nil {
+		return err
+	}
+	return sqlCommonRequireRowAffected(res)
+}
+
+func sqlCommonSearchWebhookDeliveries(filters *WebhookDeliverySearch, dbHandle sqlQuerier) ([]WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	q, args := getSearchWebhookDeliveriesQuery(filters)
+	return sqlCommonQueryWebhookDeliveries(ctx, q, filters.Limit, dbHandle, args...)
+}
+
+func sqlCommonQueryWebhookDeliveries(ctx context.Context, q string, limit int, dbHandle sqlQuerier,
+	args ...any,
+) ([]WebhookDelivery, error) {
+	deliveries := make([]WebhookDelivery, 0, limit)
+	rows, err := dbHandle.QueryContext(ctx, q, args...)
+	if err != nil {
+		return deliveries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		delivery, err := getWebhookDeliveryFromDbRow(rows)
+		if err != nil {
+			return deliveries, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func sqlCommonCleanupWebhookDeliveries(before int64, dbHandle *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	q := getCleanupWebhookDeliveriesQuery()
+	_, err := dbHandle.ExecContext(ctx, q, WebhookDeliveryStatusPending, before)
+	return err
+}
+
+func getWebhookDeliveryFromDbRow(row sqlScanner) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+	var attemptsLog sql.NullString
+
+	err := row.Scan(&delivery.ID, &delivery.URL, &delivery.Event, &delivery.Payload, &delivery.Status,
+		&delivery.Attempts, &delivery.NextAttemptAt, &delivery.CreatedAt, &delivery.UpdatedAt, &attemptsLog)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return delivery, util.NewRecordNotFoundError(err.Error())
+		}
+		return delivery, err
+	}
+	if attemptsLog.Valid && attemptsLog.String != "" {
+		var entries []WebhookDeliveryAttempt
+		if err := json.Unmarshal([]byte(attemptsLog.String), &entries); err == nil {
+			delivery.Log = entries
+		}
+	}
+	return delivery, nil
+}
+
+func sqlCommonGetDAVProperties(username, virtualPath string, dbHandle sqlQuerier) (DAVProperties, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	props := DAVProperties{
+		Username: username,
+	}
+	var data string
+	q := getDAVPropertiesQuery()
+	err := dbHandle.QueryRowContext(ctx, q, username, getDAVPathHash(virtualPath)).Scan(&props.Path, &data,
+		&props.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return props, util.NewRecordNotFoundError(err.Error())
+		}
+		return props, err
+	}
+	return props, props.unmarshalProperties(data)
+}
+
+func sqlCommonSetDAVProperties(props *DAVProperties, dbHandle *sql.DB) error {
+	data, err := props.marshalProperties()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
+	defer cancel()
+
+	pathHash := getDAVPathHash(props.Path)
+	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
+		q := getDeleteDAVPropertiesQuery()
+		if _, err := tx.ExecContext(ctx, q, props.Username, pathHash); err != nil {
+			return err
+		}
+		q = getAddDAVPropertiesQuery()
+		_, err := tx.ExecContext(ctx, q, props.Username, props.Path, pathHash, data, props.UpdatedAt)
+		return err
+	})
+}
+
+func sqlCommonRenameDAVProperties(username, source, target string, dbHandle *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	return sqlCommonExecuteTx(ctx, dbHandle, func(tx *sql.Tx) error {
+		// the properties for an overwritten target are removed
+		q := getDeleteDAVPropertiesTreeQuery()
+		if _, err := tx.ExecContext(ctx, q, getDAVPropertiesTreeArgs(username, target)...); err != nil {
+			return err
+		}
+		paths, err := sqlCommonGetDAVPropertiesTreePaths(ctx, username, source, tx)
+		if err != nil {
+			return err
+		}
+		q = getUpdateDAVPropertiesPathQuery()
+		for _, p := range paths {
+			targetPath := path.Join(target, strings.TrimPrefix(p, source))
+			_, err := tx.ExecContext(ctx, q, targetPath, getDAVPathHash(targetPath), username, getDAVPathHash(p))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func sqlCommonGetDAVPropertiesTreePaths(ctx context.Context, username, virtualPath string, dbHandle sqlQuerier,
+) ([]string, error) {
+	q := getDAVPropertiesTreePathsQuery()
+	rows, err := dbHandle.QueryContext(ctx, q, getDAVPropertiesTreeArgs(username, virtualPath)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+func sqlCommonDeleteDAVProperties(username, virtualPath string, recursive bool, dbHandle *sql.DB) error {
+	ctx, cancel := context.WithTimeout(context.Background(), longSQLQueryTimeout)
+	defer cancel()
+
+	var err error
+	switch {
+	case !recursive:
+		_, err = dbHandle.ExecContext(ctx, getDeleteDAVPropertiesQuery(), username, getDAVPathHash(virtualPath))
+	case virtualPath == "/":
+		_, err = dbHandle.ExecContext(ctx, getDeleteUserDAVPropertiesQuery(), username)
+	default:
+		_, err = dbHandle.ExecContext(ctx, getDeleteDAVPropertiesTreeQuery(),
+			getDAVPropertiesTreeArgs(username, virtualPath)...)
+	}
+	return err
+}
+
+func getDAVPropertiesTreeArgs(username, virtualPath string) []any {
+	return []any{username, getDAVPathHash(virtualPath), virtualPath + "/", virtualPath + "0"}
+}
+
+func getAuditLogEntryFromDbRow(row sqlScanner) (AuditLogEntry, error) {
+	var entry AuditLogEntry
+	var ip, role, objectData, diff sql.NullString
+
+	err := row.Scan(&entry.ID, &entry.Timestamp, &entry.Executor, &ip, &role, &entry.Operation, &entry.ObjectType,
+		&entry.ObjectName, &objectData, &diff)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return entry, util.NewRecordNotFoundError(err.Error())
+		}
+		return entry, err
+	}
+	entry.IP = ip.String
+	entry.Role = role.String
+	if objectData.Valid && objectData.String != "" {
+		entry.ObjectData = json.RawMessage(objectData.String)
+	}
+	if diff.Valid && diff.String != "" {
+		if err := json.Unmarshal([]byte(diff.String), &entry.Diff); err != nil {
+			return entry, err
+		}
+	}
+	return entry, nil
+}
+
 func sqlCommonGetDatabaseVersion(dbHandle sqlQuerier, showInitWarn bool) (schemaVersion, error) {
 	var result schemaVersion
 	ctx, cancel := context.WithTimeout(context.Background(), defaultSQLQueryTimeout)
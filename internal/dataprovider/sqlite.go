@@ -43,6 +43,9 @@ DROP TABLE IF EXISTS "{{admins_groups_mapping}}";
 DROP TABLE IF EXISTS "{{groups_folders_mapping}}";
 DROP TABLE IF EXISTS "{{admins}}";
 DROP TABLE IF EXISTS "{{folders}}";
+DROP TABLE IF EXISTS "{{share_downloads}}";
+DROP TABLE IF EXISTS "{{webhook_deliveries}}";
+DROP TABLE IF EXISTS "{{dav_properties}}";
 DROP TABLE IF EXISTS "{{shares}}";
 DROP TABLE IF EXISTS "{{users}}";
 DROP TABLE IF EXISTS "{{groups}}";
@@ -57,6 +60,7 @@ DROP TABLE IF EXISTS "{{tasks}}";
 DROP TABLE IF EXISTS "{{roles}}";
 DROP TABLE IF EXISTS "{{ip_lists}}";
 DROP TABLE IF EXISTS "{{configs}}";
+DROP TABLE IF EXISTS "{{audit_logs}}";
 DROP TABLE IF EXISTS "{{schema_version}}";
 `
 	sqliteInitialSQL = `CREATE TABLE "{{schema_version}}" ("id" integer NOT NULL PRIMARY KEY, "version" integer NOT NULL);
@@ -179,6 +183,70 @@ CREATE INDEX "{{prefix}}ip_lists_ip_updated_at_idx" ON "{{ip_lists}}" ("updated_
 CREATE INDEX "{{prefix}}ip_lists_ip_deleted_at_idx" ON "{{ip_lists}}" ("deleted_at");
 CREATE INDEX "{{prefix}}ip_lists_first_last_idx" ON "{{ip_lists}}" ("first", "last");
 INSERT INTO {{schema_version}} (version) VALUES (28);
+`
+	sqliteV29SQL = `ALTER TABLE "{{users_folders_mapping}}" ADD COLUMN "upload_bandwidth" integer DEFAULT 0 NOT NULL;
+ALTER TABLE "{{users_folders_mapping}}" ADD COLUMN "download_bandwidth" integer DEFAULT 0 NOT NULL;
+ALTER TABLE "{{groups_folders_mapping}}" ADD COLUMN "upload_bandwidth" integer DEFAULT 0 NOT NULL;
+ALTER TABLE "{{groups_folders_mapping}}" ADD COLUMN "download_bandwidth" integer DEFAULT 0 NOT NULL;
+`
+	sqliteV29DownSQL = `ALTER TABLE "{{groups_folders_mapping}}" DROP COLUMN "download_bandwidth";
+ALTER TABLE "{{groups_folders_mapping}}" DROP COLUMN "upload_bandwidth";
+ALTER TABLE "{{users_folders_mapping}}" DROP COLUMN "download_bandwidth";
+ALTER TABLE "{{users_folders_mapping}}" DROP COLUMN "upload_bandwidth";
+`
+	sqliteV30SQL = `CREATE TABLE "{{audit_logs}}" ("id" integer NOT NULL PRIMARY KEY, "date_time" bigint NOT NULL,
+"executor" varchar(255) NOT NULL, "ip" varchar(50) NOT NULL, "role" varchar(255) NULL, "operation" varchar(20) NOT NULL,
+"object_type" varchar(50) NOT NULL, "object_name" varchar(512) NOT NULL, "object_data" text NULL, "diff" text NULL);
+CREATE INDEX "{{prefix}}audit_logs_date_time_idx" ON "{{audit_logs}}" ("date_time");
+CREATE INDEX "{{prefix}}audit_logs_object_idx" ON "{{audit_logs}}" ("object_type", "object_name");
+CREATE INDEX "{{prefix}}audit_logs_executor_idx" ON "{{audit_logs}}" ("executor");
+`
+	sqliteV30DownSQL = `DROP TABLE IF EXISTS "{{audit_logs}}";
+`
+	sqliteV31SQL = `ALTER TABLE "{{users}}" ADD COLUMN "attributes" text NULL;
+ALTER TABLE "{{folders}}" ADD COLUMN "attributes" text NULL;
+`
+	sqliteV31DownSQL = `ALTER TABLE "{{folders}}" DROP COLUMN "attributes";
+ALTER TABLE "{{users}}" DROP COLUMN "attributes";
+`
+	sqliteV32SQL = `ALTER TABLE "{{groups}}" ADD COLUMN "included_groups" text NULL;
+`
+	sqliteV32DownSQL = `ALTER TABLE "{{groups}}" DROP COLUMN "included_groups";
+`
+	sqliteV33SQL = `ALTER TABLE "{{api_keys}}" ADD COLUMN "filters" text NULL;
+`
+	sqliteV33DownSQL = `ALTER TABLE "{{api_keys}}" DROP COLUMN "filters";
+`
+	sqliteV34SQL = `ALTER TABLE "{{shares}}" ADD COLUMN "email_verification" text NULL;
+`
+	sqliteV34DownSQL = `ALTER TABLE "{{shares}}" DROP COLUMN "email_verification";
+`
+	sqliteV35SQL = `ALTER TABLE "{{shares}}" ADD COLUMN "file_request" text NULL;
+`
+	sqliteV35DownSQL = `ALTER TABLE "{{shares}}" DROP COLUMN "file_request";
+`
+	sqliteV36SQL = `CREATE TABLE "{{share_downloads}}" ("id" integer NOT NULL PRIMARY KEY, "date_time" bigint NOT NULL,
+"ip" varchar(50) NOT NULL, "file_path" varchar(512) NOT NULL, "bytes_sent" bigint NOT NULL,
+"share_id" integer NOT NULL REFERENCES "{{shares}}" ("id") ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED);
+CREATE INDEX "{{prefix}}share_downloads_share_id_idx" ON "{{share_downloads}}" ("share_id");
+CREATE INDEX "{{prefix}}share_downloads_date_time_idx" ON "{{share_downloads}}" ("date_time");
+`
+	sqliteV36DownSQL = `DROP TABLE IF EXISTS "{{share_downloads}}";
+`
+	sqliteV37SQL = `CREATE TABLE "{{webhook_deliveries}}" ("id" integer NOT NULL PRIMARY KEY,
+"delivery_id" varchar(60) NOT NULL UNIQUE, "url" text NOT NULL, "event" varchar(100) NOT NULL, "payload" text NOT NULL,
+"status" integer NOT NULL, "attempts" integer NOT NULL, "next_attempt_at" bigint NOT NULL, "created_at" bigint NOT NULL,
+"updated_at" bigint NOT NULL, "attempts_log" text NULL);
+CREATE INDEX "{{prefix}}webhook_deliveries_status_next_attempt_at_idx" ON "{{webhook_deliveries}}" ("status", "next_attempt_at");
+CREATE INDEX "{{prefix}}webhook_deliveries_updated_at_idx" ON "{{webhook_deliveries}}" ("updated_at");
+`
+	sqliteV37DownSQL = `DROP TABLE IF EXISTS "{{webhook_deliveries}}";
+`
+	sqliteV38SQL = `CREATE TABLE "{{dav_properties}}" ("id" integer NOT NULL PRIMARY KEY, "username" varchar(255) NOT NULL,
+"path" text NOT NULL, "path_hash" varchar(64) NOT NULL, "properties" text NOT NULL, "updated_at" bigint NOT NULL,
+CONSTRAINT "{{prefix}}unique_dav_properties_path" UNIQUE ("username", "path_hash"));
+`
+	sqliteV38DownSQL = `DROP TABLE IF EXISTS "{{dav_properties}}";
 `
 )
 
@@ -320,6 +388,22 @@ func (p *SQLiteProvider) deleteFolder(folder vfs.BaseVirtualFolder) error {
 	return sqlCommonDeleteFolder(folder, p.dbHandle)
 }
 
+func (p *SQLiteProvider) executeUsersBatch(items []UserBatchItem) error {
+	return sqlCommonExecuteUsersBatch(items, p.dbHandle, p.normalizeError)
+}
+
+func (p *SQLiteProvider) executeFoldersBatch(items []FolderBatchItem) error {
+	return sqlCommonExecuteFoldersBatch(items, p.dbHandle, p.normalizeError)
+}
+
+func (p *SQLiteProvider) listUsers(opts *ListOptions) ([]User, error) {
+	return sqlCommonListUsers(opts, p.dbHandle)
+}
+
+func (p *SQLiteProvider) listFolders(opts *ListOptions) ([]vfs.BaseVirtualFolder, error) {
+	return sqlCommonListFolders(opts, p.dbHandle)
+}
+
 func (p *SQLiteProvider) updateFolderQuota(name string, filesAdd int, sizeAdd int64, reset bool) error {
 	return sqlCommonUpdateFolderQuota(name, filesAdd, sizeAdd, reset, p.dbHandle)
 }
@@ -416,6 +500,10 @@ func (p *SQLiteProvider) updateAPIKeyLastUse(keyID string) error {
 	return sqlCommonUpdateAPIKeyLastUse(keyID, p.dbHandle)
 }
 
+func (p *SQLiteProvider) rotateAPIKey(apiKey *APIKey) error {
+	return sqlCommonRotateAPIKey(apiKey, p.dbHandle)
+}
+
 func (p *SQLiteProvider) shareExists(shareID, username string) (Share, error) {
 	return sqlCommonGetShareByID(shareID, username, p.dbHandle)
 }
@@ -508,6 +596,10 @@ func (p *SQLiteProvider) getSharedSession(key string) (Session, error) {
 	return sqlCommonGetSession(key, p.dbHandle)
 }
 
+func (p *SQLiteProvider) getSharedSessions(sessionType SessionType) ([]Session, error) {
+	return sqlCommonGetSessions(sessionType, p.dbHandle)
+}
+
 func (p *SQLiteProvider) cleanupSharedSessions(sessionType SessionType, before int64) error {
 	return sqlCommonCleanupSessions(sessionType, before, p.dbHandle)
 }
@@ -668,6 +760,82 @@ func (p *SQLiteProvider) setConfigs(configs *Configs) error {
 	return sqlCommonSetConfigs(configs, p.dbHandle)
 }
 
+func (p *SQLiteProvider) addAuditLogEntry(entry *AuditLogEntry) error {
+	return sqlCommonAddAuditLogEntry(entry, p.dbHandle)
+}
+
+func (p *SQLiteProvider) getLastAuditLogEntry(objectType, objectName string) (AuditLogEntry, error) {
+	return sqlCommonGetLastAuditLogEntry(objectType, objectName, p.dbHandle)
+}
+
+func (p *SQLiteProvider) searchAuditLogs(filters *AuditLogSearch) ([]AuditLogEntry, error) {
+	return sqlCommonSearchAuditLogs(filters, p.dbHandle)
+}
+
+func (p *SQLiteProvider) cleanupAuditLogs(before int64) error {
+	return sqlCommonCleanupAuditLogs(before, p.dbHandle)
+}
+
+func (p *SQLiteProvider) addShareDownload(shareID string, download *ShareDownload) error {
+	return sqlCommonAddShareDownload(shareID, download, p.dbHandle)
+}
+
+func (p *SQLiteProvider) searchShareDownloads(shareID string, filters *ShareDownloadSearch) ([]ShareDownload, error) {
+	return sqlCommonSearchShareDownloads(shareID, filters, p.dbHandle)
+}
+
+func (p *SQLiteProvider) getShareDownloadStats(shareID string) (ShareDownloadStats, error) {
+	return sqlCommonGetShareDownloadStats(shareID, p.dbHandle)
+}
+
+func (p *SQLiteProvider) cleanupShareDownloads(before int64) error {
+	return sqlCommonCleanupShareDownloads(before, p.dbHandle)
+}
+
+func (p *SQLiteProvider) addWebhookDelivery(delivery *WebhookDelivery) error {
+	return sqlCommonAddWebhookDelivery(delivery, p.dbHandle)
+}
+
+func (p *SQLiteProvider) getWebhookDelivery(id string) (WebhookDelivery, error) {
+	return sqlCommonGetWebhookDelivery(id, p.dbHandle)
+}
+
+func (p *SQLiteProvider) getDueWebhookDeliveries(now int64, limit int) ([]WebhookDelivery, error) {
+	return sqlCommonGetDueWebhookDeliveries(now, limit, p.dbHandle)
+}
+
+func (p *SQLiteProvider) lockWebhookDelivery(id string, nextAttemptAt, lockUntil int64) (bool, error) {
+	return sqlCommonLockWebhookDelivery(id, nextAttemptAt, lockUntil, p.dbHandle)
+}
+
+func (p *SQLiteProvider) updateWebhookDelivery(delivery *WebhookDelivery) error {
+	return sqlCommonUpdateWebhookDelivery(delivery, p.dbHandle)
+}
+
+func (p *SQLiteProvider) searchWebhookDeliveries(filters *WebhookDeliverySearch) ([]WebhookDelivery, error) {
+	return sqlCommonSearchWebhookDeliveries(filters, p.dbHandle)
+}
+
+func (p *SQLiteProvider) cleanupWebhookDeliveries(before int64) error {
+	return sqlCommonCleanupWebhookDeliveries(before, p.dbHandle)
+}
+
+func (p *SQLiteProvider) getDAVProperties(username, virtualPath string) (DAVProperties, error) {
+	return sqlCommonGetDAVProperties(username, virtualPath, p.dbHandle)
+}
+
+func (p *SQLiteProvider) setDAVProperties(props *DAVProperties) error {
+	return sqlCommonSetDAVProperties(props, p.dbHandle)
+}
+
+func (p *SQLiteProvider) renameDAVProperties(username, source, target string) error {
+	return sqlCommonRenameDAVProperties(username, source, target, p.dbHandle)
+}
+
+func (p *SQLiteProvider) deleteDAVProperties(username, virtualPath string, recursive bool) error {
+	return sqlCommonDeleteDAVProperties(username, virtualPath, recursive, p.dbHandle)
+}
+
 func (p *SQLiteProvider) setFirstDownloadTimestamp(username string) error {
 	return sqlCommonSetFirstDownloadTimestamp(username, p.dbHandle)
 }
@@ -714,6 +882,26 @@ func (p *SQLiteProvider) migrateDatabase() error { //nolint:dupl
 		providerLog(logger.LevelError, "%v", err)
 		logger.ErrorToConsole("%v", err)
 		return err
+	case version == 28:
+		return updateSQLiteDatabaseFromV28(p.dbHandle)
+	case version == 29:
+		return updateSQLiteDatabaseFromV29(p.dbHandle)
+	case version == 30:
+		return updateSQLiteDatabaseFromV30(p.dbHandle)
+	case version == 31:
+		return updateSQLiteDatabaseFromV31(p.dbHandle)
+	case version == 32:
+		return updateSQLiteDatabaseFromV32(p.dbHandle)
+	case version == 33:
+		return updateSQLiteDatabaseFromV33(p.dbHandle)
+	case version == 34:
+		return updateSQLiteDatabaseFromV34(p.dbHandle)
+	case version == 35:
+		return updateSQLiteDatabaseFromV35(p.dbHandle)
+	case version == 36:
+		return updateSQLiteDatabaseFromV36(p.dbHandle)
+	case version == 37:
+		return updateSQLiteDatabaseFromV37(p.dbHandle)
 	default:
 		if version > sqlDatabaseVersion {
 			providerLog(logger.LevelError, "database schema version %d is newer than the supported one: %d", version,
@@ -736,6 +924,26 @@ func (p *SQLiteProvider) revertDatabase(targetVersion int) error {
 	}
 
 	switch dbVersion.Version {
+	case 29:
+		return downgradeSQLiteDatabaseFromV29(p.dbHandle)
+	case 30:
+		return downgradeSQLiteDatabaseFromV30(p.dbHandle)
+	case 31:
+		return downgradeSQLiteDatabaseFromV31(p.dbHandle)
+	case 32:
+		return downgradeSQLiteDatabaseFromV32(p.dbHandle)
+	case 33:
+		return downgradeSQLiteDatabaseFromV33(p.dbHandle)
+	case 34:
+		return downgradeSQLiteDatabaseFromV34(p.dbHandle)
+	case 35:
+		return downgradeSQLiteDatabaseFromV35(p.dbHandle)
+	case 36:
+		return downgradeSQLiteDatabaseFromV36(p.dbHandle)
+	case 37:
+		return downgradeSQLiteDatabaseFromV37(p.dbHandle)
+	case 38:
+		return downgradeSQLiteDatabaseFromV38(p.dbHandle)
 	default:
 		return fmt.Errorf("database schema version not handled: %d", dbVersion.Version)
 	}
@@ -782,3 +990,277 @@ func (p *SQLiteProvider) normalizeError(err error, fieldType int) error {
 	_, err := dbHandle.ExecContext(ctx, sql)
 	return err
 }*/
+
+func updateSQLiteDatabaseFromV28(dbHandle *sql.DB) error {
+	if err := updateSQLiteDatabaseFrom28To29(dbHandle); err != nil {
+		return err
+	}
+	return updateSQLiteDatabaseFromV29(dbHandle)
+}
+
+func updateSQLiteDatabaseFromV29(dbHandle *sql.DB) error {
+	if err := updateSQLiteDatabaseFrom29To30(dbHandle); err != nil {
+		return err
+	}
+	return updateSQLiteDatabaseFromV30(dbHandle)
+}
+
+func updateSQLiteDatabaseFromV30(dbHandle *sql.DB) error {
+	if err := updateSQLiteDatabaseFrom30To31(dbHandle); err != nil {
+		return err
+	}
+	return updateSQLiteDatabaseFromV31(dbHandle)
+}
+
+func updateSQLiteDatabaseFromV31(dbHandle *sql.DB) error {
+	if err := updateSQLiteDatabaseFrom31To32(dbHandle); err != nil {
+		return err
+	}
+	return updateSQLiteDatabaseFromV32(dbHandle)
+}
+
+func updateSQLiteDatabaseFromV32(dbHandle *sql.DB) error {
+	if err := updateSQLiteDatabaseFrom32To33(dbHandle); err != nil {
+		return err
+	}
+	return updateSQLiteDatabaseFromV33(dbHandle)
+}
+
+func updateSQLiteDatabaseFromV33(dbHandle *sql.DB) error {
+	if err := updateSQLiteDatabaseFrom33To34(dbHandle); err != nil {
+		return err
+	}
+	return updateSQLiteDatabaseFromV34(dbHandle)
+}
+
+func updateSQLiteDatabaseFromV34(dbHandle *sql.DB) error {
+	if err := updateSQLiteDatabaseFrom34To35(dbHandle); err != nil {
+		return err
+	}
+	return updateSQLiteDatabaseFromV35(dbHandle)
+}
+
+func updateSQLiteDatabaseFromV35(dbHandle *sql.DB) error {
+	if err := updateSQLiteDatabaseFrom35To36(dbHandle); err != nil {
+		return err
+	}
+	return updateSQLiteDatabaseFromV36(dbHandle)
+}
+
+func updateSQLiteDatabaseFromV36(dbHandle *sql.DB) error {
+	if err := updateSQLiteDatabaseFrom36To37(dbHandle); err != nil {
+		return err
+	}
+	return updateSQLiteDatabaseFromV37(dbHandle)
+}
+
+func updateSQLiteDatabaseFromV37(dbHandle *sql.DB) error {
+	return updateSQLiteDatabaseFrom37To38(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV29(dbHandle *sql.DB) error {
+	return downgradeSQLiteDatabaseFrom29To28(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV30(dbHandle *sql.DB) error {
+	if err := downgradeSQLiteDatabaseFrom30To29(dbHandle); err != nil {
+		return err
+	}
+	return downgradeSQLiteDatabaseFromV29(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV31(dbHandle *sql.DB) error {
+	if err := downgradeSQLiteDatabaseFrom31To30(dbHandle); err != nil {
+		return err
+	}
+	return downgradeSQLiteDatabaseFromV30(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV32(dbHandle *sql.DB) error {
+	if err := downgradeSQLiteDatabaseFrom32To31(dbHandle); err != nil {
+		return err
+	}
+	return downgradeSQLiteDatabaseFromV31(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV33(dbHandle *sql.DB) error {
+	if err := downgradeSQLiteDatabaseFrom33To32(dbHandle); err != nil {
+		return err
+	}
+	return downgradeSQLiteDatabaseFromV32(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV34(dbHandle *sql.DB) error {
+	if err := downgradeSQLiteDatabaseFrom34To33(dbHandle); err != nil {
+		return err
+	}
+	return downgradeSQLiteDatabaseFromV33(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV35(dbHandle *sql.DB) error {
+	if err := downgradeSQLiteDatabaseFrom35To34(dbHandle); err != nil {
+		return err
+	}
+	return downgradeSQLiteDatabaseFromV34(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV36(dbHandle *sql.DB) error {
+	if err := downgradeSQLiteDatabaseFrom36To35(dbHandle); err != nil {
+		return err
+	}
+	return downgradeSQLiteDatabaseFromV35(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV37(dbHandle *sql.DB) error {
+	if err := downgradeSQLiteDatabaseFrom37To36(dbHandle); err != nil {
+		return err
+	}
+	return downgradeSQLiteDatabaseFromV36(dbHandle)
+}
+
+func downgradeSQLiteDatabaseFromV38(dbHandle *sql.DB) error {
+	if err := downgradeSQLiteDatabaseFrom38To37(dbHandle); err != nil {
+		return err
+	}
+	return downgradeSQLiteDatabaseFromV37(dbHandle)
+}
+
+func updateSQLiteDatabaseFrom28To29(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 28 -> 29")
+	providerLog(logger.LevelInfo, "updating database schema version: 28 -> 29")
+	sql := sqlReplaceAll(sqliteV29SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 29, true)
+}
+
+func downgradeSQLiteDatabaseFrom29To28(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 29 -> 28")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 29 -> 28")
+	sql := sqlReplaceAll(sqliteV29DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 28, false)
+}
+
+func updateSQLiteDatabaseFrom29To30(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 29 -> 30")
+	providerLog(logger.LevelInfo, "updating database schema version: 29 -> 30")
+	sql := sqlReplaceAll(sqliteV30SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 30, true)
+}
+
+func downgradeSQLiteDatabaseFrom30To29(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 30 -> 29")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 30 -> 29")
+	sql := sqlReplaceAll(sqliteV30DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 29, false)
+}
+
+func updateSQLiteDatabaseFrom30To31(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 30 -> 31")
+	providerLog(logger.LevelInfo, "updating database schema version: 30 -> 31")
+	sql := sqlReplaceAll(sqliteV31SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 31, true)
+}
+
+func downgradeSQLiteDatabaseFrom31To30(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 31 -> 30")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 31 -> 30")
+	sql := sqlReplaceAll(sqliteV31DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 30, false)
+}
+
+func updateSQLiteDatabaseFrom31To32(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 31 -> 32")
+	providerLog(logger.LevelInfo, "updating database schema version: 31 -> 32")
+	sql := sqlReplaceAll(sqliteV32SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 32, true)
+}
+
+func downgradeSQLiteDatabaseFrom32To31(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 32 -> 31")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 32 -> 31")
+	sql := sqlReplaceAll(sqliteV32DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 31, false)
+}
+
+func updateSQLiteDatabaseFrom32To33(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 32 -> 33")
+	providerLog(logger.LevelInfo, "updating database schema version: 32 -> 33")
+	sql := sqlReplaceAll(sqliteV33SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 33, true)
+}
+
+func downgradeSQLiteDatabaseFrom33To32(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 33 -> 32")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 33 -> 32")
+	sql := sqlReplaceAll(sqliteV33DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 32, false)
+}
+
+func updateSQLiteDatabaseFrom33To34(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 33 -> 34")
+	providerLog(logger.LevelInfo, "updating database schema version: 33 -> 34")
+	sql := sqlReplaceAll(sqliteV34SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 34, true)
+}
+
+func downgradeSQLiteDatabaseFrom34To33(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 34 -> 33")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 34 -> 33")
+	sql := sqlReplaceAll(sqliteV34DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 33, false)
+}
+
+func updateSQLiteDatabaseFrom34To35(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 34 -> 35")
+	providerLog(logger.LevelInfo, "updating database schema version: 34 -> 35")
+	sql := sqlReplaceAll(sqliteV35SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 35, true)
+}
+
+func downgradeSQLiteDatabaseFrom35To34(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 35 -> 34")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 35 -> 34")
+	sql := sqlReplaceAll(sqliteV35DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 34, false)
+}
+
+func updateSQLiteDatabaseFrom35To36(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 35 -> 36")
+	providerLog(logger.LevelInfo, "updating database schema version: 35 -> 36")
+	sql := sqlReplaceAll(sqliteV36SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 36, true)
+}
+
+func downgradeSQLiteDatabaseFrom36To35(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 36 -> 35")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 36 -> 35")
+	sql := sqlReplaceAll(sqliteV36DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 35, false)
+}
+
+func updateSQLiteDatabaseFrom36To37(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 36 -> 37")
+	providerLog(logger.LevelInfo, "updating database schema version: 36 -> 37")
+	sql := sqlReplaceAll(sqliteV37SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 37, true)
+}
+
+func downgradeSQLiteDatabaseFrom37To36(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 37 -> 36")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 37 -> 36")
+	sql := sqlReplaceAll(sqliteV37DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 36, false)
+}
+
+func updateSQLiteDatabaseFrom37To38(dbHandle *sql.DB) error {
+	logger.InfoToConsole("updating database schema version: 37 -> 38")
+	providerLog(logger.LevelInfo, "updating database schema version: 37 -> 38")
+	sql := sqlReplaceAll(sqliteV38SQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 38, true)
+}
+
+func downgradeSQLiteDatabaseFrom38To37(dbHandle *sql.DB) error {
+	logger.InfoToConsole("downgrading database schema version: 38 -> 37")
+	providerLog(logger.LevelInfo, "downgrading database schema version: 38 -> 37")
+	sql := sqlReplaceAll(sqliteV38DownSQL)
+	return sqlCommonExecSQLAndUpdateDBVersion(dbHandle, []string{sql}, 37, false)
+}
@@ -27,17 +27,18 @@ const (
 		"u.permissions,u.used_quota_size,u.used_quota_files,u.last_quota_update,u.upload_bandwidth,u.download_bandwidth," +
 		"u.expiration_date,u.last_login,u.status,u.filters,u.filesystem,u.additional_info,u.description,u.email,u.created_at," +
 		"u.updated_at,u.upload_data_transfer,u.download_data_transfer,u.total_data_transfer," +
-		"u.used_upload_data_transfer,u.used_download_data_transfer,u.deleted_at,u.first_download,u.first_upload,r.name,u.last_password_change"
-	selectFolderFields = "id,path,used_quota_size,used_quota_files,last_quota_update,name,description,filesystem"
+		"u.used_upload_data_transfer,u.used_download_data_transfer,u.deleted_at,u.first_download,u.first_upload,r.name,u.last_password_change,u.attributes"
+	selectFolderFields = "id,path,used_quota_size,used_quota_files,last_quota_update,name,description,filesystem,attributes"
 	selectAdminFields  = "a.id,a.username,a.password,a.status,a.email,a.permissions,a.filters,a.additional_info,a.description,a.created_at,a.updated_at,a.last_login,r.name"
-	selectAPIKeyFields = "key_id,name,api_key,scope,created_at,updated_at,last_use_at,expires_at,description,user_id,admin_id"
+	selectAPIKeyFields = "key_id,name,api_key,scope,created_at,updated_at,last_use_at,expires_at,description,user_id,admin_id,filters"
 	selectShareFields  = "s.share_id,s.name,s.description,s.scope,s.paths,u.username,s.created_at,s.updated_at,s.last_use_at," +
-		"s.expires_at,s.password,s.max_tokens,s.used_tokens,s.allow_from"
-	selectGroupFields       = "id,name,description,created_at,updated_at,user_settings"
+		"s.expires_at,s.password,s.max_tokens,s.used_tokens,s.allow_from,s.email_verification,s.file_request"
+	selectGroupFields       = "id,name,description,created_at,updated_at,user_settings,included_groups"
 	selectEventActionFields = "id,name,description,type,options"
 	selectRoleFields        = "id,name,description,created_at,updated_at"
 	selectIPListEntryFields = "type,ipornet,mode,protocols,description,created_at,updated_at,deleted_at"
 	selectMinimalFields     = "id,name"
+	selectAuditLogFields    = "id,date_time,executor,ip,role,operation,object_type,object_name,object_data,diff"
 )
 
 func getSQLPlaceholders() []string {
@@ -102,6 +103,15 @@ func getSessionQuery() string {
 		sqlPlaceholders[0])
 }
 
+func getSessionsQuery() string {
+	if config.Driver == MySQLDataProviderName {
+		return fmt.Sprintf("SELECT `key`,`data`,`type`,`timestamp` FROM %s WHERE `type` = %s", sqlTableSharedSessions,
+			sqlPlaceholders[0])
+	}
+	return fmt.Sprintf(`SELECT key,data,type,timestamp FROM %s WHERE type = %s`, sqlTableSharedSessions,
+		sqlPlaceholders[0])
+}
+
 func getCleanupSessionsQuery() string {
 	return fmt.Sprintf(`DELETE from %s WHERE type = %s AND timestamp < %s`,
 		sqlTableSharedSessions, sqlPlaceholders[0], sqlPlaceholders[1])
@@ -409,15 +419,15 @@ func getDumpGroupsQuery() string {
 }
 
 func getAddGroupQuery() string {
-	return fmt.Sprintf(`INSERT INTO %s (name,description,created_at,updated_at,user_settings)
-		VALUES (%s,%s,%s,%s,%s)`, getSQLQuotedName(sqlTableGroups), sqlPlaceholders[0], sqlPlaceholders[1],
-		sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4])
+	return fmt.Sprintf(`INSERT INTO %s (name,description,created_at,updated_at,user_settings,included_groups)
+		VALUES (%s,%s,%s,%s,%s,%s)`, getSQLQuotedName(sqlTableGroups), sqlPlaceholders[0], sqlPlaceholders[1],
+		sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5])
 }
 
 func getUpdateGroupQuery() string {
-	return fmt.Sprintf(`UPDATE %s SET description=%s,user_settings=%s,updated_at=%s
+	return fmt.Sprintf(`UPDATE %s SET description=%s,user_settings=%s,updated_at=%s,included_groups=%s
 		WHERE name = %s`, getSQLQuotedName(sqlTableGroups), sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2],
-		sqlPlaceholders[3])
+		sqlPlaceholders[3], sqlPlaceholders[4])
 }
 
 func getDeleteGroupQuery() string {
@@ -479,27 +489,30 @@ func getDumpSharesQuery() string {
 
 func getAddShareQuery() string {
 	return fmt.Sprintf(`INSERT INTO %s (share_id,name,description,scope,paths,created_at,updated_at,last_use_at,
-		expires_at,password,max_tokens,used_tokens,allow_from,user_id) VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s)`,
+		expires_at,password,max_tokens,used_tokens,allow_from,user_id,email_verification,file_request)
+		VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s)`,
 		sqlTableShares, sqlPlaceholders[0], sqlPlaceholders[1],
 		sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5], sqlPlaceholders[6],
 		sqlPlaceholders[7], sqlPlaceholders[8], sqlPlaceholders[9], sqlPlaceholders[10], sqlPlaceholders[11],
-		sqlPlaceholders[12], sqlPlaceholders[13])
+		sqlPlaceholders[12], sqlPlaceholders[13], sqlPlaceholders[14], sqlPlaceholders[15])
 }
 
 func getUpdateShareRestoreQuery() string {
 	return fmt.Sprintf(`UPDATE %s SET name=%s,description=%s,scope=%s,paths=%s,created_at=%s,updated_at=%s,
-		last_use_at=%s,expires_at=%s,password=%s,max_tokens=%s,used_tokens=%s,allow_from=%s,user_id=%s WHERE share_id = %s`, sqlTableShares,
+		last_use_at=%s,expires_at=%s,password=%s,max_tokens=%s,used_tokens=%s,allow_from=%s,user_id=%s,email_verification=%s,
+		file_request=%s WHERE share_id = %s`, sqlTableShares,
 		sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4],
 		sqlPlaceholders[5], sqlPlaceholders[6], sqlPlaceholders[7], sqlPlaceholders[8], sqlPlaceholders[9],
-		sqlPlaceholders[10], sqlPlaceholders[11], sqlPlaceholders[12], sqlPlaceholders[13])
+		sqlPlaceholders[10], sqlPlaceholders[11], sqlPlaceholders[12], sqlPlaceholders[13], sqlPlaceholders[14],
+		sqlPlaceholders[15])
 }
 
 func getUpdateShareQuery() string {
 	return fmt.Sprintf(`UPDATE %s SET name=%s,description=%s,scope=%s,paths=%s,updated_at=%s,expires_at=%s,
-		password=%s,max_tokens=%s,allow_from=%s,user_id=%s WHERE share_id = %s`, sqlTableShares,
-		sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4],
-		sqlPlaceholders[5], sqlPlaceholders[6], sqlPlaceholders[7], sqlPlaceholders[8], sqlPlaceholders[9],
-		sqlPlaceholders[10])
+		password=%s,max_tokens=%s,allow_from=%s,user_id=%s,email_verification=%s,file_request=%s WHERE share_id = %s`,
+		sqlTableShares, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3],
+		sqlPlaceholders[4], sqlPlaceholders[5], sqlPlaceholders[6], sqlPlaceholders[7], sqlPlaceholders[8],
+		sqlPlaceholders[9], sqlPlaceholders[10], sqlPlaceholders[11], sqlPlaceholders[12])
 }
 
 func getDeleteShareQuery() string {
@@ -520,16 +533,22 @@ func getDumpAPIKeysQuery() string {
 }
 
 func getAddAPIKeyQuery() string {
-	return fmt.Sprintf(`INSERT INTO %s (key_id,name,api_key,scope,created_at,updated_at,last_use_at,expires_at,description,user_id,admin_id)
-		VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s)`, sqlTableAPIKeys, sqlPlaceholders[0], sqlPlaceholders[1],
+	return fmt.Sprintf(`INSERT INTO %s (key_id,name,api_key,scope,created_at,updated_at,last_use_at,expires_at,description,user_id,admin_id,filters)
+		VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s)`, sqlTableAPIKeys, sqlPlaceholders[0], sqlPlaceholders[1],
 		sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5], sqlPlaceholders[6],
-		sqlPlaceholders[7], sqlPlaceholders[8], sqlPlaceholders[9], sqlPlaceholders[10])
+		sqlPlaceholders[7], sqlPlaceholders[8], sqlPlaceholders[9], sqlPlaceholders[10], sqlPlaceholders[11])
 }
 
 func getUpdateAPIKeyQuery() string {
-	return fmt.Sprintf(`UPDATE %s SET name=%s,scope=%s,expires_at=%s,user_id=%s,admin_id=%s,description=%s,updated_at=%s
+	return fmt.Sprintf(`UPDATE %s SET name=%s,scope=%s,expires_at=%s,user_id=%s,admin_id=%s,description=%s,updated_at=%s,filters=%s
 		WHERE key_id = %s`, sqlTableAPIKeys, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2],
-		sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5], sqlPlaceholders[6], sqlPlaceholders[7])
+		sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5], sqlPlaceholders[6], sqlPlaceholders[7],
+		sqlPlaceholders[8])
+}
+
+func getRotateAPIKeyQuery() string {
+	return fmt.Sprintf(`UPDATE %s SET api_key=%s,filters=%s,updated_at=%s WHERE key_id = %s`, sqlTableAPIKeys,
+		sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3])
 }
 
 func getDeleteAPIKeyQuery() string {
@@ -599,6 +618,49 @@ func getUsersQuery(order, role string) string {
 		selectUserFields, sqlTableUsers, sqlTableRoles, sqlPlaceholders[0], order, sqlPlaceholders[1], sqlPlaceholders[2])
 }
 
+// getKeysetQueryParts returns the condition, if any, to start after the cursor and the
+// ORDER BY clause for a keyset paginated listing. The provided args are extended
+// with the ones required for the condition
+func getKeysetQueryParts(alias, keyField string, opts *ListOptions, args []any) (string, string, []any) {
+	op := ">"
+	if opts.Order == OrderDESC {
+		op = "<"
+	}
+	if opts.SortBy == keyField {
+		orderBy := fmt.Sprintf("%s%s %s", alias, keyField, opts.Order)
+		if opts.After == nil {
+			return "", orderBy, args
+		}
+		condition := fmt.Sprintf("%s%s %s %s", alias, keyField, op, sqlPlaceholders[len(args)])
+		return condition, orderBy, append(args, opts.After.Key)
+	}
+	orderBy := fmt.Sprintf("%[1]s%[2]s %[3]s, %[1]s%[4]s %[3]s", alias, opts.SortBy, opts.Order, keyField)
+	if opts.After == nil {
+		return "", orderBy, args
+	}
+	condition := fmt.Sprintf("(%[1]s%[2]s %[3]s %[4]s OR (%[1]s%[2]s = %[5]s AND %[1]s%[6]s %[3]s %[7]s))",
+		alias, opts.SortBy, op, sqlPlaceholders[len(args)], sqlPlaceholders[len(args)+1], keyField,
+		sqlPlaceholders[len(args)+2])
+	return condition, orderBy, append(args, opts.After.Value, opts.After.Value, opts.After.Key)
+}
+
+func getListUsersQuery(opts *ListOptions) (string, []any) {
+	var args []any
+	conditions := []string{"u.deleted_at = 0"}
+	if opts.Role != "" {
+		conditions = append(conditions, fmt.Sprintf("u.role_id is NOT NULL AND r.name = %s", sqlPlaceholders[len(args)]))
+		args = append(args, opts.Role)
+	}
+	condition, orderBy, args := getKeysetQueryParts("u.", SortFieldUsername, opts, args)
+	if condition != "" {
+		conditions = append(conditions, condition)
+	}
+	q := fmt.Sprintf(`SELECT %s FROM %s u LEFT JOIN %s r on r.id = u.role_id WHERE %s ORDER BY %s LIMIT %s`,
+		selectUserFields, sqlTableUsers, sqlTableRoles, strings.Join(conditions, " AND "), orderBy,
+		sqlPlaceholders[len(args)])
+	return q, append(args, opts.Limit)
+}
+
 func getUsersForQuotaCheckQuery(numArgs int) string {
 	var sb strings.Builder
 	for idx := 0; idx < numArgs; idx++ {
@@ -691,28 +753,30 @@ func getAddUserQuery(role string) string {
 	return fmt.Sprintf(`INSERT INTO %s (username,password,public_keys,home_dir,uid,gid,max_sessions,quota_size,quota_files,permissions,
 		used_quota_size,used_quota_files,last_quota_update,upload_bandwidth,download_bandwidth,status,last_login,expiration_date,filters,
 		filesystem,additional_info,description,email,created_at,updated_at,upload_data_transfer,download_data_transfer,total_data_transfer,
-		used_upload_data_transfer,used_download_data_transfer,deleted_at,first_download,first_upload,role_id,last_password_change)
+		used_upload_data_transfer,used_download_data_transfer,deleted_at,first_download,first_upload,role_id,last_password_change,
+		attributes)
 		VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,0,0,0,%s,%s,%s,0,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s,0,0,0,0,0,
-		COALESCE((SELECT id from %s WHERE name=%s),%s),%s)`,
+		COALESCE((SELECT id from %s WHERE name=%s),%s),%s,%s)`,
 		sqlTableUsers, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4],
 		sqlPlaceholders[5], sqlPlaceholders[6], sqlPlaceholders[7], sqlPlaceholders[8], sqlPlaceholders[9],
 		sqlPlaceholders[10], sqlPlaceholders[11], sqlPlaceholders[12], sqlPlaceholders[13], sqlPlaceholders[14],
 		sqlPlaceholders[15], sqlPlaceholders[16], sqlPlaceholders[17], sqlPlaceholders[18], sqlPlaceholders[19],
 		sqlPlaceholders[20], sqlPlaceholders[21], sqlPlaceholders[22], sqlPlaceholders[23], sqlTableRoles,
-		sqlPlaceholders[24], getCoalesceDefaultForRole(role), sqlPlaceholders[25])
+		sqlPlaceholders[24], getCoalesceDefaultForRole(role), sqlPlaceholders[25], sqlPlaceholders[26])
 }
 
 func getUpdateUserQuery(role string) string {
 	return fmt.Sprintf(`UPDATE %s SET password=%s,public_keys=%s,home_dir=%s,uid=%s,gid=%s,max_sessions=%s,quota_size=%s,
 		quota_files=%s,permissions=%s,upload_bandwidth=%s,download_bandwidth=%s,status=%s,expiration_date=%s,filters=%s,filesystem=%s,
 		additional_info=%s,description=%s,email=%s,updated_at=%s,upload_data_transfer=%s,download_data_transfer=%s,
-		total_data_transfer=%s,role_id=COALESCE((SELECT id from %s WHERE name=%s),%s),last_password_change=%s WHERE username = %s`,
+		total_data_transfer=%s,role_id=COALESCE((SELECT id from %s WHERE name=%s),%s),last_password_change=%s,attributes=%s
+		WHERE username = %s`,
 		sqlTableUsers, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4],
 		sqlPlaceholders[5], sqlPlaceholders[6], sqlPlaceholders[7], sqlPlaceholders[8], sqlPlaceholders[9],
 		sqlPlaceholders[10], sqlPlaceholders[11], sqlPlaceholders[12], sqlPlaceholders[13], sqlPlaceholders[14],
 		sqlPlaceholders[15], sqlPlaceholders[16], sqlPlaceholders[17], sqlPlaceholders[18], sqlPlaceholders[19],
 		sqlPlaceholders[20], sqlPlaceholders[21], sqlTableRoles, sqlPlaceholders[22], getCoalesceDefaultForRole(role),
-		sqlPlaceholders[23], sqlPlaceholders[24])
+		sqlPlaceholders[23], sqlPlaceholders[24], sqlPlaceholders[25])
 }
 
 func getUpdateUserPasswordQuery() string {
@@ -737,14 +801,14 @@ func getFolderByNameQuery() string {
 }
 
 func getAddFolderQuery() string {
-	return fmt.Sprintf(`INSERT INTO %s (path,used_quota_size,used_quota_files,last_quota_update,name,description,filesystem)
-		VALUES (%s,%s,%s,%s,%s,%s,%s)`, sqlTableFolders, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2],
-		sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5], sqlPlaceholders[6])
+	return fmt.Sprintf(`INSERT INTO %s (path,used_quota_size,used_quota_files,last_quota_update,name,description,filesystem,
+		attributes) VALUES (%s,%s,%s,%s,%s,%s,%s,%s)`, sqlTableFolders, sqlPlaceholders[0], sqlPlaceholders[1],
+		sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5], sqlPlaceholders[6], sqlPlaceholders[7])
 }
 
 func getUpdateFolderQuery() string {
-	return fmt.Sprintf(`UPDATE %s SET path=%s,description=%s,filesystem=%s WHERE name = %s`, sqlTableFolders, sqlPlaceholders[0],
-		sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3])
+	return fmt.Sprintf(`UPDATE %s SET path=%s,description=%s,filesystem=%s,attributes=%s WHERE name = %s`, sqlTableFolders,
+		sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4])
 }
 
 func getDeleteFolderQuery() string {
@@ -781,10 +845,10 @@ func getClearGroupFolderMappingQuery() string {
 }
 
 func getAddGroupFolderMappingQuery() string {
-	return fmt.Sprintf(`INSERT INTO %s (virtual_path,quota_size,quota_files,folder_id,group_id)
-		VALUES (%s,%s,%s,(SELECT id FROM %s WHERE name = %s),(SELECT id FROM %s WHERE name = %s))`,
-		sqlTableGroupsFoldersMapping, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlTableFolders,
-		sqlPlaceholders[3], getSQLQuotedName(sqlTableGroups), sqlPlaceholders[4])
+	return fmt.Sprintf(`INSERT INTO %s (virtual_path,quota_size,quota_files,upload_bandwidth,download_bandwidth,folder_id,group_id)
+		VALUES (%s,%s,%s,%s,%s,(SELECT id FROM %s WHERE name = %s),(SELECT id FROM %s WHERE name = %s))`,
+		sqlTableGroupsFoldersMapping, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3],
+		sqlPlaceholders[4], sqlTableFolders, sqlPlaceholders[5], getSQLQuotedName(sqlTableGroups), sqlPlaceholders[6])
 }
 
 func getClearUserFolderMappingQuery() string {
@@ -793,10 +857,10 @@ func getClearUserFolderMappingQuery() string {
 }
 
 func getAddUserFolderMappingQuery() string {
-	return fmt.Sprintf(`INSERT INTO %s (virtual_path,quota_size,quota_files,folder_id,user_id)
-		VALUES (%s,%s,%s,(SELECT id FROM %s WHERE name = %s),(SELECT id FROM %s WHERE username = %s))`,
-		sqlTableUsersFoldersMapping, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlTableFolders,
-		sqlPlaceholders[3], sqlTableUsers, sqlPlaceholders[4])
+	return fmt.Sprintf(`INSERT INTO %s (virtual_path,quota_size,quota_files,upload_bandwidth,download_bandwidth,folder_id,user_id)
+		VALUES (%s,%s,%s,%s,%s,(SELECT id FROM %s WHERE name = %s),(SELECT id FROM %s WHERE username = %s))`,
+		sqlTableUsersFoldersMapping, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3],
+		sqlPlaceholders[4], sqlTableFolders, sqlPlaceholders[5], sqlTableUsers, sqlPlaceholders[6])
 }
 
 func getFoldersQuery(order string, minimal bool) string {
@@ -810,6 +874,16 @@ func getFoldersQuery(order string, minimal bool) string {
 		order, sqlPlaceholders[0], sqlPlaceholders[1])
 }
 
+func getListFoldersQuery(opts *ListOptions) (string, []any) {
+	condition, orderBy, args := getKeysetQueryParts("", SortFieldName, opts, nil)
+	if condition != "" {
+		condition = "WHERE " + condition + " "
+	}
+	q := fmt.Sprintf(`SELECT %s FROM %s %sORDER BY %s LIMIT %s`, selectFolderFields, sqlTableFolders, condition,
+		orderBy, sqlPlaceholders[len(args)])
+	return q, append(args, opts.Limit)
+}
+
 func getUpdateFolderQuotaQuery(reset bool) string {
 	if reset {
 		return fmt.Sprintf(`UPDATE %s SET used_quota_size = %s,used_quota_files = %s,last_quota_update = %s
@@ -872,7 +946,7 @@ func getRelatedFoldersForUsersQuery(users []User) string {
 		sb.WriteString(")")
 	}
 	return fmt.Sprintf(`SELECT f.id,f.name,f.path,f.used_quota_size,f.used_quota_files,f.last_quota_update,fm.virtual_path,
-		fm.quota_size,fm.quota_files,fm.user_id,f.filesystem,f.description FROM %s f INNER JOIN %s fm ON f.id = fm.folder_id WHERE
+		fm.quota_size,fm.quota_files,fm.upload_bandwidth,fm.download_bandwidth,fm.user_id,f.filesystem,f.description,f.attributes FROM %s f INNER JOIN %s fm ON f.id = fm.folder_id WHERE
 		fm.user_id IN %s ORDER BY f.name`, sqlTableFolders, sqlTableUsersFoldersMapping, sb.String())
 }
 
@@ -959,7 +1033,7 @@ func getRelatedFoldersForGroupsQuery(groups []Group) string {
 		sb.WriteString(")")
 	}
 	return fmt.Sprintf(`SELECT f.id,f.name,f.path,f.used_quota_size,f.used_quota_files,f.last_quota_update,fm.virtual_path,
-		fm.quota_size,fm.quota_files,fm.group_id,f.filesystem,f.description FROM %s f INNER JOIN %s fm ON f.id = fm.folder_id WHERE
+		fm.quota_size,fm.quota_files,fm.upload_bandwidth,fm.download_bandwidth,fm.group_id,f.filesystem,f.description,f.attributes FROM %s f INNER JOIN %s fm ON f.id = fm.folder_id WHERE
 		fm.group_id IN %s ORDER BY f.name`, sqlTableFolders, sqlTableGroupsFoldersMapping, sb.String())
 }
 
@@ -1175,6 +1249,255 @@ func getCleanupNodesQuery() string {
 	return fmt.Sprintf(`DELETE FROM %s WHERE updated_at < %s`, sqlTableNodes, sqlPlaceholders[0])
 }
 
+func getAddAuditLogEntryQuery() string {
+	return fmt.Sprintf(`INSERT INTO %s (date_time,executor,ip,role,operation,object_type,object_name,object_data,diff)
+		VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s)`, sqlTableAuditLogs, sqlPlaceholders[0], sqlPlaceholders[1],
+		sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5], sqlPlaceholders[6],
+		sqlPlaceholders[7], sqlPlaceholders[8])
+}
+
+func getLastAuditLogEntryQuery() string {
+	return fmt.Sprintf(`SELECT %s FROM %s WHERE object_type = %s AND object_name = %s AND operation <> '%s' ORDER BY id DESC LIMIT 1`,
+		selectAuditLogFields, sqlTableAuditLogs, sqlPlaceholders[0], sqlPlaceholders[1], operationImpersonate)
+}
+
+func getSearchAuditLogsQuery(filters *AuditLogSearch) (string, []any) {
+	var sb strings.Builder
+	var args []any
+
+	addCondition := func(condition string, arg any) {
+		if len(args) == 0 {
+			sb.WriteString(" WHERE ")
+		} else {
+			sb.WriteString(" AND ")
+		}
+		sb.WriteString(condition)
+		sb.WriteString(sqlPlaceholders[len(args)])
+		args = append(args, arg)
+	}
+	addInCondition := func(field string, values []string) {
+		if len(args) == 0 {
+			sb.WriteString(" WHERE ")
+		} else {
+			sb.WriteString(" AND ")
+		}
+		sb.WriteString(field)
+		sb.WriteString(" IN (")
+		for idx, val := range values {
+			if idx > 0 {
+				sb.WriteString(",")
+			}
+			sb.WriteString(sqlPlaceholders[len(args)])
+			args = append(args, val)
+		}
+		sb.WriteString(")")
+	}
+
+	fields := selectAuditLogFields
+	if filters.OmitObjectData {
+		fields = strings.Replace(fields, "object_data", "NULL", 1)
+	}
+	sb.WriteString("SELECT ")
+	sb.WriteString(fields)
+	sb.WriteString(" FROM ")
+	sb.WriteString(sqlTableAuditLogs)
+	if filters.FromID > 0 {
+		if filters.Order == OrderASC {
+			addCondition("id > ", filters.FromID)
+		} else {
+			addCondition("id < ", filters.FromID)
+		}
+	}
+	if filters.StartTimestamp > 0 {
+		addCondition("date_time >= ", filters.StartTimestamp)
+	}
+	if filters.EndTimestamp > 0 {
+		addCondition("date_time <= ", filters.EndTimestamp)
+	}
+	if filters.Executor != "" {
+		addCondition("executor = ", filters.Executor)
+	}
+	if filters.IP != "" {
+		addCondition("ip = ", filters.IP)
+	}
+	if filters.Role != "" {
+		addCondition("role = ", filters.Role)
+	}
+	if filters.ObjectName != "" {
+		addCondition("object_name = ", filters.ObjectName)
+	}
+	if len(filters.ObjectTypes) > 0 {
+		addInCondition("object_type", filters.ObjectTypes)
+	}
+	if len(filters.Operations) > 0 {
+		addInCondition("operation", filters.Operations)
+	}
+	sb.WriteString(" ORDER BY id ")
+	sb.WriteString(filters.Order)
+	sb.WriteString(" LIMIT ")
+	sb.WriteString(sqlPlaceholders[len(args)])
+	args = append(args, filters.Limit)
+
+	return sb.String(), args
+}
+
+func getCleanupAuditLogsQuery() string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE date_time < %s`, sqlTableAuditLogs, sqlPlaceholders[0])
+}
+
+func getAddShareDownloadQuery() string {
+	return fmt.Sprintf(`INSERT INTO %s (date_time,ip,file_path,bytes_sent,share_id) SELECT %s,%s,%s,%s,id FROM %s
+		WHERE share_id = %s`, sqlTableShareDownloads, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2],
+		sqlPlaceholders[3], sqlTableShares, sqlPlaceholders[4])
+}
+
+func getSearchShareDownloadsQuery(shareID string, filters *ShareDownloadSearch) (string, []any) {
+	var sb strings.Builder
+	args := []any{shareID}
+
+	addCondition := func(condition string, arg any) {
+		sb.WriteString(" AND ")
+		sb.WriteString(condition)
+		sb.WriteString(sqlPlaceholders[len(args)])
+		args = append(args, arg)
+	}
+
+	sb.WriteString(fmt.Sprintf(`SELECT d.id,d.date_time,d.ip,d.file_path,d.bytes_sent FROM %s d
+		INNER JOIN %s s ON d.share_id = s.id WHERE s.share_id = %s`, sqlTableShareDownloads, sqlTableShares,
+		sqlPlaceholders[0]))
+	if filters.FromID > 0 {
+		if filters.Order == OrderASC {
+			addCondition("d.id > ", filters.FromID)
+		} else {
+			addCondition("d.id < ", filters.FromID)
+		}
+	}
+	if filters.StartTimestamp > 0 {
+		addCondition("d.date_time >= ", filters.StartTimestamp)
+	}
+	if filters.EndTimestamp > 0 {
+		addCondition("d.date_time <= ", filters.EndTimestamp)
+	}
+	if filters.IP != "" {
+		addCondition("d.ip = ", filters.IP)
+	}
+	sb.WriteString(" ORDER BY d.id ")
+	sb.WriteString(filters.Order)
+	sb.WriteString(" LIMIT ")
+	sb.WriteString(sqlPlaceholders[len(args)])
+	args = append(args, filters.Limit)
+
+	return sb.String(), args
+}
+
+func getShareDownloadStatsQuery() string {
+	return fmt.Sprintf(`SELECT COUNT(d.id),SUM(d.bytes_sent),COUNT(DISTINCT d.ip),MIN(d.date_time),MAX(d.date_time)
+		FROM %s d INNER JOIN %s s ON d.share_id = s.id WHERE s.share_id = %s`, sqlTableShareDownloads,
+		sqlTableShares, sqlPlaceholders[0])
+}
+
+func getCleanupShareDownloadsQuery() string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE date_time < %s`, sqlTableShareDownloads, sqlPlaceholders[0])
+}
+
+const selectWebhookDeliveryFields = "delivery_id,url,event,payload,status,attempts,next_attempt_at,created_at,updated_at,attempts_log"
+
+func getAddWebhookDeliveryQuery() string {
+	return fmt.Sprintf(`INSERT INTO %s (delivery_id,url,event,payload,status,attempts,next_attempt_at,created_at,updated_at)
+		VALUES (%s,%s,%s,%s,%s,%s,%s,%s,%s)`, sqlTableWebhookDeliveries, sqlPlaceholders[0], sqlPlaceholders[1],
+		sqlPlaceholders[2], sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5], sqlPlaceholders[6],
+		sqlPlaceholders[7], sqlPlaceholders[8])
+}
+
+func getWebhookDeliveryQuery() string {
+	return fmt.Sprintf(`SELECT %s FROM %s WHERE delivery_id = %s`, selectWebhookDeliveryFields,
+		sqlTableWebhookDeliveries, sqlPlaceholders[0])
+}
+
+func getDueWebhookDeliveriesQuery() string {
+	return fmt.Sprintf(`SELECT %s FROM %s WHERE status = %s AND next_attempt_at <= %s ORDER BY next_attempt_at ASC LIMIT %s`,
+		selectWebhookDeliveryFields, sqlTableWebhookDeliveries, sqlPlaceholders[0], sqlPlaceholders[1],
+		sqlPlaceholders[2])
+}
+
+func getLockWebhookDeliveryQuery() string {
+	return fmt.Sprintf(`UPDATE %s SET next_attempt_at = %s WHERE delivery_id = %s AND status = %s AND next_attempt_at = %s`,
+		sqlTableWebhookDeliveries, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3])
+}
+
+func getUpdateWebhookDeliveryQuery() string {
+	return fmt.Sprintf(`UPDATE %s SET status = %s,attempts = %s,next_attempt_at = %s,updated_at = %s,attempts_log = %s
+		WHERE delivery_id = %s`, sqlTableWebhookDeliveries, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2],
+		sqlPlaceholders[3], sqlPlaceholders[4], sqlPlaceholders[5])
+}
+
+func getSearchWebhookDeliveriesQuery(filters *WebhookDeliverySearch) (string, []any) {
+	var sb strings.Builder
+	var args []any
+
+	sb.WriteString(fmt.Sprintf(`SELECT %s FROM %s`, selectWebhookDeliveryFields, sqlTableWebhookDeliveries))
+	if filters.Status > 0 {
+		sb.WriteString(" WHERE status = ")
+		sb.WriteString(sqlPlaceholders[len(args)])
+		args = append(args, filters.Status)
+	}
+	sb.WriteString(" ORDER BY id ")
+	sb.WriteString(filters.Order)
+	sb.WriteString(" LIMIT ")
+	sb.WriteString(sqlPlaceholders[len(args)])
+	args = append(args, filters.Limit)
+	sb.WriteString(" OFFSET ")
+	sb.WriteString(sqlPlaceholders[len(args)])
+	args = append(args, filters.Offset)
+
+	return sb.String(), args
+}
+
+func getCleanupWebhookDeliveriesQuery() string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE status <> %s AND updated_at < %s`, sqlTableWebhookDeliveries,
+		sqlPlaceholders[0], sqlPlaceholders[1])
+}
+
+func getDAVPropertiesQuery() string {
+	return fmt.Sprintf(`SELECT path,properties,updated_at FROM %s WHERE username = %s AND path_hash = %s`,
+		sqlTableDAVProperties, sqlPlaceholders[0], sqlPlaceholders[1])
+}
+
+func getAddDAVPropertiesQuery() string {
+	return fmt.Sprintf(`INSERT INTO %s (username,path,path_hash,properties,updated_at) VALUES (%s,%s,%s,%s,%s)`,
+		sqlTableDAVProperties, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3],
+		sqlPlaceholders[4])
+}
+
+func getUpdateDAVPropertiesPathQuery() string {
+	return fmt.Sprintf(`UPDATE %s SET path = %s,path_hash = %s WHERE username = %s AND path_hash = %s`,
+		sqlTableDAVProperties, sqlPlaceholders[0], sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3])
+}
+
+func getDeleteDAVPropertiesQuery() string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE username = %s AND path_hash = %s`, sqlTableDAVProperties,
+		sqlPlaceholders[0], sqlPlaceholders[1])
+}
+
+func getDeleteUserDAVPropertiesQuery() string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE username = %s`, sqlTableDAVProperties, sqlPlaceholders[0])
+}
+
+// the children of a directory are selected using a range query: the paths starting with
+// "dir/" are greater than "dir/" and lower than "dir0", "0" follows "/" in ASCII order
+func getDAVPropertiesTreeCondition() string {
+	return fmt.Sprintf(`username = %s AND (path_hash = %s OR (path > %s AND path < %s))`, sqlPlaceholders[0],
+		sqlPlaceholders[1], sqlPlaceholders[2], sqlPlaceholders[3])
+}
+
+func getDAVPropertiesTreePathsQuery() string {
+	return fmt.Sprintf(`SELECT path FROM %s WHERE %s`, sqlTableDAVProperties, getDAVPropertiesTreeCondition())
+}
+
+func getDeleteDAVPropertiesTreeQuery() string {
+	return fmt.Sprintf(`DELETE FROM %s WHERE %s`, sqlTableDAVProperties, getDAVPropertiesTreeCondition())
+}
+
 func getDatabaseVersionQuery() string {
 	return fmt.Sprintf("SELECT version from %s LIMIT 1", sqlTableSchemaVersion)
 }
@@ -0,0 +1,195 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	sshCertSourceAddressOption     = "source-address"
+	sshCertPortForwardingExtension = "permit-port-forwarding"
+)
+
+// SSHCertificatePrincipalMapping defines a rule to map the principals of an SSH
+// user certificate to an SFTPGo username
+type SSHCertificatePrincipalMapping struct {
+	// Regular expression to match against the certificate principals
+	Principal string `json:"principal"`
+	// Username the matching principals are mapped to. Capture groups from the
+	// principal expression can be referenced, for example "$1" or "${name}"
+	Username string `json:"username"`
+}
+
+// SSHCertificateConfig defines how SSH user certificates are accepted for a user
+type SSHCertificateConfig struct {
+	// Public keys, in OpenSSH authorized keys format, of certificate authorities
+	// trusted for this user in addition to the globally configured ones
+	TrustedCAKeys []string `json:"trusted_ca_keys,omitempty"`
+	// Certificate principals allowed for this user. If empty a certificate principal
+	// must match the username or be mapped to it using the defined mapping rules
+	AllowedPrincipals []string `json:"allowed_principals,omitempty"`
+	// Rules to map certificate principals to the username
+	PrincipalMappings []SSHCertificatePrincipalMapping `json:"principal_mappings,omitempty"`
+	// Deny certificates granting port forwarding
+	DenyPortForwarding bool `json:"deny_port_forwarding,omitempty"`
+	// Deny certificates without the "source-address" critical option
+	RequireSourceAddress bool `json:"require_source_address,omitempty"`
+}
+
+// IsEmpty returns true if no certificate setting is defined
+func (c *SSHCertificateConfig) IsEmpty() bool {
+	return len(c.TrustedCAKeys) == 0 && len(c.AllowedPrincipals) == 0 && len(c.PrincipalMappings) == 0 &&
+		!c.DenyPortForwarding && !c.RequireSourceAddress
+}
+
+// IsUserAuthority returns true if the specified key is one of the trusted certificate authorities
+func (c *SSHCertificateConfig) IsUserAuthority(key ssh.PublicKey) bool {
+	for _, k := range c.TrustedCAKeys {
+		caKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k))
+		if err != nil {
+			providerLog(logger.LevelError, "unable to parse trusted SSH CA key %q: %v", k, err)
+			continue
+		}
+		if bytes.Equal(caKey.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPrincipal returns the first of the specified certificate principals allowed
+// for the specified username or an empty string if no principal is allowed
+func (c *SSHCertificateConfig) GetPrincipal(principals []string, username string) string {
+	for _, principal := range principals {
+		if len(c.AllowedPrincipals) == 0 && principal == username {
+			return principal
+		}
+		if util.Contains(c.AllowedPrincipals, principal) {
+			return principal
+		}
+		for _, mapping := range c.PrincipalMappings {
+			if mapping.getUsername(principal) == username {
+				return principal
+			}
+		}
+	}
+	return ""
+}
+
+// CheckCertificate checks the restrictions to enforce for the specified certificate
+func (c *SSHCertificateConfig) CheckCertificate(cert *ssh.Certificate) error {
+	if c.RequireSourceAddress {
+		if _, ok := cert.CriticalOptions[sshCertSourceAddressOption]; !ok {
+			return fmt.Errorf("certificate without the %q critical option not allowed", sshCertSourceAddressOption)
+		}
+	}
+	if c.DenyPortForwarding {
+		if _, ok := cert.Extensions[sshCertPortForwardingExtension]; ok {
+			return fmt.Errorf("certificate with the %q extension not allowed", sshCertPortForwardingExtension)
+		}
+	}
+	return nil
+}
+
+func (c *SSHCertificateConfig) getACopy() SSHCertificateConfig {
+	caKeys := make([]string, len(c.TrustedCAKeys))
+	copy(caKeys, c.TrustedCAKeys)
+	principals := make([]string, len(c.AllowedPrincipals))
+	copy(principals, c.AllowedPrincipals)
+	mappings := make([]SSHCertificatePrincipalMapping, len(c.PrincipalMappings))
+	copy(mappings, c.PrincipalMappings)
+	return SSHCertificateConfig{
+		TrustedCAKeys:        caKeys,
+		AllowedPrincipals:    principals,
+		PrincipalMappings:    mappings,
+		DenyPortForwarding:   c.DenyPortForwarding,
+		RequireSourceAddress: c.RequireSourceAddress,
+	}
+}
+
+func (c *SSHCertificateConfig) validate() error {
+	var caKeys []string
+	for idx, k := range c.TrustedCAKeys {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		caKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k))
+		if err != nil {
+			return util.NewValidationError(fmt.Sprintf("could not parse trusted SSH CA key #%d: %v", idx+1, err))
+		}
+		if _, ok := caKey.(*ssh.Certificate); ok {
+			return util.NewValidationError(fmt.Sprintf("trusted SSH CA key #%d is a certificate, a public key is required", idx+1))
+		}
+		caKeys = append(caKeys, k)
+	}
+	c.TrustedCAKeys = util.RemoveDuplicates(caKeys, false)
+	var principals []string
+	for _, principal := range c.AllowedPrincipals {
+		principal = strings.TrimSpace(principal)
+		if principal != "" {
+			principals = append(principals, principal)
+		}
+	}
+	c.AllowedPrincipals = util.RemoveDuplicates(principals, false)
+	var mappings []SSHCertificatePrincipalMapping
+	for idx, mapping := range c.PrincipalMappings {
+		mapping.Principal = strings.TrimSpace(mapping.Principal)
+		mapping.Username = strings.TrimSpace(mapping.Username)
+		if mapping.Principal == "" && mapping.Username == "" {
+			continue
+		}
+		if mapping.Principal == "" || mapping.Username == "" {
+			return util.NewValidationError(fmt.Sprintf("principal mapping #%d: principal and username are mandatory", idx+1))
+		}
+		if _, err := regexp.Compile(mapping.getPrincipalExpression()); err != nil {
+			return util.NewValidationError(fmt.Sprintf("principal mapping #%d: invalid principal expression %q: %v",
+				idx+1, mapping.Principal, err))
+		}
+		mappings = append(mappings, mapping)
+	}
+	c.PrincipalMappings = mappings
+	return nil
+}
+
+// getPrincipalExpression returns the principal regular expression anchored to
+// match the whole principal
+func (m *SSHCertificatePrincipalMapping) getPrincipalExpression() string {
+	return "^(?:" + m.Principal + ")$"
+}
+
+// getUsername returns the username the specified principal is mapped to
+// or an empty string if the principal does not match this rule
+func (m *SSHCertificatePrincipalMapping) getUsername(principal string) string {
+	re, err := regexp.Compile(m.getPrincipalExpression())
+	if err != nil {
+		providerLog(logger.LevelError, "invalid SSH certificate principal expression %q: %v", m.Principal, err)
+		return ""
+	}
+	match := re.FindStringSubmatchIndex(principal)
+	if match == nil {
+		return ""
+	}
+	return string(re.ExpandString(nil, m.Username, principal, match))
+}
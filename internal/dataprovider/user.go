@@ -86,6 +86,7 @@ const (
 	LoginMethodTLSCertificate         = "TLSCertificate"
 	LoginMethodTLSCertificateAndPwd   = "TLSCertificate+password"
 	LoginMethodIDP                    = "IDP"
+	LoginMethodS3AccessKey            = "S3AccessKey"
 )
 
 var (
@@ -115,6 +116,141 @@ type UserTOTPConfig struct {
 	Protocols []string `json:"protocols,omitempty"`
 }
 
+// UserPushMFAConfig defines the push based multi-factor authentication configuration
+type UserPushMFAConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Username as known by the push provider, if empty the SFTPGo username is used
+	Username string `json:"username,omitempty"`
+	// Push authentication will be required for the specified protocols.
+	// SSH protocol requires keyboard interactive authentication, HTTP protocol
+	// applies to the WebClient login and to REST API tokens
+	Protocols []string `json:"protocols,omitempty"`
+}
+
+// IsEnabledForProtocol returns true if push authentication is required for the specified protocol
+func (c *UserPushMFAConfig) IsEnabledForProtocol(protocol string) bool {
+	return c.Enabled && mfa.IsPushEnabled() && util.Contains(c.Protocols, protocol)
+}
+
+// GetProviderUsername returns the username to use for the push provider
+func (c *UserPushMFAConfig) GetProviderUsername(username string) string {
+	if c.Username != "" {
+		return c.Username
+	}
+	return username
+}
+
+// SSHAlgorithms defines the SSH algorithms a user is allowed to connect with.
+// An empty list means that all the algorithms enabled in the SFTP service configuration are allowed
+type SSHAlgorithms struct {
+	// allowed KEX (Key Exchange) algorithms
+	KexAlgorithms []string `json:"kex_algorithms,omitempty"`
+	// allowed ciphers
+	Ciphers []string `json:"ciphers,omitempty"`
+	// allowed MAC (message authentication code) algorithms, they are not checked
+	// if an authenticated encryption cipher is negotiated
+	MACs []string `json:"macs,omitempty"`
+}
+
+// IsEmpty returns true if no restriction is defined
+func (a *SSHAlgorithms) IsEmpty() bool {
+	return len(a.KexAlgorithms) == 0 && len(a.Ciphers) == 0 && len(a.MACs) == 0
+}
+
+func (a *SSHAlgorithms) getACopy() SSHAlgorithms {
+	kexs := make([]string, len(a.KexAlgorithms))
+	copy(kexs, a.KexAlgorithms)
+	ciphers := make([]string, len(a.Ciphers))
+	copy(ciphers, a.Ciphers)
+	macs := make([]string, len(a.MACs))
+	copy(macs, a.MACs)
+	return SSHAlgorithms{
+		KexAlgorithms: kexs,
+		Ciphers:       ciphers,
+		MACs:          macs,
+	}
+}
+
+// FTPSettings defines FTP specific settings
+type FTPSettings struct {
+	// IPv4 address to advertise in the PASV responses. If set, it overrides
+	// the passive IP configured for the FTP bindings. The binding passive IP
+	// overrides matching the client IP take precedence
+	PassiveIP string `json:"passive_ip,omitempty"`
+}
+
+func (s *FTPSettings) validate() error {
+	if s.PassiveIP == "" {
+		return nil
+	}
+	ip := net.ParseIP(s.PassiveIP)
+	if ip == nil || ip.To4() == nil {
+		return util.NewValidationError(fmt.Sprintf("invalid passive IP %q, it must be a valid IPv4 address", s.PassiveIP))
+	}
+	s.PassiveIP = ip.To4().String()
+	return nil
+}
+
+// SourceAuthPolicy defines additional authentication requirements for the
+// connections from the specified sources
+type SourceAuthPolicy struct {
+	// IP/Mask in CIDR notation, for example "192.168.1.0/24" or "2001:db8::/32"
+	Sources []string `json:"sources"`
+	// Login methods denied for the connections from these sources.
+	// They are added to the login methods denied for the user
+	DeniedLoginMethods []string `json:"denied_login_methods,omitempty"`
+	// Protocols requiring two-factor authentication for the connections from these sources.
+	// They are added to the protocols requiring two-factor authentication for the user
+	TwoFactorAuthProtocols []string `json:"two_factor_protocols,omitempty"`
+}
+
+func (p *SourceAuthPolicy) matches(ip net.IP) bool {
+	for _, source := range p.Sources {
+		_, ipNet, err := net.ParseCIDR(source)
+		if err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *SourceAuthPolicy) getACopy() SourceAuthPolicy {
+	sources := make([]string, len(p.Sources))
+	copy(sources, p.Sources)
+	loginMethods := make([]string, len(p.DeniedLoginMethods))
+	copy(loginMethods, p.DeniedLoginMethods)
+	protocols := make([]string, len(p.TwoFactorAuthProtocols))
+	copy(protocols, p.TwoFactorAuthProtocols)
+	return SourceAuthPolicy{
+		Sources:                sources,
+		DeniedLoginMethods:     loginMethods,
+		TwoFactorAuthProtocols: protocols,
+	}
+}
+
+// UserArchive defines the archive created for an expired user.
+// It is set by the user archive event action and cannot be modified
+type UserArchive struct {
+	// Name of the virtual folder where the archive is stored
+	Folder string `json:"folder"`
+	// Archive path relative to the folder root
+	Path string `json:"path"`
+	// number of archived files
+	Files int `json:"files"`
+	// compressed archive size as bytes
+	Size int64 `json:"size"`
+	// archive time as unix timestamp in milliseconds
+	ArchivedAt int64 `json:"archived_at"`
+}
+
+// UserLDAPSync defines the LDAP details for a user imported using LDAP sync
+// or created by the LDAP authentication.
+// It is set by the LDAP synchronization/authentication and cannot be modified
+type UserLDAPSync struct {
+	// Distinguished name of the LDAP user
+	DN string `json:"dn"`
+}
+
 // UserFilters defines additional restrictions for a user
 // TODO: rename to UserOptions in v3
 type UserFilters struct {
@@ -127,6 +263,29 @@ type UserFilters struct {
 	// Each code can only be used once, you should use these codes to login and disable or
 	// reset 2FA for your account
 	RecoveryCodes []RecoveryCode `json:"recovery_codes,omitempty"`
+	// WebAuthn security keys and passkeys registered by the user.
+	// They can be used to login to the WebClient
+	WebAuthnCredentials WebAuthnCredentials `json:"webauthn_credentials,omitempty"`
+	// Push based multi-factor authentication
+	PushMFA UserPushMFAConfig `json:"push_mfa,omitempty"`
+	// SSH algorithms allowed for this user
+	SSHAlgorithms SSHAlgorithms `json:"ssh_algorithms,omitempty"`
+	// SSH user certificates settings
+	SSHCertificates SSHCertificateConfig `json:"ssh_certificates,omitempty"`
+	// Time windows during which the user is allowed to login
+	AccessSchedule AccessSchedule `json:"access_schedule,omitempty"`
+	// FTP specific settings
+	FTP FTPSettings `json:"ftp,omitempty"`
+	// Authentication requirements based on the connection source.
+	// The first policy matching the client IP is applied
+	SourceAuthPolicies []SourceAuthPolicy `json:"source_auth_policies,omitempty"`
+	// Archive created for the expired user, if any
+	Archive *UserArchive `json:"archive,omitempty"`
+	// LDAP details for users imported using LDAP sync
+	LDAPSync *UserLDAPSync `json:"ldap_sync,omitempty"`
+	// Credentials for the S3 compatible gateway, they can only be generated
+	// by the user
+	S3Credentials *UserS3Credentials `json:"s3_credentials,omitempty"`
 }
 
 // User defines a SFTPGo user
@@ -140,6 +299,8 @@ type User struct {
 	FsConfig vfs.Filesystem `json:"filesystem"`
 	// groups associated with this user
 	Groups []sdk.GroupMapping `json:"groups,omitempty"`
+	// Custom key/value attributes
+	Attributes map[string]string `json:"attributes,omitempty"`
 	// we store the filesystem here using the base path as key.
 	fsCache map[string]vfs.Fs `json:"-"`
 	// true if group settings are already applied for this user
@@ -153,7 +314,15 @@ func (u *User) GetFilesystem(connectionID string) (fs vfs.Fs, err error) {
 	return u.GetFilesystemForPath("/", connectionID)
 }
 
-func (u *User) getRootFs(connectionID string) (fs vfs.Fs, err error) {
+func (u *User) getRootFs(connectionID string) (vfs.Fs, error) {
+	fs, err := u.getBaseRootFs(connectionID)
+	if err != nil {
+		return fs, err
+	}
+	return vfs.NewCompressFs(fs, u.FsConfig.CompressConfig), nil
+}
+
+func (u *User) getBaseRootFs(connectionID string) (fs vfs.Fs, err error) {
 	switch u.FsConfig.Provider {
 	case sdk.S3FilesystemProvider:
 		return vfs.NewS3Fs(connectionID, u.GetHomeDir(), "", u.FsConfig.S3Config)
@@ -172,6 +341,13 @@ func (u *User) getRootFs(connectionID string) (fs vfs.Fs, err error) {
 		return vfs.NewSFTPFs(connectionID, "", u.GetHomeDir(), forbiddenSelfUsers, u.FsConfig.SFTPConfig)
 	case sdk.HTTPFilesystemProvider:
 		return vfs.NewHTTPFs(connectionID, u.GetHomeDir(), "", u.FsConfig.HTTPConfig)
+	case vfs.UnionFilesystemProvider:
+		forbiddenSelfUsers, err := u.getUnionForbiddenSFTPSelfUsers(u.FsConfig.UnionConfig)
+		if err != nil {
+			return nil, err
+		}
+		forbiddenSelfUsers = append(forbiddenSelfUsers, u.Username)
+		return vfs.NewUnionFs(connectionID, "", forbiddenSelfUsers, u.FsConfig.UnionConfig)
 	default:
 		return vfs.NewOsFs(connectionID, u.GetHomeDir(), "", &u.FsConfig.OSConfig), nil
 	}
@@ -342,6 +518,9 @@ func (u *User) CheckLoginConditions() error {
 		return fmt.Errorf("user %q is expired, expiration timestamp: %v current timestamp: %v", u.Username,
 			u.ExpirationDate, util.GetTimeAsMsSinceEpoch(time.Now()))
 	}
+	if !u.Filters.AccessSchedule.IsAccessAllowed(time.Now()) {
+		return fmt.Errorf("user %q is not allowed to login at this time", u.Username)
+	}
 	return nil
 }
 
@@ -357,6 +536,9 @@ func (u *User) hideConfidentialData() {
 			code.Secret.Hide()
 		}
 	}
+	if u.Filters.S3Credentials != nil && u.Filters.S3Credentials.Secret != nil {
+		u.Filters.S3Credentials.Secret.Hide()
+	}
 }
 
 // CheckMaxShareExpiration returns an error if the share expiration exceed the
@@ -550,6 +732,24 @@ func (u *User) getForbiddenSFTPSelfUsers(username string) ([]string, error) {
 	return nil, nil
 }
 
+// getUnionForbiddenSFTPSelfUsers returns the forbidden self users for the SFTP members
+// of the specified union filesystem
+func (u *User) getUnionForbiddenSFTPSelfUsers(config vfs.UnionFsConfig) ([]string, error) {
+	var forbiddens []string
+	for idx := range config.Members {
+		member := &config.Members[idx]
+		if member.FsConfig.Provider != sdk.SFTPFilesystemProvider {
+			continue
+		}
+		users, err := u.getForbiddenSFTPSelfUsers(member.FsConfig.SFTPConfig.Username)
+		if err != nil {
+			return nil, err
+		}
+		forbiddens = append(forbiddens, users...)
+	}
+	return forbiddens, nil
+}
+
 // GetFsConfigForPath returns the file system configuration for the specified virtual path
 func (u *User) GetFsConfigForPath(virtualPath string) vfs.Filesystem {
 	if virtualPath != "" && virtualPath != "/" && len(u.VirtualFolders) > 0 {
@@ -582,6 +782,13 @@ func (u *User) GetFilesystemForPath(virtualPath, connectionID string) (vfs.Fs, e
 				}
 				forbiddenSelfUsers = append(forbiddenSelfUsers, forbiddens...)
 			}
+			if folder.FsConfig.Provider == vfs.UnionFilesystemProvider {
+				forbiddens, err := u.getUnionForbiddenSFTPSelfUsers(folder.FsConfig.UnionConfig)
+				if err != nil {
+					return nil, err
+				}
+				forbiddenSelfUsers = append(forbiddenSelfUsers, forbiddens...)
+			}
 			fs, err := folder.GetFilesystem(connectionID, forbiddenSelfUsers)
 			if err == nil {
 				u.fsCache[folder.VirtualPath] = fs
@@ -620,6 +827,22 @@ func (u *User) GetVirtualFolderForPath(virtualPath string) (vfs.VirtualFolder, e
 	return folder, errNoMatchingVirtualFolder
 }
 
+// ReencryptFiles re-encrypts, using the current passphrase, the files inside the home
+// directory still encrypted with an old passphrase. The virtual folders are not included.
+// The specified callback is executed with the size of each re-encrypted file
+func (u *User) ReencryptFiles(onFile func(size int64)) error {
+	if u.FsConfig.Provider != sdk.CryptedFilesystemProvider {
+		return util.NewValidationError("the user has no local encrypted filesystem")
+	}
+	fs, err := vfs.NewCryptFs(xid.New().String(), u.GetHomeDir(), "", u.FsConfig.CryptConfig)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+
+	return fs.(*vfs.CryptFs).ReencryptFiles(u.GetHomeDir(), onFile)
+}
+
 // CheckMetadataConsistency checks the consistency between the metadata stored
 // in the configured metadata plugin and the filesystem
 func (u *User) CheckMetadataConsistency() error {
@@ -825,6 +1048,22 @@ func (u *User) HasPermissionsInside(virtualPath string) bool {
 	return false
 }
 
+// SetReadOnlyPermissions restricts the permissions for all the configured paths
+// to list and download, permissions not already granted are not added
+func (u *User) SetReadOnlyPermissions() {
+	permissions := make(map[string][]string)
+	for dir, perms := range u.Permissions {
+		readOnlyPerms := make([]string, 0, 2)
+		for _, p := range []string{PermListItems, PermDownload} {
+			if util.Contains(perms, PermAny) || util.Contains(perms, p) {
+				readOnlyPerms = append(readOnlyPerms, p)
+			}
+		}
+		permissions[dir] = readOnlyPerms
+	}
+	u.Permissions = permissions
+}
+
 // HasPerm returns true if the user has the given permission or any permission
 func (u *User) HasPerm(permission, path string) bool {
 	perms := u.GetPermissionsForPath(path)
@@ -1043,7 +1282,14 @@ func (u *User) CanManageMFA() bool {
 	if util.Contains(u.Filters.WebClient, sdk.WebClientMFADisabled) {
 		return false
 	}
-	return len(mfa.GetAvailableTOTPConfigs()) > 0
+	return len(mfa.GetAvailableTOTPConfigs()) > 0 || mfa.IsWebAuthnEnabled()
+}
+
+// HasWebAuthn returns true if the user can login to the WebClient using a registered
+// WebAuthn credential
+func (u *User) HasWebAuthn() bool {
+	return mfa.IsWebAuthnEnabled() && len(u.Filters.WebAuthnCredentials) > 0 &&
+		!util.Contains(u.Filters.WebClient, sdk.WebClientMFADisabled)
 }
 
 func (u *User) skipExternalAuth() bool {
@@ -1153,15 +1399,10 @@ func (u *User) MustChangePassword() bool {
 
 // MustSetSecondFactor returns true if the user must set a second factor authentication
 func (u *User) MustSetSecondFactor() bool {
-	if len(u.Filters.TwoFactorAuthProtocols) > 0 {
-		if !u.Filters.TOTPConfig.Enabled {
+	for _, p := range u.Filters.TwoFactorAuthProtocols {
+		if u.MustSetSecondFactorForProtocol(p) {
 			return true
 		}
-		for _, p := range u.Filters.TwoFactorAuthProtocols {
-			if !util.Contains(u.Filters.TOTPConfig.Protocols, p) {
-				return true
-			}
-		}
 	}
 	return false
 }
@@ -1170,6 +1411,9 @@ func (u *User) MustSetSecondFactor() bool {
 // for the specified protocol
 func (u *User) MustSetSecondFactorForProtocol(protocol string) bool {
 	if util.Contains(u.Filters.TwoFactorAuthProtocols, protocol) {
+		if u.Filters.PushMFA.IsEnabledForProtocol(protocol) {
+			return false
+		}
 		if !u.Filters.TOTPConfig.Enabled {
 			return true
 		}
@@ -1208,6 +1452,44 @@ func (u *User) GetBandwidthForIP(clientIP, connectionID string) (int64, int64) {
 	return u.UploadBandwidth, u.DownloadBandwidth
 }
 
+// ApplySourceAuthPolicy adds the login methods restrictions and the two-factor
+// authentication requirements defined for the specified remoteAddr to the user
+// filters. The first matching policy is applied, if any.
+// It must be called before checking the allowed login methods and the second factor
+func (u *User) ApplySourceAuthPolicy(remoteAddr string) {
+	if len(u.Filters.SourceAuthPolicies) == 0 {
+		return
+	}
+	remoteIP := net.ParseIP(util.GetIPFromRemoteAddress(remoteAddr))
+	if remoteIP == nil {
+		return
+	}
+	for idx := range u.Filters.SourceAuthPolicies {
+		policy := &u.Filters.SourceAuthPolicies[idx]
+		if !policy.matches(remoteIP) {
+			continue
+		}
+		// always allocate new slices, the user could be cached and shared
+		deniedLoginMethods := make([]string, 0, len(u.Filters.DeniedLoginMethods)+len(policy.DeniedLoginMethods))
+		deniedLoginMethods = append(deniedLoginMethods, u.Filters.DeniedLoginMethods...)
+		for _, method := range policy.DeniedLoginMethods {
+			if !util.Contains(deniedLoginMethods, method) {
+				deniedLoginMethods = append(deniedLoginMethods, method)
+			}
+		}
+		u.Filters.DeniedLoginMethods = deniedLoginMethods
+		protocols := make([]string, 0, len(u.Filters.TwoFactorAuthProtocols)+len(policy.TwoFactorAuthProtocols))
+		protocols = append(protocols, u.Filters.TwoFactorAuthProtocols...)
+		for _, protocol := range policy.TwoFactorAuthProtocols {
+			if !util.Contains(protocols, protocol) {
+				protocols = append(protocols, protocol)
+			}
+		}
+		u.Filters.TwoFactorAuthProtocols = protocols
+		return
+	}
+}
+
 // IsLoginFromAddrAllowed returns true if the login is allowed from the specified remoteAddr.
 // If AllowedIP is defined only the specified IP/Mask can login.
 // If DeniedIP is defined the specified IP/Mask cannot login.
@@ -1437,6 +1719,8 @@ func (u *User) GetStorageDescrition() string {
 		return fmt.Sprintf("SFTP: %v", u.FsConfig.SFTPConfig.Endpoint)
 	case sdk.HTTPFilesystemProvider:
 		return fmt.Sprintf("HTTP: %v", u.FsConfig.HTTPConfig.Endpoint)
+	case vfs.UnionFilesystemProvider:
+		return fmt.Sprintf("Union: %v members", len(u.FsConfig.UnionConfig.Members))
 	default:
 		return ""
 	}
@@ -1612,8 +1896,8 @@ func (u *User) applyGroupSettings(groupsMapping map[string]Group) {
 	replacer := u.getGroupPlacehodersReplacer()
 	for _, g := range u.Groups {
 		if g.Type == sdk.GroupTypePrimary {
-			if group, ok := groupsMapping[g.Name]; ok {
-				u.mergeWithPrimaryGroup(&group, replacer)
+			if _, ok := groupsMapping[g.Name]; ok {
+				u.mergeWithPrimaryGroupHierarchy(getGroupHierarchy(g.Name, groupsMapping), replacer)
 			} else {
 				providerLog(logger.LevelError, "mapping not found for user %s, group %s", u.Username, g.Name)
 			}
@@ -1622,8 +1906,10 @@ func (u *User) applyGroupSettings(groupsMapping map[string]Group) {
 	}
 	for _, g := range u.Groups {
 		if g.Type == sdk.GroupTypeSecondary {
-			if group, ok := groupsMapping[g.Name]; ok {
-				u.mergeAdditiveProperties(&group, sdk.GroupTypeSecondary, replacer)
+			if _, ok := groupsMapping[g.Name]; ok {
+				for _, group := range getGroupHierarchy(g.Name, groupsMapping) {
+					u.mergeAdditiveProperties(&group, sdk.GroupTypeSecondary, replacer)
+				}
 			} else {
 				providerLog(logger.LevelError, "mapping not found for user %s, group %s", u.Username, g.Name)
 			}
@@ -1632,6 +1918,25 @@ func (u *User) applyGroupSettings(groupsMapping map[string]Group) {
 	u.removeDuplicatesAfterGroupMerge()
 }
 
+// mergeWithPrimaryGroupHierarchy merges the primary group and the groups it
+// includes. The home directory and the filesystem defined in a group take
+// precedence over the ones defined in the included groups
+func (u *User) mergeWithPrimaryGroupHierarchy(groups []Group, replacer *strings.Replacer) {
+	var hasHomeDir, hasFsConfig bool
+	for idx := range groups {
+		group := groups[idx]
+		if hasHomeDir {
+			group.UserSettings.HomeDir = ""
+		}
+		if hasFsConfig {
+			group.UserSettings.FsConfig = vfs.Filesystem{}
+		}
+		hasHomeDir = hasHomeDir || group.UserSettings.HomeDir != ""
+		hasFsConfig = hasFsConfig || group.UserSettings.FsConfig.Provider != sdk.LocalFilesystemProvider
+		u.mergeWithPrimaryGroup(&group, replacer)
+	}
+}
+
 // LoadAndApplyGroupSettings update the user by loading and applying the group settings
 func (u *User) LoadAndApplyGroupSettings() error {
 	if !u.hasSettingsFromGroups() {
@@ -1641,11 +1946,7 @@ func (u *User) LoadAndApplyGroupSettings() error {
 		return nil
 	}
 	names := make([]string, 0, len(u.Groups))
-	var primaryGroupName string
 	for _, g := range u.Groups {
-		if g.Type == sdk.GroupTypePrimary {
-			primaryGroupName = g.Name
-		}
 		if g.Type != sdk.GroupTypeMembership {
 			names = append(names, g.Name)
 		}
@@ -1654,28 +1955,23 @@ func (u *User) LoadAndApplyGroupSettings() error {
 	if err != nil {
 		return fmt.Errorf("unable to get groups: %w", err)
 	}
-	replacer := u.getGroupPlacehodersReplacer()
-	// make sure to always merge with the primary group first
+	groupsMapping := make(map[string]Group)
 	for idx := range groups {
-		g := groups[idx]
-		if g.Name == primaryGroupName {
-			u.mergeWithPrimaryGroup(&g, replacer)
-			lastIdx := len(groups) - 1
-			groups[idx] = groups[lastIdx]
-			groups = groups[:lastIdx]
-			break
-		}
+		groupsMapping[groups[idx].Name] = groups[idx]
 	}
-	for idx := range groups {
-		g := groups[idx]
-		u.mergeAdditiveProperties(&g, sdk.GroupTypeSecondary, replacer)
+	if err := loadIncludedGroups(groupsMapping, provider.getGroupsWithNames); err != nil {
+		return fmt.Errorf("unable to get included groups: %w", err)
 	}
-	u.removeDuplicatesAfterGroupMerge()
+	u.applyGroupSettings(groupsMapping)
 	return nil
 }
 
 func (u *User) getGroupPlacehodersReplacer() *strings.Replacer {
-	return strings.NewReplacer("%username%", u.Username)
+	oldnew := []string{"%username%", u.Username}
+	for k, v := range u.Attributes {
+		oldnew = append(oldnew, "%attr:"+k+"%", v)
+	}
+	return strings.NewReplacer(oldnew...)
 }
 
 func (u *User) replacePlaceholder(value string, replacer *strings.Replacer) string {
@@ -1689,6 +1985,7 @@ func (u *User) replaceFsConfigPlaceholders(fsConfig vfs.Filesystem, replacer *st
 	switch fsConfig.Provider {
 	case sdk.S3FilesystemProvider:
 		fsConfig.S3Config.KeyPrefix = u.replacePlaceholder(fsConfig.S3Config.KeyPrefix, replacer)
+		fsConfig.S3Config.SSEKMSKeyID = u.replacePlaceholder(fsConfig.S3Config.SSEKMSKeyID, replacer)
 	case sdk.GCSFilesystemProvider:
 		fsConfig.GCSConfig.KeyPrefix = u.replacePlaceholder(fsConfig.GCSConfig.KeyPrefix, replacer)
 	case sdk.AzureBlobFilesystemProvider:
@@ -1698,6 +1995,16 @@ func (u *User) replaceFsConfigPlaceholders(fsConfig vfs.Filesystem, replacer *st
 		fsConfig.SFTPConfig.Prefix = u.replacePlaceholder(fsConfig.SFTPConfig.Prefix, replacer)
 	case sdk.HTTPFilesystemProvider:
 		fsConfig.HTTPConfig.Username = u.replacePlaceholder(fsConfig.HTTPConfig.Username, replacer)
+	case vfs.UnionFilesystemProvider:
+		// the members are shared with the group, so we have to work on a copy
+		members := make([]vfs.UnionFsMember, len(fsConfig.UnionConfig.Members))
+		copy(members, fsConfig.UnionConfig.Members)
+		fsConfig.UnionConfig.Members = members
+		for idx := range fsConfig.UnionConfig.Members {
+			member := &fsConfig.UnionConfig.Members[idx]
+			member.MappedPath = u.replacePlaceholder(member.MappedPath, replacer)
+			member.FsConfig = u.replaceFsConfigPlaceholders(member.FsConfig, replacer)
+		}
 	}
 	return fsConfig
 }
@@ -1752,6 +2059,17 @@ func (u *User) mergeWithPrimaryGroup(group *Group, replacer *strings.Replacer) {
 		u.ExpirationDate = u.CreatedAt + int64(group.UserSettings.ExpiresIn)*86400000
 	}
 	u.mergePrimaryGroupFilters(&group.UserSettings.Filters, replacer)
+	u.mergeSSHAlgorithms(&group.UserSettings.SSHAlgorithms)
+	u.mergeSSHCertificates(&group.UserSettings.SSHCertificates)
+	if u.Filters.AccessSchedule.IsEmpty() {
+		u.Filters.AccessSchedule = group.UserSettings.AccessSchedule
+	}
+	if len(u.Filters.SourceAuthPolicies) == 0 {
+		u.Filters.SourceAuthPolicies = group.UserSettings.SourceAuthPolicies
+	}
+	if u.Filters.FTP.PassiveIP == "" {
+		u.Filters.FTP.PassiveIP = group.UserSettings.FTP.PassiveIP
+	}
 	u.mergeAdditiveProperties(group, sdk.GroupTypePrimary, replacer)
 }
 
@@ -1803,6 +2121,36 @@ func (u *User) mergePrimaryGroupFilters(filters *sdk.BaseUserFilters, replacer *
 	}
 }
 
+func (u *User) mergeSSHAlgorithms(algorithms *SSHAlgorithms) {
+	if len(u.Filters.SSHAlgorithms.KexAlgorithms) == 0 {
+		u.Filters.SSHAlgorithms.KexAlgorithms = algorithms.KexAlgorithms
+	}
+	if len(u.Filters.SSHAlgorithms.Ciphers) == 0 {
+		u.Filters.SSHAlgorithms.Ciphers = algorithms.Ciphers
+	}
+	if len(u.Filters.SSHAlgorithms.MACs) == 0 {
+		u.Filters.SSHAlgorithms.MACs = algorithms.MACs
+	}
+}
+
+func (u *User) mergeSSHCertificates(config *SSHCertificateConfig) {
+	if len(u.Filters.SSHCertificates.TrustedCAKeys) == 0 {
+		u.Filters.SSHCertificates.TrustedCAKeys = config.TrustedCAKeys
+	}
+	if len(u.Filters.SSHCertificates.AllowedPrincipals) == 0 {
+		u.Filters.SSHCertificates.AllowedPrincipals = config.AllowedPrincipals
+	}
+	if len(u.Filters.SSHCertificates.PrincipalMappings) == 0 {
+		u.Filters.SSHCertificates.PrincipalMappings = config.PrincipalMappings
+	}
+	if config.DenyPortForwarding {
+		u.Filters.SSHCertificates.DenyPortForwarding = true
+	}
+	if config.RequireSourceAddress {
+		u.Filters.SSHCertificates.RequireSourceAddress = true
+	}
+}
+
 func (u *User) mergeAdditiveProperties(group *Group, groupType int, replacer *strings.Replacer) {
 	u.mergeVirtualFolders(group, groupType, replacer)
 	u.mergePermissions(group, groupType, replacer)
@@ -1913,15 +2261,44 @@ func (u *User) getACopy() User {
 		copy(perms, v)
 		permissions[k] = perms
 	}
+	var attributes map[string]string
+	if len(u.Attributes) > 0 {
+		attributes = make(map[string]string, len(u.Attributes))
+		for k, v := range u.Attributes {
+			attributes[k] = v
+		}
+	}
 	filters := UserFilters{
 		BaseUserFilters: copyBaseUserFilters(u.Filters.BaseUserFilters),
 	}
 	filters.RequirePasswordChange = u.Filters.RequirePasswordChange
+	filters.SSHAlgorithms = u.Filters.SSHAlgorithms.getACopy()
+	filters.SSHCertificates = u.Filters.SSHCertificates.getACopy()
+	filters.AccessSchedule = u.Filters.AccessSchedule.getACopy()
+	filters.FTP = u.Filters.FTP
+	filters.SourceAuthPolicies = make([]SourceAuthPolicy, 0, len(u.Filters.SourceAuthPolicies))
+	for idx := range u.Filters.SourceAuthPolicies {
+		filters.SourceAuthPolicies = append(filters.SourceAuthPolicies, u.Filters.SourceAuthPolicies[idx].getACopy())
+	}
+	if u.Filters.Archive != nil {
+		archive := *u.Filters.Archive
+		filters.Archive = &archive
+	}
+	if u.Filters.LDAPSync != nil {
+		ldapSync := *u.Filters.LDAPSync
+		filters.LDAPSync = &ldapSync
+	}
 	filters.TOTPConfig.Enabled = u.Filters.TOTPConfig.Enabled
 	filters.TOTPConfig.ConfigName = u.Filters.TOTPConfig.ConfigName
 	filters.TOTPConfig.Secret = u.Filters.TOTPConfig.Secret.Clone()
 	filters.TOTPConfig.Protocols = make([]string, len(u.Filters.TOTPConfig.Protocols))
 	copy(filters.TOTPConfig.Protocols, u.Filters.TOTPConfig.Protocols)
+	filters.PushMFA.Enabled = u.Filters.PushMFA.Enabled
+	filters.PushMFA.Username = u.Filters.PushMFA.Username
+	filters.PushMFA.Protocols = make([]string, len(u.Filters.PushMFA.Protocols))
+	copy(filters.PushMFA.Protocols, u.Filters.PushMFA.Protocols)
+	filters.WebAuthnCredentials = u.Filters.WebAuthnCredentials.getACopy()
+	filters.S3Credentials = u.Filters.S3Credentials.getACopy()
 	filters.RecoveryCodes = make([]RecoveryCode, 0, len(u.Filters.RecoveryCodes))
 	for _, code := range u.Filters.RecoveryCodes {
 		if code.Secret == nil {
@@ -1974,6 +2351,7 @@ func (u *User) getACopy() User {
 		VirtualFolders:       virtualFolders,
 		Groups:               groups,
 		FsConfig:             u.FsConfig.GetACopy(),
+		Attributes:           attributes,
 		groupSettingsApplied: u.groupSettingsApplied,
 	}
 }
@@ -0,0 +1,273 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/kms"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+// conditional blocks have the form %if:name%...%endif%, %if:name%...%else%...%endif%
+// or %if:name=value%...%endif%. Nested blocks are not supported
+var templateConditionalRegex = regexp.MustCompile(`(?s)%if:([a-zA-Z0-9_:.-]+)(=[^%]*)?%(.*?)(?:%else%(.*?))?%endif%`)
+
+// attributes not defined for a user are replaced with an empty string
+var templateAttributeRegex = regexp.MustCompile(`%attr:[a-zA-Z0-9_.-]+%`)
+
+// templateReplacer replaces the placeholders and the conditional blocks in template fields
+type templateReplacer struct {
+	values   map[string]string
+	replacer *strings.Replacer
+}
+
+func newTemplateReplacer(values map[string]string) *templateReplacer {
+	r := &templateReplacer{
+		values: values,
+	}
+	r.setReplacer()
+	return r
+}
+
+func (r *templateReplacer) set(name, value string) {
+	r.values[name] = value
+	r.setReplacer()
+}
+
+func (r *templateReplacer) setReplacer() {
+	oldnew := make([]string, 0, 2*len(r.values))
+	for k, v := range r.values {
+		oldnew = append(oldnew, "%"+k+"%", v)
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+}
+
+func (r *templateReplacer) replace(value string) string {
+	if !strings.Contains(value, "%") {
+		return value
+	}
+	value = templateConditionalRegex.ReplaceAllStringFunc(value, func(block string) string {
+		matches := templateConditionalRegex.FindStringSubmatch(block)
+		current := r.values[matches[1]]
+		var isTrue bool
+		if matches[2] != "" {
+			isTrue = current == matches[2][1:]
+		} else {
+			isTrue = current != ""
+		}
+		if isTrue {
+			return matches[3]
+		}
+		return matches[4]
+	})
+	return templateAttributeRegex.ReplaceAllString(r.replacer.Replace(value), "")
+}
+
+func (r *templateReplacer) replaceSecret(secret *kms.Secret) *kms.Secret {
+	if secret == nil || !secret.IsPlain() {
+		return secret
+	}
+	return kms.NewPlainSecret(r.replace(secret.GetPayload()))
+}
+
+func (r *templateReplacer) replaceFsConfig(fsConfig vfs.Filesystem) vfs.Filesystem {
+	switch fsConfig.Provider {
+	case sdk.CryptedFilesystemProvider:
+		fsConfig.CryptConfig.Passphrase = r.replaceSecret(fsConfig.CryptConfig.Passphrase)
+	case sdk.S3FilesystemProvider:
+		fsConfig.S3Config.KeyPrefix = r.replace(fsConfig.S3Config.KeyPrefix)
+		fsConfig.S3Config.AccessKey = r.replace(fsConfig.S3Config.AccessKey)
+		fsConfig.S3Config.SSEKMSKeyID = r.replace(fsConfig.S3Config.SSEKMSKeyID)
+		fsConfig.S3Config.AccessSecret = r.replaceSecret(fsConfig.S3Config.AccessSecret)
+	case sdk.GCSFilesystemProvider:
+		fsConfig.GCSConfig.KeyPrefix = r.replace(fsConfig.GCSConfig.KeyPrefix)
+	case sdk.AzureBlobFilesystemProvider:
+		fsConfig.AzBlobConfig.KeyPrefix = r.replace(fsConfig.AzBlobConfig.KeyPrefix)
+		fsConfig.AzBlobConfig.AccountName = r.replace(fsConfig.AzBlobConfig.AccountName)
+		fsConfig.AzBlobConfig.AccountKey = r.replaceSecret(fsConfig.AzBlobConfig.AccountKey)
+	case sdk.SFTPFilesystemProvider:
+		fsConfig.SFTPConfig.Prefix = r.replace(fsConfig.SFTPConfig.Prefix)
+		fsConfig.SFTPConfig.Username = r.replace(fsConfig.SFTPConfig.Username)
+		fsConfig.SFTPConfig.Password = r.replaceSecret(fsConfig.SFTPConfig.Password)
+	case sdk.HTTPFilesystemProvider:
+		fsConfig.HTTPConfig.Username = r.replace(fsConfig.HTTPConfig.Username)
+	}
+	return fsConfig
+}
+
+// UserTemplateFields defines the fields used to create a user from a template
+type UserTemplateFields struct {
+	Username   string   `json:"username"`
+	Password   string   `json:"password,omitempty"`
+	PublicKeys []string `json:"public_keys,omitempty"`
+	// Custom attributes, they can be used in templates as %attr:<name>% and
+	// take precedence over the attributes defined for the template user
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// ApplyTemplate sets the username, password and public keys from the given fields
+// and replaces the placeholders and the conditional blocks in the user fields.
+// The supported placeholders are %username%, %password%, %domain%, the part of the
+// username after the last "@", %group%, the primary group name, and %attr:<name>%,
+// the value of the specified user or template attribute
+func (u *User) ApplyTemplate(fields UserTemplateFields) {
+	u.Username = fields.Username
+	u.Password = fields.Password
+	u.PublicKeys = fields.PublicKeys
+
+	values := map[string]string{
+		"username": u.Username,
+		"domain":   "",
+		"group":    "",
+	}
+	if idx := strings.LastIndex(u.Username, "@"); idx >= 0 {
+		values["domain"] = u.Username[idx+1:]
+	}
+	for _, g := range u.Groups {
+		if g.Type == sdk.GroupTypePrimary {
+			values["group"] = g.Name
+		}
+	}
+	for k, v := range u.Attributes {
+		values["attr:"+k] = v
+	}
+	for k, v := range fields.Attributes {
+		values["attr:"+k] = v
+	}
+	r := newTemplateReplacer(values)
+	if u.Password != "" && !u.IsPasswordHashed() {
+		u.Password = r.replace(u.Password)
+		r.set("password", u.Password)
+	}
+
+	u.HomeDir = r.replace(u.HomeDir)
+	var vfolders []vfs.VirtualFolder
+	for _, vfolder := range u.VirtualFolders {
+		vfolder.Name = r.replace(vfolder.Name)
+		vfolder.VirtualPath = r.replace(vfolder.VirtualPath)
+		vfolders = append(vfolders, vfolder)
+	}
+	u.VirtualFolders = vfolders
+	u.Email = r.replace(u.Email)
+	u.Description = r.replace(u.Description)
+	u.AdditionalInfo = r.replace(u.AdditionalInfo)
+	u.Filters.StartDirectory = r.replace(u.Filters.StartDirectory)
+	u.FsConfig = r.replaceFsConfig(u.FsConfig)
+}
+
+// GetFolderFromTemplate returns a copy of the given folder with the specified
+// name and the %name% and %attr:<name>% placeholders and the conditional blocks replaced
+func GetFolderFromTemplate(folder vfs.BaseVirtualFolder, name string) vfs.BaseVirtualFolder {
+	folder.Name = name
+	values := map[string]string{
+		"name": folder.Name,
+	}
+	for k, v := range folder.Attributes {
+		values["attr:"+k] = v
+	}
+	r := newTemplateReplacer(values)
+	folder.MappedPath = r.replace(folder.MappedPath)
+	folder.Description = r.replace(folder.Description)
+	folder.FsConfig = r.replaceFsConfig(folder.FsConfig)
+	return folder
+}
+
+// UserTemplate defines a template to create users
+type UserTemplate struct {
+	// Name of an existing user to extend, optional.
+	// The fields defined in User override the inherited ones
+	Extends string `json:"extends,omitempty"`
+	// User fields as JSON, they can contain placeholders and conditional blocks
+	User json.RawMessage `json:"user,omitempty"`
+	// Users to create
+	Users []UserTemplateFields `json:"users"`
+}
+
+func (t *UserTemplate) getBaseUser(role string) (User, error) {
+	var user User
+	if t.Extends != "" {
+		base, err := UserExists(t.Extends, role)
+		if err != nil {
+			return user, fmt.Errorf("unable to get the user %q to extend: %w", t.Extends, err)
+		}
+		user = base.getACopy()
+		user.ID = 0
+		user.SetEmptySecrets()
+		user.PublicKeys = nil
+		user.Email = ""
+		user.Description = ""
+		user.UsedQuotaSize = 0
+		user.UsedQuotaFiles = 0
+		user.UsedUploadDataTransfer = 0
+		user.UsedDownloadDataTransfer = 0
+		user.LastQuotaUpdate = 0
+		user.LastLogin = 0
+		user.LastPasswordChange = 0
+		user.FirstDownload = 0
+		user.FirstUpload = 0
+		user.CreatedAt = 0
+		user.UpdatedAt = 0
+		user.Filters.TOTPConfig = UserTOTPConfig{}
+		user.Filters.RecoveryCodes = nil
+		user.Filters.Archive = nil
+		user.Filters.LDAPSync = nil
+	}
+	if len(t.User) > 0 {
+		if err := json.Unmarshal(t.User, &user); err != nil {
+			return user, util.NewValidationError(fmt.Sprintf("invalid user template: %v", err))
+		}
+	}
+	return user, nil
+}
+
+// GetUsers returns the validated users defined by this template.
+// The role, if not empty, is forced for the extended and the returned users
+func (t *UserTemplate) GetUsers(role string) ([]User, error) {
+	if len(t.Users) == 0 {
+		return nil, util.NewI18nError(
+			util.NewValidationError("no valid user defined, unable to complete the requested action"),
+			util.I18nErrorUserTemplate,
+		)
+	}
+	base, err := t.getBaseUser(role)
+	if err != nil {
+		return nil, err
+	}
+	if role != "" {
+		base.Role = role
+	}
+	usernames := make(map[string]bool)
+	users := make([]User, 0, len(t.Users))
+	for _, fields := range t.Users {
+		if usernames[fields.Username] {
+			return nil, util.NewValidationError(fmt.Sprintf("duplicated username %q", fields.Username))
+		}
+		usernames[fields.Username] = true
+		user := base.getACopy()
+		user.ApplyTemplate(fields)
+		if err := ValidateUser(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
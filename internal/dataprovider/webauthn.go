@@ -0,0 +1,112 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	// MaxWebAuthnCredentials defines the maximum number of WebAuthn credentials
+	// that can be registered for a user or an admin
+	MaxWebAuthnCredentials = 20
+	// WebAuthn user handles cannot exceed 64 bytes
+	maxWebAuthnUserHandleLen = 64
+)
+
+// WebAuthnCredential defines a WebAuthn security key or passkey
+type WebAuthnCredential struct {
+	// Credential ID, base64url encoded
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// COSE encoded public key
+	PublicKey []byte `json:"public_key"`
+	SignCount uint32 `json:"sign_count,omitempty"`
+	// Discoverable credentials (resident keys) can be used for passwordless,
+	// usernameless login
+	Discoverable bool `json:"discoverable,omitempty"`
+	// Creation time as unix timestamp in milliseconds
+	CreatedAt int64 `json:"created_at"`
+}
+
+// WebAuthnCredentials defines the WebAuthn credentials registered for an account
+type WebAuthnCredentials []WebAuthnCredential
+
+// Find returns the index of the credential with the specified ID, -1 if not found
+func (c WebAuthnCredentials) Find(id string) int {
+	for idx := range c {
+		if c[idx].ID == id {
+			return idx
+		}
+	}
+	return -1
+}
+
+// GetIDs returns the IDs of the credentials
+func (c WebAuthnCredentials) GetIDs() []string {
+	ids := make([]string, 0, len(c))
+	for _, cred := range c {
+		ids = append(ids, cred.ID)
+	}
+	return ids
+}
+
+func (c WebAuthnCredentials) validate() error {
+	if len(c) > MaxWebAuthnCredentials {
+		return util.NewValidationError(fmt.Sprintf("webauthn: too many credentials, max allowed: %d",
+			MaxWebAuthnCredentials))
+	}
+	ids := make(map[string]bool)
+	for idx := range c {
+		cred := &c[idx]
+		cred.Name = strings.TrimSpace(cred.Name)
+		if cred.ID == "" {
+			return util.NewValidationError("webauthn: credential ID is mandatory")
+		}
+		if ids[cred.ID] {
+			return util.NewValidationError(fmt.Sprintf("webauthn: duplicate credential ID %q", cred.ID))
+		}
+		ids[cred.ID] = true
+		if cred.Name == "" {
+			return util.NewValidationError("webauthn: credential name is mandatory")
+		}
+		if len(cred.PublicKey) == 0 {
+			return util.NewValidationError(fmt.Sprintf("webauthn: public key is mandatory for credential %q", cred.Name))
+		}
+	}
+	return nil
+}
+
+func (c WebAuthnCredentials) getACopy() WebAuthnCredentials {
+	if c == nil {
+		return nil
+	}
+	result := make(WebAuthnCredentials, 0, len(c))
+	for _, cred := range c {
+		pubKey := make([]byte, len(cred.PublicKey))
+		copy(pubKey, cred.PublicKey)
+		cred.PublicKey = pubKey
+		result = append(result, cred)
+	}
+	return result
+}
+
+// CanUseWebAuthnUserHandle returns true if the username can be used as WebAuthn user handle
+func CanUseWebAuthnUserHandle(username string) bool {
+	return username != "" && len(username) <= maxWebAuthnUserHandleLen
+}
@@ -0,0 +1,351 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package dataprovider
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/httpclient"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// Supported webhook delivery statuses
+const (
+	WebhookDeliveryStatusPending = iota + 1
+	WebhookDeliveryStatusDelivered
+	WebhookDeliveryStatusDead
+)
+
+const (
+	// max number of attempts stored for each delivery, older ones are discarded
+	maxWebhookDeliveryLogEntries = 20
+	// max number of due deliveries processed for each scheduler run
+	webhookDeliveriesBatchSize = 100
+	// a delivery is locked for this interval while it is being attempted, this
+	// way other nodes sharing the same data provider will not pick it up
+	webhookDeliveryLockInterval = 5 * time.Minute
+	maxWebhookErrorLength       = 255
+)
+
+// HTTP headers added to the webhook deliveries
+const (
+	WebhookHeaderDelivery  = "X-SFTPGo-Delivery"
+	WebhookHeaderEvent     = "X-SFTPGo-Event"
+	WebhookHeaderTimestamp = "X-SFTPGo-Timestamp"
+	WebhookHeaderSignature = "X-SFTPGo-Signature"
+)
+
+var (
+	webhooksProcessing atomic.Bool
+)
+
+// WebhooksConfig defines the configuration for the durable delivery of the
+// HTTP notifications
+type WebhooksConfig struct {
+	// Set to true to store the HTTP notifications in the data provider and
+	// deliver them in background retrying on failures.
+	// Only SQL based data providers are supported
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// If set, each delivery includes an HMAC-SHA256 signature of the timestamp
+	// and the payload computed using this secret
+	SigningSecret string `json:"signing_secret" mapstructure:"signing_secret"`
+	// Deliveries failing after this number of attempts are marked as dead
+	MaxAttempts int `json:"max_attempts" mapstructure:"max_attempts"`
+	// Interval in seconds before the first retry, it doubles after each
+	// failed attempt
+	RetryInterval int `json:"retry_interval" mapstructure:"retry_interval"`
+	// Max interval in seconds between two retries
+	MaxRetryInterval int `json:"max_retry_interval" mapstructure:"max_retry_interval"`
+	// Delivered and dead deliveries not updated within the specified number
+	// of days are automatically removed. 0 means no automatic removal
+	RetentionDays int `json:"retention_days" mapstructure:"retention_days"`
+}
+
+func (c *WebhooksConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MaxAttempts < 1 {
+		return fmt.Errorf("invalid webhooks max attempts: %d", c.MaxAttempts)
+	}
+	if c.RetryInterval < 1 {
+		return fmt.Errorf("invalid webhooks retry interval: %d", c.RetryInterval)
+	}
+	if c.MaxRetryInterval < c.RetryInterval {
+		return fmt.Errorf("invalid webhooks max retry interval: %d, it must be greater than or equal to the retry interval",
+			c.MaxRetryInterval)
+	}
+	if c.RetentionDays < 0 {
+		return fmt.Errorf("invalid webhooks retention days: %d", c.RetentionDays)
+	}
+	if !config.IsWebhooksSupported() {
+		return fmt.Errorf("webhooks are not supported with data provider %q", config.Driver)
+	}
+	return nil
+}
+
+// getRetryDelay returns the delay before the next attempt after the
+// specified number of failed attempts
+func (c *WebhooksConfig) getRetryDelay(attempts int) time.Duration {
+	delay := time.Duration(c.RetryInterval) * time.Second
+	maxDelay := time.Duration(c.MaxRetryInterval) * time.Second
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= maxDelay {
+			return maxDelay
+		}
+	}
+	return delay
+}
+
+// WebhookDeliveryAttempt defines a delivery attempt
+type WebhookDeliveryAttempt struct {
+	// Unix timestamp in milliseconds
+	Timestamp int64 `json:"timestamp"`
+	// HTTP status code returned from the receiver, 0 if no response was received
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Elapsed time in milliseconds
+	Elapsed int64 `json:"elapsed"`
+}
+
+// WebhookDelivery defines a queued HTTP notification
+type WebhookDelivery struct {
+	ID      string `json:"id"`
+	URL     string `json:"url"`
+	Event   string `json:"event"`
+	Payload string `json:"payload,omitempty"`
+	// 1 pending, 2 delivered, 3 dead
+	Status   int `json:"status"`
+	Attempts int `json:"attempts"`
+	// Unix timestamps in milliseconds
+	NextAttemptAt int64                    `json:"next_attempt_at"`
+	CreatedAt     int64                    `json:"created_at"`
+	UpdatedAt     int64                    `json:"updated_at"`
+	Log           []WebhookDeliveryAttempt `json:"log,omitempty"`
+}
+
+// PrepareForRendering prepares a webhook delivery for rendering.
+// The URL is redacted, it could contain credentials
+func (d *WebhookDelivery) PrepareForRendering() {
+	if u, err := url.Parse(d.URL); err == nil {
+		d.URL = u.Redacted()
+	}
+}
+
+func (d *WebhookDelivery) addAttempt(attempt WebhookDeliveryAttempt) {
+	d.Attempts++
+	d.Log = append(d.Log, attempt)
+	if len(d.Log) > maxWebhookDeliveryLogEntries {
+		d.Log = d.Log[len(d.Log)-maxWebhookDeliveryLogEntries:]
+	}
+}
+
+func (d *WebhookDelivery) send() WebhookDeliveryAttempt {
+	startTime := time.Now()
+	attempt := WebhookDeliveryAttempt{
+		Timestamp: util.GetTimeAsMsSinceEpoch(startTime),
+	}
+	timestamp := strconv.FormatInt(startTime.Unix(), 10)
+	headers := map[string]string{
+		WebhookHeaderDelivery:  d.ID,
+		WebhookHeaderEvent:     d.Event,
+		WebhookHeaderTimestamp: timestamp,
+	}
+	if config.Webhooks.SigningSecret != "" {
+		headers[WebhookHeaderSignature] = GetWebhookSignature(config.Webhooks.SigningSecret, timestamp, []byte(d.Payload))
+	}
+	resp, err := httpclient.PostWithHeaders(d.URL, "application/json", bytes.NewBufferString(d.Payload), headers)
+	if err == nil {
+		attempt.StatusCode = resp.StatusCode
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		}
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+		if len(attempt.Error) > maxWebhookErrorLength {
+			attempt.Error = attempt.Error[:maxWebhookErrorLength-3] + "..."
+		}
+	}
+	attempt.Elapsed = time.Since(startTime).Milliseconds()
+	return attempt
+}
+
+// WebhookDeliverySearch defines the filters to search the webhook deliveries
+type WebhookDeliverySearch struct {
+	// 0 means any status
+	Status int
+	Limit  int
+	Offset int
+	Order  string
+}
+
+// GetWebhookSignature returns the signature for a webhook delivery with the
+// specified timestamp and payload
+func GetWebhookSignature(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsWebhooksEnabled returns true if the HTTP notifications are queued and
+// delivered with retries
+func IsWebhooksEnabled() bool {
+	return config.Webhooks.Enabled
+}
+
+// EnqueueWebhookDelivery adds an HTTP notification to the delivery queue and
+// makes the first attempt
+func EnqueueWebhookDelivery(notificationURL, event string, payload []byte) error {
+	if !config.Webhooks.Enabled {
+		return util.NewMethodDisabledError("webhooks are disabled")
+	}
+	now := util.GetTimeAsMsSinceEpoch(time.Now())
+	delivery := WebhookDelivery{
+		ID:            util.GenerateUniqueID(),
+		URL:           notificationURL,
+		Event:         event,
+		Payload:       string(payload),
+		Status:        WebhookDeliveryStatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := provider.addWebhookDelivery(&delivery); err != nil {
+		providerLog(logger.LevelError, "unable to queue webhook delivery for event %q: %v", event, err)
+		return err
+	}
+	deliverWebhook(&delivery)
+	return nil
+}
+
+// GetWebhookDelivery returns the webhook delivery with the specified ID
+func GetWebhookDelivery(id string) (WebhookDelivery, error) {
+	if !config.Webhooks.Enabled {
+		return WebhookDelivery{}, util.NewMethodDisabledError("webhooks are disabled")
+	}
+	return provider.getWebhookDelivery(id)
+}
+
+// SearchWebhookDeliveries returns the webhook deliveries matching the specified filters
+func SearchWebhookDeliveries(filters *WebhookDeliverySearch) ([]WebhookDelivery, error) {
+	if !config.Webhooks.Enabled {
+		return nil, util.NewMethodDisabledError("webhooks are disabled")
+	}
+	return provider.searchWebhookDeliveries(filters)
+}
+
+// RetryWebhookDelivery schedules a new delivery for the specified webhook.
+// The attempts counter is reset, the attempts log is preserved
+func RetryWebhookDelivery(id string) error {
+	if !config.Webhooks.Enabled {
+		return util.NewMethodDisabledError("webhooks are disabled")
+	}
+	delivery, err := provider.getWebhookDelivery(id)
+	if err != nil {
+		return err
+	}
+	if delivery.Status == WebhookDeliveryStatusPending {
+		return util.NewValidationError("the delivery is already pending")
+	}
+	now := util.GetTimeAsMsSinceEpoch(time.Now())
+	delivery.Status = WebhookDeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	if err := provider.updateWebhookDelivery(&delivery); err != nil {
+		return err
+	}
+	go deliverWebhook(&delivery)
+	return nil
+}
+
+func deliverWebhook(delivery *WebhookDelivery) {
+	now := time.Now()
+	lockUntil := util.GetTimeAsMsSinceEpoch(now.Add(webhookDeliveryLockInterval))
+	locked, err := provider.lockWebhookDelivery(delivery.ID, delivery.NextAttemptAt, lockUntil)
+	if err != nil {
+		providerLog(logger.LevelError, "unable to lock webhook delivery %q: %v", delivery.ID, err)
+		return
+	}
+	if !locked {
+		providerLog(logger.LevelDebug, "webhook delivery %q already locked", delivery.ID)
+		return
+	}
+	attempt := delivery.send()
+	delivery.addAttempt(attempt)
+	switch {
+	case attempt.Error == "":
+		delivery.Status = WebhookDeliveryStatusDelivered
+	case delivery.Attempts >= config.Webhooks.MaxAttempts:
+		delivery.Status = WebhookDeliveryStatusDead
+	default:
+		delivery.NextAttemptAt = util.GetTimeAsMsSinceEpoch(time.Now().Add(
+			config.Webhooks.getRetryDelay(delivery.Attempts)))
+	}
+	delivery.UpdatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	if err := provider.updateWebhookDelivery(delivery); err != nil {
+		providerLog(logger.LevelError, "unable to update webhook delivery %q: %v", delivery.ID, err)
+	}
+	u, _ := url.Parse(delivery.URL)
+	redactedURL := ""
+	if u != nil {
+		redactedURL = u.Redacted()
+	}
+	providerLog(logger.LevelDebug, "webhook delivery %q for event %q to URL %s, attempt %d, status code: %d, "+
+		"elapsed: %d ms, error: %q, status: %d", delivery.ID, delivery.Event, redactedURL, delivery.Attempts,
+		attempt.StatusCode, attempt.Elapsed, attempt.Error, delivery.Status)
+}
+
+func processWebhookDeliveries() {
+	if !webhooksProcessing.CompareAndSwap(false, true) {
+		providerLog(logger.LevelDebug, "webhook deliveries are already being processed")
+		return
+	}
+	defer webhooksProcessing.Store(false)
+
+	deliveries, err := provider.getDueWebhookDeliveries(util.GetTimeAsMsSinceEpoch(time.Now()),
+		webhookDeliveriesBatchSize)
+	if err != nil {
+		providerLog(logger.LevelError, "unable to get due webhook deliveries: %v", err)
+		return
+	}
+	for idx := range deliveries {
+		deliverWebhook(&deliveries[idx])
+	}
+}
+
+func cleanupWebhookDeliveries() {
+	before := time.Now().Add(-time.Duration(config.Webhooks.RetentionDays) * 24 * time.Hour)
+	err := provider.cleanupWebhookDeliveries(util.GetTimeAsMsSinceEpoch(before))
+	if err != nil {
+		providerLog(logger.LevelError, "unable to cleanup webhook deliveries: %v", err)
+	} else {
+		providerLog(logger.LevelDebug, "cleanup webhook deliveries older than %s ok", before)
+	}
+}
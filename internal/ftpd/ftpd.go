@@ -110,8 +110,11 @@ type Binding struct {
 	// on active data connections, so change the default value only if you are on a trusted/internal network
 	ActiveConnectionsSecurity int `json:"active_connections_security" mapstructure:"active_connections_security"`
 	// Debug enables the FTP debug mode. In debug mode, every FTP command will be logged
-	Debug   bool `json:"debug" mapstructure:"debug"`
-	ciphers []uint16
+	Debug bool `json:"debug" mapstructure:"debug"`
+	// If enabled the permissions for all the users connected to this binding
+	// are restricted to list and download
+	ReadOnly bool `json:"read_only" mapstructure:"read_only"`
+	ciphers  []uint16
 }
 
 func (b *Binding) setCiphers() {
@@ -201,7 +204,9 @@ func (b *Binding) getPassiveIP(cc ftpserver.ClientContext) (string, error) {
 	return strings.Split(cc.LocalAddr().String(), ":")[0], nil
 }
 
-func (b *Binding) passiveIPResolver(cc ftpserver.ClientContext) (string, error) {
+// getPassiveIPOverride returns the passive IP defined by the first override
+// matching the client IP, if any
+func (b *Binding) getPassiveIPOverride(cc ftpserver.ClientContext) (string, bool) {
 	if len(b.PassiveIPOverrides) > 0 {
 		clientIP := net.ParseIP(util.GetIPFromRemoteAddress(cc.RemoteAddr().String()))
 		if clientIP != nil {
@@ -209,14 +214,21 @@ func (b *Binding) passiveIPResolver(cc ftpserver.ClientContext) (string, error)
 				for _, fn := range override.parsedNetworks {
 					if fn(clientIP) {
 						if override.IP == "" {
-							return strings.Split(cc.LocalAddr().String(), ":")[0], nil
+							return strings.Split(cc.LocalAddr().String(), ":")[0], true
 						}
-						return override.IP, nil
+						return override.IP, true
 					}
 				}
 			}
 		}
 	}
+	return "", false
+}
+
+func (b *Binding) passiveIPResolver(cc ftpserver.ClientContext) (string, error) {
+	if ip, ok := b.getPassiveIPOverride(cc); ok {
+		return ip, nil
+	}
 	return b.getPassiveIP(cc)
 }
 
@@ -278,6 +290,8 @@ type Configuration struct {
 	// CARevocationLists defines a set a revocation lists, one for each root CA, to be used to check
 	// if a client certificate has been revoked
 	CARevocationLists []string `json:"ca_revocation_lists" mapstructure:"ca_revocation_lists"`
+	// Additional revocation checks for client certificates
+	RevocationCheck common.RevocationCheckConfig `json:"revocation_check" mapstructure:"revocation_check"`
 	// Do not impose the port 20 for active data transfer. Enabling this option allows to run SFTPGo with less privilege
 	ActiveTransfersPortNon20 bool `json:"active_transfers_port_non_20" mapstructure:"active_transfers_port_non_20"`
 	// Set to true to disable active FTP
@@ -393,6 +407,10 @@ func (c *Configuration) Initialize(configDir string) error {
 		if err := mgr.LoadCRLs(); err != nil {
 			return err
 		}
+		if err := c.RevocationCheck.Validate(); err != nil {
+			return err
+		}
+		mgr.SetRevocationCheck(c.RevocationCheck)
 		certMgr = mgr
 	}
 	serviceStatus = ServiceStatus{
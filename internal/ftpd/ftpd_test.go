@@ -16,6 +16,7 @@ package ftpd_test
 
 import (
 	"crypto/rand"
+	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/tls"
 	"encoding/hex"
@@ -3308,6 +3309,23 @@ func TestHASH(t *testing.T) {
 			assert.Equal(t, ftp.StatusFile, code)
 			assert.Contains(t, response, hash)
 
+			h = sha1.New()
+			f, err = os.Open(testFilePath)
+			assert.NoError(t, err)
+			_, err = io.Copy(h, f)
+			assert.NoError(t, err)
+			hash = hex.EncodeToString(h.Sum(nil))
+			err = f.Close()
+			assert.NoError(t, err)
+
+			code, _, err = client.SendCustomCommand("OPTS HASH SHA-1")
+			assert.NoError(t, err)
+			assert.Equal(t, ftp.StatusCommandOK, code)
+			code, response, err = client.SendCustomCommand(fmt.Sprintf("HASH %v", testFileName))
+			assert.NoError(t, err)
+			assert.Equal(t, ftp.StatusFile, code)
+			assert.Contains(t, response, hash)
+
 			err = client.Quit()
 			assert.NoError(t, err)
 
@@ -3339,8 +3357,42 @@ func TestHASH(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHASHPermissions(t *testing.T) {
+	u := getTestUser()
+	u.Permissions["/"] = []string{dataprovider.PermUpload, dataprovider.PermDownload, dataprovider.PermOverwrite}
+	u.Permissions["/sub"] = []string{dataprovider.PermAny}
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	err = createTestFile(filepath.Join(user.GetHomeDir(), testFileName), 65535)
+	assert.NoError(t, err)
+	err = createTestFile(filepath.Join(user.GetHomeDir(), "sub", testFileName), 65535)
+	assert.NoError(t, err)
+	client, err := getFTPClientImplicitTLS(user)
+	if assert.NoError(t, err) {
+		// the list permission is required to compute the hash, as for the SSH hash commands
+		code, response, err := client.SendCustomCommand(fmt.Sprintf("HASH %v", testFileName))
+		assert.NoError(t, err)
+		assert.Equal(t, ftp.StatusFileUnavailable, code)
+		assert.Contains(t, response, common.ErrPermissionDenied.Error())
+		code, _, err = client.SendCustomCommand(fmt.Sprintf("XSHA256 %v", testFileName))
+		assert.NoError(t, err)
+		assert.Equal(t, ftp.StatusFileUnavailable, code)
+		code, _, err = client.SendCustomCommand(fmt.Sprintf("HASH %v", path.Join("sub", testFileName)))
+		assert.NoError(t, err)
+		assert.Equal(t, ftp.StatusFile, code)
+
+		err = client.Quit()
+		assert.NoError(t, err)
+	}
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestCombine(t *testing.T) {
 	u := getTestUser()
+	u.QuotaSize = 6553600
 	localUser, _, err := httpdtest.AddUser(u, http.StatusCreated)
 	assert.NoError(t, err)
 	sftpUser, _, err := httpdtest.AddUser(getTestSFTPUser(), http.StatusCreated)
@@ -3364,6 +3416,17 @@ func TestCombine(t *testing.T) {
 			if user.Username == defaultUsername {
 				assert.Equal(t, ftp.StatusRequestedFileActionOK, code)
 				assert.Equal(t, "COMB succeeded!", response)
+				info, err := os.Stat(filepath.Join(user.GetHomeDir(), testFileName))
+				if assert.NoError(t, err) {
+					assert.Equal(t, 2*testFileSize, info.Size())
+				}
+				_, err = os.Stat(filepath.Join(user.GetHomeDir(), testFileName+".1"))
+				assert.ErrorIs(t, err, fs.ErrNotExist)
+				// the parts are removed and the quota is updated
+				user, _, err := httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+				assert.NoError(t, err)
+				assert.Equal(t, 1, user.UsedQuotaFiles)
+				assert.Equal(t, 2*testFileSize, user.UsedQuotaSize)
 			} else {
 				assert.Equal(t, ftp.StatusFileUnavailable, code)
 				assert.Contains(t, response, "COMB is not supported for this filesystem")
@@ -15,8 +15,14 @@
 package ftpd
 
 import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
 	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
 	"path"
@@ -290,6 +296,33 @@ func (c *Connection) Symlink(oldname, newname string) error {
 	return c.BaseConnection.CreateSymlink(oldname, newname)
 }
 
+// ComputeHash implements ClientDriverExtensionHasher, it is used for the HASH
+// and the other hash commands if hash support is enabled
+func (c *Connection) ComputeHash(name string, algo ftpserver.HASHAlgo, startOffset, endOffset int64) (string, error) {
+	c.UpdateLastActivity()
+
+	var h hash.Hash
+	switch algo {
+	case ftpserver.HASHAlgoCRC32:
+		h = crc32.NewIEEE()
+	case ftpserver.HASHAlgoMD5:
+		h = md5.New()
+	case ftpserver.HASHAlgoSHA1:
+		h = sha1.New()
+	case ftpserver.HASHAlgoSHA256:
+		h = sha256.New()
+	case ftpserver.HASHAlgoSHA512:
+		h = sha512.New()
+	default:
+		return "", c.GetOpUnsupportedError()
+	}
+	var length int64
+	if endOffset > startOffset {
+		length = endOffset - startOffset
+	}
+	return c.GetFileHash(name, h, startOffset, length)
+}
+
 // ReadDir implements ClientDriverExtensionFilelist
 func (c *Connection) ReadDir(name string) ([]os.FileInfo, error) {
 	c.UpdateLastActivity()
@@ -350,6 +383,7 @@ func (c *Connection) downloadFile(fs vfs.Fs, fsPath, ftpPath string, offset int6
 	file, r, cancelFn, err := fs.Open(fsPath, offset)
 	if err != nil {
 		c.Log(logger.LevelError, "could not open file %q for reading: %+v", fsPath, err)
+		c.TrackArchiveRestore(fsPath, ftpPath, err)
 		return nil, c.GetFsError(fs, err)
 	}
 
@@ -483,7 +517,7 @@ func (c *Connection) handleFTPUploadToExistingFile(fs vfs.Fs, flags int, resolve
 		if vfs.HasTruncateSupport(fs) {
 			vfolder, err := c.User.GetVirtualFolderForPath(path.Dir(requestPath))
 			if err == nil {
-				dataprovider.UpdateVirtualFolderQuota(&vfolder.BaseVirtualFolder, 0, -fileSize, false) //nolint:errcheck
+				dataprovider.UpdateVirtualFolderQuota(&vfolder, 0, -fileSize, false) //nolint:errcheck
 				if vfolder.IsIncludedInUserQuota() {
 					dataprovider.UpdateUserQuota(&c.User, 0, -fileSize, false) //nolint:errcheck
 				}
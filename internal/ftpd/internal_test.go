@@ -752,6 +752,10 @@ func TestResolvePathErrors(t *testing.T) {
 	if assert.Error(t, err) {
 		assert.EqualError(t, err, common.ErrGenericFailure.Error())
 	}
+	_, err = connection.ComputeHash("", ftpserver.HASHAlgoSHA256, 0, 0)
+	if assert.Error(t, err) {
+		assert.EqualError(t, err, common.ErrGenericFailure.Error())
+	}
 	_, err = connection.GetAvailableSpace("")
 	if assert.Error(t, err) {
 		assert.EqualError(t, err, common.ErrGenericFailure.Error())
@@ -1088,6 +1092,25 @@ func TestPassiveIPResolver(t *testing.T) {
 	passiveIP, err = b.passiveIPResolver(mockCC)
 	assert.NoError(t, err)
 	assert.Equal(t, b.ForcePassiveIP, passiveIP)
+
+	server := NewServer(&Configuration{}, configDir, b, 0)
+	passiveIP, err = server.passiveIPResolver(mockCC)
+	assert.NoError(t, err)
+	assert.Equal(t, b.ForcePassiveIP, passiveIP)
+	server.passiveIPs.Store(mockCC.ID(), "10.1.1.1")
+	passiveIP, err = server.passiveIPResolver(mockCC)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.1.1.1", passiveIP)
+	// the binding overrides take precedence over the user passive IP
+	mockCC.remoteIP = "192.168.1.10"
+	passiveIP, err = server.passiveIPResolver(mockCC)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.168.1.3", passiveIP)
+	mockCC.remoteIP = "172.16.2.3"
+	server.ClientDisconnected(mockCC)
+	passiveIP, err = server.passiveIPResolver(mockCC)
+	assert.NoError(t, err)
+	assert.Equal(t, b.ForcePassiveIP, passiveIP)
 }
 
 func TestRelativePath(t *testing.T) {
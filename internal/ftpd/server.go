@@ -22,6 +22,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 
 	ftpserver "github.com/fclairamb/ftpserverlib"
 	"github.com/sftpgo/sdk/plugin/notifier"
@@ -43,6 +44,8 @@ type Server struct {
 	statusBanner string
 	binding      Binding
 	tlsConfig    *tls.Config
+	// passive IPs configured for the authenticated users, keyed by client ID
+	passiveIPs sync.Map
 }
 
 // NewServer returns a new FTP server driver
@@ -119,7 +122,7 @@ func (s *Server) GetSettings() (*ftpserver.Settings, error) {
 	return &ftpserver.Settings{
 		Listener:                 ftpListener,
 		ListenAddr:               s.binding.GetAddress(),
-		PublicIPResolver:         s.binding.passiveIPResolver,
+		PublicIPResolver:         s.passiveIPResolver,
 		PassiveTransferPortRange: portRange,
 		ActiveTransferPortNon20:  s.config.ActiveTransfersPortNon20,
 		IdleTimeout:              -1,
@@ -171,6 +174,7 @@ func (s *Server) ClientConnected(cc ftpserver.ClientContext) (string, error) {
 // ClientDisconnected is called when the user disconnects, even if he never authenticated
 func (s *Server) ClientDisconnected(cc ftpserver.ClientContext) {
 	connID := fmt.Sprintf("%v_%v_%v", common.ProtocolFTP, s.ID, cc.ID())
+	s.passiveIPs.Delete(cc.ID())
 	common.Connections.Remove(connID)
 	common.Connections.RemoveClientConnection(util.GetIPFromRemoteAddress(cc.RemoteAddr().String()))
 }
@@ -182,7 +186,10 @@ func (s *Server) AuthUser(cc ftpserver.ClientContext, username, password string)
 		loginMethod = dataprovider.LoginMethodTLSCertificateAndPwd
 	}
 	ipAddr := util.GetIPFromRemoteAddress(cc.RemoteAddr().String())
-	user, err := dataprovider.CheckUserAndPass(username, password, ipAddr, common.ProtocolFTP)
+	user, isAnonymous, err := common.CheckAnonymousLogin(username, password, ipAddr, common.ProtocolFTP)
+	if !isAnonymous {
+		user, err = dataprovider.CheckUserAndPass(username, password, ipAddr, common.ProtocolFTP)
+	}
 	if err != nil {
 		user.Username = username
 		updateLoginMetrics(&user, ipAddr, loginMethod, err)
@@ -253,6 +260,7 @@ func (s *Server) VerifyConnection(cc ftpserver.ClientContext, user string, tlsCo
 
 				cc.SetExtra(true)
 
+				dbUser.ApplySourceAuthPolicy(cc.RemoteAddr().String())
 				if dbUser.IsLoginMethodAllowed(dataprovider.LoginMethodTLSCertificate, common.ProtocolFTP) {
 					connection, err := s.validateUser(dbUser, cc, dataprovider.LoginMethodTLSCertificate)
 
@@ -337,13 +345,9 @@ func (s *Server) verifyTLSConnection(state tls.ConnectionState) error {
 			return errors.New("TLS connection cannot be verified: unable to get verification chain")
 		}
 		for _, verifiedChain := range state.VerifiedChains {
-			var caCrt *x509.Certificate
-			if len(verifiedChain) > 0 {
-				caCrt = verifiedChain[len(verifiedChain)-1]
-			}
-			if certMgr.IsRevoked(clientCrt, caCrt) {
-				logger.Debug(logSender, "", "tls handshake error, client certificate %q has beed revoked", clientCrtName)
-				return common.ErrCrtRevoked
+			if err := certMgr.CheckRevocation(verifiedChain); err != nil {
+				logger.Debug(logSender, "", "tls handshake error, client certificate %q: %v", clientCrtName, err)
+				return err
 			}
 		}
 	}
@@ -358,6 +362,7 @@ func (s *Server) validateUser(user dataprovider.User, cc ftpserver.ClientContext
 			user.Username, user.HomeDir)
 		return nil, fmt.Errorf("cannot login user with invalid home dir: %q", user.HomeDir)
 	}
+	user.ApplySourceAuthPolicy(cc.RemoteAddr().String())
 	if util.Contains(user.Filters.DeniedProtocols, common.ProtocolFTP) {
 		logger.Info(logSender, connectionID, "cannot login user %q, protocol FTP is not allowed", user.Username)
 		return nil, fmt.Errorf("protocol FTP is not allowed for user %q", user.Username)
@@ -392,6 +397,9 @@ func (s *Server) validateUser(user dataprovider.User, cc ftpserver.ClientContext
 		logger.Warn(logSender, connectionID, "unable to check fs root: %v close fs error: %v", err, errClose)
 		return nil, common.ErrInternalFailure
 	}
+	if s.binding.ReadOnly {
+		user.SetReadOnlyPermissions()
+	}
 	connection := &Connection{
 		BaseConnection: common.NewBaseConnection(fmt.Sprintf("%v_%v", s.ID, cc.ID()), common.ProtocolFTP,
 			cc.LocalAddr().String(), remoteAddr, user),
@@ -403,9 +411,27 @@ func (s *Server) validateUser(user dataprovider.User, cc ftpserver.ClientContext
 		logger.Warn(logSender, connectionID, "unable to swap connection: %v, close fs error: %v", err, errClose)
 		return nil, err
 	}
+	if user.Filters.FTP.PassiveIP != "" {
+		s.passiveIPs.Store(cc.ID(), user.Filters.FTP.PassiveIP)
+	} else {
+		s.passiveIPs.Delete(cc.ID())
+	}
 	return connection, nil
 }
 
+// passiveIPResolver returns the passive IP to advertise. The binding overrides
+// matching the client IP take precedence, then the passive IP configured for
+// the authenticated user, if any, and finally the binding passive IP
+func (s *Server) passiveIPResolver(cc ftpserver.ClientContext) (string, error) {
+	if ip, ok := s.binding.getPassiveIPOverride(cc); ok {
+		return ip, nil
+	}
+	if ip, ok := s.passiveIPs.Load(cc.ID()); ok {
+		return ip.(string), nil
+	}
+	return s.binding.getPassiveIP(cc)
+}
+
 func setStartDirectory(startDirectory string, cc ftpserver.ClientContext) {
 	if startDirectory == "" {
 		return
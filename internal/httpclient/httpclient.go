@@ -230,6 +230,24 @@ func Post(url string, contentType string, body io.Reader) (*http.Response, error
 	return client.Do(req)
 }
 
+// PostWithHeaders issues a POST to the specified URL adding the specified headers.
+// The headers defined in the configuration are added too
+func PostWithHeaders(url string, contentType string, body io.Reader, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	addHeaders(req, url)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	client := GetHTTPClient()
+	defer client.CloseIdleConnections()
+
+	return client.Do(req)
+}
+
 // RetryableGet issues a GET to the specified URL using the retryable client
 func RetryableGet(url string) (*http.Response, error) {
 	req, err := retryablehttp.NewRequest(http.MethodGet, url, nil)
@@ -0,0 +1,175 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+func getAdminAuthSessions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	admin, err := dataprovider.AdminExists(getURLParam(r, "username"))
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	renderAuthSessions(w, r, admin.Username, true)
+}
+
+func revokeAdminAuthSessions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	admin, err := dataprovider.AdminExists(getURLParam(r, "username"))
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	doRevokeAuthSessions(w, r, admin.Username, true, "")
+}
+
+func revokeAdminAuthSession(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	admin, err := dataprovider.AdminExists(getURLParam(r, "username"))
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	doRevokeAuthSessions(w, r, admin.Username, true, getURLParam(r, "id"))
+}
+
+func getUserAuthSessions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	user, err := getScopedUser(r)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	renderAuthSessions(w, r, user.Username, false)
+}
+
+func revokeUserAuthSessions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	user, err := getScopedUser(r)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	doRevokeAuthSessions(w, r, user.Username, false, "")
+}
+
+func revokeUserAuthSession(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	user, err := getScopedUser(r)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	doRevokeAuthSessions(w, r, user.Username, false, getURLParam(r, "id"))
+}
+
+// getMyAuthSessions returns the sessions for the admin or user associated with the request token
+func getMyAuthSessions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	renderAuthSessions(w, r, claims.Username, !claims.hasUserAudience())
+}
+
+func revokeMyAuthSessions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	doRevokeAuthSessions(w, r, claims.Username, !claims.hasUserAudience(), "")
+}
+
+func revokeMyAuthSession(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	doRevokeAuthSessions(w, r, claims.Username, !claims.hasUserAudience(), getURLParam(r, "id"))
+}
+
+// getScopedUser returns the user specified in the URL if it is visible for the
+// admin associated with the request token
+func getScopedUser(r *http.Request) (dataprovider.User, error) {
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		return dataprovider.User{}, util.NewValidationError("invalid token claims")
+	}
+	user, err := dataprovider.UserExists(getURLParam(r, "username"), claims.Role)
+	if err != nil {
+		return user, err
+	}
+	return user, claims.checkUserScope(&user)
+}
+
+func renderAuthSessions(w http.ResponseWriter, r *http.Request, username string, isAdmin bool) {
+	sessions, err := getAuthSessions(username, isAdmin)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	render.JSON(w, r, sessions)
+}
+
+// doRevokeAuthSessions revokes the session with the specified ID or all the
+// sessions for the specified admin or user if the ID is empty
+func doRevokeAuthSessions(w http.ResponseWriter, r *http.Request, username string, isAdmin bool, id string) {
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	var sessions []authSession
+	if id != "" {
+		session, err := authSessionMgr.Get(id)
+		if err != nil || !session.isOwnedBy(username, isAdmin) || isAuthSessionRevoked(session.ID) {
+			sendAPIResponse(w, r, util.NewRecordNotFoundError(fmt.Sprintf("session %q does not exist", id)), "",
+				http.StatusNotFound)
+			return
+		}
+		sessions = append(sessions, *session)
+	} else {
+		sessions, err = getAuthSessions(username, isAdmin)
+		if err != nil {
+			sendAPIResponse(w, r, err, "", getRespStatus(err))
+			return
+		}
+	}
+	for idx := range sessions {
+		if err := revokeAuthSession(&sessions[idx]); err != nil {
+			sendAPIResponse(w, r, err, "", getRespStatus(err))
+			return
+		}
+		logger.Info(logSender, "", "%s session %q for %q revoked by %q, admin? %t, ip: %q", sessions[idx].Type,
+			sessions[idx].ID, username, claims.Username, isAdmin, util.GetIPFromRemoteAddress(r.RemoteAddr))
+	}
+	sendAPIResponse(w, r, nil, fmt.Sprintf("%d session(s) revoked", len(sessions)), http.StatusOK)
+}
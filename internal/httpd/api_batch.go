@@ -0,0 +1,262 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	maxBatchItems       = 1000
+	maxBatchRequestSize = 10 * 1048576 // 10 MB
+)
+
+type batchItemResult struct {
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Transactional bool              `json:"transactional"`
+	Results       []batchItemResult `json:"results"`
+}
+
+func checkBatchSize(size int) error {
+	if size == 0 {
+		return util.NewValidationError("no batch item defined, unable to complete the requested action")
+	}
+	if size > maxBatchItems {
+		return util.NewValidationError(fmt.Sprintf("too many batch items: %d, max allowed: %d", size, maxBatchItems))
+	}
+	return nil
+}
+
+// executeBatch executes the items without a preparation error. In transactional mode
+// a preparation error aborts the whole batch
+func executeBatch[T any](items []T, errs []error, transactional bool, execute func([]T) []error) {
+	validItems := make([]T, 0, len(items))
+	indexes := make([]int, 0, len(items))
+	for idx := range items {
+		if errs[idx] == nil {
+			validItems = append(validItems, items[idx])
+			indexes = append(indexes, idx)
+		}
+	}
+	if transactional && len(validItems) != len(items) {
+		for idx := range errs {
+			if errs[idx] == nil {
+				errs[idx] = dataprovider.ErrBatchAborted
+			}
+		}
+		return
+	}
+	if len(validItems) == 0 {
+		return
+	}
+	for idx, err := range execute(validItems) {
+		errs[indexes[idx]] = err
+		items[indexes[idx]] = validItems[idx]
+	}
+}
+
+func getBatchItemResult(action, name string, err error) batchItemResult {
+	result := batchItemResult{
+		Action: action,
+		Name:   name,
+		Status: http.StatusOK,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		if errors.Is(err, dataprovider.ErrBatchAborted) {
+			result.Status = http.StatusFailedDependency
+		} else {
+			result.Status = getRespStatus(err)
+		}
+		return result
+	}
+	if action == dataprovider.BatchActionAdd {
+		result.Status = http.StatusCreated
+	}
+	return result
+}
+
+func getUserBatchPermission(action string) (string, error) {
+	switch action {
+	case dataprovider.BatchActionAdd:
+		return dataprovider.PermAdminAddUsers, nil
+	case dataprovider.BatchActionUpdate:
+		return dataprovider.PermAdminChangeUsers, nil
+	case dataprovider.BatchActionDelete:
+		return dataprovider.PermAdminDeleteUsers, nil
+	default:
+		return "", util.NewValidationError(fmt.Sprintf("invalid batch action %q", action))
+	}
+}
+
+func prepareUserBatchItem(item *dataprovider.UserBatchItem, claims *jwtTokenClaims, admin *dataprovider.Admin) error {
+	if item.Action == dataprovider.BatchActionAdd {
+		if item.User.ExpirationDate == 0 && admin.Filters.Preferences.DefaultUsersExpiration > 0 {
+			item.User.ExpirationDate = util.GetTimeAsMsSinceEpoch(time.Now().Add(24 * time.Hour *
+				time.Duration(admin.Filters.Preferences.DefaultUsersExpiration)))
+		}
+		if claims.Role != "" {
+			item.User.Role = claims.Role
+		}
+		if err := claims.setUserScope(&item.User); err != nil {
+			return err
+		}
+		prepareUserForAdd(&item.User)
+		return nil
+	}
+	user, err := dataprovider.UserExists(item.User.Username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
+	if err != nil {
+		return err
+	}
+	if item.Action == dataprovider.BatchActionDelete {
+		return nil
+	}
+	if item.User.Password == "" {
+		item.User.Password = user.Password
+	}
+	prepareUserForUpdate(&item.User, &user)
+	if claims.Role != "" {
+		item.User.Role = claims.Role
+	}
+	return claims.setUserScope(&item.User)
+}
+
+func executeUsersBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	var items []dataprovider.UserBatchItem
+	err = render.DecodeJSON(r.Body, &items)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	if err := checkBatchSize(len(items)); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	for _, item := range items {
+		perm, err := getUserBatchPermission(item.Action)
+		if err != nil {
+			sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+			return
+		}
+		if !claims.hasPerm(perm) {
+			sendAPIResponse(w, r, nil, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+	}
+	admin, err := dataprovider.AdminExists(claims.Username)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	errs := make([]error, len(items))
+	for idx := range items {
+		errs[idx] = prepareUserBatchItem(&items[idx], &claims, &admin)
+	}
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	transactional := dataprovider.IsBatchTransactional()
+	executeBatch(items, errs, transactional, func(validItems []dataprovider.UserBatchItem) []error {
+		return dataprovider.ExecuteUsersBatch(validItems, claims.Username, ipAddr, claims.Role)
+	})
+	resp := batchResponse{
+		Transactional: transactional,
+		Results:       make([]batchItemResult, 0, len(items)),
+	}
+	for idx, item := range items {
+		resp.Results = append(resp.Results, getBatchItemResult(item.Action, item.User.Username, errs[idx]))
+		if errs[idx] == nil && item.Action == dataprovider.BatchActionDelete {
+			disconnectUser(item.User.Username, claims.Username, claims.Role)
+		}
+	}
+	render.JSON(w, r, resp)
+}
+
+func prepareFolderBatchItem(item *dataprovider.FolderBatchItem) error {
+	switch item.Action {
+	case dataprovider.BatchActionAdd:
+		return nil
+	case dataprovider.BatchActionUpdate, dataprovider.BatchActionDelete:
+		folder, err := dataprovider.GetFolderByName(item.Folder.Name)
+		if err != nil {
+			return err
+		}
+		if item.Action == dataprovider.BatchActionUpdate {
+			prepareFolderForUpdate(&item.Folder, &folder)
+			item.Folder.Users = folder.Users
+			item.Folder.Groups = folder.Groups
+		}
+		return nil
+	default:
+		return util.NewValidationError(fmt.Sprintf("invalid batch action %q", item.Action))
+	}
+}
+
+func executeFoldersBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	var items []dataprovider.FolderBatchItem
+	err = render.DecodeJSON(r.Body, &items)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	if err := checkBatchSize(len(items)); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	errs := make([]error, len(items))
+	for idx := range items {
+		errs[idx] = prepareFolderBatchItem(&items[idx])
+	}
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	transactional := dataprovider.IsBatchTransactional()
+	executeBatch(items, errs, transactional, func(validItems []dataprovider.FolderBatchItem) []error {
+		return dataprovider.ExecuteFoldersBatch(validItems, claims.Username, ipAddr, claims.Role)
+	})
+	resp := batchResponse{
+		Transactional: transactional,
+		Results:       make([]batchItemResult, 0, len(items)),
+	}
+	for idx, item := range items {
+		resp.Results = append(resp.Results, getBatchItemResult(item.Action, item.Folder.Name, errs[idx]))
+	}
+	render.JSON(w, r, resp)
+}
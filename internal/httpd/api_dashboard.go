@@ -0,0 +1,213 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	// number of users to include in the dashboard rankings
+	dashboardTopUsers = 10
+	// the quota stats require to load all the users and so they are cached
+	dashboardQuotaCacheTTL = time.Minute
+)
+
+var dashboardQuotaCache = newDashboardQuotaStatsCache()
+
+// dashboardStats defines the live stats for the local node
+type dashboardStats struct {
+	// Unix timestamp in milliseconds
+	Timestamp int64 `json:"timestamp"`
+	// Active connections for each protocol
+	Connections map[string]int `json:"connections"`
+	Transfers   int            `json:"transfers"`
+	// Bytes transferred since the service start, they include the active
+	// transfers. The throughput is calculated by clients comparing two samples
+	Protocols []common.TransferStat  `json:"protocols"`
+	TopUsers  []common.TransferStat  `json:"top_users"`
+	Defender  dashboardDefenderStats `json:"defender"`
+}
+
+type dashboardDefenderStats struct {
+	// Enabled is false if the defender is disabled or the admin does not
+	// have the permission to view it
+	Enabled bool `json:"enabled"`
+	// Number of hosts with a score and banned hosts
+	Hosts  int `json:"hosts"`
+	Banned int `json:"banned"`
+}
+
+type dashboardUserQuota struct {
+	Username       string `json:"username"`
+	UsedQuotaSize  int64  `json:"used_quota_size"`
+	UsedQuotaFiles int    `json:"used_quota_files"`
+	QuotaSize      int64  `json:"quota_size"`
+	QuotaFiles     int    `json:"quota_files"`
+}
+
+// dashboardQuotaStats defines the quota usage for all the users
+type dashboardQuotaStats struct {
+	// Unix timestamp in milliseconds
+	Timestamp      int64                `json:"timestamp"`
+	Users          int                  `json:"users"`
+	UsedQuotaSize  int64                `json:"used_quota_size"`
+	UsedQuotaFiles int                  `json:"used_quota_files"`
+	TopUsers       []dashboardUserQuota `json:"top_users"`
+}
+
+type dashboardQuotaStatsCache struct {
+	mu    sync.Mutex
+	stats map[string]dashboardQuotaStats
+}
+
+func newDashboardQuotaStatsCache() *dashboardQuotaStatsCache {
+	return &dashboardQuotaStatsCache{
+		stats: make(map[string]dashboardQuotaStats),
+	}
+}
+
+// get returns the quota stats for the users visible to the admin associated
+// with the specified claims, they are loaded from the data provider if not
+// cached or expired
+func (c *dashboardQuotaStatsCache) get(claims *jwtTokenClaims) (dashboardQuotaStats, error) {
+	key := strings.Join(append([]string{claims.Role}, claims.UserSelectors...), "\x00")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats, ok := c.stats[key]
+	if ok && stats.Timestamp > util.GetTimeAsMsSinceEpoch(time.Now().Add(-dashboardQuotaCacheTTL)) {
+		return stats, nil
+	}
+	stats, err := loadDashboardQuotaStats(claims)
+	if err != nil {
+		return stats, err
+	}
+	c.stats[key] = stats
+	return stats, nil
+}
+
+func loadDashboardQuotaStats(claims *jwtTokenClaims) (dashboardQuotaStats, error) {
+	stats := dashboardQuotaStats{
+		Timestamp: util.GetTimeAsMsSinceEpoch(time.Now()),
+		TopUsers:  make([]dashboardUserQuota, 0),
+	}
+	offset := 0
+	for {
+		users, err := dataprovider.GetUsers(defaultQueryLimit, offset, dataprovider.OrderASC, claims.Role)
+		if err != nil {
+			return stats, err
+		}
+		numUsers := len(users)
+		users = claims.filterUsersByScope(users)
+		for idx := range users {
+			user := &users[idx]
+			stats.Users++
+			stats.UsedQuotaSize += user.UsedQuotaSize
+			stats.UsedQuotaFiles += user.UsedQuotaFiles
+			if user.UsedQuotaSize == 0 && user.UsedQuotaFiles == 0 {
+				continue
+			}
+			stats.TopUsers = append(stats.TopUsers, dashboardUserQuota{
+				Username:       user.Username,
+				UsedQuotaSize:  user.UsedQuotaSize,
+				UsedQuotaFiles: user.UsedQuotaFiles,
+				QuotaSize:      user.QuotaSize,
+				QuotaFiles:     user.QuotaFiles,
+			})
+		}
+		if numUsers < defaultQueryLimit {
+			break
+		}
+		offset += numUsers
+	}
+	slices.SortFunc(stats.TopUsers, func(a, b dashboardUserQuota) int {
+		if a.UsedQuotaSize == b.UsedQuotaSize {
+			return strings.Compare(a.Username, b.Username)
+		}
+		if a.UsedQuotaSize > b.UsedQuotaSize {
+			return -1
+		}
+		return 1
+	})
+	if len(stats.TopUsers) > dashboardTopUsers {
+		stats.TopUsers = stats.TopUsers[:dashboardTopUsers]
+	}
+	return stats, nil
+}
+
+func getDashboardStats(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	stats := dashboardStats{
+		Timestamp:   util.GetTimeAsMsSinceEpoch(time.Now()),
+		Connections: make(map[string]int),
+		Defender: dashboardDefenderStats{
+			Enabled: common.Config.DefenderConfig.Enabled && claims.hasPerm(dataprovider.PermAdminViewDefender),
+		},
+	}
+	for _, conn := range common.Connections.GetStats(claims.Role) {
+		stats.Connections[conn.Protocol]++
+		stats.Transfers += len(conn.Transfers)
+	}
+	stats.Protocols, stats.TopUsers = common.GetTransferStats(claims.Role)
+	if len(stats.TopUsers) > dashboardTopUsers {
+		stats.TopUsers = stats.TopUsers[:dashboardTopUsers]
+	}
+	if stats.Defender.Enabled {
+		hosts, err := common.GetDefenderHosts()
+		if err != nil {
+			sendAPIResponse(w, r, err, "", getRespStatus(err))
+			return
+		}
+		for _, host := range hosts {
+			stats.Defender.Hosts++
+			if !host.BanTime.IsZero() {
+				stats.Defender.Banned++
+			}
+		}
+	}
+	render.JSON(w, r, stats)
+}
+
+func getDashboardQuotaStats(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	stats, err := dashboardQuotaCache.get(&claims)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	render.JSON(w, r, stats)
+}
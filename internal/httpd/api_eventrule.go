@@ -124,6 +124,18 @@ func updateEventAction(w http.ResponseWriter, r *http.Request) {
 		if updatedAction.Options.HTTPConfig.Password.IsNotPlainAndNotEmpty() {
 			updatedAction.Options.HTTPConfig.Password = action.Options.HTTPConfig.Password
 		}
+	case dataprovider.ActionTypeBackup:
+		if updatedAction.Options.BackupConfig.Passphrase.IsNotPlainAndNotEmpty() {
+			updatedAction.Options.BackupConfig.Passphrase = action.Options.BackupConfig.Passphrase
+		}
+	case dataprovider.ActionTypeChatNotification:
+		if updatedAction.Options.ChatConfig.Token.IsNotPlainAndNotEmpty() {
+			updatedAction.Options.ChatConfig.Token = action.Options.ChatConfig.Token
+		}
+	case dataprovider.ActionTypeMessagePublish:
+		if updatedAction.Options.MessageConfig.Password.IsNotPlainAndNotEmpty() {
+			updatedAction.Options.MessageConfig.Password = action.Options.MessageConfig.Password
+		}
 	}
 
 	err = dataprovider.UpdateEventAction(&updatedAction, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role)
@@ -171,6 +183,7 @@ func renderEventRule(w http.ResponseWriter, r *http.Request, name string, claims
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
+	w.Header().Set("ETag", getEventRuleETag(&rule))
 	if hideConfidentialData(claims, r) {
 		rule.PrepareForRendering()
 	}
@@ -225,6 +238,9 @@ func updateEventRule(w http.ResponseWriter, r *http.Request) {
 	}
 
 	rule, err := dataprovider.EventRuleExists(getURLParam(r, "name"))
+	if err == nil {
+		err = checkIfMatch(r, getEventRuleETag(&rule))
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
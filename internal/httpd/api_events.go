@@ -17,6 +17,7 @@ package httpd
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -26,11 +27,15 @@ import (
 	"github.com/sftpgo/sdk/plugin/eventsearcher"
 	"github.com/sftpgo/sdk/plugin/notifier"
 
+	"github.com/drakkan/sftpgo/v2/internal/common"
 	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
 	"github.com/drakkan/sftpgo/v2/internal/plugin"
 	"github.com/drakkan/sftpgo/v2/internal/util"
 )
 
+const eventStreamKeepAliveInterval = 30 * time.Second
+
 func getCommonSearchParamsFromRequest(r *http.Request) (eventsearcher.CommonSearchParams, error) {
 	c := eventsearcher.CommonSearchParams{}
 	c.Limit = 100
@@ -72,10 +77,30 @@ func getCommonSearchParamsFromRequest(r *http.Request) (eventsearcher.CommonSear
 	c.IP = r.URL.Query().Get("ip")
 	c.InstanceIDs = getCommaSeparatedQueryParam(r, "instance_ids")
 	c.FromID = r.URL.Query().Get("from_id")
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		cursor, err := decodeListCursor(value, eventsCursorSortBy, getEventsOrder(c.Order))
+		if err != nil {
+			return c, err
+		}
+		// the next page starts after the last returned event
+		c.FromID = cursor.Key
+		if c.Order == 1 {
+			c.StartTimestamp = cursor.Value
+		} else {
+			c.EndTimestamp = cursor.Value
+		}
+	}
 
 	return c, nil
 }
 
+func getEventsOrder(order int) string {
+	if order == 1 {
+		return dataprovider.OrderASC
+	}
+	return dataprovider.OrderDESC
+}
+
 func getFsSearchParamsFromRequest(r *http.Request) (eventsearcher.FsEventSearch, error) {
 	var err error
 	s := eventsearcher.FsEventSearch{}
@@ -141,6 +166,46 @@ func getLogSearchParamsFromRequest(r *http.Request) (eventsearcher.LogEventSearc
 	return s, nil
 }
 
+func getAuditLogSearchParamsFromRequest(r *http.Request) (dataprovider.AuditLogSearch, error) {
+	s := dataprovider.AuditLogSearch{
+		Limit: 100,
+		Order: dataprovider.OrderDESC,
+	}
+	common, err := getCommonSearchParamsFromRequest(r)
+	if err != nil {
+		return s, err
+	}
+	s.Limit = common.Limit
+	if common.Order == 1 {
+		s.Order = dataprovider.OrderASC
+	}
+	s.StartTimestamp = common.StartTimestamp
+	s.EndTimestamp = common.EndTimestamp
+	s.IP = common.IP
+	if common.FromID != "" {
+		fromID, err := strconv.ParseInt(common.FromID, 10, 64)
+		if err != nil {
+			return s, util.NewValidationError(fmt.Sprintf("invalid from_id: %v", err))
+		}
+		s.FromID = fromID
+	}
+	s.Executor = r.URL.Query().Get("executor")
+	s.ObjectName = r.URL.Query().Get("object_name")
+	s.ObjectTypes = getCommaSeparatedQueryParam(r, "object_types")
+	for _, objectType := range s.ObjectTypes {
+		if !util.Contains(dataprovider.GetAuditLogObjectTypes(), objectType) {
+			return s, util.NewValidationError(fmt.Sprintf("invalid object type: %q", objectType))
+		}
+	}
+	s.Operations = getCommaSeparatedQueryParam(r, "operations")
+	for _, operation := range s.Operations {
+		if !util.Contains(dataprovider.GetAuditLogOperations(), operation) {
+			return s, util.NewValidationError(fmt.Sprintf("invalid operation: %q", operation))
+		}
+	}
+	return s, nil
+}
+
 func searchFsEvents(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	claims, err := getTokenClaims(r)
@@ -169,9 +234,7 @@ func searchFsEvents(w http.ResponseWriter, r *http.Request) {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(data) //nolint:errcheck
+	renderEvents(w, r, data, filters.Limit, getEventsOrder(filters.Order))
 }
 
 func searchProviderEvents(w http.ResponseWriter, r *http.Request) {
@@ -204,9 +267,7 @@ func searchProviderEvents(w http.ResponseWriter, r *http.Request) {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(data) //nolint:errcheck
+	renderEvents(w, r, data, filters.Limit, getEventsOrder(filters.Order))
 }
 
 func searchLogEvents(w http.ResponseWriter, r *http.Request) {
@@ -237,9 +298,106 @@ func searchLogEvents(w http.ResponseWriter, r *http.Request) {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
+	renderEvents(w, r, data, filters.Limit, getEventsOrder(filters.Order))
+}
+
+func searchAuditLogs(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+
+	var filters dataprovider.AuditLogSearch
+	if filters, err = getAuditLogSearchParamsFromRequest(r); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	filters.Role = getRoleFilterForEventSearch(r, claims.Role)
+	filters.OmitObjectData = getBoolQueryParam(r, "omit_object_data")
+
+	if getBoolQueryParam(r, "csv_export") {
+		filters.Limit = 100
+		filters.OmitObjectData = true
+		if err := exportAuditLogs(w, &filters); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+		return
+	}
+
+	entries, err := dataprovider.SearchAuditLogs(&filters)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if len(entries) > 0 && len(entries) == filters.Limit {
+		last := entries[len(entries)-1]
+		setNextCursorHeader(w, eventsCursorSortBy, filters.Order, &dataprovider.ListCursor{
+			Value: last.Timestamp,
+			Key:   strconv.FormatInt(last.ID, 10),
+		})
+	}
+	renderListJSON(w, r, entries)
+}
+
+func streamEvents(w http.ResponseWriter, r *http.Request) {
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	sub, err := common.SubscribeEvents(getCommaSeparatedQueryParam(r, "types"),
+		getCommaSeparatedQueryParam(r, "usernames"), claims.Role)
+	if err != nil {
+		status := getRespStatus(err)
+		if errors.Is(err, common.ErrTooManySubscribers) {
+			status = http.StatusTooManyRequests
+		}
+		sendAPIResponse(w, r, err, "", status)
+		return
+	}
+	defer common.UnsubscribeEvents(sub)
+
+	rc := http.NewResponseController(w)
+	// the stream is long lived, remove the server write timeout
+	rc.SetWriteDeadline(time.Time{}) //nolint:errcheck
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		logger.Warn(logSender, "", "unable to flush the event stream: %v", err)
+		return
+	}
+	logger.Debug(logSender, "", "event stream subscription %q added for admin %q", sub.ID, claims.Username)
+
+	keepAlive := time.NewTicker(eventStreamKeepAliveInterval)
+	defer keepAlive.Stop()
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(data) //nolint:errcheck
+	for {
+		select {
+		case <-r.Context().Done():
+			logger.Debug(logSender, "", "event stream subscription %q closed, dropped events: %d",
+				sub.ID, sub.GetDropped())
+			return
+		case ev := <-sub.Events():
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
 }
 
 func exportFsEvents(w http.ResponseWriter, filters *eventsearcher.FsEventSearch) error {
@@ -353,6 +511,37 @@ func exportLogEvents(w http.ResponseWriter, filters *eventsearcher.LogEventSearc
 	return csvWriter.Error()
 }
 
+func exportAuditLogs(w http.ResponseWriter, filters *dataprovider.AuditLogSearch) error {
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=auditlogs-%s.csv", time.Now().Format("2006-01-02T15-04-05")))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Accept-Ranges", "none")
+	w.WriteHeader(http.StatusOK)
+
+	entry := dataprovider.AuditLogEntry{}
+	csvWriter := csv.NewWriter(w)
+	err := csvWriter.Write(entry.GetCSVHeader())
+	if err != nil {
+		return err
+	}
+	for {
+		results, err := dataprovider.SearchAuditLogs(filters)
+		if err != nil {
+			return err
+		}
+		for idx := range results {
+			if err := csvWriter.Write(results[idx].GetCSVData()); err != nil {
+				return err
+			}
+		}
+		if len(results) == 0 || len(results) < filters.Limit {
+			break
+		}
+		filters.FromID = results[len(results)-1].ID
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
 func getRoleFilterForEventSearch(r *http.Request, defaultValue string) string {
 	if defaultValue != "" {
 		return defaultValue
@@ -34,12 +34,27 @@ func getFolders(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	folders, err := dataprovider.GetFolders(limit, offset, order, false)
+	if isOffsetPagination(limit, offset) {
+		folders, err := dataprovider.GetFolders(limit, offset, order, false)
+		if err != nil {
+			sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+			return
+		}
+		renderListJSON(w, r, folders)
+		return
+	}
+	opts, err := getListOptions(r, limit, order, dataprovider.GetFolderSortFields())
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	folders, next, err := dataprovider.ListFolders(opts)
 	if err != nil {
-		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
-	render.JSON(w, r, folders)
+	setNextCursorHeader(w, opts.SortBy, opts.Order, next)
+	renderListJSON(w, r, folders)
 }
 
 func addFolder(w http.ResponseWriter, r *http.Request) {
@@ -75,6 +90,9 @@ func updateFolder(w http.ResponseWriter, r *http.Request) {
 
 	name := getURLParam(r, "name")
 	folder, err := dataprovider.GetFolderByName(name)
+	if err == nil {
+		err = checkIfMatch(r, getFolderETag(&folder))
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
@@ -86,13 +104,7 @@ func updateFolder(w http.ResponseWriter, r *http.Request) {
 		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
 		return
 	}
-	updatedFolder.ID = folder.ID
-	updatedFolder.Name = folder.Name
-	updatedFolder.FsConfig.SetEmptySecretsIfNil()
-	updateEncryptedSecrets(&updatedFolder.FsConfig, folder.FsConfig.S3Config.AccessSecret, folder.FsConfig.AzBlobConfig.AccountKey,
-		folder.FsConfig.AzBlobConfig.SASURL, folder.FsConfig.GCSConfig.Credentials, folder.FsConfig.CryptConfig.Passphrase,
-		folder.FsConfig.SFTPConfig.Password, folder.FsConfig.SFTPConfig.PrivateKey, folder.FsConfig.SFTPConfig.KeyPassphrase,
-		folder.FsConfig.HTTPConfig.Password, folder.FsConfig.HTTPConfig.APIKey)
+	prepareFolderForUpdate(&updatedFolder, &folder)
 
 	err = dataprovider.UpdateFolder(&updatedFolder, folder.Users, folder.Groups, claims.Username,
 		util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role)
@@ -103,12 +115,27 @@ func updateFolder(w http.ResponseWriter, r *http.Request) {
 	sendAPIResponse(w, r, nil, "Folder updated", http.StatusOK)
 }
 
+// prepareFolderForUpdate preserves the identifiers and the unchanged secrets
+// in the updated folder
+func prepareFolderForUpdate(updatedFolder, folder *vfs.BaseVirtualFolder) {
+	updatedFolder.ID = folder.ID
+	updatedFolder.Name = folder.Name
+	updatedFolder.FsConfig.SetEmptySecretsIfNil()
+	updateEncryptedSecrets(&updatedFolder.FsConfig, folder.FsConfig.S3Config.AccessSecret, folder.FsConfig.AzBlobConfig.AccountKey,
+		folder.FsConfig.AzBlobConfig.SASURL, folder.FsConfig.GCSConfig.Credentials, folder.FsConfig.CryptConfig.Passphrase,
+		folder.FsConfig.SFTPConfig.Password, folder.FsConfig.SFTPConfig.PrivateKey, folder.FsConfig.SFTPConfig.KeyPassphrase,
+		folder.FsConfig.HTTPConfig.Password, folder.FsConfig.HTTPConfig.APIKey)
+	updateUnionFsEncryptedSecrets(&updatedFolder.FsConfig, folder.FsConfig.UnionConfig)
+	updateCryptFsOldPassphrases(&updatedFolder.FsConfig, folder.FsConfig)
+}
+
 func renderFolder(w http.ResponseWriter, r *http.Request, name string, claims *jwtTokenClaims, status int) {
 	folder, err := dataprovider.GetFolderByName(name)
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
+	w.Header().Set("ETag", getFolderETag(&folder))
 	if hideConfidentialData(claims, r) {
 		folder.PrepareForRendering()
 	}
@@ -74,6 +74,9 @@ func updateGroup(w http.ResponseWriter, r *http.Request) {
 
 	name := getURLParam(r, "name")
 	group, err := dataprovider.GroupExists(name)
+	if err == nil {
+		err = checkIfMatch(r, getGroupETag(&group))
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
@@ -102,6 +105,8 @@ func updateGroup(w http.ResponseWriter, r *http.Request) {
 	updateEncryptedSecrets(&updatedGroup.UserSettings.FsConfig, currentS3AccessSecret, currentAzAccountKey, currentAzSASUrl,
 		currentGCSCredentials, currentCryptoPassphrase, currentSFTPPassword, currentSFTPKey, currentSFTPKeyPassphrase,
 		currentHTTPPassword, currentHTTPAPIKey)
+	updateUnionFsEncryptedSecrets(&updatedGroup.UserSettings.FsConfig, group.UserSettings.FsConfig.UnionConfig)
+	updateCryptFsOldPassphrases(&updatedGroup.UserSettings.FsConfig, group.UserSettings.FsConfig)
 	err = dataprovider.UpdateGroup(&updatedGroup, group.Users, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr),
 		claims.Role)
 	if err != nil {
@@ -117,6 +122,7 @@ func renderGroup(w http.ResponseWriter, r *http.Request, name string, claims *jw
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
+	w.Header().Set("ETag", getGroupETag(&group))
 	if hideConfidentialData(claims, r) {
 		group.PrepareForRendering()
 	}
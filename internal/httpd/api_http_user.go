@@ -16,6 +16,8 @@ package httpd
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -33,6 +35,11 @@ import (
 	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
 	"github.com/drakkan/sftpgo/v2/internal/logger"
 	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+var (
+	errChecksumMismatch = errors.New("checksum mismatch")
 )
 
 func getUserConnection(w http.ResponseWriter, r *http.Request) (*Connection, error) {
@@ -185,6 +192,136 @@ func copyUserFsEntry(w http.ResponseWriter, r *http.Request) {
 	sendAPIResponse(w, r, nil, fmt.Sprintf("%q copied to %q", source, target), http.StatusOK)
 }
 
+func getUserFileVersions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	name := connection.User.GetCleanedPath(r.URL.Query().Get("path"))
+	versions, err := connection.GetFileVersions(name)
+	if err != nil {
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to get versions for file %q", name), getMappedStatusCode(err))
+		return
+	}
+	if versions == nil {
+		versions = []vfs.FileVersion{}
+	}
+	render.JSON(w, r, versions)
+}
+
+func restoreUserFileVersion(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	name := connection.User.GetCleanedPath(r.URL.Query().Get("path"))
+	versionID := strings.TrimSpace(r.URL.Query().Get("version_id"))
+	if versionID == "" {
+		sendAPIResponse(w, r, nil, "Please set the version to restore", http.StatusBadRequest)
+		return
+	}
+	err = connection.RestoreFileVersion(name, versionID)
+	if err != nil {
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to restore version %q for file %q", versionID, name),
+			getMappedStatusCode(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, fmt.Sprintf("Version %q restored for file %q", versionID, name), http.StatusOK)
+}
+
+func getUserTrash(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	items, err := connection.GetTrashItems()
+	if err != nil {
+		sendAPIResponse(w, r, err, "Unable to get the trash contents", getMappedStatusCode(err))
+		return
+	}
+	render.JSON(w, r, items)
+}
+
+func restoreUserTrashItem(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	id := getURLParam(r, "id")
+	item, err := connection.RestoreTrashItem(id)
+	if err != nil {
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to restore trash item %q", id), getMappedStatusCode(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, fmt.Sprintf("File %q restored", item.Path), http.StatusOK)
+}
+
+func deleteUserTrashItem(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	id := getURLParam(r, "id")
+	if err := connection.DeleteTrashItem(id); err != nil {
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to delete trash item %q", id), getMappedStatusCode(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, "Trash item deleted", http.StatusOK)
+}
+
+func emptyUserTrash(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	if err := connection.EmptyTrash(); err != nil {
+		sendAPIResponse(w, r, err, "Unable to empty the trash", getMappedStatusCode(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, "Trash emptied", http.StatusOK)
+}
+
+func setUserFileAccessTier(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	name := connection.User.GetCleanedPath(r.URL.Query().Get("path"))
+	tier := strings.TrimSpace(r.URL.Query().Get("tier"))
+	if tier == "" {
+		sendAPIResponse(w, r, nil, "Please set the access tier", http.StatusBadRequest)
+		return
+	}
+	err = connection.SetFileAccessTier(name, tier)
+	if err != nil {
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to set access tier %q for file %q", tier, name),
+			getMappedStatusCode(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, fmt.Sprintf("Access tier %q set for file %q", tier, name), http.StatusOK)
+}
+
 func getUserFile(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	connection, err := getUserConnection(w, r)
@@ -280,9 +417,56 @@ func uploadUserFile(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if err = checkChecksumFromHeader(r, connection, filePath); err != nil {
+		status := getMappedStatusCode(err)
+		if errors.Is(err, errChecksumMismatch) {
+			status = http.StatusConflict
+		}
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to overwrite file %q", filePath), status)
+		return
+	}
 	doUploadFile(w, r, connection, filePath) //nolint:errcheck
 }
 
+// getFileChecksum returns the hex encoded SHA-256 checksum of the specified file
+func getFileChecksum(r *http.Request, connection *Connection, filePath string) (string, error) {
+	reader, err := connection.getFileReader(filePath, 0, r.Method, nil)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkChecksumFromHeader returns errChecksumMismatch if the checksum header is set
+// and the file to overwrite was modified or removed after the checksum was computed.
+// This allows to detect concurrent edits
+func checkChecksumFromHeader(r *http.Request, connection *Connection, filePath string) error {
+	expected := strings.ToLower(strings.TrimSpace(r.Header.Get(checksumHeader)))
+	if expected == "" {
+		return nil
+	}
+	connection.User.CheckFsRoot(connection.ID) //nolint:errcheck
+	checksum, err := getFileChecksum(r, connection, filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: the file no longer exists", errChecksumMismatch)
+		}
+		return err
+	}
+	if checksum != expected {
+		connection.Log(logger.LevelInfo, "checksum mismatch for file %q, expected: %q, actual: %q",
+			filePath, expected, checksum)
+		return fmt.Errorf("%w: the file was modified", errChecksumMismatch)
+	}
+	return nil
+}
+
 func doUploadFile(w http.ResponseWriter, r *http.Request, connection *Connection, filePath string) error {
 	connection.User.CheckFsRoot(connection.ID) //nolint:errcheck
 	writer, err := connection.getFileWriter(filePath)
@@ -432,6 +616,11 @@ func getUserFilesAsZipStream(w http.ResponseWriter, r *http.Request) {
 	}
 	defer common.Connections.Remove(connection.GetID())
 
+	opts, err := getArchiveOptions(r)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
 	var filesList []string
 	err = render.DecodeJSON(r.Body, &filesList)
 	if err != nil {
@@ -447,8 +636,8 @@ func getUserFilesAsZipStream(w http.ResponseWriter, r *http.Request) {
 	filesList = util.RemoveDuplicates(filesList, false)
 
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"",
-		getCompressedFileName(connection.GetUsername(), filesList)))
-	renderCompressedFiles(w, connection, baseDir, filesList, nil)
+		getCompressedFileName(connection.GetUsername(), filesList, opts.format)))
+	renderCompressedFiles(w, r, connection, baseDir, filesList, nil, opts)
 }
 
 func getUserProfile(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,69 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/render"
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+func getKeyRotations(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	render.JSON(w, r, common.ActiveKeyRotations.Get(claims.Role))
+}
+
+func startKeyRotation(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+
+	// the group settings are not applied, only the user's own filesystem can be rotated
+	user, err := dataprovider.UserExists(getURLParam(r, "username"), claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if user.FsConfig.Provider != sdk.CryptedFilesystemProvider || len(user.FsConfig.CryptConfig.OldPassphrases) == 0 {
+		sendAPIResponse(w, r, nil, fmt.Sprintf("No old passphrase to rotate for user %q", user.Username),
+			http.StatusBadRequest)
+		return
+	}
+	if !common.ActiveKeyRotations.Add(user.Username, user.Role) {
+		sendAPIResponse(w, r, nil, fmt.Sprintf("Another key rotation is already in progress for user %q", user.Username),
+			http.StatusConflict)
+		return
+	}
+	go common.RotateEncryptionKey(user, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr)) //nolint:errcheck
+
+	sendAPIResponse(w, r, nil, "Key rotation started", http.StatusAccepted)
+}
@@ -19,6 +19,8 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/render"
 
@@ -71,6 +73,8 @@ func addAPIKey(w http.ResponseWriter, r *http.Request) {
 	apiKey.KeyID = ""
 	apiKey.Key = ""
 	apiKey.LastUseAt = 0
+	apiKey.Filters.PreviousKey = ""
+	apiKey.Filters.PreviousKeyExpiresAt = 0
 	err = dataprovider.AddAPIKey(&apiKey, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role)
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
@@ -108,6 +112,8 @@ func updateAPIKey(w http.ResponseWriter, r *http.Request) {
 
 	updatedAPIKey.KeyID = keyID
 	updatedAPIKey.Key = apiKey.Key
+	updatedAPIKey.Filters.PreviousKey = apiKey.Filters.PreviousKey
+	updatedAPIKey.Filters.PreviousKeyExpiresAt = apiKey.Filters.PreviousKeyExpiresAt
 	err = dataprovider.UpdateAPIKey(&updatedAPIKey, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role)
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
@@ -116,6 +122,35 @@ func updateAPIKey(w http.ResponseWriter, r *http.Request) {
 	sendAPIResponse(w, r, nil, "API key updated", http.StatusOK)
 }
 
+func rotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	gracePeriod := 60
+	if _, ok := r.URL.Query()["grace-period"]; ok {
+		val, err := strconv.Atoi(r.URL.Query().Get("grace-period"))
+		if err != nil || val < 0 {
+			sendAPIResponse(w, r, err, "Invalid grace-period", http.StatusBadRequest)
+			return
+		}
+		gracePeriod = val
+	}
+	keyID := getURLParam(r, "id")
+	apiKey, err := dataprovider.RotateAPIKey(keyID, time.Duration(gracePeriod)*time.Minute, claims.Username,
+		util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	response := make(map[string]string)
+	response["message"] = "API key rotated. This is the only time the new API key is visible, please save it."
+	response["key"] = apiKey.DisplayKey()
+	render.JSON(w, r, response)
+}
+
 func deleteAPIKey(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	keyID := getURLParam(r, "id")
@@ -0,0 +1,47 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/kms"
+)
+
+func getKMSStatus(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	render.JSON(w, r, kms.GetStatus())
+}
+
+func getRekeyStatus(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	render.JSON(w, r, dataprovider.GetRekeyStatus())
+}
+
+func startRekey(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if status := kms.GetStatus(); !status.IsActive {
+		sendAPIResponse(w, r, nil, "The configured KMS is not working: "+status.Error, http.StatusServiceUnavailable)
+		return
+	}
+	if !dataprovider.StartRekey(getBoolQueryParam(r, "force")) {
+		sendAPIResponse(w, r, nil, "Another re-key is already in progress", http.StatusConflict)
+		return
+	}
+	sendAPIResponse(w, r, nil, "Re-key started", http.StatusAccepted)
+}
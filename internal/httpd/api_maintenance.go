@@ -181,6 +181,44 @@ func loadData(w http.ResponseWriter, r *http.Request) {
 	sendAPIResponse(w, r, err, "Data restored", http.StatusOK)
 }
 
+func getRemoteBackups(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	backups, err := common.GetRemoteBackups(getURLParam(r, "name"))
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if backups == nil {
+		backups = []common.RemoteBackup{}
+	}
+	render.JSON(w, r, backups)
+}
+
+func restoreRemoteBackup(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	_, scanQuota, mode, err := getLoaddataOptions(r)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	backupName := getURLParam(r, "backup")
+	content, err := common.GetRemoteBackupContent(getURLParam(r, "name"), backupName, MaxRestoreSize)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if err := restoreBackup(content, backupName, scanQuota, mode, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, "Data restored", http.StatusOK)
+}
+
 func restoreBackup(content []byte, inputFile string, scanQuota, mode int, executor, ipAddress, role string) error {
 	dump, err := dataprovider.ParseDumpData(content)
 	if err != nil {
@@ -503,6 +541,8 @@ func RestoreRoles(roles []dataprovider.Role, inputFile string, mode int, executo
 
 // RestoreGroups restores the specified groups
 func RestoreGroups(groups []dataprovider.Group, inputFile string, mode int, executor, ipAddress, role string) error {
+	// included groups must be restored before the groups that include them
+	groups = dataprovider.OrderGroupsByInclusion(groups)
 	for idx := range groups {
 		group := groups[idx]
 		g, err := dataprovider.GroupExists(group.Name)
@@ -44,6 +44,9 @@ func startMetadataCheck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	user, err := dataprovider.GetUserWithGroupSettings(getURLParam(r, "username"), claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
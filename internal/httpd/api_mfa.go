@@ -175,7 +175,7 @@ func getRecoveryCodes(w http.ResponseWriter, r *http.Request) {
 			sendAPIResponse(w, r, err, "", getRespStatus(err))
 			return
 		}
-		if !user.Filters.TOTPConfig.Enabled {
+		if !user.Filters.TOTPConfig.Enabled && len(user.Filters.WebAuthnCredentials) == 0 {
 			sendAPIResponse(w, r, errRecoveryCodeForbidden, "", http.StatusForbidden)
 			return
 		}
@@ -186,7 +186,7 @@ func getRecoveryCodes(w http.ResponseWriter, r *http.Request) {
 			sendAPIResponse(w, r, err, "", getRespStatus(err))
 			return
 		}
-		if !admin.Filters.TOTPConfig.Enabled {
+		if !admin.Filters.TOTPConfig.Enabled && len(admin.Filters.WebAuthnCredentials) == 0 {
 			sendAPIResponse(w, r, errRecoveryCodeForbidden, "", http.StatusForbidden)
 			return
 		}
@@ -226,7 +226,7 @@ func generateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
 			sendAPIResponse(w, r, err, "", getRespStatus(err))
 			return
 		}
-		if !user.Filters.TOTPConfig.Enabled {
+		if !user.Filters.TOTPConfig.Enabled && len(user.Filters.WebAuthnCredentials) == 0 {
 			sendAPIResponse(w, r, errRecoveryCodeForbidden, "", http.StatusForbidden)
 			return
 		}
@@ -241,7 +241,7 @@ func generateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
 			sendAPIResponse(w, r, err, "", getRespStatus(err))
 			return
 		}
-		if !admin.Filters.TOTPConfig.Enabled {
+		if !admin.Filters.TOTPConfig.Enabled && len(admin.Filters.WebAuthnCredentials) == 0 {
 			sendAPIResponse(w, r, errRecoveryCodeForbidden, "", http.StatusForbidden)
 			return
 		}
@@ -286,7 +286,7 @@ func saveUserTOTPConfig(username string, r *http.Request, recoveryCodes []datapr
 		if user.CountUnusedRecoveryCodes() < 5 && user.Filters.TOTPConfig.Enabled {
 			user.Filters.RecoveryCodes = recoveryCodes
 		}
-	} else {
+	} else if len(user.Filters.WebAuthnCredentials) == 0 {
 		user.Filters.RecoveryCodes = nil
 	}
 	return dataprovider.UpdateUser(&user, dataprovider.ActionExecutorSelf, util.GetIPFromRemoteAddress(r.RemoteAddr), user.Role)
@@ -307,7 +307,7 @@ func saveAdminTOTPConfig(username string, r *http.Request, recoveryCodes []datap
 		if admin.CountUnusedRecoveryCodes() < 5 && admin.Filters.TOTPConfig.Enabled {
 			admin.Filters.RecoveryCodes = recoveryCodes
 		}
-	} else {
+	} else if len(admin.Filters.WebAuthnCredentials) == 0 {
 		admin.Filters.RecoveryCodes = nil
 	}
 	if admin.Filters.TOTPConfig.Secret == nil || !admin.Filters.TOTPConfig.Secret.IsPlain() {
@@ -0,0 +1,175 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	nextCursorHeader    = "X-Next-Cursor"
+	eventsCursorSortBy  = "timestamp"
+	errInvalidCursorMsg = "invalid cursor"
+)
+
+// listCursor is the opaque cursor returned to the clients for keyset paginated
+// listings. It includes the sort options so we can reject cursors used with
+// a different sort field or order
+type listCursor struct {
+	SortBy string `json:"s"`
+	Order  string `json:"o"`
+	dataprovider.ListCursor
+}
+
+func (c *listCursor) encode() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeListCursor(value, sortBy, order string) (listCursor, error) {
+	var c listCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, util.NewValidationError(errInvalidCursorMsg)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, util.NewValidationError(errInvalidCursorMsg)
+	}
+	if c.SortBy != sortBy || c.Order != order {
+		return c, util.NewValidationError("the cursor does not match the requested sort field and order")
+	}
+	return c, nil
+}
+
+func setNextCursorHeader(w http.ResponseWriter, sortBy, order string, next *dataprovider.ListCursor) {
+	if next == nil {
+		return
+	}
+	c := listCursor{
+		SortBy:     sortBy,
+		Order:      order,
+		ListCursor: *next,
+	}
+	w.Header().Set(nextCursorHeader, c.encode())
+}
+
+// isOffsetPagination returns true if the legacy offset based pagination must be used.
+// It is still supported for backward compatibility
+func isOffsetPagination(limit, offset int) bool {
+	return offset > 0 || limit <= 0
+}
+
+func getListOptions(r *http.Request, limit int, order string, sortFields []string) (dataprovider.ListOptions, error) {
+	opts := dataprovider.ListOptions{
+		Limit:  limit,
+		Order:  order,
+		SortBy: r.URL.Query().Get("sort"),
+	}
+	if opts.SortBy == "" {
+		opts.SortBy = sortFields[0]
+	}
+	if !util.Contains(sortFields, opts.SortBy) {
+		return opts, util.NewValidationError(fmt.Sprintf("invalid sort field %q", opts.SortBy))
+	}
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		c, err := decodeListCursor(value, opts.SortBy, opts.Order)
+		if err != nil {
+			return opts, err
+		}
+		opts.After = &c.ListCursor
+	}
+	return opts, nil
+}
+
+// filterJSONFields removes from the given objects the fields not included in
+// the specified sparse fieldset
+func filterJSONFields(items []map[string]json.RawMessage, fields []string) {
+	for _, item := range items {
+		for k := range item {
+			if !util.Contains(fields, k) {
+				delete(item, k)
+			}
+		}
+	}
+}
+
+// renderListJSON renders the given slice restricted to the fields requested
+// using the "fields" query parameter, if any
+func renderListJSON(w http.ResponseWriter, r *http.Request, data any) {
+	fields := getCommaSeparatedQueryParam(r, "fields")
+	if len(fields) == 0 {
+		render.JSON(w, r, data)
+		return
+	}
+	b, err := json.Marshal(data)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+		return
+	}
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(b, &items); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+		return
+	}
+	filterJSONFields(items, fields)
+	render.JSON(w, r, items)
+}
+
+// renderEvents renders the events returned from the searcher plugin, as JSON array,
+// and sets the cursor for the next page if the page is full
+func renderEvents(w http.ResponseWriter, r *http.Request, data []byte, limit int, order string) {
+	fields := getCommaSeparatedQueryParam(r, "fields")
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+		return
+	}
+	if limit > 0 && len(items) == limit {
+		setNextCursorHeader(w, eventsCursorSortBy, order, getEventCursor(items[len(items)-1]))
+	}
+	if len(fields) == 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data) //nolint:errcheck
+		return
+	}
+	filterJSONFields(items, fields)
+	render.JSON(w, r, items)
+}
+
+func getEventCursor(event map[string]json.RawMessage) *dataprovider.ListCursor {
+	var c dataprovider.ListCursor
+	if err := json.Unmarshal(event["timestamp"], &c.Value); err != nil {
+		return nil
+	}
+	// the ID is a string for the events stored by the plugin and a number for the audit logs
+	if err := json.Unmarshal(event["id"], &c.Key); err != nil {
+		c.Key = string(event["id"])
+	}
+	if c.Key == "" {
+		return nil
+	}
+	return &c
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+)
+
+func getPasswordMigrationStatus(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	render.JSON(w, r, dataprovider.GetPasswordMigrationStatus())
+}
+
+func startPasswordMigrationCheck(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	expireAfterDays := 0
+	if _, ok := r.URL.Query()["expire-after-days"]; ok {
+		val, err := strconv.Atoi(r.URL.Query().Get("expire-after-days"))
+		if err != nil || val < 0 {
+			sendAPIResponse(w, r, err, "Invalid expire-after-days", http.StatusBadRequest)
+			return
+		}
+		expireAfterDays = val
+	}
+	if !dataprovider.StartPasswordMigrationCheck(expireAfterDays) {
+		sendAPIResponse(w, r, nil, "Another check is already in progress", http.StatusConflict)
+		return
+	}
+	sendAPIResponse(w, r, nil, "Check started", http.StatusAccepted)
+}
@@ -113,6 +113,9 @@ func updateUserTransferQuotaUsage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	user, err := dataprovider.GetUserWithGroupSettings(getURLParam(r, "username"), claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
@@ -148,6 +151,9 @@ func doUpdateUserQuotaUsage(w http.ResponseWriter, r *http.Request, username str
 		return
 	}
 	user, err := dataprovider.GetUserWithGroupSettings(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
@@ -191,7 +197,8 @@ func doUpdateFolderQuotaUsage(w http.ResponseWriter, r *http.Request, name strin
 		return
 	}
 	defer common.QuotaScans.RemoveVFolderQuotaScan(folder.Name)
-	err = dataprovider.UpdateVirtualFolderQuota(&folder, usage.UsedQuotaFiles, usage.UsedQuotaSize, mode == quotaUpdateModeReset)
+	err = dataprovider.UpdateVirtualFolderQuota(&vfs.VirtualFolder{BaseVirtualFolder: folder}, usage.UsedQuotaFiles,
+		usage.UsedQuotaSize, mode == quotaUpdateModeReset)
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 	} else {
@@ -210,6 +217,9 @@ func doStartUserQuotaScan(w http.ResponseWriter, r *http.Request, username strin
 		return
 	}
 	user, err := dataprovider.GetUserWithGroupSettings(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
@@ -244,7 +254,7 @@ func doStartFolderQuotaScan(w http.ResponseWriter, r *http.Request, name string)
 
 func doUserQuotaScan(user dataprovider.User) error {
 	defer common.QuotaScans.RemoveUserQuotaScan(user.Username)
-	numFiles, size, err := user.ScanQuota()
+	numFiles, size, err := common.ScanUserQuota(&user)
 	if err != nil {
 		logger.Warn(logSender, "", "error scanning user quota %q: %v", user.Username, err)
 		return err
@@ -265,7 +275,7 @@ func doFolderQuotaScan(folder vfs.BaseVirtualFolder) error {
 		logger.Warn(logSender, "", "error scanning folder %q: %v", folder.Name, err)
 		return err
 	}
-	err = dataprovider.UpdateVirtualFolderQuota(&folder, numFiles, size, true)
+	err = dataprovider.UpdateVirtualFolderQuota(&f, numFiles, size, true)
 	logger.Debug(logSender, "", "virtual folder %q scanned, error: %v", folder.Name, err)
 	return err
 }
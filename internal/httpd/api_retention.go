@@ -43,6 +43,9 @@ func startRetentionCheck(w http.ResponseWriter, r *http.Request) {
 	}
 	username := getURLParam(r, "username")
 	user, err := dataprovider.GetUserWithGroupSettings(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
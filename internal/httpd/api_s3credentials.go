@@ -0,0 +1,114 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/kms"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+var errS3ProtocolDenied = errors.New("the S3 protocol is not allowed for your account")
+
+type s3Credentials struct {
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	CreatedAt       int64  `json:"created_at"`
+}
+
+func getUserS3Credentials(w http.ResponseWriter, r *http.Request) {
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	user, err := dataprovider.UserExists(claims.Username, "")
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if user.Filters.S3Credentials == nil {
+		sendAPIResponse(w, r, nil, "No S3 credentials defined", http.StatusNotFound)
+		return
+	}
+	render.JSON(w, r, s3Credentials{
+		AccessKeyID: user.Username,
+		CreatedAt:   user.Filters.S3Credentials.CreatedAt,
+	})
+}
+
+func generateUserS3Credentials(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	user, err := dataprovider.UserExists(claims.Username, "")
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if util.Contains(user.Filters.DeniedProtocols, common.ProtocolS3) {
+		sendAPIResponse(w, r, errS3ProtocolDenied, "", http.StatusForbidden)
+		return
+	}
+	// any previously generated secret is replaced
+	secret := base64.RawURLEncoding.EncodeToString(util.GenerateRandomBytes(30))
+	user.Filters.S3Credentials = &dataprovider.UserS3Credentials{
+		Secret: kms.NewPlainSecret(secret),
+	}
+	if err := dataprovider.UpdateUser(&user, dataprovider.ActionExecutorSelf, util.GetIPFromRemoteAddress(r.RemoteAddr), user.Role); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	// the secret is returned only once, it cannot be retrieved later
+	render.JSON(w, r, s3Credentials{
+		AccessKeyID:     user.Username,
+		SecretAccessKey: secret,
+		CreatedAt:       user.Filters.S3Credentials.CreatedAt,
+	})
+}
+
+func deleteUserS3Credentials(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	user, err := dataprovider.UserExists(claims.Username, "")
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if user.Filters.S3Credentials == nil {
+		sendAPIResponse(w, r, nil, "No S3 credentials defined", http.StatusNotFound)
+		return
+	}
+	user.Filters.S3Credentials = nil
+	if err := dataprovider.UpdateUser(&user, dataprovider.ActionExecutorSelf, util.GetIPFromRemoteAddress(r.RemoteAddr), user.Role); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, "S3 credentials deleted", http.StatusOK)
+}
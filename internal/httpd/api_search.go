@@ -0,0 +1,148 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+type searchResult struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Size int64  `json:"size"`
+	// Last modification time as unix timestamp in milliseconds
+	LastModified int64 `json:"last_modified"`
+}
+
+type searchResponse struct {
+	Results []searchResult `json:"results"`
+	// True if the limit for the results or for the visited entries was reached
+	Truncated bool `json:"truncated"`
+}
+
+func getSearchInt64Param(r *http.Request, name string) (int64, error) {
+	val := r.URL.Query().Get(name)
+	if val == "" {
+		return 0, nil
+	}
+	result, err := strconv.ParseInt(val, 10, 64)
+	if err != nil || result < 0 {
+		return 0, util.NewValidationError(fmt.Sprintf("invalid %s: %q", name, val))
+	}
+	return result, nil
+}
+
+func getFilesSearchFilters(r *http.Request, connection *Connection) (common.SearchFilters, error) {
+	filters := common.SearchFilters{
+		BaseDir: connection.User.GetCleanedPath(r.URL.Query().Get("path")),
+		Name:    strings.ToLower(strings.TrimSpace(r.URL.Query().Get("name"))),
+		Type:    r.URL.Query().Get("type"),
+		Content: strings.ToLower(strings.TrimSpace(r.URL.Query().Get("content"))),
+		Limit:   common.SearchDefaultLimit,
+	}
+	var err error
+	if filters.MinSize, err = getSearchInt64Param(r, "min_size"); err != nil {
+		return filters, err
+	}
+	if filters.MaxSize, err = getSearchInt64Param(r, "max_size"); err != nil {
+		return filters, err
+	}
+	modifiedAfter, err := getSearchInt64Param(r, "modified_after")
+	if err != nil {
+		return filters, err
+	}
+	if modifiedAfter > 0 {
+		filters.ModifiedAfter = util.GetTimeFromMsecSinceEpoch(modifiedAfter)
+	}
+	modifiedBefore, err := getSearchInt64Param(r, "modified_before")
+	if err != nil {
+		return filters, err
+	}
+	if modifiedBefore > 0 {
+		filters.ModifiedBefore = util.GetTimeFromMsecSinceEpoch(modifiedBefore)
+	}
+	limit, err := getSearchInt64Param(r, "limit")
+	if err != nil {
+		return filters, err
+	}
+	if limit > 0 {
+		filters.Limit = int(min(limit, common.SearchMaxLimit))
+	}
+	return filters, filters.Validate()
+}
+
+func searchUserFiles(connection *Connection, filters *common.SearchFilters) (searchResponse, error) {
+	resp := searchResponse{
+		Results: []searchResult{},
+	}
+	entries, truncated, err := common.SearchFiles(connection.BaseConnection, filters,
+		func(name string) (io.ReadCloser, error) {
+			return connection.getFileReader(name, 0, http.MethodGet, nil)
+		})
+	if err != nil {
+		return resp, err
+	}
+	resp.Truncated = truncated
+	for _, entry := range entries {
+		result := searchResult{
+			Path:         entry.Path,
+			Name:         entry.Name,
+			Type:         common.SearchTypeFile,
+			Size:         entry.Size,
+			LastModified: getFileObjectModTime(entry.ModTime),
+		}
+		if entry.IsDir {
+			result.Type = common.SearchTypeDir
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return resp, nil
+}
+
+func searchUserFilesHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	filters, err := getFilesSearchFilters(r, connection)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	startTime := time.Now()
+	resp, err := searchUserFiles(connection, &filters)
+	if err != nil {
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to search in %q", filters.BaseDir), getMappedStatusCode(err))
+		return
+	}
+	connection.Log(logger.LevelDebug, "search in %q completed, results: %d, truncated: %t, elapsed: %s",
+		filters.BaseDir, len(resp.Results), resp.Truncated, time.Since(startTime))
+	render.JSON(w, r, resp)
+}
@@ -16,15 +16,19 @@ package httpd
 
 import (
 	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-chi/render"
 	"github.com/rs/xid"
@@ -99,6 +103,14 @@ func addShare(w http.ResponseWriter, r *http.Request) {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
+	if err := checkShareEmailVerification(&share); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	if err := checkShareFileRequest(&share, &user); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
 	share.ID = 0
 	share.ShareID = util.GenerateUniqueID()
 	share.LastUseAt = 0
@@ -165,6 +177,14 @@ func updateShare(w http.ResponseWriter, r *http.Request) {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
+	if err := checkShareEmailVerification(&updatedShare); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	if err := checkShareFileRequest(&updatedShare, &user); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
 	err = dataprovider.UpdateShare(&updatedShare, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role)
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
@@ -190,9 +210,124 @@ func deleteShare(w http.ResponseWriter, r *http.Request) {
 	sendAPIResponse(w, r, err, "Share deleted", http.StatusOK)
 }
 
+func getShareDownloads(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	shareID := getURLParam(r, "id")
+	if _, err := dataprovider.ShareExists(shareID, claims.Username); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	filters, err := getShareDownloadSearchParamsFromRequest(r)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if getBoolQueryParam(r, "csv_export") {
+		if !dataprovider.IsShareAnalyticsEnabled() {
+			sendAPIResponse(w, r, util.NewMethodDisabledError("share analytics are disabled"), "", http.StatusForbidden)
+			return
+		}
+		filters.Limit = 100
+		if err := exportShareDownloads(w, shareID, &filters); err != nil {
+			panic(http.ErrAbortHandler)
+		}
+		return
+	}
+	downloads, err := dataprovider.SearchShareDownloads(shareID, &filters)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	render.JSON(w, r, downloads)
+}
+
+func getShareDownloadStats(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	shareID := getURLParam(r, "id")
+	if _, err := dataprovider.ShareExists(shareID, claims.Username); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	stats, err := dataprovider.GetShareDownloadStats(shareID)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	render.JSON(w, r, stats)
+}
+
+func getShareDownloadSearchParamsFromRequest(r *http.Request) (dataprovider.ShareDownloadSearch, error) {
+	s := dataprovider.ShareDownloadSearch{
+		Limit: 100,
+		Order: dataprovider.OrderDESC,
+	}
+	common, err := getCommonSearchParamsFromRequest(r)
+	if err != nil {
+		return s, err
+	}
+	s.Limit = common.Limit
+	if common.Order == 1 {
+		s.Order = dataprovider.OrderASC
+	}
+	s.StartTimestamp = common.StartTimestamp
+	s.EndTimestamp = common.EndTimestamp
+	s.IP = common.IP
+	if common.FromID != "" {
+		fromID, err := strconv.ParseInt(common.FromID, 10, 64)
+		if err != nil {
+			return s, util.NewValidationError(fmt.Sprintf("invalid from_id: %v", err))
+		}
+		s.FromID = fromID
+	}
+	return s, nil
+}
+
+func exportShareDownloads(w http.ResponseWriter, shareID string, filters *dataprovider.ShareDownloadSearch) error {
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=share-downloads-%s-%s.csv", shareID,
+		time.Now().Format("2006-01-02T15-04-05")))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Accept-Ranges", "none")
+	w.WriteHeader(http.StatusOK)
+
+	download := dataprovider.ShareDownload{}
+	csvWriter := csv.NewWriter(w)
+	err := csvWriter.Write(download.GetCSVHeader())
+	if err != nil {
+		return err
+	}
+	for {
+		results, err := dataprovider.SearchShareDownloads(shareID, filters)
+		if err != nil {
+			return err
+		}
+		for idx := range results {
+			if err := csvWriter.Write(results[idx].GetCSVData()); err != nil {
+				return err
+			}
+		}
+		if len(results) == 0 || len(results) < filters.Limit {
+			break
+		}
+		filters.FromID = results[len(results)-1].ID
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
 func (s *httpdServer) readBrowsableShareContents(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
-	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite}
+	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite,
+		dataprovider.ShareScopeView}
 	share, connection, err := s.checkPublicShare(w, r, validScopes)
 	if err != nil {
 		return
@@ -257,6 +392,7 @@ func (s *httpdServer) downloadBrowsableSharedFile(w http.ResponseWriter, r *http
 
 	inline := r.URL.Query().Get("inline") != ""
 	dataprovider.UpdateShareLastUse(&share, 1) //nolint:errcheck
+	w = wrapShareDownloadWriter(w, r)
 	if status, err := downloadFile(w, r, connection, name, info, inline, &share); err != nil {
 		dataprovider.UpdateShareLastUse(&share, -1) //nolint:errcheck
 		resp := apiResponse{
@@ -268,7 +404,9 @@ func (s *httpdServer) downloadBrowsableSharedFile(w http.ResponseWriter, r *http
 			ctx = context.WithValue(ctx, render.StatusCtxKey, status)
 		}
 		render.JSON(w, r.WithContext(ctx), resp)
+		return
 	}
+	recordShareDownload(w, r, &share, name)
 }
 
 func (s *httpdServer) downloadFromShare(w http.ResponseWriter, r *http.Request) {
@@ -298,7 +436,18 @@ func (s *httpdServer) downloadFromShare(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
+	var opts archiveOptions
+	if compress {
+		opts, err = getArchiveOptions(r)
+		if err != nil {
+			sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+			return
+		}
+	}
+
 	dataprovider.UpdateShareLastUse(&share, 1) //nolint:errcheck
+	sharedPaths := append([]string(nil), share.Paths...)
+	w = wrapShareDownloadWriter(w, r)
 	if compress {
 		transferQuota := connection.GetTransferQuota()
 		if !transferQuota.HasDownloadSpace() {
@@ -313,8 +462,10 @@ func (s *httpdServer) downloadFromShare(w http.ResponseWriter, r *http.Request)
 			baseDir = share.Paths[0]
 			share.Paths[0] = "/"
 		}
-		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"share-%v.zip\"", share.Name))
-		renderCompressedFiles(w, connection, baseDir, share.Paths, &share)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"share-%v.%s\"", share.Name,
+			opts.format))
+		renderCompressedFiles(w, r, connection, baseDir, share.Paths, &share, opts)
+		recordShareDownload(w, r, &share, sharedPaths...)
 		return
 	}
 	if status, err := downloadFile(w, r, connection, share.Paths[0], info, false, &share); err != nil {
@@ -328,7 +479,9 @@ func (s *httpdServer) downloadFromShare(w http.ResponseWriter, r *http.Request)
 			ctx = context.WithValue(ctx, render.StatusCtxKey, status)
 		}
 		render.JSON(w, r.WithContext(ctx), resp)
+		return
 	}
+	recordShareDownload(w, r, &share, sharedPaths...)
 }
 
 func (s *httpdServer) uploadFileToShare(w http.ResponseWriter, r *http.Request) {
@@ -341,8 +494,24 @@ func (s *httpdServer) uploadFileToShare(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return
 	}
-	filePath := util.CleanPath(path.Join(share.Paths[0], name))
-	expectedPrefix := share.Paths[0]
+	uploader, err := getShareUploader(r, &share)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	if err = common.Connections.Add(connection); err != nil {
+		sendAPIResponse(w, r, err, "Unable to add connection", http.StatusTooManyRequests)
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	uploadDir, err := getShareUploadDir(connection, &share, uploader)
+	if err != nil {
+		sendAPIResponse(w, r, err, "Unable to create the upload directory", getMappedStatusCode(err))
+		return
+	}
+	filePath := util.CleanPath(path.Join(uploadDir, name))
+	expectedPrefix := uploadDir
 	if !strings.HasSuffix(expectedPrefix, "/") {
 		expectedPrefix += "/"
 	}
@@ -352,14 +521,11 @@ func (s *httpdServer) uploadFileToShare(w http.ResponseWriter, r *http.Request)
 	}
 	dataprovider.UpdateShareLastUse(&share, 1) //nolint:errcheck
 
-	if err = common.Connections.Add(connection); err != nil {
-		sendAPIResponse(w, r, err, "Unable to add connection", http.StatusTooManyRequests)
-		return
-	}
-	defer common.Connections.Remove(connection.GetID())
 	if err := doUploadFile(w, r, connection, filePath); err != nil {
 		dataprovider.UpdateShareLastUse(&share, -1) //nolint:errcheck
+		return
 	}
+	fileRequestNotifier.add(&share, uploader, uploadDir, share.GetRelativePath(filePath))
 }
 
 func (s *httpdServer) uploadFilesToShare(w http.ResponseWriter, r *http.Request) {
@@ -371,6 +537,11 @@ func (s *httpdServer) uploadFilesToShare(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		return
 	}
+	uploader, err := getShareUploader(r, &share)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
 
 	transferQuota := connection.GetTransferQuota()
 	if !transferQuota.HasUploadSpace() {
@@ -408,15 +579,27 @@ func (s *httpdServer) uploadFilesToShare(w http.ResponseWriter, r *http.Request)
 			return
 		}
 	}
+	uploadDir, err := getShareUploadDir(connection, &share, uploader)
+	if err != nil {
+		sendAPIResponse(w, r, err, "Unable to create the upload directory", getMappedStatusCode(err))
+		return
+	}
 	dataprovider.UpdateShareLastUse(&share, len(files)) //nolint:errcheck
 
-	numUploads := doUploadFiles(w, r, connection, share.Paths[0], files)
+	numUploads := doUploadFiles(w, r, connection, uploadDir, files)
 	if numUploads != len(files) {
 		dataprovider.UpdateShareLastUse(&share, numUploads-len(files)) //nolint:errcheck
 	}
+	uploaded := make([]string, 0, numUploads)
+	for _, f := range files[:numUploads] {
+		uploaded = append(uploaded, share.GetRelativePath(path.Join(uploadDir, path.Base(util.CleanPath(f.Filename)))))
+	}
+	fileRequestNotifier.add(&share, uploader, uploadDir, uploaded...)
 }
 
-func (s *httpdServer) checkWebClientShareCredentials(w http.ResponseWriter, r *http.Request, share *dataprovider.Share) error {
+// checkWebClientShareCredentials checks the share token and returns the verified
+// email, if any
+func (s *httpdServer) checkWebClientShareCredentials(w http.ResponseWriter, r *http.Request, share *dataprovider.Share) (string, error) {
 	doRedirect := func() {
 		redirectURL := path.Join(webClientPubSharesPath, share.ShareID, fmt.Sprintf("login?next=%s", url.QueryEscape(r.RequestURI)))
 		http.Redirect(w, r, redirectURL, http.StatusFound)
@@ -425,19 +608,19 @@ func (s *httpdServer) checkWebClientShareCredentials(w http.ResponseWriter, r *h
 	token, err := jwtauth.VerifyRequest(s.tokenAuth, r, jwtauth.TokenFromCookie)
 	if err != nil || token == nil {
 		doRedirect()
-		return errInvalidToken
+		return "", errInvalidToken
 	}
 	if !util.Contains(token.Audience(), tokenAudienceWebShare) {
 		logger.Debug(logSender, "", "invalid token audience for share %q", share.ShareID)
 		doRedirect()
-		return errInvalidToken
+		return "", errInvalidToken
 	}
 	if tokenValidationMode != tokenValidationNoIPMatch {
 		ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
 		if !util.Contains(token.Audience(), ipAddr) {
 			logger.Debug(logSender, "", "token for share %q is not valid for the ip address %q", share.ShareID, ipAddr)
 			doRedirect()
-			return errInvalidToken
+			return "", errInvalidToken
 		}
 	}
 	ctx := jwtauth.NewContext(r.Context(), token, nil)
@@ -445,9 +628,15 @@ func (s *httpdServer) checkWebClientShareCredentials(w http.ResponseWriter, r *h
 	if err != nil || claims.Username != share.ShareID {
 		logger.Debug(logSender, "", "token not valid for share %q", share.ShareID)
 		doRedirect()
-		return errInvalidToken
+		return "", errInvalidToken
 	}
-	return nil
+	if share.EmailVerification.IsEnabled() && !share.EmailVerification.IsEmailAllowed(claims.ShareEmail) {
+		logger.Debug(logSender, "", "token for share %q has no allowed verified email, email: %q", share.ShareID,
+			claims.ShareEmail)
+		doRedirect()
+		return "", errInvalidToken
+	}
+	return claims.ShareEmail, nil
 }
 
 func (s *httpdServer) checkPublicShare(w http.ResponseWriter, r *http.Request, validScopes []dataprovider.ShareScope,
@@ -482,12 +671,22 @@ func (s *httpdServer) checkPublicShare(w http.ResponseWriter, r *http.Request, v
 		renderError(err, "", getRespStatus(err))
 		return share, nil, err
 	}
-	if share.Password != "" {
+	if share.Password != "" || share.EmailVerification.IsEnabled() {
 		if isWebClient {
-			if err := s.checkWebClientShareCredentials(w, r, &share); err != nil {
+			email, err := s.checkWebClientShareCredentials(w, r, &share)
+			if err != nil {
 				handleDefenderEventLoginFailed(ipAddr, err) //nolint:errcheck
 				return share, nil, dataprovider.ErrInvalidCredentials
 			}
+			if email != "" {
+				logger.Info(logSender, "", "share %q accessed by verified email %q, ip: %q, method: %s, uri: %q",
+					share.ShareID, email, ipAddr, r.Method, r.RequestURI)
+			}
+		} else if share.EmailVerification.IsEnabled() {
+			err := util.NewI18nError(errors.New("this share requires email verification, use the WebClient"),
+				util.I18nErrorShareVerificationRequired)
+			renderError(err, "", http.StatusForbidden)
+			return share, nil, err
 		} else {
 			_, password, ok := r.BasicAuth()
 			if !ok {
@@ -509,6 +708,9 @@ func (s *httpdServer) checkPublicShare(w http.ResponseWriter, r *http.Request, v
 		renderError(err, "", getRespStatus(err))
 		return share, nil, err
 	}
+	if isReadOnlyBinding(r) {
+		user.SetReadOnlyPermissions()
+	}
 	connID := xid.New().String()
 	connection := &Connection{
 		BaseConnection: common.NewBaseConnection(connID, common.ProtocolHTTPShare, util.GetHTTPLocalAddress(r),
@@ -519,6 +721,29 @@ func (s *httpdServer) checkPublicShare(w http.ResponseWriter, r *http.Request, v
 	return share, connection, nil
 }
 
+// wrapShareDownloadWriter returns a response writer tracking the bytes sent to
+// the share recipients, the writer is not wrapped if share analytics are disabled
+func wrapShareDownloadWriter(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	if !dataprovider.IsShareAnalyticsEnabled() {
+		return w
+	}
+	return middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+}
+
+// recordShareDownload records a completed download from the specified share.
+// The response writer must be wrapped using wrapShareDownloadWriter
+func recordShareDownload(w http.ResponseWriter, r *http.Request, share *dataprovider.Share, files ...string) {
+	ww, ok := w.(middleware.WrapResponseWriter)
+	if !ok || r.Method == http.MethodHead {
+		return
+	}
+	if status := ww.Status(); status != http.StatusOK && status != http.StatusPartialContent {
+		return
+	}
+	dataprovider.RecordShareDownload(share, util.GetIPFromRemoteAddress(r.RemoteAddr), strings.Join(files, ","),
+		int64(ww.BytesWritten()))
+}
+
 func getUserForShare(share dataprovider.Share) (dataprovider.User, error) {
 	user, err := dataprovider.GetUserWithGroupSettings(share.Username, "")
 	if err != nil {
@@ -566,6 +791,26 @@ func validateBrowsableShare(share dataprovider.Share, connection *Connection) er
 	return nil
 }
 
+// checkSharePreview returns an error if the specified file cannot be served
+// for the share scope. View only shares can serve files displayable inline
+// by the browsers, SVG images are excluded since they can contain scripts
+func checkSharePreview(share *dataprovider.Share, name string) error {
+	if share.Scope != dataprovider.ShareScopeView {
+		return nil
+	}
+	ctype, _, _ := strings.Cut(mime.TypeByExtension(path.Ext(name)), ";")
+	switch {
+	case ctype == "application/pdf", strings.HasPrefix(ctype, "audio/"), strings.HasPrefix(ctype, "video/"):
+		return nil
+	case strings.HasPrefix(ctype, "image/") && ctype != "image/svg+xml":
+		return nil
+	}
+	return util.NewI18nError(
+		util.NewValidationError(fmt.Sprintf("the share is view only, unable to preview %q", path.Base(name))),
+		util.I18nErrorSharePreviewOnly,
+	)
+}
+
 func getBrowsableSharedPath(shareBasePath string, r *http.Request) (string, error) {
 	name := util.CleanPath(path.Join(shareBasePath, r.URL.Query().Get("path")))
 	if shareBasePath == "/" {
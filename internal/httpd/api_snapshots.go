@@ -0,0 +1,130 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+)
+
+func getSnapshotRespStatus(err error) int {
+	if errors.Is(err, common.ErrSnapshotInProgress) {
+		return http.StatusConflict
+	}
+	return getRespStatus(err)
+}
+
+func getUserSnapshots(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	user, err := dataprovider.UserExists(getURLParam(r, "username"), claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	snapshots, err := common.GetSnapshots(user.Username)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getSnapshotRespStatus(err))
+		return
+	}
+	render.JSON(w, r, snapshots)
+}
+
+func createUserSnapshot(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	user, err := dataprovider.UserExists(getURLParam(r, "username"), claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	snapshot, err := common.CreateSnapshot(user.Username)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getSnapshotRespStatus(err))
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/%s/%s", snapshotsBasePath, user.Username, snapshot.ID))
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, snapshot)
+}
+
+func deleteUserSnapshot(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	user, err := dataprovider.UserExists(getURLParam(r, "username"), claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if err := common.DeleteSnapshot(user.Username, getURLParam(r, "id")); err != nil {
+		sendAPIResponse(w, r, err, "", getSnapshotRespStatus(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, "Snapshot deleted", http.StatusOK)
+}
+
+func restoreUserSnapshot(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	user, err := dataprovider.UserExists(getURLParam(r, "username"), claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	virtualPath := r.URL.Query().Get("path")
+	if virtualPath == "" {
+		virtualPath = "/"
+	}
+	numFiles, size, err := common.RestoreSnapshot(user.Username, getURLParam(r, "id"), virtualPath)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getSnapshotRespStatus(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, fmt.Sprintf("Snapshot restored, files: %d, size: %d", numFiles, size), http.StatusOK)
+}
@@ -0,0 +1,234 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// Supported sync modes
+const (
+	// the client is the source of truth, files missing on the client must be deleted
+	// on the server
+	syncModeUpload = "upload"
+	// the server is the source of truth, files missing on the server must be deleted
+	// on the client
+	syncModeDownload = "download"
+	// the most recent version wins, nothing is deleted
+	syncModeBidirectional = "bidirectional"
+)
+
+// modification times are compared with a second resolution since some
+// storage backends does not preserve sub-second precision
+const syncMtimeTolerance = time.Second
+
+type syncManifestEntry struct {
+	// Path relative to the sync root
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// Last modification time as unix timestamp in milliseconds
+	LastModified int64 `json:"last_modified"`
+	// Optional SHA256 hash, hex encoded. If provided, it is used to check files
+	// with the same size but different modification times
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+type syncManifest struct {
+	Mode  string              `json:"mode"`
+	Files []syncManifestEntry `json:"files"`
+}
+
+func (m *syncManifest) validate() error {
+	if m.Mode == "" {
+		m.Mode = syncModeBidirectional
+	}
+	if !util.Contains([]string{syncModeUpload, syncModeDownload, syncModeBidirectional}, m.Mode) {
+		return util.NewValidationError(fmt.Sprintf("invalid sync mode %q", m.Mode))
+	}
+	for idx := range m.Files {
+		entry := &m.Files[idx]
+		entry.Path = strings.TrimPrefix(util.CleanPath(entry.Path), "/")
+		if entry.Path == "" {
+			return util.NewValidationError("invalid empty path in the sync manifest")
+		}
+		if entry.Size < 0 {
+			return util.NewValidationError(fmt.Sprintf("invalid size for path %q", entry.Path))
+		}
+		entry.SHA256 = strings.ToLower(strings.TrimSpace(entry.SHA256))
+	}
+	return nil
+}
+
+type syncDelta struct {
+	// Files to upload from the client to the server
+	Upload []string `json:"upload"`
+	// Files to download from the server to the client
+	Download []string `json:"download"`
+	// Files to delete, on the server for the upload mode, on the client for the download mode
+	Delete []string `json:"delete"`
+}
+
+func (d *syncDelta) sort() {
+	sort.Strings(d.Upload)
+	sort.Strings(d.Download)
+	sort.Strings(d.Delete)
+}
+
+func getUserSyncDelta(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxSyncManifestSize)
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	var manifest syncManifest
+	if err := render.DecodeJSON(r.Body, &manifest); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	if err := manifest.validate(); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	baseDir := connection.User.GetCleanedPath(r.URL.Query().Get("path"))
+	delta, err := computeSyncDelta(connection, baseDir, &manifest)
+	if err != nil {
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to compute the sync delta for %q", baseDir),
+			getMappedStatusCode(err))
+		return
+	}
+	render.JSON(w, r, delta)
+}
+
+func computeSyncDelta(conn *Connection, baseDir string, manifest *syncManifest) (syncDelta, error) {
+	delta := syncDelta{
+		Upload:   []string{},
+		Download: []string{},
+		Delete:   []string{},
+	}
+	serverFiles := make(map[string]os.FileInfo)
+	if err := getSyncServerFiles(conn, baseDir, "", serverFiles); err != nil {
+		return delta, err
+	}
+	for _, entry := range manifest.Files {
+		info, ok := serverFiles[entry.Path]
+		if !ok {
+			if manifest.Mode == syncModeDownload {
+				delta.Delete = append(delta.Delete, entry.Path)
+			} else {
+				delta.Upload = append(delta.Upload, entry.Path)
+			}
+			continue
+		}
+		delete(serverFiles, entry.Path)
+		if isSyncEntryEqual(conn, path.Join(baseDir, entry.Path), &entry, info) {
+			continue
+		}
+		switch manifest.Mode {
+		case syncModeUpload:
+			delta.Upload = append(delta.Upload, entry.Path)
+		case syncModeDownload:
+			delta.Download = append(delta.Download, entry.Path)
+		default:
+			if util.GetTimeFromMsecSinceEpoch(entry.LastModified).After(info.ModTime()) {
+				delta.Upload = append(delta.Upload, entry.Path)
+			} else {
+				delta.Download = append(delta.Download, entry.Path)
+			}
+		}
+	}
+	for p := range serverFiles {
+		if manifest.Mode == syncModeUpload {
+			delta.Delete = append(delta.Delete, p)
+		} else {
+			delta.Download = append(delta.Download, p)
+		}
+	}
+	delta.sort()
+	conn.Log(logger.LevelDebug, "sync delta computed for dir %q, mode %q, manifest entries: %d, upload: %d, download: %d, delete: %d",
+		baseDir, manifest.Mode, len(manifest.Files), len(delta.Upload), len(delta.Download), len(delta.Delete))
+	return delta, nil
+}
+
+// getSyncServerFiles recursively adds the regular files inside the specified
+// directory to the result map. The map keys are the paths relative to the sync root
+func getSyncServerFiles(conn *Connection, baseDir, relativePath string, result map[string]os.FileInfo) error {
+	contents, err := conn.ReadDir(path.Join(baseDir, relativePath))
+	if err != nil {
+		return err
+	}
+	for _, info := range contents {
+		p := path.Join(relativePath, info.Name())
+		if info.IsDir() {
+			if err := getSyncServerFiles(conn, baseDir, p, result); err != nil {
+				return err
+			}
+			continue
+		}
+		if info.Mode().IsRegular() {
+			result[p] = info
+		}
+	}
+	return nil
+}
+
+func isSyncEntryEqual(conn *Connection, name string, entry *syncManifestEntry, info os.FileInfo) bool {
+	if entry.Size != info.Size() {
+		return false
+	}
+	mtimeDiff := util.GetTimeFromMsecSinceEpoch(entry.LastModified).Sub(info.ModTime())
+	if mtimeDiff.Abs() < syncMtimeTolerance {
+		return true
+	}
+	if entry.SHA256 == "" {
+		return false
+	}
+	hash, err := getSyncFileHash(conn, name)
+	if err != nil {
+		conn.Log(logger.LevelDebug, "unable to compute hash for file %q: %v", name, err)
+		return false
+	}
+	return hash == entry.SHA256
+}
+
+func getSyncFileHash(conn *Connection, name string) (string, error) {
+	reader, err := conn.getFileReader(name, 0, http.MethodGet, nil)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -46,12 +46,28 @@ func getUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := dataprovider.GetUsers(limit, offset, order, claims.Role)
-	if err == nil {
-		render.JSON(w, r, users)
-	} else {
-		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+	if isOffsetPagination(limit, offset) {
+		users, err := dataprovider.GetUsers(limit, offset, order, claims.Role)
+		if err != nil {
+			sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+			return
+		}
+		renderListJSON(w, r, claims.filterUsersByScope(users))
+		return
+	}
+	opts, err := getListOptions(r, limit, order, dataprovider.GetUserSortFields())
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	opts.Role = claims.Role
+	users, next, err := dataprovider.ListUsers(opts)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
 	}
+	setNextCursorHeader(w, opts.SortBy, opts.Order, next)
+	renderListJSON(w, r, claims.filterUsersByScope(users))
 }
 
 func getUserByUsername(w http.ResponseWriter, r *http.Request) {
@@ -67,10 +83,14 @@ func getUserByUsername(w http.ResponseWriter, r *http.Request) {
 
 func renderUser(w http.ResponseWriter, r *http.Request, username string, claims *jwtTokenClaims, status int) {
 	user, err := dataprovider.UserExists(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
 	}
+	w.Header().Set("ETag", getUserETag(&user))
 	if hideConfidentialData(claims, r) {
 		user.PrepareForRendering()
 	}
@@ -107,11 +127,11 @@ func addUser(w http.ResponseWriter, r *http.Request) {
 	if claims.Role != "" {
 		user.Role = claims.Role
 	}
-	user.LastPasswordChange = 0
-	user.Filters.RecoveryCodes = nil
-	user.Filters.TOTPConfig = dataprovider.UserTOTPConfig{
-		Enabled: false,
+	if err := claims.setUserScope(&user); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
 	}
+	prepareUserForAdd(&user)
 	err = dataprovider.AddUser(&user, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role)
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
@@ -121,6 +141,41 @@ func addUser(w http.ResponseWriter, r *http.Request) {
 	renderUser(w, r, user.Username, &claims, http.StatusCreated)
 }
 
+func addUsersFromTemplate(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	var template dataprovider.UserTemplate
+	err = render.DecodeJSON(r.Body, &template)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	users, err := template.GetUsers(claims.Role)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	for idx := range users {
+		if err := claims.setUserScope(&users[idx]); err != nil {
+			sendAPIResponse(w, r, err, "", getRespStatus(err))
+			return
+		}
+	}
+	for idx := range users {
+		if err := dataprovider.AddUser(&users[idx], claims.Username, ipAddr, claims.Role); err != nil {
+			sendAPIResponse(w, r, err, fmt.Sprintf("Unable to add user %q", users[idx].Username), getRespStatus(err))
+			return
+		}
+	}
+	sendAPIResponse(w, r, nil, fmt.Sprintf("%d users added", len(users)), http.StatusCreated)
+}
+
 func disableUser2FA(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	claims, err := getTokenClaims(r)
@@ -130,6 +185,9 @@ func disableUser2FA(w http.ResponseWriter, r *http.Request) {
 	}
 	username := getURLParam(r, "username")
 	user, err := dataprovider.UserExists(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
@@ -138,6 +196,7 @@ func disableUser2FA(w http.ResponseWriter, r *http.Request) {
 	user.Filters.TOTPConfig = dataprovider.UserTOTPConfig{
 		Enabled: false,
 	}
+	user.Filters.WebAuthnCredentials = nil
 	if err := dataprovider.UpdateUser(&user, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role); err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
@@ -164,6 +223,12 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	user, err := dataprovider.UserExists(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
+	if err == nil {
+		err = checkIfMatch(r, getUserETag(&user))
+	}
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
 		return
@@ -176,19 +241,14 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
 		return
 	}
-	updatedUser.ID = user.ID
-	updatedUser.Username = user.Username
-	updatedUser.Filters.RecoveryCodes = user.Filters.RecoveryCodes
-	updatedUser.Filters.TOTPConfig = user.Filters.TOTPConfig
-	updatedUser.LastPasswordChange = user.LastPasswordChange
-	updatedUser.SetEmptySecretsIfNil()
-	updateEncryptedSecrets(&updatedUser.FsConfig, user.FsConfig.S3Config.AccessSecret, user.FsConfig.AzBlobConfig.AccountKey,
-		user.FsConfig.AzBlobConfig.SASURL, user.FsConfig.GCSConfig.Credentials, user.FsConfig.CryptConfig.Passphrase,
-		user.FsConfig.SFTPConfig.Password, user.FsConfig.SFTPConfig.PrivateKey, user.FsConfig.SFTPConfig.KeyPassphrase,
-		user.FsConfig.HTTPConfig.Password, user.FsConfig.HTTPConfig.APIKey)
+	prepareUserForUpdate(&updatedUser, &user)
 	if claims.Role != "" {
 		updatedUser.Role = claims.Role
 	}
+	if err := claims.setUserScope(&updatedUser); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
 	err = dataprovider.UpdateUser(&updatedUser, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role)
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
@@ -200,6 +260,46 @@ func updateUser(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// prepareUserForAdd resets the fields that cannot be set when adding a user
+// and replaces the placeholders
+func prepareUserForAdd(user *dataprovider.User) {
+	user.LastPasswordChange = 0
+	user.Filters.Archive = nil
+	user.Filters.LDAPSync = nil
+	user.Filters.RecoveryCodes = nil
+	user.Filters.TOTPConfig = dataprovider.UserTOTPConfig{
+		Enabled: false,
+	}
+	user.Filters.WebAuthnCredentials = nil
+	user.Filters.S3Credentials = nil
+	user.ApplyTemplate(dataprovider.UserTemplateFields{
+		Username:   user.Username,
+		Password:   user.Password,
+		PublicKeys: user.PublicKeys,
+	})
+}
+
+// prepareUserForUpdate preserves, in the updated user, the fields that cannot
+// be modified using the update API and the unchanged secrets
+func prepareUserForUpdate(updatedUser, user *dataprovider.User) {
+	updatedUser.ID = user.ID
+	updatedUser.Username = user.Username
+	updatedUser.Filters.RecoveryCodes = user.Filters.RecoveryCodes
+	updatedUser.Filters.TOTPConfig = user.Filters.TOTPConfig
+	updatedUser.Filters.WebAuthnCredentials = user.Filters.WebAuthnCredentials
+	updatedUser.Filters.S3Credentials = user.Filters.S3Credentials
+	updatedUser.Filters.Archive = user.Filters.Archive
+	updatedUser.Filters.LDAPSync = user.Filters.LDAPSync
+	updatedUser.LastPasswordChange = user.LastPasswordChange
+	updatedUser.SetEmptySecretsIfNil()
+	updateEncryptedSecrets(&updatedUser.FsConfig, user.FsConfig.S3Config.AccessSecret, user.FsConfig.AzBlobConfig.AccountKey,
+		user.FsConfig.AzBlobConfig.SASURL, user.FsConfig.GCSConfig.Credentials, user.FsConfig.CryptConfig.Passphrase,
+		user.FsConfig.SFTPConfig.Password, user.FsConfig.SFTPConfig.PrivateKey, user.FsConfig.SFTPConfig.KeyPassphrase,
+		user.FsConfig.HTTPConfig.Password, user.FsConfig.HTTPConfig.APIKey)
+	updateUnionFsEncryptedSecrets(&updatedUser.FsConfig, user.FsConfig.UnionConfig)
+	updateCryptFsOldPassphrases(&updatedUser.FsConfig, user.FsConfig)
+}
+
 func deleteUser(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	claims, err := getTokenClaims(r)
@@ -208,6 +308,16 @@ func deleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	username := getURLParam(r, "username")
+	if len(claims.UserSelectors) > 0 {
+		user, err := dataprovider.UserExists(username, claims.Role)
+		if err == nil {
+			err = claims.checkUserScope(&user)
+		}
+		if err != nil {
+			sendAPIResponse(w, r, err, "", getRespStatus(err))
+			return
+		}
+	}
 	err = dataprovider.DeleteUser(username, claims.Username, util.GetIPFromRemoteAddress(r.RemoteAddr), claims.Role)
 	if err != nil {
 		sendAPIResponse(w, r, err, "", getRespStatus(err))
@@ -318,6 +428,54 @@ func updateSFTPFsEncryptedSecrets(fsConfig *vfs.Filesystem, currentSFTPPassword,
 	}
 }
 
+// updateUnionFsEncryptedSecrets keeps the current secrets for the union members
+// that are not changed, the members are matched by position and provider
+func updateUnionFsEncryptedSecrets(fsConfig *vfs.Filesystem, current vfs.UnionFsConfig) {
+	if fsConfig.Provider != vfs.UnionFilesystemProvider {
+		return
+	}
+	for idx := range fsConfig.UnionConfig.Members {
+		if idx >= len(current.Members) {
+			break
+		}
+		member := &fsConfig.UnionConfig.Members[idx]
+		currentFs := current.Members[idx].FsConfig
+		if member.FsConfig.Provider != currentFs.Provider {
+			continue
+		}
+		currentFs.SetEmptySecretsIfNil()
+		updateEncryptedSecrets(&member.FsConfig, currentFs.S3Config.AccessSecret, currentFs.AzBlobConfig.AccountKey,
+			currentFs.AzBlobConfig.SASURL, currentFs.GCSConfig.Credentials, currentFs.CryptConfig.Passphrase,
+			currentFs.SFTPConfig.Password, currentFs.SFTPConfig.PrivateKey, currentFs.SFTPConfig.KeyPassphrase,
+			currentFs.HTTPConfig.Password, currentFs.HTTPConfig.APIKey)
+		updateCryptFsOldPassphrases(&member.FsConfig, currentFs)
+	}
+}
+
+// updateCryptFsOldPassphrases keeps the old passphrases for a local encrypted filesystem
+// and retains the current passphrase, if changed, so the existing files remain readable.
+// The old passphrases are removed once all the files are re-encrypted
+func updateCryptFsOldPassphrases(fsConfig *vfs.Filesystem, current vfs.Filesystem) {
+	if fsConfig.Provider != sdk.CryptedFilesystemProvider || current.Provider != sdk.CryptedFilesystemProvider {
+		return
+	}
+	fsConfig.CryptConfig.OldPassphrases = nil
+	for _, passphrase := range current.CryptConfig.OldPassphrases {
+		if passphrase != nil {
+			fsConfig.CryptConfig.OldPassphrases = append(fsConfig.CryptConfig.OldPassphrases, passphrase.Clone())
+		}
+	}
+	currentPassphrase := current.CryptConfig.Passphrase
+	if !fsConfig.CryptConfig.Passphrase.IsPlain() || currentPassphrase == nil || currentPassphrase.IsEmpty() {
+		return
+	}
+	decrypted := currentPassphrase.Clone()
+	if err := decrypted.TryDecrypt(); err == nil && decrypted.GetPayload() == fsConfig.CryptConfig.Passphrase.GetPayload() {
+		return
+	}
+	fsConfig.CryptConfig.OldPassphrases = append(fsConfig.CryptConfig.OldPassphrases, currentPassphrase.Clone())
+}
+
 func updateHTTPFsEncryptedSecrets(fsConfig *vfs.Filesystem, currentHTTPPassword, currentHTTPAPIKey *kms.Secret) {
 	if fsConfig.HTTPConfig.Password.IsNotPlainAndNotEmpty() {
 		fsConfig.HTTPConfig.Password = currentHTTPPassword
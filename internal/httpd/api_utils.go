@@ -17,6 +17,9 @@ package httpd
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -26,6 +29,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -44,6 +48,7 @@ import (
 	"github.com/drakkan/sftpgo/v2/internal/plugin"
 	"github.com/drakkan/sftpgo/v2/internal/smtp"
 	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
 )
 
 type pwdChange struct {
@@ -108,6 +113,9 @@ func getRespStatus(err error) int {
 	if errors.Is(err, dataprovider.ErrDuplicatedKey) || errors.Is(err, dataprovider.ErrForeignKeyViolated) {
 		return http.StatusConflict
 	}
+	if errors.Is(err, errPreconditionFailed) {
+		return http.StatusPreconditionFailed
+	}
 	return http.StatusInternalServerError
 }
 
@@ -125,6 +133,8 @@ func getMappedStatusCode(err error) int {
 		statusCode = http.StatusRequestEntityTooLarge
 	case errors.Is(err, common.ErrOpUnsupported):
 		statusCode = http.StatusBadRequest
+	case errors.Is(err, vfs.ErrRestoreInProgress):
+		statusCode = http.StatusServiceUnavailable
 	default:
 		if _, ok := err.(*http.MaxBytesError); ok {
 			statusCode = http.StatusRequestEntityTooLarge
@@ -144,6 +154,81 @@ func getURLParam(r *http.Request, key string) string {
 	return unescaped
 }
 
+func computeETag(data []byte) string {
+	h := sha256.Sum256(data)
+	return fmt.Sprintf("%q", hex.EncodeToString(h[:16]))
+}
+
+func getUserETag(user *dataprovider.User) string {
+	return computeETag([]byte(fmt.Sprintf("user:%d:%d", user.ID, user.UpdatedAt)))
+}
+
+func getGroupETag(group *dataprovider.Group) string {
+	return computeETag([]byte(fmt.Sprintf("group:%d:%d", group.ID, group.UpdatedAt)))
+}
+
+func getEventRuleETag(rule *dataprovider.EventRule) string {
+	return computeETag([]byte(fmt.Sprintf("rule:%d:%d", rule.ID, rule.UpdatedAt)))
+}
+
+// getFolderETag returns an ETag for the folder configuration, folders have no
+// update timestamp, so the ETag is computed from the fields that can be updated
+func getFolderETag(folder *vfs.BaseVirtualFolder) string {
+	f := vfs.BaseVirtualFolder{
+		ID:          folder.ID,
+		Name:        folder.Name,
+		MappedPath:  folder.MappedPath,
+		Description: folder.Description,
+		FsConfig:    folder.FsConfig,
+		Attributes:  folder.Attributes,
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return ""
+	}
+	return computeETag(data)
+}
+
+// checkIfMatch returns an error if the If-Match header is set and does not match
+// the current ETag. Requests without the If-Match header are always allowed
+func checkIfMatch(r *http.Request, etag string) error {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return nil
+	}
+	return checkETag(ifMatch, etag)
+}
+
+func checkETag(expected, etag string) error {
+	for _, val := range strings.Split(expected, ",") {
+		val = strings.TrimPrefix(strings.TrimSpace(val), "W/")
+		if val == etag {
+			return nil
+		}
+	}
+	return util.NewI18nError(errPreconditionFailed, util.I18nErrorPreconditionFailed)
+}
+
+// getWebETag returns the ETag to embed in a WebAdmin update form. If the form
+// was already posted the submitted ETag is preserved
+func getWebETag(r *http.Request, etag string) string {
+	if r.Method == http.MethodPost {
+		if val := r.Form.Get("etag"); val != "" {
+			return val
+		}
+	}
+	return etag
+}
+
+// checkWebETag checks the ETag submitted with a WebAdmin update form, if any
+func checkWebETag(r *http.Request, etag string) error {
+	val := r.Form.Get("etag")
+	if val == "" {
+		return nil
+	}
+	return checkETag(val, etag)
+}
+
 func getCommaSeparatedQueryParam(r *http.Request, key string) []string {
 	var result []string
 
@@ -293,34 +378,44 @@ func renderAPIDirContents(w http.ResponseWriter, r *http.Request, contents []os.
 		}
 		res["mode"] = info.Mode()
 		res["last_modified"] = info.ModTime().UTC().Format(time.RFC3339)
+		if fi, ok := info.(*vfs.FileInfo); ok && fi.AccessTier() != "" {
+			res["access_tier"] = fi.AccessTier()
+		}
 		results = append(results, res)
 	}
 
 	render.JSON(w, r, results)
 }
 
-func getCompressedFileName(username string, files []string) string {
+func getCompressedFileName(username string, files []string, format string) string {
 	if len(files) == 1 {
 		name := path.Base(files[0])
-		return fmt.Sprintf("%s-%s.zip", username, strings.TrimSuffix(name, path.Ext(name)))
+		return fmt.Sprintf("%s-%s.%s", username, strings.TrimSuffix(name, path.Ext(name)), format)
 	}
-	return fmt.Sprintf("%s-download.zip", username)
+	return fmt.Sprintf("%s-download.%s", username, format)
 }
 
-func renderCompressedFiles(w http.ResponseWriter, conn *Connection, baseDir string, files []string,
-	share *dataprovider.Share,
+func renderCompressedFiles(w http.ResponseWriter, r *http.Request, conn *Connection, baseDir string, files []string,
+	share *dataprovider.Share, opts archiveOptions,
 ) {
 	conn.User.CheckFsRoot(conn.ID) //nolint:errcheck
-	w.Header().Set("Content-Type", "application/zip")
+	// sort the files to always generate the same archive for the same contents
+	files = append([]string(nil), files...)
+	sort.Strings(files)
+	if opts.format != archiveFormatZip {
+		renderTarArchive(w, r, conn, baseDir, files, share, opts)
+		return
+	}
+	w.Header().Set("Content-Type", opts.getContentType())
 	w.Header().Set("Accept-Ranges", "none")
 	w.Header().Set("Content-Transfer-Encoding", "binary")
 	w.WriteHeader(http.StatusOK)
 
-	wr := zip.NewWriter(w)
+	wr := newZipArchiveWriter(w, opts.level)
 
 	for _, file := range files {
 		fullPath := util.CleanPath(path.Join(baseDir, file))
-		if err := addZipEntry(wr, conn, fullPath, baseDir); err != nil {
+		if err := addZipEntry(wr, conn, fullPath, baseDir, opts.level); err != nil {
 			if share != nil {
 				dataprovider.UpdateShareLastUse(share, -1) //nolint:errcheck
 			}
@@ -336,7 +431,7 @@ func renderCompressedFiles(w http.ResponseWriter, conn *Connection, baseDir stri
 	}
 }
 
-func addZipEntry(wr *zip.Writer, conn *Connection, entryPath, baseDir string) error {
+func addZipEntry(wr *zip.Writer, conn *Connection, entryPath, baseDir string, level int) error {
 	info, err := conn.Stat(entryPath, 1)
 	if err != nil {
 		conn.Log(logger.LevelDebug, "unable to add zip entry %q, stat error: %v", entryPath, err)
@@ -348,11 +443,13 @@ func addZipEntry(wr *zip.Writer, conn *Connection, entryPath, baseDir string) er
 		return err
 	}
 	if info.IsDir() {
-		_, err = wr.CreateHeader(&zip.FileHeader{
+		hdr := &zip.FileHeader{
 			Name:     entryName + "/",
 			Method:   zip.Deflate,
 			Modified: info.ModTime(),
-		})
+		}
+		hdr.SetMode(os.ModeDir | getArchiveEntryMode(info))
+		_, err = wr.CreateHeader(hdr)
 		if err != nil {
 			conn.Log(logger.LevelError, "unable to create zip entry %q: %v", entryPath, err)
 			return err
@@ -362,9 +459,12 @@ func addZipEntry(wr *zip.Writer, conn *Connection, entryPath, baseDir string) er
 			conn.Log(logger.LevelDebug, "unable to add zip entry %q, read dir error: %v", entryPath, err)
 			return err
 		}
+		sort.Slice(contents, func(i, j int) bool {
+			return contents[i].Name() < contents[j].Name()
+		})
 		for _, info := range contents {
 			fullPath := util.CleanPath(path.Join(entryPath, info.Name()))
-			if err := addZipEntry(wr, conn, fullPath, baseDir); err != nil {
+			if err := addZipEntry(wr, conn, fullPath, baseDir, level); err != nil {
 				return err
 			}
 		}
@@ -375,18 +475,20 @@ func addZipEntry(wr *zip.Writer, conn *Connection, entryPath, baseDir string) er
 		conn.Log(logger.LevelInfo, "skipping zip entry for non regular file %q", entryPath)
 		return nil
 	}
-	reader, err := conn.getFileReader(entryPath, 0, http.MethodGet)
+	reader, err := conn.getFileReader(entryPath, 0, http.MethodGet, nil)
 	if err != nil {
 		conn.Log(logger.LevelDebug, "unable to add zip entry %q, cannot open file: %v", entryPath, err)
 		return err
 	}
 	defer reader.Close()
 
-	f, err := wr.CreateHeader(&zip.FileHeader{
+	hdr := &zip.FileHeader{
 		Name:     entryName,
-		Method:   zip.Deflate,
+		Method:   getZipMethod(level),
 		Modified: info.ModTime(),
-	})
+	}
+	hdr.SetMode(getArchiveEntryMode(info))
+	f, err := wr.CreateHeader(hdr)
 	if err != nil {
 		conn.Log(logger.LevelError, "unable to create zip entry %q: %v", entryPath, err)
 		return err
@@ -435,7 +537,7 @@ func downloadFile(w http.ResponseWriter, r *http.Request, connection *Connection
 		}
 		responseStatus = http.StatusPartialContent
 	}
-	reader, err := connection.getFileReader(name, offset, r.Method)
+	reader, err := connection.getFileReader(name, offset, r.Method, info)
 	if err != nil {
 		return getMappedStatusCode(err), fmt.Errorf("unable to read file %q: %v", name, err)
 	}
@@ -629,6 +731,7 @@ func updateLoginMetrics(user *dataprovider.User, loginMethod, ip string, err err
 }
 
 func checkHTTPClientUser(user *dataprovider.User, r *http.Request, connectionID string, checkSessions bool) error {
+	user.ApplySourceAuthPolicy(r.RemoteAddr)
 	if util.Contains(user.Filters.DeniedProtocols, common.ProtocolHTTP) {
 		logger.Info(logSender, connectionID, "cannot login user %q, protocol HTTP is not allowed", user.Username)
 		return util.NewI18nError(
@@ -636,7 +739,11 @@ func checkHTTPClientUser(user *dataprovider.User, r *http.Request, connectionID
 			util.I18nErrorProtocolForbidden,
 		)
 	}
-	if !isLoggedInWithOIDC(r) && !user.IsLoginMethodAllowed(dataprovider.LoginMethodPassword, common.ProtocolHTTP) {
+	impersonatedBy, impersonationReadOnly := getImpersonationFromToken(r)
+	if impersonatedBy != "" {
+		logger.Info(logSender, connectionID, "user %q impersonated by admin %q, read only: %t, request: %s %s",
+			user.Username, impersonatedBy, impersonationReadOnly, r.Method, r.URL.Path)
+	} else if !isLoggedInWithOIDC(r) && !user.IsLoginMethodAllowed(dataprovider.LoginMethodPassword, common.ProtocolHTTP) {
 		logger.Info(logSender, connectionID, "cannot login user %q, password login method is not allowed", user.Username)
 		return util.NewI18nError(
 			fmt.Errorf("login method password is not allowed for user %q", user.Username),
@@ -658,6 +765,9 @@ func checkHTTPClientUser(user *dataprovider.User, r *http.Request, connectionID
 			util.I18nErrorIPForbidden,
 		)
 	}
+	if isReadOnlyBinding(r) || impersonationReadOnly {
+		user.SetReadOnlyPermissions()
+	}
 	return nil
 }
 
@@ -792,6 +902,7 @@ func isUserAllowedToResetPassword(r *http.Request, user *dataprovider.User) bool
 	if util.Contains(user.Filters.DeniedProtocols, common.ProtocolHTTP) {
 		return false
 	}
+	user.ApplySourceAuthPolicy(r.RemoteAddr)
 	if !user.IsLoginMethodAllowed(dataprovider.LoginMethodPassword, common.ProtocolHTTP) {
 		return false
 	}
@@ -0,0 +1,115 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+var errWebhooksRoleForbidden = errors.New("webhook deliveries are not available for admins with a role")
+
+func getWebhookDeliverySearchFromRequest(w http.ResponseWriter, r *http.Request) (dataprovider.WebhookDeliverySearch, error) {
+	var filters dataprovider.WebhookDeliverySearch
+	var err error
+
+	filters.Limit, filters.Offset, filters.Order, err = getSearchFilters(w, r)
+	if err != nil {
+		return filters, err
+	}
+	if _, ok := r.URL.Query()["status"]; ok {
+		filters.Status, err = strconv.Atoi(r.URL.Query().Get("status"))
+		if err != nil || filters.Status < dataprovider.WebhookDeliveryStatusPending ||
+			filters.Status > dataprovider.WebhookDeliveryStatusDead {
+			err = util.NewValidationError(fmt.Sprintf("invalid status: %q", r.URL.Query().Get("status")))
+			sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+			return filters, err
+		}
+	}
+	return filters, nil
+}
+
+func getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	if claims.Role != "" {
+		sendAPIResponse(w, r, errWebhooksRoleForbidden, "", http.StatusForbidden)
+		return
+	}
+	filters, err := getWebhookDeliverySearchFromRequest(w, r)
+	if err != nil {
+		return
+	}
+	deliveries, err := dataprovider.SearchWebhookDeliveries(&filters)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	for idx := range deliveries {
+		deliveries[idx].PrepareForRendering()
+		// the payload is returned only when a single delivery is requested
+		deliveries[idx].Payload = ""
+	}
+	render.JSON(w, r, deliveries)
+}
+
+func getWebhookDeliveryByID(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	if claims.Role != "" {
+		sendAPIResponse(w, r, errWebhooksRoleForbidden, "", http.StatusForbidden)
+		return
+	}
+	delivery, err := dataprovider.GetWebhookDelivery(getURLParam(r, "id"))
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	delivery.PrepareForRendering()
+	render.JSON(w, r, delivery)
+}
+
+func retryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	if claims.Role != "" {
+		sendAPIResponse(w, r, errWebhooksRoleForbidden, "", http.StatusForbidden)
+		return
+	}
+	if err := dataprovider.RetryWebhookDelivery(getURLParam(r, "id")); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, "Delivery scheduled", http.StatusAccepted)
+}
@@ -0,0 +1,376 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zip"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// supported formats for folders and multiple files downloads
+const (
+	archiveFormatZip   = "zip"
+	archiveFormatTar   = "tar"
+	archiveFormatTarGz = "tar.gz"
+)
+
+const (
+	tarBlockSize     = 512
+	tarTrailerLength = 2 * tarBlockSize
+)
+
+var (
+	archiveFormats = []string{archiveFormatZip, archiveFormatTar, archiveFormatTarGz}
+	tarPadding     = make([]byte, tarTrailerLength)
+)
+
+// archiveOptions defines the format and the compression level for a streamed archive
+type archiveOptions struct {
+	format string
+	// compression level from 0, no compression, to 9, best compression.
+	// -1 means the default compression level. It is ignored for tar archives
+	level int
+}
+
+func (o *archiveOptions) getContentType() string {
+	switch o.format {
+	case archiveFormatTar:
+		return "application/x-tar"
+	case archiveFormatTarGz:
+		return "application/gzip"
+	default:
+		return "application/zip"
+	}
+}
+
+// getArchiveOptions returns the archive options from the "format" and "compression"
+// query parameters, zip with the default compression level is used if not specified
+func getArchiveOptions(r *http.Request) (archiveOptions, error) {
+	opts := archiveOptions{
+		format: archiveFormatZip,
+		level:  flate.DefaultCompression,
+	}
+	if format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format"))); format != "" {
+		if format == "tgz" {
+			format = archiveFormatTarGz
+		}
+		if !util.Contains(archiveFormats, format) {
+			return opts, util.NewValidationError(fmt.Sprintf("unsupported archive format %q, supported formats: %s",
+				format, strings.Join(archiveFormats, ", ")))
+		}
+		opts.format = format
+	}
+	if level := r.URL.Query().Get("compression"); level != "" {
+		val, err := strconv.Atoi(level)
+		if err != nil || val < flate.NoCompression || val > flate.BestCompression {
+			return opts, util.NewValidationError(fmt.Sprintf("invalid compression level %q, allowed values: 0-9", level))
+		}
+		opts.level = val
+	}
+	return opts, nil
+}
+
+func newZipArchiveWriter(w io.Writer, level int) *zip.Writer {
+	wr := zip.NewWriter(w)
+	if level > flate.NoCompression {
+		wr.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, level)
+		})
+	}
+	return wr
+}
+
+func getZipMethod(level int) uint16 {
+	if level == flate.NoCompression {
+		return zip.Store
+	}
+	return zip.Deflate
+}
+
+// getArchiveEntryMode returns the permission bits to store for an archive
+// entry. Some storage backends, for example cloud storages, don't report
+// any permission, in this case the usual defaults are returned
+func getArchiveEntryMode(info os.FileInfo) os.FileMode {
+	if perm := info.Mode().Perm(); perm != 0 {
+		return perm
+	}
+	if info.IsDir() {
+		return 0755
+	}
+	return 0644
+}
+
+// tarArchiveEntry defines a file or directory included in a tar archive
+type tarArchiveEntry struct {
+	virtualPath string
+	header      []byte
+	size        int64
+}
+
+// tarArchive allows to generate a tar archive with a size known in advance,
+// so range requests can be used to resume interrupted downloads. The entries
+// are stored in a stable order and the header blocks are generated before
+// starting the download
+type tarArchive struct {
+	entries []tarArchiveEntry
+	size    int64
+	etag    string
+}
+
+func newTarArchive(conn *Connection, baseDir string, files []string) (*tarArchive, error) {
+	a := &tarArchive{
+		size: tarTrailerLength,
+	}
+	for _, file := range files {
+		fullPath := util.CleanPath(path.Join(baseDir, file))
+		if err := a.addEntry(conn, fullPath, baseDir); err != nil {
+			return nil, err
+		}
+	}
+	hash := sha256.New()
+	for _, entry := range a.entries {
+		hash.Write(entry.header)
+	}
+	a.etag = fmt.Sprintf("%q", fmt.Sprintf("%x", hash.Sum(nil))[:32])
+	return a, nil
+}
+
+func (a *tarArchive) addEntry(conn *Connection, entryPath, baseDir string) error {
+	info, err := conn.Stat(entryPath, 1)
+	if err != nil {
+		conn.Log(logger.LevelDebug, "unable to add tar entry %q, stat error: %v", entryPath, err)
+		return err
+	}
+	entryName, err := getZipEntryName(entryPath, baseDir)
+	if err != nil {
+		conn.Log(logger.LevelError, "unable to get tar entry name: %v", err)
+		return err
+	}
+	if info.IsDir() {
+		if err := a.appendEntry(entryPath, &tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     entryName + "/",
+			Mode:     int64(getArchiveEntryMode(info)),
+			ModTime:  info.ModTime().Truncate(time.Second),
+		}); err != nil {
+			conn.Log(logger.LevelError, "unable to create tar entry %q: %v", entryPath, err)
+			return err
+		}
+		contents, err := conn.ReadDir(entryPath)
+		if err != nil {
+			conn.Log(logger.LevelDebug, "unable to add tar entry %q, read dir error: %v", entryPath, err)
+			return err
+		}
+		sort.Slice(contents, func(i, j int) bool {
+			return contents[i].Name() < contents[j].Name()
+		})
+		for _, info := range contents {
+			fullPath := util.CleanPath(path.Join(entryPath, info.Name()))
+			if err := a.addEntry(conn, fullPath, baseDir); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !info.Mode().IsRegular() {
+		// we only allow regular files
+		conn.Log(logger.LevelInfo, "skipping tar entry for non regular file %q", entryPath)
+		return nil
+	}
+	if !conn.User.HasPerm(dataprovider.PermDownload, path.Dir(entryPath)) {
+		return conn.GetPermissionDeniedError()
+	}
+	if ok, policy := conn.User.IsFileAllowed(entryPath); !ok {
+		return conn.GetErrorForDeniedFile(policy)
+	}
+	if err := a.appendEntry(entryPath, &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     entryName,
+		Mode:     int64(getArchiveEntryMode(info)),
+		Size:     info.Size(),
+		ModTime:  info.ModTime().Truncate(time.Second),
+	}); err != nil {
+		conn.Log(logger.LevelError, "unable to create tar entry %q: %v", entryPath, err)
+		return err
+	}
+	return nil
+}
+
+func (a *tarArchive) appendEntry(virtualPath string, hdr *tar.Header) error {
+	var buf bytes.Buffer
+	// the header blocks are written as soon as WriteHeader is called
+	if err := tar.NewWriter(&buf).WriteHeader(hdr); err != nil {
+		return err
+	}
+	a.entries = append(a.entries, tarArchiveEntry{
+		virtualPath: virtualPath,
+		header:      buf.Bytes(),
+		size:        hdr.Size,
+	})
+	a.size += int64(buf.Len()) + hdr.Size + getTarPaddingLength(hdr.Size)
+	return nil
+}
+
+// writeRange writes the archive bytes from offset to offset+length
+func (a *tarArchive) writeRange(w io.Writer, conn *Connection, offset, length int64) error {
+	var pos int64
+	end := offset + length
+
+	writeBytes := func(data []byte) error {
+		start, stop := max(pos, offset), min(pos+int64(len(data)), end)
+		if start < stop {
+			if _, err := w.Write(data[start-pos : stop-pos]); err != nil {
+				return err
+			}
+		}
+		pos += int64(len(data))
+		return nil
+	}
+
+	for _, entry := range a.entries {
+		if pos >= end {
+			return nil
+		}
+		if err := writeBytes(entry.header); err != nil {
+			return err
+		}
+		if entry.size == 0 {
+			continue
+		}
+		start, stop := max(pos, offset), min(pos+entry.size, end)
+		if start < stop {
+			if err := a.copyFileData(w, conn, entry.virtualPath, start-pos, stop-start); err != nil {
+				return err
+			}
+		}
+		pos += entry.size
+		if err := writeBytes(tarPadding[:getTarPaddingLength(entry.size)]); err != nil {
+			return err
+		}
+	}
+	return writeBytes(tarPadding)
+}
+
+func (a *tarArchive) copyFileData(w io.Writer, conn *Connection, virtualPath string, offset, length int64) error {
+	reader, err := conn.getFileReader(virtualPath, offset, http.MethodGet, nil)
+	if err != nil {
+		conn.Log(logger.LevelDebug, "unable to add tar entry %q, cannot open file: %v", virtualPath, err)
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.CopyN(w, reader, length)
+	if errors.Is(err, io.EOF) {
+		return fmt.Errorf("file %q changed while generating the archive: %w", virtualPath, io.ErrUnexpectedEOF)
+	}
+	return err
+}
+
+func getTarPaddingLength(size int64) int64 {
+	return (tarBlockSize - size%tarBlockSize) % tarBlockSize
+}
+
+func renderTarArchive(w http.ResponseWriter, r *http.Request, conn *Connection, baseDir string, files []string,
+	share *dataprovider.Share, opts archiveOptions,
+) {
+	archive, err := newTarArchive(conn, baseDir, files)
+	if err != nil {
+		if share != nil {
+			dataprovider.UpdateShareLastUse(share, -1) //nolint:errcheck
+		}
+		w.Header().Del("Content-Disposition")
+		sendAPIResponse(w, r, err, "Unable to create the archive", getMappedStatusCode(err))
+		return
+	}
+	offset := int64(0)
+	size := archive.size
+	responseStatus := http.StatusOK
+	w.Header().Set("Content-Type", opts.getContentType())
+	w.Header().Set("Content-Transfer-Encoding", "binary")
+	if opts.format == archiveFormatTar {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("ETag", archive.etag)
+		rangeHeader := r.Header.Get("Range")
+		if ifRange := r.Header.Get("If-Range"); ifRange != "" && ifRange != archive.etag {
+			rangeHeader = ""
+		}
+		if strings.HasPrefix(rangeHeader, "bytes=") {
+			if !strings.Contains(rangeHeader, ",") {
+				offset, size, err = parseRangeRequest(rangeHeader[6:], archive.size)
+				if err == nil && size <= 0 {
+					err = fmt.Errorf("unacceptable range %q", rangeHeader)
+				}
+			} else {
+				err = fmt.Errorf("unsupported range %q", rangeHeader)
+			}
+			if err != nil {
+				if share != nil {
+					dataprovider.UpdateShareLastUse(share, -1) //nolint:errcheck
+				}
+				w.Header().Del("Content-Disposition")
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", archive.size))
+				sendAPIResponse(w, r, err, "", http.StatusRequestedRangeNotSatisfiable)
+				return
+			}
+			responseStatus = http.StatusPartialContent
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+size-1, archive.size))
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	} else {
+		w.Header().Set("Accept-Ranges", "none")
+	}
+	w.WriteHeader(responseStatus)
+
+	var wr io.Writer = w
+	var gzWriter *gzip.Writer
+	if opts.format == archiveFormatTarGz {
+		gzWriter, err = gzip.NewWriterLevel(w, opts.level)
+		if err != nil {
+			conn.Log(logger.LevelError, "unable to create gzip writer: %v", err)
+			panic(http.ErrAbortHandler)
+		}
+		wr = gzWriter
+	}
+	err = archive.writeRange(wr, conn, offset, size)
+	if err == nil && gzWriter != nil {
+		err = gzWriter.Close()
+	}
+	if err != nil {
+		conn.Log(logger.LevelDebug, "unable to write tar archive: %v", err)
+		if share != nil {
+			dataprovider.UpdateShareLastUse(share, -1) //nolint:errcheck
+		}
+		panic(http.ErrAbortHandler)
+	}
+}
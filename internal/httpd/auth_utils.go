@@ -18,11 +18,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/jwtauth/v5"
 	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/rs/xid"
+	"github.com/sftpgo/sdk"
 
 	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
 	"github.com/drakkan/sftpgo/v2/internal/logger"
@@ -41,6 +43,8 @@ const (
 	tokenAudienceAPIUser          tokenAudience = "APIUser"
 	tokenAudienceCSRF             tokenAudience = "CSRF"
 	tokenAudienceOAuth2           tokenAudience = "OAuth2"
+	tokenAudienceAPIRefresh       tokenAudience = "APIRefresh"
+	tokenAudienceAPIUserRefresh   tokenAudience = "APIUserRefresh"
 )
 
 type tokenValidation = int
@@ -60,6 +64,11 @@ const (
 	claimMustSetSecondFactorKey     = "2fa_required"
 	claimRequiredTwoFactorProtocols = "2fa_protos"
 	claimHideUserPageSection        = "hus"
+	claimUserSelectors              = "usel"
+	claimShareEmail                 = "share_email"
+	claimImpersonatedBy             = "imp_by"
+	claimImpersonationReadOnly      = "imp_ro"
+	claimSessionID                  = "sid"
 	basicRealm                      = "Basic realm=\"SFTPGo\""
 	jwtCookieKey                    = "jwt"
 )
@@ -86,6 +95,11 @@ type jwtTokenClaims struct {
 	MustChangePassword         bool
 	RequiredTwoFactorProtocols []string
 	HideUserPageSections       int
+	UserSelectors              []string
+	ShareEmail                 string
+	ImpersonatedBy             string
+	ImpersonationReadOnly      bool
+	SessionID                  string
 }
 
 func (c *jwtTokenClaims) hasUserAudience() bool {
@@ -125,6 +139,21 @@ func (c *jwtTokenClaims) asMap() map[string]any {
 	if c.HideUserPageSections > 0 {
 		claims[claimHideUserPageSection] = c.HideUserPageSections
 	}
+	if len(c.UserSelectors) > 0 {
+		claims[claimUserSelectors] = c.UserSelectors
+	}
+	if c.ShareEmail != "" {
+		claims[claimShareEmail] = c.ShareEmail
+	}
+	if c.ImpersonatedBy != "" {
+		claims[claimImpersonatedBy] = c.ImpersonatedBy
+	}
+	if c.ImpersonationReadOnly {
+		claims[claimImpersonationReadOnly] = c.ImpersonationReadOnly
+	}
+	if c.SessionID != "" {
+		claims[claimSessionID] = c.SessionID
+	}
 
 	return claims
 }
@@ -203,6 +232,26 @@ func (c *jwtTokenClaims) Decode(token map[string]any) {
 		c.RequiredTwoFactorProtocols = c.decodeSliceString(val)
 	}
 
+	if val, ok := token[claimUserSelectors]; ok {
+		c.UserSelectors = c.decodeSliceString(val)
+	}
+
+	if val, ok := token[claimShareEmail]; ok {
+		c.ShareEmail = c.decodeString(val)
+	}
+
+	if val, ok := token[claimImpersonatedBy]; ok {
+		c.ImpersonatedBy = c.decodeString(val)
+	}
+
+	if val, ok := token[claimImpersonationReadOnly]; ok {
+		c.ImpersonationReadOnly = c.decodeBoolean(val)
+	}
+
+	if val, ok := token[claimSessionID]; ok {
+		c.SessionID = c.decodeString(val)
+	}
+
 	if val, ok := token[claimHideUserPageSection]; ok {
 		switch v := val.(type) {
 		case float64:
@@ -211,6 +260,65 @@ func (c *jwtTokenClaims) Decode(token map[string]any) {
 	}
 }
 
+// checkUserScope returns a not found error if the specified user does not match
+// the user selectors, this way the users outside the admin scope are not visible
+func (c *jwtTokenClaims) checkUserScope(user *dataprovider.User) error {
+	if dataprovider.MatchUserSelectors(c.UserSelectors, user.Attributes) {
+		return nil
+	}
+	return util.NewRecordNotFoundError(fmt.Sprintf("username %q does not exist", user.Username))
+}
+
+// setUserScope sets the missing attributes required to match the user selectors
+// and checks that the specified user is within the admin scope
+func (c *jwtTokenClaims) setUserScope(user *dataprovider.User) error {
+	if len(c.UserSelectors) == 0 {
+		return nil
+	}
+	for name, value := range dataprovider.GetUserSelectorDefaults(c.UserSelectors) {
+		if _, ok := user.Attributes[name]; !ok {
+			if user.Attributes == nil {
+				user.Attributes = make(map[string]string)
+			}
+			user.Attributes[name] = value
+		}
+	}
+	if !dataprovider.MatchUserSelectors(c.UserSelectors, user.Attributes) {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("user %q does not match the selectors %q", user.Username,
+				strings.Join(c.UserSelectors, ", "))),
+			util.I18nErrorUserOutOfScope,
+		)
+	}
+	return nil
+}
+
+// checkAdminScope returns an error if the specified user selectors allow to
+// manage users outside the scope of the admin associated with these claims
+func (c *jwtTokenClaims) checkAdminScope(selectors []string) error {
+	if dataprovider.IsUserSelectorsScopeIncluded(c.UserSelectors, selectors) {
+		return nil
+	}
+	return util.NewI18nError(
+		util.NewValidationError(fmt.Sprintf("the user selectors must be within your scope: %q",
+			strings.Join(c.UserSelectors, ", "))),
+		util.I18nErrorAdminOutOfScope,
+	)
+}
+
+func (c *jwtTokenClaims) filterUsersByScope(users []dataprovider.User) []dataprovider.User {
+	if len(c.UserSelectors) == 0 {
+		return users
+	}
+	result := make([]dataprovider.User, 0, len(users))
+	for _, user := range users {
+		if dataprovider.MatchUserSelectors(c.UserSelectors, user.Attributes) {
+			result = append(result, user)
+		}
+	}
+	return result
+}
+
 func (c *jwtTokenClaims) isCriticalPermRemoved(permissions []string) bool {
 	if util.Contains(permissions, dataprovider.PermAdminAny) {
 		return false
@@ -225,21 +333,23 @@ func (c *jwtTokenClaims) isCriticalPermRemoved(permissions []string) bool {
 }
 
 func (c *jwtTokenClaims) hasPerm(perm string) bool {
-	if util.Contains(c.Permissions, dataprovider.PermAdminAny) {
-		return true
-	}
-
-	return util.Contains(c.Permissions, perm)
+	return dataprovider.HasAdminPermission(c.Permissions, perm)
 }
 
 func (c *jwtTokenClaims) createToken(tokenAuth *jwtauth.JWTAuth, audience tokenAudience, ip string) (jwt.Token, string, error) {
+	return c.createTokenWithDuration(tokenAuth, []string{audience, ip}, tokenDuration)
+}
+
+func (c *jwtTokenClaims) createTokenWithDuration(tokenAuth *jwtauth.JWTAuth, audience []string,
+	duration time.Duration,
+) (jwt.Token, string, error) {
 	claims := c.asMap()
 	now := time.Now().UTC()
 
 	claims[jwt.JwtIDKey] = xid.New().String()
 	claims[jwt.NotBeforeKey] = now.Add(-30 * time.Second)
-	claims[jwt.ExpirationKey] = now.Add(tokenDuration)
-	claims[jwt.AudienceKey] = []string{audience, ip}
+	claims[jwt.ExpirationKey] = now.Add(duration)
+	claims[jwt.AudienceKey] = audience
 
 	return tokenAuth.Encode(claims)
 }
@@ -336,11 +446,20 @@ func isTokenInvalidated(r *http.Request) bool {
 			}
 		}
 	}
+	if !isTokenFound {
+		return true
+	}
+	if _, claims, err := jwtauth.FromContext(r.Context()); err == nil {
+		if sid, ok := claims[claimSessionID].(string); ok && isAuthSessionRevoked(sid) {
+			return true
+		}
+	}
 
-	return !isTokenFound
+	return false
 }
 
 func invalidateToken(r *http.Request) {
+	endAuthSession(r)
 	tokenString := jwtauth.TokenFromHeader(r)
 	if tokenString != "" {
 		invalidatedJWTTokens.Add(tokenString, time.Now().Add(tokenDuration).UTC())
@@ -365,6 +484,41 @@ func getUserFromToken(r *http.Request) *dataprovider.User {
 	return user
 }
 
+// getImpersonationFromToken returns the admin impersonating the user associated
+// with the request token, an empty string means that the session is not impersonated.
+// The returned boolean is true for read-only impersonated sessions
+func getImpersonationFromToken(r *http.Request) (string, bool) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return "", false
+	}
+	tokenClaims := jwtTokenClaims{}
+	tokenClaims.Decode(claims)
+	if tokenClaims.ImpersonatedBy == "" || !util.Contains(tokenClaims.Audience, tokenAudienceWebClient) {
+		return "", false
+	}
+	return tokenClaims.ImpersonatedBy, tokenClaims.ImpersonationReadOnly
+}
+
+// getImpersonationPermissions returns the WebClient permissions for an impersonated
+// session. Web client permissions are negated, the features to manage the user
+// credentials are always disabled
+func getImpersonationPermissions(permissions []string, readOnly bool) []string {
+	result := make([]string, len(permissions))
+	copy(result, permissions)
+	disabled := []string{sdk.WebClientPasswordChangeDisabled, sdk.WebClientMFADisabled,
+		sdk.WebClientPubKeyChangeDisabled, sdk.WebClientAPIKeyAuthChangeDisabled, sdk.WebClientInfoChangeDisabled}
+	if readOnly {
+		disabled = append(disabled, sdk.WebClientWriteDisabled, sdk.WebClientSharesDisabled)
+	}
+	for _, perm := range disabled {
+		if !util.Contains(result, perm) {
+			result = append(result, perm)
+		}
+	}
+	return result
+}
+
 func getAdminFromToken(r *http.Request) *dataprovider.Admin {
 	admin := &dataprovider.Admin{}
 	_, claims, err := jwtauth.FromContext(r.Context())
@@ -0,0 +1,298 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// Supported authentication session types
+const (
+	authSessionTypeWeb     = "web"
+	authSessionTypeAPI     = "api"
+	authSessionTypeRefresh = "refresh"
+)
+
+// revoked sessions are stored in the invalidated tokens using this key prefix
+const authSessionRevokedPrefix = "sid:"
+
+var (
+	authSessionMgr authSessionManager
+	// the session expiration is saved again only if it is extended by more than
+	// this interval, this way we avoid a write for each refreshed token
+	authSessionUpdateInterval = 5 * time.Minute
+)
+
+type authSessionManager interface {
+	Add(s *authSession) error
+	Get(id string) (*authSession, error)
+	List(username string, isAdmin bool) ([]authSession, error)
+	Delete(id string) error
+	Cleanup()
+}
+
+func newAuthSessionManager(isShared int) authSessionManager {
+	if isShared == 1 {
+		logger.Info(logSender, "", "using provider auth session manager")
+		return &dbAuthSessionManager{}
+	}
+	logger.Info(logSender, "", "using memory auth session manager")
+	return &memoryAuthSessionManager{}
+}
+
+// authSession defines an active web session, API token or OIDC refresh token.
+// The session ID is added to the issued tokens, so they can be revoked all
+// together by revoking the session
+type authSession struct {
+	ID             string `json:"id"`
+	Username       string `json:"username"`
+	IsAdmin        bool   `json:"is_admin"`
+	Type           string `json:"type"`
+	IP             string `json:"ip"`
+	ImpersonatedBy string `json:"impersonated_by,omitempty"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+	ExpiresAt      int64  `json:"expires_at"`
+}
+
+func (s *authSession) isExpired() bool {
+	return s.ExpiresAt < util.GetTimeAsMsSinceEpoch(time.Now())
+}
+
+func (s *authSession) isOwnedBy(username string, isAdmin bool) bool {
+	return s.Username == username && s.IsAdmin == isAdmin
+}
+
+type memoryAuthSessionManager struct {
+	sessions sync.Map
+}
+
+func (m *memoryAuthSessionManager) Add(s *authSession) error {
+	m.sessions.Store(s.ID, s)
+	return nil
+}
+
+func (m *memoryAuthSessionManager) Get(id string) (*authSession, error) {
+	v, ok := m.sessions.Load(id)
+	if !ok {
+		return nil, util.NewRecordNotFoundError("session not found")
+	}
+	// return a copy, updates must be saved using Add
+	res := *(v.(*authSession))
+	if res.isExpired() {
+		return nil, util.NewRecordNotFoundError("session expired")
+	}
+	return &res, nil
+}
+
+func (m *memoryAuthSessionManager) List(username string, isAdmin bool) ([]authSession, error) {
+	var result []authSession
+	m.sessions.Range(func(_, value any) bool {
+		s, ok := value.(*authSession)
+		if ok && s.isOwnedBy(username, isAdmin) && !s.isExpired() {
+			result = append(result, *s)
+		}
+		return true
+	})
+	return result, nil
+}
+
+func (m *memoryAuthSessionManager) Delete(id string) error {
+	m.sessions.Delete(id)
+	return nil
+}
+
+func (m *memoryAuthSessionManager) Cleanup() {
+	m.sessions.Range(func(key, value any) bool {
+		s, ok := value.(*authSession)
+		if !ok || s.isExpired() {
+			m.sessions.Delete(key)
+		}
+		return true
+	})
+}
+
+type dbAuthSessionManager struct{}
+
+func (m *dbAuthSessionManager) Add(s *authSession) error {
+	session := dataprovider.Session{
+		Key:       s.ID,
+		Data:      s,
+		Type:      dataprovider.SessionTypeAuthSession,
+		Timestamp: s.ExpiresAt,
+	}
+	return dataprovider.AddSharedSession(session)
+}
+
+func (m *dbAuthSessionManager) Get(id string) (*authSession, error) {
+	session, err := dataprovider.GetSharedSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Type != dataprovider.SessionTypeAuthSession {
+		return nil, util.NewRecordNotFoundError("session not found")
+	}
+	s, err := m.decode(session)
+	if err != nil {
+		return nil, err
+	}
+	if s.isExpired() {
+		return nil, util.NewRecordNotFoundError("session expired")
+	}
+	return s, nil
+}
+
+func (m *dbAuthSessionManager) List(username string, isAdmin bool) ([]authSession, error) {
+	sessions, err := dataprovider.GetSharedSessions(dataprovider.SessionTypeAuthSession)
+	if err != nil {
+		return nil, err
+	}
+	var result []authSession
+	for _, session := range sessions {
+		s, err := m.decode(session)
+		if err != nil {
+			continue
+		}
+		if s.isOwnedBy(username, isAdmin) && !s.isExpired() {
+			result = append(result, *s)
+		}
+	}
+	return result, nil
+}
+
+func (m *dbAuthSessionManager) decode(session dataprovider.Session) (*authSession, error) {
+	if val, ok := session.Data.([]byte); ok {
+		s := &authSession{}
+		err := json.Unmarshal(val, s)
+		return s, err
+	}
+	logger.Error(logSender, "", "invalid auth session data type %T", session.Data)
+	return nil, util.NewRecordNotFoundError("invalid session")
+}
+
+func (m *dbAuthSessionManager) Delete(id string) error {
+	return dataprovider.DeleteSharedSession(id)
+}
+
+func (m *dbAuthSessionManager) Cleanup() {
+	dataprovider.CleanupSharedSessions(dataprovider.SessionTypeAuthSession, time.Now()) //nolint:errcheck
+}
+
+// startAuthSession registers a new session and sets its ID in the specified claims,
+// the tokens issued using these claims will be bound to the session
+func startAuthSession(c *jwtTokenClaims, isAdmin bool, sessionType, ip string, expiresAt time.Time) {
+	now := util.GetTimeAsMsSinceEpoch(time.Now())
+	session := &authSession{
+		ID:             util.GenerateUniqueID(),
+		Username:       c.Username,
+		IsAdmin:        isAdmin,
+		Type:           sessionType,
+		IP:             ip,
+		ImpersonatedBy: c.ImpersonatedBy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		ExpiresAt:      util.GetTimeAsMsSinceEpoch(expiresAt),
+	}
+	c.SessionID = session.ID
+	if err := authSessionMgr.Add(session); err != nil {
+		logger.Warn(logSender, "", "unable to save %s session for %q, admin? %t: %v", sessionType, c.Username, isAdmin, err)
+	}
+}
+
+// touchAuthSession extends the expiration of the session with the specified ID
+func touchAuthSession(id string, expiresAt time.Time) {
+	if id == "" {
+		return
+	}
+	session, err := authSessionMgr.Get(id)
+	if err != nil {
+		return
+	}
+	expiration := util.GetTimeAsMsSinceEpoch(expiresAt)
+	if expiration-session.ExpiresAt < authSessionUpdateInterval.Milliseconds() {
+		return
+	}
+	session.ExpiresAt = expiration
+	session.UpdatedAt = util.GetTimeAsMsSinceEpoch(time.Now())
+	if err := authSessionMgr.Add(session); err != nil {
+		logger.Warn(logSender, "", "unable to update session %q: %v", id, err)
+	}
+}
+
+func isAuthSessionRevoked(id string) bool {
+	if id == "" {
+		return false
+	}
+	return invalidatedJWTTokens.Get(authSessionRevokedPrefix + id)
+}
+
+// revokeAuthSession invalidates all the tokens issued for the specified session.
+// The saved expiration could be behind the real one, see authSessionUpdateInterval
+func revokeAuthSession(session *authSession) error {
+	expiresAt := util.GetTimeFromMsecSinceEpoch(session.ExpiresAt).Add(authSessionUpdateInterval)
+	invalidatedJWTTokens.Add(authSessionRevokedPrefix+session.ID, expiresAt.UTC())
+	return authSessionMgr.Delete(session.ID)
+}
+
+// endAuthSession revokes the session associated with the token in the request context, if any
+func endAuthSession(r *http.Request) {
+	_, claims, err := jwtauth.FromContext(r.Context())
+	if err != nil {
+		return
+	}
+	tokenClaims := jwtTokenClaims{}
+	tokenClaims.Decode(claims)
+	endAuthSessionByID(tokenClaims.SessionID)
+}
+
+func endAuthSessionByID(id string) {
+	if id == "" {
+		return
+	}
+	session, err := authSessionMgr.Get(id)
+	if err != nil {
+		return
+	}
+	revokeAuthSession(session) //nolint:errcheck
+}
+
+// getAuthSessions returns the active sessions, not revoked, for the specified admin
+// or user sorted by creation time
+func getAuthSessions(username string, isAdmin bool) ([]authSession, error) {
+	sessions, err := authSessionMgr.List(username, isAdmin)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]authSession, 0, len(sessions))
+	for _, s := range sessions {
+		if !isAuthSessionRevoked(s.ID) {
+			result = append(result, s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt < result[j].CreatedAt
+	})
+	return result, nil
+}
@@ -0,0 +1,566 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/render"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	chunkedUploadDefaultPath      = "chunked_uploads"
+	chunkedUploadDefaultChunkSize = 8
+	chunkedUploadMaxChunkSize     = 100
+	chunkedUploadMaxParallel      = 10
+	chunkedUploadCleanupInterval  = time.Hour
+	chunkedUploadInfoFile         = "upload.json"
+)
+
+var (
+	chunkedUploader            *chunkedUploadManager
+	errChunkedUploadNotFound   = errors.New("upload not found")
+	errChunkedUploadIncomplete = errors.New("upload incomplete")
+	chunkedUploadIDRegex       = regexp.MustCompile(`^[a-zA-Z0-9]{1,64}$`)
+)
+
+// ChunkedUploadsConfig defines the configuration for the chunked uploads from the WebClient.
+// Files are uploaded in chunks, in parallel, and the received chunks are stored on the
+// server until the upload is completed, so interrupted uploads can be resumed, even
+// after a page reload. The completed files are then written to the user's storage as
+// a normal upload, so quota limits, hooks and event rules are applied as usual.
+// The tus resumable uploads REST API uses the same storage and retention
+type ChunkedUploadsConfig struct {
+	// Set to true to enable chunked uploads
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Path to the directory where the received chunks are stored until the upload is
+	// completed. This can be an absolute path or a path relative to the config dir
+	StoragePath string `json:"storage_path" mapstructure:"storage_path"`
+	// Chunk size in MB
+	ChunkSize int `json:"chunk_size" mapstructure:"chunk_size"`
+	// Maximum number of chunks uploaded in parallel for each file
+	MaxParallelChunks int `json:"max_parallel_chunks" mapstructure:"max_parallel_chunks"`
+	// Incomplete uploads not updated for more than the specified number of hours are
+	// removed. 0 means no automatic cleanup
+	Retention int `json:"retention" mapstructure:"retention"`
+}
+
+func (c *ChunkedUploadsConfig) initialize(configDir string) error {
+	chunkedUploader = nil
+	if !c.Enabled {
+		return nil
+	}
+	if c.ChunkSize == 0 {
+		c.ChunkSize = chunkedUploadDefaultChunkSize
+	}
+	if c.ChunkSize < 1 || c.ChunkSize > chunkedUploadMaxChunkSize {
+		return fmt.Errorf("chunked uploads: invalid chunk size %d, allowed range: 1-%d", c.ChunkSize,
+			chunkedUploadMaxChunkSize)
+	}
+	if c.MaxParallelChunks == 0 {
+		c.MaxParallelChunks = 1
+	}
+	if c.MaxParallelChunks < 1 || c.MaxParallelChunks > chunkedUploadMaxParallel {
+		return fmt.Errorf("chunked uploads: invalid max parallel chunks %d, allowed range: 1-%d",
+			c.MaxParallelChunks, chunkedUploadMaxParallel)
+	}
+	if c.Retention < 0 {
+		return fmt.Errorf("chunked uploads: invalid retention %d", c.Retention)
+	}
+	storagePath := c.StoragePath
+	if storagePath == "" {
+		storagePath = chunkedUploadDefaultPath
+	}
+	if !filepath.IsAbs(storagePath) {
+		storagePath = filepath.Join(configDir, storagePath)
+	}
+	if err := os.MkdirAll(storagePath, 0700); err != nil {
+		return fmt.Errorf("chunked uploads: unable to create the storage dir %q: %w", storagePath, err)
+	}
+	chunkedUploader = &chunkedUploadManager{
+		storageDir:  storagePath,
+		chunkSize:   int64(c.ChunkSize) * 1048576,
+		maxParallel: c.MaxParallelChunks,
+		retention:   time.Duration(c.Retention) * time.Hour,
+	}
+	logger.Debug(logSender, "", "chunked uploads enabled, storage dir: %q, chunk size: %d, max parallel chunks: %d",
+		storagePath, chunkedUploader.chunkSize, c.MaxParallelChunks)
+	return nil
+}
+
+// chunkedUpload defines an upload in progress. It is stored, as JSON, in the upload
+// directory together with the received chunks
+type chunkedUpload struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunk_size"`
+	// Modification time for the uploaded file as unix timestamp in milliseconds
+	ModTime   int64 `json:"mtime,omitempty"`
+	CreatedAt int64 `json:"created_at"`
+}
+
+func (u *chunkedUpload) getChunks() int {
+	return int((u.Size + u.ChunkSize - 1) / u.ChunkSize)
+}
+
+// getChunkSize returns the expected size for the chunk with the specified index,
+// only the last chunk can be smaller than the configured chunk size
+func (u *chunkedUpload) getChunkSize(index int) int64 {
+	return min(u.ChunkSize, u.Size-int64(index)*u.ChunkSize)
+}
+
+// chunkedUploadStatus defines the status of an upload in progress as returned to the clients
+type chunkedUploadStatus struct {
+	ID                string `json:"id"`
+	Path              string `json:"path"`
+	Size              int64  `json:"size"`
+	ChunkSize         int64  `json:"chunk_size"`
+	Chunks            int    `json:"chunks"`
+	Received          []int  `json:"received"`
+	MaxParallelChunks int    `json:"max_parallel_chunks"`
+}
+
+type chunkedUploadRequest struct {
+	Path    string `json:"-"`
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"`
+}
+
+type chunkedUploadManager struct {
+	storageDir  string
+	chunkSize   int64
+	maxParallel int
+	retention   time.Duration
+	lastCleanup atomic.Int64
+}
+
+// getUploadDir returns the directory for the specified upload. Uploads are grouped by
+// user, each user has its own directory
+func (m *chunkedUploadManager) getUploadDir(username, id string) string {
+	userHash := sha256.Sum256([]byte(username))
+	return filepath.Join(m.storageDir, hex.EncodeToString(userHash[:8]), id)
+}
+
+func (m *chunkedUploadManager) getChunkPath(uploadDir string, index int) string {
+	return filepath.Join(uploadDir, fmt.Sprintf("%d.chunk", index))
+}
+
+func (m *chunkedUploadManager) create(username string, req chunkedUploadRequest) (*chunkedUpload, error) {
+	upload := &chunkedUpload{
+		ID:        util.GenerateUniqueID(),
+		Username:  username,
+		Path:      req.Path,
+		Size:      req.Size,
+		ChunkSize: m.chunkSize,
+		ModTime:   req.ModTime,
+		CreatedAt: util.GetTimeAsMsSinceEpoch(time.Now()),
+	}
+	uploadDir := m.getUploadDir(username, upload.ID)
+	if err := os.MkdirAll(uploadDir, 0700); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(upload)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(uploadDir, chunkedUploadInfoFile), data, 0600); err != nil {
+		os.RemoveAll(uploadDir) //nolint:errcheck
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (m *chunkedUploadManager) get(username, id string) (*chunkedUpload, error) {
+	if !chunkedUploadIDRegex.MatchString(id) {
+		return nil, util.NewRecordNotFoundError(errChunkedUploadNotFound.Error())
+	}
+	data, err := os.ReadFile(filepath.Join(m.getUploadDir(username, id), chunkedUploadInfoFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, util.NewRecordNotFoundError(errChunkedUploadNotFound.Error())
+		}
+		return nil, err
+	}
+	var upload chunkedUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, err
+	}
+	if upload.ID != id || upload.Username != username || upload.ChunkSize <= 0 {
+		return nil, util.NewRecordNotFoundError(errChunkedUploadNotFound.Error())
+	}
+	return &upload, nil
+}
+
+// getReceived returns the indexes of the chunks received with the expected size
+func (m *chunkedUploadManager) getReceived(upload *chunkedUpload) []int {
+	uploadDir := m.getUploadDir(upload.Username, upload.ID)
+	received := make([]int, 0)
+	for idx := 0; idx < upload.getChunks(); idx++ {
+		info, err := os.Stat(m.getChunkPath(uploadDir, idx))
+		if err == nil && info.Size() == upload.getChunkSize(idx) {
+			received = append(received, idx)
+		}
+	}
+	return received
+}
+
+func (m *chunkedUploadManager) getStatus(upload *chunkedUpload) chunkedUploadStatus {
+	return chunkedUploadStatus{
+		ID:                upload.ID,
+		Path:              upload.Path,
+		Size:              upload.Size,
+		ChunkSize:         upload.ChunkSize,
+		Chunks:            upload.getChunks(),
+		Received:          m.getReceived(upload),
+		MaxParallelChunks: m.maxParallel,
+	}
+}
+
+// writeChunk stores the chunk with the specified index. The chunk is written to a
+// temporary file and then renamed, so a chunk sent again, for example after a network
+// error, never corrupts an already received chunk
+func (m *chunkedUploadManager) writeChunk(upload *chunkedUpload, index int, reader io.Reader) error {
+	if index < 0 || index >= upload.getChunks() {
+		return util.NewValidationError(fmt.Sprintf("invalid chunk index %d", index))
+	}
+	expectedSize := upload.getChunkSize(index)
+	uploadDir := m.getUploadDir(upload.Username, upload.ID)
+	tmpPath := filepath.Join(uploadDir, fmt.Sprintf(".%d.%s", index, util.GenerateUniqueID()))
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return util.NewRecordNotFoundError(errChunkedUploadNotFound.Error())
+		}
+		return err
+	}
+	n, err := io.Copy(f, io.LimitReader(reader, expectedSize+1))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n != expectedSize {
+		err = util.NewValidationError(fmt.Sprintf("invalid size for chunk %d: %d, expected: %d", index, n, expectedSize))
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, m.getChunkPath(uploadDir, index))
+	}
+	if err != nil {
+		os.Remove(tmpPath) //nolint:errcheck
+	}
+	return err
+}
+
+// complete writes the received chunks to the user's storage, as a normal upload,
+// and removes the upload
+func (m *chunkedUploadManager) complete(connection *Connection, upload *chunkedUpload) error {
+	received := m.getReceived(upload)
+	if len(received) != upload.getChunks() {
+		return util.NewValidationError(fmt.Sprintf("%v: received chunks %d/%d", errChunkedUploadIncomplete,
+			len(received), upload.getChunks()))
+	}
+	connection.User.CheckFsRoot(connection.ID) //nolint:errcheck
+	writer, err := connection.getFileWriter(upload.Path)
+	if err != nil {
+		return err
+	}
+	uploadDir := m.getUploadDir(upload.Username, upload.ID)
+	for idx := 0; idx < upload.getChunks(); idx++ {
+		if err := m.copyChunk(writer, uploadDir, idx); err != nil {
+			writer.Close() //nolint:errcheck
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	if upload.ModTime > 0 {
+		setModificationTime(connection, upload.Path, upload.ModTime)
+	}
+	m.remove(upload)
+	return nil
+}
+
+func (m *chunkedUploadManager) copyChunk(w io.Writer, uploadDir string, index int) error {
+	f, err := os.Open(m.getChunkPath(uploadDir, index))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+func (m *chunkedUploadManager) remove(upload *chunkedUpload) {
+	uploadDir := m.getUploadDir(upload.Username, upload.ID)
+	if err := os.RemoveAll(uploadDir); err != nil {
+		logger.Warn(logSender, "", "unable to remove chunked upload dir %q: %v", uploadDir, err)
+	}
+}
+
+// cleanup removes the incomplete uploads not updated within the configured retention.
+// The storage directory is walked at most once per hour
+func (m *chunkedUploadManager) cleanup() {
+	if m.retention <= 0 {
+		return
+	}
+	now := time.Now()
+	lastCleanup := m.lastCleanup.Load()
+	if now.Sub(time.Unix(0, lastCleanup)) < chunkedUploadCleanupInterval {
+		return
+	}
+	if !m.lastCleanup.CompareAndSwap(lastCleanup, now.UnixNano()) {
+		return
+	}
+	removed := 0
+	userDirs, err := os.ReadDir(m.storageDir)
+	if err != nil {
+		logger.Warn(logSender, "", "unable to read chunked uploads dir %q: %v", m.storageDir, err)
+		return
+	}
+	for _, userDir := range userDirs {
+		if !userDir.IsDir() {
+			continue
+		}
+		userPath := filepath.Join(m.storageDir, userDir.Name())
+		uploads, err := os.ReadDir(userPath)
+		if err != nil {
+			continue
+		}
+		for _, upload := range uploads {
+			info, err := upload.Info()
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			// the directory modification time changes each time a chunk is received
+			if now.Sub(info.ModTime()) > m.retention {
+				if err := os.RemoveAll(filepath.Join(userPath, upload.Name())); err == nil {
+					removed++
+				}
+			}
+		}
+		if len(uploads) == 0 {
+			os.Remove(userPath) //nolint:errcheck
+		}
+	}
+	logger.Debug(logSender, "", "chunked uploads cleanup completed, removed: %d, elapsed: %s",
+		removed, time.Since(now))
+}
+
+// checkChunkedUpload checks if the connection user can upload the specified file
+// before accepting any chunk, the same checks are done again when the upload is completed
+func checkChunkedUpload(connection *Connection, req chunkedUploadRequest) error {
+	if req.Size < 0 {
+		return util.NewValidationError(fmt.Sprintf("invalid file size %d", req.Size))
+	}
+	if maxUploadFileSize > 0 && req.Size > maxUploadFileSize {
+		return util.NewValidationError(fmt.Sprintf("file size %d exceeds the maximum allowed size %d",
+			req.Size, maxUploadFileSize))
+	}
+	if ok, _ := connection.User.IsFileAllowed(req.Path); !ok {
+		return connection.GetPermissionDeniedError()
+	}
+	perm := dataprovider.PermUpload
+	var existingSize int64
+	info, err := connection.Stat(req.Path, 0)
+	if err == nil {
+		if info.IsDir() {
+			return connection.GetOpUnsupportedError()
+		}
+		perm = dataprovider.PermOverwrite
+		existingSize = info.Size()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if !connection.User.HasPerm(perm, path.Dir(req.Path)) {
+		return connection.GetPermissionDeniedError()
+	}
+	quotaResult, transferQuota := connection.HasSpace(true, false, req.Path)
+	if !quotaResult.HasSpace || !transferQuota.HasUploadSpace() {
+		return common.ErrQuotaExceeded
+	}
+	if remaining := quotaResult.GetRemainingSize(); remaining > 0 && req.Size-existingSize > remaining {
+		return common.ErrQuotaExceeded
+	}
+	return nil
+}
+
+func setModificationTime(connection *Connection, filePath string, mTime int64) {
+	attrs := common.StatAttributes{
+		Flags: common.StatAttrTimes,
+		Atime: util.GetTimeFromMsecSinceEpoch(mTime),
+		Mtime: util.GetTimeFromMsecSinceEpoch(mTime),
+	}
+	err := connection.SetStat(filePath, &attrs)
+	connection.Log(logger.LevelDebug, "requested modification time %v for file %q, error: %v",
+		attrs.Mtime, filePath, err)
+}
+
+func getChunkedUpload(w http.ResponseWriter, r *http.Request) (*Connection, *chunkedUpload, error) {
+	if chunkedUploader == nil {
+		sendAPIResponse(w, r, nil, "Chunked uploads are disabled", http.StatusNotFound)
+		return nil, nil, errChunkedUploadNotFound
+	}
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	upload, err := chunkedUploader.get(connection.GetUsername(), getURLParam(r, "id"))
+	if err != nil {
+		common.Connections.Remove(connection.GetID())
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return nil, nil, err
+	}
+	return connection, upload, nil
+}
+
+func startChunkedUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if chunkedUploader == nil {
+		sendAPIResponse(w, r, nil, "Chunked uploads are disabled", http.StatusNotFound)
+		return
+	}
+	if !r.URL.Query().Has("path") {
+		sendAPIResponse(w, r, errors.New("please set a file path"), "", http.StatusBadRequest)
+		return
+	}
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	var req chunkedUploadRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	req.Path = connection.User.GetCleanedPath(r.URL.Query().Get("path"))
+	if req.Path == "/" {
+		sendAPIResponse(w, r, errors.New("please set a file path"), "", http.StatusBadRequest)
+		return
+	}
+	connection.User.CheckFsRoot(connection.ID) //nolint:errcheck
+	if err := checkChunkedUpload(connection, req); err != nil {
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to upload file %q", req.Path), getMappedStatusCode(err))
+		return
+	}
+	upload, err := chunkedUploader.create(connection.GetUsername(), req)
+	if err != nil {
+		sendAPIResponse(w, r, err, "Unable to start the upload", http.StatusInternalServerError)
+		return
+	}
+	connection.Log(logger.LevelDebug, "chunked upload %q started for file %q, size: %d", upload.ID, upload.Path,
+		upload.Size)
+	w.Header().Set("Location", path.Join(webClientUploadsPath, upload.ID))
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, chunkedUploader.getStatus(upload))
+}
+
+func getChunkedUploadStatus(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, upload, err := getChunkedUpload(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	render.JSON(w, r, chunkedUploader.getStatus(upload))
+}
+
+func uploadChunk(w http.ResponseWriter, r *http.Request) {
+	if chunkedUploader != nil {
+		r.Body = http.MaxBytesReader(w, r.Body, chunkedUploader.chunkSize)
+	}
+	connection, upload, err := getChunkedUpload(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	index, err := strconv.Atoi(getURLParam(r, "chunk"))
+	if err != nil {
+		sendAPIResponse(w, r, err, "Invalid chunk index", http.StatusBadRequest)
+		return
+	}
+	transferQuota := connection.GetTransferQuota()
+	if !transferQuota.HasUploadSpace() {
+		connection.Log(logger.LevelInfo, "denying chunk upload due to transfer quota limits")
+		sendAPIResponse(w, r, common.ErrQuotaExceeded, "Denying file write due to transfer quota limits",
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+	connection.UpdateLastActivity()
+	if err := chunkedUploader.writeChunk(upload, index, r.Body); err != nil {
+		status := getRespStatus(err)
+		if status == http.StatusInternalServerError {
+			status = getMappedStatusCode(err)
+		}
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to save chunk %d", index), status)
+		return
+	}
+	sendAPIResponse(w, r, nil, "Chunk saved", http.StatusOK)
+}
+
+func completeChunkedUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, upload, err := getChunkedUpload(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	if err := chunkedUploader.complete(connection, upload); err != nil {
+		status := getMappedStatusCode(err)
+		if errors.Is(err, util.ErrValidation) {
+			status = http.StatusBadRequest
+		}
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to complete the upload for file %q", upload.Path), status)
+		return
+	}
+	connection.Log(logger.LevelDebug, "chunked upload %q completed for file %q", upload.ID, upload.Path)
+	sendAPIResponse(w, r, nil, "Upload completed", http.StatusCreated)
+}
+
+func deleteChunkedUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	connection, upload, err := getChunkedUpload(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	chunkedUploader.remove(upload)
+	sendAPIResponse(w, r, nil, "Upload deleted", http.StatusOK)
+}
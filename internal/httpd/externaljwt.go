@@ -0,0 +1,164 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const defaultExternalJWTRoleField = "sub"
+
+var (
+	externalJWT ExternalJWT
+	// asymmetric algorithms only, the external issuer never shares a secret with us
+	externalJWTSigningAlgs = []string{oidc.RS256, oidc.RS384, oidc.RS512, oidc.ES256, oidc.ES384, oidc.ES512,
+		oidc.PS256, oidc.PS384, oidc.PS512}
+)
+
+// ExternalJWTRoleMapping maps a token claim value to an SFTPGo admin
+type ExternalJWTRoleMapping struct {
+	// Value of the role claim
+	Role string `json:"role" mapstructure:"role"`
+	// Username of the SFTPGo admin the matching tokens are mapped to
+	Admin string `json:"admin" mapstructure:"admin"`
+}
+
+// ExternalJWT defines the configuration to accept, for the REST API, JWTs issued by
+// an external identity provider. This allows automation platforms to authenticate
+// using their workload identities instead of tokens issued by SFTPGo
+type ExternalJWT struct {
+	// Expected token issuer, the "iss" claim. Empty means disabled
+	Issuer string `json:"issuer" mapstructure:"issuer"`
+	// URL of the JSON Web Key Set used to verify the token signatures
+	JWKSURL string `json:"jwks_url" mapstructure:"jwks_url"`
+	// Expected token audience, the "aud" claim
+	Audience string `json:"audience" mapstructure:"audience"`
+	// Token claim to match against the role mappings, "sub" if empty.
+	// Nested claims can be specified using the dot notation, for example "realm_access.roles"
+	RoleField string `json:"role_field" mapstructure:"role_field"`
+	// Mappings between the role claim values and the SFTPGo admins. The first
+	// matching mapping is used, tokens not matching any mapping are rejected
+	RoleMappings []ExternalJWTRoleMapping `json:"role_mappings" mapstructure:"role_mappings"`
+	verifier     OIDCTokenVerifier
+}
+
+func (e *ExternalJWT) isEnabled() bool {
+	return e.verifier != nil
+}
+
+func (e *ExternalJWT) initialize() error {
+	e.verifier = nil
+	if e.Issuer == "" {
+		return nil
+	}
+	if e.JWKSURL == "" {
+		return errors.New("external JWT: JWKS URL cannot be empty")
+	}
+	if e.Audience == "" {
+		return errors.New("external JWT: audience cannot be empty")
+	}
+	if e.RoleField == "" {
+		e.RoleField = defaultExternalJWTRoleField
+	}
+	var mappings []ExternalJWTRoleMapping
+	for idx, m := range e.RoleMappings {
+		if m.Role == "" || m.Admin == "" {
+			return fmt.Errorf("external JWT: role mapping #%d, role and admin are mandatory", idx+1)
+		}
+		mappings = append(mappings, m)
+	}
+	if len(mappings) == 0 {
+		return errors.New("external JWT: at least a role mapping is required")
+	}
+	e.RoleMappings = mappings
+	keySet := oidc.NewRemoteKeySet(context.Background(), e.JWKSURL)
+	e.verifier = oidc.NewVerifier(e.Issuer, keySet, &oidc.Config{
+		ClientID:             e.Audience,
+		SupportedSigningAlgs: externalJWTSigningAlgs,
+	})
+	logger.Info(logSender, "", "external JWT issuer %q enabled, JWKS URL: %q", e.Issuer, e.JWKSURL)
+	return nil
+}
+
+// isIssuedBy returns true if the specified token claims to be issued by the
+// configured issuer. The token is not verified here
+func (e *ExternalJWT) isIssuedBy(rawToken string) bool {
+	token, err := jwt.ParseInsecure([]byte(rawToken))
+	if err != nil {
+		return false
+	}
+	return token.Issuer() == e.Issuer
+}
+
+// getAdminUsername returns the SFTPGo admin mapped to the role claim
+func (e *ExternalJWT) getAdminUsername(claims map[string]any) (string, error) {
+	val, ok := getOIDCFieldFromClaims(claims, e.RoleField)
+	if !ok {
+		return "", fmt.Errorf("claim %q not found", e.RoleField)
+	}
+	var roles []string
+	switch v := val.(type) {
+	case string:
+		roles = append(roles, v)
+	case []any:
+		for _, r := range v {
+			if role, ok := r.(string); ok {
+				roles = append(roles, role)
+			}
+		}
+	}
+	for _, m := range e.RoleMappings {
+		if util.Contains(roles, m.Role) {
+			return m.Admin, nil
+		}
+	}
+	return "", fmt.Errorf("no role mapping found for claim %q, values: %v", e.RoleField, roles)
+}
+
+// authenticate verifies the specified token and returns the mapped admin
+func (e *ExternalJWT) authenticate(ctx context.Context, rawToken, ipAddr string) (dataprovider.Admin, error) {
+	var admin dataprovider.Admin
+
+	idToken, err := e.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return admin, fmt.Errorf("unable to verify token: %w", err)
+	}
+	claims := make(map[string]any)
+	if err := idToken.Claims(&claims); err != nil {
+		return admin, fmt.Errorf("unable to get token claims: %w", err)
+	}
+	username, err := e.getAdminUsername(claims)
+	if err != nil {
+		return admin, err
+	}
+	admin, err = dataprovider.AdminExists(username)
+	if err != nil {
+		return admin, err
+	}
+	if err := admin.CanLogin(ipAddr); err != nil {
+		return admin, err
+	}
+	logger.Debug(logSender, "", "external JWT with subject %q mapped to admin %q", idToken.Subject, admin.Username)
+	return admin, nil
+}
@@ -0,0 +1,887 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/render"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/zip"
+	"github.com/rs/xid"
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	fileJobOperationCopy     = "copy"
+	fileJobOperationMove     = "move"
+	fileJobOperationDelete   = "delete"
+	fileJobOperationChecksum = "checksum"
+	fileJobOperationCompress = "compress"
+	fileJobOperationExtract  = "extract"
+	fileJobStatusRunning     = "running"
+	fileJobStatusCompleted   = "completed"
+	fileJobStatusFailed      = "failed"
+	fileJobStatusCanceled    = "canceled"
+	// max number of running jobs for each user
+	fileJobsMaxRunning = 5
+	// max number of entries for each bulk job
+	fileJobsMaxItems = 10000
+	// finished jobs are removed after this interval
+	fileJobsRetention = time.Hour
+	// max number of entries for a zip archive to extract
+	fileJobsMaxArchiveEntries = 100000
+)
+
+var (
+	fileJobsMgr        = newFileJobsManager()
+	errFileJobNotFound = errors.New("job not found")
+	errFileJobCanceled = errors.New("job canceled")
+	errFileJobsTooMany = errors.New("too many running jobs")
+	fileJobOperations  = []string{fileJobOperationCopy, fileJobOperationMove, fileJobOperationDelete,
+		fileJobOperationChecksum, fileJobOperationCompress, fileJobOperationExtract}
+	fileJobChecksumAlgos = []string{"md5", "sha1", "sha256", "sha512"}
+)
+
+// fileJobResult defines the outcome for a file processed by a checksum job
+type fileJobResult struct {
+	Path     string `json:"path"`
+	Checksum string `json:"checksum,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// fileJobStatus defines the progress for a file job.
+// For bulk jobs source and target are the parent directories of the
+// first entry, for compress jobs the target is the archive path
+type fileJobStatus struct {
+	ID        string `json:"id"`
+	Operation string `json:"operation"`
+	Source    string `json:"source"`
+	Target    string `json:"target,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Status    string `json:"status"`
+	// Number of entries to process, already processed and failed
+	TotalItems  int `json:"total_items"`
+	Items       int `json:"items"`
+	FailedItems int `json:"failed_items"`
+	// Number of files and bytes to process, they are available after
+	// the source path has been scanned
+	TotalFiles int   `json:"total_files"`
+	TotalSize  int64 `json:"total_size"`
+	// Number of files and bytes already processed
+	Files int    `json:"files"`
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+	// Unix timestamps in milliseconds
+	StartTime int64 `json:"start_time"`
+	EndTime   int64 `json:"end_time,omitempty"`
+	// Checksums computed so far, only for checksum jobs
+	Results []fileJobResult `json:"results,omitempty"`
+}
+
+// fileJobItem defines an entry to process within a job
+type fileJobItem struct {
+	source string
+	target string
+	// number of files and bytes found scanning the source path
+	files   int
+	size    int64
+	scanErr error
+}
+
+// fileJob defines a file operation executed in background. Moving
+// between different storage backends or virtual folders is done by copying
+// the source path and then removing it, if the job is canceled or fails
+// while copying, the source path is preserved and the files already copied
+// are left on the target. A failed entry does not stop the other entries,
+// except for compress jobs where the partial archive is removed
+type fileJob struct {
+	username   string
+	connection *Connection
+	canceled   atomic.Bool
+	mu         sync.RWMutex
+	items      []fileJobItem
+	status     fileJobStatus
+}
+
+func (j *fileJob) getStatus() fileJobStatus {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	status := j.status
+	status.Results = slices.Clone(j.status.Results)
+	if status.Status == fileJobStatusRunning {
+		// add the bytes already processed for the files in progress
+		for _, t := range j.connection.GetTransfers() {
+			switch status.Operation {
+			case fileJobOperationChecksum, fileJobOperationCompress:
+				status.Size += t.DLSize
+			default:
+				status.Size += t.ULSize
+			}
+		}
+	}
+	return status
+}
+
+func (j *fileJob) isRunning() bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	return j.status.Status == fileJobStatusRunning
+}
+
+func (j *fileJob) isExpired() bool {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+
+	if j.status.Status == fileJobStatusRunning {
+		return false
+	}
+	return j.status.EndTime < util.GetTimeAsMsSinceEpoch(time.Now().Add(-fileJobsRetention))
+}
+
+func (j *fileJob) setTotals(files int, size int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status.TotalFiles = files
+	j.status.TotalSize = size
+}
+
+func (j *fileJob) onFileDone(_ string, size int64) error {
+	j.addProgress(1, size)
+
+	if j.canceled.Load() {
+		return errFileJobCanceled
+	}
+	return nil
+}
+
+func (j *fileJob) addProgress(files int, size int64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status.Files += files
+	j.status.Size += size
+}
+
+func (j *fileJob) onItemDone(item *fileJobItem, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status.Items++
+	if err == nil || j.canceled.Load() {
+		return
+	}
+	j.status.FailedItems++
+	if j.status.Error == "" {
+		if len(j.items) > 1 {
+			j.status.Error = fmt.Sprintf("%s: %v", item.source, err)
+		} else {
+			j.status.Error = err.Error()
+		}
+	}
+}
+
+func (j *fileJob) cancel() {
+	if j.canceled.CompareAndSwap(false, true) {
+		j.connection.SignalTransfersAbort() //nolint:errcheck
+	}
+}
+
+func (j *fileJob) finish() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status.EndTime = util.GetTimeAsMsSinceEpoch(time.Now())
+	switch {
+	case j.canceled.Load():
+		j.status.Status = fileJobStatusCanceled
+	case j.status.FailedItems > 0:
+		j.status.Status = fileJobStatusFailed
+	default:
+		j.status.Status = fileJobStatusCompleted
+		j.status.TotalFiles = j.status.Files
+		j.status.TotalSize = j.status.Size
+	}
+}
+
+// scan returns the number of regular files and their total size for the
+// specified virtual path
+func (j *fileJob) scan(virtualPath string) (int, int64, error) {
+	info, err := j.connection.Stat(virtualPath, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !info.IsDir() {
+		if info.Mode().IsRegular() {
+			return 1, info.Size(), nil
+		}
+		return 0, 0, nil
+	}
+	entries, err := j.connection.ListDir(virtualPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	var files int
+	var size int64
+	for _, entry := range entries {
+		if j.canceled.Load() {
+			return 0, 0, errFileJobCanceled
+		}
+		if entry.IsDir() {
+			dirFiles, dirSize, err := j.scan(path.Join(virtualPath, entry.Name()))
+			if err != nil {
+				return 0, 0, err
+			}
+			files += dirFiles
+			size += dirSize
+		} else if entry.Mode().IsRegular() {
+			files++
+			size += entry.Size()
+		}
+	}
+	return files, size, nil
+}
+
+func (j *fileJob) run() {
+	defer common.Connections.Remove(j.connection.GetID())
+
+	startTime := time.Now()
+	j.connection.Log(logger.LevelInfo, "%s job %q started, items: %d, source: %q, target: %q", j.status.Operation,
+		j.status.ID, len(j.items), j.status.Source, j.status.Target)
+
+	var totalFiles int
+	var totalSize int64
+	for idx := range j.items {
+		if j.canceled.Load() {
+			break
+		}
+		item := &j.items[idx]
+		item.files, item.size, item.scanErr = j.scan(path.Clean(item.source))
+		totalFiles += item.files
+		totalSize += item.size
+	}
+	j.setTotals(totalFiles, totalSize)
+	j.connection.SetCopyProgressFunc(j.onFileDone)
+	if j.status.Operation == fileJobOperationCompress {
+		j.compress()
+	} else {
+		for idx := range j.items {
+			if j.canceled.Load() {
+				break
+			}
+			item := &j.items[idx]
+			err := item.scanErr
+			if err == nil {
+				err = j.executeItem(item)
+			}
+			j.onItemDone(item, err)
+		}
+	}
+	j.finish()
+	status := j.getStatus()
+	j.connection.Log(logger.LevelInfo, "%s job %q finished, status: %s, items: %d, failed: %d, files: %d, size: %d, elapsed: %s, err: %q",
+		status.Operation, status.ID, status.Status, status.Items, status.FailedItems, status.Files, status.Size,
+		time.Since(startTime), status.Error)
+}
+
+func (j *fileJob) executeItem(item *fileJobItem) error {
+	switch j.status.Operation {
+	case fileJobOperationDelete:
+		if err := j.connection.RemoveAll(item.source); err != nil {
+			return err
+		}
+		j.addProgress(item.files, item.size)
+		return nil
+	case fileJobOperationChecksum:
+		return j.checksum(item)
+	case fileJobOperationExtract:
+		return j.extract(item)
+	case fileJobOperationMove:
+		if j.connection.IsSameResource(item.source, item.target) {
+			if err := j.connection.Rename(item.source, item.target); err != nil {
+				return err
+			}
+			j.addProgress(item.files, item.size)
+			return nil
+		}
+	}
+	return j.execute(item.source, item.target)
+}
+
+func (j *fileJob) execute(source, target string) error {
+	if j.status.Operation == fileJobOperationCopy {
+		return j.connection.Copy(source, target)
+	}
+	if err := j.connection.Copy(source, target); err != nil {
+		return err
+	}
+	if j.canceled.Load() {
+		return errFileJobCanceled
+	}
+	return j.connection.RemoveAll(source)
+}
+
+func (j *fileJob) addResult(result fileJobResult) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.status.Results = append(j.status.Results, result)
+}
+
+func (j *fileJob) getHash() hash.Hash {
+	switch j.status.Algorithm {
+	case "md5":
+		return md5.New()
+	case "sha1":
+		return sha1.New()
+	case "sha512":
+		return sha512.New()
+	default:
+		return sha256.New()
+	}
+}
+
+func (j *fileJob) checksum(item *fileJobItem) error {
+	info, err := j.connection.Stat(item.source, 1)
+	if err == nil && !info.Mode().IsRegular() {
+		err = util.NewValidationError("checksums can only be computed for regular files")
+	}
+	if err == nil {
+		var reader io.ReadCloser
+		reader, err = j.connection.getFileReader(item.source, 0, http.MethodGet, info)
+		if err == nil {
+			h := j.getHash()
+			_, err = io.Copy(h, reader)
+			if errClose := reader.Close(); err == nil {
+				err = errClose
+			}
+			if err == nil {
+				j.addResult(fileJobResult{Path: item.source, Checksum: hex.EncodeToString(h.Sum(nil))})
+				j.addProgress(item.files, item.size)
+				return nil
+			}
+		}
+	}
+	if !j.canceled.Load() {
+		j.addResult(fileJobResult{Path: item.source, Error: err.Error()})
+	}
+	return err
+}
+
+// compress adds all the job items to the target zip archive, the items
+// are in the same directory that is used as archive base dir
+func (j *fileJob) compress() {
+	target := j.items[0].target
+	baseDir := path.Dir(j.items[0].source)
+	writer, err := j.connection.getFileWriter(target)
+	if err != nil {
+		j.onItemDone(&j.items[0], fmt.Errorf("unable to write archive %q: %w", target, err))
+		return
+	}
+	wr := newZipArchiveWriter(writer, flate.DefaultCompression)
+	for idx := range j.items {
+		item := &j.items[idx]
+		err = item.scanErr
+		if err == nil && j.canceled.Load() {
+			err = errFileJobCanceled
+		}
+		if err == nil {
+			err = addZipEntry(wr, j.connection, item.source, baseDir, flate.DefaultCompression)
+		}
+		if err != nil {
+			j.onItemDone(item, err)
+			break
+		}
+		j.addProgress(item.files, item.size)
+		j.onItemDone(item, nil)
+	}
+	if err == nil {
+		err = wr.Close()
+		if err != nil {
+			j.onItemDone(&j.items[len(j.items)-1], fmt.Errorf("unable to close archive %q: %w", target, err))
+		}
+	}
+	if err != nil {
+		writer.Close()                 //nolint:errcheck
+		j.connection.RemoveAll(target) //nolint:errcheck
+		return
+	}
+	if err = writer.Close(); err != nil {
+		j.onItemDone(&j.items[len(j.items)-1], fmt.Errorf("unable to close archive %q: %w", target, err))
+	}
+}
+
+// extract downloads the source archive to a temporary file, zip archives
+// require random access, and extracts its entries inside the target dir
+func (j *fileJob) extract(item *fileJobItem) error {
+	archive, err := os.CreateTemp(common.Config.TempPath, "sftpgo-extract")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		archive.Close()
+		os.Remove(archive.Name())
+	}()
+
+	reader, err := j.connection.getFileReader(item.source, 0, http.MethodGet, nil)
+	if err != nil {
+		return fmt.Errorf("unable to read archive %q: %w", item.source, err)
+	}
+	size, err := io.Copy(archive, reader)
+	if errClose := reader.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(archive, size)
+	if err != nil {
+		return util.NewValidationError(fmt.Sprintf("invalid zip archive %q: %v", item.source, err))
+	}
+	if len(zr.File) > fileJobsMaxArchiveEntries {
+		return util.NewValidationError(fmt.Sprintf("too many archive entries, max allowed: %d", fileJobsMaxArchiveEntries))
+	}
+	var totalFiles int
+	var totalSize int64
+	for _, f := range zr.File {
+		if f.Mode().IsRegular() {
+			totalFiles++
+			totalSize += int64(f.UncompressedSize64)
+		}
+	}
+	j.setTotals(totalFiles, totalSize)
+	if err := j.connection.CheckParentDirs(item.target); err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if j.canceled.Load() {
+			return errFileJobCanceled
+		}
+		if err := j.extractEntry(f, item.target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *fileJob) extractEntry(f *zip.File, targetDir string) error {
+	name, err := getExtractEntryPath(f.Name, targetDir)
+	if err != nil {
+		return err
+	}
+	if f.Mode().IsDir() {
+		return j.connection.CheckParentDirs(name)
+	}
+	if !f.Mode().IsRegular() {
+		j.connection.Log(logger.LevelInfo, "skipping archive entry for non regular file %q", f.Name)
+		return nil
+	}
+	if err := j.connection.CheckParentDirs(path.Dir(name)); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	writer, err := j.connection.getFileWriter(name)
+	if err != nil {
+		return fmt.Errorf("unable to write file %q: %w", name, err)
+	}
+	n, err := io.Copy(writer, src)
+	if err != nil {
+		writer.Close() //nolint:errcheck
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	j.addProgress(1, n)
+	return nil
+}
+
+// getExtractEntryPath returns the virtual path for an archive entry and
+// rejects the entries that would be extracted outside the target dir
+func getExtractEntryPath(entryName, targetDir string) (string, error) {
+	name := util.CleanPath(path.Join(targetDir, entryName))
+	if name != targetDir && !strings.HasPrefix(name, strings.TrimSuffix(targetDir, "/")+"/") {
+		return "", util.NewValidationError(fmt.Sprintf("invalid archive entry %q", entryName))
+	}
+	return name, nil
+}
+
+type fileJobsManager struct {
+	mu   sync.RWMutex
+	jobs map[string]*fileJob
+}
+
+func newFileJobsManager() *fileJobsManager {
+	return &fileJobsManager{
+		jobs: make(map[string]*fileJob),
+	}
+}
+
+func (m *fileJobsManager) start(connection *Connection, operation, source, target string) (fileJobStatus, error) {
+	return m.startBulk(connection, operation, "", []fileJobItem{{source: source, target: target}})
+}
+
+func (m *fileJobsManager) startBulk(connection *Connection, operation, algorithm string, items []fileJobItem,
+) (fileJobStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	running := 0
+	for _, job := range m.jobs {
+		if job.username == connection.GetUsername() && job.isRunning() {
+			running++
+		}
+	}
+	if running >= fileJobsMaxRunning {
+		return fileJobStatus{}, errFileJobsTooMany
+	}
+	source := items[0].source
+	target := items[0].target
+	if len(items) > 1 {
+		source = path.Dir(path.Clean(source))
+		if target != "" && operation != fileJobOperationCompress {
+			target = path.Dir(path.Clean(target))
+		}
+	}
+	job := &fileJob{
+		username:   connection.GetUsername(),
+		connection: connection,
+		items:      items,
+		status: fileJobStatus{
+			ID:         xid.New().String(),
+			Operation:  operation,
+			Source:     source,
+			Target:     target,
+			Algorithm:  algorithm,
+			Status:     fileJobStatusRunning,
+			TotalItems: len(items),
+			StartTime:  util.GetTimeAsMsSinceEpoch(time.Now()),
+		},
+	}
+	m.jobs[job.status.ID] = job
+	go job.run()
+
+	return job.getStatus(), nil
+}
+
+func (m *fileJobsManager) get(username, id string) (*fileJob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok || job.username != username {
+		return nil, util.NewRecordNotFoundError(errFileJobNotFound.Error())
+	}
+	return job, nil
+}
+
+func (m *fileJobsManager) list(username string) []fileJobStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]fileJobStatus, 0)
+	for _, job := range m.jobs {
+		if job.username == username {
+			result = append(result, job.getStatus())
+		}
+	}
+	slices.SortFunc(result, func(a, b fileJobStatus) int {
+		if a.StartTime == b.StartTime {
+			return strings.Compare(a.ID, b.ID)
+		}
+		if a.StartTime < b.StartTime {
+			return -1
+		}
+		return 1
+	})
+	return result
+}
+
+// remove cancels the job with the specified id, if it is running,
+// or removes it if it is already finished
+func (m *fileJobsManager) remove(username, id string) error {
+	job, err := m.get(username, id)
+	if err != nil {
+		return err
+	}
+	if job.isRunning() {
+		job.cancel()
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.jobs, id)
+	return nil
+}
+
+func (m *fileJobsManager) cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, job := range m.jobs {
+		if job.isExpired() {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func startUserFileJob(operation string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+		connection, err := getUserConnection(w, r)
+		if err != nil {
+			return
+		}
+		item, err := getFileJobItem(connection, operation, r.URL.Query().Get("path"), r.URL.Query().Get("target"))
+		if err != nil {
+			common.Connections.Remove(connection.GetID())
+			sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+			return
+		}
+		source := item.source
+		target := item.target
+		// the connection is removed when the job ends
+		status, err := fileJobsMgr.start(connection, operation, source, target)
+		if err != nil {
+			common.Connections.Remove(connection.GetID())
+			sendAPIResponse(w, r, err, fmt.Sprintf("Unable to %s %q => %q", operation, source, target),
+				http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Location", path.Join(webClientFileJobsPath, status.ID))
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, status)
+	}
+}
+
+// fileJobRequest defines a bulk job. The item targets are ignored for delete,
+// checksum and compress jobs, compress jobs use the request target as archive path
+type fileJobRequest struct {
+	Operation string `json:"operation"`
+	Items     []struct {
+		Source string `json:"source"`
+		Target string `json:"target"`
+	} `json:"items"`
+	Target string `json:"target,omitempty"`
+	// Checksum algorithm, sha256 if not set
+	Algorithm string `json:"algorithm,omitempty"`
+}
+
+func (r *fileJobRequest) validate() error {
+	if !slices.Contains(fileJobOperations, r.Operation) {
+		return fmt.Errorf("invalid operation %q", r.Operation)
+	}
+	if len(r.Items) == 0 || len(r.Items) > fileJobsMaxItems {
+		return fmt.Errorf("the number of items must be between 1 and %d", fileJobsMaxItems)
+	}
+	if r.Operation != fileJobOperationChecksum {
+		r.Algorithm = ""
+	} else if r.Algorithm == "" {
+		r.Algorithm = "sha256"
+	}
+	if r.Algorithm != "" && !slices.Contains(fileJobChecksumAlgos, r.Algorithm) {
+		return fmt.Errorf("invalid checksum algorithm %q, supported algorithms: %s", r.Algorithm,
+			strings.Join(fileJobChecksumAlgos, ", "))
+	}
+	switch r.Operation {
+	case fileJobOperationCompress:
+		if !strings.HasSuffix(strings.ToLower(r.Target), ".zip") {
+			return errors.New("the target archive must have the .zip extension")
+		}
+		for idx := range r.Items {
+			r.Items[idx].Target = r.Target
+		}
+	case fileJobOperationExtract:
+		if len(r.Items) != 1 {
+			return errors.New("a single archive can be extracted for each job")
+		}
+		if !strings.HasSuffix(strings.ToLower(r.Items[0].Source), ".zip") {
+			return errors.New("only zip archives can be extracted")
+		}
+	}
+	return nil
+}
+
+// validateCompressItems checks that the entries to compress are in the same
+// directory and that the target archive is not inside any of them
+func validateCompressItems(items []fileJobItem) error {
+	baseDir := path.Dir(items[0].source)
+	for _, item := range items {
+		if path.Dir(item.source) != baseDir {
+			return errors.New("the entries to compress must be in the same directory")
+		}
+		if item.target == item.source || strings.HasPrefix(item.target, item.source+"/") {
+			return errors.New("the target archive cannot be inside the entries to compress")
+		}
+	}
+	return nil
+}
+
+func startUserBulkFileJob(w http.ResponseWriter, r *http.Request) {
+	startFileJobFromRequest(w, r, webClientFileJobsPath)
+}
+
+func startUserAPIFileJob(w http.ResponseWriter, r *http.Request) {
+	startFileJobFromRequest(w, r, userFileJobsPath)
+}
+
+func startFileJobFromRequest(w http.ResponseWriter, r *http.Request, jobsPath string) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxMultipartMem)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	var req fileJobRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	// checksums are read-only, any other operation requires write access
+	if req.Operation != fileJobOperationChecksum && claims.hasPerm(sdk.WebClientWriteDisabled) {
+		sendAPIResponse(w, r, nil, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	items := make([]fileJobItem, 0, len(req.Items))
+	for _, reqItem := range req.Items {
+		item, err := getFileJobItem(connection, req.Operation, reqItem.Source, reqItem.Target)
+		if err != nil {
+			common.Connections.Remove(connection.GetID())
+			sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+			return
+		}
+		items = append(items, item)
+	}
+	if req.Operation == fileJobOperationCompress {
+		if err := validateCompressItems(items); err != nil {
+			common.Connections.Remove(connection.GetID())
+			sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+			return
+		}
+	}
+	// the connection is removed when the job ends
+	status, err := fileJobsMgr.startBulk(connection, req.Operation, req.Algorithm, items)
+	if err != nil {
+		common.Connections.Remove(connection.GetID())
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to start the %s job", req.Operation),
+			http.StatusTooManyRequests)
+		return
+	}
+	w.Header().Set("Location", path.Join(jobsPath, status.ID))
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, status)
+}
+
+func getFileJobItem(connection *Connection, operation, source, target string) (fileJobItem, error) {
+	copyFromSource := strings.HasSuffix(source, "/")
+	copyInTarget := strings.HasSuffix(target, "/")
+	source = connection.User.GetCleanedPath(source)
+	if operation == fileJobOperationDelete || operation == fileJobOperationChecksum {
+		if source == "/" {
+			return fileJobItem{}, errors.New("please set a valid path")
+		}
+		return fileJobItem{source: source}, nil
+	}
+	target = connection.User.GetCleanedPath(target)
+	if operation == fileJobOperationCopy {
+		if copyFromSource {
+			source += "/"
+		}
+		if copyInTarget {
+			target += "/"
+		}
+	}
+	if source == "/" || (target == "/" && (operation == fileJobOperationMove || operation == fileJobOperationCompress)) {
+		return fileJobItem{}, errors.New("please set a valid source and target path")
+	}
+	return fileJobItem{source: source, target: target}, nil
+}
+
+func getUserFileJobs(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	render.JSON(w, r, fileJobsMgr.list(claims.Username))
+}
+
+func getUserFileJob(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	job, err := fileJobsMgr.get(claims.Username, getURLParam(r, "id"))
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	render.JSON(w, r, job.getStatus())
+}
+
+func deleteUserFileJob(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	if err := fileJobsMgr.remove(claims.Username, getURLParam(r, "id")); err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, "Job deleted", http.StatusOK)
+}
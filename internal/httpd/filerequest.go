@@ -0,0 +1,230 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/smtp"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	// max number of file names included in a drop notification
+	fileRequestMaxNotifiedFiles = 100
+)
+
+var (
+	fileRequestNotifier = newFileRequestNotificationManager()
+	// a drop is considered completed if no other files are uploaded by
+	// the same uploader within this interval
+	fileRequestDropInterval = 2 * time.Minute
+)
+
+// shareUploader defines the external user uploading files to a file request share
+type shareUploader struct {
+	Name  string
+	Email string
+}
+
+func (u *shareUploader) String() string {
+	if u.Email == "" {
+		return "anonymous"
+	}
+	return fmt.Sprintf("%s <%s>", u.Name, u.Email)
+}
+
+// getShareUploader returns the uploader info from the upload request
+func getShareUploader(r *http.Request, share *dataprovider.Share) (shareUploader, error) {
+	name, email, err := share.FileRequest.CheckUploader(r.URL.Query().Get("uploader_name"),
+		r.URL.Query().Get("uploader_email"))
+	if err != nil {
+		return shareUploader{}, err
+	}
+	if email != "" && share.EmailVerification.IsEnabled() && !share.EmailVerification.IsEmailAllowed(email) {
+		return shareUploader{}, util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("uploader email %q is not allowed", email)),
+			util.I18nErrorShareUploaderEmail,
+		)
+	}
+	return shareUploader{
+		Name:  name,
+		Email: email,
+	}, nil
+}
+
+// getShareUploadDir returns the directory where the files uploaded by the
+// specified uploader must be stored, the directory is created if missing
+func getShareUploadDir(connection *Connection, share *dataprovider.Share, uploader shareUploader) (string, error) {
+	dirName := share.FileRequest.GetUploaderDir(uploader.Name, uploader.Email)
+	if dirName == "" {
+		return share.Paths[0], nil
+	}
+	uploadDir := path.Join(share.Paths[0], dirName)
+	info, err := connection.Stat(uploadDir, 0)
+	if err == nil {
+		if !info.IsDir() {
+			return "", fmt.Errorf("the uploader path %q is not a directory: %w", uploadDir,
+				connection.GetOpUnsupportedError())
+		}
+		return uploadDir, nil
+	}
+	if !connection.IsNotExistError(err) {
+		return "", err
+	}
+	if err := connection.CreateDir(uploadDir, false); err != nil {
+		return "", err
+	}
+	connection.Log(logger.LevelDebug, "created directory %q for uploader %s, share %q", uploadDir,
+		uploader.String(), share.ShareID)
+	return uploadDir, nil
+}
+
+// checkShareFileRequest returns an error if the owner of the specified share
+// should be notified about the completed drops but it is not possible
+func checkShareFileRequest(share *dataprovider.Share, user *dataprovider.User) error {
+	if share.Scope != dataprovider.ShareScopeWrite || !share.FileRequest.NotifyOwner {
+		return nil
+	}
+	if !smtp.IsEnabled() {
+		return util.NewI18nError(
+			util.NewValidationError("owner notifications require an SMTP server to be configured"),
+			util.I18nErrorShareFileRequestNoSMTP,
+		)
+	}
+	if user.Email == "" {
+		return util.NewI18nError(
+			util.NewValidationError("owner notifications require an email address in your profile"),
+			util.I18nErrorShareFileRequestNoEmail,
+		)
+	}
+	return nil
+}
+
+// fileRequestDrop defines the files uploaded by an uploader in a single session
+type fileRequestDrop struct {
+	shareID   string
+	shareName string
+	username  string
+	uploader  shareUploader
+	uploadDir string
+	files     []string
+	numFiles  int
+	startTime time.Time
+	timer     *time.Timer
+}
+
+type fileRequestNotificationManager struct {
+	mu    sync.Mutex
+	drops map[string]*fileRequestDrop
+}
+
+func newFileRequestNotificationManager() *fileRequestNotificationManager {
+	return &fileRequestNotificationManager{
+		drops: make(map[string]*fileRequestDrop),
+	}
+}
+
+// add records the specified uploaded files, the owner is notified when
+// the drop is completed
+func (m *fileRequestNotificationManager) add(share *dataprovider.Share, uploader shareUploader, uploadDir string,
+	files ...string,
+) {
+	if !share.FileRequest.NotifyOwner || len(files) == 0 {
+		return
+	}
+	key := share.ShareID + "\x00" + uploader.Email + "\x00" + uploader.Name
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	drop, ok := m.drops[key]
+	if !ok {
+		drop = &fileRequestDrop{
+			shareID:   share.ShareID,
+			shareName: share.Name,
+			username:  share.Username,
+			uploader:  uploader,
+			uploadDir: uploadDir,
+			startTime: time.Now(),
+		}
+		drop.timer = time.AfterFunc(fileRequestDropInterval, func() {
+			m.complete(key)
+		})
+		m.drops[key] = drop
+	} else {
+		drop.timer.Reset(fileRequestDropInterval)
+	}
+	drop.numFiles += len(files)
+	for _, f := range files {
+		if len(drop.files) < fileRequestMaxNotifiedFiles {
+			drop.files = append(drop.files, f)
+		}
+	}
+}
+
+func (m *fileRequestNotificationManager) complete(key string) {
+	m.mu.Lock()
+	drop, ok := m.drops[key]
+	delete(m.drops, key)
+	m.mu.Unlock()
+
+	if ok {
+		drop.notify()
+	}
+}
+
+func (d *fileRequestDrop) notify() {
+	user, err := dataprovider.UserExists(d.username, "")
+	if err != nil {
+		logger.Warn(logSender, "", "unable to notify the completed drop for share %q, user %q: %v",
+			d.shareID, d.username, err)
+		return
+	}
+	if user.Email == "" {
+		logger.Warn(logSender, "", "unable to notify the completed drop for share %q, user %q has no email",
+			d.shareID, d.username)
+		return
+	}
+	body := new(bytes.Buffer)
+	data := map[string]any{
+		"ShareName": d.shareName,
+		"Uploader":  d.uploader.String(),
+		"Path":      d.uploadDir,
+		"NumFiles":  d.numFiles,
+		"Files":     d.files,
+		"Truncated": d.numFiles > len(d.files),
+	}
+	if err := smtp.RenderFileRequestTemplate(body, data); err != nil {
+		logger.Warn(logSender, "", "unable to render file request template: %v", err)
+		return
+	}
+	startTime := time.Now()
+	subject := fmt.Sprintf("New files uploaded to share %q", d.shareName)
+	if err := smtp.SendEmail([]string{user.Email}, nil, subject, body.String(), smtp.EmailContentTypeTextHTML); err != nil {
+		logger.Warn(logSender, "", "unable to notify the completed drop for share %q via email: %v, elapsed: %v",
+			d.shareID, err, time.Since(startTime))
+		return
+	}
+	logger.Info(logSender, "", "share %q, completed drop notified to user %q, uploader: %s, files: %d, elapsed: %v",
+		d.shareID, d.username, d.uploader.String(), d.numFiles, time.Since(startTime))
+}
@@ -0,0 +1,418 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/kms"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+// The GraphQL endpoint is read-only, only queries are supported. The object
+// fields are the same returned by the REST API.
+
+const (
+	graphQLDefaultLimit = 100
+	graphQLMaxLimit     = 500
+)
+
+type graphQLContextKey struct{}
+
+var (
+	errGraphQLPermissionDenied = errors.New("permission denied")
+	graphQLEnabled             bool
+	graphQLSchema              graphql.Schema
+	graphQLSecretType          = reflect.TypeOf(kms.Secret{})
+)
+
+// GraphQLConfig defines the configuration for the GraphQL endpoint.
+// The endpoint allows to fetch users and folders, selecting only the required
+// fields, using a single request
+type GraphQLConfig struct {
+	// Set to true to enable the GraphQL endpoint
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+}
+
+func (c *GraphQLConfig) initialize() error {
+	graphQLEnabled = c.Enabled
+	if !c.Enabled {
+		return nil
+	}
+	schema, err := getGraphQLSchema()
+	if err != nil {
+		return fmt.Errorf("unable to create the GraphQL schema: %w", err)
+	}
+	graphQLSchema = schema
+	return nil
+}
+
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// graphQLLong represents 64-bit integers, the GraphQL Int type is 32-bit only
+var graphQLLong = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "Long",
+	Description: "64-bit integer",
+	Serialize: func(value any) any {
+		return value
+	},
+	ParseValue: func(value any) any {
+		return value
+	},
+	ParseLiteral: func(valueAST ast.Value) any {
+		if v, ok := valueAST.(*ast.IntValue); ok {
+			return json.Number(v.Value)
+		}
+		return nil
+	},
+})
+
+// graphQLJSON represents the values without a fixed structure, for example maps
+var graphQLJSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Any JSON value",
+	Serialize: func(value any) any {
+		return value
+	},
+	ParseValue: func(value any) any {
+		return value
+	},
+	ParseLiteral: func(_ ast.Value) any {
+		return nil
+	},
+})
+
+var graphQLOrder = graphql.NewEnum(graphql.EnumConfig{
+	Name: "Order",
+	Values: graphql.EnumValueConfigMap{
+		dataprovider.OrderASC:  &graphql.EnumValueConfig{Value: dataprovider.OrderASC},
+		dataprovider.OrderDESC: &graphql.EnumValueConfig{Value: dataprovider.OrderDESC},
+	},
+})
+
+// graphQLTypesBuilder builds the GraphQL object types from the JSON
+// representation of the Go structs, the secrets are never exposed
+type graphQLTypesBuilder struct {
+	objects map[reflect.Type]graphql.Output
+	names   map[string]reflect.Type
+}
+
+// isGraphQLSecret returns true if the specified type is a secret, a pointer to
+// a secret or a list of secrets
+func isGraphQLSecret(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t == graphQLSecretType
+}
+
+func (b *graphQLTypesBuilder) getTypeName(t reflect.Type) string {
+	name := t.Name()
+	if other, ok := b.names[name]; ok && other != t {
+		// same type name in different packages
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	b.names[name] = t
+	return name
+}
+
+func (b *graphQLTypesBuilder) getOutputType(t reflect.Type) graphql.Output {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.getOutputType(t.Elem())
+	case reflect.String:
+		return graphql.String
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return graphQLLong
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// byte slices are serialized as base64 strings
+			return graphql.String
+		}
+		return graphql.NewList(b.getOutputType(t.Elem()))
+	case reflect.Struct:
+		if objType, ok := b.objects[t]; ok {
+			return objType
+		}
+		if t.NumField() == 0 {
+			b.objects[t] = graphQLJSON
+			return graphQLJSON
+		}
+		// the fields are resolved later to allow recursive types
+		objType := graphql.NewObject(graphql.ObjectConfig{
+			Name: b.getTypeName(t),
+			Fields: graphql.FieldsThunk(func() graphql.Fields {
+				return b.getFields(t)
+			}),
+		})
+		b.objects[t] = objType
+		return objType
+	default:
+		return graphQLJSON
+	}
+}
+
+// getFields returns the fields as serialized by encoding/json, embedded structs
+// without a JSON name are flattened
+func (b *graphQLTypesBuilder) getFields(t reflect.Type) graphql.Fields {
+	fields := graphql.Fields{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		if isGraphQLSecret(field.Type) || name == "password" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			for k, v := range b.getFields(fieldType) {
+				if _, ok := fields[k]; !ok {
+					fields[k] = v
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = &graphql.Field{
+			Type: b.getOutputType(field.Type),
+		}
+	}
+	return fields
+}
+
+func getGraphQLSearchArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"limit": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: graphQLDefaultLimit,
+		},
+		"offset": &graphql.ArgumentConfig{
+			Type:         graphql.Int,
+			DefaultValue: 0,
+		},
+		"order": &graphql.ArgumentConfig{
+			Type:         graphQLOrder,
+			DefaultValue: dataprovider.OrderASC,
+		},
+	}
+}
+
+func getGraphQLSchema() (graphql.Schema, error) {
+	builder := graphQLTypesBuilder{
+		objects: make(map[reflect.Type]graphql.Output),
+		names:   make(map[string]reflect.Type),
+	}
+	userType := builder.getOutputType(reflect.TypeOf(dataprovider.User{}))
+	folderType := builder.getOutputType(reflect.TypeOf(vfs.BaseVirtualFolder{}))
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"users": &graphql.Field{
+				Type:    graphql.NewList(userType),
+				Args:    getGraphQLSearchArgs(),
+				Resolve: resolveGraphQLUsers,
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"username": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: resolveGraphQLUser,
+			},
+			"folders": &graphql.Field{
+				Type:    graphql.NewList(folderType),
+				Args:    getGraphQLSearchArgs(),
+				Resolve: resolveGraphQLFolders,
+			},
+			"folder": &graphql.Field{
+				Type: folderType,
+				Args: graphql.FieldConfigArgument{
+					"name": &graphql.ArgumentConfig{
+						Type: graphql.NewNonNull(graphql.String),
+					},
+				},
+				Resolve: resolveGraphQLFolder,
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query: query,
+	})
+}
+
+func getGraphQLClaims(p graphql.ResolveParams, perm string) (*jwtTokenClaims, error) {
+	claims, ok := p.Context.Value(graphQLContextKey{}).(*jwtTokenClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	if !claims.hasPerm(perm) {
+		return nil, errGraphQLPermissionDenied
+	}
+	return claims, nil
+}
+
+func getGraphQLSearchArguments(p graphql.ResolveParams) (int, int, string, error) {
+	limit, _ := p.Args["limit"].(int)
+	if limit < 0 {
+		return 0, 0, "", errors.New("invalid limit")
+	}
+	offset, _ := p.Args["offset"].(int)
+	if offset < 0 {
+		return 0, 0, "", errors.New("invalid offset")
+	}
+	order, _ := p.Args["order"].(string)
+	return min(limit, graphQLMaxLimit), offset, order, nil
+}
+
+// getGraphQLValue returns the REST API JSON representation of the specified object.
+// Numbers are decoded as json.Number to preserve the 64-bit integers
+func getGraphQLValue(obj any) (any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func resolveGraphQLUsers(p graphql.ResolveParams) (any, error) {
+	claims, err := getGraphQLClaims(p, dataprovider.PermAdminViewUsers)
+	if err != nil {
+		return nil, err
+	}
+	limit, offset, order, err := getGraphQLSearchArguments(p)
+	if err != nil {
+		return nil, err
+	}
+	users, err := dataprovider.GetUsers(limit, offset, order, claims.Role)
+	if err != nil {
+		return nil, err
+	}
+	return getGraphQLValue(claims.filterUsersByScope(users))
+}
+
+func resolveGraphQLUser(p graphql.ResolveParams) (any, error) {
+	claims, err := getGraphQLClaims(p, dataprovider.PermAdminViewUsers)
+	if err != nil {
+		return nil, err
+	}
+	username, _ := p.Args["username"].(string)
+	user, err := dataprovider.UserExists(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
+	if err != nil {
+		if errors.Is(err, util.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	user.PrepareForRendering()
+	return getGraphQLValue(user)
+}
+
+func resolveGraphQLFolders(p graphql.ResolveParams) (any, error) {
+	if _, err := getGraphQLClaims(p, dataprovider.PermAdminViewFolders); err != nil {
+		return nil, err
+	}
+	limit, offset, order, err := getGraphQLSearchArguments(p)
+	if err != nil {
+		return nil, err
+	}
+	folders, err := dataprovider.GetFolders(limit, offset, order, false)
+	if err != nil {
+		return nil, err
+	}
+	return getGraphQLValue(folders)
+}
+
+func resolveGraphQLFolder(p graphql.ResolveParams) (any, error) {
+	if _, err := getGraphQLClaims(p, dataprovider.PermAdminViewFolders); err != nil {
+		return nil, err
+	}
+	name, _ := p.Args["name"].(string)
+	folder, err := dataprovider.GetFolderByName(name)
+	if err != nil {
+		if errors.Is(err, util.ErrNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	folder.PrepareForRendering()
+	return getGraphQLValue(folder)
+}
+
+func handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	var req graphQLRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	result := graphql.Do(graphql.Params{
+		Schema:         graphQLSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        context.WithValue(r.Context(), graphQLContextKey{}, &claims),
+	})
+	if result.Data == nil && result.HasErrors() {
+		// the document is not valid and it was not executed
+		ctx := context.WithValue(r.Context(), render.StatusCtxKey, http.StatusBadRequest)
+		r = r.WithContext(ctx)
+	}
+	render.JSON(w, r, result)
+}
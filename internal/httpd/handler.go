@@ -94,7 +94,9 @@ func (c *Connection) ReadDir(name string) ([]os.FileInfo, error) {
 	return c.ListDir(name)
 }
 
-func (c *Connection) getFileReader(name string, offset int64, method string) (io.ReadCloser, error) {
+// getFileReader returns a reader for the specified file. The file info, if not nil,
+// must be the result of a stat of the same file, it avoids to stat it again
+func (c *Connection) getFileReader(name string, offset int64, method string, info os.FileInfo) (io.ReadCloser, error) {
 	c.UpdateLastActivity()
 
 	transferQuota := c.GetTransferQuota()
@@ -124,9 +126,10 @@ func (c *Connection) getFileReader(name string, offset int64, method string) (io
 		}
 	}
 
-	file, r, cancelFn, err := fs.Open(p, offset)
+	file, r, cancelFn, err := vfs.OpenWithInfo(fs, p, offset, info)
 	if err != nil {
 		c.Log(logger.LevelError, "could not open file %q for reading: %+v", p, err)
+		c.TrackArchiveRestore(p, name, err)
 		return nil, c.GetFsError(fs, err)
 	}
 
@@ -174,6 +177,9 @@ func (c *Connection) getFileWriter(name string) (io.WriteCloser, error) {
 	if !c.User.HasPerm(dataprovider.PermOverwrite, path.Dir(name)) {
 		return nil, c.GetPermissionDeniedError()
 	}
+	if thumbnailer != nil {
+		thumbnailer.invalidate(fs, p)
+	}
 
 	if common.Config.IsAtomicUploadEnabled() && fs.IsAtomicUploadSupported() {
 		_, _, err = fs.Rename(p, filePath)
@@ -213,7 +219,7 @@ func (c *Connection) handleUploadFile(fs vfs.Fs, resolvedPath, filePath, request
 		if vfs.HasTruncateSupport(fs) {
 			vfolder, err := c.User.GetVirtualFolderForPath(path.Dir(requestPath))
 			if err == nil {
-				dataprovider.UpdateVirtualFolderQuota(&vfolder.BaseVirtualFolder, 0, -fileSize, false) //nolint:errcheck
+				dataprovider.UpdateVirtualFolderQuota(&vfolder, 0, -fileSize, false) //nolint:errcheck
 				if vfolder.IsIncludedInUserQuota() {
 					dataprovider.UpdateUserQuota(&c.User, 0, -fileSize, false) //nolint:errcheck
 				}
@@ -38,10 +38,13 @@ import (
 	"github.com/drakkan/sftpgo/v2/internal/common"
 	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
 	"github.com/drakkan/sftpgo/v2/internal/ftpd"
+	"github.com/drakkan/sftpgo/v2/internal/kms"
 	"github.com/drakkan/sftpgo/v2/internal/logger"
 	"github.com/drakkan/sftpgo/v2/internal/mfa"
+	"github.com/drakkan/sftpgo/v2/internal/s3gw"
 	"github.com/drakkan/sftpgo/v2/internal/sftpd"
 	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
 	"github.com/drakkan/sftpgo/v2/internal/webdavd"
 )
 
@@ -51,26 +54,43 @@ const (
 	logoutPath                            = "/api/v2/logout"
 	userTokenPath                         = "/api/v2/user/token"
 	userLogoutPath                        = "/api/v2/user/logout"
+	oidcDeviceAuthPath                    = "/api/v2/oidc/device"
+	oidcDeviceTokenPath                   = "/api/v2/oidc/device/token"
+	oidcRefreshTokenPath                  = "/api/v2/oidc/token/refresh"
 	activeConnectionsPath                 = "/api/v2/connections"
+	connectionDrainsPath                  = "/api/v2/connections/drains"
 	quotasBasePath                        = "/api/v2/quotas"
 	userPath                              = "/api/v2/users"
+	userTemplatePath                      = "/api/v2/templates/users"
+	userBatchPath                         = "/api/v2/batch/users"
+	folderBatchPath                       = "/api/v2/batch/folders"
 	versionPath                           = "/api/v2/version"
 	folderPath                            = "/api/v2/folders"
 	groupPath                             = "/api/v2/groups"
 	serverStatusPath                      = "/api/v2/status"
+	dashboardPath                         = "/api/v2/dashboard"
+	graphQLPath                           = "/api/v2/graphql"
 	dumpDataPath                          = "/api/v2/dumpdata"
 	loadDataPath                          = "/api/v2/loaddata"
 	defenderHosts                         = "/api/v2/defender/hosts"
 	adminPath                             = "/api/v2/admins"
 	adminPwdPath                          = "/api/v2/admin/changepwd"
 	adminProfilePath                      = "/api/v2/admin/profile"
+	adminSessionsPath                     = "/api/v2/admin/sessions"
 	userPwdPath                           = "/api/v2/user/changepwd"
 	userDirsPath                          = "/api/v2/user/dirs"
 	userFilesPath                         = "/api/v2/user/files"
 	userFileActionsPath                   = "/api/v2/user/file-actions"
+	userFileJobsPath                      = "/api/v2/user/file-jobs"
+	userS3CredentialsPath                 = "/api/v2/user/s3-credentials"
+	userTusUploadsPath                    = "/api/v2/user/tus"
+	userFileVersionsPath                  = "/api/v2/user/file-versions"
+	userTrashPath                         = "/api/v2/user/trash"
 	userStreamZipPath                     = "/api/v2/user/streamzip"
 	userUploadFilePath                    = "/api/v2/user/files/upload"
 	userFilesDirsMetadataPath             = "/api/v2/user/files/metadata"
+	userSyncPath                          = "/api/v2/user/sync"
+	userSearchPath                        = "/api/v2/user/search"
 	apiKeysPath                           = "/api/v2/apikeys"
 	adminTOTPConfigsPath                  = "/api/v2/admin/totp/configs"
 	adminTOTPGeneratePath                 = "/api/v2/admin/totp/generate"
@@ -83,19 +103,33 @@ const (
 	userTOTPSavePath                      = "/api/v2/user/totp/save"
 	user2FARecoveryCodesPath              = "/api/v2/user/2fa/recoverycodes"
 	userProfilePath                       = "/api/v2/user/profile"
+	userSessionsPath                      = "/api/v2/user/sessions"
 	userSharesPath                        = "/api/v2/user/shares"
 	retentionBasePath                     = "/api/v2/retention/users"
 	retentionChecksPath                   = "/api/v2/retention/users/checks"
 	metadataBasePath                      = "/api/v2/metadata/users"
 	metadataChecksPath                    = "/api/v2/metadata/users/checks"
+	keyRotationBasePath                   = "/api/v2/keyrotation/users"
+	keyRotationsPath                      = "/api/v2/keyrotation/users/rotations"
+	snapshotsBasePath                     = "/api/v2/snapshots/users"
 	fsEventsPath                          = "/api/v2/events/fs"
 	providerEventsPath                    = "/api/v2/events/provider"
 	logEventsPath                         = "/api/v2/events/logs"
+	auditLogsPath                         = "/api/v2/events/audit"
+	webhookDeliveriesPath                 = "/api/v2/webhooks/deliveries"
+	eventsStreamPath                      = "/api/v2/events/stream"
+	reconcilerPath                        = "/api/v2/reconciler"
+	kmsPath                               = "/api/v2/kms"
+	passwordsMigrationPath                = "/api/v2/passwords/migration"
+	remoteBackupsPath                     = "/api/v2/remotebackups"
 	sharesPath                            = "/api/v2/shares"
 	eventActionsPath                      = "/api/v2/eventactions"
 	eventRulesPath                        = "/api/v2/eventrules"
 	rolesPath                             = "/api/v2/roles"
+	adminRolesPath                        = "/api/v2/adminroles"
 	ipListsPath                           = "/api/v2/iplists"
+	hostCAPath                            = "/api/v2/hostca"
+	userHostCAPath                        = "/api/v2/user/hostca"
 	healthzPath                           = "/healthz"
 	robotsTxtPath                         = "/robots.txt"
 	webRootPathDefault                    = "/"
@@ -119,6 +153,7 @@ const (
 	webGroupsPathDefault                  = "/web/admin/groups"
 	webGroupPathDefault                   = "/web/admin/group"
 	webStatusPathDefault                  = "/web/admin/status"
+	webDashboardPathDefault               = "/web/admin/dashboard"
 	webAdminsPathDefault                  = "/web/admin/managers"
 	webAdminPathDefault                   = "/web/admin/manager"
 	webMaintenancePathDefault             = "/web/admin/maintenance"
@@ -126,6 +161,7 @@ const (
 	webRestorePathDefault                 = "/web/admin/restore"
 	webScanVFolderPathDefault             = "/web/admin/quotas/scanfolder"
 	webQuotaScanPathDefault               = "/web/admin/quotas/scanuser"
+	webImpersonateUserPathDefault         = "/web/admin/impersonate"
 	webChangeAdminPwdPathDefault          = "/web/admin/changepwd"
 	webAdminForgotPwdPathDefault          = "/web/admin/forgot-password"
 	webAdminResetPwdPathDefault           = "/web/admin/reset-password"
@@ -138,6 +174,7 @@ const (
 	webAdminRolesPathDefault              = "/web/admin/roles"
 	webAdminRolePathDefault               = "/web/admin/role"
 	webAdminTOTPGeneratePathDefault       = "/web/admin/totp/generate"
+	webAdminWebAuthnPathDefault           = "/web/admin/webauthn"
 	webAdminTOTPValidatePathDefault       = "/web/admin/totp/validate"
 	webAdminTOTPSavePathDefault           = "/web/admin/totp/save"
 	webAdminRecoveryCodesPathDefault      = "/web/admin/recoverycodes"
@@ -148,6 +185,7 @@ const (
 	webIPListPathDefault                  = "/web/admin/ip-list"
 	webDefenderHostsPathDefault           = "/web/admin/defender/hosts"
 	webEventsPathDefault                  = "/web/admin/events"
+	webWebhooksPathDefault                = "/web/admin/webhooks"
 	webEventsFsSearchPathDefault          = "/web/admin/events/fs"
 	webEventsProviderSearchPathDefault    = "/web/admin/events/provider"
 	webEventsLogSearchPathDefault         = "/web/admin/events/logs"
@@ -159,6 +197,8 @@ const (
 	webClientFilesPathDefault             = "/web/client/files"
 	webClientFilePathDefault              = "/web/client/file"
 	webClientFileActionsPathDefault       = "/web/client/file-actions"
+	webClientFileVersionsPathDefault      = "/web/client/file-versions"
+	webClientTrashPathDefault             = "/web/client/trash"
 	webClientSharesPathDefault            = "/web/client/shares"
 	webClientSharePathDefault             = "/web/client/share"
 	webClientEditFilePathDefault          = "/web/client/editfile"
@@ -168,6 +208,7 @@ const (
 	webClientPingPathDefault              = "/web/client/ping"
 	webClientMFAPathDefault               = "/web/client/mfa"
 	webClientTOTPGeneratePathDefault      = "/web/client/totp/generate"
+	webClientWebAuthnPathDefault          = "/web/client/webauthn"
 	webClientTOTPValidatePathDefault      = "/web/client/totp/validate"
 	webClientTOTPSavePathDefault          = "/web/client/totp/save"
 	webClientRecoveryCodesPathDefault     = "/web/client/recoverycodes"
@@ -179,6 +220,10 @@ const (
 	webClientViewPDFPathDefault           = "/web/client/viewpdf"
 	webClientGetPDFPathDefault            = "/web/client/getpdf"
 	webClientExistPathDefault             = "/web/client/exist"
+	webClientThumbnailPathDefault         = "/web/client/thumbnail"
+	webClientSearchPathDefault            = "/web/client/search"
+	webClientUploadsPathDefault           = "/web/client/uploads"
+	webClientFileJobsPathDefault          = "/web/client/file-jobs"
 	webStaticFilesPathDefault             = "/static"
 	webOpenAPIPathDefault                 = "/openapi"
 	// MaxRestoreSize defines the max size for the loaddata input file
@@ -187,10 +232,14 @@ const (
 	maxLoginBodySize     = 262144       // 256 KB
 	httpdMaxEditFileSize = 2 * 1048576  // 2 MB
 	maxMultipartMem      = 10 * 1048576 // 10 MB
+	maxSyncManifestSize  = 20 * 1048576 // 20 MB
 	osWindows            = "windows"
 	otpHeaderCode        = "X-SFTPGO-OTP"
 	mTimeHeader          = "X-SFTPGO-MTIME"
+	checksumHeader       = "X-SFTPGO-CHECKSUM"
 	acmeChallengeURI     = "/.well-known/acme-challenge/"
+	// login method form value to sign in to the WebClient using the TLS client certificate only
+	webLoginMethodCertificate = "certificate"
 )
 
 var (
@@ -220,6 +269,7 @@ var (
 	webGroupsPath                  string
 	webGroupPath                   string
 	webStatusPath                  string
+	webDashboardPath               string
 	webAdminsPath                  string
 	webAdminPath                   string
 	webMaintenancePath             string
@@ -227,6 +277,7 @@ var (
 	webRestorePath                 string
 	webScanVFolderPath             string
 	webQuotaScanPath               string
+	webImpersonateUserPath         string
 	webAdminProfilePath            string
 	webAdminMFAPath                string
 	webAdminEventRulesPath         string
@@ -236,6 +287,7 @@ var (
 	webAdminRolesPath              string
 	webAdminRolePath               string
 	webAdminTOTPGeneratePath       string
+	webAdminWebAuthnPath           string
 	webAdminTOTPValidatePath       string
 	webAdminTOTPSavePath           string
 	webAdminRecoveryCodesPath      string
@@ -248,6 +300,7 @@ var (
 	webIPListPath                  string
 	webIPListsPath                 string
 	webEventsPath                  string
+	webWebhooksPath                string
 	webEventsFsSearchPath          string
 	webEventsProviderSearchPath    string
 	webEventsLogSearchPath         string
@@ -260,6 +313,8 @@ var (
 	webClientFilesPath             string
 	webClientFilePath              string
 	webClientFileActionsPath       string
+	webClientFileVersionsPath      string
+	webClientTrashPath             string
 	webClientSharesPath            string
 	webClientSharePath             string
 	webClientEditFilePath          string
@@ -270,6 +325,7 @@ var (
 	webChangeClientPwdPath         string
 	webClientMFAPath               string
 	webClientTOTPGeneratePath      string
+	webClientWebAuthnPath          string
 	webClientTOTPValidatePath      string
 	webClientTOTPSavePath          string
 	webClientRecoveryCodesPath     string
@@ -280,6 +336,10 @@ var (
 	webClientViewPDFPath           string
 	webClientGetPDFPath            string
 	webClientExistPath             string
+	webClientThumbnailPath         string
+	webClientSearchPath            string
+	webClientUploadsPath           string
+	webClientFileJobsPath          string
 	webStaticFilesPath             string
 	webOpenAPIPath                 string
 	// max upload size for http clients, 1GB by default
@@ -498,7 +558,10 @@ type Binding struct {
 	CertificateKeyFile string `json:"certificate_key_file" mapstructure:"certificate_key_file"`
 	// Defines the minimum TLS version. 13 means TLS 1.3, default is TLS 1.2
 	MinTLSVersion int `json:"min_tls_version" mapstructure:"min_tls_version"`
-	// set to 1 to require client certificate authentication in addition to basic auth.
+	// Set to 1 to require a client certificate and verify it. Set to 2 to request a
+	// client certificate during the TLS handshake and verify it if given.
+	// A verified certificate can be used to authenticate users and admins, alone
+	// or together with the password.
 	// You need to define at least a certificate authority for this to work
 	ClientAuthType int `json:"client_auth_type" mapstructure:"client_auth_type"`
 	// TLSCipherSuites is a list of supported cipher suites for TLS version 1.2.
@@ -538,7 +601,12 @@ type Binding struct {
 	// Security defines security headers to add to HTTP responses and allows to restrict allowed hosts
 	Security SecurityConf `json:"security" mapstructure:"security"`
 	// Branding defines customizations to suit your brand
-	Branding         Branding `json:"branding" mapstructure:"branding"`
+	Branding Branding `json:"branding" mapstructure:"branding"`
+	// If enabled the permissions for all the users connected to this binding
+	// are restricted to list and download. Admins are not affected
+	ReadOnly bool `json:"read_only" mapstructure:"read_only"`
+	// PublicIndex allows to expose virtual folders as a public, read-only, directory listing
+	PublicIndex      PublicIndex `json:"public_index" mapstructure:"public_index"`
 	allowHeadersFrom []func(net.IP) bool
 }
 
@@ -580,7 +648,7 @@ func (b *Binding) GetAddress() string {
 
 // IsValid returns true if the binding is valid
 func (b *Binding) IsValid() bool {
-	if !b.EnableRESTAPI && !b.EnableWebAdmin && !b.EnableWebClient {
+	if !b.EnableRESTAPI && !b.EnableWebAdmin && !b.EnableWebClient && !b.PublicIndex.isEnabled() {
 		return false
 	}
 	if b.Port > 0 {
@@ -622,6 +690,10 @@ func (b *Binding) isWebAdminLoginFormDisabled() bool {
 	return false
 }
 
+func (b *Binding) isMutualTLSEnabled() bool {
+	return b.ClientAuthType == 1 || b.ClientAuthType == 2
+}
+
 func (b *Binding) isWebClientLoginFormDisabled() bool {
 	if b.EnableWebClient {
 		if b.EnabledLoginMethods == 0 {
@@ -695,11 +767,14 @@ type ServicesStatus struct {
 	SSH          sftpd.ServiceStatus         `json:"ssh"`
 	FTP          ftpd.ServiceStatus          `json:"ftp"`
 	WebDAV       webdavd.ServiceStatus       `json:"webdav"`
+	S3GW         s3gw.ServiceStatus          `json:"s3gw"`
 	DataProvider dataprovider.ProviderStatus `json:"data_provider"`
 	Defender     defenderStatus              `json:"defender"`
 	MFA          mfa.ServiceStatus           `json:"mfa"`
 	AllowList    allowListStatus             `json:"allow_list"`
 	RateLimiters rateLimiters                `json:"rate_limiters"`
+	Dedup        vfs.DedupStatus             `json:"dedup"`
+	KMS          kms.Status                  `json:"kms"`
 }
 
 // SetupConfig defines the configuration parameters for the initial web admin setup
@@ -774,7 +849,17 @@ type Conf struct {
 	Setup SetupConfig `json:"setup" mapstructure:"setup"`
 	// If enabled, the link to the sponsors section will not appear on the setup screen page
 	HideSupportLink bool `json:"hide_support_link" mapstructure:"hide_support_link"`
-	acmeDomain      string
+	// Trust JWTs issued by an external identity provider for the REST API
+	ExternalJWT ExternalJWT `json:"external_jwt" mapstructure:"external_jwt"`
+	// Thumbnails configuration for the WebClient
+	Thumbnails ThumbnailsConfig `json:"thumbnails" mapstructure:"thumbnails"`
+	// Search configuration for the WebClient and the REST API
+	Search common.SearchConfig `json:"search" mapstructure:"search"`
+	// Chunked and resumable uploads configuration for the WebClient
+	ChunkedUploads ChunkedUploadsConfig `json:"chunked_uploads" mapstructure:"chunked_uploads"`
+	// GraphQL endpoint configuration
+	GraphQL    GraphQLConfig `json:"graphql" mapstructure:"graphql"`
+	acmeDomain string
 }
 
 type apiResponse struct {
@@ -811,11 +896,23 @@ func (c *Conf) isWebClientEnabled() bool {
 	return false
 }
 
+func (c *Conf) isPublicIndexEnabled() bool {
+	for _, binding := range c.Bindings {
+		if binding.PublicIndex.isEnabled() {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Conf) checkRequiredDirs(staticFilesPath, templatesPath string) error {
 	if (c.isWebAdminEnabled() || c.isWebClientEnabled()) && (staticFilesPath == "" || templatesPath == "") {
 		return fmt.Errorf("required directory is invalid, static file path: %q template path: %q",
 			staticFilesPath, templatesPath)
 	}
+	if c.isPublicIndexEnabled() && templatesPath == "" {
+		return fmt.Errorf("required directory is invalid, template path: %q", templatesPath)
+	}
 	return nil
 }
 
@@ -925,6 +1022,9 @@ func (c *Conf) loadTemplates(templatesPath string) {
 	} else {
 		logger.Info(logSender, "", "built-in web client interface disabled")
 	}
+	if c.isPublicIndexEnabled() {
+		loadPublicIndexTemplates(templatesPath)
+	}
 }
 
 // Initialize configures and starts the HTTP server
@@ -936,6 +1036,8 @@ func (c *Conf) Initialize(configDir string, isShared int) error {
 	configurationDir = configDir
 	invalidatedJWTTokens = newTokenManager(isShared)
 	resetCodesMgr = newResetCodeManager(isShared)
+	shareVerificationMgr = newShareVerificationManager(isShared)
+	authSessionMgr = newAuthSessionManager(isShared)
 	oidcMgr = newOIDCManager(isShared)
 	oauth2Mgr = newOAuth2Manager(isShared)
 	staticFilesPath := util.FindSharedDataPath(c.StaticFilesPath, configDir)
@@ -972,6 +1074,22 @@ func (c *Conf) Initialize(configDir string, isShared int) error {
 
 	csrfTokenAuth = jwtauth.New(jwa.HS256.String(), getSigningKey(c.SigningPassphrase), nil)
 	hideSupportLink = c.HideSupportLink
+	if err := c.ExternalJWT.initialize(); err != nil {
+		return err
+	}
+	externalJWT = c.ExternalJWT
+	if err := c.Thumbnails.initialize(configDir); err != nil {
+		return err
+	}
+	if err := c.Search.Initialize(); err != nil {
+		return err
+	}
+	if err := c.ChunkedUploads.initialize(configDir); err != nil {
+		return err
+	}
+	if err := c.GraphQL.initialize(); err != nil {
+		return err
+	}
 
 	exitChannel := make(chan error, 1)
 
@@ -1041,6 +1159,7 @@ func getServicesStatus() *ServicesStatus {
 		SSH:          sftpd.GetStatus(),
 		FTP:          ftpd.GetStatus(),
 		WebDAV:       webdavd.GetStatus(),
+		S3GW:         s3gw.GetStatus(),
 		DataProvider: dataprovider.GetProviderStatus(),
 		Defender: defenderStatus{
 			IsActive: common.Config.DefenderConfig.Enabled,
@@ -1053,6 +1172,8 @@ func getServicesStatus() *ServicesStatus {
 			IsActive:  rtlEnabled,
 			Protocols: rtlProtocols,
 		},
+		Dedup: vfs.GetDedupStatus(),
+		KMS:   kms.GetStatus(),
 	}
 	return status
 }
@@ -1090,6 +1211,8 @@ func updateWebClientURLs(baseURL string) {
 	webClientFilesPath = path.Join(baseURL, webClientFilesPathDefault)
 	webClientFilePath = path.Join(baseURL, webClientFilePathDefault)
 	webClientFileActionsPath = path.Join(baseURL, webClientFileActionsPathDefault)
+	webClientFileVersionsPath = path.Join(baseURL, webClientFileVersionsPathDefault)
+	webClientTrashPath = path.Join(baseURL, webClientTrashPathDefault)
 	webClientSharesPath = path.Join(baseURL, webClientSharesPathDefault)
 	webClientPubSharesPath = path.Join(baseURL, webClientPubSharesPathDefault)
 	webClientSharePath = path.Join(baseURL, webClientSharePathDefault)
@@ -1102,6 +1225,7 @@ func updateWebClientURLs(baseURL string) {
 	webClientLogoutPath = path.Join(baseURL, webClientLogoutPathDefault)
 	webClientMFAPath = path.Join(baseURL, webClientMFAPathDefault)
 	webClientTOTPGeneratePath = path.Join(baseURL, webClientTOTPGeneratePathDefault)
+	webClientWebAuthnPath = path.Join(baseURL, webClientWebAuthnPathDefault)
 	webClientTOTPValidatePath = path.Join(baseURL, webClientTOTPValidatePathDefault)
 	webClientTOTPSavePath = path.Join(baseURL, webClientTOTPSavePathDefault)
 	webClientRecoveryCodesPath = path.Join(baseURL, webClientRecoveryCodesPathDefault)
@@ -1110,6 +1234,10 @@ func updateWebClientURLs(baseURL string) {
 	webClientViewPDFPath = path.Join(baseURL, webClientViewPDFPathDefault)
 	webClientGetPDFPath = path.Join(baseURL, webClientGetPDFPathDefault)
 	webClientExistPath = path.Join(baseURL, webClientExistPathDefault)
+	webClientThumbnailPath = path.Join(baseURL, webClientThumbnailPathDefault)
+	webClientSearchPath = path.Join(baseURL, webClientSearchPathDefault)
+	webClientUploadsPath = path.Join(baseURL, webClientUploadsPathDefault)
+	webClientFileJobsPath = path.Join(baseURL, webClientFileJobsPathDefault)
 	webStaticFilesPath = path.Join(baseURL, webStaticFilesPathDefault)
 	webOpenAPIPath = path.Join(baseURL, webOpenAPIPathDefault)
 }
@@ -1138,6 +1266,7 @@ func updateWebAdminURLs(baseURL string) {
 	webGroupsPath = path.Join(baseURL, webGroupsPathDefault)
 	webGroupPath = path.Join(baseURL, webGroupPathDefault)
 	webStatusPath = path.Join(baseURL, webStatusPathDefault)
+	webDashboardPath = path.Join(baseURL, webDashboardPathDefault)
 	webAdminsPath = path.Join(baseURL, webAdminsPathDefault)
 	webAdminPath = path.Join(baseURL, webAdminPathDefault)
 	webMaintenancePath = path.Join(baseURL, webMaintenancePathDefault)
@@ -1145,6 +1274,7 @@ func updateWebAdminURLs(baseURL string) {
 	webRestorePath = path.Join(baseURL, webRestorePathDefault)
 	webScanVFolderPath = path.Join(baseURL, webScanVFolderPathDefault)
 	webQuotaScanPath = path.Join(baseURL, webQuotaScanPathDefault)
+	webImpersonateUserPath = path.Join(baseURL, webImpersonateUserPathDefault)
 	webChangeAdminPwdPath = path.Join(baseURL, webChangeAdminPwdPathDefault)
 	webAdminForgotPwdPath = path.Join(baseURL, webAdminForgotPwdPathDefault)
 	webAdminResetPwdPath = path.Join(baseURL, webAdminResetPwdPathDefault)
@@ -1157,6 +1287,7 @@ func updateWebAdminURLs(baseURL string) {
 	webAdminRolesPath = path.Join(baseURL, webAdminRolesPathDefault)
 	webAdminRolePath = path.Join(baseURL, webAdminRolePathDefault)
 	webAdminTOTPGeneratePath = path.Join(baseURL, webAdminTOTPGeneratePathDefault)
+	webAdminWebAuthnPath = path.Join(baseURL, webAdminWebAuthnPathDefault)
 	webAdminTOTPValidatePath = path.Join(baseURL, webAdminTOTPValidatePathDefault)
 	webAdminTOTPSavePath = path.Join(baseURL, webAdminTOTPSavePathDefault)
 	webAdminRecoveryCodesPath = path.Join(baseURL, webAdminRecoveryCodesPathDefault)
@@ -1167,6 +1298,7 @@ func updateWebAdminURLs(baseURL string) {
 	webIPListPath = path.Join(baseURL, webIPListPathDefault)
 	webIPListsPath = path.Join(baseURL, webIPListsPathDefault)
 	webEventsPath = path.Join(baseURL, webEventsPathDefault)
+	webWebhooksPath = path.Join(baseURL, webWebhooksPathDefault)
 	webEventsFsSearchPath = path.Join(baseURL, webEventsFsSearchPathDefault)
 	webEventsProviderSearchPath = path.Join(baseURL, webEventsProviderSearchPathDefault)
 	webEventsLogSearchPath = path.Join(baseURL, webEventsLogSearchPathDefault)
@@ -1198,6 +1330,17 @@ func startCleanupTicker(duration time.Duration) {
 				counter++
 				invalidatedJWTTokens.Cleanup()
 				resetCodesMgr.Cleanup()
+				shareVerificationMgr.Cleanup()
+				authSessionMgr.Cleanup()
+				deviceAuthMgr.cleanup()
+				if thumbnailer != nil {
+					thumbnailer.cleanup()
+				}
+				common.CleanupSearchIndexes()
+				if chunkedUploader != nil {
+					chunkedUploader.cleanup()
+				}
+				fileJobsMgr.cleanup()
 				if counter%2 == 0 {
 					oidcMgr.cleanup()
 					oauth2Mgr.cleanup()
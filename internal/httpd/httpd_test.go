@@ -16,7 +16,11 @@ package httpd_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -33,6 +37,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -92,8 +97,11 @@ const (
 	adminPwdPath                   = "/api/v2/admin/changepwd"
 	folderPath                     = "/api/v2/folders"
 	groupPath                      = "/api/v2/groups"
+	adminRolesPath                 = "/api/v2/adminroles"
 	activeConnectionsPath          = "/api/v2/connections"
 	serverStatusPath               = "/api/v2/status"
+	graphQLPath                    = "/api/v2/graphql"
+	dashboardPath                  = "/api/v2/dashboard"
 	quotasBasePath                 = "/api/v2/quotas"
 	quotaScanPath                  = "/api/v2/quotas/users/scans"
 	quotaScanVFolderPath           = "/api/v2/quotas/folders/scans"
@@ -104,9 +112,13 @@ const (
 	userDirsPath                   = "/api/v2/user/dirs"
 	userFilesPath                  = "/api/v2/user/files"
 	userFileActionsPath            = "/api/v2/user/file-actions"
+	userFileJobsPath               = "/api/v2/user/file-jobs"
+	userS3CredentialsPath          = "/api/v2/user/s3-credentials"
+	userFileVersionsPath           = "/api/v2/user/file-versions"
 	userStreamZipPath              = "/api/v2/user/streamzip"
 	userUploadFilePath             = "/api/v2/user/files/upload"
 	userFilesDirsMetadataPath      = "/api/v2/user/files/metadata"
+	userSearchPath                 = "/api/v2/user/search"
 	apiKeysPath                    = "/api/v2/apikeys"
 	adminTOTPConfigsPath           = "/api/v2/admin/totp/configs"
 	adminTOTPGeneratePath          = "/api/v2/admin/totp/generate"
@@ -114,18 +126,34 @@ const (
 	adminTOTPSavePath              = "/api/v2/admin/totp/save"
 	admin2FARecoveryCodesPath      = "/api/v2/admin/2fa/recoverycodes"
 	adminProfilePath               = "/api/v2/admin/profile"
+	adminSessionsPath              = "/api/v2/admin/sessions"
 	userTOTPConfigsPath            = "/api/v2/user/totp/configs"
 	userTOTPGeneratePath           = "/api/v2/user/totp/generate"
 	userTOTPValidatePath           = "/api/v2/user/totp/validate"
 	userTOTPSavePath               = "/api/v2/user/totp/save"
 	user2FARecoveryCodesPath       = "/api/v2/user/2fa/recoverycodes"
 	userProfilePath                = "/api/v2/user/profile"
+	userSessionsPath               = "/api/v2/user/sessions"
 	userSharesPath                 = "/api/v2/user/shares"
 	retentionBasePath              = "/api/v2/retention/users"
 	metadataBasePath               = "/api/v2/metadata/users"
+	keyRotationBasePath            = "/api/v2/keyrotation/users"
+	keyRotationsPath               = "/api/v2/keyrotation/users/rotations"
+	snapshotsBasePath              = "/api/v2/snapshots/users"
+	userTrashPath                  = "/api/v2/user/trash"
 	fsEventsPath                   = "/api/v2/events/fs"
 	providerEventsPath             = "/api/v2/events/provider"
 	logEventsPath                  = "/api/v2/events/logs"
+	auditLogsPath                  = "/api/v2/events/audit"
+	eventsStreamPath               = "/api/v2/events/stream"
+	webhookDeliveriesPath          = "/api/v2/webhooks/deliveries"
+	reconcilerPath                 = "/api/v2/reconciler"
+	kmsPath                        = "/api/v2/kms"
+	passwordsMigrationPath         = "/api/v2/passwords/migration"
+	remoteBackupsPath              = "/api/v2/remotebackups"
+	userTemplatePath               = "/api/v2/templates/users"
+	userBatchPath                  = "/api/v2/batch/users"
+	folderBatchPath                = "/api/v2/batch/folders"
 	sharesPath                     = "/api/v2/shares"
 	eventActionsPath               = "/api/v2/eventactions"
 	eventRulesPath                 = "/api/v2/eventrules"
@@ -140,12 +168,14 @@ const (
 	webLogoutPath                  = "/web/admin/logout"
 	webUsersPath                   = "/web/admin/users"
 	webUserPath                    = "/web/admin/user"
+	webImpersonateUserPath         = "/web/admin/impersonate"
 	webGroupsPath                  = "/web/admin/groups"
 	webGroupPath                   = "/web/admin/group"
 	webFoldersPath                 = "/web/admin/folders"
 	webFolderPath                  = "/web/admin/folder"
 	webConnectionsPath             = "/web/admin/connections"
 	webStatusPath                  = "/web/admin/status"
+	webDashboardPath               = "/web/admin/dashboard"
 	webAdminsPath                  = "/web/admin/managers"
 	webAdminPath                   = "/web/admin/manager"
 	webMaintenancePath             = "/web/admin/maintenance"
@@ -170,12 +200,15 @@ const (
 	webAdminRolesPath              = "/web/admin/roles"
 	webAdminRolePath               = "/web/admin/role"
 	webEventsPath                  = "/web/admin/events"
+	webWebhooksPath                = "/web/admin/webhooks"
 	webConfigsPath                 = "/web/admin/configs"
 	webOAuth2TokenPath             = "/web/admin/oauth2/token"
 	webBasePathClient              = "/web/client"
 	webClientLoginPath             = "/web/client/login"
 	webClientFilesPath             = "/web/client/files"
 	webClientEditFilePath          = "/web/client/editfile"
+	webClientSearchPath            = "/web/client/search"
+	webClientFileJobsPath          = "/web/client/file-jobs"
 	webClientDirsPath              = "/web/client/dirs"
 	webClientDownloadZipPath       = "/web/client/downloadzip"
 	webChangeClientPwdPath         = "/web/client/changepwd"
@@ -187,6 +220,7 @@ const (
 	webClientMFAPath               = "/web/client/mfa"
 	webClientTOTPSavePath          = "/web/client/totp/save"
 	webClientSharesPath            = "/web/client/shares"
+	webClientTrashPath             = "/web/client/trash"
 	webClientSharePath             = "/web/client/share"
 	webClientPubSharesPath         = "/web/client/pubshares"
 	webClientForgotPwdPath         = "/web/client/forgot-password"
@@ -388,6 +422,7 @@ func TestMain(m *testing.M) {
 	httpdConf := config.GetHTTPDConfig()
 
 	httpdConf.Bindings[0].Port = 8081
+	httpdConf.GraphQL.Enabled = true
 	httpdConf.Bindings[0].Security = httpd.SecurityConf{
 		Enabled: true,
 		HTTPSProxyHeaders: []httpd.HTTPSProxyHeader{
@@ -1189,6 +1224,85 @@ func TestGroupValidation(t *testing.T) {
 	assert.Contains(t, string(resp), "invalid web client options")
 }
 
+func TestGroupBranding(t *testing.T) {
+	g := getTestGroup()
+	g.UserSettings.Branding = dataprovider.GroupBranding{
+		PrimaryColor: "red",
+	}
+	_, resp, err := httpdtest.AddGroup(g, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid branding primary color")
+	g.UserSettings.Branding.PrimaryColor = "#1b84ff"
+	g.UserSettings.Branding.Logo = "javascript:alert(1)"
+	_, resp, err = httpdtest.AddGroup(g, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid branding logo")
+	g.UserSettings.Branding.Logo = "data:image/png;base64,invalid base64"
+	_, resp, err = httpdtest.AddGroup(g, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "unable to decode the image data")
+	g.UserSettings.Branding.Logo = "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("logo"))
+	g.UserSettings.Branding.FooterLinks = []dataprovider.BrandingLink{
+		{
+			Name: "Help",
+			URL:  "ftp://example.com",
+		},
+	}
+	_, resp, err = httpdtest.AddGroup(g, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid branding footer link URL")
+	g.UserSettings.Branding.FooterLinks[0].URL = "https://example.com/help"
+	g.UserSettings.Branding.Name = "Tenant Name"
+	g.UserSettings.Branding.ShortName = "Tenant"
+	g.UserSettings.Branding.LoginText = "Welcome to the tenant portal"
+	group, _, err := httpdtest.AddGroup(g, http.StatusCreated)
+	assert.NoError(t, err)
+	assert.Equal(t, g.UserSettings.Branding.Logo, group.UserSettings.Branding.Logo)
+	assert.Equal(t, "#1b84ff", group.UserSettings.Branding.PrimaryColor)
+	assert.Len(t, group.UserSettings.Branding.FooterLinks, 1)
+
+	u := getTestUser()
+	u.Groups = []sdk.GroupMapping{
+		{
+			Name: group.Name,
+			Type: sdk.GroupTypePrimary,
+		},
+	}
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, webClientLoginPath+"?tenant="+url.QueryEscape(group.Name), nil)
+	assert.NoError(t, err)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), "Welcome to the tenant portal")
+	assert.Contains(t, rr.Body.String(), "https://example.com/help")
+	assert.Contains(t, rr.Body.String(), "--bs-primary: #1b84ff")
+	req, err = http.NewRequest(http.MethodGet, webClientLoginPath+"?tenant=missing", nil)
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.NotContains(t, rr.Body.String(), "Welcome to the tenant portal")
+
+	webToken, err := getJWTWebClientTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodGet, webClientFilesPath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), g.UserSettings.Branding.Logo)
+	assert.Contains(t, rr.Body.String(), "Tenant Name")
+	assert.Contains(t, rr.Body.String(), "https://example.com/help")
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveGroup(group, http.StatusOK)
+	assert.NoError(t, err)
+}
+
 func TestGroupSettingsOverride(t *testing.T) {
 	mappedPath1 := filepath.Join(os.TempDir(), util.GenerateUniqueID())
 	folderName1 := filepath.Base(mappedPath1)
@@ -1444,6 +1558,93 @@ func TestGroupSettingsOverride(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestNestedGroups(t *testing.T) {
+	g1 := getTestGroup()
+	g1.Name += "_department"
+	g1.UserSettings.HomeDir = filepath.Join(os.TempDir(), "department", "%username%")
+	g1.UserSettings.MaxSessions = 10
+	g1.UserSettings.QuotaFiles = 100
+	g1.UserSettings.Permissions = map[string][]string{
+		"/department": {dataprovider.PermListItems},
+	}
+	g2 := getTestGroup()
+	g2.Name += "_team"
+	g2.UserSettings.MaxSessions = 5
+	g2.UserSettings.Permissions = map[string][]string{
+		"/team": {dataprovider.PermAny},
+	}
+	g2.IncludedGroups = []string{g1.Name}
+	g3 := getTestGroup()
+	g3.Name += "_role"
+	g3.UserSettings.HomeDir = filepath.Join(os.TempDir(), "role", "%username%")
+	g3.IncludedGroups = []string{g2.Name, g1.Name}
+	// the included groups must exist
+	_, _, err := httpdtest.AddGroup(g2, http.StatusBadRequest)
+	assert.NoError(t, err)
+	group1, resp, err := httpdtest.AddGroup(g1, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	group2, resp, err := httpdtest.AddGroup(g2, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	group3, resp, err := httpdtest.AddGroup(g3, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	assert.Equal(t, []string{group2.Name, group1.Name}, group3.IncludedGroups)
+	// cycles are not allowed
+	group1.IncludedGroups = []string{group3.Name}
+	_, resp, err = httpdtest.UpdateGroup(group1, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	assert.Contains(t, string(resp), "creates a cycle")
+	group1.IncludedGroups = []string{group1.Name}
+	_, _, err = httpdtest.UpdateGroup(group1, http.StatusBadRequest)
+	assert.NoError(t, err)
+
+	u := getTestUser()
+	u.Groups = []sdk.GroupMapping{
+		{
+			Name: group3.Name,
+			Type: sdk.GroupTypePrimary,
+		},
+	}
+	user, resp, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	user, err = dataprovider.CheckUserAndPass(defaultUsername, defaultPassword, "", common.ProtocolHTTP)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(os.TempDir(), "role", defaultUsername), user.GetHomeDir())
+	assert.Equal(t, 5, user.MaxSessions)
+	assert.Equal(t, 100, user.QuotaFiles)
+	assert.Equal(t, []string{dataprovider.PermListItems}, user.GetPermissionsForPath("/department"))
+	assert.Equal(t, []string{dataprovider.PermAny}, user.GetPermissionsForPath("/team"))
+	// the home dir is inherited from the included groups if not set
+	group3.UserSettings.HomeDir = ""
+	_, _, err = httpdtest.UpdateGroup(group3, http.StatusOK)
+	assert.NoError(t, err)
+	user, err = dataprovider.CheckUserAndPass(defaultUsername, defaultPassword, "", common.ProtocolHTTP)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(os.TempDir(), "department", defaultUsername), user.GetHomeDir())
+	// included groups of secondary groups are merged as secondary groups
+	u.Groups = []sdk.GroupMapping{
+		{
+			Name: group3.Name,
+			Type: sdk.GroupTypeSecondary,
+		},
+	}
+	_, resp, err = httpdtest.UpdateUser(u, http.StatusOK, "")
+	assert.NoError(t, err, string(resp))
+	user, err = dataprovider.CheckUserAndPass(defaultUsername, defaultPassword, "", common.ProtocolHTTP)
+	assert.NoError(t, err)
+	assert.Equal(t, u.HomeDir, user.GetHomeDir())
+	assert.Equal(t, 0, user.MaxSessions)
+	assert.Equal(t, []string{dataprovider.PermAny}, user.GetPermissionsForPath("/team"))
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveGroup(group3, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveGroup(group2, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveGroup(group1, http.StatusOK)
+	assert.NoError(t, err)
+}
+
 func TestConfigs(t *testing.T) {
 	err := dataprovider.UpdateConfigs(nil, "", "", "")
 	assert.NoError(t, err)
@@ -1794,6 +1995,17 @@ func TestBasicActionRulesHandling(t *testing.T) {
 		},
 	}
 
+	_, _, err = httpdtest.UpdateEventAction(a, http.StatusOK)
+	assert.NoError(t, err)
+	// inline images require HTML content
+	a.Options.EmailConfig.InlineImages = []string{"logo.png"}
+	_, _, err = httpdtest.UpdateEventAction(a, http.StatusBadRequest)
+	assert.NoError(t, err)
+	a.Options.EmailConfig.ContentType = 1
+	a.Options.EmailConfig.InlineImages = []string{"../logo.png"}
+	_, _, err = httpdtest.UpdateEventAction(a, http.StatusBadRequest)
+	assert.NoError(t, err)
+	a.Options.EmailConfig.InlineImages = []string{"logo.png"}
 	_, _, err = httpdtest.UpdateEventAction(a, http.StatusOK)
 	assert.NoError(t, err)
 
@@ -2471,6 +2683,155 @@ func TestEventActionValidation(t *testing.T) {
 	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
 	assert.NoError(t, err)
 	assert.Contains(t, string(resp), "invalid account check mode")
+	action.Type = dataprovider.ActionTypeTiering
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "at least a tiering policy is required")
+	action.Options.TieringConfig.Policies = []dataprovider.FolderTiering{
+		{
+			SourceFolder: "local",
+		},
+	}
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "source and target folders are mandatory")
+	action.Options.TieringConfig.Policies[0].TargetFolder = "local"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "source and target folders must be different")
+	action.Options.TieringConfig.Policies[0].TargetFolder = "s3"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid tiering min age")
+	action.Options.TieringConfig.Policies[0].MinAge = 30
+	action.Options.TieringConfig.Policies[0].AgeMode = 10
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid tiering age mode")
+	action.Options.TieringConfig.Policies[0].AgeMode = dataprovider.TieringAgeAccessed
+	action.Options.TieringConfig.Policies = append(action.Options.TieringConfig.Policies, dataprovider.FolderTiering{
+		SourceFolder: "local",
+		TargetFolder: "s3",
+		MinAge:       10,
+	})
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "duplicated source folder")
+	action.Type = dataprovider.ActionTypeUserArchive
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "the archive folder is mandatory")
+	action.Type = dataprovider.ActionTypeChatNotification
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "unsupported chat platform")
+	action.Options.ChatConfig.Platform = dataprovider.ChatPlatformSlack
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "chat endpoint is required")
+	action.Options.ChatConfig.Endpoint = "ftp://hooks.example.com"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid chat endpoint schema")
+	action.Options.ChatConfig.Endpoint = "https://matrix.example.com"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "chat message is required")
+	action.Options.ChatConfig.Message = "{{Event}}"
+	action.Options.ChatConfig.Platform = dataprovider.ChatPlatformMatrix
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "Matrix room ID is required")
+	action.Options.ChatConfig.Channel = "!room:example.com"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "Matrix access token is required")
+	action.Options.ChatConfig.Token = kms.NewSecret(sdkkms.SecretStatusRedacted, "payload", "", "")
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "cannot save chat configuration with a redacted secret")
+	action.Type = dataprovider.ActionTypeMessagePublish
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "unsupported messaging protocol")
+	action.Options.MessageConfig.Protocol = dataprovider.MessagingProtocolNATS
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "broker URL is required")
+	action.Options.MessageConfig.Endpoint = "mqtt://127.0.0.1:1883"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid broker URL schema")
+	action.Options.MessageConfig.Protocol = dataprovider.MessagingProtocolMQTT
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "topic is required")
+	action.Options.MessageConfig.Topic = "sftpgo/{{Event}}"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "message is required")
+	action.Options.MessageConfig.Message = "{{Name}}"
+	action.Options.MessageConfig.Password = kms.NewSecret(sdkkms.SecretStatusRedacted, "payload", "", "")
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "cannot save messaging configuration with a redacted secret")
+	action.Options.MessageConfig.Protocol = dataprovider.MessagingProtocolKafka
+	action.Options.MessageConfig.Endpoint = "kafka://127.0.0.1:9092,127.0.0.2:9092"
+	action.Options.MessageConfig.Acks = 5
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid Kafka acks")
+	action.Options.MessageConfig.Acks = dataprovider.KafkaAcksAll
+	action.Options.MessageConfig.SASLMechanism = 7
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid Kafka SASL mechanism")
+	action.Options.MessageConfig.SASLMechanism = dataprovider.KafkaSASLPlain
+	action.Type = dataprovider.ActionTypeCloudQueue
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "cloud queue target is required")
+	action.Options.CloudQueueConfig.Target = "projects/p/topics/t"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "unsupported cloud queue service")
+	action.Options.CloudQueueConfig.Service = dataprovider.CloudQueueSQS
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid SQS queue URL")
+	action.Options.CloudQueueConfig.Service = dataprovider.CloudQueueSNS
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid SNS topic ARN")
+	action.Options.CloudQueueConfig.Target = "arn:aws:sns:us-east-1:123456789012:topic"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "AWS region is required")
+	action.Options.CloudQueueConfig.Region = "us-east-1"
+	action.Options.CloudQueueConfig.Endpoint = "ftp://sns.example.com"
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid cloud queue endpoint schema")
+	action.Options.CloudQueueConfig.Endpoint = ""
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "message is required")
+	action.Options.CloudQueueConfig.Service = dataprovider.CloudQueuePubSub
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid Pub/Sub topic")
+	action.Type = dataprovider.ActionTypeUsageReport
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid usage report grouping")
+	action.Options.UsageReportConfig.GroupBy = dataprovider.UsageReportGroupByRole
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "at least an email recipient or a folder is required")
+	action.Options.UsageReportConfig.Recipients = []string{""}
+	_, resp, err = httpdtest.AddEventAction(action, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid email recipients")
 }
 
 func TestEventRuleValidation(t *testing.T) {
@@ -2672,6 +3033,33 @@ func TestEventRuleValidation(t *testing.T) {
 	_, resp, err = httpdtest.AddEventRule(rule, http.StatusBadRequest)
 	assert.NoError(t, err)
 	assert.Contains(t, string(resp), "invalid Identity Provider login event")
+	rule.Trigger = dataprovider.EventTriggerQuotaThreshold
+	_, resp, err = httpdtest.AddEventRule(rule, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "at least one quota threshold is required")
+	rule.Conditions.QuotaThresholds = []int{80, 101}
+	_, resp, err = httpdtest.AddEventRule(rule, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid quota threshold 101")
+	rule.Conditions.QuotaThresholds = []int{95, 80}
+	rule.Conditions.QuotaHysteresis = 80
+	_, resp, err = httpdtest.AddEventRule(rule, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid quota hysteresis")
+	rule.Conditions.QuotaHysteresis = 5
+	rule.Conditions.Options.ProviderObjects = []string{"user", "admin"}
+	_, resp, err = httpdtest.AddEventRule(rule, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "unsupported quota threshold object")
+	rule.Trigger = dataprovider.EventTriggerUserInactivity
+	_, resp, err = httpdtest.AddEventRule(rule, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "invalid inactivity days")
+	rule.Conditions.InactivityDays = 30
+	rule.Conditions.Schedules = nil
+	_, resp, err = httpdtest.AddEventRule(rule, http.StatusBadRequest)
+	assert.NoError(t, err)
+	assert.Contains(t, string(resp), "at least one schedule is required")
 }
 
 func TestUserBandwidthLimits(t *testing.T) {
@@ -3651,71 +4039,491 @@ func TestHTTPStreamZipError(t *testing.T) {
 	assert.NoError(t, err)
 }
 
-func TestBasicAdminHandling(t *testing.T) {
-	// we have one admin by default
-	admins, _, err := httpdtest.GetAdmins(0, 0, http.StatusOK)
+func TestAdminRoles(t *testing.T) {
+	adminRole := dataprovider.AdminRole{
+		Name:        "viewer",
+		Description: "view only admin",
+		Permissions: map[string][]string{
+			dataprovider.AdminPermObjectUsers:   {dataprovider.AdminPermVerbView},
+			dataprovider.AdminPermObjectFolders: {dataprovider.AdminPermVerbView},
+			"invalid":                           {dataprovider.AdminPermVerbView},
+		},
+	}
+	_, _, err := httpdtest.AddAdminRole(adminRole, http.StatusBadRequest)
 	assert.NoError(t, err)
-	assert.GreaterOrEqual(t, len(admins), 1)
-	admin := getTestAdmin()
-	// the default admin already exists
-	_, _, err = httpdtest.AddAdmin(admin, http.StatusConflict)
+	delete(adminRole.Permissions, "invalid")
+	adminRole.Permissions[dataprovider.AdminPermObjectGroups] = []string{dataprovider.AdminPermVerbRun}
+	_, _, err = httpdtest.AddAdminRole(adminRole, http.StatusBadRequest)
 	assert.NoError(t, err)
-
-	admin.Username = altAdminUsername
-	admin.Filters.Preferences.HideUserPageSections = 1 + 4 + 8
-	admin.Filters.Preferences.DefaultUsersExpiration = 30
-	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
+	delete(adminRole.Permissions, dataprovider.AdminPermObjectGroups)
+	adminRole, _, err = httpdtest.AddAdminRole(adminRole, http.StatusCreated)
 	assert.NoError(t, err)
-
-	admin, _, err = httpdtest.GetAdminByUsername(admin.Username, http.StatusOK)
+	_, _, err = httpdtest.AddAdminRole(adminRole, http.StatusConflict)
 	assert.NoError(t, err)
-	assert.True(t, admin.Filters.Preferences.HideGroups())
-	assert.False(t, admin.Filters.Preferences.HideFilesystem())
-	assert.True(t, admin.Filters.Preferences.HideVirtualFolders())
-	assert.True(t, admin.Filters.Preferences.HideProfile())
-	assert.False(t, admin.Filters.Preferences.HideACLs())
-	assert.False(t, admin.Filters.Preferences.HideDiskQuotaAndBandwidthLimits())
-	assert.False(t, admin.Filters.Preferences.HideAdvancedSettings())
-
-	admin.AdditionalInfo = "test info"
-	admin.Filters.Preferences.HideUserPageSections = 16 + 32 + 64
-	admin, _, err = httpdtest.UpdateAdmin(admin, http.StatusOK)
+	_, _, err = httpdtest.GetAdminRoleByName("missing role", http.StatusNotFound)
 	assert.NoError(t, err)
-	assert.Equal(t, "test info", admin.AdditionalInfo)
-	assert.False(t, admin.Filters.Preferences.HideGroups())
-	assert.False(t, admin.Filters.Preferences.HideFilesystem())
-	assert.False(t, admin.Filters.Preferences.HideVirtualFolders())
-	assert.False(t, admin.Filters.Preferences.HideProfile())
-	assert.True(t, admin.Filters.Preferences.HideACLs())
-	assert.True(t, admin.Filters.Preferences.HideDiskQuotaAndBandwidthLimits())
-	assert.True(t, admin.Filters.Preferences.HideAdvancedSettings())
-
-	admins, _, err = httpdtest.GetAdmins(1, 0, http.StatusOK)
+	_, _, err = httpdtest.UpdateAdminRole(dataprovider.AdminRole{Name: "missing role"}, http.StatusNotFound)
 	assert.NoError(t, err)
-	assert.Len(t, admins, 1)
-	assert.NotEqual(t, admin.Username, admins[0].Username)
 
-	admins, _, err = httpdtest.GetAdmins(1, 1, http.StatusOK)
+	folder := vfs.BaseVirtualFolder{
+		Name:       "vfolder_admin_roles",
+		MappedPath: filepath.Join(os.TempDir(), "vfolder_admin_roles"),
+	}
+	folder, _, err = httpdtest.AddFolder(folder, http.StatusCreated)
 	assert.NoError(t, err)
-	assert.Len(t, admins, 1)
-	assert.Equal(t, admin.Username, admins[0].Username)
 
-	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	admin := getTestAdmin()
+	admin.Username = altAdminUsername
+	admin.Password = altAdminPassword
+	admin.Permissions = nil
+	admin.Filters.AdminRoles = []string{"missing role"}
+	_, _, err = httpdtest.AddAdmin(admin, http.StatusBadRequest)
 	assert.NoError(t, err)
-
-	_, err = httpdtest.RemoveAdmin(admin, http.StatusNotFound)
+	admin.Filters.AdminRoles = []string{adminRole.Name}
+	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
 	assert.NoError(t, err)
 
-	admin, _, err = httpdtest.GetAdminByUsername(admin.Username+"123", http.StatusNotFound)
+	token, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
 	assert.NoError(t, err)
-
-	admin.Username = defaultTokenAuthUser
-	_, err = httpdtest.RemoveAdmin(admin, http.StatusBadRequest)
+	req, _ := http.NewRequest(http.MethodGet, path.Join(folderPath, folder.Name), nil)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodGet, userPath, nil)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	asJSON, err := json.Marshal(folder)
 	assert.NoError(t, err)
-}
-
-func TestAdminGroups(t *testing.T) {
-	group1 := getTestGroup()
+	req, _ = http.NewRequest(http.MethodPut, path.Join(folderPath, folder.Name), bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	req, _ = http.NewRequest(http.MethodGet, groupPath, nil)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	// manage implies view
+	adminRole.Permissions[dataprovider.AdminPermObjectGroups] = []string{dataprovider.AdminPermVerbManage}
+	adminRole, _, err = httpdtest.UpdateAdminRole(adminRole, http.StatusOK)
+	assert.NoError(t, err)
+	token, err = getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodGet, groupPath, nil)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	// a role update changes the signature of the admins using the role
+	admin, _, err = httpdtest.GetAdminByUsername(altAdminUsername, http.StatusOK)
+	assert.NoError(t, err)
+	signature := admin.GetSignature()
+	adminRole.Permissions[dataprovider.AdminPermObjectAdmins] = []string{dataprovider.AdminPermVerbManage}
+	adminRole, _, err = httpdtest.UpdateAdminRole(adminRole, http.StatusOK)
+	assert.NoError(t, err)
+	assert.NotEqual(t, signature, admin.GetSignature())
+	// an admin with manage_admins granted by a role can update itself
+	token, err = getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	admin.Description = "self update"
+	asJSON, err = json.Marshal(admin)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPut, path.Join(adminPath, altAdminUsername), bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	// the admin roles can be managed only with the manage_system permission
+	asJSON, err = json.Marshal(adminRole)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPut, path.Join(adminRolesPath, adminRole.Name), bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	_, err = httpdtest.RemoveAdminRole(adminRole, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveAdminRole(adminRole, http.StatusNotFound)
+	assert.NoError(t, err)
+	// the permissions granted by the removed role are lost
+	token, err = getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodGet, userPath, nil)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestAdminUserSelectors(t *testing.T) {
+	admin := getTestAdmin()
+	admin.Username = altAdminUsername
+	admin.Password = altAdminPassword
+	admin.Permissions = []string{dataprovider.PermAdminAddUsers, dataprovider.PermAdminChangeUsers,
+		dataprovider.PermAdminDeleteUsers, dataprovider.PermAdminViewUsers, dataprovider.PermAdminManageAdmins}
+	admin.Filters.UserSelectors = []string{"region"}
+	_, _, err := httpdtest.AddAdmin(admin, http.StatusBadRequest)
+	assert.NoError(t, err)
+	admin.Filters.UserSelectors = []string{"region=emea", "tier=gold", "tier=silver"}
+	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
+	assert.NoError(t, err)
+
+	u1 := getTestUser()
+	u1.Attributes = map[string]string{"region": "emea", "tier": "gold"}
+	user1, _, err := httpdtest.AddUser(u1, http.StatusCreated)
+	assert.NoError(t, err)
+	u2 := getTestUser()
+	u2.Username = altAdminUsername
+	u2.Attributes = map[string]string{"region": "apac", "tier": "gold"}
+	user2, _, err := httpdtest.AddUser(u2, http.StatusCreated)
+	assert.NoError(t, err)
+
+	token, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, userPath, nil)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var users []dataprovider.User
+	err = json.Unmarshal(rr.Body.Bytes(), &users)
+	assert.NoError(t, err)
+	if assert.Len(t, users, 1) {
+		assert.Equal(t, user1.Username, users[0].Username)
+	}
+	req, _ = http.NewRequest(http.MethodGet, path.Join(userPath, user1.Username), nil)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodGet, path.Join(userPath, user2.Username), nil)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	req, _ = http.NewRequest(http.MethodDelete, path.Join(userPath, user2.Username), nil)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	// moving a user outside the admin scope is not allowed
+	user1.Attributes["region"] = "apac"
+	asJSON, err := json.Marshal(user1)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPut, path.Join(userPath, user1.Username), bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "does not match the selectors")
+	// the region attribute is automatically set, tier has multiple allowed values
+	u3 := getTestUser()
+	u3.Username = "scoped_user"
+	u3.Attributes = map[string]string{"tier": "platinum"}
+	asJSON, err = json.Marshal(u3)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPost, userPath, bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	u3.Attributes["tier"] = "silver"
+	asJSON, err = json.Marshal(u3)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPost, userPath, bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	user3, _, err := httpdtest.GetUserByUsername(u3.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, "emea", user3.Attributes["region"])
+	assert.Equal(t, "silver", user3.Attributes["tier"])
+	// admins outside the scope cannot be added
+	a := getTestAdmin()
+	a.Username = "scoped_admin"
+	a.Password = altAdminPassword
+	a.Permissions = []string{dataprovider.PermAdminViewUsers}
+	a.Filters.UserSelectors = []string{"region=emea", "tier=gold", "tier=platinum"}
+	asJSON, err = json.Marshal(a)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPost, adminPath, bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	a.Filters.UserSelectors = []string{"region=emea", "tier=gold"}
+	asJSON, err = json.Marshal(a)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPost, adminPath, bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+
+	_, err = httpdtest.RemoveAdmin(a, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	for _, u := range []dataprovider.User{user1, user2, user3} {
+		_, err = httpdtest.RemoveUser(u, http.StatusOK)
+		assert.NoError(t, err)
+		err = os.RemoveAll(u.GetHomeDir())
+		assert.NoError(t, err)
+	}
+}
+
+func TestUpdateIfMatch(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+	mappedPath := filepath.Join(os.TempDir(), util.GenerateUniqueID())
+	folder, _, err := httpdtest.AddFolder(vfs.BaseVirtualFolder{
+		Name:       filepath.Base(mappedPath),
+		MappedPath: mappedPath,
+	}, http.StatusCreated)
+	assert.NoError(t, err)
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, path.Join(userPath, user.Username), nil)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	// make sure the update timestamp changes
+	time.Sleep(10 * time.Millisecond)
+	user.Description = "updated"
+	asJSON, err := json.Marshal(user)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPut, path.Join(userPath, user.Username), bytes.NewBuffer(asJSON))
+	req.Header.Set("If-Match", etag)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	// the same ETag is now stale
+	req, _ = http.NewRequest(http.MethodPut, path.Join(userPath, user.Username), bytes.NewBuffer(asJSON))
+	req.Header.Set("If-Match", etag)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusPreconditionFailed, rr)
+	// updates without If-Match are always allowed
+	req, _ = http.NewRequest(http.MethodPut, path.Join(userPath, user.Username), bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+
+	req, _ = http.NewRequest(http.MethodGet, path.Join(folderPath, folder.Name), nil)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	etag = rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	folder.Description = "updated"
+	asJSON, err = json.Marshal(folder)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPut, path.Join(folderPath, folder.Name), bytes.NewBuffer(asJSON))
+	req.Header.Set("If-Match", "W/"+etag)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	folder.Description = "updated again"
+	asJSON, err = json.Marshal(folder)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPut, path.Join(folderPath, folder.Name), bytes.NewBuffer(asJSON))
+	req.Header.Set("If-Match", etag)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusPreconditionFailed, rr)
+	folder, _, err = httpdtest.GetFolderByName(folder.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", folder.Description)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestBatchOperations(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	type batchResponse struct {
+		Transactional bool `json:"transactional"`
+		Results       []struct {
+			Status int    `json:"status"`
+			Error  string `json:"error"`
+		} `json:"results"`
+	}
+	executeBatch := func(batchPath string, items any, expectedStatus int) batchResponse {
+		asJSON, err := json.Marshal(items)
+		assert.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodPost, batchPath, bytes.NewBuffer(asJSON))
+		setBearerForReq(req, token)
+		rr := executeRequest(req)
+		checkResponseCode(t, expectedStatus, rr)
+		var resp batchResponse
+		if expectedStatus == http.StatusOK {
+			err = json.Unmarshal(rr.Body.Bytes(), &resp)
+			assert.NoError(t, err)
+		}
+		return resp
+	}
+	executeBatch(userBatchPath, []dataprovider.UserBatchItem{}, http.StatusBadRequest)
+	executeBatch(userBatchPath, []dataprovider.UserBatchItem{{Action: "rename", User: getTestUser()}}, http.StatusBadRequest)
+
+	u1 := getTestUser()
+	u2 := getTestUser()
+	u2.Username = altAdminUsername
+	resp := executeBatch(userBatchPath, []dataprovider.UserBatchItem{
+		{Action: dataprovider.BatchActionAdd, User: u1},
+		{Action: dataprovider.BatchActionAdd, User: u2},
+	}, http.StatusOK)
+	if assert.Len(t, resp.Results, 2) {
+		for _, result := range resp.Results {
+			assert.Equal(t, http.StatusCreated, result.Status, result.Error)
+		}
+	}
+	user1, _, err := httpdtest.GetUserByUsername(u1.Username, http.StatusOK)
+	assert.NoError(t, err)
+	user2, _, err := httpdtest.GetUserByUsername(u2.Username, http.StatusOK)
+	assert.NoError(t, err)
+	// the last item fails
+	u1.Description = "updated"
+	resp = executeBatch(userBatchPath, []dataprovider.UserBatchItem{
+		{Action: dataprovider.BatchActionUpdate, User: u1},
+		{Action: dataprovider.BatchActionDelete, User: dataprovider.User{BaseUser: sdk.BaseUser{Username: u2.Username}}},
+		{Action: dataprovider.BatchActionAdd, User: u1},
+	}, http.StatusOK)
+	if assert.Len(t, resp.Results, 3) {
+		assert.Equal(t, http.StatusConflict, resp.Results[2].Status)
+		if resp.Transactional {
+			assert.Equal(t, http.StatusFailedDependency, resp.Results[0].Status)
+			assert.Equal(t, http.StatusFailedDependency, resp.Results[1].Status)
+			_, _, err = httpdtest.GetUserByUsername(u2.Username, http.StatusOK)
+			assert.NoError(t, err)
+			user, _, err := httpdtest.GetUserByUsername(u1.Username, http.StatusOK)
+			assert.NoError(t, err)
+			assert.Equal(t, user1.Description, user.Description)
+		} else {
+			assert.Equal(t, http.StatusOK, resp.Results[0].Status)
+			assert.Equal(t, http.StatusOK, resp.Results[1].Status)
+			_, _, err = httpdtest.AddUser(u2, http.StatusCreated)
+			assert.NoError(t, err)
+		}
+	}
+	resp = executeBatch(userBatchPath, []dataprovider.UserBatchItem{
+		{Action: dataprovider.BatchActionUpdate, User: u1},
+		{Action: dataprovider.BatchActionDelete, User: dataprovider.User{BaseUser: sdk.BaseUser{Username: u2.Username}}},
+	}, http.StatusOK)
+	if assert.Len(t, resp.Results, 2) {
+		assert.Equal(t, http.StatusOK, resp.Results[0].Status, resp.Results[0].Error)
+		assert.Equal(t, http.StatusOK, resp.Results[1].Status, resp.Results[1].Error)
+	}
+	user, _, err := httpdtest.GetUserByUsername(u1.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", user.Description)
+	_, _, err = httpdtest.GetUserByUsername(u2.Username, http.StatusNotFound)
+	assert.NoError(t, err)
+
+	folderName := util.GenerateUniqueID()
+	resp = executeBatch(folderBatchPath, []dataprovider.FolderBatchItem{
+		{Action: dataprovider.BatchActionAdd, Folder: vfs.BaseVirtualFolder{
+			Name:       folderName,
+			MappedPath: filepath.Join(os.TempDir(), folderName),
+		}},
+	}, http.StatusOK)
+	if assert.Len(t, resp.Results, 1) {
+		assert.Equal(t, http.StatusCreated, resp.Results[0].Status, resp.Results[0].Error)
+	}
+	resp = executeBatch(folderBatchPath, []dataprovider.FolderBatchItem{
+		{Action: dataprovider.BatchActionUpdate, Folder: vfs.BaseVirtualFolder{
+			Name:        folderName,
+			MappedPath:  filepath.Join(os.TempDir(), folderName),
+			Description: "desc",
+		}},
+		{Action: dataprovider.BatchActionDelete, Folder: vfs.BaseVirtualFolder{Name: "missing folder"}},
+	}, http.StatusOK)
+	if assert.Len(t, resp.Results, 2) {
+		assert.Equal(t, http.StatusNotFound, resp.Results[1].Status)
+	}
+	resp = executeBatch(folderBatchPath, []dataprovider.FolderBatchItem{
+		{Action: dataprovider.BatchActionDelete, Folder: vfs.BaseVirtualFolder{Name: folderName}},
+	}, http.StatusOK)
+	if assert.Len(t, resp.Results, 1) {
+		assert.Equal(t, http.StatusOK, resp.Results[0].Status, resp.Results[0].Error)
+	}
+	_, _, err = httpdtest.GetFolderByName(folderName, http.StatusNotFound)
+	assert.NoError(t, err)
+
+	_, err = httpdtest.RemoveUser(user1, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user1.GetHomeDir())
+	assert.NoError(t, err)
+	err = os.RemoveAll(user2.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestBasicAdminHandling(t *testing.T) {
+	// we have one admin by default
+	admins, _, err := httpdtest.GetAdmins(0, 0, http.StatusOK)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, len(admins), 1)
+	admin := getTestAdmin()
+	// the default admin already exists
+	_, _, err = httpdtest.AddAdmin(admin, http.StatusConflict)
+	assert.NoError(t, err)
+
+	admin.Username = altAdminUsername
+	admin.Filters.Preferences.HideUserPageSections = 1 + 4 + 8
+	admin.Filters.Preferences.DefaultUsersExpiration = 30
+	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
+	assert.NoError(t, err)
+
+	admin, _, err = httpdtest.GetAdminByUsername(admin.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.True(t, admin.Filters.Preferences.HideGroups())
+	assert.False(t, admin.Filters.Preferences.HideFilesystem())
+	assert.True(t, admin.Filters.Preferences.HideVirtualFolders())
+	assert.True(t, admin.Filters.Preferences.HideProfile())
+	assert.False(t, admin.Filters.Preferences.HideACLs())
+	assert.False(t, admin.Filters.Preferences.HideDiskQuotaAndBandwidthLimits())
+	assert.False(t, admin.Filters.Preferences.HideAdvancedSettings())
+
+	admin.AdditionalInfo = "test info"
+	admin.Filters.Preferences.HideUserPageSections = 16 + 32 + 64
+	admin, _, err = httpdtest.UpdateAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, "test info", admin.AdditionalInfo)
+	assert.False(t, admin.Filters.Preferences.HideGroups())
+	assert.False(t, admin.Filters.Preferences.HideFilesystem())
+	assert.False(t, admin.Filters.Preferences.HideVirtualFolders())
+	assert.False(t, admin.Filters.Preferences.HideProfile())
+	assert.True(t, admin.Filters.Preferences.HideACLs())
+	assert.True(t, admin.Filters.Preferences.HideDiskQuotaAndBandwidthLimits())
+	assert.True(t, admin.Filters.Preferences.HideAdvancedSettings())
+
+	admins, _, err = httpdtest.GetAdmins(1, 0, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, admins, 1)
+	assert.NotEqual(t, admin.Username, admins[0].Username)
+
+	admins, _, err = httpdtest.GetAdmins(1, 1, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, admins, 1)
+	assert.Equal(t, admin.Username, admins[0].Username)
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusNotFound)
+	assert.NoError(t, err)
+
+	admin, _, err = httpdtest.GetAdminByUsername(admin.Username+"123", http.StatusNotFound)
+	assert.NoError(t, err)
+
+	admin.Username = defaultTokenAuthUser
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusBadRequest)
+	assert.NoError(t, err)
+}
+
+func TestAdminGroups(t *testing.T) {
+	group1 := getTestGroup()
 	group1.Name += "_1"
 	group1, _, err := httpdtest.AddGroup(group1, http.StatusCreated)
 	assert.NoError(t, err)
@@ -4063,6 +4871,158 @@ func TestAdminAllowList(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAdminTLSCertificateFilters(t *testing.T) {
+	a := getTestAdmin()
+	a.Username = altAdminUsername
+	a.Password = altAdminPassword
+	a.Filters.TLSUsername = "invalid"
+	_, _, err := httpdtest.AddAdmin(a, http.StatusBadRequest)
+	assert.NoError(t, err)
+	a.Filters.TLSUsername = sdk.TLSUsernameNone
+	a.Filters.TLSCertAndPassword = true
+	_, _, err = httpdtest.AddAdmin(a, http.StatusBadRequest)
+	assert.NoError(t, err)
+	a.Filters.TLSUsername = sdk.TLSUsernameCN
+	a.Filters.TLSCertAndPassword = false
+	admin, _, err := httpdtest.AddAdmin(a, http.StatusCreated)
+	assert.NoError(t, err)
+	assert.Equal(t, sdk.TLSUsernameCN, admin.Filters.TLSUsername)
+	// without a client certificate the password is enough
+	_, _, err = httpdtest.GetToken(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+
+	admin.Password = altAdminPassword
+	admin.Filters.TLSCertAndPassword = true
+	admin, _, err = httpdtest.UpdateAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	assert.True(t, admin.Filters.TLSCertAndPassword)
+	_, _, err = httpdtest.GetToken(altAdminUsername, altAdminPassword)
+	assert.EqualError(t, err, "wrong status code: got 401 want 200")
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestWebAdminImpersonateUser(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+	a := getTestAdmin()
+	a.Username = altAdminUsername
+	a.Password = altAdminPassword
+	a.Permissions = []string{dataprovider.PermAdminViewUsers}
+	admin, _, err := httpdtest.AddAdmin(a, http.StatusCreated)
+	assert.NoError(t, err)
+
+	webToken, err := getJWTWebTokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	csrfToken, err := getCSRFToken(httpBaseURL + webLoginPath)
+	assert.NoError(t, err)
+	form := make(url.Values)
+	form.Set("mode", "read_only")
+	req, err := http.NewRequest(http.MethodPost, path.Join(webImpersonateUserPath, user.Username),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidCSRF)
+
+	form.Set(csrfFormToken, csrfToken)
+	req, err = http.NewRequest(http.MethodPost, path.Join(webImpersonateUserPath, "missing"),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+
+	req, err = http.NewRequest(http.MethodPost, path.Join(webImpersonateUserPath, user.Username),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, rr)
+	assert.Equal(t, webClientFilesPath, rr.Header().Get("Location"))
+	clientToken, err := getCookieFromResponse(rr)
+	assert.NoError(t, err)
+
+	req, err = http.NewRequest(http.MethodGet, webClientFilesPath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, clientToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), "user.impersonation_banner_ro")
+	// the credentials and the profile cannot be changed in impersonated sessions
+	req, err = http.NewRequest(http.MethodGet, webChangeClientPwdPath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, clientToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	profileForm := make(url.Values)
+	profileForm.Set(csrfFormToken, csrfToken)
+	profileForm.Set("email", "impersonated@example.com")
+	req, err = http.NewRequest(http.MethodPost, webClientProfilePath, bytes.NewBuffer([]byte(profileForm.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, clientToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	// read only sessions cannot create shares
+	req, err = http.NewRequest(http.MethodGet, webClientSharePath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, clientToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	form.Set("mode", "full")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webImpersonateUserPath, user.Username),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, rr)
+	clientToken, err = getCookieFromResponse(rr)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodGet, webClientSharePath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, clientToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), `user.impersonation_banner"`)
+	// logout ends the impersonated session
+	req, err = http.NewRequest(http.MethodGet, webClientLogoutPath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, clientToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, rr)
+	req, err = http.NewRequest(http.MethodGet, webClientSharePath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, clientToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, rr)
+	assert.Equal(t, webClientLoginPath, rr.Header().Get("Location"))
+	// an admin without the required permission cannot impersonate users
+	altToken, err := getJWTWebTokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, path.Join(webImpersonateUserPath, user.Username),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, altToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestUserStatus(t *testing.T) {
 	u := getTestUser()
 	u.Status = 3
@@ -4165,9 +5125,34 @@ func TestAddUserInvalidPerms(t *testing.T) {
 
 func TestAddUserInvalidFilters(t *testing.T) {
 	u := getTestUser()
-	u.Filters.AllowedIP = []string{"192.168.1.0/24", "192.168.2.0"}
+	u.Filters.PushMFA = dataprovider.UserPushMFAConfig{
+		Enabled: true,
+	}
 	_, _, err := httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
+	u.Filters.PushMFA.Protocols = []string{common.ProtocolFTP}
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err)
+	u.Filters.PushMFA = dataprovider.UserPushMFAConfig{}
+	u.Filters.SourceAuthPolicies = []dataprovider.SourceAuthPolicy{
+		{
+			Sources: []string{"192.168.2.0"},
+		},
+	}
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err)
+	u.Filters.SourceAuthPolicies[0].Sources = []string{"192.168.2.0/24"}
+	u.Filters.SourceAuthPolicies[0].DeniedLoginMethods = []string{"invalid"}
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err)
+	u.Filters.SourceAuthPolicies[0].DeniedLoginMethods = nil
+	u.Filters.SourceAuthPolicies[0].TwoFactorAuthProtocols = []string{common.ProtocolWebDAV}
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err)
+	u.Filters.SourceAuthPolicies = nil
+	u.Filters.AllowedIP = []string{"192.168.1.0/24", "192.168.2.0"}
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err)
 	u.Filters.AllowedIP = []string{}
 	u.Filters.DeniedIP = []string{"192.168.3.0/16", "invalid"}
 	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
@@ -4332,6 +5317,60 @@ func TestAddUserInvalidFsConfig(t *testing.T) {
 		assert.Contains(t, string(resp), "invalid download concurrency")
 	}
 	u.FsConfig.S3Config.DownloadConcurrency = 0
+	u.FsConfig.S3Config.UploadMaxMemory = -1
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "invalid upload max memory")
+	}
+	u.FsConfig.S3Config.UploadPartSize = 10
+	u.FsConfig.S3Config.UploadMaxMemory = 8
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "invalid upload max memory")
+	}
+	u.FsConfig.S3Config.UploadPartSize = 0
+	u.FsConfig.S3Config.UploadMaxMemory = 0
+	u.FsConfig.S3Config.ObjectLockMode = "WORM"
+	u.FsConfig.S3Config.ObjectLockRetentionDays = 30
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "invalid object lock mode")
+	}
+	u.FsConfig.S3Config.ObjectLockMode = "governance"
+	u.FsConfig.S3Config.ObjectLockRetentionDays = 0
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "invalid object lock retention days")
+	}
+	u.FsConfig.S3Config.ObjectLockMode = ""
+	u.FsConfig.S3Config.RestoreDays = -1
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "invalid restore days")
+	}
+	u.FsConfig.S3Config.RestoreDays = 7
+	u.FsConfig.S3Config.RestoreTier = "Fast"
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "invalid restore tier")
+	}
+	u.FsConfig.S3Config.RestoreDays = 0
+	u.FsConfig.S3Config.FailoverEndpoints = []vfs.S3FailoverEndpoint{{Endpoint: " ", Region: ""}}
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "endpoint and region cannot be both empty")
+	}
+	u.FsConfig.S3Config.FailoverEndpoints = []vfs.S3FailoverEndpoint{{Endpoint: u.FsConfig.S3Config.Endpoint}}
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "it must differ from the primary endpoint")
+	}
+	u.FsConfig.S3Config.FailoverEndpoints = make([]vfs.S3FailoverEndpoint, 6)
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "too many failover endpoints")
+	}
+	u.FsConfig.S3Config.FailoverEndpoints = nil
 	u.FsConfig.S3Config.Endpoint = ""
 	u.FsConfig.S3Config.Region = ""
 	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
@@ -4360,6 +5399,11 @@ func TestAddUserInvalidFsConfig(t *testing.T) {
 	u.FsConfig.GCSConfig.Credentials = kms.NewSecret(sdkkms.SecretStatusSecretBox, "invalid", "", "")
 	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
+	u.FsConfig.GCSConfig.Credentials = kms.NewEmptySecret()
+	u.FsConfig.GCSConfig.AutomaticCredentials = 1
+	u.FsConfig.GCSConfig.KMSKeyName = "invalid key"
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err)
 
 	u = getTestUser()
 	u.FsConfig.Provider = sdk.AzureBlobFilesystemProvider
@@ -4390,6 +5434,43 @@ func TestAddUserInvalidFsConfig(t *testing.T) {
 	u.FsConfig.AzBlobConfig.UploadPartSize = 101
 	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
+	u.FsConfig.AzBlobConfig.UploadPartSize = 0
+	u.FsConfig.AzBlobConfig.RehydrateTier = "Archive"
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "invalid rehydrate tier")
+	}
+	u.FsConfig.AzBlobConfig.RehydrateTier = "Hot"
+	u.FsConfig.AzBlobConfig.RehydratePriority = "Low"
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "invalid rehydrate priority")
+	}
+	u.FsConfig.AzBlobConfig.RehydratePriority = ""
+	u.FsConfig.AzBlobConfig.TierRules = []vfs.AzBlobTierRule{
+		{
+			Path: "/archive",
+			Tier: "Cold",
+		},
+	}
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "invalid tier rule")
+	}
+	u.FsConfig.AzBlobConfig.TierRules = []vfs.AzBlobTierRule{
+		{
+			Path: "/archive",
+			Tier: "Archive",
+		},
+		{
+			Path: "/archive/",
+			Tier: "Cool",
+		},
+	}
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(resp), "duplicate tier rule")
+	}
 
 	u = getTestUser()
 	u.FsConfig.Provider = sdk.CryptedFilesystemProvider
@@ -4569,663 +5650,1093 @@ func TestMetadataAPIMock(t *testing.T) {
 	checkResponseCode(t, http.StatusNotFound, rr)
 }
 
-func TestRetentionAPI(t *testing.T) {
-	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+func TestKeyRotationAPI(t *testing.T) {
+	u := getTestUser()
+	u.FsConfig.Provider = sdk.CryptedFilesystemProvider
+	u.FsConfig.CryptConfig.Passphrase = kms.NewPlainSecret("old passphrase")
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
 	assert.NoError(t, err)
+	assert.Len(t, user.FsConfig.CryptConfig.OldPassphrases, 0)
 
-	checks, _, err := httpdtest.GetRetentionChecks(http.StatusOK)
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
-	assert.Len(t, checks, 0)
-
-	localFilePath := filepath.Join(user.HomeDir, "testdir", "testfile")
-	err = os.MkdirAll(filepath.Dir(localFilePath), os.ModePerm)
+	req, err := http.NewRequest(http.MethodPost, path.Join(keyRotationBasePath, user.Username, "rotate"), nil)
 	assert.NoError(t, err)
-	err = os.WriteFile(localFilePath, []byte("test data"), os.ModePerm)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	// write a file encrypted with the old passphrase
+	dbUser, err := dataprovider.UserExists(user.Username, "")
 	assert.NoError(t, err)
-
-	folderRetention := []dataprovider.FolderRetention{
-		{
-			Path:            "/",
-			Retention:       0,
-			DeleteEmptyDirs: true,
-		},
-	}
-
-	_, err = httpdtest.StartRetentionCheck(altAdminUsername, folderRetention, http.StatusNotFound)
+	fs, err := dbUser.GetFilesystem(xid.New().String())
 	assert.NoError(t, err)
-
-	resp, err := httpdtest.StartRetentionCheck(user.Username, folderRetention, http.StatusBadRequest)
+	err = os.MkdirAll(user.GetHomeDir(), os.ModePerm)
 	assert.NoError(t, err)
-	assert.Contains(t, string(resp), "Invalid retention check")
-
-	folderRetention[0].Retention = 24
-	_, err = httpdtest.StartRetentionCheck(user.Username, folderRetention, http.StatusAccepted)
+	testFileName := "test_file.dat"
+	testFileContent := []byte("test data")
+	_, w, _, err := fs.Create(filepath.Join(user.GetHomeDir(), testFileName), 0, 0)
+	assert.NoError(t, err)
+	_, err = w.Write(testFileContent)
+	assert.NoError(t, err)
+	err = w.Close()
 	assert.NoError(t, err)
 
-	assert.Eventually(t, func() bool {
-		return len(common.RetentionChecks.Get("")) == 0
-	}, 1000*time.Millisecond, 50*time.Millisecond)
-
-	assert.FileExists(t, localFilePath)
-
-	err = os.Chtimes(localFilePath, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
+	user.FsConfig.CryptConfig.Passphrase = kms.NewPlainSecret("new passphrase")
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
 	assert.NoError(t, err)
+	if assert.Len(t, user.FsConfig.CryptConfig.OldPassphrases, 1) {
+		assert.Equal(t, sdkkms.SecretStatusSecretBox, user.FsConfig.CryptConfig.OldPassphrases[0].GetStatus())
+	}
+	// old passphrases provided on update are ignored
+	user.FsConfig.CryptConfig.OldPassphrases = nil
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	assert.Len(t, user.FsConfig.CryptConfig.OldPassphrases, 1)
 
-	_, err = httpdtest.StartRetentionCheck(user.Username, folderRetention, http.StatusAccepted)
+	req, err = http.NewRequest(http.MethodPost, path.Join(keyRotationBasePath, user.Username, "rotate"), nil)
 	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusAccepted, rr)
 
 	assert.Eventually(t, func() bool {
-		return len(common.RetentionChecks.Get("")) == 0
-	}, 1000*time.Millisecond, 50*time.Millisecond)
-
-	assert.NoFileExists(t, localFilePath)
-	assert.NoDirExists(t, filepath.Dir(localFilePath))
+		req, err := http.NewRequest(http.MethodGet, keyRotationsPath, nil)
+		assert.NoError(t, err)
+		setBearerForReq(req, token)
+		rr := executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+		var resp []any
+		err = json.Unmarshal(rr.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		return len(resp) == 0
+	}, 2000*time.Millisecond, 50*time.Millisecond)
 
-	check := common.RetentionCheck{
-		Folders: folderRetention,
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, user.FsConfig.CryptConfig.OldPassphrases, 0)
+	// the file must be readable using the current passphrase only
+	dbUser, err = dataprovider.UserExists(user.Username, "")
+	assert.NoError(t, err)
+	fs, err = dbUser.GetFilesystem(xid.New().String())
+	assert.NoError(t, err)
+	_, r, cancelFn, err := fs.Open(filepath.Join(user.GetHomeDir(), testFileName), 0)
+	if assert.NoError(t, err) {
+		data, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, testFileContent, data)
+		r.Close()
+		if cancelFn != nil {
+			cancelFn()
+		}
 	}
-	c := common.RetentionChecks.Add(check, &user)
-	assert.NotNil(t, c)
 
-	_, err = httpdtest.StartRetentionCheck(user.Username, folderRetention, http.StatusConflict)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
 	assert.NoError(t, err)
 
-	err = c.Start()
+	req, err = http.NewRequest(http.MethodPost, path.Join(keyRotationBasePath, user.Username, "rotate"), nil)
 	assert.NoError(t, err)
-	assert.Len(t, common.RetentionChecks.Get(""), 0)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+}
 
-	admin := getTestAdmin()
-	admin.Username = altAdminUsername
-	admin.Password = altAdminPassword
-	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
+func TestSnapshotsAPI(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
 	assert.NoError(t, err)
-
-	token, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	// snapshots are disabled
+	req, err := http.NewRequest(http.MethodGet, path.Join(snapshotsBasePath, user.Username), nil)
 	assert.NoError(t, err)
-	req, _ := http.NewRequest(http.MethodPost, retentionBasePath+"/"+user.Username+"/check",
-		bytes.NewBuffer([]byte("invalid json")))
 	setBearerForReq(req, token)
 	rr := executeRequest(req)
-	checkResponseCode(t, http.StatusBadRequest, rr)
+	checkResponseCode(t, http.StatusForbidden, rr)
 
-	asJSON, err := json.Marshal(folderRetention)
+	oldConfig := config.GetCommonConfig()
+	cfg := config.GetCommonConfig()
+	cfg.Snapshots.Path = "relative"
+	err = common.Initialize(cfg, 0)
+	assert.Error(t, err)
+	cfg.Snapshots.Path = filepath.Join(homeBasePath, "snapshots")
+	cfg.Snapshots.Retention = 2
+	err = common.Initialize(cfg, 0)
 	assert.NoError(t, err)
-	req, _ = http.NewRequest(http.MethodPost, retentionBasePath+"/"+user.Username+"/check?notifications=Email,",
-		bytes.NewBuffer(asJSON))
-	setBearerForReq(req, token)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusBadRequest, rr)
-	assert.Contains(t, rr.Body.String(), "to notify results via email")
 
-	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	file1 := filepath.Join(user.GetHomeDir(), "file1.txt")
+	file2 := filepath.Join(user.GetHomeDir(), "sub", "file2.txt")
+	err = os.MkdirAll(filepath.Dir(file2), os.ModePerm)
 	assert.NoError(t, err)
-	req, _ = http.NewRequest(http.MethodPost, retentionBasePath+"/"+user.Username+"/check?notifications=Email",
-		bytes.NewBuffer(asJSON))
-	setBearerForReq(req, token)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusNotFound, rr)
-
-	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	err = os.WriteFile(file1, []byte("data1"), 0666)
 	assert.NoError(t, err)
-	err = os.RemoveAll(user.GetHomeDir())
+	err = os.WriteFile(file2, []byte("data2"), 0666)
 	assert.NoError(t, err)
-}
 
-func TestAddUserInvalidVirtualFolders(t *testing.T) {
-	u := getTestUser()
-	folderName := "fname"
-	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			MappedPath: filepath.Join(os.TempDir(), "mapped_dir"),
-			Name:       folderName,
-		},
-		VirtualPath: "/vdir",
-	})
-	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
-			Name:       folderName + "1",
-		},
-		VirtualPath: "/vdir", // invalid, already defined
-	})
-	_, _, err := httpdtest.AddUser(u, http.StatusBadRequest)
+	req, err = http.NewRequest(http.MethodPost, path.Join(snapshotsBasePath, user.Username), nil)
 	assert.NoError(t, err)
-	u.VirtualFolders = nil
-	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			MappedPath: filepath.Join(os.TempDir(), "mapped_dir"),
-			Name:       folderName,
-		},
-		VirtualPath: "/vdir1",
-	})
-	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			MappedPath: filepath.Join(os.TempDir(), "mapped_dir"),
-			Name:       folderName, // invalid, unique constraint (user.id, folder.id) violated
-		},
-		VirtualPath: "/vdir2",
-	})
-	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	var snapshot common.Snapshot
+	err = json.Unmarshal(rr.Body.Bytes(), &snapshot)
 	assert.NoError(t, err)
-	u.VirtualFolders = nil
-	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
-			Name:       folderName + "1",
-		},
-		VirtualPath: "/vdir1/",
-		QuotaSize:   -1,
-		QuotaFiles:  1, // invvalid, we cannot have -1 and > 0
-	})
-	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NotEmpty(t, snapshot.ID)
+	assert.Greater(t, snapshot.CreatedAt, int64(0))
+	assert.Equal(t, 2, snapshot.Files)
+	assert.Equal(t, int64(10), snapshot.Size)
+	assert.Equal(t, path.Join(snapshotsBasePath, user.Username, snapshot.ID), rr.Header().Get("Location"))
+	// replace the first file and remove the second one
+	err = os.Remove(file1)
 	assert.NoError(t, err)
-	u.VirtualFolders = nil
-	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
-			Name:       folderName + "1",
-		},
-		VirtualPath: "/vdir1/",
-		QuotaSize:   1,
-		QuotaFiles:  -1,
-	})
-	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	err = os.WriteFile(file1, []byte("modified"), 0666)
 	assert.NoError(t, err)
-	u.VirtualFolders = nil
-	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
-			Name:       folderName + "1",
-		},
-		VirtualPath: "/vdir1/",
-		QuotaSize:   -2, // invalid
-		QuotaFiles:  0,
-	})
-	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	err = os.Remove(file2)
 	assert.NoError(t, err)
-	u.VirtualFolders = nil
-	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
-			Name:       folderName + "1",
-		},
-		VirtualPath: "/vdir1/",
-		QuotaSize:   0,
-		QuotaFiles:  -2, // invalid
-	})
-	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+
+	req, err = http.NewRequest(http.MethodPost, path.Join(snapshotsBasePath, user.Username, snapshot.ID, "restore")+
+		"?path=/sub", nil)
 	assert.NoError(t, err)
-	u.VirtualFolders = nil
-	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			MappedPath: filepath.Join(os.TempDir(), "mapped_dir"),
-		},
-		VirtualPath: "/vdir1",
-	})
-	// folder name is mandatory
-	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	data, err := os.ReadFile(file2)
 	assert.NoError(t, err)
-}
+	assert.Equal(t, []byte("data2"), data)
+	data, err = os.ReadFile(file1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("modified"), data)
 
-func TestUserPublicKey(t *testing.T) {
-	u := getTestUser()
-	u.Password = ""
-	invalidPubKey := "invalid"
-	u.PublicKeys = []string{invalidPubKey}
-	_, _, err := httpdtest.AddUser(u, http.StatusBadRequest)
+	req, err = http.NewRequest(http.MethodPost, path.Join(snapshotsBasePath, user.Username, snapshot.ID, "restore"), nil)
 	assert.NoError(t, err)
-	u.PublicKeys = []string{testPubKey}
-	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	data, err = os.ReadFile(file1)
 	assert.NoError(t, err)
+	assert.Equal(t, []byte("data1"), data)
 
-	dbUser, err := dataprovider.UserExists(u.Username, "")
+	req, err = http.NewRequest(http.MethodPost, path.Join(snapshotsBasePath, user.Username, snapshot.ID, "restore")+
+		"?path=/missing", nil)
 	assert.NoError(t, err)
-	assert.Empty(t, dbUser.Password)
-	assert.False(t, dbUser.IsPasswordHashed())
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
 
-	user.PublicKeys = []string{testPubKey, invalidPubKey}
-	_, _, err = httpdtest.UpdateUser(user, http.StatusBadRequest, "")
+	req, err = http.NewRequest(http.MethodPost, path.Join(snapshotsBasePath, user.Username, "invalid", "restore"), nil)
 	assert.NoError(t, err)
-	user.PublicKeys = []string{testPubKey, testPubKey, testPubKey}
-	user.Password = defaultPassword
-	_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	// the oldest snapshots exceeding the retention are removed
+	for i := 0; i < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		req, err = http.NewRequest(http.MethodPost, path.Join(snapshotsBasePath, user.Username), nil)
+		assert.NoError(t, err)
+		setBearerForReq(req, token)
+		rr = executeRequest(req)
+		checkResponseCode(t, http.StatusCreated, rr)
+	}
+	req, err = http.NewRequest(http.MethodGet, path.Join(snapshotsBasePath, user.Username), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var snapshots []common.Snapshot
+	err = json.Unmarshal(rr.Body.Bytes(), &snapshots)
 	assert.NoError(t, err)
+	if assert.Len(t, snapshots, 2) {
+		assert.Greater(t, snapshots[0].ID, snapshots[1].ID)
+		assert.Greater(t, snapshots[1].ID, snapshot.ID)
+	}
 
-	dbUser, err = dataprovider.UserExists(u.Username, "")
+	req, err = http.NewRequest(http.MethodDelete, path.Join(snapshotsBasePath, user.Username, snapshots[0].ID), nil)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, dbUser.Password)
-	assert.True(t, dbUser.IsPasswordHashed())
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+
+	req, err = http.NewRequest(http.MethodPost, path.Join(snapshotsBasePath, "missinguser"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
 
 	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+	err = os.RemoveAll(cfg.Snapshots.Path)
+	assert.NoError(t, err)
+	err = common.Initialize(oldConfig, 0)
+	assert.NoError(t, err)
 }
 
-func TestUpdateUserEmptyPassword(t *testing.T) {
+func TestUserTrash(t *testing.T) {
 	u := getTestUser()
-	u.PublicKeys = []string{testPubKey}
+	u.QuotaFiles = 100
 	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
 	assert.NoError(t, err)
-
-	// the password is not empty
-	dbUser, err := dataprovider.UserExists(u.Username, "")
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, dbUser.Password)
-	assert.True(t, dbUser.IsPasswordHashed())
-	// now update the user and set an empty password
-	data, err := json.Marshal(dbUser)
+	// the trash is disabled
+	req, err := http.NewRequest(http.MethodGet, userTrashPath, nil)
 	assert.NoError(t, err)
-	var customUser map[string]any
-	err = json.Unmarshal(data, &customUser)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	oldConfig := config.GetCommonConfig()
+	cfg := config.GetCommonConfig()
+	cfg.TrashRetention = 1
+	err = common.Initialize(cfg, 0)
 	assert.NoError(t, err)
-	customUser["password"] = ""
-	asJSON, err := json.Marshal(customUser)
+
+	err = os.MkdirAll(filepath.Join(user.GetHomeDir(), "sub"), os.ModePerm)
 	assert.NoError(t, err)
-	userNoPwd, _, err := httpdtest.UpdateUserWithJSON(user, http.StatusOK, "", asJSON)
+	for _, name := range []string{"file1.txt", "file2.txt", path.Join("sub", "file3.txt")} {
+		err = os.WriteFile(filepath.Join(user.GetHomeDir(), name), []byte("data"), 0666)
+		assert.NoError(t, err)
+	}
+	err = dataprovider.UpdateUserQuota(&user, 3, 12, true)
 	assert.NoError(t, err)
-	assert.Equal(t, user.Password, userNoPwd.Password) // the password is hidden
-	// check the password within the data provider
-	dbUser, err = dataprovider.UserExists(u.Username, "")
+	for _, name := range []string{"file1.txt", "file2.txt", "sub/file3.txt"} {
+		req, err = http.NewRequest(http.MethodDelete, userFilesPath+"?path="+url.QueryEscape(name), nil)
+		assert.NoError(t, err)
+		setBearerForReq(req, token)
+		rr = executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+		assert.NoFileExists(t, filepath.Join(user.GetHomeDir(), name))
+	}
+	// the trash directory is hidden and cannot be accessed
+	req, err = http.NewRequest(http.MethodGet, userDirsPath+"/?path=%2F", nil)
 	assert.NoError(t, err)
-	assert.Empty(t, dbUser.Password)
-	assert.False(t, dbUser.IsPasswordHashed())
-
-	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.NotContains(t, rr.Body.String(), ".trash")
+	req, err = http.NewRequest(http.MethodGet, userDirsPath+"/?path=%2F.trash", nil)
 	assert.NoError(t, err)
-}
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
 
-func TestUpdateUserNoPassword(t *testing.T) {
-	u := getTestUser()
-	u.PublicKeys = []string{testPubKey}
-	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	req, err = http.NewRequest(http.MethodGet, userTrashPath, nil)
 	assert.NoError(t, err)
-	// the password is not empty
-	dbUser, err := dataprovider.UserExists(u.Username, "")
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var items []common.TrashItem
+	err = json.Unmarshal(rr.Body.Bytes(), &items)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, dbUser.Password)
-	assert.True(t, dbUser.IsPasswordHashed())
-	// now update the user and remove the password field, old password should be preserved
-	user.Password = "" // password has the omitempty tag
-	_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.Len(t, items, 3)
+	itemsByPath := make(map[string]common.TrashItem)
+	for _, item := range items {
+		assert.Equal(t, int64(4), item.Size)
+		assert.Greater(t, item.ExpiresAt, item.DeletedAt)
+		itemsByPath[item.Path] = item
+	}
+	// trashed files still count against the quota
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
 	assert.NoError(t, err)
-	// the password is preserved
-	dbUser, err = dataprovider.UserExists(u.Username, "")
+	assert.Equal(t, 3, user.UsedQuotaFiles)
+	assert.Equal(t, int64(12), user.UsedQuotaSize)
+	// the trash metadata are excluded from quota scans
+	adminToken, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, dbUser.Password)
-	assert.True(t, dbUser.IsPasswordHashed())
-
-	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	_, err = httpdtest.StartQuotaScan(user, http.StatusAccepted)
 	assert.NoError(t, err)
-}
+	waitForUsersQuotaScan(t, adminToken)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, user.UsedQuotaFiles)
+	assert.Equal(t, int64(12), user.UsedQuotaSize)
 
-func TestUpdateUser(t *testing.T) {
-	u := getTestUser()
-	u.UsedQuotaFiles = 1
-	u.UsedQuotaSize = 2
-	u.Filters.TLSUsername = sdk.TLSUsernameCN
-	u.Filters.Hooks.CheckPasswordDisabled = true
-	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	item := itemsByPath["/file1.txt"]
+	req, err = http.NewRequest(http.MethodPost, path.Join(userTrashPath, item.ID, "restore"), nil)
 	assert.NoError(t, err)
-	assert.Equal(t, 0, user.UsedQuotaFiles)
-	assert.Equal(t, int64(0), user.UsedQuotaSize)
-	user.HomeDir = filepath.Join(homeBasePath, "testmod")
-	user.UID = 33
-	user.GID = 101
-	user.MaxSessions = 10
-	user.QuotaSize = 4096
-	user.QuotaFiles = 2
-	user.Permissions["/"] = []string{dataprovider.PermCreateDirs, dataprovider.PermDelete, dataprovider.PermDownload}
-	user.Permissions["/subdir"] = []string{dataprovider.PermListItems, dataprovider.PermUpload}
-	user.Filters.AllowedIP = []string{"192.168.1.0/24", "192.168.2.0/24"}
-	user.Filters.DeniedIP = []string{"192.168.3.0/24", "192.168.4.0/24"}
-	user.Filters.DeniedLoginMethods = []string{dataprovider.LoginMethodPassword}
-	user.Filters.DeniedProtocols = []string{common.ProtocolWebDAV}
-	user.Filters.TLSUsername = sdk.TLSUsernameNone
-	user.Filters.Hooks.ExternalAuthDisabled = true
-	user.Filters.Hooks.PreLoginDisabled = true
-	user.Filters.Hooks.CheckPasswordDisabled = false
-	user.Filters.DisableFsChecks = true
-	user.Filters.FilePatterns = append(user.Filters.FilePatterns, sdk.PatternsFilter{
-		Path:            "/subdir",
-		AllowedPatterns: []string{"*.zip", "*.rar"},
-		DeniedPatterns:  []string{"*.jpg", "*.png"},
-		DenyPolicy:      sdk.DenyPolicyHide,
-	})
-	user.Filters.MaxUploadFileSize = 4096
-	user.UploadBandwidth = 1024
-	user.DownloadBandwidth = 512
-	user.VirtualFolders = nil
-	mappedPath1 := filepath.Join(os.TempDir(), "mapped_dir1")
-	mappedPath2 := filepath.Join(os.TempDir(), "mapped_dir2")
-	folderName1 := filepath.Base(mappedPath1)
-	folderName2 := filepath.Base(mappedPath2)
-	user.VirtualFolders = append(user.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			Name: folderName1,
-		},
-		VirtualPath: "/vdir1",
-	})
-	user.VirtualFolders = append(user.VirtualFolders, vfs.VirtualFolder{
-		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			Name: folderName2,
-		},
-		VirtualPath: "/vdir12/subdir",
-		QuotaSize:   123,
-		QuotaFiles:  2,
-	})
-	f1 := vfs.BaseVirtualFolder{
-		Name:       folderName1,
-		MappedPath: mappedPath1,
-	}
-	_, _, err = httpdtest.AddFolder(f1, http.StatusCreated)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "file1.txt"))
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	// the original path already exists
+	item = itemsByPath["/file2.txt"]
+	err = os.WriteFile(filepath.Join(user.GetHomeDir(), "file2.txt"), []byte("new data"), 0666)
 	assert.NoError(t, err)
-	f2 := vfs.BaseVirtualFolder{
-		Name:       folderName2,
-		MappedPath: mappedPath2,
-	}
-	_, _, err = httpdtest.AddFolder(f2, http.StatusCreated)
+	req, err = http.NewRequest(http.MethodPost, path.Join(userTrashPath, item.ID, "restore"), nil)
 	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
 
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	req, err = http.NewRequest(http.MethodDelete, path.Join(userTrashPath, item.ID), nil)
 	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
 
-	_, _, err = httpdtest.UpdateUser(user, http.StatusBadRequest, "invalid")
+	webToken, err := getJWTWebClientTokenFromTestServer(defaultUsername, defaultPassword)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "0")
+	req, err = http.NewRequest(http.MethodGet, webClientTrashPath, nil)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "1")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), itemsByPath["/sub/file3.txt"].ID)
+
+	req, err = http.NewRequest(http.MethodDelete, userTrashPath, nil)
 	assert.NoError(t, err)
-	user.Permissions["/subdir"] = []string{}
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+
+	req, err = http.NewRequest(http.MethodGet, userTrashPath, nil)
 	assert.NoError(t, err)
-	assert.Len(t, user.Permissions["/subdir"], 0)
-	assert.Len(t, user.VirtualFolders, 2)
-	for _, folder := range user.VirtualFolders {
-		assert.Greater(t, folder.ID, int64(0))
-		if folder.VirtualPath == "/vdir12/subdir" {
-			assert.Equal(t, int64(123), folder.QuotaSize)
-			assert.Equal(t, 2, folder.QuotaFiles)
-		}
-	}
-	folder, _, err := httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	items = nil
+	err = json.Unmarshal(rr.Body.Bytes(), &items)
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 1)
-	assert.Contains(t, folder.Users, user.Username)
+	assert.Len(t, items, 0)
 
-	_, err = httpdtest.RemoveUser(user, http.StatusOK)
-	assert.NoError(t, err)
-	// removing the user must remove folder mapping
-	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	// only the restored file is left
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 0)
-	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
+	assert.Equal(t, 1, user.UsedQuotaFiles)
+	assert.Equal(t, int64(4), user.UsedQuotaSize)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
-	folder, _, err = httpdtest.GetFolderByName(folderName2, http.StatusOK)
+	err = os.RemoveAll(user.GetHomeDir())
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 0)
-	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
+	err = common.Initialize(oldConfig, 0)
 	assert.NoError(t, err)
 }
 
-func TestUpdateUserTransferQuotaUsage(t *testing.T) {
-	u := getTestUser()
-	usedDownloadDataTransfer := int64(2 * 1024 * 1024)
-	usedUploadDataTransfer := int64(1024 * 1024)
-	u.UsedDownloadDataTransfer = usedDownloadDataTransfer
-	u.UsedUploadDataTransfer = usedUploadDataTransfer
-	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
-	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
-	assert.NoError(t, err)
-	assert.Equal(t, int64(0), user.UsedUploadDataTransfer)
-	assert.Equal(t, int64(0), user.UsedDownloadDataTransfer)
-	_, err = httpdtest.UpdateTransferQuotaUsage(u, "invalid_mode", http.StatusBadRequest)
+func TestUserTrashDirConflict(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
 	assert.NoError(t, err)
-	_, err = httpdtest.UpdateTransferQuotaUsage(u, "", http.StatusOK)
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+
+	oldConfig := config.GetCommonConfig()
+	cfg := config.GetCommonConfig()
+	cfg.TrashRetention = 1
+	cfg.TrashDirName = "sub/trash"
+	err = common.Initialize(cfg, 0)
+	assert.Error(t, err)
+	cfg.TrashDirName = "trash"
+	err = common.Initialize(cfg, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, usedUploadDataTransfer, user.UsedUploadDataTransfer)
-	assert.Equal(t, usedDownloadDataTransfer, user.UsedDownloadDataTransfer)
-	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusBadRequest)
-	assert.NoError(t, err, "user has no transfer quota restrictions add mode should fail")
-	user.TotalDataTransfer = 100
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	// the user already has a directory with the trash name
+	err = os.MkdirAll(filepath.Join(user.GetHomeDir(), "trash"), os.ModePerm)
 	assert.NoError(t, err)
-	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusOK)
+	for _, name := range []string{"file.txt", path.Join("trash", "file.txt")} {
+		err = os.WriteFile(filepath.Join(user.GetHomeDir(), name), []byte("data"), 0666)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(http.MethodGet, userDirsPath+"/?path=%2F", nil)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), `"trash"`)
+	req, err = http.NewRequest(http.MethodGet, userDirsPath+"/?path=%2Ftrash", nil)
 	assert.NoError(t, err)
-	assert.Equal(t, 2*usedUploadDataTransfer, user.UsedUploadDataTransfer)
-	assert.Equal(t, 2*usedDownloadDataTransfer, user.UsedDownloadDataTransfer)
-	u.UsedDownloadDataTransfer = -1
-	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusBadRequest)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), "file.txt")
+	// the existing directory is not used as trash
+	req, err = http.NewRequest(http.MethodDelete, userFilesPath+"?path=file.txt", nil)
 	assert.NoError(t, err)
-	u.UsedDownloadDataTransfer = usedDownloadDataTransfer
-	u.Username += "1"
-	_, err = httpdtest.UpdateTransferQuotaUsage(u, "", http.StatusNotFound)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	assert.NotEqual(t, http.StatusOK, rr.Code)
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "file.txt"))
+	assert.NoFileExists(t, filepath.Join(user.GetHomeDir(), "trash", ".sftpgo-trash"))
+	req, err = http.NewRequest(http.MethodGet, userTrashPath, nil)
 	assert.NoError(t, err)
-	u.Username = defaultUsername
-	_, err = httpdtest.UpdateTransferQuotaUsage(u, "", http.StatusOK)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var items []common.TrashItem
+	err = json.Unmarshal(rr.Body.Bytes(), &items)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.Len(t, items, 0)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
-	assert.Equal(t, usedUploadDataTransfer, user.UsedUploadDataTransfer)
-	assert.Equal(t, usedDownloadDataTransfer, user.UsedDownloadDataTransfer)
-	u.UsedDownloadDataTransfer = 0
-	u.UsedUploadDataTransfer = 1
-	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusOK)
+	err = os.RemoveAll(user.GetHomeDir())
 	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	err = common.Initialize(oldConfig, 0)
 	assert.NoError(t, err)
-	assert.Equal(t, usedUploadDataTransfer+1, user.UsedUploadDataTransfer)
-	assert.Equal(t, usedDownloadDataTransfer, user.UsedDownloadDataTransfer)
-	u.UsedDownloadDataTransfer = 1
-	u.UsedUploadDataTransfer = 0
-	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusOK)
+}
+
+func TestRetentionAPI(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+
+	checks, _, err := httpdtest.GetRetentionChecks(http.StatusOK)
 	assert.NoError(t, err)
-	assert.Equal(t, usedUploadDataTransfer+1, user.UsedUploadDataTransfer)
-	assert.Equal(t, usedDownloadDataTransfer+1, user.UsedDownloadDataTransfer)
+	assert.Len(t, checks, 0)
 
-	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	localFilePath := filepath.Join(user.HomeDir, "testdir", "testfile")
+	err = os.MkdirAll(filepath.Dir(localFilePath), os.ModePerm)
 	assert.NoError(t, err)
-	req, err := http.NewRequest(http.MethodPut, path.Join(quotasBasePath, "users", u.Username, "transfer-usage"),
-		bytes.NewBuffer([]byte(`not a json`)))
+	err = os.WriteFile(localFilePath, []byte("test data"), os.ModePerm)
 	assert.NoError(t, err)
-	setBearerForReq(req, token)
-	rr := executeRequest(req)
-	checkResponseCode(t, http.StatusBadRequest, rr)
 
-	_, err = httpdtest.RemoveUser(user, http.StatusOK)
-	assert.NoError(t, err)
-}
+	folderRetention := []dataprovider.FolderRetention{
+		{
+			Path:            "/",
+			Retention:       0,
+			DeleteEmptyDirs: true,
+		},
+	}
 
-func TestUpdateUserQuotaUsage(t *testing.T) {
-	u := getTestUser()
-	usedQuotaFiles := 1
-	usedQuotaSize := int64(65535)
-	u.UsedQuotaFiles = usedQuotaFiles
-	u.UsedQuotaSize = usedQuotaSize
-	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	_, err = httpdtest.StartRetentionCheck(altAdminUsername, folderRetention, http.StatusNotFound)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+
+	resp, err := httpdtest.StartRetentionCheck(user.Username, folderRetention, http.StatusBadRequest)
 	assert.NoError(t, err)
-	assert.Equal(t, 0, user.UsedQuotaFiles)
-	assert.Equal(t, int64(0), user.UsedQuotaSize)
-	_, err = httpdtest.UpdateQuotaUsage(u, "invalid_mode", http.StatusBadRequest)
+	assert.Contains(t, string(resp), "Invalid retention check")
+
+	folderRetention[0].Retention = 24
+	_, err = httpdtest.StartRetentionCheck(user.Username, folderRetention, http.StatusAccepted)
 	assert.NoError(t, err)
-	_, err = httpdtest.UpdateQuotaUsage(u, "", http.StatusOK)
+
+	assert.Eventually(t, func() bool {
+		return len(common.RetentionChecks.Get("")) == 0
+	}, 1000*time.Millisecond, 50*time.Millisecond)
+
+	assert.FileExists(t, localFilePath)
+
+	err = os.Chtimes(localFilePath, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour))
 	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+
+	_, err = httpdtest.StartRetentionCheck(user.Username, folderRetention, http.StatusAccepted)
 	assert.NoError(t, err)
-	assert.Equal(t, usedQuotaFiles, user.UsedQuotaFiles)
-	assert.Equal(t, usedQuotaSize, user.UsedQuotaSize)
-	_, err = httpdtest.UpdateQuotaUsage(u, "add", http.StatusBadRequest)
-	assert.NoError(t, err, "user has no quota restrictions add mode should fail")
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+
+	assert.Eventually(t, func() bool {
+		return len(common.RetentionChecks.Get("")) == 0
+	}, 1000*time.Millisecond, 50*time.Millisecond)
+
+	assert.NoFileExists(t, localFilePath)
+	assert.NoDirExists(t, filepath.Dir(localFilePath))
+
+	check := common.RetentionCheck{
+		Folders: folderRetention,
+	}
+	c := common.RetentionChecks.Add(check, &user)
+	assert.NotNil(t, c)
+
+	_, err = httpdtest.StartRetentionCheck(user.Username, folderRetention, http.StatusConflict)
 	assert.NoError(t, err)
-	assert.Equal(t, usedQuotaFiles, user.UsedQuotaFiles)
-	assert.Equal(t, usedQuotaSize, user.UsedQuotaSize)
-	user.QuotaFiles = 100
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+
+	err = c.Start()
 	assert.NoError(t, err)
-	_, err = httpdtest.UpdateQuotaUsage(u, "add", http.StatusOK)
+	assert.Len(t, common.RetentionChecks.Get(""), 0)
+
+	admin := getTestAdmin()
+	admin.Username = altAdminUsername
+	admin.Password = altAdminPassword
+	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+
+	token, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
 	assert.NoError(t, err)
-	assert.Equal(t, 2*usedQuotaFiles, user.UsedQuotaFiles)
-	assert.Equal(t, 2*usedQuotaSize, user.UsedQuotaSize)
-	u.UsedQuotaFiles = -1
-	_, err = httpdtest.UpdateQuotaUsage(u, "", http.StatusBadRequest)
+	req, _ := http.NewRequest(http.MethodPost, retentionBasePath+"/"+user.Username+"/check",
+		bytes.NewBuffer([]byte("invalid json")))
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	asJSON, err := json.Marshal(folderRetention)
 	assert.NoError(t, err)
-	u.UsedQuotaFiles = usedQuotaFiles
-	u.Username = u.Username + "1"
-	_, err = httpdtest.UpdateQuotaUsage(u, "", http.StatusNotFound)
+	req, _ = http.NewRequest(http.MethodPost, retentionBasePath+"/"+user.Username+"/check?notifications=Email,",
+		bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "to notify results via email")
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
 	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodPost, retentionBasePath+"/"+user.Username+"/check?notifications=Email",
+		bytes.NewBuffer(asJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+
 	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
 }
 
-func TestUserFolderMapping(t *testing.T) {
-	mappedPath1 := filepath.Join(os.TempDir(), "mapped_dir1")
-	mappedPath2 := filepath.Join(os.TempDir(), "mapped_dir2")
-	folderName1 := filepath.Base(mappedPath1)
-	folderName2 := filepath.Base(mappedPath2)
-	u1 := getTestUser()
-	u1.VirtualFolders = append(u1.VirtualFolders, vfs.VirtualFolder{
+func TestAddUserInvalidVirtualFolders(t *testing.T) {
+	u := getTestUser()
+	folderName := "fname"
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
 		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			Name: folderName1,
+			MappedPath: filepath.Join(os.TempDir(), "mapped_dir"),
+			Name:       folderName,
 		},
 		VirtualPath: "/vdir",
-		QuotaSize:   -1,
-		QuotaFiles:  -1,
 	})
-	f1 := vfs.BaseVirtualFolder{
-		Name:            folderName1,
-		MappedPath:      mappedPath1,
-		UsedQuotaFiles:  2,
-		UsedQuotaSize:   123,
-		LastQuotaUpdate: 456,
-	}
-	_, _, err := httpdtest.AddFolder(f1, http.StatusCreated)
-	assert.NoError(t, err)
-	f2 := vfs.BaseVirtualFolder{
-		Name:       folderName2,
-		MappedPath: mappedPath2,
-	}
-	_, _, err = httpdtest.AddFolder(f2, http.StatusCreated)
-	assert.NoError(t, err)
-	user1, _, err := httpdtest.AddUser(u1, http.StatusCreated)
-	assert.NoError(t, err)
-	// virtual folder must be auto created
-	folder, _, err := httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
+			Name:       folderName + "1",
+		},
+		VirtualPath: "/vdir", // invalid, already defined
+	})
+	_, _, err := httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 1)
-	assert.Contains(t, folder.Users, user1.Username)
-	assert.Equal(t, 2, folder.UsedQuotaFiles)
-	assert.Equal(t, int64(123), folder.UsedQuotaSize)
-	assert.Equal(t, int64(456), folder.LastQuotaUpdate)
-	assert.Equal(t, 2, user1.VirtualFolders[0].UsedQuotaFiles)
-	assert.Equal(t, int64(123), user1.VirtualFolders[0].UsedQuotaSize)
-	assert.Equal(t, int64(456), user1.VirtualFolders[0].LastQuotaUpdate)
-
-	u2 := getTestUser()
-	u2.Username = defaultUsername + "2"
-	u2.VirtualFolders = append(u2.VirtualFolders, vfs.VirtualFolder{
+	u.VirtualFolders = nil
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
 		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			Name:       folderName1,
-			MappedPath: mappedPath1,
+			MappedPath: filepath.Join(os.TempDir(), "mapped_dir"),
+			Name:       folderName,
 		},
 		VirtualPath: "/vdir1",
-		QuotaSize:   0,
-		QuotaFiles:  0,
 	})
-	u2.VirtualFolders = append(u2.VirtualFolders, vfs.VirtualFolder{
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
 		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			Name:       folderName2,
-			MappedPath: mappedPath2,
+			MappedPath: filepath.Join(os.TempDir(), "mapped_dir"),
+			Name:       folderName, // invalid, unique constraint (user.id, folder.id) violated
 		},
 		VirtualPath: "/vdir2",
-		QuotaSize:   -1,
-		QuotaFiles:  -1,
 	})
-	user2, _, err := httpdtest.AddUser(u2, http.StatusCreated)
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
-	folder, _, err = httpdtest.GetFolderByName(folderName2, http.StatusOK)
+	u.VirtualFolders = nil
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
+			Name:       folderName + "1",
+		},
+		VirtualPath: "/vdir1/",
+		QuotaSize:   -1,
+		QuotaFiles:  1, // invvalid, we cannot have -1 and > 0
+	})
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 1)
-	assert.Contains(t, folder.Users, user2.Username)
-	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	u.VirtualFolders = nil
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
+			Name:       folderName + "1",
+		},
+		VirtualPath: "/vdir1/",
+		QuotaSize:   1,
+		QuotaFiles:  -1,
+	})
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 2)
-	assert.Contains(t, folder.Users, user1.Username)
-	assert.Contains(t, folder.Users, user2.Username)
-	// now update user2 removing mappedPath1
-	user2.VirtualFolders = nil
-	user2.VirtualFolders = append(user2.VirtualFolders, vfs.VirtualFolder{
+	u.VirtualFolders = nil
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
 		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			Name:           folderName2,
-			MappedPath:     mappedPath2,
-			UsedQuotaFiles: 2,
-			UsedQuotaSize:  123,
+			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
+			Name:       folderName + "1",
 		},
-		VirtualPath: "/vdir",
-		QuotaSize:   0,
+		VirtualPath: "/vdir1/",
+		QuotaSize:   -2, // invalid
 		QuotaFiles:  0,
 	})
-	user2, _, err = httpdtest.UpdateUser(user2, http.StatusOK, "")
-	assert.NoError(t, err)
-	folder, _, err = httpdtest.GetFolderByName(folderName2, http.StatusOK)
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 1)
-	assert.Contains(t, folder.Users, user2.Username)
-	assert.Equal(t, 0, folder.UsedQuotaFiles)
-	assert.Equal(t, int64(0), folder.UsedQuotaSize)
-	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	u.VirtualFolders = nil
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			MappedPath: filepath.Join(os.TempDir(), "mapped_dir1"),
+			Name:       folderName + "1",
+		},
+		VirtualPath: "/vdir1/",
+		QuotaSize:   0,
+		QuotaFiles:  -2, // invalid
+	})
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 1)
-	assert.Contains(t, folder.Users, user1.Username)
-	// add mappedPath1 again to user2
-	user2.VirtualFolders = append(user2.VirtualFolders, vfs.VirtualFolder{
+	u.VirtualFolders = nil
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
 		BaseVirtualFolder: vfs.BaseVirtualFolder{
-			Name:       folderName1,
-			MappedPath: mappedPath1,
+			MappedPath: filepath.Join(os.TempDir(), "mapped_dir"),
 		},
 		VirtualPath: "/vdir1",
 	})
-	user2, _, err = httpdtest.UpdateUser(user2, http.StatusOK, "")
-	assert.NoError(t, err)
-	folder, _, err = httpdtest.GetFolderByName(folderName2, http.StatusOK)
+	// folder name is mandatory
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 1)
-	assert.Contains(t, folder.Users, user2.Username)
-	// removing virtual folders should clear relations on both side
-	_, err = httpdtest.RemoveFolder(vfs.BaseVirtualFolder{Name: folderName2}, http.StatusOK)
+}
+
+func TestUserPublicKey(t *testing.T) {
+	u := getTestUser()
+	u.Password = ""
+	invalidPubKey := "invalid"
+	u.PublicKeys = []string{invalidPubKey}
+	_, _, err := httpdtest.AddUser(u, http.StatusBadRequest)
 	assert.NoError(t, err)
-	user2, _, err = httpdtest.GetUserByUsername(user2.Username, http.StatusOK)
+	u.PublicKeys = []string{testPubKey}
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
 	assert.NoError(t, err)
-	if assert.Len(t, user2.VirtualFolders, 1) {
-		folder := user2.VirtualFolders[0]
-		assert.Equal(t, mappedPath1, folder.MappedPath)
-		assert.Equal(t, folderName1, folder.Name)
-	}
-	user1, _, err = httpdtest.GetUserByUsername(user1.Username, http.StatusOK)
+
+	dbUser, err := dataprovider.UserExists(u.Username, "")
 	assert.NoError(t, err)
-	if assert.Len(t, user2.VirtualFolders, 1) {
-		folder := user2.VirtualFolders[0]
-		assert.Equal(t, mappedPath1, folder.MappedPath)
-	}
+	assert.Empty(t, dbUser.Password)
+	assert.False(t, dbUser.IsPasswordHashed())
 
-	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	user.PublicKeys = []string{testPubKey, invalidPubKey}
+	_, _, err = httpdtest.UpdateUser(user, http.StatusBadRequest, "")
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 2)
-	// removing a user should clear virtual folder mapping
-	_, err = httpdtest.RemoveUser(user1, http.StatusOK)
+	user.PublicKeys = []string{testPubKey, testPubKey, testPubKey}
+	user.Password = defaultPassword
+	_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
 	assert.NoError(t, err)
-	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+
+	dbUser, err = dataprovider.UserExists(u.Username, "")
 	assert.NoError(t, err)
-	assert.Len(t, folder.Users, 1)
+	assert.NotEmpty(t, dbUser.Password)
+	assert.True(t, dbUser.IsPasswordHashed())
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestUpdateUserEmptyPassword(t *testing.T) {
+	u := getTestUser()
+	u.PublicKeys = []string{testPubKey}
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+
+	// the password is not empty
+	dbUser, err := dataprovider.UserExists(u.Username, "")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, dbUser.Password)
+	assert.True(t, dbUser.IsPasswordHashed())
+	// now update the user and set an empty password
+	data, err := json.Marshal(dbUser)
+	assert.NoError(t, err)
+	var customUser map[string]any
+	err = json.Unmarshal(data, &customUser)
+	assert.NoError(t, err)
+	customUser["password"] = ""
+	asJSON, err := json.Marshal(customUser)
+	assert.NoError(t, err)
+	userNoPwd, _, err := httpdtest.UpdateUserWithJSON(user, http.StatusOK, "", asJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Password, userNoPwd.Password) // the password is hidden
+	// check the password within the data provider
+	dbUser, err = dataprovider.UserExists(u.Username, "")
+	assert.NoError(t, err)
+	assert.Empty(t, dbUser.Password)
+	assert.False(t, dbUser.IsPasswordHashed())
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestUpdateUserNoPassword(t *testing.T) {
+	u := getTestUser()
+	u.PublicKeys = []string{testPubKey}
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	// the password is not empty
+	dbUser, err := dataprovider.UserExists(u.Username, "")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, dbUser.Password)
+	assert.True(t, dbUser.IsPasswordHashed())
+	// now update the user and remove the password field, old password should be preserved
+	user.Password = "" // password has the omitempty tag
+	_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	// the password is preserved
+	dbUser, err = dataprovider.UserExists(u.Username, "")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, dbUser.Password)
+	assert.True(t, dbUser.IsPasswordHashed())
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestUpdateUser(t *testing.T) {
+	u := getTestUser()
+	u.UsedQuotaFiles = 1
+	u.UsedQuotaSize = 2
+	u.Filters.TLSUsername = sdk.TLSUsernameCN
+	u.Filters.Hooks.CheckPasswordDisabled = true
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, user.UsedQuotaFiles)
+	assert.Equal(t, int64(0), user.UsedQuotaSize)
+	user.HomeDir = filepath.Join(homeBasePath, "testmod")
+	user.UID = 33
+	user.GID = 101
+	user.MaxSessions = 10
+	user.QuotaSize = 4096
+	user.QuotaFiles = 2
+	user.Permissions["/"] = []string{dataprovider.PermCreateDirs, dataprovider.PermDelete, dataprovider.PermDownload}
+	user.Permissions["/subdir"] = []string{dataprovider.PermListItems, dataprovider.PermUpload}
+	user.Filters.AllowedIP = []string{"192.168.1.0/24", "192.168.2.0/24"}
+	user.Filters.DeniedIP = []string{"192.168.3.0/24", "192.168.4.0/24"}
+	user.Filters.DeniedLoginMethods = []string{dataprovider.LoginMethodPassword}
+	user.Filters.DeniedProtocols = []string{common.ProtocolWebDAV}
+	user.Filters.TLSUsername = sdk.TLSUsernameNone
+	user.Filters.Hooks.ExternalAuthDisabled = true
+	user.Filters.Hooks.PreLoginDisabled = true
+	user.Filters.Hooks.CheckPasswordDisabled = false
+	user.Filters.DisableFsChecks = true
+	user.Filters.FilePatterns = append(user.Filters.FilePatterns, sdk.PatternsFilter{
+		Path:            "/subdir",
+		AllowedPatterns: []string{"*.zip", "*.rar"},
+		DeniedPatterns:  []string{"*.jpg", "*.png"},
+		DenyPolicy:      sdk.DenyPolicyHide,
+	})
+	user.Filters.MaxUploadFileSize = 4096
+	user.UploadBandwidth = 1024
+	user.DownloadBandwidth = 512
+	user.VirtualFolders = nil
+	mappedPath1 := filepath.Join(os.TempDir(), "mapped_dir1")
+	mappedPath2 := filepath.Join(os.TempDir(), "mapped_dir2")
+	folderName1 := filepath.Base(mappedPath1)
+	folderName2 := filepath.Base(mappedPath2)
+	user.VirtualFolders = append(user.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			Name: folderName1,
+		},
+		VirtualPath: "/vdir1",
+	})
+	user.VirtualFolders = append(user.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			Name: folderName2,
+		},
+		VirtualPath: "/vdir12/subdir",
+		QuotaSize:   123,
+		QuotaFiles:  2,
+	})
+	f1 := vfs.BaseVirtualFolder{
+		Name:       folderName1,
+		MappedPath: mappedPath1,
+	}
+	_, _, err = httpdtest.AddFolder(f1, http.StatusCreated)
+	assert.NoError(t, err)
+	f2 := vfs.BaseVirtualFolder{
+		Name:       folderName2,
+		MappedPath: mappedPath2,
+	}
+	_, _, err = httpdtest.AddFolder(f2, http.StatusCreated)
+	assert.NoError(t, err)
+
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+
+	_, _, err = httpdtest.UpdateUser(user, http.StatusBadRequest, "invalid")
+	assert.NoError(t, err)
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "0")
+	assert.NoError(t, err)
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "1")
+	assert.NoError(t, err)
+	user.Permissions["/subdir"] = []string{}
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	assert.Len(t, user.Permissions["/subdir"], 0)
+	assert.Len(t, user.VirtualFolders, 2)
+	for _, folder := range user.VirtualFolders {
+		assert.Greater(t, folder.ID, int64(0))
+		if folder.VirtualPath == "/vdir12/subdir" {
+			assert.Equal(t, int64(123), folder.QuotaSize)
+			assert.Equal(t, 2, folder.QuotaFiles)
+		}
+	}
+	folder, _, err := httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 1)
+	assert.Contains(t, folder.Users, user.Username)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	// removing the user must remove folder mapping
+	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 0)
+	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
+	assert.NoError(t, err)
+	folder, _, err = httpdtest.GetFolderByName(folderName2, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 0)
+	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestUpdateUserTransferQuotaUsage(t *testing.T) {
+	u := getTestUser()
+	usedDownloadDataTransfer := int64(2 * 1024 * 1024)
+	usedUploadDataTransfer := int64(1024 * 1024)
+	u.UsedDownloadDataTransfer = usedDownloadDataTransfer
+	u.UsedUploadDataTransfer = usedUploadDataTransfer
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), user.UsedUploadDataTransfer)
+	assert.Equal(t, int64(0), user.UsedDownloadDataTransfer)
+	_, err = httpdtest.UpdateTransferQuotaUsage(u, "invalid_mode", http.StatusBadRequest)
+	assert.NoError(t, err)
+	_, err = httpdtest.UpdateTransferQuotaUsage(u, "", http.StatusOK)
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, usedUploadDataTransfer, user.UsedUploadDataTransfer)
+	assert.Equal(t, usedDownloadDataTransfer, user.UsedDownloadDataTransfer)
+	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusBadRequest)
+	assert.NoError(t, err, "user has no transfer quota restrictions add mode should fail")
+	user.TotalDataTransfer = 100
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusOK)
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*usedUploadDataTransfer, user.UsedUploadDataTransfer)
+	assert.Equal(t, 2*usedDownloadDataTransfer, user.UsedDownloadDataTransfer)
+	u.UsedDownloadDataTransfer = -1
+	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusBadRequest)
+	assert.NoError(t, err)
+	u.UsedDownloadDataTransfer = usedDownloadDataTransfer
+	u.Username += "1"
+	_, err = httpdtest.UpdateTransferQuotaUsage(u, "", http.StatusNotFound)
+	assert.NoError(t, err)
+	u.Username = defaultUsername
+	_, err = httpdtest.UpdateTransferQuotaUsage(u, "", http.StatusOK)
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, usedUploadDataTransfer, user.UsedUploadDataTransfer)
+	assert.Equal(t, usedDownloadDataTransfer, user.UsedDownloadDataTransfer)
+	u.UsedDownloadDataTransfer = 0
+	u.UsedUploadDataTransfer = 1
+	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusOK)
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, usedUploadDataTransfer+1, user.UsedUploadDataTransfer)
+	assert.Equal(t, usedDownloadDataTransfer, user.UsedDownloadDataTransfer)
+	u.UsedDownloadDataTransfer = 1
+	u.UsedUploadDataTransfer = 0
+	_, err = httpdtest.UpdateTransferQuotaUsage(u, "add", http.StatusOK)
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, usedUploadDataTransfer+1, user.UsedUploadDataTransfer)
+	assert.Equal(t, usedDownloadDataTransfer+1, user.UsedDownloadDataTransfer)
+
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPut, path.Join(quotasBasePath, "users", u.Username, "transfer-usage"),
+		bytes.NewBuffer([]byte(`not a json`)))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestUpdateUserQuotaUsage(t *testing.T) {
+	u := getTestUser()
+	usedQuotaFiles := 1
+	usedQuotaSize := int64(65535)
+	u.UsedQuotaFiles = usedQuotaFiles
+	u.UsedQuotaSize = usedQuotaSize
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, user.UsedQuotaFiles)
+	assert.Equal(t, int64(0), user.UsedQuotaSize)
+	_, err = httpdtest.UpdateQuotaUsage(u, "invalid_mode", http.StatusBadRequest)
+	assert.NoError(t, err)
+	_, err = httpdtest.UpdateQuotaUsage(u, "", http.StatusOK)
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, usedQuotaFiles, user.UsedQuotaFiles)
+	assert.Equal(t, usedQuotaSize, user.UsedQuotaSize)
+	_, err = httpdtest.UpdateQuotaUsage(u, "add", http.StatusBadRequest)
+	assert.NoError(t, err, "user has no quota restrictions add mode should fail")
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, usedQuotaFiles, user.UsedQuotaFiles)
+	assert.Equal(t, usedQuotaSize, user.UsedQuotaSize)
+	user.QuotaFiles = 100
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	_, err = httpdtest.UpdateQuotaUsage(u, "add", http.StatusOK)
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, 2*usedQuotaFiles, user.UsedQuotaFiles)
+	assert.Equal(t, 2*usedQuotaSize, user.UsedQuotaSize)
+	u.UsedQuotaFiles = -1
+	_, err = httpdtest.UpdateQuotaUsage(u, "", http.StatusBadRequest)
+	assert.NoError(t, err)
+	u.UsedQuotaFiles = usedQuotaFiles
+	u.Username = u.Username + "1"
+	_, err = httpdtest.UpdateQuotaUsage(u, "", http.StatusNotFound)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestUserFolderMapping(t *testing.T) {
+	mappedPath1 := filepath.Join(os.TempDir(), "mapped_dir1")
+	mappedPath2 := filepath.Join(os.TempDir(), "mapped_dir2")
+	folderName1 := filepath.Base(mappedPath1)
+	folderName2 := filepath.Base(mappedPath2)
+	u1 := getTestUser()
+	u1.VirtualFolders = append(u1.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			Name: folderName1,
+		},
+		VirtualPath: "/vdir",
+		QuotaSize:   -1,
+		QuotaFiles:  -1,
+	})
+	f1 := vfs.BaseVirtualFolder{
+		Name:            folderName1,
+		MappedPath:      mappedPath1,
+		UsedQuotaFiles:  2,
+		UsedQuotaSize:   123,
+		LastQuotaUpdate: 456,
+	}
+	_, _, err := httpdtest.AddFolder(f1, http.StatusCreated)
+	assert.NoError(t, err)
+	f2 := vfs.BaseVirtualFolder{
+		Name:       folderName2,
+		MappedPath: mappedPath2,
+	}
+	_, _, err = httpdtest.AddFolder(f2, http.StatusCreated)
+	assert.NoError(t, err)
+	user1, _, err := httpdtest.AddUser(u1, http.StatusCreated)
+	assert.NoError(t, err)
+	// virtual folder must be auto created
+	folder, _, err := httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 1)
+	assert.Contains(t, folder.Users, user1.Username)
+	assert.Equal(t, 2, folder.UsedQuotaFiles)
+	assert.Equal(t, int64(123), folder.UsedQuotaSize)
+	assert.Equal(t, int64(456), folder.LastQuotaUpdate)
+	assert.Equal(t, 2, user1.VirtualFolders[0].UsedQuotaFiles)
+	assert.Equal(t, int64(123), user1.VirtualFolders[0].UsedQuotaSize)
+	assert.Equal(t, int64(456), user1.VirtualFolders[0].LastQuotaUpdate)
+
+	u2 := getTestUser()
+	u2.Username = defaultUsername + "2"
+	u2.VirtualFolders = append(u2.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			Name:       folderName1,
+			MappedPath: mappedPath1,
+		},
+		VirtualPath: "/vdir1",
+		QuotaSize:   0,
+		QuotaFiles:  0,
+	})
+	u2.VirtualFolders = append(u2.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			Name:       folderName2,
+			MappedPath: mappedPath2,
+		},
+		VirtualPath: "/vdir2",
+		QuotaSize:   -1,
+		QuotaFiles:  -1,
+	})
+	user2, _, err := httpdtest.AddUser(u2, http.StatusCreated)
+	assert.NoError(t, err)
+	folder, _, err = httpdtest.GetFolderByName(folderName2, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 1)
+	assert.Contains(t, folder.Users, user2.Username)
+	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 2)
+	assert.Contains(t, folder.Users, user1.Username)
+	assert.Contains(t, folder.Users, user2.Username)
+	// now update user2 removing mappedPath1
+	user2.VirtualFolders = nil
+	user2.VirtualFolders = append(user2.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			Name:           folderName2,
+			MappedPath:     mappedPath2,
+			UsedQuotaFiles: 2,
+			UsedQuotaSize:  123,
+		},
+		VirtualPath: "/vdir",
+		QuotaSize:   0,
+		QuotaFiles:  0,
+	})
+	user2, _, err = httpdtest.UpdateUser(user2, http.StatusOK, "")
+	assert.NoError(t, err)
+	folder, _, err = httpdtest.GetFolderByName(folderName2, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 1)
+	assert.Contains(t, folder.Users, user2.Username)
+	assert.Equal(t, 0, folder.UsedQuotaFiles)
+	assert.Equal(t, int64(0), folder.UsedQuotaSize)
+	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 1)
+	assert.Contains(t, folder.Users, user1.Username)
+	// add mappedPath1 again to user2
+	user2.VirtualFolders = append(user2.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			Name:       folderName1,
+			MappedPath: mappedPath1,
+		},
+		VirtualPath: "/vdir1",
+	})
+	user2, _, err = httpdtest.UpdateUser(user2, http.StatusOK, "")
+	assert.NoError(t, err)
+	folder, _, err = httpdtest.GetFolderByName(folderName2, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 1)
+	assert.Contains(t, folder.Users, user2.Username)
+	// removing virtual folders should clear relations on both side
+	_, err = httpdtest.RemoveFolder(vfs.BaseVirtualFolder{Name: folderName2}, http.StatusOK)
+	assert.NoError(t, err)
+	user2, _, err = httpdtest.GetUserByUsername(user2.Username, http.StatusOK)
+	assert.NoError(t, err)
+	if assert.Len(t, user2.VirtualFolders, 1) {
+		folder := user2.VirtualFolders[0]
+		assert.Equal(t, mappedPath1, folder.MappedPath)
+		assert.Equal(t, folderName1, folder.Name)
+	}
+	user1, _, err = httpdtest.GetUserByUsername(user1.Username, http.StatusOK)
+	assert.NoError(t, err)
+	if assert.Len(t, user2.VirtualFolders, 1) {
+		folder := user2.VirtualFolders[0]
+		assert.Equal(t, mappedPath1, folder.MappedPath)
+	}
+
+	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 2)
+	// removing a user should clear virtual folder mapping
+	_, err = httpdtest.RemoveUser(user1, http.StatusOK)
+	assert.NoError(t, err)
+	folder, _, err = httpdtest.GetFolderByName(folderName1, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Users, 1)
 	assert.Contains(t, folder.Users, user2.Username)
 	// removing a folder should clear mapping on the user side too
 	_, err = httpdtest.RemoveFolder(vfs.BaseVirtualFolder{Name: folderName1}, http.StatusOK)
@@ -5406,19 +6917,179 @@ func TestHTTPFsConfig(t *testing.T) {
 		Password: kms.NewPlainSecret(defaultPassword),
 		APIKey:   kms.NewPlainSecret(defaultTokenAuthUser),
 	}
-	user, _, err = httpdtest.AddUser(u, http.StatusCreated)
+	user, _, err = httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	assert.Equal(t, sdkkms.SecretStatusSecretBox, user.FsConfig.HTTPConfig.Password.GetStatus())
+	assert.NotEmpty(t, user.FsConfig.HTTPConfig.Password.GetPayload())
+	assert.Empty(t, user.FsConfig.HTTPConfig.Password.GetAdditionalData())
+	assert.Empty(t, user.FsConfig.HTTPConfig.Password.GetKey())
+	assert.Equal(t, sdkkms.SecretStatusSecretBox, user.FsConfig.HTTPConfig.APIKey.GetStatus())
+	assert.NotEmpty(t, user.FsConfig.HTTPConfig.APIKey.GetPayload())
+	assert.Empty(t, user.FsConfig.HTTPConfig.APIKey.GetAdditionalData())
+	assert.Empty(t, user.FsConfig.HTTPConfig.APIKey.GetKey())
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestUnionFsConfig(t *testing.T) {
+	localPath := filepath.Join(homeBasePath, "union_local")
+	cryptPath := filepath.Join(homeBasePath, "union_crypt")
+	u := getTestUser()
+	u.FsConfig.Provider = vfs.UnionFilesystemProvider
+	u.FsConfig.UnionConfig = vfs.UnionFsConfig{
+		Members: []vfs.UnionFsMember{
+			{
+				MappedPath: localPath,
+			},
+		},
+	}
+	_, resp, err := httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	assert.Contains(t, string(resp), "at least two members are required")
+	u.FsConfig.UnionConfig.Members = append(u.FsConfig.UnionConfig.Members, vfs.UnionFsMember{
+		MappedPath: cryptPath,
+		FsConfig: vfs.Filesystem{
+			Provider: sdk.CryptedFilesystemProvider,
+			CryptConfig: vfs.CryptFsConfig{
+				Passphrase: kms.NewPlainSecret("union passphrase"),
+			},
+		},
+	})
+	u.FsConfig.UnionConfig.WritePolicy = "invalid"
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	assert.Contains(t, string(resp), "invalid write policy")
+	u.FsConfig.UnionConfig.WritePolicy = vfs.UnionWritePolicyMostFreeSpace
+	u.FsConfig.UnionConfig.Members[0].MappedPath = "relative"
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	assert.Contains(t, string(resp), "it must be an absolute path")
+	u.FsConfig.UnionConfig.Members[0].MappedPath = localPath
+	u.FsConfig.UnionConfig.Members[0].ReadOnly = true
+	u.FsConfig.UnionConfig.Members[1].ReadOnly = true
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	assert.Contains(t, string(resp), "at least a writable member is required")
+	u.FsConfig.UnionConfig.Members[1].ReadOnly = false
+	u.FsConfig.UnionConfig.Members[1].FsConfig = vfs.Filesystem{
+		Provider: vfs.UnionFilesystemProvider,
+	}
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	assert.Contains(t, string(resp), "nested union filesystems are not allowed")
+	// redacted member secrets are not accepted
+	u.FsConfig.UnionConfig.Members[1].FsConfig = vfs.Filesystem{
+		Provider: sdk.CryptedFilesystemProvider,
+		CryptConfig: vfs.CryptFsConfig{
+			Passphrase: kms.NewSecret(sdkkms.SecretStatusRedacted, "akey", "", ""),
+		},
+	}
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err)
+	u.FsConfig.UnionConfig.Members[1].FsConfig.CryptConfig.Passphrase = kms.NewPlainSecret("union passphrase")
+	user, resp, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	assert.Equal(t, vfs.UnionWritePolicyMostFreeSpace, user.FsConfig.UnionConfig.WritePolicy)
+	if assert.Len(t, user.FsConfig.UnionConfig.Members, 2) {
+		passphrase := user.FsConfig.UnionConfig.Members[1].FsConfig.CryptConfig.Passphrase
+		assert.Equal(t, sdkkms.SecretStatusSecretBox, passphrase.GetStatus())
+		assert.NotEmpty(t, passphrase.GetPayload())
+		assert.Empty(t, passphrase.GetAdditionalData())
+		assert.Empty(t, passphrase.GetKey())
+		initialPayload := passphrase.GetPayload()
+		// an encrypted secret must be preserved on update
+		passphrase.SetStatus(sdkkms.SecretStatusSecretBox)
+		passphrase.SetAdditionalData(util.GenerateUniqueID())
+		passphrase.SetKey(util.GenerateUniqueID())
+		user.FsConfig.UnionConfig.WritePolicy = ""
+		user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+		assert.NoError(t, err)
+		assert.Equal(t, vfs.UnionWritePolicyFirstWritable, user.FsConfig.UnionConfig.WritePolicy)
+		if assert.Len(t, user.FsConfig.UnionConfig.Members, 2) {
+			passphrase = user.FsConfig.UnionConfig.Members[1].FsConfig.CryptConfig.Passphrase
+			assert.Equal(t, sdkkms.SecretStatusSecretBox, passphrase.GetStatus())
+			assert.Equal(t, initialPayload, passphrase.GetPayload())
+			assert.Empty(t, passphrase.GetAdditionalData())
+			assert.Empty(t, passphrase.GetKey())
+		}
+	}
+
+	err = os.MkdirAll(filepath.Join(localPath, "dir"), os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(localPath, "dir", "file1"), []byte("data"), 0666)
+	assert.NoError(t, err)
+	err = os.MkdirAll(filepath.Join(cryptPath, "dir"), os.ModePerm)
+	assert.NoError(t, err)
+	user, err = dataprovider.UserExists(user.Username, "")
+	assert.NoError(t, err)
+	unionFs, err := user.GetFilesystem(xid.New().String())
+	if assert.NoError(t, err) {
+		assert.False(t, unionFs.IsAtomicUploadSupported())
+		assert.False(t, unionFs.IsUploadResumeSupported())
+		fsPath, err := unionFs.ResolvePath("/dir/file2")
+		assert.NoError(t, err)
+		// the local member is read only, the crypt member returns a pipe writer
+		_, w, _, err := unionFs.Create(fsPath, 0, 0)
+		if assert.NoError(t, err) {
+			_, err = w.Write([]byte("data"))
+			assert.NoError(t, err)
+			err = w.Close()
+			assert.NoError(t, err)
+		}
+		// the new file is created inside the first writable member
+		assert.FileExists(t, filepath.Join(cryptPath, "dir", "file2"))
+		dirPath, err := unionFs.ResolvePath("/dir")
+		assert.NoError(t, err)
+		entries, err := unionFs.ReadDir(dirPath)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		err = unionFs.Close()
+		assert.NoError(t, err)
+	}
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
-	assert.Equal(t, sdkkms.SecretStatusSecretBox, user.FsConfig.HTTPConfig.Password.GetStatus())
-	assert.NotEmpty(t, user.FsConfig.HTTPConfig.Password.GetPayload())
-	assert.Empty(t, user.FsConfig.HTTPConfig.Password.GetAdditionalData())
-	assert.Empty(t, user.FsConfig.HTTPConfig.Password.GetKey())
-	assert.Equal(t, sdkkms.SecretStatusSecretBox, user.FsConfig.HTTPConfig.APIKey.GetStatus())
-	assert.NotEmpty(t, user.FsConfig.HTTPConfig.APIKey.GetPayload())
-	assert.Empty(t, user.FsConfig.HTTPConfig.APIKey.GetAdditionalData())
-	assert.Empty(t, user.FsConfig.HTTPConfig.APIKey.GetKey())
+	err = os.RemoveAll(localPath)
+	assert.NoError(t, err)
+	err = os.RemoveAll(cryptPath)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestCompressFsConfig(t *testing.T) {
+	u := getTestUser()
+	u.FsConfig.CompressConfig = vfs.CompressFsConfig{
+		Algo: "invalid",
+	}
+	_, resp, err := httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	assert.Contains(t, string(resp), "invalid compression algorithm")
+	u.FsConfig.CompressConfig.Algo = vfs.CompressAlgoGzip
+	u.FsConfig.Provider = sdk.CryptedFilesystemProvider
+	u.FsConfig.CryptConfig.Passphrase = kms.NewPlainSecret("passphrase")
+	_, resp, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	assert.Contains(t, string(resp), "compression is not supported")
+	u.FsConfig.Provider = sdk.LocalFilesystemProvider
+	u.FsConfig.CryptConfig = vfs.CryptFsConfig{}
+	u.FsConfig.CompressConfig.SkipExtensions = []string{" JPG", ".jpg", "zip", ""}
+	user, resp, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	assert.Equal(t, vfs.CompressAlgoGzip, user.FsConfig.CompressConfig.Algo)
+	assert.Equal(t, []string{".jpg", ".zip"}, user.FsConfig.CompressConfig.SkipExtensions)
+	// disabling compression removes the skipped extensions
+	user.FsConfig.CompressConfig.Algo = ""
+	user, resp, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err, string(resp))
+	assert.Empty(t, user.FsConfig.CompressConfig.Algo)
+	assert.Empty(t, user.FsConfig.CompressConfig.SkipExtensions)
 
 	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
 }
 
 func TestUserAzureBlobConfig(t *testing.T) {
@@ -6271,159 +7942,438 @@ func TestCloseConnectionAfterUserUpdateDelete(t *testing.T) {
 	assert.NoError(t, err)
 	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "0")
 	assert.NoError(t, err)
-	assert.Len(t, common.Connections.GetStats(""), 2)
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "1")
+	assert.Len(t, common.Connections.GetStats(""), 2)
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "1")
+	assert.NoError(t, err)
+	assert.Len(t, common.Connections.GetStats(""), 0)
+
+	err = common.Connections.Add(fakeConn)
+	assert.NoError(t, err)
+	err = common.Connections.Add(fakeConn1)
+	assert.NoError(t, err)
+	assert.Len(t, common.Connections.GetStats(""), 2)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, common.Connections.GetStats(""), 0)
+}
+
+func TestAdminGenerateRecoveryCodesSaveError(t *testing.T) {
+	err := dataprovider.Close()
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, "")
+	assert.NoError(t, err)
+	providerConf := config.GetProviderConf()
+	providerConf.NamingRules = 7
+	err = dataprovider.Initialize(providerConf, configDir, true)
+	assert.NoError(t, err)
+
+	a := getTestAdmin()
+	a.Username = "adMiN@example.com "
+	admin, _, err := httpdtest.AddAdmin(a, http.StatusCreated)
+	assert.NoError(t, err)
+	configName, key, _, err := mfa.GenerateTOTPSecret(mfa.GetAvailableTOTPConfigNames()[0], admin.Username)
+	assert.NoError(t, err)
+	admin.Filters.TOTPConfig = dataprovider.AdminTOTPConfig{
+		Enabled:    true,
+		ConfigName: configName,
+		Secret:     kms.NewPlainSecret(key.Secret()),
+	}
+	admin.Password = defaultTokenAuthPass
+	err = dataprovider.UpdateAdmin(&admin, "", "", "")
+	assert.NoError(t, err)
+	admin, _, err = httpdtest.GetAdminByUsername(a.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.True(t, admin.Filters.TOTPConfig.Enabled)
+
+	passcode, err := generateTOTPPasscode(key.Secret())
+	assert.NoError(t, err)
+	adminAPIToken, err := getJWTAPITokenFromTestServerWithPasscode(a.Username, defaultTokenAuthPass, passcode)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, adminAPIToken)
+
+	err = dataprovider.Close()
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, "")
+	assert.NoError(t, err)
+	providerConf = config.GetProviderConf()
+	providerConf.BackupsPath = backupsPath
+	err = dataprovider.Initialize(providerConf, configDir, true)
+	assert.NoError(t, err)
+	if config.GetProviderConf().Driver == dataprovider.MemoryDataProviderName {
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, admin2FARecoveryCodesPath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, adminAPIToken)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestNamingRules(t *testing.T) {
+	smtpCfg := smtp.Config{
+		Host:          "127.0.0.1",
+		Port:          3525,
+		From:          "notification@example.com",
+		TemplatesPath: "templates",
+	}
+	err := smtpCfg.Initialize(configDir, true)
+	require.NoError(t, err)
+	err = dataprovider.Close()
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, "")
+	assert.NoError(t, err)
+	providerConf := config.GetProviderConf()
+	providerConf.NamingRules = 7
+	err = dataprovider.Initialize(providerConf, configDir, true)
+	assert.NoError(t, err)
+
+	u := getTestUser()
+	u.Username = " uSeR@user.me "
+	u.Email = dataprovider.ConvertName(u.Username)
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	assert.Equal(t, "user@user.me", user.Username)
+	configName, key, _, err := mfa.GenerateTOTPSecret(mfa.GetAvailableTOTPConfigNames()[0], user.Username)
+	assert.NoError(t, err)
+	user.Filters.TOTPConfig = dataprovider.UserTOTPConfig{
+		Enabled:    true,
+		ConfigName: configName,
+		Secret:     kms.NewPlainSecret(key.Secret()),
+		Protocols:  []string{common.ProtocolSSH},
+	}
+	user.Password = u.Password
+	err = dataprovider.UpdateUser(&user, "", "", "")
+	assert.NoError(t, err)
+	user.Username = u.Username
+	user.AdditionalInfo = "info"
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(u.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.True(t, user.Filters.TOTPConfig.Enabled)
+
+	r := getTestRole()
+	r.Name = "role@mycompany"
+	role, _, err := httpdtest.AddRole(r, http.StatusCreated)
+	assert.NoError(t, err)
+
+	a := getTestAdmin()
+	a.Username = "admiN@example.com "
+	admin, _, err := httpdtest.AddAdmin(a, http.StatusCreated)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin@example.com", admin.Username)
+	admin.Email = dataprovider.ConvertName(a.Username)
+	admin.Username = a.Username
+	admin, _, err = httpdtest.UpdateAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	admin, _, err = httpdtest.GetAdminByUsername(a.Username, http.StatusOK)
+	assert.NoError(t, err)
+
+	f := vfs.BaseVirtualFolder{
+		Name:       "文件夹AB",
+		MappedPath: filepath.Clean(os.TempDir()),
+	}
+	folder, resp, err := httpdtest.AddFolder(f, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	assert.Equal(t, "文件夹ab", folder.Name)
+	folder.Name = f.Name
+	folder.Description = folder.Name
+	_, resp, err = httpdtest.UpdateFolder(folder, http.StatusOK)
+	assert.NoError(t, err, string(resp))
+	folder, resp, err = httpdtest.GetFolderByName(f.Name, http.StatusOK)
+	assert.NoError(t, err, string(resp))
+	assert.Equal(t, "文件夹AB", folder.Description)
+	_, err = httpdtest.RemoveFolder(f, http.StatusOK)
+	assert.NoError(t, err)
+	token, err := getJWTWebClientTokenFromTestServer(u.Username, defaultPassword)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token)
+	adminAPIToken, err := getJWTAPITokenFromTestServer(a.Username, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, adminAPIToken)
+
+	err = dataprovider.Close()
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, "")
+	assert.NoError(t, err)
+	providerConf = config.GetProviderConf()
+	providerConf.BackupsPath = backupsPath
+	err = dataprovider.Initialize(providerConf, configDir, true)
+	assert.NoError(t, err)
+	if config.GetProviderConf().Driver == dataprovider.MemoryDataProviderName {
+		return
+	}
+
+	csrfToken, err := getCSRFToken(httpBaseURL + webClientLoginPath)
+	assert.NoError(t, err)
+	token, err = getJWTWebClientTokenFromTestServer(user.Username, defaultPassword)
+	assert.NoError(t, err)
+	form := make(url.Values)
+	form.Set(csrfFormToken, csrfToken)
+	req, err := http.NewRequest(http.MethodPost, webClientProfilePath, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidUser)
+	// test user reset password. Setting the new password will fail because the username is not valid
+	form = make(url.Values)
+	form.Set("username", user.Username)
+	form.Set(csrfFormToken, csrfToken)
+	lastResetCode = ""
+	req, err = http.NewRequest(http.MethodPost, webClientForgotPwdPath, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.GreaterOrEqual(t, len(lastResetCode), 20)
+	form = make(url.Values)
+	form.Set(csrfFormToken, csrfToken)
+	form.Set("code", lastResetCode)
+	form.Set("password", defaultPassword)
+	form.Set("confirm_password", defaultPassword)
+	req, err = http.NewRequest(http.MethodPost, webClientResetPwdPath, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidUser)
+
+	adminAPIToken, err = getJWTAPITokenFromTestServer(admin.Username, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	userAPIToken, err := getJWTAPIUserTokenFromTestServer(user.Username, defaultPassword)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPut, userPath+"/"+user.Username+"/2fa/disable", nil) //nolint:goconst
+	assert.NoError(t, err)
+	setBearerForReq(req, adminAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
+
+	req, err = http.NewRequest(http.MethodPost, user2FARecoveryCodesPath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, userAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
+
+	apiKeyAuthReq := make(map[string]bool)
+	apiKeyAuthReq["allow_api_key_auth"] = true
+	asJSON, err := json.Marshal(apiKeyAuthReq)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPut, userProfilePath, bytes.NewBuffer(asJSON))
 	assert.NoError(t, err)
-	assert.Len(t, common.Connections.GetStats(""), 0)
+	setBearerForReq(req, userAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
 
-	err = common.Connections.Add(fakeConn)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
-	err = common.Connections.Add(fakeConn1)
+	err = os.RemoveAll(user.GetHomeDir())
 	assert.NoError(t, err)
-	assert.Len(t, common.Connections.GetStats(""), 2)
-	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+
+	token, err = getJWTWebTokenFromTestServer(admin.Username, defaultTokenAuthPass)
 	assert.NoError(t, err)
-	assert.Len(t, common.Connections.GetStats(""), 0)
-}
+	csrfToken, err = getCSRFToken(httpBaseURL + webLoginPath)
+	assert.NoError(t, err)
+	form = make(url.Values)
+	form.Set(csrfFormToken, csrfToken)
+	req, _ = http.NewRequest(http.MethodPost, webAdminProfilePath, bytes.NewBuffer([]byte(form.Encode())))
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidUser)
 
-func TestAdminGenerateRecoveryCodesSaveError(t *testing.T) {
-	err := dataprovider.Close()
+	req, _ = http.NewRequest(http.MethodPost, path.Join(webAdminRolePath, role.Name), bytes.NewBuffer([]byte(form.Encode())))
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidName)
+
+	apiKeyAuthReq = make(map[string]bool)
+	apiKeyAuthReq["allow_api_key_auth"] = true
+	asJSON, err = json.Marshal(apiKeyAuthReq)
 	assert.NoError(t, err)
-	err = config.LoadConfig(configDir, "")
+	req, err = http.NewRequest(http.MethodPut, adminProfilePath, bytes.NewBuffer(asJSON))
 	assert.NoError(t, err)
-	providerConf := config.GetProviderConf()
-	providerConf.NamingRules = 7
-	err = dataprovider.Initialize(providerConf, configDir, true)
+	setBearerForReq(req, adminAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
+
+	req, err = http.NewRequest(http.MethodPut, adminPath+"/"+admin.Username+"/2fa/disable", nil)
 	assert.NoError(t, err)
+	setBearerForReq(req, adminAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
 
-	a := getTestAdmin()
-	a.Username = "adMiN@example.com "
-	admin, _, err := httpdtest.AddAdmin(a, http.StatusCreated)
+	// test admin reset password
+	form = make(url.Values)
+	form.Set("username", admin.Username)
+	form.Set(csrfFormToken, csrfToken)
+	lastResetCode = ""
+	req, err = http.NewRequest(http.MethodPost, webAdminForgotPwdPath, bytes.NewBuffer([]byte(form.Encode())))
 	assert.NoError(t, err)
-	configName, key, _, err := mfa.GenerateTOTPSecret(mfa.GetAvailableTOTPConfigNames()[0], admin.Username)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.GreaterOrEqual(t, len(lastResetCode), 20)
+	form = make(url.Values)
+	form.Set(csrfFormToken, csrfToken)
+	form.Set("code", lastResetCode)
+	form.Set("password", defaultPassword)
+	form.Set("confirm_password", defaultPassword)
+	req, err = http.NewRequest(http.MethodPost, webAdminResetPwdPath, bytes.NewBuffer([]byte(form.Encode())))
 	assert.NoError(t, err)
-	admin.Filters.TOTPConfig = dataprovider.AdminTOTPConfig{
-		Enabled:    true,
-		ConfigName: configName,
-		Secret:     kms.NewPlainSecret(key.Secret()),
-	}
-	admin.Password = defaultTokenAuthPass
-	err = dataprovider.UpdateAdmin(&admin, "", "", "")
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorChangePwdGeneric)
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
 	assert.NoError(t, err)
-	admin, _, err = httpdtest.GetAdminByUsername(a.Username, http.StatusOK)
+	_, err = httpdtest.RemoveRole(role, http.StatusOK)
 	assert.NoError(t, err)
-	assert.True(t, admin.Filters.TOTPConfig.Enabled)
 
-	passcode, err := generateTOTPPasscode(key.Secret())
+	smtpCfg = smtp.Config{}
+	err = smtpCfg.Initialize(configDir, true)
+	require.NoError(t, err)
+}
+
+func TestUserPassword(t *testing.T) {
+	u := getTestUser()
+	u.Password = ""
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
 	assert.NoError(t, err)
-	adminAPIToken, err := getJWTAPITokenFromTestServerWithPasscode(a.Username, defaultTokenAuthPass, passcode)
+	assert.False(t, user.HasPassword)
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
 	assert.NoError(t, err)
-	assert.NotEmpty(t, adminAPIToken)
+	assert.False(t, user.HasPassword)
 
-	err = dataprovider.Close()
-	assert.NoError(t, err)
-	err = config.LoadConfig(configDir, "")
+	user.Password = defaultPassword
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
 	assert.NoError(t, err)
-	providerConf = config.GetProviderConf()
-	providerConf.BackupsPath = backupsPath
-	err = dataprovider.Initialize(providerConf, configDir, true)
+	assert.True(t, user.HasPassword)
+
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
-	if config.GetProviderConf().Driver == dataprovider.MemoryDataProviderName {
-		return
+
+	rawUser := map[string]any{
+		"username": user.Username,
+		"home_dir": filepath.Join(homeBasePath, defaultUsername),
+		"permissions": map[string][]string{
+			"/": {"*"},
+		},
 	}
-	req, err := http.NewRequest(http.MethodPost, admin2FARecoveryCodesPath, nil)
+	userAsJSON, err := json.Marshal(rawUser)
 	assert.NoError(t, err)
-	setBearerForReq(req, adminAPIToken)
+	req, err := http.NewRequest(http.MethodPut, path.Join(userPath, user.Username), bytes.NewBuffer(userAsJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
 	rr := executeRequest(req)
-	checkResponseCode(t, http.StatusBadRequest, rr)
-	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
+	checkResponseCode(t, http.StatusOK, rr)
+	// the previous password must be preserved
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.True(t, user.HasPassword)
+	// update the user with an empty password field, the password will be unset
+	rawUser["password"] = ""
+	userAsJSON, err = json.Marshal(rawUser)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPut, path.Join(userPath, user.Username), bytes.NewBuffer(userAsJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.False(t, user.HasPassword)
 
-	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
 }
 
-func TestNamingRules(t *testing.T) {
-	smtpCfg := smtp.Config{
-		Host:          "127.0.0.1",
-		Port:          3525,
-		From:          "notification@example.com",
-		TemplatesPath: "templates",
-	}
-	err := smtpCfg.Initialize(configDir, true)
-	require.NoError(t, err)
-	err = dataprovider.Close()
+func TestSaveErrors(t *testing.T) {
+	err := dataprovider.Close()
 	assert.NoError(t, err)
 	err = config.LoadConfig(configDir, "")
 	assert.NoError(t, err)
 	providerConf := config.GetProviderConf()
-	providerConf.NamingRules = 7
+	providerConf.NamingRules = 1
 	err = dataprovider.Initialize(providerConf, configDir, true)
 	assert.NoError(t, err)
 
+	recCode := "recovery code"
+	recoveryCodes := []dataprovider.RecoveryCode{
+		{
+			Secret: kms.NewPlainSecret(recCode),
+			Used:   false,
+		},
+	}
+
 	u := getTestUser()
-	u.Username = " uSeR@user.me "
-	u.Email = dataprovider.ConvertName(u.Username)
+	u.Username = "user@example.com"
 	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
 	assert.NoError(t, err)
-	assert.Equal(t, "user@user.me", user.Username)
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
 	configName, key, _, err := mfa.GenerateTOTPSecret(mfa.GetAvailableTOTPConfigNames()[0], user.Username)
 	assert.NoError(t, err)
+	user.Password = u.Password
 	user.Filters.TOTPConfig = dataprovider.UserTOTPConfig{
 		Enabled:    true,
 		ConfigName: configName,
 		Secret:     kms.NewPlainSecret(key.Secret()),
-		Protocols:  []string{common.ProtocolSSH},
+		Protocols:  []string{common.ProtocolSSH, common.ProtocolHTTP},
 	}
-	user.Password = u.Password
+	user.Filters.RecoveryCodes = recoveryCodes
 	err = dataprovider.UpdateUser(&user, "", "", "")
 	assert.NoError(t, err)
-	user.Username = u.Username
-	user.AdditionalInfo = "info"
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
-	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(u.Username, http.StatusOK)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
 	assert.NoError(t, err)
 	assert.True(t, user.Filters.TOTPConfig.Enabled)
-
-	r := getTestRole()
-	r.Name = "role@mycompany"
-	role, _, err := httpdtest.AddRole(r, http.StatusCreated)
-	assert.NoError(t, err)
+	assert.Len(t, user.Filters.RecoveryCodes, 1)
 
 	a := getTestAdmin()
-	a.Username = "admiN@example.com "
+	a.Username = "admin@example.com"
 	admin, _, err := httpdtest.AddAdmin(a, http.StatusCreated)
 	assert.NoError(t, err)
-	assert.Equal(t, "admin@example.com", admin.Username)
-	admin.Email = dataprovider.ConvertName(a.Username)
-	admin.Username = a.Username
+	admin.Email = admin.Username
 	admin, _, err = httpdtest.UpdateAdmin(admin, http.StatusOK)
 	assert.NoError(t, err)
-	admin, _, err = httpdtest.GetAdminByUsername(a.Username, http.StatusOK)
-	assert.NoError(t, err)
-
-	f := vfs.BaseVirtualFolder{
-		Name:       "文件夹AB",
-		MappedPath: filepath.Clean(os.TempDir()),
+	admin.Password = a.Password
+	admin.Filters.TOTPConfig = dataprovider.AdminTOTPConfig{
+		Enabled:    true,
+		ConfigName: configName,
+		Secret:     kms.NewPlainSecret(key.Secret()),
 	}
-	folder, resp, err := httpdtest.AddFolder(f, http.StatusCreated)
-	assert.NoError(t, err, string(resp))
-	assert.Equal(t, "文件夹ab", folder.Name)
-	folder.Name = f.Name
-	folder.Description = folder.Name
-	_, resp, err = httpdtest.UpdateFolder(folder, http.StatusOK)
-	assert.NoError(t, err, string(resp))
-	folder, resp, err = httpdtest.GetFolderByName(f.Name, http.StatusOK)
-	assert.NoError(t, err, string(resp))
-	assert.Equal(t, "文件夹AB", folder.Description)
-	_, err = httpdtest.RemoveFolder(f, http.StatusOK)
+	admin.Filters.RecoveryCodes = recoveryCodes
+	err = dataprovider.UpdateAdmin(&admin, "", "", "")
 	assert.NoError(t, err)
-	token, err := getJWTWebClientTokenFromTestServer(u.Username, defaultPassword)
+	admin, _, err = httpdtest.GetAdminByUsername(admin.Username, http.StatusOK)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, token)
-	adminAPIToken, err := getJWTAPITokenFromTestServer(a.Username, defaultTokenAuthPass)
+	assert.True(t, admin.Filters.TOTPConfig.Enabled)
+	assert.Len(t, admin.Filters.RecoveryCodes, 1)
+
+	r := getTestRole()
+	r.Name = "role@mycompany"
+	role, _, err := httpdtest.AddRole(r, http.StatusCreated)
 	assert.NoError(t, err)
-	assert.NotEmpty(t, adminAPIToken)
 
 	err = dataprovider.Close()
 	assert.NoError(t, err)
@@ -6437,408 +8387,678 @@ func TestNamingRules(t *testing.T) {
 		return
 	}
 
-	csrfToken, err := getCSRFToken(httpBaseURL + webClientLoginPath)
+	_, resp, err := httpdtest.UpdateRole(role, http.StatusBadRequest)
 	assert.NoError(t, err)
-	token, err = getJWTWebClientTokenFromTestServer(user.Username, defaultPassword)
+	assert.Contains(t, string(resp), "the following characters are allowed")
+
+	csrfToken, err := getCSRFToken(httpBaseURL + webLoginPath)
 	assert.NoError(t, err)
-	form := make(url.Values)
-	form.Set(csrfFormToken, csrfToken)
-	req, err := http.NewRequest(http.MethodPost, webClientProfilePath, bytes.NewBuffer([]byte(form.Encode())))
+	form := getLoginForm(a.Username, a.Password, csrfToken)
+	req, err := http.NewRequest(http.MethodPost, webLoginPath, bytes.NewBuffer([]byte(form.Encode())))
 	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	setJWTCookieForReq(req, token)
 	rr := executeRequest(req)
-	checkResponseCode(t, http.StatusOK, rr)
-	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidUser)
-	// test user reset password. Setting the new password will fail because the username is not valid
+	assert.Equal(t, http.StatusFound, rr.Code)
+	assert.Equal(t, webAdminTwoFactorPath, rr.Header().Get("Location"))
+	cookie, err := getCookieFromResponse(rr)
+	assert.NoError(t, err)
+
 	form = make(url.Values)
-	form.Set("username", user.Username)
+	form.Set("recovery_code", recCode)
 	form.Set(csrfFormToken, csrfToken)
-	lastResetCode = ""
-	req, err = http.NewRequest(http.MethodPost, webClientForgotPwdPath, bytes.NewBuffer([]byte(form.Encode())))
+	req, err = http.NewRequest(http.MethodPost, webAdminTwoFactorRecoveryPath, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, cookie)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), util.I18nError500Message)
+
+	csrfToken, err = getCSRFToken(httpBaseURL + webClientLoginPath)
+	assert.NoError(t, err)
+	form = getLoginForm(u.Username, u.Password, csrfToken)
+	req, err = http.NewRequest(http.MethodPost, webClientLoginPath, bytes.NewBuffer([]byte(form.Encode())))
 	assert.NoError(t, err)
 	req.RemoteAddr = defaultRemoteAddr
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	rr = executeRequest(req)
 	assert.Equal(t, http.StatusFound, rr.Code)
-	assert.GreaterOrEqual(t, len(lastResetCode), 20)
+	assert.Equal(t, webClientTwoFactorPath, rr.Header().Get("Location"))
+	cookie, err = getCookieFromResponse(rr)
+	assert.NoError(t, err)
+
 	form = make(url.Values)
+	form.Set("recovery_code", recCode)
 	form.Set(csrfFormToken, csrfToken)
-	form.Set("code", lastResetCode)
-	form.Set("password", defaultPassword)
-	form.Set("confirm_password", defaultPassword)
-	req, err = http.NewRequest(http.MethodPost, webClientResetPwdPath, bytes.NewBuffer([]byte(form.Encode())))
+	req, err = http.NewRequest(http.MethodPost, webClientTwoFactorRecoveryPath, bytes.NewBuffer([]byte(form.Encode())))
 	assert.NoError(t, err)
+	setJWTCookieForReq(req, cookie)
 	req.RemoteAddr = defaultRemoteAddr
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	rr = executeRequest(req)
-	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidUser)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), util.I18nError500Message)
 
-	adminAPIToken, err = getJWTAPITokenFromTestServer(admin.Username, defaultTokenAuthPass)
-	assert.NoError(t, err)
-	userAPIToken, err := getJWTAPIUserTokenFromTestServer(user.Username, defaultPassword)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
-	req, err = http.NewRequest(http.MethodPut, userPath+"/"+user.Username+"/2fa/disable", nil) //nolint:goconst
+	err = os.RemoveAll(user.GetHomeDir())
 	assert.NoError(t, err)
-	setBearerForReq(req, adminAPIToken)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusBadRequest, rr)
-	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
 
-	req, err = http.NewRequest(http.MethodPost, user2FARecoveryCodesPath, nil)
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
 	assert.NoError(t, err)
-	setBearerForReq(req, userAPIToken)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusBadRequest, rr)
-	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
+	_, err = httpdtest.RemoveRole(role, http.StatusOK)
+	assert.NoError(t, err)
+}
 
-	apiKeyAuthReq := make(map[string]bool)
-	apiKeyAuthReq["allow_api_key_auth"] = true
-	asJSON, err := json.Marshal(apiKeyAuthReq)
+func TestUserBaseDir(t *testing.T) {
+	err := dataprovider.Close()
 	assert.NoError(t, err)
-	req, err = http.NewRequest(http.MethodPut, userProfilePath, bytes.NewBuffer(asJSON))
+	err = config.LoadConfig(configDir, "")
 	assert.NoError(t, err)
-	setBearerForReq(req, userAPIToken)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusBadRequest, rr)
-	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
-
+	providerConf := config.GetProviderConf()
+	providerConf.UsersBaseDir = homeBasePath
+	err = dataprovider.Initialize(providerConf, configDir, true)
+	assert.NoError(t, err)
+	u := getTestUser()
+	u.HomeDir = ""
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	if assert.Error(t, err) {
+		assert.EqualError(t, err, "home dir mismatch")
+	}
+	assert.Equal(t, filepath.Join(providerConf.UsersBaseDir, u.Username), user.HomeDir)
 	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
-	err = os.RemoveAll(user.GetHomeDir())
+	err = dataprovider.Close()
 	assert.NoError(t, err)
-
-	token, err = getJWTWebTokenFromTestServer(admin.Username, defaultTokenAuthPass)
+	err = config.LoadConfig(configDir, "")
 	assert.NoError(t, err)
-	csrfToken, err = getCSRFToken(httpBaseURL + webLoginPath)
+	providerConf = config.GetProviderConf()
+	providerConf.BackupsPath = backupsPath
+	err = dataprovider.Initialize(providerConf, configDir, true)
 	assert.NoError(t, err)
-	form = make(url.Values)
-	form.Set(csrfFormToken, csrfToken)
-	req, _ = http.NewRequest(http.MethodPost, webAdminProfilePath, bytes.NewBuffer([]byte(form.Encode())))
-	req.RemoteAddr = defaultRemoteAddr
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	setJWTCookieForReq(req, token)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusOK, rr)
-	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidUser)
-
-	req, _ = http.NewRequest(http.MethodPost, path.Join(webAdminRolePath, role.Name), bytes.NewBuffer([]byte(form.Encode())))
-	req.RemoteAddr = defaultRemoteAddr
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	setJWTCookieForReq(req, token)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusOK, rr)
-	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidName)
+}
 
-	apiKeyAuthReq = make(map[string]bool)
-	apiKeyAuthReq["allow_api_key_auth"] = true
-	asJSON, err = json.Marshal(apiKeyAuthReq)
+func TestQuotaTrackingDisabled(t *testing.T) {
+	err := dataprovider.Close()
 	assert.NoError(t, err)
-	req, err = http.NewRequest(http.MethodPut, adminProfilePath, bytes.NewBuffer(asJSON))
+	err = config.LoadConfig(configDir, "")
 	assert.NoError(t, err)
-	setBearerForReq(req, adminAPIToken)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusBadRequest, rr)
-	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
-
-	req, err = http.NewRequest(http.MethodPut, adminPath+"/"+admin.Username+"/2fa/disable", nil)
+	providerConf := config.GetProviderConf()
+	providerConf.TrackQuota = 0
+	err = dataprovider.Initialize(providerConf, configDir, true)
+	assert.NoError(t, err)
+	// user quota scan must fail
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+	_, err = httpdtest.StartQuotaScan(user, http.StatusForbidden)
+	assert.NoError(t, err)
+	_, err = httpdtest.UpdateQuotaUsage(user, "", http.StatusForbidden)
+	assert.NoError(t, err)
+	_, err = httpdtest.UpdateTransferQuotaUsage(user, "", http.StatusForbidden)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	// folder quota scan must fail
+	folder := vfs.BaseVirtualFolder{
+		Name:       "folder_quota_test",
+		MappedPath: filepath.Clean(os.TempDir()),
+	}
+	folder, resp, err := httpdtest.AddFolder(folder, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	_, err = httpdtest.StartFolderQuotaScan(folder, http.StatusForbidden)
+	assert.NoError(t, err)
+	_, err = httpdtest.UpdateFolderQuotaUsage(folder, "", http.StatusForbidden)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
 	assert.NoError(t, err)
-	setBearerForReq(req, adminAPIToken)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusBadRequest, rr)
-	assert.Contains(t, rr.Body.String(), "the following characters are allowed")
 
-	// test admin reset password
-	form = make(url.Values)
-	form.Set("username", admin.Username)
-	form.Set(csrfFormToken, csrfToken)
-	lastResetCode = ""
-	req, err = http.NewRequest(http.MethodPost, webAdminForgotPwdPath, bytes.NewBuffer([]byte(form.Encode())))
+	err = dataprovider.Close()
 	assert.NoError(t, err)
-	req.RemoteAddr = defaultRemoteAddr
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	rr = executeRequest(req)
-	assert.Equal(t, http.StatusFound, rr.Code)
-	assert.GreaterOrEqual(t, len(lastResetCode), 20)
-	form = make(url.Values)
-	form.Set(csrfFormToken, csrfToken)
-	form.Set("code", lastResetCode)
-	form.Set("password", defaultPassword)
-	form.Set("confirm_password", defaultPassword)
-	req, err = http.NewRequest(http.MethodPost, webAdminResetPwdPath, bytes.NewBuffer([]byte(form.Encode())))
+	err = config.LoadConfig(configDir, "")
 	assert.NoError(t, err)
-	req.RemoteAddr = defaultRemoteAddr
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	rr = executeRequest(req)
-	assert.Equal(t, http.StatusOK, rr.Code)
-	assert.Contains(t, rr.Body.String(), util.I18nErrorChangePwdGeneric)
+	providerConf = config.GetProviderConf()
+	providerConf.BackupsPath = backupsPath
+	err = dataprovider.Initialize(providerConf, configDir, true)
+	assert.NoError(t, err)
+}
 
-	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+func TestAuditTrail(t *testing.T) {
+	err := dataprovider.Close()
 	assert.NoError(t, err)
-	_, err = httpdtest.RemoveRole(role, http.StatusOK)
+	err = config.LoadConfig(configDir, "")
+	assert.NoError(t, err)
+	providerConf := config.GetProviderConf()
+	providerConf.BackupsPath = backupsPath
+	providerConf.AuditTrail.Enabled = providerConf.IsAuditTrailSupported()
+	err = dataprovider.Initialize(providerConf, configDir, true)
 	assert.NoError(t, err)
 
-	smtpCfg = smtp.Config{}
-	err = smtpCfg.Initialize(configDir, true)
-	require.NoError(t, err)
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	if !providerConf.IsAuditTrailSupported() {
+		req, err := http.NewRequest(http.MethodGet, auditLogsPath, nil)
+		assert.NoError(t, err)
+		setBearerForReq(req, token)
+		rr := executeRequest(req)
+		checkResponseCode(t, http.StatusNotImplemented, rr)
+	} else {
+		u := getTestUser()
+		u.Description = "audit trail"
+		user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+		assert.NoError(t, err)
+		user.Description = "audit trail updated"
+		user.Filters.MaxUploadFileSize = 1024
+		user.Password = ""
+		_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+		assert.NoError(t, err)
+		webToken, err := getJWTWebTokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+		assert.NoError(t, err)
+		csrfToken, err := getCSRFToken(httpBaseURL + webLoginPath)
+		assert.NoError(t, err)
+		form := make(url.Values)
+		form.Set("mode", "read_only")
+		form.Set(csrfFormToken, csrfToken)
+		req, err := http.NewRequest(http.MethodPost, path.Join(webImpersonateUserPath, user.Username),
+			bytes.NewBuffer([]byte(form.Encode())))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		setJWTCookieForReq(req, webToken)
+		rr := executeRequest(req)
+		checkResponseCode(t, http.StatusFound, rr)
+		_, err = httpdtest.RemoveUser(user, http.StatusOK)
+		assert.NoError(t, err)
+
+		req, err = http.NewRequest(http.MethodGet, auditLogsPath+"?order=ASC&object_types=user&object_name="+
+			url.QueryEscape(user.Username), nil)
+		assert.NoError(t, err)
+		setBearerForReq(req, token)
+		rr = executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+		var entries []dataprovider.AuditLogEntry
+		err = json.Unmarshal(rr.Body.Bytes(), &entries)
+		assert.NoError(t, err)
+		if assert.Len(t, entries, 4) {
+			assert.Equal(t, "add", entries[0].Operation)
+			assert.Equal(t, defaultTokenAuthUser, entries[0].Executor)
+			assert.NotEmpty(t, entries[0].ObjectData)
+			assert.NotContains(t, string(entries[0].ObjectData), defaultPassword)
+			assert.Equal(t, "update", entries[1].Operation)
+			changes := make(map[string]dataprovider.AuditLogChange)
+			for _, c := range entries[1].Diff {
+				changes[c.Path] = c
+			}
+			assert.Len(t, changes, 2)
+			if assert.Contains(t, changes, "/description") {
+				assert.Equal(t, "audit trail", changes["/description"].Old)
+				assert.Equal(t, "audit trail updated", changes["/description"].New)
+			}
+			assert.Contains(t, changes, "/filters/max_upload_file_size")
+			assert.Equal(t, "impersonate", entries[2].Operation)
+			assert.Equal(t, defaultTokenAuthUser, entries[2].Executor)
+			assert.Contains(t, string(entries[2].ObjectData), `"read_only":true`)
+			assert.Equal(t, "delete", entries[3].Operation)
+			assert.Empty(t, entries[3].ObjectData)
+			assert.NotEmpty(t, entries[3].Diff)
+			// pagination
+			req, err = http.NewRequest(http.MethodGet, fmt.Sprintf("%s?order=ASC&object_types=user,admin&operations=update,delete&from_id=%d&omit_object_data=true",
+				auditLogsPath, entries[0].ID), nil)
+			assert.NoError(t, err)
+			setBearerForReq(req, token)
+			rr = executeRequest(req)
+			checkResponseCode(t, http.StatusOK, rr)
+			var page []dataprovider.AuditLogEntry
+			err = json.Unmarshal(rr.Body.Bytes(), &page)
+			assert.NoError(t, err)
+			if assert.Len(t, page, 2) {
+				assert.Equal(t, entries[1].ID, page[0].ID)
+				assert.Empty(t, page[0].ObjectData)
+			}
+		}
+		req, err = http.NewRequest(http.MethodGet, auditLogsPath+"?csv_export=true&executor="+defaultTokenAuthUser, nil)
+		assert.NoError(t, err)
+		setBearerForReq(req, token)
+		rr = executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+		assert.Equal(t, "text/csv", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), user.Username)
+
+		for _, query := range []string{"object_types=invalid", "operations=rename", "from_id=a", "limit=0"} {
+			req, err = http.NewRequest(http.MethodGet, auditLogsPath+"?"+query, nil)
+			assert.NoError(t, err)
+			setBearerForReq(req, token)
+			rr = executeRequest(req)
+			checkResponseCode(t, http.StatusBadRequest, rr)
+		}
+	}
+
+	err = dataprovider.Close()
+	assert.NoError(t, err)
+	err = config.LoadConfig(configDir, "")
+	assert.NoError(t, err)
+	providerConf = config.GetProviderConf()
+	providerConf.BackupsPath = backupsPath
+	err = dataprovider.Initialize(providerConf, configDir, true)
+	assert.NoError(t, err)
 }
 
-func TestUserPassword(t *testing.T) {
-	u := getTestUser()
-	u.Password = ""
-	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+func TestRemoteBackups(t *testing.T) {
+	folder, _, err := httpdtest.AddFolder(vfs.BaseVirtualFolder{
+		Name:       "remote_backups",
+		MappedPath: filepath.Join(os.TempDir(), "remote_backups"),
+	}, http.StatusCreated)
+	assert.NoError(t, err)
+	action, _, err := httpdtest.AddEventAction(dataprovider.BaseEventAction{
+		Name: "remote_backup",
+		Type: dataprovider.ActionTypeBackup,
+		Options: dataprovider.BaseEventActionOptions{
+			BackupConfig: dataprovider.EventActionBackupConfig{
+				Folder:    folder.Name,
+				Path:      "/provider",
+				Retention: 5,
+			},
+		},
+	}, http.StatusCreated)
+	assert.NoError(t, err)
+	localAction, _, err := httpdtest.AddEventAction(dataprovider.BaseEventAction{
+		Name: "local_backup",
+		Type: dataprovider.ActionTypeBackup,
+	}, http.StatusCreated)
 	assert.NoError(t, err)
-	assert.False(t, user.HasPassword)
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
-	assert.False(t, user.HasPassword)
 
-	user.Password = defaultPassword
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	req, err := http.NewRequest(http.MethodGet, path.Join(remoteBackupsPath, action.Name), nil)
 	assert.NoError(t, err)
-	assert.True(t, user.HasPassword)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Equal(t, "[]", strings.TrimSpace(rr.Body.String()))
 
-	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	backupName := "backup_20240101T000000Z.json"
+	err = os.MkdirAll(filepath.Join(folder.MappedPath, "provider"), os.ModePerm)
 	assert.NoError(t, err)
-
-	rawUser := map[string]any{
-		"username": user.Username,
-		"home_dir": filepath.Join(homeBasePath, defaultUsername),
-		"permissions": map[string][]string{
-			"/": {"*"},
+	dump := dataprovider.BackupData{
+		Version: dataprovider.DumpVersion,
+		Groups: []dataprovider.Group{
+			{
+				BaseGroup: sdk.BaseGroup{
+					Name:        "restored_group",
+					Description: "restored from a remote backup",
+				},
+			},
 		},
 	}
-	userAsJSON, err := json.Marshal(rawUser)
+	content, err := json.Marshal(dump)
 	assert.NoError(t, err)
-	req, err := http.NewRequest(http.MethodPut, path.Join(userPath, user.Username), bytes.NewBuffer(userAsJSON))
+	err = os.WriteFile(filepath.Join(folder.MappedPath, "provider", backupName), content, 0666)
+	assert.NoError(t, err)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(remoteBackupsPath, action.Name), nil)
 	assert.NoError(t, err)
 	setBearerForReq(req, token)
-	rr := executeRequest(req)
+	rr = executeRequest(req)
 	checkResponseCode(t, http.StatusOK, rr)
-	// the previous password must be preserved
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	var backups []common.RemoteBackup
+	err = json.Unmarshal(rr.Body.Bytes(), &backups)
 	assert.NoError(t, err)
-	assert.True(t, user.HasPassword)
-	// update the user with an empty password field, the password will be unset
-	rawUser["password"] = ""
-	userAsJSON, err = json.Marshal(rawUser)
+	if assert.Len(t, backups, 1) {
+		assert.Equal(t, backupName, backups[0].Name)
+		assert.Equal(t, int64(len(content)), backups[0].Size)
+		assert.False(t, backups[0].Encrypted)
+	}
+	req, err = http.NewRequest(http.MethodPost, path.Join(remoteBackupsPath, action.Name, backupName, "restore")+"?mode=a", nil)
 	assert.NoError(t, err)
-	req, err = http.NewRequest(http.MethodPut, path.Join(userPath, user.Username), bytes.NewBuffer(userAsJSON))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodPost, path.Join(remoteBackupsPath, action.Name, "backup_20230101T000000Z.json", "restore"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	req, err = http.NewRequest(http.MethodPost, path.Join(remoteBackupsPath, action.Name, "invalid.json", "restore"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodPost, path.Join(remoteBackupsPath, action.Name, backupName, "restore"), nil)
 	assert.NoError(t, err)
 	setBearerForReq(req, token)
 	rr = executeRequest(req)
 	checkResponseCode(t, http.StatusOK, rr)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	group, _, err := httpdtest.GetGroupByName("restored_group", http.StatusOK)
 	assert.NoError(t, err)
-	assert.False(t, user.HasPassword)
+	assert.Equal(t, "restored from a remote backup", group.Description)
+	// backups are not uploaded for this action
+	req, err = http.NewRequest(http.MethodGet, path.Join(remoteBackupsPath, localAction.Name), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodGet, path.Join(remoteBackupsPath, "missing_action"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
 
-	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	_, err = httpdtest.RemoveGroup(group, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveEventAction(action, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveEventAction(localAction, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(folder.MappedPath)
 	assert.NoError(t, err)
 }
 
-func TestSaveErrors(t *testing.T) {
-	err := dataprovider.Close()
+func TestKMSRekey(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
-	err = config.LoadConfig(configDir, "")
+	req, err := http.NewRequest(http.MethodGet, kmsPath+"/status", nil)
 	assert.NoError(t, err)
-	providerConf := config.GetProviderConf()
-	providerConf.NamingRules = 1
-	err = dataprovider.Initialize(providerConf, configDir, true)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var kmsStatus kms.Status
+	err = json.Unmarshal(rr.Body.Bytes(), &kmsStatus)
 	assert.NoError(t, err)
-
-	recCode := "recovery code"
-	recoveryCodes := []dataprovider.RecoveryCode{
-		{
-			Secret: kms.NewPlainSecret(recCode),
-			Used:   false,
-		},
-	}
+	assert.True(t, kmsStatus.IsActive)
+	assert.Equal(t, "Local", kmsStatus.Provider)
+	assert.Empty(t, kmsStatus.Error)
 
 	u := getTestUser()
-	u.Username = "user@example.com"
+	u.FsConfig.Provider = sdk.CryptedFilesystemProvider
+	u.FsConfig.CryptConfig.Passphrase = kms.NewPlainSecret(defaultPassword)
 	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
-	assert.NoError(t, err)
-	configName, key, _, err := mfa.GenerateTOTPSecret(mfa.GetAvailableTOTPConfigNames()[0], user.Username)
+	dbUser, err := dataprovider.UserExists(user.Username, "")
 	assert.NoError(t, err)
-	user.Password = u.Password
-	user.Filters.TOTPConfig = dataprovider.UserTOTPConfig{
-		Enabled:    true,
-		ConfigName: configName,
-		Secret:     kms.NewPlainSecret(key.Secret()),
-		Protocols:  []string{common.ProtocolSSH, common.ProtocolHTTP},
+	passphrase := dbUser.FsConfig.CryptConfig.Passphrase
+	assert.Equal(t, sdkkms.SecretStatusSecretBox, passphrase.GetStatus())
+
+	waitRekey := func() dataprovider.RekeyStatus {
+		var status dataprovider.RekeyStatus
+		assert.Eventually(t, func() bool {
+			req, err := http.NewRequest(http.MethodGet, kmsPath+"/rekey", nil)
+			assert.NoError(t, err)
+			setBearerForReq(req, token)
+			rr := executeRequest(req)
+			checkResponseCode(t, http.StatusOK, rr)
+			err = json.Unmarshal(rr.Body.Bytes(), &status)
+			assert.NoError(t, err)
+			return !status.IsRunning && status.EndTime > 0
+		}, 5*time.Second, 100*time.Millisecond)
+		return status
 	}
-	user.Filters.RecoveryCodes = recoveryCodes
-	err = dataprovider.UpdateUser(&user, "", "", "")
+	// the secrets are already encrypted using the configured provider
+	req, err = http.NewRequest(http.MethodPost, kmsPath+"/rekey", nil)
 	assert.NoError(t, err)
-	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusAccepted, rr)
+	status := waitRekey()
+	assert.Len(t, status.Errors, 0)
+	dbUser, err = dataprovider.UserExists(user.Username, "")
 	assert.NoError(t, err)
-	assert.True(t, user.Filters.TOTPConfig.Enabled)
-	assert.Len(t, user.Filters.RecoveryCodes, 1)
+	assert.True(t, passphrase.IsEqual(dbUser.FsConfig.CryptConfig.Passphrase))
 
-	a := getTestAdmin()
-	a.Username = "admin@example.com"
-	admin, _, err := httpdtest.AddAdmin(a, http.StatusCreated)
+	req, err = http.NewRequest(http.MethodPost, kmsPath+"/rekey?force=true", nil)
 	assert.NoError(t, err)
-	admin.Email = admin.Username
-	admin, _, err = httpdtest.UpdateAdmin(admin, http.StatusOK)
-	assert.NoError(t, err)
-	admin.Password = a.Password
-	admin.Filters.TOTPConfig = dataprovider.AdminTOTPConfig{
-		Enabled:    true,
-		ConfigName: configName,
-		Secret:     kms.NewPlainSecret(key.Secret()),
-	}
-	admin.Filters.RecoveryCodes = recoveryCodes
-	err = dataprovider.UpdateAdmin(&admin, "", "", "")
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusAccepted, rr)
+	status = waitRekey()
+	assert.Len(t, status.Errors, 0)
+	assert.Greater(t, status.Updated, 0)
+	dbUser, err = dataprovider.UserExists(user.Username, "")
 	assert.NoError(t, err)
-	admin, _, err = httpdtest.GetAdminByUsername(admin.Username, http.StatusOK)
+	assert.Equal(t, sdkkms.SecretStatusSecretBox, dbUser.FsConfig.CryptConfig.Passphrase.GetStatus())
+	assert.NotEqual(t, passphrase.GetPayload(), dbUser.FsConfig.CryptConfig.Passphrase.GetPayload())
+	assert.Equal(t, passphrase.GetAdditionalData(), dbUser.FsConfig.CryptConfig.Passphrase.GetAdditionalData())
+	err = dbUser.FsConfig.CryptConfig.Passphrase.Decrypt()
 	assert.NoError(t, err)
-	assert.True(t, admin.Filters.TOTPConfig.Enabled)
-	assert.Len(t, admin.Filters.RecoveryCodes, 1)
+	assert.Equal(t, defaultPassword, dbUser.FsConfig.CryptConfig.Passphrase.GetPayload())
 
-	r := getTestRole()
-	r.Name = "role@mycompany"
-	role, _, err := httpdtest.AddRole(r, http.StatusCreated)
+	admin := getTestAdmin()
+	admin.Username = altAdminUsername
+	admin.Password = altAdminPassword
+	admin.Permissions = []string{dataprovider.PermAdminViewServerStatus}
+	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
+	assert.NoError(t, err)
+	altToken, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, kmsPath+"/rekey", nil)
 	assert.NoError(t, err)
+	setBearerForReq(req, altToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
 
-	err = dataprovider.Close()
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
 	assert.NoError(t, err)
-	err = config.LoadConfig(configDir, "")
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
-	providerConf = config.GetProviderConf()
-	providerConf.BackupsPath = backupsPath
-	err = dataprovider.Initialize(providerConf, configDir, true)
+	err = os.RemoveAll(user.GetHomeDir())
 	assert.NoError(t, err)
-	if config.GetProviderConf().Driver == dataprovider.MemoryDataProviderName {
-		return
-	}
+}
 
-	_, resp, err := httpdtest.UpdateRole(role, http.StatusBadRequest)
+func TestPasswordMigration(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
-	assert.Contains(t, string(resp), "the following characters are allowed")
-
-	csrfToken, err := getCSRFToken(httpBaseURL + webLoginPath)
+	u := getTestUser()
+	u.Password = "$1$b5caebda$VODr/nyhGWgZaY8sJ4x05."
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
 	assert.NoError(t, err)
-	form := getLoginForm(a.Username, a.Password, csrfToken)
-	req, err := http.NewRequest(http.MethodPost, webLoginPath, bytes.NewBuffer([]byte(form.Encode())))
+
+	waitCheck := func() dataprovider.PasswordMigrationStatus {
+		var status dataprovider.PasswordMigrationStatus
+		assert.Eventually(t, func() bool {
+			req, err := http.NewRequest(http.MethodGet, passwordsMigrationPath, nil)
+			assert.NoError(t, err)
+			setBearerForReq(req, token)
+			rr := executeRequest(req)
+			checkResponseCode(t, http.StatusOK, rr)
+			err = json.Unmarshal(rr.Body.Bytes(), &status)
+			assert.NoError(t, err)
+			return !status.IsRunning && status.EndTime > 0
+		}, 5*time.Second, 100*time.Millisecond)
+		return status
+	}
+	req, err := http.NewRequest(http.MethodPost, passwordsMigrationPath+"?expire-after-days=a", nil)
 	assert.NoError(t, err)
-	req.RemoteAddr = defaultRemoteAddr
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setBearerForReq(req, token)
 	rr := executeRequest(req)
-	assert.Equal(t, http.StatusFound, rr.Code)
-	assert.Equal(t, webAdminTwoFactorPath, rr.Header().Get("Location"))
-	cookie, err := getCookieFromResponse(rr)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodPost, passwordsMigrationPath+"?expire-after-days=-1", nil)
 	assert.NoError(t, err)
-
-	form = make(url.Values)
-	form.Set("recovery_code", recCode)
-	form.Set(csrfFormToken, csrfToken)
-	req, err = http.NewRequest(http.MethodPost, webAdminTwoFactorRecoveryPath, bytes.NewBuffer([]byte(form.Encode())))
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	// the user was created now, it must not be expired
+	req, err = http.NewRequest(http.MethodPost, passwordsMigrationPath+"?expire-after-days=1", nil)
 	assert.NoError(t, err)
-	setJWTCookieForReq(req, cookie)
-	req.RemoteAddr = defaultRemoteAddr
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setBearerForReq(req, token)
 	rr = executeRequest(req)
-	assert.Equal(t, http.StatusInternalServerError, rr.Code)
-	assert.Contains(t, rr.Body.String(), util.I18nError500Message)
-
-	csrfToken, err = getCSRFToken(httpBaseURL + webClientLoginPath)
+	checkResponseCode(t, http.StatusAccepted, rr)
+	status := waitCheck()
+	assert.Len(t, status.Errors, 0)
+	assert.Greater(t, status.Users, 0)
+	assert.Equal(t, 1, status.Legacy[dataprovider.PasswordSchemeMD5Crypt])
+	assert.Equal(t, 0, status.Expired)
+	rehashed := status.Rehashed
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
 	assert.NoError(t, err)
-	form = getLoginForm(u.Username, u.Password, csrfToken)
-	req, err = http.NewRequest(http.MethodPost, webClientLoginPath, bytes.NewBuffer([]byte(form.Encode())))
+	assert.Equal(t, int64(0), user.ExpirationDate)
+	// the password hash is upgraded on login
+	_, err = getJWTAPIUserTokenFromTestServer(user.Username, "password")
 	assert.NoError(t, err)
-	req.RemoteAddr = defaultRemoteAddr
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	rr = executeRequest(req)
-	assert.Equal(t, http.StatusFound, rr.Code)
-	assert.Equal(t, webClientTwoFactorPath, rr.Header().Get("Location"))
-	cookie, err = getCookieFromResponse(rr)
+	dbUser, err := dataprovider.UserExists(user.Username, "")
 	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(dbUser.Password, "$2a$"))
 
-	form = make(url.Values)
-	form.Set("recovery_code", recCode)
-	form.Set(csrfFormToken, csrfToken)
-	req, err = http.NewRequest(http.MethodPost, webClientTwoFactorRecoveryPath, bytes.NewBuffer([]byte(form.Encode())))
+	req, err = http.NewRequest(http.MethodPost, passwordsMigrationPath, nil)
 	assert.NoError(t, err)
-	setJWTCookieForReq(req, cookie)
-	req.RemoteAddr = defaultRemoteAddr
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setBearerForReq(req, token)
 	rr = executeRequest(req)
-	assert.Equal(t, http.StatusInternalServerError, rr.Code)
-	assert.Contains(t, rr.Body.String(), util.I18nError500Message)
+	checkResponseCode(t, http.StatusAccepted, rr)
+	status = waitCheck()
+	assert.Len(t, status.Errors, 0)
+	assert.Equal(t, 0, status.Legacy[dataprovider.PasswordSchemeMD5Crypt])
+	assert.Greater(t, status.UpToDate, 0)
+	assert.Equal(t, rehashed+1, status.Rehashed)
 
-	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	admin := getTestAdmin()
+	admin.Username = altAdminUsername
+	admin.Password = altAdminPassword
+	admin.Permissions = []string{dataprovider.PermAdminViewServerStatus}
+	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
 	assert.NoError(t, err)
-	err = os.RemoveAll(user.GetHomeDir())
+	altToken, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
 	assert.NoError(t, err)
-
-	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	req, err = http.NewRequest(http.MethodGet, passwordsMigrationPath, nil)
 	assert.NoError(t, err)
-	_, err = httpdtest.RemoveRole(role, http.StatusOK)
+	setBearerForReq(req, altToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, err = http.NewRequest(http.MethodPost, passwordsMigrationPath, nil)
 	assert.NoError(t, err)
-}
+	setBearerForReq(req, altToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
 
-func TestUserBaseDir(t *testing.T) {
-	err := dataprovider.Close()
-	assert.NoError(t, err)
-	err = config.LoadConfig(configDir, "")
-	assert.NoError(t, err)
-	providerConf := config.GetProviderConf()
-	providerConf.UsersBaseDir = homeBasePath
-	err = dataprovider.Initialize(providerConf, configDir, true)
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
 	assert.NoError(t, err)
-	u := getTestUser()
-	u.HomeDir = ""
-	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
-	if assert.Error(t, err) {
-		assert.EqualError(t, err, "home dir mismatch")
-	}
-	assert.Equal(t, filepath.Join(providerConf.UsersBaseDir, u.Username), user.HomeDir)
 	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
-	err = dataprovider.Close()
-	assert.NoError(t, err)
-	err = config.LoadConfig(configDir, "")
-	assert.NoError(t, err)
-	providerConf = config.GetProviderConf()
-	providerConf.BackupsPath = backupsPath
-	err = dataprovider.Initialize(providerConf, configDir, true)
+	err = os.RemoveAll(user.GetHomeDir())
 	assert.NoError(t, err)
 }
 
-func TestQuotaTrackingDisabled(t *testing.T) {
-	err := dataprovider.Close()
+func TestReconciler(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
-	err = config.LoadConfig(configDir, "")
+	req, err := http.NewRequest(http.MethodGet, reconcilerPath, nil)
 	assert.NoError(t, err)
-	providerConf := config.GetProviderConf()
-	providerConf.TrackQuota = 0
-	err = dataprovider.Initialize(providerConf, configDir, true)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var status dataprovider.ReconcilerStatus
+	err = json.Unmarshal(rr.Body.Bytes(), &status)
 	assert.NoError(t, err)
-	// user quota scan must fail
-	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.False(t, status.Enabled)
+	req, err = http.NewRequest(http.MethodPost, reconcilerPath+"/run", nil)
 	assert.NoError(t, err)
-	_, err = httpdtest.StartQuotaScan(user, http.StatusForbidden)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	groupName := "reconciler_group"
+	folderName := "reconciler_folder"
+	sourceDir := filepath.Join(os.TempDir(), "reconciler")
+	err = os.MkdirAll(sourceDir, os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(sourceDir, "objects.yaml"), []byte(fmt.Sprintf(`folders:
+  - name: %s
+    mapped_path: %s
+groups:
+  - name: %s
+    description: managed by reconciler
+    user_settings:
+      max_sessions: 2
+`, folderName, filepath.Join(os.TempDir(), folderName), groupName)), 0666)
+	assert.NoError(t, err)
+	// invalid files must not prevent the reconciliation of the other objects
+	err = os.WriteFile(filepath.Join(sourceDir, "invalid.json"), []byte(`{"groups":[{"description":"no name"}]}`), 0666)
+	assert.NoError(t, err)
+
+	runReconciler := func() dataprovider.ReconcilerStatus {
+		var runStatus dataprovider.ReconcilerStatus
+		// a reconciliation is started at startup, wait for it to complete
+		assert.Eventually(t, func() bool {
+			req, err := http.NewRequest(http.MethodPost, reconcilerPath+"/run", nil)
+			assert.NoError(t, err)
+			setBearerForReq(req, token)
+			rr := executeRequest(req)
+			if rr.Code != http.StatusOK {
+				return false
+			}
+			err = json.Unmarshal(rr.Body.Bytes(), &runStatus)
+			assert.NoError(t, err)
+			return true
+		}, 2*time.Second, 100*time.Millisecond)
+		return runStatus
+	}
+	initializeProvider := func(mode int) {
+		err := dataprovider.Close()
+		assert.NoError(t, err)
+		err = config.LoadConfig(configDir, "")
+		assert.NoError(t, err)
+		providerConf := config.GetProviderConf()
+		providerConf.BackupsPath = backupsPath
+		providerConf.Reconciler.SourceDir = sourceDir
+		providerConf.Reconciler.Mode = mode
+		err = dataprovider.Initialize(providerConf, configDir, true)
+		assert.NoError(t, err)
+	}
+
+	initializeProvider(dataprovider.ReconcilerModeReport)
+	status = runReconciler()
+	assert.True(t, status.Enabled)
+	assert.Equal(t, dataprovider.ReconcilerModeReport, status.Mode)
+	assert.Equal(t, 3, status.Objects)
+	assert.NotEmpty(t, status.LastError)
+	if assert.Len(t, status.Drifts, 2) {
+		for _, drift := range status.Drifts {
+			assert.Equal(t, dataprovider.ReconcilerDriftMissing, drift.Reason)
+			assert.False(t, drift.Applied)
+		}
+	}
+	_, _, err = httpdtest.GetGroupByName(groupName, http.StatusNotFound)
 	assert.NoError(t, err)
-	_, err = httpdtest.UpdateQuotaUsage(user, "", http.StatusForbidden)
+
+	initializeProvider(dataprovider.ReconcilerModeApply)
+	status = runReconciler()
+	assert.Equal(t, dataprovider.ReconcilerModeApply, status.Mode)
+	assert.Len(t, status.Drifts, 0)
+	group, _, err := httpdtest.GetGroupByName(groupName, http.StatusOK)
 	assert.NoError(t, err)
-	_, err = httpdtest.UpdateTransferQuotaUsage(user, "", http.StatusForbidden)
+	assert.Equal(t, "managed by reconciler", group.Description)
+	assert.Equal(t, 2, group.UserSettings.MaxSessions)
+	folder, _, err := httpdtest.GetFolderByName(folderName, http.StatusOK)
 	assert.NoError(t, err)
-	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	// changes to the declared fields are reverted, the other fields are preserved
+	group.Description = "changed"
+	group.UserSettings.MaxSessions = 3
+	group.UserSettings.Filters.MaxUploadFileSize = 100
+	_, _, err = httpdtest.UpdateGroup(group, http.StatusOK)
 	assert.NoError(t, err)
-	// folder quota scan must fail
-	folder := vfs.BaseVirtualFolder{
-		Name:       "folder_quota_test",
-		MappedPath: filepath.Clean(os.TempDir()),
+	status = runReconciler()
+	if assert.Len(t, status.Drifts, 1) {
+		drift := status.Drifts[0]
+		assert.Equal(t, "group", drift.ObjectType)
+		assert.Equal(t, groupName, drift.ObjectName)
+		assert.Equal(t, dataprovider.ReconcilerDriftModified, drift.Reason)
+		assert.Equal(t, []string{"/description", "/user_settings/max_sessions"}, drift.Fields)
+		assert.True(t, drift.Applied)
+		assert.Empty(t, drift.Error)
 	}
-	folder, resp, err := httpdtest.AddFolder(folder, http.StatusCreated)
-	assert.NoError(t, err, string(resp))
-	_, err = httpdtest.StartFolderQuotaScan(folder, http.StatusForbidden)
+	group, _, err = httpdtest.GetGroupByName(groupName, http.StatusOK)
 	assert.NoError(t, err)
-	_, err = httpdtest.UpdateFolderQuotaUsage(folder, "", http.StatusForbidden)
+	assert.Equal(t, "managed by reconciler", group.Description)
+	assert.Equal(t, 2, group.UserSettings.MaxSessions)
+	assert.Equal(t, int64(100), group.UserSettings.Filters.MaxUploadFileSize)
+	status = runReconciler()
+	assert.Len(t, status.Drifts, 0)
+
+	err = os.RemoveAll(sourceDir)
+	assert.NoError(t, err)
+	status = runReconciler()
+	assert.NotEmpty(t, status.LastError)
+
+	_, err = httpdtest.RemoveGroup(group, http.StatusOK)
 	assert.NoError(t, err)
 	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
 	assert.NoError(t, err)
@@ -6847,7 +9067,7 @@ func TestQuotaTrackingDisabled(t *testing.T) {
 	assert.NoError(t, err)
 	err = config.LoadConfig(configDir, "")
 	assert.NoError(t, err)
-	providerConf = config.GetProviderConf()
+	providerConf := config.GetProviderConf()
 	providerConf.BackupsPath = backupsPath
 	err = dataprovider.Initialize(providerConf, configDir, true)
 	assert.NoError(t, err)
@@ -10012,6 +12232,31 @@ func TestSearchEvents(t *testing.T) {
 	}
 	exportFunc()
 
+	// sparse fieldsets and cursor
+	req, err = http.NewRequest(http.MethodGet, fsEventsPath+"?limit=1&order=ASC&fields=id,action", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	events = nil
+	err = json.Unmarshal(rr.Body.Bytes(), &events)
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Len(t, events[0], 2)
+	}
+	cursor := rr.Header().Get("X-Next-Cursor")
+	assert.NotEmpty(t, cursor)
+	req, err = http.NewRequest(http.MethodGet, fsEventsPath+"?limit=1&order=ASC&cursor="+url.QueryEscape(cursor), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, err = http.NewRequest(http.MethodGet, fsEventsPath+"?limit=1&cursor="+url.QueryEscape(cursor), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
 	req, err = http.NewRequest(http.MethodGet, fsEventsPath+"?limit=e", nil)
 	assert.NoError(t, err)
 	setBearerForReq(req, token)
@@ -11712,6 +13957,245 @@ func TestGetUsersMock(t *testing.T) {
 	checkResponseCode(t, http.StatusOK, rr)
 }
 
+func TestWebhookDeliveriesMock(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	// webhooks are disabled in the test configuration
+	req, err := http.NewRequest(http.MethodGet, webhookDeliveriesPath+"?status=3&order=DESC", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	req, err = http.NewRequest(http.MethodGet, webhookDeliveriesPath+"?status=4", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodGet, webhookDeliveriesPath+"?status=a", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodGet, webhookDeliveriesPath+"?order=invalid", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodGet, path.Join(webhookDeliveriesPath, "id"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	req, err = http.NewRequest(http.MethodPost, path.Join(webhookDeliveriesPath, "id", "retry"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	// admins with a role cannot access the deliveries
+	role, _, err := httpdtest.AddRole(getTestRole(), http.StatusCreated)
+	assert.NoError(t, err)
+	a := getTestAdmin()
+	a.Username = altAdminUsername
+	a.Password = altAdminPassword
+	a.Role = role.Name
+	a.Permissions = []string{dataprovider.PermAdminViewEvents}
+	admin, _, err := httpdtest.AddAdmin(a, http.StatusCreated)
+	assert.NoError(t, err)
+	altToken, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodGet, webhookDeliveriesPath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, altToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	assert.Contains(t, rr.Body.String(), "role")
+	webToken, err := getJWTWebTokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodGet, webWebhooksPath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveRole(role, http.StatusOK)
+	assert.NoError(t, err)
+
+	webToken, err = getJWTWebTokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodGet, webWebhooksPath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, err = http.NewRequest(http.MethodGet, webWebhooksPath+jsonAPISuffix, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+}
+
+func TestEventsStreamMock(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, eventsStreamPath+"?types=login,invalid", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, eventsStreamPath+"?types=login&usernames=stream_user", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = httptest.NewRecorder()
+	done := make(chan bool)
+	go func() {
+		testServer.Config.Handler.ServeHTTP(rr, req)
+		close(done)
+	}()
+	// wait for the subscription
+	time.Sleep(200 * time.Millisecond)
+	dataprovider.ExecutePostLoginHook(&dataprovider.User{BaseUser: sdk.BaseUser{Username: "stream_user"}},
+		dataprovider.LoginMethodPassword, "127.0.0.1", common.ProtocolFTP, errors.New("invalid credentials"))
+	dataprovider.ExecutePostLoginHook(&dataprovider.User{BaseUser: sdk.BaseUser{Username: "other_user"}},
+		dataprovider.LoginMethodPassword, "127.0.0.1", common.ProtocolFTP, nil)
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	<-done
+
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Equal(t, "text/event-stream", rr.Header().Get("Content-Type"))
+	body := rr.Body.String()
+	assert.Equal(t, 1, strings.Count(body, "event: login\n"), body)
+	assert.Contains(t, body, `"username":"stream_user"`)
+	assert.Contains(t, body, `"status":2`)
+	assert.NotContains(t, body, "other_user")
+}
+
+func TestCursorPaginationMock(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	var usernames []string
+	for i := 0; i < 3; i++ {
+		u := getTestUser()
+		u.Username = fmt.Sprintf("cursor_user_%d", i)
+		_, _, err = httpdtest.AddUser(u, http.StatusCreated)
+		assert.NoError(t, err)
+		usernames = append(usernames, u.Username)
+	}
+	folderNames := []string{"cursor_folder_0", "cursor_folder_1"}
+	for _, name := range folderNames {
+		_, _, err = httpdtest.AddFolder(vfs.BaseVirtualFolder{
+			Name:       name,
+			MappedPath: filepath.Join(os.TempDir(), name),
+		}, http.StatusCreated)
+		assert.NoError(t, err)
+	}
+	// iterate over all the pages and check that each item is returned only once and in the expected order
+	listAll := func(basePath, params, key string) []string {
+		var result []string
+		cursor := ""
+		for i := 0; i < 1000; i++ {
+			reqPath := basePath + "?limit=2&" + params
+			if cursor != "" {
+				reqPath += "&cursor=" + url.QueryEscape(cursor)
+			}
+			req, err := http.NewRequest(http.MethodGet, reqPath, nil)
+			assert.NoError(t, err)
+			setBearerForReq(req, token)
+			rr := executeRequest(req)
+			checkResponseCode(t, http.StatusOK, rr)
+			var items []map[string]any
+			err = json.Unmarshal(rr.Body.Bytes(), &items)
+			assert.NoError(t, err)
+			for _, item := range items {
+				assert.Len(t, item, 1)
+				result = append(result, item[key].(string))
+			}
+			cursor = rr.Header().Get("X-Next-Cursor")
+			if cursor == "" {
+				break
+			}
+		}
+		return result
+	}
+	names := listAll(userPath, "order=DESC&fields=username", "username")
+	assert.Equal(t, len(names), len(util.RemoveDuplicates(names, false)))
+	assert.True(t, sort.SliceIsSorted(names, func(i, j int) bool { return names[i] > names[j] }))
+	for _, username := range usernames {
+		assert.Contains(t, names, username)
+	}
+	names = listAll(userPath, "sort=created_at&fields=username", "username")
+	assert.Equal(t, len(names), len(util.RemoveDuplicates(names, false)))
+	idx := -1
+	for i, name := range names {
+		if name == usernames[0] {
+			idx = i
+			break
+		}
+	}
+	if assert.GreaterOrEqual(t, idx, 0) && assert.Less(t, idx+2, len(names)) {
+		assert.Equal(t, usernames, names[idx:idx+3])
+	}
+	names = listAll(folderPath, "sort=used_quota_size&fields=name", "name")
+	assert.Equal(t, len(names), len(util.RemoveDuplicates(names, false)))
+	for _, name := range folderNames {
+		assert.Contains(t, names, name)
+	}
+	// the cursor must be used with the same sort field and order
+	req, err := http.NewRequest(http.MethodGet, userPath+"?limit=1", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	cursor := rr.Header().Get("X-Next-Cursor")
+	assert.NotEmpty(t, cursor)
+	req, err = http.NewRequest(http.MethodGet, userPath+"?limit=1&order=DESC&cursor="+url.QueryEscape(cursor), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodGet, userPath+"?limit=1&sort=last_login&cursor="+url.QueryEscape(cursor), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodGet, folderPath+"?cursor=invalid%20cursor", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodGet, userPath+"?sort=name", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	req, err = http.NewRequest(http.MethodGet, folderPath+"?sort=username", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	// no cursor is returned using the offset
+	req, err = http.NewRequest(http.MethodGet, userPath+"?limit=1&offset=1", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+
+	for _, username := range usernames {
+		_, err = httpdtest.RemoveUser(dataprovider.User{BaseUser: sdk.BaseUser{Username: username}}, http.StatusOK)
+		assert.NoError(t, err)
+	}
+	for _, name := range folderNames {
+		_, err = httpdtest.RemoveFolder(vfs.BaseVirtualFolder{Name: name}, http.StatusOK)
+		assert.NoError(t, err)
+	}
+}
+
 func TestDeleteUserInvalidParamsMock(t *testing.T) {
 	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
@@ -12017,22 +14501,192 @@ func TestGetVersionMock(t *testing.T) {
 	checkResponseCode(t, http.StatusUnauthorized, rr)
 }
 
-func TestGetConnectionsMock(t *testing.T) {
-	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+func TestGetConnectionsMock(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, activeConnectionsPath, nil)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+}
+
+func TestGetStatusMock(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, serverStatusPath, nil)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+}
+
+func TestDashboardMock(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	req, _ := http.NewRequest(http.MethodGet, dashboardPath+"/stats", nil)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var stats map[string]any
+	err = json.Unmarshal(rr.Body.Bytes(), &stats)
+	assert.NoError(t, err)
+	assert.Contains(t, stats, "connections")
+	assert.Contains(t, stats, "protocols")
+	assert.Contains(t, stats, "top_users")
+	assert.Contains(t, stats, "defender")
+
+	req, _ = http.NewRequest(http.MethodGet, dashboardPath+"/quota", nil)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	stats = make(map[string]any)
+	err = json.Unmarshal(rr.Body.Bytes(), &stats)
+	assert.NoError(t, err)
+	assert.Contains(t, stats, "users")
+	assert.Contains(t, stats, "used_quota_size")
+	assert.Contains(t, stats, "top_users")
+
+	admin := getTestAdmin()
+	admin.Username = altAdminUsername
+	admin.Password = altAdminPassword
+	admin.Permissions = []string{dataprovider.PermAdminViewServerStatus}
+	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
+	assert.NoError(t, err)
+	altToken, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodGet, dashboardPath+"/stats", nil)
+	setBearerForReq(req, altToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodGet, dashboardPath+"/quota", nil)
+	setBearerForReq(req, altToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	webToken, err := getJWTWebTokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodGet, webDashboardPath, nil)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodGet, webDashboardPath+"/stats"+jsonAPISuffix, nil)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodGet, webDashboardPath+"/quota"+jsonAPISuffix, nil)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
 	assert.NoError(t, err)
-	req, _ := http.NewRequest(http.MethodGet, activeConnectionsPath, nil)
-	setBearerForReq(req, token)
-	rr := executeRequest(req)
-	checkResponseCode(t, http.StatusOK, rr)
 }
 
-func TestGetStatusMock(t *testing.T) {
+func TestGraphQLMock(t *testing.T) {
+	u := getTestUser()
+	u.QuotaSize = 1024
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	folderName := util.GenerateUniqueID()
+	folder, _, err := httpdtest.AddFolder(vfs.BaseVirtualFolder{
+		Name:       folderName,
+		MappedPath: filepath.Join(os.TempDir(), folderName),
+	}, http.StatusCreated)
+	assert.NoError(t, err)
 	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
-	req, _ := http.NewRequest(http.MethodGet, serverStatusPath, nil)
-	setBearerForReq(req, token)
-	rr := executeRequest(req)
-	checkResponseCode(t, http.StatusOK, rr)
+
+	doQuery := func(token string, body map[string]any, expectedStatus int) map[string]any {
+		data, err := json.Marshal(body)
+		assert.NoError(t, err)
+		req, err := http.NewRequest(http.MethodPost, graphQLPath, bytes.NewBuffer(data))
+		assert.NoError(t, err)
+		setBearerForReq(req, token)
+		rr := executeRequest(req)
+		checkResponseCode(t, expectedStatus, rr)
+		var resp map[string]any
+		err = json.Unmarshal(rr.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	query := `query Dashboard($username: String!) {
+		u: user(username: $username) { __typename username quota_size used_quota_size last_login filters { web_client } }
+		missing: user(username: "missing") { username }
+		folders(limit: 500, order: DESC) { name mapped_path }
+	}`
+	resp := doQuery(token, map[string]any{
+		"query":     query,
+		"variables": map[string]any{"username": defaultUsername},
+	}, http.StatusOK)
+	assert.NotContains(t, resp, "errors")
+	data := resp["data"].(map[string]any)
+	userData := data["u"].(map[string]any)
+	assert.Equal(t, "User", userData["__typename"])
+	assert.Equal(t, defaultUsername, userData["username"])
+	assert.Equal(t, float64(1024), userData["quota_size"])
+	assert.Len(t, userData, 6)
+	assert.Contains(t, userData, "filters")
+	assert.Nil(t, data["missing"])
+	found := false
+	for _, f := range data["folders"].([]any) {
+		if f.(map[string]any)["name"] == folderName {
+			found = true
+			assert.Len(t, f, 2)
+		}
+	}
+	assert.True(t, found)
+	// nested objects require a selection of subfields
+	resp = doQuery(token, map[string]any{
+		"query": `{ users { username filters } }`,
+	}, http.StatusBadRequest)
+	assert.Contains(t, fmt.Sprint(resp["errors"]), "must have a sub selection")
+	// secrets are not exposed
+	resp = doQuery(token, map[string]any{
+		"query": `{ users { username password } }`,
+	}, http.StatusBadRequest)
+	assert.Contains(t, fmt.Sprint(resp["errors"]), `Cannot query field "password"`)
+	resp = doQuery(token, map[string]any{
+		"query": `{ folders { filesystem { s3config { access_secret { key } } } } }`,
+	}, http.StatusBadRequest)
+	assert.Contains(t, fmt.Sprint(resp["errors"]), `Cannot query field "access_secret"`)
+	// invalid documents
+	resp = doQuery(token, map[string]any{
+		"query": `mutation { users { username } }`,
+	}, http.StatusBadRequest)
+	assert.Contains(t, fmt.Sprint(resp["errors"]), "not configured for mutations")
+	resp = doQuery(token, map[string]any{
+		"query": `{ users { username }`,
+	}, http.StatusBadRequest)
+	assert.Contains(t, fmt.Sprint(resp["errors"]), "Syntax Error")
+	resp = doQuery(token, map[string]any{
+		"query": `{ users(limit: -1) { username } }`,
+	}, http.StatusOK)
+	assert.Contains(t, fmt.Sprint(resp["errors"]), "invalid limit")
+	// admins without the required permissions
+	admin := getTestAdmin()
+	admin.Username = altAdminUsername
+	admin.Password = altAdminPassword
+	admin.Permissions = []string{dataprovider.PermAdminViewFolders}
+	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
+	assert.NoError(t, err)
+	altToken, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	resp = doQuery(altToken, map[string]any{
+		"query": `{ users { username } folders { name } }`,
+	}, http.StatusOK)
+	assert.Contains(t, fmt.Sprint(resp["errors"]), "permission denied")
+	data = resp["data"].(map[string]any)
+	assert.Nil(t, data["users"])
+	assert.NotNil(t, data["folders"])
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
+	assert.NoError(t, err)
 }
 
 func TestDeleteActiveConnectionMock(t *testing.T) {
@@ -12155,6 +14809,123 @@ func TestLogout(t *testing.T) {
 	assert.Contains(t, rr.Body.String(), "Your token is no longer valid")
 }
 
+func TestAuthSessions(t *testing.T) {
+	a := getTestAdmin()
+	a.Username = altAdminUsername
+	a.Password = altAdminPassword
+	admin, _, err := httpdtest.AddAdmin(a, http.StatusCreated)
+	assert.NoError(t, err)
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+
+	getSessions := func(token, sessionsPath string) []map[string]any {
+		req, _ := http.NewRequest(http.MethodGet, sessionsPath, nil)
+		setBearerForReq(req, token)
+		rr := executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+		var sessions []map[string]any
+		err := json.Unmarshal(rr.Body.Bytes(), &sessions)
+		assert.NoError(t, err)
+		return sessions
+	}
+	// sessions created by previous tests for the same accounts could still be valid
+	adminToken, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	for _, sessionsPath := range []string{path.Join(adminPath, altAdminUsername, "sessions"),
+		path.Join(userPath, user.Username, "sessions")} {
+		req, _ := http.NewRequest(http.MethodDelete, sessionsPath, nil)
+		setBearerForReq(req, adminToken)
+		rr := executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+	}
+
+	token1, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	sessions := getSessions(token1, adminSessionsPath)
+	require.Len(t, sessions, 1)
+	sessionID := sessions[0]["id"].(string)
+	assert.Equal(t, altAdminUsername, sessions[0]["username"])
+	assert.Equal(t, true, sessions[0]["is_admin"])
+	assert.Equal(t, "api", sessions[0]["type"])
+	token2, err := getJWTAPITokenFromTestServer(altAdminUsername, altAdminPassword)
+	assert.NoError(t, err)
+	assert.Len(t, getSessions(token2, adminSessionsPath), 2)
+	// revoke the session for the first token
+	req, _ := http.NewRequest(http.MethodDelete, path.Join(adminSessionsPath, sessionID), nil)
+	setBearerForReq(req, token2)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodDelete, path.Join(adminSessionsPath, sessionID), nil)
+	setBearerForReq(req, token2)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+
+	req, _ = http.NewRequest(http.MethodGet, adminSessionsPath, nil)
+	setBearerForReq(req, token1)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, rr)
+	sessions = getSessions(token2, adminSessionsPath)
+	require.Len(t, sessions, 1)
+	// sessions for other admins cannot be revoked using the self-service API
+	req, _ = http.NewRequest(http.MethodDelete, path.Join(adminSessionsPath, sessions[0]["id"].(string)), nil)
+	setBearerForReq(req, adminToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	// revoke all the sessions for the alternate admin
+	req, _ = http.NewRequest(http.MethodGet, path.Join(adminPath, altAdminUsername, "sessions"), nil)
+	setBearerForReq(req, adminToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodDelete, path.Join(adminPath, altAdminUsername, "sessions"), nil)
+	setBearerForReq(req, adminToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodGet, adminSessionsPath, nil)
+	setBearerForReq(req, token2)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, rr)
+	req, _ = http.NewRequest(http.MethodGet, path.Join(adminPath, "missing", "sessions"), nil)
+	setBearerForReq(req, adminToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	// user sessions
+	userToken, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+	sessions = getSessions(userToken, userSessionsPath)
+	if assert.Len(t, sessions, 1) {
+		assert.Equal(t, user.Username, sessions[0]["username"])
+		assert.Equal(t, false, sessions[0]["is_admin"])
+	}
+	sessions = getSessions(adminToken, path.Join(userPath, user.Username, "sessions"))
+	assert.Len(t, sessions, 1)
+	req, _ = http.NewRequest(http.MethodDelete, path.Join(userPath, user.Username, "sessions", sessions[0]["id"].(string)), nil)
+	setBearerForReq(req, adminToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodGet, userDirsPath, nil)
+	setBearerForReq(req, userToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, rr)
+	// revoke all the sessions using the self-service API
+	userToken, err = getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+	req, _ = http.NewRequest(http.MethodDelete, userSessionsPath, nil)
+	setBearerForReq(req, userToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	req, _ = http.NewRequest(http.MethodGet, userDirsPath, nil)
+	setBearerForReq(req, userToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusUnauthorized, rr)
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestDefenderAPIInvalidIDMock(t *testing.T) {
 	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
@@ -13829,18 +16600,215 @@ func TestWebClientShareCredentials(t *testing.T) {
 	req.RemoteAddr = defaultRemoteAddr
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusFound, rr)
-	assert.Equal(t, nextURI, rr.Header().Get("Location"))
-	// try to login to a missing share
-	loginURI = path.Join(webClientPubSharesPath, "missing", "login")
-	req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+	checkResponseCode(t, http.StatusFound, rr)
+	assert.Equal(t, nextURI, rr.Header().Get("Location"))
+	// try to login to a missing share
+	loginURI = path.Join(webClientPubSharesPath, "missing", "login")
+	req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidCredentials)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestWebClientShareEmailVerification(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+
+	share := dataprovider.Share{
+		Name:  "test share email verification",
+		Scope: dataprovider.ShareScopeRead,
+		Paths: []string{"/"},
+		EmailVerification: dataprovider.ShareEmailVerification{
+			AllowedEmails: []string{"Share@Example.com", "@example.net"},
+			MaxAttempts:   2,
+		},
+	}
+	asJSON, err := json.Marshal(share)
+	assert.NoError(t, err)
+	// SMTP is not configured
+	req, err := http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	smtpCfg := smtp.Config{
+		Host:          "127.0.0.1",
+		Port:          3525,
+		From:          "notification@example.com",
+		TemplatesPath: "templates",
+	}
+	err = smtpCfg.Initialize(configDir, true)
+	require.NoError(t, err)
+
+	share.EmailVerification.AllowedEmails = []string{"invalid email"}
+	asJSON, err = json.Marshal(share)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	share.EmailVerification.AllowedEmails = []string{"Share@Example.com", "@example.net"}
+	asJSON, err = json.Marshal(share)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	shareID := rr.Header().Get("X-Object-ID")
+	assert.NotEmpty(t, shareID)
+
+	s, err := dataprovider.ShareExists(shareID, defaultUsername)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"share@example.com", "@example.net"}, s.EmailVerification.AllowedEmails)
+	assert.Equal(t, 2, s.EmailVerification.MaxAttempts)
+	// the REST API cannot be used for shares with email verification
+	req, err = http.NewRequest(http.MethodGet, path.Join(sharesPath, shareID), nil)
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	uri := path.Join(webClientPubSharesPath, shareID, "browse")
+	req, err = http.NewRequest(http.MethodGet, uri, nil)
+	assert.NoError(t, err)
+	req.RequestURI = uri
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, rr)
+
+	getVerificationID := func(body string) string {
+		re := regexp.MustCompile(`name="verification_id" value="([^"]+)"`)
+		matches := re.FindStringSubmatch(body)
+		if len(matches) != 2 {
+			return ""
+		}
+		return matches[1]
+	}
+	csrfToken, err := getCSRFToken(httpBaseURL + webClientLoginPath)
+	assert.NoError(t, err)
+	loginURI := path.Join(webClientPubSharesPath, shareID, "login")
+	// an email not allowed gets a verification page but no code
+	lastResetCode = ""
+	form := make(url.Values)
+	form.Set(csrfFormToken, csrfToken)
+	form.Set("email", "other@example.com")
+	req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Empty(t, lastResetCode)
+	verificationID := getVerificationID(rr.Body.String())
+	assert.NotEmpty(t, verificationID)
+	// an allowed email gets the code
+	form.Set("email", "user@example.net")
+	req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Len(t, lastResetCode, 6)
+	verificationID = getVerificationID(rr.Body.String())
+	assert.NotEmpty(t, verificationID)
+	// invalid code, one attempt left
+	form = make(url.Values)
+	form.Set(csrfFormToken, csrfToken)
+	form.Set("verification_id", verificationID)
+	form.Set("verification_code", "invalid")
+	req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorShareVerificationCode)
+	assert.Equal(t, verificationID, getVerificationID(rr.Body.String()))
+	// the valid code works
+	form.Set("verification_code", lastResetCode)
+	req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nShareLoginOK)
+	cookie := strings.TrimPrefix(rr.Header().Get("Set-Cookie"), "jwt=")
+	assert.NotEmpty(t, cookie)
+	req, err = http.NewRequest(http.MethodGet, uri, nil)
+	assert.NoError(t, err)
+	req.RequestURI = uri
+	setJWTCookieForReq(req, cookie)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	// the code is one-time
+	req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorShareVerificationExpired)
+	// too many attempts
+	form = make(url.Values)
+	form.Set(csrfFormToken, csrfToken)
+	form.Set("email", "share@example.com")
+	req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	verificationID = getVerificationID(rr.Body.String())
+	assert.NotEmpty(t, verificationID)
+	form = make(url.Values)
+	form.Set(csrfFormToken, csrfToken)
+	form.Set("verification_id", verificationID)
+	form.Set("verification_code", "000000a")
+	for i := 0; i < 2; i++ {
+		req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+		assert.NoError(t, err)
+		req.RemoteAddr = defaultRemoteAddr
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr = executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+	}
+	assert.Contains(t, rr.Body.String(), util.I18nErrorShareVerificationAttempts)
+	form.Set("verification_code", lastResetCode)
+	req, err = http.NewRequest(http.MethodPost, loginURI, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorShareVerificationExpired)
+	// removing the email from the allowed list invalidates the existing tokens
+	s.EmailVerification.AllowedEmails = []string{"share@example.com"}
+	err = dataprovider.UpdateShare(&s, user.Username, "", "")
 	assert.NoError(t, err)
-	req.RemoteAddr = defaultRemoteAddr
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req, err = http.NewRequest(http.MethodGet, uri, nil)
+	assert.NoError(t, err)
+	req.RequestURI = uri
+	setJWTCookieForReq(req, cookie)
 	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusOK, rr)
-	assert.Contains(t, rr.Body.String(), util.I18nErrorInvalidCredentials)
+	checkResponseCode(t, http.StatusFound, rr)
 
+	smtpCfg = smtp.Config{}
+	err = smtpCfg.Initialize(configDir, true)
+	require.NoError(t, err)
 	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
 	err = os.RemoveAll(user.GetHomeDir())
@@ -14102,6 +17070,204 @@ func TestShareUploadSingle(t *testing.T) {
 	checkResponseCode(t, http.StatusNotFound, rr)
 }
 
+func TestShareFileRequest(t *testing.T) {
+	u := getTestUser()
+	u.Email = ""
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+
+	share := dataprovider.Share{
+		Name:  "test file request",
+		Scope: dataprovider.ShareScopeRead,
+		Paths: []string{"/"},
+		FileRequest: dataprovider.ShareFileRequest{
+			RequireUploaderInfo: true,
+		},
+	}
+	asJSON, err := json.Marshal(share)
+	assert.NoError(t, err)
+	// file request options are not supported for the read scope
+	req, err := http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	share.Scope = dataprovider.ShareScopeWrite
+	share.FileRequest.UploaderDirs = true
+	share.FileRequest.NotifyOwner = true
+	asJSON, err = json.Marshal(share)
+	assert.NoError(t, err)
+	// SMTP is not configured
+	req, err = http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	smtpCfg := smtp.Config{
+		Host:          "127.0.0.1",
+		Port:          3525,
+		From:          "notification@example.com",
+		TemplatesPath: "templates",
+	}
+	err = smtpCfg.Initialize(configDir, true)
+	require.NoError(t, err)
+	// the user has no email
+	req, err = http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	user.Email = "user@example.com"
+	_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	objectID := rr.Header().Get("X-Object-ID")
+	assert.NotEmpty(t, objectID)
+
+	s, err := dataprovider.ShareExists(objectID, user.Username)
+	assert.NoError(t, err)
+	assert.True(t, s.FileRequest.RequireUploaderInfo)
+	assert.True(t, s.FileRequest.UploaderDirs)
+	assert.True(t, s.FileRequest.NotifyOwner)
+
+	content := []byte("file request content")
+	req, err = http.NewRequest(http.MethodPost, path.Join(sharesPath, objectID, "file.txt"), bytes.NewBuffer(content))
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "uploader name")
+
+	uploadPath := path.Join(sharesPath, objectID, "file.txt") + "?uploader_name=John%20Doe&uploader_email=invalid"
+	req, err = http.NewRequest(http.MethodPost, uploadPath, bytes.NewBuffer(content))
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "uploader email")
+
+	uploadPath = path.Join(sharesPath, objectID, "file.txt") + "?uploader_name=John%20Doe&uploader_email=John@Example.com"
+	req, err = http.NewRequest(http.MethodPost, uploadPath, bytes.NewBuffer(content))
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "John Doe (john@example.com)", "file.txt"))
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("filenames", "file1.txt")
+	assert.NoError(t, err)
+	_, err = part.Write(content)
+	assert.NoError(t, err)
+	err = writer.Close()
+	assert.NoError(t, err)
+	uploadPath = path.Join(webClientPubSharesPath, objectID) + "?uploader_name=Jane&uploader_email=jane@example.net"
+	req, err = http.NewRequest(http.MethodPost, uploadPath, bytes.NewReader(body.Bytes()))
+	assert.NoError(t, err)
+	req.Header.Add("Content-Type", writer.FormDataContentType())
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "Jane (jane@example.net)", "file1.txt"))
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID, "upload"), nil)
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), "uploader_email")
+
+	smtpCfg = smtp.Config{}
+	err = smtpCfg.Initialize(configDir, true)
+	require.NoError(t, err)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestShareDownloadsAnalyticsDisabled(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+	webToken, err := getJWTWebClientTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+
+	share := dataprovider.Share{
+		Name:  "test share analytics",
+		Scope: dataprovider.ShareScopeRead,
+		Paths: []string{"/"},
+	}
+	asJSON, err := json.Marshal(share)
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	objectID := rr.Header().Get("X-Object-ID")
+	assert.NotEmpty(t, objectID)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(userSharesPath, "unknown", "downloads"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(userSharesPath, objectID, "downloads"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(userSharesPath, objectID, "downloads")+"?csv_export=true", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(userSharesPath, objectID, "downloads")+"?from_id=a", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(userSharesPath, objectID, "downloads", "stats"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientSharePath, objectID, "downloads"), nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientSharePath, "unknown", "downloads"), nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	// downloads are not recorded but still work
+	req, err = http.NewRequest(http.MethodGet, path.Join(sharesPath, objectID), nil)
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestShareReadWrite(t *testing.T) {
 	u := getTestUser()
 	u.Filters.StartDirectory = path.Join("/start", "dir")
@@ -14885,23 +18051,120 @@ func TestBrowseShares(t *testing.T) {
 	assert.NoError(t, err)
 	req, err = http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
 	assert.NoError(t, err)
-	setBearerForReq(req, token)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	objectID = rr.Header().Get("X-Object-ID")
+	assert.NotEmpty(t, objectID)
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID, "browse?path=%2F"), nil)
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	// on upload we should be redirected
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID, "upload"), nil)
+	assert.NoError(t, err)
+	req.SetBasicAuth(defaultUsername, defaultPassword)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusFound, rr)
+	location := rr.Header().Get("Location")
+	assert.Equal(t, path.Join(webClientPubSharesPath, objectID, "browse"), location)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestBrowseViewOnlyShare(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+
+	shareDir := "view"
+	testFileSize := int64(1024)
+	err = createTestFile(filepath.Join(user.GetHomeDir(), shareDir, "image.png"), testFileSize)
+	assert.NoError(t, err)
+	err = createTestFile(filepath.Join(user.GetHomeDir(), shareDir, "image.svg"), testFileSize)
+	assert.NoError(t, err)
+	err = createTestFile(filepath.Join(user.GetHomeDir(), shareDir, "file.dat"), testFileSize)
+	assert.NoError(t, err)
+
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+
+	share := dataprovider.Share{
+		Name:  "test view only share",
+		Scope: dataprovider.ShareScopeView,
+		Paths: []string{shareDir, "/"},
+	}
+	asJSON, err := json.Marshal(share)
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	share.Paths = []string{shareDir}
+	asJSON, err = json.Marshal(share)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, userSharesPath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	objectID := rr.Header().Get("X-Object-ID")
+	assert.NotEmpty(t, objectID)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID, "browse?path=%2F"), nil)
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID, "dirs?path=%2F"), nil)
+	assert.NoError(t, err)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	contents := make([]map[string]any, 0)
+	err = json.Unmarshal(rr.Body.Bytes(), &contents)
+	assert.NoError(t, err)
+	assert.Len(t, contents, 3)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID, "browse?path=image.png"), nil)
+	assert.NoError(t, err)
+	req.Header.Set("Range", "bytes=0-99")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusPartialContent, rr)
+	assert.Empty(t, rr.Header().Get("Content-Disposition"))
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "bytes 0-99/1024", rr.Header().Get("Content-Range"))
+	assert.Len(t, rr.Body.Bytes(), 100)
+
+	for _, name := range []string{"image.svg", "file.dat"} {
+		req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID, "browse?path="+name), nil)
+		assert.NoError(t, err)
+		rr = executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+		assert.Contains(t, rr.Body.String(), util.I18nErrorSharePreviewOnly)
+	}
+	// the download features are not available
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID), nil)
+	assert.NoError(t, err)
 	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusCreated, rr)
-	objectID = rr.Header().Get("X-Object-ID")
-	assert.NotEmpty(t, objectID)
-	req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID, "browse?path=%2F"), nil)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	req, err = http.NewRequest(http.MethodGet, path.Join(sharesPath, objectID, "files?path=image.png"), nil)
 	assert.NoError(t, err)
 	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusOK, rr)
-	// on upload we should be redirected
-	req, err = http.NewRequest(http.MethodGet, path.Join(webClientPubSharesPath, objectID, "upload"), nil)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	form := make(url.Values)
+	form.Set("files", `["image.png"]`)
+	req, err = http.NewRequest(http.MethodPost, path.Join(webClientPubSharesPath, objectID, "partial?path=%2F"),
+		bytes.NewBuffer([]byte(form.Encode())))
 	assert.NoError(t, err)
-	req.SetBasicAuth(defaultUsername, defaultPassword)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusFound, rr)
-	location := rr.Header().Get("Location")
-	assert.Equal(t, path.Join(webClientPubSharesPath, objectID, "browse"), location)
+	checkResponseCode(t, http.StatusForbidden, rr)
 
 	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
@@ -15693,6 +18956,34 @@ func TestWebEditFile(t *testing.T) {
 	setJWTCookieForReq(req, webToken)
 	rr := executeRequest(req)
 	checkResponseCode(t, http.StatusOK, rr)
+	content, err := os.ReadFile(filepath.Join(user.GetHomeDir(), testFile1))
+	assert.NoError(t, err)
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+	assert.Contains(t, rr.Body.String(), checksum)
+	// save using the checksum of the loaded file, the first save must succeed the second
+	// one must fail since the file was modified in the meantime
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+	for _, status := range []int{http.StatusCreated, http.StatusConflict} {
+		req, err = http.NewRequest(http.MethodPost, userUploadFilePath+"?path="+testFile1,
+			bytes.NewBuffer([]byte("edited content")))
+		assert.NoError(t, err)
+		req.Header.Set("X-SFTPGO-CHECKSUM", checksum)
+		setBearerForReq(req, token)
+		rr = executeRequest(req)
+		checkResponseCode(t, status, rr)
+	}
+	req, err = http.NewRequest(http.MethodPost, userUploadFilePath+"?path=missing.txt",
+		bytes.NewBuffer([]byte("edited content")))
+	assert.NoError(t, err)
+	req.Header.Set("X-SFTPGO-CHECKSUM", checksum)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusConflict, rr)
+	content, err = os.ReadFile(filepath.Join(user.GetHomeDir(), testFile1))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("edited content"), content)
+	assert.NoFileExists(t, filepath.Join(user.GetHomeDir(), "missing.txt"))
 
 	req, err = http.NewRequest(http.MethodGet, webClientEditFilePath+"?path="+testFile2, nil)
 	assert.NoError(t, err)
@@ -15746,12 +19037,454 @@ func TestWebEditFile(t *testing.T) {
 	assert.NoError(t, err)
 	err = os.RemoveAll(user.GetHomeDir())
 	assert.NoError(t, err)
-
-	req, err = http.NewRequest(http.MethodGet, webClientEditFilePath+"?path="+testFile1, nil)
-	assert.NoError(t, err)
-	setJWTCookieForReq(req, webToken)
-	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusNotFound, rr)
+
+	req, err = http.NewRequest(http.MethodGet, webClientEditFilePath+"?path="+testFile1, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+}
+
+func TestSearchUserFiles(t *testing.T) {
+	u := getTestUser()
+	u.Permissions["/private"] = []string{dataprovider.PermUpload}
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	err = os.MkdirAll(filepath.Join(user.GetHomeDir(), "docs", "reports"), os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(user.GetHomeDir(), "docs", "report.txt"), []byte("Quarterly REPORT"), os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(user.GetHomeDir(), "docs", "report.bin"), []byte("quarterly\x00"), os.ModePerm)
+	assert.NoError(t, err)
+	err = createTestFile(filepath.Join(user.GetHomeDir(), "docs", "reports", "big_report.dat"), 65536)
+	assert.NoError(t, err)
+	err = createTestFile(filepath.Join(user.GetHomeDir(), "private", "report.txt"), 100)
+	assert.NoError(t, err)
+
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+
+	search := func(query string, expectedStatus int) []map[string]any {
+		req, err := http.NewRequest(http.MethodGet, userSearchPath+"?"+query, nil)
+		assert.NoError(t, err)
+		setBearerForReq(req, token)
+		rr := executeRequest(req)
+		checkResponseCode(t, expectedStatus, rr)
+		if expectedStatus != http.StatusOK {
+			return nil
+		}
+		var resp map[string]any
+		err = json.Unmarshal(rr.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		var results []map[string]any
+		for _, r := range resp["results"].([]any) {
+			results = append(results, r.(map[string]any))
+		}
+		return results
+	}
+	getPaths := func(results []map[string]any) []string {
+		var paths []string
+		for _, r := range results {
+			paths = append(paths, r["path"].(string))
+		}
+		return paths
+	}
+	// the private dir cannot be listed
+	results := search("name=report", http.StatusOK)
+	assert.ElementsMatch(t, []string{"/docs/report.txt", "/docs/report.bin", "/docs/reports",
+		"/docs/reports/big_report.dat"}, getPaths(results))
+	results = search("name=report&type=dir", http.StatusOK)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "dir", results[0]["type"])
+		assert.Equal(t, "reports", results[0]["name"])
+	}
+	results = search("name=*.TXT", http.StatusOK)
+	assert.Equal(t, []string{"/docs/report.txt"}, getPaths(results))
+	results = search("content=quarterly", http.StatusOK)
+	assert.Equal(t, []string{"/docs/report.txt"}, getPaths(results))
+	results = search("name=report&min_size=1000", http.StatusOK)
+	assert.Equal(t, []string{"/docs/reports/big_report.dat"}, getPaths(results))
+	results = search("name=report&max_size=1000&path=%2Fdocs%2Freports", http.StatusOK)
+	assert.Len(t, results, 0)
+	results = search("modified_after=1", http.StatusOK)
+	assert.Len(t, results, 6)
+	results = search("modified_before=1", http.StatusOK)
+	assert.Len(t, results, 0)
+
+	req, err := http.NewRequest(http.MethodGet, userSearchPath+"?name=r&limit=1", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), `"truncated":true`)
+
+	search("", http.StatusBadRequest)
+	search("name=[", http.StatusBadRequest)
+	search("name=a&type=invalid", http.StatusBadRequest)
+	search("name=a&min_size=invalid", http.StatusBadRequest)
+	search("name=a&limit=-1", http.StatusBadRequest)
+	search("name=a&path=%2Fmissing", http.StatusNotFound)
+	search("name=a&path=%2Fprivate", http.StatusForbidden)
+	// the WebClient requires the CSRF token
+	webToken, err := getJWTWebClientTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+	csrfToken, err := getCSRFToken(httpBaseURL + webClientLoginPath)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodGet, webClientSearchPath+"?name=report", nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	req.Header.Set("X-CSRF-TOKEN", csrfToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), "/docs/report.txt")
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestWebClientFileJobs(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+	err = os.MkdirAll(filepath.Join(user.GetHomeDir(), "src", "sub"), os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(user.GetHomeDir(), "src", "sub", "file"), []byte("contents"), os.ModePerm)
+	assert.NoError(t, err)
+	webToken, err := getJWTWebClientTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+	csrfToken, err := getCSRFToken(httpBaseURL + webClientLoginPath)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodPost, webClientFileJobsPath+"/copy?path=%2Fsrc&target=%2Fdst", nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+	req.Header.Set("X-CSRF-TOKEN", csrfToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+	var job map[string]any
+	err = json.Unmarshal(rr.Body.Bytes(), &job)
+	assert.NoError(t, err)
+	jobID := job["id"].(string)
+	assert.Equal(t, path.Join(webClientFileJobsPath, jobID), rr.Header().Get("Location"))
+	assert.Eventually(t, func() bool {
+		req, err := http.NewRequest(http.MethodGet, path.Join(webClientFileJobsPath, jobID), nil)
+		assert.NoError(t, err)
+		setJWTCookieForReq(req, webToken)
+		req.Header.Set("X-CSRF-TOKEN", csrfToken)
+		rr := executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+		err = json.Unmarshal(rr.Body.Bytes(), &job)
+		assert.NoError(t, err)
+		return job["status"] == "completed"
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "dst", "sub", "file"))
+
+	req, err = http.NewRequest(http.MethodPost, webClientFileJobsPath+"/move?path=%2Fsrc&target=%2F", nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	req.Header.Set("X-CSRF-TOKEN", csrfToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	req, err = http.NewRequest(http.MethodGet, webClientFileJobsPath, nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	req.Header.Set("X-CSRF-TOKEN", csrfToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var jobs []map[string]any
+	err = json.Unmarshal(rr.Body.Bytes(), &jobs)
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+
+	req, err = http.NewRequest(http.MethodDelete, path.Join(webClientFileJobsPath, jobID), nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	req.Header.Set("X-CSRF-TOKEN", csrfToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	req, err = http.NewRequest(http.MethodGet, path.Join(webClientFileJobsPath, jobID), nil)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, webToken)
+	req.Header.Set("X-CSRF-TOKEN", csrfToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestUserAPIFileJobs(t *testing.T) {
+	u := getTestUser()
+	u.Filters.WebClient = []string{sdk.WebClientWriteDisabled}
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	err = os.MkdirAll(filepath.Join(user.GetHomeDir(), "dir"), os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(user.GetHomeDir(), "dir", "file"), []byte("contents"), os.ModePerm)
+	assert.NoError(t, err)
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+
+	waitJob := func(jobID string) map[string]any {
+		var job map[string]any
+		assert.Eventually(t, func() bool {
+			req, err := http.NewRequest(http.MethodGet, path.Join(userFileJobsPath, jobID), nil)
+			assert.NoError(t, err)
+			setBearerForReq(req, token)
+			rr := executeRequest(req)
+			checkResponseCode(t, http.StatusOK, rr)
+			err = json.Unmarshal(rr.Body.Bytes(), &job)
+			assert.NoError(t, err)
+			return job["status"] != "running"
+		}, 5*time.Second, 100*time.Millisecond)
+		return job
+	}
+	startJob := func(body string, expectedStatusCode int) string {
+		req, err := http.NewRequest(http.MethodPost, userFileJobsPath, bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		setBearerForReq(req, token)
+		rr := executeRequest(req)
+		checkResponseCode(t, expectedStatusCode, rr)
+		if expectedStatusCode != http.StatusCreated {
+			return ""
+		}
+		var job map[string]any
+		err = json.Unmarshal(rr.Body.Bytes(), &job)
+		assert.NoError(t, err)
+		jobID := job["id"].(string)
+		assert.Equal(t, path.Join(userFileJobsPath, jobID), rr.Header().Get("Location"))
+		return jobID
+	}
+	// checksums are allowed if the write access is disabled
+	jobID := startJob(`{"operation":"checksum","algorithm":"md5","items":[{"source":"/dir/file"}]}`, http.StatusCreated)
+	job := waitJob(jobID)
+	assert.Equal(t, "completed", job["status"])
+	assert.Equal(t, "md5", job["algorithm"])
+	results, ok := job["results"].([]any)
+	require.True(t, ok)
+	require.Len(t, results, 1)
+	assert.Equal(t, "98bf7d8c15784f0a3d63204441e1e2aa", results[0].(map[string]any)["checksum"])
+	startJob(`{"operation":"compress","target":"/a.zip","items":[{"source":"/dir"}]}`, http.StatusForbidden)
+	startJob(`{"operation":"checksum","algorithm":"crc","items":[{"source":"/dir/file"}]}`, http.StatusBadRequest)
+
+	user.Filters.WebClient = nil
+	_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	token, err = getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+
+	startJob(`{"operation":"compress","target":"/a.tar","items":[{"source":"/dir"}]}`, http.StatusBadRequest)
+	startJob(`{"operation":"compress","target":"/dir/a.zip","items":[{"source":"/dir"}]}`, http.StatusBadRequest)
+	jobID = startJob(`{"operation":"compress","target":"/a.zip","items":[{"source":"/dir"}]}`, http.StatusCreated)
+	job = waitJob(jobID)
+	assert.Equal(t, "completed", job["status"])
+	assert.Equal(t, "/a.zip", job["target"])
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "a.zip"))
+
+	jobID = startJob(`{"operation":"extract","items":[{"source":"/a.zip","target":"/extracted"}]}`, http.StatusCreated)
+	job = waitJob(jobID)
+	assert.Equal(t, "completed", job["status"])
+	assert.Equal(t, float64(1), job["files"])
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "extracted", "dir", "file"))
+
+	jobID = startJob(`{"operation":"move","items":[{"source":"/extracted","target":"/moved"}]}`, http.StatusCreated)
+	job = waitJob(jobID)
+	assert.Equal(t, "completed", job["status"])
+	assert.NoDirExists(t, filepath.Join(user.GetHomeDir(), "extracted"))
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "moved", "dir", "file"))
+
+	req, err := http.NewRequest(http.MethodGet, userFileJobsPath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var jobs []map[string]any
+	err = json.Unmarshal(rr.Body.Bytes(), &jobs)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, len(jobs), 4)
+
+	req, err = http.NewRequest(http.MethodDelete, path.Join(userFileJobsPath, jobID), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestUserS3Credentials(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+	token, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, userS3CredentialsPath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	_, _, err = dataprovider.GetUserForS3Auth(defaultUsername, "127.0.0.1", common.ProtocolS3)
+	assert.Error(t, err)
+
+	req, err = http.NewRequest(http.MethodPost, userS3CredentialsPath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	var credentials map[string]any
+	err = json.Unmarshal(rr.Body.Bytes(), &credentials)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultUsername, credentials["access_key_id"])
+	secret, ok := credentials["secret_access_key"].(string)
+	assert.True(t, ok)
+	assert.Len(t, secret, 40)
+	assert.Greater(t, credentials["created_at"], float64(0))
+
+	_, s3Secret, err := dataprovider.GetUserForS3Auth(defaultUsername, "127.0.0.1", common.ProtocolS3)
+	assert.NoError(t, err)
+	assert.Equal(t, secret, s3Secret)
+
+	req, err = http.NewRequest(http.MethodGet, userS3CredentialsPath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	credentials = make(map[string]any)
+	err = json.Unmarshal(rr.Body.Bytes(), &credentials)
+	assert.NoError(t, err)
+	assert.Equal(t, defaultUsername, credentials["access_key_id"])
+	assert.NotContains(t, credentials, "secret_access_key")
+	// only the encrypted secret is returned to admins and it is preserved on update
+	user, _, err = httpdtest.GetUserByUsername(defaultUsername, http.StatusOK)
+	assert.NoError(t, err)
+	if assert.NotNil(t, user.Filters.S3Credentials) {
+		assert.Equal(t, sdkkms.SecretStatusSecretBox, user.Filters.S3Credentials.Secret.GetStatus())
+		assert.NotEmpty(t, user.Filters.S3Credentials.Secret.GetPayload())
+		assert.NotEqual(t, secret, user.Filters.S3Credentials.Secret.GetPayload())
+		assert.Empty(t, user.Filters.S3Credentials.Secret.GetKey())
+	}
+	user.Filters.S3Credentials = nil
+	user.Filters.DeniedProtocols = []string{common.ProtocolS3}
+	user, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	_, s3Secret, err = dataprovider.GetUserForS3Auth(defaultUsername, "127.0.0.1", common.ProtocolS3)
+	assert.NoError(t, err)
+	assert.Equal(t, secret, s3Secret)
+
+	req, err = http.NewRequest(http.MethodPost, userS3CredentialsPath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	req, err = http.NewRequest(http.MethodDelete, userS3CredentialsPath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+	_, _, err = dataprovider.GetUserForS3Auth(defaultUsername, "127.0.0.1", common.ProtocolS3)
+	assert.Error(t, err)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
+func TestWebClientBulkFileJobs(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+	err = os.MkdirAll(user.GetHomeDir(), os.ModePerm)
+	assert.NoError(t, err)
+	for _, name := range []string{"file1", "file2", "file3"} {
+		err = os.WriteFile(filepath.Join(user.GetHomeDir(), name), []byte("contents"), os.ModePerm)
+		assert.NoError(t, err)
+	}
+	webToken, err := getJWTWebClientTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+	csrfToken, err := getCSRFToken(httpBaseURL + webClientLoginPath)
+	assert.NoError(t, err)
+
+	waitJob := func(jobID string) map[string]any {
+		var job map[string]any
+		assert.Eventually(t, func() bool {
+			req, err := http.NewRequest(http.MethodGet, path.Join(webClientFileJobsPath, jobID), nil)
+			assert.NoError(t, err)
+			setJWTCookieForReq(req, webToken)
+			req.Header.Set("X-CSRF-TOKEN", csrfToken)
+			rr := executeRequest(req)
+			checkResponseCode(t, http.StatusOK, rr)
+			err = json.Unmarshal(rr.Body.Bytes(), &job)
+			assert.NoError(t, err)
+			return job["status"] != "running"
+		}, 5*time.Second, 100*time.Millisecond)
+		return job
+	}
+	startJob := func(body string, expectedStatusCode int) string {
+		req, err := http.NewRequest(http.MethodPost, webClientFileJobsPath+"/bulk", bytes.NewBufferString(body))
+		assert.NoError(t, err)
+		setJWTCookieForReq(req, webToken)
+		req.Header.Set("X-CSRF-TOKEN", csrfToken)
+		rr := executeRequest(req)
+		checkResponseCode(t, expectedStatusCode, rr)
+		if expectedStatusCode != http.StatusCreated {
+			return ""
+		}
+		var job map[string]any
+		err = json.Unmarshal(rr.Body.Bytes(), &job)
+		assert.NoError(t, err)
+		return job["id"].(string)
+	}
+
+	startJob(`{"operation":"unknown","items":[{"source":"/file1"}]}`, http.StatusBadRequest)
+	startJob(`{"operation":"delete","items":[]}`, http.StatusBadRequest)
+	startJob(`{"operation":"delete","items":[{"source":"/"}]}`, http.StatusBadRequest)
+	startJob(`{"operation":"move","items":[{"source":"/file1","target":"/"}]}`, http.StatusBadRequest)
+	startJob(`{"operation":"copy"`, http.StatusBadRequest)
+
+	jobID := startJob(`{"operation":"copy","items":[{"source":"/file1","target":"/dir/file1"},`+
+		`{"source":"/file2","target":"/dir/file2"}]}`, http.StatusCreated)
+	job := waitJob(jobID)
+	assert.Equal(t, "completed", job["status"])
+	assert.Equal(t, float64(2), job["total_items"])
+	assert.Equal(t, float64(2), job["items"])
+	assert.Equal(t, "/", job["source"])
+	assert.Equal(t, "/dir", job["target"])
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "dir", "file1"))
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "dir", "file2"))
+
+	jobID = startJob(`{"operation":"delete","items":[{"source":"/file1"},{"source":"/missing"},`+
+		`{"source":"/file3"}]}`, http.StatusCreated)
+	job = waitJob(jobID)
+	assert.Equal(t, "failed", job["status"])
+	assert.Equal(t, float64(3), job["items"])
+	assert.Equal(t, float64(1), job["failed_items"])
+	assert.Contains(t, job["error"], "/missing")
+	assert.NoFileExists(t, filepath.Join(user.GetHomeDir(), "file1"))
+	assert.NoFileExists(t, filepath.Join(user.GetHomeDir(), "file3"))
+	assert.FileExists(t, filepath.Join(user.GetHomeDir(), "file2"))
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
 }
 
 func TestWebGetFiles(t *testing.T) {
@@ -15846,6 +19579,70 @@ func TestWebGetFiles(t *testing.T) {
 	setBearerForReq(req, webAPIToken)
 	rr = executeRequest(req)
 	checkResponseCode(t, http.StatusOK, rr)
+	assert.Equal(t, "application/zip", rr.Header().Get("Content-Type"))
+
+	req, _ = http.NewRequest(http.MethodPost, userStreamZipPath+"?format=tar", bytes.NewBuffer(asJSON))
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Equal(t, "application/x-tar", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "bytes", rr.Header().Get("Accept-Ranges"))
+	assert.Contains(t, rr.Header().Get("Content-Disposition"), ".tar\"")
+	tarContents := rr.Body.Bytes()
+	assert.Equal(t, strconv.Itoa(len(tarContents)), rr.Header().Get("Content-Length"))
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+	// resume the download
+	req, _ = http.NewRequest(http.MethodPost, userStreamZipPath+"?format=tar", bytes.NewBuffer(asJSON))
+	req.Header.Set("Range", "bytes=100-")
+	req.Header.Set("If-Range", etag)
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusPartialContent, rr)
+	assert.Equal(t, fmt.Sprintf("bytes 100-%d/%d", len(tarContents)-1, len(tarContents)),
+		rr.Header().Get("Content-Range"))
+	assert.Equal(t, tarContents[100:], rr.Body.Bytes())
+	// the range is ignored if the archive changed
+	req, _ = http.NewRequest(http.MethodPost, userStreamZipPath+"?format=tar", bytes.NewBuffer(asJSON))
+	req.Header.Set("Range", "bytes=100-")
+	req.Header.Set("If-Range", `"abc"`)
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Equal(t, tarContents, rr.Body.Bytes())
+
+	req, _ = http.NewRequest(http.MethodPost, userStreamZipPath+"?format=tar", bytes.NewBuffer(asJSON))
+	req.Header.Set("Range", "bytes=0-10,20-30")
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusRequestedRangeNotSatisfiable, rr)
+
+	req, _ = http.NewRequest(http.MethodPost, userStreamZipPath+"?format=tar.gz&compression=1", bytes.NewBuffer(asJSON))
+	req.Header.Set("Range", "bytes=100-")
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Equal(t, "none", rr.Header().Get("Accept-Ranges"))
+	gzReader, err := gzip.NewReader(bytes.NewReader(rr.Body.Bytes()))
+	assert.NoError(t, err)
+	uncompressed, err := io.ReadAll(gzReader)
+	assert.NoError(t, err)
+	assert.Equal(t, tarContents, uncompressed)
+
+	req, _ = http.NewRequest(http.MethodPost, userStreamZipPath+"?format=rar", bytes.NewBuffer(asJSON))
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	req, _ = http.NewRequest(http.MethodPost, userStreamZipPath+"?format=tar", bytes.NewBuffer([]byte(`["missing"]`)))
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+
+	req, _ = http.NewRequest(http.MethodPost, userStreamZipPath+"?compression=0", bytes.NewBuffer(asJSON))
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
 
 	assert.NoError(t, err)
 	req, _ = http.NewRequest(http.MethodPost, userStreamZipPath, bytes.NewBuffer([]byte(`file`)))
@@ -16084,6 +19881,75 @@ func TestWebGetFiles(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestUserFileVersionsAPI(t *testing.T) {
+	user, _, err := httpdtest.AddUser(getTestUser(), http.StatusCreated)
+	assert.NoError(t, err)
+	testFileName := "file.txt"
+	err = os.MkdirAll(user.GetHomeDir(), os.ModePerm)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(user.GetHomeDir(), testFileName), []byte("content"), os.ModePerm)
+	assert.NoError(t, err)
+
+	webAPIToken, err := getJWTAPIUserTokenFromTestServer(defaultUsername, defaultPassword)
+	assert.NoError(t, err)
+	// versions are not supported for the local filesystem
+	req, err := http.NewRequest(http.MethodGet, userFileVersionsPath+"?path="+testFileName, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, webAPIToken)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "file versions are not supported")
+
+	req, err = http.NewRequest(http.MethodPost, userFileVersionsPath+"/restore?path="+testFileName, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "Please set the version to restore")
+
+	req, err = http.NewRequest(http.MethodPost, userFileActionsPath+"/tier?path="+testFileName, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "Please set the access tier")
+
+	req, err = http.NewRequest(http.MethodPost, userFileActionsPath+"/tier?path="+testFileName+"&tier=Cool", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "access tiers are not supported")
+
+	req, err = http.NewRequest(http.MethodPost, userFileVersionsPath+"/restore?path="+testFileName+"&version_id=v1", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+	assert.Contains(t, rr.Body.String(), "file versions are not supported")
+
+	user.Filters.FilePatterns = []sdk.PatternsFilter{
+		{
+			Path:            "/",
+			DeniedPatterns:  []string{"*.txt"},
+			DenyPolicy:      sdk.DenyPolicyDefault,
+			AllowedPatterns: []string{},
+		},
+	}
+	_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodGet, userFileVersionsPath+"?path="+testFileName, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, webAPIToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestRenameDifferentResource(t *testing.T) {
 	folderName := "foldercryptfs"
 	f := vfs.BaseVirtualFolder{
@@ -19200,6 +23066,147 @@ func TestAPIKeyOnDeleteCascade(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAPIKeyFiltersAndRotation(t *testing.T) {
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+	admin := getTestAdmin()
+	admin.Username = altAdminUsername
+	admin.Filters.AllowAPIKeyAuth = true
+	admin, _, err = httpdtest.AddAdmin(admin, http.StatusCreated)
+	assert.NoError(t, err)
+
+	apiKey := dataprovider.APIKey{
+		Name:  "restricted api key",
+		Scope: dataprovider.APIKeyScopeAdmin,
+		Admin: altAdminUsername,
+		Filters: dataprovider.APIKeyFilters{
+			Endpoints: []dataprovider.APIKeyEndpoint{
+				{
+					Path:    "/version",
+					Methods: []string{"get"},
+				},
+			},
+		},
+	}
+	apiKey.Filters.Endpoints[0].Path = "version"
+	_, resp, err := httpdtest.AddAPIKey(apiKey, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	apiKey.Filters.Endpoints[0].Path = "/version"
+	apiKey.Filters.AllowedIP = []string{"invalid"}
+	_, resp, err = httpdtest.AddAPIKey(apiKey, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	apiKey.Filters.AllowedIP = nil
+	apiKey.Filters.Endpoints[0].Methods = []string{"CONNECT"}
+	_, resp, err = httpdtest.AddAPIKey(apiKey, http.StatusBadRequest)
+	assert.NoError(t, err, string(resp))
+	apiKey.Filters.Endpoints[0].Methods = []string{http.MethodGet}
+	apiKey, resp, err = httpdtest.AddAPIKey(apiKey, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+
+	req, err := http.NewRequest(http.MethodGet, versionPath, nil)
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	setAPIKeyForReq(req, apiKey.Key, "")
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+
+	req, err = http.NewRequest(http.MethodGet, adminPath, nil)
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	setAPIKeyForReq(req, apiKey.Key, "")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	apiKey.Filters.AllowedIP = []string{"10.8.0.0/24"}
+	_, resp, err = httpdtest.UpdateAPIKey(apiKey, http.StatusOK)
+	assert.NoError(t, err, string(resp))
+
+	req, err = http.NewRequest(http.MethodGet, versionPath, nil)
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	setAPIKeyForReq(req, apiKey.Key, "")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusForbidden, rr)
+
+	apiKey.Filters.AllowedIP = []string{"127.0.0.0/8"}
+	_, resp, err = httpdtest.UpdateAPIKey(apiKey, http.StatusOK)
+	assert.NoError(t, err, string(resp))
+	// rotate the key, the previous one is still accepted during the grace period
+	rotatePath := path.Join(apiKeysPath, apiKey.KeyID, "rotate")
+	req, err = http.NewRequest(http.MethodPost, rotatePath+"?grace-period=a", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
+
+	req, err = http.NewRequest(http.MethodPost, path.Join(apiKeysPath, "missing", "rotate"), nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
+
+	req, err = http.NewRequest(http.MethodPost, rotatePath, nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	response := make(map[string]string)
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	rotatedKey := response["key"]
+	assert.NotEmpty(t, rotatedKey)
+	assert.NotEqual(t, apiKey.Key, rotatedKey)
+
+	for _, key := range []string{apiKey.Key, rotatedKey} {
+		req, err = http.NewRequest(http.MethodGet, versionPath, nil)
+		assert.NoError(t, err)
+		req.RemoteAddr = defaultRemoteAddr
+		setAPIKeyForReq(req, key, "")
+		rr = executeRequest(req)
+		checkResponseCode(t, http.StatusOK, rr)
+	}
+	// filters and the grace period must be preserved on update
+	apiKey.Description = "updated desc"
+	_, resp, err = httpdtest.UpdateAPIKey(apiKey, http.StatusOK)
+	assert.NoError(t, err, string(resp))
+	k, _, err := httpdtest.GetAPIKeyByID(apiKey.KeyID, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, k.Filters.Endpoints, 1)
+	assert.Greater(t, k.Filters.PreviousKeyExpiresAt, int64(0))
+	assert.Empty(t, k.Filters.PreviousKey)
+	// rotate without grace period
+	req, err = http.NewRequest(http.MethodPost, rotatePath+"?grace-period=0", nil)
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	response = make(map[string]string)
+	err = json.Unmarshal(rr.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	newKey := response["key"]
+	assert.NotEmpty(t, newKey)
+
+	for _, key := range []string{apiKey.Key, rotatedKey} {
+		req, err = http.NewRequest(http.MethodGet, versionPath, nil)
+		assert.NoError(t, err)
+		req.RemoteAddr = defaultRemoteAddr
+		setAPIKeyForReq(req, key, "")
+		rr = executeRequest(req)
+		checkResponseCode(t, http.StatusUnauthorized, rr)
+	}
+	req, err = http.NewRequest(http.MethodGet, versionPath, nil)
+	assert.NoError(t, err)
+	req.RemoteAddr = defaultRemoteAddr
+	setAPIKeyForReq(req, newKey, "")
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+
+	_, err = httpdtest.RemoveAdmin(admin, http.StatusOK)
+	assert.NoError(t, err)
+	_, _, err = httpdtest.GetAPIKeyByID(apiKey.KeyID, http.StatusNotFound)
+	assert.NoError(t, err)
+}
+
 func TestBasicWebUsersMock(t *testing.T) {
 	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
 	assert.NoError(t, err)
@@ -20972,35 +24979,197 @@ func TestUserPlaceholders(t *testing.T) {
 
 	user, _, err := httpdtest.GetUserByUsername(defaultUsername, http.StatusOK)
 	assert.NoError(t, err)
-	assert.Equal(t, filepath.Join(os.TempDir(), fmt.Sprintf("%v_%v", defaultUsername, defaultPassword)), user.HomeDir)
+	assert.Equal(t, filepath.Join(os.TempDir(), fmt.Sprintf("%v_%v", defaultUsername, defaultPassword)), user.HomeDir)
+
+	dbUser, err := dataprovider.UserExists(defaultUsername, "")
+	assert.NoError(t, err)
+	assert.True(t, dbUser.IsPasswordHashed())
+	hashedPwd := dbUser.Password
+
+	form.Set("password", redactedSecret)
+	b, contentType, _ = getMultipartFormData(form, "", "")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webUserPath, defaultUsername), &b)
+	assert.NoError(t, err)
+	setJWTCookieForReq(req, token)
+	req.Header.Set("Content-Type", contentType)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+
+	user, _, err = httpdtest.GetUserByUsername(defaultUsername, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(os.TempDir(), defaultUsername+"_%password%"), user.HomeDir)
+	// check that the password was unchanged
+	dbUser, err = dataprovider.UserExists(defaultUsername, "")
+	assert.NoError(t, err)
+	assert.True(t, dbUser.IsPasswordHashed())
+	assert.Equal(t, hashedPwd, dbUser.Password)
+
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+}
+
+func TestAddUsersFromTemplate(t *testing.T) {
+	group, _, err := httpdtest.AddGroup(getTestGroup(), http.StatusCreated)
+	assert.NoError(t, err)
+	baseUser := getTestUser()
+	baseUser.Username = "template_base"
+	baseUser.Description = "base user"
+	baseUser.QuotaFiles = 100
+	baseUser.Groups = []sdk.GroupMapping{
+		{
+			Name: group.Name,
+			Type: sdk.GroupTypePrimary,
+		},
+	}
+	baseUser, _, err = httpdtest.AddUser(baseUser, http.StatusCreated)
+	assert.NoError(t, err)
+	token, err := getJWTAPITokenFromTestServer(defaultTokenAuthUser, defaultTokenAuthPass)
+	assert.NoError(t, err)
+
+	template := dataprovider.UserTemplate{
+		Extends: baseUser.Username,
+		User: json.RawMessage(`{"home_dir":"` + filepath.ToSlash(filepath.Join(os.TempDir(), "%group%")) +
+			`/%if:domain%%domain%%else%local%endif%/%username%","description":"%if:attr:dept=sales%Sales%else%Staff%endif%",` +
+			`"additional_info":"%attr:dept%"}`),
+		Users: []dataprovider.UserTemplateFields{
+			{
+				Username: "template_user1",
+				Password: "pwd_%username%",
+				Attributes: map[string]string{
+					"dept": "sales",
+				},
+			},
+			{
+				Username: "template_user2",
+			},
+		},
+	}
+	asJSON, err := json.Marshal(template)
+	assert.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, userTemplatePath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr := executeRequest(req)
+	checkResponseCode(t, http.StatusCreated, rr)
+
+	user1, _, err := httpdtest.GetUserByUsername("template_user1", http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(os.TempDir(), group.Name, "local", user1.Username), user1.HomeDir)
+	assert.Equal(t, "Sales", user1.Description)
+	assert.Equal(t, "sales", user1.AdditionalInfo)
+	assert.Equal(t, 100, user1.QuotaFiles)
+	assert.Len(t, user1.Groups, 1)
+	_, _, err = httpdtest.GetUserByUsername("template_user2", http.StatusOK)
+	assert.NoError(t, err)
+	user2, err := dataprovider.UserExists("template_user2", "")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(os.TempDir(), group.Name, "local", user2.Username), user2.HomeDir)
+	assert.Equal(t, "Staff", user2.Description)
+	assert.Empty(t, user2.AdditionalInfo)
+	assert.Empty(t, user2.Password)
+	_, err = dataprovider.CheckUserAndPass(user1.Username, "pwd_"+user1.Username, "127.0.0.1", common.ProtocolSSH)
+	assert.NoError(t, err)
+	// adding the same users again must fail
+	req, err = http.NewRequest(http.MethodPost, userTemplatePath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	assert.NotEqual(t, http.StatusCreated, rr.Code)
+
+	template.Extends = "missing user"
+	asJSON, err = json.Marshal(template)
+	assert.NoError(t, err)
+	req, err = http.NewRequest(http.MethodPost, userTemplatePath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusNotFound, rr)
 
-	dbUser, err := dataprovider.UserExists(defaultUsername, "")
+	template.Extends = ""
+	template.Users = nil
+	asJSON, err = json.Marshal(template)
 	assert.NoError(t, err)
-	assert.True(t, dbUser.IsPasswordHashed())
-	hashedPwd := dbUser.Password
+	req, err = http.NewRequest(http.MethodPost, userTemplatePath, bytes.NewBuffer(asJSON))
+	assert.NoError(t, err)
+	setBearerForReq(req, token)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusBadRequest, rr)
 
-	form.Set("password", redactedSecret)
-	b, contentType, _ = getMultipartFormData(form, "", "")
-	req, err = http.NewRequest(http.MethodPost, path.Join(webUserPath, defaultUsername), &b)
+	req, err = http.NewRequest(http.MethodPost, userTemplatePath, bytes.NewBuffer([]byte("{")))
 	assert.NoError(t, err)
-	setJWTCookieForReq(req, token)
-	req.Header.Set("Content-Type", contentType)
+	setBearerForReq(req, token)
 	rr = executeRequest(req)
-	checkResponseCode(t, http.StatusSeeOther, rr)
+	checkResponseCode(t, http.StatusBadRequest, rr)
 
-	user, _, err = httpdtest.GetUserByUsername(defaultUsername, http.StatusOK)
+	for _, username := range []string{user1.Username, user2.Username, baseUser.Username} {
+		_, err = httpdtest.RemoveUser(dataprovider.User{BaseUser: sdk.BaseUser{Username: username}}, http.StatusOK)
+		assert.NoError(t, err)
+	}
+	_, err = httpdtest.RemoveGroup(group, http.StatusOK)
 	assert.NoError(t, err)
-	assert.Equal(t, filepath.Join(os.TempDir(), defaultUsername+"_%password%"), user.HomeDir)
-	// check that the password was unchanged
-	dbUser, err = dataprovider.UserExists(defaultUsername, "")
+}
+
+func TestUserFolderAttributes(t *testing.T) {
+	mappedPath := filepath.Join(os.TempDir(), "attributes_folder")
+	f := vfs.BaseVirtualFolder{
+		Name:       filepath.Base(mappedPath),
+		MappedPath: mappedPath,
+		Attributes: map[string]string{
+			"invalid name": "value",
+		},
+	}
+	_, _, err := httpdtest.AddFolder(f, http.StatusBadRequest)
 	assert.NoError(t, err)
-	assert.True(t, dbUser.IsPasswordHashed())
-	assert.Equal(t, hashedPwd, dbUser.Password)
+	f.Attributes = map[string]string{
+		"department": "sales",
+	}
+	folder, _, err := httpdtest.AddFolder(f, http.StatusCreated)
+	assert.NoError(t, err)
+	assert.Equal(t, "sales", folder.Attributes["department"])
+	folder.Attributes["cost-center"] = "cc1"
+	_, _, err = httpdtest.UpdateFolder(folder, http.StatusOK)
+	assert.NoError(t, err)
+	folder, _, err = httpdtest.GetFolderByName(folder.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Len(t, folder.Attributes, 2)
+	assert.Equal(t, "cc1", folder.Attributes["cost-center"])
 
-	err = os.RemoveAll(user.GetHomeDir())
+	u := getTestUser()
+	u.Attributes = map[string]string{
+		"tier": strings.Repeat("a", 1025),
+	}
+	_, _, err = httpdtest.AddUser(u, http.StatusBadRequest)
+	assert.NoError(t, err)
+	u.Attributes = map[string]string{
+		"tier": "gold",
+	}
+	u.VirtualFolders = append(u.VirtualFolders, vfs.VirtualFolder{
+		BaseVirtualFolder: vfs.BaseVirtualFolder{
+			Name: folder.Name,
+		},
+		VirtualPath: "/vdir",
+	})
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	assert.Equal(t, "gold", user.Attributes["tier"])
+	if assert.Len(t, user.VirtualFolders, 1) {
+		assert.Equal(t, "sales", user.VirtualFolders[0].Attributes["department"])
+	}
+	user.Attributes = nil
+	_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
 	assert.NoError(t, err)
+	assert.Len(t, user.Attributes, 0)
+
 	_, err = httpdtest.RemoveUser(user, http.StatusOK)
 	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveFolder(folder, http.StatusOK)
+	assert.NoError(t, err)
 }
 
 func TestFolderPlaceholders(t *testing.T) {
@@ -21272,6 +25441,17 @@ func TestWebUserS3Mock(t *testing.T) {
 	user.FsConfig.S3Config.UploadConcurrency = 4
 	user.FsConfig.S3Config.DownloadPartMaxTime = 60
 	user.FsConfig.S3Config.UploadPartMaxTime = 120
+	user.FsConfig.S3Config.UploadMaxMemory = 15
+	user.FsConfig.S3Config.ObjectLockMode = "COMPLIANCE"
+	user.FsConfig.S3Config.ObjectLockRetentionDays = 90
+	user.FsConfig.S3Config.RestoreDays = 3
+	user.FsConfig.S3Config.RestoreTier = "Bulk"
+	user.FsConfig.S3Config.RequesterPays = true
+	user.FsConfig.S3Config.SSEKMSKeyID = "arn:aws:kms:us-east-1:123456789012:key/1234abcd"
+	user.FsConfig.S3Config.FailoverEndpoints = []vfs.S3FailoverEndpoint{
+		{Endpoint: "http://127.0.0.1:9001"},
+		{Region: "us-west-2"},
+	}
 	user.FsConfig.S3Config.DownloadPartSize = 6
 	user.FsConfig.S3Config.DownloadConcurrency = 3
 	user.FsConfig.S3Config.ForcePathStyle = true
@@ -21383,6 +25563,21 @@ func TestWebUserS3Mock(t *testing.T) {
 	checkResponseCode(t, http.StatusOK, rr)
 	// now add the user
 	form.Set("s3_upload_part_max_time", strconv.Itoa(user.FsConfig.S3Config.UploadPartMaxTime))
+	form.Set("s3_upload_max_memory", strconv.FormatInt(user.FsConfig.S3Config.UploadMaxMemory, 10))
+	form.Set("s3_object_lock_mode", user.FsConfig.S3Config.ObjectLockMode)
+	form.Set("s3_restore_days", strconv.Itoa(user.FsConfig.S3Config.RestoreDays))
+	form.Set("s3_restore_tier", user.FsConfig.S3Config.RestoreTier)
+	form.Set("s3_requester_pays", "checked")
+	form.Set("s3_sse_kms_key_id", user.FsConfig.S3Config.SSEKMSKeyID)
+	form.Set("s3_failover_endpoints", "http://127.0.0.1:9001\n ,us-west-2 \n\n")
+	form.Set("s3_object_lock_retention_days", "a")
+	b, contentType, _ = getMultipartFormData(form, "", "")
+	req, _ = http.NewRequest(http.MethodPost, path.Join(webUserPath, user.Username), &b)
+	setJWTCookieForReq(req, webToken)
+	req.Header.Set("Content-Type", contentType)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	form.Set("s3_object_lock_retention_days", strconv.Itoa(user.FsConfig.S3Config.ObjectLockRetentionDays))
 	b, contentType, _ = getMultipartFormData(form, "", "")
 	req, _ = http.NewRequest(http.MethodPost, path.Join(webUserPath, user.Username), &b)
 	setJWTCookieForReq(req, webToken)
@@ -21409,6 +25604,14 @@ func TestWebUserS3Mock(t *testing.T) {
 	assert.Equal(t, updateUser.FsConfig.S3Config.UploadConcurrency, user.FsConfig.S3Config.UploadConcurrency)
 	assert.Equal(t, updateUser.FsConfig.S3Config.DownloadPartMaxTime, user.FsConfig.S3Config.DownloadPartMaxTime)
 	assert.Equal(t, updateUser.FsConfig.S3Config.UploadPartMaxTime, user.FsConfig.S3Config.UploadPartMaxTime)
+	assert.Equal(t, updateUser.FsConfig.S3Config.UploadMaxMemory, user.FsConfig.S3Config.UploadMaxMemory)
+	assert.Equal(t, updateUser.FsConfig.S3Config.ObjectLockMode, user.FsConfig.S3Config.ObjectLockMode)
+	assert.Equal(t, updateUser.FsConfig.S3Config.ObjectLockRetentionDays, user.FsConfig.S3Config.ObjectLockRetentionDays)
+	assert.Equal(t, updateUser.FsConfig.S3Config.RestoreDays, user.FsConfig.S3Config.RestoreDays)
+	assert.Equal(t, updateUser.FsConfig.S3Config.RestoreTier, user.FsConfig.S3Config.RestoreTier)
+	assert.True(t, updateUser.FsConfig.S3Config.RequesterPays)
+	assert.Equal(t, user.FsConfig.S3Config.SSEKMSKeyID, updateUser.FsConfig.S3Config.SSEKMSKeyID)
+	assert.Equal(t, user.FsConfig.S3Config.FailoverEndpoints, updateUser.FsConfig.S3Config.FailoverEndpoints)
 	assert.Equal(t, updateUser.FsConfig.S3Config.DownloadPartSize, user.FsConfig.S3Config.DownloadPartSize)
 	assert.Equal(t, updateUser.FsConfig.S3Config.DownloadConcurrency, user.FsConfig.S3Config.DownloadConcurrency)
 	assert.Equal(t, lastPwdChange, updateUser.LastPasswordChange)
@@ -21506,6 +25709,8 @@ func TestWebUserGCSMock(t *testing.T) {
 	user.FsConfig.GCSConfig.ACL = "publicReadWrite"
 	user.FsConfig.GCSConfig.UploadPartSize = 16
 	user.FsConfig.GCSConfig.UploadPartMaxTime = 32
+	user.FsConfig.GCSConfig.KMSKeyName = "projects/p/locations/us/keyRings/r/cryptoKeys/k"
+	user.FsConfig.GCSConfig.UserProject = "billing-project"
 	form := make(url.Values)
 	form.Set(csrfFormToken, csrfToken)
 	form.Set("username", user.Username)
@@ -21531,6 +25736,8 @@ func TestWebUserGCSMock(t *testing.T) {
 	form.Set("gcs_bucket", user.FsConfig.GCSConfig.Bucket)
 	form.Set("gcs_storage_class", user.FsConfig.GCSConfig.StorageClass)
 	form.Set("gcs_acl", user.FsConfig.GCSConfig.ACL)
+	form.Set("gcs_kms_key_name", user.FsConfig.GCSConfig.KMSKeyName)
+	form.Set("gcs_user_project", user.FsConfig.GCSConfig.UserProject)
 	form.Set("gcs_key_prefix", user.FsConfig.GCSConfig.KeyPrefix)
 	form.Set("gcs_upload_part_size", strconv.FormatInt(user.FsConfig.GCSConfig.UploadPartSize, 10))
 	form.Set("gcs_upload_part_max_time", strconv.FormatInt(int64(user.FsConfig.GCSConfig.UploadPartMaxTime), 10))
@@ -21578,6 +25785,8 @@ func TestWebUserGCSMock(t *testing.T) {
 	assert.Equal(t, user.FsConfig.GCSConfig.KeyPrefix, updateUser.FsConfig.GCSConfig.KeyPrefix)
 	assert.Equal(t, user.FsConfig.GCSConfig.UploadPartSize, updateUser.FsConfig.GCSConfig.UploadPartSize)
 	assert.Equal(t, user.FsConfig.GCSConfig.UploadPartMaxTime, updateUser.FsConfig.GCSConfig.UploadPartMaxTime)
+	assert.Equal(t, user.FsConfig.GCSConfig.KMSKeyName, updateUser.FsConfig.GCSConfig.KMSKeyName)
+	assert.Equal(t, user.FsConfig.GCSConfig.UserProject, updateUser.FsConfig.GCSConfig.UserProject)
 	if assert.Len(t, updateUser.Filters.FilePatterns, 1) {
 		assert.Equal(t, "/dir1", updateUser.Filters.FilePatterns[0].Path)
 		assert.Len(t, updateUser.Filters.FilePatterns[0].AllowedPatterns, 2)
@@ -21631,8 +25840,10 @@ func TestWebUserHTTPFsMock(t *testing.T) {
 			Username:      defaultUsername,
 			SkipTLSVerify: true,
 		},
-		Password: kms.NewPlainSecret(defaultPassword),
-		APIKey:   kms.NewPlainSecret(defaultTokenAuthPass),
+		Password:         kms.NewPlainSecret(defaultPassword),
+		APIKey:           kms.NewPlainSecret(defaultTokenAuthPass),
+		UseRangeRequests: true,
+		TusUploads:       true,
 	}
 	form := make(url.Values)
 	form.Set(csrfFormToken, csrfToken)
@@ -21661,6 +25872,8 @@ func TestWebUserHTTPFsMock(t *testing.T) {
 	form.Set("http_password", user.FsConfig.HTTPConfig.Password.GetPayload())
 	form.Set("http_api_key", user.FsConfig.HTTPConfig.APIKey.GetPayload())
 	form.Set("http_skip_tls_verify", "checked")
+	form.Set("http_use_range_requests", "checked")
+	form.Set("http_tus_uploads", "checked")
 	form.Set("directory_patterns[0][pattern_path]", "/dir1")
 	form.Set("directory_patterns[0][patterns]", "*.jpg,*.png")
 	form.Set("directory_patterns[0][pattern_type]", "allowed")
@@ -21692,6 +25905,8 @@ func TestWebUserHTTPFsMock(t *testing.T) {
 	assert.Equal(t, user.FsConfig.HTTPConfig.Endpoint, updateUser.FsConfig.HTTPConfig.Endpoint)
 	assert.Equal(t, user.FsConfig.HTTPConfig.Username, updateUser.FsConfig.HTTPConfig.Username)
 	assert.Equal(t, user.FsConfig.HTTPConfig.SkipTLSVerify, updateUser.FsConfig.HTTPConfig.SkipTLSVerify)
+	assert.True(t, updateUser.FsConfig.HTTPConfig.UseRangeRequests)
+	assert.True(t, updateUser.FsConfig.HTTPConfig.TusUploads)
 	assert.Equal(t, sdkkms.SecretStatusSecretBox, updateUser.FsConfig.HTTPConfig.Password.GetStatus())
 	assert.NotEmpty(t, updateUser.FsConfig.HTTPConfig.Password.GetPayload())
 	assert.Empty(t, updateUser.FsConfig.HTTPConfig.Password.GetKey())
@@ -21761,6 +25976,16 @@ func TestWebUserAzureBlobMock(t *testing.T) {
 	user.FsConfig.AzBlobConfig.DownloadPartSize = 3
 	user.FsConfig.AzBlobConfig.DownloadConcurrency = 6
 	user.FsConfig.AzBlobConfig.UseEmulator = true
+	user.FsConfig.AzBlobConfig.TierRules = []vfs.AzBlobTierRule{
+		{
+			Path: "/backups",
+			Tier: "Archive",
+		},
+		{
+			Path: "/docs/old",
+			Tier: "Cool",
+		},
+	}
 	form := make(url.Values)
 	form.Set(csrfFormToken, csrfToken)
 	form.Set("username", user.Username)
@@ -21789,6 +26014,7 @@ func TestWebUserAzureBlobMock(t *testing.T) {
 	form.Set("az_endpoint", user.FsConfig.AzBlobConfig.Endpoint)
 	form.Set("az_key_prefix", user.FsConfig.AzBlobConfig.KeyPrefix)
 	form.Set("az_use_emulator", "checked")
+	form.Set("az_tier_rules", "/backups,Archive\n /docs/old/ , Cool ")
 	form.Set("directory_patterns[0][pattern_path]", "/dir1")
 	form.Set("directory_patterns[0][patterns]", "*.jpg,*.png")
 	form.Set("directory_patterns[0][pattern_type]", "allowed")
@@ -21859,6 +26085,7 @@ func TestWebUserAzureBlobMock(t *testing.T) {
 	assert.Equal(t, updateUser.FsConfig.AzBlobConfig.UploadConcurrency, user.FsConfig.AzBlobConfig.UploadConcurrency)
 	assert.Equal(t, updateUser.FsConfig.AzBlobConfig.DownloadPartSize, user.FsConfig.AzBlobConfig.DownloadPartSize)
 	assert.Equal(t, updateUser.FsConfig.AzBlobConfig.DownloadConcurrency, user.FsConfig.AzBlobConfig.DownloadConcurrency)
+	assert.Equal(t, user.FsConfig.AzBlobConfig.TierRules, updateUser.FsConfig.AzBlobConfig.TierRules)
 	assert.Equal(t, 2, len(updateUser.Filters.FilePatterns))
 	assert.Equal(t, sdkkms.SecretStatusSecretBox, updateUser.FsConfig.AzBlobConfig.AccountKey.GetStatus())
 	assert.NotEmpty(t, updateUser.FsConfig.AzBlobConfig.AccountKey.GetPayload())
@@ -22317,6 +26544,15 @@ func TestWebEventAction(t *testing.T) {
 	assert.Contains(t, rr.Body.String(), util.I18nError500Message)
 	form.Set("cmd_timeout", "20")
 	form.Set("pwd_expiration_threshold", "10")
+	form.Set("backup_retention", "a")
+	req, err = http.NewRequest(http.MethodPost, webAdminEventActionPath, bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nError500Message)
+	form.Set("backup_retention", "0")
 	form.Set("http_timeout", fmt.Sprintf("%d", action.Options.HTTPConfig.Timeout))
 	form.Set("http_headers[0][http_header_key]", action.Options.HTTPConfig.Headers[0].Key)
 	form.Set("http_headers[0][http_header_value]", action.Options.HTTPConfig.Headers[0].Value)
@@ -22516,12 +26752,13 @@ func TestWebEventAction(t *testing.T) {
 	// change action type again
 	action.Type = dataprovider.ActionTypeEmail
 	action.Options.EmailConfig = dataprovider.EventActionEmailConfig{
-		Recipients:  []string{"address1@example.com", "address2@example.com"},
-		Bcc:         []string{"address3@example.com"},
-		Subject:     "subject",
-		ContentType: 1,
-		Body:        "body",
-		Attachments: []string{"/file1.txt", "/file2.txt"},
+		Recipients:   []string{"address1@example.com", "address2@example.com"},
+		Bcc:          []string{"address3@example.com"},
+		Subject:      "subject",
+		ContentType:  1,
+		Body:         "body",
+		Attachments:  []string{"/file1.txt", "/file2.txt"},
+		InlineImages: []string{"logo.png"},
 	}
 	form.Set("type", fmt.Sprintf("%d", action.Type))
 	form.Set("email_recipients", "address1@example.com,  address2@example.com")
@@ -22530,6 +26767,7 @@ func TestWebEventAction(t *testing.T) {
 	form.Set("email_content_type", fmt.Sprintf("%d", action.Options.EmailConfig.ContentType))
 	form.Set("email_body", action.Options.EmailConfig.Body)
 	form.Set("email_attachments", "file1.txt, file2.txt")
+	form.Set("email_inline_images", "logo.png")
 	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
 		bytes.NewBuffer([]byte(form.Encode())))
 	assert.NoError(t, err)
@@ -22547,6 +26785,7 @@ func TestWebEventAction(t *testing.T) {
 	assert.Equal(t, action.Options.EmailConfig.ContentType, actionGet.Options.EmailConfig.ContentType)
 	assert.Equal(t, action.Options.EmailConfig.Body, actionGet.Options.EmailConfig.Body)
 	assert.Equal(t, action.Options.EmailConfig.Attachments, actionGet.Options.EmailConfig.Attachments)
+	assert.Equal(t, action.Options.EmailConfig.InlineImages, actionGet.Options.EmailConfig.InlineImages)
 	assert.Equal(t, dataprovider.EventActionHTTPConfig{}, actionGet.Options.HTTPConfig)
 	assert.Empty(t, actionGet.Options.CmdConfig.Cmd)
 	assert.Equal(t, 0, actionGet.Options.CmdConfig.Timeout)
@@ -22763,6 +27002,224 @@ func TestWebEventAction(t *testing.T) {
 	assert.Contains(t, actionGet.Options.IDPConfig.TemplateUser, `"user"`)
 	assert.Contains(t, actionGet.Options.IDPConfig.TemplateAdmin, `"admin"`)
 
+	action.Type = dataprovider.ActionTypeBackup
+	form.Set("type", fmt.Sprintf("%d", action.Type))
+	form.Set("backup_folder", "remote_backups")
+	form.Set("backup_path", "/provider")
+	form.Set("backup_passphrase", "backup passphrase")
+	form.Set("backup_retention", "3")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	actionGet, _, err = httpdtest.GetEventActionByName(action.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, action.Type, actionGet.Type)
+	assert.Equal(t, "remote_backups", actionGet.Options.BackupConfig.Folder)
+	assert.Equal(t, "/provider", actionGet.Options.BackupConfig.Path)
+	assert.Equal(t, 3, actionGet.Options.BackupConfig.Retention)
+	assert.Equal(t, sdkkms.SecretStatusSecretBox, actionGet.Options.BackupConfig.Passphrase.GetStatus())
+	assert.NotEmpty(t, actionGet.Options.BackupConfig.Passphrase.GetPayload())
+	assert.Empty(t, actionGet.Options.BackupConfig.Passphrase.GetKey())
+	assert.Empty(t, actionGet.Options.IDPConfig.TemplateUser)
+	// a redacted passphrase must preserve the existing one
+	action, err = dataprovider.EventActionExists(action.Name)
+	assert.NoError(t, err)
+	form.Set("backup_passphrase", redactedSecret)
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	actionAfter, err := dataprovider.EventActionExists(action.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, action.Options.BackupConfig.Passphrase.GetPayload(), actionAfter.Options.BackupConfig.Passphrase.GetPayload())
+
+	action.Type = dataprovider.ActionTypeChatNotification
+	form.Set("type", fmt.Sprintf("%d", action.Type))
+	form.Set("chat_platform", "a")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	form.Set("chat_platform", strconv.Itoa(dataprovider.ChatPlatformMatrix))
+	form.Set("chat_endpoint", "https://matrix.example.com")
+	form.Set("chat_channel", "!room:example.com")
+	form.Set("chat_token", "matrix token")
+	form.Set("chat_message", "{{Event}} {{Name}}")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	actionGet, _, err = httpdtest.GetEventActionByName(action.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, action.Type, actionGet.Type)
+	assert.Equal(t, dataprovider.ChatPlatformMatrix, actionGet.Options.ChatConfig.Platform)
+	assert.Equal(t, "https://matrix.example.com", actionGet.Options.ChatConfig.Endpoint)
+	assert.Equal(t, "!room:example.com", actionGet.Options.ChatConfig.Channel)
+	assert.Equal(t, "{{Event}} {{Name}}", actionGet.Options.ChatConfig.Message)
+	assert.Equal(t, sdkkms.SecretStatusSecretBox, actionGet.Options.ChatConfig.Token.GetStatus())
+	assert.NotEmpty(t, actionGet.Options.ChatConfig.Token.GetPayload())
+	assert.Empty(t, actionGet.Options.ChatConfig.Token.GetKey())
+	assert.Empty(t, actionGet.Options.BackupConfig.Folder)
+	// a redacted token must preserve the existing one
+	action, err = dataprovider.EventActionExists(action.Name)
+	assert.NoError(t, err)
+	form.Set("chat_token", redactedSecret)
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	actionAfter, err = dataprovider.EventActionExists(action.Name)
+	assert.NoError(t, err)
+	assert.Equal(t, action.Options.ChatConfig.Token.GetPayload(), actionAfter.Options.ChatConfig.Token.GetPayload())
+
+	action.Type = dataprovider.ActionTypeMessagePublish
+	form.Set("type", fmt.Sprintf("%d", action.Type))
+	form.Set("message_protocol", "a")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	form.Set("message_protocol", strconv.Itoa(dataprovider.MessagingProtocolMQTT))
+	form.Set("message_endpoint", "mqtts://broker.example.com")
+	form.Set("message_topic", "sftpgo/{{Event}}")
+	form.Set("message_username", "mqtt_user")
+	form.Set("message_password", "mqtt_pwd")
+	form.Set("message_body", "{{Name}}")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	actionGet, _, err = httpdtest.GetEventActionByName(action.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, action.Type, actionGet.Type)
+	assert.Equal(t, dataprovider.MessagingProtocolMQTT, actionGet.Options.MessageConfig.Protocol)
+	assert.Equal(t, "mqtts://broker.example.com", actionGet.Options.MessageConfig.Endpoint)
+	assert.Equal(t, "sftpgo/{{Event}}", actionGet.Options.MessageConfig.Topic)
+	assert.Equal(t, "mqtt_user", actionGet.Options.MessageConfig.Username)
+	assert.Equal(t, "{{Name}}", actionGet.Options.MessageConfig.Message)
+	assert.Equal(t, sdkkms.SecretStatusSecretBox, actionGet.Options.MessageConfig.Password.GetStatus())
+	assert.Empty(t, actionGet.Options.ChatConfig.Endpoint)
+	assert.Empty(t, actionGet.Options.MessageConfig.Key)
+
+	form.Set("message_protocol", strconv.Itoa(dataprovider.MessagingProtocolKafka))
+	form.Set("message_endpoint", "kafkas://broker1.example.com,broker2.example.com")
+	form.Set("message_topic", "sftpgo.{{Event}}")
+	form.Set("message_key", "{{Name}}")
+	form.Set("message_acks", "a")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	form.Set("message_acks", strconv.Itoa(dataprovider.KafkaAcksAll))
+	form.Set("message_sasl_mechanism", "a")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	form.Set("message_sasl_mechanism", strconv.Itoa(dataprovider.KafkaSASLScramSHA512))
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	actionGet, _, err = httpdtest.GetEventActionByName(action.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, dataprovider.MessagingProtocolKafka, actionGet.Options.MessageConfig.Protocol)
+	assert.Equal(t, "kafkas://broker1.example.com,broker2.example.com", actionGet.Options.MessageConfig.Endpoint)
+	assert.Equal(t, "{{Name}}", actionGet.Options.MessageConfig.Key)
+	assert.Equal(t, dataprovider.KafkaAcksAll, actionGet.Options.MessageConfig.Acks)
+	assert.Equal(t, dataprovider.KafkaSASLScramSHA512, actionGet.Options.MessageConfig.SASLMechanism)
+
+	action.Type = dataprovider.ActionTypeCloudQueue
+	form.Set("type", fmt.Sprintf("%d", action.Type))
+	form.Set("cloud_queue_service", "a")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	form.Set("cloud_queue_service", strconv.Itoa(dataprovider.CloudQueuePubSub))
+	form.Set("cloud_queue_target", "projects/p/topics/t")
+	form.Set("cloud_queue_region", "us-east-1")
+	form.Set("cloud_queue_message", "{{VirtualPath}}")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	actionGet, _, err = httpdtest.GetEventActionByName(action.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, action.Type, actionGet.Type)
+	assert.Equal(t, dataprovider.CloudQueuePubSub, actionGet.Options.CloudQueueConfig.Service)
+	assert.Equal(t, "projects/p/topics/t", actionGet.Options.CloudQueueConfig.Target)
+	assert.Empty(t, actionGet.Options.CloudQueueConfig.Region)
+	assert.Equal(t, "{{VirtualPath}}", actionGet.Options.CloudQueueConfig.Message)
+	assert.Empty(t, actionGet.Options.MessageConfig.Endpoint)
+
+	action.Type = dataprovider.ActionTypeUsageReport
+	form.Set("type", fmt.Sprintf("%d", action.Type))
+	form.Set("usage_report_group_by", "a")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	form.Set("usage_report_group_by", strconv.Itoa(dataprovider.UsageReportGroupByRole))
+	form.Set("usage_report_recipients", "a@example.com, b@example.com")
+	form.Set("usage_report_folder", "reports")
+	form.Set("usage_report_path", "monthly")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventActionPath, action.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	actionGet, _, err = httpdtest.GetEventActionByName(action.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, action.Type, actionGet.Type)
+	assert.Equal(t, dataprovider.UsageReportGroupByRole, actionGet.Options.UsageReportConfig.GroupBy)
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, actionGet.Options.UsageReportConfig.Recipients)
+	assert.Equal(t, "reports", actionGet.Options.UsageReportConfig.Folder)
+	assert.Equal(t, "/monthly", actionGet.Options.UsageReportConfig.Path)
+	assert.Empty(t, actionGet.Options.CloudQueueConfig.Target)
+
 	req, err = http.NewRequest(http.MethodDelete, path.Join(webAdminEventActionPath, action.Name), nil)
 	assert.NoError(t, err)
 	setBearerForReq(req, apiToken)
@@ -23042,6 +27499,73 @@ func TestWebEventRule(t *testing.T) {
 	assert.Equal(t, rule.Trigger, ruleGet.Trigger)
 	assert.Equal(t, 2, ruleGet.Conditions.IDPLoginEvent)
 
+	rule.Trigger = dataprovider.EventTriggerQuotaThreshold
+	form.Set("trigger", fmt.Sprintf("%d", rule.Trigger))
+	form.Set("quota_thresholds", "95%,a")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventRulePath, rule.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorRuleQuotaThresholdInvalid)
+	form.Set("quota_thresholds", "95%, 80")
+	form.Set("quota_hysteresis", "b")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventRulePath, rule.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorRuleQuotaHysteresisInvalid)
+	form.Set("quota_hysteresis", "5")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventRulePath, rule.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	ruleGet, _, err = httpdtest.GetEventRuleByName(rule.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, rule.Trigger, ruleGet.Trigger)
+	assert.Equal(t, []int{80, 95}, ruleGet.Conditions.QuotaThresholds)
+	assert.Equal(t, 5, ruleGet.Conditions.QuotaHysteresis)
+	assert.Len(t, ruleGet.Conditions.FsEvents, 0)
+	form.Del("quota_thresholds")
+	form.Del("quota_hysteresis")
+	form.Set("trigger", fmt.Sprintf("%d", dataprovider.EventTriggerIDPLogin))
+
+	rule.Trigger = dataprovider.EventTriggerUserInactivity
+	form.Set("trigger", fmt.Sprintf("%d", rule.Trigger))
+	form.Set("inactivity_days", "a")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventRulePath, rule.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusOK, rr)
+	assert.Contains(t, rr.Body.String(), util.I18nErrorRuleInactivityDaysInvalid)
+	form.Set("inactivity_days", "30")
+	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventRulePath, rule.Name),
+		bytes.NewBuffer([]byte(form.Encode())))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	setJWTCookieForReq(req, webToken)
+	rr = executeRequest(req)
+	checkResponseCode(t, http.StatusSeeOther, rr)
+	ruleGet, _, err = httpdtest.GetEventRuleByName(rule.Name, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, rule.Trigger, ruleGet.Trigger)
+	assert.Equal(t, 30, ruleGet.Conditions.InactivityDays)
+	assert.Len(t, ruleGet.Conditions.Schedules, 1)
+	assert.Len(t, ruleGet.Conditions.QuotaThresholds, 0)
+	form.Del("inactivity_days")
+	form.Set("trigger", fmt.Sprintf("%d", dataprovider.EventTriggerIDPLogin))
+
 	// update a missing rule
 	req, err = http.NewRequest(http.MethodPost, path.Join(webAdminEventRulePath, rule.Name+"1"),
 		bytes.NewBuffer([]byte(form.Encode())))
@@ -23666,6 +28190,10 @@ func TestAddWebFoldersMock(t *testing.T) {
 	form.Set("description", folderDesc)
 	form.Set("osfs_read_buffer_size", "3")
 	form.Set("osfs_write_buffer_size", "4")
+	form.Set("attributes[0][attribute_key]", "department")
+	form.Set("attributes[0][attribute_value]", "sales")
+	form.Set("attributes[1][attribute_key]", "")
+	form.Set("attributes[1][attribute_value]", "ignored")
 	b, contentType, err := getMultipartFormData(form, "", "")
 	assert.NoError(t, err)
 	req, err := http.NewRequest(http.MethodPost, webFolderPath, &b)
@@ -23721,6 +28249,7 @@ func TestAddWebFoldersMock(t *testing.T) {
 	assert.Equal(t, folderDesc, folder.Description)
 	assert.Equal(t, 3, folder.FsConfig.OSConfig.ReadBufferSize)
 	assert.Equal(t, 4, folder.FsConfig.OSConfig.WriteBufferSize)
+	assert.Equal(t, map[string]string{"department": "sales"}, folder.Attributes)
 	// cleanup
 	req, _ = http.NewRequest(http.MethodDelete, path.Join(folderPath, folderName), nil)
 	setBearerForReq(req, apiToken)
@@ -15,6 +15,7 @@
 package httpd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
@@ -34,8 +35,9 @@ import (
 )
 
 var (
-	forwardedProtoKey = &contextKey{"forwarded proto"}
-	errInvalidToken   = errors.New("invalid JWT token")
+	forwardedProtoKey  = &contextKey{"forwarded proto"}
+	readOnlyBindingKey = &contextKey{"read only binding"}
+	errInvalidToken    = errors.New("invalid JWT token")
 )
 
 type contextKey struct {
@@ -46,6 +48,18 @@ func (k *contextKey) String() string {
 	return "context value " + k.name
 }
 
+func markReadOnlyBinding(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), readOnlyBindingKey, true)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func isReadOnlyBinding(r *http.Request) bool {
+	readOnly, ok := r.Context().Value(readOnlyBindingKey).(bool)
+	return ok && readOnly
+}
+
 func validateJWTToken(w http.ResponseWriter, r *http.Request, audience tokenAudience) error {
 	token, _, err := jwtauth.FromContext(r.Context())
 
@@ -372,6 +386,46 @@ func checkNodeToken(tokenAuth *jwtauth.JWTAuth) func(next http.Handler) http.Han
 	}
 }
 
+func checkExternalJWT(tokenAuth *jwtauth.JWTAuth) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !externalJWT.isEnabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+			rawToken := jwtauth.TokenFromHeader(r)
+			if rawToken == "" || !externalJWT.isIssuedBy(rawToken) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+			admin, err := externalJWT.authenticate(r.Context(), rawToken, ipAddr)
+			if err != nil {
+				handleDefenderEventLoginFailed(ipAddr, dataprovider.ErrInvalidCredentials) //nolint:errcheck
+				logger.Debug(logSender, "", "unable to authenticate external JWT: %v", err)
+				sendAPIResponse(w, r, errors.New("the provided token cannot be authenticated"), "", http.StatusUnauthorized)
+				return
+			}
+			c := jwtTokenClaims{
+				Username:      admin.Username,
+				Permissions:   admin.GetEffectivePermissions(),
+				UserSelectors: admin.Filters.UserSelectors,
+				Signature:     admin.GetSignature(),
+				Role:          admin.Role,
+			}
+			resp, err := c.createTokenResponse(tokenAuth, tokenAudienceAPI, ipAddr)
+			if err != nil {
+				sendAPIResponse(w, r, err, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			r.Header.Set("Authorization", fmt.Sprintf("Bearer %v", resp["access_token"]))
+			dataprovider.UpdateAdminLastLogin(&admin)
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func checkAPIKeyAuth(tokenAuth *jwtauth.JWTAuth, scope dataprovider.APIKeyScope) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -413,6 +467,17 @@ func checkAPIKeyAuth(tokenAuth *jwtauth.JWTAuth, scope dataprovider.APIKeyScope)
 				sendAPIResponse(w, r, fmt.Errorf("the provided api key cannot be authenticated"), "", http.StatusUnauthorized)
 				return
 			}
+			if !k.IsAllowedFromIP(util.GetIPFromRemoteAddress(r.RemoteAddr)) {
+				handleDefenderEventLoginFailed(util.GetIPFromRemoteAddress(r.RemoteAddr), dataprovider.ErrInvalidCredentials) //nolint:errcheck
+				logger.Debug(logSender, "", "api key %q is not allowed from ip %q", keyID, r.RemoteAddr)
+				sendAPIResponse(w, r, fmt.Errorf("the provided api key is not allowed from this IP"), "", http.StatusForbidden)
+				return
+			}
+			if !k.IsEndpointAllowed(r.Method, strings.TrimPrefix(r.URL.Path, "/api/v2")) {
+				logger.Debug(logSender, "", "api key %q is not allowed to access %s %q", keyID, r.Method, r.URL.Path)
+				sendAPIResponse(w, r, fmt.Errorf("the provided api key is invalid for this request"), "", http.StatusForbidden)
+				return
+			}
 			if scope == dataprovider.APIKeyScopeAdmin {
 				if k.Admin != "" {
 					apiUser = k.Admin
@@ -484,11 +549,12 @@ func authenticateAdminWithAPIKey(username, keyID string, tokenAuth *jwtauth.JWTA
 		return err
 	}
 	c := jwtTokenClaims{
-		Username:    admin.Username,
-		Permissions: admin.Permissions,
-		Signature:   admin.GetSignature(),
-		Role:        admin.Role,
-		APIKeyID:    keyID,
+		Username:      admin.Username,
+		Permissions:   admin.GetEffectivePermissions(),
+		UserSelectors: admin.Filters.UserSelectors,
+		Signature:     admin.GetSignature(),
+		Role:          admin.Role,
+		APIKeyID:      keyID,
 	}
 
 	resp, err := c.createTokenResponse(tokenAuth, tokenAudienceAPI, ipAddr)
@@ -52,6 +52,8 @@ type OAuth2Config interface {
 	AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string
 	Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
 	TokenSource(ctx context.Context, t *oauth2.Token) oauth2.TokenSource
+	DeviceAuth(ctx context.Context, opts ...oauth2.AuthCodeOption) (*oauth2.DeviceAuthResponse, error)
+	DeviceAccessToken(ctx context.Context, da *oauth2.DeviceAuthResponse, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
 }
 
 // OIDCTokenVerifier defines an interface for OpenID token verifier, so we can mock them
@@ -97,17 +99,44 @@ type OIDC struct {
 	InsecureSkipSignatureCheck bool `json:"insecure_skip_signature_check" mapstructure:"insecure_skip_signature_check"`
 	// Debug enables the OIDC debug mode. In debug mode, the received id_token will be logged
 	// at the debug level
-	Debug             bool `json:"debug" mapstructure:"debug"`
-	provider          *oidc.Provider
-	verifier          OIDCTokenVerifier
-	providerLogoutURL string
-	oauth2Config      OAuth2Config
+	Debug bool `json:"debug" mapstructure:"debug"`
+	// DeviceAuth enables the OAuth2 device authorization flow to obtain REST API
+	// tokens from CLI and headless clients. The OpenID provider must support it
+	DeviceAuth bool `json:"device_auth" mapstructure:"device_auth"`
+	// Lifetime, in minutes, for the REST API tokens obtained using the device
+	// authorization flow. 0 means the default lifetime of the REST API tokens
+	DeviceAuthTokenLifetime int `json:"device_auth_token_lifetime" mapstructure:"device_auth_token_lifetime"`
+	// Lifetime, in hours, for the refresh tokens returned together with the REST API
+	// tokens obtained using the device authorization flow. 0 means no refresh token
+	DeviceAuthRefreshTokenLifetime int `json:"device_auth_refresh_token_lifetime" mapstructure:"device_auth_refresh_token_lifetime"`
+	provider                       *oidc.Provider
+	verifier                       OIDCTokenVerifier
+	providerLogoutURL              string
+	oauth2Config                   OAuth2Config
 }
 
 func (o *OIDC) isEnabled() bool {
 	return o.provider != nil
 }
 
+func (o *OIDC) isDeviceAuthEnabled() bool {
+	return o.isEnabled() && o.DeviceAuth
+}
+
+func (o *OIDC) getDeviceAuthTokenLifetime() time.Duration {
+	if o.DeviceAuthTokenLifetime <= 0 {
+		return tokenDuration
+	}
+	return time.Duration(o.DeviceAuthTokenLifetime) * time.Minute
+}
+
+func (o *OIDC) getDeviceAuthRefreshTokenLifetime() time.Duration {
+	if o.DeviceAuthRefreshTokenLifetime <= 0 {
+		return 0
+	}
+	return time.Duration(o.DeviceAuthRefreshTokenLifetime) * time.Hour
+}
+
 func (o *OIDC) hasRoles() bool {
 	return o.isEnabled() && (o.RoleField != "" || o.ImplicitRoles)
 }
@@ -221,10 +250,12 @@ type oidcToken struct {
 	Permissions          []string        `json:"permissions"`
 	HideUserPageSections int             `json:"hide_user_page_sections,omitempty"`
 	TokenRole            string          `json:"token_role,omitempty"` // SFTPGo role name
-	Role                 any             `json:"role"`                 // oidc user role: SFTPGo user or admin
+	UserSelectors        []string        `json:"user_selectors,omitempty"`
+	Role                 any             `json:"role"` // oidc user role: SFTPGo user or admin
 	CustomFields         *map[string]any `json:"custom_fields,omitempty"`
 	Cookie               string          `json:"cookie"`
 	UsedAt               int64           `json:"used_at"`
+	AuthSessionID        string          `json:"auth_session_id,omitempty"`
 }
 
 func (t *oidcToken) parseClaims(claims map[string]any, usernameField, roleField string, customFields []string,
@@ -379,8 +410,9 @@ func (t *oidcToken) refreshUser(r *http.Request) error {
 		if err := admin.CanLogin(util.GetIPFromRemoteAddress(r.RemoteAddr)); err != nil {
 			return err
 		}
-		t.Permissions = admin.Permissions
+		t.Permissions = admin.GetEffectivePermissions()
 		t.TokenRole = admin.Role
+		t.UserSelectors = admin.Filters.UserSelectors
 		t.HideUserPageSections = admin.Filters.Preferences.HideUserPageSections
 		return nil
 	}
@@ -424,8 +456,9 @@ func (t *oidcToken) getUser(r *http.Request) error {
 		if err := admin.CanLogin(ipAddr); err != nil {
 			return err
 		}
-		t.Permissions = admin.Permissions
+		t.Permissions = admin.GetEffectivePermissions()
 		t.TokenRole = admin.Role
+		t.UserSelectors = admin.Filters.UserSelectors
 		t.HideUserPageSections = admin.Filters.Preferences.HideUserPageSections
 		dataprovider.UpdateAdminLastLogin(admin)
 		return nil
@@ -491,6 +524,13 @@ func (s *httpdServer) validateOIDCToken(w http.ResponseWriter, r *http.Request,
 		doRedirect()
 		return oidcToken{}, errInvalidToken
 	}
+	if isAuthSessionRevoked(token.AuthSessionID) {
+		logger.Debug(logSender, "", "the session for the oidc token associated with cookie %q was revoked", token.Cookie)
+		oidcMgr.removeToken(token.Cookie)
+		doRedirect()
+		return oidcToken{}, errInvalidToken
+	}
+	touchAuthSession(token.AuthSessionID, time.Now().Add(tokenDeleteInterval*time.Millisecond))
 	if token.isExpired() {
 		logger.Debug(logSender, "", "oidc token associated with cookie %q is expired", token.Cookie)
 		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
@@ -544,6 +584,8 @@ func (s *httpdServer) oidcTokenAuthenticator(audience tokenAudience) func(next h
 				Permissions:          token.Permissions,
 				Role:                 token.TokenRole,
 				HideUserPageSections: token.HideUserPageSections,
+				UserSelectors:        token.UserSelectors,
+				SessionID:            token.AuthSessionID,
 			}
 			_, tokenString, err := jwtTokenClaims.createToken(s.tokenAuth, audience, util.GetIPFromRemoteAddress(r.RemoteAddr))
 			if err != nil {
@@ -710,6 +752,12 @@ func (s *httpdServer) handleOIDCRedirect(w http.ResponseWriter, r *http.Request)
 }
 
 func loginOIDCUser(w http.ResponseWriter, r *http.Request, token oidcToken) {
+	c := jwtTokenClaims{
+		Username: token.Username,
+	}
+	startAuthSession(&c, token.isAdmin(), authSessionTypeWeb, util.GetIPFromRemoteAddress(r.RemoteAddr),
+		time.Now().Add(tokenDeleteInterval*time.Millisecond))
+	token.AuthSessionID = c.SessionID
 	oidcMgr.addToken(token)
 
 	cookie := http.Cookie{
@@ -736,6 +784,7 @@ func (s *httpdServer) logoutOIDCUser(w http.ResponseWriter, r *http.Request) {
 		removeOIDCCookie(w, r)
 		token, err := oidcMgr.getToken(oidcKey)
 		if err == nil {
+			endAuthSessionByID(token.AuthSessionID)
 			s.logoutFromOIDCOP(token.IDToken)
 		}
 		oidcMgr.removeToken(oidcKey)
@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 	"unsafe"
@@ -65,6 +66,7 @@ type mockOAuth2Config struct {
 	authCodeURL string
 	token       *oauth2.Token
 	err         error
+	deviceAuth  *oauth2.DeviceAuthResponse
 }
 
 func (c *mockOAuth2Config) AuthCodeURL(_ string, _ ...oauth2.AuthCodeOption) string {
@@ -79,6 +81,19 @@ func (c *mockOAuth2Config) TokenSource(_ context.Context, _ *oauth2.Token) oauth
 	return c.tokenSource
 }
 
+func (c *mockOAuth2Config) DeviceAuth(_ context.Context, _ ...oauth2.AuthCodeOption) (*oauth2.DeviceAuthResponse, error) {
+	if c.deviceAuth == nil {
+		return nil, c.err
+	}
+	return c.deviceAuth, nil
+}
+
+func (c *mockOAuth2Config) DeviceAccessToken(_ context.Context, _ *oauth2.DeviceAuthResponse,
+	_ ...oauth2.AuthCodeOption,
+) (*oauth2.Token, error) {
+	return c.token, c.err
+}
+
 type mockOIDCVerifier struct {
 	token *oidc.IDToken
 	err   error
@@ -135,6 +150,115 @@ func TestOIDCInitialization(t *testing.T) {
 	assert.Equal(t, "http://127.0.0.1:8081"+webOIDCRedirectPath, config.getRedirectURL())
 }
 
+func TestExternalJWT(t *testing.T) {
+	config := ExternalJWT{}
+	err := config.initialize()
+	assert.NoError(t, err)
+	assert.False(t, config.isEnabled())
+	config.Issuer = "https://idp.example.com"
+	err = config.initialize()
+	assert.ErrorContains(t, err, "JWKS URL cannot be empty")
+	config.JWKSURL = fmt.Sprintf("http://%v/auth/realms/sftpgo/protocol/openid-connect/certs", oidcMockAddr)
+	err = config.initialize()
+	assert.ErrorContains(t, err, "audience cannot be empty")
+	config.Audience = "sftpgo"
+	err = config.initialize()
+	assert.ErrorContains(t, err, "at least a role mapping is required")
+	config.RoleMappings = []ExternalJWTRoleMapping{{Role: "automation"}}
+	err = config.initialize()
+	assert.ErrorContains(t, err, "role and admin are mandatory")
+	admin := dataprovider.Admin{
+		Username:    "test_external_jwt_admin",
+		Password:    "p",
+		Permissions: []string{dataprovider.PermAdminViewUsers},
+		Status:      1,
+	}
+	err = dataprovider.AddAdmin(&admin, "", "", "")
+	assert.NoError(t, err)
+	config.RoleField = "realm_access.roles"
+	config.RoleMappings = []ExternalJWTRoleMapping{
+		{Role: "automation", Admin: admin.Username},
+	}
+	err = config.initialize()
+	assert.NoError(t, err)
+	assert.True(t, config.isEnabled())
+
+	tokenAuth := jwtauth.New(jwa.HS256.String(), util.GenerateRandomBytes(32), nil)
+	_, rawToken, err := tokenAuth.Encode(map[string]any{"iss": config.Issuer})
+	assert.NoError(t, err)
+	assert.True(t, config.isIssuedBy(rawToken))
+	assert.False(t, config.isIssuedBy("invalid token"))
+	_, otherToken, err := tokenAuth.Encode(map[string]any{"iss": "https://other.example.com"})
+	assert.NoError(t, err)
+	assert.False(t, config.isIssuedBy(otherToken))
+
+	idToken := &oidc.IDToken{
+		Subject: "workload",
+	}
+	setIDTokenClaims(idToken, []byte(`{"sub":"workload","realm_access":{"roles":["reader","automation"]}}`))
+	config.verifier = &mockOIDCVerifier{
+		token: idToken,
+	}
+	a, err := config.authenticate(context.Background(), rawToken, "127.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, admin.Username, a.Username)
+
+	setIDTokenClaims(idToken, []byte(`{"sub":"workload","realm_access":{"roles":["reader"]}}`))
+	_, err = config.authenticate(context.Background(), rawToken, "127.0.0.1")
+	assert.ErrorContains(t, err, "no role mapping found")
+	setIDTokenClaims(idToken, []byte(`{"sub":"workload"}`))
+	_, err = config.authenticate(context.Background(), rawToken, "127.0.0.1")
+	assert.ErrorContains(t, err, "not found")
+	config.verifier = &mockOIDCVerifier{
+		err: &oidc.TokenExpiredError{Expiry: time.Now().Add(-time.Minute)},
+	}
+	_, err = config.authenticate(context.Background(), rawToken, "127.0.0.1")
+	var errExpired *oidc.TokenExpiredError
+	assert.ErrorAs(t, err, &errExpired)
+	// the middleware replaces the external token with an SFTPGo token
+	setIDTokenClaims(idToken, []byte(`{"sub":"workload","realm_access":{"roles":"automation"}}`))
+	config.verifier = &mockOIDCVerifier{
+		token: idToken,
+	}
+	externalJWT = config
+	defer func() {
+		externalJWT = ExternalJWT{}
+	}()
+	var authHeader string
+	handler := checkExternalJWT(tokenAuth)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	r, err := http.NewRequest(http.MethodGet, versionPath, nil)
+	assert.NoError(t, err)
+	r.RemoteAddr = "127.0.0.1:1234"
+	r.Header.Set("Authorization", "Bearer "+rawToken)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.NotEqual(t, "Bearer "+rawToken, authHeader)
+	token, err := jwtauth.VerifyToken(tokenAuth, strings.TrimPrefix(authHeader, "Bearer "))
+	if assert.NoError(t, err) {
+		claims := jwtTokenClaims{}
+		claims.Decode(token.PrivateClaims())
+		assert.Equal(t, admin.Username, claims.Username)
+		assert.Equal(t, admin.Permissions, claims.Permissions)
+	}
+	// tokens issued by others are passed through
+	r.Header.Set("Authorization", "Bearer "+otherToken)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "Bearer "+otherToken, authHeader)
+
+	err = dataprovider.DeleteAdmin(admin.Username, "", "", "")
+	assert.NoError(t, err)
+	r.Header.Set("Authorization", "Bearer "+rawToken)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
 func TestOIDCLoginLogout(t *testing.T) {
 	oidcMgr, ok := oidcMgr.(*memoryOIDCManager)
 	require.True(t, ok)
@@ -1077,6 +1201,198 @@ func TestOIDCImplicitRoles(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestOIDCDeviceAuth(t *testing.T) {
+	server := getTestOIDCServer()
+	server.enableRESTAPI = true
+	server.binding.OIDC.DeviceAuth = true
+	server.binding.OIDC.DeviceAuthRefreshTokenLifetime = 1
+	err := server.binding.OIDC.initialize()
+	assert.NoError(t, err)
+	server.initializeRouter()
+
+	server.binding.OIDC.oauth2Config = &mockOAuth2Config{
+		tokenSource: &mockTokenSource{},
+		err:         common.ErrGenericFailure,
+	}
+	rr := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodPost, oidcDeviceAuthPath, nil)
+	assert.NoError(t, err)
+	server.router.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	username := "test_oidc_device_user"
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: username,
+			Password: "pwd",
+			HomeDir:  filepath.Join(os.TempDir(), username),
+			Status:   1,
+			Permissions: map[string][]string{
+				"/": {dataprovider.PermAny},
+			},
+		},
+	}
+	err = dataprovider.AddUser(&user, "", "", "")
+	assert.NoError(t, err)
+
+	token := &oauth2.Token{
+		AccessToken: "1234",
+		Expiry:      time.Now().Add(5 * time.Minute),
+	}
+	token = token.WithExtra(map[string]any{
+		"id_token": "id_token_val",
+	})
+	server.binding.OIDC.oauth2Config = &mockOAuth2Config{
+		tokenSource: &mockTokenSource{},
+		token:       token,
+		deviceAuth: &oauth2.DeviceAuthResponse{
+			DeviceCode:      "device_code",
+			UserCode:        "user_code",
+			VerificationURI: "http://127.0.0.1/device",
+			Expiry:          time.Now().Add(5 * time.Minute),
+			Interval:        5,
+		},
+	}
+	idToken := &oidc.IDToken{
+		Expiry: time.Now().Add(5 * time.Minute),
+	}
+	setIDTokenClaims(idToken, []byte(`{"preferred_username":"test_oidc_device_user"}`))
+	server.binding.OIDC.verifier = &mockOIDCVerifier{
+		token: idToken,
+	}
+	rr = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodPost, oidcDeviceAuthPath, nil)
+	assert.NoError(t, err)
+	server.router.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var deviceAuth deviceAuthResponse
+	err = json.Unmarshal(rr.Body.Bytes(), &deviceAuth)
+	assert.NoError(t, err)
+	assert.Equal(t, "user_code", deviceAuth.UserCode)
+	assert.NotEmpty(t, deviceAuth.DeviceCode)
+
+	var resp map[string]any
+	assert.Eventually(t, func() bool {
+		rr = httptest.NewRecorder()
+		r, err = http.NewRequest(http.MethodPost, oidcDeviceTokenPath,
+			bytes.NewBuffer([]byte(fmt.Sprintf(`{"device_code":%q}`, deviceAuth.DeviceCode))))
+		assert.NoError(t, err)
+		server.router.ServeHTTP(rr, r)
+		return rr.Code == http.StatusOK
+	}, 2*time.Second, 100*time.Millisecond)
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp["access_token"])
+	refreshToken, ok := resp["refresh_token"].(string)
+	assert.True(t, ok)
+	// the result can be retrieved only once
+	rr = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodPost, oidcDeviceTokenPath,
+		bytes.NewBuffer([]byte(fmt.Sprintf(`{"device_code":%q}`, deviceAuth.DeviceCode))))
+	assert.NoError(t, err)
+	server.router.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	// the access token cannot be used as refresh token
+	rr = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodPost, oidcRefreshTokenPath,
+		bytes.NewBuffer([]byte(fmt.Sprintf(`{"refresh_token":%q}`, resp["access_token"]))))
+	assert.NoError(t, err)
+	server.router.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodPost, oidcRefreshTokenPath,
+		bytes.NewBuffer([]byte(fmt.Sprintf(`{"refresh_token":%q}`, refreshToken))))
+	assert.NoError(t, err)
+	server.router.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	resp = nil
+	err = json.Unmarshal(rr.Body.Bytes(), &resp)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, resp["access_token"])
+	assert.NotEmpty(t, resp["refresh_token"])
+	// a refresh token can be used only once
+	rr = httptest.NewRecorder()
+	r, err = http.NewRequest(http.MethodPost, oidcRefreshTokenPath,
+		bytes.NewBuffer([]byte(fmt.Sprintf(`{"refresh_token":%q}`, refreshToken))))
+	assert.NoError(t, err)
+	server.router.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+	err = dataprovider.DeleteUser(username, "", "", "")
+	assert.NoError(t, err)
+}
+
+func TestOIDCDeviceAuthLimits(t *testing.T) {
+	oldMgr := deviceAuthMgr
+	deviceAuthMgr = newDeviceAuthManager()
+	t.Cleanup(func() {
+		deviceAuthMgr = oldMgr
+	})
+
+	server := getTestOIDCServer()
+	server.enableRESTAPI = true
+	server.binding.OIDC.DeviceAuth = true
+	err := server.binding.OIDC.initialize()
+	assert.NoError(t, err)
+	server.initializeRouter()
+	server.binding.OIDC.oauth2Config = &mockOAuth2Config{
+		tokenSource: &mockTokenSource{},
+		err:         common.ErrGenericFailure,
+	}
+
+	rr := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodPost, oidcDeviceAuthPath, nil)
+	assert.NoError(t, err)
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	for i := 0; i < maxDeviceAuthRequestsPerIP; i++ {
+		err = deviceAuthMgr.add(util.GenerateUniqueID(), &deviceAuthRequest{
+			ExpiresAt: time.Now().Add(5 * time.Minute),
+			IP:        ipAddr,
+		})
+		assert.NoError(t, err)
+	}
+	err = deviceAuthMgr.add(util.GenerateUniqueID(), &deviceAuthRequest{
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+		IP:        ipAddr,
+	})
+	assert.ErrorIs(t, err, errDeviceAuthTooManyForIP)
+	// the OpenID provider is not contacted
+	server.router.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Len(t, deviceAuthMgr.requests, maxDeviceAuthRequestsPerIP)
+	// a different IP is allowed, the failed request is removed
+	rr = httptest.NewRecorder()
+	r.RemoteAddr = "172.16.1.2:1234"
+	server.router.ServeHTTP(rr, r)
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Len(t, deviceAuthMgr.requests, maxDeviceAuthRequestsPerIP)
+	// expired requests are removed
+	for _, req := range deviceAuthMgr.requests {
+		req.ExpiresAt = time.Now().Add(-deviceAuthResultValidity - time.Minute)
+	}
+	err = deviceAuthMgr.add(util.GenerateUniqueID(), &deviceAuthRequest{
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+		IP:        ipAddr,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, deviceAuthMgr.requests, 1)
+	for i := 1; i < maxDeviceAuthRequests; i++ {
+		err = deviceAuthMgr.add(util.GenerateUniqueID(), &deviceAuthRequest{
+			ExpiresAt: time.Now().Add(5 * time.Minute),
+			IP:        fmt.Sprintf("10.%d.%d.1", i/256, i%256),
+		})
+		assert.NoError(t, err)
+	}
+	err = deviceAuthMgr.add(util.GenerateUniqueID(), &deviceAuthRequest{
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+		IP:        "172.16.1.3",
+	})
+	assert.ErrorIs(t, err, errDeviceAuthTooMany)
+}
+
 func TestMemoryOIDCManager(t *testing.T) {
 	oidcMgr, ok := oidcMgr.(*memoryOIDCManager)
 	require.True(t, ok)
@@ -0,0 +1,462 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+	"github.com/rs/xid"
+	"golang.org/x/oauth2"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	// used if the OpenID provider does not return an expiration for the device code
+	defaultDeviceAuthValidity = 10 * time.Minute
+	// we wait some more time than the device code validity before removing
+	// the completed requests so the clients can get the result
+	deviceAuthResultValidity = 2 * time.Minute
+	// each device authorization polls the OpenID provider in background,
+	// so we limit the requests waiting for a result
+	maxDeviceAuthRequests      = 1000
+	maxDeviceAuthRequestsPerIP = 5
+)
+
+var (
+	deviceAuthMgr = newDeviceAuthManager()
+	// returned while the user has not yet completed the device authorization
+	// on the OpenID provider
+	errDeviceAuthPending = errors.New("device authorization pending")
+	// returned if there are too many device authorizations waiting for a result
+	errDeviceAuthTooMany = errors.New("too many device authorization requests")
+	// returned if there are too many device authorizations waiting for a
+	// result from the same IP address
+	errDeviceAuthTooManyForIP = errors.New("too many device authorization requests from the same IP address")
+)
+
+type deviceAuthRequest struct {
+	ExpiresAt time.Time
+	IP        string
+	done      bool
+	err       error
+	token     oidcToken
+}
+
+type deviceAuthManager struct {
+	mu       sync.Mutex
+	requests map[string]*deviceAuthRequest
+}
+
+func newDeviceAuthManager() *deviceAuthManager {
+	return &deviceAuthManager{
+		requests: make(map[string]*deviceAuthRequest),
+	}
+}
+
+// add adds the specified request if the limits are not exceeded
+func (m *deviceAuthManager) add(id string, req *deviceAuthRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeExpired()
+	if len(m.requests) >= maxDeviceAuthRequests {
+		return errDeviceAuthTooMany
+	}
+	numRequests := 0
+	for _, r := range m.requests {
+		if r.IP == req.IP {
+			numRequests++
+		}
+	}
+	if numRequests >= maxDeviceAuthRequestsPerIP {
+		return errDeviceAuthTooManyForIP
+	}
+	m.requests[id] = req
+	return nil
+}
+
+func (m *deviceAuthManager) setExpiration(id string, expiresAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if req, ok := m.requests[id]; ok {
+		req.ExpiresAt = expiresAt
+	}
+}
+
+func (m *deviceAuthManager) remove(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.requests, id)
+}
+
+func (m *deviceAuthManager) setResult(id string, token oidcToken, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[id]
+	if !ok {
+		return
+	}
+	req.done = true
+	req.token = token
+	req.err = err
+}
+
+// get returns the completed device authorization request with the specified id
+// and removes it, the result can be retrieved only once
+func (m *deviceAuthManager) get(id string) (deviceAuthRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[id]
+	if !ok || time.Now().After(req.ExpiresAt.Add(deviceAuthResultValidity)) {
+		return deviceAuthRequest{}, util.NewRecordNotFoundError("device code not found or expired")
+	}
+	if !req.done {
+		return deviceAuthRequest{}, errDeviceAuthPending
+	}
+	delete(m.requests, id)
+	return *req, nil
+}
+
+func (m *deviceAuthManager) cleanup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeExpired()
+}
+
+func (m *deviceAuthManager) removeExpired() {
+	for k, req := range m.requests {
+		if time.Now().After(req.ExpiresAt.Add(deviceAuthResultValidity)) {
+			delete(m.requests, k)
+		}
+	}
+}
+
+type deviceAuthResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+type deviceTokenRequest struct {
+	DeviceCode   string `json:"device_code"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (s *httpdServer) startOIDCDeviceAuth(w http.ResponseWriter, r *http.Request) {
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	// the request is reserved before contacting the OpenID provider
+	id := util.GenerateUniqueID()
+	err := deviceAuthMgr.add(id, &deviceAuthRequest{
+		ExpiresAt: time.Now().Add(defaultDeviceAuthValidity),
+		IP:        ipAddr,
+	})
+	if err != nil {
+		logger.Debug(logSender, "", "unable to start oidc device authorization for IP %q: %v", ipAddr, err)
+		if errors.Is(err, errDeviceAuthTooManyForIP) {
+			common.AddDefenderEvent(ipAddr, common.ProtocolHTTP, common.HostEventLimitExceeded)
+		}
+		sendAPIResponse(w, r, err, "", http.StatusTooManyRequests)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 20*time.Second)
+	defer cancel()
+
+	da, err := s.binding.OIDC.oauth2Config.DeviceAuth(ctx)
+	if err != nil {
+		deviceAuthMgr.remove(id)
+		logger.Debug(logSender, "", "unable to start oidc device authorization: %v", err)
+		sendAPIResponse(w, r, err, "Unable to start the device authorization", http.StatusInternalServerError)
+		return
+	}
+	if da.Expiry.IsZero() {
+		da.Expiry = time.Now().Add(defaultDeviceAuthValidity)
+	}
+	deviceAuthMgr.setExpiration(id, da.Expiry)
+	var forcedRole string
+	// the admin role can be requested only if the roles are implicit,
+	// otherwise it is read from the token claims
+	if s.binding.OIDC.ImplicitRoles && getBoolQueryParam(r, "admin") {
+		forcedRole = adminRoleFieldValue
+	}
+	go s.waitOIDCDeviceToken(id, da, forcedRole)
+
+	render.JSON(w, r, deviceAuthResponse{
+		DeviceCode:              id,
+		UserCode:                da.UserCode,
+		VerificationURI:         da.VerificationURI,
+		VerificationURIComplete: da.VerificationURIComplete,
+		ExpiresIn:               int64(time.Until(da.Expiry) / time.Second),
+		Interval:                da.Interval,
+	})
+}
+
+// waitOIDCDeviceToken polls the OpenID provider until the user completes the
+// authorization or the device code expires
+func (s *httpdServer) waitOIDCDeviceToken(id string, da *oauth2.DeviceAuthResponse, forcedRole string) {
+	ctx, cancel := context.WithDeadline(context.Background(), da.Expiry)
+	defer cancel()
+
+	oauth2Token, err := s.binding.OIDC.oauth2Config.DeviceAccessToken(ctx, da)
+	if err != nil {
+		logger.Debug(logSender, "", "oidc device authorization %q failed: %v", id, err)
+		deviceAuthMgr.setResult(id, oidcToken{}, err)
+		return
+	}
+	rawIDToken, ok := oauth2Token.Extra("id_token").(string)
+	if !ok {
+		logger.Debug(logSender, "", "no id_token field in OAuth2 OpenID token, device authorization %q", id)
+		deviceAuthMgr.setResult(id, oidcToken{}, errors.New("no id_token field in OAuth2 OpenID token"))
+		return
+	}
+	s.debugTokenClaims(nil, rawIDToken)
+	idToken, err := s.binding.OIDC.getVerifier(ctx).Verify(ctx, rawIDToken)
+	if err != nil {
+		logger.Debug(logSender, "", "failed to verify oidc token, device authorization %q: %v", id, err)
+		deviceAuthMgr.setResult(id, oidcToken{}, err)
+		return
+	}
+	claims := make(map[string]any)
+	if err := idToken.Claims(&claims); err != nil {
+		logger.Debug(logSender, "", "unable to get oidc token claims, device authorization %q: %v", id, err)
+		deviceAuthMgr.setResult(id, oidcToken{}, err)
+		return
+	}
+	s.debugTokenClaims(claims, rawIDToken)
+	token := oidcToken{
+		AccessToken:  oauth2Token.AccessToken,
+		TokenType:    oauth2Token.TokenType,
+		RefreshToken: oauth2Token.RefreshToken,
+		IDToken:      rawIDToken,
+		Cookie:       id,
+	}
+	err = token.parseClaims(claims, s.binding.OIDC.UsernameField, s.binding.OIDC.RoleField,
+		s.binding.OIDC.CustomFields, forcedRole)
+	if err != nil {
+		logger.Debug(logSender, "", "unable to parse oidc token claims, device authorization %q: %v", id, err)
+	}
+	deviceAuthMgr.setResult(id, token, err)
+}
+
+func (s *httpdServer) getOIDCDeviceToken(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	var req deviceTokenRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	authReq, err := deviceAuthMgr.get(req.DeviceCode)
+	if err != nil {
+		if errors.Is(err, errDeviceAuthPending) {
+			sendAPIResponse(w, r, nil, "Authorization pending", http.StatusAccepted)
+			return
+		}
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	if authReq.err != nil {
+		sendAPIResponse(w, r, authReq.err, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	// the login checks are executed here and not while waiting for the
+	// device token, so the client IP is taken into account
+	token := authReq.token
+	if err := token.getUser(r); err != nil {
+		logger.Debug(logSender, "", "unable to complete oidc device authorization: %v", err)
+		err = handleDefenderEventLoginFailed(ipAddr, err)
+		sendAPIResponse(w, r, err, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	s.sendOIDCDeviceTokens(w, r, token.Username, token.isAdmin(), ipAddr, "")
+}
+
+func (s *httpdServer) refreshOIDCDeviceToken(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	var req deviceTokenRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	sendUnauthorized := func(err error) {
+		logger.Debug(logSender, "", "unable to refresh oidc device token: %v", err)
+		err = handleDefenderEventLoginFailed(ipAddr, err)
+		sendAPIResponse(w, r, err, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	}
+	token, err := jwtauth.VerifyToken(s.tokenAuth, req.RefreshToken)
+	if err != nil || token == nil {
+		sendUnauthorized(fmt.Errorf("invalid refresh token: %w", err))
+		return
+	}
+	isAdmin := util.Contains(token.Audience(), tokenAudienceAPIRefresh)
+	if !isAdmin && !util.Contains(token.Audience(), tokenAudienceAPIUserRefresh) {
+		sendUnauthorized(errors.New("invalid refresh token audience"))
+		return
+	}
+	if invalidatedJWTTokens.Get(req.RefreshToken) {
+		sendUnauthorized(errors.New("the refresh token was already used"))
+		return
+	}
+	tokenClaims := jwtTokenClaims{
+		Signature: token.Subject(),
+	}
+	if val, ok := token.Get(claimUsernameKey); ok {
+		tokenClaims.Username = tokenClaims.decodeString(val)
+	}
+	if val, ok := token.Get(claimSessionID); ok {
+		tokenClaims.SessionID = tokenClaims.decodeString(val)
+	}
+	if isAuthSessionRevoked(tokenClaims.SessionID) {
+		sendUnauthorized(errors.New("the refresh token was revoked"))
+		return
+	}
+	if err := s.checkOIDCDeviceTokenOwner(tokenClaims, isAdmin, r); err != nil {
+		sendUnauthorized(err)
+		return
+	}
+	// refresh tokens can be used only once
+	invalidatedJWTTokens.Add(req.RefreshToken, token.Expiration())
+	s.sendOIDCDeviceTokens(w, r, tokenClaims.Username, isAdmin, ipAddr, tokenClaims.SessionID)
+}
+
+func (s *httpdServer) checkOIDCDeviceTokenOwner(tokenClaims jwtTokenClaims, isAdmin bool, r *http.Request) error {
+	if isAdmin {
+		admin, err := dataprovider.AdminExists(tokenClaims.Username)
+		if err != nil {
+			return err
+		}
+		if admin.GetSignature() != tokenClaims.Signature {
+			return fmt.Errorf("signature mismatch for admin %q", admin.Username)
+		}
+		return admin.CanLogin(util.GetIPFromRemoteAddress(r.RemoteAddr))
+	}
+	user, err := dataprovider.GetUserWithGroupSettings(tokenClaims.Username, "")
+	if err != nil {
+		return err
+	}
+	if user.GetSignature() != tokenClaims.Signature {
+		return fmt.Errorf("signature mismatch for user %q", user.Username)
+	}
+	if err := user.CheckLoginConditions(); err != nil {
+		return err
+	}
+	return checkHTTPClientUser(&user, r, xid.New().String(), true)
+}
+
+// sendOIDCDeviceTokens sends an API token for the specified admin or user and,
+// if enabled, a refresh token to get a new one after it expires.
+// The tokens issued refreshing a previous one are bound to the same session
+func (s *httpdServer) sendOIDCDeviceTokens(w http.ResponseWriter, r *http.Request, username string, isAdmin bool,
+	ipAddr, sessionID string,
+) {
+	var c jwtTokenClaims
+	var audience, refreshAudience tokenAudience
+
+	if isAdmin {
+		admin, err := dataprovider.AdminExists(username)
+		if err != nil {
+			sendAPIResponse(w, r, err, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		c = jwtTokenClaims{
+			Username:      admin.Username,
+			Permissions:   admin.GetEffectivePermissions(),
+			UserSelectors: admin.Filters.UserSelectors,
+			Role:          admin.Role,
+			Signature:     admin.GetSignature(),
+		}
+		audience = tokenAudienceAPI
+		refreshAudience = tokenAudienceAPIRefresh
+	} else {
+		user, err := dataprovider.GetUserWithGroupSettings(username, "")
+		if err != nil {
+			sendAPIResponse(w, r, err, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		c = jwtTokenClaims{
+			Username:                   user.Username,
+			Permissions:                user.Filters.WebClient,
+			Signature:                  user.GetSignature(),
+			Role:                       user.Role,
+			MustSetTwoFactorAuth:       user.MustSetSecondFactor(),
+			MustChangePassword:         user.MustChangePassword(),
+			RequiredTwoFactorProtocols: user.Filters.TwoFactorAuthProtocols,
+		}
+		audience = tokenAudienceAPIUser
+		refreshAudience = tokenAudienceAPIUserRefresh
+	}
+	sessionType := authSessionTypeAPI
+	sessionExpiration := time.Now().Add(s.binding.OIDC.getDeviceAuthTokenLifetime())
+	if lifetime := s.binding.OIDC.getDeviceAuthRefreshTokenLifetime(); lifetime > 0 {
+		sessionType = authSessionTypeRefresh
+		sessionExpiration = time.Now().Add(lifetime)
+	}
+	if sessionID == "" {
+		startAuthSession(&c, isAdmin, sessionType, ipAddr, sessionExpiration)
+	} else {
+		c.SessionID = sessionID
+		touchAuthSession(sessionID, sessionExpiration)
+	}
+	token, tokenString, err := c.createTokenWithDuration(s.tokenAuth, []string{audience, ipAddr},
+		s.binding.OIDC.getDeviceAuthTokenLifetime())
+	if err != nil {
+		sendAPIResponse(w, r, err, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	resp := make(map[string]any)
+	resp["access_token"] = tokenString
+	resp["expires_at"] = token.Expiration().Format(time.RFC3339)
+
+	if lifetime := s.binding.OIDC.getDeviceAuthRefreshTokenLifetime(); lifetime > 0 {
+		// the refresh token only identifies the admin/user, the permissions are
+		// loaded again from the data provider on refresh
+		refreshClaims := jwtTokenClaims{
+			Username:  c.Username,
+			Signature: c.Signature,
+			SessionID: c.SessionID,
+		}
+		// the refresh token is not bound to the client IP
+		token, tokenString, err = refreshClaims.createTokenWithDuration(s.tokenAuth, []string{refreshAudience}, lifetime)
+		if err != nil {
+			sendAPIResponse(w, r, err, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		resp["refresh_token"] = tokenString
+		resp["refresh_expires_at"] = token.Expiration().Format(time.RFC3339)
+	}
+	logger.Debug(logSender, "", "api token issued for %q using the oidc device authorization, admin? %t",
+		c.Username, isAdmin)
+	render.JSON(w, r, resp)
+}
@@ -0,0 +1,230 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/xid"
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+const (
+	templatePublicIndex     = "publicindex.html"
+	publicIndexHTMLFileName = "index.html"
+)
+
+var (
+	publicIndexTemplate *template.Template
+)
+
+// PublicIndex defines the configuration to expose virtual folders as a public,
+// read-only, directory listing. If enabled the binding serves only the public
+// index, the WebAdmin, the WebClient and the REST API are disabled
+type PublicIndex struct {
+	// Names of the virtual folders to expose, each folder is available as
+	// a top level directory with the same name
+	Folders []string `json:"folders" mapstructure:"folders"`
+	// If enabled the index.html file, if present, is served instead of the
+	// directory listing
+	RenderIndexHTML bool `json:"render_index_html" mapstructure:"render_index_html"`
+}
+
+func (p *PublicIndex) isEnabled() bool {
+	return len(p.Folders) > 0
+}
+
+func (p *PublicIndex) getFolderName(virtualPath string) string {
+	name, _, _ := strings.Cut(strings.TrimPrefix(virtualPath, "/"), "/")
+	if slices.Contains(p.Folders, name) {
+		return name
+	}
+	return ""
+}
+
+type publicIndexEntry struct {
+	Name    string
+	URL     string
+	IsDir   bool
+	Size    string
+	ModTime string
+}
+
+type publicIndexPage struct {
+	Path      string
+	HasParent bool
+	Entries   []publicIndexEntry
+}
+
+func loadPublicIndexTemplates(templatesPath string) {
+	publicIndexTemplate = util.LoadTemplate(nil, filepath.Join(templatesPath, templateCommonDir, templatePublicIndex))
+}
+
+// getPublicIndexUser returns a system user with the specified folder mounted
+// on a top level directory with the same name and read-only permissions.
+// The home directory is never created and no permission is granted on it,
+// only the mounted folder can be accessed
+func getPublicIndexUser(folder vfs.BaseVirtualFolder) dataprovider.User {
+	return dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Status:   1,
+			Username: dataprovider.ActionExecutorSystem,
+			HomeDir:  filepath.Join(os.TempDir(), "sftpgo_public_index", xid.New().String()),
+			Permissions: map[string][]string{
+				"/":               {},
+				"/" + folder.Name: {dataprovider.PermListItems, dataprovider.PermDownload},
+			},
+		},
+		VirtualFolders: []vfs.VirtualFolder{
+			{
+				BaseVirtualFolder: folder,
+				VirtualPath:       "/" + folder.Name,
+			},
+		},
+	}
+}
+
+func (s *httpdServer) servePublicIndex(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+	name := util.CleanPath(r.URL.Path)
+	if name == "/" {
+		entries := make([]publicIndexEntry, 0, len(s.binding.PublicIndex.Folders))
+		for _, folder := range s.binding.PublicIndex.Folders {
+			entries = append(entries, publicIndexEntry{
+				Name:  folder,
+				URL:   "./" + url.PathEscape(folder) + "/",
+				IsDir: true,
+			})
+		}
+		renderPublicIndex(w, publicIndexPage{Path: "/", Entries: entries})
+		return
+	}
+	folderName := s.binding.PublicIndex.getFolderName(name)
+	if folderName == "" {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	folder, err := dataprovider.GetFolderByName(folderName)
+	if err != nil {
+		logger.Warn(logSender, "", "unable to get public index folder %q: %v", folderName, err)
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	connection := &Connection{
+		BaseConnection: common.NewBaseConnection(xid.New().String(), common.ProtocolHTTP, util.GetHTTPLocalAddress(r),
+			r.RemoteAddr, getPublicIndexUser(folder)),
+		request: r,
+	}
+	if err := common.Connections.Add(connection); err != nil {
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	// name is always inside the mounted folder, we don't check the list
+	// permission on the parent directory: there are no permissions on "/"
+	info, err := connection.DoStat(name, 0, true)
+	if err != nil {
+		status := getMappedStatusCode(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	if !info.IsDir() {
+		s.servePublicIndexFile(w, r, connection, name, info)
+		return
+	}
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		http.Redirect(w, r, path.Base(name)+"/", http.StatusMovedPermanently)
+		return
+	}
+	if s.binding.PublicIndex.RenderIndexHTML {
+		indexPath := path.Join(name, publicIndexHTMLFileName)
+		if info, err := connection.Stat(indexPath, 0); err == nil && info.Mode().IsRegular() {
+			s.servePublicIndexFile(w, r, connection, indexPath, info)
+			return
+		}
+	}
+	contents, err := connection.ReadDir(name)
+	if err != nil {
+		status := getMappedStatusCode(err)
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	renderPublicIndex(w, publicIndexPage{
+		Path:      name + "/",
+		HasParent: true,
+		Entries:   getPublicIndexEntries(contents),
+	})
+}
+
+func (s *httpdServer) servePublicIndexFile(w http.ResponseWriter, r *http.Request, connection *Connection,
+	name string, info os.FileInfo,
+) {
+	if status, err := downloadFile(w, r, connection, name, info, true, nil); err != nil {
+		connection.Log(logger.LevelDebug, "unable to serve public index file %q: %v", name, err)
+		if status != 0 {
+			http.Error(w, http.StatusText(status), status)
+		}
+	}
+}
+
+func getPublicIndexEntries(contents []os.FileInfo) []publicIndexEntry {
+	slices.SortFunc(contents, func(a, b os.FileInfo) int {
+		if a.IsDir() != b.IsDir() {
+			if a.IsDir() {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Name(), b.Name())
+	})
+	entries := make([]publicIndexEntry, 0, len(contents))
+	for _, info := range contents {
+		entry := publicIndexEntry{
+			Name:    info.Name(),
+			URL:     "./" + url.PathEscape(info.Name()),
+			IsDir:   info.IsDir(),
+			ModTime: info.ModTime().UTC().Format("2006-01-02 15:04"),
+		}
+		if entry.IsDir {
+			entry.URL += "/"
+		} else {
+			entry.Size = util.ByteCountIEC(info.Size())
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func renderPublicIndex(w http.ResponseWriter, data publicIndexPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := publicIndexTemplate.ExecuteTemplate(w, templatePublicIndex, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
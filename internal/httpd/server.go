@@ -78,6 +78,13 @@ func newHttpdServer(b Binding, staticFilesPath, signingPassphrase string, cors C
 	if openAPIPath == "" {
 		b.RenderOpenAPI = false
 	}
+	if b.PublicIndex.isEnabled() {
+		// the public index is served on a dedicated binding
+		b.EnableWebAdmin = false
+		b.EnableWebClient = false
+		b.EnableRESTAPI = false
+		b.RenderOpenAPI = false
+	}
 	return &httpdServer{
 		binding:           b,
 		staticFilesPath:   staticFilesPath,
@@ -120,10 +127,15 @@ func (s *httpdServer) listenAndServe() error {
 		httpServer.TLSConfig = config
 		logger.Debug(logSender, "", "configured TLS cipher suites for binding %q: %v, certID: %v",
 			s.binding.GetAddress(), httpServer.TLSConfig.CipherSuites, certID)
-		if s.binding.ClientAuthType == 1 {
+		if s.binding.isMutualTLSEnabled() {
 			httpServer.TLSConfig.ClientCAs = certMgr.GetRootCAs()
-			httpServer.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
 			httpServer.TLSConfig.VerifyConnection = s.verifyTLSConnection
+			switch s.binding.ClientAuthType {
+			case 1:
+				httpServer.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			case 2:
+				httpServer.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			}
 		}
 		return util.HTTPListenAndServe(httpServer, s.binding.Address, s.binding.Port, true, logSender)
 	}
@@ -139,6 +151,9 @@ func (s *httpdServer) verifyTLSConnection(state tls.ConnectionState) error {
 			clientCrtName = clientCrt.Subject.String()
 		}
 		if len(state.VerifiedChains) == 0 {
+			if s.binding.ClientAuthType == 2 {
+				return nil
+			}
 			logger.Warn(logSender, "", "TLS connection cannot be verified: unable to get verification chain")
 			return errors.New("TLS connection cannot be verified: unable to get verification chain")
 		}
@@ -157,6 +172,36 @@ func (s *httpdServer) verifyTLSConnection(state tls.ConnectionState) error {
 	return nil
 }
 
+// getClientCertificate returns the verified TLS client certificate, if any
+func (s *httpdServer) getClientCertificate(r *http.Request) *x509.Certificate {
+	if s.binding.isMutualTLSEnabled() && r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0]
+	}
+	return nil
+}
+
+// getCredentialsAndLoginMethod returns the credentials and the login method for
+// HTTP basic authentication. If a client certificate is provided without basic
+// auth credentials the username is the certificate common name
+func (s *httpdServer) getCredentialsAndLoginMethod(r *http.Request) (string, string, string, *x509.Certificate, bool) {
+	loginMethod := dataprovider.LoginMethodPassword
+	username, password, ok := r.BasicAuth()
+	tlsCert := s.getClientCertificate(r)
+	if tlsCert != nil {
+		if ok && password != "" {
+			loginMethod = dataprovider.LoginMethodTLSCertificateAndPwd
+		} else {
+			loginMethod = dataprovider.LoginMethodTLSCertificate
+			if username == "" {
+				username = tlsCert.Subject.CommonName
+			}
+			password = ""
+		}
+		ok = true
+	}
+	return username, password, loginMethod, tlsCert, ok
+}
+
 func (s *httpdServer) refreshCookie(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		s.checkCookieExpiration(w, r)
@@ -174,8 +219,18 @@ func (s *httpdServer) renderClientLoginPage(w http.ResponseWriter, r *http.Reque
 		Branding:       s.binding.Branding.WebClient,
 		FormDisabled:   s.binding.isWebClientLoginFormDisabled(),
 	}
+	query := make(url.Values)
 	if next := r.URL.Query().Get("next"); strings.HasPrefix(next, webClientFilesPath) {
-		data.CurrentURL += "?next=" + url.QueryEscape(next)
+		query.Set("next", next)
+	}
+	if tenant := r.URL.Query().Get("tenant"); tenant != "" {
+		data.Branding, data.CustomBranding = s.getWebClientBranding(tenant)
+		if data.CustomBranding != nil {
+			query.Set("tenant", tenant)
+		}
+	}
+	if len(query) > 0 {
+		data.CurrentURL += "?" + query.Encode()
 	}
 	if s.binding.showAdminLoginURL() {
 		data.AltLoginURL = webAdminLoginPath
@@ -187,11 +242,18 @@ func (s *httpdServer) renderClientLoginPage(w http.ResponseWriter, r *http.Reque
 	if s.binding.OIDC.isEnabled() && !s.binding.isWebClientOIDCLoginDisabled() {
 		data.OpenIDLoginURL = webClientOIDCLoginPath
 	}
+	if mfa.IsWebAuthnEnabled() && !data.FormDisabled {
+		data.WebAuthnURL = webClientWebAuthnPath + "/login"
+	}
+	data.CertLogin = !data.FormDisabled && s.getClientCertificate(r) != nil
 	renderClientTemplate(w, templateCommonLogin, data)
 }
 
 func (s *httpdServer) handleWebClientLogout(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
+	if impersonatedBy, _ := getImpersonationFromToken(r); impersonatedBy != "" {
+		logger.Info(logSender, "", "admin %q stopped impersonating user %q", impersonatedBy, getUserFromToken(r).Username)
+	}
 	c := jwtTokenClaims{}
 	c.removeCookie(w, r, webBaseClientPath)
 	s.logoutOIDCUser(w, r)
@@ -239,37 +301,50 @@ func (s *httpdServer) handleWebClientLoginPost(w http.ResponseWriter, r *http.Re
 	protocol := common.ProtocolHTTP
 	username := strings.TrimSpace(r.Form.Get("username"))
 	password := strings.TrimSpace(r.Form.Get("password"))
-	if username == "" || password == "" {
+	loginMethod := dataprovider.LoginMethodPassword
+	tlsCert := s.getClientCertificate(r)
+	if tlsCert != nil {
+		if r.Form.Get("login_method") == webLoginMethodCertificate {
+			loginMethod = dataprovider.LoginMethodTLSCertificate
+			password = ""
+			if username == "" {
+				username = tlsCert.Subject.CommonName
+			}
+		} else {
+			loginMethod = dataprovider.LoginMethodTLSCertificateAndPwd
+		}
+	}
+	if username == "" || (password == "" && loginMethod != dataprovider.LoginMethodTLSCertificate) {
 		updateLoginMetrics(&dataprovider.User{BaseUser: sdk.BaseUser{Username: username}},
-			dataprovider.LoginMethodPassword, ipAddr, common.ErrNoCredentials)
+			loginMethod, ipAddr, common.ErrNoCredentials)
 		s.renderClientLoginPage(w, r,
 			util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials), ipAddr)
 		return
 	}
 	if err := verifyCSRFToken(r.Form.Get(csrfFormToken), ipAddr); err != nil {
 		updateLoginMetrics(&dataprovider.User{BaseUser: sdk.BaseUser{Username: username}},
-			dataprovider.LoginMethodPassword, ipAddr, err)
+			loginMethod, ipAddr, err)
 		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF), ipAddr)
 		return
 	}
 
 	if err := common.Config.ExecutePostConnectHook(ipAddr, protocol); err != nil {
 		updateLoginMetrics(&dataprovider.User{BaseUser: sdk.BaseUser{Username: username}},
-			dataprovider.LoginMethodPassword, ipAddr, err)
+			loginMethod, ipAddr, err)
 		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nError403Message), ipAddr)
 		return
 	}
 
-	user, err := dataprovider.CheckUserAndPass(username, password, ipAddr, protocol)
+	user, loginMethod, err := dataprovider.CheckCompositeCredentials(username, password, ipAddr, loginMethod, protocol, tlsCert)
 	if err != nil {
-		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, err)
+		updateLoginMetrics(&user, loginMethod, ipAddr, err)
 		s.renderClientLoginPage(w, r,
 			util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials), ipAddr)
 		return
 	}
 	connectionID := fmt.Sprintf("%v_%v", protocol, xid.New().String())
 	if err := checkHTTPClientUser(&user, r, connectionID, true); err != nil {
-		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, err)
+		updateLoginMetrics(&user, loginMethod, ipAddr, err)
 		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nError403Message), ipAddr)
 		return
 	}
@@ -278,7 +353,7 @@ func (s *httpdServer) handleWebClientLoginPost(w http.ResponseWriter, r *http.Re
 	err = user.CheckFsRoot(connectionID)
 	if err != nil {
 		logger.Warn(logSender, connectionID, "unable to check fs root: %v", err)
-		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, common.ErrInternalFailure)
+		updateLoginMetrics(&user, loginMethod, ipAddr, common.ErrInternalFailure)
 		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nErrorFsGeneric), ipAddr)
 		return
 	}
@@ -354,7 +429,8 @@ func (s *httpdServer) handleWebClientTwoFactorRecoveryPost(w http.ResponseWriter
 			util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials), ipAddr)
 		return
 	}
-	if !userMerged.Filters.TOTPConfig.Enabled || !util.Contains(userMerged.Filters.TOTPConfig.Protocols, common.ProtocolHTTP) {
+	if (!userMerged.Filters.TOTPConfig.Enabled || !util.Contains(userMerged.Filters.TOTPConfig.Protocols, common.ProtocolHTTP)) &&
+		!userMerged.HasWebAuthn() {
 		s.renderClientTwoFactorPage(w, r, util.NewI18nError(
 			util.NewValidationError("two factory authentication is not enabled"), util.I18n2FADisabled), ipAddr)
 		return
@@ -478,7 +554,7 @@ func (s *httpdServer) handleWebAdminTwoFactorRecoveryPost(w http.ResponseWriter,
 			ipAddr)
 		return
 	}
-	if !admin.Filters.TOTPConfig.Enabled {
+	if !admin.Filters.TOTPConfig.Enabled && !admin.HasWebAuthn() {
 		s.renderTwoFactorRecoveryPage(w, r, util.NewI18nError(util.NewValidationError("two factory authentication is not enabled"), util.I18n2FADisabled), ipAddr)
 		return
 	}
@@ -545,6 +621,11 @@ func (s *httpdServer) handleWebAdminTwoFactorPost(w http.ResponseWriter, r *http
 		s.renderTwoFactorPage(w, r, util.NewI18nError(common.ErrInternalFailure, util.I18n2FADisabled), ipAddr)
 		return
 	}
+	if admin.MustUseWebAuthn() {
+		s.renderTwoFactorPage(w, r, util.NewI18nError(errors.New("a security key is required"), util.I18nErrorWebAuthnRequired),
+			ipAddr)
+		return
+	}
 	err = admin.Filters.TOTPConfig.Secret.Decrypt()
 	if err != nil {
 		s.renderInternalServerErrorPage(w, r, err)
@@ -571,7 +652,15 @@ func (s *httpdServer) handleWebAdminLoginPost(w http.ResponseWriter, r *http.Req
 	}
 	username := strings.TrimSpace(r.Form.Get("username"))
 	password := strings.TrimSpace(r.Form.Get("password"))
-	if username == "" || password == "" {
+	// as for the REST API, a client certificate without a password is a certificate login
+	tlsCert := s.getClientCertificate(r)
+	if tlsCert != nil && (password == "" || r.Form.Get("login_method") == webLoginMethodCertificate) {
+		password = ""
+		if username == "" {
+			username = tlsCert.Subject.CommonName
+		}
+	}
+	if username == "" || (password == "" && tlsCert == nil) {
 		s.renderAdminLoginPage(w, r, util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials),
 			ipAddr)
 		return
@@ -580,7 +669,7 @@ func (s *httpdServer) handleWebAdminLoginPost(w http.ResponseWriter, r *http.Req
 		s.renderAdminLoginPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF), ipAddr)
 		return
 	}
-	admin, err := dataprovider.CheckAdminAndPass(username, password, ipAddr)
+	admin, err := dataprovider.CheckAdminCredentials(username, password, ipAddr, tlsCert)
 	if err != nil {
 		handleDefenderEventLoginFailed(ipAddr, err) //nolint:errcheck
 		s.renderAdminLoginPage(w, r, util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials),
@@ -610,6 +699,10 @@ func (s *httpdServer) renderAdminLoginPage(w http.ResponseWriter, r *http.Reques
 	if s.binding.OIDC.hasRoles() && !s.binding.isWebAdminOIDCLoginDisabled() {
 		data.OpenIDLoginURL = webAdminOIDCLoginPath
 	}
+	if mfa.IsWebAuthnEnabled() && !data.FormDisabled {
+		data.WebAuthnURL = webAdminWebAuthnPath + "/login"
+	}
+	data.CertLogin = !data.FormDisabled && s.getClientCertificate(r) != nil
 	renderAdminTemplate(w, templateCommonLogin, data)
 }
 
@@ -738,6 +831,21 @@ func (s *httpdServer) loginUser(
 	w http.ResponseWriter, r *http.Request, user *dataprovider.User, connectionID, ipAddr string,
 	isSecondFactorAuth bool, errorFunc func(w http.ResponseWriter, r *http.Request, err *util.I18nError, ip string),
 ) {
+	pushApproved := false
+	pushFallback := false
+	if !isSecondFactorAuth && user.Filters.PushMFA.IsEnabledForProtocol(common.ProtocolHTTP) {
+		err := dataprovider.CheckPushSecondFactor(user, ipAddr, common.ProtocolHTTP)
+		if err == nil {
+			pushApproved = true
+		} else if dataprovider.CanFallbackToTOTP(user, common.ProtocolHTTP, err) {
+			pushFallback = true
+		} else {
+			handleDefenderEventLoginFailed(ipAddr, err) //nolint:errcheck
+			updateLoginMetrics(user, dataprovider.LoginMethodPassword, ipAddr, dataprovider.ErrInvalidCredentials)
+			errorFunc(w, r, util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorPushMFAFailed), ipAddr)
+			return
+		}
+	}
 	c := jwtTokenClaims{
 		Username:                   user.Username,
 		Permissions:                user.Filters.WebClient,
@@ -749,10 +857,13 @@ func (s *httpdServer) loginUser(
 	}
 
 	audience := tokenAudienceWebClient
-	if user.Filters.TOTPConfig.Enabled && util.Contains(user.Filters.TOTPConfig.Protocols, common.ProtocolHTTP) &&
-		user.CanManageMFA() && !isSecondFactorAuth {
+	if pushFallback || (user.Filters.TOTPConfig.Enabled && util.Contains(user.Filters.TOTPConfig.Protocols, common.ProtocolHTTP) ||
+		user.HasWebAuthn()) && user.CanManageMFA() && !isSecondFactorAuth && !pushApproved {
 		audience = tokenAudienceWebClientPartial
 	}
+	if audience == tokenAudienceWebClient {
+		startAuthSession(&c, false, authSessionTypeWeb, ipAddr, time.Now().Add(tokenDuration))
+	}
 
 	err := c.createAndSetCookie(w, r, s.tokenAuth, audience, ipAddr)
 	if err != nil {
@@ -788,16 +899,20 @@ func (s *httpdServer) loginAdmin(
 ) {
 	c := jwtTokenClaims{
 		Username:             admin.Username,
-		Permissions:          admin.Permissions,
+		Permissions:          admin.GetEffectivePermissions(),
+		UserSelectors:        admin.Filters.UserSelectors,
 		Role:                 admin.Role,
 		Signature:            admin.GetSignature(),
 		HideUserPageSections: admin.Filters.Preferences.HideUserPageSections,
 	}
 
 	audience := tokenAudienceWebAdmin
-	if admin.Filters.TOTPConfig.Enabled && admin.CanManageMFA() && !isSecondFactorAuth {
+	if (admin.Filters.TOTPConfig.Enabled || admin.HasWebAuthn()) && admin.CanManageMFA() && !isSecondFactorAuth {
 		audience = tokenAudienceWebAdminPartial
 	}
+	if audience == tokenAudienceWebAdmin {
+		startAuthSession(&c, true, authSessionTypeWeb, ipAddr, time.Now().Add(tokenDuration))
+	}
 
 	err := c.createAndSetCookie(w, r, s.tokenAuth, audience, ipAddr)
 	if err != nil {
@@ -829,56 +944,66 @@ func (s *httpdServer) logout(w http.ResponseWriter, r *http.Request) {
 func (s *httpdServer) getUserToken(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
 	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
-	username, password, ok := r.BasicAuth()
+	username, password, loginMethod, tlsCert, ok := s.getCredentialsAndLoginMethod(r)
 	protocol := common.ProtocolHTTP
 	if !ok {
 		updateLoginMetrics(&dataprovider.User{BaseUser: sdk.BaseUser{Username: username}},
-			dataprovider.LoginMethodPassword, ipAddr, common.ErrNoCredentials)
+			loginMethod, ipAddr, common.ErrNoCredentials)
 		w.Header().Set(common.HTTPAuthenticationHeader, basicRealm)
 		sendAPIResponse(w, r, nil, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
-	if username == "" || password == "" {
+	if username == "" || (password == "" && loginMethod != dataprovider.LoginMethodTLSCertificate) {
 		updateLoginMetrics(&dataprovider.User{BaseUser: sdk.BaseUser{Username: username}},
-			dataprovider.LoginMethodPassword, ipAddr, common.ErrNoCredentials)
+			loginMethod, ipAddr, common.ErrNoCredentials)
 		w.Header().Set(common.HTTPAuthenticationHeader, basicRealm)
 		sendAPIResponse(w, r, nil, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
 	if err := common.Config.ExecutePostConnectHook(ipAddr, protocol); err != nil {
 		updateLoginMetrics(&dataprovider.User{BaseUser: sdk.BaseUser{Username: username}},
-			dataprovider.LoginMethodPassword, ipAddr, err)
+			loginMethod, ipAddr, err)
 		sendAPIResponse(w, r, err, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 		return
 	}
-	user, err := dataprovider.CheckUserAndPass(username, password, ipAddr, protocol)
+	user, loginMethod, err := dataprovider.CheckCompositeCredentials(username, password, ipAddr, loginMethod, protocol, tlsCert)
 	if err != nil {
 		w.Header().Set(common.HTTPAuthenticationHeader, basicRealm)
-		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, err)
+		updateLoginMetrics(&user, loginMethod, ipAddr, err)
 		sendAPIResponse(w, r, dataprovider.ErrInvalidCredentials, http.StatusText(http.StatusUnauthorized),
 			http.StatusUnauthorized)
 		return
 	}
 	connectionID := fmt.Sprintf("%v_%v", protocol, xid.New().String())
 	if err := checkHTTPClientUser(&user, r, connectionID, true); err != nil {
-		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, err)
+		updateLoginMetrics(&user, loginMethod, ipAddr, err)
 		sendAPIResponse(w, r, err, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 		return
 	}
 
-	if user.Filters.TOTPConfig.Enabled && util.Contains(user.Filters.TOTPConfig.Protocols, common.ProtocolHTTP) {
+	totpEnabled := user.Filters.TOTPConfig.Enabled && util.Contains(user.Filters.TOTPConfig.Protocols, common.ProtocolHTTP)
+	// an explicit authentication code takes precedence over push authentication
+	if user.Filters.PushMFA.IsEnabledForProtocol(common.ProtocolHTTP) && (!totpEnabled || r.Header.Get(otpHeaderCode) == "") {
+		if err := dataprovider.CheckPushSecondFactor(&user, ipAddr, protocol); err != nil {
+			w.Header().Set(common.HTTPAuthenticationHeader, basicRealm)
+			updateLoginMetrics(&user, loginMethod, ipAddr, dataprovider.ErrInvalidCredentials)
+			sendAPIResponse(w, r, dataprovider.ErrInvalidCredentials, http.StatusText(http.StatusUnauthorized),
+				http.StatusUnauthorized)
+			return
+		}
+	} else if totpEnabled {
 		passcode := r.Header.Get(otpHeaderCode)
 		if passcode == "" {
 			logger.Debug(logSender, "", "TOTP enabled for user %q and not passcode provided, authentication refused", user.Username)
 			w.Header().Set(common.HTTPAuthenticationHeader, basicRealm)
-			updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, dataprovider.ErrInvalidCredentials)
+			updateLoginMetrics(&user, loginMethod, ipAddr, dataprovider.ErrInvalidCredentials)
 			sendAPIResponse(w, r, dataprovider.ErrInvalidCredentials, http.StatusText(http.StatusUnauthorized),
 				http.StatusUnauthorized)
 			return
 		}
 		err = user.Filters.TOTPConfig.Secret.Decrypt()
 		if err != nil {
-			updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, common.ErrInternalFailure)
+			updateLoginMetrics(&user, loginMethod, ipAddr, common.ErrInternalFailure)
 			sendAPIResponse(w, r, fmt.Errorf("unable to decrypt TOTP secret: %w", err), http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return
 		}
@@ -887,7 +1012,7 @@ func (s *httpdServer) getUserToken(w http.ResponseWriter, r *http.Request) {
 		if !match || err != nil {
 			logger.Debug(logSender, "invalid passcode for user %q, match? %v, err: %v", user.Username, match, err)
 			w.Header().Set(common.HTTPAuthenticationHeader, basicRealm)
-			updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, dataprovider.ErrInvalidCredentials)
+			updateLoginMetrics(&user, loginMethod, ipAddr, dataprovider.ErrInvalidCredentials)
 			sendAPIResponse(w, r, dataprovider.ErrInvalidCredentials, http.StatusText(http.StatusUnauthorized),
 				http.StatusUnauthorized)
 			return
@@ -898,7 +1023,7 @@ func (s *httpdServer) getUserToken(w http.ResponseWriter, r *http.Request) {
 	err = user.CheckFsRoot(connectionID)
 	if err != nil {
 		logger.Warn(logSender, connectionID, "unable to check fs root: %v", err)
-		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, common.ErrInternalFailure)
+		updateLoginMetrics(&user, loginMethod, ipAddr, common.ErrInternalFailure)
 		sendAPIResponse(w, r, err, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
@@ -916,6 +1041,7 @@ func (s *httpdServer) generateAndSendUserToken(w http.ResponseWriter, r *http.Re
 		MustChangePassword:         user.MustChangePassword(),
 		RequiredTwoFactorProtocols: user.Filters.TwoFactorAuthProtocols,
 	}
+	startAuthSession(&c, false, authSessionTypeAPI, ipAddr, time.Now().Add(tokenDuration))
 
 	resp, err := c.createTokenResponse(s.tokenAuth, tokenAudienceAPIUser, ipAddr)
 	if err != nil {
@@ -930,20 +1056,26 @@ func (s *httpdServer) generateAndSendUserToken(w http.ResponseWriter, r *http.Re
 }
 
 func (s *httpdServer) getToken(w http.ResponseWriter, r *http.Request) {
-	username, password, ok := r.BasicAuth()
+	username, password, _, tlsCert, ok := s.getCredentialsAndLoginMethod(r)
 	if !ok {
 		w.Header().Set(common.HTTPAuthenticationHeader, basicRealm)
 		sendAPIResponse(w, r, nil, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
 	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
-	admin, err := dataprovider.CheckAdminAndPass(username, password, ipAddr)
+	admin, err := dataprovider.CheckAdminCredentials(username, password, ipAddr, tlsCert)
 	if err != nil {
 		err = handleDefenderEventLoginFailed(ipAddr, err)
 		w.Header().Set(common.HTTPAuthenticationHeader, basicRealm)
 		sendAPIResponse(w, r, err, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 		return
 	}
+	if admin.MustUseWebAuthn() {
+		logger.Debug(logSender, "", "WebAuthn required for admin %q, token authentication refused", admin.Username)
+		sendAPIResponse(w, r, errors.New("a security key is required, use the WebAdmin or an API key"),
+			http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
 	if admin.Filters.TOTPConfig.Enabled {
 		passcode := r.Header.Get(otpHeaderCode)
 		if passcode == "" {
@@ -976,11 +1108,13 @@ func (s *httpdServer) getToken(w http.ResponseWriter, r *http.Request) {
 
 func (s *httpdServer) generateAndSendToken(w http.ResponseWriter, r *http.Request, admin dataprovider.Admin, ip string) {
 	c := jwtTokenClaims{
-		Username:    admin.Username,
-		Permissions: admin.Permissions,
-		Role:        admin.Role,
-		Signature:   admin.GetSignature(),
+		Username:      admin.Username,
+		Permissions:   admin.GetEffectivePermissions(),
+		UserSelectors: admin.Filters.UserSelectors,
+		Role:          admin.Role,
+		Signature:     admin.GetSignature(),
 	}
+	startAuthSession(&c, true, authSessionTypeAPI, ip, time.Now().Add(tokenDuration))
 
 	resp, err := c.createTokenResponse(s.tokenAuth, tokenAudienceAPI, ip)
 
@@ -1031,8 +1165,16 @@ func (s *httpdServer) refreshClientToken(w http.ResponseWriter, r *http.Request,
 	}
 
 	tokenClaims.Permissions = user.Filters.WebClient
+	if tokenClaims.ImpersonatedBy != "" {
+		if err := checkImpersonationAdmin(tokenClaims.ImpersonatedBy, &user, r); err != nil {
+			logger.Debug(logSender, "", "unable to refresh impersonated session cookie for user %q: %v", user.Username, err)
+			return
+		}
+		tokenClaims.Permissions = getImpersonationPermissions(user.Filters.WebClient, tokenClaims.ImpersonationReadOnly)
+	}
 	tokenClaims.Role = user.Role
 	logger.Debug(logSender, "", "cookie refreshed for user %q", user.Username)
+	touchAuthSession(tokenClaims.SessionID, time.Now().Add(tokenDuration))
 	tokenClaims.createAndSetCookie(w, r, s.tokenAuth, tokenAudienceWebClient, util.GetIPFromRemoteAddress(r.RemoteAddr)) //nolint:errcheck
 }
 
@@ -1054,10 +1196,12 @@ func (s *httpdServer) refreshAdminToken(w http.ResponseWriter, r *http.Request,
 		logger.Debug(logSender, "", "admin %q cannot login from %v, unable to refresh cookie", admin.Username, r.RemoteAddr)
 		return
 	}
-	tokenClaims.Permissions = admin.Permissions
+	tokenClaims.Permissions = admin.GetEffectivePermissions()
+	tokenClaims.UserSelectors = admin.Filters.UserSelectors
 	tokenClaims.Role = admin.Role
 	tokenClaims.HideUserPageSections = admin.Filters.Preferences.HideUserPageSections
 	logger.Debug(logSender, "", "cookie refreshed for admin %q", admin.Username)
+	touchAuthSession(tokenClaims.SessionID, time.Now().Add(tokenDuration))
 	tokenClaims.createAndSetCookie(w, r, s.tokenAuth, tokenAudienceWebAdmin, ipAddr) //nolint:errcheck
 }
 
@@ -1221,6 +1365,13 @@ func (s *httpdServer) mustStripSlash(r *http.Request) bool {
 		!strings.HasPrefix(urlPath, webStaticFilesPath) && !strings.HasPrefix(urlPath, acmeChallengeURI)
 }
 
+func (s *httpdServer) initializePublicIndexRouter() {
+	s.router.Get(healthzPath, func(w http.ResponseWriter, r *http.Request) {
+		render.PlainText(w, r, "ok")
+	})
+	s.router.Get("/*", s.servePublicIndex)
+}
+
 func (s *httpdServer) initializeRouter() {
 	var hasHTTPSRedirect bool
 	s.tokenAuth = jwtauth.New(jwa.HS256.String(), getSigningKey(s.signingPassphrase), nil)
@@ -1231,6 +1382,9 @@ func (s *httpdServer) initializeRouter() {
 	s.router.Use(logger.NewStructuredLogger(logger.GetLogger()))
 	s.router.Use(middleware.Recoverer)
 	s.router.Use(s.checkConnection)
+	if s.binding.ReadOnly {
+		s.router.Use(markReadOnlyBinding)
+	}
 	if s.binding.Security.Enabled {
 		secureMiddleware := secure.New(secure.Options{
 			AllowedHosts:            s.binding.Security.AllowedHosts,
@@ -1267,6 +1421,10 @@ func (s *httpdServer) initializeRouter() {
 		s.router.Use(c.Handler)
 	}
 	s.router.Use(middleware.GetHead)
+	if s.binding.PublicIndex.isEnabled() {
+		s.initializePublicIndexRouter()
+		return
+	}
 	s.router.Use(middleware.Maybe(middleware.StripSlashes, s.mustStripSlash))
 
 	s.router.NotFound(s.notFoundHandler)
@@ -1294,6 +1452,11 @@ func (s *httpdServer) initializeRouter() {
 		s.router.Get(sharesPath+"/{id}/files", s.downloadBrowsableSharedFile)
 
 		s.router.Get(tokenPath, s.getToken)
+		if s.binding.OIDC.isDeviceAuthEnabled() {
+			s.router.Post(oidcDeviceAuthPath, s.startOIDCDeviceAuth)
+			s.router.Post(oidcDeviceTokenPath, s.getOIDCDeviceToken)
+			s.router.Post(oidcRefreshTokenPath, s.refreshOIDCDeviceToken)
+		}
 		s.router.Post(adminPath+"/{username}/forgot-password", forgotAdminPassword)
 		s.router.Post(adminPath+"/{username}/reset-password", resetAdminPassword)
 		s.router.Post(userPath+"/{username}/forgot-password", forgotUserPassword)
@@ -1301,6 +1464,7 @@ func (s *httpdServer) initializeRouter() {
 
 		s.router.Group(func(router chi.Router) {
 			router.Use(checkNodeToken(s.tokenAuth))
+			router.Use(checkExternalJWT(s.tokenAuth))
 			router.Use(checkAPIKeyAuth(s.tokenAuth, dataprovider.APIKeyScopeAdmin))
 			router.Use(jwtauth.Verify(s.tokenAuth, jwtauth.TokenFromHeader))
 			router.Use(jwtAuthenticatorAPI)
@@ -1314,6 +1478,9 @@ func (s *httpdServer) initializeRouter() {
 			router.With(forbidAPIKeyAuthentication).Get(adminProfilePath, getAdminProfile)
 			router.With(forbidAPIKeyAuthentication).Put(adminProfilePath, updateAdminProfile)
 			router.With(forbidAPIKeyAuthentication).Put(adminPwdPath, changeAdminPassword)
+			router.With(forbidAPIKeyAuthentication).Get(adminSessionsPath, getMyAuthSessions)
+			router.With(forbidAPIKeyAuthentication).Delete(adminSessionsPath, revokeMyAuthSessions)
+			router.With(forbidAPIKeyAuthentication).Delete(adminSessionsPath+"/{id}", revokeMyAuthSession)
 			// admin TOTP APIs
 			router.With(forbidAPIKeyAuthentication).Get(adminTOTPConfigsPath, getTOTPConfigs)
 			router.With(forbidAPIKeyAuthentication).Post(adminTOTPGeneratePath, generateTOTPSecret)
@@ -1328,32 +1495,62 @@ func (s *httpdServer) initializeRouter() {
 					render.JSON(w, r, getServicesStatus())
 				})
 
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus)).Get(dashboardPath+"/stats", getDashboardStats)
+			if graphQLEnabled {
+				router.Post(graphQLPath, handleGraphQL)
+			}
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus), s.checkPerm(dataprovider.PermAdminViewUsers)).
+				Get(dashboardPath+"/quota", getDashboardQuotaStats)
 			router.With(s.checkPerm(dataprovider.PermAdminViewConnections)).Get(activeConnectionsPath, getActiveConnections)
 			router.With(s.checkPerm(dataprovider.PermAdminCloseConnections)).
 				Delete(activeConnectionsPath+"/{connectionID}", handleCloseConnection)
+			router.With(s.checkPerm(dataprovider.PermAdminViewConnections)).Get(connectionDrainsPath, getDrainTargets)
+			router.With(s.checkPerm(dataprovider.PermAdminCloseConnections)).Post(connectionDrainsPath, addDrainTarget)
+			router.With(s.checkPerm(dataprovider.PermAdminCloseConnections)).
+				Delete(connectionDrainsPath+"/{type}/{name}", removeDrainTarget)
 			router.With(s.checkPerm(dataprovider.PermAdminQuotaScans)).Get(quotasBasePath+"/users/scans", getUsersQuotaScans)
 			router.With(s.checkPerm(dataprovider.PermAdminQuotaScans)).Post(quotasBasePath+"/users/{username}/scan", startUserQuotaScan)
 			router.With(s.checkPerm(dataprovider.PermAdminQuotaScans)).Get(quotasBasePath+"/folders/scans", getFoldersQuotaScans)
 			router.With(s.checkPerm(dataprovider.PermAdminQuotaScans)).Post(quotasBasePath+"/folders/{name}/scan", startFolderQuotaScan)
 			router.With(s.checkPerm(dataprovider.PermAdminViewUsers)).Get(userPath, getUsers)
 			router.With(s.checkPerm(dataprovider.PermAdminAddUsers)).Post(userPath, addUser)
+			router.With(s.checkPerm(dataprovider.PermAdminAddUsers)).Post(userTemplatePath, addUsersFromTemplate)
+			router.Post(userBatchPath, executeUsersBatch)
 			router.With(s.checkPerm(dataprovider.PermAdminViewUsers)).Get(userPath+"/{username}", getUserByUsername) //nolint:goconst
 			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).Put(userPath+"/{username}", updateUser)
 			router.With(s.checkPerm(dataprovider.PermAdminDeleteUsers)).Delete(userPath+"/{username}", deleteUser)
 			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).Put(userPath+"/{username}/2fa/disable", disableUser2FA)
-			router.With(s.checkPerm(dataprovider.PermAdminManageFolders)).Get(folderPath, getFolders)
-			router.With(s.checkPerm(dataprovider.PermAdminManageFolders)).Get(folderPath+"/{name}", getFolderByName) //nolint:goconst
+			router.With(s.checkPerm(dataprovider.PermAdminViewUsers)).Get(userPath+"/{username}/sessions", getUserAuthSessions)
+			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).
+				Delete(userPath+"/{username}/sessions", revokeUserAuthSessions)
+			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).
+				Delete(userPath+"/{username}/sessions/{id}", revokeUserAuthSession)
+			router.With(s.checkPerm(dataprovider.PermAdminViewFolders)).Get(folderPath, getFolders)
+			router.With(s.checkPerm(dataprovider.PermAdminViewFolders)).Get(folderPath+"/{name}", getFolderByName) //nolint:goconst
 			router.With(s.checkPerm(dataprovider.PermAdminManageFolders)).Post(folderPath, addFolder)
 			router.With(s.checkPerm(dataprovider.PermAdminManageFolders)).Put(folderPath+"/{name}", updateFolder)
 			router.With(s.checkPerm(dataprovider.PermAdminManageFolders)).Delete(folderPath+"/{name}", deleteFolder)
-			router.With(s.checkPerm(dataprovider.PermAdminManageGroups)).Get(groupPath, getGroups)
-			router.With(s.checkPerm(dataprovider.PermAdminManageGroups)).Get(groupPath+"/{name}", getGroupByName)
+			router.With(s.checkPerm(dataprovider.PermAdminManageFolders)).Post(folderBatchPath, executeFoldersBatch)
+			router.With(s.checkPerm(dataprovider.PermAdminViewGroups)).Get(groupPath, getGroups)
+			router.With(s.checkPerm(dataprovider.PermAdminViewGroups)).Get(groupPath+"/{name}", getGroupByName)
 			router.With(s.checkPerm(dataprovider.PermAdminManageGroups)).Post(groupPath, addGroup)
 			router.With(s.checkPerm(dataprovider.PermAdminManageGroups)).Put(groupPath+"/{name}", updateGroup)
 			router.With(s.checkPerm(dataprovider.PermAdminManageGroups)).Delete(groupPath+"/{name}", deleteGroup)
 			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Get(dumpDataPath, dumpData)
 			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Get(loadDataPath, loadData)
 			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Post(loadDataPath, loadDataFromRequest)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Get(remoteBackupsPath+"/{name}", getRemoteBackups)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).
+				Post(remoteBackupsPath+"/{name}/{backup}/restore", restoreRemoteBackup)
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus)).Get(reconcilerPath, getReconcilerStatus)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Post(reconcilerPath+"/run", runReconciler)
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus)).Get(kmsPath+"/status", getKMSStatus)
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus)).Get(kmsPath+"/rekey", getRekeyStatus)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Post(kmsPath+"/rekey", startRekey)
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus)).Get(passwordsMigrationPath,
+				getPasswordMigrationStatus)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Post(passwordsMigrationPath,
+				startPasswordMigrationCheck)
 			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).Put(quotasBasePath+"/users/{username}/usage",
 				updateUserQuotaUsage)
 			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).Put(quotasBasePath+"/users/{username}/transfer-usage",
@@ -1363,24 +1560,48 @@ func (s *httpdServer) initializeRouter() {
 			router.With(s.checkPerm(dataprovider.PermAdminViewDefender)).Get(defenderHosts, getDefenderHosts)
 			router.With(s.checkPerm(dataprovider.PermAdminViewDefender)).Get(defenderHosts+"/{id}", getDefenderHostByID)
 			router.With(s.checkPerm(dataprovider.PermAdminManageDefender)).Delete(defenderHosts+"/{id}", deleteDefenderHostByID)
-			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).Get(adminPath, getAdmins)
+			router.With(s.checkPerm(dataprovider.PermAdminViewAdmins)).Get(adminPath, getAdmins)
 			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).Post(adminPath, addAdmin)
-			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).Get(adminPath+"/{username}", getAdminByUsername)
+			router.With(s.checkPerm(dataprovider.PermAdminViewAdmins)).Get(adminPath+"/{username}", getAdminByUsername)
 			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).Put(adminPath+"/{username}", updateAdmin)
 			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).Delete(adminPath+"/{username}", deleteAdmin)
 			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).Put(adminPath+"/{username}/2fa/disable", disableAdmin2FA)
+			router.With(s.checkPerm(dataprovider.PermAdminViewAdmins)).Get(adminPath+"/{username}/sessions", getAdminAuthSessions)
+			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).
+				Delete(adminPath+"/{username}/sessions", revokeAdminAuthSessions)
+			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).
+				Delete(adminPath+"/{username}/sessions/{id}", revokeAdminAuthSession)
 			router.With(s.checkPerm(dataprovider.PermAdminRetentionChecks)).Get(retentionChecksPath, getRetentionChecks)
 			router.With(s.checkPerm(dataprovider.PermAdminRetentionChecks)).Post(retentionBasePath+"/{username}/check",
 				startRetentionCheck)
 			router.With(s.checkPerm(dataprovider.PermAdminMetadataChecks)).Get(metadataChecksPath, getMetadataChecks)
 			router.With(s.checkPerm(dataprovider.PermAdminMetadataChecks)).Post(metadataBasePath+"/{username}/check",
 				startMetadataCheck)
+			router.With(s.checkPerm(dataprovider.PermAdminViewUsers)).Get(keyRotationsPath, getKeyRotations)
+			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).Post(keyRotationBasePath+"/{username}/rotate",
+				startKeyRotation)
+			router.With(s.checkPerm(dataprovider.PermAdminViewUsers)).Get(snapshotsBasePath+"/{username}", getUserSnapshots)
+			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).Post(snapshotsBasePath+"/{username}",
+				createUserSnapshot)
+			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).Delete(snapshotsBasePath+"/{username}/{id}",
+				deleteUserSnapshot)
+			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).Post(snapshotsBasePath+"/{username}/{id}/restore",
+				restoreUserSnapshot)
 			router.With(s.checkPerm(dataprovider.PermAdminViewEvents), compressor.Handler).
 				Get(fsEventsPath, searchFsEvents)
 			router.With(s.checkPerm(dataprovider.PermAdminViewEvents), compressor.Handler).
 				Get(providerEventsPath, searchProviderEvents)
 			router.With(s.checkPerm(dataprovider.PermAdminViewEvents), compressor.Handler).
 				Get(logEventsPath, searchLogEvents)
+			router.With(s.checkPerm(dataprovider.PermAdminViewEvents), compressor.Handler).
+				Get(auditLogsPath, searchAuditLogs)
+			router.With(s.checkPerm(dataprovider.PermAdminViewEvents)).Get(eventsStreamPath, streamEvents)
+			router.With(s.checkPerm(dataprovider.PermAdminViewEvents), compressor.Handler).
+				Get(webhookDeliveriesPath, getWebhookDeliveries)
+			router.With(s.checkPerm(dataprovider.PermAdminViewEvents)).
+				Get(webhookDeliveriesPath+"/{id}", getWebhookDeliveryByID)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).
+				Post(webhookDeliveriesPath+"/{id}/retry", retryWebhookDelivery)
 			router.With(forbidAPIKeyAuthentication, s.checkPerm(dataprovider.PermAdminManageAPIKeys)).
 				Get(apiKeysPath, getAPIKeys)
 			router.With(forbidAPIKeyAuthentication, s.checkPerm(dataprovider.PermAdminManageAPIKeys)).
@@ -1391,27 +1612,36 @@ func (s *httpdServer) initializeRouter() {
 				Put(apiKeysPath+"/{id}", updateAPIKey)
 			router.With(forbidAPIKeyAuthentication, s.checkPerm(dataprovider.PermAdminManageAPIKeys)).
 				Delete(apiKeysPath+"/{id}", deleteAPIKey)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Get(eventActionsPath, getEventActions)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Get(eventActionsPath+"/{name}", getEventActionByName)
+			router.With(forbidAPIKeyAuthentication, s.checkPerm(dataprovider.PermAdminManageAPIKeys)).
+				Post(apiKeysPath+"/{id}/rotate", rotateAPIKey)
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules)).Get(eventActionsPath, getEventActions)
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules)).Get(eventActionsPath+"/{name}", getEventActionByName)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Post(eventActionsPath, addEventAction)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Put(eventActionsPath+"/{name}", updateEventAction)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Delete(eventActionsPath+"/{name}", deleteEventAction)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Get(eventRulesPath, getEventRules)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Get(eventRulesPath+"/{name}", getEventRuleByName)
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules)).Get(eventRulesPath, getEventRules)
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules)).Get(eventRulesPath+"/{name}", getEventRuleByName)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Post(eventRulesPath, addEventRule)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Put(eventRulesPath+"/{name}", updateEventRule)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Delete(eventRulesPath+"/{name}", deleteEventRule)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Post(eventRulesPath+"/run/{name}", runOnDemandRule)
-			router.With(s.checkPerm(dataprovider.PermAdminManageRoles)).Get(rolesPath, getRoles)
+			router.With(s.checkPerm(dataprovider.PermAdminViewRoles)).Get(rolesPath, getRoles)
 			router.With(s.checkPerm(dataprovider.PermAdminManageRoles)).Post(rolesPath, addRole)
-			router.With(s.checkPerm(dataprovider.PermAdminManageRoles)).Get(rolesPath+"/{name}", getRoleByName)
+			router.With(s.checkPerm(dataprovider.PermAdminViewRoles)).Get(rolesPath+"/{name}", getRoleByName)
 			router.With(s.checkPerm(dataprovider.PermAdminManageRoles)).Put(rolesPath+"/{name}", updateRole)
 			router.With(s.checkPerm(dataprovider.PermAdminManageRoles)).Delete(rolesPath+"/{name}", deleteRole)
-			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists), compressor.Handler).Get(ipListsPath+"/{type}", getIPListEntries) //nolint:goconst
+			router.With(s.checkPerm(dataprovider.PermAdminViewAdmins)).Get(adminRolesPath, getAdminRoles)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Post(adminRolesPath, addAdminRole)
+			router.With(s.checkPerm(dataprovider.PermAdminViewAdmins)).Get(adminRolesPath+"/{name}", getAdminRoleByName)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Put(adminRolesPath+"/{name}", updateAdminRole)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Delete(adminRolesPath+"/{name}", deleteAdminRole)
+			router.With(s.checkPerm(dataprovider.PermAdminViewIPLists), compressor.Handler).Get(ipListsPath+"/{type}", getIPListEntries) //nolint:goconst
 			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists)).Post(ipListsPath+"/{type}", addIPListEntry)
-			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists)).Get(ipListsPath+"/{type}/{ipornet}", getIPListEntry) //nolint:goconst
+			router.With(s.checkPerm(dataprovider.PermAdminViewIPLists)).Get(ipListsPath+"/{type}/{ipornet}", getIPListEntry) //nolint:goconst
 			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists)).Put(ipListsPath+"/{type}/{ipornet}", updateIPListEntry)
 			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists)).Delete(ipListsPath+"/{type}/{ipornet}", deleteIPListEntry)
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus)).Get(hostCAPath, getHostCA)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem)).Post(hostCAPath+"/renew", renewHostCertificates)
 		})
 
 		s.router.Get(userTokenPath, s.getUserToken)
@@ -1426,6 +1656,14 @@ func (s *httpdServer) initializeRouter() {
 				Put(userPwdPath, changeUserPassword)
 			router.With(forbidAPIKeyAuthentication).Get(userProfilePath, getUserProfile)
 			router.With(forbidAPIKeyAuthentication, s.checkAuthRequirements).Put(userProfilePath, updateUserProfile)
+			router.With(forbidAPIKeyAuthentication).Get(userSessionsPath, getMyAuthSessions)
+			router.With(forbidAPIKeyAuthentication).Delete(userSessionsPath, revokeMyAuthSessions)
+			router.With(forbidAPIKeyAuthentication).Delete(userSessionsPath+"/{id}", revokeMyAuthSession)
+			router.With(forbidAPIKeyAuthentication).Get(userS3CredentialsPath, getUserS3Credentials)
+			router.With(forbidAPIKeyAuthentication, s.checkAuthRequirements).
+				Post(userS3CredentialsPath, generateUserS3Credentials)
+			router.With(forbidAPIKeyAuthentication, s.checkAuthRequirements).
+				Delete(userS3CredentialsPath, deleteUserS3Credentials)
 			// user TOTP APIs
 			router.With(forbidAPIKeyAuthentication, s.checkHTTPUserPerm(sdk.WebClientMFADisabled)).
 				Get(userTOTPConfigsPath, getTOTPConfigs)
@@ -1458,6 +1696,30 @@ func (s *httpdServer) initializeRouter() {
 				Post(userFileActionsPath+"/move", renameUserFsEntry)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
 				Post(userFileActionsPath+"/copy", copyUserFsEntry)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
+				Post(userFileActionsPath+"/tier", setUserFileAccessTier)
+			router.With(s.checkAuthRequirements).Get(userFileJobsPath, getUserFileJobs)
+			router.With(s.checkAuthRequirements).Post(userFileJobsPath, startUserAPIFileJob)
+			router.With(s.checkAuthRequirements).Get(userFileJobsPath+"/{id}", getUserFileJob)
+			router.With(s.checkAuthRequirements).Delete(userFileJobsPath+"/{id}", deleteUserFileJob)
+			router.With(s.checkAuthRequirements).Options(userTusUploadsPath, getTusOptions)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
+				Post(userTusUploadsPath, createTusUpload)
+			router.With(s.checkAuthRequirements).Head(userTusUploadsPath+"/{id}", getTusUploadOffset)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
+				Patch(userTusUploadsPath+"/{id}", uploadTusData)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
+				Delete(userTusUploadsPath+"/{id}", deleteTusUpload)
+			router.With(s.checkAuthRequirements).Get(userFileVersionsPath, getUserFileVersions)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
+				Post(userFileVersionsPath+"/restore", restoreUserFileVersion)
+			router.With(s.checkAuthRequirements).Get(userTrashPath, getUserTrash)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
+				Delete(userTrashPath, emptyUserTrash)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
+				Post(userTrashPath+"/{id}/restore", restoreUserTrashItem)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
+				Delete(userTrashPath+"/{id}", deleteUserTrashItem)
 			router.With(s.checkAuthRequirements).Post(userStreamZipPath, getUserFilesAsZipStream)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientSharesDisabled)).
 				Get(userSharesPath, getShares)
@@ -1469,10 +1731,17 @@ func (s *httpdServer) initializeRouter() {
 				Put(userSharesPath+"/{id}", updateShare)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientSharesDisabled)).
 				Delete(userSharesPath+"/{id}", deleteShare)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientSharesDisabled)).
+				Get(userSharesPath+"/{id}/downloads", getShareDownloads)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientSharesDisabled)).
+				Get(userSharesPath+"/{id}/downloads/stats", getShareDownloadStats)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
 				Post(userUploadFilePath, uploadUserFile)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled)).
 				Patch(userFilesDirsMetadataPath, setFileDirMetadata)
+			router.With(s.checkAuthRequirements).Post(userSyncPath, getUserSyncDelta)
+			router.With(s.checkAuthRequirements).Get(userSearchPath, searchUserFilesHandler)
+			router.Get(userHostCAPath, getHostCA)
 		})
 
 		if s.renderOpenAPI {
@@ -1544,6 +1813,15 @@ func (s *httpdServer) setupWebClientRoutes() {
 			s.router.With(jwtauth.Verify(s.tokenAuth, jwtauth.TokenFromCookie),
 				s.jwtAuthenticatorPartial(tokenAudienceWebClientPartial)).
 				Post(webClientTwoFactorRecoveryPath, s.handleWebClientTwoFactorRecoveryPost)
+			s.router.With(verifyCSRFHeader).
+				Post(webClientWebAuthnPath+"/login/options", s.handleWebClientWebAuthnLoginOptions)
+			s.router.Post(webClientWebAuthnPath+"/login", s.handleWebClientWebAuthnLoginPost)
+			s.router.With(jwtauth.Verify(s.tokenAuth, jwtauth.TokenFromCookie),
+				s.jwtAuthenticatorPartial(tokenAudienceWebClientPartial), verifyCSRFHeader).
+				Post(webClientWebAuthnPath+"/twofactor/options", s.handleWebClientTwoFactorWebAuthnOptions)
+			s.router.With(jwtauth.Verify(s.tokenAuth, jwtauth.TokenFromCookie),
+				s.jwtAuthenticatorPartial(tokenAudienceWebClientPartial)).
+				Post(webClientWebAuthnPath+"/twofactor", s.handleWebClientTwoFactorWebAuthnPost)
 		}
 		// share routes available to external users
 		s.router.Get(webClientPubSharesPath+"/{id}/login", s.handleClientShareLoginGet)
@@ -1577,6 +1855,18 @@ func (s *httpdServer) setupWebClientRoutes() {
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled), verifyCSRFHeader).
 				Post(webClientExistPath, s.handleClientCheckExist)
 			router.With(s.checkAuthRequirements, s.refreshCookie).Get(webClientEditFilePath, s.handleClientEditFile)
+			router.With(s.checkAuthRequirements).Get(webClientThumbnailPath, getUserThumbnail)
+			router.With(s.checkAuthRequirements, s.refreshCookie, verifyCSRFHeader).
+				Get(webClientSearchPath, searchUserFilesHandler)
+			router.Group(func(router chi.Router) {
+				router.Use(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled), verifyCSRFHeader)
+
+				router.Post(webClientUploadsPath, startChunkedUpload)
+				router.Get(webClientUploadsPath+"/{id}", getChunkedUploadStatus)
+				router.Put(webClientUploadsPath+"/{id}/{chunk}", uploadChunk)
+				router.Post(webClientUploadsPath+"/{id}/complete", completeChunkedUpload)
+				router.Delete(webClientUploadsPath+"/{id}", deleteChunkedUpload)
+			})
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled), verifyCSRFHeader).
 				Delete(webClientFilesPath, deleteUserFile)
 			router.With(s.checkAuthRequirements, compressor.Handler, s.refreshCookie).
@@ -1589,6 +1879,27 @@ func (s *httpdServer) setupWebClientRoutes() {
 				Post(webClientFileActionsPath+"/move", renameUserFsEntry)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled), verifyCSRFHeader).
 				Post(webClientFileActionsPath+"/copy", copyUserFsEntry)
+			router.Group(func(router chi.Router) {
+				router.Use(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled), verifyCSRFHeader)
+
+				router.Get(webClientFileJobsPath, getUserFileJobs)
+				router.Post(webClientFileJobsPath+"/copy", startUserFileJob(fileJobOperationCopy))
+				router.Post(webClientFileJobsPath+"/move", startUserFileJob(fileJobOperationMove))
+				router.Post(webClientFileJobsPath+"/bulk", startUserBulkFileJob)
+				router.Get(webClientFileJobsPath+"/{id}", getUserFileJob)
+				router.Delete(webClientFileJobsPath+"/{id}", deleteUserFileJob)
+			})
+			router.With(s.checkAuthRequirements, s.refreshCookie, verifyCSRFHeader).
+				Get(webClientFileVersionsPath, getUserFileVersions)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled), verifyCSRFHeader).
+				Post(webClientFileVersionsPath+"/restore", restoreUserFileVersion)
+			router.With(s.checkAuthRequirements, s.refreshCookie).Get(webClientTrashPath, s.handleClientGetTrash)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled), verifyCSRFHeader).
+				Delete(webClientTrashPath, emptyUserTrash)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled), verifyCSRFHeader).
+				Post(webClientTrashPath+"/{id}/restore", restoreUserTrashItem)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientWriteDisabled), verifyCSRFHeader).
+				Delete(webClientTrashPath+"/{id}", deleteUserTrashItem)
 			router.With(s.checkAuthRequirements, s.refreshCookie).
 				Post(webClientDownloadZipPath, s.handleWebClientDownloadZip)
 			router.With(s.checkAuthRequirements, s.refreshCookie).Get(webClientPingPath, handlePingRequest)
@@ -1613,6 +1924,12 @@ func (s *httpdServer) setupWebClientRoutes() {
 				Get(webClientRecoveryCodesPath, getRecoveryCodes)
 			router.With(s.checkHTTPUserPerm(sdk.WebClientMFADisabled), verifyCSRFHeader).
 				Post(webClientRecoveryCodesPath, generateRecoveryCodes)
+			router.With(s.checkHTTPUserPerm(sdk.WebClientMFADisabled), verifyCSRFHeader).
+				Post(webClientWebAuthnPath+"/register/options", getWebAuthnRegistrationOptions)
+			router.With(s.checkHTTPUserPerm(sdk.WebClientMFADisabled), verifyCSRFHeader).
+				Post(webClientWebAuthnPath+"/register", registerWebAuthnCredential)
+			router.With(s.checkHTTPUserPerm(sdk.WebClientMFADisabled), verifyCSRFHeader).
+				Delete(webClientWebAuthnPath+"/credentials/{id}", deleteWebAuthnCredential)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientSharesDisabled), s.refreshCookie).
 				Get(webClientSharesPath, s.handleClientGetShares)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientSharesDisabled), s.refreshCookie).
@@ -1623,6 +1940,8 @@ func (s *httpdServer) setupWebClientRoutes() {
 				Get(webClientSharePath+"/{id}", s.handleClientUpdateShareGet)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientSharesDisabled)).
 				Post(webClientSharePath+"/{id}", s.handleClientUpdateSharePost)
+			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientSharesDisabled), s.refreshCookie).
+				Get(webClientSharePath+"/{id}/downloads", s.handleClientGetShareDownloads)
 			router.With(s.checkAuthRequirements, s.checkHTTPUserPerm(sdk.WebClientSharesDisabled), verifyCSRFHeader).
 				Delete(webClientSharePath+"/{id}", deleteShare)
 		})
@@ -1656,6 +1975,15 @@ func (s *httpdServer) setupWebAdminRoutes() {
 			s.router.With(jwtauth.Verify(s.tokenAuth, jwtauth.TokenFromCookie),
 				s.jwtAuthenticatorPartial(tokenAudienceWebAdminPartial)).
 				Post(webAdminTwoFactorRecoveryPath, s.handleWebAdminTwoFactorRecoveryPost)
+			s.router.With(verifyCSRFHeader).
+				Post(webAdminWebAuthnPath+"/login/options", s.handleWebAdminWebAuthnLoginOptions)
+			s.router.Post(webAdminWebAuthnPath+"/login", s.handleWebAdminWebAuthnLoginPost)
+			s.router.With(jwtauth.Verify(s.tokenAuth, jwtauth.TokenFromCookie),
+				s.jwtAuthenticatorPartial(tokenAudienceWebAdminPartial), verifyCSRFHeader).
+				Post(webAdminWebAuthnPath+"/twofactor/options", s.handleWebAdminTwoFactorWebAuthnOptions)
+			s.router.With(jwtauth.Verify(s.tokenAuth, jwtauth.TokenFromCookie),
+				s.jwtAuthenticatorPartial(tokenAudienceWebAdminPartial)).
+				Post(webAdminWebAuthnPath+"/twofactor", s.handleWebAdminTwoFactorWebAuthnPost)
 			s.router.Get(webAdminForgotPwdPath, s.handleWebAdminForgotPwd)
 			s.router.Post(webAdminForgotPwdPath, s.handleWebAdminForgotPwdPost)
 			s.router.Get(webAdminResetPwdPath, s.handleWebAdminPasswordReset)
@@ -1683,6 +2011,12 @@ func (s *httpdServer) setupWebAdminRoutes() {
 			router.With(verifyCSRFHeader, s.requireBuiltinLogin, s.refreshCookie).Get(webAdminRecoveryCodesPath,
 				getRecoveryCodes)
 			router.With(verifyCSRFHeader, s.requireBuiltinLogin).Post(webAdminRecoveryCodesPath, generateRecoveryCodes)
+			router.With(verifyCSRFHeader, s.requireBuiltinLogin).Post(webAdminWebAuthnPath+"/register/options",
+				getWebAuthnRegistrationOptions)
+			router.With(verifyCSRFHeader, s.requireBuiltinLogin).Post(webAdminWebAuthnPath+"/register",
+				registerWebAuthnCredential)
+			router.With(verifyCSRFHeader, s.requireBuiltinLogin).Delete(webAdminWebAuthnPath+"/credentials/{id}",
+				deleteWebAuthnCredential)
 
 			router.With(s.checkPerm(dataprovider.PermAdminViewUsers), s.refreshCookie).
 				Get(webUsersPath, s.handleGetWebUsers)
@@ -1695,14 +2029,18 @@ func (s *httpdServer) setupWebAdminRoutes() {
 			router.With(s.checkPerm(dataprovider.PermAdminAddUsers)).Post(webUserPath, s.handleWebAddUserPost)
 			router.With(s.checkPerm(dataprovider.PermAdminChangeUsers)).Post(webUserPath+"/{username}",
 				s.handleWebUpdateUserPost)
-			router.With(s.checkPerm(dataprovider.PermAdminManageGroups), s.refreshCookie).
+			if s.enableWebClient {
+				router.With(s.checkPerm(dataprovider.PermAdminImpersonateUsers)).
+					Post(webImpersonateUserPath+"/{username}", s.handleWebImpersonateUserPost)
+			}
+			router.With(s.checkPerm(dataprovider.PermAdminViewGroups), s.refreshCookie).
 				Get(webGroupsPath, s.handleWebGetGroups)
-			router.With(s.checkPerm(dataprovider.PermAdminManageGroups), compressor.Handler, s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewGroups), compressor.Handler, s.refreshCookie).
 				Get(webGroupsPath+jsonAPISuffix, getAllGroups)
 			router.With(s.checkPerm(dataprovider.PermAdminManageGroups), s.refreshCookie).
 				Get(webGroupPath, s.handleWebAddGroupGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageGroups)).Post(webGroupPath, s.handleWebAddGroupPost)
-			router.With(s.checkPerm(dataprovider.PermAdminManageGroups), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewGroups), s.refreshCookie).
 				Get(webGroupPath+"/{name}", s.handleWebUpdateGroupGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageGroups)).Post(webGroupPath+"/{name}",
 				s.handleWebUpdateGroupPost)
@@ -1712,22 +2050,28 @@ func (s *httpdServer) setupWebAdminRoutes() {
 				Get(webConnectionsPath, s.handleWebGetConnections)
 			router.With(s.checkPerm(dataprovider.PermAdminViewConnections), s.refreshCookie).
 				Get(webConnectionsPath+jsonAPISuffix, getActiveConnections)
-			router.With(s.checkPerm(dataprovider.PermAdminManageFolders), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewFolders), s.refreshCookie).
 				Get(webFoldersPath, s.handleWebGetFolders)
-			router.With(s.checkPerm(dataprovider.PermAdminManageFolders), compressor.Handler, s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewFolders), compressor.Handler, s.refreshCookie).
 				Get(webFoldersPath+jsonAPISuffix, getAllFolders)
 			router.With(s.checkPerm(dataprovider.PermAdminManageFolders), s.refreshCookie).
 				Get(webFolderPath, s.handleWebAddFolderGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageFolders)).Post(webFolderPath, s.handleWebAddFolderPost)
 			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus), s.refreshCookie).
 				Get(webStatusPath, s.handleWebGetStatus)
-			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus), s.refreshCookie).
+				Get(webDashboardPath, s.handleWebGetDashboard)
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus), s.refreshCookie).
+				Get(webDashboardPath+"/stats"+jsonAPISuffix, getDashboardStats)
+			router.With(s.checkPerm(dataprovider.PermAdminViewServerStatus), s.checkPerm(dataprovider.PermAdminViewUsers),
+				s.refreshCookie).Get(webDashboardPath+"/quota"+jsonAPISuffix, getDashboardQuotaStats)
+			router.With(s.checkPerm(dataprovider.PermAdminViewAdmins), s.refreshCookie).
 				Get(webAdminsPath, s.handleGetWebAdmins)
-			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins), compressor.Handler, s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewAdmins), compressor.Handler, s.refreshCookie).
 				Get(webAdminsPath+jsonAPISuffix, getAllAdmins)
 			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins), s.refreshCookie).
 				Get(webAdminPath, s.handleWebAddAdminGet)
-			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewAdmins), s.refreshCookie).
 				Get(webAdminPath+"/{username}", s.handleWebUpdateAdminGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).Post(webAdminPath, s.handleWebAddAdminPost)
 			router.With(s.checkPerm(dataprovider.PermAdminManageAdmins)).Post(webAdminPath+"/{username}",
@@ -1736,7 +2080,7 @@ func (s *httpdServer) setupWebAdminRoutes() {
 				Delete(webAdminPath+"/{username}", deleteAdmin)
 			router.With(s.checkPerm(dataprovider.PermAdminCloseConnections), verifyCSRFHeader).
 				Delete(webConnectionsPath+"/{connectionID}", handleCloseConnection)
-			router.With(s.checkPerm(dataprovider.PermAdminManageFolders), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewFolders), s.refreshCookie).
 				Get(webFolderPath+"/{name}", s.handleWebUpdateFolderGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageFolders)).Post(webFolderPath+"/{name}",
 				s.handleWebUpdateFolderPost)
@@ -1761,29 +2105,29 @@ func (s *httpdServer) setupWebAdminRoutes() {
 			router.With(s.checkPerm(dataprovider.PermAdminViewDefender)).Get(webDefenderHostsPath, getDefenderHosts)
 			router.With(s.checkPerm(dataprovider.PermAdminManageDefender)).Delete(webDefenderHostsPath+"/{id}",
 				deleteDefenderHostByID)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), compressor.Handler, s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules), compressor.Handler, s.refreshCookie).
 				Get(webAdminEventActionsPath+jsonAPISuffix, getAllActions)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules), s.refreshCookie).
 				Get(webAdminEventActionsPath, s.handleWebGetEventActions)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), s.refreshCookie).
 				Get(webAdminEventActionPath, s.handleWebAddEventActionGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Post(webAdminEventActionPath,
 				s.handleWebAddEventActionPost)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules), s.refreshCookie).
 				Get(webAdminEventActionPath+"/{name}", s.handleWebUpdateEventActionGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Post(webAdminEventActionPath+"/{name}",
 				s.handleWebUpdateEventActionPost)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), verifyCSRFHeader).
 				Delete(webAdminEventActionPath+"/{name}", deleteEventAction)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), compressor.Handler, s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules), compressor.Handler, s.refreshCookie).
 				Get(webAdminEventRulesPath+jsonAPISuffix, getAllRules)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules), s.refreshCookie).
 				Get(webAdminEventRulesPath, s.handleWebGetEventRules)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), s.refreshCookie).
 				Get(webAdminEventRulePath, s.handleWebAddEventRuleGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Post(webAdminEventRulePath,
 				s.handleWebAddEventRulePost)
-			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewEventRules), s.refreshCookie).
 				Get(webAdminEventRulePath+"/{name}", s.handleWebUpdateEventRuleGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules)).Post(webAdminEventRulePath+"/{name}",
 				s.handleWebUpdateEventRulePost)
@@ -1791,14 +2135,14 @@ func (s *httpdServer) setupWebAdminRoutes() {
 				Delete(webAdminEventRulePath+"/{name}", deleteEventRule)
 			router.With(s.checkPerm(dataprovider.PermAdminManageEventRules), verifyCSRFHeader).
 				Post(webAdminEventRulePath+"/run/{name}", runOnDemandRule)
-			router.With(s.checkPerm(dataprovider.PermAdminManageRoles), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewRoles), s.refreshCookie).
 				Get(webAdminRolesPath, s.handleWebGetRoles)
-			router.With(s.checkPerm(dataprovider.PermAdminManageRoles), compressor.Handler, s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewRoles), compressor.Handler, s.refreshCookie).
 				Get(webAdminRolesPath+jsonAPISuffix, getAllRoles)
 			router.With(s.checkPerm(dataprovider.PermAdminManageRoles), s.refreshCookie).
 				Get(webAdminRolePath, s.handleWebAddRoleGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageRoles)).Post(webAdminRolePath, s.handleWebAddRolePost)
-			router.With(s.checkPerm(dataprovider.PermAdminManageRoles), s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewRoles), s.refreshCookie).
 				Get(webAdminRolePath+"/{name}", s.handleWebUpdateRoleGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageRoles)).Post(webAdminRolePath+"/{name}",
 				s.handleWebUpdateRolePost)
@@ -1812,14 +2156,20 @@ func (s *httpdServer) setupWebAdminRoutes() {
 				Get(webEventsProviderSearchPath, searchProviderEvents)
 			router.With(s.checkPerm(dataprovider.PermAdminViewEvents), compressor.Handler, s.refreshCookie).
 				Get(webEventsLogSearchPath, searchLogEvents)
-			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists)).Get(webIPListsPath, s.handleWebIPListsPage)
-			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists), compressor.Handler, s.refreshCookie).
+			router.With(s.checkPerm(dataprovider.PermAdminViewEvents), s.refreshCookie).
+				Get(webWebhooksPath, s.handleWebGetWebhooks)
+			router.With(s.checkPerm(dataprovider.PermAdminViewEvents), compressor.Handler, s.refreshCookie).
+				Get(webWebhooksPath+jsonAPISuffix, getWebhookDeliveries)
+			router.With(s.checkPerm(dataprovider.PermAdminManageSystem), verifyCSRFHeader).
+				Post(webWebhooksPath+"/{id}/retry", retryWebhookDelivery)
+			router.With(s.checkPerm(dataprovider.PermAdminViewIPLists)).Get(webIPListsPath, s.handleWebIPListsPage)
+			router.With(s.checkPerm(dataprovider.PermAdminViewIPLists), compressor.Handler, s.refreshCookie).
 				Get(webIPListsPath+"/{type}", getIPListEntries)
 			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists), s.refreshCookie).Get(webIPListPath+"/{type}",
 				s.handleWebAddIPListEntryGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists)).Post(webIPListPath+"/{type}",
 				s.handleWebAddIPListEntryPost)
-			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists), s.refreshCookie).Get(webIPListPath+"/{type}/{ipornet}",
+			router.With(s.checkPerm(dataprovider.PermAdminViewIPLists), s.refreshCookie).Get(webIPListPath+"/{type}/{ipornet}",
 				s.handleWebUpdateIPListEntryGet)
 			router.With(s.checkPerm(dataprovider.PermAdminManageIPLists)).Post(webIPListPath+"/{type}/{ipornet}",
 				s.handleWebUpdateIPListEntryPost)
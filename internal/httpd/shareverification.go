@@ -0,0 +1,253 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/smtp"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+var (
+	shareVerificationLifespan = 10 * time.Minute
+	shareVerificationMgr      shareVerificationManager
+)
+
+type shareVerificationManager interface {
+	Add(v *shareVerification) error
+	Get(id string) (*shareVerification, error)
+	Delete(id string) error
+	Cleanup()
+}
+
+func newShareVerificationManager(isShared int) shareVerificationManager {
+	if isShared == 1 {
+		logger.Info(logSender, "", "using provider share verification manager")
+		return &dbShareVerificationManager{}
+	}
+	logger.Info(logSender, "", "using memory share verification manager")
+	return &memoryShareVerificationManager{}
+}
+
+// shareVerification defines a pending email verification for a share.
+// An empty code means that the email is not allowed, we don't disclose
+// this to the requester and the verification will always fail
+type shareVerification struct {
+	ID        string    `json:"id"`
+	ShareID   string    `json:"share_id"`
+	Email     string    `json:"email"`
+	Code      string    `json:"code"`
+	Attempts  int       `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newShareVerification(shareID, email string, allowed bool) (*shareVerification, error) {
+	v := &shareVerification{
+		ID:        util.GenerateUniqueID(),
+		ShareID:   shareID,
+		Email:     email,
+		ExpiresAt: time.Now().Add(shareVerificationLifespan).UTC(),
+	}
+	if allowed {
+		code, err := generateShareVerificationCode()
+		if err != nil {
+			return nil, err
+		}
+		v.Code = code
+	}
+	return v, nil
+}
+
+func (v *shareVerification) isExpired() bool {
+	return v.ExpiresAt.Before(time.Now().UTC())
+}
+
+func (v *shareVerification) isCodeValid(code string) bool {
+	if v.Code == "" || code == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(v.Code), []byte(code)) == 1
+}
+
+func generateShareVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+type memoryShareVerificationManager struct {
+	verifications sync.Map
+}
+
+func (m *memoryShareVerificationManager) Add(v *shareVerification) error {
+	m.verifications.Store(v.ID, v)
+	return nil
+}
+
+func (m *memoryShareVerificationManager) Get(id string) (*shareVerification, error) {
+	v, ok := m.verifications.Load(id)
+	if !ok {
+		return nil, util.NewRecordNotFoundError("share verification not found")
+	}
+	// return a copy, updates must be saved using Add
+	res := *(v.(*shareVerification))
+	return &res, nil
+}
+
+func (m *memoryShareVerificationManager) Delete(id string) error {
+	m.verifications.Delete(id)
+	return nil
+}
+
+func (m *memoryShareVerificationManager) Cleanup() {
+	m.verifications.Range(func(key, value any) bool {
+		v, ok := value.(*shareVerification)
+		if !ok || v.isExpired() {
+			m.verifications.Delete(key)
+		}
+		return true
+	})
+}
+
+type dbShareVerificationManager struct{}
+
+func (m *dbShareVerificationManager) Add(v *shareVerification) error {
+	session := dataprovider.Session{
+		Key:       v.ID,
+		Data:      v,
+		Type:      dataprovider.SessionTypeShareVerification,
+		Timestamp: util.GetTimeAsMsSinceEpoch(v.ExpiresAt),
+	}
+	return dataprovider.AddSharedSession(session)
+}
+
+func (m *dbShareVerificationManager) Get(id string) (*shareVerification, error) {
+	session, err := dataprovider.GetSharedSession(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Timestamp < util.GetTimeAsMsSinceEpoch(time.Now()) {
+		// expired
+		return nil, util.NewRecordNotFoundError("share verification expired")
+	}
+	if val, ok := session.Data.([]byte); ok {
+		v := &shareVerification{}
+		err := json.Unmarshal(val, v)
+		return v, err
+	}
+	logger.Error(logSender, "", "invalid share verification data type %T", session.Data)
+	return nil, util.NewRecordNotFoundError("invalid share verification")
+}
+
+func (m *dbShareVerificationManager) Delete(id string) error {
+	return dataprovider.DeleteSharedSession(id)
+}
+
+func (m *dbShareVerificationManager) Cleanup() {
+	dataprovider.CleanupSharedSessions(dataprovider.SessionTypeShareVerification, time.Now()) //nolint:errcheck
+}
+
+// startShareVerification sends a one-time code to the specified email address
+// if it is allowed to access the share. The returned verification is stored
+// even if the email is not allowed so we don't disclose the allowed addresses
+func startShareVerification(r *http.Request, share *dataprovider.Share, email, ipAddr string) (*shareVerification, error) {
+	allowed := share.EmailVerification.IsEmailAllowed(email)
+	v, err := newShareVerification(share.ShareID, email, allowed)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		logger.Info(logSender, middleware.GetReqID(r.Context()),
+			"share %q, email %q is not allowed, verification code not sent, ip: %q", share.ShareID, email, ipAddr)
+		return v, shareVerificationMgr.Add(v)
+	}
+	body := new(bytes.Buffer)
+	data := map[string]any{
+		"Code":      v.Code,
+		"ShareName": share.Name,
+		"Minutes":   int(shareVerificationLifespan.Minutes()),
+	}
+	if err := smtp.RenderShareVerificationTemplate(body, data); err != nil {
+		logger.Warn(logSender, middleware.GetReqID(r.Context()), "unable to render share verification template: %v", err)
+		return nil, util.NewGenericError("Unable to render share verification template")
+	}
+	startTime := time.Now()
+	subject := fmt.Sprintf("Verification code for share %q", share.Name)
+	if err := smtp.SendEmail([]string{email}, nil, subject, body.String(), smtp.EmailContentTypeTextHTML); err != nil {
+		logger.Warn(logSender, middleware.GetReqID(r.Context()), "unable to send share verification code via email: %v, elapsed: %v",
+			err, time.Since(startTime))
+		return nil, util.NewI18nError(
+			util.NewGenericError(fmt.Sprintf("Error sending verification code via email: %v", err)),
+			util.I18nErrorShareVerificationSendEmail,
+		)
+	}
+	logger.Info(logSender, middleware.GetReqID(r.Context()), "share %q, verification code sent to email %q, ip: %q, elapsed: %v",
+		share.ShareID, email, ipAddr, time.Since(startTime))
+	return v, shareVerificationMgr.Add(v)
+}
+
+// checkShareVerification validates the specified code and returns the verified email.
+// The verification is removed after a successful check or after too many attempts
+func checkShareVerification(r *http.Request, share *dataprovider.Share, id, code, ipAddr string) (string, error) {
+	v, err := shareVerificationMgr.Get(id)
+	if err != nil || v.ShareID != share.ShareID || v.isExpired() {
+		logger.Info(logSender, middleware.GetReqID(r.Context()), "share %q, verification %q not found or expired, ip: %q",
+			share.ShareID, id, ipAddr)
+		return "", util.NewI18nError(util.NewRecordNotFoundError("verification code expired"), util.I18nErrorShareVerificationExpired)
+	}
+	if v.isCodeValid(code) && share.EmailVerification.IsEmailAllowed(v.Email) {
+		logger.Info(logSender, middleware.GetReqID(r.Context()), "share %q, email %q verified, ip: %q",
+			share.ShareID, v.Email, ipAddr)
+		return v.Email, shareVerificationMgr.Delete(v.ID)
+	}
+	v.Attempts++
+	logger.Info(logSender, middleware.GetReqID(r.Context()), "share %q, invalid verification code for email %q, attempt %d/%d, ip: %q",
+		share.ShareID, v.Email, v.Attempts, share.EmailVerification.GetMaxAttempts(), ipAddr)
+	if v.Attempts >= share.EmailVerification.GetMaxAttempts() {
+		shareVerificationMgr.Delete(v.ID) //nolint:errcheck
+		return "", util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorShareVerificationAttempts)
+	}
+	if err := shareVerificationMgr.Add(v); err != nil {
+		return "", err
+	}
+	return "", util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorShareVerificationCode)
+}
+
+// checkShareEmailVerification returns an error if email verification is
+// required for the specified share and it is not possible to send emails
+func checkShareEmailVerification(share *dataprovider.Share) error {
+	if share.EmailVerification.IsEnabled() && !smtp.IsEnabled() {
+		return util.NewI18nError(
+			util.NewValidationError("email verification requires an SMTP server to be configured"),
+			util.I18nErrorShareVerificationNoSMTP,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,437 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register the GIF decoder
+	"image/jpeg"
+	_ "image/png" // register the PNG decoder
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+const (
+	thumbnailDefaultSize      = 256
+	thumbnailMinSize          = 32
+	thumbnailMaxSize          = 1024
+	thumbnailMaxPixels        = 50000000
+	thumbnailJPEGQuality      = 80
+	thumbnailVideoTimeout     = 30 * time.Second
+	thumbnailCleanupInterval  = time.Hour
+	thumbnailDefaultCachePath = "thumbnails"
+)
+
+var (
+	thumbnailer              *thumbnailManager
+	errThumbnailUnsupported  = errors.New("thumbnails are not supported for this file")
+	thumbnailImageExtensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+	thumbnailVideoExtensions = []string{".mp4", ".m4v", ".mov", ".webm", ".mkv", ".avi"}
+)
+
+// ThumbnailsConfig defines the configuration for the thumbnails displayed in the WebClient
+type ThumbnailsConfig struct {
+	// Set to true to generate thumbnails for JPEG, PNG and GIF images
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Path to the directory where the generated thumbnails are cached.
+	// This can be an absolute path or a path relative to the config dir
+	CachePath string `json:"cache_path" mapstructure:"cache_path"`
+	// Maximum width and height, in pixels, for the generated thumbnails
+	Size int `json:"size" mapstructure:"size"`
+	// Maximum size, in MB, for the files to generate thumbnails for. 0 means no limit
+	MaxFileSize int64 `json:"max_file_size" mapstructure:"max_file_size"`
+	// Cached thumbnails not accessed for more than the specified number of hours
+	// are removed. 0 means no automatic cleanup
+	CacheRetention int `json:"cache_retention" mapstructure:"cache_retention"`
+	// Path to the ffmpeg executable. If set, thumbnails are generated for videos too.
+	// The video contents are sent to ffmpeg using its standard input, so videos
+	// stored on remote backends can be processed without temporary files
+	FFmpegPath string `json:"ffmpeg_path" mapstructure:"ffmpeg_path"`
+}
+
+func (c *ThumbnailsConfig) initialize(configDir string) error {
+	thumbnailer = nil
+	if !c.Enabled {
+		return nil
+	}
+	if c.Size == 0 {
+		c.Size = thumbnailDefaultSize
+	}
+	if c.Size < thumbnailMinSize || c.Size > thumbnailMaxSize {
+		return fmt.Errorf("thumbnails: invalid size %d, allowed range: %d-%d", c.Size, thumbnailMinSize, thumbnailMaxSize)
+	}
+	if c.MaxFileSize < 0 {
+		return fmt.Errorf("thumbnails: invalid max file size %d", c.MaxFileSize)
+	}
+	if c.CacheRetention < 0 {
+		return fmt.Errorf("thumbnails: invalid cache retention %d", c.CacheRetention)
+	}
+	cachePath := c.CachePath
+	if cachePath == "" {
+		cachePath = thumbnailDefaultCachePath
+	}
+	if !filepath.IsAbs(cachePath) {
+		cachePath = filepath.Join(configDir, cachePath)
+	}
+	if err := os.MkdirAll(cachePath, 0700); err != nil {
+		return fmt.Errorf("thumbnails: unable to create the cache dir %q: %w", cachePath, err)
+	}
+	ffmpegPath := ""
+	if c.FFmpegPath != "" {
+		p, err := exec.LookPath(c.FFmpegPath)
+		if err != nil {
+			return fmt.Errorf("thumbnails: invalid ffmpeg path %q: %w", c.FFmpegPath, err)
+		}
+		ffmpegPath = p
+	}
+	thumbnailer = &thumbnailManager{
+		cacheDir:    cachePath,
+		size:        c.Size,
+		maxFileSize: c.MaxFileSize * 1048576,
+		retention:   time.Duration(c.CacheRetention) * time.Hour,
+		ffmpegPath:  ffmpegPath,
+		sem:         make(chan struct{}, runtime.NumCPU()),
+	}
+	logger.Debug(logSender, "", "thumbnails enabled, cache dir: %q, size: %d, max file size: %d, videos: %t",
+		cachePath, c.Size, thumbnailer.maxFileSize, ffmpegPath != "")
+	return nil
+}
+
+type thumbnailManager struct {
+	cacheDir    string
+	size        int
+	maxFileSize int64
+	retention   time.Duration
+	ffmpegPath  string
+	// limits the number of concurrent thumbnail generations
+	sem         chan struct{}
+	lastCleanup atomic.Int64
+}
+
+func (m *thumbnailManager) isVideo(name string) bool {
+	return m.ffmpegPath != "" && util.Contains(thumbnailVideoExtensions, strings.ToLower(path.Ext(name)))
+}
+
+// isSupported returns true if a thumbnail can be generated for the specified file
+func (m *thumbnailManager) isSupported(info os.FileInfo) bool {
+	if !info.Mode().IsRegular() || info.Size() == 0 {
+		return false
+	}
+	if m.maxFileSize > 0 && info.Size() > m.maxFileSize {
+		return false
+	}
+	return util.Contains(thumbnailImageExtensions, strings.ToLower(path.Ext(info.Name()))) || m.isVideo(info.Name())
+}
+
+// getCachePaths returns the cache directory and the file name prefix for the thumbnails
+// of the specified file. Thumbnails are grouped by storage backend, each backend has
+// its own cache directory
+func (m *thumbnailManager) getCachePaths(fs vfs.Fs, fsPath string) (string, string) {
+	backendHash := sha256.Sum256([]byte(fs.Name()))
+	pathHash := sha256.Sum256([]byte(fsPath))
+	prefix := hex.EncodeToString(pathHash[:])
+	return filepath.Join(m.cacheDir, hex.EncodeToString(backendHash[:8]), prefix[:2]), prefix
+}
+
+// getCacheName returns the file name for the cached thumbnail of the specified file version.
+// The size and the modification time of the source file are part of the name, this way
+// thumbnails for files modified outside SFTPGo are never served
+func (m *thumbnailManager) getCacheName(prefix string, info os.FileInfo) string {
+	return fmt.Sprintf("%s-%d-%d-%d.jpg", prefix, m.size, info.Size(), info.ModTime().UnixNano())
+}
+
+// removeCached removes the cached thumbnails for the specified file, except the one with the
+// specified name if any
+func (m *thumbnailManager) removeCached(dir, prefix, except string) {
+	matches, err := filepath.Glob(filepath.Join(dir, prefix+"-*.jpg"))
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		if filepath.Base(match) != except {
+			os.Remove(match) //nolint:errcheck
+		}
+	}
+}
+
+// invalidate removes the cached thumbnails for the specified file, it is called when
+// a file is overwritten
+func (m *thumbnailManager) invalidate(fs vfs.Fs, fsPath string) {
+	dir, prefix := m.getCachePaths(fs, fsPath)
+	m.removeCached(dir, prefix, "")
+}
+
+// getThumbnail returns the path to the cached thumbnail for the specified file,
+// the thumbnail is generated if not already cached
+func (m *thumbnailManager) getThumbnail(r *http.Request, connection *Connection, name string,
+	info os.FileInfo,
+) (string, error) {
+	if !m.isSupported(info) {
+		return "", errThumbnailUnsupported
+	}
+	if !connection.User.HasPerm(dataprovider.PermDownload, path.Dir(name)) {
+		return "", connection.GetPermissionDeniedError()
+	}
+	if ok, policy := connection.User.IsFileAllowed(name); !ok {
+		return "", connection.GetErrorForDeniedFile(policy)
+	}
+	fs, fsPath, err := connection.GetFsAndResolvedPath(name)
+	if err != nil {
+		return "", err
+	}
+	dir, prefix := m.getCachePaths(fs, fsPath)
+	cacheName := m.getCacheName(prefix, info)
+	cachePath := filepath.Join(dir, cacheName)
+	if _, err := os.Stat(cachePath); err == nil {
+		now := time.Now()
+		os.Chtimes(cachePath, now, now) //nolint:errcheck
+		return cachePath, nil
+	}
+
+	m.sem <- struct{}{}
+	defer func() {
+		<-m.sem
+	}()
+
+	data, err := m.generate(r, connection, name)
+	if err != nil {
+		connection.Log(logger.LevelDebug, "unable to generate thumbnail for %q: %v", name, err)
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	// write to a temporary file and rename, concurrent requests for the same file
+	// could generate the same thumbnail
+	tmpPath := filepath.Join(dir, fmt.Sprintf(".%s.%s", cacheName, util.GenerateUniqueID()))
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmpPath, cachePath); err != nil {
+		os.Remove(tmpPath) //nolint:errcheck
+		return "", err
+	}
+	m.removeCached(dir, prefix, cacheName)
+	connection.Log(logger.LevelDebug, "thumbnail generated for %q, size: %d", name, len(data))
+	return cachePath, nil
+}
+
+func (m *thumbnailManager) generate(r *http.Request, connection *Connection, name string) ([]byte, error) {
+	reader, err := connection.getFileReader(name, 0, r.Method, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if m.isVideo(name) {
+		ctx, cancel := context.WithTimeout(r.Context(), thumbnailVideoTimeout)
+		defer cancel()
+
+		return generateVideoThumbnail(ctx, m.ffmpegPath, reader, m.size)
+	}
+	var src io.Reader = reader
+	if m.maxFileSize > 0 {
+		src = io.LimitReader(reader, m.maxFileSize)
+	}
+	var b bytes.Buffer
+	if _, err := io.Copy(&b, src); err != nil {
+		return nil, err
+	}
+	return generateImageThumbnail(b.Bytes(), m.size)
+}
+
+// cleanup removes the cached thumbnails not accessed within the configured retention.
+// The cache directory is walked at most once per hour
+func (m *thumbnailManager) cleanup() {
+	if m.retention <= 0 {
+		return
+	}
+	now := time.Now()
+	lastCleanup := m.lastCleanup.Load()
+	if now.Sub(time.Unix(0, lastCleanup)) < thumbnailCleanupInterval {
+		return
+	}
+	if !m.lastCleanup.CompareAndSwap(lastCleanup, now.UnixNano()) {
+		return
+	}
+	removed := 0
+	filepath.WalkDir(m.cacheDir, func(walkedPath string, d fs.DirEntry, err error) error { //nolint:errcheck
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if now.Sub(info.ModTime()) > m.retention {
+			if err := os.Remove(walkedPath); err == nil {
+				removed++
+			}
+		}
+		return nil
+	})
+	logger.Debug(logSender, "", "thumbnails cache cleanup completed, removed: %d, elapsed: %s",
+		removed, time.Since(now))
+}
+
+// generateImageThumbnail decodes the specified image and returns a JPEG thumbnail
+// fitting within the specified size
+func generateImageThumbnail(data []byte, size int) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errThumbnailUnsupported, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > thumbnailMaxPixels {
+		return nil, fmt.Errorf("%w: image too large %dx%d", errThumbnailUnsupported, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errThumbnailUnsupported, err)
+	}
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, resizeImage(img, size), &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// resizeImage scales the specified image, preserving the aspect ratio, so that it fits
+// within the specified size. Each destination pixel is the average of the source pixels
+// it covers. Transparent areas are rendered on a white background
+func resizeImage(src image.Image, size int) *image.RGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	dstW, dstH := srcW, srcH
+	if srcW > size || srcH > size {
+		if srcW >= srcH {
+			dstW = size
+			dstH = max(1, srcH*size/srcW)
+		} else {
+			dstH = size
+			dstW = max(1, srcW*size/srcH)
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*srcH/dstH)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(x0+1, bounds.Min.X+(x+1)*srcW/dstW)
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			// the color components are alpha premultiplied, add the white background
+			bg := 0xffff - a/n
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r/n + bg),
+				G: uint16(g/n + bg),
+				B: uint16(b/n + bg),
+				A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
+
+// generateVideoThumbnail extracts the first frame of the specified video using ffmpeg
+// and returns it as a JPEG thumbnail fitting within the specified size
+func generateVideoThumbnail(ctx context.Context, ffmpegPath string, reader io.Reader, size int) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, "-hide_banner", "-loglevel", "error", "-i", "pipe:0",
+		"-frames:v", "1", "-vf", fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease", size, size),
+		"-f", "image2pipe", "-c:v", "mjpeg", "pipe:1")
+	cmd.Stdin = reader
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: ffmpeg error: %v, output: %q", errThumbnailUnsupported, err,
+			strings.TrimSpace(stderr.String()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("%w: no frame extracted", errThumbnailUnsupported)
+	}
+	return stdout.Bytes(), nil
+}
+
+func getUserThumbnail(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if thumbnailer == nil {
+		sendAPIResponse(w, r, nil, "Thumbnails are disabled", http.StatusNotFound)
+		return
+	}
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	name := connection.User.GetCleanedPath(r.URL.Query().Get("path"))
+	info, err := connection.Stat(name, 0)
+	if err != nil {
+		sendAPIResponse(w, r, err, "Unable to stat the requested file", getMappedStatusCode(err))
+		return
+	}
+	connection.User.CheckFsRoot(connection.ID) //nolint:errcheck
+	thumbnailPath, err := thumbnailer.getThumbnail(r, connection, name, info)
+	if err != nil {
+		status := getMappedStatusCode(err)
+		if errors.Is(err, errThumbnailUnsupported) {
+			status = http.StatusBadRequest
+		}
+		sendAPIResponse(w, r, err, "Unable to get the thumbnail", status)
+		return
+	}
+	f, err := os.Open(thumbnailPath)
+	if err != nil {
+		sendAPIResponse(w, r, err, "Unable to get the thumbnail", getMappedStatusCode(err))
+		return
+	}
+	defer f.Close()
+
+	// the thumbnail URLs include the source file modification time
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeContent(w, r, path.Base(thumbnailPath), info.ModTime(), f)
+}
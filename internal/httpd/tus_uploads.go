@@ -0,0 +1,471 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// tus resumable uploads, see https://tus.io/protocols/resumable-upload.
+// Uploads in progress are stored alongside the WebClient chunked uploads and
+// share the same storage directory and retention
+const (
+	tusVersion        = "1.0.0"
+	tusContentType    = "application/offset+octet-stream"
+	tusUploadInfoFile = "tus.json"
+	tusUploadDataFile = "data"
+)
+
+var (
+	// tusUploadsLocks tracks the uploads currently being modified,
+	// tus clients must not send concurrent requests for the same upload
+	tusUploadsLocks      sync.Map
+	errTusOffsetMismatch = errors.New("upload offset mismatch")
+)
+
+// tusUpload defines a tus upload in progress. It is stored, as JSON, in the upload
+// directory together with the data received so far
+type tusUpload struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	// Modification time for the uploaded file as unix timestamp in milliseconds
+	ModTime   int64 `json:"mtime,omitempty"`
+	CreatedAt int64 `json:"created_at"`
+}
+
+func (m *chunkedUploadManager) getTusDataPath(upload *tusUpload) string {
+	return filepath.Join(m.getUploadDir(upload.Username, upload.ID), tusUploadDataFile)
+}
+
+func (m *chunkedUploadManager) createTusUpload(username string, req chunkedUploadRequest) (*tusUpload, error) {
+	upload := &tusUpload{
+		ID:        util.GenerateUniqueID(),
+		Username:  username,
+		Path:      req.Path,
+		Size:      req.Size,
+		ModTime:   req.ModTime,
+		CreatedAt: util.GetTimeAsMsSinceEpoch(time.Now()),
+	}
+	uploadDir := m.getUploadDir(username, upload.ID)
+	if err := os.MkdirAll(uploadDir, 0700); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(upload)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(uploadDir, tusUploadInfoFile), data, 0600)
+	}
+	if err == nil {
+		err = os.WriteFile(m.getTusDataPath(upload), nil, 0600)
+	}
+	if err != nil {
+		os.RemoveAll(uploadDir) //nolint:errcheck
+		return nil, err
+	}
+	return upload, nil
+}
+
+func (m *chunkedUploadManager) getTusUpload(username, id string) (*tusUpload, error) {
+	if !chunkedUploadIDRegex.MatchString(id) {
+		return nil, util.NewRecordNotFoundError(errChunkedUploadNotFound.Error())
+	}
+	data, err := os.ReadFile(filepath.Join(m.getUploadDir(username, id), tusUploadInfoFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, util.NewRecordNotFoundError(errChunkedUploadNotFound.Error())
+		}
+		return nil, err
+	}
+	var upload tusUpload
+	if err := json.Unmarshal(data, &upload); err != nil {
+		return nil, err
+	}
+	if upload.ID != id || upload.Username != username {
+		return nil, util.NewRecordNotFoundError(errChunkedUploadNotFound.Error())
+	}
+	return &upload, nil
+}
+
+// getTusOffset returns the number of bytes received so far
+func (m *chunkedUploadManager) getTusOffset(upload *tusUpload) (int64, error) {
+	info, err := os.Stat(m.getTusDataPath(upload))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, util.NewRecordNotFoundError(errChunkedUploadNotFound.Error())
+		}
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// appendTusData appends the data read from the specified reader, up to the declared
+// upload size, and returns the new offset. The data received before a read error are
+// preserved, so the client can resume the upload from the returned offset
+func (m *chunkedUploadManager) appendTusData(upload *tusUpload, offset int64, reader io.Reader) (int64, error) {
+	currentOffset, err := m.getTusOffset(upload)
+	if err != nil {
+		return 0, err
+	}
+	if offset != currentOffset {
+		return currentOffset, fmt.Errorf("%w: received %d, expected %d", errTusOffsetMismatch, offset, currentOffset)
+	}
+	f, err := os.OpenFile(m.getTusDataPath(upload), os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return currentOffset, err
+	}
+	n, err := io.Copy(f, io.LimitReader(reader, upload.Size-currentOffset))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	// the directory modification time is used to remove the expired uploads
+	now := time.Now()
+	os.Chtimes(m.getUploadDir(upload.Username, upload.ID), now, now) //nolint:errcheck
+	return currentOffset + n, err
+}
+
+// completeTusUpload writes the received data to the user's storage, as a normal upload,
+// and removes the upload
+func (m *chunkedUploadManager) completeTusUpload(connection *Connection, upload *tusUpload) error {
+	offset, err := m.getTusOffset(upload)
+	if err != nil {
+		return err
+	}
+	if offset != upload.Size {
+		return util.NewValidationError(fmt.Sprintf("%v: received bytes %d/%d", errChunkedUploadIncomplete,
+			offset, upload.Size))
+	}
+	connection.User.CheckFsRoot(connection.ID) //nolint:errcheck
+	writer, err := connection.getFileWriter(upload.Path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(m.getTusDataPath(upload))
+	if err != nil {
+		writer.Close() //nolint:errcheck
+		return err
+	}
+	_, err = io.Copy(writer, f)
+	f.Close()
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if upload.ModTime > 0 {
+		setModificationTime(connection, upload.Path, upload.ModTime)
+	}
+	m.removeTusUpload(upload)
+	return nil
+}
+
+func (m *chunkedUploadManager) removeTusUpload(upload *tusUpload) {
+	uploadDir := m.getUploadDir(upload.Username, upload.ID)
+	if err := os.RemoveAll(uploadDir); err != nil {
+		logger.Warn(logSender, "", "unable to remove tus upload dir %q: %v", uploadDir, err)
+	}
+}
+
+func (m *chunkedUploadManager) getTusExpiration(upload *tusUpload) string {
+	if m.retention <= 0 {
+		return ""
+	}
+	info, err := os.Stat(m.getUploadDir(upload.Username, upload.ID))
+	if err != nil {
+		return ""
+	}
+	return info.ModTime().Add(m.retention).UTC().Format(http.TimeFormat)
+}
+
+func lockTusUpload(upload *tusUpload) bool {
+	_, loaded := tusUploadsLocks.LoadOrStore(upload.Username+"/"+upload.ID, true)
+	return !loaded
+}
+
+func unlockTusUpload(upload *tusUpload) {
+	tusUploadsLocks.Delete(upload.Username + "/" + upload.ID)
+}
+
+// parseTusMetadata parses the Upload-Metadata header, a comma separated list of
+// key value pairs, values are base64 encoded and can be omitted
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, " ")
+		if key == "" {
+			return nil, util.NewValidationError("invalid upload metadata")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, util.NewValidationError(fmt.Sprintf("invalid upload metadata for key %q", key))
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
+
+// getTusUploadPath returns the target path for a new upload. The path query parameter
+// has the precedence over the path and filename metadata, the filename is relative to
+// the root directory
+func getTusUploadPath(r *http.Request, metadata map[string]string) string {
+	if r.URL.Query().Has("path") {
+		return r.URL.Query().Get("path")
+	}
+	if p := metadata["path"]; p != "" {
+		return p
+	}
+	if name := metadata["filename"]; name != "" {
+		return path.Base(name)
+	}
+	return ""
+}
+
+func checkTusRequest(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if chunkedUploader == nil {
+		sendAPIResponse(w, r, nil, "Resumable uploads are disabled", http.StatusNotFound)
+		return false
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		sendAPIResponse(w, r, nil, "Unsupported tus protocol version", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func getTusUploadFromRequest(w http.ResponseWriter, r *http.Request) (*Connection, *tusUpload, error) {
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	upload, err := chunkedUploader.getTusUpload(connection.GetUsername(), getURLParam(r, "id"))
+	if err != nil {
+		common.Connections.Remove(connection.GetID())
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return nil, nil, err
+	}
+	return connection, upload, nil
+}
+
+func getTusOptions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if chunkedUploader == nil {
+		sendAPIResponse(w, r, nil, "Resumable uploads are disabled", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Tus-Version", tusVersion)
+	extensions := "creation,termination"
+	if chunkedUploader.retention > 0 {
+		extensions += ",expiration"
+	}
+	w.Header().Set("Tus-Extension", extensions)
+	if maxUploadFileSize > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(maxUploadFileSize, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func createTusUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if !checkTusRequest(w, r) {
+		return
+	}
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		sendAPIResponse(w, r, errors.New("deferred upload length is not supported"), "", http.StatusBadRequest)
+		return
+	}
+	size, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		sendAPIResponse(w, r, errors.New("invalid Upload-Length header"), "", http.StatusBadRequest)
+		return
+	}
+	metadata, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	req := chunkedUploadRequest{
+		Size: size,
+	}
+	if mtime := metadata["mtime"]; mtime != "" {
+		req.ModTime, err = strconv.ParseInt(mtime, 10, 64)
+		if err != nil {
+			sendAPIResponse(w, r, err, "Invalid modification time", http.StatusBadRequest)
+			return
+		}
+	}
+	connection, err := getUserConnection(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	uploadPath := getTusUploadPath(r, metadata)
+	req.Path = connection.User.GetCleanedPath(uploadPath)
+	if uploadPath == "" || req.Path == "/" {
+		sendAPIResponse(w, r, errors.New("please set a file path"), "", http.StatusBadRequest)
+		return
+	}
+	connection.User.CheckFsRoot(connection.ID) //nolint:errcheck
+	if err := checkChunkedUpload(connection, req); err != nil {
+		sendAPIResponse(w, r, err, fmt.Sprintf("Unable to upload file %q", req.Path), getMappedStatusCode(err))
+		return
+	}
+	upload, err := chunkedUploader.createTusUpload(connection.GetUsername(), req)
+	if err != nil {
+		sendAPIResponse(w, r, err, "Unable to start the upload", http.StatusInternalServerError)
+		return
+	}
+	connection.Log(logger.LevelDebug, "tus upload %q started for file %q, size: %d", upload.ID, upload.Path,
+		upload.Size)
+	if upload.Size == 0 {
+		if err := chunkedUploader.completeTusUpload(connection, upload); err != nil {
+			chunkedUploader.removeTusUpload(upload)
+			sendAPIResponse(w, r, err, fmt.Sprintf("Unable to upload file %q", upload.Path), getMappedStatusCode(err))
+			return
+		}
+	}
+	w.Header().Set("Location", path.Join(userTusUploadsPath, upload.ID))
+	if expires := chunkedUploader.getTusExpiration(upload); expires != "" {
+		w.Header().Set("Upload-Expires", expires)
+	}
+	sendAPIResponse(w, r, nil, "Upload created", http.StatusCreated)
+}
+
+func getTusUploadOffset(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if !checkTusRequest(w, r) {
+		return
+	}
+	connection, upload, err := getTusUploadFromRequest(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	offset, err := chunkedUploader.getTusOffset(upload)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Size, 10))
+	if expires := chunkedUploader.getTusExpiration(upload); expires != "" {
+		w.Header().Set("Upload-Expires", expires)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func uploadTusData(w http.ResponseWriter, r *http.Request) {
+	if !checkTusRequest(w, r) {
+		return
+	}
+	if r.Header.Get("Content-Type") != tusContentType {
+		sendAPIResponse(w, r, fmt.Errorf("invalid content type, expected %q", tusContentType), "",
+			http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		sendAPIResponse(w, r, errors.New("invalid Upload-Offset header"), "", http.StatusBadRequest)
+		return
+	}
+	connection, upload, err := getTusUploadFromRequest(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	if !lockTusUpload(upload) {
+		sendAPIResponse(w, r, nil, "The upload is in progress", http.StatusLocked)
+		return
+	}
+	defer unlockTusUpload(upload)
+
+	transferQuota := connection.GetTransferQuota()
+	if !transferQuota.HasUploadSpace() {
+		connection.Log(logger.LevelInfo, "denying tus upload due to transfer quota limits")
+		sendAPIResponse(w, r, common.ErrQuotaExceeded, "Denying file write due to transfer quota limits",
+			http.StatusRequestEntityTooLarge)
+		return
+	}
+	connection.UpdateLastActivity()
+	newOffset, err := chunkedUploader.appendTusData(upload, offset, r.Body)
+	if err != nil {
+		status := getRespStatus(err)
+		if errors.Is(err, errTusOffsetMismatch) {
+			status = http.StatusConflict
+		}
+		sendAPIResponse(w, r, err, "Unable to save the received data", status)
+		return
+	}
+	if newOffset == upload.Size {
+		if err := chunkedUploader.completeTusUpload(connection, upload); err != nil {
+			sendAPIResponse(w, r, err, fmt.Sprintf("Unable to complete the upload for file %q", upload.Path),
+				getMappedStatusCode(err))
+			return
+		}
+		connection.Log(logger.LevelDebug, "tus upload %q completed for file %q", upload.ID, upload.Path)
+	} else if expires := chunkedUploader.getTusExpiration(upload); expires != "" {
+		w.Header().Set("Upload-Expires", expires)
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteTusUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if !checkTusRequest(w, r) {
+		return
+	}
+	connection, upload, err := getTusUploadFromRequest(w, r)
+	if err != nil {
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	if !lockTusUpload(upload) {
+		sendAPIResponse(w, r, nil, "The upload is in progress", http.StatusLocked)
+		return
+	}
+	defer unlockTusUpload(upload)
+
+	chunkedUploader.removeTusUpload(upload)
+	w.WriteHeader(http.StatusNoContent)
+}
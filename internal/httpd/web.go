@@ -49,12 +49,16 @@ const (
 
 var (
 	errInvalidTokenClaims = errors.New("invalid token claims")
+	errPreconditionFailed = errors.New("the object was modified by another request, reload it and try again")
 )
 
 type commonBasePage struct {
 	CSPNonce  string
 	StaticURL string
 	Version   string
+	// CustomBranding defines the WebClient customizations configured for
+	// the primary group of the user, if any
+	CustomBranding *customBranding
 }
 
 type loginPage struct {
@@ -66,9 +70,11 @@ type loginPage struct {
 	AltLoginName   string
 	ForgotPwdURL   string
 	OpenIDLoginURL string
+	WebAuthnURL    string
 	Title          string
 	Branding       UIBranding
 	FormDisabled   bool
+	CertLogin      bool
 }
 
 type twoFactorPage struct {
@@ -77,6 +83,8 @@ type twoFactorPage struct {
 	Error       *util.I18nError
 	CSRFToken   string
 	RecoveryURL string
+	WebAuthnURL string
+	TOTPEnabled bool
 	Title       string
 	Branding    UIBranding
 }
@@ -90,7 +90,9 @@ const (
 	templateRole             = "role.html"
 	templateEvents           = "events.html"
 	templateStatus           = "status.html"
+	templateDashboard        = "dashboard.html"
 	templateDefender         = "defender.html"
+	templateWebhooks         = "webhooks.html"
 	templateIPLists          = "iplists.html"
 	templateIPList           = "iplist.html"
 	templateConfigs          = "configs.html"
@@ -116,6 +118,7 @@ type basePage struct {
 	AdminsURL           string
 	AdminURL            string
 	QuotaScanURL        string
+	ImpersonateURL      string
 	ConnectionsURL      string
 	GroupsURL           string
 	GroupURL            string
@@ -126,6 +129,7 @@ type basePage struct {
 	IPListsURL          string
 	IPListURL           string
 	EventsURL           string
+	WebhooksURL         string
 	ConfigsURL          string
 	LogoutURL           string
 	LoginURL            string
@@ -140,6 +144,7 @@ type basePage struct {
 	RoleURL             string
 	FolderQuotaScanURL  string
 	StatusURL           string
+	DashboardURL        string
 	MaintenanceURL      string
 	CSRFToken           string
 	IsEventManagerPage  bool
@@ -147,6 +152,7 @@ type basePage struct {
 	IsServerManagerPage bool
 	HasDefender         bool
 	HasSearcher         bool
+	HasWebhooks         bool
 	HasExternalLogin    bool
 	LoggedUser          *dataprovider.Admin
 	Branding            UIBranding
@@ -175,6 +181,7 @@ type userPage struct {
 	ValidLoginMethods  []string
 	ValidProtocols     []string
 	TwoFactorProtocols []string
+	PushMFAProtocols   []string
 	WebClientOptions   []string
 	RootDirPerms       []string
 	Mode               userPageMode
@@ -182,16 +189,20 @@ type userPage struct {
 	Groups             []dataprovider.Group
 	Roles              []dataprovider.Role
 	CanImpersonate     bool
+	ETag               string
 	FsWrapper          fsWrapper
 }
 
 type adminPage struct {
 	basePage
-	Admin  *dataprovider.Admin
-	Groups []dataprovider.Group
-	Roles  []dataprovider.Role
-	Error  *util.I18nError
-	IsAdd  bool
+	Admin      *dataprovider.Admin
+	Groups     []dataprovider.Group
+	Roles      []dataprovider.Role
+	AdminRoles []dataprovider.AdminRole
+	Error      *util.I18nError
+	IsAdd      bool
+	// WebAuthn can be required only for admins with registered credentials
+	WebAuthnEnabled bool
 }
 
 type profilePage struct {
@@ -215,6 +226,9 @@ type mfaPage struct {
 	ValidateTOTPURL string
 	SaveTOTPURL     string
 	RecCodesURL     string
+	// WebAuthnURL is empty if WebAuthn is disabled
+	WebAuthnURL         string
+	WebAuthnCredentials dataprovider.WebAuthnCredentials
 }
 
 type maintenancePage struct {
@@ -229,6 +243,11 @@ type defenderHostsPage struct {
 	DefenderHostsURL string
 }
 
+type webhooksPage struct {
+	basePage
+	DeliveriesURL string
+}
+
 type ipListsPage struct {
 	basePage
 	IPListsSearchURL      string
@@ -262,6 +281,7 @@ type folderPage struct {
 	Folder    vfs.BaseVirtualFolder
 	Error     *util.I18nError
 	Mode      folderPageMode
+	ETag      string
 	FsWrapper fsWrapper
 }
 
@@ -276,6 +296,8 @@ type groupPage struct {
 	TwoFactorProtocols []string
 	WebClientOptions   []string
 	VirtualFolders     []vfs.BaseVirtualFolder
+	Groups             []dataprovider.Group
+	ETag               string
 	FsWrapper          fsWrapper
 }
 
@@ -309,6 +331,7 @@ type eventRulePage struct {
 	Error           *util.I18nError
 	Mode            genericPageMode
 	IsShared        bool
+	ETag            string
 }
 
 type eventsPage struct {
@@ -322,6 +345,7 @@ type configsPage struct {
 	basePage
 	Configs           dataprovider.Configs
 	ConfigSection     int
+	AdminPermissions  []dataprovider.AdminPermissionEntry
 	RedactedSecret    string
 	OAuth2TokenURL    string
 	OAuth2RedirectURL string
@@ -335,12 +359,6 @@ type messagePage struct {
 	Text    string
 }
 
-type userTemplateFields struct {
-	Username   string
-	Password   string
-	PublicKeys []string
-}
-
 func loadAdminTemplates(templatesPath string) {
 	usersPaths := []string{
 		filepath.Join(templatesPath, templateCommonDir, templateCommonBase),
@@ -430,6 +448,11 @@ func loadAdminTemplates(templatesPath string) {
 		filepath.Join(templatesPath, templateAdminDir, templateBase),
 		filepath.Join(templatesPath, templateAdminDir, templateStatus),
 	}
+	dashboardPaths := []string{
+		filepath.Join(templatesPath, templateCommonDir, templateCommonBase),
+		filepath.Join(templatesPath, templateAdminDir, templateBase),
+		filepath.Join(templatesPath, templateAdminDir, templateDashboard),
+	}
 	loginPaths := []string{
 		filepath.Join(templatesPath, templateCommonDir, templateCommonBase),
 		filepath.Join(templatesPath, templateCommonDir, templateCommonBaseLogin),
@@ -445,6 +468,11 @@ func loadAdminTemplates(templatesPath string) {
 		filepath.Join(templatesPath, templateAdminDir, templateBase),
 		filepath.Join(templatesPath, templateAdminDir, templateDefender),
 	}
+	webhooksPaths := []string{
+		filepath.Join(templatesPath, templateCommonDir, templateCommonBase),
+		filepath.Join(templatesPath, templateAdminDir, templateBase),
+		filepath.Join(templatesPath, templateAdminDir, templateWebhooks),
+	}
 	ipListsPaths := []string{
 		filepath.Join(templatesPath, templateCommonDir, templateCommonBase),
 		filepath.Join(templatesPath, templateAdminDir, templateBase),
@@ -535,11 +563,13 @@ func loadAdminTemplates(templatesPath string) {
 	eventActionsTmpl := util.LoadTemplate(nil, eventActionsPaths...)
 	eventActionTmpl := util.LoadTemplate(nil, eventActionPaths...)
 	statusTmpl := util.LoadTemplate(nil, statusPaths...)
+	dashboardTmpl := util.LoadTemplate(nil, dashboardPaths...)
 	loginTmpl := util.LoadTemplate(nil, loginPaths...)
 	profileTmpl := util.LoadTemplate(nil, profilePaths...)
 	changePwdTmpl := util.LoadTemplate(nil, changePwdPaths...)
 	maintenanceTmpl := util.LoadTemplate(nil, maintenancePaths...)
 	defenderTmpl := util.LoadTemplate(nil, defenderPaths...)
+	webhooksTmpl := util.LoadTemplate(nil, webhooksPaths...)
 	ipListsTmpl := util.LoadTemplate(nil, ipListsPaths...)
 	ipListTmpl := util.LoadTemplate(nil, ipListPaths...)
 	mfaTmpl := util.LoadTemplate(nil, mfaPaths...)
@@ -568,11 +598,13 @@ func loadAdminTemplates(templatesPath string) {
 	adminTemplates[templateEventActions] = eventActionsTmpl
 	adminTemplates[templateEventAction] = eventActionTmpl
 	adminTemplates[templateStatus] = statusTmpl
+	adminTemplates[templateDashboard] = dashboardTmpl
 	adminTemplates[templateCommonLogin] = loginTmpl
 	adminTemplates[templateProfile] = profileTmpl
 	adminTemplates[templateChangePwd] = changePwdTmpl
 	adminTemplates[templateMaintenance] = maintenanceTmpl
 	adminTemplates[templateDefender] = defenderTmpl
+	adminTemplates[templateWebhooks] = webhooksTmpl
 	adminTemplates[templateIPLists] = ipListsTmpl
 	adminTemplates[templateIPList] = ipListTmpl
 	adminTemplates[templateMFA] = mfaTmpl
@@ -618,7 +650,7 @@ func isIPListsResource(currentURL string) bool {
 
 func isServerManagerResource(currentURL string) bool {
 	return currentURL == webEventsPath || currentURL == webStatusPath || currentURL == webMaintenancePath ||
-		currentURL == webConfigsPath
+		currentURL == webConfigsPath || currentURL == webWebhooksPath
 }
 
 func (s *httpdServer) getBasePageData(title, currentURL string, r *http.Request) basePage {
@@ -644,6 +676,7 @@ func (s *httpdServer) getBasePageData(title, currentURL string, r *http.Request)
 		IPListsURL:          webIPListsPath,
 		IPListURL:           webIPListPath,
 		EventsURL:           webEventsPath,
+		WebhooksURL:         webWebhooksPath,
 		ConfigsURL:          webConfigsPath,
 		LogoutURL:           webLogoutPath,
 		LoginURL:            webAdminLoginPath,
@@ -657,8 +690,10 @@ func (s *httpdServer) getBasePageData(title, currentURL string, r *http.Request)
 		RolesURL:            webAdminRolesPath,
 		RoleURL:             webAdminRolePath,
 		QuotaScanURL:        webQuotaScanPath,
+		ImpersonateURL:      webImpersonateUserPath,
 		ConnectionsURL:      webConnectionsPath,
 		StatusURL:           webStatusPath,
+		DashboardURL:        webDashboardPath,
 		FolderQuotaScanURL:  webScanVFolderPath,
 		MaintenanceURL:      webMaintenancePath,
 		LoggedUser:          getAdminFromToken(r),
@@ -667,6 +702,7 @@ func (s *httpdServer) getBasePageData(title, currentURL string, r *http.Request)
 		IsServerManagerPage: isServerManagerResource(currentURL),
 		HasDefender:         common.Config.DefenderConfig.Enabled,
 		HasSearcher:         plugin.Handler.HasSearcher(),
+		HasWebhooks:         dataprovider.IsWebhooksEnabled(),
 		HasExternalLogin:    isLoggedInWithOIDC(r),
 		CSRFToken:           csrfToken,
 		Branding:            s.binding.Branding.WebAdmin,
@@ -753,8 +789,17 @@ func (s *httpdServer) renderTwoFactorPage(w http.ResponseWriter, r *http.Request
 		Error:          err,
 		CSRFToken:      createCSRFToken(ip),
 		RecoveryURL:    webAdminTwoFactorRecoveryPath,
+		TOTPEnabled:    true,
 		Branding:       s.binding.Branding.WebAdmin,
 	}
+	if claims, errClaims := getTokenClaims(r); errClaims == nil {
+		if admin, errAdmin := dataprovider.AdminExists(claims.Username); errAdmin == nil {
+			data.TOTPEnabled = admin.Filters.TOTPConfig.Enabled && !admin.MustUseWebAuthn()
+			if admin.HasWebAuthn() {
+				data.WebAuthnURL = webAdminWebAuthnPath + "/twofactor"
+			}
+		}
+	}
 	renderAdminTemplate(w, templateTwoFactor, data)
 }
 
@@ -785,6 +830,10 @@ func (s *httpdServer) renderMFAPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	data.TOTPConfig = admin.Filters.TOTPConfig
+	if mfa.IsWebAuthnEnabled() {
+		data.WebAuthnURL = webAdminWebAuthnPath
+		data.WebAuthnCredentials = admin.Filters.WebAuthnCredentials
+	}
 	renderAdminTemplate(w, templateMFA, data)
 }
 
@@ -841,6 +890,7 @@ func (s *httpdServer) renderConfigsPage(w http.ResponseWriter, r *http.Request,
 		basePage:          s.getBasePageData(util.I18nConfigsTitle, webConfigsPath, r),
 		Configs:           configs,
 		ConfigSection:     section,
+		AdminPermissions:  dataprovider.GetAdminPermissionEntries(),
 		RedactedSecret:    redactedSecret,
 		OAuth2TokenURL:    webOAuth2TokenPath,
 		OAuth2RedirectURL: webOAuth2RedirectPath,
@@ -877,6 +927,11 @@ func (s *httpdServer) renderAddUpdateAdminPage(w http.ResponseWriter, r *http.Re
 	if errRoles != nil {
 		return
 	}
+	adminRoles, errAdminRoles := dataprovider.GetAdminRoles()
+	if errAdminRoles != nil {
+		s.renderInternalServerErrorPage(w, r, errAdminRoles)
+		return
+	}
 	currentURL := webAdminPath
 	title := util.I18nAddAdminTitle
 	if !isAdd {
@@ -884,12 +939,15 @@ func (s *httpdServer) renderAddUpdateAdminPage(w http.ResponseWriter, r *http.Re
 		title = util.I18nUpdateAdminTitle
 	}
 	data := adminPage{
-		basePage: s.getBasePageData(title, currentURL, r),
-		Admin:    admin,
-		Groups:   groups,
-		Roles:    roles,
-		Error:    getI18nError(err),
-		IsAdd:    isAdd,
+		basePage:   s.getBasePageData(title, currentURL, r),
+		Admin:      admin,
+		Groups:     groups,
+		Roles:      roles,
+		AdminRoles: adminRoles,
+		Error:      getI18nError(err),
+		IsAdd:      isAdd,
+
+		WebAuthnEnabled: mfa.IsWebAuthnEnabled(),
 	}
 
 	renderAdminTemplate(w, templateAdmin, data)
@@ -974,6 +1032,12 @@ func (s *httpdServer) renderUserPage(w http.ResponseWriter, r *http.Request, use
 			DirPath:         user.HomeDir,
 		},
 	}
+	if mode == userPageModeUpdate {
+		data.ETag = getWebETag(r, getUserETag(user))
+	}
+	if mfa.IsPushEnabled() {
+		data.PushMFAProtocols = dataprovider.PushMFAProtocols
+	}
 	renderAdminTemplate(w, templateUser, data)
 }
 
@@ -1026,6 +1090,10 @@ func (s *httpdServer) renderGroupPage(w http.ResponseWriter, r *http.Request, gr
 	if errFolders != nil {
 		return
 	}
+	groups, errGroups := s.getWebGroups(w, r, defaultQueryLimit, true)
+	if errGroups != nil {
+		return
+	}
 	group.SetEmptySecretsIfNil()
 	group.UserSettings.FsConfig.RedactedSecret = redactedSecret
 	var title, currentURL string
@@ -1051,6 +1119,7 @@ func (s *httpdServer) renderGroupPage(w http.ResponseWriter, r *http.Request, gr
 		TwoFactorProtocols: dataprovider.MFAProtocols,
 		WebClientOptions:   sdk.WebClientOptions,
 		VirtualFolders:     folders,
+		Groups:             groups,
 		FsWrapper: fsWrapper{
 			Filesystem:      group.UserSettings.FsConfig,
 			IsUserPage:      false,
@@ -1059,6 +1128,9 @@ func (s *httpdServer) renderGroupPage(w http.ResponseWriter, r *http.Request, gr
 			DirPath:         group.UserSettings.HomeDir,
 		},
 	}
+	if mode == genericPageModeUpdate {
+		data.ETag = getWebETag(r, getGroupETag(&group))
+	}
 	renderAdminTemplate(w, templateGroup, data)
 }
 
@@ -1128,6 +1200,9 @@ func (s *httpdServer) renderEventRulePage(w http.ResponseWriter, r *http.Request
 		Mode:            mode,
 		IsShared:        s.isShared > 0,
 	}
+	if mode == genericPageModeUpdate {
+		data.ETag = getWebETag(r, getEventRuleETag(&rule))
+	}
 	renderAdminTemplate(w, templateEventRule, data)
 }
 
@@ -1162,6 +1237,9 @@ func (s *httpdServer) renderFolderPage(w http.ResponseWriter, r *http.Request, f
 			DirPath:         folder.MappedPath,
 		},
 	}
+	if mode == folderPageModeUpdate {
+		data.ETag = getWebETag(r, getFolderETag(&folder))
+	}
 	renderAdminTemplate(w, templateFolder, data)
 }
 
@@ -1188,8 +1266,8 @@ func getFoldersForTemplate(r *http.Request) []string {
 	return res
 }
 
-func getUsersForTemplate(r *http.Request) []userTemplateFields {
-	var res []userTemplateFields
+func getUsersForTemplate(r *http.Request) []dataprovider.UserTemplateFields {
+	var res []dataprovider.UserTemplateFields
 	tplUsernames := r.Form["tpl_username"]
 	tplPasswords := r.Form["tpl_password"]
 	tplPublicKeys := r.Form["tpl_public_keys"]
@@ -1213,7 +1291,7 @@ func getUsersForTemplate(r *http.Request) []userTemplateFields {
 		}
 
 		users[username] = true
-		res = append(res, userTemplateFields{
+		res = append(res, dataprovider.UserTemplateFields{
 			Username:   username,
 			Password:   password,
 			PublicKeys: []string{publicKey},
@@ -1262,6 +1340,20 @@ func getVirtualFoldersFromPostFields(r *http.Request) []vfs.VirtualFolder {
 	return virtualFolders
 }
 
+// preserveVirtualFoldersBandwidth copies the bandwidth limits, not managed in the web forms,
+// from the existing virtual folders to the updated ones with the same name
+func preserveVirtualFoldersBandwidth(updated, existing []vfs.VirtualFolder) {
+	for idx := range updated {
+		for _, folder := range existing {
+			if folder.Name == updated[idx].Name {
+				updated[idx].UploadBandwidth = folder.UploadBandwidth
+				updated[idx].DownloadBandwidth = folder.DownloadBandwidth
+				break
+			}
+		}
+	}
+}
+
 func getSubDirPermissionsFromPostFields(r *http.Request) map[string][]string {
 	permissions := make(map[string][]string)
 
@@ -1531,9 +1623,54 @@ func getS3Config(r *http.Request) (vfs.S3FsConfig, error) {
 	if err != nil {
 		return config, fmt.Errorf("invalid s3 upload part max time: %w", err)
 	}
+	uploadMaxMemory, err := strconv.ParseInt(r.Form.Get("s3_upload_max_memory"), 10, 64)
+	if err == nil {
+		config.UploadMaxMemory = uploadMaxMemory
+	}
+	config.ObjectLockMode = strings.TrimSpace(r.Form.Get("s3_object_lock_mode"))
+	if config.ObjectLockMode != "" {
+		config.ObjectLockRetentionDays, err = strconv.Atoi(r.Form.Get("s3_object_lock_retention_days"))
+		if err != nil {
+			return config, fmt.Errorf("invalid s3 object lock retention days: %w", err)
+		}
+	}
+	restoreDays, err := strconv.Atoi(r.Form.Get("s3_restore_days"))
+	if err == nil {
+		config.RestoreDays = restoreDays
+	}
+	config.RestoreTier = strings.TrimSpace(r.Form.Get("s3_restore_tier"))
+	config.RequesterPays = r.Form.Get("s3_requester_pays") != ""
+	config.SSEKMSKeyID = strings.TrimSpace(r.Form.Get("s3_sse_kms_key_id"))
+	config.FailoverEndpoints = getS3FailoverEndpointsFromPostField(r.Form.Get("s3_failover_endpoints"))
 	return config, nil
 }
 
+// getS3FailoverEndpointsFromPostField parses the failover endpoints, one per line
+// in the format "endpoint,region". Both the endpoint and the region are optional
+func getS3FailoverEndpointsFromPostField(value string) []vfs.S3FailoverEndpoint {
+	var result []vfs.S3FailoverEndpoint
+	for _, line := range getSliceFromDelimitedValues(value, "\n") {
+		endpoint, region, _ := strings.Cut(line, ",")
+		result = append(result, vfs.S3FailoverEndpoint{
+			Endpoint: strings.TrimSpace(endpoint),
+			Region:   strings.TrimSpace(region),
+		})
+	}
+	return result
+}
+
+func getAzTierRulesFromPostField(value string) []vfs.AzBlobTierRule {
+	var result []vfs.AzBlobTierRule
+	for _, line := range getSliceFromDelimitedValues(value, "\n") {
+		p, tier, _ := strings.Cut(line, ",")
+		result = append(result, vfs.AzBlobTierRule{
+			Path: strings.TrimSpace(p),
+			Tier: strings.TrimSpace(tier),
+		})
+	}
+	return result
+}
+
 func getGCSConfig(r *http.Request) (vfs.GCSFsConfig, error) {
 	var err error
 	config := vfs.GCSFsConfig{}
@@ -1541,6 +1678,8 @@ func getGCSConfig(r *http.Request) (vfs.GCSFsConfig, error) {
 	config.Bucket = strings.TrimSpace(r.Form.Get("gcs_bucket"))
 	config.StorageClass = strings.TrimSpace(r.Form.Get("gcs_storage_class"))
 	config.ACL = strings.TrimSpace(r.Form.Get("gcs_acl"))
+	config.KMSKeyName = strings.TrimSpace(r.Form.Get("gcs_kms_key_name"))
+	config.UserProject = strings.TrimSpace(r.Form.Get("gcs_user_project"))
 	config.KeyPrefix = strings.TrimSpace(strings.TrimPrefix(r.Form.Get("gcs_key_prefix"), "/"))
 	uploadPartSize, err := strconv.ParseInt(r.Form.Get("gcs_upload_part_size"), 10, 64)
 	if err == nil {
@@ -1605,6 +1744,8 @@ func getHTTPFsConfig(r *http.Request) vfs.HTTPFsConfig {
 	config.Endpoint = strings.TrimSpace(r.Form.Get("http_endpoint"))
 	config.Username = strings.TrimSpace(r.Form.Get("http_username"))
 	config.SkipTLSVerify = r.Form.Get("http_skip_tls_verify") != ""
+	config.UseRangeRequests = r.Form.Get("http_use_range_requests") != ""
+	config.TusUploads = r.Form.Get("http_tus_uploads") != ""
 	config.Password = getSecretFromFormField(r, "http_password")
 	config.APIKey = getSecretFromFormField(r, "http_api_key")
 	if r.Form.Get("http_equality_check_mode") != "" {
@@ -1642,6 +1783,9 @@ func getAzureConfig(r *http.Request) (vfs.AzBlobFsConfig, error) {
 	if err != nil {
 		return config, fmt.Errorf("invalid azure download concurrency: %w", err)
 	}
+	config.RehydrateTier = strings.TrimSpace(r.Form.Get("az_rehydrate_tier"))
+	config.RehydratePriority = strings.TrimSpace(r.Form.Get("az_rehydrate_priority"))
+	config.TierRules = getAzTierRulesFromPostField(r.Form.Get("az_tier_rules"))
 	return config, nil
 }
 
@@ -1694,6 +1838,12 @@ func getFsConfigFromPostFields(r *http.Request) (vfs.Filesystem, error) {
 	case sdk.HTTPFilesystemProvider:
 		fs.HTTPConfig = getHTTPFsConfig(r)
 	}
+	if fs.Provider != sdk.CryptedFilesystemProvider {
+		fs.CompressConfig = vfs.CompressFsConfig{
+			Algo:           strings.TrimSpace(r.Form.Get("compress_algo")),
+			SkipExtensions: getSliceFromDelimitedValues(r.Form.Get("compress_skip_extensions"), ","),
+		}
+	}
 	return fs, nil
 }
 
@@ -1735,11 +1885,16 @@ func getAdminFromPostFields(r *http.Request) (dataprovider.Admin, error) {
 	admin.Username = strings.TrimSpace(r.Form.Get("username"))
 	admin.Password = strings.TrimSpace(r.Form.Get("password"))
 	admin.Permissions = r.Form["permissions"]
+	admin.Filters.AdminRoles = r.Form["admin_roles"]
 	admin.Email = strings.TrimSpace(r.Form.Get("email"))
 	admin.Status = status
 	admin.Role = strings.TrimSpace(r.Form.Get("role"))
 	admin.Filters.AllowList = getSliceFromDelimitedValues(r.Form.Get("allowed_ip"), ",")
+	admin.Filters.UserSelectors = getSliceFromDelimitedValues(r.Form.Get("user_selectors"), ",")
 	admin.Filters.AllowAPIKeyAuth = r.Form.Get("allow_api_key_auth") != ""
+	admin.Filters.RequireWebAuthn = r.Form.Get("require_webauthn") != ""
+	admin.Filters.TLSUsername = sdk.TLSUsername(strings.TrimSpace(r.Form.Get("tls_username")))
+	admin.Filters.TLSCertAndPassword = r.Form.Get("tls_cert_and_password") != ""
 	admin.AdditionalInfo = r.Form.Get("additional_info")
 	admin.Description = r.Form.Get("description")
 	admin.Filters.Preferences.HideUserPageSections = getAdminHiddenUserPageSections(r)
@@ -1775,126 +1930,8 @@ func getAdminFromPostFields(r *http.Request) (dataprovider.Admin, error) {
 	return admin, nil
 }
 
-func replacePlaceholders(field string, replacements map[string]string) string {
-	for k, v := range replacements {
-		field = strings.ReplaceAll(field, k, v)
-	}
-	return field
-}
-
-func getFolderFromTemplate(folder vfs.BaseVirtualFolder, name string) vfs.BaseVirtualFolder {
-	folder.Name = name
-	replacements := make(map[string]string)
-	replacements["%name%"] = folder.Name
-
-	folder.MappedPath = replacePlaceholders(folder.MappedPath, replacements)
-	folder.Description = replacePlaceholders(folder.Description, replacements)
-	switch folder.FsConfig.Provider {
-	case sdk.CryptedFilesystemProvider:
-		folder.FsConfig.CryptConfig = getCryptFsFromTemplate(folder.FsConfig.CryptConfig, replacements)
-	case sdk.S3FilesystemProvider:
-		folder.FsConfig.S3Config = getS3FsFromTemplate(folder.FsConfig.S3Config, replacements)
-	case sdk.GCSFilesystemProvider:
-		folder.FsConfig.GCSConfig = getGCSFsFromTemplate(folder.FsConfig.GCSConfig, replacements)
-	case sdk.AzureBlobFilesystemProvider:
-		folder.FsConfig.AzBlobConfig = getAzBlobFsFromTemplate(folder.FsConfig.AzBlobConfig, replacements)
-	case sdk.SFTPFilesystemProvider:
-		folder.FsConfig.SFTPConfig = getSFTPFsFromTemplate(folder.FsConfig.SFTPConfig, replacements)
-	case sdk.HTTPFilesystemProvider:
-		folder.FsConfig.HTTPConfig = getHTTPFsFromTemplate(folder.FsConfig.HTTPConfig, replacements)
-	}
-
-	return folder
-}
-
-func getCryptFsFromTemplate(fsConfig vfs.CryptFsConfig, replacements map[string]string) vfs.CryptFsConfig {
-	if fsConfig.Passphrase != nil {
-		if fsConfig.Passphrase.IsPlain() {
-			payload := replacePlaceholders(fsConfig.Passphrase.GetPayload(), replacements)
-			fsConfig.Passphrase = kms.NewPlainSecret(payload)
-		}
-	}
-	return fsConfig
-}
-
-func getS3FsFromTemplate(fsConfig vfs.S3FsConfig, replacements map[string]string) vfs.S3FsConfig {
-	fsConfig.KeyPrefix = replacePlaceholders(fsConfig.KeyPrefix, replacements)
-	fsConfig.AccessKey = replacePlaceholders(fsConfig.AccessKey, replacements)
-	if fsConfig.AccessSecret != nil && fsConfig.AccessSecret.IsPlain() {
-		payload := replacePlaceholders(fsConfig.AccessSecret.GetPayload(), replacements)
-		fsConfig.AccessSecret = kms.NewPlainSecret(payload)
-	}
-	return fsConfig
-}
-
-func getGCSFsFromTemplate(fsConfig vfs.GCSFsConfig, replacements map[string]string) vfs.GCSFsConfig {
-	fsConfig.KeyPrefix = replacePlaceholders(fsConfig.KeyPrefix, replacements)
-	return fsConfig
-}
-
-func getAzBlobFsFromTemplate(fsConfig vfs.AzBlobFsConfig, replacements map[string]string) vfs.AzBlobFsConfig {
-	fsConfig.KeyPrefix = replacePlaceholders(fsConfig.KeyPrefix, replacements)
-	fsConfig.AccountName = replacePlaceholders(fsConfig.AccountName, replacements)
-	if fsConfig.AccountKey != nil && fsConfig.AccountKey.IsPlain() {
-		payload := replacePlaceholders(fsConfig.AccountKey.GetPayload(), replacements)
-		fsConfig.AccountKey = kms.NewPlainSecret(payload)
-	}
-	return fsConfig
-}
-
-func getSFTPFsFromTemplate(fsConfig vfs.SFTPFsConfig, replacements map[string]string) vfs.SFTPFsConfig {
-	fsConfig.Prefix = replacePlaceholders(fsConfig.Prefix, replacements)
-	fsConfig.Username = replacePlaceholders(fsConfig.Username, replacements)
-	if fsConfig.Password != nil && fsConfig.Password.IsPlain() {
-		payload := replacePlaceholders(fsConfig.Password.GetPayload(), replacements)
-		fsConfig.Password = kms.NewPlainSecret(payload)
-	}
-	return fsConfig
-}
-
-func getHTTPFsFromTemplate(fsConfig vfs.HTTPFsConfig, replacements map[string]string) vfs.HTTPFsConfig {
-	fsConfig.Username = replacePlaceholders(fsConfig.Username, replacements)
-	return fsConfig
-}
-
-func getUserFromTemplate(user dataprovider.User, template userTemplateFields) dataprovider.User {
-	user.Username = template.Username
-	user.Password = template.Password
-	user.PublicKeys = template.PublicKeys
-	replacements := make(map[string]string)
-	replacements["%username%"] = user.Username
-	if user.Password != "" && !user.IsPasswordHashed() {
-		user.Password = replacePlaceholders(user.Password, replacements)
-		replacements["%password%"] = user.Password
-	}
-
-	user.HomeDir = replacePlaceholders(user.HomeDir, replacements)
-	var vfolders []vfs.VirtualFolder
-	for _, vfolder := range user.VirtualFolders {
-		vfolder.Name = replacePlaceholders(vfolder.Name, replacements)
-		vfolder.VirtualPath = replacePlaceholders(vfolder.VirtualPath, replacements)
-		vfolders = append(vfolders, vfolder)
-	}
-	user.VirtualFolders = vfolders
-	user.Description = replacePlaceholders(user.Description, replacements)
-	user.AdditionalInfo = replacePlaceholders(user.AdditionalInfo, replacements)
-	user.Filters.StartDirectory = replacePlaceholders(user.Filters.StartDirectory, replacements)
-
-	switch user.FsConfig.Provider {
-	case sdk.CryptedFilesystemProvider:
-		user.FsConfig.CryptConfig = getCryptFsFromTemplate(user.FsConfig.CryptConfig, replacements)
-	case sdk.S3FilesystemProvider:
-		user.FsConfig.S3Config = getS3FsFromTemplate(user.FsConfig.S3Config, replacements)
-	case sdk.GCSFilesystemProvider:
-		user.FsConfig.GCSConfig = getGCSFsFromTemplate(user.FsConfig.GCSConfig, replacements)
-	case sdk.AzureBlobFilesystemProvider:
-		user.FsConfig.AzBlobConfig = getAzBlobFsFromTemplate(user.FsConfig.AzBlobConfig, replacements)
-	case sdk.SFTPFilesystemProvider:
-		user.FsConfig.SFTPConfig = getSFTPFsFromTemplate(user.FsConfig.SFTPConfig, replacements)
-	case sdk.HTTPFilesystemProvider:
-		user.FsConfig.HTTPConfig = getHTTPFsFromTemplate(user.FsConfig.HTTPConfig, replacements)
-	}
-
+func getUserFromTemplate(user dataprovider.User, template dataprovider.UserTemplateFields) dataprovider.User {
+	user.ApplyTemplate(template)
 	return user
 }
 
@@ -1971,6 +2008,12 @@ func updateRepeaterFormFields(r *http.Request) {
 			r.Form.Add("download_bandwidth_source", strings.TrimSpace(r.Form.Get(base+"[download_bandwidth_source]")))
 			continue
 		}
+		if hasPrefixAndSuffix(k, "attributes[", "][attribute_key]") {
+			base, _ := strings.CutSuffix(k, "[attribute_key]")
+			r.Form.Add("attribute_key", strings.TrimSpace(r.Form.Get(k)))
+			r.Form.Add("attribute_value", r.Form.Get(base+"[attribute_value]"))
+			continue
+		}
 		if hasPrefixAndSuffix(k, "template_users[", "][tpl_username]") {
 			base, _ := strings.CutSuffix(k, "[tpl_username]")
 			r.Form.Add("tpl_username", strings.TrimSpace(r.Form.Get(k)))
@@ -2068,10 +2111,16 @@ func getUserFromPostFields(r *http.Request) (dataprovider.User, error) {
 		Filters: dataprovider.UserFilters{
 			BaseUserFilters:       filters,
 			RequirePasswordChange: r.Form.Get("require_password_change") != "",
+			PushMFA: dataprovider.UserPushMFAConfig{
+				Enabled:   r.Form.Get("push_mfa_enabled") != "",
+				Username:  strings.TrimSpace(r.Form.Get("push_mfa_username")),
+				Protocols: r.Form["push_mfa_protocols"],
+			},
 		},
 		VirtualFolders: getVirtualFoldersFromPostFields(r),
 		FsConfig:       fsConfig,
 		Groups:         getGroupsFromUserPostFields(r),
+		Attributes:     getAttributesFromPostFields(r),
 	}
 	return user, nil
 }
@@ -2141,6 +2190,7 @@ func getGroupFromPostFields(r *http.Request) (dataprovider.Group, error) {
 			FsConfig: fsConfig,
 		},
 		VirtualFolders: getVirtualFoldersFromPostFields(r),
+		IncludedGroups: r.Form["included_groups"],
 	}
 	return group, nil
 }
@@ -2164,6 +2214,19 @@ func getKeyValsFromPostFields(r *http.Request, key, val string) []dataprovider.K
 	return res
 }
 
+func getAttributesFromPostFields(r *http.Request) map[string]string {
+	var res map[string]string
+
+	for _, kv := range getKeyValsFromPostFields(r, "attribute_key", "attribute_value") {
+		if res == nil {
+			res = make(map[string]string)
+		}
+		res[kv.Key] = kv.Value
+	}
+
+	return res
+}
+
 func getFoldersRetentionFromPostFields(r *http.Request) ([]dataprovider.FolderRetention, error) {
 	var res []dataprovider.FolderRetention
 	paths := r.Form["folder_retention_path"]
@@ -2188,6 +2251,36 @@ func getFoldersRetentionFromPostFields(r *http.Request) ([]dataprovider.FolderRe
 	return res, nil
 }
 
+func getFoldersTieringFromPostFields(r *http.Request) ([]dataprovider.FolderTiering, error) {
+	var res []dataprovider.FolderTiering
+	sources := r.Form["tiering_source_folder"]
+	targets := r.Form["tiering_target_folder"]
+	ages := r.Form["tiering_min_age"]
+
+	for idx, source := range sources {
+		if source != "" {
+			minAge, err := strconv.Atoi(ages[idx])
+			if err != nil {
+				return nil, fmt.Errorf("invalid min age for folder %q: %w", source, err)
+			}
+			opts := r.Form["tiering_options"+strconv.Itoa(idx)]
+			ageMode := dataprovider.TieringAgeModified
+			if util.Contains(opts, "1") {
+				ageMode = dataprovider.TieringAgeAccessed
+			}
+			res = append(res, dataprovider.FolderTiering{
+				SourceFolder: source,
+				TargetFolder: targets[idx],
+				MinAge:       minAge,
+				AgeMode:      ageMode,
+				LeaveStub:    util.Contains(opts, "2"),
+			})
+		}
+	}
+
+	return res, nil
+}
+
 func getHTTPPartsFromPostFields(r *http.Request) []dataprovider.HTTPPart {
 	var result []dataprovider.HTTPPart
 
@@ -2270,6 +2363,15 @@ func updateRepeaterFormActionFields(r *http.Request) {
 				r.Form[base+"[folder_retention_options][]"]
 			continue
 		}
+		if hasPrefixAndSuffix(k, "tiering_policies[", "][tiering_source_folder]") {
+			base, _ := strings.CutSuffix(k, "[tiering_source_folder]")
+			r.Form.Add("tiering_source_folder", strings.TrimSpace(r.Form.Get(k)))
+			r.Form.Add("tiering_target_folder", strings.TrimSpace(r.Form.Get(base+"[tiering_target_folder]")))
+			r.Form.Add("tiering_min_age", strings.TrimSpace(r.Form.Get(base+"[tiering_min_age]")))
+			r.Form["tiering_options"+strconv.Itoa(len(r.Form["tiering_source_folder"])-1)] =
+				r.Form[base+"[tiering_options][]"]
+			continue
+		}
 		if hasPrefixAndSuffix(k, "fs_rename[", "][fs_rename_source]") {
 			base, _ := strings.CutSuffix(k, "[fs_rename_source]")
 			r.Form.Add("fs_rename_source", strings.TrimSpace(r.Form.Get(k)))
@@ -2299,6 +2401,10 @@ func getEventActionOptionsFromPostFields(r *http.Request) (dataprovider.BaseEven
 	if err != nil {
 		return dataprovider.BaseEventActionOptions{}, err
 	}
+	foldersTiering, err := getFoldersTieringFromPostFields(r)
+	if err != nil {
+		return dataprovider.BaseEventActionOptions{}, err
+	}
 	fsActionType, err := strconv.Atoi(r.Form.Get("fs_action_type"))
 	if err != nil {
 		return dataprovider.BaseEventActionOptions{}, fmt.Errorf("invalid fs action type: %w", err)
@@ -2307,10 +2413,59 @@ func getEventActionOptionsFromPostFields(r *http.Request) (dataprovider.BaseEven
 	if err != nil {
 		return dataprovider.BaseEventActionOptions{}, fmt.Errorf("invalid password expiration threshold: %w", err)
 	}
+	backupRetention, err := strconv.Atoi(r.Form.Get("backup_retention"))
+	if err != nil {
+		return dataprovider.BaseEventActionOptions{}, fmt.Errorf("invalid backup retention: %w", err)
+	}
 	var emailAttachments []string
 	if r.Form.Get("email_attachments") != "" {
 		emailAttachments = getSliceFromDelimitedValues(r.Form.Get("email_attachments"), ",")
 	}
+	var chatPlatform int
+	if val := r.Form.Get("chat_platform"); val != "" {
+		chatPlatform, err = strconv.Atoi(val)
+		if err != nil {
+			return dataprovider.BaseEventActionOptions{}, fmt.Errorf("invalid chat platform: %w", err)
+		}
+	}
+	var messageProtocol int
+	if val := r.Form.Get("message_protocol"); val != "" {
+		messageProtocol, err = strconv.Atoi(val)
+		if err != nil {
+			return dataprovider.BaseEventActionOptions{}, fmt.Errorf("invalid messaging protocol: %w", err)
+		}
+	}
+	var messageAcks, messageSASLMechanism int
+	if val := r.Form.Get("message_acks"); val != "" {
+		messageAcks, err = strconv.Atoi(val)
+		if err != nil {
+			return dataprovider.BaseEventActionOptions{}, fmt.Errorf("invalid Kafka acks: %w", err)
+		}
+	}
+	if val := r.Form.Get("message_sasl_mechanism"); val != "" {
+		messageSASLMechanism, err = strconv.Atoi(val)
+		if err != nil {
+			return dataprovider.BaseEventActionOptions{}, fmt.Errorf("invalid Kafka SASL mechanism: %w", err)
+		}
+	}
+	var cloudQueueService int
+	if val := r.Form.Get("cloud_queue_service"); val != "" {
+		cloudQueueService, err = strconv.Atoi(val)
+		if err != nil {
+			return dataprovider.BaseEventActionOptions{}, fmt.Errorf("invalid cloud queue service: %w", err)
+		}
+	}
+	var usageReportGroupBy int
+	if val := r.Form.Get("usage_report_group_by"); val != "" {
+		usageReportGroupBy, err = strconv.Atoi(val)
+		if err != nil {
+			return dataprovider.BaseEventActionOptions{}, fmt.Errorf("invalid usage report grouping: %w", err)
+		}
+	}
+	var emailInlineImages []string
+	if r.Form.Get("email_inline_images") != "" {
+		emailInlineImages = getSliceFromDelimitedValues(r.Form.Get("email_inline_images"), ",")
+	}
 	var cmdArgs []string
 	if r.Form.Get("cmd_arguments") != "" {
 		cmdArgs = getSliceFromDelimitedValues(r.Form.Get("cmd_arguments"), ",")
@@ -2343,12 +2498,13 @@ func getEventActionOptionsFromPostFields(r *http.Request) (dataprovider.BaseEven
 			EnvVars: getKeyValsFromPostFields(r, "cmd_env_key", "cmd_env_value"),
 		},
 		EmailConfig: dataprovider.EventActionEmailConfig{
-			Recipients:  getSliceFromDelimitedValues(r.Form.Get("email_recipients"), ","),
-			Bcc:         getSliceFromDelimitedValues(r.Form.Get("email_bcc"), ","),
-			Subject:     r.Form.Get("email_subject"),
-			ContentType: emailContentType,
-			Body:        r.Form.Get("email_body"),
-			Attachments: emailAttachments,
+			Recipients:   getSliceFromDelimitedValues(r.Form.Get("email_recipients"), ","),
+			Bcc:          getSliceFromDelimitedValues(r.Form.Get("email_bcc"), ","),
+			Subject:      r.Form.Get("email_subject"),
+			ContentType:  emailContentType,
+			Body:         r.Form.Get("email_body"),
+			Attachments:  emailAttachments,
+			InlineImages: emailInlineImages,
 		},
 		RetentionConfig: dataprovider.EventActionDataRetentionConfig{
 			Folders: foldersRetention,
@@ -2373,6 +2529,50 @@ func getEventActionOptionsFromPostFields(r *http.Request) (dataprovider.BaseEven
 			TemplateUser:  strings.TrimSpace(r.Form.Get("idp_user")),
 			TemplateAdmin: strings.TrimSpace(r.Form.Get("idp_admin")),
 		},
+		TieringConfig: dataprovider.EventActionTieringConfig{
+			Policies: foldersTiering,
+		},
+		ArchiveConfig: dataprovider.EventActionUserArchiveConfig{
+			Folder: strings.TrimSpace(r.Form.Get("archive_folder")),
+			Path:   strings.TrimSpace(r.Form.Get("archive_path")),
+		},
+		BackupConfig: dataprovider.EventActionBackupConfig{
+			Folder:     strings.TrimSpace(r.Form.Get("backup_folder")),
+			Path:       strings.TrimSpace(r.Form.Get("backup_path")),
+			Passphrase: getSecretFromFormField(r, "backup_passphrase"),
+			Retention:  backupRetention,
+		},
+		ChatConfig: dataprovider.EventActionChatConfig{
+			Platform: chatPlatform,
+			Endpoint: strings.TrimSpace(r.Form.Get("chat_endpoint")),
+			Channel:  strings.TrimSpace(r.Form.Get("chat_channel")),
+			Token:    getSecretFromFormField(r, "chat_token"),
+			Message:  r.Form.Get("chat_message"),
+		},
+		MessageConfig: dataprovider.EventActionMessageConfig{
+			Protocol:      messageProtocol,
+			Endpoint:      strings.TrimSpace(r.Form.Get("message_endpoint")),
+			Topic:         strings.TrimSpace(r.Form.Get("message_topic")),
+			Username:      strings.TrimSpace(r.Form.Get("message_username")),
+			Password:      getSecretFromFormField(r, "message_password"),
+			Message:       r.Form.Get("message_body"),
+			Key:           strings.TrimSpace(r.Form.Get("message_key")),
+			Acks:          messageAcks,
+			SASLMechanism: messageSASLMechanism,
+		},
+		CloudQueueConfig: dataprovider.EventActionCloudQueueConfig{
+			Service:  cloudQueueService,
+			Target:   strings.TrimSpace(r.Form.Get("cloud_queue_target")),
+			Region:   strings.TrimSpace(r.Form.Get("cloud_queue_region")),
+			Endpoint: strings.TrimSpace(r.Form.Get("cloud_queue_endpoint")),
+			Message:  r.Form.Get("cloud_queue_message"),
+		},
+		UsageReportConfig: dataprovider.EventActionUsageReportConfig{
+			GroupBy:    usageReportGroupBy,
+			Recipients: getSliceFromDelimitedValues(r.Form.Get("usage_report_recipients"), ","),
+			Folder:     strings.TrimSpace(r.Form.Get("usage_report_folder")),
+			Path:       strings.TrimSpace(r.Form.Get("usage_report_path")),
+		},
 	}
 	return options, nil
 }
@@ -2410,9 +2610,30 @@ func getIDPLoginEventFromPostField(r *http.Request) int {
 	}
 }
 
+func getQuotaThresholdsFromPostFields(r *http.Request) ([]int, int, error) {
+	var thresholds []int
+	for _, val := range getSliceFromDelimitedValues(r.Form.Get("quota_thresholds"), ",") {
+		threshold, err := strconv.Atoi(strings.TrimSuffix(val, "%"))
+		if err != nil {
+			return nil, 0, util.NewI18nError(fmt.Errorf("invalid quota threshold: %w", err), util.I18nErrorRuleQuotaThresholdInvalid)
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	var hysteresis int
+	if val := r.Form.Get("quota_hysteresis"); val != "" {
+		var err error
+		hysteresis, err = strconv.Atoi(val)
+		if err != nil {
+			return nil, 0, util.NewI18nError(fmt.Errorf("invalid quota hysteresis: %w", err), util.I18nErrorRuleQuotaHysteresisInvalid)
+		}
+	}
+	return thresholds, hysteresis, nil
+}
+
 func getEventRuleConditionsFromPostFields(r *http.Request) (dataprovider.EventConditions, error) {
 	var schedules []dataprovider.Schedule
 	var names, groupNames, roleNames, fsPaths []dataprovider.ConditionPattern
+	var attributes []dataprovider.ConditionAttribute
 
 	scheduleHours := r.Form["schedule_hour"]
 	scheduleDayOfWeeks := r.Form["schedule_day_of_week"]
@@ -2466,6 +2687,17 @@ func getEventRuleConditionsFromPostFields(r *http.Request) (dataprovider.EventCo
 		}
 	}
 
+	patterns := r.Form["attribute_pattern"]
+	for idx, name := range r.Form["attribute_name"] {
+		if name != "" {
+			attributes = append(attributes, dataprovider.ConditionAttribute{
+				Name:         name,
+				Pattern:      patterns[idx],
+				InverseMatch: r.Form["type_attribute_pattern"][idx] == inversePatternType,
+			})
+		}
+	}
+
 	minFileSize, err := util.ParseBytes(r.Form.Get("fs_min_size"))
 	if err != nil {
 		return dataprovider.EventConditions{}, util.NewI18nError(fmt.Errorf("invalid min file size: %w", err), util.I18nErrorInvalidMinSize)
@@ -2474,16 +2706,31 @@ func getEventRuleConditionsFromPostFields(r *http.Request) (dataprovider.EventCo
 	if err != nil {
 		return dataprovider.EventConditions{}, util.NewI18nError(fmt.Errorf("invalid max file size: %w", err), util.I18nErrorInvalidMaxSize)
 	}
+	quotaThresholds, quotaHysteresis, err := getQuotaThresholdsFromPostFields(r)
+	if err != nil {
+		return dataprovider.EventConditions{}, err
+	}
+	var inactivityDays int
+	if val := r.Form.Get("inactivity_days"); val != "" {
+		inactivityDays, err = strconv.Atoi(val)
+		if err != nil {
+			return dataprovider.EventConditions{}, util.NewI18nError(fmt.Errorf("invalid inactivity days: %w", err), util.I18nErrorRuleInactivityDaysInvalid)
+		}
+	}
 	conditions := dataprovider.EventConditions{
-		FsEvents:       r.Form["fs_events"],
-		ProviderEvents: r.Form["provider_events"],
-		IDPLoginEvent:  getIDPLoginEventFromPostField(r),
-		Schedules:      schedules,
+		FsEvents:        r.Form["fs_events"],
+		ProviderEvents:  r.Form["provider_events"],
+		IDPLoginEvent:   getIDPLoginEventFromPostField(r),
+		Schedules:       schedules,
+		QuotaThresholds: quotaThresholds,
+		QuotaHysteresis: quotaHysteresis,
+		InactivityDays:  inactivityDays,
 		Options: dataprovider.ConditionOptions{
 			Names:               names,
 			GroupNames:          groupNames,
 			RoleNames:           roleNames,
 			FsPaths:             fsPaths,
+			Attributes:          attributes,
 			Protocols:           r.Form["fs_protocols"],
 			ProviderObjects:     r.Form["provider_objects"],
 			MinFileSize:         minFileSize,
@@ -2557,6 +2804,13 @@ func updateRepeaterFormRuleFields(r *http.Request) {
 			r.Form.Add("type_fs_path_pattern", strings.TrimSpace(r.Form.Get(base+"[type_fs_path_pattern]")))
 			continue
 		}
+		if hasPrefixAndSuffix(k, "attribute_filters[", "][attribute_name]") {
+			base, _ := strings.CutSuffix(k, "[attribute_name]")
+			r.Form.Add("attribute_name", strings.TrimSpace(r.Form.Get(k)))
+			r.Form.Add("attribute_pattern", strings.TrimSpace(r.Form.Get(base+"[attribute_pattern]")))
+			r.Form.Add("type_attribute_pattern", strings.TrimSpace(r.Form.Get(base+"[type_attribute_pattern]")))
+			continue
+		}
 		if hasPrefixAndSuffix(k, "actions[", "][action_name]") {
 			base, _ := strings.CutSuffix(k, "[action_name]")
 			order, _ := strings.CutPrefix(k, "actions[")
@@ -2675,6 +2929,37 @@ func getACMEConfigsFromPostFields(r *http.Request) *dataprovider.ACMEConfigs {
 	}
 }
 
+func getAdminRolesFromPostFields(r *http.Request) []dataprovider.AdminRole {
+	var roles []dataprovider.AdminRole
+
+	for k := range r.Form {
+		if hasPrefixAndSuffix(k, "admin_roles[", "][admin_role_name]") {
+			name := strings.TrimSpace(r.Form.Get(k))
+			if name == "" {
+				continue
+			}
+			base, _ := strings.CutSuffix(k, "[admin_role_name]")
+			role := dataprovider.AdminRole{
+				Name:        name,
+				Description: strings.TrimSpace(r.Form.Get(base + "[admin_role_description]")),
+				Permissions: make(map[string][]string),
+			}
+			for _, val := range r.Form[base+"[admin_role_permissions][]"] {
+				object, verb, ok := strings.Cut(val, ":")
+				if ok {
+					role.Permissions[object] = append(role.Permissions[object], verb)
+				}
+			}
+			roles = append(roles, role)
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+
+	return roles
+}
+
 func getSMTPConfigsFromPostFields(r *http.Request) *dataprovider.SMTPConfigs {
 	port, err := strconv.Atoi(r.Form.Get("smtp_port"))
 	if err != nil {
@@ -2951,7 +3236,10 @@ func (s *httpdServer) handleWebAddAdminPost(w http.ResponseWriter, r *http.Reque
 		s.renderForbiddenPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF))
 		return
 	}
-	err = dataprovider.AddAdmin(&admin, claims.Username, ipAddr, claims.Role)
+	err = claims.checkAdminScope(admin.Filters.UserSelectors)
+	if err == nil {
+		err = dataprovider.AddAdmin(&admin, claims.Username, ipAddr, claims.Role)
+	}
 	if err != nil {
 		s.renderAddUpdateAdminPage(w, r, &admin, err, true)
 		return
@@ -2989,13 +3277,14 @@ func (s *httpdServer) handleWebUpdateAdminPost(w http.ResponseWriter, r *http.Re
 	}
 	updatedAdmin.Filters.TOTPConfig = admin.Filters.TOTPConfig
 	updatedAdmin.Filters.RecoveryCodes = admin.Filters.RecoveryCodes
+	updatedAdmin.Filters.WebAuthnCredentials = admin.Filters.WebAuthnCredentials
 	claims, err := getTokenClaims(r)
 	if err != nil || claims.Username == "" {
 		s.renderAddUpdateAdminPage(w, r, &updatedAdmin, util.NewI18nError(errInvalidTokenClaims, util.I18nErrorInvalidToken), false)
 		return
 	}
 	if username == claims.Username {
-		if claims.isCriticalPermRemoved(updatedAdmin.Permissions) {
+		if claims.isCriticalPermRemoved(updatedAdmin.GetEffectivePermissions()) {
 			s.renderAddUpdateAdminPage(w, r, &updatedAdmin,
 				util.NewI18nError(errors.New("you cannot remove these permissions to yourself"),
 					util.I18nErrorAdminSelfPerms,
@@ -3018,7 +3307,10 @@ func (s *httpdServer) handleWebUpdateAdminPost(w http.ResponseWriter, r *http.Re
 			return
 		}
 	}
-	err = dataprovider.UpdateAdmin(&updatedAdmin, claims.Username, ipAddr, claims.Role)
+	err = claims.checkAdminScope(updatedAdmin.Filters.UserSelectors)
+	if err == nil {
+		err = dataprovider.UpdateAdmin(&updatedAdmin, claims.Username, ipAddr, claims.Role)
+	}
 	if err != nil {
 		s.renderAddUpdateAdminPage(w, r, &updatedAdmin, err, false)
 		return
@@ -3055,7 +3347,7 @@ func getAllUsers(w http.ResponseWriter, r *http.Request) {
 			break
 		}
 	}
-	render.JSON(w, r, users)
+	render.JSON(w, r, claims.filterUsersByScope(users))
 }
 
 func (s *httpdServer) handleGetWebUsers(w http.ResponseWriter, r *http.Request) {
@@ -3066,6 +3358,9 @@ func (s *httpdServer) handleGetWebUsers(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	data := s.getBasePageData(util.I18nUsersTitle, webUsersPath, r)
+	if !s.enableWebClient {
+		data.ImpersonateURL = ""
+	}
 	renderAdminTemplate(w, templateUsers, data)
 }
 
@@ -3109,8 +3404,11 @@ func (s *httpdServer) handleWebTemplateFolderPost(w http.ResponseWriter, r *http
 		return
 	}
 
+	updateRepeaterFormFields(r)
+
 	templateFolder.MappedPath = r.Form.Get("mapped_path")
 	templateFolder.Description = r.Form.Get("description")
+	templateFolder.Attributes = getAttributesFromPostFields(r)
 	fsConfig, err := getFsConfigFromPostFields(r)
 	if err != nil {
 		s.renderMessagePage(w, r, util.I18nTemplateFolderTitle, http.StatusBadRequest, err, "")
@@ -3123,7 +3421,7 @@ func (s *httpdServer) handleWebTemplateFolderPost(w http.ResponseWriter, r *http
 
 	foldersFields := getFoldersForTemplate(r)
 	for _, tmpl := range foldersFields {
-		f := getFolderFromTemplate(templateFolder, tmpl)
+		f := dataprovider.GetFolderFromTemplate(templateFolder, tmpl)
 		if err := dataprovider.ValidateFolder(&f); err != nil {
 			s.renderMessagePage(w, r, util.I18nTemplateFolderTitle, http.StatusBadRequest, err, "")
 			return
@@ -3163,6 +3461,9 @@ func (s *httpdServer) handleWebTemplateUserGet(w http.ResponseWriter, r *http.Re
 	if r.URL.Query().Get("from") != "" {
 		username := r.URL.Query().Get("from")
 		user, err := dataprovider.UserExists(username, admin.Role)
+		if err == nil && !dataprovider.MatchUserSelectors(admin.Filters.UserSelectors, user.Attributes) {
+			err = util.NewRecordNotFoundError(fmt.Sprintf("username %q does not exist", username))
+		}
 		if err == nil {
 			user.SetEmptySecrets()
 			user.PublicKeys = nil
@@ -3215,6 +3516,10 @@ func (s *httpdServer) handleWebTemplateUserPost(w http.ResponseWriter, r *http.R
 	userTmplFields := getUsersForTemplate(r)
 	for _, tmpl := range userTmplFields {
 		u := getUserFromTemplate(templateUser, tmpl)
+		if err := claims.setUserScope(&u); err != nil {
+			s.renderMessagePage(w, r, util.I18nTemplateUserTitle, http.StatusBadRequest, err, "")
+			return
+		}
 		if err := dataprovider.ValidateUser(&u); err != nil {
 			s.renderMessagePage(w, r, util.I18nTemplateUserTitle, http.StatusBadRequest, err, "")
 			return
@@ -3279,6 +3584,9 @@ func (s *httpdServer) handleWebUpdateUserGet(w http.ResponseWriter, r *http.Requ
 	}
 	username := getURLParam(r, "username")
 	user, err := dataprovider.UserExists(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
 	if err == nil {
 		s.renderUserPage(w, r, &user, userPageModeUpdate, nil, nil)
 	} else if errors.Is(err, util.ErrNotFound) {
@@ -3288,6 +3596,84 @@ func (s *httpdServer) handleWebUpdateUserGet(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+func (s *httpdServer) handleWebImpersonateUserPost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		s.renderForbiddenPage(w, r, util.NewI18nError(errInvalidTokenClaims, util.I18nErrorInvalidToken))
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		s.renderBadRequestPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidForm))
+		return
+	}
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	if err := verifyCSRFToken(r.Form.Get(csrfFormToken), ipAddr); err != nil {
+		s.renderForbiddenPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF))
+		return
+	}
+	username := getURLParam(r, "username")
+	user, err := dataprovider.GetUserWithGroupSettings(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
+	if err != nil {
+		if errors.Is(err, util.ErrNotFound) {
+			s.renderNotFoundPage(w, r, err)
+		} else {
+			s.renderInternalServerErrorPage(w, r, err)
+		}
+		return
+	}
+	if err := user.CheckLoginConditions(); err != nil {
+		s.renderForbiddenPage(w, r, util.NewI18nError(err, util.I18nErrorImpersonateUser))
+		return
+	}
+	readOnly := r.Form.Get("mode") != "full"
+	c := jwtTokenClaims{
+		Username:              user.Username,
+		Permissions:           getImpersonationPermissions(user.Filters.WebClient, readOnly),
+		Signature:             user.GetSignature(),
+		Role:                  user.Role,
+		ImpersonatedBy:        claims.Username,
+		ImpersonationReadOnly: readOnly,
+	}
+	startAuthSession(&c, false, authSessionTypeWeb, ipAddr, time.Now().Add(tokenDuration))
+	if err := c.createAndSetCookie(w, r, s.tokenAuth, tokenAudienceWebClient, ipAddr); err != nil {
+		s.renderInternalServerErrorPage(w, r, err)
+		return
+	}
+	logger.Info(logSender, "", "admin %q started impersonating user %q from ip %q, read only: %t",
+		claims.Username, user.Username, ipAddr, readOnly)
+	dataprovider.RecordUserImpersonation(claims.Username, ipAddr, user.Username, user.Role, readOnly)
+	http.Redirect(w, r, webClientFilesPath, http.StatusFound)
+}
+
+// checkImpersonationAdmin returns an error if the specified admin is no longer
+// allowed to impersonate the specified user
+func checkImpersonationAdmin(username string, user *dataprovider.User, r *http.Request) error {
+	admin, err := dataprovider.AdminExists(username)
+	if err != nil {
+		return err
+	}
+	if admin.Status != 1 {
+		return fmt.Errorf("admin %q is disabled", admin.Username)
+	}
+	if !admin.CanLoginFromIP(util.GetIPFromRemoteAddress(r.RemoteAddr)) {
+		return fmt.Errorf("admin %q cannot login from %v", admin.Username, r.RemoteAddr)
+	}
+	if !dataprovider.HasAdminPermission(admin.GetEffectivePermissions(), dataprovider.PermAdminImpersonateUsers) {
+		return fmt.Errorf("admin %q is not allowed to impersonate users", admin.Username)
+	}
+	if admin.Role != "" && admin.Role != user.Role {
+		return fmt.Errorf("user %q is outside the role of admin %q", user.Username, admin.Username)
+	}
+	if !dataprovider.MatchUserSelectors(admin.Filters.UserSelectors, user.Attributes) {
+		return fmt.Errorf("user %q is outside the scope of admin %q", user.Username, admin.Username)
+	}
+	return nil
+}
+
 func (s *httpdServer) handleWebAddUserPost(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	claims, err := getTokenClaims(r)
@@ -3305,7 +3691,7 @@ func (s *httpdServer) handleWebAddUserPost(w http.ResponseWriter, r *http.Reques
 		s.renderForbiddenPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF))
 		return
 	}
-	user = getUserFromTemplate(user, userTemplateFields{
+	user = getUserFromTemplate(user, dataprovider.UserTemplateFields{
 		Username:   user.Username,
 		Password:   user.Password,
 		PublicKeys: user.PublicKeys,
@@ -3317,7 +3703,12 @@ func (s *httpdServer) handleWebAddUserPost(w http.ResponseWriter, r *http.Reques
 	user.Filters.TOTPConfig = dataprovider.UserTOTPConfig{
 		Enabled: false,
 	}
-	err = dataprovider.AddUser(&user, claims.Username, ipAddr, claims.Role)
+	user.Filters.WebAuthnCredentials = nil
+	user.Filters.S3Credentials = nil
+	err = claims.setUserScope(&user)
+	if err == nil {
+		err = dataprovider.AddUser(&user, claims.Username, ipAddr, claims.Role)
+	}
 	if err != nil {
 		s.renderUserPage(w, r, &user, userPageModeAdd, err, nil)
 		return
@@ -3334,6 +3725,9 @@ func (s *httpdServer) handleWebUpdateUserPost(w http.ResponseWriter, r *http.Req
 	}
 	username := getURLParam(r, "username")
 	user, err := dataprovider.UserExists(username, claims.Role)
+	if err == nil {
+		err = claims.checkUserScope(&user)
+	}
 	if errors.Is(err, util.ErrNotFound) {
 		s.renderNotFoundPage(w, r, err)
 		return
@@ -3351,10 +3745,28 @@ func (s *httpdServer) handleWebUpdateUserPost(w http.ResponseWriter, r *http.Req
 		s.renderForbiddenPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF))
 		return
 	}
+	if err := checkWebETag(r, getUserETag(&user)); err != nil {
+		s.renderUserPage(w, r, &user, userPageModeUpdate, err, nil)
+		return
+	}
 	updatedUser.ID = user.ID
 	updatedUser.Username = user.Username
 	updatedUser.Filters.RecoveryCodes = user.Filters.RecoveryCodes
 	updatedUser.Filters.TOTPConfig = user.Filters.TOTPConfig
+	updatedUser.Filters.WebAuthnCredentials = user.Filters.WebAuthnCredentials
+	updatedUser.Filters.S3Credentials = user.Filters.S3Credentials
+	if !mfa.IsPushEnabled() {
+		// the push settings are not displayed if no provider is configured
+		updatedUser.Filters.PushMFA = user.Filters.PushMFA
+	}
+	updatedUser.Filters.Archive = user.Filters.Archive
+	updatedUser.Filters.LDAPSync = user.Filters.LDAPSync
+	updatedUser.Filters.SSHAlgorithms = user.Filters.SSHAlgorithms
+	updatedUser.Filters.SSHCertificates = user.Filters.SSHCertificates
+	updatedUser.Filters.AccessSchedule = user.Filters.AccessSchedule
+	updatedUser.Filters.SourceAuthPolicies = user.Filters.SourceAuthPolicies
+	updatedUser.Filters.FTP = user.Filters.FTP
+	preserveVirtualFoldersBandwidth(updatedUser.VirtualFolders, user.VirtualFolders)
 	updatedUser.LastPasswordChange = user.LastPasswordChange
 	updatedUser.SetEmptySecretsIfNil()
 	if updatedUser.Password == redactedSecret {
@@ -3364,8 +3776,9 @@ func (s *httpdServer) handleWebUpdateUserPost(w http.ResponseWriter, r *http.Req
 		user.FsConfig.AzBlobConfig.SASURL, user.FsConfig.GCSConfig.Credentials, user.FsConfig.CryptConfig.Passphrase,
 		user.FsConfig.SFTPConfig.Password, user.FsConfig.SFTPConfig.PrivateKey, user.FsConfig.SFTPConfig.KeyPassphrase,
 		user.FsConfig.HTTPConfig.Password, user.FsConfig.HTTPConfig.APIKey)
+	updateCryptFsOldPassphrases(&updatedUser.FsConfig, user.FsConfig)
 
-	updatedUser = getUserFromTemplate(updatedUser, userTemplateFields{
+	updatedUser = getUserFromTemplate(updatedUser, dataprovider.UserTemplateFields{
 		Username:   updatedUser.Username,
 		Password:   updatedUser.Password,
 		PublicKeys: updatedUser.PublicKeys,
@@ -3374,7 +3787,10 @@ func (s *httpdServer) handleWebUpdateUserPost(w http.ResponseWriter, r *http.Req
 		updatedUser.Role = claims.Role
 	}
 
-	err = dataprovider.UpdateUser(&updatedUser, claims.Username, ipAddr, claims.Role)
+	err = claims.setUserScope(&updatedUser)
+	if err == nil {
+		err = dataprovider.UpdateUser(&updatedUser, claims.Username, ipAddr, claims.Role)
+	}
 	if err != nil {
 		s.renderUserPage(w, r, &updatedUser, userPageModeUpdate, err, nil)
 		return
@@ -3394,6 +3810,12 @@ func (s *httpdServer) handleWebGetStatus(w http.ResponseWriter, r *http.Request)
 	renderAdminTemplate(w, templateStatus, data)
 }
 
+func (s *httpdServer) handleWebGetDashboard(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	data := s.getBasePageData(util.I18nDashboardTitle, webDashboardPath, r)
+	renderAdminTemplate(w, templateDashboard, data)
+}
+
 func (s *httpdServer) handleWebGetConnections(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	claims, err := getTokenClaims(r)
@@ -3431,16 +3853,19 @@ func (s *httpdServer) handleWebAddFolderPost(w http.ResponseWriter, r *http.Requ
 		s.renderForbiddenPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF))
 		return
 	}
+	updateRepeaterFormFields(r)
+
 	folder.MappedPath = strings.TrimSpace(r.Form.Get("mapped_path"))
 	folder.Name = strings.TrimSpace(r.Form.Get("name"))
 	folder.Description = r.Form.Get("description")
+	folder.Attributes = getAttributesFromPostFields(r)
 	fsConfig, err := getFsConfigFromPostFields(r)
 	if err != nil {
 		s.renderFolderPage(w, r, folder, folderPageModeAdd, err)
 		return
 	}
 	folder.FsConfig = fsConfig
-	folder = getFolderFromTemplate(folder, folder.Name)
+	folder = dataprovider.GetFolderFromTemplate(folder, folder.Name)
 
 	err = dataprovider.AddFolder(&folder, claims.Username, ipAddr, claims.Role)
 	if err == nil {
@@ -3492,6 +3917,11 @@ func (s *httpdServer) handleWebUpdateFolderPost(w http.ResponseWriter, r *http.R
 		s.renderForbiddenPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF))
 		return
 	}
+	if err := checkWebETag(r, getFolderETag(&folder)); err != nil {
+		s.renderFolderPage(w, r, folder, folderPageModeUpdate, err)
+		return
+	}
+	updateRepeaterFormFields(r)
 	fsConfig, err := getFsConfigFromPostFields(r)
 	if err != nil {
 		s.renderFolderPage(w, r, folder, folderPageModeUpdate, err)
@@ -3500,6 +3930,7 @@ func (s *httpdServer) handleWebUpdateFolderPost(w http.ResponseWriter, r *http.R
 	updatedFolder := vfs.BaseVirtualFolder{
 		MappedPath:  strings.TrimSpace(r.Form.Get("mapped_path")),
 		Description: r.Form.Get("description"),
+		Attributes:  getAttributesFromPostFields(r),
 	}
 	updatedFolder.ID = folder.ID
 	updatedFolder.Name = folder.Name
@@ -3509,8 +3940,9 @@ func (s *httpdServer) handleWebUpdateFolderPost(w http.ResponseWriter, r *http.R
 		folder.FsConfig.AzBlobConfig.SASURL, folder.FsConfig.GCSConfig.Credentials, folder.FsConfig.CryptConfig.Passphrase,
 		folder.FsConfig.SFTPConfig.Password, folder.FsConfig.SFTPConfig.PrivateKey, folder.FsConfig.SFTPConfig.KeyPassphrase,
 		folder.FsConfig.HTTPConfig.Password, folder.FsConfig.HTTPConfig.APIKey)
+	updateCryptFsOldPassphrases(&updatedFolder.FsConfig, folder.FsConfig)
 
-	updatedFolder = getFolderFromTemplate(updatedFolder, updatedFolder.Name)
+	updatedFolder = dataprovider.GetFolderFromTemplate(updatedFolder, updatedFolder.Name)
 
 	err = dataprovider.UpdateFolder(&updatedFolder, folder.Users, folder.Groups, claims.Username, ipAddr, claims.Role)
 	if err != nil {
@@ -3669,8 +4101,19 @@ func (s *httpdServer) handleWebUpdateGroupPost(w http.ResponseWriter, r *http.Re
 		s.renderForbiddenPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF))
 		return
 	}
+	if err := checkWebETag(r, getGroupETag(&group)); err != nil {
+		s.renderGroupPage(w, r, group, genericPageModeUpdate, err)
+		return
+	}
 	updatedGroup.ID = group.ID
 	updatedGroup.Name = group.Name
+	updatedGroup.UserSettings.SSHAlgorithms = group.UserSettings.SSHAlgorithms
+	updatedGroup.UserSettings.SSHCertificates = group.UserSettings.SSHCertificates
+	updatedGroup.UserSettings.AccessSchedule = group.UserSettings.AccessSchedule
+	updatedGroup.UserSettings.SourceAuthPolicies = group.UserSettings.SourceAuthPolicies
+	updatedGroup.UserSettings.FTP = group.UserSettings.FTP
+	updatedGroup.UserSettings.Branding = group.UserSettings.Branding
+	preserveVirtualFoldersBandwidth(updatedGroup.VirtualFolders, group.VirtualFolders)
 	updatedGroup.SetEmptySecretsIfNil()
 
 	updateEncryptedSecrets(&updatedGroup.UserSettings.FsConfig, group.UserSettings.FsConfig.S3Config.AccessSecret,
@@ -3679,6 +4122,7 @@ func (s *httpdServer) handleWebUpdateGroupPost(w http.ResponseWriter, r *http.Re
 		group.UserSettings.FsConfig.SFTPConfig.Password, group.UserSettings.FsConfig.SFTPConfig.PrivateKey,
 		group.UserSettings.FsConfig.SFTPConfig.KeyPassphrase, group.UserSettings.FsConfig.HTTPConfig.Password,
 		group.UserSettings.FsConfig.HTTPConfig.APIKey)
+	updateCryptFsOldPassphrases(&updatedGroup.UserSettings.FsConfig, group.UserSettings.FsConfig)
 
 	err = dataprovider.UpdateGroup(&updatedGroup, group.Users, claims.Username, ipAddr, claims.Role)
 	if err != nil {
@@ -3808,6 +4252,18 @@ func (s *httpdServer) handleWebUpdateEventActionPost(w http.ResponseWriter, r *h
 		if updatedAction.Options.HTTPConfig.Password.IsNotPlainAndNotEmpty() {
 			updatedAction.Options.HTTPConfig.Password = action.Options.HTTPConfig.Password
 		}
+	case dataprovider.ActionTypeBackup:
+		if updatedAction.Options.BackupConfig.Passphrase.IsNotPlainAndNotEmpty() {
+			updatedAction.Options.BackupConfig.Passphrase = action.Options.BackupConfig.Passphrase
+		}
+	case dataprovider.ActionTypeChatNotification:
+		if updatedAction.Options.ChatConfig.Token.IsNotPlainAndNotEmpty() {
+			updatedAction.Options.ChatConfig.Token = action.Options.ChatConfig.Token
+		}
+	case dataprovider.ActionTypeMessagePublish:
+		if updatedAction.Options.MessageConfig.Password.IsNotPlainAndNotEmpty() {
+			updatedAction.Options.MessageConfig.Password = action.Options.MessageConfig.Password
+		}
 	}
 	err = dataprovider.UpdateEventAction(&updatedAction, claims.Username, ipAddr, claims.Role)
 	if err != nil {
@@ -3914,6 +4370,10 @@ func (s *httpdServer) handleWebUpdateEventRulePost(w http.ResponseWriter, r *htt
 		s.renderForbiddenPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF))
 		return
 	}
+	if err := checkWebETag(r, getEventRuleETag(&rule)); err != nil {
+		s.renderEventRulePage(w, r, rule, genericPageModeUpdate, err)
+		return
+	}
 	updatedRule.ID = rule.ID
 	updatedRule.Name = rule.Name
 	err = dataprovider.UpdateEventRule(&updatedRule, claims.Username, ipAddr, claims.Role)
@@ -4054,6 +4514,24 @@ func (s *httpdServer) handleWebGetEvents(w http.ResponseWriter, r *http.Request)
 	renderAdminTemplate(w, templateEvents, data)
 }
 
+func (s *httpdServer) handleWebGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		s.renderForbiddenPage(w, r, util.NewI18nError(errInvalidTokenClaims, util.I18nErrorInvalidToken))
+		return
+	}
+	if claims.Role != "" {
+		s.renderForbiddenPage(w, r, errWebhooksRoleForbidden)
+		return
+	}
+	data := webhooksPage{
+		basePage:      s.getBasePageData(util.I18nWebhooksTitle, webWebhooksPath, r),
+		DeliveriesURL: webWebhooksPath + jsonAPISuffix,
+	}
+	renderAdminTemplate(w, templateWebhooks, data)
+}
+
 func (s *httpdServer) handleWebIPListsPage(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	rtlStatus, rtlProtocols := common.Config.GetRateLimitersStatus()
@@ -4217,6 +4695,9 @@ func (s *httpdServer) handleWebConfigsPost(w http.ResponseWriter, r *http.Reques
 		smtpConfigs := getSMTPConfigsFromPostFields(r)
 		updateSMTPSecrets(smtpConfigs, configs.SMTP)
 		configs.SMTP = smtpConfigs
+	case "admin_roles_submit":
+		configSection = 4
+		configs.AdminRoles = getAdminRolesFromPostFields(r)
 	default:
 		s.renderBadRequestPage(w, r, errors.New("unsupported form action"))
 		return
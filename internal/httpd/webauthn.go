@@ -0,0 +1,860 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package httpd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/go-chi/render"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/rs/xid"
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/kms"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/mfa"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	webAuthnTimeout = 2 * time.Minute
+	// WebAuthn ceremonies, they are added to the state token audience
+	webAuthnAdminRegister = "WebAuthnAdminRegister"
+	webAuthnUserRegister  = "WebAuthnUserRegister"
+	webAuthnAdminLogin    = "WebAuthnAdminLogin"
+	webAuthnUserLogin     = "WebAuthnUserLogin"
+)
+
+var (
+	errWebAuthnDisabled = errors.New("WebAuthn is disabled")
+	errWebAuthnState    = errors.New("invalid or expired WebAuthn state")
+)
+
+type webAuthnRelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type webAuthnUserEntity struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type webAuthnCredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type webAuthnCredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type webAuthnAuthenticatorSelection struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+type webAuthnCreationOptions struct {
+	Challenge              string                         `json:"challenge"`
+	RP                     webAuthnRelyingPartyEntity     `json:"rp"`
+	User                   webAuthnUserEntity             `json:"user"`
+	PubKeyCredParams       []webAuthnCredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                          `json:"timeout"`
+	ExcludeCredentials     []webAuthnCredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection webAuthnAuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                         `json:"attestation"`
+	Extensions             map[string]any                 `json:"extensions"`
+}
+
+type webAuthnRequestOptions struct {
+	Challenge        string                         `json:"challenge"`
+	RPID             string                         `json:"rpId"`
+	Timeout          int64                          `json:"timeout"`
+	AllowCredentials []webAuthnCredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                         `json:"userVerification"`
+}
+
+type webAuthnOptionsResponse struct {
+	State   string `json:"state"`
+	Options any    `json:"options"`
+}
+
+// webAuthnRegistrationRequest defines the response to a credential creation
+// request, the binary fields are base64url encoded
+type webAuthnRegistrationRequest struct {
+	State             string `json:"state"`
+	Name              string `json:"name"`
+	ID                string `json:"id"`
+	ClientDataJSON    string `json:"client_data_json"`
+	AttestationObject string `json:"attestation_object"`
+	// value reported by the credProps extension, if supported by the client
+	Discoverable bool `json:"discoverable"`
+}
+
+// webAuthnAssertion defines the response to an authentication request,
+// the binary fields are base64url encoded
+type webAuthnAssertion struct {
+	State             string
+	ID                string
+	ClientDataJSON    string
+	AuthenticatorData string
+	Signature         string
+	UserHandle        string
+}
+
+func getWebAuthnAssertionFromForm(r *http.Request) webAuthnAssertion {
+	return webAuthnAssertion{
+		State:             strings.TrimSpace(r.Form.Get("webauthn_state")),
+		ID:                strings.TrimSpace(r.Form.Get("credential_id")),
+		ClientDataJSON:    strings.TrimSpace(r.Form.Get("client_data_json")),
+		AuthenticatorData: strings.TrimSpace(r.Form.Get("authenticator_data")),
+		Signature:         strings.TrimSpace(r.Form.Get("signature")),
+		UserHandle:        strings.TrimSpace(r.Form.Get("user_handle")),
+	}
+}
+
+func decodeWebAuthnField(val string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(val, "="))
+}
+
+func getWebAuthnRelyingParty(r *http.Request) mfa.WebAuthnRelyingParty {
+	scheme := "http"
+	if isTLS(r) {
+		scheme = "https"
+	}
+	return mfa.GetWebAuthnRelyingParty(scheme, r.Host)
+}
+
+func getWebAuthnCredentialDescriptors(credentials dataprovider.WebAuthnCredentials) []webAuthnCredentialDescriptor {
+	result := make([]webAuthnCredentialDescriptor, 0, len(credentials))
+	for _, id := range credentials.GetIDs() {
+		result = append(result, webAuthnCredentialDescriptor{
+			Type: "public-key",
+			ID:   id,
+		})
+	}
+	return result
+}
+
+// createWebAuthnState returns a short lived token that binds the challenge to the
+// ceremony, the account, if any, and the client IP
+func createWebAuthnState(ceremony, username, challenge, ip string) (string, error) {
+	claims := make(map[string]any)
+	now := time.Now().UTC()
+
+	claims[jwt.JwtIDKey] = challenge
+	claims[jwt.SubjectKey] = username
+	claims[jwt.NotBeforeKey] = now.Add(-30 * time.Second)
+	claims[jwt.ExpirationKey] = now.Add(webAuthnTimeout)
+	claims[jwt.AudienceKey] = []string{ceremony, ip}
+
+	_, tokenString, err := csrfTokenAuth.Encode(claims)
+	return tokenString, err
+}
+
+// verifyWebAuthnState validates the state token for the specified ceremony and
+// returns the associated username and challenge. A state token can be used only once
+func verifyWebAuthnState(tokenString, ceremony, ip string) (string, string, error) {
+	token, err := jwtauth.VerifyToken(csrfTokenAuth, tokenString)
+	if err != nil || token == nil {
+		logger.Debug(logSender, "", "error validating WebAuthn state: %v", err)
+		return "", "", errWebAuthnState
+	}
+	if !util.Contains(token.Audience(), ceremony) {
+		logger.Debug(logSender, "", "error validating WebAuthn state audience")
+		return "", "", errWebAuthnState
+	}
+	if tokenValidationMode != tokenValidationNoIPMatch {
+		if !util.Contains(token.Audience(), ip) {
+			logger.Debug(logSender, "", "error validating WebAuthn state IP audience")
+			return "", "", errWebAuthnState
+		}
+	}
+	if invalidatedJWTTokens.Get(tokenString) {
+		logger.Debug(logSender, "", "WebAuthn state already used")
+		return "", "", errWebAuthnState
+	}
+	invalidatedJWTTokens.Add(tokenString, token.Expiration())
+	return token.Subject(), token.JwtID(), nil
+}
+
+func getWebAuthnRequestOptions(r *http.Request, ceremony, username string,
+	credentials dataprovider.WebAuthnCredentials, userVerification string,
+) (webAuthnOptionsResponse, error) {
+	challenge := mfa.GenerateWebAuthnChallenge()
+	state, err := createWebAuthnState(ceremony, username, challenge, util.GetIPFromRemoteAddress(r.RemoteAddr))
+	if err != nil {
+		return webAuthnOptionsResponse{}, err
+	}
+	return webAuthnOptionsResponse{
+		State: state,
+		Options: webAuthnRequestOptions{
+			Challenge:        challenge,
+			RPID:             getWebAuthnRelyingParty(r).ID,
+			Timeout:          webAuthnTimeout.Milliseconds(),
+			AllowCredentials: getWebAuthnCredentialDescriptors(credentials),
+			UserVerification: userVerification,
+		},
+	}, nil
+}
+
+// verifyWebAuthnAssertion validates the assertion for one of the specified credentials and
+// returns the index of the used credential and the new signature counter
+func verifyWebAuthnAssertion(r *http.Request, assertion *webAuthnAssertion, challenge string,
+	credentials dataprovider.WebAuthnCredentials, requireUserVerification bool,
+) (int, uint32, error) {
+	idx := credentials.Find(strings.TrimRight(assertion.ID, "="))
+	if idx == -1 {
+		return -1, 0, errors.New("webauthn: unknown credential")
+	}
+	clientDataJSON, err := decodeWebAuthnField(assertion.ClientDataJSON)
+	if err != nil {
+		return -1, 0, fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	authData, err := decodeWebAuthnField(assertion.AuthenticatorData)
+	if err != nil {
+		return -1, 0, fmt.Errorf("webauthn: invalid authenticator data: %w", err)
+	}
+	signature, err := decodeWebAuthnField(assertion.Signature)
+	if err != nil {
+		return -1, 0, fmt.Errorf("webauthn: invalid signature: %w", err)
+	}
+	signCount, err := mfa.VerifyWebAuthnAssertion(getWebAuthnRelyingParty(r), challenge, credentials[idx].PublicKey,
+		credentials[idx].SignCount, clientDataJSON, authData, signature, requireUserVerification)
+	return idx, signCount, err
+}
+
+// getUsernameFromUserHandle returns the username from the user handle
+// returned by the authenticator for discoverable credentials
+func getUsernameFromUserHandle(userHandle string) (string, error) {
+	username, err := decodeWebAuthnField(userHandle)
+	if err != nil || len(username) == 0 {
+		return "", errors.New("webauthn: invalid user handle")
+	}
+	return string(username), nil
+}
+
+func getWebAuthnRegisterCeremony(claims *jwtTokenClaims) string {
+	if claims.hasUserAudience() {
+		return webAuthnUserRegister
+	}
+	return webAuthnAdminRegister
+}
+
+func getWebAuthnRegistrationOptions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if !mfa.IsWebAuthnEnabled() {
+		sendAPIResponse(w, r, errWebAuthnDisabled, "", http.StatusNotFound)
+		return
+	}
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	var credentials dataprovider.WebAuthnCredentials
+	displayName := claims.Username
+	if claims.hasUserAudience() {
+		user, err := dataprovider.UserExists(claims.Username, "")
+		if err != nil {
+			sendAPIResponse(w, r, err, "", getRespStatus(err))
+			return
+		}
+		credentials = user.Filters.WebAuthnCredentials
+		if user.Email != "" {
+			displayName = user.Email
+		}
+	} else {
+		admin, err := dataprovider.AdminExists(claims.Username)
+		if err != nil {
+			sendAPIResponse(w, r, err, "", getRespStatus(err))
+			return
+		}
+		credentials = admin.Filters.WebAuthnCredentials
+		if admin.Email != "" {
+			displayName = admin.Email
+		}
+	}
+	if !dataprovider.CanUseWebAuthnUserHandle(claims.Username) {
+		sendAPIResponse(w, r, util.NewValidationError("webauthn: the username is too long"), "", http.StatusBadRequest)
+		return
+	}
+	if len(credentials) >= dataprovider.MaxWebAuthnCredentials {
+		sendAPIResponse(w, r, util.NewValidationError(fmt.Sprintf("webauthn: too many credentials, max allowed: %d",
+			dataprovider.MaxWebAuthnCredentials)), "", http.StatusBadRequest)
+		return
+	}
+	challenge := mfa.GenerateWebAuthnChallenge()
+	state, err := createWebAuthnState(getWebAuthnRegisterCeremony(&claims), claims.Username, challenge,
+		util.GetIPFromRemoteAddress(r.RemoteAddr))
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+		return
+	}
+	params := make([]webAuthnCredentialParameter, 0, 3)
+	for _, alg := range mfa.GetWebAuthnAlgorithms() {
+		params = append(params, webAuthnCredentialParameter{Type: "public-key", Alg: alg})
+	}
+	rp := getWebAuthnRelyingParty(r)
+	render.JSON(w, r, webAuthnOptionsResponse{
+		State: state,
+		Options: webAuthnCreationOptions{
+			Challenge: challenge,
+			RP: webAuthnRelyingPartyEntity{
+				ID:   rp.ID,
+				Name: mfa.GetWebAuthnRPDisplayName(),
+			},
+			User: webAuthnUserEntity{
+				ID:          base64.RawURLEncoding.EncodeToString([]byte(claims.Username)),
+				Name:        claims.Username,
+				DisplayName: displayName,
+			},
+			PubKeyCredParams:   params,
+			Timeout:            webAuthnTimeout.Milliseconds(),
+			ExcludeCredentials: getWebAuthnCredentialDescriptors(credentials),
+			AuthenticatorSelection: webAuthnAuthenticatorSelection{
+				ResidentKey:      "preferred",
+				UserVerification: "preferred",
+			},
+			Attestation: "none",
+			Extensions: map[string]any{
+				"credProps": true,
+			},
+		},
+	})
+}
+
+func registerWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if !mfa.IsWebAuthnEnabled() {
+		sendAPIResponse(w, r, errWebAuthnDisabled, "", http.StatusNotFound)
+		return
+	}
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	var req webAuthnRegistrationRequest
+	if err := render.DecodeJSON(r.Body, &req); err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusBadRequest)
+		return
+	}
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	username, challenge, err := verifyWebAuthnState(req.State, getWebAuthnRegisterCeremony(&claims), ipAddr)
+	if err != nil || username != claims.Username {
+		sendAPIResponse(w, r, errWebAuthnState, "", http.StatusBadRequest)
+		return
+	}
+	clientDataJSON, err := decodeWebAuthnField(req.ClientDataJSON)
+	if err != nil {
+		sendAPIResponse(w, r, err, "Invalid client data", http.StatusBadRequest)
+		return
+	}
+	attestationObject, err := decodeWebAuthnField(req.AttestationObject)
+	if err != nil {
+		sendAPIResponse(w, r, err, "Invalid attestation object", http.StatusBadRequest)
+		return
+	}
+	registration, err := mfa.VerifyWebAuthnRegistration(getWebAuthnRelyingParty(r), challenge, clientDataJSON,
+		attestationObject, false)
+	if err != nil {
+		logger.Debug(logSender, "", "unable to verify WebAuthn registration for %q: %v", claims.Username, err)
+		sendAPIResponse(w, r, util.NewValidationError(err.Error()), "", http.StatusBadRequest)
+		return
+	}
+	credentialID := base64.RawURLEncoding.EncodeToString(registration.CredentialID)
+	if req.ID != "" && strings.TrimRight(req.ID, "=") != credentialID {
+		sendAPIResponse(w, r, util.NewValidationError("webauthn: credential ID mismatch"), "", http.StatusBadRequest)
+		return
+	}
+	credential := dataprovider.WebAuthnCredential{
+		ID:           credentialID,
+		Name:         req.Name,
+		PublicKey:    registration.PublicKey,
+		SignCount:    registration.SignCount,
+		Discoverable: req.Discoverable,
+		CreatedAt:    util.GetTimeAsMsSinceEpoch(time.Now()),
+	}
+	if claims.hasUserAudience() {
+		err = addUserWebAuthnCredential(claims.Username, &credential, ipAddr)
+	} else {
+		err = addAdminWebAuthnCredential(claims.Username, &credential, ipAddr)
+	}
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, "WebAuthn credential registered", http.StatusCreated)
+}
+
+func getWebAuthnRecoveryCodes() []dataprovider.RecoveryCode {
+	recoveryCodes := make([]dataprovider.RecoveryCode, 0, 12)
+	for i := 0; i < 12; i++ {
+		code := getNewRecoveryCode()
+		recoveryCodes = append(recoveryCodes, dataprovider.RecoveryCode{Secret: kms.NewPlainSecret(code)})
+	}
+	return recoveryCodes
+}
+
+func addUserWebAuthnCredential(username string, credential *dataprovider.WebAuthnCredential, ipAddr string) error {
+	user, err := dataprovider.UserExists(username, "")
+	if err != nil {
+		return err
+	}
+	if user.Filters.WebAuthnCredentials.Find(credential.ID) != -1 {
+		return util.NewValidationError("webauthn: credential already registered")
+	}
+	user.Filters.WebAuthnCredentials = append(user.Filters.WebAuthnCredentials, *credential)
+	if user.CountUnusedRecoveryCodes() < 5 {
+		user.Filters.RecoveryCodes = getWebAuthnRecoveryCodes()
+	}
+	return dataprovider.UpdateUser(&user, dataprovider.ActionExecutorSelf, ipAddr, user.Role)
+}
+
+func addAdminWebAuthnCredential(username string, credential *dataprovider.WebAuthnCredential, ipAddr string) error {
+	admin, err := dataprovider.AdminExists(username)
+	if err != nil {
+		return err
+	}
+	if admin.Filters.WebAuthnCredentials.Find(credential.ID) != -1 {
+		return util.NewValidationError("webauthn: credential already registered")
+	}
+	admin.Filters.WebAuthnCredentials = append(admin.Filters.WebAuthnCredentials, *credential)
+	if admin.CountUnusedRecoveryCodes() < 5 {
+		admin.Filters.RecoveryCodes = getWebAuthnRecoveryCodes()
+	}
+	return dataprovider.UpdateAdmin(&admin, dataprovider.ActionExecutorSelf, ipAddr, admin.Role)
+}
+
+func deleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	id := getURLParam(r, "id")
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	if claims.hasUserAudience() {
+		err = deleteUserWebAuthnCredential(claims.Username, id, ipAddr)
+	} else {
+		err = deleteAdminWebAuthnCredential(claims.Username, id, ipAddr)
+	}
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	sendAPIResponse(w, r, nil, "WebAuthn credential deleted", http.StatusOK)
+}
+
+func deleteUserWebAuthnCredential(username, id, ipAddr string) error {
+	user, err := dataprovider.UserExists(username, "")
+	if err != nil {
+		return err
+	}
+	idx := user.Filters.WebAuthnCredentials.Find(id)
+	if idx == -1 {
+		return util.NewRecordNotFoundError(fmt.Sprintf("credential %q not found", id))
+	}
+	user.Filters.WebAuthnCredentials = append(user.Filters.WebAuthnCredentials[:idx],
+		user.Filters.WebAuthnCredentials[idx+1:]...)
+	if len(user.Filters.WebAuthnCredentials) == 0 && !user.Filters.TOTPConfig.Enabled {
+		user.Filters.RecoveryCodes = nil
+	}
+	return dataprovider.UpdateUser(&user, dataprovider.ActionExecutorSelf, ipAddr, user.Role)
+}
+
+func deleteAdminWebAuthnCredential(username, id, ipAddr string) error {
+	admin, err := dataprovider.AdminExists(username)
+	if err != nil {
+		return err
+	}
+	idx := admin.Filters.WebAuthnCredentials.Find(id)
+	if idx == -1 {
+		return util.NewRecordNotFoundError(fmt.Sprintf("credential %q not found", id))
+	}
+	admin.Filters.WebAuthnCredentials = append(admin.Filters.WebAuthnCredentials[:idx],
+		admin.Filters.WebAuthnCredentials[idx+1:]...)
+	if len(admin.Filters.WebAuthnCredentials) == 0 && !admin.Filters.TOTPConfig.Enabled {
+		admin.Filters.RecoveryCodes = nil
+	}
+	// removing the last credential is refused if WebAuthn is required for the admin
+	return dataprovider.UpdateAdmin(&admin, dataprovider.ActionExecutorSelf, ipAddr, admin.Role)
+}
+
+// updateWebAuthnSignCount stores the new signature counter. Authenticators without
+// a counter always report zero, in this case there is nothing to update
+func updateWebAuthnSignCount(username, credentialID string, signCount uint32, isAdmin bool, ipAddr string) error {
+	if signCount == 0 {
+		return nil
+	}
+	if isAdmin {
+		admin, err := dataprovider.AdminExists(username)
+		if err != nil {
+			return err
+		}
+		idx := admin.Filters.WebAuthnCredentials.Find(credentialID)
+		if idx == -1 {
+			return util.NewRecordNotFoundError("credential not found")
+		}
+		admin.Filters.WebAuthnCredentials[idx].SignCount = signCount
+		return dataprovider.UpdateAdmin(&admin, dataprovider.ActionExecutorSelf, ipAddr, admin.Role)
+	}
+	user, err := dataprovider.UserExists(username, "")
+	if err != nil {
+		return err
+	}
+	idx := user.Filters.WebAuthnCredentials.Find(credentialID)
+	if idx == -1 {
+		return util.NewRecordNotFoundError("credential not found")
+	}
+	user.Filters.WebAuthnCredentials[idx].SignCount = signCount
+	return dataprovider.UpdateUser(&user, dataprovider.ActionExecutorSelf, ipAddr, user.Role)
+}
+
+func (s *httpdServer) handleWebAdminWebAuthnLoginOptions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
+	if !mfa.IsWebAuthnEnabled() {
+		sendAPIResponse(w, r, errWebAuthnDisabled, "", http.StatusNotFound)
+		return
+	}
+	resp, err := getWebAuthnRequestOptions(r, webAuthnAdminLogin, "", nil, "required")
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+		return
+	}
+	render.JSON(w, r, resp)
+}
+
+func (s *httpdServer) handleWebAdminWebAuthnLoginPost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
+
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	if err := r.ParseForm(); err != nil {
+		s.renderAdminLoginPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidForm), ipAddr)
+		return
+	}
+	if err := verifyCSRFToken(r.Form.Get(csrfFormToken), ipAddr); err != nil {
+		s.renderAdminLoginPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF), ipAddr)
+		return
+	}
+	if !mfa.IsWebAuthnEnabled() {
+		s.renderAdminLoginPage(w, r, util.NewI18nError(errWebAuthnDisabled, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	assertion := getWebAuthnAssertionFromForm(r)
+	stateUsername, challenge, err := verifyWebAuthnState(assertion.State, webAuthnAdminLogin, ipAddr)
+	if err != nil || stateUsername != "" {
+		s.renderAdminLoginPage(w, r, util.NewI18nError(errWebAuthnState, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	username, err := getUsernameFromUserHandle(assertion.UserHandle)
+	if err != nil {
+		handleDefenderEventLoginFailed(ipAddr, err) //nolint:errcheck
+		s.renderAdminLoginPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	admin, err := dataprovider.AdminExists(username)
+	if err == nil {
+		err = admin.CanLogin(ipAddr)
+	}
+	if err == nil && !admin.HasWebAuthn() {
+		err = errWebAuthnDisabled
+	}
+	if err != nil {
+		logger.Debug(logSender, "", "WebAuthn login not allowed for admin %q: %v", username, err)
+		handleDefenderEventLoginFailed(ipAddr, err) //nolint:errcheck
+		s.renderAdminLoginPage(w, r,
+			util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	idx, signCount, err := verifyWebAuthnAssertion(r, &assertion, challenge, admin.Filters.WebAuthnCredentials, true)
+	if err != nil {
+		logger.Debug(logSender, "", "unable to verify WebAuthn assertion for admin %q: %v", username, err)
+		handleDefenderEventLoginFailed(ipAddr, dataprovider.ErrInvalidCredentials) //nolint:errcheck
+		s.renderAdminLoginPage(w, r,
+			util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	err = updateWebAuthnSignCount(admin.Username, admin.Filters.WebAuthnCredentials[idx].ID, signCount, true, ipAddr)
+	if err != nil {
+		s.renderAdminLoginPage(w, r, util.NewI18nError(err, util.I18nError500Message), ipAddr)
+		return
+	}
+	// a discoverable credential with user verification is a multi-factor
+	// authentication by itself
+	s.loginAdmin(w, r, &admin, true, s.renderAdminLoginPage, ipAddr)
+}
+
+func (s *httpdServer) handleWebAdminTwoFactorWebAuthnOptions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	admin, err := dataprovider.AdminExists(claims.Username)
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if !admin.HasWebAuthn() {
+		sendAPIResponse(w, r, errWebAuthnDisabled, "", http.StatusNotFound)
+		return
+	}
+	resp, err := getWebAuthnRequestOptions(r, webAuthnAdminLogin, admin.Username, admin.Filters.WebAuthnCredentials,
+		"discouraged")
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+		return
+	}
+	render.JSON(w, r, resp)
+}
+
+func (s *httpdServer) handleWebAdminTwoFactorWebAuthnPost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
+	claims, err := getTokenClaims(r)
+	if err != nil {
+		s.renderNotFoundPage(w, r, nil)
+		return
+	}
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	if err := r.ParseForm(); err != nil {
+		s.renderTwoFactorPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidForm), ipAddr)
+		return
+	}
+	if err := verifyCSRFToken(r.Form.Get(csrfFormToken), ipAddr); err != nil {
+		err = handleDefenderEventLoginFailed(ipAddr, err)
+		s.renderTwoFactorPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF), ipAddr)
+		return
+	}
+	assertion := getWebAuthnAssertionFromForm(r)
+	username, challenge, err := verifyWebAuthnState(assertion.State, webAuthnAdminLogin, ipAddr)
+	if err != nil || username == "" || username != claims.Username {
+		s.renderTwoFactorPage(w, r, util.NewI18nError(errWebAuthnState, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	admin, err := dataprovider.AdminExists(username)
+	if err != nil {
+		if errors.Is(err, util.ErrNotFound) {
+			handleDefenderEventLoginFailed(ipAddr, err) //nolint:errcheck
+		}
+		s.renderTwoFactorPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	if !admin.HasWebAuthn() {
+		s.renderTwoFactorPage(w, r, util.NewI18nError(errWebAuthnDisabled, util.I18n2FADisabled), ipAddr)
+		return
+	}
+	idx, signCount, err := verifyWebAuthnAssertion(r, &assertion, challenge, admin.Filters.WebAuthnCredentials, false)
+	if err != nil {
+		logger.Debug(logSender, "", "unable to verify WebAuthn assertion for admin %q: %v", username, err)
+		handleDefenderEventLoginFailed(ipAddr, dataprovider.ErrInvalidCredentials) //nolint:errcheck
+		s.renderTwoFactorPage(w, r, util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials),
+			ipAddr)
+		return
+	}
+	err = updateWebAuthnSignCount(admin.Username, admin.Filters.WebAuthnCredentials[idx].ID, signCount, true, ipAddr)
+	if err != nil {
+		s.renderInternalServerErrorPage(w, r, err)
+		return
+	}
+	s.loginAdmin(w, r, &admin, true, s.renderTwoFactorPage, ipAddr)
+}
+
+func (s *httpdServer) handleWebClientWebAuthnLoginOptions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
+	if !mfa.IsWebAuthnEnabled() {
+		sendAPIResponse(w, r, errWebAuthnDisabled, "", http.StatusNotFound)
+		return
+	}
+	resp, err := getWebAuthnRequestOptions(r, webAuthnUserLogin, "", nil, "required")
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+		return
+	}
+	render.JSON(w, r, resp)
+}
+
+func (s *httpdServer) handleWebClientWebAuthnLoginPost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
+
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	if err := r.ParseForm(); err != nil {
+		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidForm), ipAddr)
+		return
+	}
+	if err := verifyCSRFToken(r.Form.Get(csrfFormToken), ipAddr); err != nil {
+		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF), ipAddr)
+		return
+	}
+	if !mfa.IsWebAuthnEnabled() {
+		s.renderClientLoginPage(w, r, util.NewI18nError(errWebAuthnDisabled, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	assertion := getWebAuthnAssertionFromForm(r)
+	stateUsername, challenge, err := verifyWebAuthnState(assertion.State, webAuthnUserLogin, ipAddr)
+	if err != nil || stateUsername != "" {
+		s.renderClientLoginPage(w, r, util.NewI18nError(errWebAuthnState, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	username, err := getUsernameFromUserHandle(assertion.UserHandle)
+	if err != nil {
+		updateLoginMetrics(&dataprovider.User{}, dataprovider.LoginMethodPassword, ipAddr, err)
+		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	protocol := common.ProtocolHTTP
+	if err := common.Config.ExecutePostConnectHook(ipAddr, protocol); err != nil {
+		updateLoginMetrics(&dataprovider.User{BaseUser: sdk.BaseUser{Username: username}},
+			dataprovider.LoginMethodPassword, ipAddr, err)
+		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nError403Message), ipAddr)
+		return
+	}
+	user, err := dataprovider.GetUserWithGroupSettings(username, "")
+	if err == nil {
+		err = user.CheckLoginConditions()
+	}
+	if err == nil && !user.HasWebAuthn() {
+		err = errWebAuthnDisabled
+	}
+	if err != nil {
+		logger.Debug(logSender, "", "WebAuthn login not allowed for user %q: %v", username, err)
+		updateLoginMetrics(&dataprovider.User{BaseUser: sdk.BaseUser{Username: username}},
+			dataprovider.LoginMethodPassword, ipAddr, dataprovider.ErrInvalidCredentials)
+		s.renderClientLoginPage(w, r,
+			util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	idx, signCount, err := verifyWebAuthnAssertion(r, &assertion, challenge, user.Filters.WebAuthnCredentials, true)
+	if err != nil {
+		logger.Debug(logSender, "", "unable to verify WebAuthn assertion for user %q: %v", username, err)
+		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, dataprovider.ErrInvalidCredentials)
+		s.renderClientLoginPage(w, r,
+			util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	connectionID := fmt.Sprintf("%v_%v", protocol, xid.New().String())
+	if err := checkHTTPClientUser(&user, r, connectionID, true); err != nil {
+		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, err)
+		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nError403Message), ipAddr)
+		return
+	}
+	err = updateWebAuthnSignCount(user.Username, user.Filters.WebAuthnCredentials[idx].ID, signCount, false, ipAddr)
+	if err != nil {
+		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, common.ErrInternalFailure)
+		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nError500Message), ipAddr)
+		return
+	}
+
+	defer user.CloseFs() //nolint:errcheck
+	err = user.CheckFsRoot(connectionID)
+	if err != nil {
+		logger.Warn(logSender, connectionID, "unable to check fs root: %v", err)
+		updateLoginMetrics(&user, dataprovider.LoginMethodPassword, ipAddr, common.ErrInternalFailure)
+		s.renderClientLoginPage(w, r, util.NewI18nError(err, util.I18nErrorFsGeneric), ipAddr)
+		return
+	}
+	s.loginUser(w, r, &user, connectionID, ipAddr, true, s.renderClientLoginPage)
+}
+
+func (s *httpdServer) handleWebClientTwoFactorWebAuthnOptions(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		sendAPIResponse(w, r, err, "Invalid token claims", http.StatusBadRequest)
+		return
+	}
+	user, err := dataprovider.GetUserWithGroupSettings(claims.Username, "")
+	if err != nil {
+		sendAPIResponse(w, r, err, "", getRespStatus(err))
+		return
+	}
+	if !user.HasWebAuthn() {
+		sendAPIResponse(w, r, errWebAuthnDisabled, "", http.StatusNotFound)
+		return
+	}
+	resp, err := getWebAuthnRequestOptions(r, webAuthnUserLogin, user.Username, user.Filters.WebAuthnCredentials,
+		"discouraged")
+	if err != nil {
+		sendAPIResponse(w, r, err, "", http.StatusInternalServerError)
+		return
+	}
+	render.JSON(w, r, resp)
+}
+
+func (s *httpdServer) handleWebClientTwoFactorWebAuthnPost(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
+	claims, err := getTokenClaims(r)
+	if err != nil {
+		s.renderNotFoundPage(w, r, nil)
+		return
+	}
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	if err := r.ParseForm(); err != nil {
+		s.renderClientTwoFactorPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidForm), ipAddr)
+		return
+	}
+	if err := verifyCSRFToken(r.Form.Get(csrfFormToken), ipAddr); err != nil {
+		err = handleDefenderEventLoginFailed(ipAddr, err)
+		s.renderClientTwoFactorPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCSRF), ipAddr)
+		return
+	}
+	assertion := getWebAuthnAssertionFromForm(r)
+	username, challenge, err := verifyWebAuthnState(assertion.State, webAuthnUserLogin, ipAddr)
+	if err != nil || username == "" || username != claims.Username {
+		s.renderClientTwoFactorPage(w, r, util.NewI18nError(errWebAuthnState, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	user, err := dataprovider.GetUserWithGroupSettings(username, "")
+	if err != nil {
+		if errors.Is(err, util.ErrNotFound) {
+			handleDefenderEventLoginFailed(ipAddr, err) //nolint:errcheck
+		}
+		s.renderClientTwoFactorPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	if !user.HasWebAuthn() {
+		s.renderClientTwoFactorPage(w, r, util.NewI18nError(errWebAuthnDisabled, util.I18n2FADisabled), ipAddr)
+		return
+	}
+	idx, signCount, err := verifyWebAuthnAssertion(r, &assertion, challenge, user.Filters.WebAuthnCredentials, false)
+	if err != nil {
+		logger.Debug(logSender, "", "unable to verify WebAuthn assertion for user %q: %v", username, err)
+		handleDefenderEventLoginFailed(ipAddr, dataprovider.ErrInvalidCredentials) //nolint:errcheck
+		s.renderClientTwoFactorPage(w, r,
+			util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials), ipAddr)
+		return
+	}
+	err = updateWebAuthnSignCount(user.Username, user.Filters.WebAuthnCredentials[idx].ID, signCount, false, ipAddr)
+	if err != nil {
+		s.renderClientInternalServerErrorPage(w, r, err)
+		return
+	}
+	connectionID := fmt.Sprintf("%s_%s", getProtocolFromRequest(r), xid.New().String())
+	s.loginUser(w, r, &user, connectionID, ipAddr, true, s.renderClientTwoFactorPage)
+}
@@ -16,6 +16,7 @@ package httpd
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -53,6 +54,8 @@ const (
 	templateClientEditFile = "editfile.html"
 	templateClientShare    = "share.html"
 	templateClientShares   = "shares.html"
+	templateClientShareDls = "sharedownloads.html"
+	templateClientTrash    = "trash.html"
 	templateClientViewPDF  = "viewpdf.html"
 	templateShareLogin     = "sharelogin.html"
 	templateShareDownload  = "sharedownload.html"
@@ -86,6 +89,7 @@ type baseClientPage struct {
 	FilesURL     string
 	SharesURL    string
 	ShareURL     string
+	TrashURL     string
 	ProfileURL   string
 	PingURL      string
 	ChangePwdURL string
@@ -96,6 +100,18 @@ type baseClientPage struct {
 	CSRFToken    string
 	LoggedUser   *dataprovider.User
 	Branding     UIBranding
+	// Username of the admin impersonating the logged in user, if any
+	ImpersonatedBy        string
+	ImpersonationReadOnly bool
+}
+
+// customBranding defines the branding customizations, configured using the
+// data provider, that cannot be expressed using the UIBranding fields
+type customBranding struct {
+	LogoURL      template.URL
+	PrimaryColor template.CSS
+	LoginText    string
+	FooterLinks  []dataprovider.BrandingLink
 }
 
 type dirMapping struct {
@@ -118,6 +134,7 @@ type editFilePage struct {
 	Name       string
 	ReadOnly   bool
 	Data       string
+	Checksum   string
 }
 
 type filesPage struct {
@@ -125,6 +142,12 @@ type filesPage struct {
 	CurrentDir         string
 	DirsURL            string
 	FileActionsURL     string
+	FileJobsURL        string
+	FileVersionsURL    string
+	SearchURL          string
+	CanSearchContent   bool
+	ChunkedUploadsURL  string
+	UploadChunkSize    int64
 	CheckExistURL      string
 	DownloadURL        string
 	ViewPDFURL         string
@@ -143,11 +166,15 @@ type filesPage struct {
 
 type shareLoginPage struct {
 	commonBasePage
-	CurrentURL string
-	Error      *util.I18nError
-	CSRFToken  string
-	Title      string
-	Branding   UIBranding
+	CurrentURL       string
+	Error            *util.I18nError
+	CSRFToken        string
+	Title            string
+	Branding         UIBranding
+	PasswordRequired bool
+	EmailRequired    bool
+	VerificationID   string
+	Email            string
 }
 
 type shareDownloadPage struct {
@@ -193,19 +220,37 @@ type clientMFAPage struct {
 	RecCodesURL       string
 	Protocols         []string
 	RequiredProtocols []string
+	// WebAuthnURL is empty if WebAuthn is disabled
+	WebAuthnURL         string
+	WebAuthnCredentials dataprovider.WebAuthnCredentials
 }
 
 type clientSharesPage struct {
 	baseClientPage
 	Shares              []dataprovider.Share
 	BasePublicSharesURL string
+	ShareAnalytics      bool
+}
+
+type clientShareDownloadsPage struct {
+	baseClientPage
+	Share     *dataprovider.Share
+	Stats     dataprovider.ShareDownloadStats
+	Downloads []dataprovider.ShareDownload
+	ExportURL string
+}
+
+type clientTrashPage struct {
+	baseClientPage
+	Items []common.TrashItem
 }
 
 type clientSharePage struct {
 	baseClientPage
-	Share *dataprovider.Share
-	Error *util.I18nError
-	IsAdd bool
+	Share                      *dataprovider.Share
+	Error                      *util.I18nError
+	IsAdd                      bool
+	EmailVerificationAvailable bool
 }
 
 type userQuotaUsage struct {
@@ -414,6 +459,16 @@ func loadClientTemplates(templatesPath string) {
 		filepath.Join(templatesPath, templateClientDir, templateClientBase),
 		filepath.Join(templatesPath, templateClientDir, templateClientShares),
 	}
+	shareDownloadsPaths := []string{
+		filepath.Join(templatesPath, templateCommonDir, templateCommonBase),
+		filepath.Join(templatesPath, templateClientDir, templateClientBase),
+		filepath.Join(templatesPath, templateClientDir, templateClientShareDls),
+	}
+	trashPaths := []string{
+		filepath.Join(templatesPath, templateCommonDir, templateCommonBase),
+		filepath.Join(templatesPath, templateClientDir, templateClientBase),
+		filepath.Join(templatesPath, templateClientDir, templateClientTrash),
+	}
 	sharePaths := []string{
 		filepath.Join(templatesPath, templateCommonDir, templateCommonBase),
 		filepath.Join(templatesPath, templateClientDir, templateClientBase),
@@ -495,6 +550,8 @@ func loadClientTemplates(templatesPath string) {
 	editFileTmpl := util.LoadTemplate(nil, editFilePath...)
 	shareLoginTmpl := util.LoadTemplate(nil, shareLoginPath...)
 	sharesTmpl := util.LoadTemplate(nil, sharesPaths...)
+	shareDownloadsTmpl := util.LoadTemplate(nil, shareDownloadsPaths...)
+	trashTmpl := util.LoadTemplate(nil, trashPaths...)
 	shareTmpl := util.LoadTemplate(nil, sharePaths...)
 	forgotPwdTmpl := util.LoadTemplate(nil, forgotPwdPaths...)
 	resetPwdTmpl := util.LoadTemplate(nil, resetPwdPaths...)
@@ -512,6 +569,8 @@ func loadClientTemplates(templatesPath string) {
 	clientTemplates[templateTwoFactorRecovery] = twoFactorRecoveryTmpl
 	clientTemplates[templateClientEditFile] = editFileTmpl
 	clientTemplates[templateClientShares] = sharesTmpl
+	clientTemplates[templateClientShareDls] = shareDownloadsTmpl
+	clientTemplates[templateClientTrash] = trashTmpl
 	clientTemplates[templateClientShare] = shareTmpl
 	clientTemplates[templateForgotPassword] = forgotPwdTmpl
 	clientTemplates[templateResetPassword] = resetPwdTmpl
@@ -544,12 +603,59 @@ func (s *httpdServer) getBaseClientPageData(title, currentURL string, r *http.Re
 		LoggedUser:     getUserFromToken(r),
 		Branding:       s.binding.Branding.WebClient,
 	}
+	if data.LoggedUser.Username != "" {
+		data.Branding, data.CustomBranding = s.getWebClientBranding(getUserPrimaryGroup(data.LoggedUser.Username))
+	}
 	if !strings.HasPrefix(r.RequestURI, webClientPubSharesPath) {
 		data.LoginURL = webClientLoginPath
 	}
+	if common.IsTrashEnabled() {
+		data.TrashURL = webClientTrashPath
+	}
+	data.ImpersonatedBy, data.ImpersonationReadOnly = getImpersonationFromToken(r)
 	return data
 }
 
+// getWebClientBranding returns the WebClient branding merged with the
+// customizations defined for the specified group, if any
+func (s *httpdServer) getWebClientBranding(groupName string) (UIBranding, *customBranding) {
+	branding := s.binding.Branding.WebClient
+	if groupName == "" {
+		return branding, nil
+	}
+	group, err := dataprovider.GroupExists(groupName)
+	if err != nil || group.UserSettings.Branding.IsEmpty() {
+		return branding, nil
+	}
+	groupBranding := &group.UserSettings.Branding
+	if groupBranding.Name != "" {
+		branding.Name = groupBranding.Name
+	}
+	if groupBranding.ShortName != "" {
+		branding.ShortName = groupBranding.ShortName
+	}
+	// logo and color are validated by the data provider
+	return branding, &customBranding{
+		LogoURL:      template.URL(groupBranding.Logo),
+		PrimaryColor: template.CSS(groupBranding.PrimaryColor),
+		LoginText:    groupBranding.LoginText,
+		FooterLinks:  groupBranding.FooterLinks,
+	}
+}
+
+func getUserPrimaryGroup(username string) string {
+	user, err := dataprovider.UserExists(username, "")
+	if err != nil {
+		return ""
+	}
+	for _, g := range user.Groups {
+		if g.Type == sdk.GroupTypePrimary {
+			return g.Name
+		}
+	}
+	return ""
+}
+
 func (s *httpdServer) renderClientForgotPwdPage(w http.ResponseWriter, r *http.Request, err *util.I18nError, ip string) {
 	data := forgotPwdPage{
 		commonBasePage: getCommonBasePage(r),
@@ -585,6 +691,29 @@ func (s *httpdServer) renderShareLoginPage(w http.ResponseWriter, r *http.Reques
 		CSRFToken:      createCSRFToken(ip),
 		Branding:       s.binding.Branding.WebClient,
 	}
+	share, errShare := dataprovider.ShareExists(getURLParam(r, "id"), "")
+	if errShare == nil {
+		data.PasswordRequired = share.Password != ""
+		data.EmailRequired = share.EmailVerification.IsEnabled()
+	} else {
+		data.PasswordRequired = true
+	}
+	renderClientTemplate(w, templateShareLogin, data)
+}
+
+func (s *httpdServer) renderShareVerificationPage(w http.ResponseWriter, r *http.Request, v *shareVerification,
+	err *util.I18nError, ip string,
+) {
+	data := shareLoginPage{
+		commonBasePage: getCommonBasePage(r),
+		Title:          util.I18nShareLoginTitle,
+		CurrentURL:     r.RequestURI,
+		Error:          err,
+		CSRFToken:      createCSRFToken(ip),
+		Branding:       s.binding.Branding.WebClient,
+		VerificationID: v.ID,
+		Email:          v.Email,
+	}
 	renderClientTemplate(w, templateShareLogin, data)
 }
 
@@ -633,8 +762,18 @@ func (s *httpdServer) renderClientTwoFactorPage(w http.ResponseWriter, r *http.R
 		Error:          err,
 		CSRFToken:      createCSRFToken(ip),
 		RecoveryURL:    webClientTwoFactorRecoveryPath,
+		TOTPEnabled:    true,
 		Branding:       s.binding.Branding.WebClient,
 	}
+	if claims, errClaims := getTokenClaims(r); errClaims == nil {
+		if user, errUser := dataprovider.UserExists(claims.Username, ""); errUser == nil {
+			data.TOTPEnabled = user.Filters.TOTPConfig.Enabled &&
+				util.Contains(user.Filters.TOTPConfig.Protocols, common.ProtocolHTTP)
+			if user.HasWebAuthn() {
+				data.WebAuthnURL = webClientWebAuthnPath + "/twofactor"
+			}
+		}
+	}
 	if next := r.URL.Query().Get("next"); strings.HasPrefix(next, webClientFilesPath) {
 		data.CurrentURL += "?next=" + url.QueryEscape(next)
 	}
@@ -670,10 +809,16 @@ func (s *httpdServer) renderClientMFAPage(w http.ResponseWriter, r *http.Request
 	}
 	data.TOTPConfig = user.Filters.TOTPConfig
 	data.RequiredProtocols = user.Filters.TwoFactorAuthProtocols
+	if mfa.IsWebAuthnEnabled() {
+		data.WebAuthnURL = webClientWebAuthnPath
+		data.WebAuthnCredentials = user.Filters.WebAuthnCredentials
+	}
 	renderClientTemplate(w, templateClientMFA, data)
 }
 
-func (s *httpdServer) renderEditFilePage(w http.ResponseWriter, r *http.Request, fileName, fileData string, readOnly bool) {
+func (s *httpdServer) renderEditFilePage(w http.ResponseWriter, r *http.Request, fileName string, fileData []byte,
+	readOnly bool,
+) {
 	title := util.I18nViewFileTitle
 	if !readOnly {
 		title = util.I18nEditFileTitle
@@ -685,7 +830,8 @@ func (s *httpdServer) renderEditFilePage(w http.ResponseWriter, r *http.Request,
 		CurrentDir:     path.Dir(fileName),
 		FileURL:        webClientFilePath,
 		ReadOnly:       readOnly,
-		Data:           fileData,
+		Data:           string(fileData),
+		Checksum:       fmt.Sprintf("%x", sha256.Sum256(fileData)),
 	}
 
 	renderClientTemplate(w, templateClientEditFile, data)
@@ -704,6 +850,8 @@ func (s *httpdServer) renderAddUpdateSharePage(w http.ResponseWriter, r *http.Re
 		Share:          share,
 		Error:          err,
 		IsAdd:          isAdd,
+		// allow to remove an existing email verification if SMTP is no longer configured
+		EmailVerificationAvailable: smtp.IsEnabled() || share.EmailVerification.IsEnabled(),
 	}
 
 	renderClientTemplate(w, templateClientShare, data)
@@ -754,7 +902,7 @@ func (s *httpdServer) renderSharedFilesPage(w http.ResponseWriter, r *http.Reque
 		CanCreateDirs:      false,
 		CanRename:          false,
 		CanDelete:          false,
-		CanDownload:        share.Scope != dataprovider.ShareScopeWrite,
+		CanDownload:        share.Scope == dataprovider.ShareScopeRead || share.Scope == dataprovider.ShareScopeReadWrite,
 		CanShare:           false,
 		Paths:              getDirMapping(dirName, currentURL),
 		QuotaUsage:         newUserQuotaUsage(&dataprovider.User{}),
@@ -791,6 +939,10 @@ func (s *httpdServer) renderFilesPage(w http.ResponseWriter, r *http.Request, di
 		DirsURL:            webClientDirsPath,
 		FileURL:            webClientFilePath,
 		FileActionsURL:     webClientFileActionsPath,
+		FileJobsURL:        webClientFileJobsPath,
+		FileVersionsURL:    webClientFileVersionsPath,
+		SearchURL:          webClientSearchPath,
+		CanSearchContent:   common.IsContentSearchEnabled(),
 		CheckExistURL:      webClientExistPath,
 		CanAddFiles:        user.CanAddFilesFromWeb(dirName),
 		CanCreateDirs:      user.CanAddDirsFromWeb(dirName),
@@ -802,6 +954,10 @@ func (s *httpdServer) renderFilesPage(w http.ResponseWriter, r *http.Request, di
 		Paths:              getDirMapping(dirName, webClientFilesPath),
 		QuotaUsage:         newUserQuotaUsage(user),
 	}
+	if chunkedUploader != nil {
+		data.ChunkedUploadsURL = webClientUploadsPath
+		data.UploadChunkSize = chunkedUploader.chunkSize
+	}
 	renderClientTemplate(w, templateClientFiles, data)
 }
 
@@ -883,10 +1039,15 @@ func (s *httpdServer) handleWebClientDownloadZip(w http.ResponseWriter, r *http.
 		s.renderClientBadRequestPage(w, r, err)
 		return
 	}
+	opts, err := getArchiveOptions(r)
+	if err != nil {
+		s.renderClientBadRequestPage(w, r, err)
+		return
+	}
 
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"",
-		getCompressedFileName(connection.GetUsername(), filesList)))
-	renderCompressedFiles(w, connection, name, filesList, nil)
+		getCompressedFileName(connection.GetUsername(), filesList, opts.format)))
+	renderCompressedFiles(w, r, connection, name, filesList, nil, opts)
 }
 
 func (s *httpdServer) handleClientSharePartialDownload(w http.ResponseWriter, r *http.Request) {
@@ -930,16 +1091,30 @@ func (s *httpdServer) handleClientSharePartialDownload(w http.ResponseWriter, r
 		s.renderClientBadRequestPage(w, r, err)
 		return
 	}
+	opts, err := getArchiveOptions(r)
+	if err != nil {
+		s.renderClientBadRequestPage(w, r, err)
+		return
+	}
 
 	dataprovider.UpdateShareLastUse(&share, 1) //nolint:errcheck
+	w = wrapShareDownloadWriter(w, r)
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"",
-		getCompressedFileName(fmt.Sprintf("share-%s", share.Name), filesList)))
-	renderCompressedFiles(w, connection, name, filesList, &share)
+		getCompressedFileName(fmt.Sprintf("share-%s", share.Name), filesList, opts.format)))
+	renderCompressedFiles(w, r, connection, name, filesList, &share, opts)
+	if dataprovider.IsShareAnalyticsEnabled() {
+		downloaded := make([]string, 0, len(filesList))
+		for _, f := range filesList {
+			downloaded = append(downloaded, util.CleanPath(path.Join(name, f)))
+		}
+		recordShareDownload(w, r, &share, downloaded...)
+	}
 }
 
 func (s *httpdServer) handleShareGetDirContents(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
-	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite}
+	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite,
+		dataprovider.ShareScopeView}
 	share, connection, err := s.checkPublicShare(w, r, validScopes)
 	if err != nil {
 		return
@@ -1004,7 +1179,8 @@ func (s *httpdServer) handleClientUploadToShare(w http.ResponseWriter, r *http.R
 
 func (s *httpdServer) handleShareGetFiles(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
-	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite}
+	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite,
+		dataprovider.ShareScopeView}
 	share, connection, err := s.checkPublicShare(w, r, validScopes)
 	if err != nil {
 		return
@@ -1041,19 +1217,29 @@ func (s *httpdServer) handleShareGetFiles(w http.ResponseWriter, r *http.Request
 		s.renderSharedFilesPage(w, r, share.GetRelativePath(name), nil, share)
 		return
 	}
+	if err := checkSharePreview(&share, name); err != nil {
+		s.renderSharedFilesPage(w, r, path.Dir(share.GetRelativePath(name)),
+			util.NewI18nError(err, util.I18nErrorSharePreviewOnly), share)
+		return
+	}
+	inline := share.Scope == dataprovider.ShareScopeView
 	dataprovider.UpdateShareLastUse(&share, 1) //nolint:errcheck
-	if status, err := downloadFile(w, r, connection, name, info, false, &share); err != nil {
+	w = wrapShareDownloadWriter(w, r)
+	if status, err := downloadFile(w, r, connection, name, info, inline, &share); err != nil {
 		dataprovider.UpdateShareLastUse(&share, -1) //nolint:errcheck
 		if status > 0 {
 			s.renderSharedFilesPage(w, r, path.Dir(share.GetRelativePath(name)),
 				util.NewI18nError(err, i18nFsMsg(getRespStatus(err))), share)
 		}
+		return
 	}
+	recordShareDownload(w, r, &share, name)
 }
 
 func (s *httpdServer) handleShareViewPDF(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxLoginBodySize)
-	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite}
+	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite,
+		dataprovider.ShareScopeView}
 	share, _, err := s.checkPublicShare(w, r, validScopes)
 	if err != nil {
 		return
@@ -1071,7 +1257,8 @@ func (s *httpdServer) handleShareViewPDF(w http.ResponseWriter, r *http.Request)
 
 func (s *httpdServer) handleShareGetPDF(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
-	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite}
+	validScopes := []dataprovider.ShareScope{dataprovider.ShareScopeRead, dataprovider.ShareScopeReadWrite,
+		dataprovider.ShareScopeView}
 	share, connection, err := s.checkPublicShare(w, r, validScopes)
 	if err != nil {
 		return
@@ -1109,9 +1296,12 @@ func (s *httpdServer) handleShareGetPDF(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	dataprovider.UpdateShareLastUse(&share, 1) //nolint:errcheck
+	w = wrapShareDownloadWriter(w, r)
 	if _, err := downloadFile(w, r, connection, name, info, true, &share); err != nil {
 		dataprovider.UpdateShareLastUse(&share, -1) //nolint:errcheck
+		return
 	}
+	recordShareDownload(w, r, &share, name)
 }
 
 func (s *httpdServer) handleClientGetDirContents(w http.ResponseWriter, r *http.Request) {
@@ -1155,6 +1345,12 @@ func (s *httpdServer) handleClientGetDirContents(w http.ResponseWriter, r *http.
 	}
 
 	dirTree := r.URL.Query().Get("dirtree") == "1"
+	hasVersions := false
+	if !dirTree {
+		if fs, _, err := connection.GetFsAndResolvedPath(name); err == nil {
+			_, hasVersions = fs.(vfs.FsVersioner)
+		}
+	}
 	results := make([]map[string]any, 0, len(contents))
 	for _, info := range contents {
 		res := make(map[string]any)
@@ -1175,6 +1371,17 @@ func (s *httpdServer) handleClientGetDirContents(w http.ResponseWriter, r *http.
 				if info.Size() < httpdMaxEditFileSize {
 					res["edit_url"] = strings.Replace(res["url"].(string), webClientFilesPath, webClientEditFilePath, 1)
 				}
+				if thumbnailer != nil && thumbnailer.isSupported(info) {
+					// the modification time allows the browser to cache the thumbnail until the file changes
+					res["thumbnail_url"] = fmt.Sprintf("%s?path=%s&v=%d", webClientThumbnailPath,
+						url.QueryEscape(path.Join(name, info.Name())), info.ModTime().UnixMilli())
+				}
+				if hasVersions {
+					res["versions"] = true
+				}
+				if fi, ok := info.(*vfs.FileInfo); ok && fi.AccessTier() != "" {
+					res["access_tier"] = fi.AccessTier()
+				}
 			}
 		}
 		res["meta"] = fmt.Sprintf("%v_%v", res["type"], info.Name())
@@ -1307,7 +1514,7 @@ func (s *httpdServer) handleClientEditFile(w http.ResponseWriter, r *http.Reques
 	}
 
 	connection.User.CheckFsRoot(connection.ID) //nolint:errcheck
-	reader, err := connection.getFileReader(name, 0, r.Method)
+	reader, err := connection.getFileReader(name, 0, r.Method, info)
 	if err != nil {
 		s.renderClientMessagePage(w, r, util.I18nErrorEditorTitle, getRespStatus(err),
 			util.NewI18nError(err, util.I18nError500Message), "")
@@ -1323,7 +1530,7 @@ func (s *httpdServer) handleClientEditFile(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	s.renderEditFilePage(w, r, name, b.String(), !user.CanAddFilesFromWeb(path.Dir(name)))
+	s.renderEditFilePage(w, r, name, b.Bytes(), !user.CanAddFilesFromWeb(path.Dir(name)))
 }
 
 func (s *httpdServer) handleClientAddShareGet(w http.ResponseWriter, r *http.Request) {
@@ -1438,6 +1645,14 @@ func (s *httpdServer) handleClientAddSharePost(w http.ResponseWriter, r *http.Re
 		), true)
 		return
 	}
+	if err := checkShareEmailVerification(share); err != nil {
+		s.renderAddUpdateSharePage(w, r, share, util.NewI18nError(err, util.I18nErrorShareGeneric), true)
+		return
+	}
+	if err := checkShareFileRequest(share, &user); err != nil {
+		s.renderAddUpdateSharePage(w, r, share, util.NewI18nError(err, util.I18nErrorShareGeneric), true)
+		return
+	}
 	err = dataprovider.AddShare(share, claims.Username, ipAddr, claims.Role)
 	if err == nil {
 		http.Redirect(w, r, webClientSharesPath, http.StatusSeeOther)
@@ -1507,6 +1722,14 @@ func (s *httpdServer) handleClientUpdateSharePost(w http.ResponseWriter, r *http
 		), false)
 		return
 	}
+	if err := checkShareEmailVerification(updatedShare); err != nil {
+		s.renderAddUpdateSharePage(w, r, updatedShare, util.NewI18nError(err, util.I18nErrorShareGeneric), false)
+		return
+	}
+	if err := checkShareFileRequest(updatedShare, &user); err != nil {
+		s.renderAddUpdateSharePage(w, r, updatedShare, util.NewI18nError(err, util.I18nErrorShareGeneric), false)
+		return
+	}
 	err = dataprovider.UpdateShare(updatedShare, claims.Username, ipAddr, claims.Role)
 	if err == nil {
 		http.Redirect(w, r, webClientSharesPath, http.StatusSeeOther)
@@ -1546,10 +1769,103 @@ func (s *httpdServer) handleClientGetShares(w http.ResponseWriter, r *http.Reque
 		baseClientPage:      s.getBaseClientPageData(util.I18nSharesTitle, webClientSharesPath, r),
 		Shares:              shares,
 		BasePublicSharesURL: webClientPubSharesPath,
+		ShareAnalytics:      dataprovider.IsShareAnalyticsEnabled(),
 	}
 	renderClientTemplate(w, templateClientShares, data)
 }
 
+func (s *httpdServer) handleClientGetShareDownloads(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	if getBoolQueryParam(r, "csv_export") {
+		getShareDownloads(w, r)
+		return
+	}
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		s.renderClientForbiddenPage(w, r, util.NewI18nError(errInvalidTokenClaims, util.I18nErrorInvalidToken))
+		return
+	}
+	shareID := getURLParam(r, "id")
+	share, err := dataprovider.ShareExists(shareID, claims.Username)
+	if err != nil {
+		if errors.Is(err, util.ErrNotFound) {
+			s.renderClientNotFoundPage(w, r, err)
+		} else {
+			s.renderClientInternalServerErrorPage(w, r, err)
+		}
+		return
+	}
+	stats, err := dataprovider.GetShareDownloadStats(shareID)
+	if err != nil {
+		s.renderClientMessagePage(w, r, util.I18nShareDownloadsTitle, getRespStatus(err),
+			util.NewI18nError(err, util.I18nErrorShareAnalytics), "")
+		return
+	}
+	downloads, err := dataprovider.SearchShareDownloads(shareID, &dataprovider.ShareDownloadSearch{
+		Limit: 1000,
+		Order: dataprovider.OrderDESC,
+	})
+	if err != nil {
+		s.renderClientMessagePage(w, r, util.I18nShareDownloadsTitle, getRespStatus(err),
+			util.NewI18nError(err, util.I18nErrorShareAnalytics), "")
+		return
+	}
+	share.HideConfidentialData()
+	data := clientShareDownloadsPage{
+		baseClientPage: s.getBaseClientPageData(util.I18nShareDownloadsTitle, webClientSharesPath, r),
+		Share:          &share,
+		Stats:          stats,
+		Downloads:      downloads,
+		ExportURL:      path.Join(webClientSharePath, url.PathEscape(share.ShareID), "downloads") + "?csv_export=true",
+	}
+	renderClientTemplate(w, templateClientShareDls, data)
+}
+
+func (s *httpdServer) handleClientGetTrash(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	claims, err := getTokenClaims(r)
+	if err != nil || claims.Username == "" {
+		s.renderClientForbiddenPage(w, r, util.NewI18nError(errInvalidTokenClaims, util.I18nErrorInvalidToken))
+		return
+	}
+	user, err := dataprovider.GetUserWithGroupSettings(claims.Username, "")
+	if err != nil {
+		s.renderClientMessagePage(w, r, util.I18nError500Title, getRespStatus(err),
+			util.NewI18nError(err, util.I18nErrorGetUser), "")
+		return
+	}
+	connID := xid.New().String()
+	protocol := getProtocolFromRequest(r)
+	connectionID := fmt.Sprintf("%v_%v", protocol, connID)
+	if err := checkHTTPClientUser(&user, r, connectionID, false); err != nil {
+		s.renderClientForbiddenPage(w, r, err)
+		return
+	}
+	connection := &Connection{
+		BaseConnection: common.NewBaseConnection(connID, protocol, util.GetHTTPLocalAddress(r),
+			r.RemoteAddr, user),
+		request: r,
+	}
+	if err = common.Connections.Add(connection); err != nil {
+		s.renderClientMessagePage(w, r, util.I18nError429Title, http.StatusTooManyRequests,
+			util.NewI18nError(err, util.I18nError429Message), "")
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	items, err := connection.GetTrashItems()
+	if err != nil {
+		s.renderClientMessagePage(w, r, util.I18nError400Title, getMappedStatusCode(err),
+			util.NewI18nError(err, util.I18nError400Message), "")
+		return
+	}
+	data := clientTrashPage{
+		baseClientPage: s.getBaseClientPageData(util.I18nTrashTitle, webClientTrashPath, r),
+		Items:          items,
+	}
+	renderClientTemplate(w, templateClientTrash, data)
+}
+
 func (s *httpdServer) handleClientGetProfile(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 	s.renderClientProfilePage(w, r, nil)
@@ -1577,6 +1893,13 @@ func (s *httpdServer) handleWebClientProfilePost(w http.ResponseWriter, r *http.
 		s.renderClientForbiddenPage(w, r, util.NewI18nError(errInvalidTokenClaims, util.I18nErrorInvalidToken))
 		return
 	}
+	if claims.ImpersonatedBy != "" {
+		s.renderClientForbiddenPage(w, r, util.NewI18nError(
+			fmt.Errorf("admin %q is not allowed to change the profile of the impersonated user", claims.ImpersonatedBy),
+			util.I18nErrorNoPermissions,
+		))
+		return
+	}
 	user, userMerged, err := dataprovider.GetUserVariants(claims.Username, "")
 	if err != nil {
 		s.renderClientProfilePage(w, r, util.NewI18nError(err, util.I18nErrorGetUser))
@@ -1657,6 +1980,17 @@ func getShareFromPostFields(r *http.Request) (*dataprovider.Share, error) {
 		return share, util.NewI18nError(err, util.I18nErrorShareMaxTokens)
 	}
 	share.MaxTokens = maxTokens
+	share.EmailVerification.AllowedEmails = getSliceFromDelimitedValues(r.Form.Get("email_verification"), ",")
+	if val := strings.TrimSpace(r.Form.Get("email_verification_max_attempts")); val != "" {
+		maxAttempts, err := strconv.Atoi(val)
+		if err != nil {
+			return share, util.NewI18nError(err, util.I18nErrorShareGeneric)
+		}
+		share.EmailVerification.MaxAttempts = maxAttempts
+	}
+	share.FileRequest.RequireUploaderInfo = r.Form.Get("file_request_uploader_info") != ""
+	share.FileRequest.UploaderDirs = r.Form.Get("file_request_uploader_dirs") != ""
+	share.FileRequest.NotifyOwner = r.Form.Get("file_request_notify_owner") != ""
 	expirationDateMillis := int64(0)
 	expirationDateString := strings.TrimSpace(r.Form.Get("expiration_date"))
 	if expirationDateString != "" {
@@ -1784,7 +2118,7 @@ func (s *httpdServer) handleClientGetPDF(w http.ResponseWriter, r *http.Request)
 }
 
 func (s *httpdServer) ensurePDF(w http.ResponseWriter, r *http.Request, name string, connection *Connection) error {
-	reader, err := connection.getFileReader(name, 0, r.Method)
+	reader, err := connection.getFileReader(name, 0, r.Method, nil)
 	if err != nil {
 		s.renderClientMessagePage(w, r, util.I18nErrorPDFTitle,
 			getRespStatus(err), util.NewI18nError(err, util.I18nError500Message), "")
@@ -1830,16 +2164,52 @@ func (s *httpdServer) handleClientShareLoginPost(w http.ResponseWriter, r *http.
 		s.renderShareLoginPage(w, r, util.NewI18nError(err, util.I18nErrorInvalidCredentials), ipAddr)
 		return
 	}
+	if verificationID := r.Form.Get("verification_id"); verificationID != "" {
+		email, err := checkShareVerification(r, &share, verificationID, strings.TrimSpace(r.Form.Get("verification_code")), ipAddr)
+		if err != nil {
+			handleDefenderEventLoginFailed(ipAddr, dataprovider.ErrInvalidCredentials) //nolint:errcheck
+			if v, errGet := shareVerificationMgr.Get(verificationID); errGet == nil {
+				s.renderShareVerificationPage(w, r, v, getI18nError(err), ipAddr)
+				return
+			}
+			s.renderShareLoginPage(w, r, getI18nError(err), ipAddr)
+			return
+		}
+		s.loginToShare(w, r, &share, email, ipAddr)
+		return
+	}
 	match, err := share.CheckCredentials(strings.TrimSpace(r.Form.Get("share_password")))
 	if !match || err != nil {
 		s.renderShareLoginPage(w, r, util.NewI18nError(dataprovider.ErrInvalidCredentials, util.I18nErrorInvalidCredentials),
 			ipAddr)
 		return
 	}
+	if share.EmailVerification.IsEnabled() {
+		email := strings.ToLower(strings.TrimSpace(r.Form.Get("email")))
+		if !util.IsEmailValid(email) {
+			s.renderShareLoginPage(w, r, util.NewI18nError(util.NewValidationError("invalid email"), util.I18nErrorInvalidEmail),
+				ipAddr)
+			return
+		}
+		v, err := startShareVerification(r, &share, email, ipAddr)
+		if err != nil {
+			s.renderShareLoginPage(w, r, getI18nError(err), ipAddr)
+			return
+		}
+		s.renderShareVerificationPage(w, r, v, nil, ipAddr)
+		return
+	}
+	s.loginToShare(w, r, &share, "", ipAddr)
+}
+
+// loginToShare sets the share cookie and redirects to the requested share page.
+// email is the verified email address, if any
+func (s *httpdServer) loginToShare(w http.ResponseWriter, r *http.Request, share *dataprovider.Share, email, ipAddr string) {
 	c := jwtTokenClaims{
-		Username: shareID,
+		Username:   share.ShareID,
+		ShareEmail: email,
 	}
-	err = c.createAndSetCookie(w, r, s.tokenAuth, tokenAudienceWebShare, ipAddr)
+	err := c.createAndSetCookie(w, r, s.tokenAuth, tokenAudienceWebShare, ipAddr)
 	if err != nil {
 		s.renderShareLoginPage(w, r, util.NewI18nError(err, util.I18nError500Message), ipAddr)
 		return
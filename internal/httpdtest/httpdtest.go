@@ -63,6 +63,7 @@ const (
 	eventActionsPath      = "/api/v2/eventactions"
 	eventRulesPath        = "/api/v2/eventrules"
 	rolesPath             = "/api/v2/roles"
+	adminRolesPath        = "/api/v2/adminroles"
 	ipListsPath           = "/api/v2/iplists"
 )
 
@@ -456,6 +457,91 @@ func GetRoleByName(name string, expectedStatusCode int) (dataprovider.Role, []by
 	return role, body, err
 }
 
+// AddAdminRole adds a new admin role and checks the received HTTP Status code against expectedStatusCode.
+func AddAdminRole(role dataprovider.AdminRole, expectedStatusCode int) (dataprovider.AdminRole, []byte, error) {
+	var newRole dataprovider.AdminRole
+	var body []byte
+	asJSON, _ := json.Marshal(role)
+	resp, err := sendHTTPRequest(http.MethodPost, buildURLRelativeToBase(adminRolesPath), bytes.NewBuffer(asJSON),
+		"application/json", getDefaultToken())
+	if err != nil {
+		return newRole, body, err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp.StatusCode, expectedStatusCode)
+	if expectedStatusCode != http.StatusCreated {
+		body, _ = getResponseBody(resp)
+		return newRole, body, err
+	}
+	if err == nil {
+		err = render.DecodeJSON(resp.Body, &newRole)
+	} else {
+		body, _ = getResponseBody(resp)
+	}
+	if err == nil {
+		err = checkAdminRole(role, newRole)
+	}
+	return newRole, body, err
+}
+
+// UpdateAdminRole updates an existing admin role and checks the received HTTP Status code against expectedStatusCode
+func UpdateAdminRole(role dataprovider.AdminRole, expectedStatusCode int) (dataprovider.AdminRole, []byte, error) {
+	var newRole dataprovider.AdminRole
+	var body []byte
+
+	asJSON, _ := json.Marshal(role)
+	resp, err := sendHTTPRequest(http.MethodPut, buildURLRelativeToBase(adminRolesPath, url.PathEscape(role.Name)),
+		bytes.NewBuffer(asJSON), "application/json", getDefaultToken())
+	if err != nil {
+		return newRole, body, err
+	}
+	defer resp.Body.Close()
+	body, _ = getResponseBody(resp)
+	err = checkResponse(resp.StatusCode, expectedStatusCode)
+	if expectedStatusCode != http.StatusOK {
+		return newRole, body, err
+	}
+	if err == nil {
+		newRole, body, err = GetAdminRoleByName(role.Name, expectedStatusCode)
+	}
+	if err == nil {
+		err = checkAdminRole(role, newRole)
+	}
+	return newRole, body, err
+}
+
+// RemoveAdminRole removes an existing admin role and checks the received HTTP Status code against expectedStatusCode.
+func RemoveAdminRole(role dataprovider.AdminRole, expectedStatusCode int) ([]byte, error) {
+	var body []byte
+	resp, err := sendHTTPRequest(http.MethodDelete, buildURLRelativeToBase(adminRolesPath, url.PathEscape(role.Name)),
+		nil, "", getDefaultToken())
+	if err != nil {
+		return body, err
+	}
+	defer resp.Body.Close()
+	body, _ = getResponseBody(resp)
+	return body, checkResponse(resp.StatusCode, expectedStatusCode)
+}
+
+// GetAdminRoleByName gets an admin role by name and checks the received HTTP Status code against expectedStatusCode.
+func GetAdminRoleByName(name string, expectedStatusCode int) (dataprovider.AdminRole, []byte, error) {
+	var role dataprovider.AdminRole
+	var body []byte
+	resp, err := sendHTTPRequest(http.MethodGet, buildURLRelativeToBase(adminRolesPath, url.PathEscape(name)),
+		nil, "", getDefaultToken())
+	if err != nil {
+		return role, body, err
+	}
+	defer resp.Body.Close()
+	err = checkResponse(resp.StatusCode, expectedStatusCode)
+	if err == nil && expectedStatusCode == http.StatusOK {
+		err = render.DecodeJSON(resp.Body, &role)
+	} else {
+		body, _ = getResponseBody(resp)
+	}
+	return role, body, err
+}
+
 // GetRoles returns a list of roles and checks the received HTTP Status code against expectedStatusCode.
 // The number of results can be limited specifying a limit.
 // Some results can be skipped specifying an offset.
@@ -1615,6 +1701,21 @@ func checkEventAction(expected, actual dataprovider.BaseEventAction) error {
 	if err := compareEventActionFsConfigFields(expected.Options.FsConfig, actual.Options.FsConfig); err != nil {
 		return err
 	}
+	if err := compareEventActionBackupConfigFields(expected.Options.BackupConfig, actual.Options.BackupConfig); err != nil {
+		return err
+	}
+	if err := compareEventActionChatConfigFields(expected.Options.ChatConfig, actual.Options.ChatConfig); err != nil {
+		return err
+	}
+	if err := compareEventActionMessageConfigFields(expected.Options.MessageConfig, actual.Options.MessageConfig); err != nil {
+		return err
+	}
+	if err := compareEventActionCloudQueueConfigFields(expected.Options.CloudQueueConfig, actual.Options.CloudQueueConfig); err != nil {
+		return err
+	}
+	if err := compareEventActionUsageReportConfigFields(expected.Options.UsageReportConfig, actual.Options.UsageReportConfig); err != nil {
+		return err
+	}
 	return compareEventActionHTTPConfigFields(expected.Options.HTTPConfig, actual.Options.HTTPConfig)
 }
 
@@ -1717,6 +1818,20 @@ func checkEventConditions(expected, actual dataprovider.EventConditions) error {
 	if expected.IDPLoginEvent != actual.IDPLoginEvent {
 		return errors.New("IDP login event mismatch")
 	}
+	if len(expected.QuotaThresholds) != len(actual.QuotaThresholds) {
+		return errors.New("quota thresholds mismatch")
+	}
+	for _, v := range expected.QuotaThresholds {
+		if !util.Contains(actual.QuotaThresholds, v) {
+			return errors.New("quota thresholds content mismatch")
+		}
+	}
+	if expected.QuotaHysteresis != actual.QuotaHysteresis {
+		return errors.New("quota hysteresis mismatch")
+	}
+	if expected.InactivityDays != actual.InactivityDays {
+		return errors.New("inactivity days mismatch")
+	}
 
 	return checkEventSchedules(expected.Schedules, actual.Schedules)
 }
@@ -1825,6 +1940,26 @@ func checkRole(expected, actual dataprovider.Role) error {
 	return nil
 }
 
+func checkAdminRole(expected, actual dataprovider.AdminRole) error {
+	if expected.Name != actual.Name {
+		return errors.New("name mismatch")
+	}
+	if expected.Description != actual.Description {
+		return errors.New("description mismatch")
+	}
+	if len(expected.Permissions) != len(actual.Permissions) {
+		return errors.New("permissions mismatch")
+	}
+	for object, verbs := range expected.Permissions {
+		for _, verb := range verbs {
+			if !util.Contains(actual.Permissions[object], verb) {
+				return fmt.Errorf("permission %s:%s not found", object, verb)
+			}
+		}
+	}
+	return nil
+}
+
 func checkGroup(expected, actual dataprovider.Group) error {
 	if expected.ID <= 0 {
 		if actual.ID <= 0 {
@@ -1857,6 +1992,14 @@ func checkGroup(expected, actual dataprovider.Group) error {
 	if err := compareUserFilters(expected.UserSettings.Filters, actual.UserSettings.Filters); err != nil {
 		return err
 	}
+	if len(expected.IncludedGroups) != len(actual.IncludedGroups) {
+		return errors.New("included groups mismatch")
+	}
+	for idx, name := range expected.IncludedGroups {
+		if actual.IncludedGroups[idx] != name {
+			return errors.New("included groups mismatch")
+		}
+	}
 	return compareFsConfig(&expected.UserSettings.FsConfig, &actual.UserSettings.FsConfig)
 }
 
@@ -1913,6 +2056,32 @@ func checkAPIKey(expected, actual *dataprovider.APIKey) error {
 	if expected.Admin != actual.Admin {
 		return errors.New("admin mismatch")
 	}
+	if len(expected.Filters.Endpoints) != len(actual.Filters.Endpoints) {
+		return errors.New("endpoints mismatch")
+	}
+	for _, e := range expected.Filters.Endpoints {
+		found := false
+		for _, a := range actual.Filters.Endpoints {
+			if e.Path == a.Path && len(e.Methods) == len(a.Methods) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("endpoint %q mismatch", e.Path)
+		}
+	}
+	if len(expected.Filters.AllowedIP) != len(actual.Filters.AllowedIP) {
+		return errors.New("allowed IP mismatch")
+	}
+	for _, ip := range expected.Filters.AllowedIP {
+		if !util.Contains(actual.Filters.AllowedIP, ip) {
+			return fmt.Errorf("allowed IP %q mismatch", ip)
+		}
+	}
+	if actual.Filters.PreviousKey != "" {
+		return errors.New("previous key must not be visible")
+	}
 
 	return nil
 }
@@ -1970,6 +2139,22 @@ func compareAdminFilters(expected, actual dataprovider.AdminFilters) error {
 	if expected.Preferences.DefaultUsersExpiration != actual.Preferences.DefaultUsersExpiration {
 		return errors.New("default users expiration mismatch")
 	}
+	if len(expected.AdminRoles) != len(actual.AdminRoles) {
+		return errors.New("admin roles mismatch")
+	}
+	for _, v := range expected.AdminRoles {
+		if !util.Contains(actual.AdminRoles, v) {
+			return errors.New("admin roles content mismatch")
+		}
+	}
+	if len(expected.UserSelectors) != len(actual.UserSelectors) {
+		return errors.New("user selectors mismatch")
+	}
+	for _, v := range expected.UserSelectors {
+		if !util.Contains(actual.UserSelectors, v) {
+			return errors.New("user selectors content mismatch")
+		}
+	}
 	return nil
 }
 
@@ -2157,6 +2342,12 @@ func compareFsConfig(expected *vfs.Filesystem, actual *vfs.Filesystem) error {
 	if err := compareSFTPFsConfig(expected, actual); err != nil {
 		return err
 	}
+	if err := compareUnionFsConfig(expected, actual); err != nil {
+		return err
+	}
+	if err := compareCompressFsConfig(expected, actual); err != nil {
+		return err
+	}
 	return compareHTTPFsConfig(expected, actual)
 }
 
@@ -2209,6 +2400,36 @@ func compareS3Config(expected *vfs.Filesystem, actual *vfs.Filesystem) error { /
 	if expected.S3Config.UploadPartMaxTime != actual.S3Config.UploadPartMaxTime {
 		return errors.New("fs S3 upload part max time mismatch")
 	}
+	if expected.S3Config.UploadMaxMemory != actual.S3Config.UploadMaxMemory {
+		return errors.New("fs S3 upload max memory mismatch")
+	}
+	if !strings.EqualFold(expected.S3Config.ObjectLockMode, actual.S3Config.ObjectLockMode) {
+		return errors.New("fs S3 object lock mode mismatch")
+	}
+	if expected.S3Config.ObjectLockMode != "" &&
+		expected.S3Config.ObjectLockRetentionDays != actual.S3Config.ObjectLockRetentionDays {
+		return errors.New("fs S3 object lock retention days mismatch")
+	}
+	if expected.S3Config.SSEKMSKeyID != actual.S3Config.SSEKMSKeyID {
+		return errors.New("fs S3 SSE-KMS key ID mismatch")
+	}
+	if expected.S3Config.RequesterPays != actual.S3Config.RequesterPays {
+		return errors.New("fs S3 requester pays mismatch")
+	}
+	if len(expected.S3Config.FailoverEndpoints) != len(actual.S3Config.FailoverEndpoints) {
+		return errors.New("fs S3 failover endpoints mismatch")
+	}
+	for idx, ep := range expected.S3Config.FailoverEndpoints {
+		if ep != actual.S3Config.FailoverEndpoints[idx] {
+			return fmt.Errorf("fs S3 failover endpoint %d mismatch", idx)
+		}
+	}
+	if expected.S3Config.RestoreDays != actual.S3Config.RestoreDays {
+		return errors.New("fs S3 restore days mismatch")
+	}
+	if expected.S3Config.RestoreTier != "" && expected.S3Config.RestoreTier != actual.S3Config.RestoreTier {
+		return errors.New("fs S3 restore tier mismatch")
+	}
 	if expected.S3Config.KeyPrefix != actual.S3Config.KeyPrefix &&
 		expected.S3Config.KeyPrefix+"/" != actual.S3Config.KeyPrefix {
 		return errors.New("fs S3 key prefix mismatch")
@@ -2239,6 +2460,12 @@ func compareGCSConfig(expected *vfs.Filesystem, actual *vfs.Filesystem) error {
 	if expected.GCSConfig.UploadPartMaxTime != actual.GCSConfig.UploadPartMaxTime {
 		return errors.New("GCS upload part max time mismatch")
 	}
+	if expected.GCSConfig.KMSKeyName != actual.GCSConfig.KMSKeyName {
+		return errors.New("GCS KMS key name mismatch")
+	}
+	if expected.GCSConfig.UserProject != actual.GCSConfig.UserProject {
+		return errors.New("GCS user project mismatch")
+	}
 	return nil
 }
 
@@ -2252,6 +2479,12 @@ func compareHTTPFsConfig(expected *vfs.Filesystem, actual *vfs.Filesystem) error
 	if expected.HTTPConfig.SkipTLSVerify != actual.HTTPConfig.SkipTLSVerify {
 		return errors.New("HTTPFs skip_tls_verify mismatch")
 	}
+	if expected.HTTPConfig.UseRangeRequests != actual.HTTPConfig.UseRangeRequests {
+		return errors.New("HTTPFs use_range_requests mismatch")
+	}
+	if expected.HTTPConfig.TusUploads != actual.HTTPConfig.TusUploads {
+		return errors.New("HTTPFs tus_uploads mismatch")
+	}
 	if expected.SFTPConfig.EqualityCheckMode != actual.SFTPConfig.EqualityCheckMode {
 		return errors.New("HTTPFs equality_check_mode mismatch")
 	}
@@ -2264,6 +2497,51 @@ func compareHTTPFsConfig(expected *vfs.Filesystem, actual *vfs.Filesystem) error
 	return nil
 }
 
+func compareUnionFsConfig(expected *vfs.Filesystem, actual *vfs.Filesystem) error {
+	if expected.UnionConfig.WritePolicy != "" && expected.UnionConfig.WritePolicy != actual.UnionConfig.WritePolicy {
+		return errors.New("UnionFs write policy mismatch")
+	}
+	if len(expected.UnionConfig.Members) != len(actual.UnionConfig.Members) {
+		return errors.New("UnionFs members mismatch")
+	}
+	for idx := range expected.UnionConfig.Members {
+		expectedMember := expected.UnionConfig.Members[idx]
+		actualMember := actual.UnionConfig.Members[idx]
+		if expectedMember.MappedPath != actualMember.MappedPath {
+			return fmt.Errorf("UnionFs member %d mapped path mismatch", idx)
+		}
+		if expectedMember.ReadOnly != actualMember.ReadOnly {
+			return fmt.Errorf("UnionFs member %d read only mismatch", idx)
+		}
+		if err := compareFsConfig(&expectedMember.FsConfig, &actualMember.FsConfig); err != nil {
+			return fmt.Errorf("UnionFs member %d mismatch: %w", idx, err)
+		}
+	}
+	return nil
+}
+
+func compareCompressFsConfig(expected *vfs.Filesystem, actual *vfs.Filesystem) error {
+	if expected.CompressConfig.Algo != actual.CompressConfig.Algo {
+		return errors.New("compression algorithm mismatch")
+	}
+	if expected.CompressConfig.Algo == "" {
+		return nil
+	}
+	for _, ext := range expected.CompressConfig.SkipExtensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		if !util.Contains(actual.CompressConfig.SkipExtensions, ext) {
+			return fmt.Errorf("compression skip extension %q missing", ext)
+		}
+	}
+	return nil
+}
+
 func compareSFTPFsConfig(expected *vfs.Filesystem, actual *vfs.Filesystem) error {
 	if expected.SFTPConfig.Endpoint != actual.SFTPConfig.Endpoint {
 		return errors.New("SFTPFs endpoint mismatch")
@@ -2343,6 +2621,22 @@ func compareAzBlobConfig(expected *vfs.Filesystem, actual *vfs.Filesystem) error
 	if expected.AzBlobConfig.AccessTier != actual.AzBlobConfig.AccessTier {
 		return errors.New("azure Blob access tier mismatch")
 	}
+	if expected.AzBlobConfig.RehydrateTier != actual.AzBlobConfig.RehydrateTier {
+		return errors.New("azure Blob rehydrate tier mismatch")
+	}
+	if expected.AzBlobConfig.RehydratePriority != "" &&
+		expected.AzBlobConfig.RehydratePriority != actual.AzBlobConfig.RehydratePriority {
+		return errors.New("azure Blob rehydrate priority mismatch")
+	}
+	if len(expected.AzBlobConfig.TierRules) != len(actual.AzBlobConfig.TierRules) {
+		return errors.New("azure Blob tier rules mismatch")
+	}
+	for idx := range expected.AzBlobConfig.TierRules {
+		if expected.AzBlobConfig.TierRules[idx].Tier != actual.AzBlobConfig.TierRules[idx].Tier ||
+			util.CleanPath(expected.AzBlobConfig.TierRules[idx].Path) != actual.AzBlobConfig.TierRules[idx].Path {
+			return errors.New("azure Blob tier rules mismatch")
+		}
+	}
 	return nil
 }
 
@@ -2674,6 +2968,14 @@ func compareEventActionEmailConfigFields(expected, actual dataprovider.EventActi
 			return errors.New("email attachments content mismatch")
 		}
 	}
+	if len(expected.InlineImages) != len(actual.InlineImages) {
+		return errors.New("email inline images mismatch")
+	}
+	for _, v := range expected.InlineImages {
+		if !util.Contains(actual.InlineImages, v) {
+			return errors.New("email inline images content mismatch")
+		}
+	}
 	return nil
 }
 
@@ -2742,6 +3044,103 @@ func compareEventActionIDPConfigFields(expected, actual dataprovider.EventAction
 	return nil
 }
 
+func compareEventActionBackupConfigFields(expected, actual dataprovider.EventActionBackupConfig) error {
+	if expected.Folder != actual.Folder {
+		return errors.New("backup folder mismatch")
+	}
+	if expected.Path != actual.Path {
+		return errors.New("backup path mismatch")
+	}
+	if expected.Retention != actual.Retention {
+		return errors.New("backup retention mismatch")
+	}
+	return nil
+}
+
+func compareEventActionChatConfigFields(expected, actual dataprovider.EventActionChatConfig) error {
+	if expected.Platform != actual.Platform {
+		return errors.New("chat platform mismatch")
+	}
+	if expected.Endpoint != actual.Endpoint {
+		return errors.New("chat endpoint mismatch")
+	}
+	if expected.Channel != actual.Channel {
+		return errors.New("chat channel mismatch")
+	}
+	if expected.Message != actual.Message {
+		return errors.New("chat message mismatch")
+	}
+	return nil
+}
+
+func compareEventActionMessageConfigFields(expected, actual dataprovider.EventActionMessageConfig) error {
+	if expected.Protocol != actual.Protocol {
+		return errors.New("messaging protocol mismatch")
+	}
+	if expected.Endpoint != actual.Endpoint {
+		return errors.New("messaging endpoint mismatch")
+	}
+	if expected.Topic != actual.Topic {
+		return errors.New("messaging topic mismatch")
+	}
+	if expected.Username != actual.Username {
+		return errors.New("messaging username mismatch")
+	}
+	if expected.Message != actual.Message {
+		return errors.New("messaging message mismatch")
+	}
+	if expected.Key != actual.Key {
+		return errors.New("messaging key mismatch")
+	}
+	if expected.Acks != actual.Acks {
+		return errors.New("messaging acks mismatch")
+	}
+	if expected.SASLMechanism != actual.SASLMechanism {
+		return errors.New("messaging SASL mechanism mismatch")
+	}
+	return nil
+}
+
+func compareEventActionCloudQueueConfigFields(expected, actual dataprovider.EventActionCloudQueueConfig) error {
+	if expected.Service != actual.Service {
+		return errors.New("cloud queue service mismatch")
+	}
+	if expected.Target != actual.Target {
+		return errors.New("cloud queue target mismatch")
+	}
+	if expected.Region != actual.Region {
+		return errors.New("cloud queue region mismatch")
+	}
+	if expected.Endpoint != actual.Endpoint {
+		return errors.New("cloud queue endpoint mismatch")
+	}
+	if expected.Message != actual.Message {
+		return errors.New("cloud queue message mismatch")
+	}
+	return nil
+}
+
+func compareEventActionUsageReportConfigFields(expected, actual dataprovider.EventActionUsageReportConfig) error {
+	if expected.GroupBy != actual.GroupBy {
+		return errors.New("usage report grouping mismatch")
+	}
+	if len(expected.Recipients) != len(actual.Recipients) {
+		return errors.New("usage report recipients mismatch")
+	}
+	for _, v := range expected.Recipients {
+		if !util.Contains(actual.Recipients, v) {
+			return errors.New("usage report recipients content mismatch")
+		}
+	}
+	if expected.Folder != actual.Folder {
+		return errors.New("usage report folder mismatch")
+	}
+	if expected.Path != actual.Path {
+		return errors.New("usage report path mismatch")
+	}
+	return nil
+}
+
 func compareEventActionCmdConfigFields(expected, actual dataprovider.EventActionCommandConfig) error {
 	if expected.Cmd != actual.Cmd {
 		return errors.New("command mismatch")
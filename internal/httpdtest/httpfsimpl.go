@@ -26,6 +26,8 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -37,19 +39,21 @@ import (
 )
 
 const (
-	statPath     = "/api/v1/stat"
-	openPath     = "/api/v1/open"
-	createPath   = "/api/v1/create"
-	renamePath   = "/api/v1/rename"
-	removePath   = "/api/v1/remove"
-	mkdirPath    = "/api/v1/mkdir"
-	chmodPath    = "/api/v1/chmod"
-	chtimesPath  = "/api/v1/chtimes"
-	truncatePath = "/api/v1/truncate"
-	readdirPath  = "/api/v1/readdir"
-	dirsizePath  = "/api/v1/dirsize"
-	mimetypePath = "/api/v1/mimetype"
-	statvfsPath  = "/api/v1/statvfs"
+	statPath       = "/api/v1/stat"
+	openPath       = "/api/v1/open"
+	createPath     = "/api/v1/create"
+	renamePath     = "/api/v1/rename"
+	removePath     = "/api/v1/remove"
+	mkdirPath      = "/api/v1/mkdir"
+	chmodPath      = "/api/v1/chmod"
+	chtimesPath    = "/api/v1/chtimes"
+	truncatePath   = "/api/v1/truncate"
+	readdirPath    = "/api/v1/readdir"
+	dirsizePath    = "/api/v1/dirsize"
+	mimetypePath   = "/api/v1/mimetype"
+	statvfsPath    = "/api/v1/statvfs"
+	tusPath        = "/api/v1/tus"
+	tusUploadsPath = "/api/v1/tusuploads"
 )
 
 // HTTPFsCallbacks defines additional callbacks to customize the HTTPfs responses
@@ -83,6 +87,14 @@ type httpFsImpl struct {
 	port           int
 	unixSocketPath string
 	callbacks      *HTTPFsCallbacks
+	tusMu          sync.Mutex
+	tusUploads     map[string]*tusUpload
+}
+
+// tusUpload defines an upload in progress using the tus protocol
+type tusUpload struct {
+	fsPath string
+	offset int64
 }
 
 type apiResponse struct {
@@ -160,6 +172,20 @@ func (fs *httpFsImpl) open(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	isRange := false
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		value, ok := strings.CutPrefix(rangeHeader, "bytes=")
+		if !ok || !strings.HasSuffix(value, "-") {
+			fs.sendAPIResponse(w, r, nil, "unsupported range", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		offset, err = strconv.ParseInt(strings.TrimSuffix(value, "-"), 10, 64)
+		if err != nil {
+			fs.sendAPIResponse(w, r, err, "", http.StatusRequestedRangeNotSatisfiable)
+			return
+		}
+		isRange = true
+	}
 	name := getNameURLParam(r)
 	fsPath := filepath.Join(fs.basePath, username, name)
 	f, err := os.Open(fsPath)
@@ -181,6 +207,9 @@ func (fs *httpFsImpl) open(w http.ResponseWriter, r *http.Request) {
 		ctype = "application/octet-stream"
 	}
 	w.Header().Set("Content-Type", ctype)
+	if isRange {
+		w.WriteHeader(http.StatusPartialContent)
+	}
 	_, err = io.Copy(w, f)
 	if err != nil {
 		panic(http.ErrAbortHandler)
@@ -221,6 +250,122 @@ func (fs *httpFsImpl) create(w http.ResponseWriter, r *http.Request) {
 	fs.sendAPIResponse(w, r, nil, "upload OK", http.StatusOK)
 }
 
+func (fs *httpFsImpl) tusCreate(w http.ResponseWriter, r *http.Request) {
+	username, err := fs.getUsername(r)
+	if err != nil {
+		fs.sendAPIResponse(w, r, err, "", fs.getRespStatus(err))
+		return
+	}
+	if r.Header.Get("Tus-Resumable") == "" || r.Header.Get("Upload-Defer-Length") != "1" {
+		fs.sendAPIResponse(w, r, nil, "invalid tus request", http.StatusBadRequest)
+		return
+	}
+	flags := os.O_RDWR | os.O_CREATE | os.O_TRUNC
+	if r.URL.Query().Has("flags") {
+		openFlags, err := strconv.ParseInt(r.URL.Query().Get("flags"), 10, 32)
+		if err != nil {
+			fs.sendAPIResponse(w, r, err, "", fs.getRespStatus(err))
+			return
+		}
+		if openFlags > 0 {
+			flags = int(openFlags)
+		}
+	}
+	name := getNameURLParam(r)
+	fsPath := filepath.Join(fs.basePath, username, name)
+	f, err := os.OpenFile(fsPath, flags, 0666)
+	if err != nil {
+		fs.sendAPIResponse(w, r, err, "", fs.getRespStatus(err))
+		return
+	}
+	f.Close()
+
+	id := strconv.FormatInt(time.Now().UnixNano(), 10)
+	fs.tusMu.Lock()
+	fs.tusUploads[id] = &tusUpload{
+		fsPath: fsPath,
+	}
+	fs.tusMu.Unlock()
+
+	w.Header().Set("Tus-Resumable", "1.0.0")
+	w.Header().Set("Location", tusUploadsPath+"/"+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (fs *httpFsImpl) getTusUpload(r *http.Request) (*tusUpload, error) {
+	if _, err := fs.getUsername(r); err != nil {
+		return nil, err
+	}
+	fs.tusMu.Lock()
+	defer fs.tusMu.Unlock()
+
+	upload, ok := fs.tusUploads[chi.URLParam(r, "id")]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return upload, nil
+}
+
+func (fs *httpFsImpl) tusHead(w http.ResponseWriter, r *http.Request) {
+	upload, err := fs.getTusUpload(r)
+	if err != nil {
+		w.WriteHeader(fs.getRespStatus(err))
+		return
+	}
+	fs.tusMu.Lock()
+	offset := upload.offset
+	fs.tusMu.Unlock()
+
+	w.Header().Set("Tus-Resumable", "1.0.0")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (fs *httpFsImpl) tusPatch(w http.ResponseWriter, r *http.Request) {
+	upload, err := fs.getTusUpload(r)
+	if err != nil {
+		fs.sendAPIResponse(w, r, err, "", fs.getRespStatus(err))
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		fs.sendAPIResponse(w, r, err, "invalid tus request", http.StatusBadRequest)
+		return
+	}
+	fs.tusMu.Lock()
+	defer fs.tusMu.Unlock()
+
+	if offset != upload.offset {
+		fs.sendAPIResponse(w, r, nil, "offset mismatch", http.StatusConflict)
+		return
+	}
+	f, err := os.OpenFile(upload.fsPath, os.O_WRONLY, 0666)
+	if err != nil {
+		fs.sendAPIResponse(w, r, err, "", fs.getRespStatus(err))
+		return
+	}
+	defer f.Close()
+
+	n, err := io.Copy(io.NewOffsetWriter(f, offset), r.Body)
+	upload.offset += n
+	if err != nil {
+		fs.sendAPIResponse(w, r, err, "", fs.getRespStatus(err))
+		return
+	}
+	if r.Header.Get("Upload-Length") != "" {
+		length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || length != upload.offset {
+			fs.sendAPIResponse(w, r, err, "invalid upload length", http.StatusBadRequest)
+			return
+		}
+		delete(fs.tusUploads, chi.URLParam(r, "id"))
+	}
+	w.Header().Set("Tus-Resumable", "1.0.0")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.offset, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (fs *httpFsImpl) rename(w http.ResponseWriter, r *http.Request) {
 	username, err := fs.getUsername(r)
 	if err != nil {
@@ -491,6 +636,9 @@ func (fs *httpFsImpl) configureRouter() {
 	fs.router.Get(dirsizePath+"/{name}", fs.dirsize)
 	fs.router.Get(mimetypePath+"/{name}", fs.mimetype)
 	fs.router.Get(statvfsPath+"/{name}", fs.statvfs)
+	fs.router.Post(tusPath+"/{name}", fs.tusCreate)
+	fs.router.Head(tusUploadsPath+"/{id}", fs.tusHead)
+	fs.router.Patch(tusUploadsPath+"/{id}", fs.tusPatch)
 }
 
 func (fs *httpFsImpl) Run() error {
@@ -501,6 +649,7 @@ func (fs *httpFsImpl) Run() error {
 	if err := os.MkdirAll(fs.basePath, os.ModePerm); err != nil {
 		return err
 	}
+	fs.tusUploads = make(map[string]*tusUpload)
 	fs.configureRouter()
 
 	httpServer := http.Server{
@@ -0,0 +1,206 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+// Package kafkaclient provides a Kafka producer used to publish events
+package kafkaclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+// Supported acknowledgement modes
+const (
+	AcksLeader = iota
+	AcksAll
+	AcksNone
+)
+
+// Supported SASL mechanisms
+const (
+	SASLPlain = iota
+	SASLScramSHA256
+	SASLScramSHA512
+)
+
+const (
+	clientID       = "sftpgo"
+	recordTries    = 3
+	publishRetry   = 500 * time.Millisecond
+	defaultTimeout = 30 * time.Second
+)
+
+// Config defines the configuration to publish Kafka records
+type Config struct {
+	// Comma separated list of bootstrap brokers, for example kafka://host1:9092,host2:9092.
+	// Use the kafkas:// scheme to connect using TLS
+	Endpoint string
+	// SASL credentials, authentication is enabled if a username is set
+	Username string
+	Password string
+	// SASL mechanism
+	SASLMechanism int
+	// Acknowledgements required to consider a record delivered
+	Acks int
+	// Maximum time allowed to deliver a record, 30 seconds if not set
+	Timeout time.Duration
+}
+
+func (c *Config) getTimeout() time.Duration {
+	if c.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return c.Timeout
+}
+
+func (c *Config) getSASLMechanism() (sasl.Mechanism, error) {
+	switch c.SASLMechanism {
+	case SASLPlain:
+		return plain.Auth{User: c.Username, Pass: c.Password}.AsMechanism(), nil
+	case SASLScramSHA256:
+		return scram.Auth{User: c.Username, Pass: c.Password}.AsSha256Mechanism(), nil
+	case SASLScramSHA512:
+		return scram.Auth{User: c.Username, Pass: c.Password}.AsSha512Mechanism(), nil
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism: %d", c.SASLMechanism)
+	}
+}
+
+func (c *Config) getClientOptions() ([]kgo.Opt, error) {
+	brokers, useTLS, err := GetBrokers(c.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(brokers...),
+		kgo.ClientID(clientID),
+		kgo.DialTimeout(c.getTimeout()),
+		kgo.RequestRetries(recordTries),
+		kgo.RetryBackoffFn(func(_ int) time.Duration {
+			return publishRetry
+		}),
+		kgo.RecordRetries(recordTries),
+		kgo.UnknownTopicRetries(recordTries),
+		kgo.RecordDeliveryTimeout(c.getTimeout()),
+		// records with the same key go to the same partition using the
+		// murmur2 hashing of the Kafka Java client
+		kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)),
+	}
+	switch c.Acks {
+	case AcksLeader:
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	case AcksAll:
+		// idempotent writes avoid duplicates when a record is sent again
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	case AcksNone:
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()), kgo.DisableIdempotentWrite())
+	default:
+		return nil, fmt.Errorf("unsupported acks: %d", c.Acks)
+	}
+	if useTLS {
+		// the server name is set from the dialed broker
+		opts = append(opts, kgo.DialTLSConfig(&tls.Config{
+			MinVersion: tls.VersionTLS12,
+		}))
+	}
+	if c.Username != "" {
+		mechanism, err := c.getSASLMechanism()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+	return opts, nil
+}
+
+// Publish sends a single record to the specified topic.
+// A new client is used for each record
+func Publish(c *Config, topic string, key, value []byte) error {
+	if !IsValidTopic(topic) {
+		return fmt.Errorf("invalid Kafka topic %q", topic)
+	}
+	opts, err := c.getClientOptions()
+	if err != nil {
+		return err
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return fmt.Errorf("unable to create Kafka client: %w", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.getTimeout())
+	defer cancel()
+
+	record := &kgo.Record{
+		Topic: topic,
+		Key:   key,
+		Value: value,
+	}
+	if err := client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		return fmt.Errorf("unable to publish Kafka record to topic %q: %w", topic, err)
+	}
+	return nil
+}
+
+// GetBrokers returns the bootstrap brokers addresses and whether TLS is
+// required for the specified endpoint, for example kafka://host1:9092,host2:9092
+func GetBrokers(endpoint string) ([]string, bool, error) {
+	useTLS := strings.HasPrefix(endpoint, "kafkas://")
+	defaultPort := "9092"
+	if useTLS {
+		defaultPort = "9093"
+	}
+	hosts := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(endpoint, "kafka://"), "kafkas://"), "/")
+	var brokers []string
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
+		}
+		if h, _, err := net.SplitHostPort(host); err != nil || h == "" {
+			return nil, false, fmt.Errorf("invalid Kafka broker %q", host)
+		}
+		brokers = append(brokers, host)
+	}
+	if len(brokers) == 0 {
+		return nil, false, fmt.Errorf("invalid broker URL %q: at least a broker is required", endpoint)
+	}
+	return brokers, useTLS, nil
+}
+
+// IsValidTopic returns true if the specified Kafka topic name is valid
+func IsValidTopic(topic string) bool {
+	if topic == "" || len(topic) > 249 || topic == "." || topic == ".." {
+		return false
+	}
+	for _, r := range topic {
+		if !(r >= 'a' && r <= 'z') && !(r >= 'A' && r <= 'Z') && !(r >= '0' && r <= '9') && r != '.' && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
@@ -18,6 +18,7 @@ package kms
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
 
@@ -45,7 +46,8 @@ type SecretProvider interface {
 }
 
 const (
-	logSender = "kms"
+	logSender          = "kms"
+	healthCheckPayload = "sftpgo KMS health check"
 )
 
 // Configuration defines the KMS configuration
@@ -147,6 +149,66 @@ func (c *Configuration) getSecretProvider(base BaseSecret) SecretProvider {
 	return NewLocalSecret(base, c.Secrets.URL, c.Secrets.masterKey)
 }
 
+func (c *Configuration) getEncryptedStatus() sdkkms.SecretStatus {
+	for k, v := range secretProviders {
+		if strings.HasPrefix(c.Secrets.URL, k) {
+			return v.encryptedStatus
+		}
+	}
+	return sdkkms.SecretStatusSecretBox
+}
+
+// isUpToDate returns true if the given encrypted secret does not need to be
+// encrypted again using the configured provider
+func (c *Configuration) isUpToDate(provider SecretProvider) bool {
+	status := provider.GetStatus()
+	if status != c.getEncryptedStatus() {
+		return false
+	}
+	// secrets encrypted using the local provider before configuring a master key
+	if status == sdkkms.SecretStatusSecretBox && c.Secrets.masterKey != "" && provider.GetMode() == 0 {
+		return false
+	}
+	return true
+}
+
+// Status defines the status of the configured KMS
+type Status struct {
+	Provider string `json:"provider"`
+	IsActive bool   `json:"is_active"`
+	Error    string `json:"error,omitempty"`
+}
+
+// GetStatus checks the configured KMS by encrypting and decrypting a test secret
+// and returns its status
+func GetStatus() Status {
+	secret := NewPlainSecret(healthCheckPayload)
+	status := Status{
+		Provider: secret.provider.Name(),
+		IsActive: true,
+	}
+	if err := checkSecret(secret); err != nil {
+		logger.Warn(logSender, "", "KMS health check failed, provider %q: %v", status.Provider, err)
+		status.IsActive = false
+		status.Error = err.Error()
+	}
+	return status
+}
+
+func checkSecret(secret *Secret) error {
+	secret.SetAdditionalData(healthCheckPayload)
+	if err := secret.Encrypt(); err != nil {
+		return fmt.Errorf("unable to encrypt the test secret: %w", err)
+	}
+	if err := secret.Decrypt(); err != nil {
+		return fmt.Errorf("unable to decrypt the test secret: %w", err)
+	}
+	if secret.GetPayload() != healthCheckPayload {
+		return errors.New("the decrypted test secret does not match the original one")
+	}
+	return nil
+}
+
 // Secret defines the struct used to store confidential data
 type Secret struct {
 	sync.RWMutex
@@ -421,6 +483,37 @@ func (s *Secret) TryDecrypt() error {
 	return nil
 }
 
+// Rekey encrypts the secret again using the configured provider. Secrets in
+// plain text or already encrypted with the configured provider are not
+// changed unless force is true, this is required, for example, after a
+// key rotation within the same provider.
+// It returns true if the secret was encrypted again
+func (s *Secret) Rekey(force bool) (bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.provider.IsEncrypted() {
+		return false, nil
+	}
+	if !force && config.isUpToDate(s.provider) {
+		return false, nil
+	}
+	decrypted := s.provider.Clone()
+	if err := decrypted.Decrypt(); err != nil {
+		return false, err
+	}
+	provider := config.getSecretProvider(BaseSecret{
+		Status:         sdkkms.SecretStatusPlain,
+		Payload:        decrypted.GetPayload(),
+		AdditionalData: s.provider.GetAdditionalData(),
+	})
+	if err := provider.Encrypt(); err != nil {
+		return false, err
+	}
+	s.provider = provider
+	return true, nil
+}
+
 func isSecretStatusValid(status string) bool {
 	for idx := range validSecretStatuses {
 		if validSecretStatuses[idx] == status {
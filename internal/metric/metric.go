@@ -19,6 +19,8 @@
 package metric
 
 import (
+	"time"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -638,6 +640,12 @@ var (
 		Name: "sftpgo_httpfs_download_size",
 		Help: "The total HTTPFs download size as bytes, partial downloads are included",
 	})
+
+	// certificateExpiration is the metric that reports the expiration time for the monitored certificates
+	certificateExpiration = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "sftpgo_certificate_expiration_timestamp_seconds",
+		Help: "Expiration time for the monitored certificates as Unix timestamp",
+	}, []string{"kind", "name"})
 )
 
 // AddMetricsEndpoint publishes metrics to the specified endpoint
@@ -1006,3 +1014,13 @@ func HTTPRequestServed(status int) {
 func UpdateActiveConnectionsSize(size int) {
 	activeConnections.Set(float64(size))
 }
+
+// UpdateCertificateExpiration sets the expiration time for the specified certificate
+func UpdateCertificateExpiration(kind, name string, expiresAt time.Time) {
+	certificateExpiration.WithLabelValues(kind, name).Set(float64(expiresAt.Unix()))
+}
+
+// RemoveCertificateExpiration removes the expiration metric for the specified certificate
+func RemoveCertificateExpiration(kind, name string) {
+	certificateExpiration.DeleteLabelValues(kind, name)
+}
@@ -4,6 +4,8 @@
 package metric
 
 import (
+	"time"
+
 	"github.com/go-chi/chi/v5"
 
 	"github.com/drakkan/sftpgo/v2/internal/version"
@@ -73,3 +75,9 @@ func HTTPRequestServed(_ int) {}
 
 // UpdateActiveConnectionsSize sets the metric for active connections
 func UpdateActiveConnectionsSize(_ int) {}
+
+// UpdateCertificateExpiration sets the expiration time for the specified certificate
+func UpdateCertificateExpiration(_, _ string, _ time.Time) {}
+
+// RemoveCertificateExpiration removes the expiration metric for the specified certificate
+func RemoveCertificateExpiration(_, _ string) {}
@@ -33,6 +33,8 @@ var (
 type ServiceStatus struct {
 	IsActive    bool         `json:"is_active"`
 	TOTPConfigs []TOTPConfig `json:"totp_configs"`
+	WebAuthn    bool         `json:"webauthn"`
+	Push        bool         `json:"push"`
 }
 
 // GetStatus returns the service status
@@ -44,13 +46,21 @@ func GetStatus() ServiceStatus {
 type Config struct {
 	// Time-based one time passwords configurations
 	TOTP []TOTPConfig `json:"totp" mapstructure:"totp"`
+	// WebAuthn security keys and passkeys configuration
+	WebAuthn WebAuthnConfig `json:"webauthn" mapstructure:"webauthn"`
+	// Push based MFA provider configuration
+	Push PushConfig `json:"push" mapstructure:"push"`
 }
 
 // Initialize configures the MFA support
 func (c *Config) Initialize() error {
 	totpConfigs = nil
+	webAuthnConfig = WebAuthnConfig{}
 	serviceStatus.IsActive = false
 	serviceStatus.TOTPConfigs = nil
+	serviceStatus.WebAuthn = false
+	serviceStatus.Push = false
+	pushConfig = PushConfig{}
 	totp := make(map[string]bool)
 	for _, totpConfig := range c.TOTP {
 		totpConfig := totpConfig //pin
@@ -67,6 +77,25 @@ func (c *Config) Initialize() error {
 		serviceStatus.IsActive = true
 		serviceStatus.TOTPConfigs = append(serviceStatus.TOTPConfigs, totpConfig)
 	}
+	if err := c.WebAuthn.validate(); err != nil {
+		totpConfigs = nil
+		return err
+	}
+	webAuthnConfig = c.WebAuthn
+	if webAuthnConfig.Enabled {
+		serviceStatus.IsActive = true
+		serviceStatus.WebAuthn = true
+	}
+	if err := c.Push.validate(); err != nil {
+		totpConfigs = nil
+		webAuthnConfig = WebAuthnConfig{}
+		return err
+	}
+	pushConfig = c.Push
+	if IsPushEnabled() {
+		serviceStatus.IsActive = true
+		serviceStatus.Push = true
+	}
 	startCleanupTicker(2 * time.Minute)
 	return nil
 }
@@ -15,6 +15,18 @@
 package mfa
 
 import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
 	"time"
 
@@ -22,6 +34,8 @@ import (
 	"github.com/pquerna/otp/totp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/drakkan/sftpgo/v2/internal/httpclient"
 )
 
 func TestMFAConfig(t *testing.T) {
@@ -160,3 +174,370 @@ func generatePasscode(secret string, algo otp.Algorithm) (string, error) {
 		Algorithm: algo,
 	})
 }
+
+func TestWebAuthnConfig(t *testing.T) {
+	config := Config{
+		WebAuthn: WebAuthnConfig{
+			Enabled: true,
+			RPID:    "https://sftpgo.example.com",
+		},
+	}
+	err := config.Initialize()
+	assert.Error(t, err)
+	config.WebAuthn.RPID = "sftpgo.example.com"
+	config.WebAuthn.Origins = []string{"sftpgo.example.com"}
+	err = config.Initialize()
+	assert.Error(t, err)
+	config.WebAuthn.Origins = []string{"https://sftpgo.example.com"}
+	err = config.Initialize()
+	assert.NoError(t, err)
+	assert.True(t, IsWebAuthnEnabled())
+	assert.Equal(t, "SFTPGo", GetWebAuthnRPDisplayName())
+	status := GetStatus()
+	assert.True(t, status.IsActive)
+	assert.True(t, status.WebAuthn)
+	rp := GetWebAuthnRelyingParty("https", "127.0.0.1:8080")
+	assert.Equal(t, "sftpgo.example.com", rp.ID)
+	assert.Equal(t, "https://127.0.0.1:8080", rp.Origin)
+
+	config.WebAuthn = WebAuthnConfig{}
+	err = config.Initialize()
+	assert.NoError(t, err)
+	assert.False(t, IsWebAuthnEnabled())
+	assert.False(t, GetStatus().WebAuthn)
+	rp = GetWebAuthnRelyingParty("http", "localhost:8080")
+	assert.Equal(t, "localhost", rp.ID)
+	assert.Equal(t, "http://localhost:8080", rp.Origin)
+
+	stopCleanupTicker()
+}
+
+func TestWebAuthnCeremonies(t *testing.T) {
+	config := Config{
+		WebAuthn: WebAuthnConfig{
+			Enabled: true,
+		},
+	}
+	err := config.Initialize()
+	require.NoError(t, err)
+	defer stopCleanupTicker()
+
+	rp := GetWebAuthnRelyingParty("https", "sftpgo.example.com")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	credentialID := []byte("test credential id")
+	challenge := GenerateWebAuthnChallenge()
+	assert.NotEqual(t, challenge, GenerateWebAuthnChallenge())
+
+	clientData := getWebAuthnTestClientData(t, "webauthn.create", challenge, rp.Origin)
+	authData := getWebAuthnTestAuthData(rp.ID, 0x41, 0, credentialID, getWebAuthnTestCOSEKey(key))
+	attestationObject := cborEncodeTest(cborTestMap{
+		{"fmt", "none"},
+		{"attStmt", cborTestMap{}},
+		{"authData", authData},
+	})
+	_, err = VerifyWebAuthnRegistration(rp, GenerateWebAuthnChallenge(), clientData, attestationObject, false)
+	assert.ErrorContains(t, err, "challenge mismatch")
+	_, err = VerifyWebAuthnRegistration(rp, challenge, clientData, attestationObject, true)
+	assert.ErrorContains(t, err, "user not verified")
+	otherRP := GetWebAuthnRelyingParty("https", "other.example.com")
+	_, err = VerifyWebAuthnRegistration(otherRP, challenge, clientData, attestationObject, false)
+	assert.ErrorContains(t, err, "origin")
+	_, err = VerifyWebAuthnRegistration(rp, challenge, getWebAuthnTestClientData(t, "webauthn.get", challenge, rp.Origin),
+		attestationObject, false)
+	assert.ErrorContains(t, err, "client data type")
+	_, err = VerifyWebAuthnRegistration(rp, challenge, clientData, attestationObject[:len(attestationObject)-1], false)
+	assert.Error(t, err)
+	registration, err := VerifyWebAuthnRegistration(rp, challenge, clientData, attestationObject, false)
+	require.NoError(t, err)
+	assert.Equal(t, credentialID, registration.CredentialID)
+	assert.Equal(t, uint32(0), registration.SignCount)
+
+	challenge = GenerateWebAuthnChallenge()
+	clientData = getWebAuthnTestClientData(t, "webauthn.get", challenge, rp.Origin)
+	authData = getWebAuthnTestAuthData(rp.ID, 0x05, 10, nil, nil)
+	signature := signWebAuthnTestAssertion(t, key, authData, clientData)
+	signCount, err := VerifyWebAuthnAssertion(rp, challenge, registration.PublicKey, registration.SignCount, clientData,
+		authData, signature, true)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(10), signCount)
+	// the counter must increase
+	_, err = VerifyWebAuthnAssertion(rp, challenge, registration.PublicKey, signCount, clientData, authData,
+		signature, true)
+	assert.ErrorIs(t, err, ErrWebAuthnCounter)
+	// invalid signature
+	_, err = VerifyWebAuthnAssertion(rp, challenge, registration.PublicKey, 0, clientData, authData,
+		signWebAuthnTestAssertion(t, key, authData, []byte("{}")), true)
+	assert.ErrorContains(t, err, "invalid signature")
+	// RP ID mismatch
+	authData = getWebAuthnTestAuthData("example.com", 0x05, 11, nil, nil)
+	_, err = VerifyWebAuthnAssertion(rp, challenge, registration.PublicKey, 0, clientData, authData,
+		signWebAuthnTestAssertion(t, key, authData, clientData), true)
+	assert.ErrorContains(t, err, "RP ID hash mismatch")
+	// user verification is required only if requested
+	authData = getWebAuthnTestAuthData(rp.ID, 0x01, 0, nil, nil)
+	signature = signWebAuthnTestAssertion(t, key, authData, clientData)
+	_, err = VerifyWebAuthnAssertion(rp, challenge, registration.PublicKey, 0, clientData, authData, signature, true)
+	assert.ErrorContains(t, err, "user not verified")
+	signCount, err = VerifyWebAuthnAssertion(rp, challenge, registration.PublicKey, 0, clientData, authData, signature,
+		false)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), signCount)
+	// user presence is always required
+	authData = getWebAuthnTestAuthData(rp.ID, 0x04, 0, nil, nil)
+	_, err = VerifyWebAuthnAssertion(rp, challenge, registration.PublicKey, 0, clientData, authData,
+		signWebAuthnTestAssertion(t, key, authData, clientData), false)
+	assert.ErrorContains(t, err, "user not present")
+	// allowed origins
+	config.WebAuthn.Origins = []string{"https://sftpgo.example.com:8443"}
+	err = config.Initialize()
+	require.NoError(t, err)
+	authData = getWebAuthnTestAuthData(rp.ID, 0x05, 0, nil, nil)
+	signature = signWebAuthnTestAssertion(t, key, authData, clientData)
+	_, err = VerifyWebAuthnAssertion(rp, challenge, registration.PublicKey, 0, clientData, authData, signature, true)
+	assert.ErrorContains(t, err, "origin")
+	clientData = getWebAuthnTestClientData(t, "webauthn.get", challenge, "https://sftpgo.example.com:8443")
+	signature = signWebAuthnTestAssertion(t, key, authData, clientData)
+	_, err = VerifyWebAuthnAssertion(rp, challenge, registration.PublicKey, 0, clientData, authData, signature, true)
+	assert.NoError(t, err)
+}
+
+func TestCOSEKeyErrors(t *testing.T) {
+	_, err := parseCOSEPublicKey([]byte{0xa0, 0x00})
+	assert.ErrorContains(t, err, "trailing data")
+	_, err = parseCOSEPublicKey(cborEncodeTest("key"))
+	assert.Error(t, err)
+	_, err = parseCOSEPublicKey(cborEncodeTest(cborTestMap{{int64(1), int64(2)}, {int64(3), int64(-36)}}))
+	assert.ErrorContains(t, err, "unsupported COSE key")
+	_, err = parseCOSEPublicKey(cborEncodeTest(cborTestMap{
+		{int64(1), int64(2)},
+		{int64(3), int64(COSEAlgES256)},
+		{int64(-1), int64(1)},
+		{int64(-2), bytes.Repeat([]byte{0x01}, 32)},
+		{int64(-3), bytes.Repeat([]byte{0x01}, 32)},
+	}))
+	assert.ErrorContains(t, err, "not on curve")
+	_, err = parseCOSEPublicKey(cborEncodeTest(cborTestMap{
+		{int64(1), int64(1)},
+		{int64(3), int64(COSEAlgEdDSA)},
+		{int64(-1), int64(6)},
+		{int64(-2), []byte{0x01}},
+	}))
+	assert.ErrorContains(t, err, "invalid OKP COSE key")
+	_, _, err = cborDecode([]byte{0x5a, 0xff, 0xff, 0xff, 0xff})
+	assert.Error(t, err)
+	_, _, err = cborDecode(bytes.Repeat([]byte{0x81}, 20))
+	assert.Error(t, err)
+}
+
+type cborTestMap [][2]any
+
+// cborEncodeTest encodes the test data using the CBOR subset used by WebAuthn
+func cborEncodeTest(item any) []byte {
+	header := func(major byte, arg uint64) []byte {
+		switch {
+		case arg < 24:
+			return []byte{major<<5 | byte(arg)}
+		case arg < 256:
+			return []byte{major<<5 | 24, byte(arg)}
+		default:
+			b := []byte{major<<5 | 25, 0, 0}
+			binary.BigEndian.PutUint16(b[1:], uint16(arg))
+			return b
+		}
+	}
+	switch v := item.(type) {
+	case int64:
+		if v < 0 {
+			return header(1, uint64(-1-v))
+		}
+		return header(0, uint64(v))
+	case []byte:
+		return append(header(2, uint64(len(v))), v...)
+	case string:
+		return append(header(3, uint64(len(v))), v...)
+	case cborTestMap:
+		result := header(5, uint64(len(v)))
+		for _, kv := range v {
+			result = append(result, cborEncodeTest(kv[0])...)
+			result = append(result, cborEncodeTest(kv[1])...)
+		}
+		return result
+	default:
+		panic("unsupported CBOR test type")
+	}
+}
+
+func getWebAuthnTestCOSEKey(key *ecdsa.PrivateKey) []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.PublicKey.X.FillBytes(x)
+	key.PublicKey.Y.FillBytes(y)
+	return cborEncodeTest(cborTestMap{
+		{int64(1), int64(2)},
+		{int64(3), int64(COSEAlgES256)},
+		{int64(-1), int64(1)},
+		{int64(-2), x},
+		{int64(-3), y},
+	})
+}
+
+func getWebAuthnTestClientData(t *testing.T, ceremony, challenge, origin string) []byte {
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": challenge,
+		"origin":    origin,
+	})
+	require.NoError(t, err)
+	return data
+}
+
+func getWebAuthnTestAuthData(rpID string, flags byte, signCount uint32, credentialID, publicKey []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	result := append([]byte(nil), rpIDHash[:]...)
+	result = append(result, flags)
+	result = binary.BigEndian.AppendUint32(result, signCount)
+	if len(credentialID) > 0 {
+		result = append(result, make([]byte, 16)...)
+		result = binary.BigEndian.AppendUint16(result, uint16(len(credentialID)))
+		result = append(result, credentialID...)
+		result = append(result, publicKey...)
+	}
+	return result
+}
+
+func signWebAuthnTestAssertion(t *testing.T, key *ecdsa.PrivateKey, authData, clientData []byte) []byte {
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	return signature
+}
+
+func TestPushConfig(t *testing.T) {
+	config := Config{
+		Push: PushConfig{
+			APIHost: "api-test.example.com",
+		},
+	}
+	err := config.Initialize()
+	assert.Error(t, err)
+	assert.False(t, IsPushEnabled())
+	config.Push.IntegrationKey = "ikey"
+	config.Push.SecretKey = "skey"
+	config.Push.Timeout = 301
+	err = config.Initialize()
+	assert.Error(t, err)
+	config.Push.Timeout = 0
+	config.Push.APIHost = "https://"
+	err = config.Initialize()
+	assert.Error(t, err)
+	config.Push.APIHost = "api-test.example.com"
+	err = config.Initialize()
+	assert.NoError(t, err)
+	assert.True(t, IsPushEnabled())
+	assert.False(t, IsPushFallbackToTOTPEnabled())
+	assert.True(t, GetStatus().Push)
+	assert.Equal(t, "https://api-test.example.com", pushConfig.baseURL.String())
+	assert.Equal(t, pushDefaultTimeout, pushConfig.Timeout)
+
+	config.Push = PushConfig{}
+	err = config.Initialize()
+	assert.NoError(t, err)
+	assert.False(t, IsPushEnabled())
+	err = SendPushRequest(PushRequest{Username: "user"})
+	assert.ErrorIs(t, err, ErrPushUnavailable)
+
+	stopCleanupTicker()
+}
+
+func TestPushRequest(t *testing.T) {
+	ikey := "integration key"
+	skey := "secret key"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		username, password, ok := r.BasicAuth()
+		expected := signPushRequest(r.Header.Get("Date"), r.Method, r.Host, r.URL.Path, string(body), skey)
+		if !ok || username != ikey || password != expected || r.URL.Path != "/prefix"+pushAuthPath {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"stat":"FAIL","code":40101,"message":"Missing request credentials"}`)
+			return
+		}
+		params, err := url.ParseQuery(string(body))
+		if err != nil || params.Get("factor") != "push" || params.Get("ipaddr") != "127.0.0.1" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"stat":"FAIL","code":40002,"message":"Invalid request parameters"}`)
+			return
+		}
+		switch params.Get("username") {
+		case "allow":
+			fmt.Fprint(w, `{"stat":"OK","response":{"result":"allow","status":"allow","status_msg":"Success"}}`)
+		case "deny":
+			fmt.Fprint(w, `{"stat":"OK","response":{"result":"deny","status":"deny","status_msg":"Login request denied"}}`)
+		case "slow":
+			time.Sleep(1500 * time.Millisecond)
+			fmt.Fprint(w, `{"stat":"OK","response":{"result":"allow","status":"allow","status_msg":"Success"}}`)
+		default:
+			fmt.Fprint(w, "invalid json")
+		}
+	}))
+	defer ts.Close()
+
+	config := Config{
+		Push: PushConfig{
+			APIHost:        ts.URL + "/prefix/",
+			IntegrationKey: ikey,
+			SecretKey:      skey,
+			Timeout:        1,
+			FallbackToTOTP: true,
+		},
+	}
+	// push requests use the shared HTTP client configuration
+	httpConfig := httpclient.Config{
+		Timeout: 5,
+	}
+	err := httpConfig.Initialize("")
+	require.NoError(t, err)
+	err = config.Initialize()
+	require.NoError(t, err)
+	defer stopCleanupTicker()
+
+	assert.True(t, IsPushFallbackToTOTPEnabled())
+	err = SendPushRequest(PushRequest{})
+	assert.Error(t, err)
+	err = SendPushRequest(PushRequest{Username: "allow", IP: "127.0.0.1", Protocol: "SSH"})
+	assert.NoError(t, err)
+	err = SendPushRequest(PushRequest{Username: "deny", IP: "127.0.0.1", Protocol: "HTTP"})
+	assert.ErrorIs(t, err, ErrPushDenied)
+	assert.NotErrorIs(t, err, ErrPushUnavailable)
+	err = SendPushRequest(PushRequest{Username: "slow", IP: "127.0.0.1"})
+	assert.ErrorIs(t, err, ErrPushUnavailable)
+	err = SendPushRequest(PushRequest{Username: "invalid", IP: "127.0.0.1"})
+	assert.ErrorIs(t, err, ErrPushUnavailable)
+	err = SendPushRequest(PushRequest{Username: "allow", IP: "127.0.0.2"})
+	if assert.ErrorIs(t, err, ErrPushUnavailable) {
+		assert.Contains(t, err.Error(), "40002")
+	}
+	pushConfig.SecretKey = "invalid"
+	err = SendPushRequest(PushRequest{Username: "allow", IP: "127.0.0.1"})
+	if assert.ErrorIs(t, err, ErrPushUnavailable) {
+		assert.Contains(t, err.Error(), "40101")
+	}
+}
+
+func TestCanonicalizePushParams(t *testing.T) {
+	params := url.Values{}
+	params.Set("username", "user name")
+	params.Set("factor", "push")
+	params.Add("a", "b+c")
+	assert.Equal(t, "a=b%2Bc&factor=push&username=user%20name", canonicalizePushParams(params))
+	sig := signPushRequest("Tue, 21 Aug 2012 17:29:18 -0000", "post", "API-xxxxxxxx.duosecurity.com",
+		"/auth/v2/auth", canonicalizePushParams(params), "skey")
+	assert.Len(t, sig, 128)
+	assert.Equal(t, sig, signPushRequest("Tue, 21 Aug 2012 17:29:18 -0000", "POST", "api-xxxxxxxx.duosecurity.com",
+		"/auth/v2/auth", canonicalizePushParams(params), "skey"))
+}
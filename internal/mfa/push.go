@@ -0,0 +1,227 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package mfa
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/httpclient"
+)
+
+const (
+	pushAuthPath          = "/auth/v2/auth"
+	pushDefaultTimeout    = 60
+	pushMaxTimeout        = 300
+	pushResultAllow       = "allow"
+	pushStatOK            = "OK"
+	pushMaxResponseLength = 1048576
+)
+
+var (
+	pushConfig PushConfig
+	// ErrPushDenied is returned if the user explicitly denied the push request
+	ErrPushDenied = errors.New("push: authentication denied")
+	// ErrPushUnavailable is returned if the push request cannot be completed,
+	// for example the provider cannot be reached or the user did not respond in time.
+	// Clients can fallback to a different second factor for this error
+	ErrPushUnavailable = errors.New("push: authentication unavailable")
+)
+
+// PushConfig defines the configuration for a push based multi-factor authentication
+// provider. The provider must implement a Duo-style Auth API
+type PushConfig struct {
+	// API hostname, for example "api-xxxxxxxx.duosecurity.com". Empty means disabled.
+	// You can also specify a base URL, for example "https://mfa.example.com", this is
+	// useful for self-hosted providers implementing the same API
+	APIHost string `json:"api_host" mapstructure:"api_host"`
+	// Integration key
+	IntegrationKey string `json:"integration_key" mapstructure:"integration_key"`
+	// Secret key used to sign the requests
+	SecretKey string `json:"secret_key" mapstructure:"secret_key"`
+	// Maximum time, in seconds, to wait for the user to approve the push notification
+	Timeout int `json:"timeout" mapstructure:"timeout"`
+	// If enabled and the push request cannot be completed, users with TOTP configured
+	// for the same protocol will be asked for an authentication code. Explicitly denied
+	// requests never fallback
+	FallbackToTOTP bool `json:"fallback_to_totp" mapstructure:"fallback_to_totp"`
+	baseURL        *url.URL
+}
+
+func (c *PushConfig) validate() error {
+	if c.APIHost == "" {
+		return nil
+	}
+	if c.IntegrationKey == "" || c.SecretKey == "" {
+		return errors.New("push: integration key and secret key are mandatory")
+	}
+	apiURL := c.APIHost
+	if !strings.HasPrefix(apiURL, "https://") && !strings.HasPrefix(apiURL, "http://") {
+		apiURL = "https://" + apiURL
+	}
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("push: invalid API host %q", c.APIHost)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	c.baseURL = u
+	if c.Timeout <= 0 {
+		c.Timeout = pushDefaultTimeout
+	}
+	if c.Timeout > pushMaxTimeout {
+		return fmt.Errorf("push: invalid timeout %d, max allowed: %d", c.Timeout, pushMaxTimeout)
+	}
+	return nil
+}
+
+// PushRequest defines the details sent to the provider for a push authentication
+type PushRequest struct {
+	// Username as known by the provider
+	Username string
+	IP       string
+	// Protocol used to login, it is added to the push details
+	Protocol string
+}
+
+type pushResponse struct {
+	Stat     string `json:"stat"`
+	Code     int    `json:"code"`
+	Message  string `json:"message"`
+	Response struct {
+		Result    string `json:"result"`
+		Status    string `json:"status"`
+		StatusMsg string `json:"status_msg"`
+	} `json:"response"`
+}
+
+// IsPushEnabled returns true if a push provider is configured
+func IsPushEnabled() bool {
+	return pushConfig.baseURL != nil
+}
+
+// IsPushFallbackToTOTPEnabled returns true if users should be asked for a TOTP
+// authentication code if the push request cannot be completed
+func IsPushFallbackToTOTPEnabled() bool {
+	return IsPushEnabled() && pushConfig.FallbackToTOTP
+}
+
+// SendPushRequest sends a push notification and waits for the user to approve it.
+// A nil error is returned if the user approved the request
+func SendPushRequest(req PushRequest) error {
+	if !IsPushEnabled() {
+		return fmt.Errorf("%w: push provider not configured", ErrPushUnavailable)
+	}
+	if req.Username == "" {
+		return errors.New("push: username is mandatory")
+	}
+	params := url.Values{}
+	params.Set("username", req.Username)
+	params.Set("factor", "push")
+	params.Set("device", "auto")
+	params.Set("type", "SFTPGo login")
+	if req.IP != "" {
+		params.Set("ipaddr", req.IP)
+	}
+	if req.Protocol != "" {
+		pushInfo := url.Values{}
+		pushInfo.Set("protocol", req.Protocol)
+		params.Set("pushinfo", pushInfo.Encode())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(pushConfig.Timeout)*time.Second)
+	defer cancel()
+
+	resp, err := doPushRequest(ctx, http.MethodPost, pushAuthPath, params)
+	if err != nil {
+		return err
+	}
+	if resp.Response.Result != pushResultAllow {
+		return fmt.Errorf("%w, status: %q, message: %q", ErrPushDenied, resp.Response.Status,
+			resp.Response.StatusMsg)
+	}
+	return nil
+}
+
+func doPushRequest(ctx context.Context, method, path string, params url.Values) (*pushResponse, error) {
+	body := canonicalizePushParams(params)
+	endpoint := *pushConfig.baseURL
+	endpoint.Path += path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint.String(), strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	date := time.Now().UTC().Format(time.RFC1123Z)
+	req.Header.Set("Date", date)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(pushConfig.IntegrationKey, signPushRequest(date, method, pushConfig.baseURL.Host,
+		endpoint.Path, body, pushConfig.SecretKey))
+
+	client := httpclient.GetHTTPClient()
+	client.Timeout = 0 // the request context defines the timeout
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPushUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	var result pushResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, pushMaxResponseLength)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("%w: unable to decode response, status code %d: %v", ErrPushUnavailable,
+			resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || result.Stat != pushStatOK {
+		return nil, fmt.Errorf("%w: unexpected response, status code %d, error code %d, message: %q",
+			ErrPushUnavailable, resp.StatusCode, result.Code, result.Message)
+	}
+	return &result, nil
+}
+
+// canonicalizePushParams returns the URL encoded parameters sorted by key,
+// spaces are encoded as "%20"
+func canonicalizePushParams(params url.Values) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		for _, v := range params[k] {
+			parts = append(parts, url.QueryEscape(k)+"="+strings.ReplaceAll(url.QueryEscape(v), "+", "%20"))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// signPushRequest returns the hex encoded HMAC-SHA512 signature of the canonical request
+func signPushRequest(date, method, host, path, params, secretKey string) string {
+	canonical := strings.Join([]string{date, strings.ToUpper(method), strings.ToLower(host), path, params}, "\n")
+	mac := hmac.New(sha512.New, []byte(secretKey))
+	mac.Write([]byte(canonical))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,404 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package mfa
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// COSE algorithm identifiers for the supported WebAuthn public keys
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+const (
+	webAuthnTypeCreate = "webauthn.create"
+	webAuthnTypeGet    = "webauthn.get"
+	// authenticator data flags
+	webAuthnFlagUserPresent  = 0x01
+	webAuthnFlagUserVerified = 0x04
+	webAuthnFlagAttestedData = 0x40
+	webAuthnFlagExtensions   = 0x80
+	// rpIdHash (32) + flags (1) + signCount (4)
+	webAuthnAuthDataMinLen = 37
+)
+
+var (
+	webAuthnConfig WebAuthnConfig
+	// ErrWebAuthnCounter is returned if the signature counter of a credential
+	// did not increase, this may indicate a cloned authenticator
+	ErrWebAuthnCounter = errors.New("webauthn: invalid signature counter, the authenticator may be cloned")
+)
+
+// WebAuthnConfig defines the configuration for WebAuthn (FIDO2) security keys
+// and passkeys
+type WebAuthnConfig struct {
+	// Set to true to allow WebClient and WebAdmin users to register security keys
+	// and use them as second factor or as passwordless primary factor
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Relying Party ID, it must be the domain name, or a registrable suffix of it,
+	// used to access SFTPGo. Leave empty to use the host of each request.
+	// The credentials are bound to the RP ID, changing it will invalidate
+	// the registered credentials
+	RPID string `json:"rp_id" mapstructure:"rp_id"`
+	// Relying Party name displayed by the browsers and authenticators
+	RPDisplayName string `json:"rp_display_name" mapstructure:"rp_display_name"`
+	// Allowed origins, for example "https://sftpgo.example.com". If empty, the origin
+	// must match the scheme and host of the request
+	Origins []string `json:"origins" mapstructure:"origins"`
+}
+
+func (c *WebAuthnConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.RPID != "" && strings.ContainsAny(c.RPID, ":/") {
+		return fmt.Errorf("webauthn: invalid RP ID %q, it must be a domain name", c.RPID)
+	}
+	for _, origin := range c.Origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("webauthn: invalid origin %q", origin)
+		}
+	}
+	if c.RPDisplayName == "" {
+		c.RPDisplayName = "SFTPGo"
+	}
+	return nil
+}
+
+// WebAuthnRelyingParty defines the Relying Party parameters used to validate
+// WebAuthn responses
+type WebAuthnRelyingParty struct {
+	ID string
+	// Origin of the current request, used if no origin is configured
+	Origin string
+}
+
+func (rp *WebAuthnRelyingParty) checkOrigin(origin string) error {
+	if len(webAuthnConfig.Origins) > 0 {
+		if util.Contains(webAuthnConfig.Origins, origin) {
+			return nil
+		}
+	} else if origin == rp.Origin {
+		return nil
+	}
+	return fmt.Errorf("webauthn: origin %q not allowed", origin)
+}
+
+// WebAuthnRegistration defines a verified WebAuthn registration
+type WebAuthnRegistration struct {
+	CredentialID []byte
+	// COSE encoded public key
+	PublicKey []byte
+	SignCount uint32
+}
+
+// IsWebAuthnEnabled returns true if WebAuthn is enabled
+func IsWebAuthnEnabled() bool {
+	return webAuthnConfig.Enabled
+}
+
+// GetWebAuthnRPDisplayName returns the configured Relying Party display name
+func GetWebAuthnRPDisplayName() string {
+	return webAuthnConfig.RPDisplayName
+}
+
+// GetWebAuthnRelyingParty returns the Relying Party to use for a request
+// with the specified scheme and host
+func GetWebAuthnRelyingParty(scheme, host string) WebAuthnRelyingParty {
+	rp := WebAuthnRelyingParty{
+		ID:     webAuthnConfig.RPID,
+		Origin: fmt.Sprintf("%s://%s", scheme, host),
+	}
+	if rp.ID == "" {
+		rp.ID = host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			rp.ID = h
+		}
+	}
+	return rp
+}
+
+// GetWebAuthnAlgorithms returns the supported COSE algorithms in order of preference
+func GetWebAuthnAlgorithms() []int {
+	return []int{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+}
+
+// GenerateWebAuthnChallenge returns a new random challenge base64url encoded
+func GenerateWebAuthnChallenge() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return util.GenerateUniqueID()
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// VerifyWebAuthnRegistration validates the response to a credential creation request.
+// Attestation statements are not verified, this is consistent with the "none"
+// attestation conveyance preference requested to the clients
+func VerifyWebAuthnRegistration(rp WebAuthnRelyingParty, challenge string, clientDataJSON,
+	attestationObject []byte, requireUserVerification bool,
+) (WebAuthnRegistration, error) {
+	var result WebAuthnRegistration
+
+	if err := verifyWebAuthnClientData(rp, clientDataJSON, webAuthnTypeCreate, challenge); err != nil {
+		return result, err
+	}
+	obj, _, err := cborDecode(attestationObject)
+	if err != nil {
+		return result, fmt.Errorf("webauthn: invalid attestation object: %w", err)
+	}
+	attestation, ok := obj.(map[any]any)
+	if !ok {
+		return result, errors.New("webauthn: invalid attestation object")
+	}
+	authDataBytes, ok := attestation["authData"].([]byte)
+	if !ok {
+		return result, errors.New("webauthn: missing authenticator data")
+	}
+	authData, err := parseWebAuthnAuthData(authDataBytes)
+	if err != nil {
+		return result, err
+	}
+	if err := authData.verify(rp.ID, requireUserVerification); err != nil {
+		return result, err
+	}
+	if len(authData.credentialID) == 0 {
+		return result, errors.New("webauthn: missing attested credential data")
+	}
+	if _, err := parseCOSEPublicKey(authData.publicKey); err != nil {
+		return result, err
+	}
+	result.CredentialID = authData.credentialID
+	result.PublicKey = authData.publicKey
+	result.SignCount = authData.signCount
+	return result, nil
+}
+
+// VerifyWebAuthnAssertion validates the response to an authentication request
+// for a credential with the specified COSE public key and signature counter.
+// The new signature counter is returned
+func VerifyWebAuthnAssertion(rp WebAuthnRelyingParty, challenge string, publicKey []byte, signCount uint32,
+	clientDataJSON, authenticatorData, signature []byte, requireUserVerification bool,
+) (uint32, error) {
+	if err := verifyWebAuthnClientData(rp, clientDataJSON, webAuthnTypeGet, challenge); err != nil {
+		return 0, err
+	}
+	authData, err := parseWebAuthnAuthData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := authData.verify(rp.ID, requireUserVerification); err != nil {
+		return 0, err
+	}
+	key, err := parseCOSEPublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	signedData := make([]byte, 0, len(authenticatorData)+len(clientDataHash))
+	signedData = append(signedData, authenticatorData...)
+	signedData = append(signedData, clientDataHash[:]...)
+	if err := key.verify(signedData, signature); err != nil {
+		return 0, err
+	}
+	// authenticators without a signature counter always return zero
+	if (authData.signCount != 0 || signCount != 0) && authData.signCount <= signCount {
+		return 0, ErrWebAuthnCounter
+	}
+	return authData.signCount, nil
+}
+
+type webAuthnClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func verifyWebAuthnClientData(rp WebAuthnRelyingParty, clientDataJSON []byte, expectedType, challenge string) error {
+	var clientData webAuthnClientData
+	if err := json.Unmarshal(clientDataJSON, &clientData); err != nil {
+		return fmt.Errorf("webauthn: invalid client data: %w", err)
+	}
+	if clientData.Type != expectedType {
+		return fmt.Errorf("webauthn: unexpected client data type %q", clientData.Type)
+	}
+	if challenge == "" || subtle.ConstantTimeCompare([]byte(clientData.Challenge), []byte(challenge)) != 1 {
+		return errors.New("webauthn: challenge mismatch")
+	}
+	return rp.checkOrigin(clientData.Origin)
+}
+
+type webAuthnAuthData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (a *webAuthnAuthData) verify(rpID string, requireUserVerification bool) error {
+	expectedHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(a.rpIDHash, expectedHash[:]) {
+		return errors.New("webauthn: RP ID hash mismatch")
+	}
+	if a.flags&webAuthnFlagUserPresent == 0 {
+		return errors.New("webauthn: user not present")
+	}
+	if requireUserVerification && a.flags&webAuthnFlagUserVerified == 0 {
+		return errors.New("webauthn: user not verified")
+	}
+	return nil
+}
+
+func parseWebAuthnAuthData(data []byte) (webAuthnAuthData, error) {
+	var result webAuthnAuthData
+
+	if len(data) < webAuthnAuthDataMinLen {
+		return result, errors.New("webauthn: authenticator data too short")
+	}
+	result.rpIDHash = data[:32]
+	result.flags = data[32]
+	result.signCount = binary.BigEndian.Uint32(data[33:37])
+	rest := data[webAuthnAuthDataMinLen:]
+	if result.flags&webAuthnFlagAttestedData != 0 {
+		// aaguid (16) + credentialIdLength (2)
+		if len(rest) < 18 {
+			return result, errors.New("webauthn: invalid attested credential data")
+		}
+		credIDLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if credIDLen == 0 || len(rest) < credIDLen {
+			return result, errors.New("webauthn: invalid credential ID length")
+		}
+		result.credentialID = append([]byte(nil), rest[:credIDLen]...)
+		rest = rest[credIDLen:]
+		_, n, err := cborDecode(rest)
+		if err != nil {
+			return result, fmt.Errorf("webauthn: invalid credential public key: %w", err)
+		}
+		result.publicKey = append([]byte(nil), rest[:n]...)
+		rest = rest[n:]
+	}
+	if result.flags&webAuthnFlagExtensions != 0 {
+		_, n, err := cborDecode(rest)
+		if err != nil {
+			return result, fmt.Errorf("webauthn: invalid extensions: %w", err)
+		}
+		rest = rest[n:]
+	}
+	if len(rest) > 0 {
+		return result, errors.New("webauthn: unexpected trailing data in authenticator data")
+	}
+	return result, nil
+}
+
+type cosePublicKey struct {
+	key crypto.PublicKey
+}
+
+func (k *cosePublicKey) verify(data, signature []byte) error {
+	switch pub := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		if ecdsa.VerifyASN1(pub, digest[:], signature) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	case ed25519.PublicKey:
+		if ed25519.Verify(pub, data, signature) {
+			return nil
+		}
+	}
+	return errors.New("webauthn: invalid signature")
+}
+
+// parseCOSEPublicKey parses a COSE_Key (RFC 9053) with one of the supported algorithms
+func parseCOSEPublicKey(data []byte) (*cosePublicKey, error) {
+	obj, n, err := cborDecode(data)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: invalid COSE key: %w", err)
+	}
+	if n != len(data) {
+		return nil, errors.New("webauthn: unexpected trailing data in COSE key")
+	}
+	m, ok := obj.(map[any]any)
+	if !ok {
+		return nil, errors.New("webauthn: invalid COSE key")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("webauthn: invalid EC2 COSE key")
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("webauthn: EC2 COSE key point is not on curve")
+		}
+		return &cosePublicKey{key: pub}, nil
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("webauthn: invalid OKP COSE key")
+		}
+		return &cosePublicKey{key: ed25519.PublicKey(x)}, nil
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("webauthn: invalid RSA COSE key")
+		}
+		exp := new(big.Int).SetBytes(e)
+		return &cosePublicKey{key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}}, nil
+	default:
+		return nil, fmt.Errorf("webauthn: unsupported COSE key type %d, algorithm %d", kty, alg)
+	}
+}
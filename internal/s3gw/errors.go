@@ -0,0 +1,116 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package s3gw
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+// apiErrorCode defines an S3 error code and its default message and HTTP status
+type apiErrorCode struct {
+	code       string
+	message    string
+	statusCode int
+}
+
+var (
+	errAccessDenied                 = apiErrorCode{"AccessDenied", "Access Denied", http.StatusForbidden}
+	errAuthorizationHeaderMalformed = apiErrorCode{"AuthorizationHeaderMalformed",
+		"The authorization header is malformed", http.StatusBadRequest}
+	errBadDigest             = apiErrorCode{"BadDigest", "The Content-MD5 you specified did not match what we received", http.StatusBadRequest}
+	errBucketAlreadyOwned    = apiErrorCode{"BucketAlreadyOwnedByYou", "The bucket you tried to create already exists", http.StatusConflict}
+	errBucketNotEmpty        = apiErrorCode{"BucketNotEmpty", "The bucket you tried to delete is not empty", http.StatusConflict}
+	errContentSHA256Mismatch = apiErrorCode{"XAmzContentSHA256Mismatch",
+		"The provided 'x-amz-content-sha256' header does not match what was computed", http.StatusBadRequest}
+	errEntityTooLarge       = apiErrorCode{"EntityTooLarge", "Your proposed upload exceeds the maximum allowed size", http.StatusBadRequest}
+	errIncompleteBody       = apiErrorCode{"IncompleteBody", "The request body is incomplete or malformed", http.StatusBadRequest}
+	errInternalError        = apiErrorCode{"InternalError", "We encountered an internal error, please try again", http.StatusInternalServerError}
+	errInvalidAccessKeyID   = apiErrorCode{"InvalidAccessKeyId", "The access key ID you provided does not exist in our records", http.StatusForbidden}
+	errInvalidArgument      = apiErrorCode{"InvalidArgument", "Invalid argument", http.StatusBadRequest}
+	errInvalidDigest        = apiErrorCode{"InvalidDigest", "The Content-MD5 you specified is not valid", http.StatusBadRequest}
+	errInvalidPart          = apiErrorCode{"InvalidPart", "One or more of the specified parts could not be found", http.StatusBadRequest}
+	errInvalidPartOrder     = apiErrorCode{"InvalidPartOrder", "The list of parts was not in ascending order", http.StatusBadRequest}
+	errInvalidRange         = apiErrorCode{"InvalidRange", "The requested range is not satisfiable", http.StatusRequestedRangeNotSatisfiable}
+	errInvalidRequest       = apiErrorCode{"InvalidRequest", "Invalid request", http.StatusBadRequest}
+	errMalformedXML         = apiErrorCode{"MalformedXML", "The XML you provided was not well-formed", http.StatusBadRequest}
+	errMethodNotAllowed     = apiErrorCode{"MethodNotAllowed", "The specified method is not allowed against this resource", http.StatusMethodNotAllowed}
+	errMissingContentLength = apiErrorCode{"MissingContentLength", "You must provide the Content-Length HTTP header", http.StatusLengthRequired}
+	errNoSuchBucket         = apiErrorCode{"NoSuchBucket", "The specified bucket does not exist", http.StatusNotFound}
+	errNoSuchKey            = apiErrorCode{"NoSuchKey", "The specified key does not exist", http.StatusNotFound}
+	errNoSuchUpload         = apiErrorCode{"NoSuchUpload", "The specified multipart upload does not exist", http.StatusNotFound}
+	errNotImplemented       = apiErrorCode{"NotImplemented", "A header or query you provided implies functionality that is not implemented", http.StatusNotImplemented}
+	errQuotaExceeded        = apiErrorCode{"QuotaExceeded", "Your quota has been exceeded", http.StatusForbidden}
+	errRequestTimeTooSkewed = apiErrorCode{"RequestTimeTooSkewed",
+		"The difference between the request time and the server's time is too large", http.StatusForbidden}
+	errSignatureDoesNotMatch = apiErrorCode{"SignatureDoesNotMatch",
+		"The request signature we calculated does not match the signature you provided", http.StatusForbidden}
+	errSlowDown = apiErrorCode{"SlowDown", "Please reduce your request rate", http.StatusServiceUnavailable}
+)
+
+// apiError is an error that can be rendered as an S3 error response
+type apiError struct {
+	code    apiErrorCode
+	message string
+}
+
+func (e *apiError) Error() string {
+	if e.message != "" {
+		return e.message
+	}
+	return e.code.message
+}
+
+func newAPIError(code apiErrorCode, message string) *apiError {
+	return &apiError{
+		code:    code,
+		message: message,
+	}
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// getAPIError maps the specified error to an S3 error. notFoundCode is the
+// error to use if the requested resource does not exist
+func getAPIError(err error, notFoundCode apiErrorCode) *apiError {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	switch {
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, common.ErrNotExist), errors.Is(err, util.ErrNotFound):
+		return newAPIError(notFoundCode, "")
+	case errors.Is(err, os.ErrPermission), errors.Is(err, common.ErrPermissionDenied):
+		return newAPIError(errAccessDenied, "")
+	case errors.Is(err, common.ErrQuotaExceeded), errors.Is(err, common.ErrReadQuotaExceeded):
+		return newAPIError(errQuotaExceeded, "")
+	case errors.Is(err, common.ErrOpUnsupported):
+		return newAPIError(errNotImplemented, "")
+	case errors.Is(err, common.ErrTransferAborted), errors.Is(err, common.ErrShuttingDown):
+		return newAPIError(errSlowDown, "")
+	}
+	return newAPIError(errInternalError, "")
+}
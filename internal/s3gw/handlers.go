@@ -0,0 +1,958 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package s3gw
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+const (
+	s3Namespace       = "http://s3.amazonaws.com/doc/2006-03-01/"
+	s3TimeFormat      = "2006-01-02T15:04:05.000Z"
+	maxKeysLimit      = 1000
+	maxXMLBodySize    = 2 * 1024 * 1024
+	maxDeleteObjects  = 1000
+	defaultObjectMime = "binary/octet-stream"
+	emptyObjectETag   = `"d41d8cd98f00b204e9800998ecf8427e"`
+)
+
+var (
+	// sub resources we don't implement, requests using them are rejected instead
+	// of being handled as plain object or bucket requests
+	unsupportedSubResources = []string{"acl", "analytics", "cors", "encryption", "inventory", "lifecycle", "logging",
+		"metrics", "notification", "object-lock", "ownershipControls", "policy", "publicAccessBlock", "replication",
+		"requestPayment", "restore", "retention", "legal-hold", "select", "tagging", "torrent", "versioning",
+		"versions", "website", "accelerate", "attributes"}
+	responseHeaderOverrides = map[string]string{
+		"response-content-type":        "Content-Type",
+		"response-content-language":    "Content-Language",
+		"response-expires":             "Expires",
+		"response-cache-control":       "Cache-Control",
+		"response-content-disposition": "Content-Disposition",
+		"response-content-encoding":    "Content-Encoding",
+	}
+)
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type bucketInfo struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Owner   owner        `xml:"Owner"`
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+type locationConstraint struct {
+	XMLName xml.Name `xml:"LocationConstraint"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Region  string   `xml:",chardata"`
+}
+
+type objectInfo struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Marker                *string        `xml:"Marker,omitempty"`
+	NextMarker            string         `xml:"NextMarker,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              *int           `xml:"KeyCount,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectInfo   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type objectIdentifier struct {
+	Key string `xml:"Key"`
+}
+
+type deleteRequest struct {
+	XMLName xml.Name           `xml:"Delete"`
+	Quiet   bool               `xml:"Quiet"`
+	Objects []objectIdentifier `xml:"Object"`
+}
+
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type deleteResult struct {
+	XMLName xml.Name           `xml:"DeleteResult"`
+	Xmlns   string             `xml:"xmlns,attr"`
+	Deleted []objectIdentifier `xml:"Deleted"`
+	Errors  []deleteError      `xml:"Error"`
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// requestHandler handles a single authenticated S3 request
+type requestHandler struct {
+	config     *Configuration
+	conn       *Connection
+	signature  *signatureInfo
+	w          http.ResponseWriter
+	r          *http.Request
+	statusCode int
+	err        error
+}
+
+func (h *requestHandler) serve() {
+	bucket, key := splitBucketAndKey(h.r.URL.Path)
+	q := h.r.URL.Query()
+	for _, subResource := range unsupportedSubResources {
+		if q.Has(subResource) {
+			h.sendError(newAPIError(errNotImplemented, fmt.Sprintf("the %q sub resource is not supported", subResource)),
+				errNoSuchKey)
+			return
+		}
+	}
+	if bucket == "" {
+		if h.r.Method == http.MethodGet {
+			h.listBuckets()
+			return
+		}
+		h.sendError(newAPIError(errMethodNotAllowed, ""), errNoSuchBucket)
+		return
+	}
+	if !isValidPathSegment(bucket) {
+		h.sendError(newAPIError(errInvalidArgument, "invalid bucket name"), errNoSuchBucket)
+		return
+	}
+	if key == "" {
+		h.serveBucket(bucket, q)
+		return
+	}
+	if !isValidKey(key) {
+		h.sendError(newAPIError(errInvalidArgument, "object keys with empty, \".\" or \"..\" path segments are not supported"),
+			errNoSuchKey)
+		return
+	}
+	h.serveObject(bucket, key, q)
+}
+
+func (h *requestHandler) serveBucket(bucket string, q url.Values) {
+	switch h.r.Method {
+	case http.MethodGet:
+		switch {
+		case q.Has("location"):
+			h.getBucketLocation(bucket)
+		case q.Has("uploads"):
+			h.sendError(newAPIError(errNotImplemented, "listing multipart uploads is not supported"), errNoSuchBucket)
+		default:
+			h.listObjects(bucket, q)
+		}
+	case http.MethodHead:
+		h.headBucket(bucket)
+	case http.MethodPut:
+		h.createBucket(bucket)
+	case http.MethodDelete:
+		h.deleteBucket(bucket)
+	case http.MethodPost:
+		if q.Has("delete") {
+			h.deleteObjects(bucket)
+			return
+		}
+		h.sendError(newAPIError(errNotImplemented, ""), errNoSuchBucket)
+	default:
+		h.sendError(newAPIError(errMethodNotAllowed, ""), errNoSuchBucket)
+	}
+}
+
+func (h *requestHandler) serveObject(bucket, key string, q url.Values) {
+	uploadID := q.Get("uploadId")
+
+	switch h.r.Method {
+	case http.MethodGet:
+		if uploadID != "" {
+			h.sendError(newAPIError(errNotImplemented, "listing parts is not supported"), errNoSuchUpload)
+			return
+		}
+		h.getObject(bucket, key, false)
+	case http.MethodHead:
+		h.getObject(bucket, key, true)
+	case http.MethodPut:
+		if h.r.Header.Get("X-Amz-Copy-Source") != "" {
+			h.sendError(newAPIError(errNotImplemented, "copying objects is not supported"), errNoSuchKey)
+			return
+		}
+		if uploadID != "" {
+			h.uploadPart(bucket, key, uploadID, q.Get("partNumber"))
+			return
+		}
+		h.putObject(bucket, key)
+	case http.MethodDelete:
+		if uploadID != "" {
+			h.abortMultipartUpload(bucket, key, uploadID)
+			return
+		}
+		h.deleteObject(bucket, key)
+	case http.MethodPost:
+		if q.Has("uploads") {
+			h.createMultipartUpload(bucket, key)
+			return
+		}
+		if uploadID != "" {
+			h.completeMultipartUpload(bucket, key, uploadID)
+			return
+		}
+		h.sendError(newAPIError(errNotImplemented, ""), errNoSuchKey)
+	default:
+		h.sendError(newAPIError(errMethodNotAllowed, ""), errNoSuchKey)
+	}
+}
+
+func (h *requestHandler) sendError(err error, notFoundCode apiErrorCode) {
+	h.err = err
+	h.statusCode = renderError(h.w, h.r, err, notFoundCode)
+}
+
+func (h *requestHandler) sendXML(statusCode int, v any) {
+	h.statusCode = statusCode
+	writeXML(h.w, statusCode, v)
+}
+
+func (h *requestHandler) sendStatus(statusCode int) {
+	h.statusCode = statusCode
+	h.w.WriteHeader(statusCode)
+}
+
+// disableDeadlines removes the server timeouts for requests transferring object data
+func (h *requestHandler) disableDeadlines() {
+	rc := http.NewResponseController(h.w)
+	rc.SetReadDeadline(time.Time{})  //nolint:errcheck
+	rc.SetWriteDeadline(time.Time{}) //nolint:errcheck
+}
+
+// checkBucket returns an error if the specified bucket does not exist
+func (h *requestHandler) checkBucket(bucket string) error {
+	info, err := h.conn.DoStat(getBucketPath(bucket), 0, false)
+	if err != nil {
+		if h.conn.IsNotExistError(err) {
+			return newAPIError(errNoSuchBucket, "")
+		}
+		return err
+	}
+	if !info.IsDir() {
+		return newAPIError(errNoSuchBucket, "")
+	}
+	return nil
+}
+
+// readXMLBody reads and verifies the request body and decodes it into v
+func (h *requestHandler) readXMLBody(v any) error {
+	reader, _, err := getPayloadReader(h.r, h.signature)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, maxXMLBodySize+1))
+	if err != nil {
+		return err
+	}
+	if len(data) > maxXMLBodySize {
+		return newAPIError(errEntityTooLarge, "")
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return newAPIError(errMalformedXML, "")
+	}
+	return nil
+}
+
+func (h *requestHandler) listBuckets() {
+	files, err := h.conn.ListDir("/")
+	if err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	result := listAllMyBucketsResult{
+		Xmlns: s3Namespace,
+		Owner: owner{
+			ID:          h.conn.User.Username,
+			DisplayName: h.conn.User.Username,
+		},
+		Buckets: []bucketInfo{},
+	}
+	for _, info := range files {
+		if info.IsDir() && isValidPathSegment(info.Name()) {
+			result.Buckets = append(result.Buckets, bucketInfo{
+				Name:         info.Name(),
+				CreationDate: info.ModTime().UTC().Format(s3TimeFormat),
+			})
+		}
+	}
+	sort.Slice(result.Buckets, func(i, j int) bool {
+		return result.Buckets[i].Name < result.Buckets[j].Name
+	})
+	h.sendXML(http.StatusOK, result)
+}
+
+func (h *requestHandler) getBucketLocation(bucket string) {
+	if err := h.checkBucket(bucket); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	h.sendXML(http.StatusOK, locationConstraint{
+		Xmlns:  s3Namespace,
+		Region: h.config.Region,
+	})
+}
+
+func (h *requestHandler) headBucket(bucket string) {
+	if err := h.checkBucket(bucket); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	h.sendStatus(http.StatusOK)
+}
+
+func (h *requestHandler) createBucket(bucket string) {
+	if err := h.checkBucket(bucket); err == nil {
+		h.sendError(newAPIError(errBucketAlreadyOwned, ""), errNoSuchBucket)
+		return
+	}
+	if err := h.conn.CreateDir(getBucketPath(bucket), true); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	h.w.Header().Set("Location", getBucketPath(bucket))
+	h.sendStatus(http.StatusOK)
+}
+
+func (h *requestHandler) deleteBucket(bucket string) {
+	if err := h.checkBucket(bucket); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	files, err := h.conn.ListDir(getBucketPath(bucket))
+	if err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	if len(files) > 0 {
+		h.sendError(newAPIError(errBucketNotEmpty, ""), errNoSuchBucket)
+		return
+	}
+	if err := h.conn.RemoveDir(getBucketPath(bucket)); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	h.sendStatus(http.StatusNoContent)
+}
+
+func (h *requestHandler) listObjects(bucket string, q url.Values) {
+	if err := h.checkBucket(bucket); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	isV2 := q.Get("list-type") == "2"
+	delimiter := q.Get("delimiter")
+	if delimiter != "" && delimiter != "/" {
+		h.sendError(newAPIError(errNotImplemented, "only the \"/\" delimiter is supported"), errNoSuchBucket)
+		return
+	}
+	encodingType := q.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		h.sendError(newAPIError(errInvalidArgument, "invalid encoding type"), errNoSuchBucket)
+		return
+	}
+	maxKeys := maxKeysLimit
+	if q.Has("max-keys") {
+		val, err := strconv.Atoi(q.Get("max-keys"))
+		if err != nil || val < 0 {
+			h.sendError(newAPIError(errInvalidArgument, "invalid max-keys"), errNoSuchBucket)
+			return
+		}
+		maxKeys = min(val, maxKeysLimit)
+	}
+	result := listBucketResult{
+		Xmlns:        s3Namespace,
+		Name:         bucket,
+		Prefix:       q.Get("prefix"),
+		MaxKeys:      maxKeys,
+		Delimiter:    delimiter,
+		EncodingType: encodingType,
+		Contents:     []objectInfo{},
+	}
+	var marker string
+	if isV2 {
+		result.StartAfter = q.Get("start-after")
+		marker = result.StartAfter
+		if token := q.Get("continuation-token"); token != "" {
+			decoded, err := base64.RawURLEncoding.DecodeString(token)
+			if err != nil {
+				h.sendError(newAPIError(errInvalidArgument, "invalid continuation token"), errNoSuchBucket)
+				return
+			}
+			result.ContinuationToken = token
+			marker = string(decoded)
+		}
+	} else {
+		marker = q.Get("marker")
+		result.Marker = &marker
+	}
+	lister := objectLister{
+		conn:      h.conn,
+		bucket:    bucket,
+		marker:    marker,
+		maxKeys:   maxKeys,
+		delimiter: delimiter,
+	}
+	if err := lister.list(result.Prefix); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	result.IsTruncated = lister.isTruncated
+	for _, entry := range lister.entries {
+		if entry.isPrefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encodeKey(entry.key, encodingType)})
+			continue
+		}
+		result.Contents = append(result.Contents, objectInfo{
+			Key:          encodeKey(entry.key, encodingType),
+			LastModified: entry.info.ModTime().UTC().Format(s3TimeFormat),
+			ETag:         getETag(entry.info),
+			Size:         entry.info.Size(),
+			StorageClass: "STANDARD",
+		})
+	}
+	if lister.isTruncated && len(lister.entries) > 0 {
+		lastKey := lister.entries[len(lister.entries)-1].key
+		if isV2 {
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(lastKey))
+		} else if delimiter != "" {
+			result.NextMarker = encodeKey(lastKey, encodingType)
+		}
+	}
+	if isV2 {
+		keyCount := len(lister.entries)
+		result.KeyCount = &keyCount
+		result.StartAfter = encodeKey(result.StartAfter, encodingType)
+	} else {
+		encodedMarker := encodeKey(marker, encodingType)
+		result.Marker = &encodedMarker
+	}
+	result.Prefix = encodeKey(result.Prefix, encodingType)
+	h.sendXML(http.StatusOK, result)
+}
+
+func (h *requestHandler) getObject(bucket, key string, isHead bool) {
+	if err := h.checkBucket(bucket); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	name := getObjectPath(bucket, key)
+	info, err := h.conn.stat(name)
+	if err != nil {
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	if info.IsDir() != strings.HasSuffix(key, "/") {
+		h.sendError(newAPIError(errNoSuchKey, ""), errNoSuchKey)
+		return
+	}
+	h.setObjectHeaders(key, info)
+	if info.IsDir() {
+		// folder marker
+		h.w.Header().Set("Content-Length", "0")
+		h.w.Header().Set("ETag", emptyObjectETag)
+		h.sendStatus(http.StatusOK)
+		return
+	}
+	size := info.Size()
+	offset := int64(0)
+	statusCode := http.StatusOK
+	if rangeHeader := h.r.Header.Get("Range"); rangeHeader != "" {
+		start, end, err := parseRange(rangeHeader, size)
+		if err != nil {
+			h.w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			h.sendError(err, errNoSuchKey)
+			return
+		}
+		h.w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		offset = start
+		size = end - start + 1
+		statusCode = http.StatusPartialContent
+	}
+	h.w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	if isHead {
+		h.sendStatus(statusCode)
+		return
+	}
+	h.disableDeadlines()
+	reader, err := h.conn.getFileReader(name, offset, false)
+	if err != nil {
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	defer reader.Close()
+
+	h.sendStatus(statusCode)
+	if _, err := io.CopyN(h.w, reader, size); err != nil {
+		h.err = err
+		h.conn.Log(logger.LevelDebug, "error reading object %q: %v", name, err)
+	}
+}
+
+func (h *requestHandler) setObjectHeaders(key string, info os.FileInfo) {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = defaultObjectMime
+	}
+	h.w.Header().Set("Content-Type", contentType)
+	h.w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+	h.w.Header().Set("ETag", getETag(info))
+	h.w.Header().Set("Accept-Ranges", "bytes")
+	q := h.r.URL.Query()
+	for param, header := range responseHeaderOverrides {
+		if val := q.Get(param); val != "" {
+			h.w.Header().Set(header, val)
+		}
+	}
+}
+
+func (h *requestHandler) putObject(bucket, key string) {
+	if err := h.checkBucket(bucket); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	name := getObjectPath(bucket, key)
+	reader, size, err := getPayloadReader(h.r, h.signature)
+	if err != nil {
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		h.putFolderMarker(name, reader)
+		return
+	}
+	h.disableDeadlines()
+	writer, err := h.conn.getFileWriter(name)
+	if err != nil {
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	written, err := io.Copy(writer, reader)
+	if err == nil && size >= 0 && written != size {
+		err = newAPIError(errIncompleteBody, "")
+	}
+	if err != nil {
+		writer.TransferError(err)
+		writer.Close() //nolint:errcheck
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	if err := writer.Close(); err != nil {
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	h.sendObjectETag(name)
+}
+
+func (h *requestHandler) putFolderMarker(name string, reader io.Reader) {
+	n, err := io.Copy(io.Discard, io.LimitReader(reader, 1))
+	if err != nil {
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	if n > 0 {
+		h.sendError(newAPIError(errInvalidArgument, "objects with a trailing slash must be empty"), errNoSuchKey)
+		return
+	}
+	if _, err := h.conn.DoStat(name, 0, false); err != nil {
+		if !h.conn.IsNotExistError(err) {
+			h.sendError(err, errNoSuchKey)
+			return
+		}
+		if err := h.conn.checkParentDirs(name); err != nil {
+			h.sendError(err, errNoSuchKey)
+			return
+		}
+		if err := h.conn.CreateDir(name, true); err != nil {
+			h.sendError(err, errNoSuchKey)
+			return
+		}
+	}
+	h.w.Header().Set("ETag", emptyObjectETag)
+	h.sendStatus(http.StatusOK)
+}
+
+func (h *requestHandler) sendObjectETag(name string) {
+	info, err := h.conn.DoStat(name, 0, false)
+	if err == nil {
+		h.w.Header().Set("ETag", getETag(info))
+	}
+	h.sendStatus(http.StatusOK)
+}
+
+func (h *requestHandler) deleteObject(bucket, key string) {
+	if err := h.checkBucket(bucket); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	if err := h.doDeleteObject(bucket, key); err != nil {
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	h.sendStatus(http.StatusNoContent)
+}
+
+func (h *requestHandler) doDeleteObject(bucket, key string) error {
+	if !isValidKey(key) {
+		return newAPIError(errInvalidArgument, "invalid object key")
+	}
+	name := getObjectPath(bucket, key)
+	if strings.HasSuffix(key, "/") {
+		// folder markers are only removed if the directory is empty
+		files, err := h.conn.ListDir(name)
+		if err != nil {
+			if h.conn.IsNotExistError(err) {
+				return nil
+			}
+			return err
+		}
+		if len(files) > 0 {
+			return nil
+		}
+		return h.conn.RemoveDir(name)
+	}
+	return h.conn.removeObject(name)
+}
+
+func (h *requestHandler) deleteObjects(bucket string) {
+	if err := h.checkBucket(bucket); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	var req deleteRequest
+	if err := h.readXMLBody(&req); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	if len(req.Objects) == 0 || len(req.Objects) > maxDeleteObjects {
+		h.sendError(newAPIError(errMalformedXML, ""), errNoSuchBucket)
+		return
+	}
+	result := deleteResult{
+		Xmlns: s3Namespace,
+	}
+	for _, obj := range req.Objects {
+		if err := h.doDeleteObject(bucket, obj.Key); err != nil {
+			apiErr := getAPIError(err, errNoSuchKey)
+			result.Errors = append(result.Errors, deleteError{
+				Key:     obj.Key,
+				Code:    apiErr.code.code,
+				Message: apiErr.Error(),
+			})
+			continue
+		}
+		if !req.Quiet {
+			result.Deleted = append(result.Deleted, obj)
+		}
+	}
+	h.sendXML(http.StatusOK, result)
+}
+
+func (h *requestHandler) createMultipartUpload(bucket, key string) {
+	if err := h.checkBucket(bucket); err != nil {
+		h.sendError(err, errNoSuchBucket)
+		return
+	}
+	if strings.HasSuffix(key, "/") {
+		h.sendError(newAPIError(errInvalidArgument, "objects with a trailing slash must be empty"), errNoSuchKey)
+		return
+	}
+	name := getObjectPath(bucket, key)
+	if !h.conn.User.HasPerm(dataprovider.PermUpload, path.Dir(name)) {
+		h.sendError(h.conn.GetPermissionDeniedError(), errNoSuchKey)
+		return
+	}
+	if ok, _ := h.conn.User.IsFileAllowed(name); !ok {
+		h.sendError(h.conn.GetPermissionDeniedError(), errNoSuchKey)
+		return
+	}
+	diskQuota, _ := h.conn.HasSpace(true, false, name)
+	if !diskQuota.HasSpace {
+		h.sendError(newAPIError(errQuotaExceeded, ""), errNoSuchKey)
+		return
+	}
+	upload := multipartUploads.add(h.conn.User.Username, bucket, key)
+	h.conn.Log(logger.LevelDebug, "multipart upload %q started for %q", upload.id, name)
+	h.sendXML(http.StatusOK, initiateMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Bucket:   bucket,
+		Key:      key,
+		UploadID: upload.id,
+	})
+}
+
+func (h *requestHandler) uploadPart(bucket, key, uploadID, partNumber string) {
+	number, err := strconv.Atoi(partNumber)
+	if err != nil || number < 1 || number > maxPartNumber {
+		h.sendError(newAPIError(errInvalidArgument, "invalid part number"), errNoSuchUpload)
+		return
+	}
+	upload, err := multipartUploads.get(uploadID, h.conn.User.Username, bucket, key)
+	if err != nil {
+		h.sendError(err, errNoSuchUpload)
+		return
+	}
+	diskQuota, _ := h.conn.HasSpace(true, false, getObjectPath(bucket, key))
+	if !diskQuota.HasSpace {
+		h.sendError(newAPIError(errQuotaExceeded, ""), errNoSuchUpload)
+		return
+	}
+	reader, _, err := getPayloadReader(h.r, h.signature)
+	if err != nil {
+		h.sendError(err, errNoSuchUpload)
+		return
+	}
+	h.disableDeadlines()
+	h.conn.UpdateLastActivity()
+	etag, err := upload.addPart(number, reader)
+	if err != nil {
+		h.sendError(err, errNoSuchUpload)
+		return
+	}
+	h.w.Header().Set("ETag", etag)
+	h.sendStatus(http.StatusOK)
+}
+
+func (h *requestHandler) completeMultipartUpload(bucket, key, uploadID string) {
+	upload, err := multipartUploads.get(uploadID, h.conn.User.Username, bucket, key)
+	if err != nil {
+		h.sendError(err, errNoSuchUpload)
+		return
+	}
+	var req completeMultipartUpload
+	if err := h.readXMLBody(&req); err != nil {
+		h.sendError(err, errNoSuchUpload)
+		return
+	}
+	parts, err := upload.getParts(req.Parts)
+	if err != nil {
+		h.sendError(err, errNoSuchUpload)
+		return
+	}
+	h.disableDeadlines()
+	name := getObjectPath(bucket, key)
+	writer, err := h.conn.getFileWriter(name)
+	if err != nil {
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	for _, part := range parts {
+		if err := copyPart(writer, upload.getPartPath(part.number)); err != nil {
+			writer.TransferError(err)
+			writer.Close() //nolint:errcheck
+			h.sendError(err, errNoSuchKey)
+			return
+		}
+	}
+	if err := writer.Close(); err != nil {
+		h.sendError(err, errNoSuchKey)
+		return
+	}
+	multipartUploads.remove(upload.id)
+	h.conn.Log(logger.LevelDebug, "multipart upload %q completed for %q, parts: %d", upload.id, name, len(parts))
+
+	result := completeMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: getObjectPath(bucket, key),
+		Bucket:   bucket,
+		Key:      key,
+	}
+	if info, err := h.conn.DoStat(name, 0, false); err == nil {
+		result.ETag = getETag(info)
+	}
+	h.sendXML(http.StatusOK, result)
+}
+
+func (h *requestHandler) abortMultipartUpload(bucket, key, uploadID string) {
+	upload, err := multipartUploads.get(uploadID, h.conn.User.Username, bucket, key)
+	if err != nil {
+		h.sendError(err, errNoSuchUpload)
+		return
+	}
+	multipartUploads.remove(upload.id)
+	h.sendStatus(http.StatusNoContent)
+}
+
+func copyPart(w io.Writer, partPath string) error {
+	f, err := os.Open(partPath)
+	if err != nil {
+		return newAPIError(errInvalidPart, "")
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// splitBucketAndKey returns the bucket and the object key from a path style request path
+func splitBucketAndKey(p string) (string, string) {
+	p = strings.TrimPrefix(p, "/")
+	bucket, key, _ := strings.Cut(p, "/")
+	return bucket, key
+}
+
+func isValidPathSegment(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, "/\\\x00")
+}
+
+// isValidKey returns true if the specified key can be mapped to a filesystem path.
+// A trailing slash is allowed and denotes a folder marker
+func isValidKey(key string) bool {
+	if strings.Contains(key, "\x00") {
+		return false
+	}
+	for _, segment := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func getBucketPath(bucket string) string {
+	return "/" + bucket
+}
+
+func getObjectPath(bucket, key string) string {
+	return path.Join("/", bucket, key)
+}
+
+// getETag returns the entity tag for the specified file. Computing the MD5 digest
+// of the content would require reading the whole file, so the ETag is derived from
+// the modification time and the size. The "-1" suffix tells clients that it is not
+// a content digest, as for multipart uploads
+func getETag(info os.FileInfo) string {
+	h := md5.Sum([]byte(fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size())))
+	return fmt.Sprintf(`"%x-1"`, h)
+}
+
+func unquoteETag(etag string) string {
+	return strings.Trim(strings.TrimSpace(etag), `"`)
+}
+
+func encodeKey(key, encodingType string) string {
+	if encodingType == "url" {
+		return url.QueryEscape(key)
+	}
+	return key
+}
+
+// parseRange parses a single range header, multiple ranges are not supported
+// and the whole object is returned as S3 does
+func parseRange(header string, size int64) (int64, int64, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, size - 1, nil
+	}
+	rawStart, rawEnd, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, newAPIError(errInvalidRange, "")
+	}
+	var start, end int64
+	var err error
+	if rawStart == "" {
+		// suffix range
+		suffix, err := strconv.ParseInt(rawEnd, 10, 64)
+		if err != nil || suffix <= 0 || size == 0 {
+			return 0, 0, newAPIError(errInvalidRange, "")
+		}
+		start = max(size-suffix, 0)
+		return start, size - 1, nil
+	}
+	start, err = strconv.ParseInt(rawStart, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, newAPIError(errInvalidRange, "")
+	}
+	end = size - 1
+	if rawEnd != "" {
+		end, err = strconv.ParseInt(rawEnd, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, newAPIError(errInvalidRange, "")
+		}
+		end = min(end, size-1)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,131 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package s3gw
+
+import (
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+type listEntry struct {
+	key      string
+	isPrefix bool
+	info     os.FileInfo
+}
+
+// objectLister maps the user's directory tree to a flat, lexicographically
+// sorted, key space. Directories are walked in key order so listing can
+// stop as soon as enough keys are found
+type objectLister struct {
+	conn        *Connection
+	bucket      string
+	marker      string
+	maxKeys     int
+	delimiter   string
+	entries     []listEntry
+	isTruncated bool
+}
+
+func (l *objectLister) list(prefix string) error {
+	if l.maxKeys == 0 {
+		return nil
+	}
+	dirKey := ""
+	if idx := strings.LastIndex(prefix, "/"); idx >= 0 {
+		dirKey = prefix[:idx+1]
+		if !isValidKey(dirKey) {
+			return nil
+		}
+	}
+	_, err := l.visit(dirKey, prefix, true)
+	if err != nil && l.conn.IsNotExistError(err) {
+		return nil
+	}
+	return err
+}
+
+// visit lists the directory mapped to dirKey and returns true if
+// enough entries have been found
+func (l *objectLister) visit(dirKey, prefix string, isRoot bool) (bool, error) {
+	files, err := l.conn.ListDir(getObjectPath(l.bucket, dirKey))
+	if err != nil {
+		if isRoot {
+			return false, err
+		}
+		l.conn.Log(logger.LevelDebug, "unable to list dir %q, skipping: %v", dirKey, err)
+		return false, nil
+	}
+	children := make([]listEntry, 0, len(files))
+	for _, info := range files {
+		key := dirKey + info.Name()
+		if info.IsDir() {
+			key += "/"
+		}
+		children = append(children, listEntry{
+			key:  key,
+			info: info,
+		})
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].key < children[j].key
+	})
+
+	for _, child := range children {
+		if child.info.IsDir() {
+			if !strings.HasPrefix(child.key, prefix) && !strings.HasPrefix(prefix, child.key) {
+				continue
+			}
+			if l.marker >= child.key && !strings.HasPrefix(l.marker, child.key) {
+				// the whole subtree is before the marker
+				continue
+			}
+			if l.delimiter != "" {
+				if !strings.HasPrefix(child.key, prefix) || child.key <= l.marker {
+					continue
+				}
+				child.isPrefix = true
+				if l.addEntry(child) {
+					return true, nil
+				}
+				continue
+			}
+			done, err := l.visit(child.key, prefix, false)
+			if err != nil || done {
+				return done, err
+			}
+			continue
+		}
+		if !strings.HasPrefix(child.key, prefix) || child.key <= l.marker {
+			continue
+		}
+		if l.addEntry(child) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// addEntry adds the specified entry and returns true if the listing is complete
+func (l *objectLister) addEntry(entry listEntry) bool {
+	if len(l.entries) == l.maxKeys {
+		l.isTruncated = true
+		return true
+	}
+	l.entries = append(l.entries, entry)
+	return false
+}
@@ -0,0 +1,215 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package s3gw
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	maxPartNumber                    = 10000
+	maxPartSize                      = 5 * 1024 * 1024 * 1024
+	defaultMultipartUploadExpiration = 24 * time.Hour
+)
+
+var (
+	multipartUploads = multipartUploadsManager{
+		uploads: make(map[string]*multipartUpload),
+	}
+)
+
+type uploadedPart struct {
+	number int
+	size   int64
+	etag   string
+}
+
+// multipartUpload defines an in progress multipart upload. The uploaded parts
+// are stored inside a local temporary directory until the upload is completed
+type multipartUpload struct {
+	id        string
+	username  string
+	bucket    string
+	key       string
+	createdAt time.Time
+	mu        sync.Mutex
+	parts     map[int]uploadedPart
+}
+
+func (u *multipartUpload) getDir() string {
+	return filepath.Join(multipartUploads.baseDir, u.id)
+}
+
+func (u *multipartUpload) getPartPath(number int) string {
+	return filepath.Join(u.getDir(), strconv.Itoa(number))
+}
+
+// addPart stores the part read from the specified reader and returns its ETag
+func (u *multipartUpload) addPart(number int, r io.Reader) (string, error) {
+	if err := os.MkdirAll(u.getDir(), 0700); err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp(u.getDir(), "part")
+	if err != nil {
+		return "", err
+	}
+	h := md5.New()
+	size, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(r, maxPartSize+1))
+	errClose := f.Close()
+	if err == nil {
+		err = errClose
+	}
+	if err == nil && size > maxPartSize {
+		err = newAPIError(errEntityTooLarge, "")
+	}
+	if err != nil {
+		os.Remove(f.Name()) //nolint:errcheck
+		return "", err
+	}
+	etag := fmt.Sprintf("%q", hex.EncodeToString(h.Sum(nil)))
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if err := os.Rename(f.Name(), u.getPartPath(number)); err != nil {
+		os.Remove(f.Name()) //nolint:errcheck
+		return "", err
+	}
+	u.parts[number] = uploadedPart{
+		number: number,
+		size:   size,
+		etag:   etag,
+	}
+	return etag, nil
+}
+
+// getParts returns the uploaded parts matching the requested ones
+func (u *multipartUpload) getParts(requested []completedPart) ([]uploadedPart, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(requested) == 0 {
+		return nil, newAPIError(errMalformedXML, "no parts specified")
+	}
+	result := make([]uploadedPart, 0, len(requested))
+	for idx, p := range requested {
+		if idx > 0 && p.PartNumber <= requested[idx-1].PartNumber {
+			return nil, newAPIError(errInvalidPartOrder, "")
+		}
+		part, ok := u.parts[p.PartNumber]
+		if !ok || unquoteETag(part.etag) != unquoteETag(p.ETag) {
+			return nil, newAPIError(errInvalidPart, "")
+		}
+		result = append(result, part)
+	}
+	return result, nil
+}
+
+func (u *multipartUpload) cleanup() {
+	if err := os.RemoveAll(u.getDir()); err != nil {
+		logger.Warn(logSender, "", "unable to remove multipart upload dir %q: %v", u.getDir(), err)
+	}
+}
+
+type multipartUploadsManager struct {
+	baseDir    string
+	expiration time.Duration
+	mu         sync.RWMutex
+	uploads    map[string]*multipartUpload
+}
+
+func (m *multipartUploadsManager) initialize(expirationHours int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expiration = defaultMultipartUploadExpiration
+	if expirationHours > 0 {
+		m.expiration = time.Duration(expirationHours) * time.Hour
+	}
+	m.baseDir = filepath.Join(getTempPath(), "s3gw-multipart")
+	// the upload state is not persisted, parts from a previous run cannot be completed
+	if err := os.RemoveAll(m.baseDir); err != nil {
+		logger.Warn(logSender, "", "unable to remove multipart uploads dir %q: %v", m.baseDir, err)
+	}
+	m.uploads = make(map[string]*multipartUpload)
+}
+
+func (m *multipartUploadsManager) add(username, bucket, key string) *multipartUpload {
+	m.removeExpired()
+
+	upload := &multipartUpload{
+		id:        util.GenerateUniqueID(),
+		username:  username,
+		bucket:    bucket,
+		key:       key,
+		createdAt: time.Now(),
+		parts:     make(map[int]uploadedPart),
+	}
+	m.mu.Lock()
+	m.uploads[upload.id] = upload
+	m.mu.Unlock()
+
+	return upload
+}
+
+func (m *multipartUploadsManager) get(id, username, bucket, key string) (*multipartUpload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	upload, ok := m.uploads[id]
+	if !ok || upload.username != username || upload.bucket != bucket || upload.key != key {
+		return nil, newAPIError(errNoSuchUpload, "")
+	}
+	return upload, nil
+}
+
+func (m *multipartUploadsManager) remove(id string) {
+	m.mu.Lock()
+	upload, ok := m.uploads[id]
+	delete(m.uploads, id)
+	m.mu.Unlock()
+
+	if ok {
+		upload.cleanup()
+	}
+}
+
+func (m *multipartUploadsManager) removeExpired() {
+	var expired []string
+
+	m.mu.RLock()
+	for id, upload := range m.uploads {
+		if time.Since(upload.createdAt) > m.expiration {
+			expired = append(expired, id)
+		}
+	}
+	m.mu.RUnlock()
+
+	for _, id := range expired {
+		logger.Debug(logSender, "", "removing expired multipart upload %q", id)
+		m.remove(id)
+	}
+}
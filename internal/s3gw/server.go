@@ -0,0 +1,324 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package s3gw
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/rs/xid"
+	"github.com/sftpgo/sdk/plugin/notifier"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/metric"
+	"github.com/drakkan/sftpgo/v2/internal/plugin"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+type ctxReqParams int
+
+const (
+	requestIDKey ctxReqParams = iota
+	requestStartKey
+)
+
+type s3Server struct {
+	config  *Configuration
+	binding Binding
+}
+
+func (s *s3Server) listenAndServe() error {
+	httpServer := &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+		ReadTimeout:       60 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		MaxHeaderBytes:    1 << 16, // 64KB
+		ErrorLog:          log.New(&logger.StdLoggerWrapper{Sender: logSender}, "", 0),
+	}
+	if certMgr != nil && s.binding.EnableHTTPS {
+		serviceStatus.Bindings = append(serviceStatus.Bindings, s.binding)
+		certID := common.DefaultTLSKeyPaidID
+		if getConfigPath(s.binding.CertificateFile, "") != "" && getConfigPath(s.binding.CertificateKeyFile, "") != "" {
+			certID = s.binding.GetAddress()
+		}
+		httpServer.TLSConfig = &tls.Config{
+			GetCertificate: certMgr.GetCertificateFunc(certID),
+			MinVersion:     util.GetTLSVersion(s.binding.MinTLSVersion),
+			NextProtos:     util.GetALPNProtocols(nil),
+			CipherSuites:   util.GetTLSCiphersFromNames(s.binding.TLSCipherSuites),
+		}
+		logger.Debug(logSender, "", "configured TLS cipher suites for binding %q: %v, certID: %v",
+			s.binding.GetAddress(), httpServer.TLSConfig.CipherSuites, certID)
+		return util.HTTPListenAndServe(httpServer, s.binding.Address, s.binding.Port, true, logSender)
+	}
+	s.binding.EnableHTTPS = false
+	serviceStatus.Bindings = append(serviceStatus.Bindings, s.binding)
+	return util.HTTPListenAndServe(httpServer, s.binding.Address, s.binding.Port, false, logSender)
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *s3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error(logSender, "", "panic in ServeHTTP: %q stack trace: %v", r, string(debug.Stack()))
+			http.Error(w, common.ErrGenericFailure.Error(), http.StatusInternalServerError)
+		}
+	}()
+
+	requestID := xid.New().String()
+	ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+	ctx = context.WithValue(ctx, requestStartKey, time.Now())
+	r = r.WithContext(ctx)
+	w.Header().Set("x-amz-request-id", requestID)
+	w.Header().Set("Server", "SFTPGo")
+
+	ipAddr := s.checkRemoteAddress(r)
+
+	common.Connections.AddClientConnection(ipAddr)
+	defer common.Connections.RemoveClientConnection(ipAddr)
+
+	if err := common.Connections.IsNewConnectionAllowed(ipAddr, common.ProtocolS3); err != nil {
+		logger.Log(logger.LevelDebug, common.ProtocolS3, "", "connection not allowed from ip %q: %v", ipAddr, err)
+		writeError(w, r, newAPIError(errSlowDown, err.Error()))
+		return
+	}
+	if common.IsBanned(ipAddr, common.ProtocolS3) {
+		writeError(w, r, newAPIError(errAccessDenied, common.ErrConnectionDenied.Error()))
+		return
+	}
+	delay, err := common.LimitRate(common.ProtocolS3, ipAddr)
+	if err != nil {
+		delay += 499999999 * time.Nanosecond
+		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", delay.Seconds()))
+		writeError(w, r, newAPIError(errSlowDown, ""))
+		return
+	}
+	if err := common.Config.ExecutePostConnectHook(ipAddr, common.ProtocolS3); err != nil {
+		writeError(w, r, newAPIError(errAccessDenied, common.ErrConnectionDenied.Error()))
+		return
+	}
+	user, signature, err := s.authenticate(r, ipAddr)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	connectionID, err := s.validateUser(&user, r)
+	if err != nil {
+		updateLoginMetrics(&user, ipAddr, err)
+		writeError(w, r, newAPIError(errAccessDenied, err.Error()))
+		return
+	}
+	if err := user.CheckFsRoot(connectionID); err != nil {
+		errClose := user.CloseFs()
+		logger.Warn(logSender, connectionID, "unable to check fs root: %v close fs error: %v", err, errClose)
+		updateLoginMetrics(&user, ipAddr, common.ErrInternalFailure)
+		writeError(w, r, newAPIError(errInternalError, ""))
+		return
+	}
+	connection := &Connection{
+		BaseConnection: common.NewBaseConnection(connectionID, common.ProtocolS3, util.GetHTTPLocalAddress(r),
+			r.RemoteAddr, user),
+		request: r,
+	}
+	if err = common.Connections.Add(connection); err != nil {
+		errClose := user.CloseFs()
+		logger.Warn(logSender, connectionID, "unable add connection: %v close fs error: %v", err, errClose)
+		updateLoginMetrics(&user, ipAddr, err)
+		writeError(w, r, newAPIError(errSlowDown, err.Error()))
+		return
+	}
+	defer common.Connections.Remove(connection.GetID())
+
+	updateLoginMetrics(&user, ipAddr, nil)
+	dataprovider.UpdateLastLogin(&user)
+
+	handler := requestHandler{
+		config:     s.config,
+		conn:       connection,
+		signature:  signature,
+		w:          w,
+		r:          r,
+		statusCode: http.StatusOK,
+	}
+	handler.serve()
+	writeLog(r, handler.statusCode, handler.err)
+}
+
+func (s *s3Server) authenticate(r *http.Request, ip string) (dataprovider.User, *signatureInfo, error) {
+	var user dataprovider.User
+
+	signature, err := parseSignature(r, s.config.Region)
+	if err != nil {
+		if errors.Is(err, errNoSignature) {
+			return user, nil, newAPIError(errAccessDenied, "anonymous access is not allowed")
+		}
+		return user, nil, err
+	}
+	user, secret, err := dataprovider.GetUserForS3Auth(signature.scope.accessKey, ip, common.ProtocolS3)
+	if err != nil {
+		user.Username = signature.scope.accessKey
+		updateLoginMetrics(&user, ip, err)
+		return user, nil, newAPIError(errInvalidAccessKeyID, "")
+	}
+	if err := signature.verify(r, secret); err != nil {
+		updateLoginMetrics(&user, ip, dataprovider.ErrInvalidCredentials)
+		return user, nil, err
+	}
+	return user, signature, nil
+}
+
+func (s *s3Server) validateUser(user *dataprovider.User, r *http.Request) (string, error) {
+	connID := xid.New().String()
+	connectionID := fmt.Sprintf("%v_%v", common.ProtocolS3, connID)
+
+	if !filepath.IsAbs(user.HomeDir) {
+		logger.Warn(logSender, connectionID, "user %q has an invalid home dir: %q. Home dir must be an absolute path, login not allowed",
+			user.Username, user.HomeDir)
+		return connID, fmt.Errorf("cannot login user with invalid home dir: %q", user.HomeDir)
+	}
+	user.ApplySourceAuthPolicy(r.RemoteAddr)
+	if util.Contains(user.Filters.DeniedProtocols, common.ProtocolS3) {
+		logger.Info(logSender, connectionID, "cannot login user %q, protocol S3 is not allowed", user.Username)
+		return connID, fmt.Errorf("protocol S3 is not allowed for user %q", user.Username)
+	}
+	if !user.IsLoginMethodAllowed(dataprovider.LoginMethodS3AccessKey, common.ProtocolS3) {
+		logger.Info(logSender, connectionID, "cannot login user %q, %v login method is not allowed",
+			user.Username, dataprovider.LoginMethodS3AccessKey)
+		return connID, fmt.Errorf("login method %v is not allowed for user %q", dataprovider.LoginMethodS3AccessKey,
+			user.Username)
+	}
+	if !user.IsLoginFromAddrAllowed(r.RemoteAddr) {
+		logger.Info(logSender, connectionID, "cannot login user %q, remote address is not allowed: %v",
+			user.Username, r.RemoteAddr)
+		return connID, fmt.Errorf("login for user %q is not allowed from this address: %v", user.Username, r.RemoteAddr)
+	}
+	return connID, nil
+}
+
+func (s *s3Server) checkRemoteAddress(r *http.Request) string {
+	ipAddr := util.GetIPFromRemoteAddress(r.RemoteAddr)
+	var ip net.IP
+	isUnixSocket := filepath.IsAbs(s.binding.Address)
+	if !isUnixSocket {
+		ip = net.ParseIP(ipAddr)
+	}
+	if isUnixSocket || ip != nil {
+		for _, allow := range s.binding.allowHeadersFrom {
+			if allow(ip) {
+				parsedIP := util.GetRealIP(r, s.binding.ClientIPProxyHeader, s.binding.ClientIPHeaderDepth)
+				if parsedIP != "" {
+					ipAddr = parsedIP
+					r.RemoteAddr = ipAddr
+				}
+				break
+			}
+		}
+	}
+	return ipAddr
+}
+
+func writeXML(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(statusCode)
+	w.Write([]byte(xml.Header)) //nolint:errcheck
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		logger.Debug(logSender, "", "unable to write XML response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	statusCode := renderError(w, r, err, errNoSuchKey)
+	writeLog(r, statusCode, err)
+}
+
+// renderError writes the S3 error response for the specified error and
+// returns the HTTP status code
+func renderError(w http.ResponseWriter, r *http.Request, err error, notFoundCode apiErrorCode) int {
+	apiErr := getAPIError(err, notFoundCode)
+	requestID, _ := r.Context().Value(requestIDKey).(string)
+	if r.Method == http.MethodHead {
+		w.WriteHeader(apiErr.code.statusCode)
+	} else {
+		writeXML(w, apiErr.code.statusCode, errorResponse{
+			Code:      apiErr.code.code,
+			Message:   apiErr.Error(),
+			Resource:  r.URL.Path,
+			RequestID: requestID,
+		})
+	}
+	return apiErr.code.statusCode
+}
+
+func writeLog(r *http.Request, status int, err error) {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	fields := map[string]any{
+		"remote_addr": r.RemoteAddr,
+		"proto":       r.Proto,
+		"method":      r.Method,
+		"user_agent":  r.UserAgent(),
+		"uri":         fmt.Sprintf("%s://%s%s", scheme, r.Host, r.URL.Path)}
+	if reqID, ok := r.Context().Value(requestIDKey).(string); ok {
+		fields["request_id"] = reqID
+	}
+	if reqStart, ok := r.Context().Value(requestStartKey).(time.Time); ok {
+		fields["elapsed_ms"] = time.Since(reqStart).Nanoseconds() / 1000000
+	}
+	if contentLength := r.Header.Get("Content-Length"); contentLength != "" {
+		fields["content_length"] = contentLength
+	}
+	if status != 0 {
+		fields["resp_status"] = status
+	}
+	logger.GetLogger().Info().
+		Timestamp().
+		Str("sender", logSender).
+		Fields(fields).
+		Err(err).
+		Send()
+}
+
+func updateLoginMetrics(user *dataprovider.User, ip string, err error) {
+	loginMethod := dataprovider.LoginMethodS3AccessKey
+	metric.AddLoginAttempt(loginMethod)
+	if err != nil && err != common.ErrInternalFailure {
+		logger.ConnectionFailedLog(user.Username, ip, loginMethod, common.ProtocolS3, err.Error())
+		event := common.HostEventLoginFailed
+		logEv := notifier.LogEventTypeLoginFailed
+		if errors.Is(err, util.ErrNotFound) {
+			event = common.HostEventUserNotFound
+			logEv = notifier.LogEventTypeLoginNoUser
+		}
+		common.AddDefenderEvent(ip, common.ProtocolS3, event)
+		plugin.Handler.NotifyLogEvent(logEv, common.ProtocolS3, user.Username, ip, "", err)
+	}
+	metric.AddLoginResult(loginMethod, err)
+	dataprovider.ExecutePostLoginHook(user, loginMethod, ip, common.ProtocolS3, err)
+}
@@ -0,0 +1,553 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package s3gw
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	signV4Algorithm          = "AWS4-HMAC-SHA256"
+	signV4ChunkAlgorithm     = "AWS4-HMAC-SHA256-PAYLOAD"
+	amzDateFormat            = "20060102T150405Z"
+	amzShortDateFormat       = "20060102"
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	emptySHA256              = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	maxClockSkew             = 15 * time.Minute
+	maxPresignedExpiration   = 7 * 24 * time.Hour
+	maxChunkSize             = 16 * 1024 * 1024
+	maxChunkHeaderSize       = 4096
+)
+
+var (
+	errNoSignature = errors.New("no signature")
+)
+
+// credentialScope defines the scope of a signing key
+type credentialScope struct {
+	accessKey string
+	date      string
+	region    string
+	service   string
+}
+
+func (s *credentialScope) String() string {
+	return strings.Join([]string{s.date, s.region, s.service, "aws4_request"}, "/")
+}
+
+func parseCredential(value string) (credentialScope, error) {
+	var scope credentialScope
+	// the scope fields are parsed from the right, anything before them is the access key
+	parts := strings.Split(value, "/")
+	if len(parts) < 5 || parts[len(parts)-1] != "aws4_request" {
+		return scope, newAPIError(errAuthorizationHeaderMalformed, "invalid credential scope")
+	}
+	scope.accessKey = strings.Join(parts[:len(parts)-4], "/")
+	scope.date = parts[len(parts)-4]
+	scope.region = parts[len(parts)-3]
+	scope.service = parts[len(parts)-2]
+	if scope.accessKey == "" {
+		return scope, newAPIError(errAuthorizationHeaderMalformed, "empty access key")
+	}
+	if scope.service != "s3" {
+		return scope, newAPIError(errAuthorizationHeaderMalformed, fmt.Sprintf("invalid service %q", scope.service))
+	}
+	if _, err := time.Parse(amzShortDateFormat, scope.date); err != nil {
+		return scope, newAPIError(errAuthorizationHeaderMalformed, fmt.Sprintf("invalid scope date %q", scope.date))
+	}
+	return scope, nil
+}
+
+// signatureInfo defines the signature details parsed from a request
+type signatureInfo struct {
+	scope         credentialScope
+	signedHeaders []string
+	signature     string
+	rawDate       string
+	date          time.Time
+	payloadHash   string
+	isPresigned   bool
+	expires       time.Duration
+	signingKey    []byte
+}
+
+func parseSignature(r *http.Request, region string) (*signatureInfo, error) {
+	var info *signatureInfo
+	var err error
+
+	if r.URL.Query().Has("X-Amz-Algorithm") {
+		info, err = parsePresignedSignature(r)
+	} else if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		info, err = parseAuthorizationHeader(r, authHeader)
+	} else {
+		return nil, errNoSignature
+	}
+	if err != nil {
+		return nil, err
+	}
+	if region != "" && info.scope.region != region {
+		return nil, newAPIError(errAuthorizationHeaderMalformed,
+			fmt.Sprintf("the region %q is wrong; expecting %q", info.scope.region, region))
+	}
+	if !strings.HasPrefix(info.rawDate, info.scope.date) {
+		return nil, newAPIError(errAuthorizationHeaderMalformed, "the credential scope date does not match the request date")
+	}
+	if !util.Contains(info.signedHeaders, "host") {
+		return nil, newAPIError(errAuthorizationHeaderMalformed, "the host header must be signed")
+	}
+	now := time.Now()
+	if info.isPresigned {
+		if info.date.After(now.Add(maxClockSkew)) {
+			return nil, newAPIError(errRequestTimeTooSkewed, "")
+		}
+		if now.After(info.date.Add(info.expires)) {
+			return nil, newAPIError(errAccessDenied, "Request has expired")
+		}
+	} else if now.Sub(info.date).Abs() > maxClockSkew {
+		return nil, newAPIError(errRequestTimeTooSkewed, "")
+	}
+	return info, nil
+}
+
+func parseAuthorizationHeader(r *http.Request, authHeader string) (*signatureInfo, error) {
+	value, ok := strings.CutPrefix(authHeader, signV4Algorithm+" ")
+	if !ok {
+		return nil, newAPIError(errAuthorizationHeaderMalformed, "unsupported signature algorithm")
+	}
+	info := &signatureInfo{}
+	var err error
+	for _, field := range strings.Split(value, ",") {
+		k, v, found := strings.Cut(strings.TrimSpace(field), "=")
+		if !found {
+			return nil, newAPIError(errAuthorizationHeaderMalformed, fmt.Sprintf("invalid field %q", field))
+		}
+		switch k {
+		case "Credential":
+			info.scope, err = parseCredential(v)
+			if err != nil {
+				return nil, err
+			}
+		case "SignedHeaders":
+			info.signedHeaders = strings.Split(v, ";")
+		case "Signature":
+			info.signature = v
+		}
+	}
+	if info.scope.accessKey == "" || len(info.signedHeaders) == 0 || info.signature == "" {
+		return nil, newAPIError(errAuthorizationHeaderMalformed, "missing authorization fields")
+	}
+	info.rawDate = r.Header.Get("X-Amz-Date")
+	if info.rawDate != "" {
+		info.date, err = time.Parse(amzDateFormat, info.rawDate)
+	} else {
+		info.date, err = http.ParseTime(r.Header.Get("Date"))
+		info.rawDate = info.date.UTC().Format(amzDateFormat)
+	}
+	if err != nil {
+		return nil, newAPIError(errAccessDenied, "missing or invalid request date")
+	}
+	info.payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	if info.payloadHash == "" {
+		return nil, newAPIError(errInvalidRequest, "missing required header x-amz-content-sha256")
+	}
+	return info, nil
+}
+
+func parsePresignedSignature(r *http.Request) (*signatureInfo, error) {
+	q := r.URL.Query()
+	if q.Get("X-Amz-Algorithm") != signV4Algorithm {
+		return nil, newAPIError(errAuthorizationHeaderMalformed, "unsupported signature algorithm")
+	}
+	info := &signatureInfo{
+		isPresigned: true,
+	}
+	var err error
+	info.scope, err = parseCredential(q.Get("X-Amz-Credential"))
+	if err != nil {
+		return nil, err
+	}
+	info.rawDate = q.Get("X-Amz-Date")
+	info.date, err = time.Parse(amzDateFormat, info.rawDate)
+	if err != nil {
+		return nil, newAPIError(errAuthorizationHeaderMalformed, "invalid X-Amz-Date")
+	}
+	expires, err := strconv.ParseInt(q.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires <= 0 {
+		return nil, newAPIError(errAuthorizationHeaderMalformed, "invalid X-Amz-Expires")
+	}
+	info.expires = time.Duration(expires) * time.Second
+	if info.expires > maxPresignedExpiration {
+		return nil, newAPIError(errAuthorizationHeaderMalformed, "X-Amz-Expires must be less than a week")
+	}
+	info.signedHeaders = strings.Split(q.Get("X-Amz-SignedHeaders"), ";")
+	info.signature = q.Get("X-Amz-Signature")
+	if info.signature == "" {
+		return nil, newAPIError(errAuthorizationHeaderMalformed, "missing X-Amz-Signature")
+	}
+	info.payloadHash = q.Get("X-Amz-Content-Sha256")
+	if info.payloadHash == "" {
+		info.payloadHash = unsignedPayload
+	}
+	return info, nil
+}
+
+// verify checks the request signature using the specified secret access key
+func (s *signatureInfo) verify(r *http.Request, secret string) error {
+	s.signingKey = getSigningKey(secret, &s.scope)
+	canonicalRequest := getCanonicalRequest(r, s.signedHeaders, s.payloadHash, s.isPresigned)
+	stringToSign := strings.Join([]string{
+		signV4Algorithm,
+		s.rawDate,
+		s.scope.String(),
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(s.signingKey, []byte(stringToSign)))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(s.signature)) != 1 {
+		return newAPIError(errSignatureDoesNotMatch, "")
+	}
+	return nil
+}
+
+func getSigningKey(secret string, scope *credentialScope) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), []byte(scope.date))
+	key = hmacSHA256(key, []byte(scope.region))
+	key = hmacSHA256(key, []byte(scope.service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+func getCanonicalRequest(r *http.Request, signedHeaders []string, payloadHash string, isPresigned bool) string {
+	canonicalURI := awsURIEncode(r.URL.Path, false)
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+	return strings.Join([]string{
+		r.Method,
+		canonicalURI,
+		getCanonicalQueryString(r, isPresigned),
+		getCanonicalHeaders(r, signedHeaders),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func getCanonicalQueryString(r *http.Request, isPresigned bool) string {
+	type queryParam struct {
+		key   string
+		value string
+	}
+	var params []queryParam
+	for k, values := range r.URL.Query() {
+		if isPresigned && k == "X-Amz-Signature" {
+			continue
+		}
+		for _, v := range values {
+			params = append(params, queryParam{key: awsURIEncode(k, true), value: awsURIEncode(v, true)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].key == params[j].key {
+			return params[i].value < params[j].value
+		}
+		return params[i].key < params[j].key
+	})
+	var sb strings.Builder
+	for idx, p := range params {
+		if idx > 0 {
+			sb.WriteString("&")
+		}
+		sb.WriteString(p.key)
+		sb.WriteString("=")
+		sb.WriteString(p.value)
+	}
+	return sb.String()
+}
+
+func getCanonicalHeaders(r *http.Request, signedHeaders []string) string {
+	var sb strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		switch name {
+		case "host":
+			values = []string{r.Host}
+		case "content-length":
+			values = r.Header.Values(name)
+			if len(values) == 0 && r.ContentLength >= 0 {
+				values = []string{strconv.FormatInt(r.ContentLength, 10)}
+			}
+		case "transfer-encoding":
+			values = r.Header.Values(name)
+			if len(values) == 0 {
+				values = r.TransferEncoding
+			}
+		default:
+			values = r.Header.Values(name)
+		}
+		trimmed := make([]string, 0, len(values))
+		for _, v := range values {
+			trimmed = append(trimmed, strings.Join(strings.Fields(v), " "))
+		}
+		sb.WriteString(name)
+		sb.WriteString(":")
+		sb.WriteString(strings.Join(trimmed, ","))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// awsURIEncode encodes the specified string as required by the signature V4
+func awsURIEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for idx := 0; idx < len(s); idx++ {
+		c := s[idx]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			sb.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&sb, "%%%02X", c)
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// getPayloadReader returns a reader for the request body based on the
+// declared payload hash. Signed chunks and payload digests are verified
+// while reading, a mismatch is returned as a read error
+func getPayloadReader(r *http.Request, info *signatureInfo) (io.Reader, int64, error) {
+	var reader io.Reader
+	size := r.ContentLength
+
+	switch info.payloadHash {
+	case unsignedPayload:
+		reader = r.Body
+	case streamingPayload, streamingPayloadTrailer, streamingUnsignedTrailer:
+		decodedLength, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil || decodedLength < 0 {
+			return nil, 0, newAPIError(errMissingContentLength, "")
+		}
+		size = decodedLength
+		cr := &chunkedReader{
+			r:          bufio.NewReader(r.Body),
+			hasTrailer: info.payloadHash != streamingPayload,
+		}
+		if info.payloadHash != streamingUnsignedTrailer {
+			cr.signingKey = info.signingKey
+			cr.scope = info.scope.String()
+			cr.rawDate = info.rawDate
+			cr.prevSignature = info.signature
+		}
+		reader = cr
+	default:
+		expected, err := hex.DecodeString(info.payloadHash)
+		if err != nil || len(expected) != sha256.Size {
+			return nil, 0, newAPIError(errInvalidArgument, "invalid x-amz-content-sha256 header")
+		}
+		reader = &digestReader{
+			r:           r.Body,
+			h:           sha256.New(),
+			expected:    expected,
+			errMismatch: newAPIError(errContentSHA256Mismatch, ""),
+		}
+	}
+	if contentMD5 := r.Header.Get("Content-Md5"); contentMD5 != "" {
+		expected, err := base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(expected) != md5.Size {
+			return nil, 0, newAPIError(errInvalidDigest, "")
+		}
+		reader = &digestReader{
+			r:           reader,
+			h:           md5.New(),
+			expected:    expected,
+			errMismatch: newAPIError(errBadDigest, ""),
+		}
+	}
+	return reader, size, nil
+}
+
+// digestReader computes the digest of the read data and returns an error
+// instead of io.EOF if it does not match the expected one
+type digestReader struct {
+	r           io.Reader
+	h           hash.Hash
+	expected    []byte
+	errMismatch error
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(r.h.Sum(nil), r.expected) {
+		return n, r.errMismatch
+	}
+	return n, err
+}
+
+// chunkedReader decodes a body sent using the aws-chunked content encoding.
+// If a signing key is set the chunk signatures are verified before returning
+// any data
+type chunkedReader struct {
+	r             *bufio.Reader
+	signingKey    []byte
+	scope         string
+	rawDate       string
+	prevSignature string
+	hasTrailer    bool
+	buf           []byte
+	offset        int
+	done          bool
+	err           error
+}
+
+func (r *chunkedReader) Read(p []byte) (int, error) {
+	for r.offset >= len(r.buf) {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.readChunk()
+	}
+	n := copy(p, r.buf[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+func (r *chunkedReader) readLine() (string, error) {
+	var line []byte
+	for {
+		data, isPrefix, err := r.r.ReadLine()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		line = append(line, data...)
+		if len(line) > maxChunkHeaderSize {
+			return "", newAPIError(errIncompleteBody, "chunk header too long")
+		}
+		if !isPrefix {
+			return string(line), nil
+		}
+	}
+}
+
+func (r *chunkedReader) readChunk() error {
+	header, err := r.readLine()
+	if err != nil {
+		return err
+	}
+	rawSize, extension, _ := strings.Cut(header, ";")
+	size, err := strconv.ParseInt(strings.TrimSpace(rawSize), 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return newAPIError(errIncompleteBody, fmt.Sprintf("invalid chunk size %q", rawSize))
+	}
+	if cap(r.buf) < int(size) {
+		r.buf = make([]byte, size)
+	}
+	r.buf = r.buf[:size]
+	r.offset = 0
+	if _, err := io.ReadFull(r.r, r.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if r.signingKey != nil {
+		signature, ok := strings.CutPrefix(extension, "chunk-signature=")
+		if !ok {
+			return newAPIError(errSignatureDoesNotMatch, "missing chunk signature")
+		}
+		if err := r.verifyChunk(signature); err != nil {
+			return err
+		}
+	}
+	if size == 0 {
+		r.done = true
+		if r.hasTrailer {
+			// trailing checksums are ignored, the data integrity is guaranteed
+			// by the chunk signatures or by TLS for unsigned payloads
+			for {
+				line, err := r.readLine()
+				if err != nil {
+					return err
+				}
+				if line == "" {
+					return nil
+				}
+			}
+		}
+	}
+	line, err := r.readLine()
+	if err != nil {
+		if r.done && errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		return err
+	}
+	if line != "" {
+		return newAPIError(errIncompleteBody, "invalid chunk terminator")
+	}
+	return nil
+}
+
+func (r *chunkedReader) verifyChunk(signature string) error {
+	stringToSign := strings.Join([]string{
+		signV4ChunkAlgorithm,
+		r.rawDate,
+		r.scope,
+		r.prevSignature,
+		emptySHA256,
+		hexSHA256(r.buf),
+	}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(r.signingKey, []byte(stringToSign)))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+		return newAPIError(errSignatureDoesNotMatch, "invalid chunk signature")
+	}
+	r.prevSignature = signature
+	return nil
+}
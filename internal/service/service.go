@@ -212,6 +212,7 @@ func (s *Service) startServices() {
 	ftpdConf := config.GetFTPDConfig()
 	httpdConf := config.GetHTTPDConfig()
 	webDavDConf := config.GetWebDAVDConfig()
+	s3gwConf := config.GetS3GWConfig()
 	telemetryConf := config.GetTelemetryConfig()
 
 	if sftpdConf.ShouldBind() {
@@ -270,6 +271,18 @@ func (s *Service) startServices() {
 	} else {
 		logger.Info(logSender, "", "WebDAV server not started, disabled in config file")
 	}
+	if s3gwConf.ShouldBind() {
+		go func() {
+			if err := s3gwConf.Initialize(s.ConfigDir); err != nil {
+				logger.Error(logSender, "", "could not start S3 gateway: %v", err)
+				logger.ErrorToConsole("could not start S3 gateway: %v", err)
+				s.Error = err
+			}
+			s.Shutdown <- true
+		}()
+	} else {
+		logger.Info(logSender, "", "S3 gateway not started, disabled in config file")
+	}
 	if telemetryConf.ShouldBind() {
 		go func() {
 			if err := telemetryConf.Initialize(s.ConfigDir); err != nil {
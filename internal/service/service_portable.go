@@ -61,6 +61,11 @@ func (s *Service) StartPortableMode(sftpdPort, ftpPort, webdavPort, httpPort int
 		httpdConf.Bindings[idx].Port = 0
 	}
 	config.SetHTTPDConfig(httpdConf)
+	s3gwConf := config.GetS3GWConfig()
+	for idx := range s3gwConf.Bindings {
+		s3gwConf.Bindings[idx].Port = 0
+	}
+	config.SetS3GWConfig(s3gwConf)
 	telemetryConf := config.GetTelemetryConfig()
 	telemetryConf.BindPort = 0
 	config.SetTelemetryConfig(telemetryConf)
@@ -31,6 +31,7 @@ import (
 	"github.com/drakkan/sftpgo/v2/internal/httpd"
 	"github.com/drakkan/sftpgo/v2/internal/logger"
 	"github.com/drakkan/sftpgo/v2/internal/plugin"
+	"github.com/drakkan/sftpgo/v2/internal/s3gw"
 	"github.com/drakkan/sftpgo/v2/internal/sftpd"
 	"github.com/drakkan/sftpgo/v2/internal/telemetry"
 	"github.com/drakkan/sftpgo/v2/internal/webdavd"
@@ -157,6 +158,10 @@ loop:
 			if err != nil {
 				logger.Warn(logSender, "", "error reloading WebDAV cert manager: %v", err)
 			}
+			err = s3gw.ReloadCertificateMgr()
+			if err != nil {
+				logger.Warn(logSender, "", "error reloading S3 gateway cert manager: %v", err)
+			}
 			err = telemetry.ReloadCertificateMgr()
 			if err != nil {
 				logger.Warn(logSender, "", "error reloading telemetry cert manager: %v", err)
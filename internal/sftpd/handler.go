@@ -101,6 +101,7 @@ func (c *Connection) Fileread(request *sftp.Request) (io.ReaderAt, error) {
 	file, r, cancelFn, err := fs.Open(p, 0)
 	if err != nil {
 		c.Log(logger.LevelError, "could not open file %q for reading: %+v", p, err)
+		c.TrackArchiveRestore(p, request.Filepath, err)
 		return nil, c.GetFsError(fs, err)
 	}
 
@@ -558,7 +559,7 @@ func (c *Connection) getStatVFSFromQuotaResult(fs vfs.Fs, name string, quotaResu
 func (c *Connection) updateQuotaAfterTruncate(requestPath string, fileSize int64) {
 	vfolder, err := c.User.GetVirtualFolderForPath(path.Dir(requestPath))
 	if err == nil {
-		dataprovider.UpdateVirtualFolderQuota(&vfolder.BaseVirtualFolder, 0, -fileSize, false) //nolint:errcheck
+		dataprovider.UpdateVirtualFolderQuota(&vfolder, 0, -fileSize, false) //nolint:errcheck
 		if vfolder.IsIncludedInUserQuota() {
 			dataprovider.UpdateUserQuota(&c.User, 0, -fileSize, false) //nolint:errcheck
 		}
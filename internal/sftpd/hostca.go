@@ -0,0 +1,399 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package sftpd
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	hostCACheckInterval = 1 * time.Hour
+	// allow for some clock skew between the server and the clients
+	hostCACertBackdate = 5 * time.Minute
+)
+
+var (
+	hostCA = &hostCertificateAuthority{
+		signers: make(map[string]*hostCACertSigner),
+	}
+)
+
+// HostCAConfig defines the configuration for the internal SSH host certificate authority.
+// If enabled, SFTPGo will sign its own host keys and will periodically renew the generated
+// host certificates. SSH clients can trust all the host keys signed by the CA adding a
+// "@cert-authority" line to their known_hosts file.
+type HostCAConfig struct {
+	// Path to the private key of the certificate authority. The path can be absolute or
+	// relative to the configuration directory. If the key does not exist a new Ed25519
+	// key will be generated. Leave empty to disable the internal certificate authority
+	Key string `json:"key" mapstructure:"key"`
+	// Validity, as number of days, for the generated host certificates
+	Validity int `json:"validity" mapstructure:"validity"`
+	// Number of days before the expiration to renew the host certificates.
+	// Must be lower than the validity
+	RenewBefore int `json:"renew_before" mapstructure:"renew_before"`
+	// Host names that the generated certificates are valid for.
+	// Empty means the certificates are valid for any host
+	Principals []string `json:"principals" mapstructure:"principals"`
+}
+
+func (c *HostCAConfig) isEnabled() bool {
+	return c.Key != ""
+}
+
+func (c *HostCAConfig) validate(configDir string) error {
+	if !util.IsFileInputValid(c.Key) {
+		return fmt.Errorf("invalid host CA key %q", c.Key)
+	}
+	if !filepath.IsAbs(c.Key) {
+		c.Key = filepath.Join(configDir, c.Key)
+	}
+	if c.Validity <= 0 {
+		return fmt.Errorf("invalid host CA certificates validity: %d", c.Validity)
+	}
+	if c.RenewBefore <= 0 || c.RenewBefore >= c.Validity {
+		return fmt.Errorf("invalid host CA renew before: %d, it must be greater than 0 and lower than the validity: %d",
+			c.RenewBefore, c.Validity)
+	}
+	var principals []string
+	for _, p := range c.Principals {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			principals = append(principals, p)
+		}
+	}
+	c.Principals = util.RemoveDuplicates(principals, false)
+	return nil
+}
+
+// HostCA defines the details of the internal SSH host certificate authority
+type HostCA struct {
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+	// KnownHosts is the line to add to the known_hosts file of the SSH clients to trust
+	// the host certificates signed by this certificate authority
+	KnownHosts   string              `json:"known_hosts"`
+	Certificates []HostCACertificate `json:"certificates"`
+}
+
+// HostCACertificate defines an host certificate issued by the internal certificate authority
+type HostCACertificate struct {
+	// Fingerprint of the signed host key
+	HostKeyFingerprint string   `json:"host_key_fingerprint"`
+	Fingerprint        string   `json:"fingerprint"`
+	KeyID              string   `json:"key_id"`
+	Serial             uint64   `json:"serial"`
+	Principals         []string `json:"principals,omitempty"`
+	ValidAfter         int64    `json:"valid_after"`
+	ValidBefore        int64    `json:"valid_before"`
+	// Certificate in OpenSSH authorized keys format
+	Certificate string `json:"certificate"`
+}
+
+// hostCACertSigner is a signer for a private host key that uses the certificate
+// issued by the internal CA as public key. The certificate can be replaced at runtime,
+// so renewed certificates are used for new connections without restarting the service
+type hostCACertSigner struct {
+	sync.RWMutex
+	hostKeyPath string
+	signer      ssh.MultiAlgorithmSigner
+	certSigner  ssh.MultiAlgorithmSigner
+	cert        *ssh.Certificate
+}
+
+func (s *hostCACertSigner) getCertSigner() ssh.MultiAlgorithmSigner {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.certSigner
+}
+
+func (s *hostCACertSigner) getCertificate() *ssh.Certificate {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.cert
+}
+
+func (s *hostCACertSigner) setCertificate(cert *ssh.Certificate) error {
+	signer, err := ssh.NewCertSigner(cert, s.signer)
+	if err != nil {
+		return err
+	}
+	certSigner, ok := signer.(ssh.MultiAlgorithmSigner)
+	if !ok {
+		return fmt.Errorf("unable to create a multi algorithm signer for host key %q", s.hostKeyPath)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.cert = cert
+	s.certSigner = certSigner
+	return nil
+}
+
+func (s *hostCACertSigner) PublicKey() ssh.PublicKey {
+	return s.getCertSigner().PublicKey()
+}
+
+func (s *hostCACertSigner) Sign(rand io.Reader, data []byte) (*ssh.Signature, error) {
+	return s.getCertSigner().Sign(rand, data)
+}
+
+func (s *hostCACertSigner) SignWithAlgorithm(rand io.Reader, data []byte, algorithm string) (*ssh.Signature, error) {
+	return s.getCertSigner().SignWithAlgorithm(rand, data, algorithm)
+}
+
+func (s *hostCACertSigner) Algorithms() []string {
+	return s.getCertSigner().Algorithms()
+}
+
+type hostCertificateAuthority struct {
+	sync.RWMutex
+	config  HostCAConfig
+	signer  ssh.Signer
+	signers map[string]*hostCACertSigner
+	ticker  *time.Ticker
+	done    chan bool
+}
+
+func (ca *hostCertificateAuthority) initialize(config HostCAConfig, configDir string) error {
+	ca.stopRenewals()
+
+	ca.Lock()
+	defer ca.Unlock()
+
+	ca.signer = nil
+	ca.signers = make(map[string]*hostCACertSigner)
+	if !config.isEnabled() {
+		logger.Debug(logSender, "", "internal host certificate authority disabled")
+		return nil
+	}
+	if err := config.validate(configDir); err != nil {
+		return err
+	}
+	if _, err := os.Stat(config.Key); errors.Is(err, fs.ErrNotExist) {
+		logger.Info(logSender, "", "host CA key %q does not exist, try to create a new one", config.Key)
+		logger.InfoToConsole("host CA key %q does not exist, try to create a new one", config.Key)
+		if err := util.GenerateEd25519Keys(config.Key); err != nil {
+			logger.Warn(logSender, "", "error creating host CA key %q: %v", config.Key, err)
+			logger.WarnToConsole("error creating host CA key %q: %v", config.Key, err)
+			return err
+		}
+	}
+	privateBytes, err := os.ReadFile(config.Key)
+	if err != nil {
+		return fmt.Errorf("unable to read host CA key %q: %w", config.Key, err)
+	}
+	signer, err := ssh.ParsePrivateKey(privateBytes)
+	if err != nil {
+		return fmt.Errorf("unable to parse host CA key %q: %w", config.Key, err)
+	}
+	ca.config = config
+	ca.signer = signer
+	logger.Info(logSender, "", "host certificate authority initialized, key %q, fingerprint %q, validity: %d days, "+
+		"renew before: %d days, principals: %+v", config.Key, ssh.FingerprintSHA256(signer.PublicKey()), config.Validity,
+		config.RenewBefore, config.Principals)
+	return nil
+}
+
+func (ca *hostCertificateAuthority) isEnabled() bool {
+	ca.RLock()
+	defer ca.RUnlock()
+
+	return ca.signer != nil
+}
+
+func (ca *hostCertificateAuthority) issueCertificate(hostKey ssh.PublicKey) (*ssh.Certificate, error) {
+	ca.RLock()
+	defer ca.RUnlock()
+
+	if ca.signer == nil {
+		return nil, errors.New("host certificate authority not initialized")
+	}
+	var serial [8]byte
+	if _, err := io.ReadFull(rand.Reader, serial[:]); err != nil {
+		return nil, fmt.Errorf("unable to generate the certificate serial: %w", err)
+	}
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             hostKey,
+		Serial:          binary.BigEndian.Uint64(serial[:]),
+		CertType:        ssh.HostCert,
+		KeyId:           fmt.Sprintf("SFTPGo host key %s", ssh.FingerprintSHA256(hostKey)),
+		ValidPrincipals: ca.config.Principals,
+		ValidAfter:      uint64(now.Add(-hostCACertBackdate).Unix()),
+		ValidBefore:     uint64(now.Add(time.Duration(ca.config.Validity) * 24 * time.Hour).Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca.signer); err != nil {
+		return nil, fmt.Errorf("unable to sign the host certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// getSigner returns a signer that uses a CA signed certificate for the specified host key
+func (ca *hostCertificateAuthority) getSigner(hostKeyPath string, signer ssh.MultiAlgorithmSigner) (*hostCACertSigner, error) {
+	cert, err := ca.issueCertificate(signer.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	s := &hostCACertSigner{
+		hostKeyPath: hostKeyPath,
+		signer:      signer,
+	}
+	if err := s.setCertificate(cert); err != nil {
+		return nil, err
+	}
+
+	ca.Lock()
+	defer ca.Unlock()
+
+	ca.signers[hostKeyPath] = s
+	return s, nil
+}
+
+func (ca *hostCertificateAuthority) getSigners() []*hostCACertSigner {
+	ca.RLock()
+	defer ca.RUnlock()
+
+	signers := make([]*hostCACertSigner, 0, len(ca.signers))
+	for _, s := range ca.signers {
+		signers = append(signers, s)
+	}
+	return signers
+}
+
+func (ca *hostCertificateAuthority) renewCertificates(force bool) error {
+	if !ca.isEnabled() {
+		return util.NewRecordNotFoundError("host certificate authority not enabled")
+	}
+	ca.RLock()
+	renewBefore := time.Duration(ca.config.RenewBefore) * 24 * time.Hour
+	ca.RUnlock()
+
+	var errs []error
+	for _, s := range ca.getSigners() {
+		if !force && time.Until(time.Unix(int64(s.getCertificate().ValidBefore), 0)) > renewBefore {
+			continue
+		}
+		cert, err := ca.issueCertificate(s.signer.PublicKey())
+		if err == nil {
+			err = s.setCertificate(cert)
+		}
+		if err != nil {
+			logger.Warn(logSender, "", "unable to renew the host certificate for host key %q: %v", s.hostKeyPath, err)
+			errs = append(errs, err)
+			continue
+		}
+		logger.Info(logSender, "", "host certificate renewed for host key %q, serial: %d, valid before: %s",
+			s.hostKeyPath, cert.Serial, time.Unix(int64(cert.ValidBefore), 0).UTC().Format(time.RFC3339))
+	}
+	return errors.Join(errs...)
+}
+
+func (ca *hostCertificateAuthority) startRenewals() {
+	if !ca.isEnabled() {
+		return
+	}
+
+	ca.Lock()
+	defer ca.Unlock()
+
+	ca.ticker = time.NewTicker(hostCACheckInterval)
+	ca.done = make(chan bool)
+	go func(ticker *time.Ticker, done chan bool) {
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ca.renewCertificates(false) //nolint:errcheck
+			}
+		}
+	}(ca.ticker, ca.done)
+}
+
+func (ca *hostCertificateAuthority) stopRenewals() {
+	ca.Lock()
+	defer ca.Unlock()
+
+	if ca.ticker != nil {
+		ca.ticker.Stop()
+		close(ca.done)
+		ca.ticker = nil
+	}
+}
+
+func (ca *hostCertificateAuthority) getDetails() (HostCA, error) {
+	if !ca.isEnabled() {
+		return HostCA{}, util.NewRecordNotFoundError("host certificate authority not enabled")
+	}
+	ca.RLock()
+	publicKey := ca.signer.PublicKey()
+	hosts := "*"
+	if len(ca.config.Principals) > 0 {
+		hosts = strings.Join(ca.config.Principals, ",")
+	}
+	ca.RUnlock()
+
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+	result := HostCA{
+		PublicKey:   authorizedKey,
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+		KnownHosts:  fmt.Sprintf("@cert-authority %s %s", hosts, authorizedKey),
+	}
+	for _, s := range ca.getSigners() {
+		cert := s.getCertificate()
+		result.Certificates = append(result.Certificates, HostCACertificate{
+			HostKeyFingerprint: ssh.FingerprintSHA256(cert.Key),
+			Fingerprint:        ssh.FingerprintSHA256(cert),
+			KeyID:              cert.KeyId,
+			Serial:             cert.Serial,
+			Principals:         cert.ValidPrincipals,
+			ValidAfter:         util.GetTimeAsMsSinceEpoch(time.Unix(int64(cert.ValidAfter), 0)),
+			ValidBefore:        util.GetTimeAsMsSinceEpoch(time.Unix(int64(cert.ValidBefore), 0)),
+			Certificate:        strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))),
+		})
+	}
+	return result, nil
+}
+
+// GetHostCA returns the details of the internal SSH host certificate authority
+// and the host certificates it issued
+func GetHostCA() (HostCA, error) {
+	return hostCA.getDetails()
+}
+
+// RenewHostCertificates issues new certificates for all the host keys signed by the
+// internal certificate authority. New connections will use the renewed certificates
+func RenewHostCertificates() error {
+	return hostCA.renewCertificates(true)
+}
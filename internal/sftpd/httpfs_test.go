@@ -16,6 +16,7 @@ package sftpd_test
 
 import (
 	"fmt"
+	"io"
 	"io/fs"
 	"math"
 	"net/http"
@@ -186,6 +187,72 @@ func TestBasicHTTPFsHandling(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHTTPFsRangeRequestsAndTusUploads(t *testing.T) {
+	usePubKey := false
+	u := getTestUserWithHTTPFs(usePubKey)
+	u.FsConfig.HTTPConfig.UseRangeRequests = true
+	u.FsConfig.HTTPConfig.TusUploads = true
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	conn, client, err := getSftpClient(user, usePubKey)
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		defer client.Close()
+
+		testFilePath := filepath.Join(homeBasePath, testFileName)
+		testFileSize := int64(65535)
+		err = createTestFile(testFilePath, testFileSize)
+		assert.NoError(t, err)
+		err = sftpUploadFile(testFilePath, testFileName, testFileSize, client)
+		assert.NoError(t, err)
+		info, err := client.Stat(testFileName)
+		if assert.NoError(t, err) {
+			assert.Equal(t, testFileSize, info.Size())
+		}
+		emptyFileName := "empty.txt"
+		f, err := client.Create(emptyFileName)
+		if assert.NoError(t, err) {
+			err = f.Close()
+			assert.NoError(t, err)
+		}
+		info, err = client.Stat(emptyFileName)
+		if assert.NoError(t, err) {
+			assert.Equal(t, int64(0), info.Size())
+		}
+		localDownloadPath := filepath.Join(homeBasePath, testDLFileName)
+		err = sftpDownloadFile(testFileName, localDownloadPath, testFileSize, client)
+		assert.NoError(t, err)
+		// read the file starting from an offset using a range request
+		httpFs, err := user.GetFilesystem("")
+		require.NoError(t, err)
+		offset := int64(1000)
+		_, r, cancelFn, err := httpFs.Open(testFileName, offset)
+		if assert.NoError(t, err) {
+			data, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Len(t, data, int(testFileSize-offset))
+			expected, err := os.ReadFile(testFilePath)
+			if assert.NoError(t, err) {
+				assert.Equal(t, expected[offset:], data)
+			}
+			err = r.Close()
+			assert.NoError(t, err)
+			cancelFn()
+		}
+		err = httpFs.Close()
+		assert.NoError(t, err)
+		err = os.Remove(testFilePath)
+		assert.NoError(t, err)
+		err = os.Remove(localDownloadPath)
+		assert.NoError(t, err)
+	}
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestHTTPFsVirtualFolder(t *testing.T) {
 	usePubKey := false
 	u := getTestUser(usePubKey)
@@ -16,6 +16,9 @@ package sftpd
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
@@ -24,6 +27,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
@@ -1982,6 +1986,237 @@ func TestLoadHostKeys(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestHostCA(t *testing.T) {
+	_, err := GetHostCA()
+	assert.ErrorIs(t, err, util.ErrNotFound)
+	err = RenewHostCertificates()
+	assert.ErrorIs(t, err, util.ErrNotFound)
+
+	keysDir := filepath.Join(os.TempDir(), "hostca")
+	err = os.MkdirAll(keysDir, os.ModePerm)
+	assert.NoError(t, err)
+	caKey := filepath.Join(keysDir, "ca_key")
+	config := HostCAConfig{
+		Key:         caKey,
+		Validity:    10,
+		RenewBefore: 10,
+	}
+	err = hostCA.initialize(config, configDir)
+	assert.Error(t, err)
+	config.RenewBefore = 0
+	err = hostCA.initialize(config, configDir)
+	assert.Error(t, err)
+	config.Validity = 0
+	err = hostCA.initialize(config, configDir)
+	assert.Error(t, err)
+	config.Key = "."
+	err = hostCA.initialize(config, configDir)
+	assert.Error(t, err)
+	config.Key = caKey
+	config.Validity = 10
+	config.RenewBefore = 2
+	config.Principals = []string{" localhost ", "", "127.0.0.1", "localhost"}
+	err = hostCA.initialize(config, configDir)
+	assert.NoError(t, err)
+	assert.FileExists(t, caKey)
+
+	c := Configuration{
+		HostKeys:          []string{filepath.Join(keysDir, defaultPrivateEd25519KeyName)},
+		HostKeyAlgorithms: preferredHostKeyAlgos,
+	}
+	serverConfig := &ssh.ServerConfig{}
+	err = c.checkAndLoadHostKeys(configDir, serverConfig)
+	assert.NoError(t, err)
+	status := GetStatus()
+	require.Len(t, status.HostKeys, 2)
+	assert.Equal(t, []string{ssh.CertAlgoED25519v01}, status.HostKeys[1].Algorithms)
+
+	ca, err := GetHostCA()
+	assert.NoError(t, err)
+	assert.Contains(t, ca.KnownHosts, "@cert-authority localhost,127.0.0.1 ssh-ed25519 ")
+	require.Len(t, ca.Certificates, 1)
+	assert.Equal(t, status.HostKeys[0].Fingerprint, ca.Certificates[0].HostKeyFingerprint)
+	assert.Equal(t, []string{"localhost", "127.0.0.1"}, ca.Certificates[0].Principals)
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(ca.Certificates[0].Certificate))
+	assert.NoError(t, err)
+	cert, ok := parsed.(*ssh.Certificate)
+	require.True(t, ok)
+	assert.Equal(t, uint32(ssh.HostCert), cert.CertType)
+	checker := ssh.CertChecker{
+		IsHostAuthority: func(auth ssh.PublicKey, _ string) bool {
+			return ssh.FingerprintSHA256(auth) == ca.Fingerprint
+		},
+	}
+	err = checker.CheckCert("localhost", cert)
+	assert.NoError(t, err)
+	err = checker.CheckCert("otherhost", cert)
+	assert.Error(t, err)
+	// not expiring soon, no renew
+	err = hostCA.renewCertificates(false)
+	assert.NoError(t, err)
+	ca1, err := GetHostCA()
+	assert.NoError(t, err)
+	require.Len(t, ca1.Certificates, 1)
+	assert.Equal(t, ca.Certificates[0].Serial, ca1.Certificates[0].Serial)
+	err = RenewHostCertificates()
+	assert.NoError(t, err)
+	ca1, err = GetHostCA()
+	assert.NoError(t, err)
+	require.Len(t, ca1.Certificates, 1)
+	assert.NotEqual(t, ca.Certificates[0].Serial, ca1.Certificates[0].Serial)
+
+	hostCA.startRenewals()
+	hostCA.stopRenewals()
+	err = hostCA.initialize(HostCAConfig{}, configDir)
+	assert.NoError(t, err)
+	assert.False(t, hostCA.isEnabled())
+
+	err = os.WriteFile(caKey, []byte("invalid key"), os.ModePerm)
+	assert.NoError(t, err)
+	config.Key = caKey
+	err = hostCA.initialize(config, configDir)
+	assert.Error(t, err)
+	assert.False(t, hostCA.isEnabled())
+
+	err = os.RemoveAll(keysDir)
+	assert.NoError(t, err)
+}
+
+type mockConnMetadata struct {
+	net.Conn
+}
+
+func (m *mockConnMetadata) User() string {
+	return ""
+}
+
+func (m *mockConnMetadata) SessionID() []byte {
+	return nil
+}
+
+func (m *mockConnMetadata) ClientVersion() []byte {
+	return nil
+}
+
+func (m *mockConnMetadata) ServerVersion() []byte {
+	return nil
+}
+
+func getKexInitPacket(nameLists [][]string) []byte {
+	payload := []byte{msgKexInit}
+	payload = append(payload, make([]byte, 16)...)
+	for _, names := range nameLists {
+		list := strings.Join(names, ",")
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(list)))
+		payload = append(payload, list...)
+	}
+	// first_kex_packet_follows and reserved
+	payload = append(payload, make([]byte, 5)...)
+	padding := 4
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(payload)+padding+1))
+	packet = append(packet, byte(padding))
+	packet = append(packet, payload...)
+	return append(packet, make([]byte, padding)...)
+}
+
+func TestNegotiatedAlgorithms(t *testing.T) {
+	clientKexInit := getKexInitPacket([][]string{
+		{"curve25519-sha256", "ecdh-sha2-nistp256", "ext-info-c"},
+		{ssh.KeyAlgoED25519},
+		{"aes128-ctr", "aes128-gcm@openssh.com"},
+		{"chacha20-poly1305@openssh.com", "aes128-ctr"},
+		{"hmac-sha2-256", "hmac-sha2-512"},
+		{"hmac-sha2-256", "hmac-sha2-512"},
+		{"none"}, {"none"}, nil, nil,
+	})
+	serverKexInit := getKexInitPacket([][]string{
+		{"ecdh-sha2-nistp256", "curve25519-sha256"},
+		{ssh.KeyAlgoED25519},
+		{"aes128-gcm@openssh.com", "aes128-ctr"},
+		{"aes128-gcm@openssh.com", "aes128-ctr", "chacha20-poly1305@openssh.com"},
+		{"hmac-sha2-512", "hmac-sha2-256"},
+		{"hmac-sha2-512", "hmac-sha2-256"},
+		{"none"}, {"none"}, nil, nil,
+	})
+	c1, c2 := net.Pipe()
+	defer c2.Close()
+	conn := newNegotiatedAlgorithmsConn(c1)
+	_, ok := negotiatedAlgosConns.Load(conn.getKey())
+	assert.True(t, ok)
+	_, err := conn.getNegotiatedAlgorithms()
+	assert.Error(t, err)
+	// send data in small chunks
+	clientData := append([]byte("SSH-2.0-client\r\n"), clientKexInit...)
+	for len(clientData) > 0 {
+		n := 7
+		if n > len(clientData) {
+			n = len(clientData)
+		}
+		conn.client.add(clientData[:n])
+		clientData = clientData[n:]
+	}
+	conn.server.add(append([]byte("SSH-2.0-server\r\n"), serverKexInit...))
+	conn.updateDone()
+	assert.True(t, conn.done.Load())
+	algos, err := conn.getNegotiatedAlgorithms()
+	assert.NoError(t, err)
+	assert.Equal(t, "curve25519-sha256", algos.kex)
+	assert.Equal(t, "aes128-ctr", algos.cipherCToS)
+	assert.Equal(t, "chacha20-poly1305@openssh.com", algos.cipherSToC)
+	assert.Equal(t, "hmac-sha2-256", algos.macCToS)
+	assert.Empty(t, algos.macSToC)
+
+	restrictions := dataprovider.SSHAlgorithms{}
+	assert.NoError(t, algos.check(&restrictions))
+	restrictions.KexAlgorithms = []string{"ecdh-sha2-nistp256"}
+	assert.Error(t, algos.check(&restrictions))
+	restrictions.KexAlgorithms = []string{"curve25519-sha256"}
+	restrictions.Ciphers = []string{"aes128-ctr"}
+	assert.Error(t, algos.check(&restrictions))
+	restrictions.Ciphers = []string{"aes128-ctr", "chacha20-poly1305@openssh.com"}
+	restrictions.MACs = []string{"hmac-sha2-512"}
+	assert.Error(t, algos.check(&restrictions))
+	restrictions.MACs = []string{"hmac-sha2-256"}
+	assert.NoError(t, algos.check(&restrictions))
+	assert.Error(t, (&negotiatedAlgorithms{}).check(&restrictions))
+
+	sniffer := kexInitSniffer{}
+	sniffer.add([]byte("SSH-2.0-client\r\n"))
+	sniffer.add([]byte{0xff, 0xff, 0xff, 0xff, 0})
+	assert.Error(t, sniffer.err)
+	sniffer = kexInitSniffer{}
+	sniffer.add([]byte("SSH-2.0-client\r\n"))
+	sniffer.add([]byte{0, 0, 0, 1, 2})
+	assert.Error(t, sniffer.err)
+	sniffer = kexInitSniffer{}
+	sniffer.add([]byte("SSH-2.0-client\r\n"))
+	sniffer.add([]byte{0, 0, 0, 2, 0, msgKexInit + 1})
+	assert.Error(t, sniffer.err)
+	sniffer = kexInitSniffer{}
+	sniffer.add(make([]byte, maxKexInitSniffSize+1))
+	assert.Error(t, sniffer.err)
+	sniffer.add([]byte("SSH-2.0-client\r\n"))
+	assert.Nil(t, sniffer.buf)
+	_, err = parseKexInit(append([]byte{msgKexInit}, make([]byte, 20)...))
+	assert.Error(t, err)
+	_, err = parseKexInit(append(append([]byte{msgKexInit}, make([]byte, 16)...), 0, 0, 0, 10))
+	assert.Error(t, err)
+
+	user := dataprovider.User{}
+	connMetadata := &mockConnMetadata{Conn: c1}
+	assert.NoError(t, checkNegotiatedAlgorithms(&user, connMetadata))
+	user.Filters.SSHAlgorithms.KexAlgorithms = []string{"curve25519-sha256"}
+	assert.NoError(t, checkNegotiatedAlgorithms(&user, connMetadata))
+	conn.client.err = errors.New("test error")
+	assert.Error(t, checkNegotiatedAlgorithms(&user, connMetadata))
+
+	err = conn.Close()
+	assert.NoError(t, err)
+	_, ok = negotiatedAlgosConns.Load(conn.getKey())
+	assert.False(t, ok)
+	assert.Error(t, checkNegotiatedAlgorithms(&user, connMetadata))
+}
+
 func TestCertCheckerInitErrors(t *testing.T) {
 	c := Configuration{}
 	c.TrustedUserCAKeys = []string{".", "missing file"}
@@ -1997,6 +2232,74 @@ func TestCertCheckerInitErrors(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestCheckUserCertificate(t *testing.T) {
+	c := Configuration{}
+	err := c.initializeCertChecker("")
+	require.NoError(t, err)
+	_, caPrivKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	caSigner, err := ssh.NewSignerFromKey(caPrivKey)
+	require.NoError(t, err)
+	userPubKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	sshPubKey, err := ssh.NewPublicKey(userPubKey)
+	require.NoError(t, err)
+	cert := &ssh.Certificate{
+		Key:             sshPubKey,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"alice@example.com"},
+		ValidBefore:     ssh.CertTimeInfinity,
+		Permissions: ssh.Permissions{
+			Extensions: map[string]string{
+				"permit-port-forwarding": "",
+			},
+		},
+	}
+	err = cert.SignCert(rand.Reader, caSigner)
+	require.NoError(t, err)
+
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: "alice",
+		},
+	}
+	err = c.checkUserCertificate(&user, cert)
+	assert.ErrorContains(t, err, "unrecognized authority")
+	user.Filters.SSHCertificates.TrustedCAKeys = []string{string(ssh.MarshalAuthorizedKey(caSigner.PublicKey()))}
+	err = c.checkUserCertificate(&user, cert)
+	assert.ErrorContains(t, err, "not allowed for user")
+	user.Filters.SSHCertificates.PrincipalMappings = []dataprovider.SSHCertificatePrincipalMapping{
+		{
+			Principal: `(\w+)@example\.com`,
+			Username:  "$1",
+		},
+	}
+	err = c.checkUserCertificate(&user, cert)
+	assert.NoError(t, err)
+	user.Username = "bob"
+	err = c.checkUserCertificate(&user, cert)
+	assert.ErrorContains(t, err, "not allowed for user")
+	user.Username = "alice"
+	user.Filters.SSHCertificates.DenyPortForwarding = true
+	err = c.checkUserCertificate(&user, cert)
+	assert.ErrorContains(t, err, "permit-port-forwarding")
+	user.Filters.SSHCertificates.DenyPortForwarding = false
+	user.Filters.SSHCertificates.RequireSourceAddress = true
+	err = c.checkUserCertificate(&user, cert)
+	assert.ErrorContains(t, err, "source-address")
+	// the CA is trusted globally and the principal is explicitly allowed
+	c.parsedUserCAKeys = append(c.parsedUserCAKeys, caSigner.PublicKey())
+	user.Username = "other"
+	user.Filters.SSHCertificates = dataprovider.SSHCertificateConfig{
+		AllowedPrincipals: []string{"alice@example.com"},
+	}
+	err = c.checkUserCertificate(&user, cert)
+	assert.NoError(t, err)
+	user.Filters.SSHCertificates.AllowedPrincipals = nil
+	err = c.checkUserCertificate(&user, cert)
+	assert.ErrorContains(t, err, "not allowed for user")
+}
+
 func TestSFTPSubSystem(t *testing.T) {
 	permissions := make(map[string][]string)
 	permissions["/"] = []string{dataprovider.PermAny}
@@ -2033,7 +2336,7 @@ func TestSFTPSubSystem(t *testing.T) {
 
 func TestRecoverer(t *testing.T) {
 	c := Configuration{}
-	c.AcceptInboundConnection(nil, nil)
+	c.AcceptInboundConnection(nil, nil, false)
 	connID := "connectionID"
 	connection := &Connection{
 		BaseConnection: common.NewBaseConnection(connID, common.ProtocolSFTP, "", "", dataprovider.User{}),
@@ -2060,14 +2363,14 @@ func TestListernerAcceptErrors(t *testing.T) {
 	errFake := errors.New("a fake error")
 	listener := newFakeListener(errFake)
 	c := Configuration{}
-	err := c.serve(listener, nil)
+	err := c.serve(listener, nil, false)
 	require.EqualError(t, err, errFake.Error())
 	err = listener.Close()
 	require.NoError(t, err)
 
 	errNetFake := &fakeNetError{error: errFake}
 	listener = newFakeListener(errNetFake)
-	err = c.serve(listener, nil)
+	err = c.serve(listener, nil, false)
 	require.EqualError(t, err, errFake.Error())
 	err = listener.Close()
 	require.NoError(t, err)
@@ -2262,3 +2565,117 @@ func TestAuthenticationErrors(t *testing.T) {
 	assert.ErrorIs(t, err, sftpAuthError)
 	assert.NotErrorIs(t, err, util.ErrNotFound)
 }
+
+func getLockRequestPacket(id uint32, request, p string, offset, length uint64, mask uint32) []byte {
+	payload := []byte{sshFxpExtended}
+	payload = binary.BigEndian.AppendUint32(payload, id)
+	payload = appendString(payload, request)
+	payload = appendString(payload, p)
+	payload = binary.BigEndian.AppendUint64(payload, offset)
+	payload = binary.BigEndian.AppendUint64(payload, length)
+	if request == sftpExtBlock {
+		payload = binary.BigEndian.AppendUint32(payload, mask)
+	}
+	packet := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	return append(packet, payload...)
+}
+
+func getStatusCode(t *testing.T, buf *bytes.Buffer, expectedID uint32) uint32 {
+	packet := make([]byte, 13)
+	_, err := io.ReadFull(buf, packet)
+	require.NoError(t, err)
+	assert.Equal(t, byte(sshFxpStatus), packet[4])
+	assert.Equal(t, expectedID, binary.BigEndian.Uint32(packet[5:]))
+	length := binary.BigEndian.Uint32(packet)
+	_, err = io.CopyN(io.Discard, buf, int64(length)-9)
+	require.NoError(t, err)
+	return binary.BigEndian.Uint32(packet[9:])
+}
+
+func TestLockingChannel(t *testing.T) {
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: "lock_user",
+			HomeDir:  os.TempDir(),
+			Permissions: map[string][]string{
+				"/":   {dataprovider.PermAny},
+				"/ro": {dataprovider.PermListItems},
+			},
+		},
+	}
+	initPacket := []byte{0, 0, 0, 5, 1, 0, 0, 0, 3}
+	statVFSPacket := []byte{0, 0, 0, 0, sshFxpExtended, 0, 0, 0, 7}
+	statVFSPacket = appendString(statVFSPacket, "statvfs@openssh.com")
+	statVFSPacket = appendString(statVFSPacket, "/")
+	binary.BigEndian.PutUint32(statVFSPacket, uint32(len(statVFSPacket)-4))
+
+	in1 := bytes.NewBuffer(nil)
+	out1 := bytes.NewBuffer(nil)
+	conn1 := &Connection{
+		BaseConnection: common.NewBaseConnection("id1", common.ProtocolSFTP, "", "", user),
+	}
+	ch1 := newLockingChannel(newSubsystemChannel(in1, out1), conn1, "")
+	in1.Write(initPacket)
+	in1.Write(getLockRequestPacket(1, sftpExtBlock, "file.txt", 0, 10, common.LockBlockRead|common.LockBlockWrite))
+	in1.Write(statVFSPacket)
+	in1.Write(getLockRequestPacket(2, sftpExtBlock, "/ro/file.txt", 0, 10, common.LockBlockWrite))
+	in1.Write(getLockRequestPacket(3, sftpExtUnblock, "/file.txt", 0, 5, 0))
+	// the non locking packets are passed through
+	data := make([]byte, len(initPacket)+len(statVFSPacket))
+	_, err := io.ReadFull(ch1, data[:4])
+	assert.NoError(t, err)
+	_, err = io.ReadFull(ch1, data[4:])
+	assert.NoError(t, err)
+	assert.Equal(t, append(initPacket, statVFSPacket...), data)
+	_, err = ch1.Read(data)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, uint32(sshFxOk), getStatusCode(t, out1, 1))
+	assert.Equal(t, uint32(sshFxPermissionDenied), getStatusCode(t, out1, 2))
+	assert.Equal(t, uint32(sshFxNoMatchingByteRangeLock), getStatusCode(t, out1, 3))
+	assert.Len(t, common.FileLocks.GetLocks(user.Username), 1)
+
+	in2 := bytes.NewBuffer(nil)
+	out2 := bytes.NewBuffer(nil)
+	conn2 := &Connection{
+		BaseConnection: common.NewBaseConnection("id2", common.ProtocolSFTP, "", "", user),
+	}
+	ch2 := newLockingChannel(newSubsystemChannel(in2, out2), conn2, "/prefix")
+	in2.Write(getLockRequestPacket(1, sftpExtBlock, "/prefix/file.txt", 5, 10, common.LockBlockWrite))
+	in2.Write(getLockRequestPacket(2, sftpExtBlock, "/prefix/file.txt", 10, 10, common.LockBlockWrite))
+	in2.Write(getLockRequestPacket(3, sftpExtBlock, "/file.txt", 10, 10, common.LockBlockWrite))
+	_, err = ch2.Read(data)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, uint32(sshFxByteRangeLockConflict), getStatusCode(t, out2, 1))
+	assert.Equal(t, uint32(sshFxOk), getStatusCode(t, out2, 2))
+	assert.Equal(t, uint32(sshFxPermissionDenied), getStatusCode(t, out2, 3))
+	assert.Len(t, common.FileLocks.GetLocks(user.Username), 2)
+	// the extensions are advertised in the version packet
+	versionPacket := []byte{0, 0, 0, 5, sshFxpVersion, 0, 0, 0, 3}
+	n, err := ch2.Write(versionPacket)
+	assert.NoError(t, err)
+	assert.Equal(t, len(versionPacket), n)
+	assert.Contains(t, out2.String(), sftpExtBlock)
+	assert.Contains(t, out2.String(), sftpExtUnblock)
+	assert.Equal(t, uint32(out2.Len()-4), binary.BigEndian.Uint32(out2.Bytes()))
+	// packets written in multiple chunks
+	out2.Reset()
+	n, err = ch2.Write(initPacket[:5])
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, uint32(4), ch2.writeRemaining)
+	n, err = ch2.Write(initPacket[5:])
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, uint32(0), ch2.writeRemaining)
+	assert.Equal(t, initPacket, out2.Bytes())
+
+	in1.Write(getLockRequestPacket(4, sftpExtUnblock, "/file.txt", 0, 10, 0))
+	_, err = ch1.Read(data)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.Equal(t, uint32(sshFxOk), getStatusCode(t, out1, 4))
+	err = ch2.Close()
+	assert.NoError(t, err)
+	assert.Len(t, common.FileLocks.GetLocks(user.Username), 0)
+	err = ch1.Close()
+	assert.NoError(t, err)
+}
@@ -0,0 +1,296 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package sftpd
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"path"
+	"sync"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	sftpExtBlock   = "block@sftpgo.com"
+	sftpExtUnblock = "unblock@sftpgo.com"
+)
+
+const (
+	sshFxpVersion  = 2
+	sshFxpStatus   = 101
+	sshFxpExtended = 200
+
+	sshFxOk                       = 0
+	sshFxPermissionDenied         = 3
+	sshFxFailure                  = 4
+	sshFxBadMessage               = 5
+	sshFxByteRangeLockConflict    = 25
+	sshFxNoMatchingByteRangeLock  = 31
+	maxLockingExtendedPacketSize  = 64 * 1024
+	lockingChannelPacketHeaderLen = 5
+)
+
+var (
+	errMalformedLockRequest = errors.New("malformed lock request")
+)
+
+// lockingChannel wraps the channel used by the SFTP request server and handles
+// the byte range locking extensions, the other packets are passed through unchanged.
+// The supported extensions are added to the SSH_FXP_VERSION packet sent by the server
+type lockingChannel struct {
+	io.ReadWriteCloser
+	connection *Connection
+	prefix     *prefixMiddleware
+	// pending bytes to return to the request server before reading new data
+	pending []byte
+	// remaining bytes of the current incoming packet to pass through
+	readRemaining uint32
+	// remaining bytes of the current outgoing packet. Writes are serialized
+	// by the request server, so no locking is required
+	writeRemaining uint32
+	// held while an outgoing packet is written, it prevents the lock responses
+	// from being interleaved with the packets sent by the request server
+	packetMu sync.Mutex
+}
+
+func newLockingChannel(channel io.ReadWriteCloser, connection *Connection, folderPrefix string) *lockingChannel {
+	c := &lockingChannel{
+		ReadWriteCloser: channel,
+		connection:      connection,
+	}
+	if folderPrefix != "" {
+		c.prefix = &prefixMiddleware{
+			prefix: folderPrefix,
+		}
+	}
+	return c
+}
+
+func (c *lockingChannel) Read(b []byte) (int, error) {
+	for {
+		if len(c.pending) > 0 {
+			n := copy(b, c.pending)
+			c.pending = c.pending[n:]
+			return n, nil
+		}
+		if c.readRemaining > 0 {
+			if uint32(len(b)) > c.readRemaining {
+				b = b[:c.readRemaining]
+			}
+			n, err := c.ReadWriteCloser.Read(b)
+			c.readRemaining -= uint32(n)
+			return n, err
+		}
+		header := make([]byte, lockingChannelPacketHeaderLen)
+		if _, err := io.ReadFull(c.ReadWriteCloser, header); err != nil {
+			return 0, err
+		}
+		length := binary.BigEndian.Uint32(header)
+		if length == 0 || header[4] != sshFxpExtended || length > maxLockingExtendedPacketSize {
+			c.pending = header
+			if length > 0 {
+				c.readRemaining = length - 1
+			}
+			continue
+		}
+		payload := make([]byte, length-1)
+		if _, err := io.ReadFull(c.ReadWriteCloser, payload); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		handled, err := c.handleExtendedPacket(payload)
+		if err != nil {
+			return 0, err
+		}
+		if !handled {
+			c.pending = append(header, payload...)
+		}
+	}
+}
+
+func (c *lockingChannel) Write(b []byte) (int, error) {
+	size := len(b)
+	if c.writeRemaining == 0 {
+		// the request server always writes the whole packet header at once
+		if len(b) < lockingChannelPacketHeaderLen {
+			return c.ReadWriteCloser.Write(b)
+		}
+		length := binary.BigEndian.Uint32(b)
+		if b[4] == sshFxpVersion && uint32(len(b)) == length+4 {
+			b = addLockingExtensions(b)
+		}
+		c.packetMu.Lock()
+		c.writeRemaining = binary.BigEndian.Uint32(b) + 4
+	}
+	n, err := c.ReadWriteCloser.Write(b)
+	if err != nil || uint32(n) >= c.writeRemaining {
+		c.writeRemaining = 0
+		c.packetMu.Unlock()
+	} else {
+		c.writeRemaining -= uint32(n)
+	}
+	if err != nil {
+		return n, err
+	}
+	return size, nil
+}
+
+func (c *lockingChannel) sendStatus(id, code uint32, message string) error {
+	b := make([]byte, 4, 4+1+4+4+4+len(message)+4)
+	b = append(b, sshFxpStatus)
+	b = binary.BigEndian.AppendUint32(b, id)
+	b = binary.BigEndian.AppendUint32(b, code)
+	b = appendString(b, message)
+	b = appendString(b, "")
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+
+	c.packetMu.Lock()
+	defer c.packetMu.Unlock()
+
+	_, err := c.ReadWriteCloser.Write(b)
+	return err
+}
+
+// handleExtendedPacket handles the byte range locking extensions and returns
+// false for any other extended request
+func (c *lockingChannel) handleExtendedPacket(payload []byte) (bool, error) {
+	if len(payload) < 4 {
+		return false, nil
+	}
+	id := binary.BigEndian.Uint32(payload)
+	request, data, err := readString(payload[4:])
+	if err != nil || (request != sftpExtBlock && request != sftpExtUnblock) {
+		return false, nil
+	}
+	c.connection.UpdateLastActivity()
+
+	code, message := c.handleLockRequest(request, data)
+	return true, c.sendStatus(id, code, message)
+}
+
+func (c *lockingChannel) handleLockRequest(request string, data []byte) (uint32, string) {
+	p, data, err := readString(data)
+	if err != nil {
+		return sshFxBadMessage, err.Error()
+	}
+	if len(data) < 16 {
+		return sshFxBadMessage, errMalformedLockRequest.Error()
+	}
+	offset := binary.BigEndian.Uint64(data)
+	length := binary.BigEndian.Uint64(data[8:])
+	data = data[16:]
+	virtualPath, ok := c.getVirtualPath(p)
+	if !ok {
+		return sshFxPermissionDenied, common.ErrPermissionDenied.Error()
+	}
+
+	if request == sftpExtUnblock {
+		err = common.FileLocks.Remove(c.connection.User.Username, virtualPath, c.connection.GetID(), offset, length)
+		if err != nil {
+			return sshFxNoMatchingByteRangeLock, err.Error()
+		}
+		c.connection.Log(logger.LevelDebug, "lock removed for path %q, offset %d, length %d", virtualPath, offset, length)
+		return sshFxOk, ""
+	}
+
+	if len(data) < 4 {
+		return sshFxBadMessage, errMalformedLockRequest.Error()
+	}
+	mask := binary.BigEndian.Uint32(data)
+	if !c.connection.User.HasAnyPerm([]string{dataprovider.PermDownload, dataprovider.PermUpload,
+		dataprovider.PermOverwrite}, path.Dir(virtualPath)) {
+		return sshFxPermissionDenied, common.ErrPermissionDenied.Error()
+	}
+	if ok, _ := c.connection.User.IsFileAllowed(virtualPath); !ok {
+		return sshFxPermissionDenied, common.ErrPermissionDenied.Error()
+	}
+	err = common.FileLocks.Add(c.connection.User.Username, virtualPath, common.FileLock{
+		Owner:    c.connection.GetID(),
+		Protocol: common.ProtocolSFTP,
+		Offset:   offset,
+		Length:   length,
+		Mask:     mask,
+		// as for SFTP v6, a lock that denies reads to others is exclusive
+		Exclusive: mask&common.LockBlockRead != 0,
+	})
+	if err != nil {
+		if errors.Is(err, common.ErrLockConflict) {
+			c.connection.Log(logger.LevelDebug, "unable to lock path %q, offset %d, length %d, mask %d: %v",
+				virtualPath, offset, length, mask, err)
+			return sshFxByteRangeLockConflict, err.Error()
+		}
+		return sshFxFailure, err.Error()
+	}
+	c.connection.Log(logger.LevelDebug, "lock added for path %q, offset %d, length %d, mask %d",
+		virtualPath, offset, length, mask)
+	return sshFxOk, ""
+}
+
+func (c *lockingChannel) getVirtualPath(p string) (string, bool) {
+	if c.connection.User.Filters.StartDirectory == "" {
+		p = util.CleanPath(p)
+	} else {
+		p = util.CleanPathWithBase(c.connection.User.Filters.StartDirectory, p)
+	}
+	if c.prefix == nil {
+		return p, true
+	}
+	if getPrefixHierarchy(c.prefix.prefix, p) != pathContainsPrefix {
+		return "", false
+	}
+	return c.prefix.removeFolderPrefix(p)
+}
+
+func (c *lockingChannel) Close() error {
+	common.FileLocks.RemoveOwnerLocks(c.connection.GetID())
+	return c.ReadWriteCloser.Close()
+}
+
+// addLockingExtensions adds the byte range locking extensions to the
+// specified SSH_FXP_VERSION packet
+func addLockingExtensions(packet []byte) []byte {
+	result := make([]byte, 0, len(packet)+2*(4+len(sftpExtBlock)+4+1))
+	result = append(result, packet...)
+	for _, ext := range []string{sftpExtBlock, sftpExtUnblock} {
+		result = appendString(result, ext)
+		result = appendString(result, "1")
+	}
+	binary.BigEndian.PutUint32(result, uint32(len(result)-4))
+	return result
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 4 {
+		return "", nil, errMalformedLockRequest
+	}
+	length := binary.BigEndian.Uint32(b)
+	b = b[4:]
+	if uint32(len(b)) < length {
+		return "", nil, errMalformedLockRequest
+	}
+	return string(b[:length]), b[length:], nil
+}
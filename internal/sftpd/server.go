@@ -131,6 +131,9 @@ type Binding struct {
 	Port int `json:"port" mapstructure:"port"`
 	// Apply the proxy configuration, if any, for this binding
 	ApplyProxyConfig bool `json:"apply_proxy_config" mapstructure:"apply_proxy_config"`
+	// If enabled the permissions for all the users connected to this binding
+	// are restricted to list and download
+	ReadOnly bool `json:"read_only" mapstructure:"read_only"`
 }
 
 // GetAddress returns the binding address
@@ -167,6 +170,9 @@ type Configuration struct {
 	// Each certificate can be defined as a path relative to the configuration directory or an absolute one.
 	// Certificate's public key must match a private host key otherwise it will be silently ignored.
 	HostCertificates []string `json:"host_certificates" mapstructure:"host_certificates"`
+	// HostCA defines the internal certificate authority used to sign the host keys.
+	// If enabled, the configured host certificates are ignored
+	HostCA HostCAConfig `json:"host_ca" mapstructure:"host_ca"`
 	// HostKeyAlgorithms lists the public key algorithms that the server will accept for host
 	// key authentication.
 	HostKeyAlgorithms []string `json:"host_key_algorithms" mapstructure:"host_key_algorithms"`
@@ -233,7 +239,11 @@ type Configuration struct {
 	// The prefix is only applied to SFTP requests, SCP and other SSH commands will be automatically disabled if
 	// you configure a prefix.
 	// This setting can help some migrations from OpenSSH. It is not recommended for general usage.
-	FolderPrefix     string `json:"folder_prefix" mapstructure:"folder_prefix"`
+	FolderPrefix string `json:"folder_prefix" mapstructure:"folder_prefix"`
+	// ByteRangeLocking enables the "block@sftpgo.com" and "unblock@sftpgo.com" SFTP extensions.
+	// They allow clients to lock byte ranges using the same semantics defined for the SFTP v6
+	// block and unblock requests. Locks are shared with WebDAV.
+	ByteRangeLocking bool `json:"byte_range_locking" mapstructure:"byte_range_locking"`
 	certChecker      *ssh.CertChecker
 	parsedUserCAKeys []ssh.PublicKey
 }
@@ -380,10 +390,14 @@ func (c *Configuration) Initialize(configDir string) error {
 	if err := c.configureSecurityOptions(serverConfig); err != nil {
 		return err
 	}
+	if err := hostCA.initialize(c.HostCA, configDir); err != nil {
+		return err
+	}
 	if err := c.checkAndLoadHostKeys(configDir, serverConfig); err != nil {
 		serviceStatus.HostKeys = nil
 		return err
 	}
+	hostCA.startRenewals()
 	if err := c.initializeCertChecker(configDir); err != nil {
 		return err
 	}
@@ -421,7 +435,7 @@ func (c *Configuration) Initialize(configDir string) error {
 				listener = proxyListener
 			}
 
-			exitChannel <- c.serve(listener, serverConfig)
+			exitChannel <- c.serve(listener, serverConfig, binding.ReadOnly)
 		}(binding)
 	}
 
@@ -432,7 +446,7 @@ func (c *Configuration) Initialize(configDir string) error {
 	return <-exitChannel
 }
 
-func (c *Configuration) serve(listener net.Listener, serverConfig *ssh.ServerConfig) error {
+func (c *Configuration) serve(listener net.Listener, serverConfig *ssh.ServerConfig, readOnly bool) error {
 	logger.Info(logSender, "", "server listener registered, address: %s", listener.Addr().String())
 	var tempDelay time.Duration // how long to sleep on accept failure
 
@@ -458,7 +472,7 @@ func (c *Configuration) serve(listener net.Listener, serverConfig *ssh.ServerCon
 		}
 		tempDelay = 0
 
-		go c.AcceptInboundConnection(conn, serverConfig)
+		go c.AcceptInboundConnection(conn, serverConfig, readOnly)
 	}
 }
 
@@ -595,7 +609,7 @@ func (c *Configuration) configureKeyboardInteractiveAuth(serverConfig *ssh.Serve
 }
 
 // AcceptInboundConnection handles an inbound connection to the server instance and determines if the request should be served or not.
-func (c *Configuration) AcceptInboundConnection(conn net.Conn, config *ssh.ServerConfig) {
+func (c *Configuration) AcceptInboundConnection(conn net.Conn, config *ssh.ServerConfig, readOnly bool) {
 	defer func() {
 		if r := recover(); r != nil {
 			logger.Error(logSender, "", "panic in AcceptInboundConnection: %q stack trace: %v", r, string(debug.Stack()))
@@ -614,6 +628,7 @@ func (c *Configuration) AcceptInboundConnection(conn net.Conn, config *ssh.Serve
 	// we'll set a Deadline for handshake to complete, the default is 2 minutes as OpenSSH
 	conn.SetDeadline(time.Now().Add(handshakeTimeout)) //nolint:errcheck
 
+	conn = newNegotiatedAlgorithmsConn(conn)
 	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
 	if err != nil {
 		logger.Debug(logSender, "", "failed to accept an incoming connection from ip %q: %v", ipAddr, err)
@@ -630,6 +645,9 @@ func (c *Configuration) AcceptInboundConnection(conn net.Conn, config *ssh.Serve
 
 	// Unmarshal cannot fails here and even if it fails we'll have a user with no permissions
 	json.Unmarshal([]byte(sconn.Permissions.Extensions["sftpgo_user"]), &user) //nolint:errcheck
+	if readOnly {
+		user.SetReadOnlyPermissions()
+	}
 
 	loginType := sconn.Permissions.Extensions["sftpgo_login_method"]
 	connectionID := hex.EncodeToString(sconn.SessionID())
@@ -726,8 +744,12 @@ func (c *Configuration) handleSftpConnection(channel ssh.Channel, connection *Co
 	}
 	defer common.Connections.Remove(connection.GetID())
 
+	var rwc io.ReadWriteCloser = channel
+	if c.ByteRangeLocking {
+		rwc = newLockingChannel(channel, connection, c.FolderPrefix)
+	}
 	// Create the server instance for the channel using the handler we created above.
-	server := sftp.NewRequestServer(channel, c.createHandlers(connection), sftp.WithRSAllocator(),
+	server := sftp.NewRequestServer(rwc, c.createHandlers(connection), sftp.WithRSAllocator(),
 		sftp.WithStartDirectory(connection.User.Filters.StartDirectory))
 
 	defer server.Close()
@@ -828,6 +850,7 @@ func loginUser(user *dataprovider.User, loginMethod, publicKey string, conn ssh.
 			user.Username, user.HomeDir)
 		return nil, fmt.Errorf("cannot login user with invalid home dir: %q", user.HomeDir)
 	}
+	user.ApplySourceAuthPolicy(conn.RemoteAddr().String())
 	if util.Contains(user.Filters.DeniedProtocols, common.ProtocolSSH) {
 		logger.Info(logSender, connectionID, "cannot login user %q, protocol SSH is not allowed", user.Username)
 		return nil, fmt.Errorf("protocol SSH is not allowed for user %q", user.Username)
@@ -856,6 +879,11 @@ func loginUser(user *dataprovider.User, loginMethod, publicKey string, conn ssh.
 			user.Username, remoteAddr)
 		return nil, fmt.Errorf("login for user %q is not allowed from this address: %v", user.Username, remoteAddr)
 	}
+	if err := checkNegotiatedAlgorithms(user, conn); err != nil {
+		logger.Info(logSender, connectionID, "cannot login user %q, SSH algorithms not allowed: %v",
+			user.Username, err)
+		return nil, fmt.Errorf("SSH algorithms not allowed for user %q: %w", user.Username, err)
+	}
 
 	json, err := json.Marshal(user)
 	if err != nil {
@@ -1068,6 +1096,12 @@ func (c *Configuration) checkAndLoadHostKeys(configDir string, serverConfig *ssh
 
 		// Add private key to the server configuration.
 		serverConfig.AddHostKey(mas)
+		if hostCA.isEnabled() {
+			if err := c.addHostCACertificate(hostKey, mas, serverConfig); err != nil {
+				return err
+			}
+			continue
+		}
 		for _, cert := range hostCertificates {
 			signer, err := ssh.NewCertSigner(cert.Certificate, mas)
 			if err == nil {
@@ -1099,6 +1133,30 @@ func (c *Configuration) checkAndLoadHostKeys(configDir string, serverConfig *ssh
 	return nil
 }
 
+func (c *Configuration) addHostCACertificate(hostKey string, mas ssh.MultiAlgorithmSigner, serverConfig *ssh.ServerConfig) error {
+	signer, err := hostCA.getSigner(hostKey, mas)
+	if err != nil {
+		return fmt.Errorf("unable to sign host key %q using the internal CA: %w", hostKey, err)
+	}
+	var algos []string
+	for _, algo := range algorithmsForKeyFormat(signer.PublicKey().Type()) {
+		if underlyingAlgo, ok := certKeyAlgoNames[algo]; ok {
+			if util.Contains(mas.Algorithms(), underlyingAlgo) {
+				algos = append(algos, algo)
+			}
+		}
+	}
+	serviceStatus.HostKeys = append(serviceStatus.HostKeys, HostKey{
+		Path:        hostKey,
+		Fingerprint: ssh.FingerprintSHA256(signer.PublicKey()),
+		Algorithms:  algos,
+	})
+	serverConfig.AddHostKey(signer)
+	logger.Info(logSender, "", "Host certificate issued by the internal CA for host key %q, fingerprint %q, algorithms %+v",
+		hostKey, ssh.FingerprintSHA256(signer.PublicKey()), algos)
+	return nil
+}
+
 func (c *Configuration) loadHostCertificates(configDir string) ([]hostCertificate, error) {
 	var certs []hostCertificate
 	for _, certPath := range c.HostCertificates {
@@ -1130,6 +1188,10 @@ func (c *Configuration) loadHostCertificates(configDir string) ([]hostCertificat
 			Path:        certPath,
 			Certificate: cert,
 		})
+		if cert.ValidBefore != ssh.CertTimeInfinity {
+			common.AddMonitoredCertificate(common.CertificateKindSSHHost, certPath, cert.KeyId,
+				time.Unix(int64(cert.ValidBefore), 0))
+		}
 	}
 	return certs, nil
 }
@@ -1184,6 +1246,32 @@ func (c *Configuration) initializeCertChecker(configDir string) error {
 	return revokedCertManager.load()
 }
 
+// checkUserCertificate checks the SSH user certificate against the globally trusted
+// CAs and the certificate settings for the specified user
+func (c *Configuration) checkUserCertificate(user *dataprovider.User, cert *ssh.Certificate) error {
+	certConfig := &user.Filters.SSHCertificates
+	certChecker := &ssh.CertChecker{
+		SupportedCriticalOptions: c.certChecker.SupportedCriticalOptions,
+		IsUserAuthority: func(k ssh.PublicKey) bool {
+			return c.certChecker.IsUserAuthority(k) || certConfig.IsUserAuthority(k)
+		},
+	}
+	if !certChecker.IsUserAuthority(cert.SignatureKey) {
+		return errors.New("ssh: certificate signed by unrecognized authority")
+	}
+	principal := certConfig.GetPrincipal(cert.ValidPrincipals, user.Username)
+	if principal == "" {
+		return fmt.Errorf("ssh: principals %v not allowed for user %q", cert.ValidPrincipals, user.Username)
+	}
+	if err := certChecker.CheckCert(principal, cert); err != nil {
+		return err
+	}
+	if err := certConfig.CheckCertificate(cert); err != nil {
+		return fmt.Errorf("ssh: %w", err)
+	}
+	return nil
+}
+
 func (c *Configuration) getPartialSuccessError(nextAuthMethods []string) error {
 	err := &ssh.PartialSuccessError{}
 	if c.PasswordAuthentication && util.Contains(nextAuthMethods, dataprovider.LoginMethodPassword) {
@@ -1219,12 +1307,6 @@ func (c *Configuration) validatePublicKeyCredentials(conn ssh.ConnMetadata, pubK
 			updateLoginMetrics(&user, ipAddr, method, err)
 			return nil, err
 		}
-		if !c.certChecker.IsUserAuthority(cert.SignatureKey) {
-			err = errors.New("ssh: certificate signed by unrecognized authority")
-			user.Username = conn.User()
-			updateLoginMetrics(&user, ipAddr, method, err)
-			return nil, err
-		}
 		if len(cert.ValidPrincipals) == 0 {
 			err = fmt.Errorf("ssh: certificate %s has no valid principals, user: \"%s\"", certFingerprint, conn.User())
 			user.Username = conn.User()
@@ -1237,18 +1319,21 @@ func (c *Configuration) validatePublicKeyCredentials(conn ssh.ConnMetadata, pubK
 			updateLoginMetrics(&user, ipAddr, method, err)
 			return nil, err
 		}
-		if err := c.certChecker.CheckCert(conn.User(), cert); err != nil {
-			user.Username = conn.User()
-			updateLoginMetrics(&user, ipAddr, method, err)
-			return nil, err
-		}
 		certPerm = &cert.Permissions
 	}
 	if user, keyID, err = dataprovider.CheckUserAndPubKey(conn.User(), pubKey.Marshal(), ipAddr, common.ProtocolSSH, ok); err == nil {
 		if ok {
+			if err = c.checkUserCertificate(&user, cert); err != nil {
+				logger.Debug(logSender, connectionID, "certificate %s not accepted for user %q: %v",
+					certFingerprint, conn.User(), err)
+				user.Username = conn.User()
+				updateLoginMetrics(&user, ipAddr, method, err)
+				return nil, err
+			}
 			keyID = fmt.Sprintf("%s: ID: %s, serial: %v, CA %s %s", certFingerprint,
 				cert.KeyId, cert.Serial, cert.Type(), ssh.FingerprintSHA256(cert.SignatureKey))
 		}
+		user.ApplySourceAuthPolicy(conn.RemoteAddr().String())
 		if user.IsPartialAuth() {
 			logger.Debug(logSender, connectionID, "user %q authenticated with partial success", conn.User())
 			return certPerm, c.getPartialSuccessError(user.GetNextAuthMethods())
@@ -1271,12 +1356,14 @@ func (c *Configuration) validatePublicKeyCredentials(conn ssh.ConnMetadata, pubK
 }
 
 func (c *Configuration) validatePasswordCredentials(conn ssh.ConnMetadata, pass []byte, method string) (*ssh.Permissions, error) {
-	var err error
-	var user dataprovider.User
 	var sshPerm *ssh.Permissions
 
 	ipAddr := util.GetIPFromRemoteAddress(conn.RemoteAddr().String())
-	if user, err = dataprovider.CheckUserAndPass(conn.User(), string(pass), ipAddr, common.ProtocolSSH); err == nil {
+	user, isAnonymous, err := common.CheckAnonymousLogin(conn.User(), string(pass), ipAddr, common.ProtocolSSH)
+	if !isAnonymous {
+		user, err = dataprovider.CheckUserAndPass(conn.User(), string(pass), ipAddr, common.ProtocolSSH)
+	}
+	if err == nil {
 		sshPerm, err = loginUser(&user, method, "", conn)
 	}
 	user.Username = conn.User()
@@ -590,6 +590,97 @@ func TestBasicSFTPHandling(t *testing.T) {
 	assert.NotEmpty(t, status.GetPublicKeysAlgosAsString())
 }
 
+func TestCompressedFs(t *testing.T) {
+	usePubKey := false
+	u := getTestUser(usePubKey)
+	u.QuotaSize = 6553600
+	u.FsConfig.CompressConfig = vfs.CompressFsConfig{
+		Algo:           vfs.CompressAlgoZstd,
+		SkipExtensions: []string{"ZIP", ".zip"},
+	}
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".zip"}, user.FsConfig.CompressConfig.SkipExtensions)
+	conn, client, err := getSftpClient(user, usePubKey)
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		defer client.Close()
+
+		testFilePath := filepath.Join(homeBasePath, testFileName)
+		content := bytes.Repeat([]byte("compressible content "), 4096)
+		testFileSize := int64(len(content))
+		err = os.WriteFile(testFilePath, content, os.ModePerm)
+		assert.NoError(t, err)
+		err = sftpUploadFile(testFilePath, testFileName, testFileSize, client)
+		assert.NoError(t, err)
+		info, err := client.Stat(testFileName)
+		if assert.NoError(t, err) {
+			assert.Equal(t, testFileSize, info.Size())
+		}
+		info, err = os.Stat(filepath.Join(user.GetHomeDir(), testFileName))
+		if assert.NoError(t, err) {
+			assert.Less(t, info.Size(), testFileSize)
+		}
+		entries, err := client.ReadDir("/")
+		if assert.NoError(t, err) && assert.Len(t, entries, 1) {
+			assert.Equal(t, testFileSize, entries[0].Size())
+		}
+		localDownloadPath := filepath.Join(homeBasePath, testDLFileName)
+		err = sftpDownloadFile(testFileName, localDownloadPath, testFileSize, client)
+		assert.NoError(t, err)
+		initialHash, err := computeHashForFile(sha256.New(), testFilePath)
+		assert.NoError(t, err)
+		downloadedFileHash, err := computeHashForFile(sha256.New(), localDownloadPath)
+		assert.NoError(t, err)
+		assert.Equal(t, initialHash, downloadedFileHash)
+		// read with an offset
+		f, err := client.Open(testFileName)
+		if assert.NoError(t, err) {
+			_, err = f.Seek(testFileSize-100, io.SeekStart)
+			assert.NoError(t, err)
+			buf := make([]byte, 100)
+			_, err = io.ReadFull(f, buf)
+			assert.NoError(t, err)
+			assert.Equal(t, content[testFileSize-100:], buf)
+			err = f.Close()
+			assert.NoError(t, err)
+		}
+		// files with the skipped extensions are stored uncompressed
+		err = sftpUploadFile(testFilePath, "file.zip", testFileSize, client)
+		assert.NoError(t, err)
+		info, err = os.Stat(filepath.Join(user.GetHomeDir(), "file.zip"))
+		if assert.NoError(t, err) {
+			assert.Equal(t, testFileSize, info.Size())
+		}
+		user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, user.UsedQuotaFiles)
+		assert.Equal(t, 2*testFileSize, user.UsedQuotaSize)
+		_, err = httpdtest.StartQuotaScan(user, http.StatusAccepted)
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			scans, _, err := httpdtest.GetQuotaScans(http.StatusOK)
+			if err == nil {
+				return len(scans) == 0
+			}
+			return false
+		}, 1*time.Second, 50*time.Millisecond)
+		user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, user.UsedQuotaFiles)
+		assert.Equal(t, 2*testFileSize, user.UsedQuotaSize)
+
+		err = os.Remove(testFilePath)
+		assert.NoError(t, err)
+		err = os.Remove(localDownloadPath)
+		assert.NoError(t, err)
+	}
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestBasicSFTPFsHandling(t *testing.T) {
 	usePubKey := true
 	baseUser, _, err := httpdtest.AddUser(getTestUser(usePubKey), http.StatusCreated)
@@ -1795,6 +1886,204 @@ func TestStatChownChmod(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestSFTPFsReadCache(t *testing.T) {
+	oldConfig := config.GetCommonConfig()
+
+	cachePath := filepath.Join(homeBasePath, "readcache")
+	cfg := config.GetCommonConfig()
+	cfg.ReadCache.Path = "relative"
+	cfg.ReadCache.MaxSize = 10
+	err := common.Initialize(cfg, 0)
+	assert.Error(t, err)
+	cfg.ReadCache.Path = cachePath
+	err = common.Initialize(cfg, 0)
+	assert.NoError(t, err)
+
+	getCachedFiles := func() int {
+		entries, err := os.ReadDir(cachePath)
+		if err != nil {
+			return 0
+		}
+		result := 0
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				result++
+			}
+		}
+		return result
+	}
+
+	usePubKey := true
+	baseUser, _, err := httpdtest.AddUser(getTestUser(usePubKey), http.StatusCreated)
+	assert.NoError(t, err)
+	user, _, err := httpdtest.AddUser(getTestSFTPUser(usePubKey), http.StatusCreated)
+	assert.NoError(t, err)
+	conn, client, err := getSftpClient(user, usePubKey)
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		defer client.Close()
+
+		testFilePath := filepath.Join(homeBasePath, testFileName)
+		testFileSize := int64(65535)
+		err = createTestFile(testFilePath, testFileSize)
+		assert.NoError(t, err)
+		err = sftpUploadFile(testFilePath, testFileName, testFileSize, client)
+		assert.NoError(t, err)
+		localDownloadPath := filepath.Join(homeBasePath, testDLFileName)
+		err = sftpDownloadFile(testFileName, localDownloadPath, testFileSize, client)
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return getCachedFiles() == 1
+		}, 1*time.Second, 50*time.Millisecond)
+		// the second download is served from the cache
+		err = sftpDownloadFile(testFileName, localDownloadPath, testFileSize, client)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, getCachedFiles())
+		// a modified file is downloaded again
+		testFileSize = 32768
+		err = createTestFile(testFilePath, testFileSize)
+		assert.NoError(t, err)
+		err = sftpUploadFile(testFilePath, testFileName, testFileSize, client)
+		assert.NoError(t, err)
+		err = sftpDownloadFile(testFileName, localDownloadPath, testFileSize, client)
+		assert.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return getCachedFiles() == 2
+		}, 1*time.Second, 50*time.Millisecond)
+
+		err = os.Remove(testFilePath)
+		assert.NoError(t, err)
+		err = os.Remove(localDownloadPath)
+		assert.NoError(t, err)
+	}
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveUser(baseUser, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(baseUser.GetHomeDir())
+	assert.NoError(t, err)
+
+	err = common.Initialize(oldConfig, 0)
+	assert.NoError(t, err)
+	err = os.RemoveAll(cachePath)
+	assert.NoError(t, err)
+}
+
+func TestDedupStore(t *testing.T) {
+	if runtime.GOOS == osWindows {
+		t.Skip("this test is not available on Windows")
+	}
+	oldConfig := config.GetCommonConfig()
+
+	storePath := filepath.Join(homeBasePath, "dedup")
+	cfg := config.GetCommonConfig()
+	cfg.Dedup.Path = "relative"
+	err := common.Initialize(cfg, 0)
+	assert.Error(t, err)
+	cfg.Dedup.Path = storePath
+	err = common.Initialize(cfg, 0)
+	assert.NoError(t, err)
+
+	usePubKey := true
+	u1 := getTestUser(usePubKey)
+	u1.Username += "1"
+	u1.HomeDir += "1"
+	u2 := getTestUser(usePubKey)
+	u2.Username += "2"
+	u2.HomeDir += "2"
+	u2.QuotaSize = 6553600
+	user1, _, err := httpdtest.AddUser(u1, http.StatusCreated)
+	assert.NoError(t, err)
+	user2, _, err := httpdtest.AddUser(u2, http.StatusCreated)
+	assert.NoError(t, err)
+
+	testFilePath := filepath.Join(homeBasePath, testFileName)
+	testFileSize := int64(65535)
+	err = createTestFile(testFilePath, testFileSize)
+	assert.NoError(t, err)
+	for _, user := range []dataprovider.User{user1, user2} {
+		conn, client, err := getSftpClient(user, usePubKey)
+		if assert.NoError(t, err) {
+			err = sftpUploadFile(testFilePath, testFileName, testFileSize, client)
+			assert.NoError(t, err)
+			err = sftpUploadFile(testFilePath, testFileName+"_copy", testFileSize, client)
+			assert.NoError(t, err)
+			client.Close()
+			conn.Close()
+		}
+	}
+	// the identical files share the same content
+	info1, err := os.Stat(filepath.Join(user1.GetHomeDir(), testFileName))
+	assert.NoError(t, err)
+	info2, err := os.Stat(filepath.Join(user2.GetHomeDir(), testFileName))
+	assert.NoError(t, err)
+	assert.True(t, os.SameFile(info1, info2))
+	info2, err = os.Stat(filepath.Join(user2.GetHomeDir(), testFileName+"_copy"))
+	assert.NoError(t, err)
+	assert.True(t, os.SameFile(info1, info2))
+	// the quota is based on the logical sizes
+	user2, _, err = httpdtest.GetUserByUsername(user2.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, user2.UsedQuotaFiles)
+	assert.Equal(t, 2*testFileSize, user2.UsedQuotaSize)
+
+	vfs.CleanupDedupStore()
+	status := vfs.GetDedupStatus()
+	assert.True(t, status.IsActive)
+	assert.Equal(t, int64(1), status.Contents)
+	assert.Equal(t, int64(4), status.References)
+	assert.Equal(t, 4*testFileSize, status.LogicalSize)
+	assert.Equal(t, testFileSize, status.PhysicalSize)
+	assert.Equal(t, 3*testFileSize, status.SavedSize)
+	// modifying a deduplicated file does not affect the other ones
+	conn, client, err := getSftpClient(user1, usePubKey)
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		defer client.Close()
+
+		appendDataSize := int64(65535)
+		err = appendToTestFile(testFilePath, appendDataSize)
+		assert.NoError(t, err)
+		err = sftpUploadResumeFile(testFilePath, testFileName, testFileSize+appendDataSize, false, client)
+		assert.NoError(t, err)
+		err = client.Truncate(testFileName+"_copy", 100)
+		assert.NoError(t, err)
+	}
+	info1, err = os.Stat(filepath.Join(user1.GetHomeDir(), testFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, 2*testFileSize, info1.Size())
+	info2, err = os.Stat(filepath.Join(user2.GetHomeDir(), testFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, testFileSize, info2.Size())
+	assert.False(t, os.SameFile(info1, info2))
+	info2, err = os.Stat(filepath.Join(user2.GetHomeDir(), testFileName+"_copy"))
+	assert.NoError(t, err)
+	assert.Equal(t, testFileSize, info2.Size())
+	// the contents no longer referenced are removed
+	_, err = httpdtest.RemoveUser(user2, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user2.GetHomeDir())
+	assert.NoError(t, err)
+	vfs.CleanupDedupStore()
+	status = vfs.GetDedupStatus()
+	assert.Equal(t, int64(1), status.Contents)
+	assert.Equal(t, int64(1), status.References)
+	assert.Equal(t, int64(0), status.SavedSize)
+
+	_, err = httpdtest.RemoveUser(user1, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user1.GetHomeDir())
+	assert.NoError(t, err)
+	err = os.Remove(testFilePath)
+	assert.NoError(t, err)
+	err = common.Initialize(oldConfig, 0)
+	assert.NoError(t, err)
+	err = os.RemoveAll(storePath)
+	assert.NoError(t, err)
+	assert.False(t, vfs.GetDedupStatus().IsActive)
+}
+
 func TestSFTPFsLoginWrongFingerprint(t *testing.T) {
 	usePubKey := true
 	localUser, _, err := httpdtest.AddUser(getTestUser(usePubKey), http.StatusCreated)
@@ -5320,6 +5609,65 @@ func TestSFTPLoopSimple(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestSFTPLoopUnionFs(t *testing.T) {
+	usePubKey := false
+	user1 := getTestUser(usePubKey)
+	user2 := getTestSFTPUser(usePubKey)
+	user1.Username = defaultSFTPUsername + "1"
+	user2.Username += "2"
+	user1.FsConfig.Provider = vfs.UnionFilesystemProvider
+	user1.FsConfig.UnionConfig = vfs.UnionFsConfig{
+		Members: []vfs.UnionFsMember{
+			{
+				MappedPath: filepath.Join(homeBasePath, user1.Username),
+			},
+			{
+				FsConfig: vfs.Filesystem{
+					Provider: sdk.SFTPFilesystemProvider,
+					SFTPConfig: vfs.SFTPFsConfig{
+						BaseSFTPFsConfig: sdk.BaseSFTPFsConfig{
+							Endpoint: sftpServerAddr,
+							Username: user1.Username,
+						},
+						Password: kms.NewPlainSecret(defaultPassword),
+					},
+				},
+			},
+		},
+	}
+	user2.FsConfig.Provider = sdk.SFTPFilesystemProvider
+	user2.FsConfig.SFTPConfig = vfs.SFTPFsConfig{
+		BaseSFTPFsConfig: sdk.BaseSFTPFsConfig{
+			Endpoint: sftpServerAddr,
+			Username: user1.Username,
+		},
+		Password: kms.NewPlainSecret(defaultPassword),
+	}
+	user1, resp, err := httpdtest.AddUser(user1, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	user2, resp, err = httpdtest.AddUser(user2, http.StatusCreated)
+	assert.NoError(t, err, string(resp))
+	// the SFTP member connects to the same user
+	_, _, err = getSftpClient(user1, usePubKey)
+	assert.Error(t, err)
+	// the SFTP member connects to a user with an SFTP filesystem
+	user1.FsConfig.UnionConfig.Members[1].FsConfig.SFTPConfig.Username = user2.Username
+	user1.FsConfig.UnionConfig.Members[1].FsConfig.SFTPConfig.Password = kms.NewPlainSecret(defaultPassword)
+	_, _, err = httpdtest.UpdateUser(user1, http.StatusOK, "")
+	assert.NoError(t, err)
+	_, _, err = getSftpClient(user1, usePubKey)
+	assert.Error(t, err)
+
+	_, err = httpdtest.RemoveUser(user1, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user1.GetHomeDir())
+	assert.NoError(t, err)
+	_, err = httpdtest.RemoveUser(user2, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user2.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestSFTPLoopVirtualFolders(t *testing.T) {
 	usePubKey := false
 	sftpFloderName := "sftp"
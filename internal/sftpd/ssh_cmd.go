@@ -192,7 +192,7 @@ func (c *sshCommand) handleSFTPGoRemove() error {
 func (c *sshCommand) updateQuota(sshDestPath string, filesNum int, filesSize int64) {
 	vfolder, err := c.connection.User.GetVirtualFolderForPath(sshDestPath)
 	if err == nil {
-		dataprovider.UpdateVirtualFolderQuota(&vfolder.BaseVirtualFolder, filesNum, filesSize, false) //nolint:errcheck
+		dataprovider.UpdateVirtualFolderQuota(&vfolder, filesNum, filesSize, false) //nolint:errcheck
 		if vfolder.IsIncludedInUserQuota() {
 			dataprovider.UpdateUserQuota(&c.connection.User, filesNum, filesSize, false) //nolint:errcheck
 		}
@@ -226,21 +226,10 @@ func (c *sshCommand) handleHashCommands() error {
 		response = fmt.Sprintf("%x  -\n", h.Sum(nil))
 	} else {
 		sshPath := c.getDestPath()
-		if ok, policy := c.connection.User.IsFileAllowed(sshPath); !ok {
-			c.connection.Log(logger.LevelInfo, "hash not allowed for file %q", sshPath)
-			return c.sendErrorResponse(c.connection.GetErrorForDeniedFile(policy))
-		}
-		fs, fsPath, err := c.connection.GetFsAndResolvedPath(sshPath)
+		hash, err := c.connection.GetFileHash(sshPath, h, 0, 0)
 		if err != nil {
 			return c.sendErrorResponse(err)
 		}
-		if !c.connection.User.HasPerm(dataprovider.PermListItems, sshPath) {
-			return c.sendErrorResponse(c.connection.GetPermissionDeniedError())
-		}
-		hash, err := c.computeHashForFile(fs, h, fsPath)
-		if err != nil {
-			return c.sendErrorResponse(c.connection.GetFsError(fs, err))
-		}
 		response = fmt.Sprintf("%v  %v\n", hash, sshPath)
 	}
 	c.connection.channel.Write([]byte(response)) //nolint:errcheck
@@ -577,26 +566,6 @@ func (c *sshCommand) sendExitStatus(err error) {
 	}
 }
 
-func (c *sshCommand) computeHashForFile(fs vfs.Fs, hasher hash.Hash, path string) (string, error) {
-	hash := ""
-	f, r, _, err := fs.Open(path, 0)
-	if err != nil {
-		return hash, err
-	}
-	var reader io.ReadCloser
-	if f != nil {
-		reader = f
-	} else {
-		reader = r
-	}
-	defer reader.Close()
-	_, err = io.Copy(hasher, reader)
-	if err == nil {
-		hash = fmt.Sprintf("%x", hasher.Sum(nil))
-	}
-	return hash, err
-}
-
 func parseCommandPayload(command string) (string, []string, error) {
 	parts, err := shlex.Split(command)
 	if err == nil && len(parts) == 0 {
@@ -0,0 +1,298 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package sftpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	msgKexInit = 20
+	// max size allowed for the version line and the first packet
+	maxKexInitSniffSize = 256 * 1024
+	// number of name-lists in a SSH_MSG_KEXINIT message
+	kexInitNameLists = 10
+)
+
+var (
+	// authenticated encryption ciphers, no MAC is negotiated if they are used
+	aeadCiphers = []string{"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com"}
+
+	negotiatedAlgosConns sync.Map
+)
+
+// negotiatedAlgorithms defines the algorithms negotiated in the SSH handshake
+type negotiatedAlgorithms struct {
+	kex          string
+	cipherCToS   string
+	cipherSToC   string
+	macCToS      string
+	macSToC      string
+	isCompleted  bool
+	clientKexMsg [][]string
+	serverKexMsg [][]string
+}
+
+func (a *negotiatedAlgorithms) negotiate() error {
+	// name-lists order: kex, host key, encryption c2s, encryption s2c, mac c2s, mac s2c,
+	// compression c2s, compression s2c, languages c2s, languages s2c
+	a.kex = findCommonAlgorithm(a.clientKexMsg[0], a.serverKexMsg[0])
+	a.cipherCToS = findCommonAlgorithm(a.clientKexMsg[2], a.serverKexMsg[2])
+	a.cipherSToC = findCommonAlgorithm(a.clientKexMsg[3], a.serverKexMsg[3])
+	if a.kex == "" || a.cipherCToS == "" || a.cipherSToC == "" {
+		return errors.New("no common algorithm")
+	}
+	if !util.Contains(aeadCiphers, a.cipherCToS) {
+		a.macCToS = findCommonAlgorithm(a.clientKexMsg[4], a.serverKexMsg[4])
+	}
+	if !util.Contains(aeadCiphers, a.cipherSToC) {
+		a.macSToC = findCommonAlgorithm(a.clientKexMsg[5], a.serverKexMsg[5])
+	}
+	a.isCompleted = true
+	return nil
+}
+
+// check returns an error if the negotiated algorithms are not allowed by the
+// specified restrictions
+func (a *negotiatedAlgorithms) check(restrictions *dataprovider.SSHAlgorithms) error {
+	if !a.isCompleted {
+		return errors.New("unable to determine the negotiated algorithms")
+	}
+	if len(restrictions.KexAlgorithms) > 0 && !util.Contains(restrictions.KexAlgorithms, a.kex) {
+		return fmt.Errorf("KEX algorithm %q is not allowed", a.kex)
+	}
+	for _, cipher := range []string{a.cipherCToS, a.cipherSToC} {
+		if len(restrictions.Ciphers) > 0 && !util.Contains(restrictions.Ciphers, cipher) {
+			return fmt.Errorf("cipher %q is not allowed", cipher)
+		}
+	}
+	for _, mac := range []string{a.macCToS, a.macSToC} {
+		if mac != "" && len(restrictions.MACs) > 0 && !util.Contains(restrictions.MACs, mac) {
+			return fmt.Errorf("MAC algorithm %q is not allowed", mac)
+		}
+	}
+	return nil
+}
+
+func findCommonAlgorithm(client, server []string) string {
+	for _, c := range client {
+		if util.Contains(server, c) {
+			return c
+		}
+	}
+	return ""
+}
+
+// kexInitSniffer collects the bytes sent by one side of the connection until the
+// first SSH_MSG_KEXINIT message, sent in clear text after the version exchange,
+// is available
+type kexInitSniffer struct {
+	buf       []byte
+	nameLists [][]string
+	err       error
+}
+
+func (s *kexInitSniffer) isDone() bool {
+	return s.nameLists != nil || s.err != nil
+}
+
+func (s *kexInitSniffer) add(data []byte) {
+	if s.isDone() {
+		return
+	}
+	s.buf = append(s.buf, data...)
+	if len(s.buf) > maxKexInitSniffSize {
+		s.setError(errors.New("SSH_MSG_KEXINIT not found"))
+		return
+	}
+	s.parse()
+}
+
+func (s *kexInitSniffer) setError(err error) {
+	s.err = err
+	s.buf = nil
+}
+
+func (s *kexInitSniffer) parse() {
+	// the version line is terminated by CR LF, other lines may be sent before it
+	data := s.buf
+	for {
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			return
+		}
+		line := data[:idx]
+		data = data[idx+1:]
+		if bytes.HasPrefix(line, []byte("SSH-")) {
+			break
+		}
+	}
+	if len(data) < 5 {
+		return
+	}
+	packetLen := binary.BigEndian.Uint32(data)
+	if packetLen > maxKexInitSniffSize {
+		s.setError(fmt.Errorf("invalid packet length: %d", packetLen))
+		return
+	}
+	if uint32(len(data)-4) < packetLen {
+		return
+	}
+	paddingLen := uint32(data[4])
+	if paddingLen+1 > packetLen {
+		s.setError(fmt.Errorf("invalid padding length: %d", paddingLen))
+		return
+	}
+	nameLists, err := parseKexInit(data[5 : 4+packetLen-paddingLen])
+	if err != nil {
+		s.setError(err)
+		return
+	}
+	s.nameLists = nameLists
+	s.buf = nil
+}
+
+func parseKexInit(payload []byte) ([][]string, error) {
+	// message type and 16 bytes cookie
+	if len(payload) < 17 || payload[0] != msgKexInit {
+		return nil, errors.New("the first packet is not a SSH_MSG_KEXINIT")
+	}
+	payload = payload[17:]
+	nameLists := make([][]string, 0, kexInitNameLists)
+	for i := 0; i < kexInitNameLists; i++ {
+		if len(payload) < 4 {
+			return nil, errors.New("malformed SSH_MSG_KEXINIT")
+		}
+		length := binary.BigEndian.Uint32(payload)
+		payload = payload[4:]
+		if uint32(len(payload)) < length {
+			return nil, errors.New("malformed SSH_MSG_KEXINIT name-list")
+		}
+		var names []string
+		if length > 0 {
+			names = strings.Split(string(payload[:length]), ",")
+		}
+		nameLists = append(nameLists, names)
+		payload = payload[length:]
+	}
+	return nameLists, nil
+}
+
+// negotiatedAlgorithmsConn is a net.Conn that allows to find the algorithms negotiated
+// in the initial SSH key exchange by inspecting the clear text SSH_MSG_KEXINIT messages
+type negotiatedAlgorithmsConn struct {
+	net.Conn
+	done   atomic.Bool
+	mu     sync.Mutex
+	client kexInitSniffer
+	server kexInitSniffer
+}
+
+func newNegotiatedAlgorithmsConn(conn net.Conn) *negotiatedAlgorithmsConn {
+	c := &negotiatedAlgorithmsConn{
+		Conn: conn,
+	}
+	negotiatedAlgosConns.Store(c.getKey(), c)
+	return c
+}
+
+func (c *negotiatedAlgorithmsConn) getKey() string {
+	return getNegotiatedAlgorithmsKey(c.LocalAddr(), c.RemoteAddr())
+}
+
+func (c *negotiatedAlgorithmsConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 && !c.done.Load() {
+		c.mu.Lock()
+		c.client.add(b[:n])
+		c.updateDone()
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+func (c *negotiatedAlgorithmsConn) Write(b []byte) (int, error) {
+	if !c.done.Load() {
+		c.mu.Lock()
+		c.server.add(b)
+		c.updateDone()
+		c.mu.Unlock()
+	}
+	return c.Conn.Write(b)
+}
+
+func (c *negotiatedAlgorithmsConn) Close() error {
+	negotiatedAlgosConns.Delete(c.getKey())
+	return c.Conn.Close()
+}
+
+func (c *negotiatedAlgorithmsConn) updateDone() {
+	if c.client.isDone() && c.server.isDone() {
+		c.done.Store(true)
+	}
+}
+
+func (c *negotiatedAlgorithmsConn) getNegotiatedAlgorithms() (negotiatedAlgorithms, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var result negotiatedAlgorithms
+	if c.client.err != nil {
+		return result, fmt.Errorf("unable to parse the client key exchange init: %w", c.client.err)
+	}
+	if c.server.err != nil {
+		return result, fmt.Errorf("unable to parse the server key exchange init: %w", c.server.err)
+	}
+	if c.client.nameLists == nil || c.server.nameLists == nil {
+		return result, errors.New("key exchange init not completed")
+	}
+	result.clientKexMsg = c.client.nameLists
+	result.serverKexMsg = c.server.nameLists
+	err := result.negotiate()
+	return result, err
+}
+
+func getNegotiatedAlgorithmsKey(localAddr, remoteAddr net.Addr) string {
+	return localAddr.String() + "_" + remoteAddr.String()
+}
+
+// checkNegotiatedAlgorithms returns an error if the algorithms negotiated for the specified
+// connection are not allowed for the given user
+func checkNegotiatedAlgorithms(user *dataprovider.User, conn ssh.ConnMetadata) error {
+	if user.Filters.SSHAlgorithms.IsEmpty() {
+		return nil
+	}
+	val, ok := negotiatedAlgosConns.Load(getNegotiatedAlgorithmsKey(conn.LocalAddr(), conn.RemoteAddr()))
+	if !ok {
+		return errors.New("unable to find the negotiated algorithms")
+	}
+	algos, err := val.(*negotiatedAlgorithmsConn).getNegotiatedAlgorithms()
+	if err != nil {
+		return err
+	}
+	return algos.check(&user.Filters.SSHAlgorithms)
+}
@@ -21,6 +21,8 @@ import (
 	"errors"
 	"fmt"
 	"html/template"
+	"io"
+	"os"
 	"path/filepath"
 	"sync"
 	"time"
@@ -52,13 +54,18 @@ const (
 	templateEmailDir           = "email"
 	templatePasswordReset      = "reset-password.html"
 	templatePasswordExpiration = "password-expiration.html"
+	templateShareVerification  = "share-verification.html"
+	templateFileRequest        = "file-request.html"
 	dialTimeout                = 10 * time.Second
+	maxInlineImageSize         = 1048576
 )
 
 var (
 	config         = &activeConfig{}
 	initialConfig  *Config
 	emailTemplates = make(map[string]*template.Template)
+	// path to the email templates, inline images are loaded from here
+	emailTemplatesPath string
 )
 
 type activeConfig struct {
@@ -116,7 +123,7 @@ func (c *activeConfig) Set(cfg *dataprovider.SMTPConfigs) {
 }
 
 func (c *activeConfig) getSMTPClientAndMsg(to, bcc []string, subject, body string, contentType EmailContentType,
-	attachments ...*mail.File,
+	embeds []*mail.File, attachments ...*mail.File,
 ) (*mail.Client, *mail.Msg, error) {
 	c.RLock()
 	defer c.RUnlock()
@@ -125,11 +132,13 @@ func (c *activeConfig) getSMTPClientAndMsg(to, bcc []string, subject, body strin
 		return nil, nil, errors.New("smtp: not configured")
 	}
 
-	return c.config.getSMTPClientAndMsg(to, bcc, subject, body, contentType, attachments...)
+	return c.config.getSMTPClientAndMsg(to, bcc, subject, body, contentType, embeds, attachments...)
 }
 
-func (c *activeConfig) sendEmail(to, bcc []string, subject, body string, contentType EmailContentType, attachments ...*mail.File) error {
-	client, msg, err := c.getSMTPClientAndMsg(to, bcc, subject, body, contentType, attachments...)
+func (c *activeConfig) sendEmail(to, bcc []string, subject, body string, contentType EmailContentType,
+	embeds []*mail.File, attachments ...*mail.File,
+) error {
+	client, msg, err := c.getSMTPClientAndMsg(to, bcc, subject, body, contentType, embeds, attachments...)
 	if err != nil {
 		return err
 	}
@@ -244,7 +253,8 @@ func (c *Config) loadTemplates(configDir string) error {
 	if templatesPath == "" {
 		return fmt.Errorf("smtp: invalid templates path %q", templatesPath)
 	}
-	loadTemplates(filepath.Join(templatesPath, templateEmailDir))
+	emailTemplatesPath = filepath.Join(templatesPath, templateEmailDir)
+	loadTemplates(emailTemplatesPath)
 	return nil
 }
 
@@ -318,7 +328,7 @@ func (c *Config) getMailClientOptions() []mail.Option {
 }
 
 func (c *Config) getSMTPClientAndMsg(to, bcc []string, subject, body string, contentType EmailContentType,
-	attachments ...*mail.File) (*mail.Client, *mail.Msg, error) {
+	embeds []*mail.File, attachments ...*mail.File) (*mail.Client, *mail.Msg, error) {
 	version := version.Get()
 	msg := mail.NewMsg()
 	msg.SetUserAgent(fmt.Sprintf("SFTPGo-%s-%s", version.Version, version.CommitHash))
@@ -344,6 +354,7 @@ func (c *Config) getSMTPClientAndMsg(to, bcc []string, subject, body string, con
 	msg.SetDate()
 	msg.SetMessageID()
 	msg.SetAttachements(attachments)
+	msg.SetEmbeds(embeds)
 
 	switch contentType {
 	case EmailContentTypeTextPlain:
@@ -370,7 +381,7 @@ func (c *Config) getSMTPClientAndMsg(to, bcc []string, subject, body string, con
 
 // SendEmail tries to send an email using the specified parameters
 func (c *Config) SendEmail(to, bcc []string, subject, body string, contentType EmailContentType, attachments ...*mail.File) error {
-	client, msg, err := c.getSMTPClientAndMsg(to, bcc, subject, body, contentType, attachments...)
+	client, msg, err := c.getSMTPClientAndMsg(to, bcc, subject, body, contentType, nil, attachments...)
 	if err != nil {
 		return err
 	}
@@ -387,9 +398,15 @@ func loadTemplates(templatesPath string) {
 	pwdResetTmpl := util.LoadTemplate(nil, passwordResetPath)
 	passwordExpirationPath := filepath.Join(templatesPath, templatePasswordExpiration)
 	pwdExpirationTmpl := util.LoadTemplate(nil, passwordExpirationPath)
+	shareVerificationPath := filepath.Join(templatesPath, templateShareVerification)
+	shareVerificationTmpl := util.LoadTemplate(nil, shareVerificationPath)
+	fileRequestPath := filepath.Join(templatesPath, templateFileRequest)
+	fileRequestTmpl := util.LoadTemplate(nil, fileRequestPath)
 
 	emailTemplates[templatePasswordReset] = pwdResetTmpl
 	emailTemplates[templatePasswordExpiration] = pwdExpirationTmpl
+	emailTemplates[templateShareVerification] = shareVerificationTmpl
+	emailTemplates[templateFileRequest] = fileRequestTmpl
 }
 
 // RenderPasswordResetTemplate executes the password reset template
@@ -408,9 +425,68 @@ func RenderPasswordExpirationTemplate(buf *bytes.Buffer, data any) error {
 	return emailTemplates[templatePasswordExpiration].Execute(buf, data)
 }
 
+// RenderShareVerificationTemplate executes the share email verification template
+func RenderShareVerificationTemplate(buf *bytes.Buffer, data any) error {
+	if !IsEnabled() {
+		return errors.New("smtp: not configured")
+	}
+	return emailTemplates[templateShareVerification].Execute(buf, data)
+}
+
+// RenderFileRequestTemplate executes the file request drop notification template
+func RenderFileRequestTemplate(buf *bytes.Buffer, data any) error {
+	if !IsEnabled() {
+		return errors.New("smtp: not configured")
+	}
+	return emailTemplates[templateFileRequest].Execute(buf, data)
+}
+
 // SendEmail tries to send an email using the specified parameters.
 func SendEmail(to, bcc []string, subject, body string, contentType EmailContentType, attachments ...*mail.File) error {
-	return config.sendEmail(to, bcc, subject, body, contentType, attachments...)
+	return config.sendEmail(to, bcc, subject, body, contentType, nil, attachments...)
+}
+
+// SendEmailWithEmbeds tries to send an email using the specified parameters.
+// The embeds are added as inline parts and can be referenced from HTML
+// bodies using their name as content ID, for example "cid:logo.png"
+func SendEmailWithEmbeds(to, bcc []string, subject, body string, contentType EmailContentType, embeds []*mail.File,
+	attachments ...*mail.File,
+) error {
+	return config.sendEmail(to, bcc, subject, body, contentType, embeds, attachments...)
+}
+
+// GetInlineImage returns the image with the specified name, loaded from the
+// email templates directory, ready to be embedded in an email
+func GetInlineImage(name string) (*mail.File, error) {
+	if emailTemplatesPath == "" {
+		return nil, fmt.Errorf("smtp: unable to load inline image %q, templates path not configured", name)
+	}
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return nil, fmt.Errorf("smtp: invalid inline image name %q", name)
+	}
+	p := filepath.Join(emailTemplatesPath, name)
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, fmt.Errorf("smtp: unable to stat inline image %q: %w", name, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("smtp: inline image %q is not a regular file", name)
+	}
+	if info.Size() > maxInlineImageSize {
+		return nil, fmt.Errorf("smtp: inline image %q too large: %s", name, util.ByteCountIEC(info.Size()))
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("smtp: unable to read inline image %q: %w", name, err)
+	}
+	return &mail.File{
+		Name:   name,
+		Header: make(map[string][]string),
+		Writer: func(w io.Writer) (int64, error) {
+			n, err := w.Write(data)
+			return int64(n), err
+		},
+	}, nil
 }
 
 // ReloadProviderConf reloads the configuration from the provider
@@ -0,0 +1,37 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build linux
+// +build linux
+
+package vfs
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// GetAccessTime returns the last access time for the specified file info.
+// The modification time is returned if the access time is not available,
+// for example for cloud storage backends
+func GetAccessTime(info os.FileInfo) time.Time {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		atime := time.Unix(stat.Atim.Sec, stat.Atim.Nsec) //nolint:unconvert
+		if atime.After(info.ModTime()) {
+			return atime
+		}
+	}
+	return info.ModTime()
+}
@@ -203,10 +203,23 @@ func (fs *AzureBlobFs) Lstat(name string) (os.FileInfo, error) {
 
 // Open opens the named file for reading
 func (fs *AzureBlobFs) Open(name string, offset int64) (File, PipeReader, func(), error) {
-	r, w, err := pipeat.PipeInDir(fs.localTempDir)
+	return fs.OpenWithInfo(name, offset, nil)
+}
+
+// OpenWithInfo opens the named file for reading, the file info is used to lookup the read cache
+func (fs *AzureBlobFs) OpenWithInfo(name string, offset int64, info os.FileInfo) (File, PipeReader, func(), error) {
+	cacheKey, size := getReadCacheKey(fs, fs.config.getReadCacheResource(), name, info)
+	if p := openFromReadCache(fs, cacheKey, name, offset); p != nil {
+		return nil, p, nil, nil
+	}
+	if err := fs.checkArchivedBlob(name); err != nil {
+		return nil, nil, nil, err
+	}
+	r, pw, err := pipeat.PipeInDir(fs.localTempDir)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	w := newReadCacheWriter(pw, cacheKey, size, offset)
 	p := NewPipeReader(r)
 	ctx, cancelFn := context.WithCancel(context.Background())
 
@@ -262,7 +275,7 @@ func (fs *AzureBlobFs) Create(name string, flag, checks int) (File, PipeWriter,
 		defer cancelFn()
 
 		blockBlob := fs.containerClient.NewBlockBlobClient(name)
-		err := fs.handleMultipartUpload(ctx, r, blockBlob, &headers, metadata)
+		err := fs.handleMultipartUpload(ctx, r, blockBlob, &headers, metadata, fs.getAccessTier(name))
 		r.CloseWithError(err) //nolint:errcheck
 		p.Done(err)
 		fsLog(fs, logger.LevelDebug, "upload completed, path: %q, readed bytes: %v, err: %+v", name, r.GetReadedBytes(), err)
@@ -472,7 +485,11 @@ func (fs *AzureBlobFs) ReadDir(dirname string) ([]os.FileInfo, error) {
 			if t, ok := modTimes[name]; ok {
 				modTime = util.GetTimeFromMsecSinceEpoch(t)
 			}
-			result = append(result, NewFileInfo(name, isDir, size, modTime, false))
+			info := NewFileInfo(name, isDir, size, modTime, false)
+			if !isDir && blobItem.Properties != nil {
+				info.SetAccessTier(util.GetStringFromPointer((*string)(blobItem.Properties.AccessTier)))
+			}
+			result = append(result, info)
 		}
 	}
 	metric.AZListObjectsCompleted(nil)
@@ -757,6 +774,50 @@ func (fs *AzureBlobFs) headObject(name string) (blob.GetPropertiesResponse, erro
 	return resp, err
 }
 
+// checkArchivedBlob starts the rehydration for blobs in the archive access tier
+// and returns ErrRestoreInProgress until they are rehydrated
+func (fs *AzureBlobFs) checkArchivedBlob(name string) error {
+	if fs.config.RehydrateTier == "" {
+		return nil
+	}
+	props, err := fs.headObject(name)
+	if err != nil {
+		return err
+	}
+	if util.GetStringFromPointer(props.AccessTier) != string(blob.AccessTierArchive) {
+		return nil
+	}
+	if util.GetStringFromPointer(props.ArchiveStatus) != "" {
+		fsLog(fs, logger.LevelDebug, "rehydration already in progress for archived blob %q, status: %q",
+			name, util.GetStringFromPointer(props.ArchiveStatus))
+		return ErrRestoreInProgress
+	}
+	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
+	defer cancelFn()
+
+	priority := blob.RehydratePriority(fs.config.RehydratePriority)
+	_, err = fs.containerClient.NewBlockBlobClient(name).SetTier(ctx, blob.AccessTier(fs.config.RehydrateTier),
+		&blob.SetTierOptions{
+			RehydratePriority: &priority,
+		})
+	if err != nil {
+		fsLog(fs, logger.LevelError, "unable to rehydrate archived blob %q: %+v", name, err)
+		return err
+	}
+	fsLog(fs, logger.LevelInfo, "rehydration started for archived blob %q, tier: %q, priority: %q",
+		name, fs.config.RehydrateTier, fs.config.RehydratePriority)
+	return ErrRestoreInProgress
+}
+
+// IsArchiveRestored implements the FsArchiveRestorer interface
+func (fs *AzureBlobFs) IsArchiveRestored(name string) (bool, error) {
+	props, err := fs.headObject(name)
+	if err != nil {
+		return false, err
+	}
+	return util.GetStringFromPointer(props.AccessTier) != string(blob.AccessTierArchive), nil
+}
+
 // GetMimeType returns the content type
 func (fs *AzureBlobFs) GetMimeType(name string) (string, error) {
 	response, err := fs.headObject(name)
@@ -830,7 +891,7 @@ func (fs *AzureBlobFs) copyFileInternal(source, target string) error {
 
 	srcBlob := fs.containerClient.NewBlockBlobClient(source)
 	dstBlob := fs.containerClient.NewBlockBlobClient(target)
-	resp, err := dstBlob.StartCopyFromURL(ctx, srcBlob.URL(), fs.getCopyOptions())
+	resp, err := dstBlob.StartCopyFromURL(ctx, srcBlob.URL(), fs.getCopyOptions(target))
 	if err != nil {
 		metric.AZCopyObjectCompleted(err)
 		return err
@@ -1076,7 +1137,7 @@ func (fs *AzureBlobFs) handleMultipartDownload(ctx context.Context, blockBlob *b
 }
 
 func (fs *AzureBlobFs) handleMultipartUpload(ctx context.Context, reader io.Reader,
-	blockBlob *blockblob.Client, httpHeaders *blob.HTTPHeaders, metadata map[string]*string,
+	blockBlob *blockblob.Client, httpHeaders *blob.HTTPHeaders, metadata map[string]*string, tier string,
 ) error {
 	partSize := fs.config.UploadPartSize
 	guard := make(chan struct{}, fs.config.UploadConcurrency)
@@ -1168,8 +1229,8 @@ func (fs *AzureBlobFs) handleMultipartUpload(ctx context.Context, reader io.Read
 		HTTPHeaders: httpHeaders,
 		Metadata:    metadata,
 	}
-	if fs.config.AccessTier != "" {
-		commitOptions.Tier = (*blob.AccessTier)(&fs.config.AccessTier)
+	if tier != "" {
+		commitOptions.Tier = (*blob.AccessTier)(&tier)
 	}
 
 	_, err := blockBlob.CommitBlockList(ctx, blocks, &commitOptions)
@@ -1198,14 +1259,52 @@ func (*AzureBlobFs) readFill(r io.Reader, buf []byte) (n int, err error) {
 	return n, err
 }
 
-func (fs *AzureBlobFs) getCopyOptions() *blob.StartCopyFromURLOptions {
+func (fs *AzureBlobFs) getCopyOptions(target string) *blob.StartCopyFromURLOptions {
 	copyOptions := &blob.StartCopyFromURLOptions{}
-	if fs.config.AccessTier != "" {
-		copyOptions.Tier = (*blob.AccessTier)(&fs.config.AccessTier)
+	if tier := fs.getAccessTier(target); tier != "" {
+		copyOptions.Tier = (*blob.AccessTier)(&tier)
 	}
 	return copyOptions
 }
 
+// getAccessTier returns the access tier for the specified blob, the most
+// specific tier rule matching the blob path overrides the default tier
+func (fs *AzureBlobFs) getAccessTier(name string) string {
+	tier := fs.config.AccessTier
+	if len(fs.config.TierRules) == 0 {
+		return tier
+	}
+	relPath := fs.GetRelativePath(name)
+	matchLen := 0
+	for _, rule := range fs.config.TierRules {
+		if len(rule.Path) <= matchLen {
+			continue
+		}
+		if rule.Path == "/" || relPath == rule.Path || strings.HasPrefix(relPath, rule.Path+"/") {
+			tier = rule.Tier
+			matchLen = len(rule.Path)
+		}
+	}
+	return tier
+}
+
+// SetAccessTier implements the FsAccessTierSetter interface
+func (fs *AzureBlobFs) SetAccessTier(name, tier string) error {
+	if tier == "" || !util.Contains(validAzAccessTier, tier) {
+		return fmt.Errorf("invalid access tier %q: %w", tier, ErrVfsUnsupported)
+	}
+	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
+	defer cancelFn()
+
+	_, err := fs.containerClient.NewBlockBlobClient(name).SetTier(ctx, blob.AccessTier(tier), &blob.SetTierOptions{})
+	if err != nil {
+		fsLog(fs, logger.LevelError, "unable to set access tier %q for blob %q: %+v", tier, name, err)
+		return err
+	}
+	fsLog(fs, logger.LevelInfo, "access tier %q set for blob %q", tier, name)
+	return nil
+}
+
 func (fs *AzureBlobFs) downloadToWriter(name string, w PipeWriter) (int64, error) {
 	fsLog(fs, logger.LevelDebug, "starting download before resuming upload, path %q", name)
 	ctx, cancelFn := context.WithTimeout(context.Background(), preResumeTimeout)
@@ -0,0 +1,557 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/eikenb/pipeat"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	// compressFsName is the name for the Fs wrapper with compression support
+	compressFsName = "compressfs"
+	// the trailer appended to compressed files: uncompressed size (8 bytes),
+	// algorithm (1 byte) and magic (7 bytes)
+	compressTrailerSize int64 = 16
+	// max number of uncompressed sizes cached for each filesystem
+	compressSizeCacheMaxEntries = 5000
+)
+
+// Supported compression algorithms
+const (
+	CompressAlgoGzip = "gzip"
+	CompressAlgoZstd = "zstd"
+)
+
+const (
+	compressAlgoGzipID byte = 1
+	compressAlgoZstdID byte = 2
+)
+
+var (
+	compressTrailerMagic = []byte("SFTPGOZ")
+	compressAlgos        = []string{CompressAlgoGzip, CompressAlgoZstd}
+)
+
+// CompressFsConfig defines the configuration to store files compressed at rest
+type CompressFsConfig struct {
+	// Compression algorithm, supported values: "gzip", "zstd".
+	// Empty means compression disabled
+	Algo string `json:"algo,omitempty"`
+	// Files with these extensions are stored uncompressed, for example
+	// already compressed formats. Extensions are case insensitive, e.g. ".zip"
+	SkipExtensions []string `json:"skip_extensions,omitempty"`
+}
+
+// IsEnabled returns true if compression is enabled
+func (c *CompressFsConfig) IsEnabled() bool {
+	return c.Algo != ""
+}
+
+func (c *CompressFsConfig) isEqual(other CompressFsConfig) bool {
+	if c.Algo != other.Algo {
+		return false
+	}
+	if len(c.SkipExtensions) != len(other.SkipExtensions) {
+		return false
+	}
+	for _, ext := range c.SkipExtensions {
+		if !util.Contains(other.SkipExtensions, ext) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *CompressFsConfig) getACopy() CompressFsConfig {
+	extensions := make([]string, len(c.SkipExtensions))
+	copy(extensions, c.SkipExtensions)
+
+	return CompressFsConfig{
+		Algo:           c.Algo,
+		SkipExtensions: extensions,
+	}
+}
+
+func (c *CompressFsConfig) validate(provider sdk.FilesystemProvider) error {
+	if !c.IsEnabled() {
+		c.SkipExtensions = nil
+		return nil
+	}
+	if !util.Contains(compressAlgos, c.Algo) {
+		return util.NewValidationError(fmt.Sprintf("invalid compression algorithm %q", c.Algo))
+	}
+	switch provider {
+	case sdk.CryptedFilesystemProvider, UnionFilesystemProvider:
+		return util.NewI18nError(
+			util.NewValidationError("compression is not supported for local encrypted and union filesystems"),
+			util.I18nErrorCompressionInvalid,
+		)
+	}
+	var extensions []string
+	for _, ext := range c.SkipExtensions {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" || ext == "." {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions = append(extensions, ext)
+	}
+	c.SkipExtensions = util.RemoveDuplicates(extensions, false)
+	return nil
+}
+
+// compressTrailer is appended to the compressed files
+type compressTrailer struct {
+	size int64
+	algo byte
+}
+
+func (t *compressTrailer) encode() []byte {
+	b := make([]byte, 0, compressTrailerSize)
+	b = binary.BigEndian.AppendUint64(b, uint64(t.size))
+	b = append(b, t.algo)
+	return append(b, compressTrailerMagic...)
+}
+
+func decodeCompressTrailer(b []byte) (compressTrailer, bool) {
+	var trailer compressTrailer
+	if int64(len(b)) != compressTrailerSize || !bytes.Equal(b[9:], compressTrailerMagic) {
+		return trailer, false
+	}
+	trailer.size = int64(binary.BigEndian.Uint64(b))
+	trailer.algo = b[8]
+	if trailer.size < 0 || (trailer.algo != compressAlgoGzipID && trailer.algo != compressAlgoZstdID) {
+		return trailer, false
+	}
+	return trailer, true
+}
+
+type compressSizeKey struct {
+	name    string
+	size    int64
+	modTime int64
+}
+
+// compressedFileInfo reports the uncompressed size for a compressed file
+type compressedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+// Size returns the uncompressed size
+func (fi *compressedFileInfo) Size() int64 {
+	return fi.size
+}
+
+// CompressFs is a Fs wrapper that stores files compressed at rest and
+// presents the uncompressed contents and sizes to the clients.
+// Files without the compression trailer, for example files uploaded before
+// enabling compression, are served unchanged
+type CompressFs struct {
+	Fs
+	config       CompressFsConfig
+	localTempDir string
+	mu           sync.Mutex
+	// uncompressed sizes for the recently checked files
+	sizes map[compressSizeKey]compressTrailer
+}
+
+// NewCompressFs wraps the specified filesystem so that the files are stored
+// compressed. The filesystem is returned unchanged if compression is disabled
+func NewCompressFs(fs Fs, config CompressFsConfig) Fs {
+	if !config.IsEnabled() {
+		return fs
+	}
+	return &CompressFs{
+		Fs:           fs,
+		config:       config,
+		localTempDir: getLocalTempDir(),
+		sizes:        make(map[compressSizeKey]compressTrailer),
+	}
+}
+
+// Name returns the name for the Fs implementation
+func (fs *CompressFs) Name() string {
+	return fmt.Sprintf("%s %s", compressFsName, fs.Fs.Name())
+}
+
+// Stat returns a FileInfo describing the named file
+func (fs *CompressFs) Stat(name string) (os.FileInfo, error) {
+	info, err := fs.Fs.Stat(name)
+	if err != nil {
+		return info, err
+	}
+	return fs.convertFileInfo(name, info), nil
+}
+
+// Lstat returns a FileInfo describing the named file
+func (fs *CompressFs) Lstat(name string) (os.FileInfo, error) {
+	info, err := fs.Fs.Lstat(name)
+	if err != nil {
+		return info, err
+	}
+	return fs.convertFileInfo(name, info), nil
+}
+
+// Open opens the named file for reading
+func (fs *CompressFs) Open(name string, offset int64) (File, PipeReader, func(), error) {
+	info, err := fs.Fs.Stat(name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	trailer, ok := fs.getTrailer(name, info)
+	if !ok {
+		return fs.Fs.Open(name, offset)
+	}
+	f, src, cancelFn, err := fs.Fs.Open(name, 0)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var reader io.ReadCloser = src
+	if f != nil {
+		reader = f
+	}
+	r, w, err := pipeat.PipeInDir(fs.localTempDir)
+	if err != nil {
+		reader.Close()
+		if cancelFn != nil {
+			cancelFn()
+		}
+		return nil, nil, nil, err
+	}
+	p := NewPipeReader(r)
+
+	go func() {
+		n, err := fs.decompress(w, io.LimitReader(reader, info.Size()-compressTrailerSize), trailer.algo, offset)
+		reader.Close()
+		w.CloseWithError(err) //nolint:errcheck
+		fsLog(fs, logger.LevelDebug, "download completed, path: %q size: %v, err: %v", name, n, err)
+	}()
+
+	return nil, p, cancelFn, nil
+}
+
+// Create creates or opens the named file for writing
+func (fs *CompressFs) Create(name string, flag, checks int) (File, PipeWriter, func(), error) {
+	if fs.isSkipped(name) {
+		return fs.Fs.Create(name, flag, checks)
+	}
+	f, w, cancelFn, err := fs.Fs.Create(name, flag, checks)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var dst io.WriteCloser = w
+	if f != nil {
+		dst = f
+	}
+	r, pw, err := pipeat.PipeInDir(fs.localTempDir)
+	if err != nil {
+		if cancelFn != nil {
+			cancelFn()
+		}
+		dst.Close()
+		return nil, nil, nil, err
+	}
+	p := NewPipeWriter(pw)
+
+	go func() {
+		n, err := fs.compress(dst, r)
+		if err != nil && cancelFn != nil {
+			cancelFn()
+		}
+		errClose := dst.Close()
+		if err == nil && errClose != nil {
+			err = errClose
+		}
+		r.CloseWithError(err) //nolint:errcheck
+		p.Done(err)
+		fsLog(fs, logger.LevelDebug, "upload completed, path: %q, readed bytes: %v, err: %v", name, n, err)
+	}()
+
+	return nil, p, cancelFn, nil
+}
+
+// Truncate changes the size of the named file
+func (*CompressFs) Truncate(_ string, _ int64) error {
+	return ErrVfsUnsupported
+}
+
+// ReadDir reads the directory named by dirname and returns
+// a list of directory entries.
+func (fs *CompressFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	list, err := fs.Fs.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]os.FileInfo, 0, len(list))
+	for _, info := range list {
+		result = append(result, fs.convertFileInfo(fs.Join(dirname, info.Name()), info))
+	}
+	return result, nil
+}
+
+// IsUploadResumeSupported returns false, compressed files cannot be appended
+func (*CompressFs) IsUploadResumeSupported() bool {
+	return false
+}
+
+// IsConditionalUploadResumeSupported returns if resuming uploads is supported
+// for the specified size
+func (*CompressFs) IsConditionalUploadResumeSupported(_ int64) bool {
+	return false
+}
+
+// ScanRootDirContents returns the number of files contained in the root
+// directory and their uncompressed size
+func (fs *CompressFs) ScanRootDirContents() (int, int64, error) {
+	root, err := fs.ResolvePath("/")
+	if err != nil {
+		return 0, 0, err
+	}
+	return fs.GetDirSize(root)
+}
+
+// GetDirSize returns the number of files and the uncompressed size for a folder
+// including any subfolders
+func (fs *CompressFs) GetDirSize(dirname string) (int, int64, error) {
+	numFiles := 0
+	size := int64(0)
+	err := fs.Walk(dirname, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info != nil && info.Mode().IsRegular() {
+			size += info.Size()
+			numFiles++
+			if numFiles%1000 == 0 {
+				fsLog(fs, logger.LevelDebug, "dirname %q scan in progress, files: %d, size: %d", dirname, numFiles, size)
+			}
+		}
+		return nil
+	})
+	return numFiles, size, err
+}
+
+// Walk walks the file tree rooted at root, calling walkFn for each file or
+// directory in the tree, including root. The uncompressed sizes are reported
+func (fs *CompressFs) Walk(root string, walkFn filepath.WalkFunc) error {
+	return fs.Fs.Walk(root, func(walkedPath string, info os.FileInfo, err error) error {
+		if err == nil && info != nil {
+			info = fs.convertFileInfo(walkedPath, info)
+		}
+		return walkFn(walkedPath, info, err)
+	})
+}
+
+// GetMimeType returns the content type
+func (fs *CompressFs) GetMimeType(name string) (string, error) {
+	info, err := fs.Fs.Stat(name)
+	if err != nil {
+		return "", err
+	}
+	trailer, ok := fs.getTrailer(name, info)
+	if !ok {
+		return fs.Fs.GetMimeType(name)
+	}
+	f, src, cancelFn, err := fs.Fs.Open(name, 0)
+	if err != nil {
+		return "", err
+	}
+	if cancelFn != nil {
+		defer cancelFn()
+	}
+	var reader io.ReadCloser = src
+	if f != nil {
+		reader = f
+	}
+	defer reader.Close()
+
+	dec, err := newDecompressReader(trailer.algo, io.LimitReader(reader, info.Size()-compressTrailerSize))
+	if err != nil {
+		return "", err
+	}
+	defer dec.Close()
+
+	var buf [512]byte
+	n, err := io.ReadFull(dec, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// RealPath implements the FsRealPather interface
+func (fs *CompressFs) RealPath(p string) (string, error) {
+	if realPather, ok := fs.Fs.(FsRealPather); ok {
+		return realPather.RealPath(p)
+	}
+	return fs.GetRelativePath(p), nil
+}
+
+func (fs *CompressFs) isSkipped(name string) bool {
+	if len(fs.config.SkipExtensions) == 0 {
+		return false
+	}
+	return util.Contains(fs.config.SkipExtensions, strings.ToLower(filepath.Ext(name)))
+}
+
+func (fs *CompressFs) convertFileInfo(name string, info os.FileInfo) os.FileInfo {
+	trailer, ok := fs.getTrailer(name, info)
+	if !ok {
+		return info
+	}
+	return &compressedFileInfo{
+		FileInfo: info,
+		size:     trailer.size,
+	}
+}
+
+// getTrailer returns the compression trailer for the specified file,
+// false means that the file is not compressed
+func (fs *CompressFs) getTrailer(name string, info os.FileInfo) (compressTrailer, bool) {
+	if !info.Mode().IsRegular() || info.Size() < compressTrailerSize {
+		return compressTrailer{}, false
+	}
+	key := compressSizeKey{
+		name:    name,
+		size:    info.Size(),
+		modTime: info.ModTime().UnixNano(),
+	}
+	fs.mu.Lock()
+	trailer, ok := fs.sizes[key]
+	fs.mu.Unlock()
+	if ok {
+		return trailer, trailer.algo != 0
+	}
+
+	trailer, ok, err := fs.readTrailer(name, info.Size())
+	if err != nil {
+		fsLog(fs, logger.LevelWarn, "unable to read the compression trailer for %q: %v", name, err)
+		return compressTrailer{}, false
+	}
+	if !ok {
+		trailer = compressTrailer{}
+	}
+	fs.mu.Lock()
+	if len(fs.sizes) >= compressSizeCacheMaxEntries {
+		clear(fs.sizes)
+	}
+	fs.sizes[key] = trailer
+	fs.mu.Unlock()
+
+	return trailer, ok
+}
+
+func (fs *CompressFs) readTrailer(name string, size int64) (compressTrailer, bool, error) {
+	f, src, cancelFn, err := fs.Fs.Open(name, size-compressTrailerSize)
+	if err != nil {
+		return compressTrailer{}, false, err
+	}
+	if cancelFn != nil {
+		defer cancelFn()
+	}
+	var reader io.ReadCloser = src
+	if f != nil {
+		reader = f
+	}
+	defer reader.Close()
+
+	buf := make([]byte, compressTrailerSize)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return compressTrailer{}, false, err
+	}
+	trailer, ok := decodeCompressTrailer(buf)
+	return trailer, ok, nil
+}
+
+func (fs *CompressFs) compress(dst io.Writer, src io.Reader) (int64, error) {
+	trailer := compressTrailer{}
+	var enc io.WriteCloser
+	switch fs.config.Algo {
+	case CompressAlgoZstd:
+		zw, err := zstd.NewWriter(dst)
+		if err != nil {
+			return 0, err
+		}
+		enc = zw
+		trailer.algo = compressAlgoZstdID
+	default:
+		enc = gzip.NewWriter(dst)
+		trailer.algo = compressAlgoGzipID
+	}
+	n, err := doCopy(enc, src, nil)
+	errClose := enc.Close()
+	if err == nil && errClose != nil {
+		err = errClose
+	}
+	if err != nil {
+		return n, err
+	}
+	trailer.size = n
+	_, err = dst.Write(trailer.encode())
+	return n, err
+}
+
+func (*CompressFs) decompress(dst io.Writer, src io.Reader, algo byte, offset int64) (int64, error) {
+	dec, err := newDecompressReader(algo, src)
+	if err != nil {
+		return 0, err
+	}
+	defer dec.Close()
+
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, dec, offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return 0, nil
+			}
+			return 0, err
+		}
+	}
+	return doCopy(dst, dec, nil)
+}
+
+func newDecompressReader(algo byte, src io.Reader) (io.ReadCloser, error) {
+	switch algo {
+	case compressAlgoZstdID:
+		dec, err := zstd.NewReader(src, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	case compressAlgoGzipID:
+		return gzip.NewReader(src)
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %d", algo)
+	}
+}
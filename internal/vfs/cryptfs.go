@@ -23,6 +23,8 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/eikenb/pipeat"
 	"github.com/minio/sio"
@@ -37,6 +39,8 @@ const (
 	version10     byte  = 0x10
 	nonceV10Size  int   = 32
 	headerV10Size int64 = 33 // 1 (version byte) + 32 (nonce size)
+	// prefix for the temporary files used while re-encrypting
+	reencryptTempPrefix = ".sftpgo-reencrypt."
 )
 
 // CryptFs is a Fs implementation that allows to encrypts/decrypts local files
@@ -44,6 +48,9 @@ type CryptFs struct {
 	*OsFs
 	localTempDir string
 	masterKey    []byte
+	// master keys derived from the old passphrases, used to decrypt
+	// the files not yet re-encrypted with the current master key
+	oldMasterKeys [][]byte
 }
 
 // NewCryptFs returns a CryptFs object
@@ -54,6 +61,13 @@ func NewCryptFs(connectionID, rootDir, mountPath string, config CryptFsConfig) (
 	if err := config.Passphrase.TryDecrypt(); err != nil {
 		return nil, err
 	}
+	var oldMasterKeys [][]byte
+	for _, passphrase := range config.OldPassphrases {
+		if err := passphrase.TryDecrypt(); err != nil {
+			return nil, err
+		}
+		oldMasterKeys = append(oldMasterKeys, []byte(passphrase.GetPayload()))
+	}
 	fs := &CryptFs{
 		OsFs: &OsFs{
 			name:            cryptFsName,
@@ -63,7 +77,8 @@ func NewCryptFs(connectionID, rootDir, mountPath string, config CryptFsConfig) (
 			readBufferSize:  config.OSFsConfig.ReadBufferSize * 1024 * 1024,
 			writeBufferSize: config.OSFsConfig.WriteBufferSize * 1024 * 1024,
 		},
-		masterKey: []byte(config.Passphrase.GetPayload()),
+		masterKey:     []byte(config.Passphrase.GetPayload()),
+		oldMasterKeys: oldMasterKeys,
 	}
 	if tempPath == "" {
 		fs.localTempDir = rootDir
@@ -306,24 +321,160 @@ func (fs *CryptFs) ConvertFileInfo(info os.FileInfo) os.FileInfo {
 }
 
 func (fs *CryptFs) getFileAndEncryptionKey(name string) (*os.File, [32]byte, error) {
+	f, key, _, err := fs.getFileAndKey(name)
+	return f, key, err
+}
+
+// getFileAndKey returns the file, its encryption key and the index of the master
+// key used to derive it: 0 is the current master key, the old ones follow
+func (fs *CryptFs) getFileAndKey(name string) (*os.File, [32]byte, int, error) {
 	var key [32]byte
 	f, err := os.Open(name)
 	if err != nil {
-		return nil, key, err
+		return nil, key, 0, err
 	}
 	header := encryptedFileHeader{}
 	err = header.Load(f)
 	if err != nil {
 		f.Close()
-		return nil, key, err
+		return nil, key, 0, err
 	}
-	kdf := hkdf.New(sha256.New, fs.masterKey, header.nonce, nil)
-	_, err = io.ReadFull(kdf, key[:])
+	masterKeys := append([][]byte{fs.masterKey}, fs.oldMasterKeys...)
+	for idx, masterKey := range masterKeys {
+		key, err = deriveEncryptionKey(masterKey, header.nonce)
+		if err != nil {
+			f.Close()
+			return nil, key, 0, err
+		}
+		if len(masterKeys) == 1 || fs.isValidKey(f, key) {
+			return f, key, idx, nil
+		}
+	}
+	// no master key can decrypt the file, the error will be returned while reading
+	key, err = deriveEncryptionKey(fs.masterKey, header.nonce)
 	if err != nil {
 		f.Close()
-		return nil, key, err
+		return nil, key, 0, err
 	}
-	return f, key, err
+	return f, key, 0, nil
+}
+
+// isValidKey returns true if the first package of the specified file
+// can be authenticated and decrypted using the specified key
+func (fs *CryptFs) isValidKey(f *os.File, key [32]byte) bool {
+	readerAt, err := sio.DecryptReaderAt(&cryptedFileWrapper{File: f}, fs.getSIOConfig(key))
+	if err != nil {
+		return false
+	}
+	var buf [1]byte
+	_, err = readerAt.ReadAt(buf[:], 0)
+	return err == nil || err == io.EOF
+}
+
+// ReencryptFiles re-encrypts, using the current passphrase, the files inside the
+// specified directory still encrypted with an old passphrase. The specified callback
+// is executed with the size of each re-encrypted file
+func (fs *CryptFs) ReencryptFiles(dirname string, onFile func(size int64)) error {
+	if len(fs.oldMasterKeys) == 0 {
+		return nil
+	}
+	return filepath.Walk(dirname, func(walkedPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), reencryptTempPrefix) {
+			return nil
+		}
+		reencrypted, err := fs.reencryptFile(walkedPath, info)
+		if err != nil {
+			return fmt.Errorf("unable to re-encrypt %q: %w", walkedPath, err)
+		}
+		if reencrypted && onFile != nil {
+			onFile(info.Size())
+		}
+		return nil
+	})
+}
+
+func (fs *CryptFs) reencryptFile(name string, info os.FileInfo) (bool, error) {
+	f, key, keyIdx, err := fs.getFileAndKey(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	// 0 means the current master key or a file that no master key can decrypt
+	if keyIdx == 0 {
+		return false, nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), reencryptTempPrefix)
+	if err != nil {
+		return false, err
+	}
+	tmpName := tmp.Name()
+	err = fs.reencrypt(tmp, f, key)
+	errClose := tmp.Close()
+	if err == nil && errClose != nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return false, err
+	}
+	// the file could be overwritten while re-encrypting, an overwritten
+	// file is already encrypted with the current passphrase
+	current, err := os.Stat(name)
+	if err != nil || current.Size() != info.Size() || !current.ModTime().Equal(info.ModTime()) {
+		os.Remove(tmpName)
+		fsLog(fs, logger.LevelDebug, "file %q changed while re-encrypting, skipped", name)
+		return false, nil
+	}
+	if err := os.Chmod(tmpName, info.Mode()); err != nil {
+		os.Remove(tmpName)
+		return false, err
+	}
+	if err := os.Chtimes(tmpName, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmpName)
+		return false, err
+	}
+	if err := os.Rename(tmpName, name); err != nil {
+		os.Remove(tmpName)
+		return false, err
+	}
+	fsLog(fs, logger.LevelDebug, "file %q re-encrypted using the current passphrase", name)
+	return true, nil
+}
+
+// reencrypt decrypts src, positioned after the header, using the specified
+// key and writes it to dst encrypted using the current master key
+func (fs *CryptFs) reencrypt(dst *os.File, src *os.File, key [32]byte) error {
+	header := encryptedFileHeader{
+		version: version10,
+		nonce:   make([]byte, 32),
+	}
+	if _, err := io.ReadFull(rand.Reader, header.nonce); err != nil {
+		return err
+	}
+	newKey, err := deriveEncryptionKey(fs.masterKey, header.nonce)
+	if err != nil {
+		return err
+	}
+	if err := header.Store(dst); err != nil {
+		return err
+	}
+	decReader, err := sio.DecryptReader(src, fs.getSIOConfig(key))
+	if err != nil {
+		return err
+	}
+	_, err = sio.Encrypt(dst, decReader, fs.getSIOConfig(newKey))
+	return err
+}
+
+func deriveEncryptionKey(masterKey, nonce []byte) ([32]byte, error) {
+	var key [32]byte
+	kdf := hkdf.New(sha256.New, masterKey, nonce, nil)
+	_, err := io.ReadFull(kdf, key[:])
+	return key, err
 }
 
 func (*CryptFs) encryptWrapper(dst io.Writer, src io.Reader, config sio.Config) (int64, error) {
@@ -0,0 +1,333 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/xid"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	dedupLogSender = "dedup"
+)
+
+// dedupStore is the content-addressed store for the local filesystem, nil means disabled
+var dedupStore *localDedupStore
+
+// DedupStatus defines the status for the content-addressed deduplication store
+type DedupStatus struct {
+	IsActive bool `json:"is_active"`
+	// number of unique contents stored
+	Contents int64 `json:"contents"`
+	// number of files referencing the stored contents
+	References int64 `json:"references"`
+	// total size of the referencing files
+	LogicalSize int64 `json:"logical_size"`
+	// disk space used by the stored contents
+	PhysicalSize int64 `json:"physical_size"`
+	// disk space saved by the deduplication
+	SavedSize int64 `json:"saved_size"`
+	// last scan of the store as unix timestamp in milliseconds
+	LastScan int64 `json:"last_scan"`
+}
+
+// GetSavedSizeAsString returns the saved disk space in a human readable format
+func (s *DedupStatus) GetSavedSizeAsString() string {
+	return util.ByteCountIEC(s.SavedSize)
+}
+
+// GetPhysicalSizeAsString returns the disk space used by the stored contents
+// in a human readable format
+func (s *DedupStatus) GetPhysicalSizeAsString() string {
+	return util.ByteCountIEC(s.PhysicalSize)
+}
+
+// SetDedupStore configures the content-addressed store for the local filesystem.
+// The content of the uploaded files is hashed and identical files are replaced
+// with hard links to a single copy stored inside the specified directory.
+// Files smaller than minFileSize, as KB, are not deduplicated.
+// The store is disabled if the path is empty
+func SetDedupStore(storePath string, minFileSize int64) error {
+	dedupStore = nil
+	if storePath == "" {
+		return nil
+	}
+	if runtime.GOOS == "windows" {
+		return errors.New("deduplication is not supported on Windows")
+	}
+	if !filepath.IsAbs(storePath) {
+		return fmt.Errorf("invalid deduplication store path %q, it must be an absolute path", storePath)
+	}
+	if minFileSize < 0 {
+		return fmt.Errorf("invalid deduplication min file size %d", minFileSize)
+	}
+	store := &localDedupStore{
+		path:        filepath.Clean(storePath),
+		minFileSize: minFileSize * 1024,
+	}
+	if err := os.MkdirAll(store.path, 0700); err != nil {
+		return fmt.Errorf("unable to create the deduplication store: %w", err)
+	}
+	dedupStore = store
+	go store.scan()
+	return nil
+}
+
+// GetDedupStatus returns the status for the deduplication store
+func GetDedupStatus() DedupStatus {
+	store := dedupStore
+	if store == nil {
+		return DedupStatus{}
+	}
+	return store.getStatus()
+}
+
+// CleanupDedupStore removes the stored contents no longer referenced
+// and updates the deduplication statistics
+func CleanupDedupStore() {
+	if store := dedupStore; store != nil {
+		store.scan()
+	}
+}
+
+// localDedupStore stores the file contents as hard links named
+// after the SHA256 hash of the content
+type localDedupStore struct {
+	path        string
+	minFileSize int64
+	mu          sync.Mutex
+	scanning    atomic.Bool
+	statusMu    sync.RWMutex
+	status      DedupStatus
+}
+
+func (s *localDedupStore) getStatus() DedupStatus {
+	s.statusMu.RLock()
+	defer s.statusMu.RUnlock()
+
+	status := s.status
+	status.IsActive = true
+	return status
+}
+
+func (s *localDedupStore) getContentPath(hash string) string {
+	return filepath.Join(s.path, hash[:2], hash)
+}
+
+// scan removes the stored contents not referenced by any file and
+// updates the statistics. A content is referenced if it has more
+// than one hard link
+func (s *localDedupStore) scan() {
+	if !s.scanning.CompareAndSwap(false, true) {
+		return
+	}
+	defer s.scanning.Store(false)
+
+	var status DedupStatus
+	var removed int
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := filepath.Walk(s.path, func(walkedPath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		links, _, _, ok := getLinksInfo(info)
+		if !ok {
+			return nil
+		}
+		if links <= 1 {
+			if err := os.Remove(walkedPath); err != nil {
+				logger.Warn(dedupLogSender, "", "unable to remove unreferenced content %q: %v", walkedPath, err)
+			} else {
+				removed++
+			}
+			return nil
+		}
+		status.Contents++
+		status.References += int64(links - 1)
+		status.PhysicalSize += info.Size()
+		status.LogicalSize += info.Size() * int64(links-1)
+		return nil
+	})
+	if err != nil {
+		logger.Error(dedupLogSender, "", "unable to scan the deduplication store %q: %v", s.path, err)
+		return
+	}
+	status.SavedSize = status.LogicalSize - status.PhysicalSize
+	status.LastScan = util.GetTimeAsMsSinceEpoch(time.Now())
+
+	s.statusMu.Lock()
+	s.status = status
+	s.statusMu.Unlock()
+
+	logger.Debug(dedupLogSender, "", "deduplication store scanned, contents: %d, references: %d, saved size: %d, "+
+		"removed contents: %d", status.Contents, status.References, status.SavedSize, removed)
+}
+
+// add replaces the specified file with a hard link to the stored content
+// with the same hash or adds the file to the store if the content is new
+func (s *localDedupStore) add(name string) error {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Size() == 0 || info.Size() < s.minFileSize {
+		return nil
+	}
+	hash, err := getFileSHA256(name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the file could be modified while we compute the hash
+	current, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if current.Size() != info.Size() || !current.ModTime().Equal(info.ModTime()) {
+		return fmt.Errorf("the file %q was modified while computing its hash", name)
+	}
+	if os.SameFile(info, current) {
+		if links, _, _, ok := getLinksInfo(current); ok && links > 1 {
+			// already deduplicated or hard linked elsewhere, for example in a snapshot
+			return nil
+		}
+	}
+	contentPath := s.getContentPath(hash)
+	stored, err := os.Lstat(contentPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(contentPath), 0700); err != nil {
+			return err
+		}
+		return os.Link(name, contentPath)
+	}
+	if os.SameFile(stored, current) {
+		return nil
+	}
+	if stored.Size() != current.Size() {
+		return fmt.Errorf("size mismatch for stored content %q, expected: %d, actual: %d",
+			contentPath, current.Size(), stored.Size())
+	}
+	_, storedUID, storedGID, _ := getLinksInfo(stored)
+	_, uid, gid, _ := getLinksInfo(current)
+	if storedUID != uid || storedGID != gid {
+		// hard links share the owner, we cannot change it for the referencing files
+		return nil
+	}
+	tempPath := filepath.Join(filepath.Dir(name), ".sftpgo-dedup."+xid.New().String())
+	if err := os.Link(contentPath, tempPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tempPath, name); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+func getFileSHA256(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isDedupLink returns true if the specified file shares its content
+// with other files, the content must be detached before modifying it
+func isDedupLink(name string) bool {
+	if dedupStore == nil {
+		return false
+	}
+	info, err := os.Lstat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	links, _, _, ok := getLinksInfo(info)
+	return ok && links > 1
+}
+
+// detachDedupLink replaces the specified file with a copy of its content,
+// so it can be modified without affecting the files with the same content
+func detachDedupLink(name string) error {
+	if !isDedupLink(name) {
+		return nil
+	}
+	info, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tempPath := filepath.Join(filepath.Dir(name), ".sftpgo-dedup."+xid.New().String())
+	dst, err := os.OpenFile(tempPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	if errClose := dst.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chtimes(tempPath, info.ModTime(), info.ModTime())
+	}
+	if err == nil {
+		if _, uid, gid, ok := getLinksInfo(info); ok {
+			os.Lchown(tempPath, int(uid), int(gid)) //nolint:errcheck
+		}
+		err = os.Rename(tempPath, name)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	logger.Debug(dedupLogSender, "", "deduplicated file %q detached", name)
+	return nil
+}
@@ -26,6 +26,7 @@ type FileInfo struct {
 	sizeInBytes int64
 	modTime     time.Time
 	mode        os.FileMode
+	accessTier  string
 }
 
 // NewFileInfo creates file info.
@@ -77,6 +78,16 @@ func (fi *FileInfo) SetMode(mode os.FileMode) {
 	fi.mode = mode
 }
 
+// SetAccessTier sets the storage access tier, for example Hot, Cool or Archive
+func (fi *FileInfo) SetAccessTier(tier string) {
+	fi.accessTier = tier
+}
+
+// AccessTier returns the storage access tier, empty if unknown or not supported
+func (fi *FileInfo) AccessTier() string {
+	return fi.accessTier
+}
+
 // Sys provides the underlying data source (can return nil)
 func (fi *FileInfo) Sys() any {
 	return nil
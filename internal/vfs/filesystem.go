@@ -33,6 +33,8 @@ type Filesystem struct {
 	CryptConfig    CryptFsConfig          `json:"cryptconfig,omitempty"`
 	SFTPConfig     SFTPFsConfig           `json:"sftpconfig,omitempty"`
 	HTTPConfig     HTTPFsConfig           `json:"httpconfig,omitempty"`
+	UnionConfig    UnionFsConfig          `json:"unionconfig,omitempty"`
+	CompressConfig CompressFsConfig       `json:"compressconfig,omitempty"`
 }
 
 // SetEmptySecrets sets the secrets to empty
@@ -47,6 +49,9 @@ func (f *Filesystem) SetEmptySecrets() {
 	f.SFTPConfig.KeyPassphrase = kms.NewEmptySecret()
 	f.HTTPConfig.Password = kms.NewEmptySecret()
 	f.HTTPConfig.APIKey = kms.NewEmptySecret()
+	for idx := range f.UnionConfig.Members {
+		f.UnionConfig.Members[idx].FsConfig.SetEmptySecrets()
+	}
 }
 
 // SetEmptySecretsIfNil sets the secrets to empty if nil
@@ -81,6 +86,40 @@ func (f *Filesystem) SetEmptySecretsIfNil() {
 	if f.HTTPConfig.APIKey == nil {
 		f.HTTPConfig.APIKey = kms.NewEmptySecret()
 	}
+	for idx := range f.UnionConfig.Members {
+		f.UnionConfig.Members[idx].FsConfig.SetEmptySecretsIfNil()
+	}
+}
+
+// GetSecrets returns the non nil secrets for the configured provider
+func (f *Filesystem) GetSecrets() []*kms.Secret {
+	var secrets []*kms.Secret
+	switch f.Provider {
+	case sdk.S3FilesystemProvider:
+		secrets = append(secrets, f.S3Config.AccessSecret)
+	case sdk.GCSFilesystemProvider:
+		secrets = append(secrets, f.GCSConfig.Credentials)
+	case sdk.AzureBlobFilesystemProvider:
+		secrets = append(secrets, f.AzBlobConfig.AccountKey, f.AzBlobConfig.SASURL)
+	case sdk.CryptedFilesystemProvider:
+		secrets = append(secrets, f.CryptConfig.Passphrase)
+		secrets = append(secrets, f.CryptConfig.OldPassphrases...)
+	case sdk.SFTPFilesystemProvider:
+		secrets = append(secrets, f.SFTPConfig.Password, f.SFTPConfig.PrivateKey, f.SFTPConfig.KeyPassphrase)
+	case sdk.HTTPFilesystemProvider:
+		secrets = append(secrets, f.HTTPConfig.Password, f.HTTPConfig.APIKey)
+	case UnionFilesystemProvider:
+		for idx := range f.UnionConfig.Members {
+			secrets = append(secrets, f.UnionConfig.Members[idx].FsConfig.GetSecrets()...)
+		}
+	}
+	result := make([]*kms.Secret, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret != nil {
+			result = append(result, secret)
+		}
+	}
+	return result
 }
 
 // SetNilSecretsIfEmpty set the secrets to nil if empty.
@@ -104,6 +143,9 @@ func (f *Filesystem) SetNilSecretsIfEmpty() {
 	}
 	f.SFTPConfig.setNilSecretsIfEmpty()
 	f.HTTPConfig.setNilSecretsIfEmpty()
+	for idx := range f.UnionConfig.Members {
+		f.UnionConfig.Members[idx].FsConfig.SetNilSecretsIfEmpty()
+	}
 }
 
 // IsEqual returns true if the fs is equal to other
@@ -111,6 +153,9 @@ func (f *Filesystem) IsEqual(other Filesystem) bool {
 	if f.Provider != other.Provider {
 		return false
 	}
+	if !f.CompressConfig.isEqual(other.CompressConfig) {
+		return false
+	}
 	switch f.Provider {
 	case sdk.S3FilesystemProvider:
 		return f.S3Config.isEqual(other.S3Config)
@@ -124,6 +169,8 @@ func (f *Filesystem) IsEqual(other Filesystem) bool {
 		return f.SFTPConfig.isEqual(other.SFTPConfig)
 	case sdk.HTTPFilesystemProvider:
 		return f.HTTPConfig.isEqual(other.HTTPConfig)
+	case UnionFilesystemProvider:
+		return f.UnionConfig.isEqual(other.UnionConfig)
 	default:
 		return true
 	}
@@ -134,6 +181,10 @@ func (f *Filesystem) IsSameResource(other Filesystem) bool {
 	if f.Provider != other.Provider {
 		return false
 	}
+	// files cannot be moved as is between compressed and uncompressed filesystems
+	if f.CompressConfig.IsEnabled() != other.CompressConfig.IsEnabled() {
+		return false
+	}
 	switch f.Provider {
 	case sdk.S3FilesystemProvider:
 		return f.S3Config.isSameResource(other.S3Config)
@@ -147,6 +198,8 @@ func (f *Filesystem) IsSameResource(other Filesystem) bool {
 		return f.SFTPConfig.isSameResource(other.SFTPConfig)
 	case sdk.HTTPFilesystemProvider:
 		return f.HTTPConfig.isSameResource(other.HTTPConfig)
+	case UnionFilesystemProvider:
+		return f.UnionConfig.isSameResource(other.UnionConfig)
 	default:
 		return true
 	}
@@ -165,6 +218,9 @@ func (f *Filesystem) GetPathSeparator() string {
 // Validate verifies the FsConfig matching the configured provider and sets all other
 // Filesystem.*Config to their zero value if successful
 func (f *Filesystem) Validate(additionalData string) error {
+	if err := f.CompressConfig.validate(f.Provider); err != nil {
+		return err
+	}
 	switch f.Provider {
 	case sdk.S3FilesystemProvider:
 		if err := f.S3Config.ValidateAndEncryptCredentials(additionalData); err != nil {
@@ -176,6 +232,7 @@ func (f *Filesystem) Validate(additionalData string) error {
 		f.CryptConfig = CryptFsConfig{}
 		f.SFTPConfig = SFTPFsConfig{}
 		f.HTTPConfig = HTTPFsConfig{}
+		f.UnionConfig = UnionFsConfig{}
 		return nil
 	case sdk.GCSFilesystemProvider:
 		if err := f.GCSConfig.ValidateAndEncryptCredentials(additionalData); err != nil {
@@ -187,6 +244,7 @@ func (f *Filesystem) Validate(additionalData string) error {
 		f.CryptConfig = CryptFsConfig{}
 		f.SFTPConfig = SFTPFsConfig{}
 		f.HTTPConfig = HTTPFsConfig{}
+		f.UnionConfig = UnionFsConfig{}
 		return nil
 	case sdk.AzureBlobFilesystemProvider:
 		if err := f.AzBlobConfig.ValidateAndEncryptCredentials(additionalData); err != nil {
@@ -198,6 +256,7 @@ func (f *Filesystem) Validate(additionalData string) error {
 		f.CryptConfig = CryptFsConfig{}
 		f.SFTPConfig = SFTPFsConfig{}
 		f.HTTPConfig = HTTPFsConfig{}
+		f.UnionConfig = UnionFsConfig{}
 		return nil
 	case sdk.CryptedFilesystemProvider:
 		if err := f.CryptConfig.ValidateAndEncryptCredentials(additionalData); err != nil {
@@ -209,6 +268,7 @@ func (f *Filesystem) Validate(additionalData string) error {
 		f.AzBlobConfig = AzBlobFsConfig{}
 		f.SFTPConfig = SFTPFsConfig{}
 		f.HTTPConfig = HTTPFsConfig{}
+		f.UnionConfig = UnionFsConfig{}
 		return validateOSFsConfig(&f.CryptConfig.OSFsConfig)
 	case sdk.SFTPFilesystemProvider:
 		if err := f.SFTPConfig.ValidateAndEncryptCredentials(additionalData); err != nil {
@@ -220,6 +280,7 @@ func (f *Filesystem) Validate(additionalData string) error {
 		f.AzBlobConfig = AzBlobFsConfig{}
 		f.CryptConfig = CryptFsConfig{}
 		f.HTTPConfig = HTTPFsConfig{}
+		f.UnionConfig = UnionFsConfig{}
 		return nil
 	case sdk.HTTPFilesystemProvider:
 		if err := f.HTTPConfig.ValidateAndEncryptCredentials(additionalData); err != nil {
@@ -231,6 +292,19 @@ func (f *Filesystem) Validate(additionalData string) error {
 		f.AzBlobConfig = AzBlobFsConfig{}
 		f.CryptConfig = CryptFsConfig{}
 		f.SFTPConfig = SFTPFsConfig{}
+		f.UnionConfig = UnionFsConfig{}
+		return nil
+	case UnionFilesystemProvider:
+		if err := f.UnionConfig.ValidateAndEncryptCredentials(additionalData); err != nil {
+			return err
+		}
+		f.OSConfig = sdk.OSFsConfig{}
+		f.S3Config = S3FsConfig{}
+		f.GCSConfig = GCSFsConfig{}
+		f.AzBlobConfig = AzBlobFsConfig{}
+		f.CryptConfig = CryptFsConfig{}
+		f.SFTPConfig = SFTPFsConfig{}
+		f.HTTPConfig = HTTPFsConfig{}
 		return nil
 	default:
 		f.Provider = sdk.LocalFilesystemProvider
@@ -240,6 +314,7 @@ func (f *Filesystem) Validate(additionalData string) error {
 		f.CryptConfig = CryptFsConfig{}
 		f.SFTPConfig = SFTPFsConfig{}
 		f.HTTPConfig = HTTPFsConfig{}
+		f.UnionConfig = UnionFsConfig{}
 		return validateOSFsConfig(&f.OSConfig)
 	}
 }
@@ -258,7 +333,7 @@ func (f *Filesystem) HasRedactedSecret() bool {
 		}
 		return f.AzBlobConfig.SASURL.IsRedacted()
 	case sdk.CryptedFilesystemProvider:
-		return f.CryptConfig.Passphrase.IsRedacted()
+		return f.CryptConfig.HasRedactedSecret()
 	case sdk.SFTPFilesystemProvider:
 		if f.SFTPConfig.Password.IsRedacted() {
 			return true
@@ -272,6 +347,12 @@ func (f *Filesystem) HasRedactedSecret() bool {
 			return true
 		}
 		return f.HTTPConfig.APIKey.IsRedacted()
+	case UnionFilesystemProvider:
+		for idx := range f.UnionConfig.Members {
+			if f.UnionConfig.Members[idx].FsConfig.HasRedactedSecret() {
+				return true
+			}
+		}
 	}
 
 	return false
@@ -292,6 +373,10 @@ func (f *Filesystem) HideConfidentialData() {
 		f.SFTPConfig.HideConfidentialData()
 	case sdk.HTTPFilesystemProvider:
 		f.HTTPConfig.HideConfidentialData()
+	case UnionFilesystemProvider:
+		for idx := range f.UnionConfig.Members {
+			f.UnionConfig.Members[idx].FsConfig.HideConfidentialData()
+		}
 	}
 }
 
@@ -323,7 +408,8 @@ func (f *Filesystem) GetACopy() Filesystem {
 				ForcePathStyle:      f.S3Config.ForcePathStyle,
 				SkipTLSVerify:       f.S3Config.SkipTLSVerify,
 			},
-			AccessSecret: f.S3Config.AccessSecret.Clone(),
+			AccessSecret:    f.S3Config.AccessSecret.Clone(),
+			UploadMaxMemory: f.S3Config.UploadMaxMemory,
 		},
 		GCSConfig: GCSFsConfig{
 			BaseGCSFsConfig: sdk.BaseGCSFsConfig{
@@ -358,7 +444,8 @@ func (f *Filesystem) GetACopy() Filesystem {
 				ReadBufferSize:  f.CryptConfig.ReadBufferSize,
 				WriteBufferSize: f.CryptConfig.WriteBufferSize,
 			},
-			Passphrase: f.CryptConfig.Passphrase.Clone(),
+			Passphrase:     f.CryptConfig.Passphrase.Clone(),
+			OldPassphrases: f.CryptConfig.getOldPassphrasesCopy(),
 		},
 		SFTPConfig: SFTPFsConfig{
 			BaseSFTPFsConfig: sdk.BaseSFTPFsConfig{
@@ -383,6 +470,8 @@ func (f *Filesystem) GetACopy() Filesystem {
 			Password: f.HTTPConfig.Password.Clone(),
 			APIKey:   f.HTTPConfig.APIKey.Clone(),
 		},
+		UnionConfig:    f.UnionConfig.getACopy(),
+		CompressConfig: f.CompressConfig.getACopy(),
 	}
 	if len(f.SFTPConfig.Fingerprints) > 0 {
 		fs.SFTPConfig.Fingerprints = make([]string, len(f.SFTPConfig.Fingerprints))
@@ -45,6 +45,8 @@ type BaseVirtualFolder struct {
 	Groups []string `json:"groups,omitempty"`
 	// Filesystem configuration details
 	FsConfig Filesystem `json:"filesystem"`
+	// Custom key/value attributes
+	Attributes map[string]string `json:"attributes,omitempty"`
 }
 
 // GetEncryptionAdditionalData returns the additional data to use for AEAD
@@ -58,6 +60,13 @@ func (v *BaseVirtualFolder) GetACopy() BaseVirtualFolder {
 	copy(users, v.Users)
 	groups := make([]string, len(v.Groups))
 	copy(groups, v.Groups)
+	var attributes map[string]string
+	if len(v.Attributes) > 0 {
+		attributes = make(map[string]string, len(v.Attributes))
+		for k, val := range v.Attributes {
+			attributes[k] = val
+		}
+	}
 	return BaseVirtualFolder{
 		ID:              v.ID,
 		Name:            v.Name,
@@ -69,6 +78,7 @@ func (v *BaseVirtualFolder) GetACopy() BaseVirtualFolder {
 		Users:           users,
 		Groups:          v.Groups,
 		FsConfig:        v.FsConfig.GetACopy(),
+		Attributes:      attributes,
 	}
 }
 
@@ -117,6 +127,8 @@ func (v *BaseVirtualFolder) GetStorageDescrition() string {
 		return fmt.Sprintf("SFTP: %s", v.FsConfig.SFTPConfig.Endpoint)
 	case sdk.HTTPFilesystemProvider:
 		return fmt.Sprintf("HTTP: %s", v.FsConfig.HTTPConfig.Endpoint)
+	case UnionFilesystemProvider:
+		return fmt.Sprintf("Union: %d members", len(v.FsConfig.UnionConfig.Members))
 	default:
 		return ""
 	}
@@ -142,6 +154,8 @@ func (v *BaseVirtualFolder) hideConfidentialData() {
 		v.FsConfig.SFTPConfig.HideConfidentialData()
 	case sdk.HTTPFilesystemProvider:
 		v.FsConfig.HTTPConfig.HideConfidentialData()
+	case UnionFilesystemProvider:
+		v.FsConfig.HideConfidentialData()
 	}
 }
 
@@ -189,10 +203,24 @@ type VirtualFolder struct {
 	QuotaSize int64 `json:"quota_size"`
 	// Maximum number of files allowed. 0 means unlimited, -1 included in user quota
 	QuotaFiles int `json:"quota_files"`
+	// Maximum upload bandwidth as KB/s for the transfers inside this folder.
+	// 0 means the user's upload bandwidth is used
+	UploadBandwidth int64 `json:"upload_bandwidth,omitempty"`
+	// Maximum download bandwidth as KB/s for the transfers inside this folder.
+	// 0 means the user's download bandwidth is used
+	DownloadBandwidth int64 `json:"download_bandwidth,omitempty"`
 }
 
 // GetFilesystem returns the filesystem for this folder
 func (v *VirtualFolder) GetFilesystem(connectionID string, forbiddenSelfUsers []string) (Fs, error) {
+	fs, err := v.getBaseFilesystem(connectionID, forbiddenSelfUsers)
+	if err != nil {
+		return fs, err
+	}
+	return NewCompressFs(fs, v.FsConfig.CompressConfig), nil
+}
+
+func (v *VirtualFolder) getBaseFilesystem(connectionID string, forbiddenSelfUsers []string) (Fs, error) {
 	switch v.FsConfig.Provider {
 	case sdk.S3FilesystemProvider:
 		return NewS3Fs(connectionID, v.MappedPath, v.VirtualPath, v.FsConfig.S3Config)
@@ -206,6 +234,8 @@ func (v *VirtualFolder) GetFilesystem(connectionID string, forbiddenSelfUsers []
 		return NewSFTPFs(connectionID, v.VirtualPath, v.MappedPath, forbiddenSelfUsers, v.FsConfig.SFTPConfig)
 	case sdk.HTTPFilesystemProvider:
 		return NewHTTPFs(connectionID, v.MappedPath, v.VirtualPath, v.FsConfig.HTTPConfig)
+	case UnionFilesystemProvider:
+		return NewUnionFs(connectionID, v.VirtualPath, forbiddenSelfUsers, v.FsConfig.UnionConfig)
 	default:
 		return NewOsFs(connectionID, v.MappedPath, v.VirtualPath, &v.FsConfig.OSConfig), nil
 	}
@@ -257,5 +287,7 @@ func (v *VirtualFolder) GetACopy() VirtualFolder {
 		VirtualPath:       v.VirtualPath,
 		QuotaSize:         v.QuotaSize,
 		QuotaFiles:        v.QuotaFiles,
+		UploadBandwidth:   v.UploadBandwidth,
+		DownloadBandwidth: v.DownloadBandwidth,
 	}
 }
@@ -127,10 +127,28 @@ func (fs *GCSFs) Lstat(name string) (os.FileInfo, error) {
 
 // Open opens the named file for reading
 func (fs *GCSFs) Open(name string, offset int64) (File, PipeReader, func(), error) {
-	r, w, err := pipeat.PipeInDir(fs.localTempDir)
+	return fs.OpenWithInfo(name, offset, nil)
+}
+
+// OpenWithInfo opens the named file for reading, the file info is used to lookup the read cache
+func (fs *GCSFs) OpenWithInfo(name string, offset int64, info os.FileInfo) (File, PipeReader, func(), error) {
+	cacheKey, size := getReadCacheKey(fs, fs.config.getReadCacheResource(), name, info)
+	if p := openFromReadCache(fs, cacheKey, name, offset); p != nil {
+		if readMetadata > 0 {
+			attrs, err := fs.headObject(name)
+			if err != nil {
+				p.Close()
+				return nil, nil, nil, err
+			}
+			p.setMetadata(attrs.Metadata)
+		}
+		return nil, p, nil, nil
+	}
+	r, pw, err := pipeat.PipeInDir(fs.localTempDir)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	w := newReadCacheWriter(pw, cacheKey, size, offset)
 	p := NewPipeReader(r)
 	if readMetadata > 0 {
 		attrs, err := fs.headObject(name)
@@ -141,7 +159,7 @@ func (fs *GCSFs) Open(name string, offset int64) (File, PipeReader, func(), erro
 		}
 		p.setMetadata(attrs.Metadata)
 	}
-	bkt := fs.svc.Bucket(fs.config.Bucket)
+	bkt := fs.getBucket()
 	obj := bkt.Object(name)
 	ctx, cancelFn := context.WithCancel(context.Background())
 	objectReader, err := obj.NewRangeReader(ctx, offset, -1)
@@ -183,7 +201,7 @@ func (fs *GCSFs) Create(name string, flag, checks int) (File, PipeWriter, func()
 	var attrs *storage.ObjectAttrs
 	var statErr error
 
-	bkt := fs.svc.Bucket(fs.config.Bucket)
+	bkt := fs.getBucket()
 	obj := bkt.Object(name)
 
 	if flag == -1 {
@@ -283,7 +301,7 @@ func (fs *GCSFs) Remove(name string, isDir bool) error {
 			name += "/"
 		}
 	}
-	obj := fs.svc.Bucket(fs.config.Bucket).Object(name)
+	obj := fs.getBucket().Object(name)
 	attrs, statErr := fs.headObject(name)
 	if statErr == nil {
 		obj = obj.If(storage.Conditions{GenerationMatch: attrs.Generation})
@@ -301,7 +319,7 @@ func (fs *GCSFs) Remove(name string, isDir bool) error {
 		ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
 		defer cancelFn()
 
-		err = fs.svc.Bucket(fs.config.Bucket).Object(strings.TrimSuffix(name, "/")).Delete(ctx)
+		err = fs.getBucket().Object(strings.TrimSuffix(name, "/")).Delete(ctx)
 	}
 	metric.GCSDeleteObjectCompleted(err)
 	if plugin.Handler.HasMetadater() && err == nil && !isDir {
@@ -389,7 +407,7 @@ func (fs *GCSFs) ReadDir(dirname string) ([]os.FileInfo, error) {
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxLongTimeout))
 	defer cancelFn()
 
-	bkt := fs.svc.Bucket(fs.config.Bucket)
+	bkt := fs.getBucket()
 	it := bkt.Objects(ctx, query)
 	pager := iterator.NewPager(it, defaultGCSPageSize, "")
 
@@ -534,7 +552,7 @@ func (fs *GCSFs) getFileNamesInPrefix(fsPrefix string) (map[string]bool, error)
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxLongTimeout))
 	defer cancelFn()
 
-	bkt := fs.svc.Bucket(fs.config.Bucket)
+	bkt := fs.getBucket()
 	it := bkt.Objects(ctx, query)
 	pager := iterator.NewPager(it, defaultGCSPageSize, "")
 
@@ -592,7 +610,7 @@ func (fs *GCSFs) GetDirSize(dirname string) (int, int64, error) {
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxLongTimeout))
 	defer cancelFn()
 
-	bkt := fs.svc.Bucket(fs.config.Bucket)
+	bkt := fs.getBucket()
 	it := bkt.Objects(ctx, query)
 	pager := iterator.NewPager(it, defaultGCSPageSize, "")
 
@@ -672,7 +690,7 @@ func (fs *GCSFs) Walk(root string, walkFn filepath.WalkFunc) error {
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxLongTimeout))
 	defer cancelFn()
 
-	bkt := fs.svc.Bucket(fs.config.Bucket)
+	bkt := fs.getBucket()
 	it := bkt.Objects(ctx, query)
 	pager := iterator.NewPager(it, defaultGCSPageSize, "")
 
@@ -776,6 +794,16 @@ func (fs *GCSFs) getObjectStat(name string) (os.FileInfo, error) {
 	return updateFileInfoModTime(fs.getStorageID(), name, NewFileInfo(name, true, attrs.Size, attrs.Updated, false))
 }
 
+// getBucket returns the handle for the configured bucket, the requests are
+// billed to the configured user project, if any
+func (fs *GCSFs) getBucket() *storage.BucketHandle {
+	bkt := fs.svc.Bucket(fs.config.Bucket)
+	if fs.config.UserProject != "" {
+		bkt = bkt.UserProject(fs.config.UserProject)
+	}
+	return bkt
+}
+
 func (fs *GCSFs) setWriterAttrs(objectWriter *storage.Writer, flag int, name string) {
 	var contentType string
 	if flag == -1 {
@@ -789,6 +817,9 @@ func (fs *GCSFs) setWriterAttrs(objectWriter *storage.Writer, flag int, name str
 	if fs.config.StorageClass != "" {
 		objectWriter.ObjectAttrs.StorageClass = fs.config.StorageClass
 	}
+	if fs.config.KMSKeyName != "" {
+		objectWriter.ObjectAttrs.KMSKeyName = fs.config.KMSKeyName
+	}
 	if fs.config.ACL != "" {
 		objectWriter.PredefinedACL = fs.config.ACL
 	}
@@ -801,6 +832,9 @@ func (fs *GCSFs) composeObjects(ctx context.Context, dst, partialObject *storage
 	if fs.config.StorageClass != "" {
 		composer.StorageClass = fs.config.StorageClass
 	}
+	if fs.config.KMSKeyName != "" {
+		composer.KMSKeyName = fs.config.KMSKeyName
+	}
 	if fs.config.ACL != "" {
 		composer.PredefinedACL = fs.config.ACL
 	}
@@ -822,8 +856,8 @@ func (fs *GCSFs) composeObjects(ctx context.Context, dst, partialObject *storage
 }
 
 func (fs *GCSFs) copyFileInternal(source, target string) error {
-	src := fs.svc.Bucket(fs.config.Bucket).Object(source)
-	dst := fs.svc.Bucket(fs.config.Bucket).Object(target)
+	src := fs.getBucket().Object(source)
+	dst := fs.getBucket().Object(target)
 	attrs, statErr := fs.headObject(target)
 	if statErr == nil {
 		dst = dst.If(storage.Conditions{GenerationMatch: attrs.Generation})
@@ -841,6 +875,9 @@ func (fs *GCSFs) copyFileInternal(source, target string) error {
 	if fs.config.StorageClass != "" {
 		copier.StorageClass = fs.config.StorageClass
 	}
+	if fs.config.KMSKeyName != "" {
+		copier.DestinationKMSKeyName = fs.config.KMSKeyName
+	}
 	if fs.config.ACL != "" {
 		copier.PredefinedACL = fs.config.ACL
 	}
@@ -934,7 +971,7 @@ func (fs *GCSFs) hasContents(name string) (bool, error) {
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
 	defer cancelFn()
 
-	bkt := fs.svc.Bucket(fs.config.Bucket)
+	bkt := fs.getBucket()
 	it := bkt.Objects(ctx, query)
 	// if we have a dir object with a trailing slash it will be returned so we set the size to 2
 	pager := iterator.NewPager(it, 2, "")
@@ -975,7 +1012,7 @@ func (fs *GCSFs) headObject(name string) (*storage.ObjectAttrs, error) {
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
 	defer cancelFn()
 
-	bkt := fs.svc.Bucket(fs.config.Bucket)
+	bkt := fs.getBucket()
 	obj := bkt.Object(name)
 	attrs, err := obj.Attrs(ctx)
 	metric.GCSHeadObjectCompleted(err)
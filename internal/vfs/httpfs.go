@@ -57,6 +57,13 @@ type HTTPFsConfig struct {
 	sdk.BaseHTTPFsConfig
 	Password *kms.Secret `json:"password,omitempty"`
 	APIKey   *kms.Secret `json:"api_key,omitempty"`
+	// Use HTTP Range requests, instead of the offset query parameter,
+	// to read the files starting from an offset
+	UseRangeRequests bool `json:"use_range_requests,omitempty"`
+	// Upload the files using the tus resumable upload protocol,
+	// the interrupted chunks are resumed from the last offset stored
+	// by the server
+	TusUploads bool `json:"tus_uploads,omitempty"`
 }
 
 func (c *HTTPFsConfig) isUnixDomainSocket() bool {
@@ -101,6 +108,9 @@ func (c *HTTPFsConfig) isEqual(other HTTPFsConfig) bool {
 	if c.SkipTLSVerify != other.SkipTLSVerify {
 		return false
 	}
+	if c.UseRangeRequests != other.UseRangeRequests || c.TusUploads != other.TusUploads {
+		return false
+	}
 	c.setEmptyCredentialsIfNil()
 	other.setEmptyCredentialsIfNil()
 	if !c.Password.IsEqual(other.Password) {
@@ -325,14 +335,19 @@ func (fs *HTTPFs) Open(name string, offset int64) (File, PipeReader, func(), err
 	ctx, cancelFn := context.WithCancel(context.Background())
 
 	var queryString string
+	headers := make(map[string]string)
 	if offset > 0 {
-		queryString = fmt.Sprintf("?offset=%d", offset)
+		if fs.config.UseRangeRequests {
+			headers["Range"] = fmt.Sprintf("bytes=%d-", offset)
+		} else {
+			queryString = fmt.Sprintf("?offset=%d", offset)
+		}
 	}
 
 	go func() {
 		defer cancelFn()
 
-		resp, err := fs.sendHTTPRequest(ctx, http.MethodGet, "open", name, queryString, "", nil)
+		resp, err := fs.doHTTPRequest(ctx, http.MethodGet, fs.getURL("open", name, queryString), nil, headers)
 		if err != nil {
 			fsLog(fs, logger.LevelError, "download error, path %q, err: %v", name, err)
 			w.CloseWithError(err) //nolint:errcheck
@@ -340,6 +355,15 @@ func (fs *HTTPFs) Open(name string, offset int64) (File, PipeReader, func(), err
 			return
 		}
 		defer resp.Body.Close()
+		if offset > 0 && fs.config.UseRangeRequests && resp.StatusCode != http.StatusPartialContent {
+			// the Range header was ignored and the whole file is returned
+			fsLog(fs, logger.LevelDebug, "range request not supported, path %q, skipping %d bytes", name, offset)
+			if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+				w.CloseWithError(err) //nolint:errcheck
+				metric.HTTPFsTransferCompleted(0, 1, err)
+				return
+			}
+		}
 		n, err := io.Copy(w, resp.Body)
 		w.CloseWithError(err) //nolint:errcheck
 		fsLog(fs, logger.LevelDebug, "download completed, path %q size: %v, err: %+v", name, n, err)
@@ -358,6 +382,11 @@ func (fs *HTTPFs) Create(name string, flag, checks int) (File, PipeWriter, func(
 	p := NewPipeWriter(w)
 	ctx, cancelFn := context.WithCancel(context.Background())
 
+	if fs.config.TusUploads {
+		go fs.tusUpload(ctx, cancelFn, name, flag, checks, r, p)
+		return nil, p, cancelFn, nil
+	}
+
 	go func() {
 		defer cancelFn()
 
@@ -698,16 +727,29 @@ func (fs *HTTPFs) GetAvailableDiskSize(dirName string) (*sftp.StatVFS, error) {
 	return response.toSFTPStatVFS(), nil
 }
 
+func (fs *HTTPFs) getURL(base, name, queryString string) string {
+	return fmt.Sprintf("%s/%s/%s%s", fs.config.Endpoint, base, url.PathEscape(name), queryString)
+}
+
 func (fs *HTTPFs) sendHTTPRequest(ctx context.Context, method, base, name, queryString, contentType string,
 	body io.Reader,
 ) (*http.Response, error) {
-	url := fmt.Sprintf("%s/%s/%s%s", fs.config.Endpoint, base, url.PathEscape(name), queryString)
+	headers := make(map[string]string)
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+	return fs.doHTTPRequest(ctx, method, fs.getURL(base, name, queryString), body, headers)
+}
+
+func (fs *HTTPFs) doHTTPRequest(ctx context.Context, method, url string, body io.Reader,
+	headers map[string]string,
+) (*http.Response, error) {
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
 	}
-	if contentType != "" {
-		req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
 	}
 	if fs.config.APIKey.GetPayload() != "" {
 		req.Header.Set("X-API-KEY", fs.config.APIKey.GetPayload())
@@ -754,7 +796,7 @@ func getErrorFromResponseCode(code int) error {
 		return os.ErrNotExist
 	case 501:
 		return ErrVfsUnsupported
-	case 200, 201:
+	case 200, 201, 204, 206:
 		return nil
 	default:
 		return fmt.Errorf("unexpected response code: %v", code)
@@ -0,0 +1,174 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/eikenb/pipeat"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/metric"
+)
+
+const (
+	tusVersion = "1.0.0"
+	// size of the chunks sent using the tus protocol
+	tusChunkSize = 5 * 1024 * 1024
+	// maximum number of attempts to send a chunk
+	tusMaxAttempts = 3
+)
+
+// tusUpload uploads the data read from the pipe using the tus resumable upload protocol.
+// The upload size is not known in advance so it is declared with the last chunk
+func (fs *HTTPFs) tusUpload(ctx context.Context, cancelFn context.CancelFunc, name string, flag, checks int,
+	r *pipeat.PipeReaderAt, p PipeWriter,
+) {
+	defer cancelFn()
+
+	uploadURL, err := fs.tusCreate(ctx, name, flag, checks)
+	if err == nil {
+		err = fs.tusSendChunks(ctx, uploadURL, r)
+	}
+	if err != nil {
+		fsLog(fs, logger.LevelError, "tus upload error, path %q, err: %v", name, err)
+	} else {
+		fsLog(fs, logger.LevelDebug, "tus upload completed, path: %q, readed bytes: %d", name, r.GetReadedBytes())
+	}
+	r.CloseWithError(err) //nolint:errcheck
+	p.Done(err)
+	metric.HTTPFsTransferCompleted(r.GetReadedBytes(), 0, err)
+}
+
+// tusCreate creates a new upload and returns its URL
+func (fs *HTTPFs) tusCreate(ctx context.Context, name string, flag, checks int) (string, error) {
+	queryString := fmt.Sprintf("?flags=%d&checks=%d", flag, checks)
+	resp, err := fs.doHTTPRequest(ctx, http.MethodPost, fs.getURL("tus", name, queryString), nil, map[string]string{
+		"Tus-Resumable":       tusVersion,
+		"Upload-Defer-Length": "1",
+		"Upload-Metadata":     "filename " + base64.StdEncoding.EncodeToString([]byte(path.Base(name))),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return "", errors.New("tus: the upload location is missing")
+	}
+	uploadURL, err := resp.Request.URL.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("tus: invalid upload location %q: %w", location, err)
+	}
+	return uploadURL.String(), nil
+}
+
+func (fs *HTTPFs) tusSendChunks(ctx context.Context, uploadURL string, r io.Reader) error {
+	buf := make([]byte, tusChunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		isLast := false
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			isLast = true
+		} else if err != nil {
+			return err
+		}
+		var length int64 = -1
+		if isLast {
+			length = offset + int64(n)
+		}
+		if err := fs.tusSendChunkWithRetry(ctx, uploadURL, buf[:n], offset, length); err != nil {
+			return err
+		}
+		offset += int64(n)
+		if isLast {
+			return nil
+		}
+	}
+}
+
+// tusSendChunkWithRetry sends a chunk, if the request fails the offset stored by the server
+// is requested and the chunk is resumed from there
+func (fs *HTTPFs) tusSendChunkWithRetry(ctx context.Context, uploadURL string, chunk []byte, offset, length int64) error {
+	var err error
+	sent := int64(0)
+	for attempt := 1; attempt <= tusMaxAttempts; attempt++ {
+		err = fs.tusSendChunk(ctx, uploadURL, chunk[sent:], offset+sent, length)
+		if err == nil || ctx.Err() != nil || errors.Is(err, ErrVfsUnsupported) {
+			return err
+		}
+		fsLog(fs, logger.LevelWarn, "tus chunk upload failed, offset: %d, attempt: %d, err: %v",
+			offset+sent, attempt, err)
+		time.Sleep(time.Duration(attempt) * time.Second)
+		serverOffset, headErr := fs.tusGetOffset(ctx, uploadURL)
+		if headErr != nil {
+			return fmt.Errorf("tus: unable to get the upload offset: %w, upload error: %v", headErr, err)
+		}
+		if serverOffset < offset || serverOffset > offset+int64(len(chunk)) {
+			return fmt.Errorf("tus: unexpected upload offset %d, expected between %d and %d",
+				serverOffset, offset, offset+int64(len(chunk)))
+		}
+		sent = serverOffset - offset
+	}
+	return err
+}
+
+func (fs *HTTPFs) tusSendChunk(ctx context.Context, uploadURL string, chunk []byte, offset, length int64) error {
+	headers := map[string]string{
+		"Tus-Resumable": tusVersion,
+		"Content-Type":  "application/offset+octet-stream",
+		"Upload-Offset": strconv.FormatInt(offset, 10),
+	}
+	if length >= 0 {
+		headers["Upload-Length"] = strconv.FormatInt(length, 10)
+	}
+	resp, err := fs.doHTTPRequest(ctx, http.MethodPatch, uploadURL, bytes.NewReader(chunk), headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	newOffset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("tus: invalid upload offset in response: %w", err)
+	}
+	if newOffset != offset+int64(len(chunk)) {
+		return fmt.Errorf("tus: unexpected upload offset %d, expected %d", newOffset, offset+int64(len(chunk)))
+	}
+	return nil
+}
+
+func (fs *HTTPFs) tusGetOffset(ctx context.Context, uploadURL string) (int64, error) {
+	resp, err := fs.doHTTPRequest(ctx, http.MethodHead, uploadURL, nil, map[string]string{
+		"Tus-Resumable": tusVersion,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
@@ -135,6 +135,9 @@ func (fs *OsFs) Open(name string, offset int64) (File, PipeReader, func(), error
 
 // Create creates or opens the named file for writing
 func (fs *OsFs) Create(name string, flag, _ int) (File, PipeWriter, func(), error) {
+	if err := fs.prepareDedupWrite(name, flag); err != nil {
+		return nil, nil, nil, err
+	}
 	if !fs.useWriteBuffering(flag) {
 		var err error
 		var f *os.File
@@ -238,11 +241,17 @@ func (fs *OsFs) Readlink(name string) (string, error) {
 
 // Chown changes the numeric uid and gid of the named file.
 func (*OsFs) Chown(name string, uid int, gid int) error {
+	if err := detachDedupLink(name); err != nil {
+		return err
+	}
 	return os.Chown(name, uid, gid)
 }
 
 // Chmod changes the mode of the named file to mode
 func (*OsFs) Chmod(name string, mode os.FileMode) error {
+	if err := detachDedupLink(name); err != nil {
+		return err
+	}
 	return os.Chmod(name, mode)
 }
 
@@ -253,9 +262,40 @@ func (*OsFs) Chtimes(name string, atime, mtime time.Time, _ bool) error {
 
 // Truncate changes the size of the named file
 func (*OsFs) Truncate(name string, size int64) error {
+	if err := detachDedupLink(name); err != nil {
+		return err
+	}
 	return os.Truncate(name, size)
 }
 
+// Deduplicate implements the FsDeduplicator interface. The content of the specified
+// file is added to the deduplication store or replaced with a hard link to the
+// stored content with the same hash. This is a no-op if deduplication is disabled
+func (fs *OsFs) Deduplicate(name string) error {
+	store := dedupStore
+	if store == nil || fs.name != osFsName {
+		return nil
+	}
+	if err := store.add(name); err != nil {
+		fsLog(fs, logger.LevelWarn, "unable to deduplicate file %q: %v", name, err)
+		return err
+	}
+	return nil
+}
+
+// prepareDedupWrite makes sure that writing to a deduplicated file does not
+// modify the content shared with other files. The file is removed if it will
+// be truncated, otherwise its content is copied
+func (fs *OsFs) prepareDedupWrite(name string, flag int) error {
+	if !isDedupLink(name) {
+		return nil
+	}
+	if flag == 0 || flag&os.O_TRUNC != 0 || fs.useWriteBuffering(flag) {
+		return os.Remove(name)
+	}
+	return detachDedupLink(name)
+}
+
 // ReadDir reads the directory named by dirname and returns
 // a list of directory entries.
 func (*OsFs) ReadDir(dirname string) ([]os.FileInfo, error) {
@@ -0,0 +1,406 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vfs
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/eikenb/pipeat"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+const (
+	readCacheLogSender = "readcache"
+	readCacheTempDir   = "tmp"
+)
+
+// readCache is the local read-through cache for cloud storage backends, nil means disabled
+var readCache *localReadCache
+
+// SetReadCache configures the local read-through cache for cloud storage backends.
+// The recently downloaded files are stored inside the specified directory and the least
+// recently used ones are evicted when the cache exceeds the specified size, as MB.
+// Files bigger than maxFileSize, as MB, are not cached, 0 means no limit.
+// The cache is disabled if the path is empty or the max size is not greater than 0
+func SetReadCache(cachePath string, maxSize, maxFileSize int64) error {
+	readCache = nil
+	if cachePath == "" || maxSize <= 0 {
+		return nil
+	}
+	if !filepath.IsAbs(cachePath) {
+		return fmt.Errorf("invalid read cache path %q, it must be an absolute path", cachePath)
+	}
+	if maxFileSize < 0 {
+		return fmt.Errorf("invalid read cache max file size %d", maxFileSize)
+	}
+	cache := &localReadCache{
+		path:        filepath.Clean(cachePath),
+		maxSize:     maxSize * 1024 * 1024,
+		maxFileSize: maxFileSize * 1024 * 1024,
+		lru:         list.New(),
+		entries:     make(map[string]*list.Element),
+	}
+	if err := cache.load(); err != nil {
+		return fmt.Errorf("unable to initialize the read cache: %w", err)
+	}
+	readCache = cache
+	return nil
+}
+
+type readCacheEntry struct {
+	key  string
+	size int64
+}
+
+// localReadCache stores the recently downloaded files on the local disk
+// and evicts the least recently used ones
+type localReadCache struct {
+	sync.Mutex
+	path        string
+	maxSize     int64
+	maxFileSize int64
+	size        int64
+	// the most recently used entries are at the front
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// load adds the files cached before a restart and removes the incomplete ones
+func (c *localReadCache) load() error {
+	if err := os.RemoveAll(filepath.Join(c.path, readCacheTempDir)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(c.path, readCacheTempDir), 0700); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(c.path)
+	if err != nil {
+		return err
+	}
+	var files []os.FileInfo
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+
+	c.Lock()
+	defer c.Unlock()
+
+	for _, info := range files {
+		c.entries[info.Name()] = c.lru.PushBack(&readCacheEntry{
+			key:  info.Name(),
+			size: info.Size(),
+		})
+		c.size += info.Size()
+	}
+	c.evict()
+	logger.Info(readCacheLogSender, "", "read cache initialized, path %q, files: %d, size: %d",
+		c.path, len(c.entries), c.size)
+	return nil
+}
+
+func (c *localReadCache) canCache(size int64) bool {
+	if size > c.maxSize {
+		return false
+	}
+	return c.maxFileSize == 0 || size <= c.maxFileSize
+}
+
+// getKey returns the cache key for a file. The key changes if the file is modified
+func (*localReadCache) getKey(resource, name string, info os.FileInfo) string {
+	h := sha256.New()
+	h.Write([]byte(resource))
+	h.Write([]byte{0})
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(info.Size(), 10)))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(info.ModTime().UnixNano(), 10)))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// open returns a reader for the cached file with the specified key, starting at the given offset
+func (c *localReadCache) open(key string, offset int64) (PipeReader, bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*readCacheEntry)
+	f, err := os.Open(filepath.Join(c.path, key))
+	if err != nil {
+		logger.Warn(readCacheLogSender, "", "unable to open cached file %q: %v", key, err)
+		c.removeElement(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	if offset > entry.size {
+		offset = entry.size
+	}
+	return &cachedFileReader{
+		SectionReader: io.NewSectionReader(f, offset, entry.size-offset),
+		file:          f,
+	}, true
+}
+
+// add moves the specified temporary file inside the cache
+func (c *localReadCache) add(key, tempPath string, size int64) {
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		os.Remove(tempPath)
+		return
+	}
+	if err := os.Rename(tempPath, filepath.Join(c.path, key)); err != nil {
+		logger.Warn(readCacheLogSender, "", "unable to add file %q to the read cache: %v", key, err)
+		os.Remove(tempPath)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&readCacheEntry{
+		key:  key,
+		size: size,
+	})
+	c.size += size
+	c.evict()
+}
+
+// evict removes the least recently used files until the cache size is within the limit.
+// It must be called within a locked block
+func (c *localReadCache) evict() {
+	for c.size > c.maxSize {
+		elem := c.lru.Back()
+		if elem == nil {
+			return
+		}
+		c.removeElement(elem)
+	}
+}
+
+// removeElement must be called within a locked block
+func (c *localReadCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*readCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+	if err := os.Remove(filepath.Join(c.path, entry.key)); err != nil && !os.IsNotExist(err) {
+		logger.Warn(readCacheLogSender, "", "unable to remove cached file %q: %v", entry.key, err)
+	}
+}
+
+func (c *localReadCache) getTempFile() (*os.File, error) {
+	return os.CreateTemp(filepath.Join(c.path, readCacheTempDir), "download")
+}
+
+// getReadCacheResource returns the identifier for a storage resource and the
+// credentials used to access it. Cached files are shared only between filesystems
+// with the same identifier, the connection specific settings must not be included
+func getReadCacheResource(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
+
+// getReadCacheKey returns the cache key and the size for the specified file.
+// The file info is used if provided, otherwise the file is stat'ed.
+// An empty key means that the file cannot be cached
+func getReadCacheKey(fs Fs, resource, name string, info os.FileInfo) (string, int64) {
+	if readCache == nil {
+		return "", 0
+	}
+	if info == nil {
+		var err error
+		info, err = fs.Stat(name)
+		if err != nil {
+			return "", 0
+		}
+	}
+	if !info.Mode().IsRegular() || !readCache.canCache(info.Size()) {
+		return "", 0
+	}
+	return readCache.getKey(resource, name, info), info.Size()
+}
+
+// openFromReadCache returns a reader for the specified file if it is cached
+func openFromReadCache(fs Fs, key, name string, offset int64) PipeReader {
+	if readCache == nil || key == "" {
+		return nil
+	}
+	p, ok := readCache.open(key, offset)
+	if !ok {
+		return nil
+	}
+	fsLog(fs, logger.LevelDebug, "serving %q from the read cache, offset: %d", name, offset)
+	return p
+}
+
+// readCacheWriter writes the downloaded data to both the pipe and a temporary
+// file that is added to the read cache if the download completes successfully
+type readCacheWriter struct {
+	*pipeat.PipeWriterAt
+	key      string
+	size     int64
+	file     *os.File
+	offset   int64
+	written  atomic.Int64
+	mu       sync.Mutex
+	writeErr error
+}
+
+// newReadCacheWriter wraps the specified pipe writer. Only full downloads are cached
+func newReadCacheWriter(w *pipeat.PipeWriterAt, key string, size, offset int64) *readCacheWriter {
+	writer := &readCacheWriter{
+		PipeWriterAt: w,
+		key:          key,
+		size:         size,
+	}
+	if readCache == nil || key == "" || offset != 0 {
+		return writer
+	}
+	f, err := readCache.getTempFile()
+	if err != nil {
+		logger.Warn(readCacheLogSender, "", "unable to create temporary file for %q: %v", key, err)
+		return writer
+	}
+	writer.file = f
+	return writer
+}
+
+func (w *readCacheWriter) cache(p []byte, off int64) {
+	if w.file == nil {
+		return
+	}
+	n, err := w.file.WriteAt(p, off)
+	w.written.Add(int64(n))
+	if err != nil {
+		w.mu.Lock()
+		w.writeErr = err
+		w.mu.Unlock()
+	}
+}
+
+// WriteAt implements the io.WriterAt interface
+func (w *readCacheWriter) WriteAt(p []byte, off int64) (int, error) {
+	n, err := w.PipeWriterAt.WriteAt(p, off)
+	if n > 0 {
+		w.cache(p[:n], off)
+	}
+	return n, err
+}
+
+// Write implements the io.Writer interface
+func (w *readCacheWriter) Write(p []byte) (int, error) {
+	n, err := w.PipeWriterAt.Write(p)
+	if n > 0 {
+		w.cache(p[:n], w.offset)
+		w.offset += int64(n)
+	}
+	return n, err
+}
+
+// CloseWithError closes the pipe writer and adds the downloaded file
+// to the read cache if there are no errors
+func (w *readCacheWriter) CloseWithError(err error) error {
+	result := w.PipeWriterAt.CloseWithError(err)
+	if w.file == nil {
+		return result
+	}
+	tempPath := w.file.Name()
+	closeErr := w.file.Close()
+
+	w.mu.Lock()
+	writeErr := w.writeErr
+	w.mu.Unlock()
+
+	if err != nil || writeErr != nil || closeErr != nil || w.written.Load() != w.size {
+		logger.Debug(readCacheLogSender, "", "file %q not cached, written: %d, expected: %d, err: %v, write err: %v",
+			w.key, w.written.Load(), w.size, err, writeErr)
+		os.Remove(tempPath)
+		return result
+	}
+	readCache.add(w.key, tempPath, w.size)
+	return result
+}
+
+// cachedFileReader is a PipeReader for a cached file
+type cachedFileReader struct {
+	*io.SectionReader
+	file     *os.File
+	mu       sync.RWMutex
+	metadata map[string]string
+}
+
+// Close closes the cached file
+func (r *cachedFileReader) Close() error {
+	return r.file.Close()
+}
+
+func (r *cachedFileReader) setMetadata(value map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metadata = value
+}
+
+func (r *cachedFileReader) setMetadataFromPointerVal(value map[string]*string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metadata = nil
+	for k, v := range value {
+		if v != nil && *v != "" {
+			if r.metadata == nil {
+				r.metadata = make(map[string]string)
+			}
+			r.metadata[k] = *v
+		}
+	}
+}
+
+// Metadata implements the Metadater interface
+func (r *cachedFileReader) Metadata() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.metadata) == 0 {
+		return nil
+	}
+	result := make(map[string]string)
+	for k, v := range r.metadata {
+		result[k] = v
+	}
+	return result
+}
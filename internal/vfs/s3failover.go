@@ -0,0 +1,147 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+//go:build !nos3
+// +build !nos3
+
+package vfs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+const (
+	s3FailoverLogSender = "s3failover"
+	// unhealthy endpoints are checked at this interval
+	s3HealthCheckInterval = 30 * time.Second
+)
+
+// s3EndpointsHealth tracks the health of the S3 endpoints, it is shared among
+// all the S3Fs instances since a new instance is created for each connection
+var s3EndpointsHealth = &s3HealthTracker{
+	endpoints: make(map[string]*s3EndpointHealth),
+}
+
+type s3EndpointHealth struct {
+	unhealthy atomic.Bool
+	checking  atomic.Bool
+}
+
+type s3HealthTracker struct {
+	sync.Mutex
+	endpoints map[string]*s3EndpointHealth
+}
+
+func (t *s3HealthTracker) get(key string) *s3EndpointHealth {
+	t.Lock()
+	defer t.Unlock()
+
+	h, ok := t.endpoints[key]
+	if !ok {
+		h = &s3EndpointHealth{}
+		t.endpoints[key] = h
+	}
+	return h
+}
+
+func (t *s3HealthTracker) isHealthy(key string) bool {
+	return !t.get(key).unhealthy.Load()
+}
+
+// markUnhealthy flags the endpoint as unhealthy and starts checking it in
+// background, the endpoint is used again as soon as the bucket is reachable
+func (t *s3HealthTracker) markUnhealthy(c *s3Client, bucket string, err error) {
+	h := t.get(c.key)
+	if !h.unhealthy.Swap(true) {
+		logger.Warn(s3FailoverLogSender, "", "endpoint %q, region %q marked as unhealthy: %v", c.endpoint, c.region, err)
+	}
+	if !h.checking.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer h.checking.Store(false)
+
+		for {
+			time.Sleep(s3HealthCheckInterval)
+			if c.isReachable(bucket) {
+				h.unhealthy.Store(false)
+				logger.Info(s3FailoverLogSender, "", "endpoint %q, region %q is healthy again", c.endpoint, c.region)
+				return
+			}
+		}
+	}()
+}
+
+// s3Client is an S3 client for one of the configured endpoints
+type s3Client struct {
+	svc      *s3.Client
+	key      string
+	endpoint string
+	region   string
+}
+
+func (c *s3Client) isReachable(bucket string) bool {
+	ctx, cancelFn := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancelFn()
+
+	_, err := c.svc.HeadBucket(ctx, &s3.HeadBucketInput{
+		Bucket: aws.String(bucket),
+	})
+	return !isS3EndpointFailure(err)
+}
+
+// isS3EndpointFailure returns true if the error means that the endpoint is not
+// reachable or not able to serve the requests. Errors such as not found or
+// permission denied are returned by healthy endpoints
+func isS3EndpointFailure(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var re *awshttp.ResponseError
+	if errors.As(err, &re) {
+		return re.Response != nil && re.Response.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// addS3FailureDetector adds a middleware that marks the endpoint as unhealthy
+// if a request fails, after the retries, with an endpoint failure
+func addS3FailureDetector(c *s3Client, bucket string) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("SFTPGoFailureDetector",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+				middleware.InitializeOutput, middleware.Metadata, error,
+			) {
+				out, metadata, err := next.HandleInitialize(ctx, in)
+				if isS3EndpointFailure(err) {
+					s3EndpointsHealth.markUnhealthy(c, bucket, err)
+				}
+				return out, metadata, err
+			}), middleware.Before)
+	}
+}
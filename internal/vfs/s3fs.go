@@ -44,8 +44,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/eikenb/pipeat"
 	"github.com/pkg/sftp"
+	"golang.org/x/sync/semaphore"
 
 	"github.com/drakkan/sftpgo/v2/internal/logger"
 	"github.com/drakkan/sftpgo/v2/internal/metric"
@@ -62,16 +64,56 @@ const (
 
 var (
 	s3DirMimeTypes = []string{s3DirMimeType, "httpd/unix-directory"}
+	s3UploadMemory = &s3MemoryBudget{}
 )
 
+// s3MemoryBudget limits the memory used by the buffers of the concurrent multipart uploads
+type s3MemoryBudget struct {
+	sync.Mutex
+	limit int64
+	sem   *semaphore.Weighted
+}
+
+func (b *s3MemoryBudget) getSemaphore() (*semaphore.Weighted, int64) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.limit != s3MaxUploadMemory {
+		b.limit = s3MaxUploadMemory
+		b.sem = nil
+		if b.limit > 0 {
+			b.sem = semaphore.NewWeighted(b.limit)
+		}
+	}
+	return b.sem, b.limit
+}
+
+// acquire waits until the requested memory is available within the configured limit
+// and returns a function to release it. A size greater than the limit is reduced to
+// the limit, so a single upload requiring more memory than the limit cannot block forever
+func (b *s3MemoryBudget) acquire(ctx context.Context, size int64) (func(), error) {
+	sem, limit := b.getSemaphore()
+	if sem == nil {
+		return func() {}, nil
+	}
+	size = min(size, limit)
+	if err := sem.Acquire(ctx, size); err != nil {
+		return nil, err
+	}
+	return func() {
+		sem.Release(size)
+	}, nil
+}
+
 // S3Fs is a Fs implementation for AWS S3 compatible object storages
 type S3Fs struct {
 	connectionID string
 	localTempDir string
 	// if not empty this fs is mouted as virtual folder in the specified path
-	mountPath  string
-	config     *S3FsConfig
-	svc        *s3.Client
+	mountPath string
+	config    *S3FsConfig
+	// the clients for the configured endpoints, the first one is the primary endpoint
+	clients    []*s3Client
 	ctxTimeout time.Duration
 }
 
@@ -122,14 +164,56 @@ func NewS3Fs(connectionID, localTempDir, mountPath string, s3Config S3FsConfig)
 		creds := stscreds.NewAssumeRoleProvider(client, fs.config.RoleARN)
 		awsConfig.Credentials = creds
 	}
-	fs.svc = s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+	endpoints := []S3FailoverEndpoint{{Endpoint: fs.config.Endpoint, Region: fs.config.Region}}
+	endpoints = append(endpoints, fs.config.FailoverEndpoints...)
+	for _, ep := range endpoints {
+		fs.clients = append(fs.clients, fs.newClient(awsConfig, ep, len(endpoints) > 1))
+	}
+	return fs, nil
+}
+
+func (fs *S3Fs) newClient(awsConfig aws.Config, ep S3FailoverEndpoint, hasFailover bool) *s3Client {
+	if ep.Endpoint == "" {
+		ep.Endpoint = fs.config.Endpoint
+	}
+	if ep.Region == "" {
+		ep.Region = fs.config.Region
+	}
+	c := &s3Client{
+		key:      fmt.Sprintf("%s|%s|%s", fs.config.Bucket, ep.Endpoint, ep.Region),
+		endpoint: ep.Endpoint,
+		region:   ep.Region,
+	}
+	c.svc = s3.NewFromConfig(awsConfig, func(o *s3.Options) {
 		o.AppID = fmt.Sprintf("SFTPGo-%s", version.Get().CommitHash)
 		o.UsePathStyle = fs.config.ForcePathStyle
-		if fs.config.Endpoint != "" {
-			o.BaseEndpoint = aws.String(fs.config.Endpoint)
+		if ep.Endpoint != "" {
+			o.BaseEndpoint = aws.String(ep.Endpoint)
+		}
+		if ep.Region != "" {
+			o.Region = ep.Region
+		}
+		if fs.config.RequesterPays {
+			o.APIOptions = append(o.APIOptions, smithyhttp.SetHeaderValue("x-amz-request-payer", "requester"))
+		}
+		if hasFailover {
+			o.APIOptions = append(o.APIOptions, addS3FailureDetector(c, fs.config.Bucket))
 		}
 	})
-	return fs, nil
+	return c
+}
+
+// getClient returns the client for the first healthy endpoint. The primary
+// endpoint is used if none of the configured endpoints is healthy
+func (fs *S3Fs) getClient() *s3.Client {
+	if len(fs.clients) > 1 {
+		for _, c := range fs.clients {
+			if s3EndpointsHealth.isHealthy(c.key) {
+				return c.svc
+			}
+		}
+	}
+	return fs.clients[0].svc
 }
 
 // Name returns the name for the Fs implementation
@@ -160,8 +244,13 @@ func (fs *S3Fs) Stat(name string) (os.FileInfo, error) {
 			_, err = fs.headObject(name + "/")
 			isDir = err == nil
 		}
-		return updateFileInfoModTime(fs.getStorageID(), name, NewFileInfo(name, isDir, util.GetIntFromPointer(obj.ContentLength),
-			util.GetTimeFromPointer(obj.LastModified), false))
+		info := NewFileInfo(name, isDir, util.GetIntFromPointer(obj.ContentLength),
+			util.GetTimeFromPointer(obj.LastModified), false)
+		if !isDir && obj.ObjectLockRetainUntilDate != nil && obj.ObjectLockRetainUntilDate.After(time.Now()) {
+			// locked objects are reported as read only
+			info.SetMode(0444)
+		}
+		return updateFileInfoModTime(fs.getStorageID(), name, info)
 	}
 	if !fs.IsNotExist(err) {
 		return result, err
@@ -197,23 +286,46 @@ func (fs *S3Fs) Lstat(name string) (os.FileInfo, error) {
 
 // Open opens the named file for reading
 func (fs *S3Fs) Open(name string, offset int64) (File, PipeReader, func(), error) {
-	r, w, err := pipeat.PipeInDir(fs.localTempDir)
+	return fs.OpenWithInfo(name, offset, nil)
+}
+
+// OpenWithInfo opens the named file for reading, the file info is used to lookup the read cache
+func (fs *S3Fs) OpenWithInfo(name string, offset int64, info os.FileInfo) (File, PipeReader, func(), error) {
+	cacheKey, size := getReadCacheKey(fs, fs.config.getReadCacheResource(), name, info)
+	if p := openFromReadCache(fs, cacheKey, name, offset); p != nil {
+		if readMetadata > 0 {
+			attrs, err := fs.headObject(name)
+			if err != nil {
+				p.Close()
+				return nil, nil, nil, err
+			}
+			p.setMetadata(attrs.Metadata)
+		}
+		return nil, p, nil, nil
+	}
+	r, pw, err := pipeat.PipeInDir(fs.localTempDir)
 	if err != nil {
 		return nil, nil, nil, err
 	}
+	w := newReadCacheWriter(pw, cacheKey, size, offset)
 	p := NewPipeReader(r)
-	if readMetadata > 0 {
+	if readMetadata > 0 || fs.config.RestoreDays > 0 {
 		attrs, err := fs.headObject(name)
+		if err == nil {
+			err = fs.checkArchivedObject(name, attrs)
+		}
 		if err != nil {
 			r.Close()
 			w.Close()
 			return nil, nil, nil, err
 		}
-		p.setMetadata(attrs.Metadata)
+		if readMetadata > 0 {
+			p.setMetadata(attrs.Metadata)
+		}
 	}
 
 	ctx, cancelFn := context.WithCancel(context.Background())
-	downloader := manager.NewDownloader(fs.svc, func(d *manager.Downloader) {
+	downloader := manager.NewDownloader(fs.getClient(), func(d *manager.Downloader) {
 		d.Concurrency = fs.config.DownloadConcurrency
 		d.PartSize = fs.config.DownloadPartSize
 		if offset == 0 && fs.config.DownloadPartMaxTime > 0 {
@@ -263,7 +375,7 @@ func (fs *S3Fs) Create(name string, flag, checks int) (File, PipeWriter, func(),
 		p = NewPipeWriter(w)
 	}
 	ctx, cancelFn := context.WithCancel(context.Background())
-	uploader := manager.NewUploader(fs.svc, func(u *manager.Uploader) {
+	uploader := manager.NewUploader(fs.getClient(), func(u *manager.Uploader) {
 		u.Concurrency = fs.config.UploadConcurrency
 		u.PartSize = fs.config.UploadPartSize
 		if fs.config.UploadPartMaxTime > 0 {
@@ -277,19 +389,42 @@ func (fs *S3Fs) Create(name string, flag, checks int) (File, PipeWriter, func(),
 	go func() {
 		defer cancelFn()
 
+		releaseMemory, err := s3UploadMemory.acquire(ctx, fs.config.UploadPartSize*int64(fs.config.UploadConcurrency))
+		if err != nil {
+			r.CloseWithError(err) //nolint:errcheck
+			p.Done(err)
+			fsLog(fs, logger.LevelDebug, "unable to acquire memory for upload, path: %q, err: %v", name, err)
+			return
+		}
+		defer releaseMemory()
+
 		var contentType string
+		var lockMode types.ObjectLockMode
+		var retainUntil *time.Time
+		var checksumAlgo types.ChecksumAlgorithm
 		if flag == -1 {
 			contentType = s3DirMimeType
 		} else {
 			contentType = mime.TypeByExtension(path.Ext(name))
+			lockMode, retainUntil = fs.getObjectLockRetention()
+			if retainUntil != nil {
+				// uploads with an Object Lock retention require an integrity checksum
+				checksumAlgo = types.ChecksumAlgorithmCrc32
+			}
 		}
-		_, err := uploader.Upload(ctx, &s3.PutObjectInput{
-			Bucket:       aws.String(fs.config.Bucket),
-			Key:          aws.String(name),
-			Body:         r,
-			ACL:          types.ObjectCannedACL(fs.config.ACL),
-			StorageClass: types.StorageClass(fs.config.StorageClass),
-			ContentType:  util.NilIfEmpty(contentType),
+		sse, kmsKeyID := fs.getServerSideEncryption()
+		_, err = uploader.Upload(ctx, &s3.PutObjectInput{
+			Bucket:                    aws.String(fs.config.Bucket),
+			Key:                       aws.String(name),
+			Body:                      r,
+			ACL:                       types.ObjectCannedACL(fs.config.ACL),
+			StorageClass:              types.StorageClass(fs.config.StorageClass),
+			ContentType:               util.NilIfEmpty(contentType),
+			ObjectLockMode:            lockMode,
+			ObjectLockRetainUntilDate: retainUntil,
+			ChecksumAlgorithm:         checksumAlgo,
+			ServerSideEncryption:      sse,
+			SSEKMSKeyId:               kmsKeyID,
 		})
 		r.CloseWithError(err) //nolint:errcheck
 		p.Done(err)
@@ -353,11 +488,13 @@ func (fs *S3Fs) Remove(name string, isDir bool) error {
 		if !strings.HasSuffix(name, "/") {
 			name += "/"
 		}
+	} else if err := fs.checkObjectLock(name); err != nil {
+		return err
 	}
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
 	defer cancelFn()
 
-	_, err := fs.svc.DeleteObject(ctx, &s3.DeleteObjectInput{
+	_, err := fs.getClient().DeleteObject(ctx, &s3.DeleteObjectInput{
 		Bucket: aws.String(fs.config.Bucket),
 		Key:    aws.String(name),
 	})
@@ -437,7 +574,7 @@ func (fs *S3Fs) ReadDir(dirname string) ([]os.FileInfo, error) {
 	}
 	prefixes := make(map[string]bool)
 
-	paginator := s3.NewListObjectsV2Paginator(fs.svc, &s3.ListObjectsV2Input{
+	paginator := s3.NewListObjectsV2Paginator(fs.getClient(), &s3.ListObjectsV2Input{
 		Bucket:    aws.String(fs.config.Bucket),
 		Prefix:    aws.String(prefix),
 		Delimiter: aws.String("/"),
@@ -477,12 +614,16 @@ func (fs *S3Fs) ReadDir(dirname string) ([]os.FileInfo, error) {
 				}
 				prefixes[name] = true
 			}
+			isLocked := !isDir && fs.isObjectLocked(objectModTime)
 			if t, ok := modTimes[name]; ok {
 				objectModTime = util.GetTimeFromMsecSinceEpoch(t)
 			}
 
-			result = append(result, NewFileInfo(name, (isDir && objectSize == 0), objectSize,
-				objectModTime, false))
+			info := NewFileInfo(name, (isDir && objectSize == 0), objectSize, objectModTime, false)
+			if isLocked {
+				info.SetMode(0444)
+			}
+			result = append(result, info)
 		}
 	}
 
@@ -531,6 +672,9 @@ func (*S3Fs) IsPermission(err error) bool {
 	if err == nil {
 		return false
 	}
+	if errors.Is(err, ErrObjectLocked) {
+		return true
+	}
 
 	var re *awshttp.ResponseError
 	if errors.As(err, &re) {
@@ -570,7 +714,7 @@ func (fs *S3Fs) getFileNamesInPrefix(fsPrefix string) (map[string]bool, error) {
 		prefix = strings.TrimPrefix(fsPrefix, "/")
 	}
 
-	paginator := s3.NewListObjectsV2Paginator(fs.svc, &s3.ListObjectsV2Input{
+	paginator := s3.NewListObjectsV2Paginator(fs.getClient(), &s3.ListObjectsV2Input{
 		Bucket:    aws.String(fs.config.Bucket),
 		Prefix:    aws.String(prefix),
 		Delimiter: aws.String("/"),
@@ -613,7 +757,7 @@ func (fs *S3Fs) GetDirSize(dirname string) (int, int64, error) {
 	numFiles := 0
 	size := int64(0)
 
-	paginator := s3.NewListObjectsV2Paginator(fs.svc, &s3.ListObjectsV2Input{
+	paginator := s3.NewListObjectsV2Paginator(fs.getClient(), &s3.ListObjectsV2Input{
 		Bucket: aws.String(fs.config.Bucket),
 		Prefix: aws.String(prefix),
 	})
@@ -678,7 +822,7 @@ func (fs *S3Fs) GetRelativePath(name string) string {
 func (fs *S3Fs) Walk(root string, walkFn filepath.WalkFunc) error {
 	prefix := fs.getPrefix(root)
 
-	paginator := s3.NewListObjectsV2Paginator(fs.svc, &s3.ListObjectsV2Input{
+	paginator := s3.NewListObjectsV2Paginator(fs.getClient(), &s3.ListObjectsV2Input{
 		Bucket: aws.String(fs.config.Bucket),
 		Prefix: aws.String(prefix),
 	})
@@ -738,6 +882,91 @@ func (fs *S3Fs) CopyFile(source, target string, srcSize int64) error {
 	return fs.copyFileInternal(source, target, srcSize)
 }
 
+// GetVersions implements the FsVersioner interface.
+// The versions are returned sorted by modification time, the most recent first
+func (fs *S3Fs) GetVersions(name string) ([]FileVersion, error) {
+	var result []FileVersion
+
+	svc := fs.getClient()
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(fs.config.Bucket),
+		Prefix: aws.String(name),
+	}
+	for {
+		ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
+		page, err := svc.ListObjectVersions(ctx, input)
+		cancelFn()
+		if err != nil {
+			metric.S3ListObjectsCompleted(err)
+			return result, err
+		}
+		for _, v := range page.Versions {
+			if util.GetStringFromPointer(v.Key) != name {
+				continue
+			}
+			result = append(result, FileVersion{
+				ID:           util.GetStringFromPointer(v.VersionId),
+				Size:         util.GetIntFromPointer(v.Size),
+				LastModified: util.GetTimeFromPointer(v.LastModified),
+				IsLatest:     aws.ToBool(v.IsLatest),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			if util.GetStringFromPointer(m.Key) != name {
+				continue
+			}
+			result = append(result, FileVersion{
+				ID:             util.GetStringFromPointer(m.VersionId),
+				LastModified:   util.GetTimeFromPointer(m.LastModified),
+				IsLatest:       aws.ToBool(m.IsLatest),
+				IsDeleteMarker: true,
+			})
+		}
+		if !aws.ToBool(page.IsTruncated) {
+			break
+		}
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+	metric.S3ListObjectsCompleted(nil)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].LastModified.After(result[j].LastModified)
+	})
+	return result, nil
+}
+
+// RestoreVersion implements the FsVersioner interface.
+// The specified version is copied as the current version of the file
+func (fs *S3Fs) RestoreVersion(name, versionID string) error {
+	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
+	defer cancelFn()
+
+	obj, err := fs.getClient().HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:    aws.String(fs.config.Bucket),
+		Key:       aws.String(name),
+		VersionId: aws.String(versionID),
+	})
+	metric.S3HeadObjectCompleted(err)
+	if err != nil {
+		return err
+	}
+	copySource := pathEscape(fs.Join(fs.config.Bucket, name)) + "?versionId=" + url.QueryEscape(versionID)
+	if err := fs.copyObject(copySource, name, mime.TypeByExtension(path.Ext(name)),
+		util.GetIntFromPointer(obj.ContentLength)); err != nil {
+		return err
+	}
+	fsLog(fs, logger.LevelInfo, "version %q restored for path %q", versionID, name)
+	if plugin.Handler.HasMetadater() {
+		err := plugin.Handler.SetModificationTime(fs.getStorageID(), ensureAbsPath(name),
+			util.GetTimeAsMsSinceEpoch(time.Now()))
+		if err != nil {
+			fsLog(fs, logger.LevelWarn, "unable to set modification time after restoring %q: %+v", name, err)
+		}
+	}
+	return nil
+}
+
 func (fs *S3Fs) resolve(name *string, prefix string) (string, bool) {
 	result := strings.TrimPrefix(util.GetStringFromPointer(name), prefix)
 	isDir := strings.HasSuffix(result, "/")
@@ -758,6 +987,15 @@ func (fs *S3Fs) setConfigDefaults() {
 	if fs.config.UploadConcurrency == 0 {
 		fs.config.UploadConcurrency = manager.DefaultUploadConcurrency
 	}
+	if fs.config.UploadMaxMemory > 0 {
+		maxConcurrency := int(fs.config.UploadMaxMemory * 1024 * 1024 / fs.config.UploadPartSize)
+		if maxConcurrency < 1 {
+			maxConcurrency = 1
+		}
+		if fs.config.UploadConcurrency > maxConcurrency {
+			fs.config.UploadConcurrency = maxConcurrency
+		}
+	}
 	if fs.config.DownloadPartSize == 0 {
 		fs.config.DownloadPartSize = manager.DefaultDownloadPartSize
 	} else {
@@ -774,9 +1012,13 @@ func (fs *S3Fs) copyFileInternal(source, target string, fileSize int64) error {
 	contentType := mime.TypeByExtension(path.Ext(source))
 	copySource := pathEscape(fs.Join(fs.config.Bucket, source))
 
+	return fs.copyObject(copySource, target, contentType, fileSize)
+}
+
+func (fs *S3Fs) copyObject(copySource, target, contentType string, fileSize int64) error {
 	if fileSize > 500*1024*1024 {
-		fsLog(fs, logger.LevelDebug, "renaming file %q with size %d using multipart copy",
-			source, fileSize)
+		fsLog(fs, logger.LevelDebug, "copying object %q with size %d using multipart copy",
+			copySource, fileSize)
 		err := fs.doMultipartCopy(copySource, target, contentType, fileSize)
 		metric.S3CopyObjectCompleted(err)
 		return err
@@ -784,13 +1026,19 @@ func (fs *S3Fs) copyFileInternal(source, target string, fileSize int64) error {
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
 	defer cancelFn()
 
-	_, err := fs.svc.CopyObject(ctx, &s3.CopyObjectInput{
-		Bucket:       aws.String(fs.config.Bucket),
-		CopySource:   aws.String(copySource),
-		Key:          aws.String(target),
-		StorageClass: types.StorageClass(fs.config.StorageClass),
-		ACL:          types.ObjectCannedACL(fs.config.ACL),
-		ContentType:  util.NilIfEmpty(contentType),
+	lockMode, retainUntil := fs.getObjectLockRetention()
+	sse, kmsKeyID := fs.getServerSideEncryption()
+	_, err := fs.getClient().CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:                    aws.String(fs.config.Bucket),
+		CopySource:                aws.String(copySource),
+		Key:                       aws.String(target),
+		StorageClass:              types.StorageClass(fs.config.StorageClass),
+		ACL:                       types.ObjectCannedACL(fs.config.ACL),
+		ContentType:               util.NilIfEmpty(contentType),
+		ObjectLockMode:            lockMode,
+		ObjectLockRetainUntilDate: retainUntil,
+		ServerSideEncryption:      sse,
+		SSEKMSKeyId:               kmsKeyID,
 	})
 
 	metric.S3CopyObjectCompleted(err)
@@ -835,6 +1083,9 @@ func (fs *S3Fs) renameInternal(source, target string, fi os.FileInfo) (int, int6
 			}
 		}
 	} else {
+		if err := fs.checkObjectLock(source); err != nil {
+			return numFiles, filesSize, err
+		}
 		if err := fs.copyFileInternal(source, target, fi.Size()); err != nil {
 			return numFiles, filesSize, err
 		}
@@ -870,7 +1121,7 @@ func (fs *S3Fs) mkdirInternal(name string) error {
 func (fs *S3Fs) hasContents(name string) (bool, error) {
 	prefix := fs.getPrefix(name)
 	maxKeys := int32(2)
-	paginator := s3.NewListObjectsV2Paginator(fs.svc, &s3.ListObjectsV2Input{
+	paginator := s3.NewListObjectsV2Paginator(fs.getClient(), &s3.ListObjectsV2Input{
 		Bucket:  aws.String(fs.config.Bucket),
 		Prefix:  aws.String(prefix),
 		MaxKeys: &maxKeys,
@@ -904,12 +1155,20 @@ func (fs *S3Fs) doMultipartCopy(source, target, contentType string, fileSize int
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
 	defer cancelFn()
 
-	res, err := fs.svc.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
-		Bucket:       aws.String(fs.config.Bucket),
-		Key:          aws.String(target),
-		StorageClass: types.StorageClass(fs.config.StorageClass),
-		ACL:          types.ObjectCannedACL(fs.config.ACL),
-		ContentType:  util.NilIfEmpty(contentType),
+	// the same endpoint must be used for all the requests of a multipart upload
+	svc := fs.getClient()
+	lockMode, retainUntil := fs.getObjectLockRetention()
+	sse, kmsKeyID := fs.getServerSideEncryption()
+	res, err := svc.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:                    aws.String(fs.config.Bucket),
+		Key:                       aws.String(target),
+		StorageClass:              types.StorageClass(fs.config.StorageClass),
+		ACL:                       types.ObjectCannedACL(fs.config.ACL),
+		ContentType:               util.NilIfEmpty(contentType),
+		ObjectLockMode:            lockMode,
+		ObjectLockRetainUntilDate: retainUntil,
+		ServerSideEncryption:      sse,
+		SSEKMSKeyId:               kmsKeyID,
 	})
 	if err != nil {
 		return fmt.Errorf("unable to create multipart copy request: %w", err)
@@ -963,7 +1222,7 @@ func (fs *S3Fs) doMultipartCopy(source, target, contentType string, fileSize int
 			innerCtx, innerCancelFn := context.WithDeadline(opCtx, time.Now().Add(fs.ctxTimeout))
 			defer innerCancelFn()
 
-			partResp, err := fs.svc.UploadPartCopy(innerCtx, &s3.UploadPartCopyInput{
+			partResp, err := svc.UploadPartCopy(innerCtx, &s3.UploadPartCopyInput{
 				Bucket:          aws.String(fs.config.Bucket),
 				CopySource:      aws.String(source),
 				Key:             aws.String(target),
@@ -981,7 +1240,7 @@ func (fs *S3Fs) doMultipartCopy(source, target, contentType string, fileSize int
 					abortCtx, abortCancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
 					defer abortCancelFn()
 
-					_, errAbort := fs.svc.AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
+					_, errAbort := svc.AbortMultipartUpload(abortCtx, &s3.AbortMultipartUploadInput{
 						Bucket:   aws.String(fs.config.Bucket),
 						Key:      aws.String(target),
 						UploadId: aws.String(uploadID),
@@ -1022,7 +1281,7 @@ func (fs *S3Fs) doMultipartCopy(source, target, contentType string, fileSize int
 	completeCtx, completeCancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
 	defer completeCancelFn()
 
-	_, err = fs.svc.CompleteMultipartUpload(completeCtx, &s3.CompleteMultipartUploadInput{
+	_, err = svc.CompleteMultipartUpload(completeCtx, &s3.CompleteMultipartUploadInput{
 		Bucket:   aws.String(fs.config.Bucket),
 		Key:      aws.String(target),
 		UploadId: aws.String(uploadID),
@@ -1051,7 +1310,7 @@ func (fs *S3Fs) headObject(name string) (*s3.HeadObjectOutput, error) {
 	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
 	defer cancelFn()
 
-	obj, err := fs.svc.HeadObject(ctx, &s3.HeadObjectInput{
+	obj, err := fs.getClient().HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(fs.config.Bucket),
 		Key:    aws.String(name),
 	})
@@ -1059,6 +1318,120 @@ func (fs *S3Fs) headObject(name string) (*s3.HeadObjectOutput, error) {
 	return obj, err
 }
 
+// getObjectLockRetention returns the Object Lock retention to apply to new objects
+func (fs *S3Fs) getObjectLockRetention() (types.ObjectLockMode, *time.Time) {
+	if fs.config.ObjectLockMode == "" {
+		return "", nil
+	}
+	retainUntil := time.Now().Add(time.Duration(fs.config.ObjectLockRetentionDays) * 24 * time.Hour).UTC()
+	return types.ObjectLockMode(fs.config.ObjectLockMode), &retainUntil
+}
+
+// getServerSideEncryption returns the SSE-KMS settings to apply to new objects.
+// If no key is configured the bucket default encryption is used
+func (fs *S3Fs) getServerSideEncryption() (types.ServerSideEncryption, *string) {
+	if fs.config.SSEKMSKeyID == "" {
+		return "", nil
+	}
+	return types.ServerSideEncryptionAwsKms, aws.String(fs.config.SSEKMSKeyID)
+}
+
+// isObjectLocked returns true if the object was uploaded with an Object Lock retention
+// that is not yet expired. The retention is computed from the object modification
+// time, so the check does not require additional requests
+func (fs *S3Fs) isObjectLocked(modTime time.Time) bool {
+	if fs.config.ObjectLockMode == "" {
+		return false
+	}
+	return modTime.Add(time.Duration(fs.config.ObjectLockRetentionDays) * 24 * time.Hour).After(time.Now())
+}
+
+// checkObjectLock returns ErrObjectLocked if the specified object is under
+// an Object Lock retention or legal hold
+func (fs *S3Fs) checkObjectLock(name string) error {
+	if fs.config.ObjectLockMode == "" {
+		return nil
+	}
+	obj, err := fs.headObject(name)
+	if err != nil {
+		if fs.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if obj.ObjectLockLegalHoldStatus == types.ObjectLockLegalHoldStatusOn {
+		fsLog(fs, logger.LevelInfo, "object %q is under legal hold", name)
+		return fmt.Errorf("%w: %q is under legal hold", ErrObjectLocked, name)
+	}
+	retainUntil := util.GetTimeFromPointer(obj.ObjectLockRetainUntilDate)
+	if obj.ObjectLockRetainUntilDate != nil && retainUntil.After(time.Now()) {
+		fsLog(fs, logger.LevelInfo, "object %q is retained in %s mode until %s", name, obj.ObjectLockMode,
+			retainUntil.Format(time.RFC3339))
+		return fmt.Errorf("%w: %q is retained in %s mode until %s", ErrObjectLocked, name, obj.ObjectLockMode,
+			retainUntil.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// isArchived returns true if the object is stored in an archive storage class,
+// or archive access tier, and a restored copy is not available
+func (*S3Fs) isArchived(attrs *s3.HeadObjectOutput) bool {
+	if attrs.StorageClass != types.StorageClassGlacier && attrs.StorageClass != types.StorageClassDeepArchive &&
+		attrs.ArchiveStatus == "" {
+		return false
+	}
+	return !strings.Contains(util.GetStringFromPointer(attrs.Restore), `ongoing-request="false"`)
+}
+
+// checkArchivedObject starts the restore for archived objects and returns
+// ErrRestoreInProgress until the restored copy is available
+func (fs *S3Fs) checkArchivedObject(name string, attrs *s3.HeadObjectOutput) error {
+	if fs.config.RestoreDays <= 0 || !fs.isArchived(attrs) {
+		return nil
+	}
+	if strings.Contains(util.GetStringFromPointer(attrs.Restore), `ongoing-request="true"`) {
+		fsLog(fs, logger.LevelDebug, "restore already in progress for archived object %q", name)
+		return ErrRestoreInProgress
+	}
+	ctx, cancelFn := context.WithDeadline(context.Background(), time.Now().Add(fs.ctxTimeout))
+	defer cancelFn()
+
+	restoreRequest := &types.RestoreRequest{
+		GlacierJobParameters: &types.GlacierJobParameters{
+			Tier: types.Tier(fs.config.RestoreTier),
+		},
+	}
+	// the objects archived by Intelligent-Tiering are restored to the frequent
+	// access tier and the number of days cannot be specified
+	if attrs.ArchiveStatus == "" {
+		restoreRequest.Days = aws.Int32(int32(fs.config.RestoreDays))
+	}
+	_, err := fs.getClient().RestoreObject(ctx, &s3.RestoreObjectInput{
+		Bucket:         aws.String(fs.config.Bucket),
+		Key:            aws.String(name),
+		RestoreRequest: restoreRequest,
+	})
+	if err != nil {
+		var re *awshttp.ResponseError
+		if !errors.As(err, &re) || re.Response == nil || re.Response.StatusCode != http.StatusConflict {
+			fsLog(fs, logger.LevelError, "unable to restore archived object %q: %+v", name, err)
+			return err
+		}
+	}
+	fsLog(fs, logger.LevelInfo, "restore started for archived object %q, storage class: %q, tier: %q",
+		name, attrs.StorageClass, fs.config.RestoreTier)
+	return ErrRestoreInProgress
+}
+
+// IsArchiveRestored implements the FsArchiveRestorer interface
+func (fs *S3Fs) IsArchiveRestored(name string) (bool, error) {
+	attrs, err := fs.headObject(name)
+	if err != nil {
+		return false, err
+	}
+	return !fs.isArchived(attrs), nil
+}
+
 // GetMimeType returns the content type
 func (fs *S3Fs) GetMimeType(name string) (string, error) {
 	obj, err := fs.headObject(name)
@@ -1083,7 +1456,7 @@ func (fs *S3Fs) downloadToWriter(name string, w PipeWriter) (int64, error) {
 	ctx, cancelFn := context.WithTimeout(context.Background(), preResumeTimeout)
 	defer cancelFn()
 
-	downloader := manager.NewDownloader(fs.svc, func(d *manager.Downloader) {
+	downloader := manager.NewDownloader(fs.getClient(), func(d *manager.Downloader) {
 		d.Concurrency = fs.config.DownloadConcurrency
 		d.PartSize = fs.config.DownloadPartSize
 		if fs.config.DownloadPartMaxTime > 0 {
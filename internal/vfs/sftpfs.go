@@ -48,17 +48,55 @@ import (
 
 const (
 	// sftpFsName is the name for the SFTP Fs implementation
-	sftpFsName               = "sftpfs"
-	logSenderSFTPCache       = "sftpCache"
-	maxSessionsPerConnection = 5
+	sftpFsName         = "sftpfs"
+	logSenderSFTPCache = "sftpCache"
 )
 
 var (
 	// ErrSFTPLoop defines the error to return if an SFTP loop is detected
 	ErrSFTPLoop    = errors.New("SFTP loop or nested local SFTP folders detected")
 	sftpConnsCache = newSFTPConnectionCache()
+	// the user sessions multiplexed on each SSH connection
+	sftpMaxSessionsPerConnection = 5
+	// maximum number of connections for each remote account, 0 means no limit
+	sftpMaxConnections      = 0
+	sftpIdleTimeout         = 30 * time.Second
+	sftpHealthCheckInterval = 30 * time.Second
 )
 
+// SetSFTPFsPool configures the pool of connections to the remote SFTP servers.
+// The user sessions for the same remote account share the pooled connections,
+// up to maxSessions sessions for each connection. If maxConnections is greater
+// than 0 and the limit is reached, the new sessions are added to the least loaded
+// connections. The connections without sessions are closed after idleTimeout
+// seconds, the connections are checked every healthCheckInterval seconds.
+// 0 means the default value for maxSessions, idleTimeout and healthCheckInterval
+func SetSFTPFsPool(maxSessions, maxConnections, idleTimeout, healthCheckInterval int) error {
+	if maxSessions < 0 || maxConnections < 0 || idleTimeout < 0 || healthCheckInterval < 0 {
+		return errors.New("invalid SFTP pool settings, negative values are not allowed")
+	}
+	if healthCheckInterval > 0 && healthCheckInterval < 5 {
+		return fmt.Errorf("invalid SFTP pool health check interval %d, the minimum allowed is 5", healthCheckInterval)
+	}
+	sftpMaxSessionsPerConnection = 5
+	if maxSessions > 0 {
+		sftpMaxSessionsPerConnection = maxSessions
+	}
+	sftpMaxConnections = maxConnections
+	sftpIdleTimeout = 30 * time.Second
+	if idleTimeout > 0 {
+		sftpIdleTimeout = time.Duration(idleTimeout) * time.Second
+	}
+	sftpHealthCheckInterval = 30 * time.Second
+	if healthCheckInterval > 0 {
+		sftpHealthCheckInterval = time.Duration(healthCheckInterval) * time.Second
+	}
+	logger.Debug(logSenderSFTPCache, "", "pool configured, max sessions per connection: %d, max connections: %d, "+
+		"idle timeout: %s, health check interval: %s", sftpMaxSessionsPerConnection, sftpMaxConnections,
+		sftpIdleTimeout, sftpHealthCheckInterval)
+	return nil
+}
+
 // SFTPFsConfig defines the configuration for SFTP based filesystem
 type SFTPFsConfig struct {
 	sdk.BaseSFTPFsConfig
@@ -149,6 +187,10 @@ func (c *SFTPFsConfig) isSameResource(other SFTPFsConfig) bool {
 	return c.Endpoint == other.Endpoint
 }
 
+func (c *SFTPFsConfig) getReadCacheResource() string {
+	return getReadCacheResource(sftpFsName, c.Endpoint, c.Username, c.Password.GetPayload(), c.PrivateKey.GetPayload())
+}
+
 // validate returns an error if the configuration is not valid
 func (c *SFTPFsConfig) validate() error {
 	c.setEmptyCredentialsIfNil()
@@ -347,6 +389,15 @@ func (fs *SFTPFs) Lstat(name string) (os.FileInfo, error) {
 
 // Open opens the named file for reading
 func (fs *SFTPFs) Open(name string, offset int64) (File, PipeReader, func(), error) {
+	return fs.OpenWithInfo(name, offset, nil)
+}
+
+// OpenWithInfo opens the named file for reading, the file info is used to lookup the read cache
+func (fs *SFTPFs) OpenWithInfo(name string, offset int64, info os.FileInfo) (File, PipeReader, func(), error) {
+	cacheKey, size := getReadCacheKey(fs, fs.config.getReadCacheResource(), name, info)
+	if p := openFromReadCache(fs, cacheKey, name, offset); p != nil {
+		return nil, p, nil, nil
+	}
 	client, err := fs.conn.getClient()
 	if err != nil {
 		return nil, nil, nil, err
@@ -362,14 +413,16 @@ func (fs *SFTPFs) Open(name string, offset int64) (File, PipeReader, func(), err
 			return nil, nil, nil, err
 		}
 	}
-	if fs.config.BufferSize == 0 {
+	// full downloads are piped if they can be cached
+	if fs.config.BufferSize == 0 && (cacheKey == "" || offset > 0) {
 		return f, nil, nil, nil
 	}
-	r, w, err := pipeat.PipeInDir(fs.localTempDir)
+	r, pw, err := pipeat.PipeInDir(fs.localTempDir)
 	if err != nil {
 		f.Close()
 		return nil, nil, nil, err
 	}
+	w := newReadCacheWriter(pw, cacheKey, size, offset)
 	p := NewPipeReader(r)
 
 	go func() {
@@ -1008,7 +1061,7 @@ func (c *sftpConnection) Wait() {
 
 	go func() {
 		var watchdogInProgress atomic.Bool
-		ticker := time.NewTicker(30 * time.Second)
+		ticker := time.NewTicker(sftpHealthCheckInterval)
 		defer ticker.Stop()
 
 		for {
@@ -1025,7 +1078,10 @@ func (c *sftpConnection) Wait() {
 
 					_, err := c.sftpClient.Getwd()
 					if err != nil {
-						logger.Error(c.logSender, "", "watchdog error: %v", err)
+						// the connection is not healthy, close it so that a new one is
+						// opened for the next request
+						logger.Error(c.logSender, "", "watchdog error, closing connection: %v", err)
+						c.sshClient.Close()
 					}
 				}()
 			case <-done:
@@ -1117,7 +1173,7 @@ func newSFTPConnectionCache() *sftpConnectionsCache {
 		scheduler: cron.New(cron.WithLocation(time.UTC), cron.WithLogger(cron.DiscardLogger)),
 		items:     make(map[uint64]*sftpConnection),
 	}
-	_, err := c.scheduler.AddFunc("@every 1m", c.Cleanup)
+	_, err := c.scheduler.AddFunc("@every 30s", c.Cleanup)
 	util.PanicOnError(err)
 	c.scheduler.Start()
 	return c
@@ -1131,17 +1187,30 @@ func (c *sftpConnectionsCache) Get(config *SFTPFsConfig, sessionID string) *sftp
 	defer c.Unlock()
 
 	var oldKey uint64
+	var leastLoaded *sftpConnection
+	leastLoadedSessions := 0
 	for {
 		if val, ok := c.items[key]; ok {
 			activeSessions := val.ActiveSessions()
-			if activeSessions < maxSessionsPerConnection || key == oldKey {
+			if activeSessions < sftpMaxSessionsPerConnection || key == oldKey {
 				logger.Debug(logSenderSFTPCache, "",
 					"reusing connection for session ID %q, key: %d, active sessions %d, active connections: %d",
 					sessionID, key, activeSessions+1, len(c.items))
 				val.AddSession(sessionID)
 				return val
 			}
+			if leastLoaded == nil || activeSessions < leastLoadedSessions {
+				leastLoaded = val
+				leastLoadedSessions = activeSessions
+			}
 			partition++
+			if sftpMaxConnections > 0 && partition >= sftpMaxConnections {
+				logger.Debug(logSenderSFTPCache, "",
+					"connections limit reached, reusing the least loaded connection for session ID %q, active sessions: %d",
+					sessionID, leastLoadedSessions+1)
+				leastLoaded.AddSession(sessionID)
+				return leastLoaded
+			}
 			oldKey = key
 			key = config.getUniqueID(partition)
 			logger.Debug(logSenderSFTPCache, "",
@@ -1174,7 +1243,7 @@ func (c *sftpConnectionsCache) Cleanup() {
 	c.RLock()
 
 	for k, conn := range c.items {
-		if val := conn.GetLastActivity(); val.Before(time.Now().Add(-30 * time.Second)) {
+		if val := conn.GetLastActivity(); val.Before(time.Now().Add(-sftpIdleTimeout)) {
 			logger.Debug(conn.logSender, "", "removing inactive connection, last activity %s", val)
 
 			defer func(key uint64) {
@@ -19,6 +19,8 @@ package vfs
 
 import (
 	"errors"
+	"os"
+	"syscall"
 
 	"golang.org/x/sys/unix"
 )
@@ -30,3 +32,11 @@ func isCrossDeviceError(err error) bool {
 func isInvalidNameError(_ error) bool {
 	return false
 }
+
+// getLinksInfo returns the number of hard links and the owner of a file
+func getLinksInfo(info os.FileInfo) (uint64, uint32, uint32, bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink), stat.Uid, stat.Gid, true //nolint:unconvert
+	}
+	return 0, 0, 0, false
+}
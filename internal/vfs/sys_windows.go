@@ -16,6 +16,7 @@ package vfs
 
 import (
 	"errors"
+	"os"
 
 	"golang.org/x/sys/windows"
 )
@@ -30,3 +31,7 @@ func isInvalidNameError(err error) bool {
 	}
 	return errors.Is(err, windows.ERROR_INVALID_NAME)
 }
+
+func getLinksInfo(_ os.FileInfo) (uint64, uint32, uint32, bool) {
+	return 0, 0, 0, false
+}
@@ -0,0 +1,835 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/sftpgo/sdk"
+
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	// unionFsName is the name for the union Fs implementation
+	unionFsName = "unionfs"
+)
+
+// UnionFilesystemProvider defines the provider for a filesystem merging
+// several backends under a single tree
+const UnionFilesystemProvider sdk.FilesystemProvider = 7
+
+// Supported write policies for the union filesystem
+const (
+	// new files and directories are created inside the first writable member
+	UnionWritePolicyFirstWritable = "first_writable"
+	// new files and directories are created inside the writable member
+	// with the most available space
+	UnionWritePolicyMostFreeSpace = "most_free_space"
+)
+
+var (
+	unionWritePolicies = []string{UnionWritePolicyFirstWritable, UnionWritePolicyMostFreeSpace}
+)
+
+// UnionFsMember defines a backend merged inside a union filesystem
+type UnionFsMember struct {
+	// Root directory for local and local encrypted backends, local temporary
+	// directory for the other backends. Optional for non local backends
+	MappedPath string `json:"mapped_path,omitempty"`
+	// If true no file or directory will be created, modified or removed
+	// inside this member
+	ReadOnly bool `json:"read_only,omitempty"`
+	// Backend configuration, nested union filesystems are not allowed
+	FsConfig Filesystem `json:"filesystem"`
+}
+
+func (m *UnionFsMember) getFilesystem(connectionID string, forbiddenSelfUsers []string) (Fs, error) {
+	fs, err := m.getBaseFilesystem(connectionID, forbiddenSelfUsers)
+	if err != nil {
+		return fs, err
+	}
+	return NewCompressFs(fs, m.FsConfig.CompressConfig), nil
+}
+
+func (m *UnionFsMember) getBaseFilesystem(connectionID string, forbiddenSelfUsers []string) (Fs, error) {
+	switch m.FsConfig.Provider {
+	case sdk.S3FilesystemProvider:
+		return NewS3Fs(connectionID, m.MappedPath, "", m.FsConfig.S3Config)
+	case sdk.GCSFilesystemProvider:
+		return NewGCSFs(connectionID, m.MappedPath, "", m.FsConfig.GCSConfig)
+	case sdk.AzureBlobFilesystemProvider:
+		return NewAzBlobFs(connectionID, m.MappedPath, "", m.FsConfig.AzBlobConfig)
+	case sdk.CryptedFilesystemProvider:
+		return NewCryptFs(connectionID, m.MappedPath, "", m.FsConfig.CryptConfig)
+	case sdk.SFTPFilesystemProvider:
+		return NewSFTPFs(connectionID, "", m.MappedPath, forbiddenSelfUsers, m.FsConfig.SFTPConfig)
+	case sdk.HTTPFilesystemProvider:
+		return NewHTTPFs(connectionID, m.MappedPath, "", m.FsConfig.HTTPConfig)
+	default:
+		return NewOsFs(connectionID, m.MappedPath, "", &m.FsConfig.OSConfig), nil
+	}
+}
+
+func (m *UnionFsMember) isEqual(other UnionFsMember) bool {
+	if m.MappedPath != other.MappedPath || m.ReadOnly != other.ReadOnly {
+		return false
+	}
+	return m.FsConfig.IsEqual(other.FsConfig)
+}
+
+func (m *UnionFsMember) validate(additionalData string) error {
+	if m.FsConfig.Provider == UnionFilesystemProvider {
+		return errors.New("nested union filesystems are not allowed")
+	}
+	switch m.FsConfig.Provider {
+	case sdk.LocalFilesystemProvider, sdk.CryptedFilesystemProvider:
+		if m.MappedPath == "" {
+			return errors.New("the mapped path is mandatory for local filesystems")
+		}
+	}
+	if m.MappedPath != "" {
+		if !filepath.IsAbs(m.MappedPath) {
+			return fmt.Errorf("invalid mapped path %q, it must be an absolute path", m.MappedPath)
+		}
+		m.MappedPath = filepath.Clean(m.MappedPath)
+	}
+	return m.FsConfig.Validate(additionalData)
+}
+
+// UnionFsConfig defines the configuration for a filesystem merging several backends
+type UnionFsConfig struct {
+	// Merged backends, the order matters: if a path exists inside multiple
+	// members the first one wins
+	Members []UnionFsMember `json:"members,omitempty"`
+	// Policy used to choose the member for new files and directories
+	WritePolicy string `json:"write_policy,omitempty"`
+}
+
+func (c *UnionFsConfig) isEqual(other UnionFsConfig) bool {
+	if c.WritePolicy != other.WritePolicy || len(c.Members) != len(other.Members) {
+		return false
+	}
+	for idx := range c.Members {
+		if !c.Members[idx].isEqual(other.Members[idx]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *UnionFsConfig) isSameResource(other UnionFsConfig) bool {
+	if len(c.Members) != len(other.Members) {
+		return false
+	}
+	for idx := range c.Members {
+		if c.Members[idx].MappedPath != other.Members[idx].MappedPath {
+			return false
+		}
+		if !c.Members[idx].FsConfig.IsSameResource(other.Members[idx].FsConfig) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *UnionFsConfig) getACopy() UnionFsConfig {
+	config := UnionFsConfig{
+		WritePolicy: c.WritePolicy,
+	}
+	for idx := range c.Members {
+		config.Members = append(config.Members, UnionFsMember{
+			MappedPath: c.Members[idx].MappedPath,
+			ReadOnly:   c.Members[idx].ReadOnly,
+			FsConfig:   c.Members[idx].FsConfig.GetACopy(),
+		})
+	}
+	return config
+}
+
+// ValidateAndEncryptCredentials validates the configuration and encrypts
+// the members credentials if they are in plain text
+func (c *UnionFsConfig) ValidateAndEncryptCredentials(additionalData string) error {
+	if c.WritePolicy == "" {
+		c.WritePolicy = UnionWritePolicyFirstWritable
+	}
+	if !util.Contains(unionWritePolicies, c.WritePolicy) {
+		return util.NewI18nError(
+			util.NewValidationError(fmt.Sprintf("could not validate union fs config: invalid write policy %q", c.WritePolicy)),
+			util.I18nErrorFsValidation,
+		)
+	}
+	if len(c.Members) < 2 {
+		return util.NewI18nError(
+			util.NewValidationError("could not validate union fs config: at least two members are required"),
+			util.I18nErrorFsValidation,
+		)
+	}
+	hasWritableMembers := false
+	for idx := range c.Members {
+		if err := c.Members[idx].validate(additionalData); err != nil {
+			var errI18n *util.I18nError
+			errValidation := util.NewValidationError(fmt.Sprintf("could not validate union fs member %d: %v", idx, err))
+			if errors.As(err, &errI18n) {
+				return util.NewI18nError(errValidation, errI18n.Message)
+			}
+			return util.NewI18nError(errValidation, util.I18nErrorFsValidation)
+		}
+		if !c.Members[idx].ReadOnly {
+			hasWritableMembers = true
+		}
+	}
+	if !hasWritableMembers {
+		return util.NewI18nError(
+			util.NewValidationError("could not validate union fs config: at least a writable member is required"),
+			util.I18nErrorFsValidation,
+		)
+	}
+	return nil
+}
+
+type unionFsMember struct {
+	fs       Fs
+	readOnly bool
+}
+
+// UnionFs is a Fs implementation that merges several backends under a single tree.
+// The paths handled by this Fs are the paths relative to the mount path,
+// they are resolved inside each member as needed
+type UnionFs struct {
+	connectionID string
+	// if not empty this fs is mouted as virtual folder in the specified path
+	mountPath   string
+	writePolicy string
+	members     []unionFsMember
+}
+
+// NewUnionFs returns a UnionFs object that allows to interact with the configured backends
+// as a single filesystem. The forbidden self users are used for the SFTP members
+func NewUnionFs(connectionID, mountPath string, forbiddenSelfUsers []string, config UnionFsConfig) (Fs, error) {
+	fs := &UnionFs{
+		connectionID: connectionID,
+		mountPath:    getMountPath(mountPath),
+		writePolicy:  config.WritePolicy,
+	}
+	for idx := range config.Members {
+		member := &config.Members[idx]
+		if member.FsConfig.Provider == UnionFilesystemProvider {
+			fs.Close()
+			return nil, errors.New("nested union filesystems are not allowed")
+		}
+		memberFs, err := member.getFilesystem(connectionID, forbiddenSelfUsers)
+		if err != nil {
+			fs.Close()
+			return nil, fmt.Errorf("unable to create union fs member %d: %w", idx, err)
+		}
+		fs.members = append(fs.members, unionFsMember{
+			fs:       memberFs,
+			readOnly: member.ReadOnly,
+		})
+	}
+	if len(fs.members) == 0 {
+		return nil, errors.New("union fs: no member configured")
+	}
+	return fs, nil
+}
+
+// Name returns the name for the Fs implementation
+func (fs *UnionFs) Name() string {
+	return fmt.Sprintf("%s with %d members", unionFsName, len(fs.members))
+}
+
+// ConnectionID returns the connection ID associated to this Fs implementation
+func (fs *UnionFs) ConnectionID() string {
+	return fs.connectionID
+}
+
+// Stat returns a FileInfo describing the named file
+func (fs *UnionFs) Stat(name string) (os.FileInfo, error) {
+	var lastErr error
+	for _, m := range fs.members {
+		fsPath, err := m.fs.ResolvePath(name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		info, err := m.fs.Stat(fsPath)
+		if err == nil {
+			return info, nil
+		}
+		lastErr = err
+	}
+	return nil, fs.getNotExistError(lastErr)
+}
+
+// Lstat returns a FileInfo describing the named file
+func (fs *UnionFs) Lstat(name string) (os.FileInfo, error) {
+	m, fsPath, err := fs.getMemberForPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return m.fs.Lstat(fsPath)
+}
+
+// Open opens the named file for reading
+func (fs *UnionFs) Open(name string, offset int64) (File, PipeReader, func(), error) {
+	m, fsPath, err := fs.getMemberForPath(name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return m.fs.Open(fsPath, offset)
+}
+
+// OpenWithInfo opens the named file for reading using the member containing it
+func (fs *UnionFs) OpenWithInfo(name string, offset int64, info os.FileInfo) (File, PipeReader, func(), error) {
+	m, fsPath, err := fs.getMemberForPath(name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return OpenWithInfo(m.fs, fsPath, offset, info)
+}
+
+// Create creates or opens the named file for writing. Existing files are
+// written inside their member, new files inside the member selected by the write policy
+func (fs *UnionFs) Create(name string, flag, checks int) (File, PipeWriter, func(), error) {
+	m, fsPath, err := fs.getMemberForPath(name)
+	if err == nil {
+		if m.readOnly {
+			return nil, nil, nil, os.ErrPermission
+		}
+		return m.fs.Create(fsPath, flag, checks)
+	}
+	if !fs.IsNotExist(err) {
+		return nil, nil, nil, err
+	}
+	m, fsPath, err = fs.getMemberForWrite(name)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return m.fs.Create(fsPath, flag, checks)
+}
+
+// Rename renames (moves) source to target. Source and target must be handled by
+// the same member, moving files between members is not supported
+func (fs *UnionFs) Rename(source, target string) (int, int64, error) {
+	m, sourcePath, err := fs.getWritableMemberForPath(source)
+	if err != nil {
+		return -1, -1, err
+	}
+	targetPath, err := m.fs.ResolvePath(target)
+	if err != nil {
+		return -1, -1, err
+	}
+	return m.fs.Rename(sourcePath, targetPath)
+}
+
+// Remove removes the named file or (empty) directory from all the members
+func (fs *UnionFs) Remove(name string, isDir bool) error {
+	var toRemove []string
+	var members []unionFsMember
+
+	for _, m := range fs.members {
+		fsPath, err := m.fs.ResolvePath(name)
+		if err != nil {
+			continue
+		}
+		if _, err := m.fs.Lstat(fsPath); err != nil {
+			continue
+		}
+		if m.readOnly {
+			return os.ErrPermission
+		}
+		toRemove = append(toRemove, fsPath)
+		members = append(members, m)
+	}
+	if len(members) == 0 {
+		return os.ErrNotExist
+	}
+	for idx, m := range members {
+		if err := m.fs.Remove(toRemove[idx], isDir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Mkdir creates a new directory inside the member selected by the write policy
+func (fs *UnionFs) Mkdir(name string) error {
+	if _, err := fs.Lstat(name); err == nil {
+		return os.ErrExist
+	}
+	m, fsPath, err := fs.getMemberForWrite(name)
+	if err != nil {
+		return err
+	}
+	return m.fs.Mkdir(fsPath)
+}
+
+// Symlink creates source as a symbolic link to target
+func (fs *UnionFs) Symlink(source, target string) error {
+	m, targetPath, err := fs.getMemberForWrite(target)
+	if err != nil {
+		return err
+	}
+	sourcePath, err := m.fs.ResolvePath(source)
+	if err != nil {
+		return err
+	}
+	return m.fs.Symlink(sourcePath, targetPath)
+}
+
+// Readlink returns the destination of the named symbolic link
+func (fs *UnionFs) Readlink(name string) (string, error) {
+	m, fsPath, err := fs.getMemberForPath(name)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := m.fs.Readlink(fsPath)
+	if err != nil {
+		return "", err
+	}
+	if fs.mountPath != "" {
+		return path.Join(fs.mountPath, resolved), nil
+	}
+	return resolved, nil
+}
+
+// Chown changes the numeric uid and gid of the named file.
+func (fs *UnionFs) Chown(name string, uid int, gid int) error {
+	m, fsPath, err := fs.getWritableMemberForPath(name)
+	if err != nil {
+		return err
+	}
+	return m.fs.Chown(fsPath, uid, gid)
+}
+
+// Chmod changes the mode of the named file to mode.
+func (fs *UnionFs) Chmod(name string, mode os.FileMode) error {
+	m, fsPath, err := fs.getWritableMemberForPath(name)
+	if err != nil {
+		return err
+	}
+	return m.fs.Chmod(fsPath, mode)
+}
+
+// Chtimes changes the access and modification times of the named file.
+func (fs *UnionFs) Chtimes(name string, atime, mtime time.Time, isUploading bool) error {
+	m, fsPath, err := fs.getWritableMemberForPath(name)
+	if err != nil {
+		return err
+	}
+	return m.fs.Chtimes(fsPath, atime, mtime, isUploading)
+}
+
+// Truncate changes the size of the named file.
+func (fs *UnionFs) Truncate(name string, size int64) error {
+	m, fsPath, err := fs.getWritableMemberForPath(name)
+	if err != nil {
+		return err
+	}
+	return m.fs.Truncate(fsPath, size)
+}
+
+// ReadDir reads the directory named by dirname and returns the merged list
+// of directory entries. If an entry exists inside multiple members the
+// first one wins
+func (fs *UnionFs) ReadDir(dirname string) ([]os.FileInfo, error) {
+	var result []os.FileInfo
+	var lastErr error
+
+	found := false
+	names := make(map[string]bool)
+	for _, m := range fs.members {
+		fsPath, err := m.fs.ResolvePath(dirname)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		list, err := m.fs.ReadDir(fsPath)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		found = true
+		for _, info := range list {
+			if names[info.Name()] {
+				continue
+			}
+			names[info.Name()] = true
+			result = append(result, info)
+		}
+	}
+	if !found {
+		return nil, fs.getNotExistError(lastErr)
+	}
+	return result, nil
+}
+
+// IsUploadResumeSupported returns true if resuming uploads is supported.
+// Resuming uploads is not supported, the member used for a resumed upload
+// may not be able to append data
+func (*UnionFs) IsUploadResumeSupported() bool {
+	return false
+}
+
+// IsConditionalUploadResumeSupported returns if resuming uploads is supported
+// for the specified size
+func (*UnionFs) IsConditionalUploadResumeSupported(_ int64) bool {
+	return false
+}
+
+// IsAtomicUploadSupported returns true if atomic upload is supported.
+// The temporary file may be created inside a different member than the
+// target one, so atomic uploads are not supported
+func (*UnionFs) IsAtomicUploadSupported() bool {
+	return false
+}
+
+// IsNotExist returns a boolean indicating whether the error is known to
+// report that a file or directory does not exist
+func (fs *UnionFs) IsNotExist(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	for _, m := range fs.members {
+		if m.fs.IsNotExist(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPermission returns a boolean indicating whether the error is known to
+// report that permission is denied.
+func (fs *UnionFs) IsPermission(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, os.ErrPermission) {
+		return true
+	}
+	for _, m := range fs.members {
+		if m.fs.IsPermission(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsNotSupported returns true if the error indicate an unsupported operation
+func (fs *UnionFs) IsNotSupported(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, ErrVfsUnsupported) {
+		return true
+	}
+	for _, m := range fs.members {
+		if m.fs.IsNotSupported(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRootPath creates the root directory for all the members if missing
+func (fs *UnionFs) CheckRootPath(username string, uid int, gid int) bool {
+	result := true
+	for _, m := range fs.members {
+		if !m.fs.CheckRootPath(username, uid, gid) {
+			result = false
+		}
+	}
+	return result
+}
+
+// ScanRootDirContents returns the number of files contained in the root
+// directory of all the members and their size
+func (fs *UnionFs) ScanRootDirContents() (int, int64, error) {
+	numFiles := 0
+	size := int64(0)
+	for _, m := range fs.members {
+		n, s, err := m.fs.ScanRootDirContents()
+		if err != nil {
+			return numFiles, size, err
+		}
+		numFiles += n
+		size += s
+	}
+	return numFiles, size, nil
+}
+
+// CheckMetadata checks the metadata consistency for all the members
+func (fs *UnionFs) CheckMetadata() error {
+	for _, m := range fs.members {
+		if err := m.fs.CheckMetadata(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDirSize returns the number of files and the size for a folder
+// including any subfolders, the contents of all the members are included
+func (fs *UnionFs) GetDirSize(dirname string) (int, int64, error) {
+	numFiles := 0
+	size := int64(0)
+	found := false
+	for _, m := range fs.members {
+		fsPath, err := m.fs.ResolvePath(dirname)
+		if err != nil {
+			continue
+		}
+		if _, err := m.fs.Stat(fsPath); err != nil {
+			continue
+		}
+		found = true
+		n, s, err := m.fs.GetDirSize(fsPath)
+		if err != nil {
+			return numFiles, size, err
+		}
+		numFiles += n
+		size += s
+	}
+	if !found {
+		return numFiles, size, os.ErrNotExist
+	}
+	return numFiles, size, nil
+}
+
+// GetAtomicUploadPath returns the path to use for an atomic upload
+func (*UnionFs) GetAtomicUploadPath(_ string) string {
+	return ""
+}
+
+// GetRelativePath returns the path for a file relative to the user's home dir.
+// This is the path as seen by SFTPGo users
+func (fs *UnionFs) GetRelativePath(name string) string {
+	rel := util.CleanPath(name)
+	if fs.mountPath != "" {
+		rel = path.Join(fs.mountPath, rel)
+	}
+	return rel
+}
+
+// Walk walks the file tree rooted at root, calling walkFn for each file or
+// directory in the tree, including root. Paths existing inside multiple
+// members are visited once
+func (fs *UnionFs) Walk(root string, walkFn filepath.WalkFunc) error {
+	visited := make(map[string]bool)
+	var skippedDirs []string
+
+	isSkipped := func(name string) bool {
+		for _, dir := range skippedDirs {
+			if name == dir || strings.HasPrefix(name, strings.TrimSuffix(dir, "/")+"/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	found := false
+	for _, m := range fs.members {
+		memberRoot, err := m.fs.ResolvePath(root)
+		if err != nil {
+			continue
+		}
+		if _, err := m.fs.Stat(memberRoot); err != nil {
+			continue
+		}
+		found = true
+		err = m.fs.Walk(memberRoot, func(walkedPath string, info os.FileInfo, err error) error {
+			name := m.fs.GetRelativePath(walkedPath)
+			if visited[name] || isSkipped(name) {
+				return nil
+			}
+			visited[name] = true
+			walkErr := walkFn(name, info, err)
+			if errors.Is(walkErr, filepath.SkipDir) && info != nil && info.IsDir() {
+				skippedDirs = append(skippedDirs, name)
+			}
+			return walkErr
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if !found {
+		err := os.ErrNotExist
+		walkFn(root, nil, err) //nolint:errcheck
+		return err
+	}
+	return nil
+}
+
+// Join joins any number of path elements into a single path
+func (*UnionFs) Join(elem ...string) string {
+	return path.Join(elem...)
+}
+
+// HasVirtualFolders returns true if folders are emulated
+func (*UnionFs) HasVirtualFolders() bool {
+	return false
+}
+
+// ResolvePath returns the matching filesystem path for the specified virtual path.
+// The returned path is relative to the mount path and it is resolved inside
+// each member when required
+func (fs *UnionFs) ResolvePath(virtualPath string) (string, error) {
+	if fs.mountPath != "" {
+		virtualPath = strings.TrimPrefix(virtualPath, fs.mountPath)
+	}
+	return util.CleanPath(virtualPath), nil
+}
+
+// GetMimeType returns the content type
+func (fs *UnionFs) GetMimeType(name string) (string, error) {
+	m, fsPath, err := fs.getMemberForPath(name)
+	if err != nil {
+		return "", err
+	}
+	return m.fs.GetMimeType(fsPath)
+}
+
+// Close closes the fs
+func (fs *UnionFs) Close() error {
+	var result error
+	for _, m := range fs.members {
+		if err := m.fs.Close(); err != nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// GetAvailableDiskSize returns the available size for the member used to
+// create new files inside the specified directory
+func (fs *UnionFs) GetAvailableDiskSize(dirName string) (*sftp.StatVFS, error) {
+	m, fsPath, err := fs.getMemberForWrite(path.Join(dirName, "placeholder"))
+	if err != nil {
+		return nil, ErrStorageSizeUnavailable
+	}
+	return m.fs.GetAvailableDiskSize(path.Dir(fsPath))
+}
+
+func (fs *UnionFs) getNotExistError(err error) error {
+	if err == nil {
+		return os.ErrNotExist
+	}
+	return err
+}
+
+// getMemberForPath returns the first member containing the specified path
+// and the path resolved inside the member
+func (fs *UnionFs) getMemberForPath(name string) (unionFsMember, string, error) {
+	var lastErr error
+	for _, m := range fs.members {
+		fsPath, err := m.fs.ResolvePath(name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if _, err := m.fs.Lstat(fsPath); err != nil {
+			lastErr = err
+			continue
+		}
+		return m, fsPath, nil
+	}
+	return unionFsMember{}, "", fs.getNotExistError(lastErr)
+}
+
+func (fs *UnionFs) getWritableMemberForPath(name string) (unionFsMember, string, error) {
+	m, fsPath, err := fs.getMemberForPath(name)
+	if err != nil {
+		return m, fsPath, err
+	}
+	if m.readOnly {
+		return m, fsPath, os.ErrPermission
+	}
+	return m, fsPath, nil
+}
+
+// getMemberForWrite returns the member, selected using the configured write policy,
+// to use for creating the specified path. Only the writable members containing
+// the parent directory are considered
+func (fs *UnionFs) getMemberForWrite(name string) (unionFsMember, string, error) {
+	var candidates []unionFsMember
+	var resolvedPaths []string
+	var lastErr error
+
+	parent := path.Dir(util.CleanPath(name))
+	for _, m := range fs.members {
+		if m.readOnly {
+			continue
+		}
+		fsPath, err := m.fs.ResolvePath(name)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if parent != "/" && !m.fs.HasVirtualFolders() {
+			parentPath, err := m.fs.ResolvePath(parent)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			info, err := m.fs.Stat(parentPath)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if !info.IsDir() {
+				lastErr = fmt.Errorf("%q is not a directory", parent)
+				continue
+			}
+		}
+		if fs.writePolicy != UnionWritePolicyMostFreeSpace {
+			return m, fsPath, nil
+		}
+		candidates = append(candidates, m)
+		resolvedPaths = append(resolvedPaths, fsPath)
+	}
+	if len(candidates) == 0 {
+		if lastErr == nil {
+			lastErr = os.ErrPermission
+		}
+		return unionFsMember{}, "", lastErr
+	}
+	selected := 0
+	maxFreeSpace := uint64(0)
+	for idx, m := range candidates {
+		stat, err := m.fs.GetAvailableDiskSize(path.Dir(resolvedPaths[idx]))
+		if err != nil {
+			fsLog(fs, logger.LevelDebug, "unable to get the available size for member %q: %v", m.fs.Name(), err)
+			continue
+		}
+		if freeSpace := stat.FreeSpace(); freeSpace > maxFreeSpace {
+			maxFreeSpace = freeSpace
+			selected = idx
+		}
+	}
+	return candidates[selected], resolvedPaths[selected], nil
+}
@@ -24,6 +24,7 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -55,6 +56,17 @@ const (
 
 var (
 	validAzAccessTier = []string{"", "Archive", "Hot", "Cool"}
+	// the allowed S3 Object Lock retention modes
+	validS3ObjectLockModes = []string{"GOVERNANCE", "COMPLIANCE"}
+	// ErrObjectLocked is returned when trying to delete or rename an object protected by Object Lock
+	ErrObjectLocked = errors.New("the object is protected by Object Lock")
+	// the allowed retrieval tiers to restore S3 archived objects
+	validS3RestoreTiers = []string{"Standard", "Bulk", "Expedited"}
+	// the allowed target tiers and priorities to rehydrate Azure archived blobs
+	validAzRehydrateTiers      = []string{"Hot", "Cool"}
+	validAzRehydratePriorities = []string{"Standard", "High"}
+	// ErrRestoreInProgress is returned when reading an archived object that is being restored
+	ErrRestoreInProgress = errors.New("the file is archived, a restore is in progress, please retry later")
 	// ErrStorageSizeUnavailable is returned if the storage backend does not support getting the size
 	ErrStorageSizeUnavailable = errors.New("unable to get available size for this storage backend")
 	// ErrVfsUnsupported defines the error for an unsupported VFS operation
@@ -66,6 +78,7 @@ var (
 	readMetadata         int
 	resumeMaxSize        int64
 	uploadMode           int
+	s3MaxUploadMemory    int64
 )
 
 // SetAllowSelfConnections sets the desired behaviour for self connections
@@ -109,6 +122,12 @@ func SetUploadMode(val int) {
 	uploadMode = val
 }
 
+// SetS3MaxUploadMemory sets the maximum memory, as MB, for the buffers
+// of the concurrent S3 multipart uploads
+func SetS3MaxUploadMemory(val int64) {
+	s3MaxUploadMemory = val * 1024 * 1024
+}
+
 // Fs defines the interface for filesystem backends
 type Fs interface {
 	Name() string
@@ -167,6 +186,52 @@ type FsFileCopier interface {
 	CopyFile(source, target string, srcSize int64) error
 }
 
+// FsAccessTierSetter is a Fs that allows to change the access tier of the existing files
+type FsAccessTierSetter interface {
+	Fs
+	SetAccessTier(name, tier string) error
+}
+
+// FsVersioner is a Fs that allows to list and restore the previous versions of a file
+type FsVersioner interface {
+	Fs
+	GetVersions(name string) ([]FileVersion, error)
+	RestoreVersion(name, versionID string) error
+}
+
+// FsArchiveRestorer is a Fs that restores the archived objects, for example S3 Glacier
+// objects or Azure Archive tier blobs, when they are read. Reading an archived object
+// starts the restore and returns ErrRestoreInProgress until the object is available
+type FsArchiveRestorer interface {
+	Fs
+	IsArchiveRestored(name string) (bool, error)
+}
+
+// FsInfoOpener is a Fs that can open a file for reading reusing the file info already
+// available to the caller, so the file is not stat'ed again to lookup the read cache
+type FsInfoOpener interface {
+	Fs
+	OpenWithInfo(name string, offset int64, info os.FileInfo) (File, PipeReader, func(), error)
+}
+
+// FsDeduplicator is a Fs that stores the content of the uploaded files inside a
+// content-addressed store so identical files share the same storage
+type FsDeduplicator interface {
+	Fs
+	Deduplicate(name string) error
+}
+
+// FileVersion defines a stored version of a file
+type FileVersion struct {
+	ID           string    `json:"id"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	// IsLatest is true for the current version of the file
+	IsLatest bool `json:"is_latest"`
+	// IsDeleteMarker is true if this version marks the file as deleted
+	IsDeleteMarker bool `json:"is_delete_marker"`
+}
+
 // File defines an interface representing a SFTPGo file
 type File interface {
 	io.Reader
@@ -235,6 +300,40 @@ func (q *QuotaCheckResult) GetRemainingFiles() int {
 type S3FsConfig struct {
 	sdk.BaseS3FsConfig
 	AccessSecret *kms.Secret `json:"access_secret,omitempty"`
+	// Maximum memory, as MB, for the buffers of a single multipart upload. The upload
+	// concurrency is reduced so that the parts uploaded in parallel fit this limit.
+	// 0 means no limit
+	UploadMaxMemory int64 `json:"upload_max_memory,omitempty"`
+	// Object Lock retention mode for the uploaded objects: GOVERNANCE or COMPLIANCE.
+	// Empty means that no retention is set by SFTPGo
+	ObjectLockMode string `json:"object_lock_mode,omitempty"`
+	// Object Lock retention period, as days. The uploaded objects are retained
+	// until the upload time plus this period
+	ObjectLockRetentionDays int `json:"object_lock_retention_days,omitempty"`
+	// Number of days a restored copy of an archived object, for example
+	// S3 Glacier, remains available. 0 means that SFTPGo does not restore
+	// the archived objects
+	RestoreDays int `json:"restore_days,omitempty"`
+	// Retrieval tier for the restore requests: Standard, Bulk or Expedited.
+	// Empty means Standard
+	RestoreTier string `json:"restore_tier,omitempty"`
+	// If enabled the requester, and not the bucket owner, pays for the requests
+	// and the data transfer costs
+	RequesterPays bool `json:"requester_pays,omitempty"`
+	// SSE-KMS key ID, ARN or alias used to encrypt the uploaded and the server
+	// side copied objects. Empty means the bucket default encryption
+	SSEKMSKeyID string `json:"sse_kms_key_id,omitempty"`
+	// Ordered list of alternative endpoints and/or regions to use if the
+	// configured endpoint is not healthy. The bucket must be reachable,
+	// with the same name and credentials, using each of them
+	FailoverEndpoints []S3FailoverEndpoint `json:"failover_endpoints,omitempty"`
+}
+
+// S3FailoverEndpoint defines an alternative endpoint for the S3 backend.
+// Empty values mean the ones configured for the primary endpoint
+type S3FailoverEndpoint struct {
+	Endpoint string `json:"endpoint,omitempty"`
+	Region   string `json:"region,omitempty"`
 }
 
 // HideConfidentialData hides confidential data
@@ -278,9 +377,36 @@ func (c *S3FsConfig) isEqual(other S3FsConfig) bool {
 	if c.SkipTLSVerify != other.SkipTLSVerify {
 		return false
 	}
+	if c.ObjectLockMode != other.ObjectLockMode {
+		return false
+	}
+	if c.ObjectLockRetentionDays != other.ObjectLockRetentionDays {
+		return false
+	}
+	if c.RestoreDays != other.RestoreDays || c.RestoreTier != other.RestoreTier {
+		return false
+	}
+	if c.RequesterPays != other.RequesterPays || c.SSEKMSKeyID != other.SSEKMSKeyID {
+		return false
+	}
+	if !c.areFailoverEndpointsEqual(other) {
+		return false
+	}
 	return c.isSecretEqual(other)
 }
 
+func (c *S3FsConfig) areFailoverEndpointsEqual(other S3FsConfig) bool {
+	if len(c.FailoverEndpoints) != len(other.FailoverEndpoints) {
+		return false
+	}
+	for idx := range c.FailoverEndpoints {
+		if c.FailoverEndpoints[idx] != other.FailoverEndpoints[idx] {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *S3FsConfig) areMultipartFieldsEqual(other S3FsConfig) bool {
 	if c.UploadPartSize != other.UploadPartSize {
 		return false
@@ -300,7 +426,7 @@ func (c *S3FsConfig) areMultipartFieldsEqual(other S3FsConfig) bool {
 	if c.UploadPartMaxTime != other.UploadPartMaxTime {
 		return false
 	}
-	return true
+	return c.UploadMaxMemory == other.UploadMaxMemory
 }
 
 func (c *S3FsConfig) isSecretEqual(other S3FsConfig) bool {
@@ -383,6 +509,17 @@ func (c *S3FsConfig) checkPartSizeAndConcurrency() error {
 			util.I18nErrorDLConcurrencyInvalid,
 		)
 	}
+	// 5 MB is the default upload part size
+	minMemory := c.UploadPartSize
+	if minMemory == 0 {
+		minMemory = 5
+	}
+	if c.UploadMaxMemory < 0 || (c.UploadMaxMemory > 0 && c.UploadMaxMemory < minMemory) {
+		return util.NewI18nError(
+			fmt.Errorf("invalid upload max memory: %v, it cannot be lower than the upload part size", c.UploadMaxMemory),
+			util.I18nErrorULMaxMemoryInvalid,
+		)
+	}
 	return nil
 }
 
@@ -396,6 +533,11 @@ func (c *S3FsConfig) isSameResource(other S3FsConfig) bool {
 	return c.Region == other.Region
 }
 
+func (c *S3FsConfig) getReadCacheResource() string {
+	return getReadCacheResource(s3fsName, c.Endpoint, c.Region, c.Bucket, c.AccessKey, c.AccessSecret.GetPayload(),
+		c.RoleARN)
+}
+
 // validate returns an error if the configuration is not valid
 func (c *S3FsConfig) validate() error {
 	if c.AccessSecret == nil {
@@ -423,13 +565,101 @@ func (c *S3FsConfig) validate() error {
 	}
 	c.StorageClass = strings.TrimSpace(c.StorageClass)
 	c.ACL = strings.TrimSpace(c.ACL)
+	c.SSEKMSKeyID = strings.TrimSpace(c.SSEKMSKeyID)
+	if err := c.checkObjectLock(); err != nil {
+		return err
+	}
+	if err := c.checkRestore(); err != nil {
+		return err
+	}
+	if err := c.checkFailoverEndpoints(); err != nil {
+		return err
+	}
 	return c.checkPartSizeAndConcurrency()
 }
 
+func (c *S3FsConfig) checkFailoverEndpoints() error {
+	if len(c.FailoverEndpoints) > 5 {
+		return util.NewI18nError(
+			fmt.Errorf("too many failover endpoints: %d, the maximum allowed is 5", len(c.FailoverEndpoints)),
+			util.I18nErrorFailoverEndpointsInvalid,
+		)
+	}
+	for idx := range c.FailoverEndpoints {
+		ep := &c.FailoverEndpoints[idx]
+		ep.Endpoint = strings.TrimSpace(ep.Endpoint)
+		ep.Region = strings.TrimSpace(ep.Region)
+		if ep.Endpoint == "" && ep.Region == "" {
+			return util.NewI18nError(
+				fmt.Errorf("invalid failover endpoint %d: endpoint and region cannot be both empty", idx+1),
+				util.I18nErrorFailoverEndpointsInvalid,
+			)
+		}
+		if ep.Endpoint == c.Endpoint && (ep.Region == "" || ep.Region == c.Region) {
+			return util.NewI18nError(
+				fmt.Errorf("invalid failover endpoint %d: it must differ from the primary endpoint", idx+1),
+				util.I18nErrorFailoverEndpointsInvalid,
+			)
+		}
+	}
+	return nil
+}
+
+func (c *S3FsConfig) checkRestore() error {
+	c.RestoreTier = strings.TrimSpace(c.RestoreTier)
+	if c.RestoreDays == 0 {
+		c.RestoreTier = ""
+		return nil
+	}
+	if c.RestoreDays < 0 || c.RestoreDays > 36500 {
+		return util.NewI18nError(
+			fmt.Errorf("invalid restore days: %d, it must be between 0 and 36500", c.RestoreDays),
+			util.I18nErrorArchiveRestoreInvalid,
+		)
+	}
+	if c.RestoreTier == "" {
+		c.RestoreTier = validS3RestoreTiers[0]
+	}
+	if !util.Contains(validS3RestoreTiers, c.RestoreTier) {
+		return util.NewI18nError(
+			fmt.Errorf("invalid restore tier %q, valid values: %v", c.RestoreTier, validS3RestoreTiers),
+			util.I18nErrorArchiveRestoreInvalid,
+		)
+	}
+	return nil
+}
+
+func (c *S3FsConfig) checkObjectLock() error {
+	c.ObjectLockMode = strings.ToUpper(strings.TrimSpace(c.ObjectLockMode))
+	if c.ObjectLockMode == "" {
+		c.ObjectLockRetentionDays = 0
+		return nil
+	}
+	if !util.Contains(validS3ObjectLockModes, c.ObjectLockMode) {
+		return util.NewI18nError(
+			fmt.Errorf("invalid object lock mode %q, valid values: %v", c.ObjectLockMode, validS3ObjectLockModes),
+			util.I18nErrorObjectLockInvalid,
+		)
+	}
+	if c.ObjectLockRetentionDays <= 0 || c.ObjectLockRetentionDays > 36500 {
+		return util.NewI18nError(
+			fmt.Errorf("invalid object lock retention days: %d, it must be between 1 and 36500", c.ObjectLockRetentionDays),
+			util.I18nErrorObjectLockInvalid,
+		)
+	}
+	return nil
+}
+
 // GCSFsConfig defines the configuration for Google Cloud Storage based filesystem
 type GCSFsConfig struct {
 	sdk.BaseGCSFsConfig
 	Credentials *kms.Secret `json:"credentials,omitempty"`
+	// Customer-managed Cloud KMS key used to encrypt the uploaded and the copied
+	// objects, for example "projects/P/locations/L/keyRings/R/cryptoKeys/K".
+	// Empty means the bucket default encryption
+	KMSKeyName string `json:"kms_key_name,omitempty"`
+	// Project to bill for the requests, required to access Requester Pays buckets
+	UserProject string `json:"user_project,omitempty"`
 }
 
 // HideConfidentialData hides confidential data
@@ -484,6 +714,9 @@ func (c *GCSFsConfig) isEqual(other GCSFsConfig) bool {
 	if c.UploadPartMaxTime != other.UploadPartMaxTime {
 		return false
 	}
+	if c.KMSKeyName != other.KMSKeyName || c.UserProject != other.UserProject {
+		return false
+	}
 	if c.Credentials == nil {
 		c.Credentials = kms.NewEmptySecret()
 	}
@@ -497,6 +730,10 @@ func (c *GCSFsConfig) isSameResource(other GCSFsConfig) bool {
 	return c.Bucket == other.Bucket
 }
 
+func (c *GCSFsConfig) getReadCacheResource() string {
+	return getReadCacheResource(gcsfsName, c.Bucket, strconv.Itoa(c.AutomaticCredentials), c.Credentials.GetPayload())
+}
+
 // validate returns an error if the configuration is not valid
 func (c *GCSFsConfig) validate() error {
 	if c.Credentials == nil || c.AutomaticCredentials == 1 {
@@ -522,6 +759,13 @@ func (c *GCSFsConfig) validate() error {
 	}
 	c.StorageClass = strings.TrimSpace(c.StorageClass)
 	c.ACL = strings.TrimSpace(c.ACL)
+	c.KMSKeyName = strings.TrimSpace(c.KMSKeyName)
+	c.UserProject = strings.TrimSpace(c.UserProject)
+	if c.KMSKeyName != "" && (!strings.HasPrefix(c.KMSKeyName, "projects/") ||
+		!strings.Contains(c.KMSKeyName, "/cryptoKeys/")) {
+		return fmt.Errorf("invalid KMS key name %q, expected format: projects/<project>/locations/<location>/keyRings/<keyring>/cryptoKeys/<key>",
+			c.KMSKeyName)
+	}
 	if c.UploadPartSize < 0 {
 		c.UploadPartSize = 0
 	}
@@ -539,6 +783,23 @@ type AzBlobFsConfig struct {
 	AccountKey *kms.Secret `json:"account_key,omitempty"`
 	// Shared access signature URL, leave blank if using account/key
 	SASURL *kms.Secret `json:"sas_url,omitempty"`
+	// Access tier, Hot or Cool, for rehydrating the archived blobs when they
+	// are read. Empty means that SFTPGo does not rehydrate the archived blobs
+	RehydrateTier string `json:"rehydrate_tier,omitempty"`
+	// Rehydrate priority: Standard or High. Empty means Standard
+	RehydratePriority string `json:"rehydrate_priority,omitempty"`
+	// Access tiers to set for the blobs uploaded inside specific paths,
+	// they override the default access tier
+	TierRules []AzBlobTierRule `json:"tier_rules,omitempty"`
+}
+
+// AzBlobTierRule defines the access tier for the blobs uploaded inside a path
+type AzBlobTierRule struct {
+	// Path relative to the filesystem root, for example "/archive".
+	// The rule applies to the path and its sub-directories
+	Path string `json:"path"`
+	// Access tier: Hot, Cool or Archive
+	Tier string `json:"tier"`
 }
 
 // HideConfidentialData hides confidential data
@@ -591,9 +852,27 @@ func (c *AzBlobFsConfig) isEqual(other AzBlobFsConfig) bool {
 	if c.AccessTier != other.AccessTier {
 		return false
 	}
+	if c.RehydrateTier != other.RehydrateTier || c.RehydratePriority != other.RehydratePriority {
+		return false
+	}
+	if !c.areTierRulesEqual(other) {
+		return false
+	}
 	return c.isSecretEqual(other)
 }
 
+func (c *AzBlobFsConfig) areTierRulesEqual(other AzBlobFsConfig) bool {
+	if len(c.TierRules) != len(other.TierRules) {
+		return false
+	}
+	for idx := range c.TierRules {
+		if c.TierRules[idx] != other.TierRules[idx] {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *AzBlobFsConfig) isSecretEqual(other AzBlobFsConfig) bool {
 	if c.AccountKey == nil {
 		c.AccountKey = kms.NewEmptySecret()
@@ -706,6 +985,11 @@ func (c *AzBlobFsConfig) isSameResource(other AzBlobFsConfig) bool {
 	return c.SASURL.GetPayload() == other.SASURL.GetPayload()
 }
 
+func (c *AzBlobFsConfig) getReadCacheResource() string {
+	return getReadCacheResource(azBlobFsName, c.Endpoint, c.AccountName, c.Container, c.AccountKey.GetPayload(),
+		c.SASURL.GetPayload())
+}
+
 // validate returns an error if the configuration is not valid
 func (c *AzBlobFsConfig) validate() error {
 	if c.AccountKey == nil {
@@ -736,6 +1020,64 @@ func (c *AzBlobFsConfig) validate() error {
 	if !util.Contains(validAzAccessTier, c.AccessTier) {
 		return fmt.Errorf("invalid access tier %q, valid values: \"''%v\"", c.AccessTier, strings.Join(validAzAccessTier, ", "))
 	}
+	if err := c.checkTierRules(); err != nil {
+		return err
+	}
+	return c.checkRehydrate()
+}
+
+func (c *AzBlobFsConfig) checkTierRules() error {
+	if len(c.TierRules) > 50 {
+		return util.NewI18nError(
+			fmt.Errorf("too many tier rules: %d, the maximum allowed is 50", len(c.TierRules)),
+			util.I18nErrorTierRulesInvalid,
+		)
+	}
+	paths := make(map[string]bool)
+	for idx := range c.TierRules {
+		rule := &c.TierRules[idx]
+		rule.Tier = strings.TrimSpace(rule.Tier)
+		rule.Path = strings.TrimSpace(rule.Path)
+		if rule.Path == "" || rule.Tier == "" || !util.Contains(validAzAccessTier, rule.Tier) {
+			return util.NewI18nError(
+				fmt.Errorf("invalid tier rule %d, path: %q, tier: %q", idx+1, rule.Path, rule.Tier),
+				util.I18nErrorTierRulesInvalid,
+			)
+		}
+		rule.Path = util.CleanPath(rule.Path)
+		if paths[rule.Path] {
+			return util.NewI18nError(
+				fmt.Errorf("duplicate tier rule for path %q", rule.Path),
+				util.I18nErrorTierRulesInvalid,
+			)
+		}
+		paths[rule.Path] = true
+	}
+	return nil
+}
+
+func (c *AzBlobFsConfig) checkRehydrate() error {
+	c.RehydrateTier = strings.TrimSpace(c.RehydrateTier)
+	c.RehydratePriority = strings.TrimSpace(c.RehydratePriority)
+	if c.RehydrateTier == "" {
+		c.RehydratePriority = ""
+		return nil
+	}
+	if !util.Contains(validAzRehydrateTiers, c.RehydrateTier) {
+		return util.NewI18nError(
+			fmt.Errorf("invalid rehydrate tier %q, valid values: %v", c.RehydrateTier, validAzRehydrateTiers),
+			util.I18nErrorArchiveRestoreInvalid,
+		)
+	}
+	if c.RehydratePriority == "" {
+		c.RehydratePriority = validAzRehydratePriorities[0]
+	}
+	if !util.Contains(validAzRehydratePriorities, c.RehydratePriority) {
+		return util.NewI18nError(
+			fmt.Errorf("invalid rehydrate priority %q, valid values: %v", c.RehydratePriority, validAzRehydratePriorities),
+			util.I18nErrorArchiveRestoreInvalid,
+		)
+	}
 	return nil
 }
 
@@ -743,6 +1085,9 @@ func (c *AzBlobFsConfig) validate() error {
 type CryptFsConfig struct {
 	sdk.OSFsConfig
 	Passphrase *kms.Secret `json:"passphrase,omitempty"`
+	// Passphrases previously used, the files encrypted with these passphrases
+	// remain readable until they are re-encrypted using the current passphrase
+	OldPassphrases []*kms.Secret `json:"old_passphrases,omitempty"`
 }
 
 // HideConfidentialData hides confidential data
@@ -750,6 +1095,24 @@ func (c *CryptFsConfig) HideConfidentialData() {
 	if c.Passphrase != nil {
 		c.Passphrase.Hide()
 	}
+	for _, passphrase := range c.OldPassphrases {
+		if passphrase != nil {
+			passphrase.Hide()
+		}
+	}
+}
+
+// HasRedactedSecret returns true if the passphrase or an old passphrase is redacted
+func (c *CryptFsConfig) HasRedactedSecret() bool {
+	if c.Passphrase.IsRedacted() {
+		return true
+	}
+	for _, passphrase := range c.OldPassphrases {
+		if passphrase != nil && passphrase.IsRedacted() {
+			return true
+		}
+	}
+	return false
 }
 
 func (c *CryptFsConfig) isEqual(other CryptFsConfig) bool {
@@ -759,9 +1122,30 @@ func (c *CryptFsConfig) isEqual(other CryptFsConfig) bool {
 	if other.Passphrase == nil {
 		other.Passphrase = kms.NewEmptySecret()
 	}
+	if len(c.OldPassphrases) != len(other.OldPassphrases) {
+		return false
+	}
+	for idx, passphrase := range c.OldPassphrases {
+		if passphrase == nil || !passphrase.IsEqual(other.OldPassphrases[idx]) {
+			return false
+		}
+	}
 	return c.Passphrase.IsEqual(other.Passphrase)
 }
 
+func (c *CryptFsConfig) getOldPassphrasesCopy() []*kms.Secret {
+	if len(c.OldPassphrases) == 0 {
+		return nil
+	}
+	result := make([]*kms.Secret, 0, len(c.OldPassphrases))
+	for _, passphrase := range c.OldPassphrases {
+		if passphrase != nil {
+			result = append(result, passphrase.Clone())
+		}
+	}
+	return result
+}
+
 // ValidateAndEncryptCredentials validates the configuration and encrypts the passphrase if it is in plain text
 func (c *CryptFsConfig) ValidateAndEncryptCredentials(additionalData string) error {
 	if err := c.validate(); err != nil {
@@ -781,6 +1165,17 @@ func (c *CryptFsConfig) ValidateAndEncryptCredentials(additionalData string) err
 			)
 		}
 	}
+	for _, passphrase := range c.OldPassphrases {
+		if passphrase.IsPlain() {
+			passphrase.SetAdditionalData(additionalData)
+			if err := passphrase.Encrypt(); err != nil {
+				return util.NewI18nError(
+					util.NewValidationError(fmt.Sprintf("could not encrypt Crypt fs old passphrase: %v", err)),
+					util.I18nErrorFsValidation,
+				)
+			}
+		}
+	}
 	return nil
 }
 
@@ -799,6 +1194,14 @@ func (c *CryptFsConfig) validate() error {
 	if c.Passphrase.IsEncrypted() && !c.Passphrase.IsValid() {
 		return errors.New("invalid encrypted passphrase")
 	}
+	for _, passphrase := range c.OldPassphrases {
+		if passphrase == nil || passphrase.IsEmpty() || !passphrase.IsValidInput() {
+			return errors.New("old passphrases cannot be empty or invalid")
+		}
+		if passphrase.IsEncrypted() && !passphrase.IsValid() {
+			return errors.New("invalid encrypted old passphrase")
+		}
+	}
 	return nil
 }
 
@@ -930,6 +1333,15 @@ func isDirectory(fs Fs, path string) (bool, error) {
 	return fileInfo.IsDir(), err
 }
 
+// OpenWithInfo opens the named file for reading. The specified file info, if not nil,
+// is reused by the filesystems implementing the FsInfoOpener interface
+func OpenWithInfo(fs Fs, name string, offset int64, info os.FileInfo) (File, PipeReader, func(), error) {
+	if opener, ok := fs.(FsInfoOpener); ok && info != nil {
+		return opener.OpenWithInfo(name, offset, info)
+	}
+	return fs.Open(name, offset)
+}
+
 // IsLocalOsFs returns true if fs is a local filesystem implementation
 func IsLocalOsFs(fs Fs) bool {
 	return fs.Name() == osFsName
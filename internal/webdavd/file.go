@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"sort"
 	"sync/atomic"
 	"time"
 
@@ -200,7 +201,7 @@ func (f *webDavFile) Read(p []byte) (n int, err error) {
 			f.TransferError(common.ErrOpUnsupported)
 			return 0, common.ErrOpUnsupported
 		}
-		file, r, cancelFn, e := f.Fs.Open(f.GetFsPath(), 0)
+		file, r, cancelFn, e := vfs.OpenWithInfo(f.Fs, f.GetFsPath(), 0, f.info)
 		f.Lock()
 		if e == nil {
 			if file != nil {
@@ -216,6 +217,7 @@ func (f *webDavFile) Read(p []byte) (n int, err error) {
 		f.startOffset = 0
 		f.Unlock()
 		if e != nil {
+			f.Connection.TrackArchiveRestore(f.GetFsPath(), f.GetVirtualPath(), e)
 			return 0, f.Connection.GetFsError(f.Fs, e)
 		}
 	}
@@ -353,7 +355,7 @@ func (f *webDavFile) Seek(offset int64, whence int) (int64, error) {
 			startByte = f.info.Size() - offset
 		}
 
-		_, r, cancelFn, err := f.Fs.Open(f.GetFsPath(), startByte)
+		_, r, cancelFn, err := vfs.OpenWithInfo(f.Fs, f.GetFsPath(), startByte, f.info)
 
 		f.Lock()
 		if err == nil {
@@ -426,18 +428,30 @@ func (f *webDavFile) isTransfer() bool {
 }
 
 // DeadProps returns a copy of the dead properties held.
-// We always return nil for now, we only support the last modification time
-// and it is already included in "live" properties
+// If dead properties are not enabled we always return nil, we only support the
+// last modification time and it is already included in "live" properties
 func (f *webDavFile) DeadProps() (map[xml.Name]webdav.Property, error) {
-	return nil, nil
+	if !deadPropertiesEnabled {
+		return nil, nil
+	}
+	props, err := dataprovider.GetDAVProperties(f.Connection.User.Username, f.GetVirtualPath())
+	if err != nil {
+		// we don't want to fail the whole PROPFIND request
+		f.Connection.Log(logger.LevelWarn, "unable to get dead properties for %q: %v", f.GetVirtualPath(), err)
+		return nil, nil
+	}
+	return getDeadPropsFromDAVProperties(props), nil
 }
 
 // Patch patches the dead properties held.
-// In our minimal implementation we just support Win32LastModifiedTime and
-// getlastmodified to set the the modification time.
-// We ignore any other property and just return an OK response if the patch sets
-// the modification time, otherwise a Forbidden response
+// Win32LastModifiedTime and getlastmodified are always supported to set the
+// modification time. If dead properties are enabled any other property is
+// stored in the data provider, otherwise it is ignored and we just return an OK
+// response if the patch sets the modification time, otherwise a Forbidden response
 func (f *webDavFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	if deadPropertiesEnabled {
+		return f.patchDeadProps(patches)
+	}
 	resp := make([]webdav.Propstat, 0, len(patches))
 	hasError := false
 	for _, patch := range patches {
@@ -446,21 +460,7 @@ func (f *webDavFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error
 		for _, p := range patch.Props {
 			if status == http.StatusForbidden && !hasError {
 				if !patch.Remove && util.Contains(lastModifiedProps, p.XMLName.Local) {
-					parsed, err := parseTime(string(p.InnerXML))
-					if err != nil {
-						f.Connection.Log(logger.LevelWarn, "unsupported last modification time: %q, err: %v",
-							string(p.InnerXML), err)
-						hasError = true
-						continue
-					}
-					attrs := &common.StatAttributes{
-						Flags: common.StatAttrTimes,
-						Atime: parsed,
-						Mtime: parsed,
-					}
-					if err := f.Connection.SetStat(f.GetVirtualPath(), attrs); err != nil {
-						f.Connection.Log(logger.LevelWarn, "unable to set modification time for %q, err :%v",
-							f.GetVirtualPath(), err)
+					if err := f.setModificationTime(string(p.InnerXML)); err != nil {
 						hasError = true
 						continue
 					}
@@ -474,3 +474,109 @@ func (f *webDavFile) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error
 	}
 	return resp, nil
 }
+
+func (f *webDavFile) patchDeadProps(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	resp := make([]webdav.Propstat, 0, len(patches))
+	if !f.Connection.User.HasPerm(dataprovider.PermChtimes, path.Dir(f.GetVirtualPath())) {
+		for _, patch := range patches {
+			pstat := webdav.Propstat{Status: http.StatusForbidden}
+			for _, p := range patch.Props {
+				pstat.Props = append(pstat.Props, webdav.Property{XMLName: p.XMLName})
+			}
+			resp = append(resp, pstat)
+		}
+		return resp, nil
+	}
+	stored, err := dataprovider.GetDAVProperties(f.Connection.User.Username, f.GetVirtualPath())
+	if err != nil {
+		f.Connection.Log(logger.LevelError, "unable to get dead properties for %q: %v", f.GetVirtualPath(), err)
+		return nil, err
+	}
+	props := getDeadPropsFromDAVProperties(stored)
+	if props == nil {
+		props = make(map[xml.Name]webdav.Property)
+	}
+	isChanged := false
+	for _, patch := range patches {
+		pstat := webdav.Propstat{Status: http.StatusOK}
+		for _, p := range patch.Props {
+			pstat.Props = append(pstat.Props, webdav.Property{XMLName: p.XMLName})
+			if !patch.Remove && util.Contains(lastModifiedProps, p.XMLName.Local) {
+				if err := f.setModificationTime(string(p.InnerXML)); err != nil {
+					pstat.Status = http.StatusForbidden
+				}
+				continue
+			}
+			if patch.Remove {
+				delete(props, p.XMLName)
+			} else {
+				props[p.XMLName] = p
+			}
+			isChanged = true
+		}
+		resp = append(resp, pstat)
+	}
+	if isChanged {
+		err = dataprovider.SetDAVProperties(f.Connection.User.Username, f.GetVirtualPath(),
+			getDAVPropertiesFromDeadProps(props))
+		if err != nil {
+			f.Connection.Log(logger.LevelError, "unable to save dead properties for %q: %v", f.GetVirtualPath(), err)
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+func (f *webDavFile) setModificationTime(value string) error {
+	parsed, err := parseTime(value)
+	if err != nil {
+		f.Connection.Log(logger.LevelWarn, "unsupported last modification time: %q, err: %v", value, err)
+		return err
+	}
+	attrs := &common.StatAttributes{
+		Flags: common.StatAttrTimes,
+		Atime: parsed,
+		Mtime: parsed,
+	}
+	if err := f.Connection.SetStat(f.GetVirtualPath(), attrs); err != nil {
+		f.Connection.Log(logger.LevelWarn, "unable to set modification time for %q, err :%v",
+			f.GetVirtualPath(), err)
+		return err
+	}
+	return nil
+}
+
+func getDeadPropsFromDAVProperties(properties []dataprovider.DAVProperty) map[xml.Name]webdav.Property {
+	if len(properties) == 0 {
+		return nil
+	}
+	props := make(map[xml.Name]webdav.Property, len(properties))
+	for _, p := range properties {
+		name := xml.Name{Space: p.Namespace, Local: p.Name}
+		props[name] = webdav.Property{
+			XMLName:  name,
+			Lang:     p.Lang,
+			InnerXML: []byte(p.InnerXML),
+		}
+	}
+	return props
+}
+
+func getDAVPropertiesFromDeadProps(props map[xml.Name]webdav.Property) []dataprovider.DAVProperty {
+	properties := make([]dataprovider.DAVProperty, 0, len(props))
+	for name, p := range props {
+		properties = append(properties, dataprovider.DAVProperty{
+			Namespace: name.Space,
+			Name:      name.Local,
+			Lang:      p.Lang,
+			InnerXML:  string(p.InnerXML),
+		})
+	}
+	sort.Slice(properties, func(i, j int) bool {
+		if properties[i].Namespace == properties[j].Namespace {
+			return properties[i].Name < properties[j].Name
+		}
+		return properties[i].Namespace < properties[j].Namespace
+	})
+	return properties
+}
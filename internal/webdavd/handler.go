@@ -16,6 +16,7 @@ package webdavd
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"os"
 	"path"
@@ -36,6 +37,8 @@ import (
 type Connection struct {
 	*common.BaseConnection
 	request *http.Request
+	// offset for partial updates, greater than 0 if an upload must be resumed
+	uploadOffset int64
 }
 
 func (c *Connection) getModificationTime() time.Time {
@@ -110,6 +113,11 @@ func (c *Connection) Rename(_ context.Context, oldName, newName string) error {
 			setStatErr := c.SetStat(newName, attrs)
 			c.Log(logger.LevelDebug, "mtime header found for %q, value: %s, err: %v", newName, mtime, setStatErr)
 		}
+		if deadPropertiesEnabled {
+			if err := dataprovider.RenameDAVProperties(c.User.Username, oldName, newName); err != nil {
+				c.Log(logger.LevelError, "unable to rename dead properties from %q to %q: %v", oldName, newName, err)
+			}
+		}
 	}
 	return err
 }
@@ -137,7 +145,15 @@ func (c *Connection) RemoveAll(_ context.Context, name string) error {
 	c.UpdateLastActivity()
 
 	name = util.CleanPath(name)
-	return c.BaseConnection.RemoveAll(name)
+	if err := c.BaseConnection.RemoveAll(name); err != nil {
+		return err
+	}
+	if deadPropertiesEnabled {
+		if err := dataprovider.DeleteDAVProperties(c.User.Username, name); err != nil {
+			c.Log(logger.LevelError, "unable to delete dead properties for %q: %v", name, err)
+		}
+	}
+	return nil
 }
 
 // OpenFile opens the named file with specified flag.
@@ -184,6 +200,10 @@ func (c *Connection) putFile(fs vfs.Fs, fsPath, virtualPath string) (webdav.File
 		if !c.User.HasPerm(dataprovider.PermUpload, path.Dir(virtualPath)) {
 			return nil, c.GetPermissionDeniedError()
 		}
+		if c.uploadOffset > 0 {
+			c.Log(logger.LevelInfo, "unable to resume upload for %q, the file does not exist", virtualPath)
+			return nil, c.GetOpUnsupportedError()
+		}
 		return c.handleUploadToNewFile(fs, fsPath, filePath, virtualPath)
 	}
 
@@ -243,16 +263,33 @@ func (c *Connection) handleUploadToExistingFile(fs vfs.Fs, resolvedPath, filePat
 		c.Log(logger.LevelInfo, "denying file write due to quota limits")
 		return nil, common.ErrQuotaExceeded
 	}
+	isResume := c.uploadOffset > 0
+	if isResume && c.uploadOffset != fileSize {
+		c.Log(logger.LevelInfo, "unable to resume upload for %q, offset %d does not match the file size %d",
+			requestPath, c.uploadOffset, fileSize)
+		return nil, c.GetOpUnsupportedError()
+	}
+	osFlags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	preActionFlags := os.O_TRUNC
+	if isResume {
+		osFlags = os.O_WRONLY
+		preActionFlags = os.O_APPEND
+	}
+	// if there is a size limit remaining size cannot be 0 here, since quotaResult.HasSpace
+	// will return false in this case and we deny the upload before.
+	// Resuming uploads is not supported for Cloud FS
+	maxWriteSize, err := c.GetMaxWriteSize(diskQuota, isResume, fileSize, fs.IsUploadResumeSupported())
+	if err != nil {
+		c.Log(logger.LevelDebug, "unable to get max write size for file %q is resume? %t: %v",
+			requestPath, isResume, err)
+		return nil, err
+	}
 	if _, err := common.ExecutePreAction(c.BaseConnection, common.OperationPreUpload, resolvedPath, requestPath,
-		fileSize, os.O_TRUNC); err != nil {
+		fileSize, preActionFlags); err != nil {
 		c.Log(logger.LevelDebug, "upload for file %q denied by pre action: %v", requestPath, err)
 		return nil, c.GetPermissionDeniedError()
 	}
 
-	// if there is a size limit remaining size cannot be 0 here, since quotaResult.HasSpace
-	// will return false in this case and we deny the upload before
-	maxWriteSize, _ := c.GetMaxWriteSize(diskQuota, false, fileSize, fs.IsUploadResumeSupported())
-
 	if common.Config.IsAtomicUploadEnabled() && fs.IsAtomicUploadSupported() {
 		_, _, err = fs.Rename(resolvedPath, filePath)
 		if err != nil {
@@ -262,17 +299,30 @@ func (c *Connection) handleUploadToExistingFile(fs vfs.Fs, resolvedPath, filePat
 		}
 	}
 
-	file, w, cancelFn, err := fs.Create(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, c.GetCreateChecks(requestPath, false, false))
+	file, w, cancelFn, err := fs.Create(filePath, osFlags, c.GetCreateChecks(requestPath, false, isResume))
 	if err != nil {
 		c.Log(logger.LevelError, "error creating file %q: %+v", resolvedPath, err)
 		return nil, c.GetFsError(fs, err)
 	}
+	minWriteOffset := int64(0)
 	initialSize := int64(0)
 	truncatedSize := int64(0) // bytes truncated and not included in quota
-	if vfs.HasTruncateSupport(fs) {
+	if isResume {
+		c.Log(logger.LevelDebug, "resuming upload requested, file path %q initial size: %d", filePath, fileSize)
+		if _, err := file.Seek(fileSize, io.SeekStart); err != nil {
+			c.Log(logger.LevelError, "unable to seek to offset %d for file %q: %+v", fileSize, filePath, err)
+			file.Close() //nolint:errcheck
+			if cancelFn != nil {
+				cancelFn()
+			}
+			return nil, c.GetFsError(fs, err)
+		}
+		minWriteOffset = fileSize
+		initialSize = fileSize
+	} else if vfs.HasTruncateSupport(fs) {
 		vfolder, err := c.User.GetVirtualFolderForPath(path.Dir(requestPath))
 		if err == nil {
-			dataprovider.UpdateVirtualFolderQuota(&vfolder.BaseVirtualFolder, 0, -fileSize, false) //nolint:errcheck
+			dataprovider.UpdateVirtualFolderQuota(&vfolder, 0, -fileSize, false) //nolint:errcheck
 			if vfolder.IsIncludedInUserQuota() {
 				dataprovider.UpdateUserQuota(&c.User, 0, -fileSize, false) //nolint:errcheck
 			}
@@ -287,7 +337,7 @@ func (c *Connection) handleUploadToExistingFile(fs vfs.Fs, resolvedPath, filePat
 	vfs.SetPathPermissions(fs, filePath, c.User.GetUID(), c.User.GetGID())
 
 	baseTransfer := common.NewBaseTransfer(file, c.BaseConnection, cancelFn, resolvedPath, filePath, requestPath,
-		common.TransferUpload, 0, initialSize, maxWriteSize, truncatedSize, false, fs, transferQuota)
+		common.TransferUpload, minWriteOffset, initialSize, maxWriteSize, truncatedSize, false, fs, transferQuota)
 	mtime := c.getModificationTime()
 	baseTransfer.SetTimes(resolvedPath, mtime, mtime)
 
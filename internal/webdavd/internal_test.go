@@ -15,7 +15,11 @@
 package webdavd
 
 import (
+	"bytes"
 	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/xml"
@@ -27,11 +31,15 @@ import (
 	"path"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/drakkan/webdav"
 	"github.com/eikenb/pipeat"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
 	"github.com/sftpgo/sdk"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -639,6 +647,31 @@ func TestFileAccessErrors(t *testing.T) {
 	}
 }
 
+func TestDeadPropsConversion(t *testing.T) {
+	assert.Nil(t, getDeadPropsFromDAVProperties(nil))
+	props := map[xml.Name]webdav.Property{
+		{Space: "http://example.com/ns", Local: "color"}: {
+			XMLName:  xml.Name{Space: "http://example.com/ns", Local: "color"},
+			Lang:     "en",
+			InnerXML: []byte(`red`),
+		},
+		{Space: "DAV:", Local: "displayname"}: {
+			XMLName:  xml.Name{Space: "DAV:", Local: "displayname"},
+			InnerXML: []byte(`<b xmlns="http://example.com/ns">name</b>`),
+		},
+	}
+	properties := getDAVPropertiesFromDeadProps(props)
+	if assert.Len(t, properties, 2) {
+		assert.Equal(t, "DAV:", properties[0].Namespace)
+		assert.Equal(t, "displayname", properties[0].Name)
+		assert.Equal(t, "http://example.com/ns", properties[1].Namespace)
+		assert.Equal(t, "color", properties[1].Name)
+		assert.Equal(t, "en", properties[1].Lang)
+		assert.Equal(t, "red", properties[1].InnerXML)
+	}
+	assert.Equal(t, props, getDeadPropsFromDAVProperties(properties))
+}
+
 func TestCheckRequestMethodWithPrefix(t *testing.T) {
 	user := dataprovider.User{
 		BaseUser: sdk.BaseUser{
@@ -664,6 +697,370 @@ func TestCheckRequestMethodWithPrefix(t *testing.T) {
 	require.Equal(t, "1", req.Header.Get("Depth"))
 }
 
+func TestUserPrefixes(t *testing.T) {
+	b := Binding{
+		Prefix: "/dav/%username%",
+		UserPrefixes: []UserPrefix{
+			{
+				Prefix:   "/legacy",
+				Username: "user1",
+			},
+			{
+				Prefix:   "/legacy/files",
+				Username: "user2",
+			},
+		},
+	}
+	err := b.validatePrefixes()
+	require.NoError(t, err)
+	assert.Equal(t, "/legacy/files", b.UserPrefixes[0].Prefix)
+
+	prefix, username, ok := b.getRequestPrefix("/legacy/files/dir/file.txt")
+	assert.True(t, ok)
+	assert.Equal(t, "/legacy/files", prefix)
+	assert.Equal(t, "user2", username)
+	prefix, username, ok = b.getRequestPrefix("/legacy/file.txt")
+	assert.True(t, ok)
+	assert.Equal(t, "/legacy", prefix)
+	assert.Equal(t, "user1", username)
+	prefix, username, ok = b.getRequestPrefix("/dav/user3/dir")
+	assert.True(t, ok)
+	assert.Equal(t, "/dav/user3", prefix)
+	assert.Equal(t, "user3", username)
+	prefix, username, ok = b.getRequestPrefix("/dav/user3")
+	assert.True(t, ok)
+	assert.Equal(t, "/dav/user3", prefix)
+	assert.Equal(t, "user3", username)
+	_, _, ok = b.getRequestPrefix("/dav")
+	assert.False(t, ok)
+	_, _, ok = b.getRequestPrefix("/dav2/user3")
+	assert.False(t, ok)
+	_, _, ok = b.getRequestPrefix("/legacyfiles")
+	assert.False(t, ok)
+
+	b.Prefix = "/dav"
+	prefix, username, ok = b.getRequestPrefix("/dav/user3")
+	assert.True(t, ok)
+	assert.Equal(t, "/dav", prefix)
+	assert.Empty(t, username)
+
+	server := webDavServer{
+		binding: b,
+	}
+	req, err := http.NewRequest(http.MethodGet, "/legacy/files/file.txt", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "/dav", server.getRequestPrefix(req))
+	req = req.WithContext(context.WithValue(req.Context(), requestPrefixKey, "/legacy/files"))
+	assert.Equal(t, "/legacy/files", server.getRequestPrefix(req))
+	assert.Equal(t, "/file.txt", server.getRequestPath(req))
+
+	b.Prefix = "/dav/user_%username%"
+	assert.Error(t, b.validatePrefixes())
+	b.Prefix = "dav/%username%"
+	assert.Error(t, b.validatePrefixes())
+	b.Prefix = "/dav/%username%"
+	b.UserPrefixes = append(b.UserPrefixes, UserPrefix{Prefix: "/legacy", Username: "user3"})
+	assert.Error(t, b.validatePrefixes())
+	b.UserPrefixes = []UserPrefix{{Prefix: "/legacy"}}
+	assert.Error(t, b.validatePrefixes())
+	b.UserPrefixes = []UserPrefix{{Prefix: "/legacy/", Username: "user1"}}
+	assert.Error(t, b.validatePrefixes())
+	b.UserPrefixes = []UserPrefix{{Prefix: "/", Username: "user1"}}
+	assert.Error(t, b.validatePrefixes())
+	b.UserPrefixes = []UserPrefix{{Prefix: "/%username%", Username: "user1"}}
+	assert.Error(t, b.validatePrefixes())
+}
+
+func TestPartialUploadOffset(t *testing.T) {
+	offset, err := parseContentRange("bytes 100-199/1000", 100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), offset)
+	offset, err = parseContentRange("bytes 100-199/*", -1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), offset)
+	for _, value := range []string{"100-199/1000", "bytes 100-199", "bytes 100-199/150", "bytes 199-100/1000",
+		"bytes */1000", "bytes a-199/1000", "items 1-2/3"} {
+		_, err = parseContentRange(value, -1)
+		assert.Error(t, err, value)
+	}
+	_, err = parseContentRange("bytes 100-199/1000", 50)
+	assert.Error(t, err)
+
+	offset, err = parseUpdateRange("append", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-1), offset)
+	offset, err = parseUpdateRange("bytes=100-", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), offset)
+	offset, err = parseUpdateRange("bytes=100-109", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), offset)
+	for _, value := range []string{"", "bytes=-10", "bytes=100-109", "bytes 100-", "bytes=a-"} {
+		_, err = parseUpdateRange(value, 5)
+		assert.Error(t, err, value)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, "/file", bytes.NewBufferString("0123456789"))
+	require.NoError(t, err)
+	_, isPartial, _ := getPartialUploadOffset(req)
+	assert.False(t, isPartial)
+	req.Header.Set("Content-Range", "bytes 10-19/*")
+	offset, isPartial, err = getPartialUploadOffset(req)
+	assert.NoError(t, err)
+	assert.True(t, isPartial)
+	assert.Equal(t, int64(10), offset)
+	req.Method = http.MethodPatch
+	_, isPartial, err = getPartialUploadOffset(req)
+	assert.True(t, isPartial)
+	assert.ErrorIs(t, err, errUnsupportedPatchType)
+	req.Header.Set("Content-Type", sabreDAVPartialUpdateContentType+"; charset=utf-8")
+	_, isPartial, err = getPartialUploadOffset(req)
+	assert.True(t, isPartial)
+	assert.Error(t, err)
+	req.Header.Set("X-Update-Range", "append")
+	offset, isPartial, err = getPartialUploadOffset(req)
+	assert.NoError(t, err)
+	assert.True(t, isPartial)
+	assert.Equal(t, int64(-1), offset)
+}
+
+func TestParsePropfindRequest(t *testing.T) {
+	req, err := parsePropfindRequest(bytes.NewBuffer(nil))
+	assert.NoError(t, err)
+	assert.NotNil(t, req.AllProp)
+	req, err = parsePropfindRequest(bytes.NewBufferString(`<?xml version="1.0" encoding="utf-8" ?>
+<D:propfind xmlns:D="DAV:"><D:prop><D:getcontentlength/><R:color xmlns:R="http://example.com/ns"/></D:prop></D:propfind>`))
+	assert.NoError(t, err)
+	if assert.NotNil(t, req.Prop) {
+		assert.Equal(t, []xml.Name{
+			{Space: "DAV:", Local: "getcontentlength"},
+			{Space: "http://example.com/ns", Local: "color"},
+		}, req.Prop.names())
+	}
+	req, err = parsePropfindRequest(bytes.NewBufferString(`<D:propfind xmlns:D="DAV:"><D:propname/></D:propfind>`))
+	assert.NoError(t, err)
+	assert.NotNil(t, req.PropName)
+	for _, body := range []string{`<D:propfind xmlns:D="DAV:"><D:prop></D:prop></D:propfind>`,
+		`<D:propfind xmlns:D="DAV:"><D:allprop/><D:propname/></D:propfind>`,
+		`<D:propfind xmlns:D="DAV:"></D:propfind>`, `<D:prop xmlns:D="DAV:"/>`, `<D:propfind`} {
+		_, err = parsePropfindRequest(bytes.NewBufferString(body))
+		assert.ErrorIs(t, err, errInvalidPropfind, body)
+	}
+}
+
+func TestInfiniteDepthPropfind(t *testing.T) {
+	homeDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(homeDir, "dir1", "sub"), os.ModePerm)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(homeDir, "dir1", "sub", "file.txt"), []byte("data"), 0666)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(homeDir, "file&1.txt"), []byte("data"), 0666)
+	require.NoError(t, err)
+
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: "user",
+			HomeDir:  homeDir,
+		},
+	}
+	user.Permissions = make(map[string][]string)
+	user.Permissions["/"] = []string{dataprovider.PermAny}
+	server := &webDavServer{
+		config: &Configuration{
+			InfiniteDepth: InfiniteDepthConfig{
+				Enabled:    true,
+				MaxResults: 100,
+			},
+		},
+		binding: Binding{
+			Prefix: "/dav",
+		},
+	}
+	doPropfind := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("PROPFIND", "/dav/", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		req.Header.Set("Depth", "infinity")
+		assert.True(t, server.isInfiniteDepthPropfind(req))
+		connection := &Connection{
+			BaseConnection: common.NewBaseConnection("connID", common.ProtocolWebDAV, "", "", user),
+			request:        req,
+		}
+		rr := httptest.NewRecorder()
+		server.handleInfiniteDepthPropfind(context.Background(), rr, req, connection)
+		return rr
+	}
+
+	rr := doPropfind("")
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	assert.Equal(t, 5, strings.Count(rr.Body.String(), "<D:response>"))
+	assert.Contains(t, rr.Body.String(), "<D:href>/dav/dir1/sub/</D:href>")
+	assert.Contains(t, rr.Body.String(), "<D:href>/dav/dir1/sub/file.txt</D:href>")
+	assert.Contains(t, rr.Body.String(), "<D:href>/dav/file&amp;1.txt</D:href>")
+	assert.Contains(t, rr.Body.String(), "<D:getcontentlength>4</D:getcontentlength>")
+	assert.NotContains(t, rr.Body.String(), "507")
+	var multistatus struct {
+		Responses []struct {
+			Href string `xml:"href"`
+		} `xml:"response"`
+	}
+	err = xml.Unmarshal(rr.Body.Bytes(), &multistatus)
+	assert.NoError(t, err)
+	assert.Len(t, multistatus.Responses, 5)
+
+	rr = doPropfind(`<D:propfind xmlns:D="DAV:"><D:prop><D:getcontentlength/><R:color xmlns:R="urn:x"/></D:prop></D:propfind>`)
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	assert.Contains(t, rr.Body.String(), `<color xmlns="urn:x"/>`)
+	assert.Contains(t, rr.Body.String(), "HTTP/1.1 404 Not Found")
+
+	server.config.InfiniteDepth.MaxResults = 2
+	rr = doPropfind("")
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	assert.Equal(t, 3, strings.Count(rr.Body.String(), "<D:response>"))
+	assert.Contains(t, rr.Body.String(), "HTTP/1.1 507 Insufficient Storage")
+
+	rr = doPropfind("<invalid")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	req, err := http.NewRequest("PROPFIND", "/dav/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Depth", "1")
+	assert.False(t, server.isInfiniteDepthPropfind(req))
+	server.config.InfiniteDepth.Enabled = false
+	req.Header.Set("Depth", "infinity")
+	assert.False(t, server.isInfiniteDepthPropfind(req))
+
+	c := InfiniteDepthConfig{Enabled: true}
+	assert.Error(t, c.validate())
+}
+
+func TestSearchFilters(t *testing.T) {
+	assert.Equal(t, "*report?2024\\*", convertLikePattern(`%report_2024*`))
+	assert.Equal(t, "a%b_c", convertLikePattern(`a\%b\_c`))
+
+	server := &webDavServer{
+		config: &Configuration{},
+		binding: Binding{
+			Prefix: "/dav",
+		},
+	}
+	getFilters := func(where string) (common.SearchFilters, error) {
+		body := `<D:searchrequest xmlns:D="DAV:"><D:basicsearch><D:select><D:allprop/></D:select>` +
+			`<D:from><D:scope><D:href>/dav/docs</D:href><D:depth>infinity</D:depth></D:scope></D:from>` +
+			where + `</D:basicsearch></D:searchrequest>`
+		search, err := parseSearchRequest(bytes.NewBufferString(body))
+		if err != nil {
+			return common.SearchFilters{}, err
+		}
+		req, err := http.NewRequest(searchMethod, "/dav/", nil)
+		require.NoError(t, err)
+		return server.getSearchFilters(req, search)
+	}
+	filters, err := getFilters(`<D:where><D:and><D:like><D:prop><D:displayname/></D:prop><D:literal>%Report%</D:literal></D:like>` +
+		`<D:gt><D:prop><D:getcontentlength/></D:prop><D:literal>100</D:literal></D:gt>` +
+		`<D:lte><D:prop><D:getcontentlength/></D:prop><D:literal>1000</D:literal></D:lte>` +
+		`<D:lt><D:prop><D:getlastmodified/></D:prop><D:literal>2024-01-02T00:00:00Z</D:literal></D:lt>` +
+		`<D:not><D:is-collection/></D:not></D:and></D:where><D:limit><D:nresults>10</D:nresults></D:limit>`)
+	assert.NoError(t, err)
+	assert.Equal(t, "/docs", filters.BaseDir)
+	assert.Equal(t, "*report*", filters.Name)
+	assert.Equal(t, int64(101), filters.MinSize)
+	assert.Equal(t, int64(1000), filters.MaxSize)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), filters.ModifiedBefore)
+	assert.Equal(t, common.SearchTypeFile, filters.Type)
+	assert.Equal(t, 10, filters.Limit)
+
+	_, err = getFilters(`<D:where><D:is-collection/></D:where>`)
+	assert.ErrorIs(t, err, errUnsupportedSearch, "at least a filter is required")
+	for _, where := range []string{
+		`<D:where><D:or><D:is-collection/></D:or></D:where>`,
+		`<D:where><D:contains>text</D:contains></D:where>`,
+		`<D:where><D:lt><D:prop><D:getcontentlength/></D:prop><D:literal>1</D:literal></D:lt></D:where>`,
+		`<D:where><D:eq><D:prop><D:getlastmodified/></D:prop><D:literal>2024-01-02T00:00:00Z</D:literal></D:eq></D:where>`,
+		`<D:where><D:like><D:prop><D:getcontenttype/></D:prop><D:literal>text</D:literal></D:like></D:where>`,
+	} {
+		_, err = getFilters(where)
+		assert.ErrorIs(t, err, errUnsupportedSearch, where)
+	}
+	for _, where := range []string{
+		`<D:where><D:gt><D:prop><D:getcontentlength/></D:prop><D:literal>a</D:literal></D:gt></D:where>`,
+		`<D:where><D:gt><D:prop><D:getlastmodified/></D:prop><D:literal>yesterday</D:literal></D:gt></D:where>`,
+		`<D:where><D:gt><D:prop><D:getcontentlength/></D:prop></D:gt></D:where>`,
+		`<D:where><D:gt><D:prop><D:getcontentlength/></D:prop><D:literal>1</D:literal></D:gt></D:where><D:limit><D:nresults>0</D:nresults></D:limit>`,
+	} {
+		_, err = getFilters(where)
+		assert.ErrorIs(t, err, errInvalidSearch, where)
+	}
+	_, err = parseSearchRequest(bytes.NewBufferString(`<D:searchrequest xmlns:D="DAV:"></D:searchrequest>`))
+	assert.ErrorIs(t, err, errUnsupportedSearch)
+	_, err = parseSearchRequest(bytes.NewBufferString(`<D:searchrequest`))
+	assert.ErrorIs(t, err, errInvalidSearch)
+	assert.Equal(t, http.StatusBadRequest, getSearchErrorStatus(errInvalidSearch))
+	assert.Equal(t, http.StatusUnprocessableEntity, getSearchErrorStatus(errUnsupportedSearch))
+	assert.Equal(t, http.StatusNotFound, getSearchErrorStatus(os.ErrNotExist))
+	assert.Equal(t, http.StatusForbidden, getSearchErrorStatus(os.ErrPermission))
+	assert.Equal(t, http.StatusInternalServerError, getSearchErrorStatus(io.ErrUnexpectedEOF))
+}
+
+func TestSearchResults(t *testing.T) {
+	homeDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(homeDir, "docs", "old"), os.ModePerm)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(homeDir, "docs", "report1.txt"), []byte("data"), 0666)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(homeDir, "docs", "old", "report2.txt"), []byte("more data"), 0666)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(homeDir, "other.txt"), []byte("data"), 0666)
+	require.NoError(t, err)
+
+	user := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: "user",
+			HomeDir:  homeDir,
+		},
+	}
+	user.Permissions = make(map[string][]string)
+	user.Permissions["/"] = []string{dataprovider.PermAny}
+	server := &webDavServer{
+		config: &Configuration{},
+	}
+	doSearch := func(scope, where, orderBy string) *httptest.ResponseRecorder {
+		body := `<D:searchrequest xmlns:D="DAV:"><D:basicsearch><D:select><D:prop><D:getcontentlength/></D:prop></D:select>` +
+			`<D:from><D:scope><D:href>` + scope + `</D:href></D:scope></D:from>` + where + orderBy +
+			`</D:basicsearch></D:searchrequest>`
+		req, err := http.NewRequest(searchMethod, "/", bytes.NewBufferString(body))
+		require.NoError(t, err)
+		connection := &Connection{
+			BaseConnection: common.NewBaseConnection("connID", common.ProtocolWebDAV, "", "", user),
+			request:        req,
+		}
+		rr := httptest.NewRecorder()
+		server.handleSearch(rr, req, connection)
+		return rr
+	}
+	where := `<D:where><D:like><D:prop><D:displayname/></D:prop><D:literal>report%</D:literal></D:like></D:where>`
+	rr := doSearch("/", where, `<D:orderby><D:order><D:prop><D:getcontentlength/></D:prop><D:descending/></D:order></D:orderby>`)
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	body := rr.Body.String()
+	assert.Equal(t, 2, strings.Count(body, "<D:response>"))
+	assert.Less(t, strings.Index(body, "/docs/old/report2.txt"), strings.Index(body, "/docs/report1.txt"))
+	assert.Contains(t, body, "<D:getcontentlength>9</D:getcontentlength>")
+
+	rr = doSearch("docs/old", where, "")
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	assert.Equal(t, 1, strings.Count(rr.Body.String(), "<D:response>"))
+
+	rr = doSearch("/", where, `<D:limit><D:nresults>1</D:nresults></D:limit>`)
+	assert.Equal(t, http.StatusMultiStatus, rr.Code)
+	assert.Equal(t, 2, strings.Count(rr.Body.String(), "<D:response>"))
+	assert.Contains(t, rr.Body.String(), "HTTP/1.1 507 Insufficient Storage")
+
+	rr = doSearch("/", where, `<D:orderby><D:order><D:prop><D:getcontenttype/></D:prop></D:order></D:orderby>`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+
+	rr = doSearch("/missing", where, "")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+}
+
 func TestContentType(t *testing.T) {
 	user := dataprovider.User{
 		BaseUser: sdk.BaseUser{
@@ -1111,6 +1508,182 @@ func TestBasicUsersCache(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestBearerAuthConfig(t *testing.T) {
+	c := BearerAuthConfig{}
+	assert.NoError(t, c.initialize())
+	c.IssuerURL = "https://issuer.example.com"
+	c.UsernameField = "preferred_username"
+	err := c.initialize()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "audience cannot be empty")
+	}
+	c.Audience = "sftpgo-webdav"
+	c.UsernameField = ""
+	err = c.initialize()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "username field cannot be empty")
+	}
+}
+
+func TestBearerTokenAuth(t *testing.T) {
+	username := "webdav_bearer_test"
+	u := dataprovider.User{
+		BaseUser: sdk.BaseUser{
+			Username: username,
+			Password: "pwd",
+			HomeDir:  filepath.Join(os.TempDir(), username),
+			Status:   1,
+		},
+	}
+	u.Permissions = make(map[string][]string)
+	u.Permissions["/"] = []string{dataprovider.PermAny}
+	err := dataprovider.AddUser(&u, "", "", "")
+	assert.NoError(t, err)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer := "https://issuer.example.com"
+	audience := "sftpgo-webdav"
+	c := &Configuration{
+		Bindings: []Binding{
+			{
+				Port: 9000,
+			},
+		},
+		Cache: Cache{
+			Users: UsersCacheConfig{
+				MaxSize:        50,
+				ExpirationTime: 1,
+			},
+		},
+		BearerAuth: BearerAuthConfig{
+			IssuerURL:     issuer,
+			Audience:      audience,
+			UsernameField: "preferred_username",
+			verifier: oidc.NewVerifier(issuer, &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{key.Public()}},
+				&oidc.Config{ClientID: audience}),
+		},
+	}
+	dataprovider.InitializeWebDAVUserCache(c.Cache.Users.MaxSize)
+	server := webDavServer{
+		config:  c,
+		binding: c.Bindings[0],
+	}
+	getToken := func(username string, expiration time.Time) string {
+		token, err := jwt.NewBuilder().
+			Issuer(issuer).
+			Audience([]string{audience}).
+			Expiration(expiration).
+			Claim("preferred_username", username).
+			Build()
+		require.NoError(t, err)
+		signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, key))
+		require.NoError(t, err)
+		return string(signed)
+	}
+
+	ipAddr := "127.0.0.1"
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("/%v", username), nil)
+	assert.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+getToken(username, time.Now().Add(time.Minute)))
+	authUser, isCached, lockSystem, loginMethod, err := server.authenticate(req, ipAddr)
+	assert.NoError(t, err)
+	assert.False(t, isCached)
+	assert.NotNil(t, lockSystem)
+	assert.Equal(t, dataprovider.LoginMethodIDP, loginMethod)
+	assert.Equal(t, username, authUser.Username)
+	cachedUser, ok := dataprovider.GetCachedWebDAVUser(username)
+	if assert.True(t, ok) {
+		assert.Empty(t, cachedUser.Password)
+	}
+	_, isCached, _, _, err = server.authenticate(req, ipAddr)
+	assert.NoError(t, err)
+	assert.True(t, isCached)
+	// expired token
+	req.Header.Set("Authorization", "Bearer "+getToken(username, time.Now().Add(-time.Minute)))
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.ErrorIs(t, err, dataprovider.ErrInvalidCredentials)
+	// token signed with a different key
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	token, err := jwt.NewBuilder().Issuer(issuer).Audience([]string{audience}).Expiration(time.Now().Add(time.Minute)).
+		Claim("preferred_username", username).Build()
+	require.NoError(t, err)
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, otherKey))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+string(signed))
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.ErrorIs(t, err, dataprovider.ErrInvalidCredentials)
+	// token issued for a different audience
+	token, err = jwt.NewBuilder().Issuer(issuer).Audience([]string{"other-client"}).Expiration(time.Now().Add(time.Minute)).
+		Claim("preferred_username", username).Build()
+	require.NoError(t, err)
+	signed, err = jwt.Sign(token, jwt.WithKey(jwa.RS256, key))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+string(signed))
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.ErrorIs(t, err, dataprovider.ErrInvalidCredentials)
+	// missing user
+	req.Header.Set("Authorization", "Bearer "+getToken("missing user", time.Now().Add(time.Minute)))
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.ErrorIs(t, err, dataprovider.ErrInvalidCredentials)
+	// token from cookie
+	server.config.BearerAuth.CookieName = "sftpgo_token"
+	req.Header.Del("Authorization")
+	req.AddCookie(&http.Cookie{Name: "sftpgo_token", Value: getToken(username, time.Now().Add(time.Minute))})
+	_, isCached, _, loginMethod, err = server.authenticate(req, ipAddr)
+	assert.NoError(t, err)
+	assert.True(t, isCached)
+	assert.Equal(t, dataprovider.LoginMethodIDP, loginMethod)
+	// state changing requests require a same origin or an allowed CORS origin
+	req.Method = http.MethodPut
+	req.Host = "webdav.example.com"
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.ErrorIs(t, err, common.ErrNoCredentials)
+	req.Header.Set("Origin", "https://evil.example.com")
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.ErrorIs(t, err, common.ErrNoCredentials)
+	req.Header.Set("Origin", "https://webdav.example.com")
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.NoError(t, err)
+	req.Header.Set("Origin", "https://app.example.com")
+	server.config.Cors = CorsConfig{
+		Enabled:        true,
+		AllowedOrigins: []string{"*"},
+	}
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.ErrorIs(t, err, common.ErrNoCredentials)
+	server.config.Cors.AllowedOrigins = []string{"https://app.example.com"}
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.NoError(t, err)
+	server.config.Cors = CorsConfig{}
+	req.Header.Del("Origin")
+	req.Method = http.MethodGet
+	// the Authorization header has precedence
+	req.Header.Set("Authorization", "Bearer "+getToken(username, time.Now().Add(-time.Minute)))
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.ErrorIs(t, err, dataprovider.ErrInvalidCredentials)
+	server.config.BearerAuth.CookieName = ""
+	req.Header.Del("Cookie")
+	// basic auth still works
+	req.Header.Del("Authorization")
+	req.SetBasicAuth(username, "pwd")
+	_, isCached, _, loginMethod, err = server.authenticate(req, ipAddr)
+	assert.NoError(t, err)
+	assert.True(t, isCached)
+	assert.Equal(t, dataprovider.LoginMethodPassword, loginMethod)
+	req.SetBasicAuth(username, "")
+	_, _, _, _, err = server.authenticate(req, ipAddr) //nolint:dogsled
+	assert.Error(t, err)
+
+	err = dataprovider.DeleteUser(username, "", "", "")
+	assert.NoError(t, err)
+	_, ok = dataprovider.GetCachedWebDAVUser(username)
+	assert.False(t, ok)
+	err = os.RemoveAll(u.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestCachedUserWithFolders(t *testing.T) {
 	username := "webdav_internal_folder_test"
 	password := "dav_pwd"
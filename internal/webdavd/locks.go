@@ -0,0 +1,108 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package webdavd
+
+import (
+	"time"
+
+	"github.com/drakkan/webdav"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+)
+
+// sharedLockSystem is a webdav.LockSystem that shares the WebDAV locks with the other
+// protocols using the common lock manager
+type sharedLockSystem struct {
+	webdav.LockSystem
+	username string
+}
+
+func newLockSystem(username string) webdav.LockSystem {
+	return &sharedLockSystem{
+		LockSystem: webdav.NewMemLS(),
+		username:   username,
+	}
+}
+
+func (l *sharedLockSystem) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (func(), error) {
+	for _, name := range []string{name0, name1} {
+		if name == "" {
+			continue
+		}
+		err := common.FileLocks.CheckAccess(l.username, name, "", common.ProtocolWebDAV,
+			common.LockBlockWrite|common.LockBlockDelete)
+		if err != nil {
+			logger.Debug(logSender, "", "unable to confirm lock for user %q, path %q: %v", l.username, name, err)
+			return nil, webdav.ErrLocked
+		}
+	}
+	return l.LockSystem.Confirm(now, name0, name1, conditions...)
+}
+
+func (l *sharedLockSystem) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	token, err := l.LockSystem.Create(now, details)
+	if err != nil {
+		return token, err
+	}
+	// the in-memory lock system accepted the lock, so any conflicting WebDAV lock is stale,
+	// for example it was acquired using a lock system no longer cached
+	common.FileLocks.RemovePathLocks(l.username, details.Root, common.ProtocolWebDAV, !details.ZeroDepth)
+	// WebDAV locks are exclusive write locks, other clients can still read
+	err = common.FileLocks.Add(l.username, details.Root, common.FileLock{
+		Owner:     token,
+		Protocol:  common.ProtocolWebDAV,
+		Mask:      common.LockBlockWrite | common.LockBlockDelete,
+		Exclusive: true,
+		Recursive: !details.ZeroDepth,
+		ExpiresAt: getLockExpiration(now, details.Duration),
+	})
+	if err != nil {
+		logger.Debug(logSender, "", "unable to lock path %q for user %q: %v", details.Root, l.username, err)
+		l.LockSystem.Unlock(now, token) //nolint:errcheck
+		return "", webdav.ErrLocked
+	}
+	return token, nil
+}
+
+func (l *sharedLockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	details, err := l.LockSystem.Refresh(now, token, duration)
+	if err == nil {
+		common.FileLocks.Refresh(token, getLockExpiration(now, details.Duration))
+	}
+	return details, err
+}
+
+func (l *sharedLockSystem) Unlock(now time.Time, token string) error {
+	common.FileLocks.RemoveOwnerLocks(token)
+	return l.LockSystem.Unlock(now, token)
+}
+
+// Delete removes the locks rooted at name, it is called after deleting a resource
+func (l *sharedLockSystem) Delete(now time.Time, name string) error {
+	common.FileLocks.RemovePathLocks(l.username, name, common.ProtocolWebDAV, true)
+	if deleter, ok := l.LockSystem.(webdav.LockDeleter); ok {
+		return deleter.Delete(now, name)
+	}
+	return nil
+}
+
+func getLockExpiration(now time.Time, duration time.Duration) time.Time {
+	// a negative duration means an infinite timeout
+	if duration < 0 {
+		return time.Time{}
+	}
+	return now.Add(duration)
+}
@@ -0,0 +1,383 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package webdavd
+
+import (
+	"bufio"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drakkan/webdav"
+
+	"github.com/drakkan/sftpgo/v2/internal/dataprovider"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+)
+
+const (
+	davNamespace = "DAV:"
+	// the response is flushed to the client after this number of resources
+	propfindFlushInterval = 100
+	maxPropfindBodySize   = 1024 * 1024
+)
+
+var (
+	errInvalidPropfind      = errors.New("invalid PROPFIND request")
+	errPropfindLimitReached = errors.New("max results reached")
+	supportedLockXML        = `<D:lockentry xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope>` +
+		`<D:locktype><D:write/></D:locktype></D:lockentry>`
+)
+
+type propfindProps struct {
+	Props []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+func (p *propfindProps) names() []xml.Name {
+	names := make([]xml.Name, 0, len(p.Props))
+	for _, prop := range p.Props {
+		names = append(names, prop.XMLName)
+	}
+	return names
+}
+
+type propfindRequest struct {
+	XMLName  xml.Name       `xml:"DAV: propfind"`
+	AllProp  *struct{}      `xml:"DAV: allprop"`
+	PropName *struct{}      `xml:"DAV: propname"`
+	Prop     *propfindProps `xml:"DAV: prop"`
+}
+
+// parsePropfindRequest parses the PROPFIND body, an empty body is an allprop request
+func parsePropfindRequest(r io.Reader) (propfindRequest, error) {
+	var req propfindRequest
+	err := xml.NewDecoder(io.LimitReader(r, maxPropfindBodySize)).Decode(&req)
+	if errors.Is(err, io.EOF) {
+		req.AllProp = &struct{}{}
+		return req, nil
+	}
+	if err != nil {
+		return req, fmt.Errorf("%w: %v", errInvalidPropfind, err)
+	}
+	count := 0
+	if req.AllProp != nil {
+		count++
+	}
+	if req.PropName != nil {
+		count++
+	}
+	if req.Prop != nil {
+		count++
+		if len(req.Prop.Props) == 0 {
+			return req, fmt.Errorf("%w: empty prop element", errInvalidPropfind)
+		}
+	}
+	if count != 1 {
+		return req, fmt.Errorf("%w: exactly one of allprop, propname or prop is required", errInvalidPropfind)
+	}
+	return req, nil
+}
+
+// isInfiniteDepthPropfind returns true if the request is a PROPFIND with "Depth: infinity"
+// and we have to handle it ourself
+func (s *webDavServer) isInfiniteDepthPropfind(r *http.Request) bool {
+	if !s.config.InfiniteDepth.Enabled || r.Method != "PROPFIND" {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(r.Header.Get("Depth")), "infinity")
+}
+
+// multistatusWriter streams a multistatus response, the properties to return
+// for each resource are selected as specified in the PROPFIND request
+type multistatusWriter struct {
+	connection *Connection
+	prefix     string
+	req        propfindRequest
+	results    int
+	w          *bufio.Writer
+	flusher    *http.ResponseController
+}
+
+func newMultistatusWriter(w http.ResponseWriter, connection *Connection, prefix string,
+	req propfindRequest,
+) *multistatusWriter {
+	rc := http.NewResponseController(w)
+	// deep listings can take long, remove the server write timeout
+	rc.SetWriteDeadline(time.Time{}) //nolint:errcheck
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+
+	m := &multistatusWriter{
+		connection: connection,
+		prefix:     prefix,
+		req:        req,
+		w:          bufio.NewWriter(w),
+		flusher:    rc,
+	}
+	m.w.WriteString(`<?xml version="1.0" encoding="UTF-8"?><D:multistatus xmlns:D="DAV:">`) //nolint:errcheck
+	return m
+}
+
+func (m *multistatusWriter) close() error {
+	m.w.WriteString(`</D:multistatus>`) //nolint:errcheck
+	return m.w.Flush()
+}
+
+type propfindWalker struct {
+	*multistatusWriter
+	maxResults int
+}
+
+// handleInfiniteDepthPropfind walks the requested directory tree and streams the multistatus
+// response. The number of returned resources is limited, if the limit is reached the response
+// is truncated and a 507 status is returned for the requested resource
+func (s *webDavServer) handleInfiniteDepthPropfind(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	connection *Connection,
+) {
+	req, err := parsePropfindRequest(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeLog(r, http.StatusBadRequest, err)
+		return
+	}
+	reqPath := util.CleanPath(s.getRequestPath(r))
+	info, err := connection.Stat(ctx, reqPath)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, os.ErrNotExist) {
+			status = http.StatusNotFound
+		} else if errors.Is(err, os.ErrPermission) {
+			status = http.StatusForbidden
+		}
+		http.Error(w, http.StatusText(status), status)
+		writeLog(r, status, err)
+		return
+	}
+
+	walker := &propfindWalker{
+		multistatusWriter: newMultistatusWriter(w, connection, s.getRequestPrefix(r), req),
+		maxResults:        s.config.InfiniteDepth.MaxResults,
+	}
+	err = walker.walk(ctx, reqPath, info)
+	if errors.Is(err, errPropfindLimitReached) {
+		connection.Log(logger.LevelInfo, "PROPFIND for %q truncated, max results reached: %d", reqPath,
+			walker.maxResults)
+		walker.writeTruncated(reqPath, info.IsDir(),
+			fmt.Sprintf("the number of results exceeds the limit of %d", walker.maxResults))
+		err = nil
+	}
+	if errClose := walker.close(); err == nil {
+		err = errClose
+	}
+	writeLog(r, http.StatusMultiStatus, err)
+}
+
+func (p *propfindWalker) walk(ctx context.Context, name string, info os.FileInfo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if p.results >= p.maxResults {
+		return errPropfindLimitReached
+	}
+	if err := p.writeResponse(name, info); err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return nil
+	}
+	f, err := p.connection.OpenFile(ctx, name, os.O_RDONLY, 0)
+	if err != nil {
+		p.connection.Log(logger.LevelDebug, "PROPFIND, unable to open directory %q: %v", name, err)
+		return nil
+	}
+	entries, err := f.Readdir(0)
+	f.Close() //nolint:errcheck
+	if err != nil {
+		// we skip the directories we cannot list
+		p.connection.Log(logger.LevelDebug, "PROPFIND, unable to list directory %q: %v", name, err)
+		return nil
+	}
+	for _, entry := range entries {
+		if err := p.walk(ctx, path.Join(name, entry.Name()), entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *multistatusWriter) getHref(name string, isDir bool) string {
+	href := (&url.URL{Path: path.Join("/", p.prefix, name)}).EscapedPath()
+	if isDir && !strings.HasSuffix(href, "/") {
+		href += "/"
+	}
+	return href
+}
+
+func (p *multistatusWriter) writeResponse(name string, info os.FileInfo) error {
+	p.results++
+	props := p.getProperties(name, info)
+	p.w.WriteString(`<D:response><D:href>`)                    //nolint:errcheck
+	xml.EscapeText(p.w, []byte(p.getHref(name, info.IsDir()))) //nolint:errcheck
+	p.w.WriteString(`</D:href>`)                               //nolint:errcheck
+
+	switch {
+	case p.req.PropName != nil:
+		p.writePropstat(props, http.StatusOK, true)
+	case p.req.Prop != nil:
+		var found, missing []webdav.Property
+		for _, n := range p.req.Prop.names() {
+			if prop, ok := findProperty(props, n); ok {
+				found = append(found, prop)
+			} else {
+				missing = append(missing, webdav.Property{XMLName: n})
+			}
+		}
+		p.writePropstat(found, http.StatusOK, false)
+		p.writePropstat(missing, http.StatusNotFound, true)
+	default:
+		p.writePropstat(props, http.StatusOK, false)
+	}
+	p.w.WriteString(`</D:response>`) //nolint:errcheck
+
+	if p.results%propfindFlushInterval == 0 {
+		if err := p.w.Flush(); err != nil {
+			return err
+		}
+		return p.flusher.Flush()
+	}
+	return nil
+}
+
+func (p *multistatusWriter) writeTruncated(name string, isDir bool, description string) {
+	p.w.WriteString(`<D:response><D:href>`)                                            //nolint:errcheck
+	xml.EscapeText(p.w, []byte(p.getHref(name, isDir)))                                //nolint:errcheck
+	p.w.WriteString(`</D:href><D:status>HTTP/1.1 507 Insufficient Storage</D:status>`) //nolint:errcheck
+	p.w.WriteString(`<D:responsedescription>`)                                         //nolint:errcheck
+	xml.EscapeText(p.w, []byte(description))                                           //nolint:errcheck
+	p.w.WriteString(`</D:responsedescription>`)                                        //nolint:errcheck
+	p.w.WriteString(`</D:response>`)                                                   //nolint:errcheck
+}
+
+func (p *multistatusWriter) writePropstat(props []webdav.Property, status int, nameOnly bool) {
+	if len(props) == 0 {
+		return
+	}
+	p.w.WriteString(`<D:propstat><D:prop>`) //nolint:errcheck
+	for _, prop := range props {
+		writeProperty(p.w, prop, nameOnly)
+	}
+	fmt.Fprintf(p.w, `</D:prop><D:status>HTTP/1.1 %d %s</D:status></D:propstat>`, status, http.StatusText(status))
+}
+
+func (p *multistatusWriter) getProperties(name string, info os.FileInfo) []webdav.Property {
+	props := make([]webdav.Property, 0, 8)
+	addProp := func(local, innerXML string) {
+		props = append(props, webdav.Property{
+			XMLName:  xml.Name{Space: davNamespace, Local: local},
+			InnerXML: []byte(innerXML),
+		})
+	}
+	if info.IsDir() {
+		addProp("resourcetype", `<D:collection xmlns:D="DAV:"/>`)
+	} else {
+		addProp("resourcetype", "")
+	}
+	if name != "/" {
+		addProp("displayname", escapeXMLText(info.Name()))
+	}
+	addProp("getlastmodified", info.ModTime().UTC().Format(http.TimeFormat))
+	addProp("supportedlock", supportedLockXML)
+	if !info.IsDir() {
+		addProp("getcontentlength", strconv.FormatInt(info.Size(), 10))
+		addProp("getetag", fmt.Sprintf(`"%x%x"`, info.ModTime().UnixNano(), info.Size()))
+		if contentType := getPropfindContentType(info, name); contentType != "" {
+			addProp("getcontenttype", escapeXMLText(contentType))
+		}
+	}
+	if deadPropertiesEnabled {
+		stored, err := dataprovider.GetDAVProperties(p.connection.User.Username, name)
+		if err != nil {
+			p.connection.Log(logger.LevelWarn, "unable to get dead properties for %q: %v", name, err)
+		}
+		for _, prop := range getDeadPropsFromDAVProperties(stored) {
+			if _, ok := findProperty(props, prop.XMLName); !ok {
+				props = append(props, prop)
+			}
+		}
+	}
+	return props
+}
+
+func getPropfindContentType(info os.FileInfo, name string) string {
+	if ctyper, ok := info.(webdav.ContentTyper); ok {
+		if contentType, err := ctyper.ContentType(context.Background()); err == nil {
+			return contentType
+		}
+		return ""
+	}
+	return mime.TypeByExtension(path.Ext(name))
+}
+
+func findProperty(props []webdav.Property, name xml.Name) (webdav.Property, bool) {
+	for _, prop := range props {
+		if prop.XMLName == name {
+			return prop, true
+		}
+	}
+	return webdav.Property{}, false
+}
+
+func writeProperty(w *bufio.Writer, prop webdav.Property, nameOnly bool) {
+	var tag string
+	if prop.XMLName.Space == davNamespace {
+		tag = "D:" + prop.XMLName.Local
+		w.WriteString("<" + tag) //nolint:errcheck
+	} else {
+		tag = prop.XMLName.Local
+		w.WriteString("<" + tag + ` xmlns="`)         //nolint:errcheck
+		xml.EscapeText(w, []byte(prop.XMLName.Space)) //nolint:errcheck
+		w.WriteString(`"`)                            //nolint:errcheck
+	}
+	if !nameOnly && prop.Lang != "" {
+		w.WriteString(` xml:lang="`)         //nolint:errcheck
+		xml.EscapeText(w, []byte(prop.Lang)) //nolint:errcheck
+		w.WriteString(`"`)                   //nolint:errcheck
+	}
+	if nameOnly || len(prop.InnerXML) == 0 {
+		w.WriteString("/>") //nolint:errcheck
+		return
+	}
+	w.WriteString(">")              //nolint:errcheck
+	w.Write(prop.InnerXML)          //nolint:errcheck
+	w.WriteString("</" + tag + ">") //nolint:errcheck
+}
+
+func escapeXMLText(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s)) //nolint:errcheck
+	return sb.String()
+}
@@ -0,0 +1,432 @@
+// Copyright (C) 2019 Nicola Murino
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, version 3.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program. If not, see <https://www.gnu.org/licenses/>.
+
+package webdavd
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/drakkan/sftpgo/v2/internal/common"
+	"github.com/drakkan/sftpgo/v2/internal/logger"
+	"github.com/drakkan/sftpgo/v2/internal/util"
+	"github.com/drakkan/sftpgo/v2/internal/vfs"
+)
+
+const (
+	searchMethod         = "SEARCH"
+	basicSearchGrammar   = "<DAV:basicsearch>"
+	maxSearchRequestSize = 64 * 1024
+)
+
+var (
+	errInvalidSearch     = errors.New("invalid SEARCH request")
+	errUnsupportedSearch = errors.New("unsupported SEARCH request")
+)
+
+type searchRequest struct {
+	XMLName     xml.Name     `xml:"DAV: searchrequest"`
+	BasicSearch *basicSearch `xml:"DAV: basicsearch"`
+}
+
+type basicSearch struct {
+	Select struct {
+		AllProp *struct{}      `xml:"DAV: allprop"`
+		Prop    *propfindProps `xml:"DAV: prop"`
+	} `xml:"DAV: select"`
+	From struct {
+		Scopes []struct {
+			Href  string `xml:"DAV: href"`
+			Depth string `xml:"DAV: depth"`
+		} `xml:"DAV: scope"`
+	} `xml:"DAV: from"`
+	Where   *searchExpression `xml:"DAV: where"`
+	OrderBy *struct {
+		Orders []searchOrder `xml:"DAV: order"`
+	} `xml:"DAV: orderby"`
+	Limit *struct {
+		NResults string `xml:"DAV: nresults"`
+	} `xml:"DAV: limit"`
+}
+
+type searchOrder struct {
+	Prop       *propfindProps `xml:"DAV: prop"`
+	Descending *struct{}      `xml:"DAV: descending"`
+}
+
+type searchExpression struct {
+	XMLName  xml.Name
+	Prop     *propfindProps     `xml:"DAV: prop"`
+	Literal  *string            `xml:"DAV: literal"`
+	Children []searchExpression `xml:",any"`
+}
+
+// getPropName returns the only DAV property referenced by the expression
+func (e *searchExpression) getPropName() (string, error) {
+	if e.Prop == nil || len(e.Prop.Props) != 1 || e.Prop.Props[0].XMLName.Space != davNamespace {
+		return "", fmt.Errorf("%w: %q requires a single DAV property", errUnsupportedSearch, e.XMLName.Local)
+	}
+	return e.Prop.Props[0].XMLName.Local, nil
+}
+
+func (e *searchExpression) getLiteral() (string, error) {
+	if e.Literal == nil {
+		return "", fmt.Errorf("%w: %q requires a literal", errInvalidSearch, e.XMLName.Local)
+	}
+	return strings.TrimSpace(*e.Literal), nil
+}
+
+// apply converts the expression to search filters. Only the conditions supported
+// by the search subsystem can be used, combined using the "and" operator
+func (e *searchExpression) apply(filters *common.SearchFilters) error {
+	if e.XMLName.Space != davNamespace {
+		return fmt.Errorf("%w: operator %q", errUnsupportedSearch, e.XMLName.Local)
+	}
+	switch e.XMLName.Local {
+	case "where", "and":
+		for idx := range e.Children {
+			if err := e.Children[idx].apply(filters); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "is-collection":
+		return setSearchType(filters, common.SearchTypeDir)
+	case "not":
+		if len(e.Children) == 1 && e.Children[0].XMLName.Space == davNamespace &&
+			e.Children[0].XMLName.Local == "is-collection" {
+			return setSearchType(filters, common.SearchTypeFile)
+		}
+		return fmt.Errorf("%w: \"not\" is only supported for \"is-collection\"", errUnsupportedSearch)
+	case "like":
+		return e.applyLike(filters)
+	case "eq", "gt", "gte", "lt", "lte":
+		return e.applyComparison(filters)
+	default:
+		return fmt.Errorf("%w: operator %q", errUnsupportedSearch, e.XMLName.Local)
+	}
+}
+
+func (e *searchExpression) applyLike(filters *common.SearchFilters) error {
+	propName, err := e.getPropName()
+	if err != nil {
+		return err
+	}
+	if propName != "displayname" {
+		return fmt.Errorf("%w: \"like\" is only supported for \"displayname\"", errUnsupportedSearch)
+	}
+	literal, err := e.getLiteral()
+	if err != nil {
+		return err
+	}
+	if literal == "" || filters.Name != "" {
+		return fmt.Errorf("%w: a single, not empty, \"like\" condition is supported", errUnsupportedSearch)
+	}
+	filters.Name = strings.ToLower(convertLikePattern(literal))
+	return nil
+}
+
+func (e *searchExpression) applyComparison(filters *common.SearchFilters) error {
+	propName, err := e.getPropName()
+	if err != nil {
+		return err
+	}
+	literal, err := e.getLiteral()
+	if err != nil {
+		return err
+	}
+	switch propName {
+	case "getcontentlength":
+		size, err := strconv.ParseInt(literal, 10, 64)
+		if err != nil || size < 0 {
+			return fmt.Errorf("%w: invalid size %q", errInvalidSearch, literal)
+		}
+		return applySizeComparison(filters, e.XMLName.Local, size)
+	case "getlastmodified":
+		if e.XMLName.Local == "eq" {
+			return fmt.Errorf("%w: \"eq\" is not supported for \"getlastmodified\"", errUnsupportedSearch)
+		}
+		modTime, err := parseSearchTime(literal)
+		if err != nil {
+			return fmt.Errorf("%w: invalid date %q", errInvalidSearch, literal)
+		}
+		applyTimeComparison(filters, e.XMLName.Local, modTime)
+		return nil
+	default:
+		return fmt.Errorf("%w: comparisons are not supported for %q", errUnsupportedSearch, propName)
+	}
+}
+
+func setSearchType(filters *common.SearchFilters, searchType string) error {
+	if filters.Type != "" && filters.Type != searchType {
+		return fmt.Errorf("%w: conflicting \"is-collection\" conditions", errUnsupportedSearch)
+	}
+	filters.Type = searchType
+	return nil
+}
+
+// applySizeComparison restricts the size filters, a max size of 0 means no limit so
+// conditions allowing only empty files are not supported
+func applySizeComparison(filters *common.SearchFilters, operator string, size int64) error {
+	minSize, maxSize := int64(-1), int64(-1)
+	switch operator {
+	case "eq":
+		minSize, maxSize = size, size
+	case "gt":
+		minSize = size + 1
+	case "gte":
+		minSize = size
+	case "lt":
+		maxSize = size - 1
+	case "lte":
+		maxSize = size
+	}
+	if minSize > filters.MinSize {
+		filters.MinSize = minSize
+	}
+	if maxSize >= 0 {
+		if maxSize == 0 {
+			return fmt.Errorf("%w: conditions matching only empty files", errUnsupportedSearch)
+		}
+		if filters.MaxSize == 0 || maxSize < filters.MaxSize {
+			filters.MaxSize = maxSize
+		}
+	}
+	return nil
+}
+
+func applyTimeComparison(filters *common.SearchFilters, operator string, t time.Time) {
+	switch operator {
+	case "gt", "gte":
+		if operator == "gte" {
+			t = t.Add(-time.Nanosecond)
+		}
+		if filters.ModifiedAfter.IsZero() || t.After(filters.ModifiedAfter) {
+			filters.ModifiedAfter = t
+		}
+	case "lt", "lte":
+		if operator == "lte" {
+			t = t.Add(time.Nanosecond)
+		}
+		if filters.ModifiedBefore.IsZero() || t.Before(filters.ModifiedBefore) {
+			filters.ModifiedBefore = t
+		}
+	}
+}
+
+func parseSearchTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return parseTime(value)
+}
+
+// convertLikePattern converts a SQL like pattern to a shell pattern: "%" matches
+// any sequence of characters, "_" a single character and "\" is the escape character
+func convertLikePattern(pattern string) string {
+	var sb strings.Builder
+	escaped := false
+	for _, c := range pattern {
+		if escaped {
+			escaped = false
+			if strings.ContainsRune(`*?[]\`, c) {
+				sb.WriteRune('\\')
+			}
+			sb.WriteRune(c)
+			continue
+		}
+		switch c {
+		case '\\':
+			escaped = true
+		case '%':
+			sb.WriteRune('*')
+		case '_':
+			sb.WriteRune('?')
+		case '*', '?', '[', ']':
+			sb.WriteRune('\\')
+			sb.WriteRune(c)
+		default:
+			sb.WriteRune(c)
+		}
+	}
+	return sb.String()
+}
+
+func parseSearchRequest(r io.Reader) (*basicSearch, error) {
+	var req searchRequest
+	if err := xml.NewDecoder(io.LimitReader(r, maxSearchRequestSize)).Decode(&req); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidSearch, err)
+	}
+	if req.BasicSearch == nil {
+		return nil, fmt.Errorf("%w: only the basicsearch grammar is supported", errUnsupportedSearch)
+	}
+	search := req.BasicSearch
+	if search.Select.AllProp == nil && search.Select.Prop == nil {
+		return nil, fmt.Errorf("%w: select requires allprop or prop", errInvalidSearch)
+	}
+	if len(search.From.Scopes) != 1 {
+		return nil, fmt.Errorf("%w: a single scope is supported", errUnsupportedSearch)
+	}
+	if depth := search.From.Scopes[0].Depth; depth != "" && !strings.EqualFold(depth, "infinity") {
+		return nil, fmt.Errorf("%w: only infinite depth is supported", errUnsupportedSearch)
+	}
+	return search, nil
+}
+
+func (s *webDavServer) getSearchFilters(r *http.Request, search *basicSearch) (common.SearchFilters, error) {
+	filters := common.SearchFilters{
+		Limit: common.SearchMaxLimit,
+	}
+	href, err := url.Parse(strings.TrimSpace(search.From.Scopes[0].Href))
+	if err != nil {
+		return filters, fmt.Errorf("%w: invalid scope: %v", errInvalidSearch, err)
+	}
+	scopePath := r.URL.ResolveReference(href).Path
+	if prefix := s.getRequestPrefix(r); prefix != "" {
+		if scopePath != prefix && !strings.HasPrefix(scopePath, prefix+"/") {
+			return filters, fmt.Errorf("%w: the scope %q is outside the binding prefix", errInvalidSearch, scopePath)
+		}
+		scopePath = strings.TrimPrefix(scopePath, prefix)
+	}
+	filters.BaseDir = util.CleanPath(scopePath)
+	if search.Where != nil {
+		if err := search.Where.apply(&filters); err != nil {
+			return filters, err
+		}
+	}
+	if search.Limit != nil {
+		limit, err := strconv.Atoi(strings.TrimSpace(search.Limit.NResults))
+		if err != nil || limit <= 0 {
+			return filters, fmt.Errorf("%w: invalid limit %q", errInvalidSearch, search.Limit.NResults)
+		}
+		filters.Limit = min(limit, common.SearchMaxLimit)
+	}
+	if err := filters.Validate(); err != nil {
+		return filters, fmt.Errorf("%w: %v", errUnsupportedSearch, err)
+	}
+	return filters, nil
+}
+
+// sortSearchResults sorts the results as specified in the orderby element
+func sortSearchResults(entries []common.SearchEntry, search *basicSearch) error {
+	if search.OrderBy == nil {
+		return nil
+	}
+	orders := search.OrderBy.Orders
+	for idx := len(orders) - 1; idx >= 0; idx-- {
+		var less func(a, b *common.SearchEntry) bool
+		order := searchExpression{XMLName: xml.Name{Space: davNamespace, Local: "order"}, Prop: orders[idx].Prop}
+		propName, err := order.getPropName()
+		if err != nil {
+			return err
+		}
+		switch propName {
+		case "displayname":
+			less = func(a, b *common.SearchEntry) bool { return strings.ToLower(a.Name) < strings.ToLower(b.Name) }
+		case "getcontentlength":
+			less = func(a, b *common.SearchEntry) bool { return a.Size < b.Size }
+		case "getlastmodified":
+			less = func(a, b *common.SearchEntry) bool { return a.ModTime.Before(b.ModTime) }
+		default:
+			return fmt.Errorf("%w: ordering by %q", errUnsupportedSearch, propName)
+		}
+		descending := orders[idx].Descending != nil
+		sort.SliceStable(entries, func(i, j int) bool {
+			if descending {
+				return less(&entries[j], &entries[i])
+			}
+			return less(&entries[i], &entries[j])
+		})
+	}
+	return nil
+}
+
+func getSearchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidSearch):
+		return http.StatusBadRequest
+	case errors.Is(err, errUnsupportedSearch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, os.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, os.ErrPermission):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// handleSearch handles SEARCH requests using the DASL basicsearch grammar, RFC 5323.
+// The search is executed by the same subsystem used for WebClient and REST API users
+func (s *webDavServer) handleSearch(w http.ResponseWriter, r *http.Request, connection *Connection) {
+	search, err := parseSearchRequest(r.Body)
+	if err == nil {
+		var filters common.SearchFilters
+		filters, err = s.getSearchFilters(r, search)
+		if err == nil {
+			var entries []common.SearchEntry
+			var truncated bool
+			startTime := time.Now()
+			entries, truncated, err = common.SearchFiles(connection.BaseConnection, &filters, nil)
+			if err == nil {
+				err = sortSearchResults(entries, search)
+			}
+			if err == nil {
+				connection.Log(logger.LevelDebug, "search in %q completed, results: %d, truncated: %t, elapsed: %s",
+					filters.BaseDir, len(entries), truncated, time.Since(startTime))
+				s.writeSearchResults(w, r, connection, search, &filters, entries, truncated)
+				return
+			}
+		}
+	}
+	status := getSearchErrorStatus(err)
+	if status == http.StatusInternalServerError {
+		connection.Log(logger.LevelError, "unable to execute search: %v", err)
+	}
+	http.Error(w, err.Error(), status)
+	writeLog(r, status, err)
+}
+
+func (s *webDavServer) writeSearchResults(w http.ResponseWriter, r *http.Request, connection *Connection,
+	search *basicSearch, filters *common.SearchFilters, entries []common.SearchEntry, truncated bool,
+) {
+	req := propfindRequest{
+		AllProp: search.Select.AllProp,
+		Prop:    search.Select.Prop,
+	}
+	m := newMultistatusWriter(w, connection, s.getRequestPrefix(r), req)
+	var err error
+	for _, entry := range entries {
+		info := vfs.NewFileInfo(entry.Name, entry.IsDir, entry.Size, entry.ModTime, false)
+		if err = m.writeResponse(entry.Path, info); err != nil {
+			break
+		}
+	}
+	if err == nil && truncated {
+		m.writeTruncated(filters.BaseDir, true, "the search results were truncated, please refine the query")
+	}
+	if errClose := m.close(); err == nil {
+		err = errClose
+	}
+	writeLog(r, http.StatusMultiStatus, err)
+}
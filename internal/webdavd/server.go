@@ -21,11 +21,14 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"net"
 	"net/http"
+	"os"
 	"path"
 	"path/filepath"
 	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
 
@@ -120,13 +123,9 @@ func (s *webDavServer) verifyTLSConnection(state tls.ConnectionState) error {
 			return errors.New("TLS connection cannot be verified: unable to get verification chain")
 		}
 		for _, verifiedChain := range state.VerifiedChains {
-			var caCrt *x509.Certificate
-			if len(verifiedChain) > 0 {
-				caCrt = verifiedChain[len(verifiedChain)-1]
-			}
-			if certMgr.IsRevoked(clientCrt, caCrt) {
-				logger.Debug(logSender, "", "tls handshake error, client certificate %q has been revoked", clientCrtName)
-				return common.ErrCrtRevoked
+			if err := certMgr.CheckRevocation(verifiedChain); err != nil {
+				logger.Debug(logSender, "", "tls handshake error, client certificate %q: %v", clientCrtName, err)
+				return err
 			}
 		}
 	}
@@ -134,15 +133,28 @@ func (s *webDavServer) verifyTLSConnection(state tls.ConnectionState) error {
 	return nil
 }
 
+func (s *webDavServer) getRequestPath(r *http.Request) string {
+	p := path.Clean(r.URL.Path)
+	if prefix := s.getRequestPrefix(r); prefix != "" {
+		p = strings.TrimPrefix(p, prefix)
+	}
+	return p
+}
+
+// getRequestPrefix returns the URL prefix resolved for the request, it can differ from
+// the binding prefix if it contains the username placeholder or for user prefixes
+func (s *webDavServer) getRequestPrefix(r *http.Request) string {
+	if prefix, ok := r.Context().Value(requestPrefixKey).(string); ok {
+		return prefix
+	}
+	return s.binding.Prefix
+}
+
 // returns true if we have to handle a HEAD response, for a directory, ourself
 func (s *webDavServer) checkRequestMethod(ctx context.Context, r *http.Request, connection *Connection) bool {
 	// see RFC4918, section 9.4
 	if r.Method == http.MethodGet || r.Method == http.MethodHead {
-		p := path.Clean(r.URL.Path)
-		if s.binding.Prefix != "" {
-			p = strings.TrimPrefix(p, s.binding.Prefix)
-		}
-		info, err := connection.Stat(ctx, p)
+		info, err := connection.Stat(ctx, s.getRequestPath(r))
 		if err == nil && info.IsDir() {
 			if r.Method == http.MethodHead {
 				return true
@@ -156,6 +168,139 @@ func (s *webDavServer) checkRequestMethod(ctx context.Context, r *http.Request,
 	return false
 }
 
+// checkPartialUpload handles the partial updates: a PUT request with a Content-Range header
+// or a PATCH request using the SabreDAV partial update convention. Only resuming an interrupted
+// upload is supported, so the data must be appended to the existing file. Partial updates are
+// converted to PUT requests and the offset is saved in the connection. It returns a non-zero
+// status code, and the related error, if the request must be rejected
+func (s *webDavServer) checkPartialUpload(ctx context.Context, r *http.Request, connection *Connection) (int, error) {
+	offset, isPartial, err := getPartialUploadOffset(r)
+	if !isPartial {
+		return 0, nil
+	}
+	if err != nil {
+		if errors.Is(err, errUnsupportedPatchType) {
+			return http.StatusUnsupportedMediaType, err
+		}
+		return http.StatusBadRequest, err
+	}
+	var fileSize int64
+	info, err := connection.Stat(ctx, s.getRequestPath(r))
+	if err == nil {
+		if info.IsDir() {
+			return http.StatusMethodNotAllowed, errors.New("partial updates are not allowed for directories")
+		}
+		fileSize = info.Size()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		if errors.Is(err, os.ErrPermission) {
+			return http.StatusForbidden, err
+		}
+		return http.StatusInternalServerError, err
+	}
+	if offset < 0 {
+		offset = fileSize
+	}
+	if offset > 0 && offset != fileSize {
+		connection.Log(logger.LevelDebug, "partial update rejected, offset %d, file size %d", offset, fileSize)
+		return http.StatusRequestedRangeNotSatisfiable,
+			fmt.Errorf("invalid offset %d, partial updates must start at the current file size %d", offset, fileSize)
+	}
+	connection.uploadOffset = offset
+	r.Method = http.MethodPut
+	return 0, nil
+}
+
+// getPartialUploadOffset returns the upload offset for partial updates, -1 means append.
+// The second return value is false if the request is not a partial update.
+// PATCH requests are only supported as SabreDAV partial updates
+func getPartialUploadOffset(r *http.Request) (int64, bool, error) {
+	switch r.Method {
+	case http.MethodPut:
+		contentRange := r.Header.Get("Content-Range")
+		if contentRange == "" {
+			return 0, false, nil
+		}
+		offset, err := parseContentRange(contentRange, r.ContentLength)
+		return offset, true, err
+	case http.MethodPatch:
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType != sabreDAVPartialUpdateContentType {
+			return 0, true, errUnsupportedPatchType
+		}
+		offset, err := parseUpdateRange(r.Header.Get("X-Update-Range"), r.ContentLength)
+		return offset, true, err
+	default:
+		return 0, false, nil
+	}
+}
+
+// parseContentRange parses a Content-Range header such as "bytes 100-199/1000",
+// the total length can be unknown, "*"
+func parseContentRange(value string, contentLength int64) (int64, error) {
+	rangeSpec, found := strings.CutPrefix(value, "bytes ")
+	if !found {
+		return 0, fmt.Errorf("unsupported Content-Range %q", value)
+	}
+	rangeSpec, total, found := strings.Cut(rangeSpec, "/")
+	if !found {
+		return 0, fmt.Errorf("invalid Content-Range %q", value)
+	}
+	start, end, err := parseByteRange(rangeSpec, false)
+	if err != nil {
+		return 0, fmt.Errorf("invalid Content-Range %q: %w", value, err)
+	}
+	if total != "*" {
+		size, err := strconv.ParseInt(total, 10, 64)
+		if err != nil || size <= end {
+			return 0, fmt.Errorf("invalid Content-Range %q", value)
+		}
+	}
+	if contentLength >= 0 && end-start+1 != contentLength {
+		return 0, fmt.Errorf("the Content-Range %q does not match the content length %d", value, contentLength)
+	}
+	return start, nil
+}
+
+// parseUpdateRange parses a SabreDAV X-Update-Range header: "append", "bytes=start-end"
+// or "bytes=start-". Ranges relative to the end of the file, "bytes=-N", are not supported
+func parseUpdateRange(value string, contentLength int64) (int64, error) {
+	if value == "append" {
+		return -1, nil
+	}
+	rangeSpec, found := strings.CutPrefix(value, "bytes=")
+	if !found || strings.HasPrefix(rangeSpec, "-") {
+		return 0, fmt.Errorf("unsupported X-Update-Range %q", value)
+	}
+	start, end, err := parseByteRange(rangeSpec, true)
+	if err != nil {
+		return 0, fmt.Errorf("invalid X-Update-Range %q: %w", value, err)
+	}
+	if end >= 0 && contentLength >= 0 && end-start+1 != contentLength {
+		return 0, fmt.Errorf("the X-Update-Range %q does not match the content length %d", value, contentLength)
+	}
+	return start, nil
+}
+
+// parseByteRange parses a "start-end" byte range, end is -1 if it is optional and not set
+func parseByteRange(value string, isEndOptional bool) (int64, int64, error) {
+	startValue, endValue, found := strings.Cut(value, "-")
+	if !found {
+		return 0, 0, errors.New("missing range separator")
+	}
+	start, err := strconv.ParseInt(startValue, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, errors.New("invalid range start")
+	}
+	if endValue == "" && isEndOptional {
+		return start, -1, nil
+	}
+	end, err := strconv.ParseInt(endValue, 10, 64)
+	if err != nil || end < start {
+		return 0, 0, errors.New("invalid range end")
+	}
+	return start, end, nil
+}
+
 // ServeHTTP implements the http.Handler interface
 func (s *webDavServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	defer func() {
@@ -191,14 +336,29 @@ func (s *webDavServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, common.ErrConnectionDenied.Error(), http.StatusForbidden)
 		return
 	}
+	prefix, prefixUsername, ok := s.binding.getRequestPrefix(r.URL.Path)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), requestPrefixKey, prefix))
 	user, isCached, lockSystem, loginMethod, err := s.authenticate(r, ipAddr)
 	if err != nil {
 		if !s.binding.DisableWWWAuthHeader {
 			w.Header().Set("WWW-Authenticate", "Basic realm=\"SFTPGo WebDAV\"")
+			if s.config.BearerAuth.isEnabled() {
+				w.Header().Add("WWW-Authenticate", "Bearer realm=\"SFTPGo WebDAV\"")
+			}
 		}
 		http.Error(w, fmt.Sprintf("Authentication error: %v", err), http.StatusUnauthorized)
 		return
 	}
+	if prefixUsername != "" && prefixUsername != user.Username {
+		logger.Info(logSender, "", "user %q is not allowed to access the prefix %q reserved to %q",
+			user.Username, prefix, prefixUsername)
+		http.Error(w, common.ErrPermissionDenied.Error(), http.StatusForbidden)
+		return
+	}
 
 	connectionID, err := s.validateUser(&user, r, loginMethod)
 	if err != nil {
@@ -221,6 +381,9 @@ func (s *webDavServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if s.binding.ReadOnly {
+		user.SetReadOnlyPermissions()
+	}
 
 	connection := &Connection{
 		BaseConnection: common.NewBaseConnection(connectionID, common.ProtocolWebDAV, util.GetHTTPLocalAddress(r),
@@ -250,9 +413,25 @@ func (s *webDavServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		writeLog(r, http.StatusMultiStatus, nil)
 		return
 	}
+	if status, err := s.checkPartialUpload(ctx, r, connection); err != nil {
+		http.Error(w, err.Error(), status)
+		writeLog(r, status, err)
+		return
+	}
+	if s.isInfiniteDepthPropfind(r) {
+		s.handleInfiniteDepthPropfind(ctx, w, r.WithContext(ctx), connection)
+		return
+	}
+	switch r.Method {
+	case searchMethod:
+		s.handleSearch(w, r.WithContext(ctx), connection)
+		return
+	case http.MethodOptions:
+		w.Header().Set("DASL", basicSearchGrammar)
+	}
 
 	handler := webdav.Handler{
-		Prefix:     s.binding.Prefix,
+		Prefix:     s.getRequestPrefix(r),
 		FileSystem: connection,
 		LockSystem: lockSystem,
 		Logger:     writeLog,
@@ -280,9 +459,61 @@ func (s *webDavServer) getCredentialsAndLoginMethod(r *http.Request) (string, st
 	return username, password, loginMethod, tlsCert, ok
 }
 
+func (s *webDavServer) authenticateWithBearerToken(r *http.Request, ip, rawToken string) (dataprovider.User, bool, webdav.LockSystem, string, error) {
+	var user dataprovider.User
+	loginMethod := dataprovider.LoginMethodIDP
+
+	username, err := s.config.BearerAuth.getUsername(r.Context(), rawToken)
+	if err != nil {
+		logger.Debug(logSender, "", "unable to validate bearer token: %v", err)
+		updateLoginMetrics(&user, ip, loginMethod, dataprovider.ErrInvalidCredentials)
+		return user, false, nil, loginMethod, dataprovider.ErrInvalidCredentials
+	}
+	// the token is valid, the cached user can be used as is
+	cachedUser, ok := dataprovider.GetCachedWebDAVUser(username)
+	if ok && !cachedUser.IsExpired() {
+		if err := cachedUser.User.CheckLoginConditions(); err != nil {
+			return user, false, nil, loginMethod, err
+		}
+		return cachedUser.User, true, cachedUser.LockSystem, loginMethod, nil
+	}
+	user, err = dataprovider.GetUserAfterIDPAuth(username, ip, common.ProtocolWebDAV, nil)
+	if err == nil {
+		err = user.CheckLoginConditions()
+	}
+	if err != nil {
+		user.Username = username
+		updateLoginMetrics(&user, ip, loginMethod, err)
+		return user, false, nil, loginMethod, dataprovider.ErrInvalidCredentials
+	}
+	lockSystem := newLockSystem(user.Username)
+	// the password is not cached, so basic authentication always requires
+	// the user password
+	dataprovider.CacheWebDAVUser(&dataprovider.CachedUser{
+		User:       user,
+		LockSystem: lockSystem,
+		Expiration: s.config.Cache.Users.getExpirationTime(),
+	})
+	return user, false, lockSystem, loginMethod, nil
+}
+
+// getCookieAllowedOrigins returns the CORS origins allowed to send requests
+// authenticated using the token cookie
+func (s *webDavServer) getCookieAllowedOrigins() []string {
+	if !s.config.Cors.Enabled {
+		return nil
+	}
+	return s.config.Cors.AllowedOrigins
+}
+
 func (s *webDavServer) authenticate(r *http.Request, ip string) (dataprovider.User, bool, webdav.LockSystem, string, error) {
 	var user dataprovider.User
 	var err error
+	if s.config.BearerAuth.isEnabled() {
+		if rawToken, ok := s.config.BearerAuth.getRawToken(r, s.getCookieAllowedOrigins()); ok {
+			return s.authenticateWithBearerToken(r, ip, rawToken)
+		}
+	}
 	username, password, loginMethod, tlsCert, ok := s.getCredentialsAndLoginMethod(r)
 	if !ok {
 		user.Username = username
@@ -303,7 +534,7 @@ func (s *webDavServer) authenticate(r *http.Request, ip string) (dataprovider.Us
 				if cu != nil {
 					return cu.User, true, cu.LockSystem, loginMethod, nil
 				}
-				lockSystem := webdav.NewMemLS()
+				lockSystem := newLockSystem(u.Username)
 				cachedUser = &dataprovider.CachedUser{
 					User:       *u,
 					Password:   password,
@@ -324,7 +555,7 @@ func (s *webDavServer) authenticate(r *http.Request, ip string) (dataprovider.Us
 		updateLoginMetrics(&user, ip, loginMethod, err)
 		return user, false, nil, loginMethod, dataprovider.ErrInvalidCredentials
 	}
-	lockSystem := webdav.NewMemLS()
+	lockSystem := newLockSystem(user.Username)
 	cachedUser = &dataprovider.CachedUser{
 		User:       user,
 		Password:   password,
@@ -344,6 +575,7 @@ func (s *webDavServer) validateUser(user *dataprovider.User, r *http.Request, lo
 			user.Username, user.HomeDir)
 		return connID, fmt.Errorf("cannot login user with invalid home dir: %q", user.HomeDir)
 	}
+	user.ApplySourceAuthPolicy(r.RemoteAddr)
 	if util.Contains(user.Filters.DeniedProtocols, common.ProtocolWebDAV) {
 		logger.Info(logSender, connectionID, "cannot login user %q, protocol DAV is not allowed", user.Username)
 		return connID, fmt.Errorf("protocol DAV is not allowed for user %q", user.Username)
@@ -16,13 +16,20 @@
 package webdavd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/go-chi/chi/v5/middleware"
 
 	"github.com/drakkan/sftpgo/v2/internal/common"
@@ -36,16 +43,23 @@ type ctxReqParams int
 const (
 	requestIDKey ctxReqParams = iota
 	requestStartKey
+	requestPrefixKey
 )
 
 const (
-	logSender = "webdavd"
+	logSender                        = "webdavd"
+	usernamePlaceholder              = "%username%"
+	sabreDAVPartialUpdateContentType = "application/x-sabredav-partialupdate"
 )
 
 var (
-	certMgr       *common.CertManager
-	serviceStatus ServiceStatus
-	timeFormats   = []string{
+	errUnsupportedPatchType = fmt.Errorf("unsupported PATCH content type, only %q is supported",
+		sabreDAVPartialUpdateContentType)
+
+	certMgr               *common.CertManager
+	serviceStatus         ServiceStatus
+	deadPropertiesEnabled bool
+	timeFormats           = []string{
 		http.TimeFormat,
 		"Mon, _2 Jan 2006 15:04:05 GMT",
 		time.RFC850,
@@ -105,6 +119,14 @@ type Cache struct {
 	MimeTypes MimeCacheConfig  `json:"mime_types" mapstructure:"mime_types"`
 }
 
+// UserPrefix defines a WebDAV URL prefix reserved to a user
+type UserPrefix struct {
+	// Absolute URI, for example "/legacy/files"
+	Prefix string `json:"prefix" mapstructure:"prefix"`
+	// Only this user can access the resources below the prefix
+	Username string `json:"username" mapstructure:"username"`
+}
+
 // Binding defines the configuration for a network listener
 type Binding struct {
 	// The address to listen on. A blank value means listen on all available network interfaces.
@@ -137,7 +159,12 @@ type Binding struct {
 	Protocols []string `json:"tls_protocols" mapstructure:"tls_protocols"`
 	// Prefix for WebDAV resources, if empty WebDAV resources will be available at the
 	// root ("/") URI. If defined it must be an absolute URI.
+	// The "%username%" placeholder can be used as a path segment, for example "/dav/%username%",
+	// in this case each user can only access the resources below its own prefix
 	Prefix string `json:"prefix" mapstructure:"prefix"`
+	// Custom prefixes mapped to specific users, for example to keep the URLs used with a
+	// legacy server. They take precedence over the prefix defined above
+	UserPrefixes []UserPrefix `json:"user_prefixes" mapstructure:"user_prefixes"`
 	// List of IP addresses and IP ranges allowed to set client IP proxy headers
 	ProxyAllowed []string `json:"proxy_allowed" mapstructure:"proxy_allowed"`
 	// Allowed client IP proxy header such as "X-Forwarded-For", "X-Real-IP"
@@ -150,7 +177,77 @@ type Binding struct {
 	// Do not add the WWW-Authenticate header after an authentication error,
 	// only the 401 status code will be sent
 	DisableWWWAuthHeader bool `json:"disable_www_auth_header" mapstructure:"disable_www_auth_header"`
-	allowHeadersFrom     []func(net.IP) bool
+	// If enabled the permissions for all the users connected to this binding
+	// are restricted to list and download
+	ReadOnly         bool `json:"read_only" mapstructure:"read_only"`
+	allowHeadersFrom []func(net.IP) bool
+}
+
+func (b *Binding) validatePrefixes() error {
+	if strings.Contains(b.Prefix, usernamePlaceholder) {
+		if !path.IsAbs(b.Prefix) || path.Clean(b.Prefix) != b.Prefix {
+			return fmt.Errorf("invalid prefix %q, it must be an absolute and clean URI", b.Prefix)
+		}
+		for _, segment := range strings.Split(b.Prefix, "/") {
+			if strings.Contains(segment, usernamePlaceholder) && segment != usernamePlaceholder {
+				return fmt.Errorf("invalid prefix %q, the username placeholder must be a path segment", b.Prefix)
+			}
+		}
+	}
+	prefixes := make(map[string]bool)
+	for idx := range b.UserPrefixes {
+		p := &b.UserPrefixes[idx]
+		if p.Username == "" {
+			return fmt.Errorf("the username for the user prefix %q is mandatory", p.Prefix)
+		}
+		if p.Prefix == "/" || !path.IsAbs(p.Prefix) || path.Clean(p.Prefix) != p.Prefix ||
+			strings.Contains(p.Prefix, usernamePlaceholder) {
+			return fmt.Errorf("invalid user prefix %q", p.Prefix)
+		}
+		if prefixes[p.Prefix] {
+			return fmt.Errorf("duplicated user prefix %q", p.Prefix)
+		}
+		prefixes[p.Prefix] = true
+	}
+	// the longest prefixes must be matched first
+	sort.SliceStable(b.UserPrefixes, func(i, j int) bool {
+		return len(b.UserPrefixes[i].Prefix) > len(b.UserPrefixes[j].Prefix)
+	})
+	return nil
+}
+
+// getRequestPrefix returns the prefix matching the specified URL path and, if the prefix
+// is restricted to a specific user, the username. It returns false if the path does not
+// match a prefix with the username placeholder
+func (b *Binding) getRequestPrefix(urlPath string) (string, string, bool) {
+	urlPath = path.Clean(urlPath)
+	for _, p := range b.UserPrefixes {
+		if urlPath == p.Prefix || strings.HasPrefix(urlPath, p.Prefix+"/") {
+			return p.Prefix, p.Username, true
+		}
+	}
+	if !strings.Contains(b.Prefix, usernamePlaceholder) {
+		return b.Prefix, "", true
+	}
+	templateSegments := strings.Split(strings.TrimPrefix(b.Prefix, "/"), "/")
+	segments := strings.Split(strings.TrimPrefix(urlPath, "/"), "/")
+	if len(segments) < len(templateSegments) {
+		return "", "", false
+	}
+	var username string
+	for idx, segment := range templateSegments {
+		if segment == usernamePlaceholder {
+			if segments[idx] == "" {
+				return "", "", false
+			}
+			username = segments[idx]
+			continue
+		}
+		if segment != segments[idx] {
+			return "", "", false
+		}
+	}
+	return "/" + strings.Join(segments[:len(templateSegments)], "/"), username, true
 }
 
 func (b *Binding) parseAllowedProxy() error {
@@ -181,6 +278,128 @@ func (b *Binding) IsValid() bool {
 	return b.Port > 0
 }
 
+// BearerAuthConfig defines the configuration to authenticate users using
+// OAuth2/OpenID Connect bearer tokens
+type BearerAuthConfig struct {
+	// IssuerURL is the OpenID Connect issuer. The provider configuration and signing
+	// keys are retrieved from this URL on startup. Leave empty to disable bearer
+	// token authentication
+	IssuerURL string `json:"issuer_url" mapstructure:"issuer_url"`
+	// Expected token audience, required if bearer token authentication is enabled
+	Audience string `json:"audience" mapstructure:"audience"`
+	// Token claims field to map to the SFTPGo username
+	UsernameField string `json:"username_field" mapstructure:"username_field"`
+	// Name of a cookie to read the token from if no "Authorization: Bearer" header
+	// is provided. Browser based clients can use it to authenticate requests
+	// without exposing the token to scripts. Leave empty to disable
+	CookieName string `json:"cookie_name" mapstructure:"cookie_name"`
+	verifier   *oidc.IDTokenVerifier
+}
+
+func (c *BearerAuthConfig) isEnabled() bool {
+	return c.verifier != nil
+}
+
+// getRawToken returns the bearer token from the Authorization header or,
+// if configured, from the token cookie
+func (c *BearerAuthConfig) getRawToken(r *http.Request, allowedOrigins []string) (string, bool) {
+	if rawToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return rawToken, true
+	}
+	if c.CookieName != "" {
+		if cookie, err := r.Cookie(c.CookieName); err == nil && cookie.Value != "" {
+			if !isCookieAuthAllowed(r, allowedOrigins) {
+				logger.Debug(logSender, "", "token cookie not allowed for method %q, origin %q", r.Method, r.Header.Get("Origin"))
+				return "", false
+			}
+			return cookie.Value, true
+		}
+	}
+	return "", false
+}
+
+// isCookieAuthAllowed returns true if a cookie can be used to authenticate the
+// specified request. Browsers send cookies automatically, to prevent cross-site
+// request forgery, the requests that can modify resources are only allowed if
+// they come from the same origin or from one of the allowed CORS origins
+func isCookieAuthAllowed(r *http.Request, allowedOrigins []string) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, "PROPFIND", searchMethod:
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" || origin == "null" {
+		return false
+	}
+	for _, allowed := range allowedOrigins {
+		if strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func (c *BearerAuthConfig) initialize() error {
+	if c.IssuerURL == "" {
+		return nil
+	}
+	if c.Audience == "" {
+		return errors.New("bearer auth: audience cannot be empty")
+	}
+	if c.UsernameField == "" {
+		return errors.New("bearer auth: username field cannot be empty")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	provider, err := oidc.NewProvider(ctx, c.IssuerURL)
+	if err != nil {
+		return fmt.Errorf("bearer auth: unable to initialize provider for URL %q: %w", c.IssuerURL, err)
+	}
+	c.verifier = provider.Verifier(&oidc.Config{
+		ClientID: c.Audience,
+	})
+	return nil
+}
+
+// InfiniteDepthConfig defines the configuration for PROPFIND requests with "Depth: infinity"
+type InfiniteDepthConfig struct {
+	// Set to true to allow PROPFIND requests with "Depth: infinity"
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Maximum number of resources returned for each request. If the limit is reached
+	// the response is truncated and a "507 Insufficient Storage" status is returned
+	// for the requested resource
+	MaxResults int `json:"max_results" mapstructure:"max_results"`
+}
+
+func (c *InfiniteDepthConfig) validate() error {
+	if c.Enabled && c.MaxResults <= 0 {
+		return fmt.Errorf("infinite depth: invalid max results %d", c.MaxResults)
+	}
+	return nil
+}
+
+// getUsername verifies the given token and returns the username from its claims
+func (c *BearerAuthConfig) getUsername(ctx context.Context, rawToken string) (string, error) {
+	token, err := c.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return "", err
+	}
+	claims := make(map[string]any)
+	if err := token.Claims(&claims); err != nil {
+		return "", err
+	}
+	username, ok := claims[c.UsernameField].(string)
+	if !ok || username == "" {
+		return "", fmt.Errorf("username field %q not found, empty or not a string", c.UsernameField)
+	}
+	return username, nil
+}
+
 // Configuration defines the configuration for the WevDAV server
 type Configuration struct {
 	// Addresses and ports to bind to
@@ -196,11 +415,20 @@ type Configuration struct {
 	// CARevocationLists defines a set a revocation lists, one for each root CA, to be used to check
 	// if a client certificate has been revoked
 	CARevocationLists []string `json:"ca_revocation_lists" mapstructure:"ca_revocation_lists"`
+	// Additional revocation checks for client certificates
+	RevocationCheck common.RevocationCheckConfig `json:"revocation_check" mapstructure:"revocation_check"`
 	// CORS configuration
 	Cors CorsConfig `json:"cors" mapstructure:"cors"`
 	// Cache configuration
-	Cache      Cache `json:"cache" mapstructure:"cache"`
-	acmeDomain string
+	Cache Cache `json:"cache" mapstructure:"cache"`
+	// Bearer token authentication, in addition to basic authentication
+	BearerAuth BearerAuthConfig `json:"bearer_auth" mapstructure:"bearer_auth"`
+	// DeadProperties enables storing the properties set by the clients using PROPPATCH requests.
+	// Properties are stored in the data provider, an SQL based provider is required
+	DeadProperties bool `json:"dead_properties" mapstructure:"dead_properties"`
+	// Configuration for PROPFIND requests with "Depth: infinity"
+	InfiniteDepth InfiniteDepthConfig `json:"infinite_depth" mapstructure:"infinite_depth"`
+	acmeDomain    string
 }
 
 // GetStatus returns the server status
@@ -304,6 +532,12 @@ func (c *Configuration) Initialize(configDir string) error {
 	if !c.ShouldBind() {
 		return common.ErrNoBinding
 	}
+	if err := c.BearerAuth.initialize(); err != nil {
+		return err
+	}
+	if err := c.InfiniteDepth.validate(); err != nil {
+		return err
+	}
 
 	keyPairs := c.getKeyPairs(configDir)
 	if len(keyPairs) > 0 {
@@ -319,9 +553,14 @@ func (c *Configuration) Initialize(configDir string) error {
 		if err := mgr.LoadCRLs(); err != nil {
 			return err
 		}
+		if err := c.RevocationCheck.Validate(); err != nil {
+			return err
+		}
+		mgr.SetRevocationCheck(c.RevocationCheck)
 		certMgr = mgr
 	}
-	compressor := middleware.NewCompressor(5, "text/*")
+	compressor := middleware.NewCompressor(5, "text/*", "application/xml")
+	deadPropertiesEnabled = c.DeadProperties
 	dataprovider.InitializeWebDAVUserCache(c.Cache.Users.MaxSize)
 
 	serviceStatus = ServiceStatus{
@@ -337,6 +576,9 @@ func (c *Configuration) Initialize(configDir string) error {
 		if err := binding.parseAllowedProxy(); err != nil {
 			return err
 		}
+		if err := binding.validatePrefixes(); err != nil {
+			return err
+		}
 
 		go func(binding Binding) {
 			server := webDavServer{
@@ -964,6 +964,80 @@ func TestMtimeHeader(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestPartialUploads(t *testing.T) {
+	u := getTestUser()
+	u.QuotaSize = 1000
+	user, _, err := httpdtest.AddUser(u, http.StatusCreated)
+	assert.NoError(t, err)
+	client := getWebDavClient(user, false, nil)
+	assert.NoError(t, checkBasicFunc(client))
+
+	partialUpdateContentType := "application/x-sabredav-partialupdate"
+	fileContents := util.GenerateRandomBytes(300)
+	httpClient := httpclient.GetHTTPClient()
+	doRequest := func(method string, data []byte, headers map[string]string) int {
+		req, err := http.NewRequest(method, fmt.Sprintf("http://%v/%v", webDavServerAddr, testFileName),
+			bytes.NewReader(data))
+		assert.NoError(t, err)
+		req.SetBasicAuth(u.Username, u.Password)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := httpClient.Do(req)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		err = resp.Body.Close()
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+	// an upload starting at offset 0 is a normal upload
+	status := doRequest(http.MethodPut, fileContents[:100], map[string]string{"Content-Range": "bytes 0-99/300"})
+	assert.Equal(t, http.StatusCreated, status)
+	status = doRequest(http.MethodPut, fileContents[200:], map[string]string{"Content-Range": "bytes 200-299/300"})
+	assert.Equal(t, http.StatusRequestedRangeNotSatisfiable, status)
+	status = doRequest(http.MethodPut, fileContents[100:200], map[string]string{"Content-Range": "bytes 100-200/300"})
+	assert.Equal(t, http.StatusBadRequest, status)
+	status = doRequest(http.MethodPut, fileContents[100:200], map[string]string{"Content-Range": "bytes 100-199/*"})
+	assert.Equal(t, http.StatusCreated, status)
+	status = doRequest(http.MethodPatch, fileContents[200:250], map[string]string{
+		"Content-Type":   partialUpdateContentType,
+		"X-Update-Range": "bytes=-50",
+	})
+	assert.Equal(t, http.StatusBadRequest, status)
+	status = doRequest(http.MethodPatch, fileContents[200:250], map[string]string{
+		"Content-Type":   partialUpdateContentType,
+		"X-Update-Range": "bytes=200-",
+	})
+	assert.Equal(t, http.StatusCreated, status)
+	status = doRequest(http.MethodPatch, fileContents[250:], map[string]string{
+		"Content-Type":   partialUpdateContentType,
+		"X-Update-Range": "append",
+	})
+	assert.Equal(t, http.StatusCreated, status)
+	data, err := os.ReadFile(filepath.Join(user.GetHomeDir(), testFileName))
+	assert.NoError(t, err)
+	assert.Equal(t, fileContents, data)
+	user, _, err = httpdtest.GetUserByUsername(user.Username, http.StatusOK)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, user.UsedQuotaFiles)
+	assert.Equal(t, int64(len(fileContents)), user.UsedQuotaSize)
+	// PATCH requests without the SabreDAV content type are not supported
+	status = doRequest(http.MethodPatch, fileContents, nil)
+	assert.Equal(t, http.StatusUnsupportedMediaType, status)
+	// the max upload file size is enforced
+	user.Filters.MaxUploadFileSize = 350
+	_, _, err = httpdtest.UpdateUser(user, http.StatusOK, "")
+	assert.NoError(t, err)
+	status = doRequest(http.MethodPut, fileContents[:100], map[string]string{"Content-Range": "bytes 300-399/400"})
+	assert.NotEqual(t, http.StatusCreated, status)
+
+	_, err = httpdtest.RemoveUser(user, http.StatusOK)
+	assert.NoError(t, err)
+	err = os.RemoveAll(user.GetHomeDir())
+	assert.NoError(t, err)
+}
+
 func TestRenameWithLock(t *testing.T) {
 	u := getTestUser()
 	user, _, err := httpdtest.AddUser(u, http.StatusCreated)